@@ -0,0 +1,94 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+
+	api_http "github.com/mendersoftware/inventory/api/http"
+	inventory "github.com/mendersoftware/inventory/inv"
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store/mongo"
+)
+
+// anonymizeKeySize is the size of the random pseudonymization keys.
+const anonymizeKeySize = 32
+
+// makeAnonymizeOptions returns the options of the anonymized export; without
+// the key the pseudonyms are random, differing from run to run.
+func makeAnonymizeOptions(key string, attributes []string) (model.AnonymizeOptions, error) {
+	opts := model.AnonymizeOptions{Key: []byte(key)}
+	if key == "" {
+		opts.Key = make([]byte, anonymizeKeySize)
+		if _, err := rand.Read(opts.Key); err != nil {
+			return opts, errors.Wrap(err, "failed to generate the key")
+		}
+	}
+	for _, attr := range attributes {
+		ref, err := api_http.ParseAttributeRef(attr)
+		if err != nil {
+			return opts, err
+		}
+		opts.Attributes = append(opts.Attributes, model.SelectAttribute{
+			Scope:     ref.Scope,
+			Attribute: ref.Name,
+		})
+	}
+	return opts, nil
+}
+
+func cmdAnonymize(args *cli.Context) error {
+	l := log.New(log.Ctx{})
+
+	opts, err := makeAnonymizeOptions(args.String("key"), args.StringSlice("attribute"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	db, err := mongo.NewDataStoreMongo(makeDataStoreConfig())
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("failed to connect to db: %v", err),
+			3)
+	}
+
+	var out io.Writer = os.Stdout
+	if path := args.String("out"); path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return cli.NewExitError(
+				fmt.Sprintf("failed to create the output: %v", err),
+				5)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	inv := inventory.NewInventory(db)
+	count, err := inv.ExportAnonymized(tenantContext(args.String("tenant")), out, opts)
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("failed to export devices: %v", err),
+			5)
+	}
+	l.Infof("exported %d anonymized devices", count)
+	return nil
+}
@@ -0,0 +1,42 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+func TestMakeAnonymizeOptions(t *testing.T) {
+	opts, err := makeAnonymizeOptions("secret", []string{"tags", "inventory/owner"})
+	assert.NoError(t, err)
+	assert.Equal(t, model.AnonymizeOptions{
+		Key: []byte("secret"),
+		Attributes: []model.SelectAttribute{
+			{Scope: "tags"},
+			{Scope: "inventory", Attribute: "owner"},
+		},
+	}, opts)
+
+	opts, err = makeAnonymizeOptions("", nil)
+	assert.NoError(t, err)
+	assert.Len(t, opts.Key, anonymizeKeySize)
+
+	_, err = makeAnonymizeOptions("secret", []string{"inventory/"})
+	assert.EqualError(t, err, `invalid attribute: "inventory/"`)
+}
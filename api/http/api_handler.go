@@ -14,6 +14,8 @@
 package http
 
 import (
+	"strings"
+
 	"github.com/ant0ine/go-json-rest/rest"
 )
 
@@ -21,4 +23,52 @@ import (
 type ApiHandler interface {
 	// produce a rest.App with routing setup or an error
 	GetApp() (rest.App, error)
+	// produce a rest.App routing only the given route groups; the route
+	// middlewares run once the endpoint class of the request is known
+	GetGroupsApp(groups []RouteGroup, mws ...rest.Middleware) (rest.App, error)
+}
+
+// route is an endpoint of the API together with the class of access
+// it grants.
+type route struct {
+	class EndpointClass
+	*rest.Route
+}
+
+// RouteGroup is the audience of a set of routes, which can be served by
+// a listener of its own.
+type RouteGroup string
+
+const (
+	RouteGroupDevices    RouteGroup = "devices"
+	RouteGroupManagement RouteGroup = "management"
+	RouteGroupInternal   RouteGroup = "internal"
+)
+
+// RouteGroups lists all the route groups.
+var RouteGroups = []RouteGroup{
+	RouteGroupDevices,
+	RouteGroupManagement,
+	RouteGroupInternal,
+}
+
+// RouteGroupOf returns the group of the route with the given path.
+func RouteGroupOf(path string) RouteGroup {
+	switch {
+	case strings.HasPrefix(path, "/api/internal/"):
+		return RouteGroupInternal
+	case path == uriAttributes:
+		return RouteGroupDevices
+	default:
+		return RouteGroupManagement
+	}
+}
+
+func routeGroupIn(group RouteGroup, groups []RouteGroup) bool {
+	for _, g := range groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
 }
@@ -16,7 +16,15 @@ package http
 
 import (
 	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
 	"net/http"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -28,8 +36,12 @@ import (
 	"github.com/mendersoftware/go-lib-micro/rest_utils"
 	u "github.com/mendersoftware/go-lib-micro/rest_utils"
 	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/yaml.v2"
 
+	"github.com/mendersoftware/inventory/events"
 	inventory "github.com/mendersoftware/inventory/inv"
+	"github.com/mendersoftware/inventory/metrics"
 	"github.com/mendersoftware/inventory/model"
 	"github.com/mendersoftware/inventory/store"
 	"github.com/mendersoftware/inventory/utils"
@@ -41,37 +53,190 @@ const (
 	uriDevice        = "/api/0.1.0/devices/:id"
 	uriDeviceGroups  = "/api/0.1.0/devices/:id/group"
 	uriDeviceGroup   = "/api/0.1.0/devices/:id/group/:name"
+	uriDevChildren   = "/api/0.1.0/devices/:id/children"
+	uriDevComplete   = "/api/0.1.0/devices/:id/completeness"
+	uriDevMerged     = "/api/0.1.0/devices/:id/merged"
+	uriDevTemplate   = "/api/0.1.0/devices/:id/template"
+	uriDevicesGet    = "/api/0.1.0/devices/get"
+	uriDevicesChange = "/api/0.1.0/devices/changes"
 	uriAttributes    = "/api/0.1.0/attributes"
 	uriGroups        = "/api/0.1.0/groups"
 	uriGroupsDevices = "/api/0.1.0/groups/:name/devices"
+	uriGroupsExport  = uriGroupsDevices + "/export"
 
 	uriInternalAlive         = "/api/internal/v1/inventory/alive"
 	uriInternalHealth        = "/api/internal/v1/inventory/health"
 	uriInternalTenants       = "/api/internal/v1/inventory/tenants"
 	uriInternalDevices       = "/api/internal/v1/inventory/devices"
+	uriInternalStatistics    = "/api/internal/v1/inventory/statistics"
+	uriInternalDatabases     = "/api/internal/v1/inventory/databases"
+	uriInternalMetrics       = "/api/internal/v1/inventory/metrics"
 	urlInternalDevicesStatus = "/api/internal/v1/inventory/tenants/:tenant_id/devices/status/:status"
 	uriInternalDeviceGroups  = "/api/internal/v1/inventory/tenants/:tenant_id/devices/:device_id/groups"
+	urlInternalReconcile     = "/api/internal/v1/inventory/tenants/:tenant_id/reconciliation"
+	urlInternalExternalIDs   = "/api/internal/v1/inventory/tenants/:tenant_id/external_ids"
+	urlInternalExternalID    = urlInternalExternalIDs + "/:system/:id"
 	urlInternalAttributes    = "/api/internal/v1/inventory/tenants/:tenant_id/device/:device_id/attribute/scope/:scope"
+	urlInternalFeatureFlags  = "/api/internal/v1/inventory/tenants/:tenant_id/feature_flags"
+	urlInternalLimits        = "/api/internal/v1/inventory/tenants/:tenant_id/limits"
+	urlInternalDeadLetters   = "/api/internal/v1/inventory/tenants/:tenant_id/dead_letters"
+	urlInternalDeadLetter    = urlInternalDeadLetters + "/:id"
+	urlInternalReplayLetter  = urlInternalDeadLetter + "/replay"
+	urlInternalReplayLetters = urlInternalDeadLetters + "/replay"
+	urlInternalCatalogWarmUp = "/api/internal/v1/inventory/tenants/:tenant_id/catalog/warmup"
+	urlInternalDeployDone    = "/api/internal/v1/inventory/tenants/:tenant_id/deployments/:id/finished"
+	urlInternalTimeline      = "/api/internal/v1/inventory/tenants/:tenant_id/timeline"
+	urlInternalEligibility   = "/api/internal/v1/inventory/tenants/:tenant_id/devices/:device_id/eligibility"
 	apiUrlManagementV2       = "/api/management/v2/inventory"
 	urlFiltersAttributes     = apiUrlManagementV2 + "/filters/attributes"
 	urlFiltersSearch         = apiUrlManagementV2 + "/filters/search"
+	urlFiltersValidate       = apiUrlManagementV2 + "/filters/validate"
+	urlConfigBundle          = apiUrlManagementV2 + "/bundle"
+	urlGroupsV2              = apiUrlManagementV2 + "/groups"
+	urlGroupV2               = urlGroupsV2 + "/:name"
+	urlGroupsPreview         = urlGroupsV2 + "/preview"
+	urlGroupAssignment       = urlGroupV2 + "/assignment"
+	urlGroupAssignPreview    = urlGroupAssignment + "/preview"
+	urlDynamicGroups         = urlGroupsV2 + "/dynamic"
+	urlDynamicGroup          = urlDynamicGroups + "/:name"
+	urlGroupsMetadata        = urlGroupsV2 + "/metadata"
+	urlGroupMetadata         = urlGroupsMetadata + "/:name"
+	urlGroupsCompleteness    = urlGroupsV2 + "/completeness"
+	urlGroupsCountsStream    = urlGroupsV2 + "/counts/stream"
+	urlScopes                = apiUrlManagementV2 + "/scopes"
+	urlScope                 = urlScopes + "/:name"
+	urlSchemaAttributes      = apiUrlManagementV2 + "/schema/attributes"
+	urlSchemaAttribute       = urlSchemaAttributes + "/:scope/:name"
+	urlSchemaViolations      = apiUrlManagementV2 + "/schema/violations"
+	urlAttributeGraph        = apiUrlManagementV2 + "/graph/attributes/:scope/:name"
+	urlAttributePivot        = apiUrlManagementV2 + "/pivot/attributes"
+	urlValidationWebhook     = apiUrlManagementV2 + "/schema/validation_webhook"
+	urlIncompleteDevices     = apiUrlManagementV2 + "/schema/incomplete_devices"
+	urlCompletenessAlert     = apiUrlManagementV2 + "/schema/completeness_alert"
+	urlAttributeDescriptions = apiUrlManagementV2 + "/schema/descriptions"
+	urlPinnedAttributes      = apiUrlManagementV2 + "/settings/pinned_attributes"
+	urlAttributeAliases      = apiUrlManagementV2 + "/settings/attribute_aliases"
+	urlDigest                = apiUrlManagementV2 + "/settings/digest"
+	urlDigestPreview         = urlDigest + "/preview"
+	urlSavedFilters          = apiUrlManagementV2 + "/saved_filters"
+	urlSavedFiltersPrivate   = urlSavedFilters + "/private"
+	urlSavedFiltersShared    = urlSavedFilters + "/shared"
+	urlSavedFilter           = urlSavedFilters + "/:id"
+	urlSavedFilterDevices    = urlSavedFilter + "/devices"
+	urlSubscriptions         = apiUrlManagementV2 + "/subscriptions"
+	urlSubscription          = urlSubscriptions + "/:id"
+	urlExports               = apiUrlManagementV2 + "/exports"
+	urlExport                = urlExports + "/:id"
+	urlTagsImport            = apiUrlManagementV2 + "/tags/import"
+	urlTagsAssignment        = apiUrlManagementV2 + "/tags/assignment"
 
 	apiUrlInternalV2         = "/api/internal/v2/inventory"
 	urlInternalFiltersSearch = apiUrlInternalV2 + "/tenants/:tenant_id/filters/search"
-
-	hdrTotalCount = "X-Total-Count"
+	urlInternalSearchExplain = apiUrlInternalV2 + "/tenants/:tenant_id/debug/explain"
+
+	hdrTotalCount     = "X-Total-Count"
+	hdrPartialResults = "X-Partial-Results"
+	hdrNextCursor     = "X-Next-Cursor"
+	hdrWarning        = "Warning"
+	hdrRetryAfter     = "Retry-After"
+
+	contentTypeYAML   = "application/x-yaml"
+	contentTypeNDJSON = "application/x-ndjson"
+	contentTypeCSV    = "text/csv"
+	contentTypeSSE    = "text/event-stream"
 )
 
+// groupCountsKeepAlive is the interval of the comments sent on the idle
+// streams of the group counts, keeping the proxies from closing them.
+const groupCountsKeepAlive = 30 * time.Second
+
 const (
 	queryParamGroup          = "group"
 	queryParamSort           = "sort"
 	queryParamHasGroup       = "has_group"
 	queryParamValueSeparator = ":"
 	queryParamScopeSeparator = "/"
-	sortOrderAsc             = "asc"
-	sortOrderDesc            = "desc"
-	sortAttributeNameIdx     = 0
-	sortOrderIdx             = 1
+	queryParamAttributes     = "attributes"
+	queryParamFormat         = "format"
+	queryParamName           = "name"
+	queryParamTimezone       = "tz"
+	queryParamIdentity       = "identity"
+	queryParamAgent          = "agent"
+	queryParamPrecedence     = "precedence"
+
+	// queryValueNull selects the devices without the attribute, e.g.
+	// group=null
+	queryValueNull = "null"
+
+	// queryParamListSeparator separates the values of the in and nin
+	// filters, e.g. attr=in:a,b
+	queryParamListSeparator = ","
+
+	// queryParamOr lists the alternative filters, separated by
+	// queryParamConditionSeparator, e.g. or=group=a|group=b
+	queryParamOr                 = "or"
+	queryParamConditionSeparator = "|"
+
+	// queryParamSearch is the text searched in the IDs and the attribute
+	// values of the devices, e.g. search=kitchen
+	queryParamSearch = "search"
+
+	// queryParamUpdatedSince lists the devices updated since the time,
+	// e.g. updated_since=2021-06-01T12:00:00Z; queryParamAfterID resumes
+	// the listing after the last device returned
+	queryParamUpdatedSince = "updated_since"
+	queryParamAfterID      = "after_id"
+
+	// queryParamSinceToken resumes the change feed after the changes
+	// already consumed; queryParamLimit bounds the changes returned
+	queryParamSinceToken = "since_token"
+	queryParamLimit      = "limit"
+
+	// queryParamCursor selects the keyset pagination of the devices,
+	// resuming after the cursor returned in the hdrNextCursor header of
+	// the previous page; an empty cursor starts from the first device
+	queryParamCursor = "cursor"
+
+	// queryParamInclude adds the attributes of the cold scopes to the
+	// device, e.g. include=cold
+	queryParamInclude = "include"
+	includeCold       = "cold"
+
+	// queryParamFull lists the devices of a group with all their
+	// attributes in place of their IDs, e.g. full=true
+	queryParamFull = "full"
+
+	// queryParamRecursive lists the devices of a group together with
+	// the devices of its descendant groups, e.g. recursive=true
+	queryParamRecursive = "recursive"
+
+	// queryParamCountOnly returns the number of the devices matching
+	// the filters in place of the devices, e.g. count_only=true
+	queryParamCountOnly = "count_only"
+
+	// queryParamCounts lists the groups together with the number of
+	// their devices, e.g. counts=true
+	queryParamCounts = "counts"
+
+	// queryParamExplainEmpty reports the filter terms eliminating all
+	// the devices of the searches without results, e.g. explain_empty=true
+	queryParamExplainEmpty = "explain_empty"
+
+	// queryParamRows and queryParamColumns name the attributes counted
+	// by the pivot, e.g. rows=inventory/device_type
+	queryParamRows    = "rows"
+	queryParamColumns = "columns"
+
+	sortOrderAsc         = "asc"
+	sortOrderDesc        = "desc"
+	sortAttributeNameIdx = 0
+	sortOrderIdx         = 1
+	sortNullsIdx         = 2
+)
+
+const (
+	exportFormatCSV    = "csv"
+	exportFormatNDJSON = "ndjson"
 )
 
 const (
@@ -99,40 +264,166 @@ func NewInventoryApiHandlers(i inventory.InventoryApp) ApiHandler {
 }
 
 func (i *inventoryHandlers) GetApp() (rest.App, error) {
-	routes := []*rest.Route{
-		rest.Get(uriInternalAlive, i.LivelinessHandler),
-		rest.Get(uriInternalHealth, i.HealthCheckHandler),
-
-		rest.Get(uriDevices, i.GetDevicesHandler),
-		rest.Get(uriDevice, i.GetDeviceHandler),
-		rest.Delete(uriDevice, i.DeleteDeviceHandler),
-		rest.Delete(uriDeviceGroup, i.DeleteDeviceGroupHandler),
-		rest.Delete(uriGroupsDevices, i.ClearDevicesGroup),
-		rest.Patch(uriAttributes, i.UpdateDeviceAttributesHandler),
-		rest.Put(uriAttributes, i.UpdateDeviceAttributesHandler),
-		rest.Patch(urlInternalAttributes, i.PatchDeviceAttributesInternalHandler),
-		rest.Put(uriDeviceGroups, i.AddDeviceToGroupHandler),
-		rest.Patch(uriGroupsDevices, i.AppendDevicesToGroup),
-		rest.Get(uriDeviceGroups, i.GetDeviceGroupHandler),
-		rest.Get(uriGroups, i.GetGroupsHandler),
-		rest.Get(uriGroupsDevices, i.GetDevicesByGroup),
-
-		rest.Post(uriInternalTenants, i.CreateTenantHandler),
-		rest.Post(uriInternalDevices, i.AddDeviceHandler),
-		rest.Post(urlInternalDevicesStatus, i.InternalDevicesStatusHandler),
-		rest.Get(uriInternalDeviceGroups, i.GetDeviceGroupsInternalHandler),
-		rest.Get(urlFiltersAttributes, i.FiltersAttributesHandler),
-		rest.Post(urlFiltersSearch, i.FiltersSearchHandler),
-
-		rest.Post(urlInternalFiltersSearch, i.InternalFiltersSearchHandler),
-	}
-
-	routes = append(routes)
-
-	app, err := rest.MakeRouter(
-		// augment routes with OPTIONS handler
-		AutogenOptionsRoutes(routes, AllowHeaderOptionsGenerator)...,
-	)
+	return i.GetGroupsApp(RouteGroups)
+}
+
+func (i *inventoryHandlers) GetGroupsApp(
+	groups []RouteGroup,
+	mws ...rest.Middleware,
+) (rest.App, error) {
+	routes := []route{
+		{EndpointClassAdmin, rest.Get(uriInternalAlive, i.LivelinessHandler)},
+		{EndpointClassAdmin, rest.Get(uriInternalHealth, i.HealthCheckHandler)},
+
+		{EndpointClassRead, rest.Get(uriDevices, i.GetDevicesHandler)},
+		// defined before uriDevice, which matches it as well
+		{EndpointClassRead, rest.Get(uriDevicesChange, i.ListDeviceChangesHandler)},
+		{EndpointClassRead, rest.Get(uriDevice, i.GetDeviceHandler)},
+		{EndpointClassRead, rest.Post(uriDevicesGet, i.GetDevicesByIDsHandler)},
+		{EndpointClassAdmin, rest.Delete(uriDevice, i.DeleteDeviceHandler)},
+		{EndpointClassGroups, rest.Delete(uriDeviceGroup, i.DeleteDeviceGroupHandler)},
+		{EndpointClassGroups, rest.Delete(uriGroupsDevices, i.ClearDevicesGroup)},
+		{EndpointClassDevice, rest.Patch(uriAttributes, i.UpdateDeviceAttributesHandler)},
+		{EndpointClassDevice, rest.Put(uriAttributes, i.UpdateDeviceAttributesHandler)},
+		{EndpointClassAdmin, rest.Patch(urlInternalAttributes, i.PatchDeviceAttributesInternalHandler)},
+		{EndpointClassGroups, rest.Put(uriDeviceGroups, i.AddDeviceToGroupHandler)},
+		{EndpointClassGroups, rest.Patch(uriGroupsDevices, i.AppendDevicesToGroup)},
+		{EndpointClassRead, rest.Get(uriDeviceGroups, i.GetDeviceGroupHandler)},
+		{EndpointClassRead, rest.Get(uriDevChildren, i.GetDeviceChildrenHandler)},
+		{EndpointClassRead, rest.Get(uriDevComplete, i.GetDeviceCompletenessHandler)},
+		{EndpointClassRead, rest.Get(uriDevMerged, i.GetDeviceMergedHandler)},
+		{EndpointClassAdmin, rest.Post(uriDevTemplate, i.ApplyDeviceTemplateHandler)},
+		{EndpointClassRead, rest.Get(uriGroups, i.GetGroupsHandler)},
+		{EndpointClassRead, rest.Get(uriGroupsDevices, i.GetDevicesByGroup)},
+		{EndpointClassRead, rest.Get(uriGroupsExport, i.ExportGroupDevicesHandler)},
+
+		{EndpointClassAdmin, rest.Post(uriInternalTenants, i.CreateTenantHandler)},
+		{EndpointClassAdmin, rest.Post(uriInternalDevices, i.AddDeviceHandler)},
+		{EndpointClassAdmin, rest.Post(urlInternalDevicesStatus, i.InternalDevicesStatusHandler)},
+		{EndpointClassAdmin, rest.Get(uriInternalDeviceGroups, i.GetDeviceGroupsInternalHandler)},
+		{EndpointClassAdmin, rest.Post(urlInternalReconcile, i.InternalReconcileDevicesHandler)},
+		{EndpointClassAdmin, rest.Put(urlInternalExternalIDs, i.InternalUpsertExternalIDsHandler)},
+		{EndpointClassAdmin, rest.Delete(urlInternalExternalID, i.InternalDeleteExternalIDHandler)},
+		{EndpointClassAdmin, rest.Get(urlInternalFeatureFlags, i.InternalGetFeatureFlagsHandler)},
+		{EndpointClassAdmin, rest.Patch(urlInternalFeatureFlags, i.InternalUpdateFeatureFlagsHandler)},
+		{EndpointClassAdmin, rest.Get(urlInternalLimits, i.InternalGetLimitsHandler)},
+		{EndpointClassAdmin, rest.Put(urlInternalLimits, i.InternalSetLimitsHandler)},
+		{EndpointClassAdmin, rest.Get(urlInternalDeadLetters, i.InternalListDeadLettersHandler)},
+		{EndpointClassAdmin, rest.Delete(urlInternalDeadLetters, i.InternalPurgeDeadLettersHandler)},
+		{EndpointClassAdmin, rest.Delete(urlInternalDeadLetter, i.InternalDeleteDeadLetterHandler)},
+		{EndpointClassAdmin, rest.Post(urlInternalReplayLetter, i.InternalReplayDeadLetterHandler)},
+		{EndpointClassAdmin, rest.Post(urlInternalReplayLetters, i.InternalReplayDeadLettersHandler)},
+		{EndpointClassAdmin, rest.Post(urlInternalCatalogWarmUp, i.InternalWarmUpCatalogHandler)},
+		{EndpointClassAdmin, rest.Post(urlInternalDeployDone, i.InternalDeploymentFinishedHandler)},
+		{EndpointClassAdmin, rest.Post(urlInternalTimeline, i.InternalIngestTimelineHandler)},
+		{EndpointClassAdmin, rest.Post(urlInternalEligibility, i.InternalDeviceEligibilityHandler)},
+		{EndpointClassAdmin, rest.Get(uriInternalStatistics, i.InternalAttributeStatisticsHandler)},
+		{EndpointClassAdmin, rest.Get(uriInternalDatabases, i.InternalListTenantDatabasesHandler)},
+		{EndpointClassAdmin, rest.Get(uriInternalMetrics, i.InternalMetricsHandler)},
+		{EndpointClassRead, rest.Get(urlFiltersAttributes, i.FiltersAttributesHandler)},
+		{EndpointClassRead, rest.Post(urlFiltersSearch, i.FiltersSearchHandler)},
+		{EndpointClassRead, rest.Post(urlFiltersValidate, i.FiltersValidateHandler)},
+		{EndpointClassRead, rest.Get(urlConfigBundle, i.ExportConfigBundleHandler)},
+		{EndpointClassAdmin, rest.Post(urlConfigBundle, i.ImportConfigBundleHandler)},
+		{EndpointClassRead, rest.Get(urlGroupsV2, i.ListGroupsV2Handler)},
+		{EndpointClassGroups, rest.Put(urlGroupV2, i.ReplaceGroupHandler)},
+		{EndpointClassGroups, rest.Delete(urlGroupV2, i.DeleteGroupHandler)},
+		{EndpointClassRead, rest.Post(urlGroupsPreview, i.PreviewGroupHandler)},
+		{EndpointClassGroups, rest.Post(urlGroupAssignment, i.AssignGroupHandler)},
+		{EndpointClassRead, rest.Post(urlGroupAssignPreview, i.PreviewGroupAssignmentHandler)},
+		{EndpointClassRead, rest.Get(urlDynamicGroups, i.ListDynamicGroupsHandler)},
+		{EndpointClassRead, rest.Get(urlDynamicGroup, i.GetDynamicGroupHandler)},
+		{EndpointClassGroups, rest.Put(urlDynamicGroup, i.ReplaceDynamicGroupHandler)},
+		{EndpointClassGroups, rest.Delete(urlDynamicGroup, i.DeleteDynamicGroupHandler)},
+		{EndpointClassRead, rest.Get(urlGroupsMetadata, i.ListGroupsMetadataHandler)},
+		{EndpointClassRead, rest.Get(urlGroupMetadata, i.GetGroupMetadataHandler)},
+		{EndpointClassGroups, rest.Put(urlGroupMetadata, i.ReplaceGroupMetadataHandler)},
+		{EndpointClassGroups, rest.Delete(urlGroupMetadata, i.DeleteGroupMetadataHandler)},
+		{EndpointClassRead, rest.Get(urlGroupsCompleteness, i.GetGroupsCompletenessHandler)},
+		{EndpointClassRead, rest.Get(urlGroupsCountsStream, i.GroupCountsStreamHandler)},
+		{EndpointClassRead, rest.Get(urlScopes, i.ListScopesHandler)},
+		{EndpointClassAdmin, rest.Put(urlScope, i.ReplaceScopeHandler)},
+		{EndpointClassAdmin, rest.Delete(urlScope, i.DeleteScopeHandler)},
+		{EndpointClassRead, rest.Get(urlSchemaAttributes, i.ListAttributeDefinitionsHandler)},
+		{EndpointClassAdmin, rest.Put(urlSchemaAttribute, i.ReplaceAttributeDefinitionHandler)},
+		{EndpointClassAdmin, rest.Delete(urlSchemaAttribute, i.DeleteAttributeDefinitionHandler)},
+		{EndpointClassRead, rest.Get(urlSchemaViolations, i.ListSchemaViolationsHandler)},
+		{EndpointClassRead, rest.Get(urlAttributeGraph, i.GetAttributeGraphHandler)},
+		{EndpointClassRead, rest.Get(urlAttributePivot, i.GetAttributePivotHandler)},
+		{EndpointClassRead, rest.Get(urlValidationWebhook, i.GetValidationWebhookHandler)},
+		{EndpointClassAdmin, rest.Put(urlValidationWebhook, i.SetValidationWebhookHandler)},
+		{EndpointClassAdmin, rest.Delete(urlValidationWebhook, i.DeleteValidationWebhookHandler)},
+		{EndpointClassRead, rest.Get(urlIncompleteDevices, i.ListIncompleteDevicesHandler)},
+		{EndpointClassRead, rest.Get(urlCompletenessAlert, i.GetCompletenessAlertHandler)},
+		{EndpointClassAdmin, rest.Put(urlCompletenessAlert, i.SetCompletenessAlertHandler)},
+		{EndpointClassAdmin, rest.Delete(urlCompletenessAlert, i.DeleteCompletenessAlertHandler)},
+		{EndpointClassRead, rest.Get(urlAttributeDescriptions, i.GetAttributeDescriptionsHandler)},
+		{EndpointClassAdmin, rest.Put(urlAttributeDescriptions, i.SetAttributeDescriptionsHandler)},
+		{EndpointClassAdmin, rest.Patch(urlAttributeDescriptions, i.UpdateAttributeDescriptionsHandler)},
+		{EndpointClassRead, rest.Get(urlDigest, i.GetDigestSettingsHandler)},
+		{EndpointClassAdmin, rest.Put(urlDigest, i.SetDigestSettingsHandler)},
+		{EndpointClassAdmin, rest.Delete(urlDigest, i.DeleteDigestSettingsHandler)},
+		{EndpointClassRead, rest.Get(urlDigestPreview, i.PreviewDigestHandler)},
+		{EndpointClassRead, rest.Get(urlPinnedAttributes, i.GetPinnedAttributesHandler)},
+		{EndpointClassAdmin, rest.Put(urlPinnedAttributes, i.SetPinnedAttributesHandler)},
+		{EndpointClassRead, rest.Get(urlAttributeAliases, i.GetAttributeAliasesHandler)},
+		{EndpointClassAdmin, rest.Put(urlAttributeAliases, i.SetAttributeAliasesHandler)},
+		{EndpointClassRead, rest.Get(urlSavedFiltersPrivate, i.ListPrivateSavedFiltersHandler)},
+		{EndpointClassRead, rest.Get(urlSavedFiltersShared, i.ListSharedSavedFiltersHandler)},
+		{EndpointClassAdmin, rest.Post(urlSavedFilters, i.CreateSavedFilterHandler)},
+		{EndpointClassRead, rest.Get(urlSavedFilter, i.GetSavedFilterHandler)},
+		{EndpointClassAdmin, rest.Put(urlSavedFilter, i.ReplaceSavedFilterHandler)},
+		{EndpointClassAdmin, rest.Delete(urlSavedFilter, i.DeleteSavedFilterHandler)},
+		{EndpointClassRead, rest.Get(urlSavedFilterDevices, i.SearchSavedFilterHandler)},
+		{EndpointClassRead, rest.Get(urlSubscriptions, i.ListSubscriptionsHandler)},
+		{EndpointClassAdmin, rest.Post(urlSubscriptions, i.CreateSubscriptionHandler)},
+		{EndpointClassAdmin, rest.Put(urlSubscription, i.ReplaceSubscriptionHandler)},
+		{EndpointClassAdmin, rest.Delete(urlSubscription, i.DeleteSubscriptionHandler)},
+		{EndpointClassRead, rest.Post(urlExports, i.StartExportHandler)},
+		{EndpointClassRead, rest.Get(urlExport, i.GetExportJobHandler)},
+		{EndpointClassTags, rest.Post(urlTagsImport, i.ImportTagsHandler)},
+		{EndpointClassTags, rest.Post(urlTagsAssignment, i.AssignTagsHandler)},
+
+		{EndpointClassAdmin, rest.Post(urlInternalFiltersSearch, i.InternalFiltersSearchHandler)},
+		{EndpointClassAdmin, rest.Post(urlInternalSearchExplain, i.InternalExplainSearchHandler)},
+	}
+
+	return makeRouter(routes, groups, mws)
+}
+
+// makeRouter returns the router of the routes of the groups. The class of
+// the endpoint is stored in the request context before the middlewares run;
+// a route without a class is an error.
+func makeRouter(
+	routes []route,
+	groups []RouteGroup,
+	mws []rest.Middleware,
+) (rest.App, error) {
+	served := make([]*rest.Route, 0, len(routes))
+	classes := make([]EndpointClass, 0, len(routes))
+	for _, rt := range routes {
+		if rt.class == "" {
+			return nil, errors.Errorf("route %s %s has no endpoint class",
+				rt.HttpMethod, rt.PathExp)
+		}
+		if routeGroupIn(RouteGroupOf(rt.PathExp), groups) {
+			served = append(served, rt.Route)
+			classes = append(classes, rt.class)
+		}
+	}
+
+	// augment routes with OPTIONS handler
+	served = AutogenOptionsRoutes(served, AllowHeaderOptionsGenerator)
+	for n, rt := range served {
+		// the OPTIONS handlers only list the methods of the route
+		class := EndpointClassRead
+		if n < len(classes) {
+			class = classes[n]
+		}
+		rt.Func = withEndpointClass(class, rest.WrapMiddlewares(mws, rt.Func))
+	}
+
+	app, err := rest.MakeRouter(served...)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create router")
 	}
@@ -161,18 +452,42 @@ func (i *inventoryHandlers) HealthCheckHandler(w rest.ResponseWriter, r *rest.Re
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// `sort` paramater value is an attribute name with optional direction (desc or asc)
-// separated by colon (:)
+// `sort` paramater value is a comma-separated list of attribute names with
+// optional direction (desc or asc) separated by colon (:); the devices are
+// sorted by the attributes in order, the parameter can also be repeated
 //
-// eg. `sort=attr_name1` or `sort=attr_name1:asc`
-func parseSortParam(r *rest.Request) (*store.Sort, error) {
-	sortStr, err := utils.ParseQueryParmStr(r, queryParamSort, false, nil)
-	if err != nil {
-		return nil, err
-	}
-	if sortStr == "" {
-		return nil, nil
+// eg. `sort=attr_name1` or `sort=attr_name1:asc,attr_name2:desc`
+func parseSortParam(r *rest.Request) ([]store.Sort, error) {
+	var sorts []store.Sort
+	seen := make(map[string]bool)
+	for _, param := range r.URL.Query()[queryParamSort] {
+		for _, sortStr := range strings.Split(param, queryParamListSeparator) {
+			if sortStr == "" {
+				continue
+			}
+			sort, err := parseSortKey(sortStr)
+			if err != nil {
+				return nil, err
+			}
+			key := sort.AttrScope + queryParamScopeSeparator + sort.AttrName
+			if seen[key] {
+				return nil, errors.Errorf("duplicate sort attribute: %s", key)
+			}
+			seen[key] = true
+			sorts = append(sorts, *sort)
+		}
 	}
+	return sorts, nil
+}
+
+// sortNulls are the placements of the devices without the sort attribute,
+// following the order as in attr:asc:nulls_last.
+var sortNulls = map[string]string{
+	"nulls_first": model.SortNullsFirst,
+	"nulls_last":  model.SortNullsLast,
+}
+
+func parseSortKey(sortStr string) (*store.Sort, error) {
 	sortValArray := strings.Split(sortStr, queryParamValueSeparator)
 	attrNameWithScope := strings.SplitN(sortValArray[sortAttributeNameIdx], queryParamScopeSeparator, 2)
 	var scope, attrName string
@@ -184,25 +499,64 @@ func parseSortParam(r *rest.Request) (*store.Sort, error) {
 		attrName = attrNameWithScope[1]
 	}
 	sort := store.Sort{AttrName: attrName, AttrScope: scope}
-	if len(sortValArray) == 2 {
+	if len(sortValArray) > sortNullsIdx+1 {
+		return nil, errors.New("invalid sort key")
+	}
+	if len(sortValArray) > sortOrderIdx {
 		sortOrder := sortValArray[sortOrderIdx]
 		if sortOrder != sortOrderAsc && sortOrder != sortOrderDesc {
 			return nil, errors.New("invalid sort order")
 		}
 		sort.Ascending = sortOrder == sortOrderAsc
 	}
+	if len(sortValArray) > sortNullsIdx {
+		nulls, ok := sortNulls[sortValArray[sortNullsIdx]]
+		if !ok {
+			return nil, errors.New("invalid sort nulls placement")
+		}
+		sort.Nulls = nulls
+	}
 	return &sort, nil
 }
 
+// filterOperators are the operators of the filters of the devices
+// listing, prefixing the values as in attr=gte:10.
+var filterOperators = map[string]store.ComparisonOperator{
+	"eq":     store.Eq,
+	"ne":     store.Ne,
+	"gt":     store.Gt,
+	"gte":    store.Gte,
+	"lt":     store.Lt,
+	"lte":    store.Lte,
+	"in":     store.In,
+	"nin":    store.Nin,
+	"exists": store.Exists,
+	"regex":  store.Regex,
+	"iregex": store.IRegex,
+}
+
+// substringOperators match the values containing the substring, as the
+// regular expression of the escaped substring.
+var substringOperators = map[string]store.ComparisonOperator{
+	"contains":  store.Regex,
+	"icontains": store.IRegex,
+}
+
 // Filter paramaters name are attributes name. Value can be prefixed
-// with equality operator code (`eq` for =), separated from value by colon (:).
-// Equality operator default value is `eq`
+// with the operator code (see filterOperators), separated from value by
+// colon (:). Equality operator default value is `eq`; the values of `in`
+// and `nin` are separated by commas. The `contains` and `icontains`
+// operators match the substrings of the values.
 //
-// eg. `attr_name1=value1` or `attr_name1=eq:value1`
-func parseFilterParams(r *rest.Request) ([]store.Filter, error) {
-	knownParams := []string{utils.PageName, utils.PerPageName, queryParamSort, queryParamHasGroup, queryParamGroup}
+// eg. `attr_name1=value1`, `attr_name1=eq:value1`, `attr_name1=in:a,b`
+// or `attr_name1=icontains:foo`
+//
+// The extra parameters of the endpoint are not parsed as filters.
+func parseFilterParams(r *rest.Request, extraParams ...string) ([]store.Filter, error) {
+	knownParams := []string{utils.PageName, utils.PerPageName, queryParamSort, queryParamHasGroup, queryParamGroup, queryParamOr, queryParamSearch,
+		queryParamUpdatedSince, queryParamAfterID, queryParamCursor, queryParamCountOnly}
+	knownParams = append(knownParams, extraParams...)
 	filters := make([]store.Filter, 0)
-	var filter store.Filter
 	for name := range r.URL.Query() {
 		if utils.ContainsString(name, knownParams) {
 			continue
@@ -211,53 +565,161 @@ func parseFilterParams(r *rest.Request) ([]store.Filter, error) {
 		if err != nil {
 			return nil, err
 		}
+		filter, err := parseFilter(name, valueStr)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
 
-		attrNameWithScope := strings.SplitN(name, queryParamScopeSeparator, 2)
-		var scope, attrName string
-		if len(attrNameWithScope) == 1 {
-			scope = model.AttrScopeInventory
-			attrName = attrNameWithScope[0]
-		} else {
-			scope = attrNameWithScope[0]
-			attrName = attrNameWithScope[1]
+// parseFilter parses the filter of the attribute name with the value
+// of the query parameter.
+func parseFilter(name, valueStr string) (store.Filter, error) {
+	attrNameWithScope := strings.SplitN(name, queryParamScopeSeparator, 2)
+	var scope, attrName string
+	if len(attrNameWithScope) == 1 {
+		scope = model.AttrScopeInventory
+		attrName = attrNameWithScope[0]
+	} else {
+		scope = attrNameWithScope[0]
+		attrName = attrNameWithScope[1]
+	}
+	filter := store.Filter{AttrName: attrName, AttrScope: scope}
+	substring := false
+
+	// make sure we parse ':'s in value, it's either:
+	// not there
+	// after a valid operator specifier
+	// or/and inside the value itself(mac, etc), in which case leave it alone
+	sepIdx := strings.Index(valueStr, ":")
+	if sepIdx == -1 {
+		filter.Value = valueStr
+		filter.Operator = store.Eq
+	} else {
+		if op, ok := filterOperators[valueStr[:sepIdx]]; ok {
+			filter.Operator = op
+			filter.Value = valueStr[sepIdx+1:]
+		} else if op, ok := substringOperators[valueStr[:sepIdx]]; ok {
+			filter.Operator = op
+			filter.Value = valueStr[sepIdx+1:]
+			substring = filter.Value != ""
 		}
-		filter = store.Filter{AttrName: attrName, AttrScope: scope}
 
-		// make sure we parse ':'s in value, it's either:
-		// not there
-		// after a valid operator specifier
-		// or/and inside the value itself(mac, etc), in which case leave it alone
-		sepIdx := strings.Index(valueStr, ":")
-		if sepIdx == -1 {
+		if filter.Value == "" {
 			filter.Value = valueStr
 			filter.Operator = store.Eq
-		} else {
-			validOps := []string{"eq"}
-			for _, o := range validOps {
-				if valueStr[:sepIdx] == o {
-					switch o {
-					case "eq":
-						filter.Operator = store.Eq
-						filter.Value = valueStr[sepIdx+1:]
-					}
-					break
-				}
-			}
+		}
+	}
 
-			if filter.Value == "" {
-				filter.Value = valueStr
-				filter.Operator = store.Eq
+	switch filter.Operator {
+	case store.In, store.Nin:
+		filter.Values = strings.Split(filter.Value, queryParamListSeparator)
+	case store.Exists:
+		if _, err := strconv.ParseBool(filter.Value); err != nil {
+			return filter, errors.Errorf(
+				"invalid value of the exists operator of %s: %q",
+				name, filter.Value)
+		}
+	case store.Regex, store.IRegex:
+		if substring {
+			if len(filter.Value) > model.RegexMaxLength {
+				return filter, errors.Wrapf(model.ErrRegexTooLong,
+					"invalid substring of %s", name)
 			}
+			filter.Value = regexp.QuoteMeta(filter.Value)
+			return filter, nil
+		}
+		if err := model.ValidateRegex(filter.Value); err != nil {
+			return filter, errors.Wrapf(err,
+				"invalid regular expression of %s", name)
 		}
+		return filter, nil
+	}
+
+	floatValue, err := strconv.ParseFloat(filter.Value, 64)
+	if err == nil {
+		filter.ValueFloat = &floatValue
+	}
+	return filter, nil
+}
+
+// parseSearchParam parses the text of the `search` parameter, searched
+// in the IDs and the attribute values of the devices; the surrounding
+// white space is ignored.
+func parseSearchParam(r *rest.Request) (string, error) {
+	search, err := utils.ParseQueryParmStr(r, queryParamSearch, false, nil)
+	if err != nil {
+		return "", err
+	}
+	search = strings.TrimSpace(search)
+	if len(search) > model.RegexMaxLength {
+		return "", errors.Errorf(
+			"the %s parameter must be at most %d characters long",
+			queryParamSearch, model.RegexMaxLength)
+	}
+	return search, nil
+}
 
-		floatValue, err := strconv.ParseFloat(filter.Value, 64)
-		if err == nil {
-			filter.ValueFloat = &floatValue
+// parseUpdatedSinceParams parses the time the listed devices must have
+// been updated since, and the ID of the device the listing resumes after.
+// The values are unescaped once, so that the time zone offsets can be
+// given with an encoded plus sign.
+func parseUpdatedSinceParams(r *rest.Request) (*time.Time, model.DeviceID, error) {
+	query := r.URL.Query()
+	afterID := model.DeviceID(query.Get(queryParamAfterID))
+	value := query.Get(queryParamUpdatedSince)
+	if value == "" {
+		if afterID != "" {
+			return nil, "", errors.Errorf("the %s parameter requires %s",
+				queryParamAfterID, queryParamUpdatedSince)
 		}
+		return nil, "", nil
+	}
+	since, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil, "", errors.Errorf(
+			"invalid %s: must be an RFC 3339 timestamp", queryParamUpdatedSince)
+	}
+	return &since, afterID, nil
+}
 
-		filters = append(filters, filter)
+// parseFilterGroup parses the `or` parameters: each one lists the
+// conditions, separated by pipes (|), of which at least one must match,
+// and all of them must match the devices. A condition is formatted as
+// a filter parameter; `group` stands for the group of the device.
+//
+// eg. `or=group=prod|group=staging&artifact_name=ne:X`
+func parseFilterGroup(r *rest.Request) (*store.FilterGroup, error) {
+	params := r.URL.Query()[queryParamOr]
+	if len(params) == 0 {
+		return nil, nil
 	}
-	return filters, nil
+	group := &store.FilterGroup{Operator: store.And}
+	for _, param := range params {
+		alternatives := store.FilterGroup{Operator: store.Or}
+		for _, condition := range strings.Split(param, queryParamConditionSeparator) {
+			nameValue := strings.SplitN(condition, "=", 2)
+			if len(nameValue) != 2 || nameValue[0] == "" {
+				return nil, errors.Errorf(
+					"invalid condition of the %s parameter: %q",
+					queryParamOr, condition)
+			}
+			name := nameValue[0]
+			if name == queryParamGroup {
+				name = model.AttrScopeSystem + queryParamScopeSeparator +
+					model.AttrNameGroup
+			}
+			filter, err := parseFilter(name, nameValue[1])
+			if err != nil {
+				return nil, err
+			}
+			alternatives.Filters = append(alternatives.Filters, filter)
+		}
+		group.Groups = append(group.Groups, alternatives)
+	}
+	return group, nil
 }
 
 func (i *inventoryHandlers) GetDevicesHandler(w rest.ResponseWriter, r *rest.Request) {
@@ -282,25 +744,130 @@ func (i *inventoryHandlers) GetDevicesHandler(w rest.ResponseWriter, r *rest.Req
 		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
 		return
 	}
+	if groupName == queryValueNull {
+		// group=null lists the devices without group
+		if hasGroup != nil && *hasGroup {
+			u.RestErrWithLog(w, r, l,
+				errors.New("group=null conflicts with has_group=true"),
+				http.StatusBadRequest,
+			)
+			return
+		}
+		noGroup := false
+		groupName, hasGroup = "", &noGroup
+	}
+
+	countOnly, err := utils.ParseQueryParmBool(r, queryParamCountOnly, false, nil)
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
 
 	sort, err := parseSortParam(r)
 	if err != nil {
 		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
 		return
 	}
+	updatedSince, afterID, err := parseUpdatedSinceParams(r)
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	} else if updatedSince != nil && len(sort) > 0 {
+		// the devices updated since are listed in the order of updates
+		u.RestErrWithLog(w, r, l,
+			errors.Errorf("%s conflicts with %s", queryParamUpdatedSince, queryParamSort),
+			http.StatusBadRequest,
+		)
+		return
+	}
+
+	cursor, err := parseCursorParam(r, sortCriteria(sort))
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	} else if cursor != nil {
+		// the devices following the cursor are listed in the order of
+		// the sort, from the first page
+		for _, param := range []string{utils.PageName, queryParamUpdatedSince} {
+			if _, ok := r.URL.Query()[param]; ok {
+				u.RestErrWithLog(w, r, l,
+					errors.Errorf("%s conflicts with %s", queryParamCursor, param),
+					http.StatusBadRequest,
+				)
+				return
+			}
+		}
+	}
 
 	filters, err := parseFilterParams(r)
 	if err != nil {
 		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
 		return
 	}
+	filterGroup, err := parseFilterGroup(r)
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+	allFilters := append(filters, filterGroup.AllFilters()...)
+	if !checkAttributesVisible(w, r, listQueryAttributes(allFilters, sort)) {
+		return
+	}
+	search, err := parseSearchParam(r)
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+	if search != "" && attributeAccessFromContext(ctx).HidesAny() {
+		// the matches would disclose the values of the hidden attributes
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(ErrAttributeHidden, "the search covers all the attributes"),
+			http.StatusForbidden,
+		)
+		return
+	}
+	if !i.checkLimits(w, r, model.Limits{
+		PerPage: int(perPage),
+		Filters: len(allFilters),
+	}) {
+		return
+	}
 
 	ld := store.ListQuery{Skip: int((page - 1) * perPage),
-		Limit:     int(perPage),
-		Filters:   filters,
-		Sort:      sort,
-		HasGroup:  hasGroup,
-		GroupName: groupName}
+		Limit:          int(perPage),
+		Filters:        filters,
+		FilterGroup:    filterGroup,
+		Sort:           sort,
+		HasGroup:       hasGroup,
+		GroupName:      groupName,
+		Search:         search,
+		UpdatedSince:   updatedSince,
+		UpdatedAfterID: afterID,
+		Cursor:         cursor,
+	}
+
+	if countOnly != nil && *countOnly {
+		count, err := i.inventory.CountDevices(ctx, ld)
+		if err != nil {
+			u.RestErrWithLogInternal(w, r, l, err)
+			return
+		}
+		w.Header().Add("X-Total-Count", strconv.Itoa(count))
+		w.WriteJson(model.DevicesCount{Count: count})
+		return
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), contentTypeNDJSON) {
+		if cursor != nil {
+			u.RestErrWithLog(w, r, l,
+				errors.Errorf("%s conflicts with %s", queryParamCursor, contentTypeNDJSON),
+				http.StatusBadRequest,
+			)
+			return
+		}
+		i.streamDevices(w, r, ld, page, perPage)
+		return
+	}
 
 	devs, totalCount, err := i.inventory.ListDevices(ctx, ld)
 
@@ -309,14 +876,115 @@ func (i *inventoryHandlers) GetDevicesHandler(w rest.ResponseWriter, r *rest.Req
 		return
 	}
 
+	if cursor != nil {
+		if err := writeNextCursor(w, cursor, devs, int(perPage)); err != nil {
+			u.RestErrWithLogInternal(w, r, l, err)
+			return
+		}
+	} else {
+		hasNext := totalCount > int(page*perPage)
+		links := utils.MakePageLinkHdrs(r, page, perPage, hasNext)
+		for _, l := range links {
+			w.Header().Add("Link", l)
+		}
+	}
+	// the response writer will ensure the header name is in Kebab-Pascal-Case
+	w.Header().Add("X-Total-Count", strconv.Itoa(totalCount))
+	hideAttributes(ctx, devs)
+	w.WriteJson(devs)
+}
+
+// parseCursorParam returns the cursor of the keyset pagination of
+// the devices in the order of the sort, or nil without the cursor
+// parameter.
+func parseCursorParam(r *rest.Request, sort []model.SortCriteria) (*model.DeviceCursor, error) {
+	values, ok := r.URL.Query()[queryParamCursor]
+	if !ok {
+		return nil, nil
+	} else if values[0] == "" {
+		return &model.DeviceCursor{Sort: sort}, nil
+	}
+	return model.ParseDeviceCursor(values[0], sort)
+}
+
+// writeNextCursor sets the header of the cursor resuming after the page
+// of devices; a page which is not full is the last one, without cursor.
+func writeNextCursor(
+	w rest.ResponseWriter,
+	cursor *model.DeviceCursor,
+	devs []model.Device,
+	perPage int,
+) error {
+	if len(devs) == 0 || len(devs) < perPage {
+		return nil
+	}
+	next, err := model.NewDeviceCursor(cursor.Sort, devs[len(devs)-1]).Encode()
+	if err != nil {
+		return err
+	}
+	w.Header().Set(hdrNextCursor, next)
+	return nil
+}
+
+// sortCriteria returns the sorting of the devices listing as the sort
+// criteria of the searches.
+func sortCriteria(sort []store.Sort) []model.SortCriteria {
+	criteria := make([]model.SortCriteria, len(sort))
+	for n, s := range sort {
+		criteria[n] = model.SortCriteria{
+			Scope:     s.AttrScope,
+			Attribute: s.AttrName,
+			Order:     sortOrderDesc,
+			Nulls:     s.Nulls,
+		}
+		if s.Ascending {
+			criteria[n].Order = sortOrderAsc
+		}
+	}
+	return criteria
+}
+
+// streamDevices writes the devices as newline-delimited JSON, encoding
+// each device as soon as it is decoded from the database.
+func (i *inventoryHandlers) streamDevices(
+	w rest.ResponseWriter,
+	r *rest.Request,
+	ld store.ListQuery,
+	page, perPage uint64,
+) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	stream, totalCount, err := i.inventory.StreamDevices(ctx, ld)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	defer stream.Close()
+
 	hasNext := totalCount > int(page*perPage)
 	links := utils.MakePageLinkHdrs(r, page, perPage, hasNext)
 	for _, l := range links {
 		w.Header().Add("Link", l)
 	}
-	// the response writer will ensure the header name is in Kebab-Pascal-Case
-	w.Header().Add("X-Total-Count", strconv.Itoa(totalCount))
-	w.WriteJson(devs)
+	w.Header().Add(hdrTotalCount, strconv.Itoa(totalCount))
+	w.Header().Set("Content-Type", contentTypeNDJSON)
+	w.WriteHeader(http.StatusOK)
+
+	// the status is already sent, errors can only be logged from now on
+	access := attributeAccessFromContext(ctx)
+	enc := json.NewEncoder(w.(http.ResponseWriter))
+	for dev := range stream.Devices() {
+		access.FilterDevice(&dev)
+		if err := enc.Encode(dev); err != nil {
+			l.Errorf("failed to write device %s: %v", dev.ID, err)
+			return
+		}
+	}
+	if err := stream.Err(); err != nil {
+		l.Errorf("failed to stream devices: %v", err)
+	}
 }
 
 func (i *inventoryHandlers) GetDeviceHandler(w rest.ResponseWriter, r *rest.Request) {
@@ -324,9 +992,22 @@ func (i *inventoryHandlers) GetDeviceHandler(w rest.ResponseWriter, r *rest.Requ
 
 	l := log.FromContext(ctx)
 
-	deviceID := r.PathParam("id")
+	include := r.URL.Query().Get(queryParamInclude)
+	if include != "" && include != includeCold {
+		u.RestErrWithLog(w, r, l,
+			errors.Errorf("invalid %s value: must be %s",
+				queryParamInclude, includeCold),
+			http.StatusBadRequest,
+		)
+		return
+	}
+
+	deviceID, ok := i.resolveDeviceID(ctx, w, r, r.PathParam("id"))
+	if !ok {
+		return
+	}
 
-	dev, err := i.inventory.GetDevice(ctx, model.DeviceID(deviceID))
+	dev, err := i.inventory.GetDevice(ctx, deviceID)
 	if err != nil {
 		u.RestErrWithLogInternal(w, r, l, err)
 		return
@@ -335,8 +1016,55 @@ func (i *inventoryHandlers) GetDeviceHandler(w rest.ResponseWriter, r *rest.Requ
 		u.RestErrWithLog(w, r, l, store.ErrDevNotFound, http.StatusNotFound)
 		return
 	}
+	if include == includeCold {
+		cold, err := i.inventory.GetColdAttributes(ctx, deviceID)
+		if err != nil {
+			u.RestErrWithLogInternal(w, r, l, err)
+			return
+		}
+		dev.Attributes = append(dev.Attributes, cold...)
+	}
+
+	attributeAccessFromContext(ctx).FilterDevice(dev)
+	w.WriteJson(struct {
+		*model.Device
+		Telemetry *model.DeviceTelemetry `json:"telemetry,omitempty"`
+	}{
+		Device:    dev,
+		Telemetry: dev.Telemetry,
+	})
+}
+
+// GetDevicesByIDsHandler returns the devices with the IDs listed in the
+// request body, saving the clients a request per device.
+func (i *inventoryHandlers) GetDevicesByIDsHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var req model.DevicesGetRequest
+	if err := r.DecodeJsonPayload(&req); err != nil {
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+	if !checkAttributesVisible(w, r, req.Attributes) {
+		return
+	}
 
-	w.WriteJson(dev)
+	devs, err := i.inventory.GetDevicesByIDs(ctx, req)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	hideAttributes(ctx, devs)
+	w.WriteJson(devs)
 }
 
 func (i *inventoryHandlers) DeleteDeviceHandler(w rest.ResponseWriter, r *rest.Request) {
@@ -344,9 +1072,12 @@ func (i *inventoryHandlers) DeleteDeviceHandler(w rest.ResponseWriter, r *rest.R
 
 	l := log.FromContext(ctx)
 
-	deviceID := r.PathParam("id")
+	deviceID, ok := i.resolveDeviceID(ctx, w, r, r.PathParam("id"))
+	if !ok {
+		return
+	}
 
-	err := i.inventory.DeleteDevice(ctx, model.DeviceID(deviceID))
+	err := i.inventory.DeleteDevice(ctx, deviceID)
 	if err != nil && err != store.ErrDevNotFound {
 		u.RestErrWithLogInternal(w, r, l, err)
 		return
@@ -400,12 +1131,18 @@ func (i *inventoryHandlers) UpdateDeviceAttributesHandler(w rest.ResponseWriter,
 		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
 		return
 	}
+	agent, err := parseAgentParam(r, attrs)
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
 
 	// upsert or replace the attributes
 	if r.Method == http.MethodPatch {
 		err = i.inventory.UpsertAttributesWithUpdated(ctx, model.DeviceID(idata.Subject), attrs)
 	} else if r.Method == http.MethodPut {
-		err = i.inventory.ReplaceAttributes(ctx, model.DeviceID(idata.Subject), attrs, model.AttrScopeInventory)
+		err = i.inventory.ReplaceAttributes(ctx, model.DeviceID(idata.Subject), attrs,
+			model.AgentScope(model.AttrScopeInventory, agent))
 	} else {
 		u.RestErrWithLog(w, r, l, errors.New("method not alllowed"), http.StatusMethodNotAllowed)
 		return
@@ -415,6 +1152,12 @@ func (i *inventoryHandlers) UpdateDeviceAttributesHandler(w rest.ResponseWriter,
 	case store.ErrNoAttrName:
 		u.RestErrWithLog(w, r, l, cause, http.StatusBadRequest)
 		return
+	case inventory.ErrScopeWriteForbidden:
+		u.RestErrWithLog(w, r, l, err, http.StatusForbidden)
+		return
+	case inventory.ErrSchemaViolation, inventory.ErrInvalidParentDevice:
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
 	}
 	if err != nil {
 		u.RestErrWithLogInternal(w, r, l, err)
@@ -424,6 +1167,26 @@ func (i *inventoryHandlers) UpdateDeviceAttributesHandler(w rest.ResponseWriter,
 	w.WriteHeader(http.StatusOK)
 }
 
+// parseAgentParam parses the agent reporting the attributes, if not
+// the client of the device, and moves the attributes to its sub-scopes.
+func parseAgentParam(r *rest.Request, attrs model.DeviceAttributes) (string, error) {
+	agent := r.URL.Query().Get(queryParamAgent)
+	if agent == "" {
+		return model.AgentClient, nil
+	} else if err := model.ValidateAgentName(agent); err != nil {
+		return "", err
+	}
+	for n := range attrs {
+		if strings.Contains(attrs[n].Scope, model.AgentScopeSeparator) {
+			return "", errors.Errorf(
+				"the scope of the attributes of the agent must not be "+
+					"a sub-scope: %q", attrs[n].Scope)
+		}
+		attrs[n].Scope = model.AgentScope(attrs[n].Scope, agent)
+	}
+	return agent, nil
+}
+
 func (i *inventoryHandlers) PatchDeviceAttributesInternalHandler(w rest.ResponseWriter, r *rest.Request) {
 	ctx := r.Context()
 	tenantId := r.PathParam("tenant_id")
@@ -436,6 +1199,10 @@ func (i *inventoryHandlers) PatchDeviceAttributesInternalHandler(w rest.Response
 		u.RestErrWithLog(w, r, l, errors.New("device id cannot be empty"), http.StatusBadRequest)
 		return
 	}
+	deviceID, ok := i.resolveDeviceID(ctx, w, r, deviceId)
+	if !ok {
+		return
+	}
 	//extract attributes from body
 	attrs, err := parseAttributes(r)
 	if err != nil {
@@ -447,12 +1214,24 @@ func (i *inventoryHandlers) PatchDeviceAttributesInternalHandler(w rest.Response
 	}
 
 	//upsert the attributes
-	err = i.inventory.UpsertAttributes(ctx, model.DeviceID(deviceId), attrs)
+	err = i.inventory.UpsertAttributes(ctx, deviceID, attrs)
 	cause := errors.Cause(err)
 	switch cause {
 	case store.ErrNoAttrName:
 		u.RestErrWithLog(w, r, l, cause, http.StatusBadRequest)
 		return
+	case inventory.ErrScopeWriteForbidden:
+		u.RestErrWithLog(w, r, l, err, http.StatusForbidden)
+		return
+	case inventory.ErrSchemaViolation, inventory.ErrInvalidParentDevice:
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	case inventory.ErrAttributesRejected:
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	case inventory.ErrValidationUnavailable:
+		u.RestErrWithLog(w, r, l, err, http.StatusServiceUnavailable)
+		return
 	}
 	if err != nil {
 		u.RestErrWithLogInternal(w, r, l, err)
@@ -467,10 +1246,13 @@ func (i *inventoryHandlers) DeleteDeviceGroupHandler(w rest.ResponseWriter, r *r
 
 	l := log.FromContext(ctx)
 
-	deviceID := r.PathParam("id")
+	deviceID, ok := i.resolveDeviceID(ctx, w, r, r.PathParam("id"))
+	if !ok {
+		return
+	}
 	groupName := r.PathParam("name")
 
-	err := i.inventory.UnsetDeviceGroup(ctx, model.DeviceID(deviceID), model.GroupName(groupName))
+	err := i.inventory.UnsetDeviceGroup(ctx, deviceID, model.GroupName(groupName))
 	if err != nil {
 		cause := errors.Cause(err)
 		if cause != nil {
@@ -491,7 +1273,10 @@ func (i *inventoryHandlers) AddDeviceToGroupHandler(w rest.ResponseWriter, r *re
 
 	l := log.FromContext(ctx)
 
-	devId := r.PathParam("id")
+	devId, ok := i.resolveDeviceID(ctx, w, r, r.PathParam("id"))
+	if !ok {
+		return
+	}
 
 	var group InventoryApiGroup
 	err := r.DecodeJsonPayload(&group)
@@ -507,7 +1292,7 @@ func (i *inventoryHandlers) AddDeviceToGroupHandler(w rest.ResponseWriter, r *re
 		return
 	}
 
-	err = i.inventory.UpdateDeviceGroup(ctx, model.DeviceID(devId), model.GroupName(group.Group))
+	err = i.inventory.UpdateDeviceGroup(ctx, devId, model.GroupName(group.Group))
 	if err != nil {
 		if cause := errors.Cause(err); cause != nil && cause == store.ErrDevNotFound {
 			u.RestErrWithLog(w, r, l, err, http.StatusNotFound)
@@ -531,27 +1316,480 @@ func (i *inventoryHandlers) GetDevicesByGroup(w rest.ResponseWriter, r *rest.Req
 		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
 		return
 	}
-
-	//get one extra device to see if there's a 'next' page
-	ids, totalCount, err := i.inventory.ListDevicesByGroup(ctx, model.GroupName(group), int((page-1)*perPage), int(perPage))
+	full, err := utils.ParseQueryParmBool(r, queryParamFull, false, nil)
 	if err != nil {
-		if err == store.ErrGroupNotFound {
-			u.RestErrWithLog(w, r, l, err, http.StatusNotFound)
-		} else {
-			u.RestErrWithLogInternal(w, r, l, err)
-		}
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
 		return
 	}
-
-	hasNext := totalCount > int(page*perPage)
-
+	recursive, err := utils.ParseQueryParmBool(r, queryParamRecursive, false, nil)
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+	sort, err := parseSortParam(r)
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+	filters, err := parseFilterParams(r, queryParamFull, queryParamRecursive)
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+	if !checkAttributesVisible(w, r, listQueryAttributes(filters, sort)) {
+		return
+	}
+	if !i.checkLimits(w, r, model.Limits{
+		PerPage: int(perPage),
+		Filters: len(filters),
+	}) {
+		return
+	}
+
+	var (
+		res        interface{}
+		totalCount int
+	)
+	q := store.ListQuery{
+		Skip:    int((page - 1) * perPage),
+		Limit:   int(perPage),
+		Filters: filters,
+		Sort:    sort,
+	}
+	if recursive != nil && *recursive {
+		descendants, err := i.inventory.GetGroupDescendants(ctx,
+			model.GroupName(group))
+		if err != nil {
+			u.RestErrWithLogInternal(w, r, l, err)
+			return
+		}
+		if len(descendants) > 0 {
+			q.GroupNames = append(
+				[]model.GroupName{model.GroupName(group)},
+				descendants...,
+			)
+		}
+	}
+	if full != nil && *full {
+		var devs []model.Device
+		devs, totalCount, err = i.inventory.ListDevicesByGroupFull(ctx,
+			model.GroupName(group), q)
+		hideAttributes(ctx, devs)
+		res = devs
+	} else {
+		res, totalCount, err = i.inventory.ListDevicesByGroup(ctx,
+			model.GroupName(group), q)
+	}
+	if err != nil {
+		if err == store.ErrGroupNotFound {
+			u.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+		} else if err == inventory.ErrDynamicGroupQuery {
+			u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		} else {
+			u.RestErrWithLogInternal(w, r, l, err)
+		}
+		return
+	}
+
+	hasNext := totalCount > int(page*perPage)
+
 	links := utils.MakePageLinkHdrs(r, page, perPage, hasNext)
 	for _, l := range links {
 		w.Header().Add("Link", l)
 	}
 	// the response writer will ensure the header name is in Kebab-Pascal-Case
 	w.Header().Add("X-Total-Count", strconv.Itoa(totalCount))
-	w.WriteJson(ids)
+	w.WriteJson(res)
+}
+
+func (i *inventoryHandlers) GetDeviceChildrenHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	deviceID, ok := i.resolveDeviceID(ctx, w, r, r.PathParam("id"))
+	if !ok {
+		return
+	}
+
+	page, perPage, err := utils.ParsePagination(r)
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	devs, totalCount, err := i.inventory.ListDeviceChildren(ctx,
+		deviceID, int((page-1)*perPage), int(perPage),
+	)
+	if err != nil {
+		if err == store.ErrDevNotFound {
+			u.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+		} else {
+			u.RestErrWithLogInternal(w, r, l, err)
+		}
+		return
+	}
+
+	hasNext := totalCount > int(page*perPage)
+	links := utils.MakePageLinkHdrs(r, page, perPage, hasNext)
+	for _, l := range links {
+		w.Header().Add("Link", l)
+	}
+	w.Header().Add(hdrTotalCount, strconv.Itoa(totalCount))
+	hideAttributes(ctx, devs)
+	w.WriteJson(devs)
+}
+
+// GetDeviceCompletenessHandler returns the fraction of the attributes
+// required by the schema which the device has reported, and the missing
+// ones.
+func (i *inventoryHandlers) GetDeviceCompletenessHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	deviceID, ok := i.resolveDeviceID(ctx, w, r, r.PathParam("id"))
+	if !ok {
+		return
+	}
+
+	completeness, err := i.inventory.GetDeviceCompleteness(ctx, deviceID)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	} else if completeness == nil {
+		u.RestErrWithLog(w, r, l, store.ErrDevNotFound, http.StatusNotFound)
+		return
+	}
+
+	w.WriteJson(completeness)
+}
+
+// ApplyDeviceTemplateHandler extracts the selected attributes of
+// the device as a template and applies them to the target devices of
+// the request, if any.
+func (i *inventoryHandlers) ApplyDeviceTemplateHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	deviceID, ok := i.resolveDeviceID(ctx, w, r, r.PathParam("id"))
+	if !ok {
+		return
+	}
+	var req model.DeviceTemplateRequest
+	if err := r.DecodeJsonPayload(&req); err != nil {
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Attributes) == 0 && attributeAccessFromContext(ctx) != nil {
+		// the tags of the device are selected explicitly, so that
+		// the access of the user to each of them is checked
+		tags, err := i.inventory.ApplyDeviceTemplate(ctx, deviceID,
+			model.DeviceTemplateRequest{})
+		if err != nil {
+			u.RestErrWithLogInternal(w, r, l, err)
+			return
+		} else if tags == nil {
+			u.RestErrWithLog(w, r, l, store.ErrDevNotFound, http.StatusNotFound)
+			return
+		}
+		for _, attr := range tags.Attributes {
+			req.Attributes = append(req.Attributes, model.SelectAttribute{
+				Scope:     attr.Scope,
+				Attribute: attr.Name,
+			})
+		}
+	}
+	if !checkAttributesVisible(w, r, req.Attributes) {
+		return
+	}
+	if req.Apply() && !checkAttributesWritable(w, r, req.Attributes) {
+		return
+	}
+
+	template, err := i.inventory.ApplyDeviceTemplate(ctx, deviceID, req)
+	if err != nil {
+		switch errors.Cause(err) {
+		case inventory.ErrScopeWriteForbidden:
+			u.RestErrWithLog(w, r, l, err, http.StatusForbidden)
+		case inventory.ErrTemplateAttributeNotFound, inventory.ErrSchemaViolation:
+			u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		default:
+			u.RestErrWithLogInternal(w, r, l, err)
+		}
+		return
+	} else if template == nil {
+		u.RestErrWithLog(w, r, l, store.ErrDevNotFound, http.StatusNotFound)
+		return
+	}
+	w.WriteJson(template)
+}
+
+// GetDeviceMergedHandler returns the device with the sub-scopes of
+// the agents merged into their scopes by the precedence of the agents,
+// given as a comma-separated list.
+func (i *inventoryHandlers) GetDeviceMergedHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var precedence []string
+	if param := r.URL.Query().Get(queryParamPrecedence); param != "" {
+		precedence = strings.Split(param, queryParamListSeparator)
+		for _, agent := range precedence {
+			if err := model.ValidateAgentName(agent); err != nil {
+				u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	deviceID, ok := i.resolveDeviceID(ctx, w, r, r.PathParam("id"))
+	if !ok {
+		return
+	}
+
+	dev, err := i.inventory.GetDevice(ctx, deviceID)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	if dev == nil {
+		u.RestErrWithLog(w, r, l, store.ErrDevNotFound, http.StatusNotFound)
+		return
+	}
+
+	attributeAccessFromContext(ctx).FilterDevice(dev)
+	model.MergeAgentScopes(dev, precedence)
+	w.WriteJson(dev)
+}
+
+// ListDeviceChangesHandler returns the next batch of the change feed of
+// the devices, resuming after the since_token parameter.
+func (i *inventoryHandlers) ListDeviceChangesHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	limit, err := utils.ParseQueryParmUInt(r, queryParamLimit, false,
+		1, model.DeviceChangesLimitMax, model.DeviceChangesLimitDefault)
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	page, err := i.inventory.ListDeviceChanges(ctx,
+		r.URL.Query().Get(queryParamSinceToken), int(limit))
+	switch err {
+	case nil:
+	case inventory.ErrInvalidChangeToken:
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	case inventory.ErrChangeTokenExpired:
+		u.RestErrWithLog(w, r, l, err, http.StatusGone)
+		return
+	default:
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteJson(page)
+}
+
+// parseSelectAttributes parses the attributes selected with
+// the comma-separated "scope/name" lists of the attributes parameter;
+// the scope defaults to inventory.
+func parseSelectAttributes(r *rest.Request) ([]model.SelectAttribute, error) {
+	var attributes []model.SelectAttribute
+	for _, param := range r.URL.Query()[queryParamAttributes] {
+		for _, name := range strings.Split(param, ",") {
+			if name == "" {
+				return nil, errors.New(
+					utils.MsgQueryParmInvalid(queryParamAttributes))
+			}
+			attrNameWithScope := strings.SplitN(name, queryParamScopeSeparator, 2)
+			attribute := model.SelectAttribute{
+				Scope:     model.AttrScopeInventory,
+				Attribute: attrNameWithScope[0],
+			}
+			if len(attrNameWithScope) == 2 {
+				attribute.Scope = attrNameWithScope[0]
+				attribute.Attribute = attrNameWithScope[1]
+			}
+			attributes = append(attributes, attribute)
+		}
+	}
+	if len(attributes) == 0 {
+		return nil, errors.New(utils.MsgQueryParmMissing(queryParamAttributes))
+	}
+	return attributes, nil
+}
+
+// ExportGroupDevicesHandler streams the selected attributes of all
+// the devices of the group as CSV or newline-delimited JSON.
+func (i *inventoryHandlers) ExportGroupDevicesHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	group := model.GroupName(r.PathParam("name"))
+	if err := group.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+	format, err := utils.ParseQueryParmStr(r, queryParamFormat, false,
+		[]string{exportFormatCSV, exportFormatNDJSON})
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+	attributes, err := parseSelectAttributes(r)
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	} else if !checkAttributesVisible(w, r, attributes) {
+		return
+	}
+	loc, err := parseTimezone(r)
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	stream, totalCount, err := i.inventory.StreamDevices(ctx, store.ListQuery{
+		GroupName:  string(group),
+		Attributes: attributes,
+	})
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	defer stream.Close()
+	if totalCount == 0 {
+		u.RestErrWithLog(w, r, l, store.ErrGroupNotFound, http.StatusNotFound)
+		return
+	}
+	if !i.checkLimits(w, r, model.Limits{ExportDevices: totalCount}) {
+		return
+	}
+
+	if format == exportFormatNDJSON {
+		w.Header().Set("Content-Type", contentTypeNDJSON)
+	} else {
+		format = exportFormatCSV
+		w.Header().Set("Content-Type", contentTypeCSV)
+	}
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf("attachment; filename=%q", string(group)+"."+format))
+	w.Header().Add(hdrTotalCount, strconv.Itoa(totalCount))
+	w.WriteHeader(http.StatusOK)
+
+	// the status is already sent, errors can only be logged from now on
+	if format == exportFormatNDJSON {
+		err = writeDevicesNDJSON(w.(http.ResponseWriter), stream)
+	} else {
+		err = writeDevicesCSV(w.(http.ResponseWriter), stream, attributes, loc)
+	}
+	if err != nil {
+		l.Errorf("failed to export the devices of group %s: %v", group, err)
+	}
+}
+
+func writeDevicesNDJSON(w io.Writer, stream *store.DeviceStream) error {
+	enc := json.NewEncoder(w)
+	for dev := range stream.Devices() {
+		if err := enc.Encode(dev); err != nil {
+			return errors.Wrapf(err, "failed to write device %s", dev.ID)
+		}
+	}
+	return stream.Err()
+}
+
+// parseTimezone returns the location named by the tz parameter, UTC if
+// none is given.
+func parseTimezone(r *rest.Request) (*time.Location, error) {
+	name := r.URL.Query().Get(queryParamTimezone)
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, errors.New(utils.MsgQueryParmInvalid(queryParamTimezone))
+	}
+	return loc, nil
+}
+
+// writeDevicesCSV writes the device ID and the selected attributes of
+// each device as a CSV row, after a header row of "scope/name" columns;
+// the timestamps are rendered in the given location.
+func writeDevicesCSV(
+	w io.Writer,
+	stream *store.DeviceStream,
+	attributes []model.SelectAttribute,
+	loc *time.Location,
+) error {
+	enc := csv.NewWriter(w)
+	header := make([]string, 0, len(attributes)+1)
+	header = append(header, model.AttrNameID)
+	for _, attr := range attributes {
+		header = append(header, attr.Scope+queryParamScopeSeparator+attr.Attribute)
+	}
+	if err := enc.Write(header); err != nil {
+		return errors.Wrap(err, "failed to write the header")
+	}
+	row := make([]string, len(header))
+	for dev := range stream.Devices() {
+		values := make(map[[2]string]interface{}, len(dev.Attributes))
+		for _, attr := range dev.Attributes {
+			values[[2]string{attr.Scope, attr.Name}] = attr.Value
+		}
+		row[0] = string(dev.ID)
+		for i, attr := range attributes {
+			row[i+1] = csvValue(values[[2]string{attr.Scope, attr.Attribute}], loc)
+		}
+		if err := enc.Write(row); err != nil {
+			return errors.Wrapf(err, "failed to write device %s", dev.ID)
+		}
+	}
+	enc.Flush()
+	if err := enc.Error(); err != nil {
+		return errors.Wrap(err, "failed to write devices")
+	}
+	return stream.Err()
+}
+
+// csvValue formats the attribute value as a CSV field; the timestamps
+// are rendered in the given location and the values other than strings,
+// numbers, booleans and timestamps are JSON encoded.
+func csvValue(value interface{}, loc *time.Location) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.In(loc).Format(time.RFC3339Nano)
+	case primitive.DateTime:
+		return v.Time().In(loc).Format(time.RFC3339Nano)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
 }
 
 func (i *inventoryHandlers) AppendDevicesToGroup(w rest.ResponseWriter, r *rest.Request) {
@@ -669,6 +1907,14 @@ func (i *inventoryHandlers) GetGroupsHandler(w rest.ResponseWriter, r *rest.Requ
 			Value:     status,
 		}}
 	}
+	// the groups are paged on request only: the clients listing all
+	// the groups do not follow the page links
+	if query.Get(queryParamName) != "" ||
+		query.Get(utils.PageName) != "" ||
+		query.Get(utils.PerPageName) != "" {
+		i.searchGroups(w, r, fltr)
+		return
+	}
 
 	groups, err := i.inventory.ListGroups(ctx, fltr)
 	if err != nil {
@@ -683,28 +1929,147 @@ func (i *inventoryHandlers) GetGroupsHandler(w rest.ResponseWriter, r *rest.Requ
 	w.WriteJson(groups)
 }
 
-func (i *inventoryHandlers) GetDeviceGroupHandler(w rest.ResponseWriter, r *rest.Request) {
+// ListGroupsV2Handler returns all the groups together with the number of
+// devices without group, and optionally of each group, limited to
+// the devices with the given auth set status.
+func (i *inventoryHandlers) ListGroupsV2Handler(w rest.ResponseWriter, r *rest.Request) {
 	ctx := r.Context()
 
 	l := log.FromContext(ctx)
 
-	deviceID := r.PathParam("id")
-
-	group, err := i.inventory.GetDeviceGroup(ctx, model.DeviceID(deviceID))
+	withCounts, err := utils.ParseQueryParmBool(r, queryParamCounts, false, nil)
 	if err != nil {
-		if err == store.ErrDevNotFound {
-			u.RestErrWithLog(w, r, l, store.ErrDevNotFound, http.StatusNotFound)
-		} else {
-			u.RestErrWithLogInternal(w, r, l, err)
-		}
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
 		return
 	}
 
-	ret := map[string]*model.GroupName{"group": nil}
-
-	if group != "" {
-		ret["group"] = &group
-	}
+	var (
+		fltr       []model.FilterPredicate
+		devFilters []store.Filter
+	)
+	if status := r.URL.Query().Get("status"); status != "" {
+		fltr = []model.FilterPredicate{{
+			Attribute: "status",
+			Scope:     model.AttrScopeIdentity,
+			Type:      "$eq",
+			Value:     status,
+		}}
+		devFilters = []store.Filter{{
+			AttrName:  "status",
+			AttrScope: model.AttrScopeIdentity,
+			Value:     status,
+			Operator:  store.Eq,
+		}}
+	}
+
+	var (
+		groups []model.GroupName
+		counts []model.GroupCount
+	)
+	if withCounts != nil && *withCounts {
+		counts, err = i.inventory.ListGroupCounts(ctx, fltr)
+		for _, count := range counts {
+			groups = append(groups, count.Group)
+		}
+	} else {
+		groups, err = i.inventory.ListGroups(ctx, fltr)
+	}
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	noGroup := false
+	_, ungrouped, err := i.inventory.ListDevices(ctx, store.ListQuery{
+		Limit:    1,
+		Filters:  devFilters,
+		HasGroup: &noGroup,
+		IDsOnly:  true,
+	})
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	if groups == nil {
+		groups = []model.GroupName{}
+	}
+	w.WriteJson(model.GroupsListing{
+		Groups:         groups,
+		Counts:         counts,
+		UngroupedCount: ungrouped,
+	})
+}
+
+// searchGroups returns a page of the groups, sorted by name, optionally
+// limited to the names starting with the given prefix; meant for
+// autocompleting the group names.
+func (i *inventoryHandlers) searchGroups(
+	w rest.ResponseWriter,
+	r *rest.Request,
+	filters []model.FilterPredicate,
+) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	page, perPage, err := utils.ParsePagination(r)
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+	if !i.checkLimits(w, r, model.Limits{PerPage: int(perPage)}) {
+		return
+	}
+
+	groups, totalCount, err := i.inventory.SearchGroups(ctx, store.GroupsQuery{
+		Prefix:  r.URL.Query().Get(queryParamName),
+		Filters: filters,
+		Skip:    int((page - 1) * perPage),
+		Limit:   int(perPage),
+	})
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	hasNext := totalCount > int(page*perPage)
+	links := utils.MakePageLinkHdrs(r, page, perPage, hasNext)
+	for _, l := range links {
+		w.Header().Add("Link", l)
+	}
+	w.Header().Add(hdrTotalCount, strconv.Itoa(totalCount))
+	w.WriteJson(groups)
+}
+
+func (i *inventoryHandlers) GetDeviceGroupHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	deviceID, ok := i.resolveDeviceID(ctx, w, r, r.PathParam("id"))
+	if !ok {
+		return
+	}
+
+	groups, err := i.inventory.GetDeviceGroups(ctx, deviceID)
+	if err != nil {
+		if err == store.ErrDevNotFound {
+			u.RestErrWithLog(w, r, l, store.ErrDevNotFound, http.StatusNotFound)
+		} else {
+			u.RestErrWithLogInternal(w, r, l, err)
+		}
+		return
+	}
+
+	// the v1 API predates devices in several groups: the group is the
+	// first one, and all of them are returned in the groups field
+	ret := map[string]interface{}{"group": nil, "groups": groups}
+
+	if len(groups) > 0 {
+		ret["group"] = groups[0]
+	} else {
+		ret["groups"] = []model.GroupName{}
+	}
 
 	w.WriteJson(ret)
 }
@@ -758,6 +2123,15 @@ func (i *inventoryHandlers) FiltersAttributesHandler(w rest.ResponseWriter, r *r
 		u.RestErrWithLogInternal(w, r, l, err)
 		return
 	}
+	if access := attributeAccessFromContext(ctx); access != nil {
+		visible := make([]model.FilterAttribute, 0, len(attributes))
+		for _, attr := range attributes {
+			if !access.Hidden(attr.Scope, attr.Name) {
+				visible = append(visible, attr)
+			}
+		}
+		attributes = visible
+	}
 
 	w.WriteJson(attributes)
 }
@@ -773,10 +2147,62 @@ func (i *inventoryHandlers) FiltersSearchHandler(w rest.ResponseWriter, r *rest.
 		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
 		return
 	}
+	i.searchDevices(w, r, *searchParams)
+}
 
-	// query the database
-	devs, totalCount, err := i.inventory.SearchDevices(ctx, *searchParams)
+// searchDevices runs the validated search and writes the devices found,
+// after checking the attributes it references and the limits of the tenant.
+func (i *inventoryHandlers) searchDevices(
+	w rest.ResponseWriter,
+	r *rest.Request,
+	searchParams model.SearchParams,
+) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	if !checkAttributesVisible(w, r, searchAttributes(&searchParams)) {
+		return
+	}
+	if !i.checkLimits(w, r, model.Limits{
+		PerPage: searchParams.PerPage,
+		Filters: len(searchParams.Filters),
+	}) {
+		return
+	}
+	explainEmpty, err := utils.ParseQueryParmBool(r, queryParamExplainEmpty, false, nil)
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	cursor, err := parseCursorParam(r, searchParams.Sort)
 	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	} else if cursor != nil {
+		if searchParams.Page > 1 {
+			u.RestErrWithLog(w, r, l,
+				errors.Errorf("%s conflicts with %s", queryParamCursor, utils.PageName),
+				http.StatusBadRequest,
+			)
+			return
+		}
+		searchParams.Cursor = cursor
+		if len(searchParams.Attributes) > 0 {
+			// the next cursor is made of the values of the sort
+			// attributes, which are returned as well
+			searchParams.Attributes = withSortAttributes(
+				searchParams.Attributes, searchParams.Sort)
+		}
+	}
+
+	// query the database
+	devs, totalCount, err := i.inventory.SearchDevices(ctx, searchParams)
+	if errors.Cause(err) == store.ErrPartialResults {
+		l.Warnf("search devices: %v", err)
+		w.Header().Add(hdrPartialResults, "true")
+	} else if err != nil {
 		if strings.Contains(err.Error(), "BadValue") {
 			u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
 		} else {
@@ -785,11 +2211,109 @@ func (i *inventoryHandlers) FiltersSearchHandler(w rest.ResponseWriter, r *rest.
 		return
 	}
 
+	if cursor != nil {
+		if err := writeNextCursor(w, cursor, devs, searchParams.PerPage); err != nil {
+			u.RestErrWithLogInternal(w, r, l, err)
+			return
+		}
+	}
 	// the response writer will ensure the header name is in Kebab-Pascal-Case
-	w.Header().Add(hdrTotalCount, strconv.Itoa(totalCount))
+	if totalCount >= 0 {
+		w.Header().Add(hdrTotalCount, strconv.Itoa(totalCount))
+	}
+	if totalCount == 0 && explainEmpty != nil && *explainEmpty {
+		terms, err := i.inventory.ExplainEmptySearch(ctx, searchParams)
+		if err != nil {
+			u.RestErrWithLogInternal(w, r, l, err)
+			return
+		}
+		if explanation := explainEmptySearch(terms); explanation != "" {
+			w.Header().Add(hdrWarning, fmt.Sprintf("299 - %q", explanation))
+		}
+	}
+	hideAttributes(ctx, devs)
 	w.WriteJson(devs)
 }
 
+// explainEmptySearch returns the explanation of a search without results:
+// the first filter term which matches no devices, alone or together with
+// the previous terms, e.g. for a typo in an attribute name.
+func explainEmptySearch(terms []model.FilterTermCount) string {
+	attribute := func(term model.FilterTermCount) string {
+		return term.Filter.Scope + queryParamScopeSeparator +
+			term.Filter.Attribute
+	}
+	for k, term := range terms {
+		if term.Reported != nil && *term.Reported == 0 {
+			return fmt.Sprintf(
+				"filters[%d]: no device reports the attribute %s",
+				k, attribute(term))
+		} else if term.Devices == 0 {
+			return fmt.Sprintf(
+				"filters[%d]: no device matches the value of %s",
+				k, attribute(term))
+		}
+	}
+	for k, term := range terms {
+		if term.Cumulative == 0 {
+			return fmt.Sprintf(
+				"filters[%d]: no device matches %s together with "+
+					"the previous filters",
+				k, attribute(term))
+		}
+	}
+	return ""
+}
+
+// withSortAttributes returns the selected attributes and the sort
+// attributes not selected.
+func withSortAttributes(
+	attributes []model.SelectAttribute,
+	sort []model.SortCriteria,
+) []model.SelectAttribute {
+	selected := append([]model.SelectAttribute(nil), attributes...)
+	for _, s := range sort {
+		attr := model.SelectAttribute{Scope: s.Scope, Attribute: s.Attribute}
+		found := false
+		for _, a := range attributes {
+			if a == attr {
+				found = true
+				break
+			}
+		}
+		if !found {
+			selected = append(selected, attr)
+		}
+	}
+	return selected
+}
+
+func (i *inventoryHandlers) FiltersValidateHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var req model.FilterValidationRequest
+	if err := r.DecodeJsonPayload(&req); err != nil {
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	result, err := i.inventory.ValidateFilter(ctx, req)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteJson(result)
+}
+
 func (i *inventoryHandlers) InternalFiltersSearchHandler(w rest.ResponseWriter, r *rest.Request) {
 	ctx := r.Context()
 
@@ -809,7 +2333,10 @@ func (i *inventoryHandlers) InternalFiltersSearchHandler(w rest.ResponseWriter,
 
 	// query the database
 	devs, totalCount, err := i.inventory.SearchDevices(ctx, *searchParams)
-	if err != nil {
+	if errors.Cause(err) == store.ErrPartialResults {
+		l.Warnf("search devices: %v", err)
+		w.Header().Add(hdrPartialResults, "true")
+	} else if err != nil {
 		if strings.Contains(err.Error(), "BadValue") {
 			u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
 		} else {
@@ -819,10 +2346,46 @@ func (i *inventoryHandlers) InternalFiltersSearchHandler(w rest.ResponseWriter,
 	}
 
 	// the response writer will ensure the header name is in Kebab-Pascal-Case
-	w.Header().Add(hdrTotalCount, strconv.Itoa(totalCount))
+	if totalCount >= 0 {
+		w.Header().Add(hdrTotalCount, strconv.Itoa(totalCount))
+	}
 	w.WriteJson(devs)
 }
 
+// InternalExplainSearchHandler returns the plan of the device search;
+// it is meant for support and requires a user token, which the
+// authorization policy restricts to the admin roles.
+func (i *inventoryHandlers) InternalExplainSearchHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	idata, err := identity.ExtractIdentityFromHeaders(r.Header)
+	if err != nil || !idata.IsUser {
+		u.RestErrWithLog(w, r, l, ErrUserTokenRequired, http.StatusUnauthorized)
+		return
+	}
+
+	ctx = getTenantContext(ctx, r.PathParam("tenant_id"))
+
+	searchParams, err := parseSearchParams(r)
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	plan, err := i.inventory.ExplainSearchDevices(ctx, *searchParams)
+	if err != nil {
+		if strings.Contains(err.Error(), "BadValue") {
+			u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		} else {
+			u.RestErrWithLogInternal(w, r, l, err)
+		}
+		return
+	}
+	w.WriteJson(plan)
+}
+
 func getTenantContext(ctx context.Context, tenantId string) context.Context {
 	if ctx == nil {
 		ctx = context.Background()
@@ -909,8 +2472,11 @@ func (i *inventoryHandlers) GetDeviceGroupsInternalHandler(w rest.ResponseWriter
 	tenantId := r.PathParam("tenant_id")
 	ctx = getTenantContext(ctx, tenantId)
 
-	deviceID := r.PathParam("device_id")
-	group, err := i.inventory.GetDeviceGroup(ctx, model.DeviceID(deviceID))
+	deviceID, ok := i.resolveDeviceID(ctx, w, r, r.PathParam("device_id"))
+	if !ok {
+		return
+	}
+	groups, err := i.inventory.GetDeviceGroups(ctx, deviceID)
 	if err != nil {
 		if err == store.ErrDevNotFound {
 			u.RestErrWithLog(w, r, l, store.ErrDevNotFound, http.StatusNotFound)
@@ -921,13 +2487,43 @@ func (i *inventoryHandlers) GetDeviceGroupsInternalHandler(w rest.ResponseWriter
 	}
 
 	res := model.DeviceGroups{}
-	if group != "" {
+	for _, group := range groups {
 		res.Groups = append(res.Groups, string(group))
 	}
 
 	w.WriteJson(res)
 }
 
+// resolveDeviceID returns the ID of the device addressed by the path
+// parameter: either the device ID itself or a reference to the external ID
+// of the device, external:<system>:<id>. On failure, the error response is
+// written and false is returned.
+func (i *inventoryHandlers) resolveDeviceID(
+	ctx context.Context,
+	w rest.ResponseWriter,
+	r *rest.Request,
+	param string,
+) (model.DeviceID, bool) {
+	l := log.FromContext(ctx)
+
+	ref, err := model.ParseExternalIDRef(param)
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return model.NilDeviceID, false
+	} else if ref == nil {
+		return model.DeviceID(param), true
+	}
+	id, err := i.inventory.ResolveExternalID(ctx, *ref)
+	if err == store.ErrExternalIDNotFound {
+		u.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+		return model.NilDeviceID, false
+	} else if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return model.NilDeviceID, false
+	}
+	return id, true
+}
+
 func getIdsFromDevices(devices []model.DeviceUpdate) []model.DeviceID {
 	ids := make([]model.DeviceID, len(devices))
 	for i, dev := range devices {
@@ -956,3 +2552,2183 @@ func parseSearchParams(r *rest.Request) (*model.SearchParams, error) {
 
 	return &searchParams, nil
 }
+
+// checkLimits rejects the request of the given size if it exceeds
+// the hard limits of the tenant, and returns false after responding.
+// The soft limits exceeded by the request are reported in the Warning
+// headers of the response.
+func (i *inventoryHandlers) checkLimits(
+	w rest.ResponseWriter,
+	r *rest.Request,
+	size model.Limits,
+) bool {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	warnings, err := i.inventory.CheckLimits(ctx, size)
+	if errors.Cause(err) == inventory.ErrLimitExceeded {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return false
+	} else if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return false
+	}
+	for _, warning := range warnings {
+		w.Header().Add(hdrWarning, fmt.Sprintf("299 - %q", warning.String()))
+	}
+	return true
+}
+
+// ExportConfigBundleHandler returns the inventory configuration bundle,
+// encoded as YAML if requested by the Accept header and as JSON otherwise.
+func (i *inventoryHandlers) ExportConfigBundleHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	bundle, err := i.inventory.ExportConfigBundle(ctx)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	if !strings.Contains(r.Header.Get("Accept"), "yaml") {
+		w.WriteJson(bundle)
+		return
+	}
+	data, err := yaml.Marshal(bundle)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.Header().Set("Content-Type", contentTypeYAML)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.(http.ResponseWriter).Write(data)
+}
+
+// ImportConfigBundleHandler imports the inventory configuration bundle,
+// decoded from YAML if the Content-Type says so and from JSON otherwise.
+func (i *inventoryHandlers) ImportConfigBundleHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var bundle model.ConfigBundle
+	if err := decodeConfigBundle(r, &bundle); err != nil {
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	if err := bundle.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	result, err := i.inventory.ImportConfigBundle(ctx, bundle)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteJson(result)
+}
+
+// decodeConfigBundle decodes the bundle in the body of the request, in
+// YAML or JSON.
+func decodeConfigBundle(r *rest.Request, bundle *model.ConfigBundle) error {
+	mediatype, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if !strings.HasSuffix(mediatype, "yaml") {
+		return r.DecodeJsonPayload(bundle)
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	return yaml.UnmarshalStrict(data, bundle)
+}
+
+// ImportTagsHandler sets the tags of the devices listed in the CSV body,
+// as rows of identity value, tag name and tag value; the devices are
+// identified by the identity attribute named in the query. The rows which
+// cannot be applied are reported in the response.
+func (i *inventoryHandlers) ImportTagsHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	mediatype, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediatype != contentTypeCSV {
+		u.RestErrWithLog(w, r, l,
+			errors.New("Content-Type must be "+contentTypeCSV),
+			http.StatusUnsupportedMediaType,
+		)
+		return
+	}
+	identityAttr, err := utils.ParseQueryParmStr(r, queryParamIdentity, true, nil)
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+	if !checkAttributesVisible(w, r, []model.SelectAttribute{
+		{Scope: model.AttrScopeIdentity, Attribute: identityAttr},
+	}) {
+		return
+	}
+
+	access := attributeAccessFromContext(ctx)
+	reader := csv.NewReader(r.Body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	rows := []model.TagsImportRow{}
+	rejected := []model.TagsImportError{}
+	for n := 1; ; n++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			u.RestErrWithLog(w, r, l,
+				errors.Wrap(err, "failed to parse the CSV body"),
+				http.StatusBadRequest,
+			)
+			return
+		} else if n > model.TagsImportMaxRows {
+			u.RestErrWithLog(w, r, l,
+				errors.Errorf("too many rows, the maximum is %d",
+					model.TagsImportMaxRows),
+				http.StatusBadRequest,
+			)
+			return
+		}
+		if len(record) != 3 {
+			rejected = append(rejected, model.TagsImportError{
+				Row:   n,
+				Error: "expected 3 fields: identity, tag name and tag value",
+			})
+			continue
+		}
+		row := model.TagsImportRow{
+			Row:      n,
+			Identity: record[0],
+			Name:     record[1],
+			Value:    record[2],
+		}
+		if access.ReadOnly(model.AttrScopeTags, row.Name) {
+			rejected = append(rejected, model.TagsImportError{
+				Row:      n,
+				Identity: row.Identity,
+				Error: errors.Wrap(ErrAttributeReadOnly,
+					model.AttrScopeTags+queryParamScopeSeparator+row.Name,
+				).Error(),
+			})
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	result, err := i.inventory.ImportTags(ctx, identityAttr, rows)
+	if errors.Cause(err) == inventory.ErrScopeWriteForbidden {
+		u.RestErrWithLog(w, r, l, err, http.StatusForbidden)
+		return
+	} else if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	result.Rows += len(rejected)
+	result.Errors = append(result.Errors, rejected...)
+	sort.SliceStable(result.Errors, func(a, b int) bool {
+		return result.Errors[a].Row < result.Errors[b].Row
+	})
+	w.WriteJson(result)
+}
+
+// AssignTagsHandler sets and removes tags on the devices selected by IDs,
+// filters or both in a single update.
+func (i *inventoryHandlers) AssignTagsHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var assignment model.TagsAssignment
+	if err := r.DecodeJsonPayload(&assignment); err != nil {
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	if err := assignment.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+	params := assignment.SearchParams()
+	if !checkAttributesVisible(w, r, searchAttributes(&params)) {
+		return
+	}
+	set, unset := assignment.Attributes()
+	tags := make([]model.SelectAttribute, 0, len(set)+len(unset))
+	for _, attr := range append(set, unset...) {
+		tags = append(tags, model.SelectAttribute{
+			Scope:     attr.Scope,
+			Attribute: attr.Name,
+		})
+	}
+	if !checkAttributesWritable(w, r, tags) {
+		return
+	}
+
+	result, err := i.inventory.AssignTags(ctx, assignment)
+	if err != nil {
+		cause := errors.Cause(err)
+		if cause == inventory.ErrScopeWriteForbidden {
+			u.RestErrWithLog(w, r, l, err, http.StatusForbidden)
+		} else if cause == inventory.ErrSchemaViolation ||
+			strings.Contains(err.Error(), "BadValue") {
+			u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		} else {
+			u.RestErrWithLogInternal(w, r, l, err)
+		}
+		return
+	}
+	w.WriteJson(result)
+}
+
+// ReplaceGroupHandler sets the full list of members of a group; the group
+// name is the stable identifier of the resource.
+func (i *inventoryHandlers) ReplaceGroupHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var group model.GroupDefinition
+	if err := r.DecodeJsonPayload(&group); err != nil {
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	name := model.GroupName(r.PathParam("name"))
+	if group.Name == "" {
+		group.Name = name
+	} else if group.Name != name {
+		u.RestErrWithLog(w, r, l,
+			errors.New("group name does not match the resource"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	if err := group.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	result, err := i.inventory.ReplaceGroup(ctx, group)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteJson(result)
+}
+
+// DeleteGroupHandler removes all the devices from a group and deletes its
+// metadata, returning the number of devices removed from the group.
+func (i *inventoryHandlers) DeleteGroupHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	name := model.GroupName(r.PathParam("name"))
+	if err := name.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	result, err := i.inventory.DeleteGroup(ctx, name)
+	if err == store.ErrGroupNotFound {
+		u.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+		return
+	} else if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteJson(result)
+}
+
+// PreviewGroupHandler returns the number of devices matching the candidate
+// definition of a dynamic group together with a sample of them.
+func (i *inventoryHandlers) PreviewGroupHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var preview model.GroupPreview
+	if err := r.DecodeJsonPayload(&preview); err != nil {
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	if err := preview.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	result, err := i.inventory.PreviewGroup(ctx, preview)
+	if err != nil {
+		if strings.Contains(err.Error(), "BadValue") {
+			u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		} else {
+			u.RestErrWithLogInternal(w, r, l, err)
+		}
+		return
+	}
+	w.WriteJson(result)
+}
+
+// AssignGroupHandler adds the devices selected by their IDs, by filters or
+// both to the group in a single update, so that the clients do not page
+// through the search results first.
+func (i *inventoryHandlers) AssignGroupHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	groupName := model.GroupName(r.PathParam("name"))
+	if err := groupName.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	var assignment model.GroupAssignment
+	if err := r.DecodeJsonPayload(&assignment); err != nil {
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	if err := assignment.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	result, err := i.inventory.UpdateDevicesGroupByFilter(
+		ctx, assignment.SearchParams(), groupName,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "BadValue") {
+			u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		} else {
+			u.RestErrWithLogInternal(w, r, l, err)
+		}
+		return
+	}
+	w.WriteJson(result)
+}
+
+// PreviewGroupAssignmentHandler returns the number of devices adding the
+// selected devices to the group would affect, without modifying them.
+func (i *inventoryHandlers) PreviewGroupAssignmentHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	groupName := model.GroupName(r.PathParam("name"))
+	if err := groupName.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	var assignment model.GroupAssignment
+	if err := r.DecodeJsonPayload(&assignment); err != nil {
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	if err := assignment.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	preview, err := i.inventory.PreviewDevicesGroup(ctx, assignment, groupName)
+	if err != nil {
+		if strings.Contains(err.Error(), "BadValue") {
+			u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		} else {
+			u.RestErrWithLogInternal(w, r, l, err)
+		}
+		return
+	}
+	w.WriteJson(preview)
+}
+
+func (i *inventoryHandlers) ListDynamicGroupsHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	groups, err := i.inventory.ListDynamicGroups(ctx)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteJson(groups)
+}
+
+func (i *inventoryHandlers) GetDynamicGroupHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	group, err := i.inventory.GetDynamicGroup(ctx, model.GroupName(r.PathParam("name")))
+	switch err {
+	case nil:
+		w.WriteJson(group)
+	case store.ErrDynamicGroupNotFound:
+		u.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	default:
+		u.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+// ReplaceDynamicGroupHandler creates or redefines the dynamic group; the
+// group name is the stable identifier of the resource.
+func (i *inventoryHandlers) ReplaceDynamicGroupHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var group model.DynamicGroup
+	if err := r.DecodeJsonPayload(&group); err != nil {
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	name := model.GroupName(r.PathParam("name"))
+	if group.Name == "" {
+		group.Name = name
+	} else if group.Name != name {
+		u.RestErrWithLog(w, r, l,
+			errors.New("group name does not match the resource"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	if err := group.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+	filters, _ := group.Filters()
+	if !checkAttributesVisible(w, r, searchAttributes(&model.SearchParams{
+		Filters: filters,
+	})) {
+		return
+	}
+
+	result, err := i.inventory.ReplaceDynamicGroup(ctx, group)
+	switch err {
+	case nil:
+		w.WriteJson(result)
+	case inventory.ErrDynamicGroupsLimit, inventory.ErrGroupNameInUse:
+		u.RestErrWithLog(w, r, l, err, http.StatusConflict)
+	default:
+		u.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (i *inventoryHandlers) DeleteDynamicGroupHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	err := i.inventory.DeleteDynamicGroup(ctx, model.GroupName(r.PathParam("name")))
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case store.ErrDynamicGroupNotFound:
+		u.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	default:
+		u.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (i *inventoryHandlers) ListGroupsMetadataHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	groups, err := i.inventory.ListGroupsMetadata(ctx)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteJson(groups)
+}
+
+func (i *inventoryHandlers) GetGroupMetadataHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	group, err := i.inventory.GetGroupMetadata(ctx, model.GroupName(r.PathParam("name")))
+	switch err {
+	case nil:
+		w.WriteJson(group)
+	case store.ErrGroupMetadataNotFound:
+		u.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	default:
+		u.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+// ReplaceGroupMetadataHandler creates or replaces the description of
+// the group; the group name is the stable identifier of the resource.
+func (i *inventoryHandlers) ReplaceGroupMetadataHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var group model.GroupMetadata
+	if err := r.DecodeJsonPayload(&group); err != nil {
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	name := model.GroupName(r.PathParam("name"))
+	if group.Name == "" {
+		group.Name = name
+	} else if group.Name != name {
+		u.RestErrWithLog(w, r, l,
+			errors.New("group name does not match the resource"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	if err := group.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	result, err := i.inventory.ReplaceGroupMetadata(ctx, group)
+	switch err {
+	case nil:
+		w.WriteJson(result)
+	case inventory.ErrGroupCycle:
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+	default:
+		u.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (i *inventoryHandlers) DeleteGroupMetadataHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	err := i.inventory.DeleteGroupMetadata(ctx, model.GroupName(r.PathParam("name")))
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case store.ErrGroupMetadataNotFound:
+		u.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	default:
+		u.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+// GetGroupsCompletenessHandler returns the inventory completeness of the
+// devices aggregated per group.
+func (i *inventoryHandlers) GetGroupsCompletenessHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	groups, err := i.inventory.GetGroupsCompleteness(ctx)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	w.WriteJson(groups)
+}
+
+// GroupCountsStreamHandler streams the number of devices per group as
+// server-sent events: first the current counts, then each time they change,
+// until the client disconnects.
+func (i *inventoryHandlers) GroupCountsStreamHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	updates, err := i.inventory.WatchGroupCounts(ctx)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypeSSE)
+	w.Header().Set(hdrCacheControl, "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	// the status is already sent, errors can only be logged from now on
+	hw := w.(http.ResponseWriter)
+	flusher, _ := w.(http.Flusher)
+	keepAlive := time.NewTicker(groupCountsKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case counts, ok := <-updates:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(counts)
+			if err != nil {
+				l.Errorf("failed to encode group counts: %v", err)
+				return
+			}
+			_, err = fmt.Fprintf(hw, "event: group_counts\ndata: %s\n\n", data)
+			if err != nil {
+				l.Errorf("failed to write group counts: %v", err)
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := io.WriteString(hw, ": keep-alive\n\n"); err != nil {
+				l.Errorf("failed to write group counts: %v", err)
+				return
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// InternalReconcileDevicesHandler reports the devices missing from the
+// inventory, deviceauth or deployments, given the devices known to the
+// latter two.
+func (i *inventoryHandlers) InternalReconcileDevicesHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	ctx = getTenantContext(ctx, r.PathParam("tenant_id"))
+
+	var rec model.Reconciliation
+	if err := r.DecodeJsonPayload(&rec); err != nil {
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	if err := rec.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	report, err := i.inventory.ReconcileDevices(ctx, rec)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteJson(report)
+}
+
+// InternalIngestTimelineHandler adds a batch of the events reported by
+// the other services to the device timeline.
+func (i *inventoryHandlers) InternalIngestTimelineHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	ctx = getTenantContext(ctx, r.PathParam("tenant_id"))
+
+	var events []model.TimelineEvent
+	if err := r.DecodeJsonPayload(&events); err != nil {
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	if len(events) > model.TimelineEventsBatchMax {
+		u.RestErrWithLog(w, r, l,
+			errors.Errorf(
+				"at most %d timeline events can be ingested at once",
+				model.TimelineEventsBatchMax,
+			),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	for _, event := range events {
+		if err := event.Validate(); err != nil {
+			u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+			return
+		}
+	}
+
+	result, err := i.inventory.IngestTimelineEvents(ctx, events)
+	if err == inventory.ErrTimelineBusy {
+		w.Header().Set(hdrRetryAfter, "1")
+		u.RestErrWithLog(w, r, l, err, http.StatusTooManyRequests)
+		return
+	} else if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteJson(result)
+}
+
+// InternalUpsertExternalIDsHandler maps the external IDs to the devices.
+func (i *inventoryHandlers) InternalUpsertExternalIDsHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	ctx = getTenantContext(ctx, r.PathParam("tenant_id"))
+
+	var ids []model.ExternalID
+	if err := r.DecodeJsonPayload(&ids); err != nil {
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	if len(ids) > model.ExternalIDsBatchMax {
+		u.RestErrWithLog(w, r, l,
+			errors.Errorf(
+				"at most %d external IDs can be updated at once",
+				model.ExternalIDsBatchMax,
+			),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	for _, extID := range ids {
+		if err := extID.Validate(); err != nil {
+			u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+			return
+		}
+	}
+
+	result, err := i.inventory.UpsertExternalIDs(ctx, ids)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteJson(result)
+}
+
+func (i *inventoryHandlers) InternalDeleteExternalIDHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	ctx = getTenantContext(ctx, r.PathParam("tenant_id"))
+
+	ref := model.ExternalIDRef{
+		System: r.PathParam("system"),
+		ID:     r.PathParam("id"),
+	}
+	err := i.inventory.DeleteExternalID(ctx, ref)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case store.ErrExternalIDNotFound:
+		u.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	default:
+		u.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+// InternalAttributeStatisticsHandler returns the distribution of the values
+// of an attribute aggregated across all the tenants.
+func (i *inventoryHandlers) InternalAttributeStatisticsHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	name, err := utils.ParseQueryParmStr(r, "attribute", true, nil)
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+	scope, err := utils.ParseQueryParmStr(r, "scope", false, nil)
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	} else if scope == "" {
+		scope = model.AttrScopeInventory
+	}
+
+	stats, err := i.inventory.GetAttributeStatistics(ctx, scope, name)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteJson(stats)
+}
+
+// InternalListTenantDatabasesHandler lists the databases of the tenants
+// with their number of devices, schema version and storage size.
+func (i *inventoryHandlers) InternalListTenantDatabasesHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	dbs, err := i.inventory.ListTenantDatabases(ctx)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteJson(dbs)
+}
+
+// GetAttributeGraphHandler groups the devices into the clusters connected
+// by the shared values of the attribute, for topology views.
+func (i *inventoryHandlers) GetAttributeGraphHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	scope, name := r.PathParam("scope"), r.PathParam("name")
+	if !checkAttributesVisible(w, r, []model.SelectAttribute{
+		{Scope: scope, Attribute: name},
+	}) {
+		return
+	}
+	limit, err := utils.ParseQueryParmUInt(r, queryParamLimit, false,
+		1, model.AttributeClustersLimitMax, model.AttributeClustersLimitDefault)
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	graph, err := i.inventory.GetAttributeGraph(ctx, scope, name, int(limit))
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteJson(graph)
+}
+
+// GetAttributePivotHandler counts the devices matching the filters by
+// the values of two attributes, e.g. the device types by the artifacts
+// installed.
+func (i *inventoryHandlers) GetAttributePivotHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	row, err := parsePivotAttribute(r, queryParamRows)
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+	column, err := parsePivotAttribute(r, queryParamColumns)
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+	limit, err := utils.ParseQueryParmUInt(r, queryParamLimit, false,
+		1, model.AttributePivotLimitMax, model.AttributePivotLimitDefault)
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+	groupName, err := utils.ParseQueryParmStr(r, queryParamGroup, false, nil)
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+	filters, err := parseFilterParams(r,
+		queryParamRows, queryParamColumns, queryParamLimit)
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+	if !checkAttributesVisible(w, r,
+		append(listQueryAttributes(filters, nil), row, column)) {
+		return
+	}
+	if !i.checkLimits(w, r, model.Limits{Filters: len(filters)}) {
+		return
+	}
+
+	pivot, err := i.inventory.GetAttributePivot(ctx, store.ListQuery{
+		Filters:   filters,
+		GroupName: groupName,
+	}, row, column, int(limit))
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteJson(pivot)
+}
+
+// parsePivotAttribute returns the attribute of the required parameter,
+// in the scope/name notation; the scope defaults to inventory.
+func parsePivotAttribute(r *rest.Request, param string) (model.SelectAttribute, error) {
+	value := r.URL.Query().Get(param)
+	if value == "" {
+		return model.SelectAttribute{}, errors.New(utils.MsgQueryParmMissing(param))
+	}
+	attribute := model.SelectAttribute{
+		Scope:     model.AttrScopeInventory,
+		Attribute: value,
+	}
+	attrNameWithScope := strings.SplitN(value, queryParamScopeSeparator, 2)
+	if len(attrNameWithScope) == 2 {
+		attribute.Scope = attrNameWithScope[0]
+		attribute.Attribute = attrNameWithScope[1]
+	}
+	if attribute.Scope == "" || attribute.Attribute == "" {
+		return model.SelectAttribute{}, errors.New(utils.MsgQueryParmInvalid(param))
+	}
+	return attribute, nil
+}
+
+func (i *inventoryHandlers) ListScopesHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	scopes, err := i.inventory.ListScopes(ctx)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteJson(scopes)
+}
+
+// ReplaceScopeHandler registers a custom attribute scope or replaces its
+// policy; the scope name is the stable identifier of the resource.
+func (i *inventoryHandlers) ReplaceScopeHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var scope model.Scope
+	if err := r.DecodeJsonPayload(&scope); err != nil {
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	name := r.PathParam("name")
+	if scope.Name == "" {
+		scope.Name = name
+	} else if scope.Name != name {
+		u.RestErrWithLog(w, r, l,
+			errors.New("scope name does not match the resource"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	if err := scope.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	result, err := i.inventory.ReplaceScope(ctx, scope)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteJson(result)
+}
+
+func (i *inventoryHandlers) DeleteScopeHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	name := r.PathParam("name")
+	if _, ok := model.GetBuiltinScope(name); ok {
+		u.RestErrWithLog(w, r, l,
+			errors.New("builtin scopes cannot be removed"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+
+	err := i.inventory.DeleteScope(ctx, name)
+	if err == store.ErrScopeNotFound {
+		u.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+		return
+	} else if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (i *inventoryHandlers) ListAttributeDefinitionsHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	defs, err := i.inventory.ListAttributeDefinitions(ctx)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	if access := attributeAccessFromContext(ctx); access != nil {
+		visible := make([]model.AttributeDefinition, 0, len(defs))
+		for _, def := range defs {
+			if !access.Hidden(def.Scope, def.Name) {
+				visible = append(visible, def)
+			}
+		}
+		defs = visible
+	}
+	w.WriteJson(defs)
+}
+
+// ReplaceAttributeDefinitionHandler defines an attribute or replaces its
+// definition; the scope and the name identify the resource.
+func (i *inventoryHandlers) ReplaceAttributeDefinitionHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var def model.AttributeDefinition
+	if err := r.DecodeJsonPayload(&def); err != nil {
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	scope, name := r.PathParam("scope"), r.PathParam("name")
+	if (def.Scope != "" && def.Scope != scope) ||
+		(def.Name != "" && def.Name != name) {
+		u.RestErrWithLog(w, r, l,
+			errors.New("attribute does not match the resource"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	def.Scope, def.Name = scope, name
+	if err := def.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+	if !checkAttributesWritable(w, r, []model.SelectAttribute{
+		{Scope: scope, Attribute: name},
+	}) {
+		return
+	}
+
+	result, err := i.inventory.ReplaceAttributeDefinition(ctx, def)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteJson(result)
+}
+
+func (i *inventoryHandlers) DeleteAttributeDefinitionHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	scope, name := r.PathParam("scope"), r.PathParam("name")
+	if !checkAttributesWritable(w, r, []model.SelectAttribute{
+		{Scope: scope, Attribute: name},
+	}) {
+		return
+	}
+
+	err := i.inventory.DeleteAttributeDefinition(ctx, scope, name)
+	if err == store.ErrAttributeDefinitionNotFound {
+		u.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+		return
+	} else if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListSchemaViolationsHandler returns the validation report of the
+// attributes defined in the monitor mode.
+func (i *inventoryHandlers) ListSchemaViolationsHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	page, perPage, err := utils.ParsePagination(r)
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+	deviceID, err := utils.ParseQueryParmStr(r, "device_id", false, nil)
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	violations, totalCount, err := i.inventory.ListSchemaViolations(ctx,
+		model.DeviceID(deviceID), int((page-1)*perPage), int(perPage),
+	)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	hasNext := totalCount > int(page*perPage)
+	links := utils.MakePageLinkHdrs(r, page, perPage, hasNext)
+	for _, l := range links {
+		w.Header().Add("Link", l)
+	}
+	w.Header().Add(hdrTotalCount, strconv.Itoa(totalCount))
+	w.WriteJson(violations)
+}
+
+func (i *inventoryHandlers) GetValidationWebhookHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	hook, err := i.inventory.GetValidationWebhook(ctx)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	} else if hook == nil {
+		u.RestErrWithLog(w, r, l,
+			errors.New("validation webhook not found"),
+			http.StatusNotFound,
+		)
+		return
+	}
+	w.WriteJson(hook)
+}
+
+// SetValidationWebhookHandler registers the webhook validating the
+// attributes written by the users, replacing the existing one.
+func (i *inventoryHandlers) SetValidationWebhookHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var hook model.ValidationWebhook
+	if err := r.DecodeJsonPayload(&hook); err != nil {
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	if err := hook.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	if err := i.inventory.SetValidationWebhook(ctx, hook); err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteJson(hook)
+}
+
+func (i *inventoryHandlers) DeleteValidationWebhookHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	if err := i.inventory.DeleteValidationWebhook(ctx); err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListIncompleteDevicesHandler lists the devices missing any of the
+// attributes required by the schema.
+func (i *inventoryHandlers) ListIncompleteDevicesHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	page, perPage, err := utils.ParsePagination(r)
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	devs, totalCount, err := i.inventory.ListIncompleteDevices(ctx,
+		int((page-1)*perPage), int(perPage),
+	)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	hasNext := totalCount > int(page*perPage)
+	links := utils.MakePageLinkHdrs(r, page, perPage, hasNext)
+	for _, l := range links {
+		w.Header().Add("Link", l)
+	}
+	w.Header().Add(hdrTotalCount, strconv.Itoa(totalCount))
+	w.WriteJson(devs)
+}
+
+func (i *inventoryHandlers) GetCompletenessAlertHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	alert, err := i.inventory.GetCompletenessAlert(ctx)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	} else if alert == nil {
+		u.RestErrWithLog(w, r, l,
+			errors.New("completeness alert not found"),
+			http.StatusNotFound,
+		)
+		return
+	}
+	w.WriteJson(alert)
+}
+
+// SetCompletenessAlertHandler sets up the notifications about the devices
+// still missing required attributes after the grace period, replacing
+// the existing alert.
+func (i *inventoryHandlers) SetCompletenessAlertHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var alert model.CompletenessAlert
+	if err := r.DecodeJsonPayload(&alert); err != nil {
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	if err := alert.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+	// the time of the last check is read-only
+	alert.CheckedTs = nil
+
+	if err := i.inventory.SetCompletenessAlert(ctx, alert); err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteJson(alert)
+}
+
+func (i *inventoryHandlers) DeleteCompletenessAlertHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	if err := i.inventory.DeleteCompletenessAlert(ctx); err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (i *inventoryHandlers) GetDigestSettingsHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	settings, err := i.inventory.GetDigestSettings(ctx)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	} else if settings == nil {
+		u.RestErrWithLog(w, r, l,
+			errors.New("digest settings not found"),
+			http.StatusNotFound,
+		)
+		return
+	}
+	w.WriteJson(settings)
+}
+
+// SetDigestSettingsHandler sets up the periodic summary of the inventory,
+// replacing the existing settings.
+func (i *inventoryHandlers) SetDigestSettingsHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var settings model.DigestSettings
+	if err := r.DecodeJsonPayload(&settings); err != nil {
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	if err := settings.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+	// the end of the last digest is read-only
+	settings.SentTs = nil
+
+	if err := i.inventory.SetDigestSettings(ctx, settings); err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteJson(settings)
+}
+
+func (i *inventoryHandlers) DeleteDigestSettingsHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	if err := i.inventory.DeleteDigestSettings(ctx); err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PreviewDigestHandler returns the digest which would be sent now,
+// without sending it.
+func (i *inventoryHandlers) PreviewDigestHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	digest, err := i.inventory.PreviewDigest(ctx)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	} else if digest == nil {
+		u.RestErrWithLog(w, r, l,
+			errors.New("digest settings not found"),
+			http.StatusNotFound,
+		)
+		return
+	}
+	w.WriteJson(digest)
+}
+
+func (i *inventoryHandlers) GetPinnedAttributesHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	pinned, err := i.inventory.GetPinnedAttributes(ctx)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	if pinned.Attributes == nil {
+		pinned.Attributes = []model.SelectAttribute{}
+	}
+	w.WriteJson(pinned)
+}
+
+// SetPinnedAttributesHandler replaces the attributes the device searches
+// always return in the highlights of the devices.
+func (i *inventoryHandlers) SetPinnedAttributesHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var pinned model.PinnedAttributes
+	if err := r.DecodeJsonPayload(&pinned); err != nil {
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	if err := pinned.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+	if pinned.Attributes == nil {
+		pinned.Attributes = []model.SelectAttribute{}
+	}
+
+	if err := i.inventory.SetPinnedAttributes(ctx, pinned); err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteJson(pinned)
+}
+
+func (i *inventoryHandlers) GetAttributeAliasesHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	aliases, err := i.inventory.GetAttributeAliases(ctx)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	if aliases.Attributes == nil {
+		aliases.Attributes = []model.AttributeAlias{}
+	}
+	w.WriteJson(aliases)
+}
+
+// SetAttributeAliasesHandler replaces the aliases of the attributes, the
+// other names the filters, the sorts and the attribute catalog treat as
+// the same attribute.
+func (i *inventoryHandlers) SetAttributeAliasesHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var aliases model.AttributeAliases
+	if err := r.DecodeJsonPayload(&aliases); err != nil {
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	if err := aliases.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+	if aliases.Attributes == nil {
+		aliases.Attributes = []model.AttributeAlias{}
+	}
+
+	if err := i.inventory.SetAttributeAliases(ctx, aliases); err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteJson(aliases)
+}
+
+// GetAttributeDescriptionsHandler returns the catalog of the descriptions
+// of the attributes, without the attributes hidden from the user.
+func (i *inventoryHandlers) GetAttributeDescriptionsHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	descs, err := i.inventory.GetAttributeDescriptions(ctx)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	visible := make(model.AttributeDescriptions, 0, len(descs))
+	access := attributeAccessFromContext(ctx)
+	for _, desc := range descs {
+		if !access.Hidden(desc.Scope, desc.Name) {
+			visible = append(visible, desc)
+		}
+	}
+	w.WriteJson(visible)
+}
+
+// parseAttributeDescriptions decodes and validates the descriptions of
+// the request; returns false if it responded with an error.
+func parseAttributeDescriptions(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) (model.AttributeDescriptions, bool) {
+	l := log.FromContext(r.Context())
+
+	var descs model.AttributeDescriptions
+	if err := r.DecodeJsonPayload(&descs); err != nil {
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest,
+		)
+		return nil, false
+	}
+	if err := descs.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return nil, false
+	}
+	attrs := make([]model.SelectAttribute, len(descs))
+	for n, desc := range descs {
+		attrs[n] = model.SelectAttribute{Scope: desc.Scope, Attribute: desc.Name}
+	}
+	if !checkAttributesWritable(w, r, attrs) {
+		return nil, false
+	}
+	return descs, true
+}
+
+// SetAttributeDescriptionsHandler replaces the catalog of the descriptions
+// of the attributes.
+func (i *inventoryHandlers) SetAttributeDescriptionsHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	descs, ok := parseAttributeDescriptions(w, r)
+	if !ok {
+		return
+	}
+	if err := i.inventory.SetAttributeDescriptions(ctx, descs); err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UpdateAttributeDescriptionsHandler sets the descriptions of the
+// attributes of the request, keeping the others; an empty description
+// removes the description of the attribute.
+func (i *inventoryHandlers) UpdateAttributeDescriptionsHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	descs, ok := parseAttributeDescriptions(w, r)
+	if !ok {
+		return
+	}
+	err := i.inventory.UpdateAttributeDescriptions(ctx, descs)
+	if err == inventory.ErrAttributeDescriptionsLimit {
+		u.RestErrWithLog(w, r, l, err, http.StatusConflict)
+		return
+	} else if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// InternalMetricsHandler exposes the service metrics in the Prometheus
+// text format.
+func (i *inventoryHandlers) InternalMetricsHandler(w rest.ResponseWriter, r *rest.Request) {
+	w.Header().Set("Content-Type", metrics.ContentType)
+	w.WriteHeader(http.StatusOK)
+	if err := metrics.WriteText(w.(http.ResponseWriter)); err != nil {
+		l := log.FromContext(r.Context())
+		l.Errorf("failed to write metrics: %s", err.Error())
+	}
+}
+
+func (i *inventoryHandlers) InternalGetFeatureFlagsHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	ctx = getTenantContext(ctx, r.PathParam("tenant_id"))
+
+	flags, err := i.inventory.GetFeatureFlags(ctx)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteJson(flags)
+}
+
+func (i *inventoryHandlers) InternalUpdateFeatureFlagsHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	ctx = getTenantContext(ctx, r.PathParam("tenant_id"))
+
+	var update model.FeatureFlagsUpdate
+	if err := r.DecodeJsonPayload(&update); err != nil {
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	if err := update.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	flags, err := i.inventory.UpdateFeatureFlags(ctx, update)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteJson(flags)
+}
+
+func (i *inventoryHandlers) InternalGetLimitsHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	ctx = getTenantContext(ctx, r.PathParam("tenant_id"))
+
+	limits, err := i.inventory.GetLimits(ctx)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteJson(limits)
+}
+
+// InternalSetLimitsHandler replaces the hard limits of the requests of
+// the tenant; the requests exceeding them are rejected.
+func (i *inventoryHandlers) InternalSetLimitsHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	ctx = getTenantContext(ctx, r.PathParam("tenant_id"))
+
+	var limits model.Limits
+	if err := r.DecodeJsonPayload(&limits); err != nil {
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	if err := limits.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	if err := i.inventory.SetLimits(ctx, limits); err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteJson(limits)
+}
+
+// InternalListDeadLettersHandler returns the failed webhook deliveries
+// of the tenant, oldest first.
+func (i *inventoryHandlers) InternalListDeadLettersHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	ctx = getTenantContext(ctx, r.PathParam("tenant_id"))
+
+	page, perPage, err := utils.ParsePagination(r)
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	letters, totalCount, err := i.inventory.ListDeadLetters(ctx,
+		int((page-1)*perPage), int(perPage),
+	)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	hasNext := totalCount > int(page*perPage)
+	links := utils.MakePageLinkHdrs(r, page, perPage, hasNext)
+	for _, l := range links {
+		w.Header().Add("Link", l)
+	}
+	w.Header().Add(hdrTotalCount, strconv.Itoa(totalCount))
+	w.WriteJson(letters)
+}
+
+func (i *inventoryHandlers) InternalPurgeDeadLettersHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	ctx = getTenantContext(ctx, r.PathParam("tenant_id"))
+
+	result, err := i.inventory.PurgeDeadLetters(ctx)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteJson(result)
+}
+
+func (i *inventoryHandlers) InternalDeleteDeadLetterHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	ctx = getTenantContext(ctx, r.PathParam("tenant_id"))
+
+	err := i.inventory.DeleteDeadLetter(ctx, r.PathParam("id"))
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case store.ErrDeadLetterNotFound:
+		u.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	default:
+		u.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+// InternalReplayDeadLetterHandler delivers a failed webhook delivery again;
+// a delivery failing again is reported with 502 Bad Gateway.
+func (i *inventoryHandlers) InternalReplayDeadLetterHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	ctx = getTenantContext(ctx, r.PathParam("tenant_id"))
+
+	err := i.inventory.ReplayDeadLetter(ctx, r.PathParam("id"))
+	var derr *events.DeliveryError
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case err == store.ErrDeadLetterNotFound:
+		u.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	case errors.As(err, &derr):
+		u.RestErrWithLog(w, r, l, err, http.StatusBadGateway)
+	default:
+		u.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (i *inventoryHandlers) InternalReplayDeadLettersHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	ctx = getTenantContext(ctx, r.PathParam("tenant_id"))
+
+	result, err := i.inventory.ReplayDeadLetters(ctx)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteJson(result)
+}
+
+// ListPrivateSavedFiltersHandler lists the private filters of the user.
+func (i *inventoryHandlers) ListPrivateSavedFiltersHandler(w rest.ResponseWriter, r *rest.Request) {
+	i.listSavedFilters(w, r, false)
+}
+
+// ListSharedSavedFiltersHandler lists the filters shared by the users of
+// the tenant.
+func (i *inventoryHandlers) ListSharedSavedFiltersHandler(w rest.ResponseWriter, r *rest.Request) {
+	i.listSavedFilters(w, r, true)
+}
+
+func (i *inventoryHandlers) listSavedFilters(w rest.ResponseWriter, r *rest.Request, shared bool) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	filters, err := i.inventory.ListSavedFilters(ctx, shared)
+	switch err {
+	case nil:
+		w.WriteJson(filters)
+	case inventory.ErrSavedFilterUserRequired:
+		u.RestErrWithLog(w, r, l, err, http.StatusForbidden)
+	default:
+		u.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (i *inventoryHandlers) GetSavedFilterHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	filter, err := i.inventory.GetSavedFilter(ctx, r.PathParam("id"))
+	switch err {
+	case nil:
+		w.WriteJson(filter)
+	case inventory.ErrSavedFilterUserRequired:
+		u.RestErrWithLog(w, r, l, err, http.StatusForbidden)
+	case store.ErrSavedFilterNotFound:
+		u.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	default:
+		u.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+// SearchSavedFilterHandler runs the search of a saved filter visible to
+// the user and returns the requested page of the devices found.
+func (i *inventoryHandlers) SearchSavedFilterHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	page, perPage, err := utils.ParsePagination(r)
+	if err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	filter, err := i.inventory.GetSavedFilter(ctx, r.PathParam("id"))
+	switch err {
+	case nil:
+	case inventory.ErrSavedFilterUserRequired:
+		u.RestErrWithLog(w, r, l, err, http.StatusForbidden)
+		return
+	case store.ErrSavedFilterNotFound:
+		u.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+		return
+	default:
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	i.searchDevices(w, r, model.SearchParams{
+		Page:    int(page),
+		PerPage: int(perPage),
+		Filters: filter.Filters,
+		Sort:    filter.Sort,
+	})
+}
+
+func (i *inventoryHandlers) CreateSavedFilterHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var filter model.SavedFilter
+	if err := r.DecodeJsonPayload(&filter); err != nil {
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	if err := filter.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	result, err := i.inventory.CreateSavedFilter(ctx, filter)
+	switch err {
+	case nil:
+		w.Header().Add("Location", "saved_filters/"+result.ID)
+		w.WriteHeader(http.StatusCreated)
+		w.WriteJson(result)
+	case inventory.ErrSavedFilterUserRequired:
+		u.RestErrWithLog(w, r, l, err, http.StatusForbidden)
+	case inventory.ErrSavedFiltersLimit:
+		u.RestErrWithLog(w, r, l, err, http.StatusConflict)
+	default:
+		u.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+// ReplaceSavedFilterHandler creates the filter with the ID in the URL,
+// or replaces it; only its owner can modify it, whether it is shared or not.
+func (i *inventoryHandlers) ReplaceSavedFilterHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var filter model.SavedFilter
+	if err := r.DecodeJsonPayload(&filter); err != nil {
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	id := r.PathParam("id")
+	if filter.ID != "" && filter.ID != id {
+		u.RestErrWithLog(w, r, l,
+			errors.New("filter ID does not match the resource"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	filter.ID = id
+	if err := model.ValidateClientID(filter.ID); err != nil {
+		u.RestErrWithLog(w, r, l, errors.Wrap(err, "id"), http.StatusBadRequest)
+		return
+	}
+	if err := filter.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	result, err := i.inventory.ReplaceSavedFilter(ctx, filter)
+	switch err {
+	case nil:
+		w.WriteJson(result)
+	case inventory.ErrSavedFilterUserRequired, inventory.ErrSavedFilterNotOwner:
+		u.RestErrWithLog(w, r, l, err, http.StatusForbidden)
+	case inventory.ErrSavedFiltersLimit:
+		u.RestErrWithLog(w, r, l, err, http.StatusConflict)
+	default:
+		u.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (i *inventoryHandlers) DeleteSavedFilterHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	err := i.inventory.DeleteSavedFilter(ctx, r.PathParam("id"))
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case inventory.ErrSavedFilterUserRequired, inventory.ErrSavedFilterNotOwner:
+		u.RestErrWithLog(w, r, l, err, http.StatusForbidden)
+	case store.ErrSavedFilterNotFound:
+		u.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	default:
+		u.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (i *inventoryHandlers) ListSubscriptionsHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	subs, err := i.inventory.ListSubscriptions(ctx)
+	switch err {
+	case nil:
+		w.WriteJson(subs)
+	case inventory.ErrSubscriptionUserRequired:
+		u.RestErrWithLog(w, r, l, err, http.StatusForbidden)
+	default:
+		u.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (i *inventoryHandlers) CreateSubscriptionHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var sub model.Subscription
+	if err := r.DecodeJsonPayload(&sub); err != nil {
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	if err := sub.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	result, err := i.inventory.CreateSubscription(ctx, sub)
+	switch err {
+	case nil:
+		w.Header().Add("Location", "subscriptions/"+result.ID)
+		w.WriteHeader(http.StatusCreated)
+		w.WriteJson(result)
+	case inventory.ErrSubscriptionUserRequired:
+		u.RestErrWithLog(w, r, l, err, http.StatusForbidden)
+	case inventory.ErrSubscriptionsLimit:
+		u.RestErrWithLog(w, r, l, err, http.StatusConflict)
+	default:
+		u.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+// ReplaceSubscriptionHandler creates the subscription with the ID in
+// the URL, or replaces it; the users only see their own subscriptions.
+func (i *inventoryHandlers) ReplaceSubscriptionHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var sub model.Subscription
+	if err := r.DecodeJsonPayload(&sub); err != nil {
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	id := r.PathParam("id")
+	if sub.ID != "" && sub.ID != id {
+		u.RestErrWithLog(w, r, l,
+			errors.New("subscription ID does not match the resource"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	sub.ID = id
+	if err := model.ValidateClientID(sub.ID); err != nil {
+		u.RestErrWithLog(w, r, l, errors.Wrap(err, "id"), http.StatusBadRequest)
+		return
+	}
+	if err := sub.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	result, err := i.inventory.ReplaceSubscription(ctx, sub)
+	switch err {
+	case nil:
+		w.WriteJson(result)
+	case inventory.ErrSubscriptionUserRequired:
+		u.RestErrWithLog(w, r, l, err, http.StatusForbidden)
+	case inventory.ErrSubscriptionsLimit, inventory.ErrSubscriptionIDInUse:
+		u.RestErrWithLog(w, r, l, err, http.StatusConflict)
+	default:
+		u.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (i *inventoryHandlers) DeleteSubscriptionHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	err := i.inventory.DeleteSubscription(ctx, r.PathParam("id"))
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case inventory.ErrSubscriptionUserRequired:
+		u.RestErrWithLog(w, r, l, err, http.StatusForbidden)
+	case store.ErrSubscriptionNotFound:
+		u.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	default:
+		u.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (i *inventoryHandlers) StartExportHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	var req model.ExportRequest
+	if err := r.DecodeJsonPayload(&req); err != nil {
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	job, err := i.inventory.StartExport(ctx, req)
+	switch err {
+	case nil:
+		w.Header().Add("Location", "exports/"+job.ID)
+		w.WriteHeader(http.StatusAccepted)
+		w.WriteJson(job)
+	case inventory.ErrExportsDisabled:
+		u.RestErrWithLog(w, r, l, err, http.StatusNotImplemented)
+	default:
+		u.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (i *inventoryHandlers) GetExportJobHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	job, err := i.inventory.GetExportJob(ctx, r.PathParam("id"))
+	switch err {
+	case nil:
+		w.WriteJson(job)
+	case store.ErrExportJobNotFound:
+		u.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+	default:
+		u.RestErrWithLogInternal(w, r, l, err)
+	}
+}
+
+func (i *inventoryHandlers) InternalWarmUpCatalogHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	ctx = getTenantContext(ctx, r.PathParam("tenant_id"))
+
+	catalog, err := i.inventory.WarmUpCatalog(ctx)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteJson(catalog)
+}
+
+// InternalDeploymentFinishedHandler reports the changes of the key
+// attributes of the devices of the finished deployment.
+func (i *inventoryHandlers) InternalDeploymentFinishedHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	ctx = getTenantContext(ctx, r.PathParam("tenant_id"))
+
+	var finished model.DeploymentFinished
+	if err := r.DecodeJsonPayload(&finished); err != nil {
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	if err := finished.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	diffs, err := i.inventory.DeploymentFinished(ctx,
+		r.PathParam("id"), finished.Devices)
+	if err != nil {
+		u.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+	w.WriteJson(diffs)
+}
+
+// InternalDeviceEligibilityHandler checks the attributes of the device
+// against the eligibility conditions, listing the ones it fails.
+func (i *inventoryHandlers) InternalDeviceEligibilityHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+
+	l := log.FromContext(ctx)
+
+	ctx = getTenantContext(ctx, r.PathParam("tenant_id"))
+
+	var req model.EligibilityRequest
+	if err := r.DecodeJsonPayload(&req); err != nil {
+		u.RestErrWithLog(w, r, l,
+			errors.Wrap(err, "failed to decode request body"),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		u.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	res, err := i.inventory.CheckDeviceEligibility(ctx,
+		model.DeviceID(r.PathParam("device_id")), req)
+	if err != nil {
+		if err == store.ErrDevNotFound {
+			u.RestErrWithLog(w, r, l, err, http.StatusNotFound)
+		} else {
+			u.RestErrWithLogInternal(w, r, l, err)
+		}
+		return
+	}
+	w.WriteJson(res)
+}
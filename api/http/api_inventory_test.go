@@ -21,10 +21,13 @@ import (
 	"net/http"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/ant0ine/go-json-rest/rest"
 	"github.com/ant0ine/go-json-rest/rest/test"
+	midentity "github.com/mendersoftware/go-lib-micro/identity"
 	"github.com/mendersoftware/go-lib-micro/mongo/oid"
 	"github.com/mendersoftware/go-lib-micro/requestid"
 	"github.com/mendersoftware/go-lib-micro/requestlog"
@@ -33,11 +36,15 @@ import (
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 
+	"github.com/mendersoftware/inventory/events"
 	inventory "github.com/mendersoftware/inventory/inv"
 	minventory "github.com/mendersoftware/inventory/inv/mocks"
+	"github.com/mendersoftware/inventory/metrics"
 	"github.com/mendersoftware/inventory/model"
 	"github.com/mendersoftware/inventory/store"
+	mstore "github.com/mendersoftware/inventory/store/mocks"
 	"github.com/mendersoftware/inventory/utils"
 )
 
@@ -112,6 +119,26 @@ func mockListDevices(num int) []model.Device {
 	return devs
 }
 
+// mockDeviceStream returns a stream delivering the devices, decoded from
+// a mocked cursor.
+func mockDeviceStream(devs []model.Device) *store.DeviceStream {
+	cur := &mstore.DeviceCursor{}
+	for i := range devs {
+		dev := devs[i]
+		cur.On("Next", mock.Anything).Return(true).Once()
+		cur.On("Decode", mock.AnythingOfType("*model.Device")).
+			Run(func(args mock.Arguments) {
+				*args.Get(0).(*model.Device) = dev
+			}).
+			Return(nil).
+			Once()
+	}
+	cur.On("Next", mock.Anything).Return(false)
+	cur.On("Err").Return(nil)
+	cur.On("Close", mock.Anything).Return(nil)
+	return store.NewDeviceStream(context.Background(), cur)
+}
+
 func mockListDeviceIDs(num int) []model.DeviceID {
 	var devs []model.DeviceID
 	for i := 0; i < num; i++ {
@@ -132,6 +159,37 @@ func TestLiveliness(t *testing.T) {
 	recorded.CodeIs(http.StatusNoContent)
 }
 
+func TestRouteGroupOf(t *testing.T) {
+	assert.Equal(t, RouteGroupDevices, RouteGroupOf(uriAttributes))
+	assert.Equal(t, RouteGroupManagement, RouteGroupOf(uriDevices))
+	assert.Equal(t, RouteGroupManagement, RouteGroupOf(urlGroupsV2))
+	assert.Equal(t, RouteGroupInternal, RouteGroupOf(uriInternalAlive))
+	assert.Equal(t, RouteGroupInternal, RouteGroupOf(urlInternalFiltersSearch))
+}
+
+func TestApiGetGroupsApp(t *testing.T) {
+	handlers := NewInventoryApiHandlers(nil)
+	app, err := handlers.GetGroupsApp([]RouteGroup{RouteGroupInternal})
+	assert.NoError(t, err)
+	api := rest.NewApi()
+	api.SetApp(app)
+	handler := api.MakeHandler()
+
+	req, _ := http.NewRequest("GET", "http://localhost"+uriInternalAlive, nil)
+	test.RunRequest(t, handler, req).CodeIs(http.StatusNoContent)
+	req, _ = http.NewRequest("GET", "http://localhost"+uriGroups, nil)
+	test.RunRequest(t, handler, req).CodeIs(http.StatusNotFound)
+
+	app, err = handlers.GetGroupsApp([]RouteGroup{RouteGroupDevices, RouteGroupManagement})
+	assert.NoError(t, err)
+	api = rest.NewApi()
+	api.SetApp(app)
+	handler = api.MakeHandler()
+
+	req, _ = http.NewRequest("GET", "http://localhost"+uriInternalAlive, nil)
+	test.RunRequest(t, handler, req).CodeIs(http.StatusNotFound)
+}
+
 func TestHealthCheck(t *testing.T) {
 	t.Parallel()
 
@@ -272,6 +330,117 @@ func TestApiParseFilterParams(t *testing.T) {
 				},
 			},
 		},
+		"ne": {
+			inReq: test.MakeSimpleRequest("GET", "http://1.2.3.4/api/0.1.0/devices?attr_name1=ne:A0001", nil),
+			filters: []store.Filter{
+				{
+					AttrName:  "attr_name1",
+					AttrScope: model.AttrScopeInventory,
+					Value:     "A0001",
+					Operator:  store.Ne,
+				},
+			},
+		},
+		"range": {
+			inReq: test.MakeSimpleRequest("GET", "http://1.2.3.4/api/0.1.0/devices?attr_name1=gte:10&attr_name2=lt:2.5", nil),
+			filters: []store.Filter{
+				{
+					AttrName:   "attr_name1",
+					AttrScope:  model.AttrScopeInventory,
+					Value:      "10",
+					ValueFloat: floatPtr(10),
+					Operator:   store.Gte,
+				},
+				{
+					AttrName:   "attr_name2",
+					AttrScope:  model.AttrScopeInventory,
+					Value:      "2.5",
+					ValueFloat: floatPtr(2.5),
+					Operator:   store.Lt,
+				},
+			},
+		},
+		"in": {
+			inReq: test.MakeSimpleRequest("GET", "http://1.2.3.4/api/0.1.0/devices?identity/mac=in:00:01,00:02", nil),
+			filters: []store.Filter{
+				{
+					AttrName:  "mac",
+					AttrScope: model.AttrScopeIdentity,
+					Value:     "00:01,00:02",
+					Values:    []string{"00:01", "00:02"},
+					Operator:  store.In,
+				},
+			},
+		},
+		"nin": {
+			inReq: test.MakeSimpleRequest("GET", "http://1.2.3.4/api/0.1.0/devices?attr_name1=nin:a", nil),
+			filters: []store.Filter{
+				{
+					AttrName:  "attr_name1",
+					AttrScope: model.AttrScopeInventory,
+					Value:     "a",
+					Values:    []string{"a"},
+					Operator:  store.Nin,
+				},
+			},
+		},
+		"exists": {
+			inReq: test.MakeSimpleRequest("GET", "http://1.2.3.4/api/0.1.0/devices?attr_name1=exists:false", nil),
+			filters: []store.Filter{
+				{
+					AttrName:  "attr_name1",
+					AttrScope: model.AttrScopeInventory,
+					Value:     "false",
+					Operator:  store.Exists,
+				},
+			},
+		},
+		"error, exists": {
+			inReq: test.MakeSimpleRequest("GET", "http://1.2.3.4/api/0.1.0/devices?attr_name1=exists:maybe", nil),
+			err:   errors.New(`invalid value of the exists operator of attr_name1: "maybe"`),
+		},
+		"regex": {
+			inReq: test.MakeSimpleRequest("GET", "http://1.2.3.4/api/0.1.0/devices?device_type=regex:%5Erpi%5B34%5D&hostname=iregex:kitchen", nil),
+			filters: []store.Filter{
+				{
+					AttrName:  "device_type",
+					AttrScope: model.AttrScopeInventory,
+					Value:     "^rpi[34]",
+					Operator:  store.Regex,
+				},
+				{
+					AttrName:  "hostname",
+					AttrScope: model.AttrScopeInventory,
+					Value:     "kitchen",
+					Operator:  store.IRegex,
+				},
+			},
+		},
+		"contains": {
+			inReq: test.MakeSimpleRequest("GET", "http://1.2.3.4/api/0.1.0/devices?name=contains:a.b&identity/mac=icontains:(1)", nil),
+			filters: []store.Filter{
+				{
+					AttrName:  "name",
+					AttrScope: model.AttrScopeInventory,
+					Value:     `a\.b`,
+					Operator:  store.Regex,
+				},
+				{
+					AttrName:  "mac",
+					AttrScope: model.AttrScopeIdentity,
+					Value:     `\(1\)`,
+					Operator:  store.IRegex,
+				},
+			},
+		},
+		"error, regex": {
+			inReq: test.MakeSimpleRequest("GET", "http://1.2.3.4/api/0.1.0/devices?name=regex:%5E(a*)*%24", nil),
+			err:   errors.New("invalid regular expression of name: " + model.ErrRegexNestedRepetition.Error()),
+		},
+		"error, substring": {
+			inReq: test.MakeSimpleRequest("GET", "http://1.2.3.4/api/0.1.0/devices?name=contains:"+strings.Repeat("a", model.RegexMaxLength+1), nil),
+			err:   errors.New("invalid substring of name: " + model.ErrRegexTooLong.Error()),
+		},
 	}
 
 	for name, testCase := range testCases {
@@ -279,7 +448,7 @@ func TestApiParseFilterParams(t *testing.T) {
 			req := rest.Request{Request: testCase.inReq}
 			filters, err := parseFilterParams(&req)
 			if testCase.err != nil {
-				assert.Error(t, testCase.err, err.Error())
+				assert.EqualError(t, err, testCase.err.Error())
 			} else {
 				assert.NoError(t, err)
 				assert.NotNil(t, filters)
@@ -291,6 +460,88 @@ func TestApiParseFilterParams(t *testing.T) {
 	}
 }
 
+func TestApiParseFilterGroup(t *testing.T) {
+	t.Parallel()
+
+	floatVal := 10.0
+	testCases := map[string]struct {
+		query string
+		group *store.FilterGroup
+		err   string
+	}{
+		"ok, no alternatives": {
+			query: "attr=value",
+		},
+		"ok": {
+			query: "or=group=prod|group=staging&or=os=linux|size=gte:10&artifact_name=ne:X",
+			group: &store.FilterGroup{
+				Operator: store.And,
+				Groups: []store.FilterGroup{
+					{
+						Operator: store.Or,
+						Filters: []store.Filter{
+							{
+								AttrName:  model.AttrNameGroup,
+								AttrScope: model.AttrScopeSystem,
+								Value:     "prod",
+								Operator:  store.Eq,
+							},
+							{
+								AttrName:  model.AttrNameGroup,
+								AttrScope: model.AttrScopeSystem,
+								Value:     "staging",
+								Operator:  store.Eq,
+							},
+						},
+					},
+					{
+						Operator: store.Or,
+						Filters: []store.Filter{
+							{
+								AttrName:  "os",
+								AttrScope: model.AttrScopeInventory,
+								Value:     "linux",
+								Operator:  store.Eq,
+							},
+							{
+								AttrName:   "size",
+								AttrScope:  model.AttrScopeInventory,
+								Value:      "10",
+								ValueFloat: &floatVal,
+								Operator:   store.Gte,
+							},
+						},
+					},
+				},
+			},
+		},
+		"error, condition": {
+			query: "or=group=prod|staging",
+			err:   `invalid condition of the or parameter: "staging"`,
+		},
+		"error, exists": {
+			query: "or=identity/mac=exists:maybe",
+			err:   `invalid value of the exists operator of identity/mac: "maybe"`,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			req := rest.Request{Request: test.MakeSimpleRequest("GET",
+				"http://1.2.3.4/api/0.1.0/devices?"+
+					strings.Replace(tc.query, "|", "%7C", -1), nil)}
+			group, err := parseFilterGroup(&req)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.group, group)
+			}
+		})
+	}
+}
+
 func TestApiInventoryGetDevices(t *testing.T) {
 	t.Parallel()
 	rest.ErrorFieldName = "error"
@@ -353,6 +604,34 @@ func TestApiInventoryGetDevices(t *testing.T) {
 				},
 			},
 		},
+		"alternative filters": {
+			listDevicesNum:  2,
+			listDevicesErr:  nil,
+			listDeviceTotal: 2,
+			inReq: test.MakeSimpleRequest("GET",
+				"http://1.2.3.4/api/0.1.0/devices?or=group%3Dprod%7Cgroup%3Dstaging", nil),
+			resp: utils.JSONResponseParams{
+				OutputStatus:     200,
+				OutputBodyObject: mockListDevices(2),
+				OutputHeaders: map[string][]string{
+					"Link": {
+						fmt.Sprintf(utils.LinkTmpl, "devices", "or=group%3Dprod%7Cgroup%3Dstaging&page=1&per_page=20", "first"),
+					},
+					"X-Total-Count": {"2"},
+				},
+			},
+		},
+		"invalid alternative filters": {
+			listDevicesNum:  5,
+			listDevicesErr:  nil,
+			listDeviceTotal: 5,
+			inReq:           test.MakeSimpleRequest("GET", "http://1.2.3.4/api/0.1.0/devices?or=prod", nil),
+			resp: utils.JSONResponseParams{
+				OutputStatus:     400,
+				OutputBodyObject: RestError(`invalid condition of the or parameter: "prod"`),
+				OutputHeaders:    nil,
+			},
+		},
 		"invalid pagination - page format": {
 			listDevicesNum:  5,
 			listDevicesErr:  nil,
@@ -475,6 +754,8 @@ func TestApiInventoryGetDevices(t *testing.T) {
 
 		ctx := contextMatcher()
 
+		inv.On("CheckLimits", contextMatcher(), mock.AnythingOfType("model.Limits")).
+			Return(nil, nil).Maybe()
 		inv.On("ListDevices",
 			ctx,
 			mock.AnythingOfType("store.ListQuery"),
@@ -486,6 +767,456 @@ func TestApiInventoryGetDevices(t *testing.T) {
 	}
 }
 
+func TestApiInventoryGetDevicesWithoutGroup(t *testing.T) {
+	t.Parallel()
+
+	inv := &minventory.InventoryApp{}
+	defer inv.AssertExpectations(t)
+	inv.On("CheckLimits", contextMatcher(), mock.AnythingOfType("model.Limits")).
+		Return(nil, nil).Maybe()
+	inv.On("ListDevices",
+		contextMatcher(),
+		mock.MatchedBy(func(q store.ListQuery) bool {
+			return q.GroupName == "" && q.HasGroup != nil && !*q.HasGroup
+		}),
+	).Return(mockListDevices(2), 2, nil).Once()
+	apih := makeMockApiHandler(t, inv)
+
+	req := makeReq("GET",
+		"http://1.2.3.4/api/0.1.0/devices?group=null&has_group=false", "", nil)
+	recorded := test.RunRequest(t, apih, req)
+	recorded.CodeIs(http.StatusOK)
+	recorded.BodyIs(ToJson(mockListDevices(2)))
+
+	req = makeReq("GET",
+		"http://1.2.3.4/api/0.1.0/devices?group=null&has_group=true", "", nil)
+	recorded = test.RunRequest(t, apih, req)
+	recorded.CodeIs(http.StatusBadRequest)
+	recorded.BodyIs(ToJson(restError("group=null conflicts with has_group=true")))
+}
+
+func TestApiInventoryGetDevicesCountOnly(t *testing.T) {
+	t.Parallel()
+
+	inv := &minventory.InventoryApp{}
+	defer inv.AssertExpectations(t)
+	inv.On("CheckLimits", contextMatcher(), mock.AnythingOfType("model.Limits")).
+		Return(nil, nil).Maybe()
+	inv.On("CountDevices",
+		contextMatcher(),
+		mock.MatchedBy(func(q store.ListQuery) bool {
+			return q.GroupName == "foo" && len(q.Filters) == 1 &&
+				q.Filters[0].AttrName == "mac"
+		}),
+	).Return(42, nil).Once()
+	inv.On("CountDevices",
+		contextMatcher(),
+		mock.MatchedBy(func(q store.ListQuery) bool {
+			return q.GroupName == "bar"
+		}),
+	).Return(-1, errors.New("connection refused")).Once()
+	apih := makeMockApiHandler(t, inv)
+
+	req := makeReq("GET",
+		"http://1.2.3.4/api/0.1.0/devices?group=foo&mac=00:11&count_only=true", "", nil)
+	recorded := test.RunRequest(t, apih, req)
+	recorded.CodeIs(http.StatusOK)
+	recorded.HeaderIs("X-Total-Count", "42")
+	recorded.BodyIs(ToJson(model.DevicesCount{Count: 42}))
+
+	req = makeReq("GET",
+		"http://1.2.3.4/api/0.1.0/devices?group=bar&count_only=true", "", nil)
+	recorded = test.RunRequest(t, apih, req)
+	recorded.CodeIs(http.StatusInternalServerError)
+
+	req = makeReq("GET",
+		"http://1.2.3.4/api/0.1.0/devices?count_only=maybe", "", nil)
+	recorded = test.RunRequest(t, apih, req)
+	recorded.CodeIs(http.StatusBadRequest)
+	recorded.BodyIs(ToJson(restError(utils.MsgQueryParmInvalid(queryParamCountOnly))))
+}
+
+func TestApiInventoryGetDevicesSearch(t *testing.T) {
+	t.Parallel()
+
+	inv := &minventory.InventoryApp{}
+	defer inv.AssertExpectations(t)
+	inv.On("CheckLimits", contextMatcher(), mock.AnythingOfType("model.Limits")).
+		Return(nil, nil).Maybe()
+	inv.On("ListDevices",
+		contextMatcher(),
+		mock.MatchedBy(func(q store.ListQuery) bool {
+			return q.Search == "kitchen pi" && len(q.Filters) == 0
+		}),
+	).Return(mockListDevices(2), 2, nil).Once()
+	apih := makeMockApiHandler(t, inv)
+
+	req := makeReq("GET",
+		"http://1.2.3.4/api/0.1.0/devices?search=%20kitchen%20pi%20", "", nil)
+	recorded := test.RunRequest(t, apih, req)
+	recorded.CodeIs(http.StatusOK)
+	recorded.BodyIs(ToJson(mockListDevices(2)))
+
+	req = makeReq("GET",
+		"http://1.2.3.4/api/0.1.0/devices?search="+
+			strings.Repeat("a", model.RegexMaxLength+1), "", nil)
+	recorded = test.RunRequest(t, apih, req)
+	recorded.CodeIs(http.StatusBadRequest)
+	recorded.BodyIs(ToJson(restError(
+		"the search parameter must be at most 256 characters long")))
+}
+
+func TestApiInventoryGetDevicesUpdatedSince(t *testing.T) {
+	t.Parallel()
+
+	since := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	testCases := map[string]struct {
+		query string
+
+		callInv bool
+		afterID model.DeviceID
+
+		code int
+		resp string
+	}{
+		"ok": {
+			query:   "updated_since=2021-06-01T12:00:00Z",
+			callInv: true,
+			code:    http.StatusOK,
+			resp:    ToJson(mockListDevices(2)),
+		},
+		"ok, time zone offset": {
+			query:   "updated_since=2021-06-01T14:00:00%2B02:00",
+			callInv: true,
+			code:    http.StatusOK,
+			resp:    ToJson(mockListDevices(2)),
+		},
+		"ok, after id": {
+			query:   "updated_since=2021-06-01T12:00:00Z&after_id=1",
+			callInv: true,
+			afterID: "1",
+			code:    http.StatusOK,
+			resp:    ToJson(mockListDevices(2)),
+		},
+		"error, timestamp": {
+			query: "updated_since=2021-06-01",
+			code:  http.StatusBadRequest,
+			resp: ToJson(restError(
+				"invalid updated_since: must be an RFC 3339 timestamp")),
+		},
+		"error, after id alone": {
+			query: "after_id=1",
+			code:  http.StatusBadRequest,
+			resp: ToJson(restError(
+				"the after_id parameter requires updated_since")),
+		},
+		"error, sort": {
+			query: "updated_since=2021-06-01T12:00:00Z&sort=name:asc",
+			code:  http.StatusBadRequest,
+			resp:  ToJson(restError("updated_since conflicts with sort")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := &minventory.InventoryApp{}
+			defer inv.AssertExpectations(t)
+			if tc.callInv {
+				inv.On("CheckLimits", contextMatcher(), mock.AnythingOfType("model.Limits")).
+					Return(nil, nil)
+				inv.On("ListDevices",
+					contextMatcher(),
+					mock.MatchedBy(func(q store.ListQuery) bool {
+						return q.UpdatedSince != nil &&
+							q.UpdatedSince.Equal(since) &&
+							q.UpdatedAfterID == tc.afterID &&
+							len(q.Filters) == 0
+					}),
+				).Return(mockListDevices(2), 2, nil)
+			}
+			apih := makeMockApiHandler(t, inv)
+
+			req := makeReq("GET",
+				"http://1.2.3.4/api/0.1.0/devices?"+tc.query, "", nil)
+			recorded := test.RunRequest(t, apih, req)
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+		})
+	}
+}
+
+func TestApiInventoryGetDevicesSort(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		query string
+
+		sort []store.Sort
+
+		code int
+		resp string
+	}{
+		"ok": {
+			query: "sort=name:asc",
+			sort: []store.Sort{
+				{AttrName: "name", AttrScope: model.AttrScopeInventory, Ascending: true},
+			},
+			code: http.StatusOK,
+			resp: ToJson(mockListDevices(2)),
+		},
+		"ok, several keys": {
+			query: "sort=system/group:asc&sort=system/updated_ts",
+			sort: []store.Sort{
+				{AttrName: "group", AttrScope: model.AttrScopeSystem, Ascending: true},
+				{AttrName: "updated_ts", AttrScope: model.AttrScopeSystem},
+			},
+			code: http.StatusOK,
+			resp: ToJson(mockListDevices(2)),
+		},
+		"ok, list": {
+			query: "sort=system/group:asc,system/updated_ts",
+			sort: []store.Sort{
+				{AttrName: "group", AttrScope: model.AttrScopeSystem, Ascending: true},
+				{AttrName: "updated_ts", AttrScope: model.AttrScopeSystem},
+			},
+			code: http.StatusOK,
+			resp: ToJson(mockListDevices(2)),
+		},
+		"ok, nulls placement": {
+			query: "sort=name:asc:nulls_last,system/group:desc:nulls_first",
+			sort: []store.Sort{
+				{
+					AttrName:  "name",
+					AttrScope: model.AttrScopeInventory,
+					Ascending: true,
+					Nulls:     model.SortNullsLast,
+				},
+				{
+					AttrName:  "group",
+					AttrScope: model.AttrScopeSystem,
+					Nulls:     model.SortNullsFirst,
+				},
+			},
+			code: http.StatusOK,
+			resp: ToJson(mockListDevices(2)),
+		},
+		"error, order": {
+			query: "sort=name:asc,status:up",
+			code:  http.StatusBadRequest,
+			resp:  ToJson(restError("invalid sort order")),
+		},
+		"error, nulls placement": {
+			query: "sort=name:asc:nulls",
+			code:  http.StatusBadRequest,
+			resp:  ToJson(restError("invalid sort nulls placement")),
+		},
+		"error, sort key": {
+			query: "sort=name:asc:nulls_last:x",
+			code:  http.StatusBadRequest,
+			resp:  ToJson(restError("invalid sort key")),
+		},
+		"error, duplicate": {
+			query: "sort=name:asc&sort=inventory/name:desc",
+			code:  http.StatusBadRequest,
+			resp:  ToJson(restError("duplicate sort attribute: inventory/name")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := &minventory.InventoryApp{}
+			defer inv.AssertExpectations(t)
+			if tc.sort != nil {
+				inv.On("CheckLimits", contextMatcher(), mock.AnythingOfType("model.Limits")).
+					Return(nil, nil)
+				inv.On("ListDevices",
+					contextMatcher(),
+					mock.MatchedBy(func(q store.ListQuery) bool {
+						return assert.ObjectsAreEqual(tc.sort, q.Sort)
+					}),
+				).Return(mockListDevices(2), 2, nil)
+			}
+			apih := makeMockApiHandler(t, inv)
+
+			req := makeReq("GET",
+				"http://1.2.3.4/api/0.1.0/devices?"+tc.query, "", nil)
+			recorded := test.RunRequest(t, apih, req)
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+		})
+	}
+}
+
+func TestApiInventoryGetDevicesCursor(t *testing.T) {
+	t.Parallel()
+
+	sort := []model.SortCriteria{{
+		Scope:     model.AttrScopeInventory,
+		Attribute: "mem",
+		Order:     "asc",
+	}}
+	devs := []model.Device{{
+		ID: "1",
+		Attributes: model.DeviceAttributes{
+			{Scope: model.AttrScopeInventory, Name: "mem", Value: 512.0},
+		},
+	}, {
+		ID: "2",
+		Attributes: model.DeviceAttributes{
+			{Scope: model.AttrScopeInventory, Name: "mem", Value: 1024.0},
+		},
+	}}
+	token := func(cursor *model.DeviceCursor) string {
+		token, err := cursor.Encode()
+		assert.NoError(t, err)
+		return token
+	}
+	first := token(model.NewDeviceCursor(sort, devs[0]))
+	next := token(model.NewDeviceCursor(sort, devs[1]))
+
+	testCases := map[string]struct {
+		query string
+
+		cursor *model.DeviceCursor
+		devs   []model.Device
+
+		code int
+		resp string
+		next string
+	}{
+		"ok, first page": {
+			query:  "cursor=&sort=mem:asc&per_page=2",
+			cursor: &model.DeviceCursor{Sort: sort},
+			devs:   devs,
+			code:   http.StatusOK,
+			resp:   ToJson(devs),
+			next:   next,
+		},
+		"ok, next page": {
+			query:  "cursor=" + first + "&sort=mem:asc&per_page=2",
+			cursor: model.NewDeviceCursor(sort, devs[0]),
+			devs:   devs[1:],
+			code:   http.StatusOK,
+			resp:   ToJson(devs[1:]),
+		},
+		"error, sort changed": {
+			query: "cursor=" + first + "&sort=mem:desc",
+			code:  http.StatusBadRequest,
+			resp:  ToJson(restError(model.ErrInvalidDeviceCursor.Error())),
+		},
+		"error, page": {
+			query: "cursor=&page=2",
+			code:  http.StatusBadRequest,
+			resp:  ToJson(restError("cursor conflicts with page")),
+		},
+		"error, updated since": {
+			query: "cursor=&updated_since=2021-06-01T12:00:00Z",
+			code:  http.StatusBadRequest,
+			resp:  ToJson(restError("cursor conflicts with updated_since")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := &minventory.InventoryApp{}
+			defer inv.AssertExpectations(t)
+			if tc.cursor != nil {
+				inv.On("CheckLimits", contextMatcher(), mock.AnythingOfType("model.Limits")).
+					Return(nil, nil)
+				inv.On("ListDevices",
+					contextMatcher(),
+					mock.MatchedBy(func(q store.ListQuery) bool {
+						return assert.ObjectsAreEqual(tc.cursor, q.Cursor)
+					}),
+				).Return(tc.devs, len(devs), nil)
+			}
+			apih := makeMockApiHandler(t, inv)
+
+			req := makeReq("GET",
+				"http://1.2.3.4/api/0.1.0/devices?"+tc.query, "", nil)
+			recorded := test.RunRequest(t, apih, req)
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			recorded.HeaderIs(hdrNextCursor, tc.next)
+		})
+	}
+}
+
+func TestApiInventoryGetDevicesNDJSON(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		devices   []model.Device
+		total     int
+		streamErr error
+
+		code int
+		body string
+	}{
+		"ok": {
+			devices: mockListDevices(3),
+			total:   18,
+
+			code: http.StatusOK,
+			body: "{\"id\":\"0\",\"updated_ts\":\"0001-01-01T00:00:00Z\"}\n" +
+				"{\"id\":\"1\",\"updated_ts\":\"0001-01-01T00:00:00Z\"}\n" +
+				"{\"id\":\"2\",\"updated_ts\":\"0001-01-01T00:00:00Z\"}\n",
+		},
+		"ok, no devices": {
+			total: 0,
+
+			code: http.StatusOK,
+			body: "",
+		},
+		"error": {
+			streamErr: errors.New("inventory error"),
+
+			code: http.StatusInternalServerError,
+			body: ToJson(restError("internal error")),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := &minventory.InventoryApp{}
+			defer inv.AssertExpectations(t)
+
+			var stream *store.DeviceStream
+			if tc.streamErr == nil {
+				stream = mockDeviceStream(tc.devices)
+			}
+			inv.On("CheckLimits", contextMatcher(), mock.AnythingOfType("model.Limits")).
+				Return(nil, nil).Maybe()
+			inv.On("StreamDevices",
+				contextMatcher(),
+				mock.MatchedBy(func(q store.ListQuery) bool {
+					return q.Skip == 5 && q.Limit == 5
+				}),
+			).Return(stream, tc.total, tc.streamErr)
+
+			req := makeReq("GET",
+				"http://1.2.3.4/api/0.1.0/devices?page=2&per_page=5", "", nil)
+			req.Header.Set("Accept", contentTypeNDJSON)
+			recorded := test.RunRequest(t, makeMockApiHandler(t, inv), req)
+			recorded.CodeIs(tc.code)
+			assert.Equal(t, tc.body, recorded.Recorder.Body.String())
+			if tc.code == http.StatusOK {
+				recorded.HeaderIs("Content-Type", contentTypeNDJSON)
+				recorded.HeaderIs(hdrTotalCount, strconv.Itoa(tc.total))
+			}
+		})
+	}
+}
+
 func TestApiInventoryAddDevice(t *testing.T) {
 	t.Parallel()
 	rest.ErrorFieldName = "error"
@@ -685,6 +1416,7 @@ func TestApiInventoryUpsertAttributes(t *testing.T) {
 
 		resp             utils.JSONResponseParams
 		deviceAttributes model.DeviceAttributes
+		replacedScope    string
 	}{
 		"no auth": {
 			inReq: test.MakeSimpleRequest("PATCH",
@@ -725,50 +1457,94 @@ func TestApiInventoryUpsertAttributes(t *testing.T) {
 			},
 		},
 
-		"garbled body": {
+		"scope write forbidden": {
 			inReq: test.MakeSimpleRequest("PATCH",
 				"http://1.2.3.4/api/0.1.0/attributes",
-				`{"foo": "bar"}`),
+				[]model.DeviceAttribute{
+					{
+						Name:  "name1",
+						Value: "value1",
+						Scope: "warranty",
+					},
+				},
+			),
 			inHdrs: map[string]string{
 				"Authorization": makeDeviceAuthHeader(`{"sub": "fakeid"}`),
 			},
-			inventoryErr: nil,
+			inventoryErr: errors.Wrap(inventory.ErrScopeWriteForbidden, "scope warranty"),
 			resp: utils.JSONResponseParams{
-				OutputStatus:     http.StatusBadRequest,
-				OutputBodyObject: RestError("failed to decode request body: json: cannot unmarshal string into Go value of type []model.DeviceAttribute"),
+				OutputStatus:     http.StatusForbidden,
+				OutputBodyObject: RestError("scope warranty: writing attributes of the scope is forbidden"),
 			},
 		},
 
-		"body formatted ok, attribute name missing": {
+		"schema violation": {
 			inReq: test.MakeSimpleRequest("PATCH",
 				"http://1.2.3.4/api/0.1.0/attributes",
 				[]model.DeviceAttribute{
 					{
-						Name:        "name1",
-						Value:       "value1",
-						Description: strPtr("descr1"),
-					},
-					{
-						Value:       2,
-						Description: strPtr("descr2"),
+						Name:  "cpu_count",
+						Value: "4",
+						Scope: "inventory",
 					},
 				},
 			),
 			inHdrs: map[string]string{
 				"Authorization": makeDeviceAuthHeader(`{"sub": "fakeid"}`),
 			},
-			inventoryErr: nil,
+			inventoryErr: errors.Wrap(inventory.ErrSchemaViolation,
+				"attribute inventory/cpu_count must be of type number"),
 			resp: utils.JSONResponseParams{
-				OutputStatus:     http.StatusBadRequest,
-				OutputBodyObject: RestError("name: cannot be blank."),
+				OutputStatus: http.StatusBadRequest,
+				OutputBodyObject: RestError("attribute inventory/cpu_count " +
+					"must be of type number: attribute does not conform to the schema"),
 			},
 		},
 
-		"body formatted ok, attribute value missing": {
+		"garbled body": {
 			inReq: test.MakeSimpleRequest("PATCH",
 				"http://1.2.3.4/api/0.1.0/attributes",
-				[]model.DeviceAttribute{
-					{
+				`{"foo": "bar"}`),
+			inHdrs: map[string]string{
+				"Authorization": makeDeviceAuthHeader(`{"sub": "fakeid"}`),
+			},
+			inventoryErr: nil,
+			resp: utils.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: RestError("failed to decode request body: json: cannot unmarshal string into Go value of type []model.DeviceAttribute"),
+			},
+		},
+
+		"body formatted ok, attribute name missing": {
+			inReq: test.MakeSimpleRequest("PATCH",
+				"http://1.2.3.4/api/0.1.0/attributes",
+				[]model.DeviceAttribute{
+					{
+						Name:        "name1",
+						Value:       "value1",
+						Description: strPtr("descr1"),
+					},
+					{
+						Value:       2,
+						Description: strPtr("descr2"),
+					},
+				},
+			),
+			inHdrs: map[string]string{
+				"Authorization": makeDeviceAuthHeader(`{"sub": "fakeid"}`),
+			},
+			inventoryErr: nil,
+			resp: utils.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: RestError("name: cannot be blank."),
+			},
+		},
+
+		"body formatted ok, attribute value missing": {
+			inReq: test.MakeSimpleRequest("PATCH",
+				"http://1.2.3.4/api/0.1.0/attributes",
+				[]model.DeviceAttribute{
+					{
 						Name:        "name1",
 						Description: strPtr("descr1"),
 					},
@@ -969,11 +1745,87 @@ func TestApiInventoryUpsertAttributes(t *testing.T) {
 				OutputBodyObject: nil,
 			},
 		},
+
+		"agent": {
+			inReq: test.MakeSimpleRequest("PATCH",
+				"http://1.2.3.4/api/0.1.0/attributes?agent=gateway",
+				[]model.DeviceAttribute{
+					{Name: "name1", Value: "value1"},
+					{Name: "name2", Value: "value2", Scope: "telemetry"},
+				},
+			),
+			inHdrs: map[string]string{
+				"Authorization": makeDeviceAuthHeader(`{"sub": "fakeid"}`),
+			},
+			resp: utils.JSONResponseParams{
+				OutputStatus: http.StatusOK,
+			},
+			deviceAttributes: model.DeviceAttributes{
+				{Name: "name1", Value: "value1", Scope: "inventory:gateway"},
+				{Name: "name2", Value: "value2", Scope: "telemetry:gateway"},
+			},
+		},
+
+		"agent, PUT": {
+			inReq: test.MakeSimpleRequest("PUT",
+				"http://1.2.3.4/api/0.1.0/attributes?agent=gateway",
+				[]model.DeviceAttribute{
+					{Name: "name1", Value: "value1"},
+				},
+			),
+			inHdrs: map[string]string{
+				"Authorization": makeDeviceAuthHeader(`{"sub": "fakeid"}`),
+			},
+			resp: utils.JSONResponseParams{
+				OutputStatus: http.StatusOK,
+			},
+			deviceAttributes: model.DeviceAttributes{
+				{Name: "name1", Value: "value1", Scope: "inventory:gateway"},
+			},
+			replacedScope: "inventory:gateway",
+		},
+
+		"agent, invalid name": {
+			inReq: test.MakeSimpleRequest("PATCH",
+				"http://1.2.3.4/api/0.1.0/attributes?agent=Gate-Way",
+				[]model.DeviceAttribute{
+					{Name: "name1", Value: "value1"},
+				},
+			),
+			inHdrs: map[string]string{
+				"Authorization": makeDeviceAuthHeader(`{"sub": "fakeid"}`),
+			},
+			resp: utils.JSONResponseParams{
+				OutputStatus: http.StatusBadRequest,
+				OutputBodyObject: RestError(`invalid agent name "Gate-Way": ` +
+					`must be up to 32 lower case letters, digits and underscores`),
+			},
+		},
+
+		"agent, sub-scope": {
+			inReq: test.MakeSimpleRequest("PATCH",
+				"http://1.2.3.4/api/0.1.0/attributes?agent=gateway",
+				[]model.DeviceAttribute{
+					{Name: "name1", Value: "value1", Scope: "inventory:other"},
+				},
+			),
+			inHdrs: map[string]string{
+				"Authorization": makeDeviceAuthHeader(`{"sub": "fakeid"}`),
+			},
+			resp: utils.JSONResponseParams{
+				OutputStatus: http.StatusBadRequest,
+				OutputBodyObject: RestError("the scope of the attributes of " +
+					`the agent must not be a sub-scope: "inventory:other"`),
+			},
+		},
 	}
 
 	for name, tc := range testCases {
 		t.Logf("test case: %s", name)
 		inv := minventory.InventoryApp{}
+		if tc.replacedScope == "" {
+			tc.replacedScope = model.AttrScopeInventory
+		}
 
 		ctx := contextMatcher()
 
@@ -1006,7 +1858,7 @@ func TestApiInventoryUpsertAttributes(t *testing.T) {
 						return true
 					},
 				),
-				model.AttrScopeInventory,
+				tc.replacedScope,
 			).Return(tc.inventoryErr)
 		}
 
@@ -1320,6 +2172,66 @@ func TestApiInventoryUpsertAttributesInternal(t *testing.T) {
 				OutputBodyObject: RestError("internal error"),
 			},
 		},
+
+		"invalid parent device": {
+			tenantId: "3456355",
+			deviceId: "sdfg435fgs-gs-dgsfgdfs-3456dgsf",
+			scope:    "system",
+
+			payload: []model.DeviceAttribute{
+				{
+					Name:  "parent_device",
+					Value: "gw",
+				},
+			},
+			inventoryErr: errors.Wrap(inventory.ErrInvalidParentDevice,
+				"device gw not found"),
+			resp: utils.JSONResponseParams{
+				OutputStatus: http.StatusBadRequest,
+				OutputBodyObject: RestError(
+					"device gw not found: invalid parent device"),
+			},
+		},
+
+		"rejected by the validation webhook": {
+			tenantId: "3456355",
+			deviceId: "sdfg435fgs-gs-dgsfgdfs-3456dgsf",
+			scope:    "inventory",
+
+			payload: []model.DeviceAttribute{
+				{
+					Name:  "location",
+					Value: "lab",
+				},
+			},
+			inventoryErr: errors.Wrap(inventory.ErrAttributesRejected,
+				"unknown location"),
+			resp: utils.JSONResponseParams{
+				OutputStatus: http.StatusBadRequest,
+				OutputBodyObject: RestError("unknown location: " +
+					"attributes rejected by the validation webhook"),
+			},
+		},
+
+		"validation webhook unavailable": {
+			tenantId: "3456355",
+			deviceId: "sdfg435fgs-gs-dgsfgdfs-3456dgsf",
+			scope:    "inventory",
+
+			payload: []model.DeviceAttribute{
+				{
+					Name:  "location",
+					Value: "lab",
+				},
+			},
+			inventoryErr: errors.Wrap(inventory.ErrValidationUnavailable,
+				"connection refused"),
+			resp: utils.JSONResponseParams{
+				OutputStatus: http.StatusServiceUnavailable,
+				OutputBodyObject: RestError(
+					"connection refused: validation webhook unavailable"),
+			},
+		},
 	}
 
 	for name, tc := range testCases {
@@ -1571,13 +2483,112 @@ func TestApiListGroups(t *testing.T) {
 	}
 }
 
+func TestApiSearchGroups(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		query string
+
+		callInv bool
+		q       store.GroupsQuery
+		groups  []model.GroupName
+		total   int
+		err     error
+
+		code  int
+		links []string
+		resp  string
+	}{
+		"ok, prefix": {
+			query:   "?name=site-",
+			callInv: true,
+			q:       store.GroupsQuery{Prefix: "site-", Limit: 20},
+			groups:  []model.GroupName{"site-bergen", "site-oslo"},
+			total:   2,
+			code:    http.StatusOK,
+			links: []string{
+				`<groups?name=site-&page=1&per_page=20>; rel="first"`,
+			},
+			resp: ToJson([]model.GroupName{"site-bergen", "site-oslo"}),
+		},
+		"ok, page with status": {
+			query:   "?status=accepted&page=2&per_page=1",
+			callInv: true,
+			q: store.GroupsQuery{
+				Filters: []model.FilterPredicate{{
+					Scope:     model.AttrScopeIdentity,
+					Attribute: "status",
+					Type:      "$eq",
+					Value:     "accepted",
+				}},
+				Skip:  1,
+				Limit: 1,
+			},
+			groups: []model.GroupName{"site-oslo"},
+			total:  3,
+			code:   http.StatusOK,
+			links: []string{
+				`<groups?page=1&per_page=1&status=accepted>; rel="prev"`,
+				`<groups?page=3&per_page=1&status=accepted>; rel="next"`,
+				`<groups?page=1&per_page=1&status=accepted>; rel="first"`,
+			},
+			resp: ToJson([]model.GroupName{"site-oslo"}),
+		},
+		"error, pagination": {
+			query: "?name=site-&per_page=0",
+			code:  http.StatusBadRequest,
+			resp:  ToJson(restError("Param per_page is out of bounds")),
+		},
+		"error, internal": {
+			query:   "?name=site-",
+			callInv: true,
+			q:       store.GroupsQuery{Prefix: "site-", Limit: 20},
+			total:   -1,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			inv.On("CheckLimits", contextMatcher(), mock.AnythingOfType("model.Limits")).
+				Return(nil, nil).Maybe()
+			if tc.callInv {
+				inv.On("SearchGroups", contextMatcher(), tc.q).
+					Return(tc.groups, tc.total, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodGet,
+				"http://localhost"+uriGroups+tc.query, "", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			if tc.code == http.StatusOK {
+				assert.Equal(t, tc.links, recorded.Recorder.Header()["Link"])
+				recorded.HeaderIs(hdrTotalCount, strconv.Itoa(tc.total))
+			}
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
 func TestApiGetDevice(t *testing.T) {
 	rest.ErrorFieldName = "error"
 
+	updatedTs := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
 	tcases := map[string]struct {
 		utils.JSONResponseParams
 
 		inReq        *http.Request
+		inExtID      *model.ExternalIDRef
+		resolveErr   error
 		inDevId      model.DeviceID
 		outputDevice *model.Device
 		inventoryErr error
@@ -1606,6 +2617,32 @@ func TestApiGetDevice(t *testing.T) {
 				},
 			},
 		},
+		"device with telemetry": {
+			inDevId: model.DeviceID("2"),
+			inReq:   test.MakeSimpleRequest("GET", "http://1.2.3.4/api/0.1.0/devices/2", nil),
+			outputDevice: &model.Device{
+				ID: model.DeviceID("2"),
+				Telemetry: &model.DeviceTelemetry{
+					Updates:       3,
+					LastUpdateTs:  &updatedTs,
+					Intervals:     []float64{60, 120},
+					UpdatesPerDay: 960,
+				},
+			},
+			JSONResponseParams: utils.JSONResponseParams{
+				OutputStatus: http.StatusOK,
+				OutputBodyObject: map[string]interface{}{
+					"id":         "2",
+					"updated_ts": time.Time{},
+					"telemetry": map[string]interface{}{
+						"updates":         3,
+						"last_update_ts":  updatedTs,
+						"intervals":       []float64{60, 120},
+						"updates_per_day": 960,
+					},
+				},
+			},
+		},
 		"error": {
 			inDevId: model.DeviceID("3"),
 			inReq:   test.MakeSimpleRequest("GET", "http://1.2.3.4/api/0.1.0/devices/3", nil),
@@ -1615,6 +2652,37 @@ func TestApiGetDevice(t *testing.T) {
 			},
 			inventoryErr: errors.New("internal error"),
 		},
+		"device by external ID": {
+			inReq:   test.MakeSimpleRequest("GET", "http://1.2.3.4/api/0.1.0/devices/external:erp:SN-1", nil),
+			inExtID: &model.ExternalIDRef{System: "erp", ID: "SN-1"},
+			inDevId: model.DeviceID("2"),
+			outputDevice: &model.Device{
+				ID: model.DeviceID("2"),
+			},
+			JSONResponseParams: utils.JSONResponseParams{
+				OutputStatus: http.StatusOK,
+				OutputBodyObject: model.Device{
+					ID: model.DeviceID("2"),
+				},
+			},
+		},
+		"external ID not found": {
+			inReq:      test.MakeSimpleRequest("GET", "http://1.2.3.4/api/0.1.0/devices/external:erp:SN-1", nil),
+			inExtID:    &model.ExternalIDRef{System: "erp", ID: "SN-1"},
+			resolveErr: store.ErrExternalIDNotFound,
+			JSONResponseParams: utils.JSONResponseParams{
+				OutputStatus:     http.StatusNotFound,
+				OutputBodyObject: RestError(store.ErrExternalIDNotFound.Error()),
+			},
+		},
+		"error, invalid external ID": {
+			inReq: test.MakeSimpleRequest("GET", "http://1.2.3.4/api/0.1.0/devices/external:erp", nil),
+			JSONResponseParams: utils.JSONResponseParams{
+				OutputStatus: http.StatusBadRequest,
+				OutputBodyObject: RestError("invalid external device reference: " +
+					"external:erp, expected external:<system>:<id>"),
+			},
+		},
 	}
 
 	for name, tc := range tcases {
@@ -1623,6 +2691,10 @@ func TestApiGetDevice(t *testing.T) {
 
 		ctx := contextMatcher()
 
+		if tc.inExtID != nil {
+			inv.On("ResolveExternalID", ctx, *tc.inExtID).
+				Return(tc.inDevId, tc.resolveErr)
+		}
 		inv.On("GetDevice", ctx, tc.inDevId).Return(tc.outputDevice, tc.inventoryErr)
 
 		apih := makeMockApiHandler(t, &inv)
@@ -1631,6 +2703,90 @@ func TestApiGetDevice(t *testing.T) {
 	}
 }
 
+func TestApiGetDeviceColdAttributes(t *testing.T) {
+	t.Parallel()
+
+	hot := model.DeviceAttribute{
+		Scope: model.AttrScopeInventory, Name: "os", Value: "linux",
+	}
+	cold := model.DeviceAttribute{
+		Scope: "packages", Name: "installed", Value: []interface{}{"a", "b"},
+	}
+	testCases := map[string]struct {
+		query string
+
+		callGetDevice bool
+		callGetCold   bool
+		coldErr       error
+
+		code int
+		resp string
+	}{
+		"ok, without cold attributes": {
+			callGetDevice: true,
+			code:          http.StatusOK,
+			resp: ToJson(model.Device{
+				ID:         "1",
+				Attributes: model.DeviceAttributes{hot},
+			}),
+		},
+		"ok, with cold attributes": {
+			query:         "include=cold",
+			callGetDevice: true,
+			callGetCold:   true,
+			code:          http.StatusOK,
+			resp: ToJson(model.Device{
+				ID:         "1",
+				Attributes: model.DeviceAttributes{hot, cold},
+			}),
+		},
+		"error, include": {
+			query: "include=all",
+			code:  http.StatusBadRequest,
+			resp:  ToJson(restError("invalid include value: must be cold")),
+		},
+		"error, cold attributes": {
+			query:         "include=cold",
+			callGetDevice: true,
+			callGetCold:   true,
+			coldErr:       errors.New("connection error"),
+			code:          http.StatusInternalServerError,
+			resp:          ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := &minventory.InventoryApp{}
+			defer inv.AssertExpectations(t)
+			if tc.callGetDevice {
+				inv.On("GetDevice", contextMatcher(), model.DeviceID("1")).
+					Return(&model.Device{
+						ID:         "1",
+						Attributes: model.DeviceAttributes{hot},
+					}, nil)
+			}
+			if tc.callGetCold {
+				var attrs model.DeviceAttributes
+				if tc.coldErr == nil {
+					attrs = model.DeviceAttributes{cold}
+				}
+				inv.On("GetColdAttributes", contextMatcher(), model.DeviceID("1")).
+					Return(attrs, tc.coldErr)
+			}
+			apih := makeMockApiHandler(t, inv)
+
+			req := makeReq("GET",
+				"http://1.2.3.4/api/0.1.0/devices/1?"+tc.query, "", nil)
+			recorded := test.RunRequest(t, apih, req)
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+		})
+	}
+}
+
 func TestApiInventoryGetDevicesByGroup(t *testing.T) {
 	t.Parallel()
 	rest.ErrorFieldName = "error"
@@ -1739,11 +2895,12 @@ func TestApiInventoryGetDevicesByGroup(t *testing.T) {
 
 		ctx := contextMatcher()
 
+		inv.On("CheckLimits", contextMatcher(), mock.AnythingOfType("model.Limits")).
+			Return(nil, nil).Maybe()
 		inv.On("ListDevicesByGroup",
 			ctx,
 			mock.AnythingOfType("model.GroupName"),
-			mock.AnythingOfType("int"),
-			mock.AnythingOfType("int"),
+			mock.AnythingOfType("store.ListQuery"),
 		).Return(mockListDeviceIDs(testCase.listDevicesNum), testCase.listDevicesTotal, testCase.listDevicesErr)
 
 		apih := makeMockApiHandler(t, &inv)
@@ -1752,76 +2909,274 @@ func TestApiInventoryGetDevicesByGroup(t *testing.T) {
 	}
 }
 
-func TestApiGetDeviceGroup(t *testing.T) {
-	rest.ErrorFieldName = "error"
-
-	tcases := map[string]struct {
-		utils.JSONResponseParams
+func TestApiInventoryGetDevicesByGroupRecursive(t *testing.T) {
+	t.Parallel()
 
-		inReq *http.Request
+	testCases := map[string]struct {
+		url            string
+		descendants    []model.GroupName
+		descendantsErr error
 
-		inventoryGroup model.GroupName
-		inventoryErr   error
+		groupNames []model.GroupName
+		code       int
 	}{
-
-		/*
-		   device w group
-		   device n group
-		   no device
-		   generic error
-		*/
-
-		"device with group": {
-			inReq:          test.MakeSimpleRequest("GET", "http://1.2.3.4/api/0.1.0/devices/1/group", nil),
-			inventoryGroup: model.GroupName("dev"),
-			inventoryErr:   nil,
-
-			JSONResponseParams: utils.JSONResponseParams{
-				OutputStatus:     http.StatusOK,
-				OutputBodyObject: map[string]interface{}{"group": "dev"},
-			},
+		"ok": {
+			url:         "/api/0.1.0/groups/eu/devices?recursive=true",
+			descendants: []model.GroupName{"de", "fr"},
+			groupNames:  []model.GroupName{"eu", "de", "fr"},
+			code:        http.StatusOK,
 		},
-		"device without group": {
-			inReq:          test.MakeSimpleRequest("GET", "http://1.2.3.4/api/0.1.0/devices/1/group", nil),
-			inventoryGroup: model.GroupName(""),
-			inventoryErr:   nil,
-
-			JSONResponseParams: utils.JSONResponseParams{
-				OutputStatus:     http.StatusOK,
-				OutputBodyObject: map[string]interface{}{"group": nil},
-			},
+		"ok, no descendants": {
+			url:         "/api/0.1.0/groups/eu/devices?recursive=true",
+			descendants: []model.GroupName{},
+			code:        http.StatusOK,
 		},
-		"device not found": {
-			inReq:          test.MakeSimpleRequest("GET", "http://1.2.3.4/api/0.1.0/devices/1/group", nil),
-			inventoryGroup: model.GroupName(""),
-			inventoryErr:   store.ErrDevNotFound,
-
-			JSONResponseParams: utils.JSONResponseParams{
-				OutputStatus:     http.StatusNotFound,
-				OutputBodyObject: RestError(store.ErrDevNotFound.Error()),
-			},
+		"ok, not recursive": {
+			url:  "/api/0.1.0/groups/eu/devices?recursive=false",
+			code: http.StatusOK,
 		},
-		"generic inventory error": {
-			inReq:          test.MakeSimpleRequest("GET", "http://1.2.3.4/api/0.1.0/devices/1/group", nil),
-			inventoryGroup: model.GroupName(""),
-			inventoryErr:   errors.New("inventory: internal error"),
-
-			JSONResponseParams: utils.JSONResponseParams{
-				OutputStatus:     http.StatusInternalServerError,
-				OutputBodyObject: RestError("internal error"),
-			},
+		"error, recursive": {
+			url:  "/api/0.1.0/groups/eu/devices?recursive=maybe",
+			code: http.StatusBadRequest,
+		},
+		"error, descendants": {
+			url:            "/api/0.1.0/groups/eu/devices?recursive=true",
+			descendantsErr: errors.New("db error"),
+			code:           http.StatusInternalServerError,
 		},
 	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
 
-	for name, tc := range tcases {
-		t.Logf("test case: %s", name)
-		inv := minventory.InventoryApp{}
+			inv := minventory.InventoryApp{}
+			inv.On("CheckLimits", contextMatcher(), mock.AnythingOfType("model.Limits")).
+				Return(nil, nil).Maybe()
+			if tc.descendants != nil || tc.descendantsErr != nil {
+				inv.On("GetGroupDescendants", contextMatcher(), model.GroupName("eu")).
+					Return(tc.descendants, tc.descendantsErr)
+			}
+			if tc.code == http.StatusOK {
+				inv.On("ListDevicesByGroup", contextMatcher(), model.GroupName("eu"),
+					mock.MatchedBy(func(q store.ListQuery) bool {
+						return assert.Equal(t, tc.groupNames, q.GroupNames) &&
+							len(q.Filters) == 0
+					}),
+				).Return(mockListDeviceIDs(2), 2, nil)
+			}
 
-		ctx := contextMatcher()
+			api := makeMockApiHandler(t, &inv)
+			req := test.MakeSimpleRequest("GET", "http://localhost"+tc.url, nil)
+			recorded := test.RunRequest(t, api, req)
+			recorded.CodeIs(tc.code)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiInventoryGetDevicesByGroupFull(t *testing.T) {
+	t.Parallel()
+
+	defaultQuery := store.ListQuery{Limit: 20, Filters: []store.Filter{}}
+	testCases := map[string]struct {
+		query string
+
+		callFull bool
+		callIDs  bool
+		q        store.ListQuery
+		err      error
+
+		code int
+		resp string
+	}{
+		"ok, full devices": {
+			query:    "full=true",
+			callFull: true,
+			q:        defaultQuery,
+			code:     http.StatusOK,
+			resp:     ToJson(mockListDevices(2)),
+		},
+		"ok, device IDs": {
+			query:   "full=false",
+			callIDs: true,
+			q:       defaultQuery,
+			code:    http.StatusOK,
+			resp:    ToJson(mockListDeviceIDs(2)),
+		},
+		"ok, filters and sort": {
+			query:    "full=true&artifact_name=release-2&sort=system/updated_ts:desc",
+			callFull: true,
+			q: store.ListQuery{
+				Limit: 20,
+				Filters: []store.Filter{{
+					AttrName:  "artifact_name",
+					AttrScope: model.AttrScopeInventory,
+					Value:     "release-2",
+					Operator:  store.Eq,
+				}},
+				Sort: []store.Sort{{
+					AttrName:  model.AttrNameUpdated,
+					AttrScope: model.AttrScopeSystem,
+					Ascending: false,
+				}},
+			},
+			code: http.StatusOK,
+			resp: ToJson(mockListDevices(2)),
+		},
+		"error, full": {
+			query: "full=yes",
+			code:  http.StatusBadRequest,
+			resp:  ToJson(restError(utils.MsgQueryParmInvalid("full"))),
+		},
+		"error, group not found": {
+			query:    "full=true",
+			callFull: true,
+			q:        defaultQuery,
+			err:      store.ErrGroupNotFound,
+			code:     http.StatusNotFound,
+			resp:     ToJson(restError(store.ErrGroupNotFound.Error())),
+		},
+		"error, dynamic group": {
+			query:   "artifact_name=release-2",
+			callIDs: true,
+			q: store.ListQuery{
+				Limit: 20,
+				Filters: []store.Filter{{
+					AttrName:  "artifact_name",
+					AttrScope: model.AttrScopeInventory,
+					Value:     "release-2",
+					Operator:  store.Eq,
+				}},
+			},
+			err:  inventory.ErrDynamicGroupQuery,
+			code: http.StatusBadRequest,
+			resp: ToJson(restError(inventory.ErrDynamicGroupQuery.Error())),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := &minventory.InventoryApp{}
+			defer inv.AssertExpectations(t)
+			inv.On("CheckLimits", contextMatcher(), mock.AnythingOfType("model.Limits")).
+				Return(nil, nil).Maybe()
+			if tc.callFull {
+				var devs []model.Device
+				if tc.err == nil {
+					devs = mockListDevices(2)
+				}
+				inv.On("ListDevicesByGroupFull",
+					contextMatcher(), model.GroupName("foo"), tc.q,
+				).Return(devs, 2, tc.err)
+			}
+			if tc.callIDs {
+				var ids []model.DeviceID
+				if tc.err == nil {
+					ids = mockListDeviceIDs(2)
+				}
+				inv.On("ListDevicesByGroup",
+					contextMatcher(), model.GroupName("foo"), tc.q,
+				).Return(ids, 2, tc.err)
+			}
+			apih := makeMockApiHandler(t, inv)
+
+			req := makeReq("GET",
+				"http://1.2.3.4/api/0.1.0/groups/foo/devices?"+tc.query, "", nil)
+			recorded := test.RunRequest(t, apih, req)
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+		})
+	}
+}
+
+func TestApiGetDeviceGroup(t *testing.T) {
+	rest.ErrorFieldName = "error"
+
+	tcases := map[string]struct {
+		utils.JSONResponseParams
+
+		inReq *http.Request
+
+		inventoryGroups []model.GroupName
+		inventoryErr    error
+	}{
+
+		/*
+		   device w group
+		   device n group
+		   no device
+		   generic error
+		*/
+
+		"device with group": {
+			inReq:           test.MakeSimpleRequest("GET", "http://1.2.3.4/api/0.1.0/devices/1/group", nil),
+			inventoryGroups: []model.GroupName{"dev"},
+			inventoryErr:    nil,
+
+			JSONResponseParams: utils.JSONResponseParams{
+				OutputStatus: http.StatusOK,
+				OutputBodyObject: map[string]interface{}{
+					"group":  "dev",
+					"groups": []string{"dev"},
+				},
+			},
+		},
+		"device with several groups": {
+			inReq:           test.MakeSimpleRequest("GET", "http://1.2.3.4/api/0.1.0/devices/1/group", nil),
+			inventoryGroups: []model.GroupName{"dev", "eu"},
+
+			JSONResponseParams: utils.JSONResponseParams{
+				OutputStatus: http.StatusOK,
+				OutputBodyObject: map[string]interface{}{
+					"group":  "dev",
+					"groups": []string{"dev", "eu"},
+				},
+			},
+		},
+		"device without group": {
+			inReq:        test.MakeSimpleRequest("GET", "http://1.2.3.4/api/0.1.0/devices/1/group", nil),
+			inventoryErr: nil,
+
+			JSONResponseParams: utils.JSONResponseParams{
+				OutputStatus: http.StatusOK,
+				OutputBodyObject: map[string]interface{}{
+					"group":  nil,
+					"groups": []string{},
+				},
+			},
+		},
+		"device not found": {
+			inReq:        test.MakeSimpleRequest("GET", "http://1.2.3.4/api/0.1.0/devices/1/group", nil),
+			inventoryErr: store.ErrDevNotFound,
+
+			JSONResponseParams: utils.JSONResponseParams{
+				OutputStatus:     http.StatusNotFound,
+				OutputBodyObject: RestError(store.ErrDevNotFound.Error()),
+			},
+		},
+		"generic inventory error": {
+			inReq:        test.MakeSimpleRequest("GET", "http://1.2.3.4/api/0.1.0/devices/1/group", nil),
+			inventoryErr: errors.New("inventory: internal error"),
 
-		inv.On("GetDeviceGroup",
+			JSONResponseParams: utils.JSONResponseParams{
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: RestError("internal error"),
+			},
+		},
+	}
+
+	for name, tc := range tcases {
+		t.Logf("test case: %s", name)
+		inv := minventory.InventoryApp{}
+
+		ctx := contextMatcher()
+
+		inv.On("GetDeviceGroups",
 			ctx,
-			mock.AnythingOfType("model.DeviceID")).Return(tc.inventoryGroup, tc.inventoryErr)
+			mock.AnythingOfType("model.DeviceID")).Return(tc.inventoryGroups, tc.inventoryErr)
 
 		apih := makeMockApiHandler(t, &inv)
 
@@ -1837,23 +3192,31 @@ func TestApiGetDeviceGroupInternal(t *testing.T) {
 
 		inReq *http.Request
 
-		inventoryGroup model.GroupName
-		inventoryErr   error
+		inventoryGroups []model.GroupName
+		inventoryErr    error
 	}{
 		"device with group": {
-			inReq:          test.MakeSimpleRequest("GET", "http://1.2.3.4/api/internal/v1/inventory/tenants/foo/devices/1/groups", nil),
-			inventoryGroup: model.GroupName("dev"),
-			inventoryErr:   nil,
+			inReq:           test.MakeSimpleRequest("GET", "http://1.2.3.4/api/internal/v1/inventory/tenants/foo/devices/1/groups", nil),
+			inventoryGroups: []model.GroupName{"dev"},
+			inventoryErr:    nil,
 
 			JSONResponseParams: utils.JSONResponseParams{
 				OutputStatus:     http.StatusOK,
 				OutputBodyObject: model.DeviceGroups{Groups: []string{"dev"}},
 			},
 		},
+		"device with several groups": {
+			inReq:           test.MakeSimpleRequest("GET", "http://1.2.3.4/api/internal/v1/inventory/tenants/foo/devices/1/groups", nil),
+			inventoryGroups: []model.GroupName{"dev", "eu"},
+
+			JSONResponseParams: utils.JSONResponseParams{
+				OutputStatus:     http.StatusOK,
+				OutputBodyObject: model.DeviceGroups{Groups: []string{"dev", "eu"}},
+			},
+		},
 		"device without group": {
-			inReq:          test.MakeSimpleRequest("GET", "http://1.2.3.4/api/internal/v1/inventory/tenants/foo/devices/1/groups", nil),
-			inventoryGroup: model.GroupName(""),
-			inventoryErr:   nil,
+			inReq:        test.MakeSimpleRequest("GET", "http://1.2.3.4/api/internal/v1/inventory/tenants/foo/devices/1/groups", nil),
+			inventoryErr: nil,
 
 			JSONResponseParams: utils.JSONResponseParams{
 				OutputStatus:     http.StatusOK,
@@ -1861,9 +3224,8 @@ func TestApiGetDeviceGroupInternal(t *testing.T) {
 			},
 		},
 		"device not found": {
-			inReq:          test.MakeSimpleRequest("GET", "http://1.2.3.4/api/internal/v1/inventory/tenants/foo/devices/1/groups", nil),
-			inventoryGroup: model.GroupName(""),
-			inventoryErr:   store.ErrDevNotFound,
+			inReq:        test.MakeSimpleRequest("GET", "http://1.2.3.4/api/internal/v1/inventory/tenants/foo/devices/1/groups", nil),
+			inventoryErr: store.ErrDevNotFound,
 
 			JSONResponseParams: utils.JSONResponseParams{
 				OutputStatus:     http.StatusNotFound,
@@ -1871,9 +3233,8 @@ func TestApiGetDeviceGroupInternal(t *testing.T) {
 			},
 		},
 		"generic inventory error": {
-			inReq:          test.MakeSimpleRequest("GET", "http://1.2.3.4/api/internal/v1/inventory/tenants/foo/devices/1/groups", nil),
-			inventoryGroup: model.GroupName(""),
-			inventoryErr:   errors.New("inventory: internal error"),
+			inReq:        test.MakeSimpleRequest("GET", "http://1.2.3.4/api/internal/v1/inventory/tenants/foo/devices/1/groups", nil),
+			inventoryErr: errors.New("inventory: internal error"),
 
 			JSONResponseParams: utils.JSONResponseParams{
 				OutputStatus:     http.StatusInternalServerError,
@@ -1888,9 +3249,9 @@ func TestApiGetDeviceGroupInternal(t *testing.T) {
 
 		ctx := contextMatcher()
 
-		inv.On("GetDeviceGroup",
+		inv.On("GetDeviceGroups",
 			ctx,
-			mock.AnythingOfType("model.DeviceID")).Return(tc.inventoryGroup, tc.inventoryErr)
+			mock.AnythingOfType("model.DeviceID")).Return(tc.inventoryGroups, tc.inventoryErr)
 
 		apih := makeMockApiHandler(t, &inv)
 
@@ -2586,6 +3947,26 @@ func TestApiInventorySearchDevices(t *testing.T) {
 				},
 			},
 		},
+		"partial results": {
+			listDevicesNum:  3,
+			listDevicesErr:  store.ErrPartialResults,
+			listDeviceTotal: -1,
+			inReq: test.MakeSimpleRequest("POST",
+				"http://1.2.3.4/api/management/v2/inventory/filters/search",
+				model.SearchParams{
+					Page:      1,
+					PerPage:   5,
+					MaxTimeMS: 100,
+				},
+			),
+			resp: utils.JSONResponseParams{
+				OutputStatus:     200,
+				OutputBodyObject: mockListDevices(3),
+				OutputHeaders: map[string][]string{
+					hdrPartialResults: {"true"},
+				},
+			},
+		},
 		"valid filter and sort": {
 			listDevicesNum:  5,
 			listDevicesErr:  nil,
@@ -2847,6 +4228,8 @@ func TestApiInventorySearchDevices(t *testing.T) {
 
 		ctx := contextMatcher()
 
+		inv.On("CheckLimits", contextMatcher(), mock.AnythingOfType("model.Limits")).
+			Return(nil, nil).Maybe()
 		inv.On("SearchDevices",
 			ctx,
 			mock.AnythingOfType("model.SearchParams"),
@@ -2858,6 +4241,228 @@ func TestApiInventorySearchDevices(t *testing.T) {
 	}
 }
 
+func TestApiInventorySearchDevicesCursor(t *testing.T) {
+	t.Parallel()
+
+	sort := []model.SortCriteria{{
+		Scope:     model.AttrScopeInventory,
+		Attribute: "mem",
+		Order:     "desc",
+	}}
+	selected := []model.SelectAttribute{{
+		Scope:     model.AttrScopeInventory,
+		Attribute: "device_type",
+	}}
+	devs := []model.Device{{
+		ID: "1",
+		Attributes: model.DeviceAttributes{
+			{Scope: model.AttrScopeInventory, Name: "mem", Value: 1024.0},
+		},
+	}}
+	first, err := model.NewDeviceCursor(sort, devs[0]).Encode()
+	assert.NoError(t, err)
+
+	testCases := map[string]struct {
+		query string
+		body  model.SearchParams
+
+		params *model.SearchParams
+
+		code int
+		resp string
+		next string
+	}{
+		"ok, first page": {
+			query: "?cursor=",
+			body: model.SearchParams{
+				PerPage:    1,
+				Sort:       sort,
+				Attributes: selected,
+			},
+			params: &model.SearchParams{
+				Page:    1,
+				PerPage: 1,
+				Sort:    sort,
+				// the sort attributes are selected as well
+				Attributes: append(selected, model.SelectAttribute{
+					Scope:     model.AttrScopeInventory,
+					Attribute: "mem",
+				}),
+				Cursor: &model.DeviceCursor{Sort: sort},
+			},
+			code: http.StatusOK,
+			resp: ToJson(devs),
+			next: first,
+		},
+		"ok, last page": {
+			query: "?cursor=" + first,
+			body:  model.SearchParams{PerPage: 2, Sort: sort},
+			params: &model.SearchParams{
+				Page:    1,
+				PerPage: 2,
+				Sort:    sort,
+				Cursor:  model.NewDeviceCursor(sort, devs[0]),
+			},
+			code: http.StatusOK,
+			resp: ToJson(devs),
+		},
+		"error, invalid cursor": {
+			query: "?cursor=" + first,
+			body:  model.SearchParams{PerPage: 2},
+			code:  http.StatusBadRequest,
+			resp:  ToJson(restError(model.ErrInvalidDeviceCursor.Error())),
+		},
+		"error, page": {
+			query: "?cursor=",
+			body:  model.SearchParams{Page: 2, PerPage: 2},
+			code:  http.StatusBadRequest,
+			resp:  ToJson(restError("cursor conflicts with page")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := &minventory.InventoryApp{}
+			defer inv.AssertExpectations(t)
+			inv.On("CheckLimits", contextMatcher(), mock.AnythingOfType("model.Limits")).
+				Return(nil, nil)
+			if tc.params != nil {
+				inv.On("SearchDevices", contextMatcher(), *tc.params).
+					Return(devs, len(devs), nil)
+			}
+			apih := makeMockApiHandler(t, inv)
+
+			req := makeReq(http.MethodPost,
+				"http://1.2.3.4"+urlFiltersSearch+tc.query, "", tc.body)
+			recorded := test.RunRequest(t, apih, req)
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			recorded.HeaderIs(hdrNextCursor, tc.next)
+		})
+	}
+}
+
+func TestApiInventorySearchDevicesExplainEmpty(t *testing.T) {
+	t.Parallel()
+
+	filters := []model.FilterPredicate{{
+		Scope:     model.AttrScopeInventory,
+		Attribute: "device_type",
+		Type:      "$eq",
+		Value:     "raspberrypi4",
+	}, {
+		Scope:     model.AttrScopeInventory,
+		Attribute: "artifact_name",
+		Type:      "$eq",
+		Value:     "release-1",
+	}}
+	params := model.SearchParams{Page: 1, PerPage: 20, Filters: filters}
+	zero := 0
+	twelve := 12
+
+	testCases := map[string]struct {
+		query   string
+		devices []model.Device
+		terms   []model.FilterTermCount
+		termErr error
+
+		code    int
+		warning string
+	}{
+		"ok, not requested": {
+			code: http.StatusOK,
+		},
+		"ok, devices found": {
+			query:   "?explain_empty=true",
+			devices: []model.Device{{ID: "1"}},
+			code:    http.StatusOK,
+		},
+		"ok, attribute not reported": {
+			query: "?explain_empty=true",
+			terms: []model.FilterTermCount{{
+				Filter:     filters[0],
+				Devices:    12,
+				Cumulative: 12,
+			}, {
+				Filter:   filters[1],
+				Reported: &zero,
+			}},
+			code: http.StatusOK,
+			warning: `299 - "filters[1]: no device reports the attribute ` +
+				`inventory/artifact_name"`,
+		},
+		"ok, value not matching": {
+			query: "?explain_empty=true",
+			terms: []model.FilterTermCount{{
+				Filter:   filters[0],
+				Reported: &twelve,
+			}, {
+				Filter:  filters[1],
+				Devices: 3,
+			}},
+			code: http.StatusOK,
+			warning: `299 - "filters[0]: no device matches the value of ` +
+				`inventory/device_type"`,
+		},
+		"ok, terms not matching together": {
+			query: "?explain_empty=true",
+			terms: []model.FilterTermCount{{
+				Filter:     filters[0],
+				Devices:    12,
+				Cumulative: 12,
+			}, {
+				Filter:  filters[1],
+				Devices: 3,
+			}},
+			code: http.StatusOK,
+			warning: `299 - "filters[1]: no device matches ` +
+				`inventory/artifact_name together with the previous filters"`,
+		},
+		"error, flag": {
+			query: "?explain_empty=maybe",
+			code:  http.StatusBadRequest,
+		},
+		"error, counts": {
+			query:   "?explain_empty=true",
+			termErr: errors.New("connection error"),
+			code:    http.StatusInternalServerError,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := &minventory.InventoryApp{}
+			defer inv.AssertExpectations(t)
+			inv.On("CheckLimits", contextMatcher(), mock.AnythingOfType("model.Limits")).
+				Return(nil, nil)
+			if tc.code != http.StatusBadRequest {
+				devices := tc.devices
+				if devices == nil {
+					devices = []model.Device{}
+				}
+				inv.On("SearchDevices", contextMatcher(), params).
+					Return(devices, len(devices), nil)
+			}
+			if tc.terms != nil || tc.termErr != nil {
+				inv.On("ExplainEmptySearch", contextMatcher(), params).
+					Return(tc.terms, tc.termErr)
+			}
+			apih := makeMockApiHandler(t, inv)
+
+			req := makeReq(http.MethodPost,
+				"http://1.2.3.4"+urlFiltersSearch+tc.query, "",
+				model.SearchParams{Filters: filters})
+			recorded := test.RunRequest(t, apih, req)
+			recorded.CodeIs(tc.code)
+			recorded.HeaderIs(hdrWarning, tc.warning)
+		})
+	}
+}
+
 func TestApiParseSearchParams(t *testing.T) {
 	t.Parallel()
 
@@ -3122,6 +4727,26 @@ func TestApiInventoryInternalSearchDevices(t *testing.T) {
 		inReq           *http.Request
 		resp            utils.JSONResponseParams
 	}{
+		"partial results": {
+			listDevicesNum:  3,
+			listDevicesErr:  store.ErrPartialResults,
+			listDeviceTotal: -1,
+			inReq: test.MakeSimpleRequest("POST",
+				"http://1.2.3.4/api/internal/v2/inventory/tenants/foo/filters/search",
+				model.SearchParams{
+					Page:      1,
+					PerPage:   5,
+					MaxTimeMS: 100,
+				},
+			),
+			resp: utils.JSONResponseParams{
+				OutputStatus:     200,
+				OutputBodyObject: mockListDevices(3),
+				OutputHeaders: map[string][]string{
+					hdrPartialResults: {"true"},
+				},
+			},
+		},
 		"valid filter and sort": {
 			listDevicesNum:  5,
 			listDevicesErr:  nil,
@@ -3369,3 +4994,5791 @@ func makeReq(method, url, auth string, body interface{}) *http.Request {
 func restError(status string) map[string]interface{} {
 	return map[string]interface{}{"error": status, "request_id": "test"}
 }
+
+func TestApiExportConfigBundle(t *testing.T) {
+	t.Parallel()
+
+	bundle := &model.ConfigBundle{
+		Version: model.ConfigBundleVersion,
+		Groups: []model.GroupDefinition{{
+			Name:    "foo",
+			Devices: []model.DeviceID{"1", "2"},
+		}},
+	}
+	testCases := map[string]struct {
+		accept string
+		err    error
+
+		code        int
+		contentType string
+		body        string
+	}{
+		"ok, json": {
+			code:        http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+			body:        ToJson(bundle),
+		},
+		"ok, yaml": {
+			accept:      "application/x-yaml",
+			code:        http.StatusOK,
+			contentType: contentTypeYAML,
+			body: "version: 2\n" +
+				"groups:\n" +
+				"- name: foo\n" +
+				"  devices:\n" +
+				"  - \"1\"\n" +
+				"  - \"2\"\n",
+		},
+		"error": {
+			err:         errors.New("db error"),
+			code:        http.StatusInternalServerError,
+			contentType: "application/json; charset=utf-8",
+			body:        ToJson(restError("internal error")),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			inv.On("ExportConfigBundle", contextMatcher()).
+				Return(bundle, tc.err)
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodGet, "http://localhost"+urlConfigBundle, "", nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.HeaderIs("Content-Type", tc.contentType)
+			recorded.BodyIs(tc.body)
+		})
+	}
+}
+
+func TestApiImportConfigBundle(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		body interface{}
+
+		callInv bool
+		result  *model.UpdateResult
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			body: model.ConfigBundle{
+				Version: model.ConfigBundleVersion,
+				Groups: []model.GroupDefinition{{
+					Name:    "foo",
+					Devices: []model.DeviceID{"1", "2"},
+				}},
+			},
+			callInv: true,
+			result:  &model.UpdateResult{MatchedCount: 2, UpdatedCount: 1},
+			code:    http.StatusOK,
+			resp:    `{"matched_count":2,"updated_count":1}`,
+		},
+		"error, invalid payload": {
+			body: "foo",
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("failed to decode request body: " +
+				"json: cannot unmarshal string into Go value of type " +
+				"model.ConfigBundle")),
+		},
+		"error, invalid bundle": {
+			body: model.ConfigBundle{Version: 3},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("unsupported bundle version: 3")),
+		},
+		"error, internal": {
+			body:    model.ConfigBundle{Version: model.ConfigBundleVersion},
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				inv.On("ImportConfigBundle",
+					contextMatcher(),
+					tc.body,
+				).Return(tc.result, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPost, "http://localhost"+urlConfigBundle, "", tc.body)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiImportConfigBundleYAML(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		contentType string
+		body        string
+
+		bundle *model.ConfigBundle
+
+		code int
+		resp string
+	}{
+		"ok": {
+			contentType: "application/yaml",
+			body: "version: 2\n" +
+				"groups:\n" +
+				"- name: foo\n" +
+				"  devices: [\"1\", \"2\"]\n" +
+				"dynamic_groups:\n" +
+				"- name: linux\n" +
+				"  expression: inventory/os == \"linux\"\n" +
+				"saved_filters:\n" +
+				"- id: \"1\"\n" +
+				"  name: debian\n" +
+				"  filters:\n" +
+				"  - scope: inventory\n" +
+				"    attribute: os\n" +
+				"    type: $eq\n" +
+				"    value: debian\n",
+			bundle: &model.ConfigBundle{
+				Version: 2,
+				Groups: []model.GroupDefinition{{
+					Name:    "foo",
+					Devices: []model.DeviceID{"1", "2"},
+				}},
+				DynamicGroups: []model.BundleDynamicGroup{{
+					Name:       "linux",
+					Expression: `inventory/os == "linux"`,
+				}},
+				SavedFilters: []model.BundleSavedFilter{{
+					ID:   "1",
+					Name: "debian",
+					Filters: []model.FilterPredicate{{
+						Scope:     "inventory",
+						Attribute: "os",
+						Type:      "$eq",
+						Value:     "debian",
+					}},
+				}},
+			},
+			code: http.StatusOK,
+			resp: `{"matched_count":2,"updated_count":2}`,
+		},
+		"ok, x-yaml": {
+			contentType: "application/x-yaml",
+			body:        "version: 1\n",
+			bundle:      &model.ConfigBundle{Version: 1},
+			code:        http.StatusOK,
+			resp:        `{"matched_count":2,"updated_count":2}`,
+		},
+		"error, unknown field": {
+			contentType: "application/yaml",
+			body:        "version: 2\nfoo: bar\n",
+			code:        http.StatusBadRequest,
+			resp: ToJson(restError("failed to decode request body: " +
+				"yaml: unmarshal errors:\n  line 2: field foo not found in " +
+				"type model.ConfigBundle")),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.bundle != nil {
+				inv.On("ImportConfigBundle",
+					contextMatcher(),
+					*tc.bundle,
+				).Return(&model.UpdateResult{MatchedCount: 2, UpdatedCount: 2}, nil)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req, _ := http.NewRequest(http.MethodPost,
+				"http://localhost"+urlConfigBundle,
+				strings.NewReader(tc.body),
+			)
+			req.Header.Set("Content-Type", tc.contentType)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiImportTags(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		query       string
+		contentType string
+		body        string
+
+		rows   []model.TagsImportRow
+		result *model.TagsImportResult
+		err    error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			query:       "?identity=mac",
+			contentType: "text/csv",
+			body:        "00:01,site,berlin\n00:02,site\n\"00:03\", floor,\"2\"\n",
+			rows: []model.TagsImportRow{
+				{Row: 1, Identity: "00:01", Name: "site", Value: "berlin"},
+				{Row: 3, Identity: "00:03", Name: "floor", Value: "2"},
+			},
+			result: &model.TagsImportResult{
+				Rows:   2,
+				Tagged: 1,
+				Errors: []model.TagsImportError{{
+					Row:      3,
+					Identity: "00:03",
+					Error:    `no device with identity/mac "00:03"`,
+				}},
+			},
+			code: http.StatusOK,
+			resp: ToJson(model.TagsImportResult{
+				Rows:   3,
+				Tagged: 1,
+				Errors: []model.TagsImportError{{
+					Row:   2,
+					Error: "expected 3 fields: identity, tag name and tag value",
+				}, {
+					Row:      3,
+					Identity: "00:03",
+					Error:    `no device with identity/mac "00:03"`,
+				}},
+			}),
+		},
+		"error, content type": {
+			query:       "?identity=mac",
+			contentType: "application/json",
+			body:        `{}`,
+			code:        http.StatusUnsupportedMediaType,
+			resp:        ToJson(restError("Content-Type must be text/csv")),
+		},
+		"error, missing identity": {
+			contentType: "text/csv",
+			body:        "00:01,site,berlin\n",
+			code:        http.StatusBadRequest,
+			resp:        ToJson(restError("Missing required param identity")),
+		},
+		"error, too many rows": {
+			query:       "?identity=mac",
+			contentType: "text/csv",
+			body: strings.Repeat("00:01,site,berlin\n",
+				model.TagsImportMaxRows+1),
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("too many rows, the maximum is 10000")),
+		},
+		"error, forbidden": {
+			query:       "?identity=mac",
+			contentType: "text/csv",
+			body:        "00:01,site,berlin\n",
+			rows: []model.TagsImportRow{
+				{Row: 1, Identity: "00:01", Name: "site", Value: "berlin"},
+			},
+			err:  errors.Wrap(inventory.ErrScopeWriteForbidden, "scope tags"),
+			code: http.StatusForbidden,
+			resp: ToJson(restError("scope tags: writing attributes of the scope is forbidden")),
+		},
+		"error, internal": {
+			query:       "?identity=mac",
+			contentType: "text/csv",
+			body:        "00:01,site,berlin\n",
+			rows: []model.TagsImportRow{
+				{Row: 1, Identity: "00:01", Name: "site", Value: "berlin"},
+			},
+			err:  errors.New("db error"),
+			code: http.StatusInternalServerError,
+			resp: ToJson(restError("internal error")),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.rows != nil {
+				inv.On("ImportTags",
+					contextMatcher(),
+					"mac",
+					tc.rows,
+				).Return(tc.result, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req, _ := http.NewRequest(http.MethodPost,
+				"http://localhost"+urlTagsImport+tc.query,
+				strings.NewReader(tc.body),
+			)
+			req.Header.Set("Content-Type", tc.contentType)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiAssignTags(t *testing.T) {
+	t.Parallel()
+
+	filters := []model.FilterPredicate{{
+		Scope:     model.AttrScopeInventory,
+		Attribute: "device_type",
+		Type:      "$eq",
+		Value:     "raspberrypi4",
+	}}
+	assignment := model.TagsAssignment{
+		DeviceIDs: []model.DeviceID{"1", "2"},
+		Filters:   filters,
+		Set:       map[string]string{"site": "oslo"},
+		Unset:     []string{"owner"},
+	}
+	result := &model.TagsAssignmentResult{
+		Tagged: 1,
+		Errors: []model.TagsAssignmentError{{
+			DeviceID: "2",
+			Error:    "device not found or not matching the filters",
+		}},
+	}
+	testCases := map[string]struct {
+		body interface{}
+
+		callInv bool
+		result  *model.TagsAssignmentResult
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			body:    assignment,
+			callInv: true,
+			result:  result,
+			code:    http.StatusOK,
+			resp:    ToJson(result),
+		},
+		"error, malformed body": {
+			body: "tags",
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("failed to decode request body: " +
+				"json: cannot unmarshal string into Go value of type " +
+				"model.TagsAssignment")),
+		},
+		"error, no tags": {
+			body: model.TagsAssignment{Filters: filters},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("either tags to set or to unset must be provided")),
+		},
+		"error, forbidden": {
+			body:    assignment,
+			callInv: true,
+			err:     errors.Wrap(inventory.ErrScopeWriteForbidden, "scope tags"),
+			code:    http.StatusForbidden,
+			resp:    ToJson(restError("scope tags: writing attributes of the scope is forbidden")),
+		},
+		"error, schema violation": {
+			body:    assignment,
+			callInv: true,
+			err: errors.Wrap(inventory.ErrSchemaViolation,
+				"attribute tags/site must be of type number"),
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("attribute tags/site must be of type number: " +
+				"attribute does not conform to the schema")),
+		},
+		"error, internal": {
+			body:    assignment,
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				inv.On("AssignTags", contextMatcher(), assignment).
+					Return(tc.result, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPost,
+				"http://localhost"+urlTagsAssignment, "", tc.body)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiListGroupsV2(t *testing.T) {
+	t.Parallel()
+
+	statusFilter := []model.FilterPredicate{{
+		Attribute: "status",
+		Scope:     model.AttrScopeIdentity,
+		Type:      "$eq",
+		Value:     "accepted",
+	}}
+	testCases := map[string]struct {
+		query string
+
+		filters    []model.FilterPredicate
+		groups     []model.GroupName
+		withCounts bool
+		counts     []model.GroupCount
+		groupsErr  error
+		ungrouped  int
+		countErr   error
+		countCalls bool
+
+		code int
+		resp string
+	}{
+		"ok": {
+			groups:     []model.GroupName{"bar", "foo"},
+			ungrouped:  3,
+			countCalls: true,
+			code:       http.StatusOK,
+			resp:       `{"groups":["bar","foo"],"ungrouped_count":3}`,
+		},
+		"ok, status": {
+			query:      "?status=accepted",
+			filters:    statusFilter,
+			countCalls: true,
+			code:       http.StatusOK,
+			resp:       `{"groups":[],"ungrouped_count":0}`,
+		},
+		"ok, counts": {
+			query:      "?status=accepted&counts=true",
+			filters:    statusFilter,
+			withCounts: true,
+			counts: []model.GroupCount{
+				{Group: "bar", Count: 2},
+				{Group: "foo", Count: 5},
+			},
+			ungrouped:  3,
+			countCalls: true,
+			code:       http.StatusOK,
+			resp: `{"groups":["bar","foo"],` +
+				`"counts":[{"group":"bar","count":2},{"group":"foo","count":5}],` +
+				`"ungrouped_count":3}`,
+		},
+		"error, groups": {
+			groupsErr: errors.New("db error"),
+			code:      http.StatusInternalServerError,
+			resp:      ToJson(restError("internal error")),
+		},
+		"error, counts": {
+			query:      "?counts=true",
+			withCounts: true,
+			groupsErr:  errors.New("db error"),
+			code:       http.StatusInternalServerError,
+			resp:       ToJson(restError("internal error")),
+		},
+		"error, invalid counts": {
+			query: "?counts=some",
+			code:  http.StatusBadRequest,
+			resp:  ToJson(restError(utils.MsgQueryParmInvalid(queryParamCounts))),
+		},
+		"error, count": {
+			groups:     []model.GroupName{"foo"},
+			countErr:   errors.New("db error"),
+			countCalls: true,
+			code:       http.StatusInternalServerError,
+			resp:       ToJson(restError("internal error")),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := &minventory.InventoryApp{}
+			defer inv.AssertExpectations(t)
+			if tc.withCounts {
+				inv.On("ListGroupCounts", contextMatcher(), tc.filters).
+					Return(tc.counts, tc.groupsErr)
+			} else if tc.code != http.StatusBadRequest {
+				inv.On("ListGroups", contextMatcher(), tc.filters).
+					Return(tc.groups, tc.groupsErr)
+			}
+			if tc.countCalls {
+				inv.On("ListDevices",
+					contextMatcher(),
+					mock.MatchedBy(func(q store.ListQuery) bool {
+						return q.HasGroup != nil && !*q.HasGroup &&
+							len(q.Filters) == len(tc.filters)
+					}),
+				).Return(nil, tc.ungrouped, tc.countErr)
+			}
+
+			req := makeReq(http.MethodGet,
+				"http://localhost"+urlGroupsV2+tc.query, "", nil)
+			recorded := test.RunRequest(t, makeMockApiHandler(t, inv), req)
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+		})
+	}
+}
+
+func TestApiReplaceGroup(t *testing.T) {
+	t.Parallel()
+
+	group := model.GroupDefinition{
+		Name:    "foo",
+		Devices: []model.DeviceID{"1", "2"},
+	}
+	testCases := map[string]struct {
+		name string
+		body interface{}
+
+		callInv bool
+		group   *model.GroupDefinition
+		result  *model.GroupDefinition
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			name:    "foo",
+			body:    map[string]interface{}{"devices": group.Devices},
+			callInv: true,
+			result:  &group,
+			code:    http.StatusOK,
+			resp:    ToJson(group),
+		},
+		"ok, with name": {
+			name:    "foo",
+			body:    group,
+			callInv: true,
+			result:  &group,
+			code:    http.StatusOK,
+			resp:    ToJson(group),
+		},
+		"error, name mismatch": {
+			name: "bar",
+			body: group,
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("group name does not match the resource")),
+		},
+		"error, invalid group name": {
+			name: "foo%20bar",
+			body: map[string]interface{}{"devices": group.Devices},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("name: Group name can only contain: " +
+				"upper/lowercase alphanum, -(dash), _(underscore).")),
+		},
+		"ok, empty group": {
+			name:    "foo",
+			body:    map[string]interface{}{"devices": []string{}},
+			callInv: true,
+			group:   &model.GroupDefinition{Name: "foo", Devices: []model.DeviceID{}},
+			result:  &model.GroupDefinition{Name: "foo", Devices: []model.DeviceID{}},
+			code:    http.StatusOK,
+			resp:    `{"name":"foo","devices":[]}`,
+		},
+		"error, no devices": {
+			name: "foo",
+			body: map[string]interface{}{},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("devices: is required.")),
+		},
+		"error, internal": {
+			name:    "foo",
+			body:    group,
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				expected := group
+				if tc.group != nil {
+					expected = *tc.group
+				}
+				inv.On("ReplaceGroup", contextMatcher(), expected).
+					Return(tc.result, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPut,
+				"http://localhost"+urlGroupsV2+"/"+tc.name, "", tc.body)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiDeleteGroup(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		name string
+
+		callInv bool
+		result  *model.UpdateResult
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			name:    "foo",
+			callInv: true,
+			result:  &model.UpdateResult{MatchedCount: 2, UpdatedCount: 2},
+			code:    http.StatusOK,
+			resp: ToJson(&model.UpdateResult{
+				MatchedCount: 2, UpdatedCount: 2,
+			}),
+		},
+		"error, invalid group name": {
+			name: "foo%20bar",
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("Group name can only contain: " +
+				"upper/lowercase alphanum, -(dash), _(underscore)")),
+		},
+		"error, not found": {
+			name:    "foo",
+			callInv: true,
+			err:     store.ErrGroupNotFound,
+			code:    http.StatusNotFound,
+			resp:    ToJson(restError(store.ErrGroupNotFound.Error())),
+		},
+		"error, internal": {
+			name:    "foo",
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				inv.On("DeleteGroup", contextMatcher(), model.GroupName(tc.name)).
+					Return(tc.result, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodDelete,
+				"http://localhost"+urlGroupsV2+"/"+tc.name, "", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiInternalAttributeStatistics(t *testing.T) {
+	t.Parallel()
+
+	stats := &model.AttributeStatistics{
+		Name:    "mender_client_version",
+		Scope:   "inventory",
+		Tenants: 2,
+		Devices: 3,
+		Values: []model.AttributeValueCount{
+			{Value: "2.5.0", Count: 2},
+			{Value: "2.4.0", Count: 1},
+		},
+	}
+	testCases := map[string]struct {
+		query string
+
+		callInv bool
+		scope   string
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			query:   "?attribute=mender_client_version",
+			callInv: true,
+			scope:   "inventory",
+			code:    http.StatusOK,
+			resp:    ToJson(stats),
+		},
+		"ok, scope": {
+			query:   "?attribute=mender_client_version&scope=identity",
+			callInv: true,
+			scope:   "identity",
+			code:    http.StatusOK,
+			resp:    ToJson(stats),
+		},
+		"error, no attribute": {
+			query: "?scope=identity",
+			code:  http.StatusBadRequest,
+			resp:  ToJson(restError("Missing required param attribute")),
+		},
+		"error, internal": {
+			query:   "?attribute=mender_client_version",
+			callInv: true,
+			scope:   "inventory",
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				inv.On("GetAttributeStatistics",
+					contextMatcher(),
+					tc.scope,
+					"mender_client_version",
+				).Return(stats, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodGet,
+				"http://localhost"+uriInternalStatistics+tc.query, "", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiInternalListTenantDatabases(t *testing.T) {
+	t.Parallel()
+
+	dbs := []model.TenantDatabase{{
+		TenantID:   "foo",
+		Database:   "inventory-foo",
+		Devices:    120,
+		Version:    "1.0.9",
+		SizeOnDisk: 1 << 20,
+	}, {
+		TenantID: "bar",
+		Database: "inventory-bar",
+	}}
+	testCases := map[string]struct {
+		dbs []model.TenantDatabase
+		err error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			dbs:  dbs,
+			code: http.StatusOK,
+			resp: ToJson(dbs),
+		},
+		"ok, empty": {
+			dbs:  []model.TenantDatabase{},
+			code: http.StatusOK,
+			resp: "[]",
+		},
+		"error, internal": {
+			err:  errors.New("db error"),
+			code: http.StatusInternalServerError,
+			resp: ToJson(restError("internal error")),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			inv.On("ListTenantDatabases", contextMatcher()).
+				Return(tc.dbs, tc.err)
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodGet,
+				"http://localhost"+uriInternalDatabases, "", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiListScopes(t *testing.T) {
+	t.Parallel()
+
+	scopes := append(model.BuiltinScopes, model.Scope{
+		Name:   "warranty",
+		Writer: model.SourceTypeUser,
+	})
+	testCases := map[string]struct {
+		err  error
+		code int
+		resp string
+	}{
+		"ok": {
+			code: http.StatusOK,
+			resp: ToJson(scopes),
+		},
+		"error": {
+			err:  errors.New("db error"),
+			code: http.StatusInternalServerError,
+			resp: ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			inv.On("ListScopes", contextMatcher()).Return(scopes, tc.err)
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodGet, "http://localhost"+urlScopes, "", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+		})
+	}
+}
+
+func TestApiReplaceScope(t *testing.T) {
+	t.Parallel()
+
+	scope := model.Scope{
+		Name:          "warranty",
+		Writer:        model.SourceTypeUser,
+		RetentionDays: 30,
+	}
+	testCases := map[string]struct {
+		name string
+		body interface{}
+
+		callInv bool
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			name:    "warranty",
+			body:    map[string]interface{}{"writer": "user", "retention_days": 30},
+			callInv: true,
+			code:    http.StatusOK,
+			resp:    ToJson(scope),
+		},
+		"error, name mismatch": {
+			name: "telemetry",
+			body: scope,
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("scope name does not match the resource")),
+		},
+		"error, builtin scope": {
+			name: "inventory",
+			body: map[string]interface{}{"writer": "user"},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("name: must not be a builtin scope.")),
+		},
+		"error, invalid writer": {
+			name: "warranty",
+			body: map[string]interface{}{"writer": "anyone"},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("writer: must be a valid value.")),
+		},
+		"error, internal": {
+			name:    "warranty",
+			body:    scope,
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				var res *model.Scope
+				if tc.err == nil {
+					res = &scope
+				}
+				inv.On("ReplaceScope", contextMatcher(), scope).
+					Return(res, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPut,
+				"http://localhost"+urlScopes+"/"+tc.name, "", tc.body)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiDeleteScope(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		name string
+
+		callInv bool
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			name:    "warranty",
+			callInv: true,
+			code:    http.StatusNoContent,
+		},
+		"error, builtin scope": {
+			name: "identity",
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("builtin scopes cannot be removed")),
+		},
+		"error, not found": {
+			name:    "warranty",
+			callInv: true,
+			err:     store.ErrScopeNotFound,
+			code:    http.StatusNotFound,
+			resp:    ToJson(restError("scope not found")),
+		},
+		"error, internal": {
+			name:    "warranty",
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				inv.On("DeleteScope", contextMatcher(), tc.name).
+					Return(tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodDelete,
+				"http://localhost"+urlScopes+"/"+tc.name, "", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiReplaceAttributeDefinition(t *testing.T) {
+	t.Parallel()
+
+	def := model.AttributeDefinition{
+		Scope:   "inventory",
+		Name:    "last_gps_fix",
+		TTLDays: 30,
+	}
+	testCases := map[string]struct {
+		path string
+		body interface{}
+
+		callInv bool
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			path:    "/inventory/last_gps_fix",
+			body:    map[string]interface{}{"ttl_days": 30},
+			callInv: true,
+			code:    http.StatusOK,
+			resp:    ToJson(def),
+		},
+		"error, attribute mismatch": {
+			path: "/inventory/mac",
+			body: def,
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("attribute does not match the resource")),
+		},
+		"error, system attribute": {
+			path: "/system/group",
+			body: map[string]interface{}{"ttl_days": 30},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("scope: system attributes cannot be defined.")),
+		},
+		"error, invalid ttl": {
+			path: "/inventory/last_gps_fix",
+			body: map[string]interface{}{"ttl_days": -1},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("ttl_days: must be no less than 0.")),
+		},
+		"error, internal": {
+			path:    "/inventory/last_gps_fix",
+			body:    def,
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				var res *model.AttributeDefinition
+				if tc.err == nil {
+					res = &def
+				}
+				inv.On("ReplaceAttributeDefinition", contextMatcher(), def).
+					Return(res, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPut,
+				"http://localhost"+urlSchemaAttributes+tc.path, "", tc.body)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiDeleteAttributeDefinition(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		err error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			code: http.StatusNoContent,
+		},
+		"error, not found": {
+			err:  store.ErrAttributeDefinitionNotFound,
+			code: http.StatusNotFound,
+			resp: ToJson(restError("attribute definition not found")),
+		},
+		"error, internal": {
+			err:  errors.New("db error"),
+			code: http.StatusInternalServerError,
+			resp: ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			inv.On("DeleteAttributeDefinition", contextMatcher(),
+				"inventory", "last_gps_fix",
+			).Return(tc.err)
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodDelete,
+				"http://localhost"+urlSchemaAttributes+"/inventory/last_gps_fix",
+				"", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiInternalMetrics(t *testing.T) {
+	t.Parallel()
+
+	metrics.NewCounterVec("inventory_test_requests_total", "Test requests.").Inc()
+
+	api := makeMockApiHandler(t, &minventory.InventoryApp{})
+	req := makeReq(http.MethodGet, "http://localhost"+uriInternalMetrics, "", nil)
+	recorded := test.RunRequest(t, api, req)
+
+	recorded.CodeIs(http.StatusOK)
+	recorded.HeaderIs("Content-Type", metrics.ContentType)
+	assert.Contains(t, recorded.Recorder.Body.String(),
+		"# TYPE inventory_test_requests_total counter\n"+
+			"inventory_test_requests_total 1\n")
+}
+
+func TestApiListSchemaViolations(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	violations := []model.SchemaViolation{{
+		DeviceID: "1",
+		Scope:    "inventory",
+		Name:     "kernel",
+		Value:    5.1,
+		Error:    "must be of type string",
+		Count:    2,
+		FirstTs:  now,
+		LastTs:   now,
+	}}
+	testCases := map[string]struct {
+		query string
+
+		callInv  bool
+		deviceID model.DeviceID
+		skip     int
+		err      error
+
+		code  int
+		total string
+		resp  string
+	}{
+		"ok": {
+			callInv: true,
+			skip:    0,
+			code:    http.StatusOK,
+			total:   "21",
+			resp:    ToJson(violations),
+		},
+		"ok, device": {
+			query:    "?device_id=1&page=2",
+			callInv:  true,
+			deviceID: "1",
+			skip:     20,
+			code:     http.StatusOK,
+			total:    "21",
+			resp:     ToJson(violations),
+		},
+		"error, pagination": {
+			query: "?page=foo",
+			code:  http.StatusBadRequest,
+			resp:  ToJson(restError(utils.MsgQueryParmInvalid("page"))),
+		},
+		"error, internal": {
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				inv.On("ListSchemaViolations", contextMatcher(),
+					tc.deviceID, tc.skip, 20,
+				).Return(violations, 21, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodGet,
+				"http://localhost"+urlSchemaViolations+tc.query, "", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			if tc.total != "" {
+				recorded.HeaderIs(hdrTotalCount, tc.total)
+			}
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiGetValidationWebhook(t *testing.T) {
+	t.Parallel()
+
+	hook := &model.ValidationWebhook{
+		URL:           "https://hooks.example.com",
+		FailurePolicy: model.ValidationFailOpen,
+	}
+	testCases := map[string]struct {
+		hook *model.ValidationWebhook
+		err  error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			hook: hook,
+			code: http.StatusOK,
+			resp: ToJson(hook),
+		},
+		"error, not found": {
+			code: http.StatusNotFound,
+			resp: ToJson(restError("validation webhook not found")),
+		},
+		"error, internal": {
+			err:  errors.New("db error"),
+			code: http.StatusInternalServerError,
+			resp: ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			inv.On("GetValidationWebhook", contextMatcher()).
+				Return(tc.hook, tc.err)
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodGet,
+				"http://localhost"+urlValidationWebhook, "", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+		})
+	}
+}
+
+func TestApiSetValidationWebhook(t *testing.T) {
+	t.Parallel()
+
+	hook := model.ValidationWebhook{
+		URL:           "https://hooks.example.com",
+		TimeoutMS:     500,
+		FailurePolicy: model.ValidationFailClosed,
+	}
+	testCases := map[string]struct {
+		body interface{}
+
+		callInv bool
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			body:    hook,
+			callInv: true,
+			code:    http.StatusOK,
+			resp:    ToJson(hook),
+		},
+		"error, malformed body": {
+			body: "hook",
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("failed to decode request body: " +
+				"json: cannot unmarshal string into Go value of type " +
+				"model.ValidationWebhook")),
+		},
+		"error, invalid policy": {
+			body: map[string]interface{}{
+				"url":            "https://hooks.example.com",
+				"failure_policy": "ignore",
+			},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("failure_policy: must be a valid value.")),
+		},
+		"error, internal": {
+			body:    hook,
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				inv.On("SetValidationWebhook", contextMatcher(), hook).
+					Return(tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPut,
+				"http://localhost"+urlValidationWebhook, "", tc.body)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiDeleteValidationWebhook(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		err error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			code: http.StatusNoContent,
+		},
+		"error, internal": {
+			err:  errors.New("db error"),
+			code: http.StatusInternalServerError,
+			resp: ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			inv.On("DeleteValidationWebhook", contextMatcher()).
+				Return(tc.err)
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodDelete,
+				"http://localhost"+urlValidationWebhook, "", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+		})
+	}
+}
+
+func TestApiListIncompleteDevices(t *testing.T) {
+	t.Parallel()
+
+	devs := []model.DeviceCompleteness{{
+		DeviceID:     "1",
+		Completeness: 0,
+		Missing: []model.SelectAttribute{{
+			Scope:     model.AttrScopeInventory,
+			Attribute: "os",
+		}},
+	}}
+	testCases := map[string]struct {
+		query string
+
+		callInv bool
+		skip    int
+		err     error
+
+		code  int
+		total string
+		resp  string
+	}{
+		"ok": {
+			callInv: true,
+			code:    http.StatusOK,
+			total:   "21",
+			resp:    ToJson(devs),
+		},
+		"ok, page": {
+			query:   "?page=2",
+			callInv: true,
+			skip:    20,
+			code:    http.StatusOK,
+			total:   "21",
+			resp:    ToJson(devs),
+		},
+		"error, pagination": {
+			query: "?per_page=foo",
+			code:  http.StatusBadRequest,
+			resp:  ToJson(restError(utils.MsgQueryParmInvalid("per_page"))),
+		},
+		"error, internal": {
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				inv.On("ListIncompleteDevices", contextMatcher(),
+					tc.skip, 20,
+				).Return(devs, 21, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodGet,
+				"http://localhost"+urlIncompleteDevices+tc.query, "", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			if tc.total != "" {
+				recorded.HeaderIs(hdrTotalCount, tc.total)
+			}
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiGetCompletenessAlert(t *testing.T) {
+	t.Parallel()
+
+	alert := &model.CompletenessAlert{
+		Channel: model.NotificationChannel{
+			Type:  model.NotificationChannelEmail,
+			Email: "ops@example.com",
+		},
+		AfterHours: 24,
+	}
+	testCases := map[string]struct {
+		alert *model.CompletenessAlert
+		err   error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			alert: alert,
+			code:  http.StatusOK,
+			resp:  ToJson(alert),
+		},
+		"error, not found": {
+			code: http.StatusNotFound,
+			resp: ToJson(restError("completeness alert not found")),
+		},
+		"error, internal": {
+			err:  errors.New("db error"),
+			code: http.StatusInternalServerError,
+			resp: ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			inv.On("GetCompletenessAlert", contextMatcher()).
+				Return(tc.alert, tc.err)
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodGet,
+				"http://localhost"+urlCompletenessAlert, "", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+		})
+	}
+}
+
+func TestApiSetCompletenessAlert(t *testing.T) {
+	t.Parallel()
+
+	alert := model.CompletenessAlert{
+		Channel: model.NotificationChannel{
+			Type: model.NotificationChannelWebhook,
+			URL:  "https://hooks.example.com",
+		},
+		AfterHours: 24,
+	}
+	checkedTs := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	testCases := map[string]struct {
+		body interface{}
+
+		callInv bool
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			body:    alert,
+			callInv: true,
+			code:    http.StatusOK,
+			resp:    ToJson(alert),
+		},
+		"ok, checked_ts ignored": {
+			body: model.CompletenessAlert{
+				Channel:    alert.Channel,
+				AfterHours: alert.AfterHours,
+				CheckedTs:  &checkedTs,
+			},
+			callInv: true,
+			code:    http.StatusOK,
+			resp:    ToJson(alert),
+		},
+		"error, malformed body": {
+			body: "alert",
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("failed to decode request body: " +
+				"json: cannot unmarshal string into Go value of type " +
+				"model.CompletenessAlert")),
+		},
+		"error, grace period": {
+			body: model.CompletenessAlert{
+				Channel:    alert.Channel,
+				AfterHours: model.CompletenessAlertHoursMax + 1,
+			},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("after_hours: must be no greater than 720.")),
+		},
+		"error, internal": {
+			body:    alert,
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				inv.On("SetCompletenessAlert", contextMatcher(), alert).
+					Return(tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPut,
+				"http://localhost"+urlCompletenessAlert, "", tc.body)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiDeleteCompletenessAlert(t *testing.T) {
+	t.Parallel()
+
+	inv := minventory.InventoryApp{}
+	inv.On("DeleteCompletenessAlert", contextMatcher()).Return(nil).Once()
+	inv.On("DeleteCompletenessAlert", contextMatcher()).
+		Return(errors.New("db error")).Once()
+	api := makeMockApiHandler(t, &inv)
+
+	req := makeReq(http.MethodDelete,
+		"http://localhost"+urlCompletenessAlert, "", nil)
+	recorded := test.RunRequest(t, api, req)
+	recorded.CodeIs(http.StatusNoContent)
+
+	req = makeReq(http.MethodDelete,
+		"http://localhost"+urlCompletenessAlert, "", nil)
+	recorded = test.RunRequest(t, api, req)
+	recorded.CodeIs(http.StatusInternalServerError)
+	inv.AssertExpectations(t)
+}
+
+func TestApiGetDigestSettings(t *testing.T) {
+	t.Parallel()
+
+	settings := &model.DigestSettings{
+		Channel: model.NotificationChannel{
+			Type:  model.NotificationChannelEmail,
+			Email: "ops@example.com",
+		},
+		PeriodHours: 24,
+		SilentHours: 48,
+	}
+	testCases := map[string]struct {
+		settings *model.DigestSettings
+		err      error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			settings: settings,
+			code:     http.StatusOK,
+			resp:     ToJson(settings),
+		},
+		"error, not found": {
+			code: http.StatusNotFound,
+			resp: ToJson(restError("digest settings not found")),
+		},
+		"error, internal": {
+			err:  errors.New("db error"),
+			code: http.StatusInternalServerError,
+			resp: ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			inv.On("GetDigestSettings", contextMatcher()).
+				Return(tc.settings, tc.err)
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodGet,
+				"http://localhost"+urlDigest, "", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+		})
+	}
+}
+
+func TestApiSetDigestSettings(t *testing.T) {
+	t.Parallel()
+
+	settings := model.DigestSettings{
+		Channel: model.NotificationChannel{
+			Type: model.NotificationChannelWebhook,
+			URL:  "https://hooks.example.com",
+		},
+		PeriodHours: 24,
+		SilentHours: 48,
+	}
+	sentTs := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	testCases := map[string]struct {
+		body interface{}
+
+		callInv bool
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			body:    settings,
+			callInv: true,
+			code:    http.StatusOK,
+			resp:    ToJson(settings),
+		},
+		"ok, sent_ts ignored": {
+			body: model.DigestSettings{
+				Channel:     settings.Channel,
+				PeriodHours: settings.PeriodHours,
+				SilentHours: settings.SilentHours,
+				SentTs:      &sentTs,
+			},
+			callInv: true,
+			code:    http.StatusOK,
+			resp:    ToJson(settings),
+		},
+		"error, malformed body": {
+			body: "digest",
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("failed to decode request body: " +
+				"json: cannot unmarshal string into Go value of type " +
+				"model.DigestSettings")),
+		},
+		"error, period": {
+			body: model.DigestSettings{
+				Channel:     settings.Channel,
+				PeriodHours: model.DigestHoursMax + 1,
+				SilentHours: settings.SilentHours,
+			},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("period_hours: must be no greater than 720.")),
+		},
+		"error, internal": {
+			body:    settings,
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				inv.On("SetDigestSettings", contextMatcher(), settings).
+					Return(tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPut,
+				"http://localhost"+urlDigest, "", tc.body)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiDeleteDigestSettings(t *testing.T) {
+	t.Parallel()
+
+	inv := minventory.InventoryApp{}
+	inv.On("DeleteDigestSettings", contextMatcher()).Return(nil).Once()
+	inv.On("DeleteDigestSettings", contextMatcher()).
+		Return(errors.New("db error")).Once()
+	api := makeMockApiHandler(t, &inv)
+
+	req := makeReq(http.MethodDelete,
+		"http://localhost"+urlDigest, "", nil)
+	recorded := test.RunRequest(t, api, req)
+	recorded.CodeIs(http.StatusNoContent)
+
+	req = makeReq(http.MethodDelete,
+		"http://localhost"+urlDigest, "", nil)
+	recorded = test.RunRequest(t, api, req)
+	recorded.CodeIs(http.StatusInternalServerError)
+	inv.AssertExpectations(t)
+}
+
+func TestApiPreviewDigest(t *testing.T) {
+	t.Parallel()
+
+	from := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	digest := &model.FleetDigest{
+		From:          from,
+		To:            from.Add(24 * time.Hour),
+		Devices:       10,
+		NewDevices:    2,
+		SilentDevices: 1,
+		GroupChanges: []model.GroupChanges{
+			{Group: "prod", Joined: 2},
+		},
+	}
+	testCases := map[string]struct {
+		digest *model.FleetDigest
+		err    error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			digest: digest,
+			code:   http.StatusOK,
+			resp:   ToJson(digest),
+		},
+		"error, not found": {
+			code: http.StatusNotFound,
+			resp: ToJson(restError("digest settings not found")),
+		},
+		"error, internal": {
+			err:  errors.New("db error"),
+			code: http.StatusInternalServerError,
+			resp: ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			inv.On("PreviewDigest", contextMatcher()).
+				Return(tc.digest, tc.err)
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodGet,
+				"http://localhost"+urlDigestPreview, "", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+		})
+	}
+}
+
+func TestApiGetPinnedAttributes(t *testing.T) {
+	t.Parallel()
+
+	pinned := model.PinnedAttributes{Attributes: []model.SelectAttribute{{
+		Scope:     model.AttrScopeInventory,
+		Attribute: "os",
+	}}}
+	testCases := map[string]struct {
+		pinned model.PinnedAttributes
+		err    error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			pinned: pinned,
+			code:   http.StatusOK,
+			resp:   ToJson(pinned),
+		},
+		"ok, none pinned": {
+			code: http.StatusOK,
+			resp: `{"attributes":[]}`,
+		},
+		"error, internal": {
+			err:  errors.New("db error"),
+			code: http.StatusInternalServerError,
+			resp: ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			inv.On("GetPinnedAttributes", contextMatcher()).
+				Return(tc.pinned, tc.err)
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodGet,
+				"http://localhost"+urlPinnedAttributes, "", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+		})
+	}
+}
+
+func TestApiSetPinnedAttributes(t *testing.T) {
+	t.Parallel()
+
+	pinned := model.PinnedAttributes{Attributes: []model.SelectAttribute{{
+		Scope:     model.AttrScopeInventory,
+		Attribute: "os",
+	}}}
+	testCases := map[string]struct {
+		body interface{}
+
+		callInv bool
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			body:    pinned,
+			callInv: true,
+			code:    http.StatusOK,
+			resp:    ToJson(pinned),
+		},
+		"error, malformed body": {
+			body: "os",
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("failed to decode request body: " +
+				"json: cannot unmarshal string into Go value of type " +
+				"model.PinnedAttributes")),
+		},
+		"error, invalid attribute": {
+			body: map[string]interface{}{
+				"attributes": []map[string]string{{"scope": "inventory"}},
+			},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("attribute: cannot be blank.")),
+		},
+		"error, internal": {
+			body:    pinned,
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				inv.On("SetPinnedAttributes", contextMatcher(), pinned).
+					Return(tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPut,
+				"http://localhost"+urlPinnedAttributes, "", tc.body)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiGetAttributeAliases(t *testing.T) {
+	t.Parallel()
+
+	aliases := model.AttributeAliases{Attributes: []model.AttributeAlias{{
+		Scope:   model.AttrScopeInventory,
+		Name:    "ip_address",
+		Aliases: []string{"ipv4"},
+	}}}
+	testCases := map[string]struct {
+		aliases model.AttributeAliases
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			aliases: aliases,
+			code:    http.StatusOK,
+			resp:    ToJson(aliases),
+		},
+		"ok, no aliases": {
+			code: http.StatusOK,
+			resp: `{"attributes":[]}`,
+		},
+		"error, internal": {
+			err:  errors.New("db error"),
+			code: http.StatusInternalServerError,
+			resp: ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			inv.On("GetAttributeAliases", contextMatcher()).
+				Return(tc.aliases, tc.err)
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodGet,
+				"http://localhost"+urlAttributeAliases, "", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+		})
+	}
+}
+
+func TestApiSetAttributeAliases(t *testing.T) {
+	t.Parallel()
+
+	aliases := model.AttributeAliases{Attributes: []model.AttributeAlias{{
+		Scope:   model.AttrScopeInventory,
+		Name:    "ip_address",
+		Aliases: []string{"ipv4"},
+	}}}
+	testCases := map[string]struct {
+		body interface{}
+
+		callInv bool
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			body:    aliases,
+			callInv: true,
+			code:    http.StatusOK,
+			resp:    ToJson(aliases),
+		},
+		"error, malformed body": {
+			body: "ipv4",
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("failed to decode request body: " +
+				"json: cannot unmarshal string into Go value of type " +
+				"model.AttributeAliases")),
+		},
+		"error, name used twice": {
+			body: model.AttributeAliases{Attributes: []model.AttributeAlias{{
+				Scope:   model.AttrScopeInventory,
+				Name:    "ip_address",
+				Aliases: []string{"ip_address"},
+			}}},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("inventory/ip_address: " +
+				"the name ip_address is used more than once")),
+		},
+		"error, internal": {
+			body:    aliases,
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				inv.On("SetAttributeAliases", contextMatcher(), aliases).
+					Return(tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPut,
+				"http://localhost"+urlAttributeAliases, "", tc.body)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiGetAttributeDescriptions(t *testing.T) {
+	t.Parallel()
+
+	descs := model.AttributeDescriptions{{
+		Scope:       model.AttrScopeInventory,
+		Name:        "os",
+		Description: "Operating system",
+	}}
+	testCases := map[string]struct {
+		descs model.AttributeDescriptions
+		err   error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			descs: descs,
+			code:  http.StatusOK,
+			resp:  ToJson(descs),
+		},
+		"ok, none described": {
+			code: http.StatusOK,
+			resp: `[]`,
+		},
+		"error, internal": {
+			err:  errors.New("db error"),
+			code: http.StatusInternalServerError,
+			resp: ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			inv.On("GetAttributeDescriptions", contextMatcher()).
+				Return(tc.descs, tc.err)
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodGet,
+				"http://localhost"+urlAttributeDescriptions, "", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+		})
+	}
+}
+
+func TestApiSetAttributeDescriptions(t *testing.T) {
+	t.Parallel()
+
+	descs := model.AttributeDescriptions{{
+		Scope:       model.AttrScopeInventory,
+		Name:        "os",
+		Description: "Operating system",
+	}}
+	testCases := map[string]struct {
+		method string
+		body   interface{}
+
+		callInv bool
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok, replace": {
+			method:  http.MethodPut,
+			body:    descs,
+			callInv: true,
+			code:    http.StatusNoContent,
+		},
+		"ok, update": {
+			method:  http.MethodPatch,
+			body:    descs,
+			callInv: true,
+			code:    http.StatusNoContent,
+		},
+		"error, malformed body": {
+			method: http.MethodPut,
+			body:   map[string]string{"os": "Operating system"},
+			code:   http.StatusBadRequest,
+			resp: ToJson(restError("failed to decode request body: " +
+				"json: cannot unmarshal object into Go value of type " +
+				"model.AttributeDescriptions")),
+		},
+		"error, invalid description": {
+			method: http.MethodPatch,
+			body:   []map[string]string{{"name": "os"}},
+			code:   http.StatusBadRequest,
+			resp:   ToJson(restError("/os: scope: cannot be blank.")),
+		},
+		"error, too many descriptions": {
+			method:  http.MethodPatch,
+			body:    descs,
+			callInv: true,
+			err:     inventory.ErrAttributeDescriptionsLimit,
+			code:    http.StatusConflict,
+			resp: ToJson(restError(
+				inventory.ErrAttributeDescriptionsLimit.Error())),
+		},
+		"error, internal": {
+			method:  http.MethodPut,
+			body:    descs,
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				method := "SetAttributeDescriptions"
+				if tc.method == http.MethodPatch {
+					method = "UpdateAttributeDescriptions"
+				}
+				inv.On(method, contextMatcher(), descs).Return(tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(tc.method,
+				"http://localhost"+urlAttributeDescriptions, "", tc.body)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			if tc.resp != "" {
+				recorded.BodyIs(tc.resp)
+			}
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiPreviewGroup(t *testing.T) {
+	t.Parallel()
+
+	preview := model.GroupPreview{
+		Filters: []model.FilterPredicate{{
+			Scope:     model.AttrScopeInventory,
+			Attribute: "device_type",
+			Type:      "$eq",
+			Value:     "raspberrypi4",
+		}},
+		Attributes: []model.SelectAttribute{{
+			Scope:     model.AttrScopeInventory,
+			Attribute: "mac",
+		}},
+		Limit: 1,
+	}
+	result := &model.GroupPreviewResult{
+		Count: 3,
+		Devices: []model.Device{{
+			ID: "1",
+			Attributes: model.DeviceAttributes{{
+				Scope: model.AttrScopeInventory,
+				Name:  "mac",
+				Value: "00:01:02:03:04:05",
+			}},
+		}},
+	}
+	testCases := map[string]struct {
+		body interface{}
+
+		callInv bool
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			body:    preview,
+			callInv: true,
+			code:    http.StatusOK,
+			resp:    ToJson(result),
+		},
+		"error, expression": {
+			body: model.GroupPreview{Expression: "inventory/device_type =="},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("invalid expression: position 24: " +
+				"expected a value, found end of expression")),
+		},
+		"error, no filters": {
+			body: model.GroupPreview{Limit: 1},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("at least one filter term must be provided")),
+		},
+		"error, limit": {
+			body: model.GroupPreview{Filters: preview.Filters, Limit: 100},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("limit: must be no greater than 50.")),
+		},
+		"error, internal": {
+			body:    preview,
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				var res *model.GroupPreviewResult
+				if tc.err == nil {
+					res = result
+				}
+				inv.On("PreviewGroup", contextMatcher(), preview).
+					Return(res, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPost,
+				"http://localhost"+urlGroupsPreview, "", tc.body)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiAssignGroup(t *testing.T) {
+	t.Parallel()
+
+	assignment := model.GroupAssignment{
+		Filters: []model.FilterPredicate{{
+			Scope:     model.AttrScopeInventory,
+			Attribute: "device_type",
+			Type:      "$eq",
+			Value:     "raspberrypi4",
+		}},
+	}
+	result := &model.UpdateResult{MatchedCount: 3, UpdatedCount: 2}
+	testCases := map[string]struct {
+		group string
+		body  interface{}
+
+		callInv bool
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			group:   "foo",
+			body:    assignment,
+			callInv: true,
+			code:    http.StatusOK,
+			resp:    ToJson(result),
+		},
+		"error, group": {
+			group: "foo+bar",
+			body:  assignment,
+			code:  http.StatusBadRequest,
+			resp: ToJson(restError("Group name can only contain: " +
+				"upper/lowercase alphanum, -(dash), _(underscore)")),
+		},
+		"error, no devices": {
+			group: "foo",
+			body:  model.GroupAssignment{},
+			code:  http.StatusBadRequest,
+			resp: ToJson(restError(
+				"either device IDs or filters must be provided")),
+		},
+		"error, bad value": {
+			group:   "foo",
+			body:    assignment,
+			callInv: true,
+			err:     errors.New("(BadValue) $in needs an array"),
+			code:    http.StatusBadRequest,
+			resp:    ToJson(restError("(BadValue) $in needs an array")),
+		},
+		"error, internal": {
+			group:   "foo",
+			body:    assignment,
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				var res *model.UpdateResult
+				if tc.err == nil {
+					res = result
+				}
+				inv.On("UpdateDevicesGroupByFilter", contextMatcher(),
+					assignment.SearchParams(), model.GroupName(tc.group)).
+					Return(res, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPost,
+				"http://localhost"+strings.Replace(
+					urlGroupAssignment, ":name", tc.group, 1,
+				), "", tc.body)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiPreviewGroupAssignment(t *testing.T) {
+	t.Parallel()
+
+	assignment := model.GroupAssignment{
+		DeviceIDs: []model.DeviceID{"1", "2", "3"},
+		Filters: []model.FilterPredicate{{
+			Scope:     model.AttrScopeInventory,
+			Attribute: "device_type",
+			Type:      "$eq",
+			Value:     "raspberrypi4",
+		}},
+	}
+	preview := &model.GroupAssignmentPreview{
+		Matched:        2,
+		NotFound:       1,
+		AlreadyInGroup: 1,
+	}
+	testCases := map[string]struct {
+		group string
+		body  interface{}
+
+		callInv bool
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			group:   "foo",
+			body:    assignment,
+			callInv: true,
+			code:    http.StatusOK,
+			resp:    ToJson(preview),
+		},
+		"error, group": {
+			group: "foo+bar",
+			body:  assignment,
+			code:  http.StatusBadRequest,
+			resp: ToJson(restError("Group name can only contain: " +
+				"upper/lowercase alphanum, -(dash), _(underscore)")),
+		},
+		"error, no devices": {
+			group: "foo",
+			body:  model.GroupAssignment{},
+			code:  http.StatusBadRequest,
+			resp: ToJson(restError(
+				"either device IDs or filters must be provided")),
+		},
+		"error, payload": {
+			group: "foo",
+			body:  "foo",
+			code:  http.StatusBadRequest,
+			resp: ToJson(restError("failed to decode request body: " +
+				"json: cannot unmarshal string into Go value of type " +
+				"model.GroupAssignment")),
+		},
+		"error, internal": {
+			group:   "foo",
+			body:    assignment,
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				var res *model.GroupAssignmentPreview
+				if tc.err == nil {
+					res = preview
+				}
+				inv.On("PreviewDevicesGroup", contextMatcher(),
+					assignment, model.GroupName(tc.group)).
+					Return(res, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPost,
+				"http://localhost"+strings.Replace(
+					urlGroupAssignPreview, ":name", tc.group, 1,
+				), "", tc.body)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiReplaceDynamicGroup(t *testing.T) {
+	t.Parallel()
+
+	group := model.DynamicGroup{
+		Name:       "rpi4",
+		Expression: `inventory/device_type == "raspberrypi4"`,
+	}
+	result := group
+	result.CreatedTs = time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)
+	result.UpdatedTs = result.CreatedTs
+
+	testCases := map[string]struct {
+		body interface{}
+
+		callInv bool
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			body:    group,
+			callInv: true,
+			code:    http.StatusOK,
+			resp:    ToJson(result),
+		},
+		"ok, name of the resource": {
+			body:    model.DynamicGroup{Expression: group.Expression},
+			callInv: true,
+			code:    http.StatusOK,
+			resp:    ToJson(result),
+		},
+		"error, name mismatch": {
+			body: model.DynamicGroup{
+				Name:       "other",
+				Expression: group.Expression,
+			},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("group name does not match the resource")),
+		},
+		"error, expression": {
+			body: model.DynamicGroup{Expression: "inventory/device_type"},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("invalid expression: position 21: " +
+				"expected an operator (\"==\", \"not in\", \"matches\" " +
+				"or \"contains\"), found end of expression")),
+		},
+		"error, name in use": {
+			body:    group,
+			callInv: true,
+			err:     inventory.ErrGroupNameInUse,
+			code:    http.StatusConflict,
+			resp:    ToJson(restError(inventory.ErrGroupNameInUse.Error())),
+		},
+		"error, internal": {
+			body:    group,
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				var res *model.DynamicGroup
+				if tc.err == nil {
+					res = &result
+				}
+				inv.On("ReplaceDynamicGroup", contextMatcher(), group).
+					Return(res, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPut,
+				"http://localhost"+urlDynamicGroups+"/rpi4", "", tc.body)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiGetDynamicGroup(t *testing.T) {
+	t.Parallel()
+
+	group := &model.DynamicGroup{
+		Name:       "rpi4",
+		Expression: `inventory/device_type == "raspberrypi4"`,
+	}
+	testCases := map[string]struct {
+		err error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			code: http.StatusOK,
+			resp: ToJson(group),
+		},
+		"error, not found": {
+			err:  store.ErrDynamicGroupNotFound,
+			code: http.StatusNotFound,
+			resp: ToJson(restError(store.ErrDynamicGroupNotFound.Error())),
+		},
+		"error, internal": {
+			err:  errors.New("db error"),
+			code: http.StatusInternalServerError,
+			resp: ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			var res *model.DynamicGroup
+			if tc.err == nil {
+				res = group
+			}
+			inv.On("GetDynamicGroup", contextMatcher(), model.GroupName("rpi4")).
+				Return(res, tc.err)
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodGet,
+				"http://localhost"+urlDynamicGroups+"/rpi4", "", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiListDynamicGroups(t *testing.T) {
+	t.Parallel()
+
+	groups := []model.DynamicGroup{{
+		Name:       "rpi4",
+		Expression: `inventory/device_type == "raspberrypi4"`,
+	}}
+	inv := minventory.InventoryApp{}
+	inv.On("ListDynamicGroups", contextMatcher()).Return(groups, nil).Once()
+	inv.On("ListDynamicGroups", contextMatcher()).
+		Return(nil, errors.New("db error")).Once()
+	api := makeMockApiHandler(t, &inv)
+
+	req := makeReq(http.MethodGet,
+		"http://localhost"+urlDynamicGroups, "", nil)
+	recorded := test.RunRequest(t, api, req)
+	recorded.CodeIs(http.StatusOK)
+	recorded.BodyIs(ToJson(groups))
+
+	req = makeReq(http.MethodGet,
+		"http://localhost"+urlDynamicGroups, "", nil)
+	recorded = test.RunRequest(t, api, req)
+	recorded.CodeIs(http.StatusInternalServerError)
+	recorded.BodyIs(ToJson(restError("internal error")))
+	inv.AssertExpectations(t)
+}
+
+func TestApiDeleteDynamicGroup(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		err error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			code: http.StatusNoContent,
+		},
+		"error, not found": {
+			err:  store.ErrDynamicGroupNotFound,
+			code: http.StatusNotFound,
+			resp: ToJson(restError(store.ErrDynamicGroupNotFound.Error())),
+		},
+		"error, internal": {
+			err:  errors.New("db error"),
+			code: http.StatusInternalServerError,
+			resp: ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			inv.On("DeleteDynamicGroup", contextMatcher(), model.GroupName("rpi4")).
+				Return(tc.err)
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodDelete,
+				"http://localhost"+urlDynamicGroups+"/rpi4", "", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			if tc.resp != "" {
+				recorded.BodyIs(tc.resp)
+			}
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiReplaceGroupMetadata(t *testing.T) {
+	t.Parallel()
+
+	group := model.GroupMetadata{
+		Name:        "prod",
+		Description: "production devices",
+	}
+	result := group
+	result.Type = model.GroupTypeStatic
+	result.CreatedTs = time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)
+	result.UpdatedTs = result.CreatedTs
+
+	testCases := map[string]struct {
+		body interface{}
+
+		callInv bool
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			body:    group,
+			callInv: true,
+			code:    http.StatusOK,
+			resp:    ToJson(result),
+		},
+		"ok, name of the resource": {
+			body:    model.GroupMetadata{Description: group.Description},
+			callInv: true,
+			code:    http.StatusOK,
+			resp:    ToJson(result),
+		},
+		"error, name mismatch": {
+			body: model.GroupMetadata{
+				Name:        "other",
+				Description: group.Description,
+			},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("group name does not match the resource")),
+		},
+		"error, description": {
+			body: model.GroupMetadata{
+				Description: strings.Repeat("a", model.GroupDescriptionMaxLength+1),
+			},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("description: the length must be no more than 1024.")),
+		},
+		"error, cycle": {
+			body:    group,
+			callInv: true,
+			err:     inventory.ErrGroupCycle,
+			code:    http.StatusBadRequest,
+			resp:    ToJson(restError(inventory.ErrGroupCycle.Error())),
+		},
+		"error, internal": {
+			body:    group,
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				var res *model.GroupMetadata
+				if tc.err == nil {
+					res = &result
+				}
+				inv.On("ReplaceGroupMetadata", contextMatcher(), group).
+					Return(res, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPut,
+				"http://localhost"+urlGroupsMetadata+"/prod", "", tc.body)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiGetGroupMetadata(t *testing.T) {
+	t.Parallel()
+
+	group := &model.GroupMetadata{
+		Name:        "prod",
+		Type:        model.GroupTypeStatic,
+		Description: "production devices",
+	}
+	inv := minventory.InventoryApp{}
+	inv.On("GetGroupMetadata", contextMatcher(), model.GroupName("prod")).
+		Return(group, nil).Once()
+	inv.On("GetGroupMetadata", contextMatcher(), model.GroupName("prod")).
+		Return(nil, store.ErrGroupMetadataNotFound).Once()
+	inv.On("ListGroupsMetadata", contextMatcher()).
+		Return([]model.GroupMetadata{*group}, nil).Once()
+	inv.On("ListGroupsMetadata", contextMatcher()).
+		Return(nil, errors.New("db error")).Once()
+	api := makeMockApiHandler(t, &inv)
+
+	req := makeReq(http.MethodGet,
+		"http://localhost"+urlGroupsMetadata+"/prod", "", nil)
+	recorded := test.RunRequest(t, api, req)
+	recorded.CodeIs(http.StatusOK)
+	recorded.BodyIs(ToJson(group))
+
+	req = makeReq(http.MethodGet,
+		"http://localhost"+urlGroupsMetadata+"/prod", "", nil)
+	recorded = test.RunRequest(t, api, req)
+	recorded.CodeIs(http.StatusNotFound)
+	recorded.BodyIs(ToJson(restError(store.ErrGroupMetadataNotFound.Error())))
+
+	req = makeReq(http.MethodGet,
+		"http://localhost"+urlGroupsMetadata, "", nil)
+	recorded = test.RunRequest(t, api, req)
+	recorded.CodeIs(http.StatusOK)
+	recorded.BodyIs(ToJson([]model.GroupMetadata{*group}))
+
+	req = makeReq(http.MethodGet,
+		"http://localhost"+urlGroupsMetadata, "", nil)
+	recorded = test.RunRequest(t, api, req)
+	recorded.CodeIs(http.StatusInternalServerError)
+	recorded.BodyIs(ToJson(restError("internal error")))
+	inv.AssertExpectations(t)
+}
+
+func TestApiDeleteGroupMetadata(t *testing.T) {
+	t.Parallel()
+
+	inv := minventory.InventoryApp{}
+	inv.On("DeleteGroupMetadata", contextMatcher(), model.GroupName("prod")).
+		Return(nil).Once()
+	inv.On("DeleteGroupMetadata", contextMatcher(), model.GroupName("prod")).
+		Return(store.ErrGroupMetadataNotFound).Once()
+	api := makeMockApiHandler(t, &inv)
+
+	req := makeReq(http.MethodDelete,
+		"http://localhost"+urlGroupsMetadata+"/prod", "", nil)
+	recorded := test.RunRequest(t, api, req)
+	recorded.CodeIs(http.StatusNoContent)
+
+	req = makeReq(http.MethodDelete,
+		"http://localhost"+urlGroupsMetadata+"/prod", "", nil)
+	recorded = test.RunRequest(t, api, req)
+	recorded.CodeIs(http.StatusNotFound)
+	recorded.BodyIs(ToJson(restError(store.ErrGroupMetadataNotFound.Error())))
+	inv.AssertExpectations(t)
+}
+
+func TestApiGetGroupsCompleteness(t *testing.T) {
+	t.Parallel()
+
+	groups := []model.GroupCompleteness{
+		{Devices: 1, Completeness: 0},
+		{Group: "dev", Devices: 2, Complete: 1, Completeness: 0.75},
+	}
+	testCases := map[string]struct {
+		groups []model.GroupCompleteness
+		err    error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			groups: groups,
+			code:   http.StatusOK,
+			resp:   ToJson(groups),
+		},
+		"error, internal": {
+			err:  errors.New("db error"),
+			code: http.StatusInternalServerError,
+			resp: ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			inv.On("GetGroupsCompleteness", contextMatcher()).
+				Return(tc.groups, tc.err)
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodGet,
+				"http://localhost"+urlGroupsCompleteness, "", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+		})
+	}
+}
+
+func TestApiGroupCountsStream(t *testing.T) {
+	t.Parallel()
+
+	pending := []model.GroupCount{{Group: "pending", Count: 2}}
+	updated := []model.GroupCount{
+		{Group: "pending", Count: 1},
+		{Group: "updated", Count: 1},
+	}
+	testCases := map[string]struct {
+		updates [][]model.GroupCount
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			updates: [][]model.GroupCount{pending, updated},
+			code:    http.StatusOK,
+			resp: "event: group_counts\ndata: " + ToJson(pending) + "\n\n" +
+				"event: group_counts\ndata: " + ToJson(updated) + "\n\n",
+		},
+		"error, internal": {
+			err:  errors.New("db error"),
+			code: http.StatusInternalServerError,
+			resp: ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var updates chan []model.GroupCount
+			if tc.err == nil {
+				updates = make(chan []model.GroupCount, len(tc.updates))
+				for _, counts := range tc.updates {
+					updates <- counts
+				}
+				close(updates)
+			}
+			inv := minventory.InventoryApp{}
+			inv.On("WatchGroupCounts", contextMatcher()).
+				Return((<-chan []model.GroupCount)(updates), tc.err)
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodGet,
+				"http://localhost"+urlGroupsCountsStream, "", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			if tc.err == nil {
+				recorded.HeaderIs("Content-Type", contentTypeSSE)
+			}
+		})
+	}
+}
+
+func TestApiInternalReconcileDevices(t *testing.T) {
+	t.Parallel()
+
+	rec := model.Reconciliation{
+		DeviceAuth:    []model.DeviceID{"1", "2"},
+		Deployments:   []model.DeviceID{"1"},
+		CreateMissing: true,
+	}
+	report := &model.ReconciliationReport{
+		MissingFromInventory:   []model.DeviceID{"2"},
+		MissingFromDeviceAuth:  []model.DeviceID{},
+		MissingFromDeployments: []model.DeviceID{"2"},
+		Created:                []model.DeviceID{"2"},
+	}
+	testCases := map[string]struct {
+		body interface{}
+
+		callInv bool
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			body:    rec,
+			callInv: true,
+			code:    http.StatusOK,
+			resp:    ToJson(report),
+		},
+		"error, missing deployments": {
+			body: map[string]interface{}{
+				"deviceauth": []string{"1"},
+			},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("deployments: is required.")),
+		},
+		"error, internal": {
+			body:    rec,
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				var res *model.ReconciliationReport
+				if tc.err == nil {
+					res = report
+				}
+				inv.On("ReconcileDevices",
+					mock.MatchedBy(func(ctx context.Context) bool {
+						id := midentity.FromContext(ctx)
+						return id != nil && id.Tenant == "tenant"
+					}),
+					rec,
+				).Return(res, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPost,
+				"http://localhost/api/internal/v1/inventory/tenants/tenant/reconciliation",
+				"", tc.body)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiInternalUpsertExternalIDs(t *testing.T) {
+	t.Parallel()
+
+	ids := []model.ExternalID{{
+		ExternalIDRef: model.ExternalIDRef{System: "erp", ID: "SN-1"},
+		DeviceID:      "1",
+	}}
+	testCases := map[string]struct {
+		body interface{}
+
+		callInv bool
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			body:    ids,
+			callInv: true,
+			code:    http.StatusOK,
+			resp:    ToJson(&model.UpdateResult{CreatedCount: 1}),
+		},
+		"error, invalid external ID": {
+			body: []model.ExternalID{{
+				ExternalIDRef: model.ExternalIDRef{System: "erp"},
+				DeviceID:      "1",
+			}},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("id: cannot be blank.")),
+		},
+		"error, too many external IDs": {
+			body: make([]model.ExternalID, model.ExternalIDsBatchMax+1),
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("at most 1000 external IDs can be updated at once")),
+		},
+		"error, internal": {
+			body:    ids,
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				var res *model.UpdateResult
+				if tc.err == nil {
+					res = &model.UpdateResult{CreatedCount: 1}
+				}
+				inv.On("UpsertExternalIDs", contextMatcher(), ids).
+					Return(res, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPut,
+				"http://localhost/api/internal/v1/inventory/tenants/tenant/external_ids",
+				"", tc.body)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiInternalIngestTimeline(t *testing.T) {
+	t.Parallel()
+
+	events := []model.TimelineEvent{{
+		DedupKey:  "deployments:1:started",
+		DeviceID:  "1",
+		Type:      model.TimelineDeploymentStarted,
+		Source:    "deployments",
+		Timestamp: time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC),
+		Data:      map[string]interface{}{"deployment_id": "1"},
+	}}
+	testCases := map[string]struct {
+		body interface{}
+
+		callInv bool
+		err     error
+
+		code       int
+		retryAfter string
+		resp       string
+	}{
+		"ok": {
+			body:    events,
+			callInv: true,
+			code:    http.StatusOK,
+			resp:    ToJson(&model.TimelineIngestResult{Accepted: 1}),
+		},
+		"error, invalid event": {
+			body: []model.TimelineEvent{{
+				DedupKey:  "1",
+				DeviceID:  "1",
+				Type:      "rebooted",
+				Source:    "deviceauth",
+				Timestamp: time.Now(),
+			}},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("type: must be a valid value.")),
+		},
+		"error, too many events": {
+			body: make([]model.TimelineEvent, model.TimelineEventsBatchMax+1),
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("at most 1000 timeline events can be ingested at once")),
+		},
+		"error, busy": {
+			body:       events,
+			callInv:    true,
+			err:        inventory.ErrTimelineBusy,
+			code:       http.StatusTooManyRequests,
+			retryAfter: "1",
+			resp:       ToJson(restError(inventory.ErrTimelineBusy.Error())),
+		},
+		"error, internal": {
+			body:    events,
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				var res *model.TimelineIngestResult
+				if tc.err == nil {
+					res = &model.TimelineIngestResult{Accepted: 1}
+				}
+				inv.On("IngestTimelineEvents", contextMatcher(), events).
+					Return(res, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPost,
+				"http://localhost/api/internal/v1/inventory/tenants/tenant/timeline",
+				"", tc.body)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			assert.Equal(t, tc.retryAfter,
+				recorded.Recorder.Header().Get(hdrRetryAfter))
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiInternalDeleteExternalID(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		err error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			code: http.StatusNoContent,
+		},
+		"error, not found": {
+			err:  store.ErrExternalIDNotFound,
+			code: http.StatusNotFound,
+			resp: ToJson(restError(store.ErrExternalIDNotFound.Error())),
+		},
+		"error, internal": {
+			err:  errors.New("db error"),
+			code: http.StatusInternalServerError,
+			resp: ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			inv.On("DeleteExternalID",
+				contextMatcher(),
+				model.ExternalIDRef{System: "erp", ID: "SN-1"},
+			).Return(tc.err)
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodDelete,
+				"http://localhost/api/internal/v1/inventory/tenants/tenant/external_ids/erp/SN-1",
+				"", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			if tc.resp != "" {
+				recorded.BodyIs(tc.resp)
+			}
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiInternalUpdateFeatureFlags(t *testing.T) {
+	t.Parallel()
+
+	disabled := false
+	update := model.FeatureFlagsUpdate{
+		model.FeatureWebhooks: &disabled,
+		model.FeatureAPIv2:    nil,
+	}
+	flags := model.FeatureFlagSet{
+		model.FeatureDynamicGroups: true,
+		model.FeatureWebhooks:      false,
+		model.FeatureAPIv2:         true,
+	}
+	testCases := map[string]struct {
+		body interface{}
+
+		callInv bool
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			body:    map[string]interface{}{"webhooks": false, "api_v2": nil},
+			callInv: true,
+			code:    http.StatusOK,
+			resp:    ToJson(flags),
+		},
+		"error, unknown flag": {
+			body: map[string]interface{}{"teleport": true},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("unknown feature flag: teleport")),
+		},
+		"error, empty update": {
+			body: map[string]interface{}{},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("no feature flags to update")),
+		},
+		"error, internal": {
+			body:    map[string]interface{}{"webhooks": false, "api_v2": nil},
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				var res model.FeatureFlagSet
+				if tc.err == nil {
+					res = flags
+				}
+				inv.On("UpdateFeatureFlags", contextMatcher(), update).
+					Return(res, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPatch,
+				"http://localhost/api/internal/v1/inventory/tenants/tenant/feature_flags",
+				"", tc.body)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiInternalGetFeatureFlags(t *testing.T) {
+	t.Parallel()
+
+	flags := model.FeatureFlagSet{model.FeatureAPIv2: true}
+	inv := minventory.InventoryApp{}
+	inv.On("GetFeatureFlags", contextMatcher()).Return(flags, nil).Once()
+	inv.On("GetFeatureFlags", contextMatcher()).
+		Return(nil, errors.New("db error")).Once()
+
+	api := makeMockApiHandler(t, &inv)
+	req := makeReq(http.MethodGet,
+		"http://localhost/api/internal/v1/inventory/tenants/tenant/feature_flags",
+		"", nil)
+	recorded := test.RunRequest(t, api, req)
+	recorded.CodeIs(http.StatusOK)
+	recorded.BodyIs(ToJson(flags))
+
+	recorded = test.RunRequest(t, api, req)
+	recorded.CodeIs(http.StatusInternalServerError)
+	inv.AssertExpectations(t)
+}
+
+func TestApiInternalGetLimits(t *testing.T) {
+	t.Parallel()
+
+	limits := model.Limits{PerPage: 100}
+	inv := minventory.InventoryApp{}
+	inv.On("GetLimits", contextMatcher()).Return(limits, nil).Once()
+	inv.On("GetLimits", contextMatcher()).
+		Return(model.Limits{}, errors.New("db error")).Once()
+
+	api := makeMockApiHandler(t, &inv)
+	req := makeReq(http.MethodGet,
+		"http://localhost/api/internal/v1/inventory/tenants/tenant/limits",
+		"", nil)
+	recorded := test.RunRequest(t, api, req)
+	recorded.CodeIs(http.StatusOK)
+	recorded.BodyIs(ToJson(limits))
+
+	recorded = test.RunRequest(t, api, req)
+	recorded.CodeIs(http.StatusInternalServerError)
+	inv.AssertExpectations(t)
+}
+
+func TestApiInternalSetLimits(t *testing.T) {
+	t.Parallel()
+
+	limits := model.Limits{PerPage: 100, ExportDevices: 10000}
+	testCases := map[string]struct {
+		body interface{}
+
+		callInv bool
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			body:    limits,
+			callInv: true,
+			code:    http.StatusOK,
+			resp:    ToJson(limits),
+		},
+		"error, negative limit": {
+			body: map[string]interface{}{"per_page": -1},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("per_page: must be no less than 0.")),
+		},
+		"error, internal": {
+			body:    limits,
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				inv.On("SetLimits", contextMatcher(), limits).Return(tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPut,
+				"http://localhost/api/internal/v1/inventory/tenants/tenant/limits",
+				"", tc.body)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiCheckLimits(t *testing.T) {
+	t.Parallel()
+
+	params := model.SearchParams{
+		Page:    1,
+		PerPage: 300,
+		Filters: []model.FilterPredicate{{
+			Scope: "inventory", Attribute: "device_type",
+			Type: "$eq", Value: "rpi4",
+		}},
+	}
+	size := model.Limits{PerPage: 300, Filters: 1}
+	testCases := map[string]struct {
+		warnings []model.LimitViolation
+		err      error
+
+		callSearch bool
+		code       int
+		hdrs       []string
+		resp       string
+	}{
+		"ok": {
+			callSearch: true,
+			code:       http.StatusOK,
+			resp:       "[]",
+		},
+		"ok, soft limits exceeded": {
+			warnings: []model.LimitViolation{
+				{Limit: model.LimitPerPage, Value: 300, Max: 100},
+			},
+			callSearch: true,
+			code:       http.StatusOK,
+			hdrs: []string{
+				`299 - "per_page 300 exceeds the limit of 100"`,
+			},
+			resp: "[]",
+		},
+		"error, hard limits exceeded": {
+			err: errors.Wrap(inventory.ErrLimitExceeded,
+				"per_page 300 exceeds the limit of 200"),
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("per_page 300 exceeds the limit of 200: " +
+				"request exceeds the limits of the tenant")),
+		},
+		"error, internal": {
+			err:  errors.New("db error"),
+			code: http.StatusInternalServerError,
+			resp: ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			inv.On("CheckLimits", contextMatcher(), size).
+				Return(tc.warnings, tc.err)
+			if tc.callSearch {
+				inv.On("SearchDevices", contextMatcher(), params).
+					Return([]model.Device{}, 0, nil)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPost,
+				"http://localhost"+urlFiltersSearch, "", params)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			assert.Equal(t, tc.hdrs,
+				recorded.Recorder.Header()[hdrWarning])
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiFiltersValidate(t *testing.T) {
+	t.Parallel()
+
+	req := model.FilterValidationRequest{
+		Expression: `inventory/device_type == "rpi4"`,
+	}
+	result := &model.FilterValidation{
+		Valid:     true,
+		Canonical: `inventory/device_type == "rpi4"`,
+		Filters: []model.FilterPredicate{{
+			Scope: "inventory", Attribute: "device_type",
+			Type: "$eq", Value: "rpi4",
+		}},
+	}
+	testCases := map[string]struct {
+		body interface{}
+
+		callInv bool
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			body:    req,
+			callInv: true,
+			code:    http.StatusOK,
+			resp:    ToJson(result),
+		},
+		"error, no filter": {
+			body: map[string]interface{}{},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("exactly one of expression and filters is required")),
+		},
+		"error, internal": {
+			body:    req,
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				var res *model.FilterValidation
+				if tc.err == nil {
+					res = result
+				}
+				inv.On("ValidateFilter", contextMatcher(), req).
+					Return(res, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPost,
+				"http://localhost/api/management/v2/inventory/filters/validate",
+				"", tc.body)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiCreateSubscription(t *testing.T) {
+	t.Parallel()
+
+	sub := model.Subscription{
+		DeviceID: "1",
+		Channel: model.NotificationChannel{
+			Type: model.NotificationChannelWebhook,
+			URL:  "https://hooks.example.com",
+		},
+	}
+	created := sub
+	created.ID = "sub"
+	created.CreatedTs = time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := map[string]struct {
+		body interface{}
+
+		callInv bool
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			body:    sub,
+			callInv: true,
+			code:    http.StatusCreated,
+			resp:    ToJson(created),
+		},
+		"error, invalid subscription": {
+			body: model.Subscription{Channel: sub.Channel},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("a device or filters are required")),
+		},
+		"error, no user": {
+			body:    sub,
+			callInv: true,
+			err:     inventory.ErrSubscriptionUserRequired,
+			code:    http.StatusForbidden,
+			resp:    ToJson(restError(inventory.ErrSubscriptionUserRequired.Error())),
+		},
+		"error, limit": {
+			body:    sub,
+			callInv: true,
+			err:     inventory.ErrSubscriptionsLimit,
+			code:    http.StatusConflict,
+			resp:    ToJson(restError(inventory.ErrSubscriptionsLimit.Error())),
+		},
+		"error, internal": {
+			body:    sub,
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				var res *model.Subscription
+				if tc.err == nil {
+					res = &created
+				}
+				inv.On("CreateSubscription", contextMatcher(), sub).
+					Return(res, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPost,
+				"http://localhost/api/management/v2/inventory/subscriptions",
+				"", tc.body)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			if tc.code == http.StatusCreated {
+				recorded.HeaderIs("Location", "subscriptions/sub")
+			}
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiReplaceSubscription(t *testing.T) {
+	t.Parallel()
+
+	sub := model.Subscription{
+		DeviceID: "1",
+		Channel: model.NotificationChannel{
+			Type: model.NotificationChannelWebhook,
+			URL:  "https://hooks.example.com",
+		},
+	}
+	replaced := sub
+	replaced.ID = "sub"
+	replaced.CreatedTs = time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := map[string]struct {
+		id   string
+		body interface{}
+
+		callInv bool
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			id:      "sub",
+			body:    sub,
+			callInv: true,
+			code:    http.StatusOK,
+			resp:    ToJson(replaced),
+		},
+		"error, ID mismatch": {
+			id:   "other",
+			body: replaced,
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("subscription ID does not match the resource")),
+		},
+		"error, invalid subscription": {
+			id:   "sub",
+			body: model.Subscription{Channel: sub.Channel},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("a device or filters are required")),
+		},
+		"error, ID in use": {
+			id:      "sub",
+			body:    sub,
+			callInv: true,
+			err:     inventory.ErrSubscriptionIDInUse,
+			code:    http.StatusConflict,
+			resp:    ToJson(restError(inventory.ErrSubscriptionIDInUse.Error())),
+		},
+		"error, no user": {
+			id:      "sub",
+			body:    sub,
+			callInv: true,
+			err:     inventory.ErrSubscriptionUserRequired,
+			code:    http.StatusForbidden,
+			resp:    ToJson(restError(inventory.ErrSubscriptionUserRequired.Error())),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				var res *model.Subscription
+				if tc.err == nil {
+					res = &replaced
+				}
+				withID := sub
+				withID.ID = "sub"
+				inv.On("ReplaceSubscription", contextMatcher(), withID).
+					Return(res, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPut,
+				"http://localhost"+urlSubscriptions+"/"+tc.id, "", tc.body)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiListSubscriptions(t *testing.T) {
+	t.Parallel()
+
+	subs := []model.Subscription{{
+		ID:       "sub",
+		DeviceID: "1",
+		Channel: model.NotificationChannel{
+			Type:  model.NotificationChannelEmail,
+			Email: "user@example.com",
+		},
+	}}
+	inv := minventory.InventoryApp{}
+	inv.On("ListSubscriptions", contextMatcher()).Return(subs, nil).Once()
+	inv.On("ListSubscriptions", contextMatcher()).
+		Return(nil, inventory.ErrSubscriptionUserRequired).Once()
+
+	api := makeMockApiHandler(t, &inv)
+	req := makeReq(http.MethodGet,
+		"http://localhost/api/management/v2/inventory/subscriptions",
+		"", nil)
+	recorded := test.RunRequest(t, api, req)
+	recorded.CodeIs(http.StatusOK)
+	recorded.BodyIs(ToJson(subs))
+
+	recorded = test.RunRequest(t, api, req)
+	recorded.CodeIs(http.StatusForbidden)
+	inv.AssertExpectations(t)
+}
+
+func TestApiDeleteSubscription(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		err error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			code: http.StatusNoContent,
+		},
+		"error, not found": {
+			err:  store.ErrSubscriptionNotFound,
+			code: http.StatusNotFound,
+			resp: ToJson(restError(store.ErrSubscriptionNotFound.Error())),
+		},
+		"error, internal": {
+			err:  errors.New("db error"),
+			code: http.StatusInternalServerError,
+			resp: ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			inv.On("DeleteSubscription", contextMatcher(), "sub").Return(tc.err)
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodDelete,
+				"http://localhost/api/management/v2/inventory/subscriptions/sub",
+				"", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			if tc.resp != "" {
+				recorded.BodyIs(tc.resp)
+			}
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiListSavedFilters(t *testing.T) {
+	t.Parallel()
+
+	filters := []model.SavedFilter{{ID: "1", Name: "offline", OwnerID: "user"}}
+	testCases := map[string]struct {
+		path   string
+		shared bool
+		err    error
+
+		code int
+		resp string
+	}{
+		"ok, private": {
+			path: urlSavedFiltersPrivate,
+			code: http.StatusOK,
+			resp: ToJson(filters),
+		},
+		"ok, shared": {
+			path:   urlSavedFiltersShared,
+			shared: true,
+			code:   http.StatusOK,
+			resp:   ToJson(filters),
+		},
+		"error, no user": {
+			path: urlSavedFiltersPrivate,
+			err:  inventory.ErrSavedFilterUserRequired,
+			code: http.StatusForbidden,
+			resp: ToJson(restError(inventory.ErrSavedFilterUserRequired.Error())),
+		},
+		"error, internal": {
+			path:   urlSavedFiltersShared,
+			shared: true,
+			err:    errors.New("db error"),
+			code:   http.StatusInternalServerError,
+			resp:   ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			var res []model.SavedFilter
+			if tc.err == nil {
+				res = filters
+			}
+			inv.On("ListSavedFilters", contextMatcher(), tc.shared).
+				Return(res, tc.err)
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodGet, "http://localhost"+tc.path, "", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiGetSavedFilter(t *testing.T) {
+	t.Parallel()
+
+	filter := &model.SavedFilter{ID: "1", Name: "offline", OwnerID: "user"}
+	testCases := map[string]struct {
+		err error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			code: http.StatusOK,
+			resp: ToJson(filter),
+		},
+		"error, not found": {
+			err:  store.ErrSavedFilterNotFound,
+			code: http.StatusNotFound,
+			resp: ToJson(restError(store.ErrSavedFilterNotFound.Error())),
+		},
+		"error, internal": {
+			err:  errors.New("db error"),
+			code: http.StatusInternalServerError,
+			resp: ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			var res *model.SavedFilter
+			if tc.err == nil {
+				res = filter
+			}
+			inv.On("GetSavedFilter", contextMatcher(), "1").Return(res, tc.err)
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodGet,
+				"http://localhost"+urlSavedFilters+"/1", "", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiCreateSavedFilter(t *testing.T) {
+	t.Parallel()
+
+	filter := model.SavedFilter{
+		Name:   "offline",
+		Shared: true,
+		Filters: []model.FilterPredicate{{
+			Scope:     model.AttrScopeInventory,
+			Attribute: "status",
+			Type:      "$eq",
+			Value:     "offline",
+		}},
+	}
+	created := filter
+	created.ID = "1"
+	created.OwnerID = "user"
+	testCases := map[string]struct {
+		body interface{}
+
+		callInv bool
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			body:    filter,
+			callInv: true,
+			code:    http.StatusCreated,
+			resp:    ToJson(created),
+		},
+		"error, invalid filter": {
+			body: model.SavedFilter{Filters: filter.Filters},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("name: cannot be blank.")),
+		},
+		"error, no user": {
+			body:    filter,
+			callInv: true,
+			err:     inventory.ErrSavedFilterUserRequired,
+			code:    http.StatusForbidden,
+			resp:    ToJson(restError(inventory.ErrSavedFilterUserRequired.Error())),
+		},
+		"error, limit": {
+			body:    filter,
+			callInv: true,
+			err:     inventory.ErrSavedFiltersLimit,
+			code:    http.StatusConflict,
+			resp:    ToJson(restError(inventory.ErrSavedFiltersLimit.Error())),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				var res *model.SavedFilter
+				if tc.err == nil {
+					res = &created
+				}
+				inv.On("CreateSavedFilter", contextMatcher(),
+					mock.AnythingOfType("model.SavedFilter"),
+				).Return(res, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPost,
+				"http://localhost"+urlSavedFilters, "", tc.body)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			if tc.code == http.StatusCreated {
+				recorded.HeaderIs("Location", "saved_filters/1")
+			}
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiReplaceSavedFilter(t *testing.T) {
+	t.Parallel()
+
+	filter := model.SavedFilter{
+		Name: "offline",
+		Filters: []model.FilterPredicate{{
+			Scope:     model.AttrScopeInventory,
+			Attribute: "status",
+			Type:      "$eq",
+			Value:     "offline",
+		}},
+	}
+	updated := filter
+	updated.ID = "offline-1"
+	testCases := map[string]struct {
+		id   string
+		body model.SavedFilter
+
+		callInv bool
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			id:      "offline-1",
+			body:    filter,
+			callInv: true,
+			code:    http.StatusOK,
+			resp:    ToJson(updated),
+		},
+		"ok, ID in the body": {
+			id:      "offline-1",
+			body:    updated,
+			callInv: true,
+			code:    http.StatusOK,
+			resp:    ToJson(updated),
+		},
+		"error, ID mismatch": {
+			id:   "other",
+			body: updated,
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("filter ID does not match the resource")),
+		},
+		"error, invalid ID": {
+			id:   "off%20line",
+			body: filter,
+			code: http.StatusBadRequest,
+			resp: ToJson(restError(
+				"id: must be at most 64 letters, digits or _.~- characters")),
+		},
+		"error, not owner": {
+			id:      "offline-1",
+			body:    filter,
+			callInv: true,
+			err:     inventory.ErrSavedFilterNotOwner,
+			code:    http.StatusForbidden,
+			resp:    ToJson(restError(inventory.ErrSavedFilterNotOwner.Error())),
+		},
+		"error, limit": {
+			id:      "offline-1",
+			body:    filter,
+			callInv: true,
+			err:     inventory.ErrSavedFiltersLimit,
+			code:    http.StatusConflict,
+			resp:    ToJson(restError(inventory.ErrSavedFiltersLimit.Error())),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				var res *model.SavedFilter
+				if tc.err == nil {
+					res = &updated
+				}
+				inv.On("ReplaceSavedFilter", contextMatcher(),
+					mock.MatchedBy(func(f model.SavedFilter) bool {
+						return f.ID == "offline-1" && f.Name == filter.Name
+					}),
+				).Return(res, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPut,
+				"http://localhost"+urlSavedFilters+"/"+tc.id, "", tc.body)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiDeleteSavedFilter(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		err error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			code: http.StatusNoContent,
+		},
+		"error, not owner": {
+			err:  inventory.ErrSavedFilterNotOwner,
+			code: http.StatusForbidden,
+			resp: ToJson(restError(inventory.ErrSavedFilterNotOwner.Error())),
+		},
+		"error, not found": {
+			err:  store.ErrSavedFilterNotFound,
+			code: http.StatusNotFound,
+			resp: ToJson(restError(store.ErrSavedFilterNotFound.Error())),
+		},
+		"error, internal": {
+			err:  errors.New("db error"),
+			code: http.StatusInternalServerError,
+			resp: ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			inv.On("DeleteSavedFilter", contextMatcher(), "1").Return(tc.err)
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodDelete,
+				"http://localhost"+urlSavedFilters+"/1", "", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			if tc.resp != "" {
+				recorded.BodyIs(tc.resp)
+			}
+			inv.AssertExpectations(t)
+		})
+	}
+}
+func TestApiSearchSavedFilter(t *testing.T) {
+	t.Parallel()
+
+	filter := &model.SavedFilter{
+		ID:      "1",
+		Name:    "offline",
+		OwnerID: "user",
+		Filters: []model.FilterPredicate{{
+			Scope:     model.AttrScopeInventory,
+			Attribute: "status",
+			Type:      "$eq",
+			Value:     "offline",
+		}},
+		Sort: []model.SortCriteria{{
+			Scope:     model.AttrScopeSystem,
+			Attribute: model.AttrNameUpdated,
+			Order:     "desc",
+		}},
+	}
+	devs := []model.Device{{ID: "dev-1"}}
+
+	testCases := map[string]struct {
+		query string
+
+		callGet    bool
+		getErr     error
+		callSearch bool
+		params     model.SearchParams
+
+		code  int
+		resp  string
+		total string
+	}{
+		"ok": {
+			callGet:    true,
+			callSearch: true,
+			params: model.SearchParams{
+				Page:    utils.PageDefault,
+				PerPage: utils.PerPageDefault,
+				Filters: filter.Filters,
+				Sort:    filter.Sort,
+			},
+			code:  http.StatusOK,
+			resp:  ToJson(devs),
+			total: "1",
+		},
+		"ok, pagination": {
+			query:      "?page=3&per_page=5",
+			callGet:    true,
+			callSearch: true,
+			params: model.SearchParams{
+				Page:    3,
+				PerPage: 5,
+				Filters: filter.Filters,
+				Sort:    filter.Sort,
+			},
+			code:  http.StatusOK,
+			resp:  ToJson(devs),
+			total: "1",
+		},
+		"error, bad pagination": {
+			query: "?page=0",
+			code:  http.StatusBadRequest,
+			resp: ToJson(restError(
+				utils.MsgQueryParmLimit(utils.PageName))),
+		},
+		"error, not found": {
+			callGet: true,
+			getErr:  store.ErrSavedFilterNotFound,
+			code:    http.StatusNotFound,
+			resp:    ToJson(restError(store.ErrSavedFilterNotFound.Error())),
+		},
+		"error, no user": {
+			callGet: true,
+			getErr:  inventory.ErrSavedFilterUserRequired,
+			code:    http.StatusForbidden,
+			resp: ToJson(restError(
+				inventory.ErrSavedFilterUserRequired.Error())),
+		},
+		"error, internal": {
+			callGet: true,
+			getErr:  errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callGet {
+				var res *model.SavedFilter
+				if tc.getErr == nil {
+					res = filter
+				}
+				inv.On("GetSavedFilter", contextMatcher(), "1").
+					Return(res, tc.getErr)
+			}
+			if tc.callSearch {
+				inv.On("CheckLimits", contextMatcher(), model.Limits{
+					PerPage: tc.params.PerPage,
+					Filters: len(tc.params.Filters),
+				}).Return(nil, nil)
+				inv.On("SearchDevices", contextMatcher(), tc.params).
+					Return(devs, len(devs), nil)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodGet,
+				"http://localhost"+urlSavedFilters+"/1/devices"+tc.query,
+				"", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			if tc.total != "" {
+				recorded.HeaderIs(hdrTotalCount, tc.total)
+			}
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiListDeviceChanges(t *testing.T) {
+	t.Parallel()
+
+	page := &model.DeviceChangesPage{
+		Changes: []model.DeviceChange{{
+			Seq:       5,
+			DeviceID:  "1",
+			Type:      model.DeviceChangeDeleted,
+			Timestamp: time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC),
+		}},
+		NextToken: "5",
+	}
+
+	testCases := map[string]struct {
+		query string
+
+		callInv bool
+		token   string
+		limit   int
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			callInv: true,
+			limit:   model.DeviceChangesLimitDefault,
+			code:    http.StatusOK,
+			resp:    ToJson(page),
+		},
+		"ok, resumed": {
+			query:   "?since_token=4&limit=10",
+			callInv: true,
+			token:   "4",
+			limit:   10,
+			code:    http.StatusOK,
+			resp:    ToJson(page),
+		},
+		"error, bad limit": {
+			query: "?limit=1001",
+			code:  http.StatusBadRequest,
+			resp:  ToJson(restError(utils.MsgQueryParmLimit(queryParamLimit))),
+		},
+		"error, invalid token": {
+			query:   "?since_token=abc",
+			callInv: true,
+			token:   "abc",
+			limit:   model.DeviceChangesLimitDefault,
+			err:     inventory.ErrInvalidChangeToken,
+			code:    http.StatusBadRequest,
+			resp:    ToJson(restError(inventory.ErrInvalidChangeToken.Error())),
+		},
+		"error, expired token": {
+			query:   "?since_token=1",
+			callInv: true,
+			token:   "1",
+			limit:   model.DeviceChangesLimitDefault,
+			err:     inventory.ErrChangeTokenExpired,
+			code:    http.StatusGone,
+			resp:    ToJson(restError(inventory.ErrChangeTokenExpired.Error())),
+		},
+		"error, internal": {
+			callInv: true,
+			limit:   model.DeviceChangesLimitDefault,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				var res *model.DeviceChangesPage
+				if tc.err == nil {
+					res = page
+				}
+				inv.On("ListDeviceChanges", contextMatcher(), tc.token, tc.limit).
+					Return(res, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodGet,
+				"http://localhost/api/0.1.0/devices/changes"+tc.query, "", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiStartExport(t *testing.T) {
+	t.Parallel()
+
+	exportReq := model.ExportRequest{Kind: model.ExportKindInventory}
+	job := model.ExportJob{
+		ID:        "job",
+		Kind:      model.ExportKindInventory,
+		Format:    model.ExportFormatParquet,
+		Status:    model.ExportStatusPending,
+		CreatedTs: time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	testCases := map[string]struct {
+		body interface{}
+
+		callInv bool
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			body:    exportReq,
+			callInv: true,
+			code:    http.StatusAccepted,
+			resp:    ToJson(job),
+		},
+		"error, unsupported format": {
+			body: model.ExportRequest{
+				Kind:   model.ExportKindInventory,
+				Format: "csv",
+			},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("format: must be a valid value.")),
+		},
+		"error, disabled": {
+			body:    exportReq,
+			callInv: true,
+			err:     inventory.ErrExportsDisabled,
+			code:    http.StatusNotImplemented,
+			resp:    ToJson(restError(inventory.ErrExportsDisabled.Error())),
+		},
+		"error, internal": {
+			body:    exportReq,
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				var res *model.ExportJob
+				if tc.err == nil {
+					res = &job
+				}
+				inv.On("StartExport", contextMatcher(), exportReq).
+					Return(res, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPost,
+				"http://localhost/api/management/v2/inventory/exports",
+				"", tc.body)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			if tc.code == http.StatusAccepted {
+				recorded.HeaderIs("Location", "exports/job")
+			}
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiGetExportJob(t *testing.T) {
+	t.Parallel()
+
+	job := &model.ExportJob{
+		ID:        "job",
+		Kind:      model.ExportKindInventory,
+		Format:    model.ExportFormatParquet,
+		Status:    model.ExportStatusDone,
+		Object:    "exports/tenant/job.parquet",
+		Devices:   10,
+		CreatedTs: time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	testCases := map[string]struct {
+		job *model.ExportJob
+		err error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			job:  job,
+			code: http.StatusOK,
+			resp: ToJson(job),
+		},
+		"error, not found": {
+			err:  store.ErrExportJobNotFound,
+			code: http.StatusNotFound,
+			resp: ToJson(restError(store.ErrExportJobNotFound.Error())),
+		},
+		"error, internal": {
+			err:  errors.New("db error"),
+			code: http.StatusInternalServerError,
+			resp: ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			inv.On("GetExportJob", contextMatcher(), "job").Return(tc.job, tc.err)
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodGet,
+				"http://localhost/api/management/v2/inventory/exports/job",
+				"", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiInternalListDeadLetters(t *testing.T) {
+	t.Parallel()
+
+	letters := []model.DeadLetter{{
+		ID:        "1",
+		URL:       "https://hooks.example.com",
+		Payload:   json.RawMessage(`[{"id":"1"}]`),
+		Reason:    "connection refused",
+		Attempts:  1,
+		CreatedTs: time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC),
+	}}
+	inv := minventory.InventoryApp{}
+	inv.On("ListDeadLetters", contextMatcher(), 20, 20).
+		Return(letters, 21, nil).Once()
+	inv.On("ListDeadLetters", contextMatcher(), 0, 20).
+		Return(nil, -1, errors.New("db error")).Once()
+
+	api := makeMockApiHandler(t, &inv)
+	req := makeReq(http.MethodGet,
+		"http://localhost/api/internal/v1/inventory/tenants/tenant/dead_letters?page=2",
+		"", nil)
+	recorded := test.RunRequest(t, api, req)
+	recorded.CodeIs(http.StatusOK)
+	recorded.BodyIs(ToJson(letters))
+	recorded.HeaderIs(hdrTotalCount, "21")
+
+	req = makeReq(http.MethodGet,
+		"http://localhost/api/internal/v1/inventory/tenants/tenant/dead_letters",
+		"", nil)
+	recorded = test.RunRequest(t, api, req)
+	recorded.CodeIs(http.StatusInternalServerError)
+	inv.AssertExpectations(t)
+}
+
+func TestApiInternalPurgeDeadLetters(t *testing.T) {
+	t.Parallel()
+
+	result := &model.UpdateResult{DeletedCount: 3}
+	inv := minventory.InventoryApp{}
+	inv.On("PurgeDeadLetters", contextMatcher()).Return(result, nil)
+
+	api := makeMockApiHandler(t, &inv)
+	req := makeReq(http.MethodDelete,
+		"http://localhost/api/internal/v1/inventory/tenants/tenant/dead_letters",
+		"", nil)
+	recorded := test.RunRequest(t, api, req)
+	recorded.CodeIs(http.StatusOK)
+	recorded.BodyIs(ToJson(result))
+	inv.AssertExpectations(t)
+}
+
+func TestApiInternalDeleteDeadLetter(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		err error
+
+		code int
+	}{
+		"ok": {
+			code: http.StatusNoContent,
+		},
+		"error, not found": {
+			err:  store.ErrDeadLetterNotFound,
+			code: http.StatusNotFound,
+		},
+		"error, internal": {
+			err:  errors.New("db error"),
+			code: http.StatusInternalServerError,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			inv.On("DeleteDeadLetter", contextMatcher(), "1").Return(tc.err)
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodDelete,
+				"http://localhost/api/internal/v1/inventory/tenants/tenant/dead_letters/1",
+				"", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiInternalReplayDeadLetter(t *testing.T) {
+	t.Parallel()
+
+	derr := &events.DeliveryError{
+		Err: errors.New("webhook responded with 500 Internal Server Error"),
+	}
+	testCases := map[string]struct {
+		err error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			code: http.StatusNoContent,
+		},
+		"error, not found": {
+			err:  store.ErrDeadLetterNotFound,
+			code: http.StatusNotFound,
+			resp: ToJson(restError(store.ErrDeadLetterNotFound.Error())),
+		},
+		"error, delivery": {
+			err:  derr,
+			code: http.StatusBadGateway,
+			resp: ToJson(restError(derr.Error())),
+		},
+		"error, internal": {
+			err:  errors.New("db error"),
+			code: http.StatusInternalServerError,
+			resp: ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			inv.On("ReplayDeadLetter", contextMatcher(), "1").Return(tc.err)
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPost,
+				"http://localhost/api/internal/v1/inventory/tenants/tenant/dead_letters/1/replay",
+				"", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			if tc.resp != "" {
+				recorded.BodyIs(tc.resp)
+			}
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiInternalReplayDeadLetters(t *testing.T) {
+	t.Parallel()
+
+	result := &model.DeadLettersReplay{Replayed: 2, Failed: 1}
+	inv := minventory.InventoryApp{}
+	inv.On("ReplayDeadLetters", contextMatcher()).Return(result, nil).Once()
+	inv.On("ReplayDeadLetters", contextMatcher()).
+		Return(nil, errors.New("db error")).Once()
+
+	api := makeMockApiHandler(t, &inv)
+	req := makeReq(http.MethodPost,
+		"http://localhost/api/internal/v1/inventory/tenants/tenant/dead_letters/replay",
+		"", nil)
+	recorded := test.RunRequest(t, api, req)
+	recorded.CodeIs(http.StatusOK)
+	recorded.BodyIs(ToJson(result))
+
+	recorded = test.RunRequest(t, api, req)
+	recorded.CodeIs(http.StatusInternalServerError)
+	inv.AssertExpectations(t)
+}
+
+func TestApiInternalWarmUpCatalog(t *testing.T) {
+	t.Parallel()
+
+	catalog := &model.Catalog{
+		Attributes: []model.FilterAttribute{
+			{Name: "mac", Scope: "inventory", Count: 2},
+		},
+		Groups: []model.GroupCount{
+			{Group: "foo", Count: 2},
+		},
+		Devices:    3,
+		ComputedTs: time.Now(),
+	}
+	testCases := map[string]struct {
+		catalog *model.Catalog
+		err     error
+
+		code int
+		body string
+	}{
+		"ok": {
+			catalog: catalog,
+			code:    http.StatusOK,
+			body:    ToJson(catalog),
+		},
+		"error": {
+			err:  errors.New("db error"),
+			code: http.StatusInternalServerError,
+			body: ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			inv.On("WarmUpCatalog", contextMatcher()).Return(tc.catalog, tc.err)
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPost,
+				"http://localhost/api/internal/v1/inventory/tenants/tenant/catalog/warmup",
+				"", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.body)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiGetDeviceChildren(t *testing.T) {
+	t.Parallel()
+
+	children := []model.Device{{ID: "1"}, {ID: "2"}}
+	testCases := map[string]struct {
+		query string
+
+		callInv bool
+		skip    int
+		err     error
+
+		code  int
+		total string
+		resp  string
+	}{
+		"ok": {
+			callInv: true,
+			code:    http.StatusOK,
+			total:   "22",
+			resp:    ToJson(children),
+		},
+		"ok, page": {
+			query:   "?page=2",
+			callInv: true,
+			skip:    20,
+			code:    http.StatusOK,
+			total:   "22",
+			resp:    ToJson(children),
+		},
+		"error, pagination": {
+			query: "?page=foo",
+			code:  http.StatusBadRequest,
+			resp:  ToJson(restError(utils.MsgQueryParmInvalid("page"))),
+		},
+		"error, not found": {
+			callInv: true,
+			err:     store.ErrDevNotFound,
+			code:    http.StatusNotFound,
+			resp:    ToJson(restError(store.ErrDevNotFound.Error())),
+		},
+		"error, internal": {
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				inv.On("ListDeviceChildren", contextMatcher(),
+					model.DeviceID("gw"), tc.skip, 20,
+				).Return(children, 22, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodGet,
+				"http://localhost/api/0.1.0/devices/gw/children"+tc.query,
+				"", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			if tc.total != "" {
+				recorded.HeaderIs(hdrTotalCount, tc.total)
+			}
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiGetDeviceCompleteness(t *testing.T) {
+	t.Parallel()
+
+	completeness := &model.DeviceCompleteness{
+		DeviceID:     "1",
+		Completeness: 0.5,
+		Missing: []model.SelectAttribute{{
+			Scope:     model.AttrScopeInventory,
+			Attribute: "os",
+		}},
+	}
+	testCases := map[string]struct {
+		completeness *model.DeviceCompleteness
+		err          error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			completeness: completeness,
+			code:         http.StatusOK,
+			resp:         ToJson(completeness),
+		},
+		"error, not found": {
+			code: http.StatusNotFound,
+			resp: ToJson(restError(store.ErrDevNotFound.Error())),
+		},
+		"error, internal": {
+			err:  errors.New("db error"),
+			code: http.StatusInternalServerError,
+			resp: ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			inv.On("GetDeviceCompleteness", contextMatcher(),
+				model.DeviceID("1"),
+			).Return(tc.completeness, tc.err)
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodGet,
+				"http://localhost/api/0.1.0/devices/1/completeness",
+				"", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiApplyDeviceTemplate(t *testing.T) {
+	t.Parallel()
+
+	site := model.SelectAttribute{Scope: model.AttrScopeTags, Attribute: "site"}
+	template := &model.DeviceTemplate{
+		DeviceID: "1",
+		Attributes: model.DeviceAttributes{
+			{Scope: model.AttrScopeTags, Name: "site", Value: "oslo"},
+		},
+		Applied: &model.DeviceTemplateResult{
+			Updated: 1,
+			Errors: []model.DeviceTemplateError{
+				{DeviceID: "3", Error: "device not found"},
+			},
+		},
+	}
+	testCases := map[string]struct {
+		body interface{}
+
+		callInv  bool
+		template *model.DeviceTemplate
+		err      error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			body: model.DeviceTemplateRequest{
+				Attributes: []model.SelectAttribute{site},
+				DeviceIDs:  []model.DeviceID{"2", "3"},
+			},
+			callInv:  true,
+			template: template,
+			code:     http.StatusOK,
+			resp:     ToJson(template),
+		},
+		"error, malformed body": {
+			body: "site",
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("failed to decode request body: " +
+				"json: cannot unmarshal string into Go value of type " +
+				"model.DeviceTemplateRequest")),
+		},
+		"error, invalid request": {
+			body: model.DeviceTemplateRequest{
+				DeviceIDs: []model.DeviceID{"2"},
+				Group:     "lab",
+			},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError(
+				"either device IDs or a group can be provided, not both")),
+		},
+		"error, not found": {
+			body:    model.DeviceTemplateRequest{},
+			callInv: true,
+			code:    http.StatusNotFound,
+			resp:    ToJson(restError(store.ErrDevNotFound.Error())),
+		},
+		"error, attribute not found": {
+			body: model.DeviceTemplateRequest{
+				Attributes: []model.SelectAttribute{site},
+			},
+			callInv: true,
+			err: errors.Wrap(inventory.ErrTemplateAttributeNotFound,
+				"tags/site"),
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("tags/site: " +
+				inventory.ErrTemplateAttributeNotFound.Error())),
+		},
+		"error, scope write forbidden": {
+			body:    model.DeviceTemplateRequest{Group: "lab"},
+			callInv: true,
+			err: errors.Wrap(inventory.ErrScopeWriteForbidden,
+				"scope inventory"),
+			code: http.StatusForbidden,
+			resp: ToJson(restError("scope inventory: " +
+				inventory.ErrScopeWriteForbidden.Error())),
+		},
+		"error, internal": {
+			body:    model.DeviceTemplateRequest{Group: "lab"},
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				inv.On("ApplyDeviceTemplate", contextMatcher(),
+					model.DeviceID("1"),
+					mock.AnythingOfType("model.DeviceTemplateRequest"),
+				).Return(tc.template, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPost,
+				"http://localhost/api/0.1.0/devices/1/template",
+				"", tc.body)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiGetDeviceMerged(t *testing.T) {
+	t.Parallel()
+
+	ts := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+	device := func() *model.Device {
+		return &model.Device{
+			ID: "1",
+			Attributes: model.DeviceAttributes{
+				{Scope: "inventory", Name: "ip", Value: "10.0.0.1"},
+				{Scope: "inventory:gateway", Name: "ip", Value: "10.0.0.2"},
+				{Scope: "inventory:gateway", Name: "rssi", Value: -70.0},
+			},
+			Sources: map[string]model.AttributeSource{
+				"inventory":         {Type: "device", ID: "1", Timestamp: ts},
+				"inventory:gateway": {Type: "device", ID: "1", Timestamp: ts.Add(time.Minute)},
+			},
+		}
+	}
+	testCases := map[string]struct {
+		query    string
+		device   *model.Device
+		callInv  bool
+		code     int
+		response interface{}
+	}{
+		"ok, latest agent": {
+			device:  device(),
+			callInv: true,
+			code:    http.StatusOK,
+			response: model.Device{
+				ID: "1",
+				Attributes: model.DeviceAttributes{
+					{Scope: "inventory", Name: "ip", Value: "10.0.0.2", Agent: "gateway"},
+					{Scope: "inventory", Name: "rssi", Value: -70.0, Agent: "gateway"},
+				},
+				Sources: device().Sources,
+			},
+		},
+		"ok, precedence": {
+			query:   "?precedence=client,gateway",
+			device:  device(),
+			callInv: true,
+			code:    http.StatusOK,
+			response: model.Device{
+				ID: "1",
+				Attributes: model.DeviceAttributes{
+					{Scope: "inventory", Name: "ip", Value: "10.0.0.1", Agent: "client"},
+					{Scope: "inventory", Name: "rssi", Value: -70.0, Agent: "gateway"},
+				},
+				Sources: device().Sources,
+			},
+		},
+		"error, invalid precedence": {
+			query: "?precedence=client,",
+			code:  http.StatusBadRequest,
+			response: restError(`invalid agent name "": must be up to 32 ` +
+				`lower case letters, digits and underscores`),
+		},
+		"error, not found": {
+			callInv:  true,
+			code:     http.StatusNotFound,
+			response: restError(store.ErrDevNotFound.Error()),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				inv.On("GetDevice", contextMatcher(), model.DeviceID("1")).
+					Return(tc.device, nil)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodGet,
+				"http://localhost/api/0.1.0/devices/1/merged"+tc.query,
+				"", nil)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(ToJson(tc.response))
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiExportGroupDevices(t *testing.T) {
+	t.Parallel()
+
+	ts := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+	devices := []model.Device{{
+		ID: "1",
+		Attributes: model.DeviceAttributes{
+			{Scope: "inventory", Name: "mac", Value: "00:01"},
+			{Scope: "inventory", Name: "cpus", Value: 4.0},
+			{Scope: "system", Name: "created_ts", Value: ts},
+		},
+	}, {
+		ID: "2",
+		Attributes: model.DeviceAttributes{
+			{Scope: "inventory", Name: "mac", Value: "a,b"},
+			{Scope: "inventory", Name: "cpus", Value: []interface{}{1.0, 2.0}},
+		},
+	}}
+	attributes := []model.SelectAttribute{
+		{Scope: "inventory", Attribute: "mac"},
+		{Scope: "inventory", Attribute: "cpus"},
+		{Scope: "system", Attribute: "created_ts"},
+	}
+	testCases := map[string]struct {
+		query string
+
+		callInv   bool
+		total     int
+		streamErr error
+
+		code        int
+		contentType string
+		body        string
+	}{
+		"ok, csv": {
+			query:   "?attributes=mac,cpus&attributes=system/created_ts",
+			callInv: true,
+			total:   2,
+
+			code:        http.StatusOK,
+			contentType: contentTypeCSV,
+			body: "id,inventory/mac,inventory/cpus,system/created_ts\n" +
+				"1,00:01,4,2021-06-01T10:00:00Z\n" +
+				"2,\"a,b\",\"[1,2]\",\n",
+		},
+		"ok, csv in time zone": {
+			query:   "?tz=Europe/Oslo&attributes=mac,cpus&attributes=system/created_ts",
+			callInv: true,
+			total:   1,
+
+			code:        http.StatusOK,
+			contentType: contentTypeCSV,
+			body: "id,inventory/mac,inventory/cpus,system/created_ts\n" +
+				"1,00:01,4,2021-06-01T12:00:00+02:00\n",
+		},
+		"ok, ndjson in UTC": {
+			query:   "?tz=Europe/Oslo&format=ndjson&attributes=mac,cpus,system/created_ts",
+			callInv: true,
+			total:   1,
+
+			code:        http.StatusOK,
+			contentType: contentTypeNDJSON,
+			body:        ToJson(devices[0]) + "\n",
+		},
+		"error, time zone": {
+			query: "?tz=Mars/Olympus&attributes=mac",
+
+			code: http.StatusBadRequest,
+			body: ToJson(restError(utils.MsgQueryParmInvalid("tz"))),
+		},
+		"ok, ndjson": {
+			query:   "?format=ndjson&attributes=mac,inventory/cpus,system/created_ts",
+			callInv: true,
+			total:   2,
+
+			code:        http.StatusOK,
+			contentType: contentTypeNDJSON,
+			body:        ToJson(devices[0]) + "\n" + ToJson(devices[1]) + "\n",
+		},
+		"error, format": {
+			query: "?format=xml&attributes=mac",
+
+			code: http.StatusBadRequest,
+			body: ToJson(restError(utils.MsgQueryParmOneOf("format",
+				[]string{"csv", "ndjson"}))),
+		},
+		"error, no attributes": {
+			code: http.StatusBadRequest,
+			body: ToJson(restError(utils.MsgQueryParmMissing("attributes"))),
+		},
+		"error, empty attribute": {
+			query: "?attributes=mac,",
+
+			code: http.StatusBadRequest,
+			body: ToJson(restError(utils.MsgQueryParmInvalid("attributes"))),
+		},
+		"error, group not found": {
+			query:   "?attributes=mac,cpus,system/created_ts",
+			callInv: true,
+
+			code: http.StatusNotFound,
+			body: ToJson(restError(store.ErrGroupNotFound.Error())),
+		},
+		"error, internal": {
+			query:     "?attributes=mac,cpus,system/created_ts",
+			callInv:   true,
+			streamErr: errors.New("db error"),
+
+			code: http.StatusInternalServerError,
+			body: ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := &minventory.InventoryApp{}
+			if tc.callInv {
+				var stream *store.DeviceStream
+				if tc.streamErr == nil {
+					stream = mockDeviceStream(devices[:tc.total])
+				}
+				inv.On("CheckLimits", contextMatcher(), mock.AnythingOfType("model.Limits")).
+					Return(nil, nil).Maybe()
+				inv.On("StreamDevices", contextMatcher(), store.ListQuery{
+					GroupName:  "foo",
+					Attributes: attributes,
+				}).Return(stream, tc.total, tc.streamErr)
+			}
+
+			req := makeReq(http.MethodGet,
+				"http://localhost/api/0.1.0/groups/foo/devices/export"+tc.query,
+				"", nil)
+			recorded := test.RunRequest(t, makeMockApiHandler(t, inv), req)
+			recorded.CodeIs(tc.code)
+			assert.Equal(t, tc.body, recorded.Recorder.Body.String())
+			if tc.contentType != "" {
+				recorded.HeaderIs("Content-Type", tc.contentType)
+				recorded.HeaderIs(hdrTotalCount, strconv.Itoa(tc.total))
+			}
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCSVValueTimestamps(t *testing.T) {
+	t.Parallel()
+
+	loc, err := time.LoadLocation("America/New_York")
+	if !assert.NoError(t, err) {
+		return
+	}
+	ts := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, "2021-01-01T12:00:00Z", csvValue(ts, time.UTC))
+	assert.Equal(t, "2021-01-01T07:00:00-05:00", csvValue(ts, loc))
+	// timestamps read from the database
+	assert.Equal(t, "2021-01-01T07:00:00-05:00",
+		csvValue(primitive.NewDateTimeFromTime(ts), loc))
+}
+
+func TestApiInternalDeploymentFinished(t *testing.T) {
+	t.Parallel()
+
+	diffs := []model.DeploymentDiff{{
+		DeploymentID: "d1",
+		DeviceID:     "1",
+		Changes: []model.AttributeChange{{
+			Scope:  "inventory",
+			Name:   "artifact_name",
+			Before: "release-1",
+			After:  "release-2",
+		}},
+	}}
+	testCases := map[string]struct {
+		body string
+
+		callInv bool
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			body:    `{"devices": ["1", "2"]}`,
+			callInv: true,
+			code:    http.StatusOK,
+			resp:    ToJson(diffs),
+		},
+		"error, no devices": {
+			body: `{"devices": []}`,
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("devices: cannot be blank.")),
+		},
+		"error, body": {
+			body: `{"devices": "1"}`,
+			code: http.StatusBadRequest,
+		},
+		"error, internal": {
+			body:    `{"devices": ["1", "2"]}`,
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				inv.On("DeploymentFinished", contextMatcher(), "d1",
+					[]model.DeviceID{"1", "2"},
+				).Return(diffs, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPost,
+				"http://localhost/api/internal/v1/inventory/tenants/tenant/deployments/d1/finished",
+				"", json.RawMessage(tc.body))
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			if tc.resp != "" {
+				recorded.BodyIs(tc.resp)
+			}
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiInternalDeviceEligibility(t *testing.T) {
+	t.Parallel()
+
+	req := model.EligibilityRequest{
+		Conditions: []model.EligibilityCondition{{
+			Scope:     "inventory",
+			Attribute: "rootfs_free_kb",
+			Type:      model.EligibilityGte,
+			Value:     float64(4096),
+		}},
+	}
+	res := &model.DeviceEligibility{
+		DeviceID: "1",
+		Failed: []model.EligibilityFailure{{
+			Condition: req.Conditions[0],
+			Actual:    float64(1024),
+		}},
+	}
+	testCases := map[string]struct {
+		body string
+
+		callInv bool
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			body: `{"conditions": [{"scope": "inventory", ` +
+				`"attribute": "rootfs_free_kb", "type": "$gte", "value": 4096}]}`,
+			callInv: true,
+			code:    http.StatusOK,
+			resp:    ToJson(res),
+		},
+		"error, no conditions": {
+			body: `{"conditions": []}`,
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("conditions: cannot be blank.")),
+		},
+		"error, body": {
+			body: `{"conditions": "rootfs_free_kb"}`,
+			code: http.StatusBadRequest,
+		},
+		"error, device not found": {
+			body: `{"conditions": [{"scope": "inventory", ` +
+				`"attribute": "rootfs_free_kb", "type": "$gte", "value": 4096}]}`,
+			callInv: true,
+			err:     store.ErrDevNotFound,
+			code:    http.StatusNotFound,
+			resp:    ToJson(restError(store.ErrDevNotFound.Error())),
+		},
+		"error, internal": {
+			body: `{"conditions": [{"scope": "inventory", ` +
+				`"attribute": "rootfs_free_kb", "type": "$gte", "value": 4096}]}`,
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				var ret *model.DeviceEligibility
+				if tc.err == nil {
+					ret = res
+				}
+				inv.On("CheckDeviceEligibility", contextMatcher(),
+					model.DeviceID("1"), req,
+				).Return(ret, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPost,
+				"http://localhost/api/internal/v1/inventory/tenants/tenant/devices/1/eligibility",
+				"", json.RawMessage(tc.body))
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			if tc.resp != "" {
+				recorded.BodyIs(tc.resp)
+			}
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiInternalExplainSearch(t *testing.T) {
+	t.Parallel()
+
+	userToken := makeJWTAuthHeader(`{"sub": "user", "mender.user": true}`)
+	plan := &model.QueryPlan{
+		Stage:        "LIMIT",
+		Indexes:      []string{"system_group"},
+		Returned:     10,
+		KeysExamined: 10,
+		DocsExamined: 10,
+	}
+	testCases := map[string]struct {
+		auth string
+		body interface{}
+
+		callInv bool
+		plan    *model.QueryPlan
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			auth:    userToken,
+			body:    model.SearchParams{Page: 1, PerPage: 10},
+			callInv: true,
+			plan:    plan,
+			code:    http.StatusOK,
+			resp:    ToJson(plan),
+		},
+		"error, no token": {
+			body: model.SearchParams{Page: 1, PerPage: 10},
+			code: http.StatusUnauthorized,
+			resp: ToJson(restError(ErrUserTokenRequired.Error())),
+		},
+		"error, device token": {
+			auth: makeJWTAuthHeader(`{"sub": "device", "mender.device": true}`),
+			body: model.SearchParams{Page: 1, PerPage: 10},
+			code: http.StatusUnauthorized,
+			resp: ToJson(restError(ErrUserTokenRequired.Error())),
+		},
+		"error, invalid search": {
+			auth: userToken,
+			body: model.SearchParams{Page: 1, PerPage: 10, MaxTimeMS: -1},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("max_time_ms: must be no less than 0.")),
+		},
+		"error, internal": {
+			auth:    userToken,
+			body:    model.SearchParams{Page: 1, PerPage: 10},
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				inv.On("ExplainSearchDevices",
+					contextMatcher(),
+					mock.AnythingOfType("model.SearchParams"),
+				).Return(tc.plan, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPost,
+				"http://localhost/api/internal/v2/inventory/tenants/tenant/debug/explain",
+				tc.auth, tc.body)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiGetDevicesByIDs(t *testing.T) {
+	t.Parallel()
+
+	devs := []model.Device{{ID: "2"}, {ID: "1"}}
+	testCases := map[string]struct {
+		body interface{}
+
+		callInv bool
+		devs    []model.Device
+		err     error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			body:    model.DevicesGetRequest{IDs: []model.DeviceID{"2", "1"}},
+			callInv: true,
+			devs:    devs,
+			code:    http.StatusOK,
+			resp:    ToJson(devs),
+		},
+		"ok, none found": {
+			body:    model.DevicesGetRequest{IDs: []model.DeviceID{"3"}},
+			callInv: true,
+			devs:    []model.Device{},
+			code:    http.StatusOK,
+			resp:    ToJson([]model.Device{}),
+		},
+		"error, malformed body": {
+			body: "foo",
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("failed to decode request body: " +
+				"json: cannot unmarshal string into Go value of type model.DevicesGetRequest")),
+		},
+		"error, no IDs": {
+			body: model.DevicesGetRequest{},
+			code: http.StatusBadRequest,
+			resp: ToJson(restError("ids: cannot be blank.")),
+		},
+		"error, internal": {
+			body:    model.DevicesGetRequest{IDs: []model.DeviceID{"1"}},
+			callInv: true,
+			err:     errors.New("db error"),
+			code:    http.StatusInternalServerError,
+			resp:    ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := minventory.InventoryApp{}
+			if tc.callInv {
+				inv.On("GetDevicesByIDs",
+					contextMatcher(),
+					mock.AnythingOfType("model.DevicesGetRequest"),
+				).Return(tc.devs, tc.err)
+			}
+
+			api := makeMockApiHandler(t, &inv)
+			req := makeReq(http.MethodPost,
+				"http://localhost/api/0.1.0/devices/get", "", tc.body)
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+			inv.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApiGetAttributeGraph(t *testing.T) {
+	t.Parallel()
+
+	graph := &model.AttributeGraph{
+		Name:          "gateway_id",
+		Scope:         model.AttrScopeInventory,
+		Devices:       3,
+		TotalClusters: 1,
+		Clusters: []model.AttributeCluster{{
+			Values:     []interface{}{"gw-1", "gw-2"},
+			ValueCount: 2,
+			Count:      3,
+			Devices:    []model.DeviceID{"1", "2", "3"},
+		}},
+	}
+	testCases := map[string]struct {
+		query string
+
+		callGraph bool
+		limit     int
+		err       error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			callGraph: true,
+			limit:     model.AttributeClustersLimitDefault,
+			code:      http.StatusOK,
+			resp:      ToJson(graph),
+		},
+		"ok, limit": {
+			query:     "?limit=5",
+			callGraph: true,
+			limit:     5,
+			code:      http.StatusOK,
+			resp:      ToJson(graph),
+		},
+		"error, limit": {
+			query: "?limit=1000",
+			code:  http.StatusBadRequest,
+			resp:  ToJson(restError(utils.MsgQueryParmLimit("limit"))),
+		},
+		"error, internal": {
+			callGraph: true,
+			limit:     model.AttributeClustersLimitDefault,
+			err:       errors.New("db error"),
+			code:      http.StatusInternalServerError,
+			resp:      ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := &minventory.InventoryApp{}
+			defer inv.AssertExpectations(t)
+			if tc.callGraph {
+				var res *model.AttributeGraph
+				if tc.err == nil {
+					res = graph
+				}
+				inv.On("GetAttributeGraph", contextMatcher(),
+					model.AttrScopeInventory, "gateway_id", tc.limit,
+				).Return(res, tc.err)
+			}
+			apih := makeMockApiHandler(t, inv)
+
+			req := makeReq("GET",
+				"http://1.2.3.4"+apiUrlManagementV2+
+					"/graph/attributes/inventory/gateway_id"+tc.query, "", nil)
+			recorded := test.RunRequest(t, apih, req)
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+		})
+	}
+}
+
+func TestApiGetAttributePivot(t *testing.T) {
+	t.Parallel()
+
+	deviceType := model.SelectAttribute{
+		Scope:     model.AttrScopeInventory,
+		Attribute: "device_type",
+	}
+	artifactName := model.SelectAttribute{
+		Scope:     model.AttrScopeInventory,
+		Attribute: "artifact_name",
+	}
+	pivot := &model.AttributePivot{
+		Rows: model.PivotAxis{
+			Scope:  model.AttrScopeInventory,
+			Name:   "device_type",
+			Values: []interface{}{"rpi4", "rpi3"},
+		},
+		Columns: model.PivotAxis{
+			Scope:  model.AttrScopeInventory,
+			Name:   "artifact_name",
+			Values: []interface{}{"release-2", "release-1"},
+		},
+		Counts:  [][]int{{5, 2}, {0, 3}},
+		Devices: 10,
+	}
+	testCases := map[string]struct {
+		query string
+
+		callPivot bool
+		q         store.ListQuery
+		column    model.SelectAttribute
+		limit     int
+		err       error
+
+		code int
+		resp string
+	}{
+		"ok": {
+			query:     "?rows=device_type&columns=inventory/artifact_name",
+			callPivot: true,
+			q:         store.ListQuery{Filters: []store.Filter{}},
+			column:    artifactName,
+			limit:     model.AttributePivotLimitDefault,
+			code:      http.StatusOK,
+			resp:      ToJson(pivot),
+		},
+		"ok, group and filters": {
+			query: "?rows=device_type&columns=system/group&group=prod" +
+				"&status=accepted&limit=5",
+			callPivot: true,
+			q: store.ListQuery{
+				Filters: []store.Filter{{
+					AttrName:  "status",
+					AttrScope: model.AttrScopeInventory,
+					Value:     "accepted",
+					Operator:  store.Eq,
+				}},
+				GroupName: "prod",
+			},
+			column: model.SelectAttribute{
+				Scope:     model.AttrScopeSystem,
+				Attribute: model.AttrNameGroup,
+			},
+			limit: 5,
+			code:  http.StatusOK,
+			resp:  ToJson(pivot),
+		},
+		"error, missing rows": {
+			query: "?columns=artifact_name",
+			code:  http.StatusBadRequest,
+			resp:  ToJson(restError(utils.MsgQueryParmMissing("rows"))),
+		},
+		"error, invalid columns": {
+			query: "?rows=device_type&columns=inventory/",
+			code:  http.StatusBadRequest,
+			resp:  ToJson(restError(utils.MsgQueryParmInvalid("columns"))),
+		},
+		"error, limit": {
+			query: "?rows=device_type&columns=artifact_name&limit=1000",
+			code:  http.StatusBadRequest,
+			resp:  ToJson(restError(utils.MsgQueryParmLimit("limit"))),
+		},
+		"error, internal": {
+			query:     "?rows=device_type&columns=artifact_name",
+			callPivot: true,
+			q:         store.ListQuery{Filters: []store.Filter{}},
+			column:    artifactName,
+			limit:     model.AttributePivotLimitDefault,
+			err:       errors.New("db error"),
+			code:      http.StatusInternalServerError,
+			resp:      ToJson(restError("internal error")),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := &minventory.InventoryApp{}
+			defer inv.AssertExpectations(t)
+			inv.On("CheckLimits", contextMatcher(), mock.AnythingOfType("model.Limits")).
+				Return(nil, nil).Maybe()
+			if tc.callPivot {
+				var res *model.AttributePivot
+				if tc.err == nil {
+					res = pivot
+				}
+				inv.On("GetAttributePivot", contextMatcher(),
+					tc.q, deviceType, tc.column, tc.limit,
+				).Return(res, tc.err)
+			}
+			apih := makeMockApiHandler(t, inv)
+
+			req := makeReq("GET",
+				"http://1.2.3.4"+apiUrlManagementV2+
+					"/pivot/attributes"+tc.query, "", nil)
+			recorded := test.RunRequest(t, apih, req)
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.resp)
+		})
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/log"
+	u "github.com/mendersoftware/go-lib-micro/rest_utils"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+)
+
+var (
+	ErrAttributeHidden   = errors.New("access to the attribute is forbidden")
+	ErrAttributeReadOnly = errors.New("the attribute is read-only")
+)
+
+// AttributeRef names an attribute, or all the attributes of a scope if
+// the name is empty; it is written as scope/name or scope.
+type AttributeRef struct {
+	Scope string
+	Name  string
+}
+
+// ParseAttributeRef parses the scope/name or scope notation.
+func ParseAttributeRef(s string) (AttributeRef, error) {
+	parts := strings.SplitN(s, queryParamScopeSeparator, 2)
+	ref := AttributeRef{Scope: parts[0]}
+	if len(parts) == 2 {
+		ref.Name = parts[1]
+		if ref.Name == "" {
+			return ref, errors.Errorf("invalid attribute: %q", s)
+		}
+	}
+	if ref.Scope == "" {
+		return ref, errors.Errorf("invalid attribute: %q", s)
+	}
+	return ref, nil
+}
+
+func (a AttributeRef) String() string {
+	if a.Name == "" {
+		return a.Scope
+	}
+	return a.Scope + queryParamScopeSeparator + a.Name
+}
+
+// Matches returns true if the reference covers the attribute; the
+// references to a scope also cover the sub-scopes of its agents.
+func (a AttributeRef) Matches(scope, name string) bool {
+	if a.Scope != scope {
+		if base, _ := model.SplitAgentScope(scope); a.Scope != base {
+			return false
+		}
+	}
+	return a.Name == "" || a.Name == name
+}
+
+// AttributeRules restricts the access of a role to the attributes.
+type AttributeRules struct {
+	// Hidden attributes are left out of the responses and cannot be
+	// referenced by the requests, e.g. in the search filters.
+	Hidden []AttributeRef
+	// ReadOnly attributes, as well as the hidden ones, cannot be modified.
+	ReadOnly []AttributeRef
+}
+
+func matchesAny(refs []AttributeRef, scope, name string) bool {
+	for _, ref := range refs {
+		if ref.Matches(scope, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// AttributeAccess is the access of a user to the attributes: an attribute
+// is only restricted if it is restricted for all the roles of the user.
+// A nil AttributeAccess grants full access.
+type AttributeAccess struct {
+	rules []AttributeRules
+}
+
+// Hidden returns true if the attribute is hidden from the user.
+func (a *AttributeAccess) Hidden(scope, name string) bool {
+	if a == nil {
+		return false
+	}
+	for _, rules := range a.rules {
+		if !matchesAny(rules.Hidden, scope, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// HidesAny returns true if some of the attributes may be hidden from
+// the user, i.e. each of the roles hides some.
+func (a *AttributeAccess) HidesAny() bool {
+	if a == nil {
+		return false
+	}
+	for _, rules := range a.rules {
+		if len(rules.Hidden) == 0 {
+			return false
+		}
+	}
+	return len(a.rules) > 0
+}
+
+// ReadOnly returns true if the user cannot modify the attribute.
+func (a *AttributeAccess) ReadOnly(scope, name string) bool {
+	if a == nil {
+		return false
+	}
+	for _, rules := range a.rules {
+		if !matchesAny(rules.Hidden, scope, name) &&
+			!matchesAny(rules.ReadOnly, scope, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// FilterAttributes returns the attributes visible to the user.
+func (a *AttributeAccess) FilterAttributes(attrs model.DeviceAttributes) model.DeviceAttributes {
+	if a == nil || attrs == nil {
+		return attrs
+	}
+	visible := make(model.DeviceAttributes, 0, len(attrs))
+	for _, attr := range attrs {
+		if !a.Hidden(attr.Scope, attr.Name) {
+			visible = append(visible, attr)
+		}
+	}
+	return visible
+}
+
+// FilterDevice removes the attributes hidden from the user from
+// the device.
+func (a *AttributeAccess) FilterDevice(dev *model.Device) {
+	if a == nil || dev == nil {
+		return
+	}
+	dev.Attributes = a.FilterAttributes(dev.Attributes)
+	dev.Highlights = a.FilterAttributes(dev.Highlights)
+	for scope := range dev.Sources {
+		// only the references to the whole scope match the empty name
+		if a.Hidden(scope, "") {
+			delete(dev.Sources, scope)
+		}
+	}
+	if a.Hidden(model.AttrScopeTelemetry, "") {
+		dev.Telemetry = nil
+	}
+}
+
+type attributeAccessContextKey struct{}
+
+func withAttributeAccess(ctx context.Context, access *AttributeAccess) context.Context {
+	return context.WithValue(ctx, attributeAccessContextKey{}, access)
+}
+
+// attributeAccessFromContext returns the access of the user to
+// the attributes; nil if it is not restricted.
+func attributeAccessFromContext(ctx context.Context) *AttributeAccess {
+	access, _ := ctx.Value(attributeAccessContextKey{}).(*AttributeAccess)
+	return access
+}
+
+// hideAttributes removes the attributes hidden from the user from
+// the devices.
+func hideAttributes(ctx context.Context, devs []model.Device) {
+	access := attributeAccessFromContext(ctx)
+	if access == nil {
+		return
+	}
+	for i := range devs {
+		access.FilterDevice(&devs[i])
+	}
+}
+
+// checkAttributesVisible responds with 403 and returns false if any of
+// the attributes referenced by the request is hidden from the user.
+func checkAttributesVisible(
+	w rest.ResponseWriter,
+	r *rest.Request,
+	attrs []model.SelectAttribute,
+) bool {
+	access := attributeAccessFromContext(r.Context())
+	for _, attr := range attrs {
+		if access.Hidden(attr.Scope, attr.Attribute) {
+			l := log.FromContext(r.Context())
+			u.RestErrWithLog(w, r, l,
+				errors.Wrap(ErrAttributeHidden,
+					attr.Scope+queryParamScopeSeparator+attr.Attribute),
+				http.StatusForbidden,
+			)
+			return false
+		}
+	}
+	return true
+}
+
+// checkAttributesWritable responds with 403 and returns false if any of
+// the attributes is read-only for the user.
+func checkAttributesWritable(
+	w rest.ResponseWriter,
+	r *rest.Request,
+	attrs []model.SelectAttribute,
+) bool {
+	access := attributeAccessFromContext(r.Context())
+	for _, attr := range attrs {
+		if access.ReadOnly(attr.Scope, attr.Attribute) {
+			l := log.FromContext(r.Context())
+			u.RestErrWithLog(w, r, l,
+				errors.Wrap(ErrAttributeReadOnly,
+					attr.Scope+queryParamScopeSeparator+attr.Attribute),
+				http.StatusForbidden,
+			)
+			return false
+		}
+	}
+	return true
+}
+
+// searchAttributes returns the attributes referenced by the search.
+func searchAttributes(params *model.SearchParams) []model.SelectAttribute {
+	attrs := make([]model.SelectAttribute, 0,
+		len(params.Filters)+len(params.Sort)+len(params.Attributes))
+	for _, f := range params.Filters {
+		attrs = append(attrs, model.SelectAttribute{
+			Scope: f.Scope, Attribute: f.Attribute,
+		})
+	}
+	for _, s := range params.Sort {
+		attrs = append(attrs, model.SelectAttribute{
+			Scope: s.Scope, Attribute: s.Attribute,
+		})
+	}
+	return append(attrs, params.Attributes...)
+}
+
+// listQueryAttributes returns the attributes referenced by the filters
+// and the sorting of the devices listing.
+func listQueryAttributes(filters []store.Filter, sort []store.Sort) []model.SelectAttribute {
+	attrs := make([]model.SelectAttribute, 0, len(filters)+len(sort))
+	for _, f := range filters {
+		attrs = append(attrs, model.SelectAttribute{
+			Scope: f.AttrScope, Attribute: f.AttrName,
+		})
+	}
+	for _, s := range sort {
+		attrs = append(attrs, model.SelectAttribute{
+			Scope: s.AttrScope, Attribute: s.AttrName,
+		})
+	}
+	return attrs
+}
@@ -0,0 +1,209 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/mendersoftware/go-lib-micro/requestid"
+	"github.com/stretchr/testify/assert"
+
+	minventory "github.com/mendersoftware/inventory/inv/mocks"
+	"github.com/mendersoftware/inventory/model"
+)
+
+var attributesPolicy = AuthzPolicy{
+	Attributes: map[string]AttributeRules{
+		"helpdesk": {
+			Hidden: []AttributeRef{
+				{Scope: "inventory", Name: "owner_email"},
+				{Scope: "warranty"},
+			},
+		},
+		"operator": {
+			Hidden:   []AttributeRef{{Scope: "inventory", Name: "owner_email"}},
+			ReadOnly: []AttributeRef{{Scope: "warranty"}},
+		},
+	},
+}
+
+func TestParseAttributeRef(t *testing.T) {
+	ref, err := ParseAttributeRef("inventory/owner_email")
+	assert.NoError(t, err)
+	assert.Equal(t, AttributeRef{Scope: "inventory", Name: "owner_email"}, ref)
+	assert.Equal(t, "inventory/owner_email", ref.String())
+
+	ref, err = ParseAttributeRef("warranty")
+	assert.NoError(t, err)
+	assert.Equal(t, AttributeRef{Scope: "warranty"}, ref)
+	assert.Equal(t, "warranty", ref.String())
+
+	for _, s := range []string{"", "/name", "warranty/"} {
+		_, err = ParseAttributeRef(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestAttributeAccess(t *testing.T) {
+	assert.Nil(t, attributesPolicy.AttributeAccess(nil))
+	assert.Nil(t, attributesPolicy.AttributeAccess([]string{"admin"}))
+	assert.Nil(t, attributesPolicy.AttributeAccess([]string{"helpdesk", "admin"}))
+
+	helpdesk := attributesPolicy.AttributeAccess([]string{"helpdesk"})
+	assert.True(t, helpdesk.Hidden("inventory", "owner_email"))
+	assert.True(t, helpdesk.Hidden("warranty", "expires"))
+	assert.True(t, helpdesk.Hidden("inventory:gateway", "owner_email"))
+	assert.True(t, helpdesk.Hidden("warranty:gateway", "expires"))
+	assert.False(t, helpdesk.Hidden("inventory", "mac"))
+	assert.True(t, helpdesk.ReadOnly("warranty", "expires"))
+	assert.False(t, helpdesk.ReadOnly("inventory", "mac"))
+
+	// the restrictions common to all the roles apply
+	both := attributesPolicy.AttributeAccess([]string{"helpdesk", "operator"})
+	assert.True(t, both.Hidden("inventory", "owner_email"))
+	assert.False(t, both.Hidden("warranty", "expires"))
+	assert.True(t, both.ReadOnly("warranty", "expires"))
+	assert.True(t, both.HidesAny())
+
+	var full *AttributeAccess
+	assert.False(t, full.Hidden("warranty", "expires"))
+	assert.False(t, full.ReadOnly("warranty", "expires"))
+	assert.False(t, full.HidesAny())
+
+	dev := &model.Device{
+		ID: "1",
+		Attributes: model.DeviceAttributes{
+			{Scope: "inventory", Name: "mac", Value: "00:11"},
+			{Scope: "inventory", Name: "owner_email", Value: "foo@bar"},
+			{Scope: "warranty", Name: "expires", Value: "2030-01-01"},
+		},
+		Highlights: model.DeviceAttributes{
+			{Scope: "warranty", Name: "expires", Value: "2030-01-01"},
+		},
+		Sources: map[string]model.AttributeSource{
+			"inventory": {Type: "device", ID: "1"},
+			"warranty":  {Type: "user", ID: "2"},
+		},
+	}
+	helpdesk.FilterDevice(dev)
+	assert.Equal(t, &model.Device{
+		ID: "1",
+		Attributes: model.DeviceAttributes{
+			{Scope: "inventory", Name: "mac", Value: "00:11"},
+		},
+		Highlights: model.DeviceAttributes{},
+		Sources: map[string]model.AttributeSource{
+			"inventory": {Type: "device", ID: "1"},
+		},
+	}, dev)
+}
+
+func TestAttributeAccessHandlers(t *testing.T) {
+	t.Parallel()
+
+	helpdesk := makeJWTAuthHeader(`{"sub": "user", "mender.user": true, "mender.roles": ["helpdesk"]}`)
+	operator := makeJWTAuthHeader(`{"sub": "user", "mender.user": true, "mender.roles": ["operator"]}`)
+	dev := &model.Device{
+		ID: "1",
+		Attributes: model.DeviceAttributes{
+			{Scope: "inventory", Name: "mac", Value: "00:11"},
+			{Scope: "warranty", Name: "expires", Value: "2030-01-01"},
+		},
+	}
+	testCases := map[string]struct {
+		inv  func() *minventory.InventoryApp
+		req  *http.Request
+		code int
+		body string
+	}{
+		"ok, hidden attributes left out": {
+			inv: func() *minventory.InventoryApp {
+				inv := &minventory.InventoryApp{}
+				inv.On("GetDevice", contextMatcher(), model.DeviceID("1")).
+					Return(dev, nil)
+				return inv
+			},
+			req:  makeReq(http.MethodGet, "http://localhost/api/0.1.0/devices/1", helpdesk, nil),
+			code: http.StatusOK,
+			body: ToJson(model.Device{
+				ID: "1",
+				Attributes: model.DeviceAttributes{
+					{Scope: "inventory", Name: "mac", Value: "00:11"},
+				},
+			}),
+		},
+		"error, filter on a hidden attribute": {
+			inv: func() *minventory.InventoryApp {
+				return &minventory.InventoryApp{}
+			},
+			req: makeReq(http.MethodPost, "http://localhost"+urlFiltersSearch, helpdesk,
+				map[string]interface{}{
+					"filters": []map[string]interface{}{{
+						"scope":     "warranty",
+						"attribute": "expires",
+						"type":      "$eq",
+						"value":     "2030-01-01",
+					}},
+				},
+			),
+			code: http.StatusForbidden,
+			body: ToJson(restError("warranty/expires: access to the attribute is forbidden")),
+		},
+		"error, search with hidden attributes": {
+			inv: func() *minventory.InventoryApp {
+				return &minventory.InventoryApp{}
+			},
+			req:  makeReq(http.MethodGet, "http://localhost/api/0.1.0/devices?search=foo", helpdesk, nil),
+			code: http.StatusForbidden,
+			body: ToJson(restError("the search covers all the attributes: access to the attribute is forbidden")),
+		},
+		"error, read-only attribute": {
+			inv: func() *minventory.InventoryApp {
+				return &minventory.InventoryApp{}
+			},
+			req: makeReq(http.MethodPut,
+				"http://localhost"+urlSchemaAttributes+"/warranty/expires",
+				operator, map[string]interface{}{"type": "string"},
+			),
+			code: http.StatusForbidden,
+			body: ToJson(restError("warranty/expires: the attribute is read-only")),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := tc.inv()
+			defer inv.AssertExpectations(t)
+
+			handlers := NewInventoryApiHandlers(inv)
+			app, err := handlers.GetGroupsApp(RouteGroups,
+				&AuthzMiddleware{Policy: attributesPolicy})
+			assert.NoError(t, err)
+			api := rest.NewApi()
+			api.Use(&requestid.RequestIdMiddleware{})
+			api.SetApp(app)
+
+			recorded := test.RunRequest(t, api.MakeHandler(), tc.req)
+			recorded.CodeIs(tc.code)
+			recorded.BodyIs(tc.body)
+		})
+	}
+}
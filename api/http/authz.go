@@ -0,0 +1,286 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/log"
+	u "github.com/mendersoftware/go-lib-micro/rest_utils"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/utils"
+	"github.com/mendersoftware/inventory/utils/identity"
+)
+
+// EndpointClass groups the API endpoints by the kind of access they grant.
+type EndpointClass string
+
+const (
+	// EndpointClassRead covers all the endpoints reading the inventory.
+	EndpointClassRead EndpointClass = "read"
+	// EndpointClassTags covers the endpoints modifying device tags.
+	EndpointClassTags EndpointClass = "tags"
+	// EndpointClassGroups covers the endpoints modifying device groups.
+	EndpointClassGroups EndpointClass = "groups"
+	// EndpointClassAdmin covers the remaining endpoints modifying the
+	// inventory, the saved filters and the subscriptions, as well as
+	// the internal API.
+	EndpointClassAdmin EndpointClass = "admin"
+	// EndpointClassDevice covers the device API, which is not subject to
+	// the authorization policy.
+	EndpointClassDevice EndpointClass = "device"
+)
+
+// ScopeInventoryReport is the token scope allowing devices to report
+// their inventory.
+const ScopeInventoryReport = "inventory-report"
+
+var (
+	ErrAuthzForbidden    = errors.New("access to the endpoint is forbidden")
+	ErrAuthzTokenInvalid = errors.New("invalid authorization token")
+	ErrAuthzNoClass      = errors.New("unknown endpoint class")
+
+	ErrDeviceTokenRequired = errors.New("device token required")
+	ErrDeviceTokenScope    = errors.New("token is missing the " + ScopeInventoryReport + " scope")
+	ErrDeviceTokenMismatch = errors.New("token subject does not match the target device")
+
+	ErrUserTokenRequired = errors.New("user token required")
+
+	endpointClasses = []string{
+		string(EndpointClassRead),
+		string(EndpointClassTags),
+		string(EndpointClassGroups),
+		string(EndpointClassAdmin),
+	}
+)
+
+func (c EndpointClass) Validate() error {
+	if !utils.ContainsString(string(c), endpointClasses) {
+		return errors.Errorf("unknown endpoint class: %s", c)
+	}
+	return nil
+}
+
+// AuthzPolicy maps JWT roles to the endpoint classes they are allowed
+// to access.
+type AuthzPolicy struct {
+	// Roles maps the roles to the endpoint classes; all the endpoints
+	// are accessible if empty.
+	Roles map[string][]EndpointClass
+	// DefaultRole is assumed for user tokens which do not carry any role.
+	// If empty, such tokens are granted full access.
+	DefaultRole string
+	// Attributes restricts the access of the roles to the attributes;
+	// the roles left out have full access.
+	Attributes map[string]AttributeRules
+}
+
+func (p AuthzPolicy) Validate() error {
+	for role, classes := range p.Roles {
+		for _, c := range classes {
+			if err := c.Validate(); err != nil {
+				return errors.Wrapf(err, "role %s", role)
+			}
+		}
+	}
+	if _, ok := p.Roles[p.DefaultRole]; len(p.Roles) > 0 &&
+		p.DefaultRole != "" && !ok {
+		return errors.Errorf("default role %s is not defined", p.DefaultRole)
+	}
+	for role := range p.Attributes {
+		if _, ok := p.Roles[role]; len(p.Roles) > 0 && !ok {
+			return errors.Errorf("role %s is not defined", role)
+		}
+	}
+	return nil
+}
+
+// userRoles returns the roles the policy is evaluated for: the default
+// role for the tokens which do not carry any role.
+func (p AuthzPolicy) userRoles(roles []string) []string {
+	if len(roles) == 0 && p.DefaultRole != "" {
+		return []string{p.DefaultRole}
+	}
+	return roles
+}
+
+// Allows returns true if any of the roles grants access to the endpoint class.
+func (p AuthzPolicy) Allows(roles []string, class EndpointClass) bool {
+	roles = p.userRoles(roles)
+	if len(roles) == 0 || len(p.Roles) == 0 {
+		return true
+	}
+	for _, role := range roles {
+		for _, c := range p.Roles[role] {
+			if c == class {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AttributeAccess returns the access of the roles to the attributes;
+// nil if any of the roles has full access.
+func (p AuthzPolicy) AttributeAccess(roles []string) *AttributeAccess {
+	roles = p.userRoles(roles)
+	if len(roles) == 0 {
+		return nil
+	}
+	access := &AttributeAccess{rules: make([]AttributeRules, 0, len(roles))}
+	for _, role := range roles {
+		rules, ok := p.Attributes[role]
+		if !ok {
+			return nil
+		}
+		access.rules = append(access.rules, rules)
+	}
+	return access
+}
+
+// AuthzMiddleware enforces the authorization policy on the requests issued
+// with user tokens and stores their access to the attributes in the request
+// context. Device tokens and requests without a token (internal service
+// calls) are not subject to the policy, while the requests with a token
+// which cannot be decoded are rejected. It is a route middleware: the class
+// of the endpoint comes from the route table.
+type AuthzMiddleware struct {
+	Policy AuthzPolicy
+}
+
+func (mw *AuthzMiddleware) MiddlewareFunc(h rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		l := log.FromContext(r.Context())
+		class, ok := endpointClassFromContext(r.Context())
+		if !ok {
+			u.RestErrWithLogInternal(w, r, l, ErrAuthzNoClass)
+			return
+		} else if class == EndpointClassDevice {
+			h(w, r)
+			return
+		}
+
+		idata, ok, err := requestIdentity(r)
+		if err != nil {
+			l.Warnf("authorization: %s", err.Error())
+			u.RestErrWithLog(w, r, l, ErrAuthzTokenInvalid, http.StatusUnauthorized)
+			return
+		} else if !ok || !idata.IsUser {
+			h(w, r)
+			return
+		}
+
+		if !mw.Policy.Allows(idata.Roles, class) {
+			u.RestErrWithLog(w, r, l, ErrAuthzForbidden, http.StatusForbidden)
+			return
+		}
+		if access := mw.Policy.AttributeAccess(idata.Roles); access != nil {
+			r.Request = r.Request.WithContext(
+				withAttributeAccess(r.Context(), access))
+		}
+		h(w, r)
+	}
+}
+
+// requestIdentity returns the identity of the token of the request, taken
+// from the Authorization header or the JWT cookie, and false if
+// the request carries no token.
+func requestIdentity(r *rest.Request) (identity.Identity, bool, error) {
+	if r.Header.Get("Authorization") != "" {
+		idata, err := identity.ExtractIdentityFromHeaders(r.Header)
+		return idata, true, err
+	}
+	if cookie, err := r.Cookie("JWT"); err == nil {
+		idata, err := identity.ExtractIdentity(cookie.Value)
+		return idata, true, err
+	}
+	return identity.Identity{}, false, nil
+}
+
+type endpointClassKey struct{}
+
+// withEndpointClass stores the class of the endpoint of the route in
+// the request context.
+func withEndpointClass(class EndpointClass, h rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		r.Request = r.Request.WithContext(
+			context.WithValue(r.Context(), endpointClassKey{}, class))
+		h(w, r)
+	}
+}
+
+// endpointClassFromContext returns the class of the endpoint serving
+// the request.
+func endpointClassFromContext(ctx context.Context) (EndpointClass, bool) {
+	class, ok := ctx.Value(endpointClassKey{}).(EndpointClass)
+	return class, ok
+}
+
+// DeviceTokenMiddleware verifies the tokens used to report device attributes:
+// reports must be issued with a device token carrying the inventory-report
+// scope, and a device token can only write attributes of its own device.
+type DeviceTokenMiddleware struct{}
+
+func (mw *DeviceTokenMiddleware) MiddlewareFunc(h rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		target, isReport := attributesReportTarget(r.URL.Path)
+		if !isReport {
+			h(w, r)
+			return
+		}
+
+		l := log.FromContext(r.Context())
+		idata, err := identity.ExtractIdentityFromHeaders(r.Header)
+		if target == "" {
+			// devices API: the target is the subject of the token
+			if err != nil || !idata.IsDevice {
+				u.RestErrWithLog(w, r, l, ErrDeviceTokenRequired, http.StatusUnauthorized)
+				return
+			} else if !utils.ContainsString(ScopeInventoryReport, idata.Scopes) {
+				u.RestErrWithLog(w, r, l, ErrDeviceTokenScope, http.StatusForbidden)
+				return
+			}
+		} else if err == nil && idata.IsDevice && idata.Subject != target {
+			// internal API: service calls come without a token, but
+			// a device must not write attributes of another device
+			u.RestErrWithLog(w, r, l, ErrDeviceTokenMismatch, http.StatusForbidden)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// attributesReportTarget returns true if the request path points at
+// an endpoint writing device attributes, together with the ID of the target
+// device if it is part of the path.
+func attributesReportTarget(path string) (string, bool) {
+	const internalTenantsPrefix = uriInternalTenants + "/"
+	if path == uriAttributes {
+		return "", true
+	} else if !strings.HasPrefix(path, internalTenantsPrefix) {
+		return "", false
+	}
+	// :tenant_id/device/:device_id/attribute/scope/:scope
+	parts := strings.Split(strings.TrimPrefix(path, internalTenantsPrefix), "/")
+	if len(parts) != 6 ||
+		parts[1] != "device" || parts[3] != "attribute" || parts[4] != "scope" {
+		return "", false
+	}
+	return parts[2], true
+}
@@ -0,0 +1,415 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"encoding/base64"
+	"net/http"
+	"testing"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/mendersoftware/go-lib-micro/requestid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeJWTAuthHeader(claims string) string {
+	return "Bearer foo." +
+		base64.StdEncoding.EncodeToString([]byte(claims)) +
+		".bar"
+}
+
+// routedApp returns the app routing the requests of the method and path
+// through the route middlewares to a handler responding 200 OK, as
+// the endpoint of the class.
+func routedApp(
+	t *testing.T,
+	class EndpointClass,
+	method, path string,
+	mws ...rest.Middleware,
+) rest.App {
+	app, err := makeRouter([]route{{class, &rest.Route{
+		HttpMethod: method,
+		PathExp:    path,
+		Func: func(w rest.ResponseWriter, r *rest.Request) {
+			w.WriteHeader(http.StatusOK)
+		},
+	}}}, RouteGroups, mws)
+	require.NoError(t, err)
+	return app
+}
+
+func TestEndpointClasses(t *testing.T) {
+	handlers := &inventoryHandlers{}
+	classes := map[string]EndpointClass{}
+	var mw rest.MiddlewareSimple = func(h rest.HandlerFunc) rest.HandlerFunc {
+		return func(w rest.ResponseWriter, r *rest.Request) {
+			class, ok := endpointClassFromContext(r.Context())
+			assert.True(t, ok)
+			classes[r.Method+" "+r.URL.Path] = class
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+	app, err := handlers.GetGroupsApp(RouteGroups, mw)
+	require.NoError(t, err)
+	api := rest.NewApi()
+	api.SetApp(app)
+	handler := api.MakeHandler()
+
+	testCases := []struct {
+		method string
+		path   string
+		class  EndpointClass
+	}{
+		{http.MethodPatch, uriAttributes, EndpointClassDevice},
+		{http.MethodPut, uriAttributes, EndpointClassDevice},
+		{http.MethodGet, uriDevices, EndpointClassRead},
+		{http.MethodOptions, uriDevices, EndpointClassRead},
+		{http.MethodGet, "/api/0.1.0/devices/1/group", EndpointClassRead},
+		{http.MethodPost, urlFiltersSearch, EndpointClassRead},
+		{http.MethodPost, urlFiltersValidate, EndpointClassRead},
+		{http.MethodGet, urlSubscriptions, EndpointClassRead},
+		{http.MethodPost, urlSubscriptions, EndpointClassAdmin},
+		{http.MethodPut, urlSubscriptions + "/1", EndpointClassAdmin},
+		{http.MethodDelete, urlSubscriptions + "/1", EndpointClassAdmin},
+		{http.MethodGet, urlSavedFilters + "/1", EndpointClassRead},
+		{http.MethodPost, urlSavedFilters, EndpointClassAdmin},
+		{http.MethodPut, urlSavedFilters + "/1", EndpointClassAdmin},
+		{http.MethodDelete, urlSavedFilters + "/1", EndpointClassAdmin},
+		{http.MethodPost, urlGroupsPreview, EndpointClassRead},
+		{http.MethodPost, "/api/management/v2/inventory/groups/foo/assignment/preview", EndpointClassRead},
+		{http.MethodPost, urlExports, EndpointClassRead},
+		{http.MethodPost, uriDevicesGet, EndpointClassRead},
+		{http.MethodPost, urlTagsImport, EndpointClassTags},
+		{http.MethodPost, urlTagsAssignment, EndpointClassTags},
+		{http.MethodPut, "/api/0.1.0/devices/1/group", EndpointClassGroups},
+		{http.MethodDelete, "/api/0.1.0/devices/1/group/foo", EndpointClassGroups},
+		{http.MethodPatch, "/api/0.1.0/groups/foo/devices", EndpointClassGroups},
+		{http.MethodPut, "/api/management/v2/inventory/groups/foo", EndpointClassGroups},
+		{http.MethodPost, "/api/management/v2/inventory/groups/foo/assignment", EndpointClassGroups},
+		{http.MethodPost, urlConfigBundle, EndpointClassAdmin},
+		{http.MethodDelete, "/api/0.1.0/devices/1", EndpointClassAdmin},
+		{http.MethodPost, "/api/0.1.0/devices/1/template", EndpointClassAdmin},
+		{http.MethodGet, uriInternalAlive, EndpointClassAdmin},
+		{http.MethodPost, uriInternalTenants, EndpointClassAdmin},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			req, _ := http.NewRequest(tc.method, "http://localhost"+tc.path, nil)
+			test.RunRequest(t, handler, req).CodeIs(http.StatusNoContent)
+			assert.Equal(t, tc.class, classes[tc.method+" "+tc.path])
+		})
+	}
+}
+
+func TestMakeRouterNoClass(t *testing.T) {
+	_, err := makeRouter([]route{
+		{EndpointClassRead, rest.Get(uriDevices, nil)},
+		{"", rest.Delete(uriDevice, nil)},
+	}, RouteGroups, nil)
+	assert.EqualError(t, err, "route DELETE "+uriDevice+" has no endpoint class")
+}
+
+func TestAuthzPolicyValidate(t *testing.T) {
+	policy := AuthzPolicy{
+		Roles: map[string][]EndpointClass{
+			"helpdesk": {EndpointClassRead},
+		},
+		DefaultRole: "helpdesk",
+	}
+	assert.NoError(t, policy.Validate())
+
+	policy.DefaultRole = "operator"
+	assert.EqualError(t, policy.Validate(),
+		"default role operator is not defined")
+
+	policy.Roles["operator"] = []EndpointClass{"write"}
+	assert.EqualError(t, policy.Validate(),
+		"role operator: unknown endpoint class: write")
+
+	policy = AuthzPolicy{
+		Roles: map[string][]EndpointClass{
+			"helpdesk": {EndpointClassRead},
+		},
+		Attributes: map[string]AttributeRules{
+			"operator": {Hidden: []AttributeRef{{Scope: "warranty"}}},
+		},
+	}
+	assert.EqualError(t, policy.Validate(), "role operator is not defined")
+
+	// the attribute rules alone do not restrict the endpoints
+	policy.Roles = nil
+	assert.NoError(t, policy.Validate())
+	assert.True(t, policy.Allows([]string{"operator"}, EndpointClassAdmin))
+}
+
+func TestAuthzMiddleware(t *testing.T) {
+	t.Parallel()
+
+	policy := AuthzPolicy{
+		Roles: map[string][]EndpointClass{
+			"helpdesk": {EndpointClassRead},
+			"operator": {EndpointClassRead, EndpointClassGroups},
+		},
+	}
+	testCases := map[string]struct {
+		policy AuthzPolicy
+		class  EndpointClass
+		method string
+		path   string
+		auth   string
+		cookie string
+
+		code int
+	}{
+		"ok, read access": {
+			policy: policy,
+			class:  EndpointClassRead,
+			method: http.MethodGet,
+			path:   uriDevices,
+			auth:   makeJWTAuthHeader(`{"sub": "user", "mender.user": true, "mender.roles": ["helpdesk"]}`),
+			code:   http.StatusOK,
+		},
+		"ok, one of the roles grants access": {
+			policy: policy,
+			class:  EndpointClassGroups,
+			method: http.MethodPut,
+			path:   "/api/0.1.0/devices/1/group",
+			auth:   makeJWTAuthHeader(`{"sub": "user", "mender.user": true, "mender.roles": ["helpdesk", "operator"]}`),
+			code:   http.StatusOK,
+		},
+		"ok, no roles and no default role": {
+			policy: policy,
+			class:  EndpointClassAdmin,
+			method: http.MethodDelete,
+			path:   "/api/0.1.0/devices/1",
+			auth:   makeJWTAuthHeader(`{"sub": "user", "mender.user": true}`),
+			code:   http.StatusOK,
+		},
+		"ok, device token": {
+			policy: policy,
+			class:  EndpointClassDevice,
+			method: http.MethodPatch,
+			path:   uriAttributes,
+			auth:   makeJWTAuthHeader(`{"sub": "device", "mender.device": true}`),
+			code:   http.StatusOK,
+		},
+		"ok, internal call without token": {
+			policy: policy,
+			class:  EndpointClassAdmin,
+			method: http.MethodPost,
+			path:   uriInternalTenants,
+			code:   http.StatusOK,
+		},
+		"forbidden, read-only role": {
+			policy: policy,
+			class:  EndpointClassGroups,
+			method: http.MethodPut,
+			path:   "/api/0.1.0/devices/1/group",
+			auth:   makeJWTAuthHeader(`{"sub": "user", "mender.user": true, "mender.roles": ["helpdesk"]}`),
+			code:   http.StatusForbidden,
+		},
+		"forbidden, unknown role": {
+			policy: policy,
+			class:  EndpointClassRead,
+			method: http.MethodGet,
+			path:   uriDevices,
+			auth:   makeJWTAuthHeader(`{"sub": "user", "mender.user": true, "mender.roles": ["guest"]}`),
+			code:   http.StatusForbidden,
+		},
+		"forbidden, default role": {
+			policy: AuthzPolicy{
+				Roles:       policy.Roles,
+				DefaultRole: "helpdesk",
+			},
+			class:  EndpointClassAdmin,
+			method: http.MethodDelete,
+			path:   "/api/0.1.0/devices/1",
+			auth:   makeJWTAuthHeader(`{"sub": "user", "mender.user": true}`),
+			code:   http.StatusForbidden,
+		},
+		"ok, device token on a management endpoint": {
+			policy: policy,
+			class:  EndpointClassAdmin,
+			method: http.MethodDelete,
+			path:   "/api/0.1.0/devices/1",
+			auth:   makeJWTAuthHeader(`{"sub": "device", "mender.device": true}`),
+			code:   http.StatusOK,
+		},
+		"forbidden, cookie token": {
+			policy: policy,
+			class:  EndpointClassGroups,
+			method: http.MethodPut,
+			path:   "/api/0.1.0/devices/1/group",
+			cookie: `{"sub": "user", "mender.user": true, "mender.roles": ["helpdesk"]}`,
+			code:   http.StatusForbidden,
+		},
+		"unauthorized, malformed roles": {
+			policy: policy,
+			class:  EndpointClassAdmin,
+			method: http.MethodDelete,
+			path:   "/api/0.1.0/devices/1",
+			auth:   makeJWTAuthHeader(`{"sub": "user", "mender.user": true, "mender.roles": "admin"}`),
+			code:   http.StatusUnauthorized,
+		},
+		"unauthorized, malformed scope": {
+			policy: policy,
+			class:  EndpointClassAdmin,
+			method: http.MethodDelete,
+			path:   "/api/0.1.0/devices/1",
+			auth:   makeJWTAuthHeader(`{"sub": "user", "mender.user": true, "scope": ["all"]}`),
+			code:   http.StatusUnauthorized,
+		},
+		"unauthorized, malformed header": {
+			policy: policy,
+			class:  EndpointClassRead,
+			method: http.MethodGet,
+			path:   uriDevices,
+			auth:   "Bearer",
+			code:   http.StatusUnauthorized,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			api := rest.NewApi()
+			api.Use(&requestid.RequestIdMiddleware{})
+			api.SetApp(routedApp(t, tc.class, tc.method, tc.path,
+				&AuthzMiddleware{Policy: tc.policy}))
+
+			req, _ := http.NewRequest(tc.method, "http://localhost"+tc.path, nil)
+			if tc.auth != "" {
+				req.Header.Set("Authorization", tc.auth)
+			}
+			if tc.cookie != "" {
+				req.AddCookie(&http.Cookie{
+					Name: "JWT",
+					Value: "foo." + base64.StdEncoding.EncodeToString(
+						[]byte(tc.cookie)) + ".bar",
+				})
+			}
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.code)
+		})
+	}
+}
+
+func TestAttributesReportTarget(t *testing.T) {
+	target, ok := attributesReportTarget(uriAttributes)
+	assert.True(t, ok)
+	assert.Empty(t, target)
+
+	target, ok = attributesReportTarget(
+		"/api/internal/v1/inventory/tenants/tenant/device/dev/attribute/scope/identity",
+	)
+	assert.True(t, ok)
+	assert.Equal(t, "dev", target)
+
+	_, ok = attributesReportTarget(
+		"/api/internal/v1/inventory/tenants/tenant/devices/dev/groups",
+	)
+	assert.False(t, ok)
+
+	_, ok = attributesReportTarget(uriDevices)
+	assert.False(t, ok)
+}
+
+func TestDeviceTokenMiddleware(t *testing.T) {
+	t.Parallel()
+
+	const internalPath = "/api/internal/v1/inventory/tenants/tenant" +
+		"/device/dev/attribute/scope/identity"
+	testCases := map[string]struct {
+		method string
+		path   string
+		auth   string
+
+		code int
+	}{
+		"ok, device report": {
+			method: http.MethodPatch,
+			path:   uriAttributes,
+			auth:   makeJWTAuthHeader(`{"sub": "dev", "mender.device": true, "scope": "inventory-report"}`),
+			code:   http.StatusOK,
+		},
+		"ok, internal call without token": {
+			method: http.MethodPatch,
+			path:   internalPath,
+			code:   http.StatusOK,
+		},
+		"ok, internal call with matching device token": {
+			method: http.MethodPatch,
+			path:   internalPath,
+			auth:   makeJWTAuthHeader(`{"sub": "dev", "mender.device": true}`),
+			code:   http.StatusOK,
+		},
+		"ok, other endpoint": {
+			method: http.MethodGet,
+			path:   uriDevices,
+			auth:   makeJWTAuthHeader(`{"sub": "user", "mender.user": true}`),
+			code:   http.StatusOK,
+		},
+		"error, no token": {
+			method: http.MethodPatch,
+			path:   uriAttributes,
+			code:   http.StatusUnauthorized,
+		},
+		"error, user token": {
+			method: http.MethodPut,
+			path:   uriAttributes,
+			auth:   makeJWTAuthHeader(`{"sub": "user", "mender.user": true, "scope": "inventory-report"}`),
+			code:   http.StatusUnauthorized,
+		},
+		"error, missing scope": {
+			method: http.MethodPatch,
+			path:   uriAttributes,
+			auth:   makeJWTAuthHeader(`{"sub": "dev", "mender.device": true, "scope": "other"}`),
+			code:   http.StatusForbidden,
+		},
+		"error, cross-device write": {
+			method: http.MethodPatch,
+			path:   internalPath,
+			auth:   makeJWTAuthHeader(`{"sub": "other-dev", "mender.device": true}`),
+			code:   http.StatusForbidden,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			api := rest.NewApi()
+			api.Use(
+				&requestid.RequestIdMiddleware{},
+				&DeviceTokenMiddleware{},
+			)
+			api.SetApp(rest.AppSimple(func(w rest.ResponseWriter, r *rest.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req, _ := http.NewRequest(tc.method, "http://localhost"+tc.path, nil)
+			if tc.auth != "" {
+				req.Header.Set("Authorization", tc.auth)
+			}
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.code)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+const (
+	hdrCacheControl = "Cache-Control"
+	hdrETag         = "ETag"
+	hdrIfNoneMatch  = "If-None-Match"
+)
+
+// ConditionalGetMiddleware adds the Cache-Control and ETag headers to the
+// responses of the relatively static endpoints, such as the list of groups
+// or the catalog of attribute names, and responds with 304 Not Modified if
+// the client already holds the current representation. Within MaxAge,
+// clients can reuse the response without contacting the service at all.
+type ConditionalGetMiddleware struct {
+	MaxAge time.Duration
+}
+
+func (mw *ConditionalGetMiddleware) MiddlewareFunc(h rest.HandlerFunc) rest.HandlerFunc {
+	cacheControl := "private, no-cache"
+	if seconds := int(mw.MaxAge.Seconds()); seconds > 0 {
+		cacheControl = fmt.Sprintf("private, max-age=%d", seconds)
+	}
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		if !cacheableEndpoint(r.Method, r.URL.Path) {
+			h(w, r)
+			return
+		}
+
+		rec := &bufferedResponseWriter{ResponseWriter: w}
+		h(rec, r)
+		if rec.status == 0 {
+			return
+		}
+
+		if rec.status == http.StatusOK {
+			etag := makeETag(rec.body.Bytes())
+			w.Header().Set(hdrETag, etag)
+			w.Header().Set(hdrCacheControl, cacheControl)
+			if etagMatches(r.Header.Get(hdrIfNoneMatch), etag) {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		w.WriteHeader(rec.status)
+		_, _ = w.(http.ResponseWriter).Write(rec.body.Bytes())
+	}
+}
+
+// cacheableEndpoint returns true if the responses of the endpoint serving
+// the request can be cached by the clients.
+func cacheableEndpoint(method, path string) bool {
+	if method != http.MethodGet {
+		return false
+	}
+	switch path {
+	case uriGroups, urlGroupsV2, urlFiltersAttributes, urlScopes,
+		urlSchemaAttributes:
+		return true
+	}
+	return false
+}
+
+func makeETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches implements the weak comparison of the entity tags listed in
+// the If-None-Match header with the current one.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferedResponseWriter holds back the response until the handler
+// completes, so that the entity tag of the body can be computed.
+type bufferedResponseWriter struct {
+	rest.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedResponseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *bufferedResponseWriter) WriteJson(v interface{}) error {
+	b, err := w.EncodeJson(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
@@ -0,0 +1,130 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/mendersoftware/go-lib-micro/requestid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEtagMatches(t *testing.T) {
+	etag := makeETag([]byte(`["foo"]`))
+	assert.Len(t, etag, 34)
+	assert.True(t, etagMatches(etag, etag))
+	assert.True(t, etagMatches(`"other", W/`+etag, etag))
+	assert.True(t, etagMatches("*", etag))
+	assert.False(t, etagMatches("", etag))
+	assert.False(t, etagMatches(`"other"`, etag))
+}
+
+func TestConditionalGetMiddleware(t *testing.T) {
+	t.Parallel()
+
+	body := `["bar","foo"]`
+	etag := makeETag([]byte(body))
+	testCases := map[string]struct {
+		maxAge      time.Duration
+		method      string
+		path        string
+		ifNoneMatch string
+		status      int
+
+		code         int
+		etag         string
+		cacheControl string
+		body         string
+	}{
+		"ok": {
+			maxAge:       10 * time.Second,
+			method:       http.MethodGet,
+			path:         uriGroups,
+			status:       http.StatusOK,
+			code:         http.StatusOK,
+			etag:         etag,
+			cacheControl: "private, max-age=10",
+			body:         body,
+		},
+		"ok, not modified": {
+			maxAge:       10 * time.Second,
+			method:       http.MethodGet,
+			path:         urlFiltersAttributes,
+			ifNoneMatch:  etag,
+			status:       http.StatusOK,
+			code:         http.StatusNotModified,
+			etag:         etag,
+			cacheControl: "private, max-age=10",
+		},
+		"ok, modified": {
+			method:       http.MethodGet,
+			path:         urlScopes,
+			ifNoneMatch:  `"outdated"`,
+			status:       http.StatusOK,
+			code:         http.StatusOK,
+			etag:         etag,
+			cacheControl: "private, no-cache",
+			body:         body,
+		},
+		"ok, error response": {
+			method:      http.MethodGet,
+			path:        urlSchemaAttributes,
+			ifNoneMatch: etag,
+			status:      http.StatusInternalServerError,
+			code:        http.StatusInternalServerError,
+			body:        body,
+		},
+		"ok, other endpoint": {
+			method:      http.MethodGet,
+			path:        uriDevices,
+			ifNoneMatch: etag,
+			status:      http.StatusOK,
+			code:        http.StatusOK,
+			body:        body,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			api := rest.NewApi()
+			api.Use(
+				&requestid.RequestIdMiddleware{},
+				&ConditionalGetMiddleware{MaxAge: tc.maxAge},
+			)
+			api.SetApp(rest.AppSimple(func(w rest.ResponseWriter, r *rest.Request) {
+				w.WriteHeader(tc.status)
+				w.WriteJson([]string{"bar", "foo"})
+			}))
+
+			req, _ := http.NewRequest(tc.method, "http://localhost"+tc.path, nil)
+			if tc.ifNoneMatch != "" {
+				req.Header.Set(hdrIfNoneMatch, tc.ifNoneMatch)
+			}
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.code)
+			assert.Equal(t, tc.etag, recorded.Recorder.Header().Get(hdrETag))
+			assert.Equal(t, tc.cacheControl,
+				recorded.Recorder.Header().Get(hdrCacheControl))
+			assert.Equal(t, tc.body, recorded.Recorder.Body.String())
+		})
+	}
+}
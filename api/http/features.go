@@ -0,0 +1,61 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/log"
+	u "github.com/mendersoftware/go-lib-micro/rest_utils"
+	"github.com/pkg/errors"
+
+	inventory "github.com/mendersoftware/inventory/inv"
+	"github.com/mendersoftware/inventory/model"
+)
+
+var ErrFeatureDisabled = errors.New("the feature is not enabled for the tenant")
+
+// FeatureFlagMiddleware rejects the requests to the endpoints of
+// the features which are not enabled for the tenant of the caller.
+type FeatureFlagMiddleware struct {
+	Inventory inventory.InventoryApp
+}
+
+func (mw *FeatureFlagMiddleware) MiddlewareFunc(h rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		for _, flag := range endpointFeatures(r.URL.Path) {
+			if !mw.Inventory.FeatureEnabled(r.Context(), flag) {
+				l := log.FromContext(r.Context())
+				u.RestErrWithLog(w, r, l, ErrFeatureDisabled, http.StatusForbidden)
+				return
+			}
+		}
+		h(w, r)
+	}
+}
+
+// endpointFeatures returns the feature flags the endpoint serving
+// the request depends on; none if the endpoint is always available.
+func endpointFeatures(path string) []model.FeatureFlag {
+	if !strings.HasPrefix(path, apiUrlManagementV2+"/") {
+		return nil
+	}
+	if path == urlDynamicGroups || strings.HasPrefix(path, urlDynamicGroups+"/") {
+		return []model.FeatureFlag{model.FeatureAPIv2, model.FeatureDynamicGroups}
+	}
+	return []model.FeatureFlag{model.FeatureAPIv2}
+}
@@ -0,0 +1,97 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/mendersoftware/go-lib-micro/requestid"
+	"github.com/stretchr/testify/mock"
+
+	minventory "github.com/mendersoftware/inventory/inv/mocks"
+	"github.com/mendersoftware/inventory/model"
+)
+
+func TestFeatureFlagMiddleware(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		path     string
+		disabled []model.FeatureFlag
+
+		code int
+	}{
+		"ok, v2 enabled": {
+			path: urlFiltersAttributes,
+			code: http.StatusOK,
+		},
+		"ok, v1 endpoint": {
+			path: uriDevices,
+			code: http.StatusOK,
+		},
+		"ok, dynamic groups enabled": {
+			path: urlDynamicGroups + "/rpi4",
+			code: http.StatusOK,
+		},
+		"forbidden, v2 disabled": {
+			path:     urlFiltersAttributes,
+			disabled: []model.FeatureFlag{model.FeatureAPIv2},
+			code:     http.StatusForbidden,
+		},
+		"forbidden, dynamic groups disabled": {
+			path:     urlDynamicGroups,
+			disabled: []model.FeatureFlag{model.FeatureDynamicGroups},
+			code:     http.StatusForbidden,
+		},
+		"ok, static groups with dynamic groups disabled": {
+			path:     urlGroupsV2,
+			disabled: []model.FeatureFlag{model.FeatureDynamicGroups},
+			code:     http.StatusOK,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := &minventory.InventoryApp{}
+			for _, flag := range model.FeatureFlags {
+				enabled := true
+				for _, disabled := range tc.disabled {
+					enabled = enabled && flag != disabled
+				}
+				inv.On("FeatureEnabled", mock.Anything, flag).
+					Return(enabled)
+			}
+
+			api := rest.NewApi()
+			api.Use(
+				&requestid.RequestIdMiddleware{},
+				&FeatureFlagMiddleware{Inventory: inv},
+			)
+			api.SetApp(rest.AppSimple(func(w rest.ResponseWriter, r *rest.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req, _ := http.NewRequest(http.MethodGet, "http://localhost"+tc.path, nil)
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.code)
+		})
+	}
+}
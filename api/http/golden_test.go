@@ -0,0 +1,585 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	inventory "github.com/mendersoftware/inventory/inv"
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+	mstore "github.com/mendersoftware/inventory/store/mocks"
+)
+
+// updateGolden rewrites the golden responses instead of comparing them,
+// after an intended change of the API: go test ./api/http -update
+var updateGolden = flag.Bool("update", false, "rewrite the golden responses")
+
+const goldenDir = "testdata/golden"
+
+// goldenHeaders are the response headers recorded in the golden files;
+// the others depend on the run.
+var goldenHeaders = []string{
+	"Content-Type",
+	"Link",
+	"Location",
+	hdrTotalCount,
+}
+
+// goldenTime is the time of all the changes in the memory store, so that
+// the responses don't depend on the clock.
+var goldenTime = time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// memStore keeps the devices of a single tenant in memory. It implements
+// the operations of the recorded requests; the others fail as unexpected
+// calls of the embedded mock.
+type memStore struct {
+	*mstore.DataStore
+
+	mu      sync.Mutex
+	devices map[model.DeviceID]*model.Device
+}
+
+func newMemStore(devs []model.Device) *memStore {
+	s := &memStore{
+		DataStore: &mstore.DataStore{},
+		devices:   make(map[model.DeviceID]*model.Device, len(devs)),
+	}
+	for _, dev := range devs {
+		s.upsert(dev.ID, dev.Attributes)
+		if dev.Group != "" {
+			s.setGroups(dev.ID, []model.GroupName{dev.Group})
+		}
+	}
+	return s
+}
+
+// upsert sets the attributes of the device, creating it if needed,
+// and returns true if it was created. The timestamps are replaced with
+// goldenTime.
+func (s *memStore) upsert(id model.DeviceID, attrs model.DeviceAttributes) bool {
+	dev, ok := s.devices[id]
+	if !ok {
+		dev = &model.Device{ID: id, CreatedTs: goldenTime}
+		s.devices[id] = dev
+	}
+	for _, attr := range attrs {
+		if _, ok := attr.Value.(time.Time); ok {
+			attr.Value = goldenTime
+		}
+		found := false
+		for n := range dev.Attributes {
+			if dev.Attributes[n].Scope == attr.Scope &&
+				dev.Attributes[n].Name == attr.Name {
+				dev.Attributes[n] = attr
+				found = true
+			}
+		}
+		if !found {
+			dev.Attributes = append(dev.Attributes, attr)
+		}
+	}
+	dev.UpdatedTs = goldenTime
+	return !ok
+}
+
+// setGroups replaces the groups of the device; the group attribute holds
+// the first one like in the datastore.
+func (s *memStore) setGroups(id model.DeviceID, groups []model.GroupName) {
+	dev := s.devices[id]
+	dev.Groups = groups
+	dev.Group = ""
+	if len(groups) > 0 {
+		dev.Group = groups[0]
+	}
+	attrs := dev.Attributes[:0]
+	for _, attr := range dev.Attributes {
+		if attr.Scope != model.AttrScopeSystem || attr.Name != model.AttrNameGroup {
+			attrs = append(attrs, attr)
+		}
+	}
+	dev.Attributes = attrs
+	if dev.Group != "" {
+		dev.Attributes = append(dev.Attributes, model.DeviceAttribute{
+			Scope: model.AttrScopeSystem,
+			Name:  model.AttrNameGroup,
+			Value: string(dev.Group),
+		})
+	}
+}
+
+func hasGroup(dev *model.Device, group model.GroupName) bool {
+	for _, g := range dev.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// sorted returns the copies of the devices, sorted by ID.
+func (s *memStore) sorted() []model.Device {
+	devs := make([]model.Device, 0, len(s.devices))
+	for _, dev := range s.devices {
+		devs = append(devs, copyDevice(dev))
+	}
+	sort.Slice(devs, func(i, j int) bool { return devs[i].ID < devs[j].ID })
+	return devs
+}
+
+func copyDevice(dev *model.Device) model.Device {
+	res := *dev
+	res.Attributes = append(model.DeviceAttributes(nil), dev.Attributes...)
+	return res
+}
+
+func matchesFilter(dev model.Device, f store.Filter) bool {
+	equal := false
+	for _, attr := range dev.Attributes {
+		if attr.Scope == f.AttrScope && attr.Name == f.AttrName {
+			equal = fmt.Sprint(attr.Value) == f.Value
+		}
+	}
+	switch f.Operator {
+	case store.Eq:
+		return equal
+	case store.Ne:
+		return !equal
+	default:
+		panic(fmt.Sprintf("memStore: unsupported operator %d", f.Operator))
+	}
+}
+
+func matchesFilterGroup(dev model.Device, g store.FilterGroup) bool {
+	matches := make([]bool, 0, len(g.Filters)+len(g.Groups))
+	for _, f := range g.Filters {
+		matches = append(matches, matchesFilter(dev, f))
+	}
+	for _, nested := range g.Groups {
+		matches = append(matches, matchesFilterGroup(dev, nested))
+	}
+	for _, m := range matches {
+		if m == (g.Operator == store.Or) {
+			return m
+		}
+	}
+	return g.Operator != store.Or || len(matches) == 0
+}
+
+// matchesSearch mirrors the text search of the datastore: the ID or any
+// string or number value contains the text, ignoring the case.
+func matchesSearch(dev model.Device, text string) bool {
+	text = strings.ToLower(text)
+	contains := func(value interface{}) bool {
+		switch value.(type) {
+		case string, float64:
+			return strings.Contains(strings.ToLower(fmt.Sprint(value)), text)
+		}
+		return false
+	}
+	if contains(string(dev.ID)) {
+		return true
+	}
+	for _, attr := range dev.Attributes {
+		values, ok := attr.Value.([]interface{})
+		if !ok {
+			values = []interface{}{attr.Value}
+		}
+		for _, value := range values {
+			if contains(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *memStore) AddDevice(ctx context.Context, dev *model.Device) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.upsert(dev.ID, dev.Attributes)
+	if dev.Group != "" {
+		s.setGroups(dev.ID, []model.GroupName{dev.Group})
+	}
+	return nil
+}
+
+func (s *memStore) GetDevice(
+	ctx context.Context,
+	id model.DeviceID,
+) (*model.Device, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dev, ok := s.devices[id]
+	if !ok {
+		return nil, nil
+	}
+	res := copyDevice(dev)
+	return &res, nil
+}
+
+func (s *memStore) GetDevices(
+	ctx context.Context,
+	q store.ListQuery,
+) ([]model.Device, int, error) {
+	if q.Sort != nil {
+		panic("memStore: sorting is not supported")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	devs := []model.Device{}
+	for _, dev := range s.sorted() {
+		if q.GroupName != "" && !hasGroup(&dev, model.GroupName(q.GroupName)) {
+			continue
+		}
+		if q.HasGroup != nil && *q.HasGroup != (dev.Group != "") {
+			continue
+		}
+		matches := true
+		for _, f := range q.Filters {
+			matches = matches && matchesFilter(dev, f)
+		}
+		if q.FilterGroup != nil {
+			matches = matches && matchesFilterGroup(dev, *q.FilterGroup)
+		}
+		if q.Search != "" {
+			matches = matches && matchesSearch(dev, q.Search)
+		}
+		if !matches {
+			continue
+		}
+		if q.IDsOnly {
+			dev = model.Device{ID: dev.ID}
+		}
+		devs = append(devs, dev)
+	}
+	total := len(devs)
+	if q.Skip > len(devs) {
+		q.Skip = len(devs)
+	}
+	devs = devs[q.Skip:]
+	if q.Limit > 0 && q.Limit < len(devs) {
+		devs = devs[:q.Limit]
+	}
+	return devs, total, nil
+}
+
+func (s *memStore) DeleteDevices(
+	ctx context.Context,
+	ids []model.DeviceID,
+) (*model.UpdateResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := &model.UpdateResult{}
+	for _, id := range ids {
+		if _, ok := s.devices[id]; ok {
+			delete(s.devices, id)
+			res.DeletedCount++
+		}
+	}
+	return res, nil
+}
+
+func (s *memStore) UpsertDevicesAttributes(
+	ctx context.Context,
+	ids []model.DeviceID,
+	attrs model.DeviceAttributes,
+) (*model.UpdateResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := &model.UpdateResult{}
+	for _, id := range ids {
+		if s.upsert(id, attrs) {
+			res.CreatedCount++
+		} else {
+			res.MatchedCount++
+			res.UpdatedCount++
+		}
+	}
+	return res, nil
+}
+
+func (s *memStore) UpsertDevicesAttributesWithUpdated(
+	ctx context.Context,
+	ids []model.DeviceID,
+	attrs model.DeviceAttributes,
+) (*model.UpdateResult, error) {
+	return s.UpsertDevicesAttributes(ctx, ids, attrs)
+}
+
+func (s *memStore) UpdateDevicesGroup(
+	ctx context.Context,
+	ids []model.DeviceID,
+	group model.GroupName,
+) (*model.UpdateResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := &model.UpdateResult{}
+	for _, id := range ids {
+		dev, ok := s.devices[id]
+		if !ok {
+			continue
+		}
+		res.MatchedCount++
+		// the devices are moved, like in the datastore before
+		// the groups array is rolled out
+		if !hasGroup(dev, group) {
+			s.setGroups(id, []model.GroupName{group})
+			res.UpdatedCount++
+		}
+	}
+	return res, nil
+}
+
+// InsertGroupsMetadata ignores the metadata, which isn't part of the
+// recorded responses.
+func (s *memStore) InsertGroupsMetadata(
+	ctx context.Context,
+	groups []model.GroupMetadata,
+) error {
+	return nil
+}
+
+func (s *memStore) UnsetDevicesGroup(
+	ctx context.Context,
+	ids []model.DeviceID,
+	group model.GroupName,
+) (*model.UpdateResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := &model.UpdateResult{}
+	for _, id := range ids {
+		dev, ok := s.devices[id]
+		if !ok || !hasGroup(dev, group) {
+			continue
+		}
+		var groups []model.GroupName
+		for _, g := range dev.Groups {
+			if g != group {
+				groups = append(groups, g)
+			}
+		}
+		s.setGroups(id, groups)
+		res.MatchedCount++
+		res.UpdatedCount++
+	}
+	return res, nil
+}
+
+func (s *memStore) GetDevicesGroups(
+	ctx context.Context,
+	ids []model.DeviceID,
+) (map[model.DeviceID][]model.GroupName, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make(map[model.DeviceID][]model.GroupName, len(ids))
+	for _, id := range ids {
+		if dev, ok := s.devices[id]; ok {
+			res[id] = dev.Groups
+		}
+	}
+	return res, nil
+}
+
+func (s *memStore) GetDeviceGroups(
+	ctx context.Context,
+	id model.DeviceID,
+) ([]model.GroupName, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dev, ok := s.devices[id]
+	if !ok {
+		return nil, store.ErrDevNotFound
+	}
+	return dev.Groups, nil
+}
+
+func (s *memStore) ListGroups(
+	ctx context.Context,
+	filters []model.FilterPredicate,
+) ([]model.GroupName, error) {
+	if len(filters) > 0 {
+		panic("memStore: filtering the groups is not supported")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var groups []model.GroupName
+	seen := map[model.GroupName]bool{}
+	for _, dev := range s.sorted() {
+		for _, group := range dev.Groups {
+			if !seen[group] {
+				seen[group] = true
+				groups = append(groups, group)
+			}
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i] < groups[j] })
+	return groups, nil
+}
+
+func (s *memStore) GetDevicesByGroup(
+	ctx context.Context,
+	group model.GroupName,
+	q store.ListQuery,
+) ([]model.DeviceID, int, error) {
+	q.GroupName = string(group)
+	q.IDsOnly = true
+	devs, total, err := s.GetDevices(ctx, q)
+	if err != nil || total == 0 {
+		return nil, -1, store.ErrGroupNotFound
+	}
+	ids := make([]model.DeviceID, len(devs))
+	for n, dev := range devs {
+		ids[n] = dev.ID
+	}
+	return ids, total, nil
+}
+
+// GetLimits returns no limits: the requests are never limited.
+func (s *memStore) GetLimits(ctx context.Context) (model.Limits, error) {
+	return model.Limits{}, nil
+}
+
+// GetFeatureFlags returns no overrides: the features keep their defaults.
+func (s *memStore) GetFeatureFlags(ctx context.Context) (model.FeatureFlagSet, error) {
+	return model.FeatureFlagSet{}, nil
+}
+
+// GetAttributeDescriptions returns no descriptions: the attributes keep
+// the descriptions reported by the devices.
+func (s *memStore) GetAttributeDescriptions(
+	ctx context.Context,
+) (model.AttributeDescriptions, error) {
+	return model.AttributeDescriptions{}, nil
+}
+
+// GetDynamicGroups returns no groups: the corpus has static groups only.
+func (s *memStore) GetDynamicGroups(ctx context.Context) ([]model.DynamicGroup, error) {
+	return []model.DynamicGroup{}, nil
+}
+
+// GetDynamicGroup finds no group: the corpus has static groups only.
+func (s *memStore) GetDynamicGroup(
+	ctx context.Context,
+	name model.GroupName,
+) (*model.DynamicGroup, error) {
+	return nil, store.ErrDynamicGroupNotFound
+}
+
+// goldenRequest is a recorded request of the corpus.
+type goldenRequest struct {
+	Name    string            `json:"name"`
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// goldenCorpus is the corpus of an API version: the requests are replayed
+// in order against the store holding the devices.
+type goldenCorpus struct {
+	Devices  []model.Device  `json:"devices"`
+	Requests []goldenRequest `json:"requests"`
+}
+
+// formatGoldenResponse formats the response as the golden file: the status,
+// the recorded headers and the indented JSON body.
+func formatGoldenResponse(rec *httptest.ResponseRecorder) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%d %s\n", rec.Code, http.StatusText(rec.Code))
+	for _, hdr := range goldenHeaders {
+		for _, val := range rec.Header().Values(hdr) {
+			fmt.Fprintf(&buf, "%s: %s\n", hdr, val)
+		}
+	}
+	buf.WriteString("\n")
+	body := rec.Body.Bytes()
+	if json.Indent(&buf, body, "", "  ") != nil {
+		buf.Write(body)
+	}
+	if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+		buf.WriteString("\n")
+	}
+	return buf.Bytes()
+}
+
+func replayGoldenCorpus(t *testing.T, dir string) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, "requests.json"))
+	require.NoError(t, err)
+	var corpus goldenCorpus
+	require.NoError(t, json.Unmarshal(data, &corpus))
+
+	handler := makeMockApiHandler(t,
+		inventory.NewInventory(newMemStore(corpus.Devices)))
+	for _, req := range corpus.Requests {
+		r := httptest.NewRequest(req.Method, "http://localhost"+req.Path,
+			bytes.NewReader(req.Body))
+		if req.Body != nil {
+			r.Header.Set("Content-Type", "application/json")
+		}
+		for hdr, val := range req.Headers {
+			r.Header.Set(hdr, val)
+		}
+		r.Header.Set("X-Men-Requestid", "test")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		actual := formatGoldenResponse(rec)
+
+		golden := filepath.Join(dir, req.Name+".golden")
+		if *updateGolden {
+			require.NoError(t, ioutil.WriteFile(golden, actual, 0644))
+			continue
+		}
+		expected, err := ioutil.ReadFile(golden)
+		if !assert.NoError(t, err, "missing golden response; run with -update") {
+			continue
+		}
+		assert.Equal(t, string(expected), string(actual),
+			"%s %s: the response differs from %s", req.Method, req.Path, golden)
+	}
+}
+
+// TestGoldenResponses replays the recorded requests of each API version and
+// compares the responses with the golden ones, catching the changes in
+// the serialization and the pagination.
+func TestGoldenResponses(t *testing.T) {
+	versions, err := ioutil.ReadDir(goldenDir)
+	require.NoError(t, err)
+	for _, version := range versions {
+		if !version.IsDir() {
+			continue
+		}
+		dir := filepath.Join(goldenDir, version.Name())
+		t.Run(version.Name(), func(t *testing.T) {
+			replayGoldenCorpus(t, dir)
+		})
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/log"
+	u "github.com/mendersoftware/go-lib-micro/rest_utils"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/metrics"
+)
+
+// Priority is the class of the requests deciding which ones are rejected
+// first when the service approaches overload.
+type Priority string
+
+const (
+	// PriorityHigh covers the attribute reports of the devices and
+	// the internal service calls; they are never shed.
+	PriorityHigh Priority = "high"
+	// PriorityNormal covers the management requests modifying
+	// the inventory.
+	PriorityNormal Priority = "normal"
+	// PriorityLow covers the management reads, such as the dashboards.
+	PriorityLow Priority = "low"
+)
+
+// shedThresholds is the load, relative to the configured limits, from
+// which the requests of the priority class are rejected.
+var shedThresholds = map[Priority]float64{
+	PriorityLow:    0.6,
+	PriorityNormal: 0.8,
+}
+
+// latencyWindow is the age after which the latency of the completed
+// requests no longer counts towards the load, so that the service
+// recovers even if all the measured requests are being shed.
+const latencyWindow = time.Second
+
+// latencyWeight is the weight of the latest request in the moving
+// average of the latency.
+const latencyWeight = 0.1
+
+var ErrOverloaded = errors.New("the service is overloaded, retry later")
+
+var requestsShed = metrics.NewCounterVec(
+	"inventory_requests_shed_total",
+	"Number of requests rejected because of overload, by priority class.",
+	"priority",
+)
+
+// LoadSheddingMiddleware rejects the requests of the lower priority classes
+// with 429 Too Many Requests as the service approaches overload, keeping
+// the capacity for the device reports and the internal service calls.
+// The load is the higher of the number of requests in progress relative to
+// MaxInFlight and the average latency of the requests relative to
+// MaxLatency; a zero limit disables the respective signal. It is a route
+// middleware: the priority depends on the class of the endpoint.
+type LoadSheddingMiddleware struct {
+	MaxInFlight int
+	MaxLatency  time.Duration
+
+	mu        sync.Mutex
+	inFlight  int
+	latency   float64
+	latencyTs time.Time
+}
+
+func (mw *LoadSheddingMiddleware) MiddlewareFunc(h rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		priority := requestPriority(r)
+		if threshold, ok := shedThresholds[priority]; ok &&
+			mw.load(time.Now()) >= threshold {
+			requestsShed.Inc(string(priority))
+			l := log.FromContext(r.Context())
+			w.Header().Set(hdrRetryAfter, "1")
+			u.RestErrWithLog(w, r, l, ErrOverloaded, http.StatusTooManyRequests)
+			return
+		}
+		if r.URL.Path == urlGroupsCountsStream {
+			// the streams last until the clients leave
+			h(w, r)
+			return
+		}
+
+		mw.mu.Lock()
+		mw.inFlight++
+		mw.mu.Unlock()
+		defer mw.done(time.Now())
+		h(w, r)
+	}
+}
+
+// load returns the current load relative to the configured limits.
+func (mw *LoadSheddingMiddleware) load(now time.Time) float64 {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+	var load float64
+	if mw.MaxInFlight > 0 {
+		load = float64(mw.inFlight) / float64(mw.MaxInFlight)
+	}
+	if mw.MaxLatency > 0 && now.Sub(mw.latencyTs) <= latencyWindow {
+		if l := mw.latency / float64(mw.MaxLatency); l > load {
+			load = l
+		}
+	}
+	return load
+}
+
+// done records the completion of the request started at the given time.
+func (mw *LoadSheddingMiddleware) done(start time.Time) {
+	end := time.Now()
+	latency := float64(end.Sub(start))
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+	mw.inFlight--
+	if end.Sub(mw.latencyTs) > latencyWindow {
+		mw.latency = latency
+	} else {
+		mw.latency += latencyWeight * (latency - mw.latency)
+	}
+	mw.latencyTs = end
+}
+
+// requestPriority returns the priority class of the endpoint serving
+// the request.
+func requestPriority(r *rest.Request) Priority {
+	class, _ := endpointClassFromContext(r.Context())
+	switch {
+	case class == EndpointClassDevice,
+		RouteGroupOf(r.URL.Path) == RouteGroupInternal:
+		return PriorityHigh
+	case class == EndpointClassRead:
+		return PriorityLow
+	default:
+		return PriorityNormal
+	}
+}
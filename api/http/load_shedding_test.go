@@ -0,0 +1,155 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/mendersoftware/go-lib-micro/requestid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadSheddingMiddleware(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		class    EndpointClass
+		method   string
+		path     string
+		inFlight int
+		latency  time.Duration
+		stale    bool
+
+		code int
+	}{
+		"ok, read under load": {
+			class:    EndpointClassRead,
+			method:   http.MethodGet,
+			path:     uriDevices,
+			inFlight: 5,
+			code:     http.StatusOK,
+		},
+		"ok, write under load": {
+			class:    EndpointClassGroups,
+			method:   http.MethodPut,
+			path:     uriDevice + "/group",
+			inFlight: 7,
+			code:     http.StatusOK,
+		},
+		"ok, stale latency": {
+			class:   EndpointClassRead,
+			method:  http.MethodGet,
+			path:    uriDevices,
+			latency: 5 * time.Second,
+			stale:   true,
+			code:    http.StatusOK,
+		},
+		"ok, device report on overload": {
+			class:    EndpointClassDevice,
+			method:   http.MethodPatch,
+			path:     uriAttributes,
+			inFlight: 20,
+			code:     http.StatusOK,
+		},
+		"ok, internal call on overload": {
+			class:    EndpointClassAdmin,
+			method:   http.MethodGet,
+			path:     uriInternalDevices,
+			inFlight: 20,
+			code:     http.StatusOK,
+		},
+		"shed, read": {
+			class:    EndpointClassRead,
+			method:   http.MethodGet,
+			path:     uriDevices,
+			inFlight: 6,
+			code:     http.StatusTooManyRequests,
+		},
+		"shed, search": {
+			class:   EndpointClassRead,
+			method:  http.MethodPost,
+			path:    urlFiltersSearch,
+			latency: 700 * time.Millisecond,
+			code:    http.StatusTooManyRequests,
+		},
+		"shed, write": {
+			class:    EndpointClassAdmin,
+			method:   http.MethodDelete,
+			path:     uriDevices + "/1",
+			inFlight: 8,
+			code:     http.StatusTooManyRequests,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			mw := &LoadSheddingMiddleware{
+				MaxInFlight: 10,
+				MaxLatency:  time.Second,
+				inFlight:    tc.inFlight,
+			}
+			if tc.latency > 0 {
+				mw.latency = float64(tc.latency)
+				mw.latencyTs = time.Now()
+				if tc.stale {
+					mw.latencyTs = mw.latencyTs.Add(-2 * latencyWindow)
+				}
+			}
+			api := rest.NewApi()
+			api.Use(&requestid.RequestIdMiddleware{})
+			api.SetApp(routedApp(t, tc.class, tc.method, tc.path, mw))
+
+			req, _ := http.NewRequest(tc.method, "http://localhost"+tc.path, nil)
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.code)
+			if tc.code == http.StatusTooManyRequests {
+				recorded.HeaderIs(hdrRetryAfter, "1")
+				assert.Equal(t, tc.inFlight, mw.inFlight)
+			}
+		})
+	}
+}
+
+func TestLoadSheddingMiddlewareInFlight(t *testing.T) {
+	t.Parallel()
+
+	mw := &LoadSheddingMiddleware{MaxInFlight: 10}
+	var inFlight int
+	app, err := makeRouter([]route{{EndpointClassRead, rest.Get(uriDevices,
+		func(w rest.ResponseWriter, r *rest.Request) {
+			mw.mu.Lock()
+			inFlight = mw.inFlight
+			mw.mu.Unlock()
+			w.WriteHeader(http.StatusOK)
+		})}}, RouteGroups, []rest.Middleware{mw})
+	assert.NoError(t, err)
+	api := rest.NewApi()
+	api.Use(&requestid.RequestIdMiddleware{})
+	api.SetApp(app)
+
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost"+uriDevices, nil)
+	recorded := test.RunRequest(t, api.MakeHandler(), req)
+	recorded.CodeIs(http.StatusOK)
+	assert.Equal(t, 1, inFlight)
+	assert.Equal(t, 0, mw.inFlight)
+	assert.Equal(t, 0.0, mw.load(time.Now()))
+}
@@ -0,0 +1,45 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package http
+
+import (
+	"net/http"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/log"
+	u "github.com/mendersoftware/go-lib-micro/rest_utils"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/verifier"
+)
+
+var ErrRequestNotAuthenticated = errors.New("the request could not be authenticated")
+
+// VerifierMiddleware rejects the requests the verifier of the listener does
+// not authenticate. The cause is logged and not disclosed to the caller.
+type VerifierMiddleware struct {
+	Verifier verifier.Verifier
+}
+
+func (mw *VerifierMiddleware) MiddlewareFunc(h rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		if err := mw.Verifier.Verify(r.Request); err != nil {
+			l := log.FromContext(r.Context())
+			l.Warnf("request verification failed: %s", err.Error())
+			u.RestErrWithLog(w, r, l, ErrRequestNotAuthenticated, http.StatusUnauthorized)
+			return
+		}
+		h(w, r)
+	}
+}
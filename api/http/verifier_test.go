@@ -0,0 +1,75 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package http
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/mendersoftware/go-lib-micro/requestid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
+
+	mverifier "github.com/mendersoftware/inventory/verifier/mocks"
+)
+
+func TestVerifierMiddleware(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		err error
+
+		code int
+	}{
+		"ok": {
+			code: http.StatusOK,
+		},
+		"error, not verified": {
+			err:  errors.New("token expired"),
+			code: http.StatusUnauthorized,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			v := &mverifier.Verifier{}
+			v.On("Verify", mock.AnythingOfType("*http.Request")).
+				Return(tc.err)
+			defer v.AssertExpectations(t)
+
+			api := rest.NewApi()
+			api.Use(
+				&requestid.RequestIdMiddleware{},
+				&VerifierMiddleware{Verifier: v},
+			)
+			api.SetApp(rest.AppSimple(func(w rest.ResponseWriter, r *rest.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req, _ := http.NewRequest(http.MethodGet, "http://localhost"+urlFiltersAttributes, nil)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.code)
+			if tc.err != nil {
+				recorded.BodyIs(`{"error":"` + ErrRequestNotAuthenticated.Error() +
+					`","request_id":"test"}`)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+// Package blob stores the files produced by the inventory, such as
+// the analytics exports, for the other systems to pick up.
+package blob
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var ErrInvalidKey = errors.New("invalid object key")
+
+// Store keeps the objects under slash-separated keys.
+//go:generate ../utils/mockgen.sh
+type Store interface {
+	// Put stores the content read from r under the key, replacing
+	// the existing object, if any.
+	Put(ctx context.Context, key string, r io.Reader) error
+}
+
+// FileStore keeps the objects as files in a directory, typically a volume
+// shared with, or synchronized to, the object storage.
+type FileStore struct {
+	dir string
+}
+
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{dir: dir}
+}
+
+// Put writes the object to a temporary file and moves it in place once
+// complete, so that partial objects are never visible under the key.
+func (s *FileStore) Put(ctx context.Context, key string, r io.Reader) error {
+	if key == "" || path.IsAbs(key) || path.Clean(key) != key ||
+		key == ".." || strings.HasPrefix(key, "../") {
+		return ErrInvalidKey
+	}
+	dst := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return errors.Wrap(err, "failed to create the object directory")
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(dst), ".tmp-")
+	if err != nil {
+		return errors.Wrap(err, "failed to create the object")
+	}
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return errors.Wrap(err, "failed to write the object")
+	}
+	if err := os.Rename(f.Name(), dst); err != nil {
+		os.Remove(f.Name())
+		return errors.Wrap(err, "failed to store the object")
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package blob
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("read error")
+}
+
+func TestFileStorePut(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blob")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+
+	s := NewFileStore(dir)
+	ctx := context.Background()
+
+	err = s.Put(ctx, "tenant/export.parquet", strings.NewReader("data"))
+	assert.NoError(t, err)
+	data, err := ioutil.ReadFile(filepath.Join(dir, "tenant", "export.parquet"))
+	assert.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+
+	// objects are replaced, and failed writes leave no partial objects
+	err = s.Put(ctx, "tenant/export.parquet", strings.NewReader("new"))
+	assert.NoError(t, err)
+	err = s.Put(ctx, "tenant/export.parquet", failingReader{})
+	assert.EqualError(t, err, "failed to write the object: read error")
+	data, err = ioutil.ReadFile(filepath.Join(dir, "tenant", "export.parquet"))
+	assert.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+	files, err := ioutil.ReadDir(filepath.Join(dir, "tenant"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	for _, key := range []string{"", "/etc/passwd", "../x", "a/../../x", "a//b"} {
+		err = s.Put(ctx, key, strings.NewReader("data"))
+		assert.Equal(t, ErrInvalidKey, err, key)
+	}
+}
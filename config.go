@@ -21,6 +21,25 @@ const (
 	SettingListen        = "listen"
 	SettingListenDefault = ":8080"
 
+	// addresses of the listeners of the route groups; the groups without
+	// an address of their own are served on SettingListen
+	SettingListenDevices    = "listen_devices"
+	SettingListenManagement = "listen_management"
+	SettingListenInternal   = "listen_internal"
+
+	// suffixes of the settings of the listeners of the route groups,
+	// e.g. listen_internal_tls_cert
+	SettingListenTLSCertSuffix     = "_tls_cert"
+	SettingListenTLSKeySuffix      = "_tls_key"
+	SettingListenTLSClientCASuffix = "_tls_client_ca"
+	SettingListenMiddlewareSuffix  = "_middleware"
+
+	// suffixes of the settings of the verifiers authenticating
+	// the requests of the listeners, e.g. listen_management_verifier
+	SettingListenVerifierSuffix   = "_verifier"
+	SettingListenJWKSURLSuffix    = "_jwks_url"
+	SettingListenOIDCIssuerSuffix = "_oidc_issuer"
+
 	SettingMiddleware        = "middleware"
 	SettingMiddlewareDefault = EnvProd
 
@@ -33,8 +52,92 @@ const (
 	SettingDbSSLSkipVerify        = "mongo_ssl_skipverify"
 	SettingDbSSLSkipVerifyDefault = false
 
+	SettingFIPSRequired        = "fips_required"
+	SettingFIPSRequiredDefault = false
+
 	SettingDbUsername = "mongo_username"
 	SettingDbPassword = "mongo_password"
+
+	SettingDbUnavailableThreshold        = "mongo_unavailable_threshold"
+	SettingDbUnavailableThresholdDefault = 30
+
+	SettingDbSearchExplainSampleRate        = "mongo_search_explain_sample_rate"
+	SettingDbSearchExplainSampleRateDefault = 0
+
+	SettingDbCursorMaxAge        = "mongo_cursor_max_age"
+	SettingDbCursorMaxAgeDefault = 3600
+
+	SettingDbAttributeCompressionThreshold        = "mongo_attribute_compression_threshold"
+	SettingDbAttributeCompressionThresholdDefault = 0
+
+	SettingDbName              = "mongo_db_name"
+	SettingDbTenantPrefix      = "mongo_tenant_db_prefix"
+	SettingDbDevicesCollection = "mongo_devices_collection"
+
+	SettingSchemaRolloutGroups        = "schema_rollout_groups"
+	SettingSchemaRolloutGroupsDefault = false
+
+	SettingFeatureFlags = "feature_flags"
+
+	SettingAuthzPolicy             = "authorization_policy"
+	SettingAuthzDefaultRole        = "authorization_default_role"
+	SettingAuthzHiddenAttributes   = "authorization_hidden_attributes"
+	SettingAuthzReadOnlyAttributes = "authorization_read_only_attributes"
+
+	SettingDeviceTokenVerification        = "device_token_verification"
+	SettingDeviceTokenVerificationDefault = false
+
+	SettingRetentionSweepInterval        = "retention_sweep_interval"
+	SettingRetentionSweepIntervalDefault = 3600
+
+	SettingCompletenessAlertsInterval        = "completeness_alerts_interval"
+	SettingCompletenessAlertsIntervalDefault = 600
+
+	SettingDigestsInterval        = "digests_interval"
+	SettingDigestsIntervalDefault = 600
+
+	SettingEventsWebhookURL        = "events_webhook_url"
+	SettingEventsWebhookURLDefault = ""
+
+	SettingSubscriptionsWorker        = "subscriptions_worker"
+	SettingSubscriptionsWorkerDefault = false
+
+	SettingNotificationsEmailConnectorURL        = "notifications_email_connector_url"
+	SettingNotificationsEmailConnectorURLDefault = ""
+
+	SettingExportsDir        = "exports_dir"
+	SettingExportsDirDefault = ""
+
+	SettingRemoteWriteURL        = "remote_write_url"
+	SettingRemoteWriteURLDefault = ""
+
+	SettingRemoteWriteAttributes = "remote_write_attributes"
+
+	SettingDeploymentDiffAttributes = "deployment_diff_attributes"
+
+	SettingCacheMaxAge        = "cache_max_age"
+	SettingCacheMaxAgeDefault = 10
+
+	SettingSoftLimitPerPage        = "soft_limit_per_page"
+	SettingSoftLimitPerPageDefault = 0
+
+	SettingSoftLimitFilters        = "soft_limit_filters"
+	SettingSoftLimitFiltersDefault = 0
+
+	SettingSoftLimitExportDevices        = "soft_limit_export_devices"
+	SettingSoftLimitExportDevicesDefault = 0
+
+	SettingTimelineConcurrency        = "timeline_concurrency"
+	SettingTimelineConcurrencyDefault = 4
+
+	SettingDeviceTelemetry        = "device_telemetry"
+	SettingDeviceTelemetryDefault = true
+
+	SettingLoadSheddingMaxInFlight        = "load_shedding_max_in_flight"
+	SettingLoadSheddingMaxInFlightDefault = 0
+
+	SettingLoadSheddingMaxLatency        = "load_shedding_max_latency"
+	SettingLoadSheddingMaxLatencyDefault = 0
 )
 
 var (
@@ -45,5 +148,29 @@ var (
 		{Key: SettingDb, Value: SettingDbDefault},
 		{Key: SettingDbSSL, Value: SettingDbSSLDefault},
 		{Key: SettingDbSSLSkipVerify, Value: SettingDbSSLSkipVerifyDefault},
+		{Key: SettingFIPSRequired, Value: SettingFIPSRequiredDefault},
+		{Key: SettingDbUnavailableThreshold, Value: SettingDbUnavailableThresholdDefault},
+		{Key: SettingDbSearchExplainSampleRate, Value: SettingDbSearchExplainSampleRateDefault},
+		{Key: SettingDbCursorMaxAge, Value: SettingDbCursorMaxAgeDefault},
+		{Key: SettingDbAttributeCompressionThreshold, Value: SettingDbAttributeCompressionThresholdDefault},
+		{Key: SettingSchemaRolloutGroups, Value: SettingSchemaRolloutGroupsDefault},
+		{Key: SettingDeviceTokenVerification, Value: SettingDeviceTokenVerificationDefault},
+		{Key: SettingRetentionSweepInterval, Value: SettingRetentionSweepIntervalDefault},
+		{Key: SettingCompletenessAlertsInterval, Value: SettingCompletenessAlertsIntervalDefault},
+		{Key: SettingDigestsInterval, Value: SettingDigestsIntervalDefault},
+		{Key: SettingEventsWebhookURL, Value: SettingEventsWebhookURLDefault},
+		{Key: SettingSubscriptionsWorker, Value: SettingSubscriptionsWorkerDefault},
+		{Key: SettingNotificationsEmailConnectorURL,
+			Value: SettingNotificationsEmailConnectorURLDefault},
+		{Key: SettingExportsDir, Value: SettingExportsDirDefault},
+		{Key: SettingRemoteWriteURL, Value: SettingRemoteWriteURLDefault},
+		{Key: SettingCacheMaxAge, Value: SettingCacheMaxAgeDefault},
+		{Key: SettingSoftLimitPerPage, Value: SettingSoftLimitPerPageDefault},
+		{Key: SettingSoftLimitFilters, Value: SettingSoftLimitFiltersDefault},
+		{Key: SettingSoftLimitExportDevices, Value: SettingSoftLimitExportDevicesDefault},
+		{Key: SettingTimelineConcurrency, Value: SettingTimelineConcurrencyDefault},
+		{Key: SettingDeviceTelemetry, Value: SettingDeviceTelemetryDefault},
+		{Key: SettingLoadSheddingMaxInFlight, Value: SettingLoadSheddingMaxInFlightDefault},
+		{Key: SettingLoadSheddingMaxLatency, Value: SettingLoadSheddingMaxLatencyDefault},
 	}
 )
@@ -55,6 +55,7 @@ type Reader interface {
 	GetString(key string) string
 	GetStringMap(key string) map[string]interface{}
 	GetStringMapString(key string) map[string]string
+	GetStringMapStringSlice(key string) map[string][]string
 	GetStringSlice(key string) []string
 	GetTime(key string) time.Time
 	GetDuration(key string) time.Duration
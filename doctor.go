@@ -0,0 +1,122 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/urfave/cli"
+
+	"github.com/mendersoftware/inventory/store/mongo"
+)
+
+const (
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorGray   = "\033[90m"
+)
+
+var checkLabels = map[mongo.CheckStatus]struct {
+	label string
+	color string
+}{
+	mongo.CheckOK:      {"[ OK ]", colorGreen},
+	mongo.CheckWarning: {"[WARN]", colorYellow},
+	mongo.CheckFailed:  {"[FAIL]", colorRed},
+	mongo.CheckSkipped: {"[SKIP]", colorGray},
+}
+
+// doctorReport is the report of the doctor command.
+type doctorReport struct {
+	Version string        `json:"version"`
+	Healthy bool          `json:"healthy"`
+	Checks  []mongo.Check `json:"checks"`
+}
+
+func makeDoctorReport(report *mongo.DiagnosticsReport) doctorReport {
+	return doctorReport{
+		Version: CreateVersionString(),
+		Healthy: report.Healthy(),
+		Checks:  report.Checks,
+	}
+}
+
+// writeDoctorReport writes the report for a human, colored for
+// the terminals.
+func writeDoctorReport(w io.Writer, report doctorReport, color bool) error {
+	paint := func(color, s string) string { return s }
+	if color {
+		paint = func(color, s string) string { return color + s + colorReset }
+	}
+	if _, err := fmt.Fprintf(w, "Inventory Service, version %s\n\n",
+		report.Version); err != nil {
+		return err
+	}
+	for _, check := range report.Checks {
+		label := checkLabels[check.Status]
+		if _, err := fmt.Fprintf(w, "%s %s: %s\n",
+			paint(label.color, label.label), check.Name, check.Message); err != nil {
+			return err
+		}
+		if check.Hint != "" {
+			if _, err := fmt.Fprintf(w, "       hint: %s\n", check.Hint); err != nil {
+				return err
+			}
+		}
+	}
+	summary := paint(colorGreen, "no problem found")
+	if !report.Healthy {
+		summary = paint(colorRed, "problems found")
+	}
+	_, err := fmt.Fprintf(w, "\n%s\n", summary)
+	return err
+}
+
+// isTerminal returns true if the file is a character device.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func cmdDoctor(args *cli.Context) error {
+	report := makeDoctorReport(
+		mongo.Diagnose(context.Background(), makeDataStoreConfig()),
+	)
+
+	var err error
+	if args.Bool("json") {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		color := !args.Bool("no-color") && isTerminal(os.Stdout)
+		err = writeDoctorReport(os.Stdout, report, color)
+	}
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("failed to write the report: %v", err),
+			7)
+	}
+	if !report.Healthy {
+		return cli.NewExitError("", 7)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/inventory/store/mongo"
+)
+
+func TestWriteDoctorReport(t *testing.T) {
+	report := doctorReport{
+		Version: "1.0.0",
+		Healthy: false,
+		Checks: []mongo.Check{
+			{Name: "connectivity", Status: mongo.CheckOK, Message: "connected"},
+			{
+				Name:    "schema version",
+				Status:  mongo.CheckFailed,
+				Message: "1 databases older than 1.0.6",
+				Hint:    "run `inventory migrate`",
+			},
+			{Name: "indexes", Status: mongo.CheckSkipped, Message: "not migrated"},
+		},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, writeDoctorReport(&buf, report, false))
+	assert.Equal(t, "Inventory Service, version 1.0.0\n\n"+
+		"[ OK ] connectivity: connected\n"+
+		"[FAIL] schema version: 1 databases older than 1.0.6\n"+
+		"       hint: run `inventory migrate`\n"+
+		"[SKIP] indexes: not migrated\n"+
+		"\nproblems found\n", buf.String())
+
+	buf.Reset()
+	report.Healthy = true
+	report.Checks = report.Checks[:1]
+	assert.NoError(t, writeDoctorReport(&buf, report, true))
+	assert.Equal(t, "Inventory Service, version 1.0.0\n\n"+
+		"\033[32m[ OK ]\033[0m connectivity: connected\n"+
+		"\n\033[32mno problem found\033[0m\n", buf.String())
+}
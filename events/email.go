@@ -0,0 +1,256 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+// defaultSMTPPort is the port of the SMTP servers configured without one.
+const defaultSMTPPort = "25"
+
+// Email is an email notification about an event.
+type Email struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	// Body is the text rendered for the event, empty for the events
+	// without a text rendering.
+	Body  string `json:"body,omitempty"`
+	Event Event  `json:"event"`
+}
+
+// EmailConnector sends the email notifications.
+type EmailConnector interface {
+	SendEmail(ctx context.Context, email Email) error
+}
+
+// NewEmailConnector returns the connector for the URL: an HTTP(S) email
+// connector service, or an SMTP server configured as
+// smtp://[user:password@]host[:port]?from=address. With an empty URL,
+// the connector is nil.
+func NewEmailConnector(rawURL string) (EmailConnector, error) {
+	if rawURL == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid email connector URL")
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return NewHTTPEmailConnector(rawURL), nil
+	case "smtp":
+		return NewSMTPEmailConnector(u)
+	}
+	return nil, errors.Errorf("unsupported email connector: %s", u.Scheme)
+}
+
+// HTTPEmailConnector posts the emails as JSON to an email connector
+// service.
+type HTTPEmailConnector struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPEmailConnector(url string) *HTTPEmailConnector {
+	return &HTTPEmailConnector{
+		url:    url,
+		client: &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+func (c *HTTPEmailConnector) SendEmail(ctx context.Context, email Email) error {
+	body, err := json.Marshal(email)
+	if err != nil {
+		return errors.Wrap(err, "failed to serialize the notification")
+	}
+	req, err := http.NewRequestWithContext(ctx,
+		http.MethodPost, c.url, bytes.NewReader(body),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to prepare the connector request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	rsp, err := c.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to deliver the notification")
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode >= 300 {
+		return errors.Errorf(
+			"failed to deliver the notification: connector responded with %s",
+			rsp.Status,
+		)
+	}
+	return nil
+}
+
+// SMTPEmailConnector sends the emails as plain text through an SMTP
+// server, upgrading the connection to TLS if the server supports it.
+type SMTPEmailConnector struct {
+	addr    string
+	host    string
+	from    string
+	auth    smtp.Auth
+	timeout time.Duration
+}
+
+func NewSMTPEmailConnector(u *url.URL) (*SMTPEmailConnector, error) {
+	from := u.Query().Get("from")
+	if from == "" {
+		return nil, errors.New("the SMTP connector requires a from address")
+	}
+	port := u.Port()
+	if port == "" {
+		port = defaultSMTPPort
+	}
+	c := &SMTPEmailConnector{
+		addr:    net.JoinHostPort(u.Hostname(), port),
+		host:    u.Hostname(),
+		from:    from,
+		timeout: defaultTimeout,
+	}
+	if u.User != nil {
+		password, _ := u.User.Password()
+		c.auth = smtp.PlainAuth("", u.User.Username(), password, c.host)
+	}
+	return c, nil
+}
+
+func (c *SMTPEmailConnector) SendEmail(ctx context.Context, email Email) error {
+	msg, err := c.message(email)
+	if err != nil {
+		return err
+	}
+	dialer := net.Dialer{Timeout: c.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return errors.Wrap(err, "failed to connect to the SMTP server")
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(c.timeout)
+	}
+	_ = conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, c.host)
+	if err != nil {
+		conn.Close()
+		return errors.Wrap(err, "failed to connect to the SMTP server")
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		err = client.StartTLS(&tls.Config{ServerName: c.host})
+		if err != nil {
+			return errors.Wrap(err, "failed to start TLS with the SMTP server")
+		}
+	}
+	if c.auth != nil {
+		if err = client.Auth(c.auth); err != nil {
+			return errors.Wrap(err, "failed to authenticate to the SMTP server")
+		}
+	}
+	if err = client.Mail(c.from); err != nil {
+		return errors.Wrap(err, "failed to send the email")
+	}
+	if err = client.Rcpt(email.To); err != nil {
+		return errors.Wrap(err, "failed to send the email")
+	}
+	w, err := client.Data()
+	if err != nil {
+		return errors.Wrap(err, "failed to send the email")
+	}
+	if _, err = w.Write(msg); err != nil {
+		return errors.Wrap(err, "failed to send the email")
+	}
+	if err = w.Close(); err != nil {
+		return errors.Wrap(err, "failed to send the email")
+	}
+	return client.Quit()
+}
+
+// message returns the email as a plain text message; the events without
+// a text rendering are sent as JSON.
+func (c *SMTPEmailConnector) message(email Email) ([]byte, error) {
+	body := email.Body
+	if body == "" {
+		b, err := json.MarshalIndent(email.Event, "", "  ")
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to serialize the notification")
+		}
+		body = string(b)
+	}
+	var msg bytes.Buffer
+	for _, hdr := range [][2]string{
+		{"From", c.from},
+		{"To", email.To},
+		{"Subject", mime.QEncoding.Encode("utf-8", email.Subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "text/plain; charset=utf-8"},
+	} {
+		fmt.Fprintf(&msg, "%s: %s\r\n", hdr[0], hdr[1])
+	}
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return msg.Bytes(), nil
+}
+
+var digestTemplate = template.Must(template.New("digest").Parse(
+	`Inventory digest from {{ .From.Format "2006-01-02 15:04 MST" }} ` +
+		`to {{ .To.Format "2006-01-02 15:04 MST" }}
+
+Devices:             {{ .Devices }}
+New devices:         {{ .NewDevices }}
+Devices gone silent: {{ .SilentDevices }}
+{{ if .GroupChanges }}
+Group changes:
+{{- range .GroupChanges }}
+  {{ .Group }}: {{ .Joined }} joined, {{ .Left }} left
+{{- end }}
+{{ else }}
+No group changes.
+{{ end -}}
+`))
+
+// emailBody renders the text of the event, or returns an empty string
+// for the events without a text rendering.
+func emailBody(event Event) string {
+	switch event.Type {
+	case TypeFleetDigest:
+		if d, ok := event.Data.(model.FleetDigest); ok {
+			var buf bytes.Buffer
+			if err := digestTemplate.Execute(&buf, d); err == nil {
+				return buf.String()
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,230 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+// Package events implements the delivery of the inventory change events.
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/mongo/oid"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/utils/reqctx"
+)
+
+const (
+	TypeDeviceGroupJoined = "device.group.joined"
+	TypeDeviceGroupLeft   = "device.group.left"
+
+	TypeDeviceDeploymentDiff = "device.deployment.diff"
+
+	TypeSubscriptionDeviceChanged = "subscription.device.changed"
+	TypeSubscriptionDeviceMatched = "subscription.device.matched"
+
+	TypeDevicesIncomplete = "devices.incomplete"
+
+	TypeFleetDigest = "fleet.digest"
+
+	defaultTimeout = 10 * time.Second
+)
+
+// Event is a notification about a change in the inventory.
+type Event struct {
+	ID       string      `json:"id"`
+	Type     string      `json:"type"`
+	TenantID string      `json:"tenant_id,omitempty"`
+	Time     time.Time   `json:"time"`
+	Data     interface{} `json:"data"`
+}
+
+// New returns an event of the given type concerning the tenant in
+// the context.
+func New(ctx context.Context, eventType string, data interface{}) Event {
+	return Event{
+		ID:       oid.NewUUIDv4().String(),
+		Type:     eventType,
+		TenantID: reqctx.FromContext(ctx).TenantID,
+		Time:     time.Now(),
+		Data:     data,
+	}
+}
+
+//go:generate ../utils/mockgen.sh
+type Emitter interface {
+	Emit(ctx context.Context, events ...Event) error
+}
+
+// WebhookEmitter delivers the events to a webhook as a JSON array.
+type WebhookEmitter struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookEmitter(url string) *WebhookEmitter {
+	return &WebhookEmitter{
+		url:    url,
+		client: &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+func (e *WebhookEmitter) Emit(ctx context.Context, events ...Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(events)
+	if err != nil {
+		return errors.Wrap(err, "failed to serialize events")
+	}
+	return e.deliver(ctx, body)
+}
+
+// DeliveryError is returned when the delivery to a webhook failed;
+// it carries the payload, for the delivery to be replayed later.
+type DeliveryError struct {
+	URL     string
+	Payload []byte
+	Err     error
+}
+
+func (e *DeliveryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DeliveryError) Unwrap() error {
+	return e.Err
+}
+
+func (e *WebhookEmitter) deliver(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx,
+		http.MethodPost, e.url, bytes.NewReader(body),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to prepare the webhook request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	rsp, err := e.client.Do(req)
+	if err != nil {
+		return &DeliveryError{
+			URL:     e.url,
+			Payload: body,
+			Err:     errors.Wrap(err, "failed to deliver events"),
+		}
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode >= 300 {
+		return &DeliveryError{
+			URL:     e.url,
+			Payload: body,
+			Err: errors.Errorf(
+				"failed to deliver events: webhook responded with %s",
+				rsp.Status,
+			),
+		}
+	}
+	return nil
+}
+
+var ErrEmailConnectorMissing = errors.New("no email connector configured")
+
+//go:generate ../utils/mockgen.sh
+type Notifier interface {
+	// Notify delivers the event to the notification channel.
+	Notify(ctx context.Context, channel model.NotificationChannel, event Event) error
+	// Redeliver posts the payload of a failed delivery to the webhook
+	// again.
+	Redeliver(ctx context.Context, url string, payload []byte) error
+}
+
+// ChannelNotifier delivers the events to the webhooks of the subscriptions,
+// or as emails through the email connector.
+type ChannelNotifier struct {
+	email  EmailConnector
+	client *http.Client
+}
+
+// NewChannelNotifier returns a notifier sending the emails through
+// the connector; with a nil connector, emails are not supported.
+func NewChannelNotifier(email EmailConnector) *ChannelNotifier {
+	return &ChannelNotifier{
+		email:  email,
+		client: &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+func (n *ChannelNotifier) Notify(
+	ctx context.Context,
+	channel model.NotificationChannel,
+	event Event,
+) error {
+	switch channel.Type {
+	case model.NotificationChannelWebhook:
+		webhook := &WebhookEmitter{url: channel.URL, client: n.client}
+		return webhook.Emit(ctx, event)
+	case model.NotificationChannelEmail:
+		if n.email == nil {
+			return ErrEmailConnectorMissing
+		}
+		return n.email.SendEmail(ctx, Email{
+			To:      channel.Email,
+			Subject: emailSubject(event),
+			Body:    emailBody(event),
+			Event:   event,
+		})
+	}
+	return errors.Errorf("unsupported notification channel: %s", channel.Type)
+}
+
+func (n *ChannelNotifier) Redeliver(
+	ctx context.Context,
+	url string,
+	payload []byte,
+) error {
+	webhook := &WebhookEmitter{url: url, client: n.client}
+	return webhook.deliver(ctx, payload)
+}
+
+func emailSubject(event Event) string {
+	var deviceID model.DeviceID
+	if n, ok := event.Data.(model.Notification); ok {
+		deviceID = n.DeviceID
+	}
+	switch event.Type {
+	case TypeDevicesIncomplete:
+		if n, ok := event.Data.(model.IncompleteDevices); ok {
+			return fmt.Sprintf(
+				"%d device(s) missing required attributes after %d hour(s)",
+				len(n.Devices), n.AfterHours,
+			)
+		}
+	case TypeFleetDigest:
+		if d, ok := event.Data.(model.FleetDigest); ok {
+			return fmt.Sprintf(
+				"Inventory digest: %d new device(s), %d device(s) gone silent",
+				d.NewDevices, d.SilentDevices,
+			)
+		}
+	case TypeSubscriptionDeviceChanged:
+		return fmt.Sprintf("Device %s changed", deviceID)
+	case TypeSubscriptionDeviceMatched:
+		return fmt.Sprintf("Device %s matches your subscription", deviceID)
+	}
+	return "Inventory notification"
+}
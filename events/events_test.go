@@ -0,0 +1,266 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+func TestNew(t *testing.T) {
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "tenant",
+	})
+	event := New(ctx, TypeDeviceGroupJoined, "data")
+	assert.NotEmpty(t, event.ID)
+	assert.Equal(t, TypeDeviceGroupJoined, event.Type)
+	assert.Equal(t, "tenant", event.TenantID)
+	assert.False(t, event.Time.IsZero())
+	assert.Equal(t, "data", event.Data)
+
+	event = New(context.Background(), TypeDeviceGroupLeft, nil)
+	assert.Empty(t, event.TenantID)
+}
+
+func TestWebhookEmitter(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		events []Event
+		status int
+
+		calls int
+		err   string
+	}{
+		"ok": {
+			events: []Event{
+				{ID: "1", Type: TypeDeviceGroupLeft},
+				{ID: "2", Type: TypeDeviceGroupJoined},
+			},
+			status: http.StatusAccepted,
+			calls:  1,
+		},
+		"ok, no events": {
+			status: http.StatusInternalServerError,
+		},
+		"error, webhook failure": {
+			events: []Event{{ID: "1", Type: TypeDeviceGroupLeft}},
+			status: http.StatusInternalServerError,
+			calls:  1,
+			err: "failed to deliver events: " +
+				"webhook responded with 500 Internal Server Error",
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			calls := 0
+			srv := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					calls++
+					assert.Equal(t, http.MethodPost, r.Method)
+					assert.Equal(t, "application/json",
+						r.Header.Get("Content-Type"))
+					var received []Event
+					err := json.NewDecoder(r.Body).Decode(&received)
+					assert.NoError(t, err)
+					assert.Len(t, received, len(tc.events))
+					w.WriteHeader(tc.status)
+				},
+			))
+			defer srv.Close()
+
+			emitter := NewWebhookEmitter(srv.URL)
+			err := emitter.Emit(context.Background(), tc.events...)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				var derr *DeliveryError
+				if assert.True(t, errors.As(err, &derr)) {
+					assert.Equal(t, srv.URL, derr.URL)
+					payload, _ := json.Marshal(tc.events)
+					assert.Equal(t, payload, derr.Payload)
+				}
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.calls, calls)
+		})
+	}
+}
+
+func TestChannelNotifier(t *testing.T) {
+	t.Parallel()
+
+	event := Event{
+		ID:   "1",
+		Type: TypeSubscriptionDeviceMatched,
+		Data: model.Notification{SubscriptionID: "sub", DeviceID: "dev"},
+	}
+	testCases := map[string]struct {
+		channel   model.NotificationChannel
+		connector bool
+		status    int
+
+		path string
+		err  string
+	}{
+		"ok, webhook": {
+			channel: model.NotificationChannel{Type: model.NotificationChannelWebhook},
+			status:  http.StatusOK,
+			path:    "/webhook",
+		},
+		"ok, email": {
+			channel: model.NotificationChannel{
+				Type:  model.NotificationChannelEmail,
+				Email: "user@example.com",
+			},
+			connector: true,
+			status:    http.StatusAccepted,
+			path:      "/email",
+		},
+		"error, email connector missing": {
+			channel: model.NotificationChannel{
+				Type:  model.NotificationChannelEmail,
+				Email: "user@example.com",
+			},
+			err: ErrEmailConnectorMissing.Error(),
+		},
+		"error, connector failure": {
+			channel: model.NotificationChannel{
+				Type:  model.NotificationChannelEmail,
+				Email: "user@example.com",
+			},
+			connector: true,
+			status:    http.StatusBadGateway,
+			path:      "/email",
+			err: "failed to deliver the notification: " +
+				"connector responded with 502 Bad Gateway",
+		},
+		"error, unsupported channel": {
+			channel: model.NotificationChannel{Type: "sms"},
+			err:     "unsupported notification channel: sms",
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var path string
+			srv := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					path = r.URL.Path
+					if r.URL.Path == "/email" {
+						var msg Email
+						err := json.NewDecoder(r.Body).Decode(&msg)
+						assert.NoError(t, err)
+						assert.Equal(t, tc.channel.Email, msg.To)
+						assert.Equal(t,
+							"Device dev matches your subscription",
+							msg.Subject)
+					}
+					w.WriteHeader(tc.status)
+				},
+			))
+			defer srv.Close()
+
+			var email EmailConnector
+			if tc.connector {
+				email = NewHTTPEmailConnector(srv.URL + "/email")
+			}
+			if tc.channel.Type == model.NotificationChannelWebhook {
+				tc.channel.URL = srv.URL + "/webhook"
+			}
+			notifier := NewChannelNotifier(email)
+			err := notifier.Notify(context.Background(), tc.channel, event)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.path, path)
+		})
+	}
+}
+
+func TestChannelNotifierRedeliver(t *testing.T) {
+	t.Parallel()
+
+	payload := []byte(`[{"id":"1"}]`)
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			body, err := ioutil.ReadAll(r.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, payload, body)
+			w.WriteHeader(status)
+		},
+	))
+	defer srv.Close()
+
+	notifier := NewChannelNotifier(nil)
+	err := notifier.Redeliver(context.Background(), srv.URL, payload)
+	assert.NoError(t, err)
+
+	status = http.StatusServiceUnavailable
+	err = notifier.Redeliver(context.Background(), srv.URL, payload)
+	assert.EqualError(t, err, "failed to deliver events: "+
+		"webhook responded with 503 Service Unavailable")
+	var derr *DeliveryError
+	if assert.True(t, errors.As(err, &derr)) {
+		assert.Equal(t, payload, derr.Payload)
+	}
+}
+
+func TestEmailSubject(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	assert.Equal(t, "Device dev changed", emailSubject(New(ctx,
+		TypeSubscriptionDeviceChanged,
+		model.Notification{DeviceID: "dev"},
+	)))
+	assert.Equal(t,
+		"2 device(s) missing required attributes after 24 hour(s)",
+		emailSubject(New(ctx, TypeDevicesIncomplete, model.IncompleteDevices{
+			AfterHours: 24,
+			Devices:    []model.DeviceCompleteness{{}, {}},
+		})),
+	)
+	assert.Equal(t,
+		"Inventory digest: 3 new device(s), 1 device(s) gone silent",
+		emailSubject(New(ctx, TypeFleetDigest, model.FleetDigest{
+			NewDevices:    3,
+			SilentDevices: 1,
+		})),
+	)
+	assert.Equal(t, "Inventory notification", emailSubject(New(ctx,
+		TypeDeviceGroupJoined, nil,
+	)))
+}
@@ -0,0 +1,32 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/config"
+)
+
+// checkFIPS returns an error if the configuration requires the FIPS mode
+// and the binary is built without it.
+func checkFIPS(c config.Reader) error {
+	if c.GetBool(SettingFIPSRequired) && !fipsMode {
+		return errors.New("the FIPS mode is required, but the binary is " +
+			"built without the FIPS-validated crypto module; build it " +
+			"with GOEXPERIMENT=boringcrypto and the boringcrypto tag")
+	}
+	return nil
+}
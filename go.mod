@@ -4,11 +4,15 @@ go 1.14
 
 require (
 	github.com/ant0ine/go-json-rest v3.3.3-0.20170913041208-ebb33769ae01+incompatible
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/go-ozzo/ozzo-validation/v4 v4.3.0
+	github.com/golang/snappy v0.0.1
 	github.com/mendersoftware/go-lib-micro v0.0.0-20201013131806-cf1f6a851bcb
 	github.com/pkg/errors v0.9.1
 	github.com/spf13/viper v1.8.0
 	github.com/stretchr/testify v1.7.0
 	github.com/urfave/cli v1.22.5
 	go.mongodb.org/mongo-driver v1.5.4
+	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c
+	gopkg.in/yaml.v2 v2.4.0
 )
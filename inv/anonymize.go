@@ -0,0 +1,226 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+)
+
+// identifyingNames matches the names of the attributes holding
+// identifying values in any scope, e.g. mac, serial_number or ipv4_eth0.
+var identifyingNames = regexp.MustCompile(
+	`(?i)(^|_)(mac|serial|serialno|serial_number|hostname|host|ip|ipv4|ipv6|imei|imsi|iccid|uuid)(_|$)`,
+)
+
+const (
+	pseudonymDigits   = "0123456789"
+	pseudonymLower    = "abcdefghijklmnopqrstuvwxyz"
+	pseudonymUpper    = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	pseudonymHexLower = "0123456789abcdef"
+	pseudonymHexUpper = "0123456789ABCDEF"
+)
+
+// anonymizer pseudonymizes the identifying values of the devices with
+// a keyed hash, so that a value gets the same pseudonym in all the devices.
+type anonymizer struct {
+	key        []byte
+	attributes []model.SelectAttribute
+}
+
+func newAnonymizer(opts model.AnonymizeOptions) *anonymizer {
+	return &anonymizer{key: opts.Key, attributes: opts.Attributes}
+}
+
+// identifying returns true if the values of the attribute are
+// pseudonymized.
+func (a *anonymizer) identifying(scope, name string) bool {
+	if scope == model.AttrScopeIdentity ||
+		(scope == model.AttrScopeSystem && name == model.AttrNameParentDevice) ||
+		identifyingNames.MatchString(name) {
+		return true
+	}
+	for _, attr := range a.attributes {
+		if attr.Scope == scope && (attr.Attribute == "" || attr.Attribute == name) {
+			return true
+		}
+	}
+	return false
+}
+
+// device returns the device with the ID and the identifying values
+// pseudonymized; the sources of the attributes, identifying the users,
+// are left out.
+func (a *anonymizer) device(dev model.Device) model.Device {
+	res := model.Device{
+		ID:         model.DeviceID(a.pseudonym(string(dev.ID))),
+		Attributes: make(model.DeviceAttributes, 0, len(dev.Attributes)),
+		UpdatedTs:  dev.UpdatedTs,
+	}
+	for _, attr := range dev.Attributes {
+		if a.identifying(attr.Scope, attr.Name) {
+			attr.Value = a.value(attr.Value)
+		}
+		res.Attributes = append(res.Attributes, attr)
+	}
+	return res
+}
+
+// value pseudonymizes the strings, including the ones of the arrays;
+// the other values are not identifying.
+func (a *anonymizer) value(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return a.pseudonym(v)
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for n := range v {
+			res[n] = a.value(v[n])
+		}
+		return res
+	default:
+		return value
+	}
+}
+
+// digest returns n bytes of the keyed hash of the value.
+func (a *anonymizer) digest(value string, n int) []byte {
+	var res []byte
+	for block := byte(0); len(res) < n; block++ {
+		mac := hmac.New(sha256.New, a.key)
+		mac.Write([]byte{block})
+		mac.Write([]byte(value))
+		res = mac.Sum(res)
+	}
+	return res[:n]
+}
+
+// pseudonym returns the pseudonym of the value, keeping its shape:
+// the IP addresses are replaced with private ones, with the same prefix
+// length, the hexadecimal values (e.g. MACs) with hexadecimal ones, and
+// in the other values the digits are replaced with digits and the letters
+// with letters of the same case; the other characters are kept.
+func (a *anonymizer) pseudonym(value string) string {
+	if ip, ipnet, err := net.ParseCIDR(value); err == nil {
+		ones, _ := ipnet.Mask.Size()
+		return a.pseudonymIP(ip) + "/" + strconv.Itoa(ones)
+	} else if ip := net.ParseIP(value); ip != nil {
+		return a.pseudonymIP(ip)
+	}
+
+	hex, upper := isHexValue(value)
+	digest := a.digest(value, len(value))
+	res := []byte(value)
+	for n, c := range res {
+		var chars string
+		switch {
+		case hex && upper:
+			chars = pseudonymHexUpper
+		case hex:
+			chars = pseudonymHexLower
+		case c >= '0' && c <= '9':
+			chars = pseudonymDigits
+		case c >= 'a' && c <= 'z':
+			chars = pseudonymLower
+		case c >= 'A' && c <= 'Z':
+			chars = pseudonymUpper
+		}
+		if chars != "" && isAlphanumeric(c) {
+			res[n] = chars[int(digest[n])%len(chars)]
+		}
+	}
+	return string(res)
+}
+
+// pseudonymIP returns an address of the 10.0.0.0/8 IPv4 or of the fd00::/8
+// IPv6 private ranges.
+func (a *anonymizer) pseudonymIP(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		digest := a.digest(ip4.String(), 3)
+		return net.IPv4(10, digest[0], digest[1], digest[2]).String()
+	}
+	res := make(net.IP, net.IPv6len)
+	res[0] = 0xfd
+	copy(res[1:], a.digest(ip.String(), net.IPv6len-1))
+	return res.String()
+}
+
+func isAlphanumeric(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// isHexValue returns true if the letters and digits of the value are
+// hexadecimal digits, including at least one letter, and if they are
+// upper case.
+func isHexValue(value string) (hex, upper bool) {
+	letters := false
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c >= '0' && c <= '9':
+		case c >= 'a' && c <= 'f':
+			letters = true
+		case c >= 'A' && c <= 'F':
+			letters, upper = true, true
+		case isAlphanumeric(c):
+			return false, false
+		}
+	}
+	return letters, upper && !strings.ContainsAny(value, "abcdef")
+}
+
+// ExportAnonymized writes the devices as newline-delimited JSON, with
+// their IDs and identifying attribute values pseudonymized, and returns
+// the number of the devices written.
+func (i *inventory) ExportAnonymized(
+	ctx context.Context,
+	w io.Writer,
+	opts model.AnonymizeOptions,
+) (int, error) {
+	if len(opts.Key) == 0 {
+		return 0, errors.New("no pseudonymization key")
+	}
+	a := newAnonymizer(opts)
+	stream, _, err := i.db.StreamDevices(ctx, store.ListQuery{})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to read devices")
+	}
+	defer stream.Close()
+
+	enc := json.NewEncoder(w)
+	count := 0
+	for dev := range stream.Devices() {
+		if err := enc.Encode(a.device(dev)); err != nil {
+			return count, errors.Wrap(err, "failed to write device")
+		}
+		count++
+	}
+	if err := stream.Err(); err != nil {
+		return count, errors.Wrap(err, "failed to read devices")
+	}
+	return count, nil
+}
@@ -0,0 +1,211 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+	mstore "github.com/mendersoftware/inventory/store/mocks"
+)
+
+func TestAnonymizerPseudonym(t *testing.T) {
+	t.Parallel()
+
+	a := newAnonymizer(model.AnonymizeOptions{Key: []byte("key")})
+	testCases := map[string]struct {
+		value string
+		shape string
+	}{
+		"mac": {
+			value: "00:1a:2b:3c:4d:5e",
+			shape: `^[0-9a-f]{2}(:[0-9a-f]{2}){5}$`,
+		},
+		"mac, upper case": {
+			value: "00-1A-2B-3C-4D-5E",
+			shape: `^[0-9A-F]{2}(-[0-9A-F]{2}){5}$`,
+		},
+		"serial": {
+			value: "SN-2021-0042",
+			shape: `^[A-Z]{2}-[0-9]{4}-[0-9]{4}$`,
+		},
+		"hostname": {
+			value: "gateway-07.example.com",
+			shape: `^[a-z]{7}-[0-9]{2}\.[a-z]{7}\.[a-z]{3}$`,
+		},
+		"ipv4": {
+			value: "192.168.1.10/24",
+			shape: `^10\.[0-9]+\.[0-9]+\.[0-9]+/24$`,
+		},
+		"ipv6": {
+			value: "2001:db8::1",
+			shape: `^fd[0-9a-f]{2}:`,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			pseudonym := a.pseudonym(tc.value)
+			assert.NotEqual(t, tc.value, pseudonym)
+			assert.Regexp(t, regexp.MustCompile(tc.shape), pseudonym)
+			assert.Equal(t, pseudonym, a.pseudonym(tc.value))
+		})
+	}
+
+	ip, _, err := net.ParseCIDR(a.pseudonym("192.168.1.10/24"))
+	assert.NoError(t, err)
+	assert.Equal(t, ip.String(), a.pseudonym("192.168.1.10"))
+
+	other := newAnonymizer(model.AnonymizeOptions{Key: []byte("other")})
+	assert.NotEqual(t, a.pseudonym("00:1a:2b:3c:4d:5e"),
+		other.pseudonym("00:1a:2b:3c:4d:5e"))
+}
+
+func TestAnonymizerDevice(t *testing.T) {
+	t.Parallel()
+
+	a := newAnonymizer(model.AnonymizeOptions{
+		Key: []byte("key"),
+		Attributes: []model.SelectAttribute{
+			{Scope: model.AttrScopeInventory, Attribute: "owner"},
+			{Scope: model.AttrScopeTags},
+		},
+	})
+	now := time.Now()
+	dev := model.Device{
+		ID: "5f3c2a",
+		Attributes: model.DeviceAttributes{
+			{Scope: model.AttrScopeIdentity, Name: "mac", Value: "00:1a:2b:3c:4d:5e"},
+			{Scope: model.AttrScopeInventory, Name: "ipv4_eth0", Value: []interface{}{"192.168.1.10/24"}},
+			{Scope: model.AttrScopeInventory, Name: "hostname", Value: "gateway-07"},
+			{Scope: model.AttrScopeInventory, Name: "owner", Value: "alice"},
+			{Scope: model.AttrScopeInventory, Name: "device_type", Value: "raspberrypi4"},
+			{Scope: model.AttrScopeInventory, Name: "cpu_cores", Value: 4.0},
+			{Scope: model.AttrScopeTags, Name: "location", Value: "Oslo"},
+			{Scope: model.AttrScopeSystem, Name: model.AttrNameParentDevice, Value: "5f3c2b"},
+		},
+		UpdatedTs: now,
+		Sources: map[string]model.AttributeSource{
+			model.AttrScopeTags: {Type: "user", ID: "alice"},
+		},
+	}
+
+	res := a.device(dev)
+	assert.Equal(t, model.DeviceID(a.pseudonym("5f3c2a")), res.ID)
+	assert.Equal(t, now, res.UpdatedTs)
+	assert.Nil(t, res.Sources)
+	values := make(map[string]interface{}, len(res.Attributes))
+	for _, attr := range res.Attributes {
+		values[attr.Name] = attr.Value
+	}
+	assert.Equal(t, map[string]interface{}{
+		"mac":                      a.pseudonym("00:1a:2b:3c:4d:5e"),
+		"ipv4_eth0":                []interface{}{a.pseudonym("192.168.1.10/24")},
+		"hostname":                 a.pseudonym("gateway-07"),
+		"owner":                    a.pseudonym("alice"),
+		"device_type":              "raspberrypi4",
+		"cpu_cores":                4.0,
+		"location":                 a.pseudonym("Oslo"),
+		model.AttrNameParentDevice: a.pseudonym("5f3c2b"),
+	}, values)
+	// the original device is left intact
+	assert.Equal(t, "00:1a:2b:3c:4d:5e", dev.Attributes[0].Value)
+}
+
+func TestInventoryExportAnonymized(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		key       []byte
+		streamErr error
+
+		outCount int
+		outErr   string
+	}{
+		"ok": {
+			key:      []byte("key"),
+			outCount: 1,
+		},
+		"error, no key": {
+			outErr: "no pseudonymization key",
+		},
+		"error, db": {
+			key:       []byte("key"),
+			streamErr: errors.New("connection refused"),
+			outErr:    "failed to read devices: connection refused",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+
+			cur := &mstore.DeviceCursor{}
+			cur.On("Next", mock.Anything).Return(true).Once()
+			cur.On("Next", mock.Anything).Return(false)
+			cur.On("Decode", mock.Anything).
+				Run(func(args mock.Arguments) {
+					*args.Get(0).(*model.Device) = model.Device{
+						ID: "1",
+						Attributes: model.DeviceAttributes{{
+							Scope: model.AttrScopeIdentity,
+							Name:  "mac",
+							Value: "00:1a:2b:3c:4d:5e",
+						}},
+					}
+				}).
+				Return(nil)
+			cur.On("Err").Return(nil)
+			cur.On("Close", mock.Anything).Return(nil)
+
+			db := &mstore.DataStore{}
+			if tc.streamErr != nil {
+				db.On("StreamDevices", ctx, store.ListQuery{}).
+					Return(nil, -1, tc.streamErr)
+			} else {
+				db.On("StreamDevices", ctx, store.ListQuery{}).
+					Return(store.NewDeviceStream(ctx, cur), 1, nil)
+			}
+
+			var out bytes.Buffer
+			i := &inventory{db: db}
+			count, err := i.ExportAnonymized(ctx, &out, model.AnonymizeOptions{Key: tc.key})
+			assert.Equal(t, tc.outCount, count)
+			if tc.outErr != "" {
+				assert.EqualError(t, err, tc.outErr)
+				return
+			}
+			assert.NoError(t, err)
+			var dev model.Device
+			assert.NoError(t, json.Unmarshal(out.Bytes(), &dev))
+			a := newAnonymizer(model.AnonymizeOptions{Key: tc.key})
+			assert.Equal(t, model.DeviceID(a.pseudonym("1")), dev.ID)
+			assert.Equal(t, a.pseudonym("00:1a:2b:3c:4d:5e"), dev.Attributes[0].Value)
+		})
+	}
+}
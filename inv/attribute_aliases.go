@@ -0,0 +1,56 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package inv
+
+import (
+	"context"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+// withAttributeAliases resolves the aliases of the attributes in the
+// filters and the sorts of the search; if they cannot be retrieved, the
+// search uses the names as given.
+func (i *inventory) withAttributeAliases(
+	ctx context.Context,
+	searchParams model.SearchParams,
+) model.SearchParams {
+	if len(searchParams.Filters) == 0 && len(searchParams.Sort) == 0 {
+		return searchParams
+	}
+	aliases, err := i.db.GetAttributeAliases(ctx)
+	if err != nil {
+		log.FromContext(ctx).Warnf("skipping the attribute aliases: %v", err)
+		return searchParams
+	}
+	return aliases.Apply(searchParams)
+}
+
+func (i *inventory) GetAttributeAliases(ctx context.Context) (model.AttributeAliases, error) {
+	aliases, err := i.db.GetAttributeAliases(ctx)
+	if err != nil {
+		return aliases, errors.Wrap(err, "failed to get attribute aliases")
+	}
+	return aliases, nil
+}
+
+func (i *inventory) SetAttributeAliases(ctx context.Context, aliases model.AttributeAliases) error {
+	if err := i.db.SetAttributeAliases(ctx, aliases); err != nil {
+		return errors.Wrap(err, "failed to set attribute aliases")
+	}
+	return nil
+}
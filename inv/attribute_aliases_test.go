@@ -0,0 +1,113 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package inv
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/inventory/model"
+	mstore "github.com/mendersoftware/inventory/store/mocks"
+)
+
+func TestInventorySearchDevicesAliases(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	aliases := model.AttributeAliases{Attributes: []model.AttributeAlias{{
+		Scope:   model.AttrScopeInventory,
+		Name:    "ip_address",
+		Aliases: []string{"ipv4"},
+	}}}
+	filter := model.FilterPredicate{
+		Scope:     model.AttrScopeInventory,
+		Attribute: "ipv4",
+		Type:      "$eq",
+		Value:     "10.0.0.1",
+	}
+	aliased := filter
+	aliased.Attribute = "ip_address"
+	aliased.Aliases = []string{"ipv4"}
+
+	db := &mstore.DataStore{}
+	db.On("GetPinnedAttributes", ctx).Return(model.PinnedAttributes{}, nil)
+	db.On("GetAttributeDescriptions", ctx).
+		Return(model.AttributeDescriptions{}, nil)
+	db.On("GetAttributeAliases", ctx).Return(aliases, nil).Once()
+	db.On("SearchDevices", ctx, model.SearchParams{
+		Filters: []model.FilterPredicate{aliased},
+	}).Return([]model.Device{{ID: "1"}}, 1, nil).Once()
+	i := invForTest(db)
+
+	devs, totalCount, err := i.SearchDevices(ctx, model.SearchParams{
+		Filters: []model.FilterPredicate{filter},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, totalCount)
+	assert.Equal(t, []model.Device{{ID: "1"}}, devs)
+
+	// the names are used as given if the aliases cannot be fetched
+	db.On("GetAttributeAliases", ctx).
+		Return(model.AttributeAliases{}, errors.New("db error")).Once()
+	db.On("SearchDevices", ctx, model.SearchParams{
+		Filters: []model.FilterPredicate{filter},
+	}).Return([]model.Device{}, 0, nil).Once()
+
+	_, _, err = i.SearchDevices(ctx, model.SearchParams{
+		Filters: []model.FilterPredicate{filter},
+	})
+	assert.NoError(t, err)
+
+	// the aliases are not fetched for the searches without filters
+	// and sorts
+	db.On("SearchDevices", ctx, model.SearchParams{}).
+		Return([]model.Device{}, 0, nil).Once()
+	_, _, err = i.SearchDevices(ctx, model.SearchParams{})
+	assert.NoError(t, err)
+	db.AssertExpectations(t)
+}
+
+func TestInventoryAttributeAliases(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	aliases := model.AttributeAliases{Attributes: []model.AttributeAlias{{
+		Scope:   model.AttrScopeInventory,
+		Name:    "ip_address",
+		Aliases: []string{"ipv4"},
+	}}}
+
+	db := &mstore.DataStore{}
+	db.On("GetAttributeAliases", ctx).Return(aliases, nil).Once()
+	db.On("GetAttributeAliases", ctx).
+		Return(model.AttributeAliases{}, errors.New("db error")).Once()
+	db.On("SetAttributeAliases", ctx, aliases).Return(nil).Once()
+	db.On("SetAttributeAliases", ctx, aliases).
+		Return(errors.New("db error")).Once()
+	i := invForTest(db)
+
+	res, err := i.GetAttributeAliases(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, aliases, res)
+	_, err = i.GetAttributeAliases(ctx)
+	assert.EqualError(t, err, "failed to get attribute aliases: db error")
+
+	assert.NoError(t, i.SetAttributeAliases(ctx, aliases))
+	assert.EqualError(t, i.SetAttributeAliases(ctx, aliases),
+		"failed to set attribute aliases: db error")
+	db.AssertExpectations(t)
+}
@@ -0,0 +1,118 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package inv
+
+import (
+	"context"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+// ErrAttributeDescriptionsLimit is returned when an update would describe
+// more attributes than allowed.
+var ErrAttributeDescriptionsLimit = errors.Errorf(
+	"at most %d attributes can be described",
+	model.AttributeDescriptionsMax,
+)
+
+// attributeDescriptions returns the catalog of the descriptions of the
+// tenant in the context; if it cannot be retrieved, the attributes are
+// returned with the descriptions reported by the devices.
+func (i *inventory) attributeDescriptions(ctx context.Context) model.AttributeDescriptions {
+	descs, err := i.db.GetAttributeDescriptions(ctx)
+	if err != nil {
+		log.FromContext(ctx).Warnf("skipping the attribute descriptions: %v", err)
+	}
+	return descs
+}
+
+// describeDevices sets the descriptions of the catalog on the attributes
+// of the devices.
+func (i *inventory) describeDevices(ctx context.Context, devs []model.Device) {
+	if len(devs) == 0 {
+		return
+	}
+	i.attributeDescriptions(ctx).Describe(devs)
+}
+
+func (i *inventory) GetAttributeDescriptions(
+	ctx context.Context,
+) (model.AttributeDescriptions, error) {
+	descs, err := i.db.GetAttributeDescriptions(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get attribute descriptions")
+	}
+	return descs, nil
+}
+
+// SetAttributeDescriptions replaces the catalog of the descriptions; the
+// attributes with an empty description are left out.
+func (i *inventory) SetAttributeDescriptions(
+	ctx context.Context,
+	descs model.AttributeDescriptions,
+) error {
+	described := make(model.AttributeDescriptions, 0, len(descs))
+	for _, desc := range descs {
+		if desc.Description != "" {
+			described = append(described, desc)
+		}
+	}
+	if err := i.db.SetAttributeDescriptions(ctx, described); err != nil {
+		return errors.Wrap(err, "failed to set attribute descriptions")
+	}
+	return nil
+}
+
+// UpdateAttributeDescriptions sets the descriptions of the attributes,
+// keeping the descriptions of the others; an empty description removes
+// the description of the attribute.
+func (i *inventory) UpdateAttributeDescriptions(
+	ctx context.Context,
+	descs model.AttributeDescriptions,
+) error {
+	current, err := i.db.GetAttributeDescriptions(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get attribute descriptions")
+	}
+	described := make(map[model.SelectAttribute]bool, len(current))
+	for _, desc := range current {
+		described[model.SelectAttribute{Scope: desc.Scope, Attribute: desc.Name}] = true
+	}
+	count := len(current)
+	var set, unset model.AttributeDescriptions
+	for _, desc := range descs {
+		key := model.SelectAttribute{Scope: desc.Scope, Attribute: desc.Name}
+		if desc.Description == "" {
+			unset = append(unset, desc)
+			if described[key] {
+				count--
+			}
+		} else {
+			set = append(set, desc)
+			if !described[key] {
+				count++
+			}
+		}
+	}
+	if count > model.AttributeDescriptionsMax {
+		return ErrAttributeDescriptionsLimit
+	}
+	if err := i.db.UpdateAttributeDescriptions(ctx, set, unset); err != nil {
+		return errors.Wrap(err, "failed to update attribute descriptions")
+	}
+	return nil
+}
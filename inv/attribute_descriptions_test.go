@@ -0,0 +1,173 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package inv
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/inventory/model"
+	mstore "github.com/mendersoftware/inventory/store/mocks"
+)
+
+func TestInventoryGetDeviceDescribed(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	reported := "reported by the device"
+	described := "Operating system"
+
+	device := func() *model.Device {
+		return &model.Device{ID: "1", Attributes: model.DeviceAttributes{{
+			Scope: model.AttrScopeInventory, Name: "os", Value: "linux",
+			Description: &reported,
+		}}}
+	}
+
+	db := &mstore.DataStore{}
+	db.On("GetDevice", ctx, model.DeviceID("1")).Return(device(), nil).Once()
+	db.On("GetDevice", ctx, model.DeviceID("1")).Return(device(), nil).Once()
+	db.On("GetAttributeDescriptions", ctx).
+		Return(model.AttributeDescriptions{{
+			Scope: model.AttrScopeInventory, Name: "os",
+			Description: described,
+		}}, nil).Once()
+	// the reported descriptions are kept without the catalog
+	db.On("GetAttributeDescriptions", ctx).
+		Return(model.AttributeDescriptions(nil), errors.New("db error")).Once()
+	i := invForTest(db)
+
+	dev, err := i.GetDevice(ctx, "1")
+	assert.NoError(t, err)
+	if assert.NotNil(t, dev) && assert.Len(t, dev.Attributes, 1) {
+		assert.Equal(t, described, *dev.Attributes[0].Description)
+	}
+
+	dev, err = i.GetDevice(ctx, "1")
+	assert.NoError(t, err)
+	if assert.NotNil(t, dev) && assert.Len(t, dev.Attributes, 1) {
+		assert.Equal(t, reported, *dev.Attributes[0].Description)
+	}
+	db.AssertExpectations(t)
+}
+
+func TestInventoryAttributeDescriptions(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	descs := model.AttributeDescriptions{
+		{Scope: model.AttrScopeInventory, Name: "os", Description: "Operating system"},
+		{Scope: model.AttrScopeInventory, Name: "kernel"},
+	}
+	described := descs[:1]
+
+	db := &mstore.DataStore{}
+	db.On("GetAttributeDescriptions", ctx).Return(described, nil).Once()
+	db.On("GetAttributeDescriptions", ctx).
+		Return(model.AttributeDescriptions(nil), errors.New("db error")).Once()
+	// the attributes without a description are left out
+	db.On("SetAttributeDescriptions", ctx, described).Return(nil).Once()
+	db.On("SetAttributeDescriptions", ctx, described).
+		Return(errors.New("db error")).Once()
+	i := invForTest(db)
+
+	res, err := i.GetAttributeDescriptions(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, described, res)
+	_, err = i.GetAttributeDescriptions(ctx)
+	assert.EqualError(t, err, "failed to get attribute descriptions: db error")
+
+	assert.NoError(t, i.SetAttributeDescriptions(ctx, descs))
+	assert.EqualError(t, i.SetAttributeDescriptions(ctx, descs),
+		"failed to set attribute descriptions: db error")
+	db.AssertExpectations(t)
+}
+
+func TestInventoryUpdateAttributeDescriptions(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	os := model.AttributeDescription{
+		Scope: model.AttrScopeInventory, Name: "os", Description: "Operating system",
+	}
+	kernel := model.AttributeDescription{
+		Scope: model.AttrScopeInventory, Name: "kernel", Description: "Kernel version",
+	}
+	noOS := model.AttributeDescription{Scope: model.AttrScopeInventory, Name: "os"}
+
+	t.Run("ok", func(t *testing.T) {
+		db := &mstore.DataStore{}
+		db.On("GetAttributeDescriptions", ctx).
+			Return(model.AttributeDescriptions{os}, nil).Once()
+		db.On("UpdateAttributeDescriptions", ctx,
+			model.AttributeDescriptions{kernel},
+			model.AttributeDescriptions{noOS},
+		).Return(nil).Once()
+		i := invForTest(db)
+
+		err := i.UpdateAttributeDescriptions(ctx, model.AttributeDescriptions{
+			kernel, noOS,
+		})
+		assert.NoError(t, err)
+		db.AssertExpectations(t)
+	})
+
+	t.Run("error, limit", func(t *testing.T) {
+		current := make(model.AttributeDescriptions, model.AttributeDescriptionsMax)
+		for n := range current {
+			current[n] = model.AttributeDescription{
+				Scope: model.AttrScopeInventory, Name: "attr" + strconv.Itoa(n),
+				Description: "description",
+			}
+		}
+		db := &mstore.DataStore{}
+		db.On("GetAttributeDescriptions", ctx).Return(current, nil).Twice()
+		db.On("UpdateAttributeDescriptions", ctx,
+			model.AttributeDescriptions{os},
+			model.AttributeDescriptions{{
+				Scope: model.AttrScopeInventory, Name: "attr0",
+			}},
+		).Return(nil).Once()
+		i := invForTest(db)
+
+		err := i.UpdateAttributeDescriptions(ctx, model.AttributeDescriptions{os})
+		assert.Equal(t, ErrAttributeDescriptionsLimit, err)
+
+		// removing a description makes room for another one
+		err = i.UpdateAttributeDescriptions(ctx, model.AttributeDescriptions{
+			os, {Scope: model.AttrScopeInventory, Name: "attr0"},
+		})
+		assert.NoError(t, err)
+		db.AssertExpectations(t)
+	})
+
+	t.Run("error, db", func(t *testing.T) {
+		db := &mstore.DataStore{}
+		db.On("GetAttributeDescriptions", ctx).
+			Return(model.AttributeDescriptions{}, nil).Once()
+		db.On("UpdateAttributeDescriptions", ctx,
+			model.AttributeDescriptions{os},
+			model.AttributeDescriptions(nil),
+		).Return(errors.New("db error")).Once()
+		i := invForTest(db)
+
+		err := i.UpdateAttributeDescriptions(ctx, model.AttributeDescriptions{os})
+		assert.EqualError(t, err, "failed to update attribute descriptions: db error")
+		db.AssertExpectations(t)
+	})
+}
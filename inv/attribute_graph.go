@@ -0,0 +1,150 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+const (
+	// attributeGraphSetsLimit bounds the number of distinct sets of
+	// values clustered by the attribute graph
+	attributeGraphSetsLimit = 10000
+	// attributeGraphSamples is the number of devices and of values
+	// sampled per cluster
+	attributeGraphSamples = 5
+)
+
+// GetAttributeGraph groups the devices having the attribute into clusters
+// connected by the values they share, e.g. the devices behind the same
+// gateways, and returns the limit largest ones.
+func (i *inventory) GetAttributeGraph(
+	ctx context.Context,
+	scope, name string,
+	limit int,
+) (*model.AttributeGraph, error) {
+	sets, err := i.db.GetAttributeValueSets(ctx, scope, name,
+		attributeGraphSamples, attributeGraphSetsLimit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to group attribute values")
+	}
+
+	valueSets := make(valueSetForest)
+	for _, set := range sets {
+		valueSets.union(set.Values)
+	}
+
+	var (
+		clusters []*model.AttributeCluster
+		byRoot   = make(map[string]*model.AttributeCluster)
+		seen     = make(map[string]bool)
+		graph    = &model.AttributeGraph{
+			Name:    name,
+			Scope:   scope,
+			Partial: len(sets) >= attributeGraphSetsLimit,
+		}
+	)
+	for _, set := range sets {
+		if len(set.Values) == 0 {
+			continue
+		}
+		root := valueSets.find(valueKey(set.Values[0]))
+		cluster, ok := byRoot[root]
+		if !ok {
+			cluster = &model.AttributeCluster{
+				Values:  []interface{}{},
+				Devices: []model.DeviceID{},
+			}
+			byRoot[root] = cluster
+			clusters = append(clusters, cluster)
+		}
+		for _, value := range set.Values {
+			key := valueKey(value)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			cluster.ValueCount++
+			if len(cluster.Values) < attributeGraphSamples {
+				cluster.Values = append(cluster.Values, value)
+			}
+		}
+		for _, id := range set.Devices {
+			if len(cluster.Devices) >= attributeGraphSamples {
+				break
+			}
+			cluster.Devices = append(cluster.Devices, id)
+		}
+		cluster.Count += set.Count
+		graph.Devices += set.Count
+	}
+
+	// the sets are sorted by size, and so the clusters by their largest
+	// set; the ties are kept in that order
+	sort.SliceStable(clusters, func(a, b int) bool {
+		return clusters[a].Count > clusters[b].Count
+	})
+	graph.TotalClusters = len(clusters)
+	if len(clusters) > limit {
+		clusters = clusters[:limit]
+	}
+	graph.Clusters = make([]model.AttributeCluster, len(clusters))
+	for n, cluster := range clusters {
+		graph.Clusters[n] = *cluster
+	}
+	return graph, nil
+}
+
+// valueKey identifies an attribute value; values of different types
+// are not the same.
+func valueKey(value interface{}) string {
+	return fmt.Sprintf("%T:%v", value, value)
+}
+
+// valueSetForest is a disjoint-set forest of the attribute values,
+// joining the values held together by a device.
+type valueSetForest map[string]string
+
+func (f valueSetForest) find(key string) string {
+	parent, ok := f[key]
+	if !ok {
+		f[key] = key
+		return key
+	}
+	if parent == key {
+		return key
+	}
+	root := f.find(parent)
+	f[key] = root
+	return root
+}
+
+func (f valueSetForest) union(values []interface{}) {
+	if len(values) == 0 {
+		return
+	}
+	root := f.find(valueKey(values[0]))
+	for _, value := range values[1:] {
+		if other := f.find(valueKey(value)); other != root {
+			f[other] = root
+		}
+	}
+}
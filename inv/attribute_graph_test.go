@@ -0,0 +1,136 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/inventory/model"
+	mstore "github.com/mendersoftware/inventory/store/mocks"
+)
+
+func TestInventoryGetAttributeGraph(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		sets    []model.AttributeValueSet
+		setsErr error
+		limit   int
+
+		graph *model.AttributeGraph
+		err   error
+	}{
+		"ok, scalar values": {
+			sets: []model.AttributeValueSet{
+				{Values: []interface{}{"gw-1"}, Count: 3, Devices: []model.DeviceID{"1", "2", "3"}},
+				{Values: []interface{}{"gw-2"}, Count: 1, Devices: []model.DeviceID{"4"}},
+			},
+			limit: 20,
+			graph: &model.AttributeGraph{
+				Name:          "gateway_id",
+				Scope:         model.AttrScopeInventory,
+				Devices:       4,
+				TotalClusters: 2,
+				Clusters: []model.AttributeCluster{
+					{Values: []interface{}{"gw-1"}, ValueCount: 1, Count: 3, Devices: []model.DeviceID{"1", "2", "3"}},
+					{Values: []interface{}{"gw-2"}, ValueCount: 1, Count: 1, Devices: []model.DeviceID{"4"}},
+				},
+			},
+		},
+		"ok, values connected by devices": {
+			sets: []model.AttributeValueSet{
+				{Values: []interface{}{"gw-1"}, Count: 2, Devices: []model.DeviceID{"1", "2"}},
+				{Values: []interface{}{"gw-3"}, Count: 2, Devices: []model.DeviceID{"3", "4"}},
+				{Values: []interface{}{"gw-2", "gw-3"}, Count: 1, Devices: []model.DeviceID{"5"}},
+				{Values: []interface{}{"gw-1", "gw-2"}, Count: 1, Devices: []model.DeviceID{"6"}},
+				{Values: []interface{}{float64(1)}, Count: 1, Devices: []model.DeviceID{"7"}},
+				{Values: []interface{}{}, Count: 1, Devices: []model.DeviceID{"8"}},
+			},
+			limit: 20,
+			graph: &model.AttributeGraph{
+				Name:          "gateway_id",
+				Scope:         model.AttrScopeInventory,
+				Devices:       7,
+				TotalClusters: 2,
+				Clusters: []model.AttributeCluster{
+					{
+						Values:     []interface{}{"gw-1", "gw-3", "gw-2"},
+						ValueCount: 3,
+						Count:      6,
+						Devices:    []model.DeviceID{"1", "2", "3", "4", "5"},
+					},
+					{Values: []interface{}{float64(1)}, ValueCount: 1, Count: 1, Devices: []model.DeviceID{"7"}},
+				},
+			},
+		},
+		"ok, limit": {
+			sets: []model.AttributeValueSet{
+				{Values: []interface{}{"gw-1"}, Count: 3, Devices: []model.DeviceID{"1", "2", "3"}},
+				{Values: []interface{}{"gw-2"}, Count: 1, Devices: []model.DeviceID{"4"}},
+			},
+			limit: 1,
+			graph: &model.AttributeGraph{
+				Name:          "gateway_id",
+				Scope:         model.AttrScopeInventory,
+				Devices:       4,
+				TotalClusters: 2,
+				Clusters: []model.AttributeCluster{
+					{Values: []interface{}{"gw-1"}, ValueCount: 1, Count: 3, Devices: []model.DeviceID{"1", "2", "3"}},
+				},
+			},
+		},
+		"ok, no devices": {
+			limit: 20,
+			graph: &model.AttributeGraph{
+				Name:     "gateway_id",
+				Scope:    model.AttrScopeInventory,
+				Clusters: []model.AttributeCluster{},
+			},
+		},
+		"error": {
+			setsErr: errors.New("db error"),
+			limit:   20,
+			err:     errors.New("failed to group attribute values: db error"),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			db.On("GetAttributeValueSets", ctx,
+				model.AttrScopeInventory, "gateway_id",
+				attributeGraphSamples, attributeGraphSetsLimit,
+			).Return(tc.sets, tc.setsErr)
+
+			i := invForTest(db)
+			graph, err := i.GetAttributeGraph(ctx,
+				model.AttrScopeInventory, "gateway_id", tc.limit)
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.graph, graph)
+			}
+		})
+	}
+}
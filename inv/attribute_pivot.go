@@ -0,0 +1,128 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+)
+
+// attributePivotPairsLimit bounds the number of distinct pairs of values
+// counted by the attribute pivot
+const attributePivotPairsLimit = 10000
+
+// GetAttributePivot counts the devices matching the filters of the query
+// by the values of the row and the column attributes, keeping the limit
+// most common values of each.
+func (i *inventory) GetAttributePivot(
+	ctx context.Context,
+	q store.ListQuery,
+	row, column model.SelectAttribute,
+	limit int,
+) (*model.AttributePivot, error) {
+	pairs, err := i.db.CountDevicesByAttributePair(ctx, q, row, column,
+		attributePivotPairsLimit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to count devices by attribute values")
+	}
+
+	rows, columns := newPivotValues(), newPivotValues()
+	for _, pair := range pairs {
+		rows.add(pair.Row, pair.Count)
+		columns.add(pair.Column, pair.Count)
+	}
+	rowValues, rowsPartial := rows.top(limit)
+	columnValues, columnsPartial := columns.top(limit)
+	pivot := &model.AttributePivot{
+		Rows: model.PivotAxis{
+			Scope:  row.Scope,
+			Name:   row.Attribute,
+			Values: rowValues,
+		},
+		Columns: model.PivotAxis{
+			Scope:  column.Scope,
+			Name:   column.Attribute,
+			Values: columnValues,
+		},
+		Counts: make([][]int, len(rowValues)),
+		Partial: len(pairs) >= attributePivotPairsLimit ||
+			rowsPartial || columnsPartial,
+	}
+	for n := range pivot.Counts {
+		pivot.Counts[n] = make([]int, len(columnValues))
+	}
+	for _, pair := range pairs {
+		pivot.Devices += pair.Count
+		r, ok := rows.index[valueKey(pair.Row)]
+		if !ok {
+			continue
+		}
+		c, ok := columns.index[valueKey(pair.Column)]
+		if !ok {
+			continue
+		}
+		pivot.Counts[r][c] += pair.Count
+	}
+	return pivot, nil
+}
+
+// pivotValues totals the devices of the values of an attribute of
+// the pivot.
+type pivotValues struct {
+	values []interface{}
+	counts map[string]int
+	// index is the position of the values kept by top
+	index map[string]int
+}
+
+func newPivotValues() *pivotValues {
+	return &pivotValues{
+		counts: make(map[string]int),
+		index:  make(map[string]int),
+	}
+}
+
+func (p *pivotValues) add(value interface{}, count int) {
+	key := valueKey(value)
+	if _, ok := p.counts[key]; !ok {
+		p.values = append(p.values, value)
+	}
+	p.counts[key] += count
+}
+
+// top returns the limit most common values, and whether any was left out;
+// the ties are kept in the order of the pairs.
+func (p *pivotValues) top(limit int) ([]interface{}, bool) {
+	values := p.values
+	sort.SliceStable(values, func(a, b int) bool {
+		return p.counts[valueKey(values[a])] > p.counts[valueKey(values[b])]
+	})
+	partial := len(values) > limit
+	if partial {
+		values = values[:limit]
+	}
+	for n, value := range values {
+		p.index[valueKey(value)] = n
+	}
+	if values == nil {
+		values = []interface{}{}
+	}
+	return values, partial
+}
@@ -0,0 +1,136 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+	mstore "github.com/mendersoftware/inventory/store/mocks"
+)
+
+func TestInventoryGetAttributePivot(t *testing.T) {
+	t.Parallel()
+
+	row := model.SelectAttribute{Scope: model.AttrScopeInventory, Attribute: "device_type"}
+	column := model.SelectAttribute{Scope: model.AttrScopeInventory, Attribute: "artifact_name"}
+	testCases := map[string]struct {
+		pairs    []model.AttributePairCount
+		pairsErr error
+		limit    int
+
+		pivot *model.AttributePivot
+		err   error
+	}{
+		"ok": {
+			pairs: []model.AttributePairCount{
+				{Row: "rpi4", Column: "release-2", Count: 5},
+				{Row: "rpi3", Column: "release-1", Count: 3},
+				{Row: "rpi4", Column: "release-1", Count: 2},
+				{Row: "rpi3", Column: nil, Count: 1},
+			},
+			limit: 20,
+			pivot: &model.AttributePivot{
+				Rows: model.PivotAxis{
+					Scope:  model.AttrScopeInventory,
+					Name:   "device_type",
+					Values: []interface{}{"rpi4", "rpi3"},
+				},
+				Columns: model.PivotAxis{
+					Scope:  model.AttrScopeInventory,
+					Name:   "artifact_name",
+					Values: []interface{}{"release-2", "release-1", nil},
+				},
+				Counts: [][]int{
+					{5, 2, 0},
+					{0, 3, 1},
+				},
+				Devices: 11,
+			},
+		},
+		"ok, limit": {
+			pairs: []model.AttributePairCount{
+				{Row: "rpi4", Column: "release-2", Count: 5},
+				{Row: "rpi3", Column: "release-1", Count: 3},
+				{Row: "rpi4", Column: "release-1", Count: 2},
+			},
+			limit: 1,
+			pivot: &model.AttributePivot{
+				Rows: model.PivotAxis{
+					Scope:  model.AttrScopeInventory,
+					Name:   "device_type",
+					Values: []interface{}{"rpi4"},
+				},
+				Columns: model.PivotAxis{
+					Scope:  model.AttrScopeInventory,
+					Name:   "artifact_name",
+					Values: []interface{}{"release-2"},
+				},
+				Counts:  [][]int{{5}},
+				Devices: 10,
+				Partial: true,
+			},
+		},
+		"ok, no devices": {
+			limit: 20,
+			pivot: &model.AttributePivot{
+				Rows: model.PivotAxis{
+					Scope:  model.AttrScopeInventory,
+					Name:   "device_type",
+					Values: []interface{}{},
+				},
+				Columns: model.PivotAxis{
+					Scope:  model.AttrScopeInventory,
+					Name:   "artifact_name",
+					Values: []interface{}{},
+				},
+				Counts: [][]int{},
+			},
+		},
+		"error": {
+			pairsErr: errors.New("db error"),
+			limit:    20,
+			err:      errors.New("failed to count devices by attribute values: db error"),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			q := store.ListQuery{GroupName: "prod"}
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			db.On("CountDevicesByAttributePair", ctx, q, row, column,
+				attributePivotPairsLimit,
+			).Return(tc.pairs, tc.pairsErr)
+
+			i := invForTest(db)
+			pivot, err := i.GetAttributePivot(ctx, q, row, column, tc.limit)
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.pivot, pivot)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+// CatalogTTL is how long a precomputed catalog is served in place of
+// aggregating the attribute catalog from the devices on each request.
+const CatalogTTL = 5 * time.Minute
+
+// WarmUpCatalog precomputes the attribute catalog, the group counts and
+// the device count of the tenant and stores them, for the attribute catalog
+// to be served from the store during CatalogTTL.
+func (i *inventory) WarmUpCatalog(ctx context.Context) (*model.Catalog, error) {
+	catalog := model.Catalog{ComputedTs: time.Now()}
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() (err error) {
+		catalog.Attributes, err = i.db.GetFiltersAttributes(gctx)
+		return errors.Wrap(err, "failed to get filter attributes from the db")
+	})
+	g.Go(func() (err error) {
+		catalog.Groups, err = i.db.CountDevicesByGroup(gctx, nil)
+		return err
+	})
+	g.Go(func() (err error) {
+		catalog.Devices, err = i.db.CountDevices(gctx)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	if catalog.Attributes == nil {
+		catalog.Attributes = []model.FilterAttribute{}
+	}
+	if err := i.db.SaveCatalog(ctx, catalog); err != nil {
+		return nil, err
+	}
+	return &catalog, nil
+}
+
+// cachedFiltersAttributes returns the attribute catalog precomputed by
+// WarmUpCatalog, or nil if there is none within CatalogTTL.
+func (i *inventory) cachedFiltersAttributes(ctx context.Context) []model.FilterAttribute {
+	catalog, err := i.db.GetCatalog(ctx)
+	if err != nil {
+		log.FromContext(ctx).Warnf(
+			"failed to get the precomputed catalog: %s", err.Error())
+		return nil
+	} else if catalog == nil || time.Since(catalog.ComputedTs) > CatalogTTL {
+		return nil
+	}
+	return catalog.Attributes
+}
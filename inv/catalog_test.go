@@ -0,0 +1,95 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/inventory/model"
+	mstore "github.com/mendersoftware/inventory/store/mocks"
+)
+
+func TestInventoryWarmUpCatalog(t *testing.T) {
+	t.Parallel()
+
+	attributes := []model.FilterAttribute{
+		{Name: "mac", Scope: "inventory", Count: 2},
+	}
+	groups := []model.GroupCount{{Group: "foo", Count: 2}}
+	testCases := map[string]struct {
+		attributes    []model.FilterAttribute
+		attributesErr error
+		groupsErr     error
+		saveErr       error
+
+		outErr error
+	}{
+		"ok": {
+			attributes: attributes,
+		},
+		"ok, no attributes": {},
+		"error, attributes": {
+			attributesErr: errors.New("db error"),
+			outErr:        errors.New("failed to get filter attributes from the db: db error"),
+		},
+		"error, groups": {
+			groupsErr: errors.New("db error"),
+			outErr:    errors.New("db error"),
+		},
+		"error, save": {
+			attributes: attributes,
+			saveErr:    errors.New("db error"),
+			outErr:     errors.New("db error"),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			db := &mstore.DataStore{}
+			db.On("GetFiltersAttributes", mock.Anything).
+				Return(tc.attributes, tc.attributesErr)
+			db.On("CountDevicesByGroup", mock.Anything, mock.Anything).
+				Return(groups, tc.groupsErr)
+			db.On("CountDevices", mock.Anything).Return(3, nil)
+			db.On("SaveCatalog", ctx, mock.MatchedBy(func(c model.Catalog) bool {
+				return c.Attributes != nil && c.Devices == 3 &&
+					!c.ComputedTs.IsZero()
+			})).Return(tc.saveErr)
+
+			i := invForTest(db)
+			catalog, err := i.WarmUpCatalog(ctx)
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+				assert.Nil(t, catalog)
+				return
+			}
+			assert.NoError(t, err)
+			if assert.NotNil(t, catalog) {
+				assert.Equal(t, groups, catalog.Groups)
+				assert.Equal(t, 3, catalog.Devices)
+				assert.Len(t, catalog.Attributes, len(tc.attributes))
+			}
+			db.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+// GetColdAttributes returns the attributes of the cold scopes of the
+// device, which are not part of the device document.
+func (i *inventory) GetColdAttributes(
+	ctx context.Context,
+	id model.DeviceID,
+) (model.DeviceAttributes, error) {
+	attrs, err := i.db.GetColdAttributes(ctx, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get cold attributes")
+	}
+	return attrs, nil
+}
+
+// coldScopes returns the names of the cold scopes among the scopes of the
+// attributes; the builtin scopes are never cold, so the custom scopes are
+// only loaded when the attributes belong to them.
+func (i *inventory) coldScopes(
+	ctx context.Context,
+	attrs model.DeviceAttributes,
+) (map[string]bool, error) {
+	custom := false
+	for _, attr := range attrs {
+		name, _ := model.SplitAgentScope(attr.Scope)
+		if _, ok := model.GetBuiltinScope(name); !ok && name != "" {
+			custom = true
+			break
+		}
+	}
+	if !custom {
+		return nil, nil
+	}
+	scopes, err := i.db.GetScopes(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get scopes")
+	}
+	var cold map[string]bool
+	for _, scope := range scopes {
+		if scope.Cold {
+			if cold == nil {
+				cold = make(map[string]bool)
+			}
+			cold[scope.Name] = true
+		}
+	}
+	return cold, nil
+}
+
+// splitColdAttributes separates the attributes of the cold scopes from the
+// attributes stored in the device document.
+func (i *inventory) splitColdAttributes(
+	ctx context.Context,
+	attrs model.DeviceAttributes,
+) (hot, cold model.DeviceAttributes, err error) {
+	coldScopes, err := i.coldScopes(ctx, attrs)
+	if err != nil || len(coldScopes) == 0 {
+		return attrs, nil, err
+	}
+	for _, attr := range attrs {
+		name, _ := model.SplitAgentScope(attr.Scope)
+		if coldScopes[name] {
+			cold = append(cold, attr)
+		} else {
+			hot = append(hot, attr)
+		}
+	}
+	return hot, cold, nil
+}
+
+// upsertColdAttributes writes the attributes of the cold scopes apart from
+// the device, dropping the copies written to the device document before
+// the scopes became cold.
+func (i *inventory) upsertColdAttributes(
+	ctx context.Context,
+	id model.DeviceID,
+	hot, cold model.DeviceAttributes,
+) error {
+	if err := i.db.UpsertRemoveColdAttributes(ctx, id, cold, nil); err != nil {
+		return errors.Wrap(err, "failed to upsert cold attributes in db")
+	}
+	if _, err := i.db.UpsertRemoveDeviceAttributes(ctx, id, hot, cold); err != nil {
+		return errors.Wrap(err, "failed to upsert attributes in db")
+	}
+	return nil
+}
+
+// removedAttributes returns the attributes of the scope which are not
+// replaced by the upserted attributes.
+func removedAttributes(
+	attrs model.DeviceAttributes,
+	scope string,
+	upsertAttrs model.DeviceAttributes,
+) model.DeviceAttributes {
+	removeAttrs := model.DeviceAttributes{}
+	for _, attr := range attrs {
+		if attr.Scope != scope {
+			continue
+		}
+		update := false
+		for _, upsertAttr := range upsertAttrs {
+			if upsertAttr.Name == attr.Name {
+				update = true
+			}
+		}
+		if !update {
+			removeAttrs = append(removeAttrs, attr)
+		}
+	}
+	return removeAttrs
+}
@@ -0,0 +1,134 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/inventory/model"
+	mstore "github.com/mendersoftware/inventory/store/mocks"
+)
+
+func TestInventoryUpsertColdAttributes(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	scopes := []model.Scope{
+		{Name: "packages", Writer: model.SourceTypeDevice, Cold: true},
+		{Name: "sensors", Writer: model.SourceTypeDevice},
+	}
+	hot := model.DeviceAttributes{
+		{Scope: model.AttrScopeInventory, Name: "os", Value: "linux"},
+		{Scope: "sensors", Name: "temp", Value: 42.0},
+	}
+	cold := model.DeviceAttributes{
+		{Scope: "packages", Name: "installed", Value: []interface{}{"a"}},
+		{Scope: "packages:gateway", Name: "installed", Value: []interface{}{"b"}},
+	}
+
+	testCases := map[string]struct {
+		attrs model.DeviceAttributes
+
+		hot     model.DeviceAttributes
+		cold    model.DeviceAttributes
+		coldErr error
+		err     string
+	}{
+		"ok, hot attributes": {
+			attrs: hot,
+		},
+		"ok, cold attributes": {
+			attrs: append(append(model.DeviceAttributes{}, hot...), cold...),
+			hot:   hot,
+			cold:  cold,
+		},
+		"error, cold attributes": {
+			attrs:   cold,
+			cold:    cold,
+			coldErr: errors.New("connection error"),
+			err:     "failed to upsert cold attributes in db: connection error",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			db.On("GetAttributeDefinitions", ctx).Return(nil, nil)
+			db.On("GetScopes", ctx).Return(scopes, nil)
+			if tc.cold == nil {
+				db.On("UpsertDevicesAttributes",
+					ctx, []model.DeviceID{"1"}, tc.attrs,
+				).Return(&model.UpdateResult{}, nil)
+			} else {
+				db.On("UpsertRemoveColdAttributes",
+					ctx, model.DeviceID("1"), tc.cold, model.DeviceAttributes(nil),
+				).Return(tc.coldErr)
+				if tc.coldErr == nil {
+					db.On("UpsertRemoveDeviceAttributes",
+						ctx, model.DeviceID("1"), tc.hot, tc.cold,
+					).Return(&model.UpdateResult{}, nil)
+				}
+			}
+
+			err := invForTest(db).UpsertAttributes(ctx, "1", tc.attrs)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestInventoryReplaceColdAttributes(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db := &mstore.DataStore{}
+	defer db.AssertExpectations(t)
+
+	db.On("GetAttributeDefinitions", ctx).Return(nil, nil)
+	db.On("GetScopes", ctx).Return([]model.Scope{
+		{Name: "packages", Writer: model.SourceTypeDevice, Cold: true},
+	}, nil)
+	db.On("GetColdAttributes", ctx, model.DeviceID("1")).
+		Return(model.DeviceAttributes{
+			{Scope: "packages", Name: "installed", Value: []interface{}{"a"}},
+			{Scope: "packages", Name: "removed", Value: []interface{}{"b"}},
+		}, nil)
+	upsert := model.DeviceAttributes{
+		{Scope: "packages", Name: "installed", Value: []interface{}{"a", "c"}},
+	}
+	db.On("UpsertRemoveColdAttributes", ctx, model.DeviceID("1"),
+		upsert,
+		model.DeviceAttributes{
+			{Scope: "packages", Name: "removed", Value: []interface{}{"b"}},
+		},
+	).Return(nil)
+	// drops the attributes written before the scope became cold
+	db.On("ReplaceDeviceAttributes", ctx, model.DeviceID("1"),
+		"packages", model.DeviceAttributes(nil),
+	).Return(&model.UpdateResult{}, nil)
+
+	err := invForTest(db).ReplaceAttributes(ctx, "1", upsert, "packages")
+	assert.NoError(t, err)
+}
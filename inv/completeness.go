@@ -0,0 +1,227 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/events"
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+	"github.com/mendersoftware/inventory/utils/reqctx"
+)
+
+// completenessAlertBatch is the maximum number of devices reported by
+// a single completeness alert.
+const completenessAlertBatch = 100
+
+func (i *inventory) requiredAttributes(ctx context.Context) ([]model.SelectAttribute, error) {
+	defs, err := i.db.GetAttributeDefinitions(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get attribute definitions")
+	}
+	return model.RequiredAttributes(defs), nil
+}
+
+// withRequiredAttributes resolves the attributes the completeness of the
+// devices is computed from, if the search filters by completeness.
+func (i *inventory) withRequiredAttributes(
+	ctx context.Context,
+	searchParams model.SearchParams,
+) (model.SearchParams, error) {
+	if searchParams.Completeness == nil {
+		return searchParams, nil
+	}
+	required, err := i.requiredAttributes(ctx)
+	if err != nil {
+		return searchParams, err
+	}
+	searchParams.RequiredAttributes = required
+	return searchParams, nil
+}
+
+// GetDeviceCompleteness returns the inventory completeness of the device,
+// or nil if the device does not exist.
+func (i *inventory) GetDeviceCompleteness(
+	ctx context.Context,
+	id model.DeviceID,
+) (*model.DeviceCompleteness, error) {
+	dev, err := i.db.GetDevice(ctx, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch device")
+	} else if dev == nil {
+		return nil, nil
+	}
+	required, err := i.requiredAttributes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	completeness := model.Completeness(dev, required)
+	return &completeness, nil
+}
+
+func (i *inventory) GetGroupsCompleteness(ctx context.Context) ([]model.GroupCompleteness, error) {
+	required, err := i.requiredAttributes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := i.db.GetGroupsCompleteness(ctx, required)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get groups completeness")
+	}
+	return groups, nil
+}
+
+// ListIncompleteDevices returns a page of the devices missing any of
+// the attributes required by the schema.
+func (i *inventory) ListIncompleteDevices(
+	ctx context.Context,
+	skip, limit int,
+) ([]model.DeviceCompleteness, int, error) {
+	required, err := i.requiredAttributes(ctx)
+	if err != nil {
+		return nil, -1, err
+	}
+	devs, total, err := i.db.GetIncompleteDevices(ctx,
+		store.IncompleteDevicesQuery{
+			Required: required,
+			Skip:     skip,
+			Limit:    limit,
+		},
+	)
+	if err != nil {
+		return nil, -1, errors.Wrap(err, "failed to get incomplete devices")
+	}
+	res := make([]model.DeviceCompleteness, len(devs))
+	for idx := range devs {
+		res[idx] = model.Completeness(&devs[idx], required)
+	}
+	return res, total, nil
+}
+
+func (i *inventory) GetCompletenessAlert(ctx context.Context) (*model.CompletenessAlert, error) {
+	alert, err := i.db.GetCompletenessAlert(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get completeness alert")
+	}
+	return alert, nil
+}
+
+// SetCompletenessAlert replaces the completeness alert; the first check
+// of the new alert reports all the devices past the grace period.
+func (i *inventory) SetCompletenessAlert(ctx context.Context, alert model.CompletenessAlert) error {
+	alert.CheckedTs = nil
+	if err := i.db.SetCompletenessAlert(ctx, alert); err != nil {
+		return errors.Wrap(err, "failed to set completeness alert")
+	}
+	return nil
+}
+
+func (i *inventory) DeleteCompletenessAlert(ctx context.Context) error {
+	if err := i.db.DeleteCompletenessAlert(ctx); err != nil {
+		return errors.Wrap(err, "failed to delete completeness alert")
+	}
+	return nil
+}
+
+// CheckCompletenessAlerts notifies the tenants about the devices whose
+// grace period to report the required attributes ended since the last
+// check. A failure to check one tenant does not stop the checks of the
+// others.
+func (i *inventory) CheckCompletenessAlerts(ctx context.Context) error {
+	l := log.FromContext(ctx)
+	failed := 0
+	err := i.forEachTenant(ctx, func(tctx context.Context) error {
+		if err := i.checkCompletenessAlert(tctx, time.Now()); err != nil {
+			info := reqctx.FromContext(tctx)
+			l.F(info.LogContext()).Errorf(
+				"failed to check completeness alert: %s", err.Error())
+			failed++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	} else if failed > 0 {
+		return errors.Errorf(
+			"failed to check completeness alerts of %d tenant(s)", failed,
+		)
+	}
+	return nil
+}
+
+// checkCompletenessAlert reports the devices of the tenant in the context
+// first seen within the grace period before the last check and now, and
+// still missing required attributes.
+func (i *inventory) checkCompletenessAlert(ctx context.Context, now time.Time) error {
+	alert, err := i.db.GetCompletenessAlert(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get completeness alert")
+	} else if alert == nil {
+		return nil
+	} else if i.notifier == nil {
+		return ErrNotifierMissing
+	}
+	required, err := i.requiredAttributes(ctx)
+	if err != nil {
+		return err
+	}
+
+	q := store.IncompleteDevicesQuery{
+		Required: required,
+		Limit:    completenessAlertBatch,
+	}
+	until := now.Add(-alert.GracePeriod())
+	q.CreatedUntil = &until
+	if alert.CheckedTs != nil {
+		from := alert.CheckedTs.Add(-alert.GracePeriod())
+		q.CreatedFrom = &from
+	}
+	for {
+		devs, _, err := i.db.GetIncompleteDevices(ctx, q)
+		if err != nil {
+			return errors.Wrap(err, "failed to get incomplete devices")
+		} else if len(devs) == 0 {
+			break
+		}
+		payload := model.IncompleteDevices{
+			AfterHours: alert.AfterHours,
+			Devices:    make([]model.DeviceCompleteness, len(devs)),
+		}
+		for idx := range devs {
+			payload.Devices[idx] = model.Completeness(&devs[idx], required)
+		}
+		event := events.New(ctx, events.TypeDevicesIncomplete, payload)
+		if err := i.notifier.Notify(ctx, alert.Channel, event); err != nil {
+			log.FromContext(ctx).Errorf(
+				"failed to deliver completeness alert: %s", err.Error())
+			i.recordDeadLetter(ctx, err, "")
+		}
+		if len(devs) < q.Limit {
+			break
+		}
+		q.Skip += len(devs)
+	}
+
+	alert.CheckedTs = &now
+	if err := i.db.SetCompletenessAlert(ctx, *alert); err != nil {
+		return errors.Wrap(err, "failed to set completeness alert")
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/inventory/events"
+	mevents "github.com/mendersoftware/inventory/events/mocks"
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+	mstore "github.com/mendersoftware/inventory/store/mocks"
+)
+
+var completenessDefs = []model.AttributeDefinition{
+	{Scope: model.AttrScopeInventory, Name: "os", Required: true},
+	{Scope: model.AttrScopeInventory, Name: "cpu"},
+}
+
+func TestInventorySearchDevicesCompleteness(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	max := 0.5
+	required := []model.SelectAttribute{
+		{Scope: model.AttrScopeInventory, Attribute: "os"},
+	}
+
+	db := &mstore.DataStore{}
+	db.On("GetAttributeDefinitions", ctx).Return(completenessDefs, nil).Once()
+	db.On("GetPinnedAttributes", ctx).Return(model.PinnedAttributes{}, nil)
+	db.On("GetAttributeDescriptions", ctx).
+		Return(model.AttributeDescriptions{}, nil)
+	// the required attributes are resolved from the schema
+	db.On("SearchDevices", ctx, model.SearchParams{
+		Completeness:       &model.CompletenessRange{Max: &max},
+		RequiredAttributes: required,
+	}).Return([]model.Device{{ID: "1"}}, 1, nil).Once()
+	i := invForTest(db)
+
+	devs, totalCount, err := i.SearchDevices(ctx, model.SearchParams{
+		Completeness: &model.CompletenessRange{Max: &max},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, totalCount)
+	assert.Equal(t, []model.Device{{ID: "1"}}, devs)
+
+	db.On("GetAttributeDefinitions", ctx).
+		Return(nil, errors.New("db error")).Once()
+	_, _, err = i.SearchDevices(ctx, model.SearchParams{
+		Completeness: &model.CompletenessRange{Max: &max},
+	})
+	assert.EqualError(t, err,
+		"failed to get attribute definitions: db error")
+	db.AssertExpectations(t)
+}
+
+func TestInventoryGetDeviceCompleteness(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db := &mstore.DataStore{}
+	db.On("GetDevice", ctx, model.DeviceID("1")).Return(&model.Device{
+		ID: "1",
+		Attributes: model.DeviceAttributes{
+			{Scope: model.AttrScopeInventory, Name: "os", Value: "linux"},
+		},
+	}, nil)
+	db.On("GetDevice", ctx, model.DeviceID("2")).
+		Return(nil, nil)
+	db.On("GetDevice", ctx, model.DeviceID("3")).
+		Return(nil, errors.New("db error"))
+	db.On("GetAttributeDefinitions", ctx).Return(completenessDefs, nil)
+	i := invForTest(db)
+
+	res, err := i.GetDeviceCompleteness(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, &model.DeviceCompleteness{
+		DeviceID:     "1",
+		Completeness: 1,
+		Missing:      []model.SelectAttribute{},
+	}, res)
+
+	res, err = i.GetDeviceCompleteness(ctx, "2")
+	assert.NoError(t, err)
+	assert.Nil(t, res)
+
+	_, err = i.GetDeviceCompleteness(ctx, "3")
+	assert.EqualError(t, err, "failed to fetch device: db error")
+}
+
+func TestInventoryGetGroupsCompleteness(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	required := []model.SelectAttribute{
+		{Scope: model.AttrScopeInventory, Attribute: "os"},
+	}
+	groups := []model.GroupCompleteness{
+		{Group: "dev", Devices: 2, Complete: 1, Completeness: 0.5},
+	}
+
+	db := &mstore.DataStore{}
+	db.On("GetAttributeDefinitions", ctx).Return(completenessDefs, nil)
+	db.On("GetGroupsCompleteness", ctx, required).Return(groups, nil).Once()
+	db.On("GetGroupsCompleteness", ctx, required).
+		Return(nil, errors.New("db error")).Once()
+	i := invForTest(db)
+
+	res, err := i.GetGroupsCompleteness(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, groups, res)
+
+	_, err = i.GetGroupsCompleteness(ctx)
+	assert.EqualError(t, err,
+		"failed to get groups completeness: db error")
+	db.AssertExpectations(t)
+}
+
+func TestInventoryListIncompleteDevices(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	required := []model.SelectAttribute{
+		{Scope: model.AttrScopeInventory, Attribute: "os"},
+	}
+	q := store.IncompleteDevicesQuery{Required: required, Skip: 20, Limit: 10}
+
+	db := &mstore.DataStore{}
+	db.On("GetAttributeDefinitions", ctx).Return(completenessDefs, nil)
+	db.On("GetIncompleteDevices", ctx, q).
+		Return([]model.Device{{ID: "1"}}, 21, nil).Once()
+	db.On("GetIncompleteDevices", ctx, q).
+		Return(nil, -1, errors.New("db error")).Once()
+	i := invForTest(db)
+
+	res, total, err := i.ListIncompleteDevices(ctx, 20, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 21, total)
+	assert.Equal(t, []model.DeviceCompleteness{{
+		DeviceID:     "1",
+		Completeness: 0,
+		Missing:      required,
+	}}, res)
+
+	_, _, err = i.ListIncompleteDevices(ctx, 20, 10)
+	assert.EqualError(t, err,
+		"failed to get incomplete devices: db error")
+	db.AssertExpectations(t)
+}
+
+func TestInventoryCheckCompletenessAlert(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2021, 6, 2, 12, 0, 0, 0, time.UTC)
+	checkedTs := now.Add(-10 * time.Minute)
+	channel := model.NotificationChannel{
+		Type: model.NotificationChannelWebhook,
+		URL:  "https://hooks.example.com",
+	}
+	required := []model.SelectAttribute{
+		{Scope: model.AttrScopeInventory, Attribute: "os"},
+	}
+	until := now.Add(-24 * time.Hour)
+	from := checkedTs.Add(-24 * time.Hour)
+	derr := &events.DeliveryError{
+		URL: channel.URL, Err: errors.New("connection refused"),
+	}
+
+	testCases := map[string]struct {
+		alert     *model.CompletenessAlert
+		getErr    error
+		notifier  bool
+		pages     [][]model.Device
+		notifyErr error
+
+		outErr error
+	}{
+		"ok, no alert": {},
+		"ok, first check": {
+			alert:    &model.CompletenessAlert{Channel: channel, AfterHours: 24},
+			notifier: true,
+			pages: [][]model.Device{
+				make([]model.Device, completenessAlertBatch),
+				{{ID: "101"}},
+			},
+		},
+		"ok, since the last check": {
+			alert: &model.CompletenessAlert{
+				Channel: channel, AfterHours: 24, CheckedTs: &checkedTs,
+			},
+			notifier: true,
+			pages:    [][]model.Device{{{ID: "1"}}},
+		},
+		"ok, delivery failure": {
+			alert: &model.CompletenessAlert{
+				Channel: channel, AfterHours: 24, CheckedTs: &checkedTs,
+			},
+			notifier:  true,
+			pages:     [][]model.Device{{{ID: "1"}}},
+			notifyErr: derr,
+		},
+		"error, notifier missing": {
+			alert:  &model.CompletenessAlert{Channel: channel, AfterHours: 24},
+			outErr: ErrNotifierMissing,
+		},
+		"error, db": {
+			getErr: errors.New("db error"),
+			outErr: errors.New("failed to get completeness alert: db error"),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			db := &mstore.DataStore{}
+			notifier := &mevents.Notifier{}
+			var alert *model.CompletenessAlert
+			if tc.alert != nil {
+				a := *tc.alert
+				alert = &a
+			}
+			db.On("GetCompletenessAlert", ctx).Return(alert, tc.getErr)
+			if tc.pages != nil {
+				db.On("GetAttributeDefinitions", ctx).
+					Return(completenessDefs, nil)
+				q := store.IncompleteDevicesQuery{
+					Required:     required,
+					CreatedUntil: &until,
+					Limit:        completenessAlertBatch,
+				}
+				if tc.alert.CheckedTs != nil {
+					q.CreatedFrom = &from
+				}
+				for _, page := range tc.pages {
+					db.On("GetIncompleteDevices", ctx, q).
+						Return(page, -1, nil).Once()
+					q.Skip += len(page)
+				}
+				notifier.On("Notify", ctx, channel,
+					mock.MatchedBy(func(e events.Event) bool {
+						return e.Type == events.TypeDevicesIncomplete
+					}),
+				).Return(tc.notifyErr).Times(len(tc.pages))
+				db.On("SetCompletenessAlert", ctx,
+					model.CompletenessAlert{
+						Channel:    channel,
+						AfterHours: 24,
+						CheckedTs:  &now,
+					},
+				).Return(nil)
+			}
+			if tc.notifyErr != nil {
+				db.On("CreateDeadLetter", ctx,
+					mock.AnythingOfType("model.DeadLetter"),
+				).Return(nil)
+			}
+
+			i := &inventory{db: db}
+			if tc.notifier {
+				i.notifier = notifier
+			}
+			err := i.checkCompletenessAlert(ctx, now)
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			db.AssertExpectations(t)
+			notifier.AssertExpectations(t)
+		})
+	}
+}
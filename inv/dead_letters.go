@@ -0,0 +1,143 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/mendersoftware/go-lib-micro/mongo/oid"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/events"
+	"github.com/mendersoftware/inventory/model"
+)
+
+// deadLettersReplayBatch is the number of dead letters fetched at once
+// while replaying all of them.
+const deadLettersReplayBatch = 100
+
+var ErrNotifierMissing = errors.New("no notifier configured")
+
+// recordDeadLetter stores the failed webhook delivery in the dead-letter
+// queue; failures other than webhook deliveries are ignored.
+func (i *inventory) recordDeadLetter(
+	ctx context.Context,
+	err error,
+	subscriptionID string,
+) {
+	var derr *events.DeliveryError
+	if !errors.As(err, &derr) {
+		return
+	}
+	now := time.Now()
+	letter := model.DeadLetter{
+		ID:             oid.NewUUIDv4().String(),
+		URL:            derr.URL,
+		SubscriptionID: subscriptionID,
+		Payload:        derr.Payload,
+		Reason:         derr.Error(),
+		Attempts:       1,
+		CreatedTs:      now,
+		LastAttemptTs:  now,
+	}
+	if err := i.db.CreateDeadLetter(ctx, letter); err != nil {
+		log.FromContext(ctx).Errorf("failed to store dead letter: %v", err)
+	}
+}
+
+func (i *inventory) ListDeadLetters(
+	ctx context.Context,
+	skip, limit int,
+) ([]model.DeadLetter, int, error) {
+	letters, total, err := i.db.GetDeadLetters(ctx, skip, limit)
+	if err != nil {
+		return nil, -1, errors.Wrap(err, "failed to list dead letters")
+	}
+	return letters, total, nil
+}
+
+func (i *inventory) DeleteDeadLetter(ctx context.Context, id string) error {
+	return i.db.DeleteDeadLetter(ctx, id)
+}
+
+func (i *inventory) PurgeDeadLetters(ctx context.Context) (*model.UpdateResult, error) {
+	return i.db.PurgeDeadLetters(ctx)
+}
+
+// ReplayDeadLetter delivers the dead letter again; it is removed from
+// the queue once delivered, otherwise the failure is recorded and
+// returned.
+func (i *inventory) ReplayDeadLetter(ctx context.Context, id string) error {
+	letter, err := i.db.GetDeadLetter(ctx, id)
+	if err != nil {
+		return err
+	}
+	return i.replay(ctx, *letter)
+}
+
+// ReplayDeadLetters delivers all the dead letters again, oldest first.
+func (i *inventory) ReplayDeadLetters(ctx context.Context) (*model.DeadLettersReplay, error) {
+	l := log.FromContext(ctx)
+	res := &model.DeadLettersReplay{}
+	for {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		// the delivered letters leave the queue: skip the failed ones only
+		letters, _, err := i.db.GetDeadLetters(ctx,
+			res.Failed, deadLettersReplayBatch)
+		if err != nil {
+			return res, errors.Wrap(err, "failed to list dead letters")
+		}
+		for _, letter := range letters {
+			err := i.replay(ctx, letter)
+			var derr *events.DeliveryError
+			if err == nil {
+				res.Replayed++
+			} else if errors.As(err, &derr) {
+				l.Warnf("failed to replay dead letter %s: %v", letter.ID, err)
+				res.Failed++
+			} else {
+				return res, err
+			}
+		}
+		if len(letters) < deadLettersReplayBatch {
+			return res, nil
+		}
+	}
+}
+
+func (i *inventory) replay(ctx context.Context, letter model.DeadLetter) error {
+	if i.notifier == nil {
+		return ErrNotifierMissing
+	}
+	err := i.notifier.Redeliver(ctx, letter.URL, letter.Payload)
+	if err == nil {
+		if err := i.db.DeleteDeadLetter(ctx, letter.ID); err != nil {
+			return errors.Wrap(err, "failed to remove dead letter")
+		}
+		return nil
+	}
+
+	letter.Attempts++
+	letter.LastAttemptTs = time.Now()
+	letter.Reason = err.Error()
+	if uerr := i.db.UpdateDeadLetter(ctx, letter); uerr != nil {
+		return errors.Wrap(uerr, "failed to update dead letter")
+	}
+	return err
+}
@@ -0,0 +1,168 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/inventory/events"
+	mevents "github.com/mendersoftware/inventory/events/mocks"
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+	mstore "github.com/mendersoftware/inventory/store/mocks"
+)
+
+func TestInventoryRecordDeadLetter(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	payload := []byte(`[{"id":"1"}]`)
+	derr := &events.DeliveryError{
+		URL:     "https://hooks.example.com",
+		Payload: payload,
+		Err:     errors.New("webhook responded with 500"),
+	}
+
+	db := &mstore.DataStore{}
+	db.On("CreateDeadLetter", ctx,
+		mock.MatchedBy(func(letter model.DeadLetter) bool {
+			return letter.ID != "" &&
+				letter.URL == derr.URL &&
+				letter.SubscriptionID == "sub" &&
+				string(letter.Payload) == string(payload) &&
+				letter.Reason == "webhook responded with 500" &&
+				letter.Attempts == 1 && !letter.CreatedTs.IsZero()
+		}),
+	).Return(nil).Once()
+
+	i := &inventory{db: db}
+	i.recordDeadLetter(ctx, pkgerrors.Wrap(derr, "notify"), "sub")
+	// failures other than webhook deliveries are not queued
+	i.recordDeadLetter(ctx, events.ErrEmailConnectorMissing, "sub")
+
+	db.AssertExpectations(t)
+}
+
+func TestInventoryReplayDeadLetter(t *testing.T) {
+	t.Parallel()
+
+	letter := &model.DeadLetter{
+		ID:       "1",
+		URL:      "https://hooks.example.com",
+		Payload:  json.RawMessage(`[{"id":"1"}]`),
+		Attempts: 1,
+	}
+	derr := &events.DeliveryError{Err: errors.New("connection refused")}
+	testCases := map[string]struct {
+		getErr     error
+		deliverErr error
+		updateErr  error
+
+		outErr error
+	}{
+		"ok": {},
+		"error, not found": {
+			getErr: store.ErrDeadLetterNotFound,
+			outErr: store.ErrDeadLetterNotFound,
+		},
+		"error, delivery": {
+			deliverErr: derr,
+			outErr:     derr,
+		},
+		"error, update": {
+			deliverErr: derr,
+			updateErr:  errors.New("db error"),
+			outErr:     errors.New("failed to update dead letter: db error"),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			db := &mstore.DataStore{}
+			notifier := &mevents.Notifier{}
+			if tc.getErr != nil {
+				db.On("GetDeadLetter", ctx, "1").Return(nil, tc.getErr)
+			} else {
+				l := *letter
+				db.On("GetDeadLetter", ctx, "1").Return(&l, nil)
+				notifier.On("Redeliver", ctx, letter.URL, []byte(letter.Payload)).
+					Return(tc.deliverErr)
+			}
+			if tc.deliverErr != nil {
+				db.On("UpdateDeadLetter", ctx,
+					mock.MatchedBy(func(l model.DeadLetter) bool {
+						return l.Attempts == 2 &&
+							l.Reason == tc.deliverErr.Error()
+					}),
+				).Return(tc.updateErr)
+			} else if tc.getErr == nil {
+				db.On("DeleteDeadLetter", ctx, "1").Return(nil)
+			}
+
+			i := &inventory{db: db, notifier: notifier}
+			err := i.ReplayDeadLetter(ctx, "1")
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			db.AssertExpectations(t)
+			notifier.AssertExpectations(t)
+		})
+	}
+}
+
+func TestInventoryReplayDeadLetters(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	letters := []model.DeadLetter{
+		{ID: "1", URL: "https://a.example.com"},
+		{ID: "2", URL: "https://b.example.com"},
+	}
+	db := &mstore.DataStore{}
+	db.On("GetDeadLetters", ctx, 0, deadLettersReplayBatch).
+		Return(letters, 2, nil)
+	db.On("DeleteDeadLetter", ctx, "1").Return(nil)
+	db.On("UpdateDeadLetter", ctx, mock.AnythingOfType("model.DeadLetter")).
+		Return(nil)
+	notifier := &mevents.Notifier{}
+	notifier.On("Redeliver", ctx, "https://a.example.com", mock.Anything).
+		Return(nil)
+	notifier.On("Redeliver", ctx, "https://b.example.com", mock.Anything).
+		Return(&events.DeliveryError{Err: errors.New("connection refused")})
+
+	i := &inventory{db: db, notifier: notifier}
+	res, err := i.ReplayDeadLetters(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, &model.DeadLettersReplay{Replayed: 1, Failed: 1}, res)
+
+	db = &mstore.DataStore{}
+	db.On("GetDeadLetters", ctx, 0, deadLettersReplayBatch).
+		Return(nil, -1, errors.New("db error"))
+	i = &inventory{db: db, notifier: notifier}
+	_, err = i.ReplayDeadLetters(ctx)
+	assert.EqualError(t, err, "failed to list dead letters: db error")
+}
@@ -0,0 +1,132 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/events"
+	"github.com/mendersoftware/inventory/model"
+)
+
+// defaultDiffAttributes are the key attributes compared on the deployment
+// completion, unless configured otherwise.
+var defaultDiffAttributes = []model.SelectAttribute{
+	{Scope: model.AttrScopeInventory, Attribute: "artifact_name"},
+}
+
+// WithDiffAttributes sets the key attributes, listed by scope, compared
+// before and after each deployment; artifact_name is compared by default.
+func (i *inventory) WithDiffAttributes(attributes map[string][]string) InventoryApp {
+	i.diffAttrs = nil
+	for scope, names := range attributes {
+		for _, name := range names {
+			i.diffAttrs = append(i.diffAttrs, model.SelectAttribute{
+				Scope:     scope,
+				Attribute: name,
+			})
+		}
+	}
+	sort.Slice(i.diffAttrs, func(a, b int) bool {
+		if i.diffAttrs[a].Scope != i.diffAttrs[b].Scope {
+			return i.diffAttrs[a].Scope < i.diffAttrs[b].Scope
+		}
+		return i.diffAttrs[a].Attribute < i.diffAttrs[b].Attribute
+	})
+	return i
+}
+
+func (i *inventory) diffAttributes() []model.SelectAttribute {
+	if len(i.diffAttrs) == 0 {
+		return defaultDiffAttributes
+	}
+	return i.diffAttrs
+}
+
+// DeploymentFinished compares the key attributes of the devices of
+// the finished deployment with the ones recorded at the previous finished
+// deployment, emits the differences and records the current attributes
+// for the next deployment. Devices missing from the inventory are skipped.
+func (i *inventory) DeploymentFinished(
+	ctx context.Context,
+	deploymentID string,
+	ids []model.DeviceID,
+) ([]model.DeploymentDiff, error) {
+	keys := i.diffAttributes()
+	devIDs := make([]string, len(ids))
+	for n, id := range ids {
+		devIDs[n] = string(id)
+	}
+	devs, _, err := i.db.SearchDevices(ctx, model.SearchParams{
+		Page:       1,
+		PerPage:    len(ids),
+		Attributes: keys,
+		DeviceIDs:  devIDs,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get the devices")
+	}
+	snapshots, err := i.db.GetAttributeSnapshots(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	previous := make(map[model.DeviceID]model.AttributeSnapshot, len(snapshots))
+	for _, snapshot := range snapshots {
+		previous[snapshot.DeviceID] = snapshot
+	}
+
+	now := time.Now()
+	diffs := make([]model.DeploymentDiff, len(devs))
+	current := make([]model.AttributeSnapshot, len(devs))
+	for n, dev := range devs {
+		before := previous[dev.ID]
+		diffs[n] = model.DeploymentDiff{
+			DeploymentID:         deploymentID,
+			DeviceID:             dev.ID,
+			PreviousDeploymentID: before.DeploymentID,
+			Changes: model.DiffAttributes(keys,
+				before.Attributes, dev.Attributes),
+		}
+		current[n] = model.AttributeSnapshot{
+			DeviceID:     dev.ID,
+			DeploymentID: deploymentID,
+			Attributes:   dev.Attributes,
+			Ts:           now,
+		}
+	}
+	if err := i.db.SaveAttributeSnapshots(ctx, current); err != nil {
+		return nil, err
+	}
+
+	if len(diffs) > 0 && i.events != nil &&
+		i.FeatureEnabled(ctx, model.FeatureWebhooks) {
+		evts := make([]events.Event, len(diffs))
+		for n, diff := range diffs {
+			evts[n] = events.New(ctx, events.TypeDeviceDeploymentDiff, diff)
+		}
+		// the snapshots are already recorded, the failure is only logged
+		if err := i.events.Emit(ctx, evts...); err != nil {
+			log.FromContext(ctx).Errorf(
+				"failed to emit deployment diff events: %v", err)
+			i.recordDeadLetter(ctx, err, "")
+		}
+	}
+	return diffs, nil
+}
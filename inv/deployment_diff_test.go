@@ -0,0 +1,166 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/inventory/events"
+	mevents "github.com/mendersoftware/inventory/events/mocks"
+	"github.com/mendersoftware/inventory/model"
+	mstore "github.com/mendersoftware/inventory/store/mocks"
+)
+
+func TestInventoryDeploymentFinished(t *testing.T) {
+	t.Parallel()
+
+	keys := []model.SelectAttribute{
+		{Scope: model.AttrScopeInventory, Attribute: "artifact_name"},
+		{Scope: model.AttrScopeInventory, Attribute: "device_type"},
+	}
+	devices := []model.Device{{
+		ID: "1",
+		Attributes: model.DeviceAttributes{
+			{Scope: "inventory", Name: "artifact_name", Value: "release-2"},
+			{Scope: "inventory", Name: "device_type", Value: "rpi4"},
+		},
+	}, {
+		ID: "2",
+		Attributes: model.DeviceAttributes{
+			{Scope: "inventory", Name: "artifact_name", Value: "release-2"},
+		},
+	}}
+	snapshots := []model.AttributeSnapshot{{
+		DeviceID:     "1",
+		DeploymentID: "d0",
+		Attributes: model.DeviceAttributes{
+			{Scope: "inventory", Name: "artifact_name", Value: "release-1"},
+			{Scope: "inventory", Name: "device_type", Value: "rpi4"},
+		},
+	}}
+	diffs := []model.DeploymentDiff{{
+		DeploymentID:         "d1",
+		DeviceID:             "1",
+		PreviousDeploymentID: "d0",
+		Changes: []model.AttributeChange{{
+			Scope:  "inventory",
+			Name:   "artifact_name",
+			Before: "release-1",
+			After:  "release-2",
+		}},
+	}, {
+		DeploymentID: "d1",
+		DeviceID:     "2",
+		Changes: []model.AttributeChange{{
+			Scope: "inventory",
+			Name:  "artifact_name",
+			After: "release-2",
+		}},
+	}}
+	testCases := map[string]struct {
+		searchErr   error
+		snapshotErr error
+		saveErr     error
+		emitErr     error
+
+		outDiffs []model.DeploymentDiff
+		outErr   error
+	}{
+		"ok": {
+			outDiffs: diffs,
+		},
+		"ok, emit error": {
+			emitErr:  errors.New("webhook error"),
+			outDiffs: diffs,
+		},
+		"error, search": {
+			searchErr: errors.New("db error"),
+			outErr:    errors.New("failed to get the devices: db error"),
+		},
+		"error, snapshots": {
+			snapshotErr: errors.New("db error"),
+			outErr:      errors.New("db error"),
+		},
+		"error, save": {
+			saveErr: errors.New("db error"),
+			outErr:  errors.New("db error"),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			ids := []model.DeviceID{"1", "2", "3"}
+			db := &mstore.DataStore{}
+			db.On("SearchDevices", ctx, model.SearchParams{
+				Page:       1,
+				PerPage:    3,
+				Attributes: keys,
+				DeviceIDs:  []string{"1", "2", "3"},
+			}).Return(devices, 2, tc.searchErr)
+			db.On("GetAttributeSnapshots", ctx, ids).
+				Return(snapshots, tc.snapshotErr)
+			db.On("SaveAttributeSnapshots", ctx,
+				mock.MatchedBy(func(s []model.AttributeSnapshot) bool {
+					return len(s) == 2 &&
+						s[0].DeviceID == "1" && s[0].DeploymentID == "d1" &&
+						s[1].DeviceID == "2" && s[1].DeploymentID == "d1" &&
+						!s[0].Ts.IsZero()
+				}),
+			).Return(tc.saveErr)
+			db.On("GetFeatureFlags", ctx).Return(model.FeatureFlagSet{}, nil)
+			emitter := &mevents.Emitter{}
+			emitter.On("Emit", ctx,
+				mock.MatchedBy(func(e events.Event) bool {
+					return e.Type == events.TypeDeviceDeploymentDiff &&
+						e.Data.(model.DeploymentDiff).DeviceID == "1"
+				}),
+				mock.MatchedBy(func(e events.Event) bool {
+					return e.Type == events.TypeDeviceDeploymentDiff &&
+						e.Data.(model.DeploymentDiff).DeviceID == "2"
+				}),
+			).Return(tc.emitErr)
+
+			i := invForTest(db).
+				WithEventEmitter(emitter).
+				WithDiffAttributes(map[string][]string{
+					"inventory": {"device_type", "artifact_name"},
+				})
+			res, err := i.DeploymentFinished(ctx, "d1", ids)
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+				emitter.AssertNotCalled(t, "Emit")
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.outDiffs, res)
+			emitter.AssertExpectations(t)
+		})
+	}
+}
+
+func TestInventoryDiffAttributesDefault(t *testing.T) {
+	t.Parallel()
+
+	i := &inventory{}
+	assert.Equal(t, defaultDiffAttributes, i.diffAttributes())
+}
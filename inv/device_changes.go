@@ -0,0 +1,76 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+var (
+	// ErrInvalidChangeToken is returned for a resume token of the change
+	// feed which was not issued by the service.
+	ErrInvalidChangeToken = errors.New("invalid change feed token")
+	// ErrChangeTokenExpired is returned when the changes following
+	// the resume token are no longer retained in the change feed.
+	ErrChangeTokenExpired = errors.New("the change feed token has expired")
+)
+
+// ListDeviceChanges returns a batch of at most limit changes of the devices
+// following the resume token, and the token resuming after the batch; an
+// empty token starts from the oldest change retained.
+func (i *inventory) ListDeviceChanges(
+	ctx context.Context,
+	token string,
+	limit int,
+) (*model.DeviceChangesPage, error) {
+	var seq int64
+	if token != "" {
+		var err error
+		seq, err = strconv.ParseInt(token, 10, 64)
+		if err != nil || seq < 1 {
+			return nil, ErrInvalidChangeToken
+		}
+	}
+
+	// the change of the token is fetched as well, to tell whether
+	// the feed still holds the changes following it: the changes expire
+	// in order
+	changes, err := i.db.GetDeviceChanges(ctx, seq, limit+1)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list device changes")
+	}
+	if seq > 0 {
+		if len(changes) == 0 || changes[0].Seq != seq {
+			return nil, ErrChangeTokenExpired
+		}
+		changes = changes[1:]
+	} else if len(changes) > limit {
+		changes = changes[:limit]
+	}
+
+	page := &model.DeviceChangesPage{
+		Changes:   changes,
+		NextToken: token,
+	}
+	if len(changes) > 0 {
+		page.NextToken = strconv.FormatInt(changes[len(changes)-1].Seq, 10)
+	}
+	return page, nil
+}
@@ -0,0 +1,129 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/inventory/model"
+	mstore "github.com/mendersoftware/inventory/store/mocks"
+)
+
+func TestInventoryListDeviceChanges(t *testing.T) {
+	t.Parallel()
+
+	changes := func(seqs ...int64) []model.DeviceChange {
+		res := make([]model.DeviceChange, len(seqs))
+		for n, seq := range seqs {
+			res[n] = model.DeviceChange{
+				Seq:      seq,
+				DeviceID: "1",
+				Type:     model.DeviceChangeUpdated,
+			}
+		}
+		return res
+	}
+	testCases := map[string]struct {
+		token   string
+		fromSeq int64
+		changes []model.DeviceChange
+		dbErr   error
+
+		page *model.DeviceChangesPage
+		err  string
+	}{
+		"ok, from the start": {
+			changes: changes(3, 4, 5),
+			page: &model.DeviceChangesPage{
+				Changes:   changes(3, 4),
+				NextToken: "4",
+			},
+		},
+		"ok, empty feed": {
+			changes: changes(),
+			page: &model.DeviceChangesPage{
+				Changes:   changes(),
+				NextToken: "",
+			},
+		},
+		"ok, resumed": {
+			token:   "4",
+			fromSeq: 4,
+			changes: changes(4, 5),
+			page: &model.DeviceChangesPage{
+				Changes:   changes(5),
+				NextToken: "5",
+			},
+		},
+		"ok, no new changes": {
+			token:   "5",
+			fromSeq: 5,
+			changes: changes(5),
+			page: &model.DeviceChangesPage{
+				Changes:   changes(),
+				NextToken: "5",
+			},
+		},
+		"error, invalid token": {
+			token: "abc",
+			err:   ErrInvalidChangeToken.Error(),
+		},
+		"error, negative token": {
+			token: "-1",
+			err:   ErrInvalidChangeToken.Error(),
+		},
+		"error, expired": {
+			token:   "2",
+			fromSeq: 2,
+			changes: changes(3, 4),
+			err:     ErrChangeTokenExpired.Error(),
+		},
+		"error, expired, empty feed": {
+			token:   "2",
+			fromSeq: 2,
+			changes: changes(),
+			err:     ErrChangeTokenExpired.Error(),
+		},
+		"error, db": {
+			dbErr: errors.New("db error"),
+			err:   "failed to list device changes: db error",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			db := &mstore.DataStore{}
+			db.On("GetDeviceChanges", ctx, tc.fromSeq, 3).
+				Return(tc.changes, tc.dbErr)
+
+			i := NewInventory(db)
+			page, err := i.ListDeviceChanges(ctx, tc.token, 2)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				assert.Nil(t, page)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.page, page)
+			}
+		})
+	}
+}
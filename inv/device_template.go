@@ -0,0 +1,90 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package inv
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+// ErrTemplateAttributeNotFound is returned when an attribute selected for
+// a device template is not reported by the device.
+var ErrTemplateAttributeNotFound = errors.New("the device does not have the selected attributes")
+
+// ApplyDeviceTemplate extracts the selected attributes of the device as
+// a template and sets them on the target devices of the request, if any,
+// in a single update; returns nil if the device does not exist.
+func (i *inventory) ApplyDeviceTemplate(
+	ctx context.Context,
+	id model.DeviceID,
+	req model.DeviceTemplateRequest,
+) (*model.DeviceTemplate, error) {
+	dev, err := i.db.GetDevice(ctx, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch device")
+	} else if dev == nil {
+		return nil, nil
+	}
+	attrs, missing := req.Extract(dev)
+	if len(missing) > 0 {
+		names := make([]string, len(missing))
+		for n, attr := range missing {
+			names[n] = fmt.Sprintf("%s/%s", attr.Scope, attr.Attribute)
+		}
+		return nil, errors.Wrap(ErrTemplateAttributeNotFound,
+			strings.Join(names, ", "))
+	}
+	template := &model.DeviceTemplate{
+		DeviceID:   dev.ID,
+		Attributes: attrs,
+	}
+	if !req.Apply() || len(attrs) == 0 {
+		return template, nil
+	}
+
+	if err := i.checkScopeWriters(ctx, attrs); err != nil {
+		return nil, err
+	}
+	// the template reports the values normalized for the targets
+	if err := i.checkBulkAttributeSchema(ctx, attrs); err != nil {
+		return nil, err
+	}
+	result, matched, err := i.db.UpdateDevicesTags(ctx, req.SearchParams(), attrs, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to update devices")
+	}
+	template.Applied = &model.DeviceTemplateResult{
+		Updated: int(result.MatchedCount),
+		Errors:  []model.DeviceTemplateError{},
+	}
+	found := make(map[model.DeviceID]bool, len(matched))
+	for _, id := range matched {
+		found[id] = true
+	}
+	for _, id := range distinctDeviceIDs(req.DeviceIDs) {
+		if !found[id] {
+			template.Applied.Errors = append(template.Applied.Errors,
+				model.DeviceTemplateError{
+					DeviceID: id,
+					Error:    "device not found",
+				})
+		}
+	}
+	return template, nil
+}
@@ -0,0 +1,244 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package inv
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/inventory/model"
+	mstore "github.com/mendersoftware/inventory/store/mocks"
+)
+
+func TestInventoryApplyDeviceTemplate(t *testing.T) {
+	t.Parallel()
+
+	userCtx := identity.WithContext(context.Background(),
+		&identity.Identity{Subject: "user", IsUser: true})
+	device := func() *model.Device {
+		return &model.Device{
+			ID: "1",
+			Attributes: model.DeviceAttributes{
+				{Scope: model.AttrScopeInventory, Name: "device_type", Value: "raspberrypi4"},
+				{Scope: model.AttrScopeTags, Name: "site", Value: " Oslo"},
+				{Scope: model.AttrScopeTags, Name: "floor", Value: "two"},
+			},
+		}
+	}
+	defs := []model.AttributeDefinition{{
+		Scope:       model.AttrScopeTags,
+		Name:        "site",
+		Normalizers: []string{model.NormalizerTrim, model.NormalizerLowercase},
+	}, {
+		Scope: model.AttrScopeTags,
+		Name:  "floor",
+		Type:  model.AttributeTypeNumber,
+	}}
+	site := model.SelectAttribute{Scope: model.AttrScopeTags, Attribute: "site"}
+	testCases := map[string]struct {
+		req model.DeviceTemplateRequest
+		db  func() *mstore.DataStore
+
+		res *model.DeviceTemplate
+		err string
+	}{
+		"ok, all the tags": {
+			db: func() *mstore.DataStore {
+				db := &mstore.DataStore{}
+				db.On("GetDevice", userCtx, model.DeviceID("1")).
+					Return(device(), nil)
+				return db
+			},
+			res: &model.DeviceTemplate{
+				DeviceID: "1",
+				Attributes: model.DeviceAttributes{
+					{Scope: model.AttrScopeTags, Name: "floor", Value: "two"},
+					{Scope: model.AttrScopeTags, Name: "site", Value: " Oslo"},
+				},
+			},
+		},
+		"ok, device IDs": {
+			req: model.DeviceTemplateRequest{
+				Attributes: []model.SelectAttribute{site},
+				DeviceIDs:  []model.DeviceID{"2", "3", "3"},
+			},
+			db: func() *mstore.DataStore {
+				db := &mstore.DataStore{}
+				db.On("GetDevice", userCtx, model.DeviceID("1")).
+					Return(device(), nil)
+				db.On("GetAttributeDefinitions", userCtx).Return(defs, nil)
+				db.On("UpdateDevicesTags", userCtx,
+					model.SearchParams{DeviceIDs: []string{"2", "3", "3"}},
+					model.DeviceAttributes{
+						{Scope: model.AttrScopeTags, Name: "site", Value: "oslo"},
+					},
+					model.DeviceAttributes(nil),
+				).Return(
+					&model.UpdateResult{MatchedCount: 1},
+					[]model.DeviceID{"2"},
+					nil,
+				)
+				return db
+			},
+			res: &model.DeviceTemplate{
+				DeviceID: "1",
+				Attributes: model.DeviceAttributes{
+					{Scope: model.AttrScopeTags, Name: "site", Value: "oslo"},
+				},
+				Applied: &model.DeviceTemplateResult{
+					Updated: 1,
+					Errors: []model.DeviceTemplateError{
+						{DeviceID: "3", Error: "device not found"},
+					},
+				},
+			},
+		},
+		"ok, group": {
+			req: model.DeviceTemplateRequest{
+				Attributes: []model.SelectAttribute{site},
+				Group:      "lab",
+			},
+			db: func() *mstore.DataStore {
+				db := &mstore.DataStore{}
+				db.On("GetDevice", userCtx, model.DeviceID("1")).
+					Return(device(), nil)
+				db.On("GetAttributeDefinitions", userCtx).Return(nil, nil)
+				db.On("UpdateDevicesTags", userCtx,
+					model.SearchParams{Filters: []model.FilterPredicate{{
+						Scope:     model.AttrScopeSystem,
+						Attribute: model.AttrNameGroup,
+						Type:      "$eq",
+						Value:     "lab",
+					}}},
+					model.DeviceAttributes{
+						{Scope: model.AttrScopeTags, Name: "site", Value: " Oslo"},
+					},
+					model.DeviceAttributes(nil),
+				).Return(&model.UpdateResult{MatchedCount: 12}, nil, nil)
+				return db
+			},
+			res: &model.DeviceTemplate{
+				DeviceID: "1",
+				Attributes: model.DeviceAttributes{
+					{Scope: model.AttrScopeTags, Name: "site", Value: " Oslo"},
+				},
+				Applied: &model.DeviceTemplateResult{
+					Updated: 12,
+					Errors:  []model.DeviceTemplateError{},
+				},
+			},
+		},
+		"ok, device not found": {
+			db: func() *mstore.DataStore {
+				db := &mstore.DataStore{}
+				db.On("GetDevice", userCtx, model.DeviceID("1")).
+					Return(nil, nil)
+				return db
+			},
+		},
+		"error, attribute not found": {
+			req: model.DeviceTemplateRequest{
+				Attributes: []model.SelectAttribute{
+					site,
+					{Scope: model.AttrScopeTags, Attribute: "owner"},
+				},
+			},
+			db: func() *mstore.DataStore {
+				db := &mstore.DataStore{}
+				db.On("GetDevice", userCtx, model.DeviceID("1")).
+					Return(device(), nil)
+				return db
+			},
+			err: "tags/owner: the device does not have the selected attributes",
+		},
+		"error, scope write forbidden": {
+			req: model.DeviceTemplateRequest{
+				Attributes: []model.SelectAttribute{{
+					Scope:     model.AttrScopeInventory,
+					Attribute: "device_type",
+				}},
+				Group: "lab",
+			},
+			db: func() *mstore.DataStore {
+				db := &mstore.DataStore{}
+				db.On("GetDevice", userCtx, model.DeviceID("1")).
+					Return(device(), nil)
+				return db
+			},
+			err: "scope inventory: writing attributes of the scope is forbidden",
+		},
+		"error, schema violation": {
+			req: model.DeviceTemplateRequest{Group: "lab"},
+			db: func() *mstore.DataStore {
+				db := &mstore.DataStore{}
+				db.On("GetDevice", userCtx, model.DeviceID("1")).
+					Return(device(), nil)
+				db.On("GetAttributeDefinitions", userCtx).Return(defs, nil)
+				return db
+			},
+			err: "attribute tags/floor must be of type number: " +
+				"attribute does not conform to the schema",
+		},
+		"error, db": {
+			db: func() *mstore.DataStore {
+				db := &mstore.DataStore{}
+				db.On("GetDevice", userCtx, model.DeviceID("1")).
+					Return(nil, errors.New("db error"))
+				return db
+			},
+			err: "failed to fetch device: db error",
+		},
+		"error, db update": {
+			req: model.DeviceTemplateRequest{
+				Attributes: []model.SelectAttribute{site},
+				Group:      "lab",
+			},
+			db: func() *mstore.DataStore {
+				db := &mstore.DataStore{}
+				db.On("GetDevice", userCtx, model.DeviceID("1")).
+					Return(device(), nil)
+				db.On("GetAttributeDefinitions", userCtx).Return(nil, nil)
+				db.On("UpdateDevicesTags", userCtx,
+					mock.Anything, mock.Anything, mock.Anything,
+				).Return(nil, nil, errors.New("db error"))
+				return db
+			},
+			err: "failed to update devices: db error",
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			db := tc.db()
+			defer db.AssertExpectations(t)
+
+			i := invForTest(db)
+			res, err := i.ApplyDeviceTemplate(userCtx, "1", tc.req)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.res, res)
+			}
+		})
+	}
+}
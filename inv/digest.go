@@ -0,0 +1,129 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package inv
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/events"
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/utils/reqctx"
+)
+
+func (i *inventory) GetDigestSettings(ctx context.Context) (*model.DigestSettings, error) {
+	settings, err := i.db.GetDigestSettings(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get digest settings")
+	}
+	return settings, nil
+}
+
+// SetDigestSettings replaces the digest settings; the first digest of
+// the new settings covers the whole period before it is sent.
+func (i *inventory) SetDigestSettings(ctx context.Context, settings model.DigestSettings) error {
+	settings.SentTs = nil
+	if err := i.db.SetDigestSettings(ctx, settings); err != nil {
+		return errors.Wrap(err, "failed to set digest settings")
+	}
+	return nil
+}
+
+func (i *inventory) DeleteDigestSettings(ctx context.Context) error {
+	if err := i.db.DeleteDigestSettings(ctx); err != nil {
+		return errors.Wrap(err, "failed to delete digest settings")
+	}
+	return nil
+}
+
+// PreviewDigest returns the digest covering the period since the last
+// one until now, without sending it, or nil if the digest is not set up.
+func (i *inventory) PreviewDigest(ctx context.Context) (*model.FleetDigest, error) {
+	settings, err := i.db.GetDigestSettings(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get digest settings")
+	} else if settings == nil {
+		return nil, nil
+	}
+	return i.fleetDigest(ctx, *settings, time.Now())
+}
+
+func (i *inventory) fleetDigest(
+	ctx context.Context,
+	settings model.DigestSettings,
+	now time.Time,
+) (*model.FleetDigest, error) {
+	from, to := settings.Window(now)
+	digest, err := i.db.GetFleetDigest(ctx, from, to, settings.SilentAfter())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get fleet digest")
+	}
+	return digest, nil
+}
+
+// SendDigests sends the digests of the tenants whose digest period is
+// over.
+func (i *inventory) SendDigests(ctx context.Context) error {
+	l := log.FromContext(ctx)
+	failed := 0
+	err := i.forEachTenant(ctx, func(tctx context.Context) error {
+		if err := i.sendDigest(tctx, time.Now()); err != nil {
+			info := reqctx.FromContext(tctx)
+			l.F(info.LogContext()).Errorf(
+				"failed to send digest: %s", err.Error())
+			failed++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	} else if failed > 0 {
+		return errors.Errorf("failed to send the digests of %d tenant(s)", failed)
+	}
+	return nil
+}
+
+// sendDigest sends the digest of the tenant in the context if its period
+// is over; failed deliveries are recorded as dead letters and the next
+// digest starts after the period of the failed one.
+func (i *inventory) sendDigest(ctx context.Context, now time.Time) error {
+	settings, err := i.db.GetDigestSettings(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get digest settings")
+	} else if settings == nil || !settings.Due(now) {
+		return nil
+	} else if i.notifier == nil {
+		return ErrNotifierMissing
+	}
+	digest, err := i.fleetDigest(ctx, *settings, now)
+	if err != nil {
+		return err
+	}
+
+	event := events.New(ctx, events.TypeFleetDigest, *digest)
+	if err := i.notifier.Notify(ctx, settings.Channel, event); err != nil {
+		log.FromContext(ctx).Errorf(
+			"failed to deliver digest: %s", err.Error())
+		i.recordDeadLetter(ctx, err, "")
+	}
+
+	settings.SentTs = &now
+	if err := i.db.SetDigestSettings(ctx, *settings); err != nil {
+		return errors.Wrap(err, "failed to set digest settings")
+	}
+	return nil
+}
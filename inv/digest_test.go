@@ -0,0 +1,214 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package inv
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/inventory/events"
+	mevents "github.com/mendersoftware/inventory/events/mocks"
+	"github.com/mendersoftware/inventory/model"
+	mstore "github.com/mendersoftware/inventory/store/mocks"
+)
+
+func TestInventorySetDigestSettings(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	sentTs := time.Now()
+	settings := model.DigestSettings{
+		Channel: model.NotificationChannel{
+			Type:  model.NotificationChannelEmail,
+			Email: "ops@example.com",
+		},
+		PeriodHours: 24,
+		SilentHours: 48,
+	}
+	db := &mstore.DataStore{}
+	// the first digest of new settings covers a whole period
+	db.On("SetDigestSettings", ctx, settings).
+		Return(errors.New("db error")).Once()
+
+	i := NewInventory(db)
+	settings.SentTs = &sentTs
+	err := i.SetDigestSettings(ctx, settings)
+	assert.EqualError(t, err, "failed to set digest settings: db error")
+	db.AssertExpectations(t)
+}
+
+func TestInventoryPreviewDigest(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	settings := &model.DigestSettings{PeriodHours: 24, SilentHours: 48}
+	digest := &model.FleetDigest{Devices: 10, NewDevices: 2}
+
+	db := &mstore.DataStore{}
+	db.On("GetDigestSettings", ctx).Return(nil, nil).Once()
+	db.On("GetDigestSettings", ctx).Return(settings, nil).Once()
+	db.On("GetFleetDigest", ctx,
+		mock.MatchedBy(func(from time.Time) bool {
+			return time.Since(from) >= 24*time.Hour
+		}),
+		mock.AnythingOfType("time.Time"),
+		48*time.Hour,
+	).Return(digest, nil).Once()
+
+	i := NewInventory(db)
+	res, err := i.PreviewDigest(ctx)
+	assert.NoError(t, err)
+	assert.Nil(t, res)
+
+	res, err = i.PreviewDigest(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, digest, res)
+	db.AssertExpectations(t)
+}
+
+func TestInventorySendDigest(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2021, 6, 2, 12, 0, 0, 0, time.UTC)
+	channel := model.NotificationChannel{
+		Type: model.NotificationChannelWebhook,
+		URL:  "https://hooks.example.com",
+	}
+	sentTs := now.Add(-24 * time.Hour)
+	recentTs := now.Add(-time.Hour)
+	digest := &model.FleetDigest{Devices: 10, NewDevices: 2, SilentDevices: 1}
+	derr := &events.DeliveryError{
+		URL: channel.URL, Err: errors.New("connection refused"),
+	}
+
+	testCases := map[string]struct {
+		settings  *model.DigestSettings
+		getErr    error
+		notifier  bool
+		from      time.Time
+		digestErr error
+		notifyErr error
+
+		outErr error
+	}{
+		"ok, no digest": {},
+		"ok, first digest": {
+			settings: &model.DigestSettings{
+				Channel: channel, PeriodHours: 24, SilentHours: 48,
+			},
+			notifier: true,
+			from:     now.Add(-24 * time.Hour),
+		},
+		"ok, since the last digest": {
+			settings: &model.DigestSettings{
+				Channel: channel, PeriodHours: 24, SilentHours: 48,
+				SentTs: &sentTs,
+			},
+			notifier: true,
+			from:     sentTs,
+		},
+		"ok, not due": {
+			settings: &model.DigestSettings{
+				Channel: channel, PeriodHours: 24, SilentHours: 48,
+				SentTs: &recentTs,
+			},
+		},
+		"ok, delivery failure": {
+			settings: &model.DigestSettings{
+				Channel: channel, PeriodHours: 24, SilentHours: 48,
+			},
+			notifier:  true,
+			from:      now.Add(-24 * time.Hour),
+			notifyErr: derr,
+		},
+		"error, notifier missing": {
+			settings: &model.DigestSettings{
+				Channel: channel, PeriodHours: 24, SilentHours: 48,
+			},
+			outErr: ErrNotifierMissing,
+		},
+		"error, digest": {
+			settings: &model.DigestSettings{
+				Channel: channel, PeriodHours: 24, SilentHours: 48,
+			},
+			notifier:  true,
+			from:      now.Add(-24 * time.Hour),
+			digestErr: errors.New("db error"),
+			outErr:    errors.New("failed to get fleet digest: db error"),
+		},
+		"error, db": {
+			getErr: errors.New("db error"),
+			outErr: errors.New("failed to get digest settings: db error"),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			db := &mstore.DataStore{}
+			notifier := &mevents.Notifier{}
+			var settings *model.DigestSettings
+			if tc.settings != nil {
+				s := *tc.settings
+				settings = &s
+			}
+			db.On("GetDigestSettings", ctx).Return(settings, tc.getErr)
+			if !tc.from.IsZero() {
+				var res *model.FleetDigest
+				if tc.digestErr == nil {
+					res = digest
+				}
+				db.On("GetFleetDigest", ctx, tc.from, now, 48*time.Hour).
+					Return(res, tc.digestErr)
+			}
+			if !tc.from.IsZero() && tc.digestErr == nil {
+				notifier.On("Notify", ctx, channel,
+					mock.MatchedBy(func(e events.Event) bool {
+						return e.Type == events.TypeFleetDigest &&
+							reflect.DeepEqual(e.Data, *digest)
+					}),
+				).Return(tc.notifyErr)
+				expected := *tc.settings
+				expected.SentTs = &now
+				db.On("SetDigestSettings", ctx, expected).Return(nil)
+			}
+			if tc.notifyErr != nil {
+				db.On("CreateDeadLetter", ctx,
+					mock.AnythingOfType("model.DeadLetter"),
+				).Return(nil)
+			}
+
+			i := &inventory{db: db}
+			if tc.notifier {
+				i.notifier = notifier
+			}
+			err := i.sendDigest(ctx, now)
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			db.AssertExpectations(t)
+			notifier.AssertExpectations(t)
+		})
+	}
+}
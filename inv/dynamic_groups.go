@@ -0,0 +1,236 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+)
+
+var (
+	// ErrDynamicGroupsLimit is returned when the tenant has too many
+	// dynamic groups.
+	ErrDynamicGroupsLimit = errors.Errorf(
+		"at most %d dynamic groups are allowed",
+		model.DynamicGroupsMax,
+	)
+	// ErrGroupNameInUse is returned when a dynamic group is defined with
+	// the name of a group the devices are assigned to.
+	ErrGroupNameInUse = errors.New("the devices are assigned to a group with the same name")
+	// ErrDynamicGroupQuery is returned when the devices of a dynamic group
+	// are listed with filters or sort keys; the filters belong in the
+	// expression of the group.
+	ErrDynamicGroupQuery = errors.New("the devices of a dynamic group cannot be filtered or sorted")
+)
+
+// ListDynamicGroups returns the dynamic groups, sorted by name.
+func (i *inventory) ListDynamicGroups(ctx context.Context) ([]model.DynamicGroup, error) {
+	groups, err := i.db.GetDynamicGroups(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list dynamic groups")
+	}
+	return groups, nil
+}
+
+func (i *inventory) GetDynamicGroup(
+	ctx context.Context,
+	name model.GroupName,
+) (*model.DynamicGroup, error) {
+	group, err := i.db.GetDynamicGroup(ctx, name)
+	if err != nil && err != store.ErrDynamicGroupNotFound {
+		return nil, errors.Wrap(err, "failed to get dynamic group")
+	}
+	return group, err
+}
+
+// ReplaceDynamicGroup creates or redefines the dynamic group; a new group
+// cannot take the name of a group the devices are assigned to.
+func (i *inventory) ReplaceDynamicGroup(
+	ctx context.Context,
+	group model.DynamicGroup,
+) (*model.DynamicGroup, error) {
+	now := time.Now()
+	created := false
+	current, err := i.db.GetDynamicGroup(ctx, group.Name)
+	switch err {
+	case nil:
+		group.CreatedTs = current.CreatedTs
+	case store.ErrDynamicGroupNotFound:
+		groups, err := i.db.GetDynamicGroups(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list dynamic groups")
+		} else if len(groups) >= model.DynamicGroupsMax {
+			return nil, ErrDynamicGroupsLimit
+		}
+		_, _, err = i.db.GetDevicesByGroup(ctx, group.Name, store.ListQuery{Limit: 1})
+		if err == nil {
+			return nil, ErrGroupNameInUse
+		} else if err != store.ErrGroupNotFound {
+			return nil, errors.Wrap(err, "failed to check the group devices")
+		}
+		group.CreatedTs = now
+		created = true
+	default:
+		return nil, errors.Wrap(err, "failed to get dynamic group")
+	}
+
+	group.UpdatedTs = now
+	if err := i.db.UpsertDynamicGroup(ctx, group); err != nil {
+		return nil, errors.Wrap(err, "failed to replace dynamic group")
+	}
+	if created {
+		i.addGroupMetadata(ctx, group.Name, model.GroupTypeDynamic)
+	}
+	return &group, nil
+}
+
+// DeleteDynamicGroup removes the dynamic group together with its
+// metadata.
+func (i *inventory) DeleteDynamicGroup(ctx context.Context, name model.GroupName) error {
+	err := i.db.DeleteDynamicGroup(ctx, name)
+	if err != nil && err != store.ErrDynamicGroupNotFound {
+		return errors.Wrap(err, "failed to remove dynamic group")
+	} else if err != nil {
+		return err
+	}
+	err = i.db.DeleteGroupMetadata(ctx, name)
+	if err != nil && err != store.ErrGroupMetadataNotFound {
+		log.FromContext(ctx).Errorf(
+			"failed to remove the metadata of group %s: %v", name, err)
+	}
+	return nil
+}
+
+// dynamicGroupNames returns the names of the dynamic groups; if filters
+// are given, only the groups with a device matching them are returned.
+func (i *inventory) dynamicGroupNames(
+	ctx context.Context,
+	filters []model.FilterPredicate,
+) ([]model.GroupName, error) {
+	groups, err := i.db.GetDynamicGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]model.GroupName, 0, len(groups))
+	for _, group := range groups {
+		if len(filters) > 0 {
+			predicates, err := group.Filters()
+			if err != nil {
+				log.FromContext(ctx).Warnf(
+					"skipping dynamic group %s: %s", group.Name, err.Error())
+				continue
+			}
+			_, count, err := i.db.SearchDevices(ctx, model.SearchParams{
+				Page:    1,
+				PerPage: 1,
+				Filters: append(predicates, filters...),
+			})
+			if err != nil {
+				return nil, err
+			} else if count == 0 {
+				continue
+			}
+		}
+		names = append(names, group.Name)
+	}
+	return names, nil
+}
+
+// dynamicGroupCounts returns the number of the devices matching the
+// expression of each dynamic group and the filters; with the filters,
+// the groups without matching devices are skipped, as by
+// dynamicGroupNames.
+func (i *inventory) dynamicGroupCounts(
+	ctx context.Context,
+	filters []model.FilterPredicate,
+) ([]model.GroupCount, error) {
+	groups, err := i.db.GetDynamicGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counts := make([]model.GroupCount, 0, len(groups))
+	for _, group := range groups {
+		predicates, err := group.Filters()
+		if err != nil {
+			log.FromContext(ctx).Warnf(
+				"skipping dynamic group %s: %s", group.Name, err.Error())
+			continue
+		}
+		_, count, err := i.db.SearchDevices(ctx, model.SearchParams{
+			Page:    1,
+			PerPage: 1,
+			Filters: append(predicates, filters...),
+		})
+		if err != nil {
+			return nil, err
+		} else if count == 0 && len(filters) > 0 {
+			continue
+		}
+		counts = append(counts, model.GroupCount{
+			Group: group.Name,
+			Count: count,
+		})
+	}
+	return counts, nil
+}
+
+// listDynamicGroupDevices returns a page of the devices matching
+// the expression of the dynamic group.
+func (i *inventory) listDynamicGroupDevices(
+	ctx context.Context,
+	group *model.DynamicGroup,
+	skip, limit int,
+) ([]model.DeviceID, int, error) {
+	devs, totalCount, err := i.searchDynamicGroupDevices(ctx, group, skip, limit,
+		[]model.SelectAttribute{{
+			Scope:     model.AttrScopeSystem,
+			Attribute: model.AttrNameGroup,
+		}},
+	)
+	if err != nil {
+		return nil, -1, err
+	}
+	ids := make([]model.DeviceID, len(devs))
+	for n, dev := range devs {
+		ids[n] = dev.ID
+	}
+	return ids, totalCount, nil
+}
+
+// searchDynamicGroupDevices returns a page of the devices matching the
+// filters of the dynamic group, limited to the selected attributes if any.
+func (i *inventory) searchDynamicGroupDevices(
+	ctx context.Context,
+	group *model.DynamicGroup,
+	skip, limit int,
+	attributes []model.SelectAttribute,
+) ([]model.Device, int, error) {
+	predicates, err := group.Filters()
+	if err != nil {
+		return nil, -1, errors.Wrapf(err, "dynamic group %s", group.Name)
+	}
+	return i.db.SearchDevices(ctx, model.SearchParams{
+		Page:       skip/limit + 1,
+		PerPage:    limit,
+		Filters:    predicates,
+		Attributes: attributes,
+	})
+}
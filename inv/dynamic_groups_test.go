@@ -0,0 +1,310 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+	mstore "github.com/mendersoftware/inventory/store/mocks"
+)
+
+func TestInventoryReplaceDynamicGroup(t *testing.T) {
+	t.Parallel()
+
+	created := time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)
+	group := model.DynamicGroup{
+		Name:       "rpi4",
+		Expression: `inventory/device_type == "raspberrypi4"`,
+	}
+	testCases := map[string]struct {
+		current    *model.DynamicGroup
+		currentErr error
+		groups     int
+		staticErr  error
+		upsert     bool
+
+		err string
+	}{
+		"ok, created": {
+			currentErr: store.ErrDynamicGroupNotFound,
+			staticErr:  store.ErrGroupNotFound,
+			upsert:     true,
+		},
+		"ok, replaced": {
+			current: &model.DynamicGroup{Name: "rpi4", CreatedTs: created},
+			upsert:  true,
+		},
+		"error, limit": {
+			currentErr: store.ErrDynamicGroupNotFound,
+			groups:     model.DynamicGroupsMax,
+			err:        ErrDynamicGroupsLimit.Error(),
+		},
+		"error, static group": {
+			currentErr: store.ErrDynamicGroupNotFound,
+			err:        ErrGroupNameInUse.Error(),
+		},
+		"error, db": {
+			currentErr: errors.New("db error"),
+			err:        "failed to get dynamic group: db error",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			db := &mstore.DataStore{}
+			db.On("GetDynamicGroup", ctx, group.Name).
+				Return(tc.current, tc.currentErr)
+			if tc.currentErr == store.ErrDynamicGroupNotFound {
+				db.On("GetDynamicGroups", ctx).
+					Return(make([]model.DynamicGroup, tc.groups), nil)
+				if tc.groups < model.DynamicGroupsMax {
+					db.On("GetDevicesByGroup", ctx, group.Name, store.ListQuery{Limit: 1}).
+						Return([]model.DeviceID{}, 0, tc.staticErr)
+				}
+			}
+			if tc.upsert {
+				db.On("UpsertDynamicGroup", ctx,
+					mock.MatchedBy(func(g model.DynamicGroup) bool {
+						return g.Name == group.Name &&
+							g.Expression == group.Expression &&
+							!g.UpdatedTs.IsZero()
+					}),
+				).Return(nil)
+				if tc.current == nil {
+					db.On("InsertGroupsMetadata", ctx,
+						mock.MatchedBy(func(groups []model.GroupMetadata) bool {
+							return len(groups) == 1 &&
+								groups[0].Name == group.Name &&
+								groups[0].Type == model.GroupTypeDynamic
+						}),
+					).Return(nil)
+				}
+			}
+
+			res, err := invForTest(db).ReplaceDynamicGroup(ctx, group)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else if assert.NoError(t, err) {
+				if tc.current != nil {
+					assert.Equal(t, created, res.CreatedTs)
+				} else {
+					assert.Equal(t, res.UpdatedTs, res.CreatedTs)
+				}
+			}
+			db.AssertExpectations(t)
+		})
+	}
+}
+
+func TestInventoryListGroupsDynamic(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	filters := []model.FilterPredicate{{
+		Scope:     model.AttrScopeIdentity,
+		Attribute: "status",
+		Type:      "$eq",
+		Value:     "accepted",
+	}}
+	db := &mstore.DataStore{}
+	db.On("ListGroups", ctx, filters).
+		Return([]model.GroupName{"foo", "rpi4"}, nil)
+	db.On("GetFeatureFlags", ctx).Return(model.FeatureFlagSet{}, nil)
+	db.On("GetDynamicGroups", ctx).Return([]model.DynamicGroup{{
+		Name:       "arm",
+		Expression: `inventory/arch == "arm"`,
+	}, {
+		Name:       "rpi4",
+		Expression: `inventory/device_type == "raspberrypi4"`,
+	}, {
+		Name:       "x86",
+		Expression: `inventory/arch == "x86"`,
+	}}, nil)
+	match := func(value string) interface{} {
+		return mock.MatchedBy(func(params model.SearchParams) bool {
+			return len(params.Filters) == 2 &&
+				params.Filters[0].Value == value &&
+				assert.ObjectsAreEqual(filters[0], params.Filters[1])
+		})
+	}
+	db.On("SearchDevices", ctx, match("arm")).
+		Return([]model.Device{{ID: "1"}}, 1, nil)
+	db.On("SearchDevices", ctx, match("raspberrypi4")).
+		Return([]model.Device{{ID: "2"}}, 1, nil)
+	db.On("SearchDevices", ctx, match("x86")).
+		Return([]model.Device{}, 0, nil)
+
+	groups, err := invForTest(db).ListGroups(ctx, filters)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.GroupName{"foo", "rpi4", "arm"}, groups)
+	db.AssertExpectations(t)
+}
+
+func TestInventoryListGroupCountsDynamic(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	filters := []model.FilterPredicate{{
+		Scope:     model.AttrScopeIdentity,
+		Attribute: "status",
+		Type:      "$eq",
+		Value:     "accepted",
+	}}
+	db := &mstore.DataStore{}
+	db.On("CountDevicesByGroup", ctx, filters).
+		Return([]model.GroupCount{
+			{Group: "foo", Count: 3},
+			{Group: "rpi4", Count: 2},
+		}, nil)
+	db.On("GetFeatureFlags", ctx).Return(model.FeatureFlagSet{}, nil)
+	db.On("GetDynamicGroups", ctx).Return([]model.DynamicGroup{{
+		Name:       "arm",
+		Expression: `inventory/arch == "arm"`,
+	}, {
+		Name:       "rpi4",
+		Expression: `inventory/device_type == "raspberrypi4"`,
+	}, {
+		Name:       "x86",
+		Expression: `inventory/arch == "x86"`,
+	}}, nil)
+	match := func(value string) interface{} {
+		return mock.MatchedBy(func(params model.SearchParams) bool {
+			return len(params.Filters) == 2 &&
+				params.Filters[0].Value == value &&
+				assert.ObjectsAreEqual(filters[0], params.Filters[1])
+		})
+	}
+	db.On("SearchDevices", ctx, match("arm")).
+		Return([]model.Device{{ID: "1"}}, 4, nil)
+	db.On("SearchDevices", ctx, match("raspberrypi4")).
+		Return([]model.Device{{ID: "2"}}, 1, nil)
+	db.On("SearchDevices", ctx, match("x86")).
+		Return([]model.Device{}, 0, nil)
+
+	counts, err := invForTest(db).ListGroupCounts(ctx, filters)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.GroupCount{
+		{Group: "arm", Count: 4},
+		{Group: "foo", Count: 3},
+		{Group: "rpi4", Count: 2},
+	}, counts)
+	db.AssertExpectations(t)
+}
+
+func TestInventoryListDevicesByDynamicGroup(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db := &mstore.DataStore{}
+	db.On("GetFeatureFlags", ctx).Return(model.FeatureFlagSet{}, nil)
+	db.On("GetDynamicGroup", ctx, model.GroupName("rpi4")).
+		Return(&model.DynamicGroup{
+			Name:       "rpi4",
+			Expression: `inventory/device_type == "raspberrypi4"`,
+		}, nil)
+	db.On("SearchDevices", ctx, model.SearchParams{
+		Page:    3,
+		PerPage: 10,
+		Filters: []model.FilterPredicate{{
+			Scope:     model.AttrScopeInventory,
+			Attribute: "device_type",
+			Type:      "$eq",
+			Value:     "raspberrypi4",
+		}},
+		Attributes: []model.SelectAttribute{{
+			Scope:     model.AttrScopeSystem,
+			Attribute: model.AttrNameGroup,
+		}},
+	}).Return([]model.Device{{ID: "1"}, {ID: "2"}}, 22, nil)
+
+	ids, total, err := invForTest(db).ListDevicesByGroup(ctx, "rpi4",
+		store.ListQuery{Skip: 20, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.DeviceID{"1", "2"}, ids)
+	assert.Equal(t, 22, total)
+	db.AssertExpectations(t)
+}
+
+func TestInventoryListDevicesByDynamicGroupFull(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	devs := []model.Device{{
+		ID: "1",
+		Attributes: model.DeviceAttributes{{
+			Scope: model.AttrScopeInventory,
+			Name:  "device_type",
+			Value: "raspberrypi4",
+		}},
+	}}
+	db := &mstore.DataStore{}
+	db.On("GetFeatureFlags", ctx).Return(model.FeatureFlagSet{}, nil)
+	db.On("GetDynamicGroup", ctx, model.GroupName("rpi4")).
+		Return(&model.DynamicGroup{
+			Name:       "rpi4",
+			Expression: `inventory/device_type == "raspberrypi4"`,
+		}, nil)
+	db.On("SearchDevices", ctx, model.SearchParams{
+		Page:    1,
+		PerPage: 10,
+		Filters: []model.FilterPredicate{{
+			Scope:     model.AttrScopeInventory,
+			Attribute: "device_type",
+			Type:      "$eq",
+			Value:     "raspberrypi4",
+		}},
+	}).Return(devs, 1, nil)
+
+	res, total, err := invForTest(db).ListDevicesByGroupFull(ctx, "rpi4",
+		store.ListQuery{Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, devs, res)
+	assert.Equal(t, 1, total)
+	db.AssertExpectations(t)
+}
+
+func TestInventoryDeleteDynamicGroup(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db := &mstore.DataStore{}
+	db.On("DeleteDynamicGroup", ctx, model.GroupName("rpi4")).
+		Return(nil).Once()
+	db.On("DeleteGroupMetadata", ctx, model.GroupName("rpi4")).
+		Return(nil).Once()
+	db.On("DeleteDynamicGroup", ctx, model.GroupName("rpi4")).
+		Return(store.ErrDynamicGroupNotFound).Once()
+	db.On("DeleteDynamicGroup", ctx, model.GroupName("rpi4")).
+		Return(errors.New("db error")).Once()
+	i := invForTest(db)
+
+	assert.NoError(t, i.DeleteDynamicGroup(ctx, "rpi4"))
+	assert.Equal(t, store.ErrDynamicGroupNotFound,
+		i.DeleteDynamicGroup(ctx, "rpi4"))
+	assert.EqualError(t, i.DeleteDynamicGroup(ctx, "rpi4"),
+		"failed to remove dynamic group: db error")
+	db.AssertExpectations(t)
+}
@@ -0,0 +1,42 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+)
+
+// CheckDeviceEligibility evaluates the conditions against the current
+// attributes of the device, listing the ones it fails, so that e.g.
+// deployments can gate the installs on the inventory.
+func (i *inventory) CheckDeviceEligibility(
+	ctx context.Context,
+	id model.DeviceID,
+	req model.EligibilityRequest,
+) (*model.DeviceEligibility, error) {
+	dev, err := i.db.GetDevice(ctx, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch device")
+	} else if dev == nil {
+		return nil, store.ErrDevNotFound
+	}
+	res := model.CheckEligibility(dev, req.Conditions)
+	return &res, nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+	mstore "github.com/mendersoftware/inventory/store/mocks"
+)
+
+func TestInventoryCheckDeviceEligibility(t *testing.T) {
+	t.Parallel()
+
+	req := model.EligibilityRequest{
+		Conditions: []model.EligibilityCondition{{
+			Scope:     model.AttrScopeInventory,
+			Attribute: "device_type",
+			Type:      model.EligibilityEq,
+			Value:     "rpi4",
+		}, {
+			Scope:     model.AttrScopeInventory,
+			Attribute: "rootfs_free_kb",
+			Type:      model.EligibilityGte,
+			Value:     float64(4096),
+		}},
+	}
+	testCases := map[string]struct {
+		dev   *model.Device
+		dbErr error
+
+		res *model.DeviceEligibility
+		err string
+	}{
+		"ok, eligible": {
+			dev: &model.Device{
+				ID: "1",
+				Attributes: model.DeviceAttributes{
+					{Scope: "inventory", Name: "device_type", Value: "rpi4"},
+					{Scope: "inventory", Name: "rootfs_free_kb", Value: float64(8192)},
+				},
+			},
+			res: &model.DeviceEligibility{
+				DeviceID: "1",
+				Eligible: true,
+				Failed:   []model.EligibilityFailure{},
+			},
+		},
+		"ok, ineligible": {
+			dev: &model.Device{
+				ID: "1",
+				Attributes: model.DeviceAttributes{
+					{Scope: "inventory", Name: "device_type", Value: "rpi4"},
+				},
+			},
+			res: &model.DeviceEligibility{
+				DeviceID: "1",
+				Failed: []model.EligibilityFailure{
+					{Condition: req.Conditions[1]},
+				},
+			},
+		},
+		"error, device not found": {
+			err: store.ErrDevNotFound.Error(),
+		},
+		"error, db": {
+			dbErr: errors.New("db error"),
+			err:   "failed to fetch device: db error",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			db := &mstore.DataStore{}
+			db.On("GetDevice", ctx, model.DeviceID("1")).
+				Return(tc.dev, tc.dbErr)
+
+			i := invForTest(db)
+			res, err := i.CheckDeviceEligibility(ctx, "1", req)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.res, res)
+			db.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,269 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/mendersoftware/go-lib-micro/mongo/oid"
+	"github.com/mendersoftware/go-lib-micro/requestid"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/blob"
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+	"github.com/mendersoftware/inventory/utils/parquet"
+	"github.com/mendersoftware/inventory/utils/reqctx"
+)
+
+var ErrExportsDisabled = errors.New("exports are disabled: no blob store configured")
+
+var invalidColumnChars = regexp.MustCompile("[^A-Za-z0-9_]")
+
+// WithBlobStore sets the store the exports are written to; without it
+// the exports are disabled.
+func (i *inventory) WithBlobStore(blobs blob.Store) InventoryApp {
+	i.blobs = blobs
+	return i
+}
+
+// StartExport creates an export job and runs it in the background.
+func (i *inventory) StartExport(
+	ctx context.Context,
+	req model.ExportRequest,
+) (*model.ExportJob, error) {
+	if i.blobs == nil {
+		return nil, ErrExportsDisabled
+	}
+	job := model.ExportJob{
+		ID:        oid.NewUUIDv4().String(),
+		Kind:      req.Kind,
+		Format:    model.ExportFormatParquet,
+		Status:    model.ExportStatusPending,
+		CreatedTs: time.Now(),
+	}
+	if err := i.db.CreateExportJob(ctx, job); err != nil {
+		return nil, errors.Wrap(err, "failed to create export job")
+	}
+	go i.runExport(detachContext(ctx), job)
+	return &job, nil
+}
+
+func (i *inventory) GetExportJob(ctx context.Context, id string) (*model.ExportJob, error) {
+	return i.db.GetExportJob(ctx, id)
+}
+
+// detachContext returns a context with the identity, the request ID and
+// the logger of ctx, which is not canceled together with it, for the jobs
+// outliving the request.
+func detachContext(ctx context.Context) context.Context {
+	dctx := log.WithContext(context.Background(), log.FromContext(ctx))
+	dctx = requestid.WithContext(dctx, requestid.FromContext(ctx))
+	if id := identity.FromContext(ctx); id != nil {
+		dctx = identity.WithContext(dctx, id)
+	}
+	return dctx
+}
+
+// exportObjectKey returns the key of the exported file in the blob store.
+func exportObjectKey(ctx context.Context, job model.ExportJob) string {
+	tenantID := reqctx.FromContext(ctx).TenantID
+	if tenantID == "" {
+		tenantID = "default"
+	}
+	return path.Join("exports", tenantID, job.ID+"."+job.Format)
+}
+
+func (i *inventory) runExport(ctx context.Context, job model.ExportJob) {
+	l := log.FromContext(ctx)
+
+	job.Status = model.ExportStatusRunning
+	if err := i.db.UpdateExportJob(ctx, job); err != nil {
+		l.Errorf("export %s: %s", job.ID, err.Error())
+	}
+
+	key := exportObjectKey(ctx, job)
+	devices, err := i.exportInventory(ctx, key)
+	finished := time.Now()
+	job.Devices = devices
+	job.FinishedTs = &finished
+	if err != nil {
+		l.Errorf("export %s: %s", job.ID, err.Error())
+		job.Status = model.ExportStatusFailed
+		job.Error = err.Error()
+	} else {
+		job.Status = model.ExportStatusDone
+		job.Object = key
+	}
+	if err := i.db.UpdateExportJob(ctx, job); err != nil {
+		l.Errorf("export %s: %s", job.ID, err.Error())
+	}
+}
+
+// exportColumn maps a catalog attribute to a column of the export.
+type exportColumn struct {
+	scope, name string
+	typ         parquet.Type
+}
+
+// exportColumns derives the columns of the export from the attribute
+// catalog, typed according to the attribute schema; the values of
+// the attributes without a type are exported as strings.
+func (i *inventory) exportColumns(
+	ctx context.Context,
+) ([]parquet.Column, []exportColumn, error) {
+	attrs, err := i.db.GetFiltersAttributes(ctx)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get the attribute catalog")
+	}
+	defs, err := i.db.GetAttributeDefinitions(ctx)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get the attribute schema")
+	}
+	types := make(map[[2]string]string, len(defs))
+	for _, def := range defs {
+		types[[2]string{def.Scope, def.Name}] = def.Type
+	}
+
+	columns := []parquet.Column{
+		{Name: "id", Type: parquet.String},
+		{Name: "updated_ts", Type: parquet.Timestamp, Optional: true},
+	}
+	names := map[string]bool{"id": true, "updated_ts": true}
+	mapping := make([]exportColumn, 0, len(attrs))
+	for _, attr := range attrs {
+		col := exportColumn{scope: attr.Scope, name: attr.Name}
+		switch types[[2]string{attr.Scope, attr.Name}] {
+		case model.AttributeTypeNumber:
+			col.typ = parquet.Double
+		case model.AttributeTypeArray:
+			col.typ = parquet.JSON
+		default:
+			col.typ = parquet.String
+		}
+		// warehouses accept letters, digits and underscores only
+		base := invalidColumnChars.ReplaceAllString(attr.Scope+"_"+attr.Name, "_")
+		name := base
+		for n := 2; names[name]; n++ {
+			name = base + "_" + strconv.Itoa(n)
+		}
+		names[name] = true
+		columns = append(columns, parquet.Column{
+			Name:     name,
+			Type:     col.typ,
+			Optional: true,
+		})
+		mapping = append(mapping, col)
+	}
+	return columns, mapping, nil
+}
+
+// exportRow returns the values of the columns for the device; values not
+// conforming to the type of the column are exported as nulls.
+func exportRow(dev model.Device, columns []exportColumn) []interface{} {
+	values := make(map[[2]string]interface{}, len(dev.Attributes))
+	for _, attr := range dev.Attributes {
+		values[[2]string{attr.Scope, attr.Name}] = attr.Value
+	}
+
+	row := make([]interface{}, 0, len(columns)+2)
+	row = append(row, string(dev.ID))
+	if dev.UpdatedTs.IsZero() {
+		row = append(row, nil)
+	} else {
+		row = append(row, dev.UpdatedTs)
+	}
+	for _, col := range columns {
+		value := values[[2]string{col.scope, col.name}]
+		switch v := value.(type) {
+		case nil:
+		case float64:
+			if col.typ == parquet.String {
+				value = strconv.FormatFloat(v, 'f', -1, 64)
+			}
+		case string:
+			if col.typ == parquet.Double {
+				value = nil
+			}
+		default:
+			switch col.typ {
+			case parquet.Double:
+				value = nil
+			case parquet.String:
+				if data, err := json.Marshal(v); err == nil {
+					value = string(data)
+				} else {
+					value = nil
+				}
+			}
+		}
+		row = append(row, value)
+	}
+	return row
+}
+
+// exportInventory writes the snapshot of the current inventory to
+// the blob store and returns the number of exported devices.
+func (i *inventory) exportInventory(ctx context.Context, key string) (int, error) {
+	columns, mapping, err := i.exportColumns(ctx)
+	if err != nil {
+		return 0, err
+	}
+	stream, _, err := i.db.StreamDevices(ctx, store.ListQuery{})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to read devices")
+	}
+	defer stream.Close()
+
+	pr, pw := io.Pipe()
+	stored := make(chan error, 1)
+	go func() {
+		err := i.blobs.Put(ctx, key, pr)
+		// unblock the writer if the store gave up early
+		pr.CloseWithError(errors.New("blob store stopped reading"))
+		stored <- err
+	}()
+
+	w := parquet.NewWriter(pw, columns)
+	count := 0
+	for dev := range stream.Devices() {
+		if err = w.Write(exportRow(dev, mapping)...); err != nil {
+			break
+		}
+		count++
+	}
+	if err == nil {
+		err = stream.Err()
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	pw.CloseWithError(err)
+	// a failure of the blob store also fails the writer: report the cause
+	if serr := <-stored; serr != nil {
+		return count, errors.Wrap(serr, "failed to store the export")
+	} else if err != nil {
+		return count, errors.Wrap(err, "failed to export devices")
+	}
+	return count, nil
+}
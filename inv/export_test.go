@@ -0,0 +1,182 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	mblob "github.com/mendersoftware/inventory/blob/mocks"
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+	mstore "github.com/mendersoftware/inventory/store/mocks"
+	"github.com/mendersoftware/inventory/utils/parquet"
+)
+
+func TestInventoryStartExportDisabled(t *testing.T) {
+	t.Parallel()
+
+	i := invForTest(&mstore.DataStore{})
+	job, err := i.StartExport(context.Background(), model.ExportRequest{
+		Kind: model.ExportKindInventory,
+	})
+	assert.Equal(t, ErrExportsDisabled, err)
+	assert.Nil(t, job)
+}
+
+func TestInventoryExportColumns(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db := &mstore.DataStore{}
+	db.On("GetFiltersAttributes", ctx).Return([]model.FilterAttribute{
+		{Name: "cpu.count", Scope: model.AttrScopeInventory},
+		{Name: "cpu_count", Scope: model.AttrScopeInventory},
+		{Name: "ips", Scope: model.AttrScopeInventory},
+		{Name: "status", Scope: model.AttrScopeIdentity},
+	}, nil)
+	db.On("GetAttributeDefinitions", ctx).Return([]model.AttributeDefinition{
+		{Name: "cpu.count", Scope: model.AttrScopeInventory,
+			Type: model.AttributeTypeNumber},
+		{Name: "ips", Scope: model.AttrScopeInventory,
+			Type: model.AttributeTypeArray},
+	}, nil)
+
+	i := &inventory{db: db}
+	columns, mapping, err := i.exportColumns(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []parquet.Column{
+		{Name: "id", Type: parquet.String},
+		{Name: "updated_ts", Type: parquet.Timestamp, Optional: true},
+		{Name: "inventory_cpu_count", Type: parquet.Double, Optional: true},
+		{Name: "inventory_cpu_count_2", Type: parquet.String, Optional: true},
+		{Name: "inventory_ips", Type: parquet.JSON, Optional: true},
+		{Name: "identity_status", Type: parquet.String, Optional: true},
+	}, columns)
+	assert.Len(t, mapping, 4)
+}
+
+func TestExportRow(t *testing.T) {
+	t.Parallel()
+
+	updated := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	columns := []exportColumn{
+		{scope: "inventory", name: "num", typ: parquet.Double},
+		{scope: "inventory", name: "num_str", typ: parquet.Double},
+		{scope: "inventory", name: "str", typ: parquet.String},
+		{scope: "inventory", name: "str_num", typ: parquet.String},
+		{scope: "inventory", name: "str_arr", typ: parquet.String},
+		{scope: "inventory", name: "arr", typ: parquet.JSON},
+		{scope: "inventory", name: "missing", typ: parquet.String},
+	}
+	dev := model.Device{
+		ID:        "1",
+		UpdatedTs: updated,
+		Attributes: model.DeviceAttributes{
+			{Scope: "inventory", Name: "num", Value: 1.5},
+			{Scope: "inventory", Name: "num_str", Value: "foo"},
+			{Scope: "inventory", Name: "str", Value: "bar"},
+			{Scope: "inventory", Name: "str_num", Value: float64(2)},
+			{Scope: "inventory", Name: "str_arr",
+				Value: []interface{}{"a", "b"}},
+			{Scope: "inventory", Name: "arr", Value: []interface{}{"a"}},
+		},
+	}
+	assert.Equal(t, []interface{}{
+		"1", updated, 1.5, nil, "bar", "2", `["a","b"]`, []interface{}{"a"}, nil,
+	}, exportRow(dev, columns))
+
+	row := exportRow(model.Device{ID: "2"}, nil)
+	assert.Equal(t, []interface{}{"2", nil}, row)
+}
+
+func TestInventoryExportInventory(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		putErr error
+
+		outCount int
+		outErr   string
+	}{
+		"ok": {
+			outCount: 1,
+		},
+		"error, blob store": {
+			putErr:   errors.New("disk full"),
+			outCount: 1,
+			outErr:   "failed to store the export: disk full",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+
+			cur := &mstore.DeviceCursor{}
+			cur.On("Next", mock.Anything).Return(true).Once()
+			cur.On("Next", mock.Anything).Return(false)
+			cur.On("Decode", mock.Anything).
+				Run(func(args mock.Arguments) {
+					*args.Get(0).(*model.Device) = model.Device{ID: "1"}
+				}).
+				Return(nil)
+			cur.On("Err").Return(nil)
+			cur.On("Close", mock.Anything).Return(nil)
+
+			db := &mstore.DataStore{}
+			db.On("GetFiltersAttributes", ctx).
+				Return([]model.FilterAttribute{}, nil)
+			db.On("GetAttributeDefinitions", ctx).
+				Return([]model.AttributeDefinition{}, nil)
+			db.On("StreamDevices", ctx, store.ListQuery{}).
+				Return(store.NewDeviceStream(ctx, cur), 1, nil)
+
+			var stored bytes.Buffer
+			blobs := &mblob.Store{}
+			blobs.On("Put", ctx, "exports/default/1.parquet",
+				mock.AnythingOfType("*io.PipeReader"),
+			).Return(func(_ context.Context, _ string, r io.Reader) error {
+				if tc.putErr != nil {
+					return tc.putErr
+				}
+				data, err := ioutil.ReadAll(r)
+				stored.Write(data)
+				return err
+			})
+
+			i := &inventory{db: db, blobs: blobs}
+			count, err := i.exportInventory(ctx, "exports/default/1.parquet")
+			if tc.outErr != "" {
+				assert.EqualError(t, err, tc.outErr)
+			} else {
+				assert.NoError(t, err)
+				assert.True(t, bytes.HasPrefix(stored.Bytes(), []byte("PAR1")))
+				assert.True(t, bytes.HasSuffix(stored.Bytes(), []byte("PAR1")))
+			}
+			assert.Equal(t, tc.outCount, count)
+		})
+	}
+}
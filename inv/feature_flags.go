@@ -0,0 +1,134 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/utils/reqctx"
+)
+
+// featureFlagsRefreshInterval is how long the feature flags of a tenant
+// are cached; changes made through other instances take up to this long
+// to take effect.
+const featureFlagsRefreshInterval = 30 * time.Second
+
+type cachedFeatureFlags struct {
+	flags     model.FeatureFlagSet
+	fetchedAt time.Time
+}
+
+// featureFlagsCache caches the feature flags overridden per tenant.
+type featureFlagsCache struct {
+	mu      sync.Mutex
+	tenants map[string]cachedFeatureFlags
+}
+
+func newFeatureFlagsCache() *featureFlagsCache {
+	return &featureFlagsCache{
+		tenants: make(map[string]cachedFeatureFlags),
+	}
+}
+
+func (c *featureFlagsCache) get(tenantID string) (model.FeatureFlagSet, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached, ok := c.tenants[tenantID]
+	if !ok || time.Since(cached.fetchedAt) > featureFlagsRefreshInterval {
+		return nil, false
+	}
+	return cached.flags, true
+}
+
+func (c *featureFlagsCache) set(tenantID string, flags model.FeatureFlagSet) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tenants[tenantID] = cachedFeatureFlags{
+		flags:     flags,
+		fetchedAt: time.Now(),
+	}
+}
+
+// WithFeatureFlags sets the state of the feature flags the tenants
+// did not override; the flags left out are enabled.
+func (i *inventory) WithFeatureFlags(defaults model.FeatureFlagSet) InventoryApp {
+	i.features = model.DefaultFeatureFlags().Merge(defaults)
+	return i
+}
+
+func (i *inventory) defaultFeatureFlags() model.FeatureFlagSet {
+	if i.features == nil {
+		return model.DefaultFeatureFlags()
+	}
+	return i.features
+}
+
+// GetFeatureFlags returns the state of all the feature flags for the tenant
+// in the context.
+func (i *inventory) GetFeatureFlags(ctx context.Context) (model.FeatureFlagSet, error) {
+	tenantID := reqctx.FromContext(ctx).TenantID
+	overrides, ok := i.featureCache.get(tenantID)
+	if !ok {
+		var err error
+		overrides, err = i.db.GetFeatureFlags(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get feature flags")
+		}
+		i.featureCache.set(tenantID, overrides)
+	}
+	return i.defaultFeatureFlags().Merge(overrides), nil
+}
+
+// UpdateFeatureFlags overrides the feature flags for the tenant in
+// the context and returns the resulting state of all the flags.
+func (i *inventory) UpdateFeatureFlags(
+	ctx context.Context,
+	update model.FeatureFlagsUpdate,
+) (model.FeatureFlagSet, error) {
+	if err := i.db.UpdateFeatureFlags(ctx, update); err != nil {
+		return nil, errors.Wrap(err, "failed to update feature flags")
+	}
+	overrides, err := i.db.GetFeatureFlags(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get feature flags")
+	}
+	i.featureCache.set(reqctx.FromContext(ctx).TenantID, overrides)
+	return i.defaultFeatureFlags().Merge(overrides), nil
+}
+
+// FeatureEnabled returns true if the feature is enabled for the tenant in
+// the context; if the flags cannot be retrieved, the default applies.
+func (i *inventory) FeatureEnabled(ctx context.Context, flag model.FeatureFlag) bool {
+	flags, err := i.GetFeatureFlags(ctx)
+	if err != nil {
+		l := log.FromContext(ctx)
+		l.Warnf("using the default state of feature flag %s: %s",
+			flag, err.Error())
+		return i.defaultFeatureFlags().Enabled(flag)
+	}
+	return flags.Enabled(flag)
+}
@@ -0,0 +1,113 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	mevents "github.com/mendersoftware/inventory/events/mocks"
+	"github.com/mendersoftware/inventory/model"
+	mstore "github.com/mendersoftware/inventory/store/mocks"
+)
+
+func TestInventoryFeatureFlags(t *testing.T) {
+	t.Parallel()
+
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "tenant",
+	})
+	disabled := false
+
+	db := &mstore.DataStore{}
+	db.On("GetFeatureFlags", ctx).
+		Return(model.FeatureFlagSet{model.FeatureWebhooks: false}, nil).
+		Once()
+	i := NewInventory(db).WithFeatureFlags(model.FeatureFlagSet{
+		model.FeatureDynamicGroups: false,
+	})
+
+	flags, err := i.GetFeatureFlags(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, model.FeatureFlagSet{
+		model.FeatureDynamicGroups: false,
+		model.FeatureWebhooks:      false,
+		model.FeatureAPIv2:         true,
+	}, flags)
+	// the flags of the tenant are cached
+	assert.False(t, i.FeatureEnabled(ctx, model.FeatureWebhooks))
+	assert.True(t, i.FeatureEnabled(ctx, model.FeatureAPIv2))
+	db.AssertExpectations(t)
+
+	update := model.FeatureFlagsUpdate{
+		model.FeatureWebhooks: nil,
+		model.FeatureAPIv2:    &disabled,
+	}
+	db.On("UpdateFeatureFlags", ctx, update).Return(nil)
+	db.On("GetFeatureFlags", ctx).
+		Return(model.FeatureFlagSet{model.FeatureAPIv2: false}, nil).
+		Once()
+	flags, err = i.UpdateFeatureFlags(ctx, update)
+	assert.NoError(t, err)
+	assert.Equal(t, model.FeatureFlagSet{
+		model.FeatureDynamicGroups: false,
+		model.FeatureWebhooks:      true,
+		model.FeatureAPIv2:         false,
+	}, flags)
+	assert.False(t, i.FeatureEnabled(ctx, model.FeatureAPIv2))
+	db.AssertExpectations(t)
+
+	// the other tenants are not affected
+	ctx = context.Background()
+	db.On("GetFeatureFlags", ctx).Return(nil, errors.New("db error"))
+	_, err = i.GetFeatureFlags(ctx)
+	assert.EqualError(t, err, "failed to get feature flags: db error")
+	assert.True(t, i.FeatureEnabled(ctx, model.FeatureAPIv2))
+	assert.False(t, i.FeatureEnabled(ctx, model.FeatureDynamicGroups))
+
+	db = &mstore.DataStore{}
+	db.On("UpdateFeatureFlags", ctx, update).Return(errors.New("db error"))
+	_, err = invForTest(db).UpdateFeatureFlags(ctx, update)
+	assert.EqualError(t, err, "failed to update feature flags: db error")
+}
+
+func TestInventoryWebhooksFeatureFlag(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	ids := []model.DeviceID{"1"}
+	db := &mstore.DataStore{}
+	db.On("GetDevicesGroups", ctx, ids).
+		Return(map[model.DeviceID][]model.GroupName{"1": nil}, nil)
+	db.On("UpdateDevicesGroup", ctx, ids, model.GroupName("foo")).
+		Return(&model.UpdateResult{MatchedCount: 1, UpdatedCount: 1}, nil)
+	db.On("InsertGroupsMetadata", ctx, mock.Anything).Return(nil)
+	db.On("UpsertDevicesAttributes", ctx, ids, mock.Anything).
+		Return(&model.UpdateResult{MatchedCount: 1, UpdatedCount: 1}, nil)
+	db.On("GetFeatureFlags", ctx).
+		Return(model.FeatureFlagSet{model.FeatureWebhooks: false}, nil)
+	emitter := &mevents.Emitter{}
+
+	_, err := invForTest(db).WithEventEmitter(emitter).
+		UpdateDevicesGroup(ctx, ids, "foo")
+	assert.NoError(t, err)
+	db.AssertExpectations(t)
+	emitter.AssertNotCalled(t, "Emit", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,88 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+)
+
+// ErrInvalidParentDevice is returned when the parent_device attribute
+// does not refer to another existing device.
+var ErrInvalidParentDevice = errors.New("invalid parent device")
+
+// checkParentDevice verifies the parent_device system attribute, if
+// written, refers to another device of the inventory.
+func (i *inventory) checkParentDevice(
+	ctx context.Context,
+	id model.DeviceID,
+	attrs model.DeviceAttributes,
+) error {
+	for _, attr := range attrs {
+		if attr.Scope != model.AttrScopeSystem ||
+			attr.Name != model.AttrNameParentDevice {
+			continue
+		}
+		parent, ok := attr.Value.(string)
+		if !ok || parent == "" {
+			return errors.Wrap(ErrInvalidParentDevice,
+				"the value must be a device ID")
+		} else if model.DeviceID(parent) == id {
+			return errors.Wrap(ErrInvalidParentDevice,
+				"a device cannot be its own parent")
+		}
+		dev, err := i.db.GetDevice(ctx, model.DeviceID(parent))
+		if err != nil {
+			return errors.Wrap(err, "failed to get the parent device")
+		} else if dev == nil {
+			return errors.Wrapf(ErrInvalidParentDevice,
+				"device %s not found", parent)
+		}
+	}
+	return nil
+}
+
+// ListDeviceChildren lists the devices connected through the gateway,
+// i.e. the devices having the gateway as their parent_device.
+func (i *inventory) ListDeviceChildren(
+	ctx context.Context,
+	id model.DeviceID,
+	skip, limit int,
+) ([]model.Device, int, error) {
+	dev, err := i.db.GetDevice(ctx, id)
+	if err != nil {
+		return nil, -1, errors.Wrap(err, "failed to fetch device")
+	} else if dev == nil {
+		return nil, -1, store.ErrDevNotFound
+	}
+	devs, totalCount, err := i.db.GetDevices(ctx, store.ListQuery{
+		Skip:  skip,
+		Limit: limit,
+		Filters: []store.Filter{{
+			AttrName:  model.AttrNameParentDevice,
+			AttrScope: model.AttrScopeSystem,
+			Value:     string(id),
+			Operator:  store.Eq,
+		}},
+	})
+	if err != nil {
+		return nil, -1, errors.Wrap(err, "failed to fetch device children")
+	}
+	return devs, totalCount, nil
+}
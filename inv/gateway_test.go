@@ -0,0 +1,149 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+	mstore "github.com/mendersoftware/inventory/store/mocks"
+)
+
+func TestInventoryCheckParentDevice(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		value  interface{}
+		parent *model.Device
+		dbErr  error
+
+		outErr error
+	}{
+		"ok": {
+			value:  "gw",
+			parent: &model.Device{ID: "gw"},
+		},
+		"error, not a string": {
+			value:  1.0,
+			outErr: errors.New("the value must be a device ID: invalid parent device"),
+		},
+		"error, itself": {
+			value:  "dev",
+			outErr: errors.New("a device cannot be its own parent: invalid parent device"),
+		},
+		"error, not found": {
+			value:  "gw",
+			outErr: errors.New("device gw not found: invalid parent device"),
+		},
+		"error, db": {
+			value:  "gw",
+			dbErr:  errors.New("db error"),
+			outErr: errors.New("failed to get the parent device: db error"),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			db := &mstore.DataStore{}
+			db.On("GetDevice", ctx, model.DeviceID("gw")).
+				Return(tc.parent, tc.dbErr)
+
+			i := invForTest(db)
+			err := i.(*inventory).checkParentDevice(ctx, "dev",
+				model.DeviceAttributes{
+					{Scope: model.AttrScopeInventory, Name: "mac", Value: "gw"},
+					{
+						Scope: model.AttrScopeSystem,
+						Name:  model.AttrNameParentDevice,
+						Value: tc.value,
+					},
+				})
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestInventoryListDeviceChildren(t *testing.T) {
+	t.Parallel()
+
+	children := []model.Device{{ID: "1"}, {ID: "2"}}
+	testCases := map[string]struct {
+		gateway    *model.Device
+		gatewayErr error
+		devsErr    error
+
+		outErr error
+	}{
+		"ok": {
+			gateway: &model.Device{ID: "gw"},
+		},
+		"error, not found": {
+			outErr: store.ErrDevNotFound,
+		},
+		"error, gateway": {
+			gatewayErr: errors.New("db error"),
+			outErr:     errors.New("failed to fetch device: db error"),
+		},
+		"error, children": {
+			gateway: &model.Device{ID: "gw"},
+			devsErr: errors.New("db error"),
+			outErr:  errors.New("failed to fetch device children: db error"),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			db := &mstore.DataStore{}
+			db.On("GetDevice", ctx, model.DeviceID("gw")).
+				Return(tc.gateway, tc.gatewayErr)
+			db.On("GetDevices", ctx, store.ListQuery{
+				Skip:  10,
+				Limit: 5,
+				Filters: []store.Filter{{
+					AttrName:  model.AttrNameParentDevice,
+					AttrScope: model.AttrScopeSystem,
+					Value:     "gw",
+					Operator:  store.Eq,
+				}},
+			}).Return(children, 12, tc.devsErr)
+
+			i := invForTest(db)
+			devs, total, err := i.ListDeviceChildren(ctx, "gw", 10, 5)
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+				assert.Equal(t, -1, total)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, children, devs)
+			assert.Equal(t, 12, total)
+		})
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/utils/reqctx"
+)
+
+// groupCountsInterval is the minimum time between two recounts of
+// the devices per group for a watcher, bounding the load of the devices
+// changing in bursts on the database.
+const groupCountsInterval = time.Second
+
+// groupCountsHub signals the watchers of the group counts of a tenant
+// about the changes of its devices, as observed by the change stream.
+type groupCountsHub struct {
+	// interval is the minimum time between two recounts
+	interval time.Duration
+
+	mu       sync.Mutex
+	watchers map[string]map[chan struct{}]struct{}
+}
+
+func (h *groupCountsHub) subscribe(tenantID string) chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.watchers == nil {
+		h.watchers = make(map[string]map[chan struct{}]struct{})
+	}
+	if h.watchers[tenantID] == nil {
+		h.watchers[tenantID] = make(map[chan struct{}]struct{})
+	}
+	// a single pending signal is enough: the counts are recomputed
+	// from scratch
+	signal := make(chan struct{}, 1)
+	h.watchers[tenantID][signal] = struct{}{}
+	return signal
+}
+
+func (h *groupCountsHub) unsubscribe(tenantID string, signal chan struct{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.watchers[tenantID], signal)
+	if len(h.watchers[tenantID]) == 0 {
+		delete(h.watchers, tenantID)
+	}
+}
+
+func (h *groupCountsHub) notify(tenantID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for signal := range h.watchers[tenantID] {
+		select {
+		case signal <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// WatchGroupCounts delivers the number of devices per group of the tenant
+// in the context, first the current ones and then each time they change,
+// until the context is canceled. The changes are detected by the change
+// stream of the subscriptions worker; without it only the current counts
+// are delivered.
+func (i *inventory) WatchGroupCounts(ctx context.Context) (<-chan []model.GroupCount, error) {
+	counts, err := i.db.CountDevicesByGroup(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to count devices by group")
+	}
+	tenantID := reqctx.FromContext(ctx).TenantID
+	signal := i.groupCounts.subscribe(tenantID)
+
+	updates := make(chan []model.GroupCount)
+	go func() {
+		defer close(updates)
+		defer i.groupCounts.unsubscribe(tenantID, signal)
+		l := log.FromContext(ctx)
+
+		changed := true
+		for {
+			if changed {
+				select {
+				case updates <- counts:
+					changed = false
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-signal:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case <-time.After(i.groupCounts.interval):
+			case <-ctx.Done():
+				return
+			}
+			current, err := i.db.CountDevicesByGroup(ctx, nil)
+			if err != nil {
+				if ctx.Err() == nil {
+					l.Errorf("failed to count devices by group: %s",
+						err.Error())
+				}
+				continue
+			}
+			if !reflect.DeepEqual(current, counts) {
+				counts, changed = current, true
+			}
+		}
+	}()
+	return updates, nil
+}
+
+// handleDeviceChange is called by the change stream on each change of
+// a device of the tenant in the context.
+func (i *inventory) handleDeviceChange(ctx context.Context, change model.DeviceChange) error {
+	// a change failing to be recorded stops the change stream, so that
+	// it is retried when the stream resumes
+	if err := i.db.AppendDeviceChange(ctx, change); err != nil {
+		return err
+	}
+	i.groupCounts.notify(reqctx.FromContext(ctx).TenantID)
+	if change.Type == model.DeviceChangeDeleted {
+		return nil
+	}
+	return i.notifySubscribers(ctx, change.DeviceID)
+}
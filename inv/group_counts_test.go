@@ -0,0 +1,102 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/inventory/model"
+	mstore "github.com/mendersoftware/inventory/store/mocks"
+)
+
+func TestInventoryWatchGroupCounts(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(
+		identity.WithContext(context.Background(), &identity.Identity{
+			Tenant: "tenant",
+		}),
+	)
+	defer cancel()
+	otherCtx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "other",
+	})
+	pending := []model.GroupCount{{Group: "pending", Count: 2}}
+	moving := []model.GroupCount{
+		{Group: "pending", Count: 1},
+		{Group: "updated", Count: 1},
+	}
+	updated := []model.GroupCount{{Group: "updated", Count: 2}}
+
+	db := &mstore.DataStore{}
+	db.On("CountDevicesByGroup", ctx, []model.FilterPredicate(nil)).Return(pending, nil).Once()
+	db.On("CountDevicesByGroup", ctx, []model.FilterPredicate(nil)).Return(moving, nil).Once()
+	db.On("CountDevicesByGroup", ctx, []model.FilterPredicate(nil)).Return(nil, errors.New("db error")).Once()
+	// unchanged counts are not delivered
+	db.On("CountDevicesByGroup", ctx, []model.FilterPredicate(nil)).Return(moving, nil).Once()
+	db.On("CountDevicesByGroup", ctx, []model.FilterPredicate(nil)).Return(updated, nil)
+	db.On("AppendDeviceChange", mock.Anything, mock.AnythingOfType("model.DeviceChange")).
+		Return(nil)
+	db.On("GetSubscriptions", mock.Anything, "").Return(nil, nil)
+
+	i := &inventory{db: db}
+	updates, err := i.WatchGroupCounts(ctx)
+	assert.NoError(t, err)
+
+	next := func() []model.GroupCount {
+		select {
+		case counts := <-updates:
+			return counts
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for the group counts")
+		}
+		return nil
+	}
+	assert.Equal(t, pending, next())
+	change := model.DeviceChange{DeviceID: "1", Type: model.DeviceChangeUpdated}
+	for _, expected := range [][]model.GroupCount{moving, updated} {
+		// the changes of the devices of the other tenants are ignored
+		assert.NoError(t, i.handleDeviceChange(otherCtx, change))
+		for {
+			assert.NoError(t, i.handleDeviceChange(ctx, change))
+			select {
+			case counts := <-updates:
+				assert.Equal(t, expected, counts)
+			case <-time.After(10 * time.Millisecond):
+				continue
+			}
+			break
+		}
+	}
+
+	cancel()
+	_, open := <-updates
+	assert.False(t, open)
+	db.AssertExpectations(t)
+
+	db = &mstore.DataStore{}
+	db.On("CountDevicesByGroup", ctx, []model.FilterPredicate(nil)).Return(nil, errors.New("db error"))
+	i = &inventory{db: db}
+	_, err = i.WatchGroupCounts(ctx)
+	assert.EqualError(t, err,
+		"failed to count devices by group: db error")
+}
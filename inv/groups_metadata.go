@@ -0,0 +1,180 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+	"github.com/mendersoftware/inventory/utils/reqctx"
+)
+
+// ErrGroupCycle is returned when the parent of a group is the group itself
+// or one of its descendants.
+var ErrGroupCycle = errors.New("the parent group is nested in the group")
+
+// ListGroupsMetadata returns the metadata of the groups, sorted by name.
+func (i *inventory) ListGroupsMetadata(ctx context.Context) ([]model.GroupMetadata, error) {
+	groups, err := i.db.GetGroupsMetadata(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list groups metadata")
+	}
+	return groups, nil
+}
+
+func (i *inventory) GetGroupMetadata(
+	ctx context.Context,
+	name model.GroupName,
+) (*model.GroupMetadata, error) {
+	group, err := i.db.GetGroupMetadata(ctx, name)
+	if err != nil && err != store.ErrGroupMetadataNotFound {
+		return nil, errors.Wrap(err, "failed to get group metadata")
+	}
+	return group, err
+}
+
+// ReplaceGroupMetadata creates or replaces the description of the group;
+// the type, the creator and the creation time of the group are kept.
+func (i *inventory) ReplaceGroupMetadata(
+	ctx context.Context,
+	group model.GroupMetadata,
+) (*model.GroupMetadata, error) {
+	now := time.Now()
+	current, err := i.db.GetGroupMetadata(ctx, group.Name)
+	switch err {
+	case nil:
+		group.CreatedTs = current.CreatedTs
+		group.CreatedBy = current.CreatedBy
+	case store.ErrGroupMetadataNotFound:
+		group.CreatedTs = now
+		if info := reqctx.FromContext(ctx); info.IsUser {
+			group.CreatedBy = info.Subject
+		}
+	default:
+		return nil, errors.Wrap(err, "failed to get group metadata")
+	}
+
+	if group.Parent != "" {
+		if err := i.checkGroupParent(ctx, group); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = i.db.GetDynamicGroup(ctx, group.Name)
+	switch err {
+	case nil:
+		group.Type = model.GroupTypeDynamic
+	case store.ErrDynamicGroupNotFound:
+		group.Type = model.GroupTypeStatic
+	default:
+		return nil, errors.Wrap(err, "failed to get dynamic group")
+	}
+
+	group.UpdatedTs = now
+	if err := i.db.UpsertGroupMetadata(ctx, group); err != nil {
+		return nil, errors.Wrap(err, "failed to replace group metadata")
+	}
+	return &group, nil
+}
+
+// checkGroupParent returns ErrGroupCycle if the parent of the group is
+// nested in the group.
+func (i *inventory) checkGroupParent(ctx context.Context, group model.GroupMetadata) error {
+	groups, err := i.db.GetGroupsMetadata(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to list groups metadata")
+	}
+	parents := make(map[model.GroupName]model.GroupName, len(groups))
+	for _, g := range groups {
+		parents[g.Name] = g.Parent
+	}
+	parents[group.Name] = group.Parent
+	// the stored hierarchy has no cycles, so the walk up from the new
+	// parent either reaches the group or a root
+	for parent := group.Parent; parent != ""; parent = parents[parent] {
+		if parent == group.Name {
+			return ErrGroupCycle
+		}
+	}
+	return nil
+}
+
+// GetGroupDescendants returns the names of the groups nested in the group,
+// directly or not, sorted by name.
+func (i *inventory) GetGroupDescendants(
+	ctx context.Context,
+	name model.GroupName,
+) ([]model.GroupName, error) {
+	groups, err := i.db.GetGroupsMetadata(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list groups metadata")
+	}
+	children := make(map[model.GroupName][]model.GroupName)
+	for _, g := range groups {
+		if g.Parent != "" {
+			children[g.Parent] = append(children[g.Parent], g.Name)
+		}
+	}
+	descendants := []model.GroupName{}
+	seen := map[model.GroupName]bool{name: true}
+	for queue := children[name]; len(queue) > 0; queue = queue[1:] {
+		child := queue[0]
+		if seen[child] {
+			continue
+		}
+		seen[child] = true
+		descendants = append(descendants, child)
+		queue = append(queue, children[child]...)
+	}
+	sort.Slice(descendants, func(i, j int) bool {
+		return descendants[i] < descendants[j]
+	})
+	return descendants, nil
+}
+
+func (i *inventory) DeleteGroupMetadata(ctx context.Context, name model.GroupName) error {
+	err := i.db.DeleteGroupMetadata(ctx, name)
+	if err != nil && err != store.ErrGroupMetadataNotFound {
+		return errors.Wrap(err, "failed to remove group metadata")
+	}
+	return err
+}
+
+// addGroupMetadata stores the metadata of a group created along with
+// the change, unless the group already has some. The group is already
+// created at this point, so failures are only logged.
+func (i *inventory) addGroupMetadata(
+	ctx context.Context,
+	name model.GroupName,
+	groupType string,
+) {
+	now := time.Now()
+	err := i.db.InsertGroupsMetadata(ctx, []model.GroupMetadata{{
+		Name:      name,
+		Type:      groupType,
+		CreatedTs: now,
+		UpdatedTs: now,
+	}})
+	if err != nil {
+		log.FromContext(ctx).Errorf(
+			"failed to store the metadata of group %s: %v", name, err)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+	mstore "github.com/mendersoftware/inventory/store/mocks"
+)
+
+func TestInventoryReplaceGroupMetadata(t *testing.T) {
+	t.Parallel()
+
+	created := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	group := model.GroupMetadata{
+		Name:        "prod",
+		Description: "production devices",
+	}
+	testCases := map[string]struct {
+		current    *model.GroupMetadata
+		currentErr error
+		dynamicErr error
+		upsert     bool
+
+		groupType string
+		createdBy string
+		err       string
+	}{
+		"ok, created": {
+			currentErr: store.ErrGroupMetadataNotFound,
+			dynamicErr: store.ErrDynamicGroupNotFound,
+			upsert:     true,
+			groupType:  model.GroupTypeStatic,
+			createdBy:  "alice",
+		},
+		"ok, replaced": {
+			current: &model.GroupMetadata{
+				Name:      "prod",
+				Type:      model.GroupTypeStatic,
+				CreatedBy: "bob",
+				CreatedTs: created,
+			},
+			dynamicErr: store.ErrDynamicGroupNotFound,
+			upsert:     true,
+			groupType:  model.GroupTypeStatic,
+			createdBy:  "bob",
+		},
+		"ok, dynamic group": {
+			currentErr: store.ErrGroupMetadataNotFound,
+			upsert:     true,
+			groupType:  model.GroupTypeDynamic,
+			createdBy:  "alice",
+		},
+		"error, dynamic group": {
+			currentErr: store.ErrGroupMetadataNotFound,
+			dynamicErr: errors.New("db error"),
+			err:        "failed to get dynamic group: db error",
+		},
+		"error, db": {
+			currentErr: errors.New("db error"),
+			err:        "failed to get group metadata: db error",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := identity.WithContext(context.Background(), &identity.Identity{
+				Subject: "alice",
+				IsUser:  true,
+			})
+			db := &mstore.DataStore{}
+			db.On("GetGroupMetadata", ctx, group.Name).
+				Return(tc.current, tc.currentErr)
+			if tc.current != nil || tc.currentErr == store.ErrGroupMetadataNotFound {
+				db.On("GetDynamicGroup", ctx, group.Name).
+					Return(&model.DynamicGroup{}, tc.dynamicErr)
+			}
+			if tc.upsert {
+				db.On("UpsertGroupMetadata", ctx,
+					mock.MatchedBy(func(g model.GroupMetadata) bool {
+						return g.Name == group.Name &&
+							g.Description == group.Description &&
+							g.Type == tc.groupType &&
+							g.CreatedBy == tc.createdBy &&
+							!g.UpdatedTs.IsZero()
+					}),
+				).Return(nil)
+			}
+
+			res, err := invForTest(db).ReplaceGroupMetadata(ctx, group)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else if assert.NoError(t, err) {
+				if tc.current != nil {
+					assert.Equal(t, created, res.CreatedTs)
+				} else {
+					assert.Equal(t, res.UpdatedTs, res.CreatedTs)
+				}
+			}
+			db.AssertExpectations(t)
+		})
+	}
+}
+
+func TestInventoryGroupHierarchy(t *testing.T) {
+	t.Parallel()
+
+	// fleet > eu > (de, fr > paris), and us
+	groups := []model.GroupMetadata{
+		{Name: "de", Parent: "eu"},
+		{Name: "eu", Parent: "fleet"},
+		{Name: "fleet"},
+		{Name: "fr", Parent: "eu"},
+		{Name: "paris", Parent: "fr"},
+		{Name: "us"},
+	}
+	ctx := context.Background()
+	db := &mstore.DataStore{}
+	db.On("GetGroupsMetadata", ctx).Return(groups, nil)
+	i := invForTest(db)
+
+	descendants, err := i.GetGroupDescendants(ctx, "eu")
+	assert.NoError(t, err)
+	assert.Equal(t, []model.GroupName{"de", "fr", "paris"}, descendants)
+
+	descendants, err = i.GetGroupDescendants(ctx, "us")
+	assert.NoError(t, err)
+	assert.Empty(t, descendants)
+
+	// nesting a group in its descendant is rejected
+	db.On("GetGroupMetadata", ctx, model.GroupName("eu")).
+		Return(&groups[1], nil)
+	_, err = i.ReplaceGroupMetadata(ctx, model.GroupMetadata{
+		Name:   "eu",
+		Parent: "paris",
+	})
+	assert.Equal(t, ErrGroupCycle, err)
+
+	db.On("GetGroupMetadata", ctx, model.GroupName("us")).
+		Return(&groups[5], nil)
+	db.On("GetDynamicGroup", ctx, model.GroupName("us")).
+		Return(nil, store.ErrDynamicGroupNotFound)
+	db.On("UpsertGroupMetadata", ctx,
+		mock.MatchedBy(func(g model.GroupMetadata) bool {
+			return g.Name == "us" && g.Parent == "fleet"
+		}),
+	).Return(nil)
+	_, err = i.ReplaceGroupMetadata(ctx, model.GroupMetadata{
+		Name:   "us",
+		Parent: "fleet",
+	})
+	assert.NoError(t, err)
+	db.AssertExpectations(t)
+
+	db = &mstore.DataStore{}
+	db.On("GetGroupsMetadata", ctx).Return(nil, errors.New("db error"))
+	_, err = invForTest(db).GetGroupDescendants(ctx, "eu")
+	assert.EqualError(t, err, "failed to list groups metadata: db error")
+}
+
+func TestInventoryDeleteGroupMetadata(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db := &mstore.DataStore{}
+	db.On("DeleteGroupMetadata", ctx, model.GroupName("prod")).
+		Return(nil).Once()
+	db.On("DeleteGroupMetadata", ctx, model.GroupName("prod")).
+		Return(store.ErrGroupMetadataNotFound).Once()
+	db.On("DeleteGroupMetadata", ctx, model.GroupName("prod")).
+		Return(errors.New("db error")).Once()
+	i := invForTest(db)
+
+	assert.NoError(t, i.DeleteGroupMetadata(ctx, "prod"))
+	assert.Equal(t, store.ErrGroupMetadataNotFound,
+		i.DeleteGroupMetadata(ctx, "prod"))
+	assert.EqualError(t, i.DeleteGroupMetadata(ctx, "prod"),
+		"failed to remove group metadata: db error")
+	db.AssertExpectations(t)
+}
@@ -16,12 +16,26 @@ package inv
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
 
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
 	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
 
+	"github.com/mendersoftware/inventory/blob"
+	"github.com/mendersoftware/inventory/events"
+	"github.com/mendersoftware/inventory/metrics"
 	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/remotewrite"
 	"github.com/mendersoftware/inventory/store"
 	"github.com/mendersoftware/inventory/store/mongo"
+	"github.com/mendersoftware/inventory/utils/reqctx"
+	"github.com/mendersoftware/inventory/validator"
 )
 
 // this inventory service interface
@@ -29,7 +43,13 @@ import (
 type InventoryApp interface {
 	HealthCheck(ctx context.Context) error
 	ListDevices(ctx context.Context, q store.ListQuery) ([]model.Device, int, error)
+	CountDevices(ctx context.Context, q store.ListQuery) (int, error)
+	// StreamDevices returns the devices matching the query as a stream,
+	// together with the total number of matching devices; the stream
+	// must be closed by the caller.
+	StreamDevices(ctx context.Context, q store.ListQuery) (*store.DeviceStream, int, error)
 	GetDevice(ctx context.Context, id model.DeviceID) (*model.Device, error)
+	GetDevicesByIDs(ctx context.Context, req model.DevicesGetRequest) ([]model.Device, error)
 	AddDevice(ctx context.Context, d *model.Device) error
 	UpsertAttributes(ctx context.Context, id model.DeviceID, attrs model.DeviceAttributes) error
 	UpsertAttributesWithUpdated(ctx context.Context, id model.DeviceID, attrs model.DeviceAttributes) error
@@ -48,9 +68,30 @@ type InventoryApp interface {
 		ids []model.DeviceID,
 		group model.GroupName,
 	) (*model.UpdateResult, error)
+	UpdateDevicesGroupByFilter(
+		ctx context.Context,
+		params model.SearchParams,
+		group model.GroupName,
+	) (*model.UpdateResult, error)
+	PreviewDevicesGroup(
+		ctx context.Context,
+		assignment model.GroupAssignment,
+		group model.GroupName,
+	) (*model.GroupAssignmentPreview, error)
 	ListGroups(ctx context.Context, filters []model.FilterPredicate) ([]model.GroupName, error)
-	ListDevicesByGroup(ctx context.Context, group model.GroupName, skip int, limit int) ([]model.DeviceID, int, error)
-	GetDeviceGroup(ctx context.Context, id model.DeviceID) (model.GroupName, error)
+	ListGroupCounts(ctx context.Context, filters []model.FilterPredicate) ([]model.GroupCount, error)
+	SearchGroups(ctx context.Context, q store.GroupsQuery) ([]model.GroupName, int, error)
+	ListDevicesByGroup(
+		ctx context.Context,
+		group model.GroupName,
+		q store.ListQuery,
+	) ([]model.DeviceID, int, error)
+	ListDevicesByGroupFull(
+		ctx context.Context,
+		group model.GroupName,
+		q store.ListQuery,
+	) ([]model.Device, int, error)
+	GetDeviceGroups(ctx context.Context, id model.DeviceID) ([]model.GroupName, error)
 	DeleteDevice(ctx context.Context, id model.DeviceID) error
 	DeleteDevices(
 		ctx context.Context,
@@ -58,14 +99,193 @@ type InventoryApp interface {
 	) (*model.UpdateResult, error)
 	CreateTenant(ctx context.Context, tenant model.NewTenant) error
 	SearchDevices(ctx context.Context, searchParams model.SearchParams) ([]model.Device, int, error)
+	ExplainSearchDevices(ctx context.Context, searchParams model.SearchParams) (*model.QueryPlan, error)
+	ExplainEmptySearch(ctx context.Context, searchParams model.SearchParams) ([]model.FilterTermCount, error)
+	ValidateFilter(ctx context.Context, req model.FilterValidationRequest) (*model.FilterValidation, error)
+	ExportConfigBundle(ctx context.Context) (*model.ConfigBundle, error)
+	ImportConfigBundle(ctx context.Context, bundle model.ConfigBundle) (*model.UpdateResult, error)
+	ReplaceGroup(ctx context.Context, group model.GroupDefinition) (*model.GroupDefinition, error)
+	DeleteGroup(ctx context.Context, name model.GroupName) (*model.UpdateResult, error)
+	GetAttributeStatistics(ctx context.Context, scope, name string) (*model.AttributeStatistics, error)
+	ListTenantDatabases(ctx context.Context) ([]model.TenantDatabase, error)
+	GetAttributeGraph(ctx context.Context, scope, name string, limit int) (*model.AttributeGraph, error)
+	GetAttributePivot(
+		ctx context.Context,
+		q store.ListQuery,
+		row, column model.SelectAttribute,
+		limit int,
+	) (*model.AttributePivot, error)
+	ListScopes(ctx context.Context) ([]model.Scope, error)
+	ReplaceScope(ctx context.Context, scope model.Scope) (*model.Scope, error)
+	DeleteScope(ctx context.Context, name string) error
+	GetColdAttributes(ctx context.Context, id model.DeviceID) (model.DeviceAttributes, error)
+	ListAttributeDefinitions(ctx context.Context) ([]model.AttributeDefinition, error)
+	ReplaceAttributeDefinition(ctx context.Context, def model.AttributeDefinition) (*model.AttributeDefinition, error)
+	DeleteAttributeDefinition(ctx context.Context, scope, name string) error
+	SweepExpiredAttributes(ctx context.Context) error
+	BackfillGroups(ctx context.Context) (*model.UpdateResult, error)
+	CutoverGroups(ctx context.Context) error
+	ListSchemaViolations(ctx context.Context, id model.DeviceID, skip, limit int) ([]model.SchemaViolation, int, error)
+	PreviewGroup(ctx context.Context, preview model.GroupPreview) (*model.GroupPreviewResult, error)
+	ListDynamicGroups(ctx context.Context) ([]model.DynamicGroup, error)
+	GetDynamicGroup(ctx context.Context, name model.GroupName) (*model.DynamicGroup, error)
+	ReplaceDynamicGroup(ctx context.Context, group model.DynamicGroup) (*model.DynamicGroup, error)
+	DeleteDynamicGroup(ctx context.Context, name model.GroupName) error
+	ListGroupsMetadata(ctx context.Context) ([]model.GroupMetadata, error)
+	GetGroupMetadata(ctx context.Context, name model.GroupName) (*model.GroupMetadata, error)
+	ReplaceGroupMetadata(ctx context.Context, group model.GroupMetadata) (*model.GroupMetadata, error)
+	DeleteGroupMetadata(ctx context.Context, name model.GroupName) error
+	GetGroupDescendants(ctx context.Context, name model.GroupName) ([]model.GroupName, error)
+	ReconcileDevices(ctx context.Context, rec model.Reconciliation) (*model.ReconciliationReport, error)
+	ResolveExternalID(ctx context.Context, ref model.ExternalIDRef) (model.DeviceID, error)
+	UpsertExternalIDs(ctx context.Context, ids []model.ExternalID) (*model.UpdateResult, error)
+	DeleteExternalID(ctx context.Context, ref model.ExternalIDRef) error
+	GetLimits(ctx context.Context) (model.Limits, error)
+	SetLimits(ctx context.Context, limits model.Limits) error
+	CheckLimits(ctx context.Context, size model.Limits) ([]model.LimitViolation, error)
+	GetValidationWebhook(ctx context.Context) (*model.ValidationWebhook, error)
+	SetValidationWebhook(ctx context.Context, hook model.ValidationWebhook) error
+	DeleteValidationWebhook(ctx context.Context) error
+	GetPinnedAttributes(ctx context.Context) (model.PinnedAttributes, error)
+	SetPinnedAttributes(ctx context.Context, pinned model.PinnedAttributes) error
+	GetAttributeAliases(ctx context.Context) (model.AttributeAliases, error)
+	SetAttributeAliases(ctx context.Context, aliases model.AttributeAliases) error
+	GetAttributeDescriptions(ctx context.Context) (model.AttributeDescriptions, error)
+	SetAttributeDescriptions(ctx context.Context, descs model.AttributeDescriptions) error
+	UpdateAttributeDescriptions(ctx context.Context, descs model.AttributeDescriptions) error
+	ApplyDeviceTemplate(ctx context.Context, id model.DeviceID, req model.DeviceTemplateRequest) (*model.DeviceTemplate, error)
+	GetDeviceCompleteness(ctx context.Context, id model.DeviceID) (*model.DeviceCompleteness, error)
+	GetGroupsCompleteness(ctx context.Context) ([]model.GroupCompleteness, error)
+	WatchGroupCounts(ctx context.Context) (<-chan []model.GroupCount, error)
+	ListIncompleteDevices(ctx context.Context, skip, limit int) ([]model.DeviceCompleteness, int, error)
+	GetCompletenessAlert(ctx context.Context) (*model.CompletenessAlert, error)
+	SetCompletenessAlert(ctx context.Context, alert model.CompletenessAlert) error
+	DeleteCompletenessAlert(ctx context.Context) error
+	CheckCompletenessAlerts(ctx context.Context) error
+	GetDigestSettings(ctx context.Context) (*model.DigestSettings, error)
+	SetDigestSettings(ctx context.Context, settings model.DigestSettings) error
+	DeleteDigestSettings(ctx context.Context) error
+	PreviewDigest(ctx context.Context) (*model.FleetDigest, error)
+	SendDigests(ctx context.Context) error
+	ListSavedFilters(ctx context.Context, shared bool) ([]model.SavedFilter, error)
+	GetSavedFilter(ctx context.Context, id string) (*model.SavedFilter, error)
+	CreateSavedFilter(ctx context.Context, filter model.SavedFilter) (*model.SavedFilter, error)
+	ReplaceSavedFilter(ctx context.Context, filter model.SavedFilter) (*model.SavedFilter, error)
+	DeleteSavedFilter(ctx context.Context, id string) error
+	IngestTimelineEvents(ctx context.Context, events []model.TimelineEvent) (*model.TimelineIngestResult, error)
+	GetFeatureFlags(ctx context.Context) (model.FeatureFlagSet, error)
+	UpdateFeatureFlags(ctx context.Context, update model.FeatureFlagsUpdate) (model.FeatureFlagSet, error)
+	FeatureEnabled(ctx context.Context, flag model.FeatureFlag) bool
+	ListSubscriptions(ctx context.Context) ([]model.Subscription, error)
+	CreateSubscription(ctx context.Context, sub model.Subscription) (*model.Subscription, error)
+	ReplaceSubscription(ctx context.Context, sub model.Subscription) (*model.Subscription, error)
+	DeleteSubscription(ctx context.Context, id string) error
+	WatchSubscriptions(ctx context.Context) error
+	ListDeviceChanges(ctx context.Context, token string, limit int) (*model.DeviceChangesPage, error)
+	StartExport(ctx context.Context, req model.ExportRequest) (*model.ExportJob, error)
+	GetExportJob(ctx context.Context, id string) (*model.ExportJob, error)
+	ExportAnonymized(ctx context.Context, w io.Writer, opts model.AnonymizeOptions) (int, error)
+	ListDeadLetters(ctx context.Context, skip, limit int) ([]model.DeadLetter, int, error)
+	DeleteDeadLetter(ctx context.Context, id string) error
+	PurgeDeadLetters(ctx context.Context) (*model.UpdateResult, error)
+	ReplayDeadLetter(ctx context.Context, id string) error
+	ReplayDeadLetters(ctx context.Context) (*model.DeadLettersReplay, error)
+	WarmUpCatalog(ctx context.Context) (*model.Catalog, error)
+	ListDeviceChildren(ctx context.Context, id model.DeviceID, skip, limit int) ([]model.Device, int, error)
+	DeploymentFinished(ctx context.Context, deploymentID string, ids []model.DeviceID) ([]model.DeploymentDiff, error)
+	CheckDeviceEligibility(ctx context.Context, id model.DeviceID, req model.EligibilityRequest) (*model.DeviceEligibility, error)
+	ImportTags(ctx context.Context, identity string, rows []model.TagsImportRow) (*model.TagsImportResult, error)
+	AssignTags(ctx context.Context, assignment model.TagsAssignment) (*model.TagsAssignmentResult, error)
+	WithEventEmitter(emitter events.Emitter) InventoryApp
+	WithNotifier(notifier events.Notifier) InventoryApp
+	WithFeatureFlags(defaults model.FeatureFlagSet) InventoryApp
+	WithBlobStore(blobs blob.Store) InventoryApp
+	WithRemoteWrite(w remotewrite.Writer, attributes map[string][]string) InventoryApp
+	WithDiffAttributes(attributes map[string][]string) InventoryApp
+	WithAttributesValidator(v validator.Validator) InventoryApp
+	WithSoftLimits(limits model.Limits) InventoryApp
+	WithTimelineConcurrency(n int) InventoryApp
+}
+
+var (
+	// ErrScopeWriteForbidden is returned when the caller is not allowed
+	// to write the attributes of a scope.
+	ErrScopeWriteForbidden = errors.New("writing attributes of the scope is forbidden")
+	// ErrSchemaViolation is returned when an attribute does not conform
+	// to its definition enforced by the schema.
+	ErrSchemaViolation = errors.New("attribute does not conform to the schema")
+)
+
+// bundleExportPageSize is the number of group members fetched at once
+// while listing all the devices of a group.
+const bundleExportPageSize = 1000
+
+const (
+	// statisticsConcurrency is the number of tenant databases aggregated
+	// in parallel while computing cross-tenant statistics.
+	statisticsConcurrency = 4
+	// StatisticsValuesLimit is the maximum number of distinct attribute
+	// values collected per tenant and returned in the statistics; reaching
+	// it makes the statistics approximate.
+	StatisticsValuesLimit = 100
+)
+
+var (
+	expiredAttributesRemoved = metrics.NewCounterVec(
+		"inventory_expired_attributes_removed_total",
+		"Number of expired attributes removed from the devices.",
+		"scope", "attribute",
+	)
+	retentionSweepFailures = metrics.NewCounterVec(
+		"inventory_retention_sweep_failures_total",
+		"Number of tenants the removal of expired attributes failed for.",
+	)
+)
+
+// groupTransitionEvents maps the group transitions to the types of
+// the events emitted for them.
+var groupTransitionEvents = map[string]string{
+	model.GroupTransitionJoined: events.TypeDeviceGroupJoined,
+	model.GroupTransitionLeft:   events.TypeDeviceGroupLeft,
 }
 
 type inventory struct {
-	db store.DataStore
+	db     store.DataStore
+	events events.Emitter
+
+	features     model.FeatureFlagSet
+	featureCache *featureFlagsCache
+	softLimits   model.Limits
+	limitsCache  *limitsCache
+	notifier     events.Notifier
+	blobs        blob.Store
+
+	remoteWrite      remotewrite.Writer
+	remoteWriteAttrs map[[2]string]bool
+
+	diffAttrs []model.SelectAttribute
+
+	validator validator.Validator
+
+	timelineSlots chan struct{}
+
+	groupCounts groupCountsHub
 }
 
 func NewInventory(d store.DataStore) InventoryApp {
-	return &inventory{db: d}
+	return &inventory{
+		db:           d,
+		featureCache: newFeatureFlagsCache(),
+		limitsCache:  newLimitsCache(),
+		groupCounts:  groupCountsHub{interval: groupCountsInterval},
+	}
+}
+
+// WithEventEmitter sets the emitter notifying about the changes in
+// the inventory; without it no events are emitted.
+func (i *inventory) WithEventEmitter(emitter events.Emitter) InventoryApp {
+	i.events = emitter
+	return i
 }
 
 func (i *inventory) HealthCheck(ctx context.Context) error {
@@ -82,18 +302,69 @@ func (i *inventory) ListDevices(ctx context.Context, q store.ListQuery) ([]model
 	if err != nil {
 		return nil, -1, errors.Wrap(err, "failed to fetch devices")
 	}
+	i.describeDevices(ctx, devs)
 
 	return devs, totalCount, nil
 }
 
+// CountDevices returns the number of devices matching the query, without
+// fetching them.
+func (i *inventory) CountDevices(ctx context.Context, q store.ListQuery) (int, error) {
+	count, err := i.db.CountDevicesByQuery(ctx, q)
+	if err != nil {
+		return -1, errors.Wrap(err, "failed to count devices")
+	}
+	return count, nil
+}
+
+func (i *inventory) StreamDevices(
+	ctx context.Context,
+	q store.ListQuery,
+) (*store.DeviceStream, int, error) {
+	stream, totalCount, err := i.db.StreamDevices(ctx, q)
+	if err != nil {
+		return nil, -1, errors.Wrap(err, "failed to fetch devices")
+	}
+	return stream, totalCount, nil
+}
+
 func (i *inventory) GetDevice(ctx context.Context, id model.DeviceID) (*model.Device, error) {
 	dev, err := i.db.GetDevice(ctx, id)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to fetch device")
 	}
+	if dev != nil {
+		i.describeDevices(ctx, []model.Device{*dev})
+	}
 	return dev, nil
 }
 
+// GetDevicesByIDs returns the requested devices in the order of the
+// request; the missing devices are skipped.
+func (i *inventory) GetDevicesByIDs(
+	ctx context.Context,
+	req model.DevicesGetRequest,
+) ([]model.Device, error) {
+	devs, err := i.db.GetDevicesByIDs(ctx, req.IDs, req.Attributes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch devices")
+	}
+	byID := make(map[model.DeviceID]model.Device, len(devs))
+	for _, dev := range devs {
+		byID[dev.ID] = dev
+	}
+	res := make([]model.Device, 0, len(devs))
+	for _, id := range req.IDs {
+		if dev, ok := byID[id]; ok {
+			res = append(res, dev)
+			// the IDs may be repeated
+			delete(byID, id)
+		}
+	}
+	i.describeDevices(ctx, res)
+	return res, nil
+}
+
 func (i *inventory) AddDevice(ctx context.Context, dev *model.Device) error {
 	if dev == nil {
 		return errors.New("no device given")
@@ -123,54 +394,122 @@ func (i *inventory) DeleteDevice(ctx context.Context, id model.DeviceID) error {
 }
 
 func (i *inventory) UpsertAttributes(ctx context.Context, id model.DeviceID, attrs model.DeviceAttributes) error {
-	if _, err := i.db.UpsertDevicesAttributes(
+	if err := i.checkScopeWriters(ctx, attrs); err != nil {
+		return err
+	}
+	if err := i.checkAttributeSchema(ctx, id, attrs); err != nil {
+		return err
+	}
+	if err := i.checkParentDevice(ctx, id, attrs); err != nil {
+		return err
+	}
+	if err := i.checkValidationWebhook(ctx, id, attrs); err != nil {
+		return err
+	}
+	hot, cold, err := i.splitColdAttributes(ctx, attrs)
+	if err != nil {
+		return err
+	}
+	if len(cold) > 0 {
+		if err := i.upsertColdAttributes(ctx, id, hot, cold); err != nil {
+			return err
+		}
+	} else if _, err := i.db.UpsertDevicesAttributes(
 		ctx, []model.DeviceID{id}, attrs,
 	); err != nil {
 		return errors.Wrap(err, "failed to upsert attributes in db")
 	}
+	i.forwardAttributes(ctx, id, attrs)
 	return nil
 }
 
 func (i *inventory) UpsertAttributesWithUpdated(ctx context.Context, id model.DeviceID, attrs model.DeviceAttributes) error {
-	if _, err := i.db.UpsertDevicesAttributesWithUpdated(
+	if err := i.checkScopeWriters(ctx, attrs); err != nil {
+		return err
+	}
+	if err := i.checkAttributeSchema(ctx, id, attrs); err != nil {
+		return err
+	}
+	if err := i.checkParentDevice(ctx, id, attrs); err != nil {
+		return err
+	}
+	if err := i.checkValidationWebhook(ctx, id, attrs); err != nil {
+		return err
+	}
+	hot, cold, err := i.splitColdAttributes(ctx, attrs)
+	if err != nil {
+		return err
+	}
+	if len(cold) > 0 {
+		if err := i.upsertColdAttributes(ctx, id, hot, cold); err != nil {
+			return err
+		}
+	} else if _, err := i.db.UpsertDevicesAttributesWithUpdated(
 		ctx, []model.DeviceID{id}, attrs,
 	); err != nil {
 		return errors.Wrap(err, "failed to upsert attributes in db")
 	}
+	i.forwardAttributes(ctx, id, attrs)
 	return nil
 }
 
 func (i *inventory) ReplaceAttributes(ctx context.Context, id model.DeviceID, upsertAttrs model.DeviceAttributes, scope string) error {
-	device, err := i.db.GetDevice(ctx, id)
-	if err != nil && err != store.ErrDevNotFound {
-		return errors.Wrap(err, "failed to get the device")
-	}
-	removeAttrs := model.DeviceAttributes{}
-	if device != nil {
-		for _, attr := range device.Attributes {
-			if attr.Scope == scope {
-				update := false
-				for _, upsertAttr := range upsertAttrs {
-					if upsertAttr.Name == attr.Name {
-						update = true
-					}
-				}
-				if !update {
-					removeAttrs = append(removeAttrs, attr)
-				}
-			}
+	err := i.checkScopeWriters(ctx,
+		append(model.DeviceAttributes{{Scope: scope}}, upsertAttrs...),
+	)
+	if err != nil {
+		return err
+	}
+	if err := i.checkAttributeSchema(ctx, id, upsertAttrs); err != nil {
+		return err
+	}
+	if err := i.checkParentDevice(ctx, id, upsertAttrs); err != nil {
+		return err
+	}
+	if err := i.checkValidationWebhook(ctx, id, upsertAttrs); err != nil {
+		return err
+	}
+	coldScopes, err := i.coldScopes(ctx, model.DeviceAttributes{{Scope: scope}})
+	if err != nil {
+		return err
+	}
+	hot := upsertAttrs
+	if name, _ := model.SplitAgentScope(scope); coldScopes[name] {
+		cold, err := i.db.GetColdAttributes(ctx, id)
+		if err != nil {
+			return errors.Wrap(err, "failed to get cold attributes")
+		}
+		err = i.db.UpsertRemoveColdAttributes(ctx, id,
+			upsertAttrs, removedAttributes(cold, scope, upsertAttrs))
+		if err != nil {
+			return errors.Wrap(err, "failed to replace cold attributes in db")
 		}
+		// drop the attributes written before the scope became cold
+		hot = nil
 	}
-	if _, err := i.db.UpsertRemoveDeviceAttributes(ctx, id, upsertAttrs, removeAttrs); err != nil {
+	if _, err := i.db.ReplaceDeviceAttributes(ctx, id, scope, hot); err != nil {
 		return errors.Wrap(err, "failed to replace attributes in db")
 	}
+	i.forwardAttributes(ctx, id, upsertAttrs)
 	return nil
 }
 
 func (i *inventory) GetFiltersAttributes(ctx context.Context) ([]model.FilterAttribute, error) {
-	attributes, err := i.db.GetFiltersAttributes(ctx)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to get filter attributes from the db")
+	attributes := i.cachedFiltersAttributes(ctx)
+	if attributes == nil {
+		var err error
+		attributes, err = i.db.GetFiltersAttributes(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get filter attributes from the db")
+		}
+	}
+	if len(attributes) > 0 {
+		aliases, err := i.db.GetAttributeAliases(ctx)
+		if err != nil {
+			log.FromContext(ctx).Warnf("skipping the attribute aliases: %v", err)
+		}
+		attributes = aliases.MergeFilterAttributes(attributes)
+		i.attributeDescriptions(ctx).DescribeFilterAttributes(attributes)
 	}
 	return attributes, nil
 }
@@ -188,11 +527,15 @@ func (i *inventory) UnsetDevicesGroup(
 	deviceIDs []model.DeviceID,
 	groupName model.GroupName,
 ) (*model.UpdateResult, error) {
-	return i.db.UnsetDevicesGroup(ctx, deviceIDs, groupName)
+	return i.unassignGroup(
+		ctx, deviceIDs, groupName, model.NewGroupChangeReason(ctx),
+	)
 }
 
 func (i *inventory) UnsetDeviceGroup(ctx context.Context, id model.DeviceID, group model.GroupName) error {
-	result, err := i.db.UnsetDevicesGroup(ctx, []model.DeviceID{id}, group)
+	result, err := i.unassignGroup(
+		ctx, []model.DeviceID{id}, group, model.NewGroupChangeReason(ctx),
+	)
 	if err != nil {
 		return errors.Wrap(err, "failed to unassign group from device")
 	} else if result.MatchedCount <= 0 {
@@ -206,7 +549,9 @@ func (i *inventory) UpdateDevicesGroup(
 	deviceIDs []model.DeviceID,
 	group model.GroupName,
 ) (*model.UpdateResult, error) {
-	return i.db.UpdateDevicesGroup(ctx, deviceIDs, group)
+	return i.assignGroup(
+		ctx, deviceIDs, group, model.NewGroupChangeReason(ctx),
+	)
 }
 
 func (i *inventory) UpdateDeviceGroup(
@@ -214,8 +559,8 @@ func (i *inventory) UpdateDeviceGroup(
 	devid model.DeviceID,
 	group model.GroupName,
 ) error {
-	result, err := i.db.UpdateDevicesGroup(
-		ctx, []model.DeviceID{devid}, group,
+	result, err := i.assignGroup(
+		ctx, []model.DeviceID{devid}, group, model.NewGroupChangeReason(ctx),
 	)
 	if err != nil {
 		return errors.Wrap(err, "failed to add device to group")
@@ -225,6 +570,245 @@ func (i *inventory) UpdateDeviceGroup(
 	return nil
 }
 
+// UpdateDevicesGroupByFilter adds the devices matching the search to
+// the group in a single update and records the transitions of the devices
+// which joined it. Until the groups array is rolled out the devices are
+// moved to the group; the groups they left are not known to the single
+// update, so their transitions are not recorded.
+func (i *inventory) UpdateDevicesGroupByFilter(
+	ctx context.Context,
+	params model.SearchParams,
+	group model.GroupName,
+) (*model.UpdateResult, error) {
+	result, joined, err := i.db.UpdateDevicesGroupByFilter(ctx, params, group)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to add devices to group")
+	}
+
+	reason := model.NewGroupChangeReason(ctx)
+	now := time.Now()
+	transitions := make([]model.GroupTransition, len(joined))
+	for n, id := range joined {
+		transitions[n] = model.GroupTransition{
+			DeviceID:  id,
+			Action:    model.GroupTransitionJoined,
+			Group:     group,
+			Reason:    reason,
+			Timestamp: now,
+		}
+	}
+	if len(transitions) > 0 {
+		i.addGroupMetadata(ctx, group, model.GroupTypeStatic)
+	}
+	i.recordGroupTransitions(ctx, transitions)
+	return result, nil
+}
+
+// PreviewDevicesGroup counts the devices which adding the selected devices
+// to the group would affect, without modifying them.
+func (i *inventory) PreviewDevicesGroup(
+	ctx context.Context,
+	assignment model.GroupAssignment,
+	group model.GroupName,
+) (*model.GroupAssignmentPreview, error) {
+	preview, err := i.db.PreviewDevicesGroup(ctx, assignment, group)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to preview group assignment")
+	}
+	return preview, nil
+}
+
+// assignGroup adds the devices to the group and records the transitions of
+// the devices which joined it. Until the groups array is rolled out the
+// devices are moved to the group, so the transitions of the devices which
+// left their previous groups are recorded as well.
+func (i *inventory) assignGroup(
+	ctx context.Context,
+	ids []model.DeviceID,
+	group model.GroupName,
+	reason model.GroupChangeReason,
+) (*model.UpdateResult, error) {
+	previous, err := i.db.GetDevicesGroups(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	result, err := i.db.UpdateDevicesGroup(ctx, ids, group)
+	if err != nil {
+		return nil, err
+	}
+
+	left := i.leftGroups(ctx, ids, previous, group)
+
+	now := time.Now()
+	var transitions []model.GroupTransition
+	for _, id := range ids {
+		groups, ok := previous[id]
+		if !ok || containsGroup(groups, group) {
+			continue
+		}
+		delete(previous, id)
+		transitions = append(transitions,
+			leftTransitions(id, left[id], reason, now)...)
+		transitions = append(transitions, model.GroupTransition{
+			DeviceID:  id,
+			Action:    model.GroupTransitionJoined,
+			Group:     group,
+			Reason:    reason,
+			Timestamp: now,
+		})
+	}
+	if len(transitions) > 0 {
+		i.addGroupMetadata(ctx, group, model.GroupTypeStatic)
+	}
+	i.recordGroupTransitions(ctx, transitions)
+	return result, nil
+}
+
+// leftGroups returns the groups the devices left when added to the group,
+// by their IDs: until the groups array is rolled out the devices are moved
+// to the group. The devices are in the group already, so failing to fetch
+// their current groups is only logged.
+func (i *inventory) leftGroups(
+	ctx context.Context,
+	ids []model.DeviceID,
+	previous map[model.DeviceID][]model.GroupName,
+	group model.GroupName,
+) map[model.DeviceID][]model.GroupName {
+	var moved []model.DeviceID
+	seen := make(map[model.DeviceID]bool, len(ids))
+	for _, id := range ids {
+		if groups := previous[id]; len(groups) > 0 && !seen[id] &&
+			!containsGroup(groups, group) {
+			seen[id] = true
+			moved = append(moved, id)
+		}
+	}
+	if len(moved) == 0 {
+		return nil
+	}
+	current, err := i.db.GetDevicesGroups(ctx, moved)
+	if err != nil {
+		log.FromContext(ctx).Warnf(
+			"failed to fetch the groups the devices left: %v", err)
+		return nil
+	}
+	left := make(map[model.DeviceID][]model.GroupName, len(moved))
+	for _, id := range moved {
+		remaining, ok := current[id]
+		if !ok {
+			continue
+		}
+		for _, prev := range previous[id] {
+			if !containsGroup(remaining, prev) {
+				left[id] = append(left[id], prev)
+			}
+		}
+	}
+	return left
+}
+
+func leftTransitions(
+	id model.DeviceID,
+	groups []model.GroupName,
+	reason model.GroupChangeReason,
+	now time.Time,
+) []model.GroupTransition {
+	transitions := make([]model.GroupTransition, len(groups))
+	for n, group := range groups {
+		transitions[n] = model.GroupTransition{
+			DeviceID:  id,
+			Action:    model.GroupTransitionLeft,
+			Group:     group,
+			Reason:    reason,
+			Timestamp: now,
+		}
+	}
+	return transitions
+}
+
+// unassignGroup removes the devices from the group, keeping their other
+// groups, and records the transitions of the devices which were its
+// members.
+func (i *inventory) unassignGroup(
+	ctx context.Context,
+	ids []model.DeviceID,
+	group model.GroupName,
+	reason model.GroupChangeReason,
+) (*model.UpdateResult, error) {
+	previous, err := i.db.GetDevicesGroups(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	result, err := i.db.UnsetDevicesGroup(ctx, ids, group)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var transitions []model.GroupTransition
+	for _, id := range ids {
+		if groups, ok := previous[id]; !ok || !containsGroup(groups, group) {
+			continue
+		}
+		delete(previous, id)
+		transitions = append(transitions, model.GroupTransition{
+			DeviceID:  id,
+			Action:    model.GroupTransitionLeft,
+			Group:     group,
+			Reason:    reason,
+			Timestamp: now,
+		})
+	}
+	i.recordGroupTransitions(ctx, transitions)
+	return result, nil
+}
+
+func containsGroup(groups []model.GroupName, group model.GroupName) bool {
+	for _, g := range groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// recordGroupTransitions stores the last transition of each device as its
+// system attributes and emits the group membership change events.
+// The group change is already applied at this point, so failures are
+// only logged.
+func (i *inventory) recordGroupTransitions(
+	ctx context.Context,
+	transitions []model.GroupTransition,
+) {
+	if len(transitions) == 0 {
+		return
+	}
+	l := log.FromContext(ctx)
+
+	// all the devices changed by a single call end with the same
+	// transition: either joining or leaving the same group
+	last := transitions[len(transitions)-1]
+	ids := make([]model.DeviceID, 0, len(transitions))
+	evts := make([]events.Event, len(transitions))
+	for n, t := range transitions {
+		if t.Action == last.Action {
+			ids = append(ids, t.DeviceID)
+		}
+		evts[n] = events.New(ctx, groupTransitionEvents[t.Action], t)
+	}
+	if _, err := i.db.UpsertDevicesAttributes(
+		ctx, ids, last.Attributes(),
+	); err != nil {
+		l.Errorf("failed to record group transitions: %v", err)
+	}
+	if i.events != nil && i.FeatureEnabled(ctx, model.FeatureWebhooks) {
+		if err := i.events.Emit(ctx, evts...); err != nil {
+			l.Errorf("failed to emit group change events: %v", err)
+			i.recordDeadLetter(ctx, err, "")
+		}
+	}
+}
+
 func (i *inventory) ListGroups(
 	ctx context.Context,
 	filters []model.FilterPredicate,
@@ -235,13 +819,94 @@ func (i *inventory) ListGroups(
 	}
 
 	if groups == nil {
-		return []model.GroupName{}, nil
+		groups = []model.GroupName{}
+	}
+	if !i.FeatureEnabled(ctx, model.FeatureDynamicGroups) {
+		return groups, nil
+	}
+	dynamic, err := i.dynamicGroupNames(ctx, filters)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list groups")
+	}
+	listed := make(map[model.GroupName]struct{}, len(groups))
+	for _, group := range groups {
+		listed[group] = struct{}{}
+	}
+	for _, group := range dynamic {
+		if _, ok := listed[group]; !ok {
+			groups = append(groups, group)
+		}
 	}
 	return groups, nil
 }
 
-func (i *inventory) ListDevicesByGroup(ctx context.Context, group model.GroupName, skip, limit int) ([]model.DeviceID, int, error) {
-	ids, totalCount, err := i.db.GetDevicesByGroup(ctx, group, skip, limit)
+// ListGroupCounts returns the groups together with the number of their
+// devices, sorted by name. The static groups are counted by a single
+// aggregation, in place of a count per group.
+func (i *inventory) ListGroupCounts(
+	ctx context.Context,
+	filters []model.FilterPredicate,
+) ([]model.GroupCount, error) {
+	counts, err := i.db.CountDevicesByGroup(ctx, filters)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to count devices by group")
+	}
+
+	if counts == nil {
+		counts = []model.GroupCount{}
+	}
+	if !i.FeatureEnabled(ctx, model.FeatureDynamicGroups) {
+		return counts, nil
+	}
+	dynamic, err := i.dynamicGroupCounts(ctx, filters)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to count devices by group")
+	}
+	listed := make(map[model.GroupName]struct{}, len(counts))
+	for _, count := range counts {
+		listed[count.Group] = struct{}{}
+	}
+	for _, count := range dynamic {
+		if _, ok := listed[count.Group]; !ok {
+			counts = append(counts, count)
+		}
+	}
+	sort.Slice(counts, func(a, b int) bool {
+		return counts[a].Group < counts[b].Group
+	})
+	return counts, nil
+}
+
+func (i *inventory) SearchGroups(
+	ctx context.Context,
+	q store.GroupsQuery,
+) ([]model.GroupName, int, error) {
+	groups, total, err := i.db.SearchGroups(ctx, q)
+	if err != nil {
+		return nil, -1, errors.Wrap(err, "failed to search groups")
+	}
+	return groups, total, nil
+}
+
+// ListDevicesByGroup returns a page of the IDs of the devices of the group
+// matching the filters of the query, sorted by its sort keys.
+func (i *inventory) ListDevicesByGroup(
+	ctx context.Context,
+	group model.GroupName,
+	q store.ListQuery,
+) ([]model.DeviceID, int, error) {
+	dynamic, err := i.getDynamicGroup(ctx, group, q)
+	if err != nil {
+		return nil, -1, err
+	} else if dynamic != nil {
+		ids, totalCount, err := i.listDynamicGroupDevices(ctx, dynamic, q.Skip, q.Limit)
+		if err != nil {
+			return nil, -1, errors.Wrap(err, "failed to list devices by group")
+		}
+		return ids, totalCount, nil
+	}
+
+	ids, totalCount, err := i.db.GetDevicesByGroup(ctx, group, q)
 	if err != nil {
 		if err == store.ErrGroupNotFound {
 			return nil, -1, err
@@ -253,17 +918,85 @@ func (i *inventory) ListDevicesByGroup(ctx context.Context, group model.GroupNam
 	return ids, totalCount, nil
 }
 
-func (i *inventory) GetDeviceGroup(ctx context.Context, id model.DeviceID) (model.GroupName, error) {
-	group, err := i.db.GetDeviceGroup(ctx, id)
+// ListDevicesByGroupFull returns a page of the devices of the group with
+// all their attributes, saving the clients a request per device listed by
+// ListDevicesByGroup.
+func (i *inventory) ListDevicesByGroupFull(
+	ctx context.Context,
+	group model.GroupName,
+	q store.ListQuery,
+) ([]model.Device, int, error) {
+	dynamic, err := i.getDynamicGroup(ctx, group, q)
+	if err != nil {
+		return nil, -1, err
+	} else if dynamic != nil {
+		devs, totalCount, err := i.searchDynamicGroupDevices(
+			ctx, dynamic, q.Skip, q.Limit, nil)
+		if err != nil {
+			return nil, -1, errors.Wrap(err, "failed to list devices by group")
+		}
+		return devs, totalCount, nil
+	}
+
+	hasGroup := group != ""
+	q.HasGroup = &hasGroup
+	q.GroupName = string(group)
+	q.IDsOnly = false
+	devs, totalCount, err := i.db.GetDevices(ctx, q)
+	if err != nil {
+		return nil, -1, errors.Wrap(err, "failed to list devices by group")
+	}
+	if totalCount == 0 {
+		if len(q.Filters) == 0 {
+			return nil, -1, store.ErrGroupNotFound
+		}
+		// none of the devices of the group matches the filters
+		_, _, err = i.db.GetDevicesByGroup(ctx, group, store.ListQuery{
+			Limit:      1,
+			GroupNames: q.GroupNames,
+		})
+		if err == store.ErrGroupNotFound {
+			return nil, -1, err
+		} else if err != nil {
+			return nil, -1, errors.Wrap(err, "failed to list devices by group")
+		}
+	}
+	return devs, totalCount, nil
+}
+
+// getDynamicGroup returns the dynamic group with the name if the dynamic
+// groups are enabled, or nil if there is no such group. The devices of
+// the dynamic groups cannot be filtered or sorted by the query.
+func (i *inventory) getDynamicGroup(
+	ctx context.Context,
+	group model.GroupName,
+	q store.ListQuery,
+) (*model.DynamicGroup, error) {
+	if !i.FeatureEnabled(ctx, model.FeatureDynamicGroups) {
+		return nil, nil
+	}
+	dynamic, err := i.db.GetDynamicGroup(ctx, group)
+	if err == store.ErrDynamicGroupNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to list devices by group")
+	} else if len(q.Filters) > 0 || len(q.Sort) > 0 {
+		return nil, ErrDynamicGroupQuery
+	}
+	return dynamic, nil
+}
+
+func (i *inventory) GetDeviceGroups(ctx context.Context, id model.DeviceID) ([]model.GroupName, error) {
+	groups, err := i.db.GetDeviceGroups(ctx, id)
 	if err != nil {
 		if err == store.ErrDevNotFound {
-			return "", err
+			return nil, err
 		} else {
-			return "", errors.Wrap(err, "failed to get device's group")
+			return nil, errors.Wrap(err, "failed to get device's groups")
 		}
 	}
 
-	return group, nil
+	return groups, nil
 }
 
 func (i *inventory) CreateTenant(ctx context.Context, tenant model.NewTenant) error {
@@ -275,11 +1008,939 @@ func (i *inventory) CreateTenant(ctx context.Context, tenant model.NewTenant) er
 }
 
 func (i *inventory) SearchDevices(ctx context.Context, searchParams model.SearchParams) ([]model.Device, int, error) {
-	devs, totalCount, err := i.db.SearchDevices(ctx, searchParams)
-
+	query, err := i.withRequiredAttributes(ctx, searchParams)
 	if err != nil {
+		return nil, -1, err
+	}
+	query = i.withAttributeAliases(ctx, query)
+	pinned := i.pinnedAttributes(ctx)
+	if len(query.Attributes) > 0 && len(pinned.Attributes) > 0 {
+		query.Attributes = make([]model.SelectAttribute, 0,
+			len(searchParams.Attributes)+len(pinned.Attributes))
+		query.Attributes = append(query.Attributes, searchParams.Attributes...)
+		query.Attributes = append(query.Attributes, pinned.Attributes...)
+	}
+	devs, totalCount, err := i.db.SearchDevices(ctx, query)
+	if err != nil && err != store.ErrPartialResults {
 		return nil, -1, errors.Wrap(err, "failed to fetch devices")
 	}
+	pinned.Highlight(devs, searchParams.Attributes)
+	i.describeDevices(ctx, devs)
 
-	return devs, totalCount, nil
+	return devs, totalCount, err
+}
+
+func (i *inventory) ExplainSearchDevices(
+	ctx context.Context,
+	searchParams model.SearchParams,
+) (*model.QueryPlan, error) {
+	searchParams, err := i.withRequiredAttributes(ctx, searchParams)
+	if err != nil {
+		return nil, err
+	}
+	searchParams = i.withAttributeAliases(ctx, searchParams)
+	plan, err := i.db.ExplainSearchDevices(ctx, searchParams)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to explain the device search")
+	}
+	return plan, nil
+}
+
+// ExplainEmptySearch counts the devices matching each term of the filters
+// of a search without results, to find the term eliminating all of them.
+func (i *inventory) ExplainEmptySearch(
+	ctx context.Context,
+	searchParams model.SearchParams,
+) ([]model.FilterTermCount, error) {
+	searchParams, err := i.withRequiredAttributes(ctx, searchParams)
+	if err != nil {
+		return nil, err
+	}
+	searchParams = i.withAttributeAliases(ctx, searchParams)
+	terms, err := i.db.CountFilterTerms(ctx, searchParams)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to explain the device search")
+	}
+	return terms, nil
+}
+
+// ValidateFilter parses the filter and checks the values of its predicates
+// against the types of the attributes defined in the schema. Syntax and
+// type errors are reported in the result, not returned.
+func (i *inventory) ValidateFilter(
+	ctx context.Context,
+	req model.FilterValidationRequest,
+) (*model.FilterValidation, error) {
+	result := &model.FilterValidation{}
+	predicates := req.Filters
+	var positions []int
+	if req.Expression != "" {
+		parsed, err := model.ParseFilterExpression(req.Expression)
+		if exprErr, ok := err.(*model.FilterExpressionError); ok {
+			result.Errors = []model.FilterExpressionError{*exprErr}
+			return result, nil
+		} else if err != nil {
+			return nil, err
+		}
+		predicates, positions = parsed.Predicates, parsed.Positions
+	} else if result.Errors = model.TermErrors(predicates); len(result.Errors) > 0 {
+		return result, nil
+	}
+
+	defs, err := i.db.GetAttributeDefinitions(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get attribute definitions")
+	}
+	typed := make(map[[2]string]model.AttributeDefinition, len(defs))
+	for _, def := range defs {
+		typed[[2]string{def.Scope, def.Name}] = def
+	}
+	for idx, pred := range predicates {
+		def, ok := typed[[2]string{pred.Scope, pred.Attribute}]
+		if !ok {
+			def = model.AttributeDefinition{
+				Scope: pred.Scope,
+				Name:  pred.Attribute,
+			}
+		}
+		if err := def.CheckPredicate(pred); err != nil {
+			exprErr := model.FilterExpressionError{Message: err.Error()}
+			if positions != nil {
+				exprErr.Position = positions[idx]
+			} else {
+				term := idx
+				exprErr.Term = &term
+			}
+			result.Errors = append(result.Errors, exprErr)
+		}
+	}
+	if len(result.Errors) == 0 {
+		result.Valid = true
+		result.Canonical = model.FormatFilterExpression(predicates)
+		result.Filters = predicates
+	}
+	return result, nil
+}
+
+// PreviewGroup counts the devices matching the candidate definition of
+// a dynamic group and returns the first of them.
+func (i *inventory) PreviewGroup(
+	ctx context.Context,
+	preview model.GroupPreview,
+) (*model.GroupPreviewResult, error) {
+	devs, count, err := i.db.SearchDevices(ctx, preview.SearchParams())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch devices")
+	}
+	return &model.GroupPreviewResult{
+		Count:   count,
+		Devices: devs,
+	}, nil
+}
+
+// ReconcileDevices compares the devices in the inventory with the devices
+// known to deviceauth and deployments. Optionally, the devices known to
+// deviceauth but missing from the inventory are added to it.
+func (i *inventory) ReconcileDevices(
+	ctx context.Context,
+	rec model.Reconciliation,
+) (*model.ReconciliationReport, error) {
+	ids, err := i.db.GetAllDeviceIDs(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list devices")
+	}
+	report := rec.Report(ids)
+	if !rec.CreateMissing {
+		return report, nil
+	}
+
+	known := make(map[model.DeviceID]struct{}, len(rec.DeviceAuth))
+	for _, id := range rec.DeviceAuth {
+		known[id] = struct{}{}
+	}
+	var create []model.DeviceID
+	for _, id := range report.MissingFromInventory {
+		if _, ok := known[id]; ok {
+			create = append(create, id)
+		}
+	}
+	if len(create) > 0 {
+		_, err = i.db.UpsertDevicesAttributesWithUpdated(ctx, create, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create missing devices")
+		}
+		report.Created = create
+	}
+	return report, nil
+}
+
+func (i *inventory) ExportConfigBundle(ctx context.Context) (*model.ConfigBundle, error) {
+	groups, err := i.db.ListGroups(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list groups")
+	}
+	bundle := &model.ConfigBundle{
+		Version: model.ConfigBundleVersion,
+		Groups:  make([]model.GroupDefinition, 0, len(groups)),
+	}
+	for _, group := range groups {
+		devices, err := i.listGroupMembers(ctx, group)
+		if err != nil {
+			return nil, err
+		}
+		if len(devices) > 0 {
+			bundle.Groups = append(bundle.Groups, model.GroupDefinition{
+				Name:    group,
+				Devices: devices,
+			})
+		}
+	}
+
+	metadata, err := i.db.GetGroupsMetadata(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list groups metadata")
+	}
+	for _, g := range metadata {
+		bundle.GroupsMetadata = append(bundle.GroupsMetadata,
+			model.BundleGroupMetadata{
+				Name:        g.Name,
+				Description: g.Description,
+				Parent:      g.Parent,
+			})
+	}
+
+	dynamic, err := i.db.GetDynamicGroups(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list dynamic groups")
+	}
+	for _, g := range dynamic {
+		bundle.DynamicGroups = append(bundle.DynamicGroups,
+			model.BundleDynamicGroup{
+				Name:       g.Name,
+				Expression: g.Expression,
+			})
+	}
+
+	// the private filters belong to their owners, not to the tenant
+	shared := true
+	filters, err := i.db.GetSavedFilters(ctx, store.SavedFiltersQuery{
+		Shared: &shared,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list saved filters")
+	}
+	for _, f := range filters {
+		bundle.SavedFilters = append(bundle.SavedFilters,
+			model.BundleSavedFilter{
+				ID:      f.ID,
+				Name:    f.Name,
+				Filters: f.Filters,
+				Sort:    f.Sort,
+			})
+	}
+	return bundle, nil
+}
+
+// listGroupMembers returns the IDs of all the devices in the group. The
+// members are paged by their IDs, so that the devices joining or leaving
+// the group in the meantime do not shift the pages.
+func (i *inventory) listGroupMembers(
+	ctx context.Context,
+	group model.GroupName,
+) ([]model.DeviceID, error) {
+	var devices []model.DeviceID
+	cursor := &model.DeviceCursor{}
+	for {
+		ids, _, err := i.db.GetDevicesByGroup(ctx, group, store.ListQuery{
+			Limit:  bundleExportPageSize,
+			Cursor: cursor,
+		})
+		if err == store.ErrGroupNotFound {
+			// the group is empty, or was emptied in the meantime
+			break
+		} else if err != nil {
+			return nil, errors.Wrapf(err,
+				"failed to list devices of group %s", group)
+		}
+		devices = append(devices, ids...)
+		if len(ids) < bundleExportPageSize {
+			break
+		}
+		cursor = &model.DeviceCursor{ID: ids[len(ids)-1]}
+	}
+	return devices, nil
+}
+
+// ImportConfigBundle applies the bundle: the devices are added to the
+// static groups, the dynamic groups, the groups metadata and the saved
+// filters are created or replaced. The import is idempotent; the
+// configuration missing from the bundle is kept.
+func (i *inventory) ImportConfigBundle(
+	ctx context.Context,
+	bundle model.ConfigBundle,
+) (*model.UpdateResult, error) {
+	if err := bundle.Validate(); err != nil {
+		return nil, err
+	}
+	result := &model.UpdateResult{}
+	reason := model.GroupChangeReason{Type: model.GroupChangeImport}
+	for _, group := range bundle.Groups {
+		res, err := i.assignGroup(ctx, group.Devices, group.Name, reason)
+		if err != nil {
+			return result, errors.Wrapf(err,
+				"failed to import group %s", group.Name)
+		}
+		result.MatchedCount += res.MatchedCount
+		result.UpdatedCount += res.UpdatedCount
+	}
+	// the dynamic groups go first, for their metadata to take their type
+	for _, group := range bundle.DynamicGroups {
+		_, err := i.ReplaceDynamicGroup(ctx, model.DynamicGroup{
+			Name:       group.Name,
+			Expression: group.Expression,
+		})
+		if err != nil {
+			return result, errors.Wrapf(err,
+				"failed to import dynamic group %s", group.Name)
+		}
+	}
+	for _, group := range bundle.GroupsMetadata {
+		_, err := i.ReplaceGroupMetadata(ctx, model.GroupMetadata{
+			Name:        group.Name,
+			Description: group.Description,
+			Parent:      group.Parent,
+		})
+		if err != nil {
+			return result, errors.Wrapf(err,
+				"failed to import the metadata of group %s", group.Name)
+		}
+	}
+	for _, filter := range bundle.SavedFilters {
+		if err := i.importSavedFilter(ctx, filter.SavedFilter()); err != nil {
+			return result, errors.Wrapf(err,
+				"failed to import saved filter %s", filter.ID)
+		}
+	}
+	return result, nil
+}
+
+// ReplaceGroup sets the members of the group to exactly the given devices:
+// the remaining members are removed from the group and the devices are
+// assigned to it by a single update. The call is idempotent: repeating it
+// after a failure or a concurrent change converges to the given members.
+// Devices missing from the inventory are skipped; returns the resulting
+// group.
+func (i *inventory) ReplaceGroup(
+	ctx context.Context,
+	group model.GroupDefinition,
+) (*model.GroupDefinition, error) {
+	previous, err := i.db.GetDevicesGroups(ctx, group.Devices)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get the groups of the devices")
+	}
+	_, left, err := i.db.ReplaceDevicesGroup(ctx, group.Devices, group.Name)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to replace the devices of group")
+	}
+
+	reason := model.NewGroupChangeReason(ctx)
+	now := time.Now()
+	transitions := make([]model.GroupTransition, len(left))
+	for n, id := range left {
+		transitions[n] = model.GroupTransition{
+			DeviceID:  id,
+			Action:    model.GroupTransitionLeft,
+			Group:     group.Name,
+			Reason:    reason,
+			Timestamp: now,
+		}
+	}
+	i.recordGroupTransitions(ctx, transitions)
+
+	moved := i.leftGroups(ctx, group.Devices, previous, group.Name)
+	devices := []model.DeviceID{}
+	transitions = nil
+	for _, id := range group.Devices {
+		groups, ok := previous[id]
+		if !ok {
+			continue
+		}
+		delete(previous, id)
+		devices = append(devices, id)
+		if containsGroup(groups, group.Name) {
+			continue
+		}
+		transitions = append(transitions,
+			leftTransitions(id, moved[id], reason, now)...)
+		transitions = append(transitions, model.GroupTransition{
+			DeviceID:  id,
+			Action:    model.GroupTransitionJoined,
+			Group:     group.Name,
+			Reason:    reason,
+			Timestamp: now,
+		})
+	}
+	if len(transitions) > 0 {
+		i.addGroupMetadata(ctx, group.Name, model.GroupTypeStatic)
+	}
+	i.recordGroupTransitions(ctx, transitions)
+
+	return &model.GroupDefinition{
+		Name:    group.Name,
+		Devices: devices,
+	}, nil
+}
+
+// DeleteGroup removes all the devices from the group at once together with
+// the metadata of the group; the metadata of a dynamic group with the same
+// name is kept. Returns ErrGroupNotFound if the group has neither members
+// nor metadata.
+func (i *inventory) DeleteGroup(
+	ctx context.Context,
+	name model.GroupName,
+) (*model.UpdateResult, error) {
+	members, err := i.listGroupMembers(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	result, err := i.db.DeleteGroup(ctx, name)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to remove devices from group")
+	}
+
+	reason := model.NewGroupChangeReason(ctx)
+	now := time.Now()
+	transitions := make([]model.GroupTransition, len(members))
+	for n, id := range members {
+		transitions[n] = model.GroupTransition{
+			DeviceID:  id,
+			Action:    model.GroupTransitionLeft,
+			Group:     name,
+			Reason:    reason,
+			Timestamp: now,
+		}
+	}
+	i.recordGroupTransitions(ctx, transitions)
+
+	_, err = i.db.GetDynamicGroup(ctx, name)
+	switch err {
+	case store.ErrDynamicGroupNotFound:
+		err = i.db.DeleteGroupMetadata(ctx, name)
+		if err == store.ErrGroupMetadataNotFound && result.MatchedCount == 0 {
+			return nil, store.ErrGroupNotFound
+		} else if err != nil && err != store.ErrGroupMetadataNotFound {
+			return nil, errors.Wrap(err, "failed to remove group metadata")
+		}
+	case nil:
+		if result.MatchedCount == 0 {
+			return nil, store.ErrGroupNotFound
+		}
+	default:
+		return nil, errors.Wrap(err, "failed to get dynamic group")
+	}
+	return result, nil
+}
+
+// ListTenantDatabases describes the databases of the tenants, sorted by
+// name.
+func (i *inventory) ListTenantDatabases(ctx context.Context) ([]model.TenantDatabase, error) {
+	dbs, err := i.db.ListTenantDatabases(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list tenant databases")
+	}
+	return dbs, nil
+}
+
+// GetAttributeStatistics computes the distribution of the values of the
+// attribute across all the tenants. The tenant databases are aggregated by
+// a bounded number of workers and only the totals are returned. Only the
+// most common values of each tenant are merged, so the value counts are
+// approximate if a tenant has more distinct values than the limit; the
+// devices of such tenants are counted separately to keep the total exact.
+func (i *inventory) GetAttributeStatistics(
+	ctx context.Context,
+	scope, name string,
+) (*model.AttributeStatistics, error) {
+	tenants, err := i.db.ListTenantIDs(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list tenants")
+	}
+
+	var (
+		mu     sync.Mutex
+		counts = make(map[string]*model.AttributeValueCount)
+		stats  = &model.AttributeStatistics{
+			Name:  name,
+			Scope: scope,
+		}
+	)
+	jobs := make(chan string)
+	eg, egCtx := errgroup.WithContext(ctx)
+	for w := 0; w < statisticsConcurrency; w++ {
+		eg.Go(func() error {
+			for tenantID := range jobs {
+				tctx := egCtx
+				if tenantID != "" {
+					tctx = identity.WithContext(egCtx, &identity.Identity{
+						Tenant: tenantID,
+					})
+				}
+				values, err := i.db.GetAttributeValueCounts(
+					tctx, scope, name, StatisticsValuesLimit,
+				)
+				if err != nil {
+					return err
+				}
+				if len(values) == 0 {
+					continue
+				}
+				devices := 0
+				for _, v := range values {
+					devices += v.Count
+				}
+				// the less common values were cut, and their devices
+				// are only counted separately
+				truncated := len(values) >= StatisticsValuesLimit
+				if truncated {
+					devices, err = i.db.CountAttributeDevices(tctx, scope, name)
+					if err != nil {
+						return err
+					}
+				}
+				mu.Lock()
+				stats.Tenants++
+				stats.Devices += devices
+				stats.Approximate = stats.Approximate || truncated
+				for _, v := range values {
+					// values of different types must not be merged
+					key := fmt.Sprintf("%T:%v", v.Value, v.Value)
+					if c, ok := counts[key]; ok {
+						c.Count += v.Count
+					} else {
+						v := v
+						counts[key] = &v
+					}
+				}
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+	eg.Go(func() error {
+		defer close(jobs)
+		for _, tenantID := range tenants {
+			select {
+			case jobs <- tenantID:
+			case <-egCtx.Done():
+				return egCtx.Err()
+			}
+		}
+		return nil
+	})
+	if err := eg.Wait(); err != nil {
+		return nil, errors.Wrap(err, "failed to aggregate attribute values")
+	}
+
+	stats.Values = make([]model.AttributeValueCount, 0, len(counts))
+	for _, c := range counts {
+		stats.Values = append(stats.Values, *c)
+	}
+	sort.Slice(stats.Values, func(a, b int) bool {
+		if stats.Values[a].Count != stats.Values[b].Count {
+			return stats.Values[a].Count > stats.Values[b].Count
+		}
+		return fmt.Sprint(stats.Values[a].Value) < fmt.Sprint(stats.Values[b].Value)
+	})
+	if len(stats.Values) > StatisticsValuesLimit {
+		stats.Values = stats.Values[:StatisticsValuesLimit]
+	}
+	return stats, nil
+}
+
+// checkScopeWriters verifies the principal authenticated in the context is
+// allowed to write the attributes of the given scopes. Internal services can
+// write any scope; devices and users can only write the scopes declaring
+// them as the writer. The sub-scopes of the agents follow their scopes.
+func (i *inventory) checkScopeWriters(ctx context.Context, attrs model.DeviceAttributes) error {
+	writer := model.NewAttributeSource(ctx, time.Time{}).Type
+	if writer == model.SourceTypeInternal {
+		return nil
+	}
+	var custom map[string]model.Scope
+	for _, attr := range attrs {
+		name, _ := model.SplitAgentScope(attr.Scope)
+		scope, ok := model.GetBuiltinScope(name)
+		if !ok {
+			if custom == nil {
+				scopes, err := i.db.GetScopes(ctx)
+				if err != nil {
+					return errors.Wrap(err, "failed to get scopes")
+				}
+				custom = make(map[string]model.Scope, len(scopes))
+				for _, s := range scopes {
+					custom[s.Name] = s
+				}
+			}
+			scope, ok = custom[name]
+		}
+		if !ok || !scope.AllowsWriter(writer) {
+			return errors.Wrapf(ErrScopeWriteForbidden, "scope %s", attr.Scope)
+		}
+	}
+	return nil
+}
+
+// checkAttributeSchema normalizes the values of the attributes in place
+// and verifies they conform to their definitions in the schema. Violations
+// of the definitions in the monitor mode do not fail the write, but are
+// recorded in the validation report.
+func (i *inventory) checkAttributeSchema(
+	ctx context.Context,
+	id model.DeviceID,
+	attrs model.DeviceAttributes,
+) error {
+	if len(attrs) == 0 {
+		return nil
+	}
+	defs, err := i.db.GetAttributeDefinitions(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get attribute definitions")
+	}
+	defined := make(map[[2]string]model.AttributeDefinition, len(defs))
+	for _, def := range defs {
+		if def.Type != "" || len(def.Normalizers) > 0 {
+			defined[[2]string{def.Scope, def.Name}] = def
+		}
+	}
+	if len(defined) == 0 {
+		return nil
+	}
+
+	var violations []model.SchemaViolation
+	now := time.Now()
+	for n, attr := range attrs {
+		scope := attr.Scope
+		if scope == "" {
+			scope = model.AttrScopeInventory
+		}
+		// the agents' reports conform to the definitions of their scopes
+		base, _ := model.SplitAgentScope(scope)
+		def, ok := defined[[2]string{base, attr.Name}]
+		if !ok {
+			continue
+		}
+		attr.Value = def.Normalize(attr.Value)
+		attrs[n].Value = attr.Value
+		err := def.Check(attr.Value)
+		if err == nil {
+			continue
+		} else if !def.Monitored() {
+			return errors.Wrapf(ErrSchemaViolation,
+				"attribute %s/%s %s", scope, attr.Name, err.Error())
+		}
+		violations = append(violations, model.SchemaViolation{
+			DeviceID: id,
+			Scope:    scope,
+			Name:     attr.Name,
+			Value:    attr.Value,
+			Error:    err.Error(),
+			FirstTs:  now,
+			LastTs:   now,
+		})
+	}
+	if len(violations) > 0 {
+		// the report is best effort and must not fail the write
+		err := i.db.RecordSchemaViolations(ctx, violations)
+		if err != nil {
+			log.FromContext(ctx).Errorf(
+				"failed to record schema violations: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+func (i *inventory) ListScopes(ctx context.Context) ([]model.Scope, error) {
+	custom, err := i.db.GetScopes(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get scopes")
+	}
+	scopes := make([]model.Scope, 0, len(model.BuiltinScopes)+len(custom))
+	scopes = append(scopes, model.BuiltinScopes...)
+	for _, scope := range custom {
+		// skip the scopes registered before their name became builtin
+		if _, ok := model.GetBuiltinScope(scope.Name); !ok {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
+
+func (i *inventory) ReplaceScope(ctx context.Context, scope model.Scope) (*model.Scope, error) {
+	if err := scope.Validate(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	scope.UpdatedTs = &now
+	if err := i.db.UpsertScope(ctx, scope); err != nil {
+		return nil, errors.Wrap(err, "failed to register scope")
+	}
+	return &scope, nil
+}
+
+func (i *inventory) DeleteScope(ctx context.Context, name string) error {
+	err := i.db.DeleteScope(ctx, name)
+	if err != nil && err != store.ErrScopeNotFound {
+		return errors.Wrap(err, "failed to delete scope")
+	}
+	return err
+}
+
+// ResolveExternalID returns the ID of the device mapped to the external ID;
+// returns store.ErrExternalIDNotFound if the external ID is not mapped.
+func (i *inventory) ResolveExternalID(
+	ctx context.Context,
+	ref model.ExternalIDRef,
+) (model.DeviceID, error) {
+	extID, err := i.db.GetExternalID(ctx, ref)
+	if err != nil {
+		return model.NilDeviceID, errors.Wrap(err, "failed to resolve external ID")
+	} else if extID == nil {
+		return model.NilDeviceID, store.ErrExternalIDNotFound
+	}
+	return extID.DeviceID, nil
+}
+
+func (i *inventory) UpsertExternalIDs(
+	ctx context.Context,
+	ids []model.ExternalID,
+) (*model.UpdateResult, error) {
+	res, err := i.db.UpsertExternalIDs(ctx, ids)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to update external IDs")
+	}
+	return res, nil
+}
+
+func (i *inventory) DeleteExternalID(
+	ctx context.Context,
+	ref model.ExternalIDRef,
+) error {
+	err := i.db.DeleteExternalID(ctx, ref)
+	if err != nil && err != store.ErrExternalIDNotFound {
+		return errors.Wrap(err, "failed to delete external ID")
+	}
+	return err
+}
+
+func (i *inventory) ListAttributeDefinitions(
+	ctx context.Context,
+) ([]model.AttributeDefinition, error) {
+	defs, err := i.db.GetAttributeDefinitions(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get attribute definitions")
+	}
+	return defs, nil
+}
+
+func (i *inventory) ReplaceAttributeDefinition(
+	ctx context.Context,
+	def model.AttributeDefinition,
+) (*model.AttributeDefinition, error) {
+	if err := def.Validate(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	def.UpdatedTs = &now
+	if err := i.db.UpsertAttributeDefinition(ctx, def); err != nil {
+		return nil, errors.Wrap(err, "failed to store attribute definition")
+	}
+	return &def, nil
+}
+
+func (i *inventory) DeleteAttributeDefinition(ctx context.Context, scope, name string) error {
+	err := i.db.DeleteAttributeDefinition(ctx, scope, name)
+	if err != nil && err != store.ErrAttributeDefinitionNotFound {
+		return errors.Wrap(err, "failed to delete attribute definition")
+	}
+	return err
+}
+
+// SweepExpiredAttributes removes the attributes which outlived their TTL
+// from the devices of all the tenants. A failure to sweep one tenant does
+// not stop the sweep of the others.
+func (i *inventory) SweepExpiredAttributes(ctx context.Context) error {
+	l := log.FromContext(ctx)
+	failed := 0
+	err := i.forEachTenant(ctx, func(tctx context.Context) error {
+		if err := i.sweepExpiredAttributes(tctx, time.Now()); err != nil {
+			info := reqctx.FromContext(tctx)
+			l.F(info.LogContext()).Errorf(
+				"failed to remove expired attributes: %s", err.Error())
+			retentionSweepFailures.Inc()
+			failed++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	} else if failed > 0 {
+		return errors.Errorf(
+			"failed to remove expired attributes of %d tenant(s)", failed,
+		)
+	}
+	return nil
+}
+
+// forEachTenant calls f with the context of each of the tenants.
+func (i *inventory) forEachTenant(
+	ctx context.Context,
+	f func(ctx context.Context) error,
+) error {
+	tenants, err := i.db.ListTenantIDs(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to list tenants")
+	}
+	for _, tenantID := range tenants {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		tctx := ctx
+		if tenantID != "" {
+			tctx = identity.WithContext(ctx, &identity.Identity{
+				Tenant: tenantID,
+			})
+		}
+		if err := f(tctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BackfillGroups converts the group membership of the devices of all
+// the tenants to the groups array format. A failure to backfill one tenant
+// does not stop the backfill of the others.
+func (i *inventory) BackfillGroups(ctx context.Context) (*model.UpdateResult, error) {
+	l := log.FromContext(ctx)
+	total := &model.UpdateResult{}
+	failed := 0
+	err := i.forEachTenant(ctx, func(tctx context.Context) error {
+		res, err := i.db.BackfillGroups(tctx)
+		if err == store.ErrRolloutDisabled {
+			return err
+		} else if err != nil {
+			info := reqctx.FromContext(tctx)
+			l.F(info.LogContext()).Errorf(
+				"failed to backfill groups: %s", err.Error())
+			failed++
+			return nil
+		}
+		total.MatchedCount += res.MatchedCount
+		total.UpdatedCount += res.UpdatedCount
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	} else if failed > 0 {
+		return nil, errors.Errorf(
+			"failed to backfill groups of %d tenant(s)", failed,
+		)
+	}
+	return total, nil
+}
+
+// CutoverGroups backfills the groups array of all the tenants, verifies
+// no device diverges from its group attribute and switches reading
+// the group membership to the groups array.
+func (i *inventory) CutoverGroups(ctx context.Context) error {
+	if _, err := i.BackfillGroups(ctx); err != nil {
+		return err
+	}
+	err := i.forEachTenant(ctx, func(tctx context.Context) error {
+		n, err := i.db.CountDivergingGroups(tctx)
+		if err != nil {
+			return err
+		} else if n > 0 {
+			return errors.Errorf(
+				"groups of %d device(s) of tenant %q diverge after the backfill",
+				n, reqctx.FromContext(tctx).TenantID,
+			)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to verify the backfill")
+	}
+	return i.db.CutoverGroups(ctx)
+}
+
+type attributeTTL struct {
+	scope string
+	name  string
+	days  int
+}
+
+// sweepExpiredAttributes removes the expired attributes of the tenant in
+// the context. The TTL of an attribute comes from its definition in the
+// schema or, if not defined, from the retention of its scope.
+func (i *inventory) sweepExpiredAttributes(ctx context.Context, now time.Time) error {
+	defs, err := i.db.GetAttributeDefinitions(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get attribute definitions")
+	}
+	ttls := make([]attributeTTL, 0, len(defs))
+	defined := make(map[[2]string]bool, len(defs))
+	for _, def := range defs {
+		if def.TTLDays > 0 {
+			ttls = append(ttls, attributeTTL{def.Scope, def.Name, def.TTLDays})
+			defined[[2]string{def.Scope, def.Name}] = true
+		}
+	}
+
+	scopes, err := i.db.GetScopes(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get scopes")
+	}
+	var attrs []model.FilterAttribute
+	for _, scope := range scopes {
+		if scope.RetentionDays <= 0 {
+			continue
+		}
+		if attrs == nil {
+			attrs, err = i.db.GetFiltersAttributes(ctx)
+			if err != nil {
+				return errors.Wrap(err, "failed to get attributes")
+			}
+		}
+		for _, attr := range attrs {
+			if attr.Scope == scope.Name &&
+				!defined[[2]string{attr.Scope, attr.Name}] {
+				ttls = append(ttls, attributeTTL{
+					attr.Scope, attr.Name, scope.RetentionDays,
+				})
+			}
+		}
+	}
+
+	for _, ttl := range ttls {
+		since := now.Add(-time.Duration(ttl.days) * 24 * time.Hour)
+		n, err := i.db.UnsetExpiredAttributes(ctx, ttl.scope, ttl.name, since)
+		if err != nil {
+			return errors.Wrapf(err, "attribute %s/%s", ttl.scope, ttl.name)
+		}
+		expiredAttributesRemoved.Add(float64(n), ttl.scope, ttl.name)
+	}
+	return nil
+}
+
+func (i *inventory) ListSchemaViolations(
+	ctx context.Context,
+	id model.DeviceID,
+	skip, limit int,
+) ([]model.SchemaViolation, int, error) {
+	violations, total, err := i.db.GetSchemaViolations(ctx, id, skip, limit)
+	if err != nil {
+		return nil, -1, errors.Wrap(err, "failed to get schema violations")
+	}
+	return violations, total, nil
 }
@@ -19,10 +19,15 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/mendersoftware/go-lib-micro/identity"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
+	"github.com/mendersoftware/inventory/events"
+	mevents "github.com/mendersoftware/inventory/events/mocks"
+	"github.com/mendersoftware/inventory/metrics"
 	"github.com/mendersoftware/inventory/model"
 	"github.com/mendersoftware/inventory/store"
 	mstore "github.com/mendersoftware/inventory/store/mocks"
@@ -133,6 +138,8 @@ func TestInventoryListDevices(t *testing.T) {
 			ctx,
 			mock.AnythingOfType("store.ListQuery"),
 		).Return(tc.outDevices, tc.outDeviceCount, tc.datastoreError)
+		db.On("GetAttributeDescriptions", ctx).
+			Return(model.AttributeDescriptions{}, nil)
 		i := invForTest(db)
 
 		devs, totalCount, err := i.ListDevices(ctx,
@@ -156,6 +163,37 @@ func TestInventoryListDevices(t *testing.T) {
 	}
 }
 
+func TestInventoryStreamDevices(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	q := store.ListQuery{Skip: 10, Limit: 10}
+
+	cur := &mstore.DeviceCursor{}
+	cur.On("Next", mock.Anything).Return(false)
+	cur.On("Err").Return(nil)
+	cur.On("Close", mock.Anything).Return(nil)
+	stream := store.NewDeviceStream(ctx, cur)
+	defer stream.Close()
+
+	db := &mstore.DataStore{}
+	db.On("StreamDevices", ctx, q).Return(stream, 12, nil).Once()
+	db.On("StreamDevices", ctx, q).
+		Return(nil, -1, errors.New("db connection failed")).
+		Once()
+	i := invForTest(db)
+
+	res, totalCount, err := i.StreamDevices(ctx, q)
+	assert.NoError(t, err)
+	assert.Equal(t, stream, res)
+	assert.Equal(t, 12, totalCount)
+
+	res, totalCount, err = i.StreamDevices(ctx, q)
+	assert.EqualError(t, err, "failed to fetch devices: db connection failed")
+	assert.Nil(t, res)
+	assert.Equal(t, -1, totalCount)
+}
+
 func TestInventoryGetDevice(t *testing.T) {
 	t.Parallel()
 
@@ -191,6 +229,8 @@ func TestInventoryGetDevice(t *testing.T) {
 			ctx,
 			mock.AnythingOfType("model.DeviceID"),
 		).Return(tc.outDevice, tc.datastoreError)
+		db.On("GetAttributeDescriptions", ctx).
+			Return(model.AttributeDescriptions{}, nil)
 		i := invForTest(db)
 
 		dev, err := i.GetDevice(ctx, tc.devid)
@@ -210,6 +250,35 @@ func TestInventoryGetDevice(t *testing.T) {
 	}
 }
 
+func TestInventoryGetDevicesByIDs(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	req := model.DevicesGetRequest{
+		IDs: []model.DeviceID{"3", "1", "2", "3"},
+		Attributes: []model.SelectAttribute{
+			{Scope: model.AttrScopeInventory, Attribute: "mac"},
+		},
+	}
+
+	db := &mstore.DataStore{}
+	db.On("GetDevicesByIDs", ctx, req.IDs, req.Attributes).
+		Return([]model.Device{{ID: "1"}, {ID: "3"}}, nil).Once()
+	db.On("GetDevicesByIDs", ctx, req.IDs, req.Attributes).
+		Return(nil, errors.New("db error")).Once()
+	db.On("GetAttributeDescriptions", ctx).
+		Return(model.AttributeDescriptions{}, nil).Once()
+	i := invForTest(db)
+
+	devs, err := i.GetDevicesByIDs(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.Device{{ID: "3"}, {ID: "1"}}, devs)
+
+	_, err = i.GetDevicesByIDs(ctx, req)
+	assert.EqualError(t, err, "failed to fetch devices: db error")
+	db.AssertExpectations(t)
+}
+
 func TestInventoryAddDevice(t *testing.T) {
 	t.Parallel()
 
@@ -352,42 +421,13 @@ func TestReplaceAttributes(t *testing.T) {
 
 	testCases := map[string]struct {
 		deviceID       model.DeviceID
-		getDevice      *model.Device
-		getDeviceErr   error
 		datastoreError error
 
 		upsertAttrs model.DeviceAttributes
-		removeAttrs model.DeviceAttributes
 		outError    error
 	}{
-		"ok, device not found": {
-			deviceID:     "1",
-			getDevice:    nil,
-			getDeviceErr: store.ErrDevNotFound,
-
-			upsertAttrs: model.DeviceAttributes{
-				model.DeviceAttribute{
-					Name:  "name",
-					Value: "foo",
-					Scope: model.AttrScopeInventory,
-				},
-				model.DeviceAttribute{
-					Name:  "ip_address",
-					Value: "127.0.0.1",
-					Scope: model.AttrScopeInventory,
-				},
-			},
-			removeAttrs: model.DeviceAttributes{},
-
-			datastoreError: nil,
-			outError:       nil,
-		},
-		"ok, device found": {
+		"ok": {
 			deviceID: "1",
-			getDevice: &model.Device{
-				Attributes: model.DeviceAttributes{},
-			},
-			getDeviceErr: nil,
 
 			upsertAttrs: model.DeviceAttributes{
 				model.DeviceAttribute{
@@ -401,64 +441,13 @@ func TestReplaceAttributes(t *testing.T) {
 					Scope: model.AttrScopeInventory,
 				},
 			},
-			removeAttrs: model.DeviceAttributes{},
-
-			datastoreError: nil,
-			outError:       nil,
 		},
-		"ok, device found, replace attributes": {
-			deviceID: "1",
-			getDevice: &model.Device{
-				Attributes: model.DeviceAttributes{
-					model.DeviceAttribute{
-						Name:  "name",
-						Value: "foo",
-						Scope: model.AttrScopeInventory,
-					},
-					model.DeviceAttribute{
-						Name:  "custom",
-						Value: "bar",
-						Scope: model.AttrScopeInventory,
-					},
-				},
-			},
-			getDeviceErr: nil,
-
-			upsertAttrs: model.DeviceAttributes{
-				model.DeviceAttribute{
-					Name:  "name",
-					Value: "foo",
-					Scope: model.AttrScopeInventory,
-				},
-				model.DeviceAttribute{
-					Name:  "ip_address",
-					Value: "127.0.0.1",
-					Scope: model.AttrScopeInventory,
-				},
-			},
-			removeAttrs: model.DeviceAttributes{
-				model.DeviceAttribute{
-					Name:  "custom",
-					Value: "bar",
-					Scope: model.AttrScopeInventory,
-				},
-			},
-
-			datastoreError: nil,
-			outError:       nil,
-		},
-		"ko, get device error": {
-			deviceID:     "1",
-			getDevice:    nil,
-			getDeviceErr: errors.New("get device error"),
-
-			datastoreError: nil,
-			outError:       errors.New("failed to get the device: get device error"),
+		"ok, no attributes": {
+			deviceID:    "1",
+			upsertAttrs: model.DeviceAttributes{},
 		},
 		"ko, datastore error": {
-			deviceID:     "1",
-			getDevice:    nil,
-			getDeviceErr: store.ErrDevNotFound,
+			deviceID: "1",
 
 			upsertAttrs: model.DeviceAttributes{
 				model.DeviceAttribute{
@@ -466,16 +455,10 @@ func TestReplaceAttributes(t *testing.T) {
 					Value: "foo",
 					Scope: model.AttrScopeInventory,
 				},
-				model.DeviceAttribute{
-					Name:  "ip_address",
-					Value: "127.0.0.1",
-					Scope: model.AttrScopeInventory,
-				},
 			},
-			removeAttrs: model.DeviceAttributes{},
 
-			datastoreError: errors.New("get device error"),
-			outError:       errors.New("failed to replace attributes in db: get device error"),
+			datastoreError: errors.New("connection error"),
+			outError:       errors.New("failed to replace attributes in db: connection error"),
 		},
 	}
 
@@ -486,19 +469,16 @@ func TestReplaceAttributes(t *testing.T) {
 			db := &mstore.DataStore{}
 			defer db.AssertExpectations(t)
 
-			db.On("GetDevice",
+			db.On("GetAttributeDefinitions", ctx).
+				Return(nil, nil).Maybe()
+			// the attributes of the scope are removed by the datastore,
+			// without reading the device beforehand
+			db.On("ReplaceDeviceAttributes",
 				ctx,
 				tc.deviceID,
-			).Return(tc.getDevice, tc.getDeviceErr)
-
-			if tc.getDeviceErr == nil || tc.getDeviceErr == store.ErrDevNotFound {
-				db.On("UpsertRemoveDeviceAttributes",
-					ctx,
-					tc.deviceID,
-					tc.upsertAttrs,
-					tc.removeAttrs,
-				).Return(nil, tc.datastoreError)
-			}
+				model.AttrScopeInventory,
+				tc.upsertAttrs,
+			).Return(&model.UpdateResult{MatchedCount: 1}, tc.datastoreError)
 
 			i := invForTest(db)
 			err := i.ReplaceAttributes(ctx, tc.deviceID, tc.upsertAttrs, model.AttrScopeInventory)
@@ -518,10 +498,36 @@ func TestGetFiltersAttributes(t *testing.T) {
 	t.Parallel()
 
 	testCases := map[string]struct {
-		attributes []model.FilterAttribute
-		err        error
-		outErr     error
+		catalog      *model.Catalog
+		catalogErr   error
+		attributes   []model.FilterAttribute
+		aliases      model.AttributeAliases
+		descriptions model.AttributeDescriptions
+		expected     []model.FilterAttribute
+		err          error
+		outErr       error
 	}{
+		"ok, precomputed": {
+			catalog: &model.Catalog{
+				Attributes: []model.FilterAttribute{
+					{Name: "name", Scope: "scope", Count: 100},
+				},
+				ComputedTs: time.Now(),
+			},
+		},
+		"ok, precomputed expired": {
+			catalog: &model.Catalog{
+				Attributes: []model.FilterAttribute{},
+				ComputedTs: time.Now().Add(-CatalogTTL - time.Minute),
+			},
+			attributes: []model.FilterAttribute{
+				{Name: "name", Scope: "scope", Count: 100},
+			},
+		},
+		"ok, precomputed error": {
+			catalogErr: errors.New("db error"),
+			attributes: []model.FilterAttribute{},
+		},
 		"ok": {
 			attributes: []model.FilterAttribute{
 				{
@@ -536,6 +542,33 @@ func TestGetFiltersAttributes(t *testing.T) {
 				},
 			},
 		},
+		"ok, described": {
+			attributes: []model.FilterAttribute{
+				{Name: "name", Scope: "scope", Count: 100},
+				{Name: "other_name", Scope: "scope", Count: 90},
+			},
+			descriptions: model.AttributeDescriptions{
+				{Name: "name", Scope: "scope", Description: "The name"},
+			},
+			expected: []model.FilterAttribute{
+				{Name: "name", Scope: "scope", Count: 100, Description: "The name"},
+				{Name: "other_name", Scope: "scope", Count: 90},
+			},
+		},
+		"ok, aliased": {
+			attributes: []model.FilterAttribute{
+				{Name: "name", Scope: "scope", Count: 100},
+				{Name: "other_name", Scope: "scope", Count: 90},
+				{Name: "old_name", Scope: "scope", Count: 5},
+			},
+			aliases: model.AttributeAliases{Attributes: []model.AttributeAlias{
+				{Scope: "scope", Name: "name", Aliases: []string{"old_name"}},
+			}},
+			expected: []model.FilterAttribute{
+				{Name: "name", Scope: "scope", Count: 105},
+				{Name: "other_name", Scope: "scope", Count: 90},
+			},
+		},
 		"ko": {
 			err:    errors.New("error"),
 			outErr: errors.New("failed to get filter attributes from the db: error"),
@@ -546,13 +579,23 @@ func TestGetFiltersAttributes(t *testing.T) {
 			ctx := context.Background()
 
 			db := &mstore.DataStore{}
+			db.On("GetCatalog", ctx).Return(tc.catalog, tc.catalogErr)
 			db.On("GetFiltersAttributes",
 				ctx,
 			).Return(tc.attributes, tc.err)
+			db.On("GetAttributeAliases", ctx).Return(tc.aliases, nil)
+			db.On("GetAttributeDescriptions", ctx).
+				Return(tc.descriptions, nil)
 
 			i := invForTest(db)
 			attributes, err := i.GetFiltersAttributes(ctx)
-			assert.Equal(t, tc.attributes, attributes)
+			if tc.expected != nil {
+				assert.Equal(t, tc.expected, attributes)
+			} else if tc.attributes == nil && tc.catalog != nil {
+				assert.Equal(t, tc.catalog.Attributes, attributes)
+			} else {
+				assert.Equal(t, tc.attributes, attributes)
+			}
 			if tc.err != nil {
 				assert.EqualError(t, tc.outErr, err.Error())
 			} else {
@@ -614,6 +657,10 @@ func TestInventoryUnsetDeviceGroup(t *testing.T) {
 			ctx := context.Background()
 
 			db := &mstore.DataStore{}
+			db.On("GetDevicesGroups",
+				ctx,
+				mock.AnythingOfType("[]model.DeviceID")).
+				Return(map[model.DeviceID][]model.GroupName{}, nil)
 			db.On("UnsetDevicesGroup",
 				ctx,
 				mock.AnythingOfType("[]model.DeviceID"),
@@ -669,6 +716,10 @@ func TestInventoryUpdateDeviceGroup(t *testing.T) {
 			ctx := context.Background()
 
 			db := &mstore.DataStore{}
+			db.On("GetDevicesGroups",
+				ctx,
+				mock.AnythingOfType("[]model.DeviceID")).
+				Return(map[model.DeviceID][]model.GroupName{}, nil)
 			db.On("UpdateDevicesGroup",
 				ctx,
 				mock.AnythingOfType("[]model.DeviceID"),
@@ -730,6 +781,9 @@ func TestInventoryListGroups(t *testing.T) {
 
 			db.On("ListGroups", ctx, tc.filters).
 				Return(tc.inputGroups, tc.datastoreError)
+			db.On("GetFeatureFlags", ctx).Return(model.FeatureFlagSet{
+				model.FeatureDynamicGroups: false,
+			}, nil)
 			i := invForTest(db)
 
 			groups, err := i.ListGroups(ctx, tc.filters)
@@ -745,6 +799,49 @@ func TestInventoryListGroups(t *testing.T) {
 	}
 }
 
+func TestInventorySearchGroups(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	q := store.GroupsQuery{Prefix: "site-", Limit: 20}
+	db := &mstore.DataStore{}
+	db.On("SearchGroups", ctx, q).
+		Return([]model.GroupName{"site-bergen", "site-oslo"}, 2, nil).Once()
+	db.On("SearchGroups", ctx, q).
+		Return(nil, -1, errors.New("db error")).Once()
+	i := invForTest(db)
+
+	groups, total, err := i.SearchGroups(ctx, q)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.GroupName{"site-bergen", "site-oslo"}, groups)
+	assert.Equal(t, 2, total)
+
+	_, _, err = i.SearchGroups(ctx, q)
+	assert.EqualError(t, err, "failed to search groups: db error")
+	db.AssertExpectations(t)
+}
+
+func TestInventoryCountDevices(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	q := store.ListQuery{GroupName: "asd", Limit: 20}
+
+	db := &mstore.DataStore{}
+	db.On("CountDevicesByQuery", ctx, q).Return(3, nil).Once()
+	i := invForTest(db)
+	count, err := i.CountDevices(ctx, q)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	db.On("CountDevicesByQuery", ctx, q).
+		Return(-1, errors.New("db connection failed")).Once()
+	count, err = i.CountDevices(ctx, q)
+	assert.EqualError(t, err, "failed to count devices: db connection failed")
+	assert.Equal(t, -1, count)
+	db.AssertExpectations(t)
+}
+
 func TestInventoryListDevicesByGroup(t *testing.T) {
 	t.Parallel()
 
@@ -794,13 +891,16 @@ func TestInventoryListDevicesByGroup(t *testing.T) {
 		db.On("GetDevicesByGroup",
 			ctx,
 			mock.AnythingOfType("model.GroupName"),
-			mock.AnythingOfType("int"),
-			mock.AnythingOfType("int"),
+			store.ListQuery{Skip: 1, Limit: 1},
 		).Return(tc.OutDevices, tc.OutDeviceCount, tc.DatastoreError)
+		db.On("GetFeatureFlags", ctx).Return(model.FeatureFlagSet{
+			model.FeatureDynamicGroups: false,
+		}, nil)
 
 		i := invForTest(db)
 
-		devs, totalCount, err := i.ListDevicesByGroup(ctx, "foo", 1, 1)
+		devs, totalCount, err := i.ListDevicesByGroup(ctx, "foo",
+			store.ListQuery{Skip: 1, Limit: 1})
 
 		if tc.OutError != "" {
 			if assert.Error(t, err) {
@@ -818,38 +918,91 @@ func TestInventoryListDevicesByGroup(t *testing.T) {
 	}
 }
 
-func TestInventoryGetDeviceGroup(t *testing.T) {
+func TestInventoryListDevicesByGroupFull(t *testing.T) {
 	t.Parallel()
 
 	testCases := map[string]struct {
-		DatastoreError error
-		DatastoreGroup model.GroupName
-		OutError       error
-		OutGroup       model.GroupName
+		devices    []model.Device
+		totalCount int
+		dbErr      error
+
+		err string
+	}{
+		"ok": {
+			devices:    []model.Device{{ID: "11"}, {ID: "12"}},
+			totalCount: 12,
+		},
+		"ok, page past the end": {
+			devices:    []model.Device{},
+			totalCount: 12,
+		},
+		"error, group not found": {
+			devices: []model.Device{},
+			err:     store.ErrGroupNotFound.Error(),
+		},
+		"error, datastore": {
+			dbErr:      errors.New("datastore error"),
+			totalCount: -1,
+			err:        "failed to list devices by group: datastore error",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			db.On("GetFeatureFlags", ctx).Return(model.FeatureFlagSet{
+				model.FeatureDynamicGroups: false,
+			}, nil)
+			db.On("GetDevices", ctx, mock.MatchedBy(func(q store.ListQuery) bool {
+				return q.Skip == 10 && q.Limit == 10 &&
+					q.GroupName == "foo" &&
+					q.HasGroup != nil && *q.HasGroup &&
+					!q.IDsOnly
+			})).Return(tc.devices, tc.totalCount, tc.dbErr)
+
+			devs, totalCount, err := invForTest(db).
+				ListDevicesByGroupFull(ctx, "foo", store.ListQuery{Skip: 10, Limit: 10})
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.devices, devs)
+				assert.Equal(t, tc.totalCount, totalCount)
+			}
+		})
+	}
+}
+
+func TestInventoryGetDeviceGroups(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		DatastoreError  error
+		DatastoreGroups []model.GroupName
+		OutError        error
+		OutGroups       []model.GroupName
 	}{
 		"success - device has group": {
-			DatastoreError: nil,
-			DatastoreGroup: model.GroupName("dev"),
-			OutError:       nil,
-			OutGroup:       model.GroupName("dev"),
+			DatastoreError:  nil,
+			DatastoreGroups: []model.GroupName{"dev", "eu"},
+			OutError:        nil,
+			OutGroups:       []model.GroupName{"dev", "eu"},
 		},
 		"success - device has no group": {
 			DatastoreError: nil,
-			DatastoreGroup: model.GroupName(""),
 			OutError:       nil,
-			OutGroup:       model.GroupName(""),
 		},
 		"datastore error - device not found": {
 			DatastoreError: store.ErrDevNotFound,
-			DatastoreGroup: model.GroupName(""),
 			OutError:       store.ErrDevNotFound,
-			OutGroup:       model.GroupName(""),
 		},
 		"datastore error - generic": {
 			DatastoreError: errors.New("datastore error"),
-			DatastoreGroup: model.GroupName(""),
-			OutError:       errors.New("failed to get device's group: datastore error"),
-			OutGroup:       model.GroupName(""),
+			OutError:       errors.New("failed to get device's groups: datastore error"),
 		},
 	}
 
@@ -860,14 +1013,14 @@ func TestInventoryGetDeviceGroup(t *testing.T) {
 
 		db := &mstore.DataStore{}
 
-		db.On("GetDeviceGroup",
+		db.On("GetDeviceGroups",
 			ctx,
 			mock.AnythingOfType("model.DeviceID"),
-		).Return(tc.OutGroup, tc.DatastoreError)
+		).Return(tc.DatastoreGroups, tc.DatastoreError)
 
 		i := invForTest(db)
 
-		group, err := i.GetDeviceGroup(ctx, "foo")
+		groups, err := i.GetDeviceGroups(ctx, "foo")
 
 		if tc.OutError != nil {
 			if assert.Error(t, err) {
@@ -875,7 +1028,7 @@ func TestInventoryGetDeviceGroup(t *testing.T) {
 			}
 		} else {
 			assert.NoError(t, err)
-			assert.Equal(t, tc.OutGroup, group)
+			assert.Equal(t, tc.OutGroups, groups)
 		}
 	}
 }
@@ -1006,6 +1159,13 @@ func TestInventorySearchDevices(t *testing.T) {
 			outDevices:     nil,
 			outDeviceCount: -1,
 		},
+		"partial results": {
+			searchParams:   model.SearchParams{MaxTimeMS: 100},
+			datastoreError: store.ErrPartialResults,
+			outError:       store.ErrPartialResults,
+			outDevices:     []model.Device{{ID: model.DeviceID("1")}},
+			outDeviceCount: -1,
+		},
 	}
 
 	for name, tc := range testCases {
@@ -1013,6 +1173,10 @@ func TestInventorySearchDevices(t *testing.T) {
 			ctx := context.Background()
 
 			db := &mstore.DataStore{}
+			db.On("GetPinnedAttributes", ctx).
+				Return(model.PinnedAttributes{}, nil)
+			db.On("GetAttributeDescriptions", ctx).
+				Return(model.AttributeDescriptions{}, nil)
 			db.On("SearchDevices",
 				ctx,
 				mock.AnythingOfType("model.SearchParams"),
@@ -1021,7 +1185,11 @@ func TestInventorySearchDevices(t *testing.T) {
 
 			devs, totalCount, err := i.SearchDevices(ctx, tc.searchParams)
 
-			if tc.outError != nil {
+			if tc.outError == store.ErrPartialResults {
+				assert.Equal(t, store.ErrPartialResults, err)
+				assert.Equal(t, tc.outDevices, devs)
+				assert.Equal(t, tc.outDeviceCount, totalCount)
+			} else if tc.outError != nil {
 				if assert.Error(t, err) {
 					assert.EqualError(t, err, tc.outError.Error())
 				}
@@ -1034,6 +1202,64 @@ func TestInventorySearchDevices(t *testing.T) {
 	}
 }
 
+func TestInventoryExplainSearchDevices(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	params := model.SearchParams{Page: 1, PerPage: 20}
+	plan := &model.QueryPlan{Stage: "COLLSCAN", CollectionScan: true}
+
+	db := &mstore.DataStore{}
+	db.On("ExplainSearchDevices", ctx, params).Return(plan, nil).Once()
+	db.On("ExplainSearchDevices", ctx, params).
+		Return(nil, errors.New("db error")).Once()
+	i := invForTest(db)
+
+	res, err := i.ExplainSearchDevices(ctx, params)
+	assert.NoError(t, err)
+	assert.Equal(t, plan, res)
+
+	_, err = i.ExplainSearchDevices(ctx, params)
+	assert.EqualError(t, err, "failed to explain the device search: db error")
+	db.AssertExpectations(t)
+}
+
+func TestInventoryExplainEmptySearch(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	params := model.SearchParams{
+		Page:    1,
+		PerPage: 20,
+		Filters: []model.FilterPredicate{{
+			Scope:     model.AttrScopeInventory,
+			Attribute: "devce_type",
+			Type:      "$eq",
+			Value:     "rpi4",
+		}},
+	}
+	reported := 0
+	terms := []model.FilterTermCount{{
+		Filter:   params.Filters[0],
+		Reported: &reported,
+	}}
+
+	db := &mstore.DataStore{}
+	db.On("GetAttributeAliases", ctx).Return(model.AttributeAliases{}, nil)
+	db.On("CountFilterTerms", ctx, params).Return(terms, nil).Once()
+	db.On("CountFilterTerms", ctx, params).
+		Return(nil, errors.New("db error")).Once()
+	i := invForTest(db)
+
+	res, err := i.ExplainEmptySearch(ctx, params)
+	assert.NoError(t, err)
+	assert.Equal(t, terms, res)
+
+	_, err = i.ExplainEmptySearch(ctx, params)
+	assert.EqualError(t, err, "failed to explain the device search: db error")
+	db.AssertExpectations(t)
+}
+
 func TestInventoryUpdateDevicesGroup(t *testing.T) {
 	t.Parallel()
 	testCases := []struct {
@@ -1066,6 +1292,8 @@ func TestInventoryUpdateDevicesGroup(t *testing.T) {
 		t.Run(testCase.Name, func(t *testing.T) {
 			ctx := context.Background()
 			db := &mstore.DataStore{}
+			db.On("GetDevicesGroups", ctx, testCase.DeviceIDs).
+				Return(map[model.DeviceID][]model.GroupName{}, nil)
 			db.On("UpdateDevicesGroup",
 				ctx,
 				testCase.DeviceIDs,
@@ -1116,6 +1344,8 @@ func TestInventoryUnsetDevicesGroup(t *testing.T) {
 		t.Run(testCase.Name, func(t *testing.T) {
 			ctx := context.Background()
 			db := &mstore.DataStore{}
+			db.On("GetDevicesGroups", ctx, testCase.DeviceIDs).
+				Return(map[model.DeviceID][]model.GroupName{}, nil)
 			db.On("UnsetDevicesGroup",
 				ctx,
 				testCase.DeviceIDs,
@@ -1133,3 +1363,1387 @@ func TestInventoryUnsetDevicesGroup(t *testing.T) {
 		})
 	}
 }
+
+func TestInventoryExportConfigBundle(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db := &mstore.DataStore{}
+	db.On("ListGroups", ctx, []model.FilterPredicate(nil)).
+		Return([]model.GroupName{"foo", "bar", "baz"}, nil)
+	db.On("GetDevicesByGroup", ctx, model.GroupName("foo"),
+		store.ListQuery{
+			Limit:  bundleExportPageSize,
+			Cursor: &model.DeviceCursor{},
+		}).
+		Return([]model.DeviceID{"1", "2"}, 2, nil)
+	db.On("GetDevicesByGroup", ctx, model.GroupName("bar"),
+		store.ListQuery{
+			Limit:  bundleExportPageSize,
+			Cursor: &model.DeviceCursor{},
+		}).
+		Return([]model.DeviceID{"1"}, 1, nil)
+	db.On("GetDevicesByGroup", ctx, model.GroupName("baz"),
+		store.ListQuery{
+			Limit:  bundleExportPageSize,
+			Cursor: &model.DeviceCursor{},
+		}).
+		Return(nil, -1, store.ErrGroupNotFound)
+	db.On("GetGroupsMetadata", ctx).
+		Return([]model.GroupMetadata{{
+			Name:        "foo",
+			Type:        model.GroupTypeStatic,
+			Description: "Foo",
+			Parent:      "linux",
+			CreatedBy:   "user",
+		}}, nil)
+	db.On("GetDynamicGroups", ctx).
+		Return([]model.DynamicGroup{{
+			Name:       "linux",
+			Expression: `inventory/os == "linux"`,
+		}}, nil)
+	shared := true
+	db.On("GetSavedFilters", ctx, store.SavedFiltersQuery{Shared: &shared}).
+		Return([]model.SavedFilter{{
+			ID:      "1",
+			Name:    "debian",
+			OwnerID: "user",
+			Shared:  true,
+			Filters: []model.FilterPredicate{{
+				Scope:     "inventory",
+				Attribute: "os",
+				Type:      "$eq",
+				Value:     "debian",
+			}},
+		}}, nil)
+
+	// the devices in several groups are listed in each of them
+	bundle, err := invForTest(db).ExportConfigBundle(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, &model.ConfigBundle{
+		Version: model.ConfigBundleVersion,
+		Groups: []model.GroupDefinition{{
+			Name:    "foo",
+			Devices: []model.DeviceID{"1", "2"},
+		}, {
+			Name:    "bar",
+			Devices: []model.DeviceID{"1"},
+		}},
+		GroupsMetadata: []model.BundleGroupMetadata{{
+			Name:        "foo",
+			Description: "Foo",
+			Parent:      "linux",
+		}},
+		DynamicGroups: []model.BundleDynamicGroup{{
+			Name:       "linux",
+			Expression: `inventory/os == "linux"`,
+		}},
+		SavedFilters: []model.BundleSavedFilter{{
+			ID:   "1",
+			Name: "debian",
+			Filters: []model.FilterPredicate{{
+				Scope:     "inventory",
+				Attribute: "os",
+				Type:      "$eq",
+				Value:     "debian",
+			}},
+		}},
+	}, bundle)
+
+	db = &mstore.DataStore{}
+	db.On("ListGroups", ctx, []model.FilterPredicate(nil)).
+		Return(nil, errors.New("db error"))
+	_, err = invForTest(db).ExportConfigBundle(ctx)
+	assert.EqualError(t, err, "failed to list groups: db error")
+
+	db = &mstore.DataStore{}
+	db.On("ListGroups", ctx, []model.FilterPredicate(nil)).
+		Return([]model.GroupName{}, nil)
+	db.On("GetGroupsMetadata", ctx).
+		Return([]model.GroupMetadata{}, nil)
+	db.On("GetDynamicGroups", ctx).
+		Return([]model.DynamicGroup{}, nil)
+	db.On("GetSavedFilters", ctx, store.SavedFiltersQuery{Shared: &shared}).
+		Return(nil, errors.New("db error"))
+	_, err = invForTest(db).ExportConfigBundle(ctx)
+	assert.EqualError(t, err, "failed to list saved filters: db error")
+}
+
+func TestInventoryImportConfigBundle(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	bundle := model.ConfigBundle{
+		Version: model.ConfigBundleVersion,
+		Groups: []model.GroupDefinition{
+			{Name: "foo", Devices: []model.DeviceID{"1", "2"}},
+			{Name: "bar", Devices: []model.DeviceID{"1", "3"}},
+		},
+	}
+	// the devices are added to the groups, keeping their other groups
+	db := &mstore.DataStore{}
+	db.On("GetDevicesGroups", ctx, mock.AnythingOfType("[]model.DeviceID")).
+		Return(map[model.DeviceID][]model.GroupName{}, nil)
+	db.On("UpdateDevicesGroup", ctx, []model.DeviceID{"1", "2"}, model.GroupName("foo")).
+		Return(&model.UpdateResult{MatchedCount: 2, UpdatedCount: 1}, nil)
+	db.On("UpdateDevicesGroup", ctx, []model.DeviceID{"1", "3"}, model.GroupName("bar")).
+		Return(&model.UpdateResult{MatchedCount: 2, UpdatedCount: 2}, nil)
+
+	res, err := invForTest(db).ImportConfigBundle(ctx, bundle)
+	assert.NoError(t, err)
+	assert.Equal(t, &model.UpdateResult{MatchedCount: 4, UpdatedCount: 3}, res)
+
+	bundle.Version = 0
+	_, err = invForTest(db).ImportConfigBundle(ctx, bundle)
+	assert.EqualError(t, err, "unsupported bundle version: 0")
+}
+
+func TestInventoryImportConfigBundleSections(t *testing.T) {
+	t.Parallel()
+
+	filter := model.BundleSavedFilter{
+		Name: "debian",
+		Filters: []model.FilterPredicate{{
+			Scope:     "inventory",
+			Attribute: "os",
+			Type:      "$eq",
+			Value:     "debian",
+		}},
+	}
+	existing, created := filter, filter
+	existing.ID, created.ID = "1", "2"
+	bundle := model.ConfigBundle{
+		Version: model.ConfigBundleVersion,
+		GroupsMetadata: []model.BundleGroupMetadata{
+			{Name: "linux", Description: "Linux"},
+		},
+		DynamicGroups: []model.BundleDynamicGroup{
+			{Name: "linux", Expression: `inventory/os == "linux"`},
+		},
+		SavedFilters: []model.BundleSavedFilter{existing, created},
+	}
+	createdTs := time.Now().Add(-time.Hour)
+
+	// the dynamic group and the metadata are created, the filter of
+	// another user is replaced and the new one is owned by the importer
+	ctx := userContext("importer")
+	db := &mstore.DataStore{}
+	db.On("GetDynamicGroup", ctx, model.GroupName("linux")).
+		Return(nil, store.ErrDynamicGroupNotFound).Once()
+	db.On("GetDynamicGroups", ctx).Return([]model.DynamicGroup{}, nil)
+	db.On("GetDevicesByGroup", ctx, model.GroupName("linux"), store.ListQuery{Limit: 1}).
+		Return(nil, -1, store.ErrGroupNotFound)
+	db.On("UpsertDynamicGroup", ctx, mock.MatchedBy(func(g model.DynamicGroup) bool {
+		return g.Name == "linux" && g.Expression == `inventory/os == "linux"`
+	})).Return(nil)
+	db.On("InsertGroupsMetadata", ctx, mock.AnythingOfType("[]model.GroupMetadata")).
+		Return(nil)
+	db.On("GetGroupMetadata", ctx, model.GroupName("linux")).
+		Return(&model.GroupMetadata{Name: "linux", Type: model.GroupTypeDynamic}, nil)
+	db.On("GetDynamicGroup", ctx, model.GroupName("linux")).
+		Return(&model.DynamicGroup{Name: "linux"}, nil)
+	db.On("UpsertGroupMetadata", ctx, mock.MatchedBy(func(g model.GroupMetadata) bool {
+		return g.Name == "linux" && g.Description == "Linux" &&
+			g.Type == model.GroupTypeDynamic
+	})).Return(nil)
+	db.On("GetSavedFilter", ctx, "1").
+		Return(&model.SavedFilter{ID: "1", OwnerID: "user", CreatedTs: createdTs}, nil)
+	db.On("UpsertSavedFilter", ctx, mock.MatchedBy(func(f model.SavedFilter) bool {
+		return f.ID == "1" && f.OwnerID == "user" && f.Shared &&
+			f.CreatedTs.Equal(createdTs) && f.Name == "debian"
+	})).Return(nil)
+	db.On("GetSavedFilter", ctx, "2").
+		Return(nil, store.ErrSavedFilterNotFound)
+	db.On("UpsertSavedFilter", ctx, mock.MatchedBy(func(f model.SavedFilter) bool {
+		return f.ID == "2" && f.OwnerID == "importer" && f.Shared
+	})).Return(nil)
+
+	res, err := invForTest(db).ImportConfigBundle(ctx, bundle)
+	assert.NoError(t, err)
+	assert.Equal(t, &model.UpdateResult{}, res)
+	db.AssertExpectations(t)
+
+	// the new saved filters need an owner
+	ctx = context.Background()
+	bundle.DynamicGroups, bundle.GroupsMetadata = nil, nil
+	db = &mstore.DataStore{}
+	db.On("GetSavedFilter", ctx, "1").
+		Return(nil, store.ErrSavedFilterNotFound)
+	_, err = invForTest(db).ImportConfigBundle(ctx, bundle)
+	assert.EqualError(t, err,
+		"failed to import saved filter 1: saved filters require a user token")
+}
+
+func TestInventoryReplaceGroup(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	group := model.GroupDefinition{
+		Name:    "foo",
+		Devices: []model.DeviceID{"2", "3", "2"},
+	}
+
+	// 1 leaves, 2 stays and 3 is moved from bar to the group
+	db := &mstore.DataStore{}
+	db.On("GetDevicesGroups", ctx, group.Devices).
+		Return(map[model.DeviceID][]model.GroupName{
+			"2": {"foo"},
+			"3": {"bar"},
+		}, nil)
+	db.On("ReplaceDevicesGroup", ctx, group.Devices, model.GroupName("foo")).
+		Return(&model.UpdateResult{MatchedCount: 2, UpdatedCount: 2},
+			[]model.DeviceID{"1"}, nil)
+	db.On("GetDevicesGroups", ctx, []model.DeviceID{"3"}).
+		Return(map[model.DeviceID][]model.GroupName{"3": {"foo"}}, nil)
+	db.On("UpsertDevicesAttributes", ctx, []model.DeviceID{"1"},
+		mock.AnythingOfType("model.DeviceAttributes")).
+		Return(&model.UpdateResult{}, nil)
+	db.On("UpsertDevicesAttributes", ctx, []model.DeviceID{"3"},
+		mock.AnythingOfType("model.DeviceAttributes")).
+		Return(&model.UpdateResult{}, nil)
+	db.On("InsertGroupsMetadata", ctx, mock.AnythingOfType("[]model.GroupMetadata")).
+		Return(nil)
+
+	res, err := invForTest(db).ReplaceGroup(ctx, group)
+	assert.NoError(t, err)
+	assert.Equal(t, &model.GroupDefinition{
+		Name:    "foo",
+		Devices: []model.DeviceID{"2", "3"},
+	}, res)
+	db.AssertExpectations(t)
+
+	// the group is emptied, none of the devices exist
+	db = &mstore.DataStore{}
+	db.On("GetDevicesGroups", ctx, group.Devices).
+		Return(map[model.DeviceID][]model.GroupName{}, nil)
+	db.On("ReplaceDevicesGroup", ctx, group.Devices, model.GroupName("foo")).
+		Return(&model.UpdateResult{}, []model.DeviceID{}, nil)
+
+	res, err = invForTest(db).ReplaceGroup(ctx, group)
+	assert.NoError(t, err)
+	assert.Equal(t, &model.GroupDefinition{
+		Name:    "foo",
+		Devices: []model.DeviceID{},
+	}, res)
+	db.AssertExpectations(t)
+
+	db = &mstore.DataStore{}
+	db.On("GetDevicesGroups", ctx, group.Devices).
+		Return(map[model.DeviceID][]model.GroupName{}, nil)
+	db.On("ReplaceDevicesGroup", ctx, group.Devices, model.GroupName("foo")).
+		Return(nil, nil, errors.New("db error"))
+	_, err = invForTest(db).ReplaceGroup(ctx, group)
+	assert.EqualError(t, err, "failed to replace the devices of group: db error")
+}
+
+func TestInventoryListGroupMembers(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	name := model.GroupName("foo")
+	page := make([]model.DeviceID, bundleExportPageSize)
+	for n := range page {
+		page[n] = model.DeviceID(fmt.Sprintf("%04d", n))
+	}
+
+	// the second page resumes after the last device of the first one
+	db := &mstore.DataStore{}
+	db.On("GetDevicesByGroup", ctx, name, store.ListQuery{
+		Limit:  bundleExportPageSize,
+		Cursor: &model.DeviceCursor{},
+	}).Return(page, bundleExportPageSize+1, nil)
+	db.On("GetDevicesByGroup", ctx, name, store.ListQuery{
+		Limit:  bundleExportPageSize,
+		Cursor: &model.DeviceCursor{ID: page[len(page)-1]},
+	}).Return([]model.DeviceID{"9999"}, bundleExportPageSize+1, nil)
+
+	devices, err := invForTest(db).(*inventory).listGroupMembers(ctx, name)
+	assert.NoError(t, err)
+	assert.Equal(t, append(page, "9999"), devices)
+	db.AssertExpectations(t)
+}
+
+func TestInventoryDeleteGroup(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	name := model.GroupName("foo")
+	result := &model.UpdateResult{MatchedCount: 2, UpdatedCount: 2}
+
+	db := &mstore.DataStore{}
+	db.On("GetDevicesByGroup", ctx, name,
+		store.ListQuery{
+			Limit:  bundleExportPageSize,
+			Cursor: &model.DeviceCursor{},
+		}).
+		Return([]model.DeviceID{"1", "2"}, 2, nil)
+	db.On("DeleteGroup", ctx, name).Return(result, nil)
+	db.On("UpsertDevicesAttributes", ctx, []model.DeviceID{"1", "2"},
+		mock.AnythingOfType("model.DeviceAttributes")).
+		Return(&model.UpdateResult{}, nil)
+	db.On("GetDynamicGroup", ctx, name).
+		Return(nil, store.ErrDynamicGroupNotFound)
+	db.On("DeleteGroupMetadata", ctx, name).Return(nil)
+
+	res, err := invForTest(db).DeleteGroup(ctx, name)
+	assert.NoError(t, err)
+	assert.Equal(t, result, res)
+	db.AssertExpectations(t)
+
+	// no members, only the metadata
+	db = &mstore.DataStore{}
+	db.On("GetDevicesByGroup", ctx, name,
+		store.ListQuery{
+			Limit:  bundleExportPageSize,
+			Cursor: &model.DeviceCursor{},
+		}).
+		Return(nil, -1, store.ErrGroupNotFound)
+	db.On("DeleteGroup", ctx, name).Return(&model.UpdateResult{}, nil)
+	db.On("GetDynamicGroup", ctx, name).
+		Return(nil, store.ErrDynamicGroupNotFound)
+	db.On("DeleteGroupMetadata", ctx, name).Return(nil)
+
+	res, err = invForTest(db).DeleteGroup(ctx, name)
+	assert.NoError(t, err)
+	assert.Equal(t, &model.UpdateResult{}, res)
+	db.AssertExpectations(t)
+
+	// neither members nor metadata
+	db = &mstore.DataStore{}
+	db.On("GetDevicesByGroup", ctx, name,
+		store.ListQuery{
+			Limit:  bundleExportPageSize,
+			Cursor: &model.DeviceCursor{},
+		}).
+		Return(nil, -1, store.ErrGroupNotFound)
+	db.On("DeleteGroup", ctx, name).Return(&model.UpdateResult{}, nil)
+	db.On("GetDynamicGroup", ctx, name).
+		Return(nil, store.ErrDynamicGroupNotFound)
+	db.On("DeleteGroupMetadata", ctx, name).
+		Return(store.ErrGroupMetadataNotFound)
+
+	_, err = invForTest(db).DeleteGroup(ctx, name)
+	assert.Equal(t, store.ErrGroupNotFound, err)
+	db.AssertExpectations(t)
+
+	// the metadata of the dynamic group is kept
+	db = &mstore.DataStore{}
+	db.On("GetDevicesByGroup", ctx, name,
+		store.ListQuery{
+			Limit:  bundleExportPageSize,
+			Cursor: &model.DeviceCursor{},
+		}).
+		Return(nil, -1, store.ErrGroupNotFound)
+	db.On("DeleteGroup", ctx, name).Return(&model.UpdateResult{}, nil)
+	db.On("GetDynamicGroup", ctx, name).
+		Return(&model.DynamicGroup{Name: name}, nil)
+
+	_, err = invForTest(db).DeleteGroup(ctx, name)
+	assert.Equal(t, store.ErrGroupNotFound, err)
+	db.AssertExpectations(t)
+
+	db = &mstore.DataStore{}
+	db.On("GetDevicesByGroup", ctx, name,
+		store.ListQuery{
+			Limit:  bundleExportPageSize,
+			Cursor: &model.DeviceCursor{},
+		}).
+		Return(nil, -1, store.ErrGroupNotFound)
+	db.On("DeleteGroup", ctx, name).Return(nil, errors.New("db error"))
+	_, err = invForTest(db).DeleteGroup(ctx, name)
+	assert.EqualError(t, err, "failed to remove devices from group: db error")
+}
+
+func TestInventoryGroupTransitions(t *testing.T) {
+	t.Parallel()
+
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Subject: "alice",
+		IsUser:  true,
+	})
+	reason := model.GroupChangeReason{Type: model.GroupChangeUser, ID: "alice"}
+	isTransition := func(
+		expected model.GroupTransition,
+	) interface{} {
+		return mock.MatchedBy(func(event events.Event) bool {
+			transition, ok := event.Data.(model.GroupTransition)
+			if !ok || event.Type != groupTransitionEvents[expected.Action] {
+				return false
+			}
+			transition.Timestamp = time.Time{}
+			return transition == expected
+		})
+	}
+	isTransitionAttrs := func(value string) func(model.DeviceAttributes) bool {
+		return func(attrs model.DeviceAttributes) bool {
+			return len(attrs) == 3 &&
+				attrs[0].Value == value &&
+				attrs[1].Value == "user:alice"
+		}
+	}
+
+	// device 1 is moved from bar to foo, 2 joins its first group, 3 is
+	// already a member, 4 does not exist and 5 joins foo keeping baz
+	ids := []model.DeviceID{"1", "2", "3", "4", "5"}
+	db := &mstore.DataStore{}
+	db.On("GetDevicesGroups", ctx, ids).
+		Return(map[model.DeviceID][]model.GroupName{
+			"1": {"bar"}, "2": nil, "3": {"bar", "foo"}, "5": {"baz"},
+		}, nil)
+	db.On("UpdateDevicesGroup", ctx, ids, model.GroupName("foo")).
+		Return(&model.UpdateResult{MatchedCount: 4, UpdatedCount: 3}, nil)
+	db.On("GetDevicesGroups", ctx, []model.DeviceID{"1", "5"}).
+		Return(map[model.DeviceID][]model.GroupName{
+			"1": {"foo"}, "5": {"baz", "foo"},
+		}, nil)
+	db.On("InsertGroupsMetadata", ctx,
+		mock.MatchedBy(func(groups []model.GroupMetadata) bool {
+			return len(groups) == 1 && groups[0].Name == "foo" &&
+				groups[0].Type == model.GroupTypeStatic
+		})).
+		Return(nil)
+	db.On("UpsertDevicesAttributes", ctx, []model.DeviceID{"1", "2", "5"},
+		mock.MatchedBy(isTransitionAttrs("joined:foo"))).
+		Return(&model.UpdateResult{MatchedCount: 3, UpdatedCount: 3}, nil)
+	db.On("GetFeatureFlags", ctx).Return(model.FeatureFlagSet{}, nil)
+	emitter := &mevents.Emitter{}
+	emitter.On("Emit", ctx,
+		isTransition(model.GroupTransition{
+			DeviceID: "1", Action: "left", Group: "bar", Reason: reason,
+		}),
+		isTransition(model.GroupTransition{
+			DeviceID: "1", Action: "joined", Group: "foo", Reason: reason,
+		}),
+		isTransition(model.GroupTransition{
+			DeviceID: "2", Action: "joined", Group: "foo", Reason: reason,
+		}),
+		isTransition(model.GroupTransition{
+			DeviceID: "5", Action: "joined", Group: "foo", Reason: reason,
+		}),
+	).Return(nil)
+	i := invForTest(db).WithEventEmitter(emitter)
+
+	res, err := i.UpdateDevicesGroup(ctx, ids, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, &model.UpdateResult{MatchedCount: 4, UpdatedCount: 3}, res)
+	db.AssertExpectations(t)
+	emitter.AssertExpectations(t)
+
+	// only the members leave the group, keeping the others; failing to
+	// record the transitions does not fail the request
+	ids = []model.DeviceID{"1", "2"}
+	db = &mstore.DataStore{}
+	db.On("GetDevicesGroups", ctx, ids).
+		Return(map[model.DeviceID][]model.GroupName{
+			"1": {"bar", "foo"}, "2": {"bar"},
+		}, nil)
+	db.On("UnsetDevicesGroup", ctx, ids, model.GroupName("foo")).
+		Return(&model.UpdateResult{MatchedCount: 1, UpdatedCount: 1}, nil)
+	db.On("UpsertDevicesAttributes", ctx, []model.DeviceID{"1"},
+		mock.MatchedBy(isTransitionAttrs("left:foo"))).
+		Return(nil, errors.New("db error"))
+	db.On("GetFeatureFlags", ctx).Return(model.FeatureFlagSet{}, nil)
+	emitter = &mevents.Emitter{}
+	emitter.On("Emit", ctx,
+		isTransition(model.GroupTransition{
+			DeviceID: "1", Action: "left", Group: "foo", Reason: reason,
+		}),
+	).Return(errors.New("webhook error"))
+	i = invForTest(db).WithEventEmitter(emitter)
+
+	res, err = i.UnsetDevicesGroup(ctx, ids, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, &model.UpdateResult{MatchedCount: 1, UpdatedCount: 1}, res)
+	db.AssertExpectations(t)
+	emitter.AssertExpectations(t)
+
+	// the group is not changed if the current groups are unknown
+	db = &mstore.DataStore{}
+	db.On("GetDevicesGroups", ctx, ids).
+		Return(nil, errors.New("db error"))
+	_, err = invForTest(db).UpdateDevicesGroup(ctx, ids, "foo")
+	assert.EqualError(t, err, "db error")
+	db.AssertExpectations(t)
+}
+
+func TestInventoryUpdateDevicesGroupByFilter(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	params := model.SearchParams{
+		Filters: []model.FilterPredicate{{
+			Scope:     model.AttrScopeInventory,
+			Attribute: "device_type",
+			Type:      "$eq",
+			Value:     "raspberrypi4",
+		}},
+	}
+	result := &model.UpdateResult{MatchedCount: 3, UpdatedCount: 2}
+
+	// the devices which joined the group are recorded
+	db := &mstore.DataStore{}
+	db.On("UpdateDevicesGroupByFilter", ctx, params, model.GroupName("foo")).
+		Return(result, []model.DeviceID{"1", "2"}, nil)
+	db.On("InsertGroupsMetadata", ctx,
+		mock.MatchedBy(func(groups []model.GroupMetadata) bool {
+			return len(groups) == 1 && groups[0].Name == "foo" &&
+				groups[0].Type == model.GroupTypeStatic
+		})).
+		Return(nil)
+	db.On("UpsertDevicesAttributes", ctx, []model.DeviceID{"1", "2"},
+		mock.MatchedBy(func(attrs model.DeviceAttributes) bool {
+			return len(attrs) == 3 && attrs[0].Value == "joined:foo"
+		})).
+		Return(&model.UpdateResult{MatchedCount: 2, UpdatedCount: 2}, nil)
+	res, err := invForTest(db).UpdateDevicesGroupByFilter(ctx, params, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, result, res)
+	db.AssertExpectations(t)
+
+	// all the devices are already members
+	db = &mstore.DataStore{}
+	db.On("UpdateDevicesGroupByFilter", ctx, params, model.GroupName("foo")).
+		Return(&model.UpdateResult{MatchedCount: 3}, []model.DeviceID{}, nil)
+	res, err = invForTest(db).UpdateDevicesGroupByFilter(ctx, params, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, &model.UpdateResult{MatchedCount: 3}, res)
+	db.AssertExpectations(t)
+
+	db = &mstore.DataStore{}
+	db.On("UpdateDevicesGroupByFilter", ctx, params, model.GroupName("foo")).
+		Return(nil, nil, errors.New("db error"))
+	_, err = invForTest(db).UpdateDevicesGroupByFilter(ctx, params, "foo")
+	assert.EqualError(t, err, "failed to add devices to group: db error")
+	db.AssertExpectations(t)
+}
+
+func TestInventoryListTenantDatabases(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dbs := []model.TenantDatabase{{
+		TenantID: "foo",
+		Database: "inventory-foo",
+		Devices:  2,
+		Version:  "1.0.9",
+	}}
+
+	db := &mstore.DataStore{}
+	db.On("ListTenantDatabases", ctx).Return(dbs, nil)
+	res, err := invForTest(db).ListTenantDatabases(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, dbs, res)
+
+	db = &mstore.DataStore{}
+	db.On("ListTenantDatabases", ctx).Return(nil, errors.New("db error"))
+	_, err = invForTest(db).ListTenantDatabases(ctx)
+	assert.EqualError(t, err, "failed to list tenant databases: db error")
+}
+
+func TestInventoryGetAttributeStatistics(t *testing.T) {
+	t.Parallel()
+
+	tenantMatcher := func(tenantID string) interface{} {
+		return mock.MatchedBy(func(ctx context.Context) bool {
+			id := identity.FromContext(ctx)
+			return id != nil && id.Tenant == tenantID
+		})
+	}
+
+	ctx := context.Background()
+	db := &mstore.DataStore{}
+	db.On("ListTenantIDs", ctx).
+		Return([]string{"tenant1", "tenant2", "tenant3"}, nil)
+	db.On("GetAttributeValueCounts", tenantMatcher("tenant1"),
+		"inventory", "mender_client_version", StatisticsValuesLimit,
+	).Return([]model.AttributeValueCount{
+		{Value: "2.5.0", Count: 3},
+		{Value: "2.4.0", Count: 1},
+	}, nil)
+	db.On("GetAttributeValueCounts", tenantMatcher("tenant2"),
+		"inventory", "mender_client_version", StatisticsValuesLimit,
+	).Return([]model.AttributeValueCount{
+		{Value: "2.4.0", Count: 4},
+		{Value: "2.5.0", Count: 2},
+	}, nil)
+	db.On("GetAttributeValueCounts", tenantMatcher("tenant3"),
+		"inventory", "mender_client_version", StatisticsValuesLimit,
+	).Return(nil, nil)
+
+	stats, err := invForTest(db).GetAttributeStatistics(
+		ctx, "inventory", "mender_client_version",
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, &model.AttributeStatistics{
+		Name:    "mender_client_version",
+		Scope:   "inventory",
+		Tenants: 2,
+		Devices: 10,
+		Values: []model.AttributeValueCount{
+			{Value: "2.4.0", Count: 5},
+			{Value: "2.5.0", Count: 5},
+		},
+	}, stats)
+
+	// the devices of the tenants with more values than the limit are
+	// counted separately
+	values := make([]model.AttributeValueCount, StatisticsValuesLimit)
+	for n := range values {
+		values[n] = model.AttributeValueCount{Value: fmt.Sprintf("2.%d.0", n), Count: 2}
+	}
+	db = &mstore.DataStore{}
+	db.On("ListTenantIDs", ctx).
+		Return([]string{"tenant1", "tenant2"}, nil)
+	db.On("GetAttributeValueCounts", tenantMatcher("tenant1"),
+		"inventory", "mender_client_version", StatisticsValuesLimit,
+	).Return(values, nil)
+	db.On("CountAttributeDevices", tenantMatcher("tenant1"),
+		"inventory", "mender_client_version",
+	).Return(250, nil)
+	db.On("GetAttributeValueCounts", tenantMatcher("tenant2"),
+		"inventory", "mender_client_version", StatisticsValuesLimit,
+	).Return([]model.AttributeValueCount{{Value: "2.0.0", Count: 3}}, nil)
+
+	stats, err = invForTest(db).GetAttributeStatistics(
+		ctx, "inventory", "mender_client_version",
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats.Tenants)
+	assert.Equal(t, 253, stats.Devices)
+	assert.True(t, stats.Approximate)
+	assert.Len(t, stats.Values, StatisticsValuesLimit)
+	assert.Equal(t, model.AttributeValueCount{Value: "2.0.0", Count: 5}, stats.Values[0])
+	db.AssertExpectations(t)
+
+	db = &mstore.DataStore{}
+	db.On("ListTenantIDs", ctx).Return([]string{""}, nil)
+	db.On("GetAttributeValueCounts", mock.Anything,
+		"inventory", "mender_client_version", StatisticsValuesLimit,
+	).Return(nil, errors.New("db error"))
+	_, err = invForTest(db).GetAttributeStatistics(
+		ctx, "inventory", "mender_client_version",
+	)
+	assert.EqualError(t, err, "failed to aggregate attribute values: db error")
+}
+
+func TestInventoryCheckScopeWriters(t *testing.T) {
+	t.Parallel()
+
+	deviceCtx := identity.WithContext(context.Background(),
+		&identity.Identity{Subject: "1", IsDevice: true})
+	userCtx := identity.WithContext(context.Background(),
+		&identity.Identity{Subject: "user", IsUser: true})
+	scopes := []model.Scope{
+		{Name: "sensors", Writer: model.SourceTypeDevice},
+		{Name: "warranty", Writer: model.SourceTypeUser},
+	}
+
+	testCases := map[string]struct {
+		ctx   context.Context
+		scope string
+
+		callGetScopes bool
+		err           string
+	}{
+		"ok, internal writes any scope": {
+			ctx:           context.Background(),
+			scope:         "unknown",
+			callGetScopes: true,
+		},
+		"ok, device writes builtin scope": {
+			ctx:   deviceCtx,
+			scope: model.AttrScopeInventory,
+		},
+		"ok, device writes custom scope": {
+			ctx:           deviceCtx,
+			scope:         "sensors",
+			callGetScopes: true,
+		},
+		"ok, user writes custom scope": {
+			ctx:           userCtx,
+			scope:         "warranty",
+			callGetScopes: true,
+		},
+		"ok, device writes agent sub-scope": {
+			ctx:           deviceCtx,
+			scope:         "sensors:gateway",
+			callGetScopes: true,
+		},
+		"error, device writes agent sub-scope": {
+			ctx:   deviceCtx,
+			scope: "identity:gateway",
+			err:   "scope identity:gateway: writing attributes of the scope is forbidden",
+		},
+		"error, device writes builtin scope": {
+			ctx:   deviceCtx,
+			scope: model.AttrScopeIdentity,
+			err:   "scope identity: writing attributes of the scope is forbidden",
+		},
+		"error, device writes user scope": {
+			ctx:           deviceCtx,
+			scope:         "warranty",
+			callGetScopes: true,
+			err:           "scope warranty: writing attributes of the scope is forbidden",
+		},
+		"error, unknown scope": {
+			ctx:           userCtx,
+			scope:         "unknown",
+			callGetScopes: true,
+			err:           "scope unknown: writing attributes of the scope is forbidden",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			attrs := model.DeviceAttributes{
+				{Name: "foo", Value: "bar", Scope: tc.scope},
+			}
+			db := &mstore.DataStore{}
+			if tc.callGetScopes {
+				db.On("GetScopes", tc.ctx).Return(scopes, nil)
+			}
+			if tc.err == "" {
+				db.On("GetAttributeDefinitions", tc.ctx).Return(nil, nil)
+				db.On("UpsertDevicesAttributes",
+					tc.ctx, []model.DeviceID{"1"}, attrs,
+				).Return(&model.UpdateResult{}, nil)
+			}
+
+			err := invForTest(db).UpsertAttributes(tc.ctx, "1", attrs)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+			db.AssertExpectations(t)
+		})
+	}
+}
+
+func TestInventoryScopes(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	scope := model.Scope{Name: "warranty", Writer: model.SourceTypeUser}
+
+	// registered before the name was taken by the builtin scope
+	legacy := model.Scope{Name: "telemetry", Writer: model.SourceTypeDevice}
+
+	db := &mstore.DataStore{}
+	db.On("GetScopes", ctx).Return([]model.Scope{scope, legacy}, nil)
+	db.On("UpsertScope", ctx, mock.MatchedBy(func(s model.Scope) bool {
+		return s.Name == scope.Name && s.UpdatedTs != nil
+	})).Return(nil)
+	db.On("DeleteScope", ctx, "warranty").Return(nil)
+	db.On("DeleteScope", ctx, "fleet").Return(store.ErrScopeNotFound)
+	i := invForTest(db)
+
+	scopes, err := i.ListScopes(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, append(model.BuiltinScopes, scope), scopes)
+
+	res, err := i.ReplaceScope(ctx, scope)
+	assert.NoError(t, err)
+	assert.Equal(t, scope.Name, res.Name)
+	assert.NotNil(t, res.UpdatedTs)
+
+	_, err = i.ReplaceScope(ctx, model.Scope{Name: "inventory", Writer: "user"})
+	assert.EqualError(t, err, "name: must not be a builtin scope.")
+	_, err = i.ReplaceScope(ctx, legacy)
+	assert.EqualError(t, err, "name: must not be a builtin scope.")
+
+	assert.NoError(t, i.DeleteScope(ctx, "warranty"))
+	assert.Equal(t, store.ErrScopeNotFound, i.DeleteScope(ctx, "fleet"))
+}
+
+func TestInventoryAttributeDefinitions(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	def := model.AttributeDefinition{
+		Scope:   model.AttrScopeInventory,
+		Name:    "last_gps_fix",
+		TTLDays: 30,
+	}
+
+	db := &mstore.DataStore{}
+	db.On("GetAttributeDefinitions", ctx).
+		Return([]model.AttributeDefinition{def}, nil)
+	db.On("UpsertAttributeDefinition", ctx,
+		mock.MatchedBy(func(d model.AttributeDefinition) bool {
+			return d.Name == def.Name && d.UpdatedTs != nil
+		}),
+	).Return(nil)
+	db.On("DeleteAttributeDefinition", ctx, "inventory", "last_gps_fix").
+		Return(nil)
+	db.On("DeleteAttributeDefinition", ctx, "inventory", "mac").
+		Return(store.ErrAttributeDefinitionNotFound)
+	i := invForTest(db)
+
+	defs, err := i.ListAttributeDefinitions(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.AttributeDefinition{def}, defs)
+
+	res, err := i.ReplaceAttributeDefinition(ctx, def)
+	assert.NoError(t, err)
+	assert.Equal(t, def.TTLDays, res.TTLDays)
+	assert.NotNil(t, res.UpdatedTs)
+
+	_, err = i.ReplaceAttributeDefinition(ctx, model.AttributeDefinition{
+		Scope: model.AttrScopeInventory,
+	})
+	assert.EqualError(t, err, "name: cannot be blank.")
+
+	assert.NoError(t, i.DeleteAttributeDefinition(ctx, "inventory", "last_gps_fix"))
+	assert.Equal(t, store.ErrAttributeDefinitionNotFound,
+		i.DeleteAttributeDefinition(ctx, "inventory", "mac"))
+}
+
+func TestInventorySweepExpiredAttributes(t *testing.T) {
+	t.Parallel()
+
+	tenantMatcher := func(tenantID string) interface{} {
+		return mock.MatchedBy(func(ctx context.Context) bool {
+			id := identity.FromContext(ctx)
+			return id != nil && id.Tenant == tenantID
+		})
+	}
+	daysAgo := func(days int) interface{} {
+		return mock.MatchedBy(func(since time.Time) bool {
+			d := time.Since(since) - time.Duration(days)*24*time.Hour
+			return d >= 0 && d < time.Minute
+		})
+	}
+
+	ctx := context.Background()
+	db := &mstore.DataStore{}
+	db.On("ListTenantIDs", ctx).Return([]string{"tenant1", "tenant2"}, nil)
+
+	// tenant1: the definition overrides the retention of the scope
+	db.On("GetAttributeDefinitions", tenantMatcher("tenant1")).
+		Return([]model.AttributeDefinition{
+			{Scope: "inventory", Name: "last_gps_fix", TTLDays: 30},
+			{Scope: "inventory", Name: "mac"},
+			{Scope: "sensors", Name: "rssi", TTLDays: 1},
+		}, nil)
+	db.On("GetScopes", tenantMatcher("tenant1")).
+		Return([]model.Scope{
+			{Name: "sensors", Writer: "device", RetentionDays: 7},
+			{Name: "warranty", Writer: "user"},
+		}, nil)
+	db.On("GetFiltersAttributes", tenantMatcher("tenant1")).
+		Return([]model.FilterAttribute{
+			{Scope: "inventory", Name: "mac"},
+			{Scope: "sensors", Name: "rssi"},
+			{Scope: "sensors", Name: "temperature"},
+		}, nil)
+	db.On("UnsetExpiredAttributes", tenantMatcher("tenant1"),
+		"inventory", "last_gps_fix", daysAgo(30),
+	).Return(int64(2), nil)
+	db.On("UnsetExpiredAttributes", tenantMatcher("tenant1"),
+		"sensors", "rssi", daysAgo(1),
+	).Return(int64(0), nil)
+	db.On("UnsetExpiredAttributes", tenantMatcher("tenant1"),
+		"sensors", "temperature", daysAgo(7),
+	).Return(int64(5), nil)
+
+	// tenant2: failure does not stop the sweep
+	db.On("GetAttributeDefinitions", tenantMatcher("tenant2")).
+		Return(nil, errors.New("db error"))
+
+	before := metrics.Value("inventory_expired_attributes_removed_total",
+		"sensors", "temperature")
+	err := invForTest(db).SweepExpiredAttributes(ctx)
+	assert.EqualError(t, err, "failed to remove expired attributes of 1 tenant(s)")
+	db.AssertExpectations(t)
+	assert.Equal(t, before+5, metrics.Value(
+		"inventory_expired_attributes_removed_total",
+		"sensors", "temperature",
+	))
+
+	db = &mstore.DataStore{}
+	db.On("ListTenantIDs", ctx).Return(nil, errors.New("db error"))
+	err = invForTest(db).SweepExpiredAttributes(ctx)
+	assert.EqualError(t, err, "failed to list tenants: db error")
+}
+
+func TestInventoryCutoverGroups(t *testing.T) {
+	t.Parallel()
+
+	tenantMatcher := func(tenantID string) interface{} {
+		return mock.MatchedBy(func(ctx context.Context) bool {
+			id := identity.FromContext(ctx)
+			if tenantID == "" {
+				return id == nil
+			}
+			return id != nil && id.Tenant == tenantID
+		})
+	}
+
+	testCases := map[string]struct {
+		setup func(db *mstore.DataStore)
+		err   string
+	}{
+		"ok": {
+			setup: func(db *mstore.DataStore) {
+				db.On("BackfillGroups", tenantMatcher("")).
+					Return(&model.UpdateResult{UpdatedCount: 1}, nil)
+				db.On("BackfillGroups", tenantMatcher("tenant")).
+					Return(&model.UpdateResult{UpdatedCount: 2}, nil)
+				db.On("CountDivergingGroups", mock.Anything).
+					Return(int64(0), nil)
+				db.On("CutoverGroups", mock.Anything).Return(nil)
+			},
+		},
+		"error, rollout disabled": {
+			setup: func(db *mstore.DataStore) {
+				db.On("BackfillGroups", tenantMatcher("")).
+					Return(nil, store.ErrRolloutDisabled)
+			},
+			err: store.ErrRolloutDisabled.Error(),
+		},
+		"error, backfill failed": {
+			setup: func(db *mstore.DataStore) {
+				db.On("BackfillGroups", tenantMatcher("")).
+					Return(nil, errors.New("db error"))
+				db.On("BackfillGroups", tenantMatcher("tenant")).
+					Return(&model.UpdateResult{}, nil)
+			},
+			err: "failed to backfill groups of 1 tenant(s)",
+		},
+		"error, devices diverge": {
+			setup: func(db *mstore.DataStore) {
+				db.On("BackfillGroups", mock.Anything).
+					Return(&model.UpdateResult{}, nil)
+				db.On("CountDivergingGroups", tenantMatcher("")).
+					Return(int64(0), nil)
+				db.On("CountDivergingGroups", tenantMatcher("tenant")).
+					Return(int64(3), nil)
+			},
+			err: "failed to verify the backfill: groups of 3 device(s) " +
+				"of tenant \"tenant\" diverge after the backfill",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			db := &mstore.DataStore{}
+			db.On("ListTenantIDs", ctx).Return([]string{"", "tenant"}, nil)
+			tc.setup(db)
+
+			err := invForTest(db).CutoverGroups(ctx)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+			db.AssertExpectations(t)
+		})
+	}
+}
+
+func TestInventoryValidateFilter(t *testing.T) {
+	t.Parallel()
+
+	defs := []model.AttributeDefinition{
+		{Scope: "inventory", Name: "cpu_count", Type: model.AttributeTypeNumber},
+		{Scope: "inventory", Name: "mac", TTLDays: 1},
+	}
+	term := func(i int) *int { return &i }
+	testCases := map[string]struct {
+		req   model.FilterValidationRequest
+		dbErr error
+
+		result *model.FilterValidation
+		err    string
+	}{
+		"ok, expression": {
+			req: model.FilterValidationRequest{
+				Expression: `inventory/cpu_count = 4  AND inventory/mac not in ["a"]`,
+			},
+			result: &model.FilterValidation{
+				Valid:     true,
+				Canonical: `inventory/cpu_count == 4 and inventory/mac not in ["a"]`,
+				Filters: []model.FilterPredicate{
+					{Scope: "inventory", Attribute: "cpu_count", Type: "$eq", Value: float64(4)},
+					{Scope: "inventory", Attribute: "mac", Type: "$nin", Value: []interface{}{"a"}},
+				},
+			},
+		},
+		"ok, filters": {
+			req: model.FilterValidationRequest{
+				Filters: []model.FilterPredicate{
+					{Scope: "identity", Attribute: "status", Type: "$eq", Value: "accepted"},
+				},
+			},
+			result: &model.FilterValidation{
+				Valid:     true,
+				Canonical: `identity/status == "accepted"`,
+				Filters: []model.FilterPredicate{
+					{Scope: "identity", Attribute: "status", Type: "$eq", Value: "accepted"},
+				},
+			},
+		},
+		"invalid, syntax error": {
+			req: model.FilterValidationRequest{
+				Expression: `inventory/cpu_count == four`,
+			},
+			result: &model.FilterValidation{
+				Errors: []model.FilterExpressionError{{
+					Position: 23,
+					Message:  `expected a value, found "four"; strings must be double-quoted`,
+				}},
+			},
+		},
+		"invalid, type error": {
+			req: model.FilterValidationRequest{
+				Expression: `inventory/mac == "a" and inventory/cpu_count == "4"`,
+			},
+			result: &model.FilterValidation{
+				Errors: []model.FilterExpressionError{{
+					Position: 25,
+					Message:  "attribute inventory/cpu_count: must be of type number",
+				}},
+			},
+		},
+		"invalid, filter terms": {
+			req: model.FilterValidationRequest{
+				Filters: []model.FilterPredicate{
+					{Scope: "inventory", Attribute: "mac", Type: "$eq", Value: "a"},
+					{Scope: "inventory", Attribute: "mac", Type: "$nin", Value: "a"},
+				},
+			},
+			result: &model.FilterValidation{
+				Errors: []model.FilterExpressionError{{
+					Term:    term(1),
+					Message: `the value of "not in" must be a list`,
+				}},
+			},
+		},
+		"error, db": {
+			req: model.FilterValidationRequest{
+				Expression: `inventory/mac == "a"`,
+			},
+			dbErr: errors.New("db error"),
+			err:   "failed to get attribute definitions: db error",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			db := &mstore.DataStore{}
+			db.On("GetAttributeDefinitions", ctx).Return(defs, tc.dbErr).Maybe()
+
+			result, err := invForTest(db).ValidateFilter(ctx, tc.req)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.result, result)
+			}
+		})
+	}
+}
+
+func TestInventoryCheckAttributeSchema(t *testing.T) {
+	t.Parallel()
+
+	defs := []model.AttributeDefinition{
+		{Scope: "inventory", Name: "cpu_count", Type: model.AttributeTypeNumber},
+		{
+			Scope: "inventory",
+			Name:  "kernel",
+			Type:  model.AttributeTypeString,
+			Mode:  model.SchemaModeMonitor,
+		},
+		{Scope: "inventory", Name: "last_gps_fix", TTLDays: 30},
+		{
+			Scope:       "inventory",
+			Name:        "mac",
+			Normalizers: []string{model.NormalizerTrim, model.NormalizerMAC},
+		},
+		{
+			Scope:       "inventory",
+			Name:        "ipv6",
+			Type:        model.AttributeTypeArray,
+			Normalizers: []string{model.NormalizerIP},
+		},
+	}
+	testCases := map[string]struct {
+		attrs model.DeviceAttributes
+		defs  []model.AttributeDefinition
+
+		// normalized are the attributes written, if not the given ones
+		normalized model.DeviceAttributes
+		violations []model.SchemaViolation
+		recordErr  error
+		err        string
+	}{
+		"ok, conforming": {
+			attrs: model.DeviceAttributes{
+				{Name: "cpu_count", Value: 4.0},
+				{Name: "kernel", Value: "5.10", Scope: "inventory"},
+				{Name: "last_gps_fix", Value: 52.2},
+			},
+			defs: defs,
+		},
+		"ok, no schema": {
+			attrs: model.DeviceAttributes{{Name: "cpu_count", Value: "4"}},
+		},
+		"ok, normalized": {
+			attrs: model.DeviceAttributes{
+				{Name: "mac", Value: " 00-1A-2B-3C-4D-5E\n"},
+				{Name: "ipv6", Value: []interface{}{"2001:DB8:0:0::1/64", "fe80::1"}},
+				{Name: "kernel", Value: " 5.10 "},
+			},
+			defs: defs,
+			normalized: model.DeviceAttributes{
+				{Name: "mac", Value: "00:1a:2b:3c:4d:5e"},
+				{Name: "ipv6", Value: []interface{}{"2001:db8::1/64", "fe80::1"}},
+				{Name: "kernel", Value: " 5.10 "},
+			},
+		},
+		"ok, monitored": {
+			attrs: model.DeviceAttributes{
+				{Name: "cpu_count", Value: 4.0},
+				{Name: "kernel", Value: 5.1},
+			},
+			defs: defs,
+			violations: []model.SchemaViolation{{
+				DeviceID: "1",
+				Scope:    "inventory",
+				Name:     "kernel",
+				Value:    5.1,
+				Error:    "must be of type string",
+			}},
+		},
+		"ok, failure to record the violation": {
+			attrs: model.DeviceAttributes{{Name: "kernel", Value: 5.1}},
+			defs:  defs,
+			violations: []model.SchemaViolation{{
+				DeviceID: "1",
+				Scope:    "inventory",
+				Name:     "kernel",
+				Value:    5.1,
+				Error:    "must be of type string",
+			}},
+			recordErr: errors.New("db error"),
+		},
+		"error, enforced": {
+			attrs: model.DeviceAttributes{
+				{Name: "kernel", Value: 5.1},
+				{Name: "cpu_count", Value: "4", Scope: "inventory"},
+			},
+			defs: defs,
+			err: "attribute inventory/cpu_count must be of type number: " +
+				"attribute does not conform to the schema",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			db := &mstore.DataStore{}
+			db.On("GetAttributeDefinitions", ctx).Return(tc.defs, nil)
+			if tc.violations != nil {
+				db.On("RecordSchemaViolations", ctx,
+					mock.MatchedBy(func(v []model.SchemaViolation) bool {
+						got := append([]model.SchemaViolation{}, v...)
+						for i := range got {
+							if got[i].FirstTs.IsZero() ||
+								got[i].LastTs != got[i].FirstTs {
+								return false
+							}
+							got[i].FirstTs = time.Time{}
+							got[i].LastTs = time.Time{}
+						}
+						return reflect.DeepEqual(tc.violations, got)
+					}),
+				).Return(tc.recordErr)
+			}
+			if tc.err == "" {
+				written := tc.normalized
+				if written == nil {
+					written = append(model.DeviceAttributes{}, tc.attrs...)
+				}
+				db.On("UpsertDevicesAttributesWithUpdated",
+					ctx, []model.DeviceID{"1"}, written,
+				).Return(&model.UpdateResult{}, nil)
+			}
+
+			err := invForTest(db).UpsertAttributesWithUpdated(ctx, "1", tc.attrs)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+			db.AssertExpectations(t)
+		})
+	}
+}
+
+func TestInventoryPreviewGroup(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	preview := model.GroupPreview{
+		Filters: []model.FilterPredicate{{
+			Scope:     model.AttrScopeInventory,
+			Attribute: "device_type",
+			Type:      "$eq",
+			Value:     "raspberrypi4",
+		}},
+		Limit: 2,
+	}
+	devs := []model.Device{{ID: "1"}, {ID: "2"}}
+
+	db := &mstore.DataStore{}
+	db.On("SearchDevices", ctx, preview.SearchParams()).Return(devs, 5, nil)
+	res, err := invForTest(db).PreviewGroup(ctx, preview)
+	assert.NoError(t, err)
+	assert.Equal(t, &model.GroupPreviewResult{Count: 5, Devices: devs}, res)
+
+	db = &mstore.DataStore{}
+	db.On("SearchDevices", ctx, preview.SearchParams()).
+		Return(nil, -1, errors.New("db error"))
+	_, err = invForTest(db).PreviewGroup(ctx, preview)
+	assert.EqualError(t, err, "failed to fetch devices: db error")
+}
+
+func TestInventoryPreviewDevicesGroup(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	assignment := model.GroupAssignment{
+		DeviceIDs: []model.DeviceID{"1", "2", "3"},
+	}
+	preview := &model.GroupAssignmentPreview{
+		Matched:        2,
+		NotFound:       1,
+		AlreadyInGroup: 1,
+	}
+
+	db := &mstore.DataStore{}
+	db.On("PreviewDevicesGroup", ctx, assignment, model.GroupName("foo")).
+		Return(preview, nil)
+	res, err := invForTest(db).PreviewDevicesGroup(ctx, assignment, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, preview, res)
+	db.AssertExpectations(t)
+
+	db = &mstore.DataStore{}
+	db.On("PreviewDevicesGroup", ctx, assignment, model.GroupName("foo")).
+		Return(nil, errors.New("db error"))
+	_, err = invForTest(db).PreviewDevicesGroup(ctx, assignment, "foo")
+	assert.EqualError(t, err, "failed to preview group assignment: db error")
+}
+
+func TestInventoryReconcileDevices(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	rec := model.Reconciliation{
+		DeviceAuth:  []model.DeviceID{"1", "2", "3"},
+		Deployments: []model.DeviceID{"1", "4"},
+	}
+
+	db := &mstore.DataStore{}
+	db.On("GetAllDeviceIDs", ctx).
+		Return([]model.DeviceID{"1", "5"}, nil)
+	report, err := invForTest(db).ReconcileDevices(ctx, rec)
+	assert.NoError(t, err)
+	assert.Equal(t, &model.ReconciliationReport{
+		MissingFromInventory:   []model.DeviceID{"2", "3", "4"},
+		MissingFromDeviceAuth:  []model.DeviceID{"4", "5"},
+		MissingFromDeployments: []model.DeviceID{"2", "3", "5"},
+		Created:                []model.DeviceID{},
+	}, report)
+	db.AssertExpectations(t)
+
+	// only the devices known to deviceauth are created
+	rec.CreateMissing = true
+	db = &mstore.DataStore{}
+	db.On("GetAllDeviceIDs", ctx).
+		Return([]model.DeviceID{"1", "5"}, nil)
+	db.On("UpsertDevicesAttributesWithUpdated",
+		ctx, []model.DeviceID{"2", "3"}, model.DeviceAttributes(nil),
+	).Return(&model.UpdateResult{CreatedCount: 2}, nil)
+	report, err = invForTest(db).ReconcileDevices(ctx, rec)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.DeviceID{"2", "3"}, report.Created)
+	db.AssertExpectations(t)
+
+	db = &mstore.DataStore{}
+	db.On("GetAllDeviceIDs", ctx).
+		Return([]model.DeviceID{"1", "5"}, nil)
+	db.On("UpsertDevicesAttributesWithUpdated",
+		ctx, []model.DeviceID{"2", "3"}, model.DeviceAttributes(nil),
+	).Return(nil, errors.New("db error"))
+	_, err = invForTest(db).ReconcileDevices(ctx, rec)
+	assert.EqualError(t, err, "failed to create missing devices: db error")
+
+	db = &mstore.DataStore{}
+	db.On("GetAllDeviceIDs", ctx).
+		Return(nil, errors.New("db error"))
+	_, err = invForTest(db).ReconcileDevices(ctx, rec)
+	assert.EqualError(t, err, "failed to list devices: db error")
+}
+
+func TestInventoryExternalIDs(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	ref := model.ExternalIDRef{System: "erp", ID: "SN-1"}
+
+	db := &mstore.DataStore{}
+	db.On("GetExternalID", ctx, ref).
+		Return(&model.ExternalID{ExternalIDRef: ref, DeviceID: "1"}, nil).Once()
+	db.On("GetExternalID", ctx, ref).
+		Return(nil, nil).Once()
+	db.On("GetExternalID", ctx, ref).
+		Return(nil, errors.New("db error")).Once()
+	i := invForTest(db)
+
+	id, err := i.ResolveExternalID(ctx, ref)
+	assert.NoError(t, err)
+	assert.Equal(t, model.DeviceID("1"), id)
+
+	_, err = i.ResolveExternalID(ctx, ref)
+	assert.Equal(t, store.ErrExternalIDNotFound, err)
+
+	_, err = i.ResolveExternalID(ctx, ref)
+	assert.EqualError(t, err, "failed to resolve external ID: db error")
+
+	ids := []model.ExternalID{{ExternalIDRef: ref, DeviceID: "1"}}
+	db.On("UpsertExternalIDs", ctx, ids).
+		Return(&model.UpdateResult{CreatedCount: 1}, nil).Once()
+	db.On("UpsertExternalIDs", ctx, ids).
+		Return(nil, errors.New("db error")).Once()
+
+	res, err := i.UpsertExternalIDs(ctx, ids)
+	assert.NoError(t, err)
+	assert.Equal(t, &model.UpdateResult{CreatedCount: 1}, res)
+	_, err = i.UpsertExternalIDs(ctx, ids)
+	assert.EqualError(t, err, "failed to update external IDs: db error")
+
+	db.On("DeleteExternalID", ctx, ref).
+		Return(store.ErrExternalIDNotFound).Once()
+	db.On("DeleteExternalID", ctx, ref).
+		Return(errors.New("db error")).Once()
+
+	err = i.DeleteExternalID(ctx, ref)
+	assert.Equal(t, store.ErrExternalIDNotFound, err)
+	err = i.DeleteExternalID(ctx, ref)
+	assert.EqualError(t, err, "failed to delete external ID: db error")
+	db.AssertExpectations(t)
+}
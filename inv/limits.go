@@ -0,0 +1,148 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/metrics"
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/utils/reqctx"
+)
+
+// limitsRefreshInterval is how long the hard limits of a tenant are
+// cached; changes made through other instances take up to this long to
+// take effect.
+const limitsRefreshInterval = 30 * time.Second
+
+// ErrLimitExceeded is returned when a request exceeds the hard limits of
+// the tenant.
+var ErrLimitExceeded = errors.New("request exceeds the limits of the tenant")
+
+var (
+	softLimitsExceeded = metrics.NewCounterVec(
+		"inventory_soft_limits_exceeded_total",
+		"Number of requests served despite exceeding a soft limit.",
+		"tenant", "limit",
+	)
+	hardLimitsExceeded = metrics.NewCounterVec(
+		"inventory_hard_limits_exceeded_total",
+		"Number of requests rejected for exceeding a hard limit.",
+		"tenant", "limit",
+	)
+)
+
+type cachedLimits struct {
+	limits    model.Limits
+	fetchedAt time.Time
+}
+
+// limitsCache caches the hard limits per tenant.
+type limitsCache struct {
+	mu      sync.Mutex
+	tenants map[string]cachedLimits
+}
+
+func newLimitsCache() *limitsCache {
+	return &limitsCache{
+		tenants: make(map[string]cachedLimits),
+	}
+}
+
+func (c *limitsCache) get(tenantID string) (model.Limits, bool) {
+	if c == nil {
+		return model.Limits{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached, ok := c.tenants[tenantID]
+	if !ok || time.Since(cached.fetchedAt) > limitsRefreshInterval {
+		return model.Limits{}, false
+	}
+	return cached.limits, true
+}
+
+func (c *limitsCache) set(tenantID string, limits model.Limits) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tenants[tenantID] = cachedLimits{
+		limits:    limits,
+		fetchedAt: time.Now(),
+	}
+}
+
+// WithSoftLimits sets the limits which, when exceeded, are only
+// reported to the clients; the requests are still served.
+func (i *inventory) WithSoftLimits(limits model.Limits) InventoryApp {
+	i.softLimits = limits
+	return i
+}
+
+// GetLimits returns the hard limits of the tenant in the context.
+func (i *inventory) GetLimits(ctx context.Context) (model.Limits, error) {
+	tenantID := reqctx.FromContext(ctx).TenantID
+	if limits, ok := i.limitsCache.get(tenantID); ok {
+		return limits, nil
+	}
+	limits, err := i.db.GetLimits(ctx)
+	if err != nil {
+		return limits, errors.Wrap(err, "failed to get limits")
+	}
+	i.limitsCache.set(tenantID, limits)
+	return limits, nil
+}
+
+// SetLimits replaces the hard limits of the tenant in the context.
+func (i *inventory) SetLimits(ctx context.Context, limits model.Limits) error {
+	if err := i.db.SetLimits(ctx, limits); err != nil {
+		return errors.Wrap(err, "failed to set limits")
+	}
+	i.limitsCache.set(reqctx.FromContext(ctx).TenantID, limits)
+	return nil
+}
+
+// CheckLimits returns ErrLimitExceeded if a request of the given size
+// exceeds the hard limits of the tenant in the context; otherwise it
+// returns the soft limits the request exceeds, which the caller reports
+// to the client. If the hard limits cannot be retrieved, none apply.
+func (i *inventory) CheckLimits(
+	ctx context.Context,
+	size model.Limits,
+) ([]model.LimitViolation, error) {
+	tenantID := reqctx.FromContext(ctx).TenantID
+	limits, err := i.GetLimits(ctx)
+	if err != nil {
+		log.FromContext(ctx).Warnf("skipping the hard limits: %v", err)
+	}
+	if violations := limits.Exceeded(size); len(violations) > 0 {
+		for _, v := range violations {
+			hardLimitsExceeded.Inc(tenantID, v.Limit)
+		}
+		return nil, errors.Wrap(ErrLimitExceeded, violations[0].String())
+	}
+	violations := i.softLimits.Exceeded(size)
+	for _, v := range violations {
+		softLimitsExceeded.Inc(tenantID, v.Limit)
+	}
+	return violations, nil
+}
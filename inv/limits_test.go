@@ -0,0 +1,76 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/inventory/model"
+	mstore "github.com/mendersoftware/inventory/store/mocks"
+)
+
+func TestInventoryLimits(t *testing.T) {
+	t.Parallel()
+
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "tenant",
+	})
+
+	db := &mstore.DataStore{}
+	db.On("GetLimits", ctx).
+		Return(model.Limits{PerPage: 200}, nil).
+		Once()
+	i := NewInventory(db).WithSoftLimits(model.Limits{
+		PerPage: 100,
+		Filters: 5,
+	})
+
+	limits, err := i.GetLimits(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, model.Limits{PerPage: 200}, limits)
+
+	// the limits of the tenant are cached
+	warnings, err := i.CheckLimits(ctx, model.Limits{PerPage: 150, Filters: 6})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.LimitViolation{
+		{Limit: model.LimitPerPage, Value: 150, Max: 100},
+		{Limit: model.LimitFilters, Value: 6, Max: 5},
+	}, warnings)
+	_, err = i.CheckLimits(ctx, model.Limits{PerPage: 250})
+	assert.EqualError(t, err, "per_page 250 exceeds the limit of 200: "+
+		"request exceeds the limits of the tenant")
+	db.AssertExpectations(t)
+
+	db.On("SetLimits", ctx, model.Limits{PerPage: 300}).Return(nil)
+	err = i.SetLimits(ctx, model.Limits{PerPage: 300})
+	assert.NoError(t, err)
+	warnings, err = i.CheckLimits(ctx, model.Limits{PerPage: 250})
+	assert.NoError(t, err)
+	assert.Len(t, warnings, 1)
+
+	// the hard limits are skipped when unavailable
+	ctx = context.Background()
+	db.On("GetLimits", ctx).Return(model.Limits{}, errors.New("db error"))
+	_, err = i.GetLimits(ctx)
+	assert.EqualError(t, err, "failed to get limits: db error")
+	warnings, err = i.CheckLimits(ctx, model.Limits{PerPage: 250})
+	assert.NoError(t, err)
+	assert.Len(t, warnings, 1)
+}
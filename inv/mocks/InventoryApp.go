@@ -17,13 +17,24 @@
 package mocks
 
 import (
+	blob "github.com/mendersoftware/inventory/blob"
+
 	context "context"
 
+	events "github.com/mendersoftware/inventory/events"
+	inv "github.com/mendersoftware/inventory/inv"
+
+	io "io"
+
 	mock "github.com/stretchr/testify/mock"
 
 	model "github.com/mendersoftware/inventory/model"
 
+	remotewrite "github.com/mendersoftware/inventory/remotewrite"
+
 	store "github.com/mendersoftware/inventory/store"
+
+	validator "github.com/mendersoftware/inventory/validator"
 )
 
 // InventoryApp is an autogenerated mock type for the InventoryApp type
@@ -45,50 +56,45 @@ func (_m *InventoryApp) AddDevice(ctx context.Context, d *model.Device) error {
 	return r0
 }
 
-// CreateTenant provides a mock function with given fields: ctx, tenant
-func (_m *InventoryApp) CreateTenant(ctx context.Context, tenant model.NewTenant) error {
-	ret := _m.Called(ctx, tenant)
+// ApplyDeviceTemplate provides a mock function with given fields: ctx, id, req
+func (_m *InventoryApp) ApplyDeviceTemplate(ctx context.Context, id model.DeviceID, req model.DeviceTemplateRequest) (*model.DeviceTemplate, error) {
+	ret := _m.Called(ctx, id, req)
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, model.NewTenant) error); ok {
-		r0 = rf(ctx, tenant)
+	var r0 *model.DeviceTemplate
+	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceID, model.DeviceTemplateRequest) *model.DeviceTemplate); ok {
+		r0 = rf(ctx, id, req)
 	} else {
-		r0 = ret.Error(0)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.DeviceTemplate)
+		}
 	}
 
-	return r0
-}
-
-// DeleteDevice provides a mock function with given fields: ctx, id
-func (_m *InventoryApp) DeleteDevice(ctx context.Context, id model.DeviceID) error {
-	ret := _m.Called(ctx, id)
-
-	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceID) error); ok {
-		r0 = rf(ctx, id)
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.DeviceID, model.DeviceTemplateRequest) error); ok {
+		r1 = rf(ctx, id, req)
 	} else {
-		r0 = ret.Error(0)
+		r1 = ret.Error(1)
 	}
 
-	return r0
+	return r0, r1
 }
 
-// DeleteDevices provides a mock function with given fields: ctx, ids
-func (_m *InventoryApp) DeleteDevices(ctx context.Context, ids []model.DeviceID) (*model.UpdateResult, error) {
-	ret := _m.Called(ctx, ids)
+// AssignTags provides a mock function with given fields: ctx, assignment
+func (_m *InventoryApp) AssignTags(ctx context.Context, assignment model.TagsAssignment) (*model.TagsAssignmentResult, error) {
+	ret := _m.Called(ctx, assignment)
 
-	var r0 *model.UpdateResult
-	if rf, ok := ret.Get(0).(func(context.Context, []model.DeviceID) *model.UpdateResult); ok {
-		r0 = rf(ctx, ids)
+	var r0 *model.TagsAssignmentResult
+	if rf, ok := ret.Get(0).(func(context.Context, model.TagsAssignment) *model.TagsAssignmentResult); ok {
+		r0 = rf(ctx, assignment)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*model.UpdateResult)
+			r0 = ret.Get(0).(*model.TagsAssignmentResult)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, []model.DeviceID) error); ok {
-		r1 = rf(ctx, ids)
+	if rf, ok := ret.Get(1).(func(context.Context, model.TagsAssignment) error); ok {
+		r1 = rf(ctx, assignment)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -96,22 +102,22 @@ func (_m *InventoryApp) DeleteDevices(ctx context.Context, ids []model.DeviceID)
 	return r0, r1
 }
 
-// GetDevice provides a mock function with given fields: ctx, id
-func (_m *InventoryApp) GetDevice(ctx context.Context, id model.DeviceID) (*model.Device, error) {
-	ret := _m.Called(ctx, id)
+// BackfillGroups provides a mock function with given fields: ctx
+func (_m *InventoryApp) BackfillGroups(ctx context.Context) (*model.UpdateResult, error) {
+	ret := _m.Called(ctx)
 
-	var r0 *model.Device
-	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceID) *model.Device); ok {
-		r0 = rf(ctx, id)
+	var r0 *model.UpdateResult
+	if rf, ok := ret.Get(0).(func(context.Context) *model.UpdateResult); ok {
+		r0 = rf(ctx)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*model.Device)
+			r0 = ret.Get(0).(*model.UpdateResult)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, model.DeviceID) error); ok {
-		r1 = rf(ctx, id)
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -119,43 +125,36 @@ func (_m *InventoryApp) GetDevice(ctx context.Context, id model.DeviceID) (*mode
 	return r0, r1
 }
 
-// GetDeviceGroup provides a mock function with given fields: ctx, id
-func (_m *InventoryApp) GetDeviceGroup(ctx context.Context, id model.DeviceID) (model.GroupName, error) {
-	ret := _m.Called(ctx, id)
-
-	var r0 model.GroupName
-	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceID) model.GroupName); ok {
-		r0 = rf(ctx, id)
-	} else {
-		r0 = ret.Get(0).(model.GroupName)
-	}
+// CheckCompletenessAlerts provides a mock function with given fields: ctx
+func (_m *InventoryApp) CheckCompletenessAlerts(ctx context.Context) error {
+	ret := _m.Called(ctx)
 
-	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, model.DeviceID) error); ok {
-		r1 = rf(ctx, id)
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
 	} else {
-		r1 = ret.Error(1)
+		r0 = ret.Error(0)
 	}
 
-	return r0, r1
+	return r0
 }
 
-// GetFiltersAttributes provides a mock function with given fields: ctx
-func (_m *InventoryApp) GetFiltersAttributes(ctx context.Context) ([]model.FilterAttribute, error) {
-	ret := _m.Called(ctx)
+// CheckDeviceEligibility provides a mock function with given fields: ctx, id, req
+func (_m *InventoryApp) CheckDeviceEligibility(ctx context.Context, id model.DeviceID, req model.EligibilityRequest) (*model.DeviceEligibility, error) {
+	ret := _m.Called(ctx, id, req)
 
-	var r0 []model.FilterAttribute
-	if rf, ok := ret.Get(0).(func(context.Context) []model.FilterAttribute); ok {
-		r0 = rf(ctx)
+	var r0 *model.DeviceEligibility
+	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceID, model.EligibilityRequest) *model.DeviceEligibility); ok {
+		r0 = rf(ctx, id, req)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]model.FilterAttribute)
+			r0 = ret.Get(0).(*model.DeviceEligibility)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
-		r1 = rf(ctx)
+	if rf, ok := ret.Get(1).(func(context.Context, model.DeviceID, model.EligibilityRequest) error); ok {
+		r1 = rf(ctx, id, req)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -163,96 +162,89 @@ func (_m *InventoryApp) GetFiltersAttributes(ctx context.Context) ([]model.Filte
 	return r0, r1
 }
 
-// HealthCheck provides a mock function with given fields: ctx
-func (_m *InventoryApp) HealthCheck(ctx context.Context) error {
-	ret := _m.Called(ctx)
+// CheckLimits provides a mock function with given fields: ctx, size
+func (_m *InventoryApp) CheckLimits(ctx context.Context, size model.Limits) ([]model.LimitViolation, error) {
+	ret := _m.Called(ctx, size)
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
-		r0 = rf(ctx)
+	var r0 []model.LimitViolation
+	if rf, ok := ret.Get(0).(func(context.Context, model.Limits) []model.LimitViolation); ok {
+		r0 = rf(ctx, size)
 	} else {
-		r0 = ret.Error(0)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.LimitViolation)
+		}
 	}
 
-	return r0
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.Limits) error); ok {
+		r1 = rf(ctx, size)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
-// ListDevices provides a mock function with given fields: ctx, q
-func (_m *InventoryApp) ListDevices(ctx context.Context, q store.ListQuery) ([]model.Device, int, error) {
+// CountDevices provides a mock function with given fields: ctx, q
+func (_m *InventoryApp) CountDevices(ctx context.Context, q store.ListQuery) (int, error) {
 	ret := _m.Called(ctx, q)
 
-	var r0 []model.Device
-	if rf, ok := ret.Get(0).(func(context.Context, store.ListQuery) []model.Device); ok {
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, store.ListQuery) int); ok {
 		r0 = rf(ctx, q)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]model.Device)
-		}
+		r0 = ret.Get(0).(int)
 	}
 
-	var r1 int
-	if rf, ok := ret.Get(1).(func(context.Context, store.ListQuery) int); ok {
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, store.ListQuery) error); ok {
 		r1 = rf(ctx, q)
 	} else {
-		r1 = ret.Get(1).(int)
-	}
-
-	var r2 error
-	if rf, ok := ret.Get(2).(func(context.Context, store.ListQuery) error); ok {
-		r2 = rf(ctx, q)
-	} else {
-		r2 = ret.Error(2)
+		r1 = ret.Error(1)
 	}
 
-	return r0, r1, r2
+	return r0, r1
 }
 
-// ListDevicesByGroup provides a mock function with given fields: ctx, group, skip, limit
-func (_m *InventoryApp) ListDevicesByGroup(ctx context.Context, group model.GroupName, skip int, limit int) ([]model.DeviceID, int, error) {
-	ret := _m.Called(ctx, group, skip, limit)
+// CreateSavedFilter provides a mock function with given fields: ctx, filter
+func (_m *InventoryApp) CreateSavedFilter(ctx context.Context, filter model.SavedFilter) (*model.SavedFilter, error) {
+	ret := _m.Called(ctx, filter)
 
-	var r0 []model.DeviceID
-	if rf, ok := ret.Get(0).(func(context.Context, model.GroupName, int, int) []model.DeviceID); ok {
-		r0 = rf(ctx, group, skip, limit)
+	var r0 *model.SavedFilter
+	if rf, ok := ret.Get(0).(func(context.Context, model.SavedFilter) *model.SavedFilter); ok {
+		r0 = rf(ctx, filter)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]model.DeviceID)
+			r0 = ret.Get(0).(*model.SavedFilter)
 		}
 	}
 
-	var r1 int
-	if rf, ok := ret.Get(1).(func(context.Context, model.GroupName, int, int) int); ok {
-		r1 = rf(ctx, group, skip, limit)
-	} else {
-		r1 = ret.Get(1).(int)
-	}
-
-	var r2 error
-	if rf, ok := ret.Get(2).(func(context.Context, model.GroupName, int, int) error); ok {
-		r2 = rf(ctx, group, skip, limit)
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.SavedFilter) error); ok {
+		r1 = rf(ctx, filter)
 	} else {
-		r2 = ret.Error(2)
+		r1 = ret.Error(1)
 	}
 
-	return r0, r1, r2
+	return r0, r1
 }
 
-// ListGroups provides a mock function with given fields: ctx, filters
-func (_m *InventoryApp) ListGroups(ctx context.Context, filters []model.FilterPredicate) ([]model.GroupName, error) {
-	ret := _m.Called(ctx, filters)
+// CreateSubscription provides a mock function with given fields: ctx, sub
+func (_m *InventoryApp) CreateSubscription(ctx context.Context, sub model.Subscription) (*model.Subscription, error) {
+	ret := _m.Called(ctx, sub)
 
-	var r0 []model.GroupName
-	if rf, ok := ret.Get(0).(func(context.Context, []model.FilterPredicate) []model.GroupName); ok {
-		r0 = rf(ctx, filters)
+	var r0 *model.Subscription
+	if rf, ok := ret.Get(0).(func(context.Context, model.Subscription) *model.Subscription); ok {
+		r0 = rf(ctx, sub)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]model.GroupName)
+			r0 = ret.Get(0).(*model.Subscription)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, []model.FilterPredicate) error); ok {
-		r1 = rf(ctx, filters)
+	if rf, ok := ret.Get(1).(func(context.Context, model.Subscription) error); ok {
+		r1 = rf(ctx, sub)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -260,13 +252,13 @@ func (_m *InventoryApp) ListGroups(ctx context.Context, filters []model.FilterPr
 	return r0, r1
 }
 
-// ReplaceAttributes provides a mock function with given fields: ctx, id, upsertAttrs, scope
-func (_m *InventoryApp) ReplaceAttributes(ctx context.Context, id model.DeviceID, upsertAttrs model.DeviceAttributes, scope string) error {
-	ret := _m.Called(ctx, id, upsertAttrs, scope)
+// CreateTenant provides a mock function with given fields: ctx, tenant
+func (_m *InventoryApp) CreateTenant(ctx context.Context, tenant model.NewTenant) error {
+	ret := _m.Called(ctx, tenant)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceID, model.DeviceAttributes, string) error); ok {
-		r0 = rf(ctx, id, upsertAttrs, scope)
+	if rf, ok := ret.Get(0).(func(context.Context, model.NewTenant) error); ok {
+		r0 = rf(ctx, tenant)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -274,43 +266,41 @@ func (_m *InventoryApp) ReplaceAttributes(ctx context.Context, id model.DeviceID
 	return r0
 }
 
-// SearchDevices provides a mock function with given fields: ctx, searchParams
-func (_m *InventoryApp) SearchDevices(ctx context.Context, searchParams model.SearchParams) ([]model.Device, int, error) {
-	ret := _m.Called(ctx, searchParams)
+// CutoverGroups provides a mock function with given fields: ctx
+func (_m *InventoryApp) CutoverGroups(ctx context.Context) error {
+	ret := _m.Called(ctx)
 
-	var r0 []model.Device
-	if rf, ok := ret.Get(0).(func(context.Context, model.SearchParams) []model.Device); ok {
-		r0 = rf(ctx, searchParams)
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]model.Device)
-		}
+		r0 = ret.Error(0)
 	}
 
-	var r1 int
-	if rf, ok := ret.Get(1).(func(context.Context, model.SearchParams) int); ok {
-		r1 = rf(ctx, searchParams)
-	} else {
-		r1 = ret.Get(1).(int)
-	}
+	return r0
+}
 
-	var r2 error
-	if rf, ok := ret.Get(2).(func(context.Context, model.SearchParams) error); ok {
-		r2 = rf(ctx, searchParams)
+// DeleteAttributeDefinition provides a mock function with given fields: ctx, scope, name
+func (_m *InventoryApp) DeleteAttributeDefinition(ctx context.Context, scope string, name string) error {
+	ret := _m.Called(ctx, scope, name)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, scope, name)
 	} else {
-		r2 = ret.Error(2)
+		r0 = ret.Error(0)
 	}
 
-	return r0, r1, r2
+	return r0
 }
 
-// UnsetDeviceGroup provides a mock function with given fields: ctx, id, groupName
-func (_m *InventoryApp) UnsetDeviceGroup(ctx context.Context, id model.DeviceID, groupName model.GroupName) error {
-	ret := _m.Called(ctx, id, groupName)
+// DeleteCompletenessAlert provides a mock function with given fields: ctx
+func (_m *InventoryApp) DeleteCompletenessAlert(ctx context.Context) error {
+	ret := _m.Called(ctx)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceID, model.GroupName) error); ok {
-		r0 = rf(ctx, id, groupName)
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -318,36 +308,27 @@ func (_m *InventoryApp) UnsetDeviceGroup(ctx context.Context, id model.DeviceID,
 	return r0
 }
 
-// UnsetDevicesGroup provides a mock function with given fields: ctx, deviceIDs, groupName
-func (_m *InventoryApp) UnsetDevicesGroup(ctx context.Context, deviceIDs []model.DeviceID, groupName model.GroupName) (*model.UpdateResult, error) {
-	ret := _m.Called(ctx, deviceIDs, groupName)
-
-	var r0 *model.UpdateResult
-	if rf, ok := ret.Get(0).(func(context.Context, []model.DeviceID, model.GroupName) *model.UpdateResult); ok {
-		r0 = rf(ctx, deviceIDs, groupName)
-	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*model.UpdateResult)
-		}
-	}
+// DeleteDeadLetter provides a mock function with given fields: ctx, id
+func (_m *InventoryApp) DeleteDeadLetter(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
 
-	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, []model.DeviceID, model.GroupName) error); ok {
-		r1 = rf(ctx, deviceIDs, groupName)
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
 	} else {
-		r1 = ret.Error(1)
+		r0 = ret.Error(0)
 	}
 
-	return r0, r1
+	return r0
 }
 
-// UpdateDeviceGroup provides a mock function with given fields: ctx, id, group
-func (_m *InventoryApp) UpdateDeviceGroup(ctx context.Context, id model.DeviceID, group model.GroupName) error {
-	ret := _m.Called(ctx, id, group)
+// DeleteDevice provides a mock function with given fields: ctx, id
+func (_m *InventoryApp) DeleteDevice(ctx context.Context, id model.DeviceID) error {
+	ret := _m.Called(ctx, id)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceID, model.GroupName) error); ok {
-		r0 = rf(ctx, id, group)
+	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceID) error); ok {
+		r0 = rf(ctx, id)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -355,13 +336,13 @@ func (_m *InventoryApp) UpdateDeviceGroup(ctx context.Context, id model.DeviceID
 	return r0
 }
 
-// UpdateDevicesGroup provides a mock function with given fields: ctx, ids, group
-func (_m *InventoryApp) UpdateDevicesGroup(ctx context.Context, ids []model.DeviceID, group model.GroupName) (*model.UpdateResult, error) {
-	ret := _m.Called(ctx, ids, group)
+// DeleteDevices provides a mock function with given fields: ctx, ids
+func (_m *InventoryApp) DeleteDevices(ctx context.Context, ids []model.DeviceID) (*model.UpdateResult, error) {
+	ret := _m.Called(ctx, ids)
 
 	var r0 *model.UpdateResult
-	if rf, ok := ret.Get(0).(func(context.Context, []model.DeviceID, model.GroupName) *model.UpdateResult); ok {
-		r0 = rf(ctx, ids, group)
+	if rf, ok := ret.Get(0).(func(context.Context, []model.DeviceID) *model.UpdateResult); ok {
+		r0 = rf(ctx, ids)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*model.UpdateResult)
@@ -369,8 +350,8 @@ func (_m *InventoryApp) UpdateDevicesGroup(ctx context.Context, ids []model.Devi
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, []model.DeviceID, model.GroupName) error); ok {
-		r1 = rf(ctx, ids, group)
+	if rf, ok := ret.Get(1).(func(context.Context, []model.DeviceID) error); ok {
+		r1 = rf(ctx, ids)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -378,13 +359,13 @@ func (_m *InventoryApp) UpdateDevicesGroup(ctx context.Context, ids []model.Devi
 	return r0, r1
 }
 
-// UpsertAttributes provides a mock function with given fields: ctx, id, attrs
-func (_m *InventoryApp) UpsertAttributes(ctx context.Context, id model.DeviceID, attrs model.DeviceAttributes) error {
-	ret := _m.Called(ctx, id, attrs)
+// DeleteDigestSettings provides a mock function with given fields: ctx
+func (_m *InventoryApp) DeleteDigestSettings(ctx context.Context) error {
+	ret := _m.Called(ctx)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceID, model.DeviceAttributes) error); ok {
-		r0 = rf(ctx, id, attrs)
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -392,13 +373,13 @@ func (_m *InventoryApp) UpsertAttributes(ctx context.Context, id model.DeviceID,
 	return r0
 }
 
-// UpsertAttributesWithUpdated provides a mock function with given fields: ctx, id, attrs
-func (_m *InventoryApp) UpsertAttributesWithUpdated(ctx context.Context, id model.DeviceID, attrs model.DeviceAttributes) error {
-	ret := _m.Called(ctx, id, attrs)
+// DeleteDynamicGroup provides a mock function with given fields: ctx, name
+func (_m *InventoryApp) DeleteDynamicGroup(ctx context.Context, name model.GroupName) error {
+	ret := _m.Called(ctx, name)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceID, model.DeviceAttributes) error); ok {
-		r0 = rf(ctx, id, attrs)
+	if rf, ok := ret.Get(0).(func(context.Context, model.GroupName) error); ok {
+		r0 = rf(ctx, name)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -406,17 +387,2042 @@ func (_m *InventoryApp) UpsertAttributesWithUpdated(ctx context.Context, id mode
 	return r0
 }
 
-// UpsertDevicesStatuses provides a mock function with given fields: ctx, devices, attrs
-func (_m *InventoryApp) UpsertDevicesStatuses(ctx context.Context, devices []model.DeviceUpdate, attrs model.DeviceAttributes) (*model.UpdateResult, error) {
-	ret := _m.Called(ctx, devices, attrs)
+// DeleteExternalID provides a mock function with given fields: ctx, ref
+func (_m *InventoryApp) DeleteExternalID(ctx context.Context, ref model.ExternalIDRef) error {
+	ret := _m.Called(ctx, ref)
 
-	var r0 *model.UpdateResult
-	if rf, ok := ret.Get(0).(func(context.Context, []model.DeviceUpdate, model.DeviceAttributes) *model.UpdateResult); ok {
-		r0 = rf(ctx, devices, attrs)
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.ExternalIDRef) error); ok {
+		r0 = rf(ctx, ref)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*model.UpdateResult)
-		}
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteGroup provides a mock function with given fields: ctx, name
+func (_m *InventoryApp) DeleteGroup(ctx context.Context, name model.GroupName) (*model.UpdateResult, error) {
+	ret := _m.Called(ctx, name)
+
+	var r0 *model.UpdateResult
+	if rf, ok := ret.Get(0).(func(context.Context, model.GroupName) *model.UpdateResult); ok {
+		r0 = rf(ctx, name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UpdateResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.GroupName) error); ok {
+		r1 = rf(ctx, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteGroupMetadata provides a mock function with given fields: ctx, name
+func (_m *InventoryApp) DeleteGroupMetadata(ctx context.Context, name model.GroupName) error {
+	ret := _m.Called(ctx, name)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.GroupName) error); ok {
+		r0 = rf(ctx, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteSavedFilter provides a mock function with given fields: ctx, id
+func (_m *InventoryApp) DeleteSavedFilter(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteScope provides a mock function with given fields: ctx, name
+func (_m *InventoryApp) DeleteScope(ctx context.Context, name string) error {
+	ret := _m.Called(ctx, name)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteSubscription provides a mock function with given fields: ctx, id
+func (_m *InventoryApp) DeleteSubscription(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteValidationWebhook provides a mock function with given fields: ctx
+func (_m *InventoryApp) DeleteValidationWebhook(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeploymentFinished provides a mock function with given fields: ctx, deploymentID, ids
+func (_m *InventoryApp) DeploymentFinished(ctx context.Context, deploymentID string, ids []model.DeviceID) ([]model.DeploymentDiff, error) {
+	ret := _m.Called(ctx, deploymentID, ids)
+
+	var r0 []model.DeploymentDiff
+	if rf, ok := ret.Get(0).(func(context.Context, string, []model.DeviceID) []model.DeploymentDiff); ok {
+		r0 = rf(ctx, deploymentID, ids)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.DeploymentDiff)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, []model.DeviceID) error); ok {
+		r1 = rf(ctx, deploymentID, ids)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ExplainEmptySearch provides a mock function with given fields: ctx, searchParams
+func (_m *InventoryApp) ExplainEmptySearch(ctx context.Context, searchParams model.SearchParams) ([]model.FilterTermCount, error) {
+	ret := _m.Called(ctx, searchParams)
+
+	var r0 []model.FilterTermCount
+	if rf, ok := ret.Get(0).(func(context.Context, model.SearchParams) []model.FilterTermCount); ok {
+		r0 = rf(ctx, searchParams)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.FilterTermCount)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.SearchParams) error); ok {
+		r1 = rf(ctx, searchParams)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ExplainSearchDevices provides a mock function with given fields: ctx, searchParams
+func (_m *InventoryApp) ExplainSearchDevices(ctx context.Context, searchParams model.SearchParams) (*model.QueryPlan, error) {
+	ret := _m.Called(ctx, searchParams)
+
+	var r0 *model.QueryPlan
+	if rf, ok := ret.Get(0).(func(context.Context, model.SearchParams) *model.QueryPlan); ok {
+		r0 = rf(ctx, searchParams)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.QueryPlan)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.SearchParams) error); ok {
+		r1 = rf(ctx, searchParams)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ExportAnonymized provides a mock function with given fields: ctx, w, opts
+func (_m *InventoryApp) ExportAnonymized(ctx context.Context, w io.Writer, opts model.AnonymizeOptions) (int, error) {
+	ret := _m.Called(ctx, w, opts)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, io.Writer, model.AnonymizeOptions) int); ok {
+		r0 = rf(ctx, w, opts)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, io.Writer, model.AnonymizeOptions) error); ok {
+		r1 = rf(ctx, w, opts)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ExportConfigBundle provides a mock function with given fields: ctx
+func (_m *InventoryApp) ExportConfigBundle(ctx context.Context) (*model.ConfigBundle, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *model.ConfigBundle
+	if rf, ok := ret.Get(0).(func(context.Context) *model.ConfigBundle); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ConfigBundle)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FeatureEnabled provides a mock function with given fields: ctx, flag
+func (_m *InventoryApp) FeatureEnabled(ctx context.Context, flag model.FeatureFlag) bool {
+	ret := _m.Called(ctx, flag)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, model.FeatureFlag) bool); ok {
+		r0 = rf(ctx, flag)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
+// GetAttributeAliases provides a mock function with given fields: ctx
+func (_m *InventoryApp) GetAttributeAliases(ctx context.Context) (model.AttributeAliases, error) {
+	ret := _m.Called(ctx)
+
+	var r0 model.AttributeAliases
+	if rf, ok := ret.Get(0).(func(context.Context) model.AttributeAliases); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(model.AttributeAliases)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAttributeDescriptions provides a mock function with given fields: ctx
+func (_m *InventoryApp) GetAttributeDescriptions(ctx context.Context) (model.AttributeDescriptions, error) {
+	ret := _m.Called(ctx)
+
+	var r0 model.AttributeDescriptions
+	if rf, ok := ret.Get(0).(func(context.Context) model.AttributeDescriptions); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(model.AttributeDescriptions)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAttributeGraph provides a mock function with given fields: ctx, scope, name, limit
+func (_m *InventoryApp) GetAttributeGraph(ctx context.Context, scope string, name string, limit int) (*model.AttributeGraph, error) {
+	ret := _m.Called(ctx, scope, name, limit)
+
+	var r0 *model.AttributeGraph
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, int) *model.AttributeGraph); ok {
+		r0 = rf(ctx, scope, name, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.AttributeGraph)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, int) error); ok {
+		r1 = rf(ctx, scope, name, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAttributePivot provides a mock function with given fields: ctx, q, row, column, limit
+func (_m *InventoryApp) GetAttributePivot(ctx context.Context, q store.ListQuery, row model.SelectAttribute, column model.SelectAttribute, limit int) (*model.AttributePivot, error) {
+	ret := _m.Called(ctx, q, row, column, limit)
+
+	var r0 *model.AttributePivot
+	if rf, ok := ret.Get(0).(func(context.Context, store.ListQuery, model.SelectAttribute, model.SelectAttribute, int) *model.AttributePivot); ok {
+		r0 = rf(ctx, q, row, column, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.AttributePivot)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, store.ListQuery, model.SelectAttribute, model.SelectAttribute, int) error); ok {
+		r1 = rf(ctx, q, row, column, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAttributeStatistics provides a mock function with given fields: ctx, scope, name
+func (_m *InventoryApp) GetAttributeStatistics(ctx context.Context, scope string, name string) (*model.AttributeStatistics, error) {
+	ret := _m.Called(ctx, scope, name)
+
+	var r0 *model.AttributeStatistics
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *model.AttributeStatistics); ok {
+		r0 = rf(ctx, scope, name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.AttributeStatistics)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, scope, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetColdAttributes provides a mock function with given fields: ctx, id
+func (_m *InventoryApp) GetColdAttributes(ctx context.Context, id model.DeviceID) (model.DeviceAttributes, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 model.DeviceAttributes
+	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceID) model.DeviceAttributes); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(model.DeviceAttributes)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.DeviceID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetCompletenessAlert provides a mock function with given fields: ctx
+func (_m *InventoryApp) GetCompletenessAlert(ctx context.Context) (*model.CompletenessAlert, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *model.CompletenessAlert
+	if rf, ok := ret.Get(0).(func(context.Context) *model.CompletenessAlert); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.CompletenessAlert)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDevice provides a mock function with given fields: ctx, id
+func (_m *InventoryApp) GetDevice(ctx context.Context, id model.DeviceID) (*model.Device, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *model.Device
+	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceID) *model.Device); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Device)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.DeviceID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeviceCompleteness provides a mock function with given fields: ctx, id
+func (_m *InventoryApp) GetDeviceCompleteness(ctx context.Context, id model.DeviceID) (*model.DeviceCompleteness, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *model.DeviceCompleteness
+	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceID) *model.DeviceCompleteness); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.DeviceCompleteness)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.DeviceID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeviceGroups provides a mock function with given fields: ctx, id
+func (_m *InventoryApp) GetDeviceGroups(ctx context.Context, id model.DeviceID) ([]model.GroupName, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 []model.GroupName
+	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceID) []model.GroupName); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.GroupName)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.DeviceID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDevicesByIDs provides a mock function with given fields: ctx, req
+func (_m *InventoryApp) GetDevicesByIDs(ctx context.Context, req model.DevicesGetRequest) ([]model.Device, error) {
+	ret := _m.Called(ctx, req)
+
+	var r0 []model.Device
+	if rf, ok := ret.Get(0).(func(context.Context, model.DevicesGetRequest) []model.Device); ok {
+		r0 = rf(ctx, req)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Device)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.DevicesGetRequest) error); ok {
+		r1 = rf(ctx, req)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDigestSettings provides a mock function with given fields: ctx
+func (_m *InventoryApp) GetDigestSettings(ctx context.Context) (*model.DigestSettings, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *model.DigestSettings
+	if rf, ok := ret.Get(0).(func(context.Context) *model.DigestSettings); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.DigestSettings)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDynamicGroup provides a mock function with given fields: ctx, name
+func (_m *InventoryApp) GetDynamicGroup(ctx context.Context, name model.GroupName) (*model.DynamicGroup, error) {
+	ret := _m.Called(ctx, name)
+
+	var r0 *model.DynamicGroup
+	if rf, ok := ret.Get(0).(func(context.Context, model.GroupName) *model.DynamicGroup); ok {
+		r0 = rf(ctx, name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.DynamicGroup)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.GroupName) error); ok {
+		r1 = rf(ctx, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetExportJob provides a mock function with given fields: ctx, id
+func (_m *InventoryApp) GetExportJob(ctx context.Context, id string) (*model.ExportJob, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *model.ExportJob
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.ExportJob); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ExportJob)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetFeatureFlags provides a mock function with given fields: ctx
+func (_m *InventoryApp) GetFeatureFlags(ctx context.Context) (model.FeatureFlagSet, error) {
+	ret := _m.Called(ctx)
+
+	var r0 model.FeatureFlagSet
+	if rf, ok := ret.Get(0).(func(context.Context) model.FeatureFlagSet); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(model.FeatureFlagSet)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetFiltersAttributes provides a mock function with given fields: ctx
+func (_m *InventoryApp) GetFiltersAttributes(ctx context.Context) ([]model.FilterAttribute, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []model.FilterAttribute
+	if rf, ok := ret.Get(0).(func(context.Context) []model.FilterAttribute); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.FilterAttribute)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetGroupDescendants provides a mock function with given fields: ctx, name
+func (_m *InventoryApp) GetGroupDescendants(ctx context.Context, name model.GroupName) ([]model.GroupName, error) {
+	ret := _m.Called(ctx, name)
+
+	var r0 []model.GroupName
+	if rf, ok := ret.Get(0).(func(context.Context, model.GroupName) []model.GroupName); ok {
+		r0 = rf(ctx, name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.GroupName)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.GroupName) error); ok {
+		r1 = rf(ctx, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetGroupMetadata provides a mock function with given fields: ctx, name
+func (_m *InventoryApp) GetGroupMetadata(ctx context.Context, name model.GroupName) (*model.GroupMetadata, error) {
+	ret := _m.Called(ctx, name)
+
+	var r0 *model.GroupMetadata
+	if rf, ok := ret.Get(0).(func(context.Context, model.GroupName) *model.GroupMetadata); ok {
+		r0 = rf(ctx, name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.GroupMetadata)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.GroupName) error); ok {
+		r1 = rf(ctx, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetGroupsCompleteness provides a mock function with given fields: ctx
+func (_m *InventoryApp) GetGroupsCompleteness(ctx context.Context) ([]model.GroupCompleteness, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []model.GroupCompleteness
+	if rf, ok := ret.Get(0).(func(context.Context) []model.GroupCompleteness); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.GroupCompleteness)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetLimits provides a mock function with given fields: ctx
+func (_m *InventoryApp) GetLimits(ctx context.Context) (model.Limits, error) {
+	ret := _m.Called(ctx)
+
+	var r0 model.Limits
+	if rf, ok := ret.Get(0).(func(context.Context) model.Limits); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(model.Limits)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetPinnedAttributes provides a mock function with given fields: ctx
+func (_m *InventoryApp) GetPinnedAttributes(ctx context.Context) (model.PinnedAttributes, error) {
+	ret := _m.Called(ctx)
+
+	var r0 model.PinnedAttributes
+	if rf, ok := ret.Get(0).(func(context.Context) model.PinnedAttributes); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(model.PinnedAttributes)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSavedFilter provides a mock function with given fields: ctx, id
+func (_m *InventoryApp) GetSavedFilter(ctx context.Context, id string) (*model.SavedFilter, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *model.SavedFilter
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.SavedFilter); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.SavedFilter)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetValidationWebhook provides a mock function with given fields: ctx
+func (_m *InventoryApp) GetValidationWebhook(ctx context.Context) (*model.ValidationWebhook, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *model.ValidationWebhook
+	if rf, ok := ret.Get(0).(func(context.Context) *model.ValidationWebhook); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ValidationWebhook)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// HealthCheck provides a mock function with given fields: ctx
+func (_m *InventoryApp) HealthCheck(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ImportConfigBundle provides a mock function with given fields: ctx, bundle
+func (_m *InventoryApp) ImportConfigBundle(ctx context.Context, bundle model.ConfigBundle) (*model.UpdateResult, error) {
+	ret := _m.Called(ctx, bundle)
+
+	var r0 *model.UpdateResult
+	if rf, ok := ret.Get(0).(func(context.Context, model.ConfigBundle) *model.UpdateResult); ok {
+		r0 = rf(ctx, bundle)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UpdateResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.ConfigBundle) error); ok {
+		r1 = rf(ctx, bundle)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ImportTags provides a mock function with given fields: ctx, identity, rows
+func (_m *InventoryApp) ImportTags(ctx context.Context, identity string, rows []model.TagsImportRow) (*model.TagsImportResult, error) {
+	ret := _m.Called(ctx, identity, rows)
+
+	var r0 *model.TagsImportResult
+	if rf, ok := ret.Get(0).(func(context.Context, string, []model.TagsImportRow) *model.TagsImportResult); ok {
+		r0 = rf(ctx, identity, rows)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.TagsImportResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, []model.TagsImportRow) error); ok {
+		r1 = rf(ctx, identity, rows)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IngestTimelineEvents provides a mock function with given fields: ctx, events
+func (_m *InventoryApp) IngestTimelineEvents(ctx context.Context, events []model.TimelineEvent) (*model.TimelineIngestResult, error) {
+	ret := _m.Called(ctx, events)
+
+	var r0 *model.TimelineIngestResult
+	if rf, ok := ret.Get(0).(func(context.Context, []model.TimelineEvent) *model.TimelineIngestResult); ok {
+		r0 = rf(ctx, events)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.TimelineIngestResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []model.TimelineEvent) error); ok {
+		r1 = rf(ctx, events)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListAttributeDefinitions provides a mock function with given fields: ctx
+func (_m *InventoryApp) ListAttributeDefinitions(ctx context.Context) ([]model.AttributeDefinition, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []model.AttributeDefinition
+	if rf, ok := ret.Get(0).(func(context.Context) []model.AttributeDefinition); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.AttributeDefinition)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListDeadLetters provides a mock function with given fields: ctx, skip, limit
+func (_m *InventoryApp) ListDeadLetters(ctx context.Context, skip int, limit int) ([]model.DeadLetter, int, error) {
+	ret := _m.Called(ctx, skip, limit)
+
+	var r0 []model.DeadLetter
+	if rf, ok := ret.Get(0).(func(context.Context, int, int) []model.DeadLetter); ok {
+		r0 = rf(ctx, skip, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.DeadLetter)
+		}
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, int, int) int); ok {
+		r1 = rf(ctx, skip, limit)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, int, int) error); ok {
+		r2 = rf(ctx, skip, limit)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ListDeviceChanges provides a mock function with given fields: ctx, token, limit
+func (_m *InventoryApp) ListDeviceChanges(ctx context.Context, token string, limit int) (*model.DeviceChangesPage, error) {
+	ret := _m.Called(ctx, token, limit)
+
+	var r0 *model.DeviceChangesPage
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) *model.DeviceChangesPage); ok {
+		r0 = rf(ctx, token, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.DeviceChangesPage)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, int) error); ok {
+		r1 = rf(ctx, token, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListDeviceChildren provides a mock function with given fields: ctx, id, skip, limit
+func (_m *InventoryApp) ListDeviceChildren(ctx context.Context, id model.DeviceID, skip int, limit int) ([]model.Device, int, error) {
+	ret := _m.Called(ctx, id, skip, limit)
+
+	var r0 []model.Device
+	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceID, int, int) []model.Device); ok {
+		r0 = rf(ctx, id, skip, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Device)
+		}
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, model.DeviceID, int, int) int); ok {
+		r1 = rf(ctx, id, skip, limit)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, model.DeviceID, int, int) error); ok {
+		r2 = rf(ctx, id, skip, limit)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ListDevices provides a mock function with given fields: ctx, q
+func (_m *InventoryApp) ListDevices(ctx context.Context, q store.ListQuery) ([]model.Device, int, error) {
+	ret := _m.Called(ctx, q)
+
+	var r0 []model.Device
+	if rf, ok := ret.Get(0).(func(context.Context, store.ListQuery) []model.Device); ok {
+		r0 = rf(ctx, q)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Device)
+		}
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, store.ListQuery) int); ok {
+		r1 = rf(ctx, q)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, store.ListQuery) error); ok {
+		r2 = rf(ctx, q)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ListDevicesByGroup provides a mock function with given fields: ctx, group, q
+func (_m *InventoryApp) ListDevicesByGroup(ctx context.Context, group model.GroupName, q store.ListQuery) ([]model.DeviceID, int, error) {
+	ret := _m.Called(ctx, group, q)
+
+	var r0 []model.DeviceID
+	if rf, ok := ret.Get(0).(func(context.Context, model.GroupName, store.ListQuery) []model.DeviceID); ok {
+		r0 = rf(ctx, group, q)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.DeviceID)
+		}
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, model.GroupName, store.ListQuery) int); ok {
+		r1 = rf(ctx, group, q)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, model.GroupName, store.ListQuery) error); ok {
+		r2 = rf(ctx, group, q)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ListDevicesByGroupFull provides a mock function with given fields: ctx, group, q
+func (_m *InventoryApp) ListDevicesByGroupFull(ctx context.Context, group model.GroupName, q store.ListQuery) ([]model.Device, int, error) {
+	ret := _m.Called(ctx, group, q)
+
+	var r0 []model.Device
+	if rf, ok := ret.Get(0).(func(context.Context, model.GroupName, store.ListQuery) []model.Device); ok {
+		r0 = rf(ctx, group, q)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Device)
+		}
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, model.GroupName, store.ListQuery) int); ok {
+		r1 = rf(ctx, group, q)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, model.GroupName, store.ListQuery) error); ok {
+		r2 = rf(ctx, group, q)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ListDynamicGroups provides a mock function with given fields: ctx
+func (_m *InventoryApp) ListDynamicGroups(ctx context.Context) ([]model.DynamicGroup, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []model.DynamicGroup
+	if rf, ok := ret.Get(0).(func(context.Context) []model.DynamicGroup); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.DynamicGroup)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListGroupCounts provides a mock function with given fields: ctx, filters
+func (_m *InventoryApp) ListGroupCounts(ctx context.Context, filters []model.FilterPredicate) ([]model.GroupCount, error) {
+	ret := _m.Called(ctx, filters)
+
+	var r0 []model.GroupCount
+	if rf, ok := ret.Get(0).(func(context.Context, []model.FilterPredicate) []model.GroupCount); ok {
+		r0 = rf(ctx, filters)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.GroupCount)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []model.FilterPredicate) error); ok {
+		r1 = rf(ctx, filters)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListGroups provides a mock function with given fields: ctx, filters
+func (_m *InventoryApp) ListGroups(ctx context.Context, filters []model.FilterPredicate) ([]model.GroupName, error) {
+	ret := _m.Called(ctx, filters)
+
+	var r0 []model.GroupName
+	if rf, ok := ret.Get(0).(func(context.Context, []model.FilterPredicate) []model.GroupName); ok {
+		r0 = rf(ctx, filters)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.GroupName)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []model.FilterPredicate) error); ok {
+		r1 = rf(ctx, filters)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListGroupsMetadata provides a mock function with given fields: ctx
+func (_m *InventoryApp) ListGroupsMetadata(ctx context.Context) ([]model.GroupMetadata, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []model.GroupMetadata
+	if rf, ok := ret.Get(0).(func(context.Context) []model.GroupMetadata); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.GroupMetadata)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListIncompleteDevices provides a mock function with given fields: ctx, skip, limit
+func (_m *InventoryApp) ListIncompleteDevices(ctx context.Context, skip int, limit int) ([]model.DeviceCompleteness, int, error) {
+	ret := _m.Called(ctx, skip, limit)
+
+	var r0 []model.DeviceCompleteness
+	if rf, ok := ret.Get(0).(func(context.Context, int, int) []model.DeviceCompleteness); ok {
+		r0 = rf(ctx, skip, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.DeviceCompleteness)
+		}
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, int, int) int); ok {
+		r1 = rf(ctx, skip, limit)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, int, int) error); ok {
+		r2 = rf(ctx, skip, limit)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ListSavedFilters provides a mock function with given fields: ctx, shared
+func (_m *InventoryApp) ListSavedFilters(ctx context.Context, shared bool) ([]model.SavedFilter, error) {
+	ret := _m.Called(ctx, shared)
+
+	var r0 []model.SavedFilter
+	if rf, ok := ret.Get(0).(func(context.Context, bool) []model.SavedFilter); ok {
+		r0 = rf(ctx, shared)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.SavedFilter)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, bool) error); ok {
+		r1 = rf(ctx, shared)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListSchemaViolations provides a mock function with given fields: ctx, id, skip, limit
+func (_m *InventoryApp) ListSchemaViolations(ctx context.Context, id model.DeviceID, skip int, limit int) ([]model.SchemaViolation, int, error) {
+	ret := _m.Called(ctx, id, skip, limit)
+
+	var r0 []model.SchemaViolation
+	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceID, int, int) []model.SchemaViolation); ok {
+		r0 = rf(ctx, id, skip, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.SchemaViolation)
+		}
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, model.DeviceID, int, int) int); ok {
+		r1 = rf(ctx, id, skip, limit)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, model.DeviceID, int, int) error); ok {
+		r2 = rf(ctx, id, skip, limit)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ListScopes provides a mock function with given fields: ctx
+func (_m *InventoryApp) ListScopes(ctx context.Context) ([]model.Scope, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []model.Scope
+	if rf, ok := ret.Get(0).(func(context.Context) []model.Scope); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Scope)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListSubscriptions provides a mock function with given fields: ctx
+func (_m *InventoryApp) ListSubscriptions(ctx context.Context) ([]model.Subscription, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []model.Subscription
+	if rf, ok := ret.Get(0).(func(context.Context) []model.Subscription); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Subscription)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListTenantDatabases provides a mock function with given fields: ctx
+func (_m *InventoryApp) ListTenantDatabases(ctx context.Context) ([]model.TenantDatabase, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []model.TenantDatabase
+	if rf, ok := ret.Get(0).(func(context.Context) []model.TenantDatabase); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.TenantDatabase)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PreviewDevicesGroup provides a mock function with given fields: ctx, assignment, group
+func (_m *InventoryApp) PreviewDevicesGroup(ctx context.Context, assignment model.GroupAssignment, group model.GroupName) (*model.GroupAssignmentPreview, error) {
+	ret := _m.Called(ctx, assignment, group)
+
+	var r0 *model.GroupAssignmentPreview
+	if rf, ok := ret.Get(0).(func(context.Context, model.GroupAssignment, model.GroupName) *model.GroupAssignmentPreview); ok {
+		r0 = rf(ctx, assignment, group)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.GroupAssignmentPreview)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.GroupAssignment, model.GroupName) error); ok {
+		r1 = rf(ctx, assignment, group)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PreviewDigest provides a mock function with given fields: ctx
+func (_m *InventoryApp) PreviewDigest(ctx context.Context) (*model.FleetDigest, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *model.FleetDigest
+	if rf, ok := ret.Get(0).(func(context.Context) *model.FleetDigest); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.FleetDigest)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PreviewGroup provides a mock function with given fields: ctx, preview
+func (_m *InventoryApp) PreviewGroup(ctx context.Context, preview model.GroupPreview) (*model.GroupPreviewResult, error) {
+	ret := _m.Called(ctx, preview)
+
+	var r0 *model.GroupPreviewResult
+	if rf, ok := ret.Get(0).(func(context.Context, model.GroupPreview) *model.GroupPreviewResult); ok {
+		r0 = rf(ctx, preview)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.GroupPreviewResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.GroupPreview) error); ok {
+		r1 = rf(ctx, preview)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PurgeDeadLetters provides a mock function with given fields: ctx
+func (_m *InventoryApp) PurgeDeadLetters(ctx context.Context) (*model.UpdateResult, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *model.UpdateResult
+	if rf, ok := ret.Get(0).(func(context.Context) *model.UpdateResult); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UpdateResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReconcileDevices provides a mock function with given fields: ctx, rec
+func (_m *InventoryApp) ReconcileDevices(ctx context.Context, rec model.Reconciliation) (*model.ReconciliationReport, error) {
+	ret := _m.Called(ctx, rec)
+
+	var r0 *model.ReconciliationReport
+	if rf, ok := ret.Get(0).(func(context.Context, model.Reconciliation) *model.ReconciliationReport); ok {
+		r0 = rf(ctx, rec)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ReconciliationReport)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.Reconciliation) error); ok {
+		r1 = rf(ctx, rec)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReplaceAttributeDefinition provides a mock function with given fields: ctx, def
+func (_m *InventoryApp) ReplaceAttributeDefinition(ctx context.Context, def model.AttributeDefinition) (*model.AttributeDefinition, error) {
+	ret := _m.Called(ctx, def)
+
+	var r0 *model.AttributeDefinition
+	if rf, ok := ret.Get(0).(func(context.Context, model.AttributeDefinition) *model.AttributeDefinition); ok {
+		r0 = rf(ctx, def)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.AttributeDefinition)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.AttributeDefinition) error); ok {
+		r1 = rf(ctx, def)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReplaceAttributes provides a mock function with given fields: ctx, id, upsertAttrs, scope
+func (_m *InventoryApp) ReplaceAttributes(ctx context.Context, id model.DeviceID, upsertAttrs model.DeviceAttributes, scope string) error {
+	ret := _m.Called(ctx, id, upsertAttrs, scope)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceID, model.DeviceAttributes, string) error); ok {
+		r0 = rf(ctx, id, upsertAttrs, scope)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ReplaceDynamicGroup provides a mock function with given fields: ctx, group
+func (_m *InventoryApp) ReplaceDynamicGroup(ctx context.Context, group model.DynamicGroup) (*model.DynamicGroup, error) {
+	ret := _m.Called(ctx, group)
+
+	var r0 *model.DynamicGroup
+	if rf, ok := ret.Get(0).(func(context.Context, model.DynamicGroup) *model.DynamicGroup); ok {
+		r0 = rf(ctx, group)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.DynamicGroup)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.DynamicGroup) error); ok {
+		r1 = rf(ctx, group)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReplaceGroup provides a mock function with given fields: ctx, group
+func (_m *InventoryApp) ReplaceGroup(ctx context.Context, group model.GroupDefinition) (*model.GroupDefinition, error) {
+	ret := _m.Called(ctx, group)
+
+	var r0 *model.GroupDefinition
+	if rf, ok := ret.Get(0).(func(context.Context, model.GroupDefinition) *model.GroupDefinition); ok {
+		r0 = rf(ctx, group)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.GroupDefinition)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.GroupDefinition) error); ok {
+		r1 = rf(ctx, group)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReplaceGroupMetadata provides a mock function with given fields: ctx, group
+func (_m *InventoryApp) ReplaceGroupMetadata(ctx context.Context, group model.GroupMetadata) (*model.GroupMetadata, error) {
+	ret := _m.Called(ctx, group)
+
+	var r0 *model.GroupMetadata
+	if rf, ok := ret.Get(0).(func(context.Context, model.GroupMetadata) *model.GroupMetadata); ok {
+		r0 = rf(ctx, group)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.GroupMetadata)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.GroupMetadata) error); ok {
+		r1 = rf(ctx, group)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReplaceSavedFilter provides a mock function with given fields: ctx, filter
+func (_m *InventoryApp) ReplaceSavedFilter(ctx context.Context, filter model.SavedFilter) (*model.SavedFilter, error) {
+	ret := _m.Called(ctx, filter)
+
+	var r0 *model.SavedFilter
+	if rf, ok := ret.Get(0).(func(context.Context, model.SavedFilter) *model.SavedFilter); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.SavedFilter)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.SavedFilter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReplaceScope provides a mock function with given fields: ctx, scope
+func (_m *InventoryApp) ReplaceScope(ctx context.Context, scope model.Scope) (*model.Scope, error) {
+	ret := _m.Called(ctx, scope)
+
+	var r0 *model.Scope
+	if rf, ok := ret.Get(0).(func(context.Context, model.Scope) *model.Scope); ok {
+		r0 = rf(ctx, scope)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Scope)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.Scope) error); ok {
+		r1 = rf(ctx, scope)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReplaceSubscription provides a mock function with given fields: ctx, sub
+func (_m *InventoryApp) ReplaceSubscription(ctx context.Context, sub model.Subscription) (*model.Subscription, error) {
+	ret := _m.Called(ctx, sub)
+
+	var r0 *model.Subscription
+	if rf, ok := ret.Get(0).(func(context.Context, model.Subscription) *model.Subscription); ok {
+		r0 = rf(ctx, sub)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Subscription)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.Subscription) error); ok {
+		r1 = rf(ctx, sub)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReplayDeadLetter provides a mock function with given fields: ctx, id
+func (_m *InventoryApp) ReplayDeadLetter(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ReplayDeadLetters provides a mock function with given fields: ctx
+func (_m *InventoryApp) ReplayDeadLetters(ctx context.Context) (*model.DeadLettersReplay, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *model.DeadLettersReplay
+	if rf, ok := ret.Get(0).(func(context.Context) *model.DeadLettersReplay); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.DeadLettersReplay)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ResolveExternalID provides a mock function with given fields: ctx, ref
+func (_m *InventoryApp) ResolveExternalID(ctx context.Context, ref model.ExternalIDRef) (model.DeviceID, error) {
+	ret := _m.Called(ctx, ref)
+
+	var r0 model.DeviceID
+	if rf, ok := ret.Get(0).(func(context.Context, model.ExternalIDRef) model.DeviceID); ok {
+		r0 = rf(ctx, ref)
+	} else {
+		r0 = ret.Get(0).(model.DeviceID)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.ExternalIDRef) error); ok {
+		r1 = rf(ctx, ref)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SearchDevices provides a mock function with given fields: ctx, searchParams
+func (_m *InventoryApp) SearchDevices(ctx context.Context, searchParams model.SearchParams) ([]model.Device, int, error) {
+	ret := _m.Called(ctx, searchParams)
+
+	var r0 []model.Device
+	if rf, ok := ret.Get(0).(func(context.Context, model.SearchParams) []model.Device); ok {
+		r0 = rf(ctx, searchParams)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Device)
+		}
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, model.SearchParams) int); ok {
+		r1 = rf(ctx, searchParams)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, model.SearchParams) error); ok {
+		r2 = rf(ctx, searchParams)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// SearchGroups provides a mock function with given fields: ctx, q
+func (_m *InventoryApp) SearchGroups(ctx context.Context, q store.GroupsQuery) ([]model.GroupName, int, error) {
+	ret := _m.Called(ctx, q)
+
+	var r0 []model.GroupName
+	if rf, ok := ret.Get(0).(func(context.Context, store.GroupsQuery) []model.GroupName); ok {
+		r0 = rf(ctx, q)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.GroupName)
+		}
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, store.GroupsQuery) int); ok {
+		r1 = rf(ctx, q)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, store.GroupsQuery) error); ok {
+		r2 = rf(ctx, q)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// SendDigests provides a mock function with given fields: ctx
+func (_m *InventoryApp) SendDigests(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetAttributeAliases provides a mock function with given fields: ctx, aliases
+func (_m *InventoryApp) SetAttributeAliases(ctx context.Context, aliases model.AttributeAliases) error {
+	ret := _m.Called(ctx, aliases)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.AttributeAliases) error); ok {
+		r0 = rf(ctx, aliases)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetAttributeDescriptions provides a mock function with given fields: ctx, descs
+func (_m *InventoryApp) SetAttributeDescriptions(ctx context.Context, descs model.AttributeDescriptions) error {
+	ret := _m.Called(ctx, descs)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.AttributeDescriptions) error); ok {
+		r0 = rf(ctx, descs)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetCompletenessAlert provides a mock function with given fields: ctx, alert
+func (_m *InventoryApp) SetCompletenessAlert(ctx context.Context, alert model.CompletenessAlert) error {
+	ret := _m.Called(ctx, alert)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.CompletenessAlert) error); ok {
+		r0 = rf(ctx, alert)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetDigestSettings provides a mock function with given fields: ctx, settings
+func (_m *InventoryApp) SetDigestSettings(ctx context.Context, settings model.DigestSettings) error {
+	ret := _m.Called(ctx, settings)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.DigestSettings) error); ok {
+		r0 = rf(ctx, settings)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetLimits provides a mock function with given fields: ctx, limits
+func (_m *InventoryApp) SetLimits(ctx context.Context, limits model.Limits) error {
+	ret := _m.Called(ctx, limits)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.Limits) error); ok {
+		r0 = rf(ctx, limits)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetPinnedAttributes provides a mock function with given fields: ctx, pinned
+func (_m *InventoryApp) SetPinnedAttributes(ctx context.Context, pinned model.PinnedAttributes) error {
+	ret := _m.Called(ctx, pinned)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.PinnedAttributes) error); ok {
+		r0 = rf(ctx, pinned)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetValidationWebhook provides a mock function with given fields: ctx, hook
+func (_m *InventoryApp) SetValidationWebhook(ctx context.Context, hook model.ValidationWebhook) error {
+	ret := _m.Called(ctx, hook)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.ValidationWebhook) error); ok {
+		r0 = rf(ctx, hook)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// StartExport provides a mock function with given fields: ctx, req
+func (_m *InventoryApp) StartExport(ctx context.Context, req model.ExportRequest) (*model.ExportJob, error) {
+	ret := _m.Called(ctx, req)
+
+	var r0 *model.ExportJob
+	if rf, ok := ret.Get(0).(func(context.Context, model.ExportRequest) *model.ExportJob); ok {
+		r0 = rf(ctx, req)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ExportJob)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.ExportRequest) error); ok {
+		r1 = rf(ctx, req)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// StreamDevices provides a mock function with given fields: ctx, q
+func (_m *InventoryApp) StreamDevices(ctx context.Context, q store.ListQuery) (*store.DeviceStream, int, error) {
+	ret := _m.Called(ctx, q)
+
+	var r0 *store.DeviceStream
+	if rf, ok := ret.Get(0).(func(context.Context, store.ListQuery) *store.DeviceStream); ok {
+		r0 = rf(ctx, q)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*store.DeviceStream)
+		}
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, store.ListQuery) int); ok {
+		r1 = rf(ctx, q)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, store.ListQuery) error); ok {
+		r2 = rf(ctx, q)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// SweepExpiredAttributes provides a mock function with given fields: ctx
+func (_m *InventoryApp) SweepExpiredAttributes(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UnsetDeviceGroup provides a mock function with given fields: ctx, id, groupName
+func (_m *InventoryApp) UnsetDeviceGroup(ctx context.Context, id model.DeviceID, groupName model.GroupName) error {
+	ret := _m.Called(ctx, id, groupName)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceID, model.GroupName) error); ok {
+		r0 = rf(ctx, id, groupName)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UnsetDevicesGroup provides a mock function with given fields: ctx, deviceIDs, groupName
+func (_m *InventoryApp) UnsetDevicesGroup(ctx context.Context, deviceIDs []model.DeviceID, groupName model.GroupName) (*model.UpdateResult, error) {
+	ret := _m.Called(ctx, deviceIDs, groupName)
+
+	var r0 *model.UpdateResult
+	if rf, ok := ret.Get(0).(func(context.Context, []model.DeviceID, model.GroupName) *model.UpdateResult); ok {
+		r0 = rf(ctx, deviceIDs, groupName)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UpdateResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []model.DeviceID, model.GroupName) error); ok {
+		r1 = rf(ctx, deviceIDs, groupName)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateAttributeDescriptions provides a mock function with given fields: ctx, descs
+func (_m *InventoryApp) UpdateAttributeDescriptions(ctx context.Context, descs model.AttributeDescriptions) error {
+	ret := _m.Called(ctx, descs)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.AttributeDescriptions) error); ok {
+		r0 = rf(ctx, descs)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateDeviceGroup provides a mock function with given fields: ctx, id, group
+func (_m *InventoryApp) UpdateDeviceGroup(ctx context.Context, id model.DeviceID, group model.GroupName) error {
+	ret := _m.Called(ctx, id, group)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceID, model.GroupName) error); ok {
+		r0 = rf(ctx, id, group)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateDevicesGroup provides a mock function with given fields: ctx, ids, group
+func (_m *InventoryApp) UpdateDevicesGroup(ctx context.Context, ids []model.DeviceID, group model.GroupName) (*model.UpdateResult, error) {
+	ret := _m.Called(ctx, ids, group)
+
+	var r0 *model.UpdateResult
+	if rf, ok := ret.Get(0).(func(context.Context, []model.DeviceID, model.GroupName) *model.UpdateResult); ok {
+		r0 = rf(ctx, ids, group)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UpdateResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []model.DeviceID, model.GroupName) error); ok {
+		r1 = rf(ctx, ids, group)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateDevicesGroupByFilter provides a mock function with given fields: ctx, params, group
+func (_m *InventoryApp) UpdateDevicesGroupByFilter(ctx context.Context, params model.SearchParams, group model.GroupName) (*model.UpdateResult, error) {
+	ret := _m.Called(ctx, params, group)
+
+	var r0 *model.UpdateResult
+	if rf, ok := ret.Get(0).(func(context.Context, model.SearchParams, model.GroupName) *model.UpdateResult); ok {
+		r0 = rf(ctx, params, group)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UpdateResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.SearchParams, model.GroupName) error); ok {
+		r1 = rf(ctx, params, group)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateFeatureFlags provides a mock function with given fields: ctx, update
+func (_m *InventoryApp) UpdateFeatureFlags(ctx context.Context, update model.FeatureFlagsUpdate) (model.FeatureFlagSet, error) {
+	ret := _m.Called(ctx, update)
+
+	var r0 model.FeatureFlagSet
+	if rf, ok := ret.Get(0).(func(context.Context, model.FeatureFlagsUpdate) model.FeatureFlagSet); ok {
+		r0 = rf(ctx, update)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(model.FeatureFlagSet)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.FeatureFlagsUpdate) error); ok {
+		r1 = rf(ctx, update)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpsertAttributes provides a mock function with given fields: ctx, id, attrs
+func (_m *InventoryApp) UpsertAttributes(ctx context.Context, id model.DeviceID, attrs model.DeviceAttributes) error {
+	ret := _m.Called(ctx, id, attrs)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceID, model.DeviceAttributes) error); ok {
+		r0 = rf(ctx, id, attrs)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpsertAttributesWithUpdated provides a mock function with given fields: ctx, id, attrs
+func (_m *InventoryApp) UpsertAttributesWithUpdated(ctx context.Context, id model.DeviceID, attrs model.DeviceAttributes) error {
+	ret := _m.Called(ctx, id, attrs)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceID, model.DeviceAttributes) error); ok {
+		r0 = rf(ctx, id, attrs)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpsertDevicesStatuses provides a mock function with given fields: ctx, devices, attrs
+func (_m *InventoryApp) UpsertDevicesStatuses(ctx context.Context, devices []model.DeviceUpdate, attrs model.DeviceAttributes) (*model.UpdateResult, error) {
+	ret := _m.Called(ctx, devices, attrs)
+
+	var r0 *model.UpdateResult
+	if rf, ok := ret.Get(0).(func(context.Context, []model.DeviceUpdate, model.DeviceAttributes) *model.UpdateResult); ok {
+		r0 = rf(ctx, devices, attrs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UpdateResult)
+		}
 	}
 
 	var r1 error
@@ -428,3 +2434,253 @@ func (_m *InventoryApp) UpsertDevicesStatuses(ctx context.Context, devices []mod
 
 	return r0, r1
 }
+
+// UpsertExternalIDs provides a mock function with given fields: ctx, ids
+func (_m *InventoryApp) UpsertExternalIDs(ctx context.Context, ids []model.ExternalID) (*model.UpdateResult, error) {
+	ret := _m.Called(ctx, ids)
+
+	var r0 *model.UpdateResult
+	if rf, ok := ret.Get(0).(func(context.Context, []model.ExternalID) *model.UpdateResult); ok {
+		r0 = rf(ctx, ids)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UpdateResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []model.ExternalID) error); ok {
+		r1 = rf(ctx, ids)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ValidateFilter provides a mock function with given fields: ctx, req
+func (_m *InventoryApp) ValidateFilter(ctx context.Context, req model.FilterValidationRequest) (*model.FilterValidation, error) {
+	ret := _m.Called(ctx, req)
+
+	var r0 *model.FilterValidation
+	if rf, ok := ret.Get(0).(func(context.Context, model.FilterValidationRequest) *model.FilterValidation); ok {
+		r0 = rf(ctx, req)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.FilterValidation)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.FilterValidationRequest) error); ok {
+		r1 = rf(ctx, req)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// WarmUpCatalog provides a mock function with given fields: ctx
+func (_m *InventoryApp) WarmUpCatalog(ctx context.Context) (*model.Catalog, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *model.Catalog
+	if rf, ok := ret.Get(0).(func(context.Context) *model.Catalog); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Catalog)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// WatchGroupCounts provides a mock function with given fields: ctx
+func (_m *InventoryApp) WatchGroupCounts(ctx context.Context) (<-chan []model.GroupCount, error) {
+	ret := _m.Called(ctx)
+
+	var r0 <-chan []model.GroupCount
+	if rf, ok := ret.Get(0).(func(context.Context) <-chan []model.GroupCount); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan []model.GroupCount)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// WatchSubscriptions provides a mock function with given fields: ctx
+func (_m *InventoryApp) WatchSubscriptions(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// WithAttributesValidator provides a mock function with given fields: v
+func (_m *InventoryApp) WithAttributesValidator(v validator.Validator) inv.InventoryApp {
+	ret := _m.Called(v)
+
+	var r0 inv.InventoryApp
+	if rf, ok := ret.Get(0).(func(validator.Validator) inv.InventoryApp); ok {
+		r0 = rf(v)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(inv.InventoryApp)
+		}
+	}
+
+	return r0
+}
+
+// WithBlobStore provides a mock function with given fields: blobs
+func (_m *InventoryApp) WithBlobStore(blobs blob.Store) inv.InventoryApp {
+	ret := _m.Called(blobs)
+
+	var r0 inv.InventoryApp
+	if rf, ok := ret.Get(0).(func(blob.Store) inv.InventoryApp); ok {
+		r0 = rf(blobs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(inv.InventoryApp)
+		}
+	}
+
+	return r0
+}
+
+// WithDiffAttributes provides a mock function with given fields: attributes
+func (_m *InventoryApp) WithDiffAttributes(attributes map[string][]string) inv.InventoryApp {
+	ret := _m.Called(attributes)
+
+	var r0 inv.InventoryApp
+	if rf, ok := ret.Get(0).(func(map[string][]string) inv.InventoryApp); ok {
+		r0 = rf(attributes)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(inv.InventoryApp)
+		}
+	}
+
+	return r0
+}
+
+// WithEventEmitter provides a mock function with given fields: emitter
+func (_m *InventoryApp) WithEventEmitter(emitter events.Emitter) inv.InventoryApp {
+	ret := _m.Called(emitter)
+
+	var r0 inv.InventoryApp
+	if rf, ok := ret.Get(0).(func(events.Emitter) inv.InventoryApp); ok {
+		r0 = rf(emitter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(inv.InventoryApp)
+		}
+	}
+
+	return r0
+}
+
+// WithFeatureFlags provides a mock function with given fields: defaults
+func (_m *InventoryApp) WithFeatureFlags(defaults model.FeatureFlagSet) inv.InventoryApp {
+	ret := _m.Called(defaults)
+
+	var r0 inv.InventoryApp
+	if rf, ok := ret.Get(0).(func(model.FeatureFlagSet) inv.InventoryApp); ok {
+		r0 = rf(defaults)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(inv.InventoryApp)
+		}
+	}
+
+	return r0
+}
+
+// WithNotifier provides a mock function with given fields: notifier
+func (_m *InventoryApp) WithNotifier(notifier events.Notifier) inv.InventoryApp {
+	ret := _m.Called(notifier)
+
+	var r0 inv.InventoryApp
+	if rf, ok := ret.Get(0).(func(events.Notifier) inv.InventoryApp); ok {
+		r0 = rf(notifier)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(inv.InventoryApp)
+		}
+	}
+
+	return r0
+}
+
+// WithRemoteWrite provides a mock function with given fields: w, attributes
+func (_m *InventoryApp) WithRemoteWrite(w remotewrite.Writer, attributes map[string][]string) inv.InventoryApp {
+	ret := _m.Called(w, attributes)
+
+	var r0 inv.InventoryApp
+	if rf, ok := ret.Get(0).(func(remotewrite.Writer, map[string][]string) inv.InventoryApp); ok {
+		r0 = rf(w, attributes)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(inv.InventoryApp)
+		}
+	}
+
+	return r0
+}
+
+// WithSoftLimits provides a mock function with given fields: limits
+func (_m *InventoryApp) WithSoftLimits(limits model.Limits) inv.InventoryApp {
+	ret := _m.Called(limits)
+
+	var r0 inv.InventoryApp
+	if rf, ok := ret.Get(0).(func(model.Limits) inv.InventoryApp); ok {
+		r0 = rf(limits)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(inv.InventoryApp)
+		}
+	}
+
+	return r0
+}
+
+// WithTimelineConcurrency provides a mock function with given fields: n
+func (_m *InventoryApp) WithTimelineConcurrency(n int) inv.InventoryApp {
+	ret := _m.Called(n)
+
+	var r0 inv.InventoryApp
+	if rf, ok := ret.Get(0).(func(int) inv.InventoryApp); ok {
+		r0 = rf(n)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(inv.InventoryApp)
+		}
+	}
+
+	return r0
+}
@@ -0,0 +1,50 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+// pinnedAttributes returns the attributes pinned by the tenant in the
+// context; if they cannot be retrieved, the devices are returned without
+// highlights.
+func (i *inventory) pinnedAttributes(ctx context.Context) model.PinnedAttributes {
+	pinned, err := i.db.GetPinnedAttributes(ctx)
+	if err != nil {
+		log.FromContext(ctx).Warnf("skipping the highlights: %v", err)
+	}
+	return pinned
+}
+
+func (i *inventory) GetPinnedAttributes(ctx context.Context) (model.PinnedAttributes, error) {
+	pinned, err := i.db.GetPinnedAttributes(ctx)
+	if err != nil {
+		return pinned, errors.Wrap(err, "failed to get pinned attributes")
+	}
+	return pinned, nil
+}
+
+func (i *inventory) SetPinnedAttributes(ctx context.Context, pinned model.PinnedAttributes) error {
+	if err := i.db.SetPinnedAttributes(ctx, pinned); err != nil {
+		return errors.Wrap(err, "failed to set pinned attributes")
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/inventory/model"
+	mstore "github.com/mendersoftware/inventory/store/mocks"
+)
+
+func TestInventorySearchDevicesHighlights(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	os := model.DeviceAttribute{
+		Scope: model.AttrScopeInventory, Name: "os", Value: "linux",
+	}
+	mac := model.DeviceAttribute{
+		Scope: model.AttrScopeIdentity, Name: "mac", Value: "00:00",
+	}
+	selected := []model.SelectAttribute{
+		{Scope: model.AttrScopeIdentity, Attribute: "mac"},
+	}
+	pinned := model.PinnedAttributes{Attributes: []model.SelectAttribute{
+		{Scope: model.AttrScopeInventory, Attribute: "os"},
+	}}
+
+	db := &mstore.DataStore{}
+	db.On("GetPinnedAttributes", ctx).Return(pinned, nil).Once()
+	db.On("GetAttributeDescriptions", ctx).
+		Return(model.AttributeDescriptions{}, nil)
+	// the pinned attributes are fetched along with the selected ones
+	db.On("SearchDevices", ctx, model.SearchParams{
+		Attributes: append(selected, pinned.Attributes...),
+	}).Return([]model.Device{
+		{ID: "1", Attributes: model.DeviceAttributes{os, mac}},
+	}, 1, nil).Once()
+	i := invForTest(db)
+
+	devs, totalCount, err := i.SearchDevices(ctx, model.SearchParams{
+		Attributes: selected,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, totalCount)
+	assert.Equal(t, []model.Device{{
+		ID:         "1",
+		Attributes: model.DeviceAttributes{mac},
+		Highlights: model.DeviceAttributes{os},
+	}}, devs)
+
+	// the devices are returned without highlights if the pinned
+	// attributes cannot be fetched
+	db.On("GetPinnedAttributes", ctx).
+		Return(model.PinnedAttributes{}, errors.New("db error")).Once()
+	db.On("SearchDevices", ctx, model.SearchParams{Attributes: selected}).
+		Return([]model.Device{
+			{ID: "1", Attributes: model.DeviceAttributes{mac}},
+		}, 1, nil).Once()
+
+	devs, _, err = i.SearchDevices(ctx, model.SearchParams{
+		Attributes: selected,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.Device{
+		{ID: "1", Attributes: model.DeviceAttributes{mac}},
+	}, devs)
+	db.AssertExpectations(t)
+}
+
+func TestInventoryPinnedAttributes(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	pinned := model.PinnedAttributes{Attributes: []model.SelectAttribute{
+		{Scope: model.AttrScopeInventory, Attribute: "os"},
+	}}
+
+	db := &mstore.DataStore{}
+	db.On("GetPinnedAttributes", ctx).Return(pinned, nil).Once()
+	db.On("GetPinnedAttributes", ctx).
+		Return(model.PinnedAttributes{}, errors.New("db error")).Once()
+	db.On("SetPinnedAttributes", ctx, pinned).Return(nil).Once()
+	db.On("SetPinnedAttributes", ctx, pinned).
+		Return(errors.New("db error")).Once()
+	i := invForTest(db)
+
+	res, err := i.GetPinnedAttributes(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, pinned, res)
+	_, err = i.GetPinnedAttributes(ctx)
+	assert.EqualError(t, err, "failed to get pinned attributes: db error")
+
+	assert.NoError(t, i.SetPinnedAttributes(ctx, pinned))
+	assert.EqualError(t, i.SetPinnedAttributes(ctx, pinned),
+		"failed to set pinned attributes: db error")
+	db.AssertExpectations(t)
+}
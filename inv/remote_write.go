@@ -0,0 +1,123 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+
+	"github.com/mendersoftware/inventory/metrics"
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/remotewrite"
+	"github.com/mendersoftware/inventory/utils/reqctx"
+)
+
+var invalidMetricNameChars = regexp.MustCompile("[^a-zA-Z0-9_:]")
+
+var remoteWriteFailures = metrics.NewCounterVec(
+	"inventory_remote_write_failures_total",
+	"Number of failed deliveries of attribute samples to remote write.",
+	"tenant",
+)
+
+// WithRemoteWrite forwards the numeric values of the given attributes,
+// listed by scope, to the remote-write endpoint whenever they are
+// reported; without it no attributes are forwarded.
+func (i *inventory) WithRemoteWrite(
+	w remotewrite.Writer,
+	attributes map[string][]string,
+) InventoryApp {
+	i.remoteWrite = w
+	i.remoteWriteAttrs = make(map[[2]string]bool)
+	for scope, names := range attributes {
+		for _, name := range names {
+			i.remoteWriteAttrs[[2]string{scope, name}] = true
+		}
+	}
+	return i
+}
+
+// remoteWriteMetricName returns the name of the metric the attribute is
+// forwarded as.
+func remoteWriteMetricName(scope, name string) string {
+	return invalidMetricNameChars.ReplaceAllString(scope+"_"+name, "_")
+}
+
+// forwardAttributes forwards the configured numeric attributes of the
+// device as gauges labeled with the device ID and group; failures are
+// logged and do not fail the update of the attributes.
+func (i *inventory) forwardAttributes(
+	ctx context.Context,
+	id model.DeviceID,
+	attrs model.DeviceAttributes,
+) {
+	if i.remoteWrite == nil {
+		return
+	}
+	now := time.Now()
+	samples := []remotewrite.Sample{}
+	for _, attr := range attrs {
+		value, ok := attr.Value.(float64)
+		if !ok || !i.remoteWriteAttrs[[2]string{attr.Scope, attr.Name}] {
+			continue
+		}
+		samples = append(samples, remotewrite.Sample{
+			Labels: []remotewrite.Label{{
+				Name:  remotewrite.LabelName,
+				Value: remoteWriteMetricName(attr.Scope, attr.Name),
+			}, {
+				Name:  "device_id",
+				Value: string(id),
+			}},
+			Value:     value,
+			Timestamp: now,
+		})
+	}
+	if len(samples) == 0 {
+		return
+	}
+
+	l := log.FromContext(ctx)
+	info := reqctx.FromContext(ctx)
+	extra := []remotewrite.Label{}
+	if info.TenantID != "" {
+		extra = append(extra,
+			remotewrite.Label{Name: "tenant_id", Value: info.TenantID})
+	}
+	groups, err := i.db.GetDeviceGroups(ctx, id)
+	if err != nil {
+		l.Errorf("failed to get the groups of the device: %v", err)
+	} else if len(groups) > 0 {
+		names := make([]string, len(groups))
+		for n, group := range groups {
+			names[n] = string(group)
+		}
+		extra = append(extra, remotewrite.Label{
+			Name: "group", Value: strings.Join(names, ","),
+		})
+	}
+	for n := range samples {
+		samples[n].Labels = append(samples[n].Labels, extra...)
+	}
+
+	if err := i.remoteWrite.Write(ctx, samples...); err != nil {
+		l.Errorf("failed to forward attributes: %v", err)
+		remoteWriteFailures.Inc(info.TenantID)
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/remotewrite"
+	mremotewrite "github.com/mendersoftware/inventory/remotewrite/mocks"
+	mstore "github.com/mendersoftware/inventory/store/mocks"
+)
+
+func TestRemoteWriteMetricName(t *testing.T) {
+	assert.Equal(t, "inventory_cpu_temperature",
+		remoteWriteMetricName("inventory", "cpu_temperature"))
+	assert.Equal(t, "inventory_mem_free_kB",
+		remoteWriteMetricName("inventory", "mem.free-kB"))
+}
+
+func TestInventoryForwardAttributes(t *testing.T) {
+	t.Parallel()
+
+	tenantCtx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "tenant",
+	})
+	attrs := model.DeviceAttributes{
+		{Scope: model.AttrScopeInventory, Name: "cpu_temperature", Value: 51.5},
+		{Scope: model.AttrScopeInventory, Name: "uptime", Value: float64(10)},
+		{Scope: model.AttrScopeInventory, Name: "mem_free_kB", Value: "n/a"},
+	}
+	testCases := map[string]struct {
+		ctx      context.Context
+		attrs    model.DeviceAttributes
+		groups   []model.GroupName
+		groupErr error
+		writeErr error
+
+		outLabels []remotewrite.Label
+	}{
+		"ok": {
+			ctx:    tenantCtx,
+			attrs:  attrs,
+			groups: []model.GroupName{"prod"},
+			outLabels: []remotewrite.Label{
+				{Name: remotewrite.LabelName, Value: "inventory_cpu_temperature"},
+				{Name: "device_id", Value: "1"},
+				{Name: "tenant_id", Value: "tenant"},
+				{Name: "group", Value: "prod"},
+			},
+		},
+		"ok, several groups": {
+			ctx:    context.Background(),
+			attrs:  attrs,
+			groups: []model.GroupName{"prod", "eu"},
+			outLabels: []remotewrite.Label{
+				{Name: remotewrite.LabelName, Value: "inventory_cpu_temperature"},
+				{Name: "device_id", Value: "1"},
+				{Name: "group", Value: "prod,eu"},
+			},
+		},
+		"ok, no tenant nor group": {
+			ctx:   context.Background(),
+			attrs: attrs,
+			outLabels: []remotewrite.Label{
+				{Name: remotewrite.LabelName, Value: "inventory_cpu_temperature"},
+				{Name: "device_id", Value: "1"},
+			},
+		},
+		"ok, group error": {
+			ctx:      context.Background(),
+			attrs:    attrs,
+			groupErr: errors.New("db error"),
+			outLabels: []remotewrite.Label{
+				{Name: remotewrite.LabelName, Value: "inventory_cpu_temperature"},
+				{Name: "device_id", Value: "1"},
+			},
+		},
+		"ok, write error": {
+			ctx:      context.Background(),
+			attrs:    attrs,
+			writeErr: errors.New("connection refused"),
+			outLabels: []remotewrite.Label{
+				{Name: remotewrite.LabelName, Value: "inventory_cpu_temperature"},
+				{Name: "device_id", Value: "1"},
+			},
+		},
+		"ok, nothing to forward": {
+			ctx:   context.Background(),
+			attrs: attrs[1:],
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			db := &mstore.DataStore{}
+			w := &mremotewrite.Writer{}
+			if tc.outLabels != nil {
+				db.On("GetDeviceGroups", tc.ctx, model.DeviceID("1")).
+					Return(tc.groups, tc.groupErr)
+				w.On("Write", tc.ctx,
+					mock.MatchedBy(func(s remotewrite.Sample) bool {
+						return assert.Equal(t, tc.outLabels, s.Labels) &&
+							s.Value == 51.5 && !s.Timestamp.IsZero()
+					}),
+				).Return(tc.writeErr)
+			}
+
+			i := NewInventory(db).WithRemoteWrite(w, map[string][]string{
+				model.AttrScopeInventory: {"cpu_temperature", "mem_free_kB"},
+			}).(*inventory)
+			i.forwardAttributes(tc.ctx, "1", tc.attrs)
+
+			db.AssertExpectations(t)
+			w.AssertExpectations(t)
+		})
+	}
+}
+
+func TestInventoryForwardAttributesDisabled(t *testing.T) {
+	t.Parallel()
+
+	db := &mstore.DataStore{}
+	i := &inventory{db: db}
+	i.forwardAttributes(context.Background(), "1", model.DeviceAttributes{
+		{Scope: model.AttrScopeInventory, Name: "cpu_temperature", Value: 51.5},
+	})
+	db.AssertExpectations(t)
+}
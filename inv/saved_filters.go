@@ -0,0 +1,214 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/mongo/oid"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+	"github.com/mendersoftware/inventory/utils/reqctx"
+)
+
+var (
+	// ErrSavedFilterUserRequired is returned when saved filters are
+	// managed with a token other than a user token.
+	ErrSavedFilterUserRequired = errors.New("saved filters require a user token")
+	// ErrSavedFilterNotOwner is returned when a user modifies a shared
+	// filter of another user.
+	ErrSavedFilterNotOwner = errors.New("only the owner can modify the saved filter")
+	// ErrSavedFiltersLimit is returned when the user has too many saved
+	// filters.
+	ErrSavedFiltersLimit = errors.Errorf(
+		"at most %d saved filters are allowed per user",
+		model.SavedFiltersPerUserMax,
+	)
+)
+
+// filterOwner returns the ID of the user in the context.
+func filterOwner(ctx context.Context) (string, error) {
+	info := reqctx.FromContext(ctx)
+	if !info.IsUser || info.Subject == "" {
+		return "", ErrSavedFilterUserRequired
+	}
+	return info.Subject, nil
+}
+
+// ListSavedFilters returns the shared filters of the tenant, or
+// the private filters of the user in the context.
+func (i *inventory) ListSavedFilters(
+	ctx context.Context,
+	shared bool,
+) ([]model.SavedFilter, error) {
+	userID, err := filterOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	q := store.SavedFiltersQuery{Shared: &shared}
+	if !shared {
+		q.OwnerID = userID
+	}
+	filters, err := i.db.GetSavedFilters(ctx, q)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list saved filters")
+	}
+	return filters, nil
+}
+
+// GetSavedFilter returns the saved filter, if visible to the user in
+// the context; the private filters of the other users are not found.
+func (i *inventory) GetSavedFilter(ctx context.Context, id string) (*model.SavedFilter, error) {
+	userID, err := filterOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return i.getSavedFilter(ctx, userID, id)
+}
+
+func (i *inventory) getSavedFilter(
+	ctx context.Context,
+	userID, id string,
+) (*model.SavedFilter, error) {
+	filter, err := i.db.GetSavedFilter(ctx, id)
+	if err == store.ErrSavedFilterNotFound {
+		return nil, err
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to get saved filter")
+	} else if !filter.VisibleTo(userID) {
+		return nil, store.ErrSavedFilterNotFound
+	}
+	return filter, nil
+}
+
+// CreateSavedFilter saves the filter owned by the user in the context.
+func (i *inventory) CreateSavedFilter(
+	ctx context.Context,
+	filter model.SavedFilter,
+) (*model.SavedFilter, error) {
+	userID, err := filterOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	owned, err := i.db.GetSavedFilters(ctx, store.SavedFiltersQuery{
+		OwnerID: userID,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list saved filters")
+	} else if len(owned) >= model.SavedFiltersPerUserMax {
+		return nil, ErrSavedFiltersLimit
+	}
+
+	filter.ID = oid.NewUUIDv4().String()
+	filter.OwnerID = userID
+	filter.CreatedTs = time.Now()
+	filter.UpdatedTs = filter.CreatedTs
+	if err := i.db.CreateSavedFilter(ctx, filter); err != nil {
+		return nil, errors.Wrap(err, "failed to create saved filter")
+	}
+	return &filter, nil
+}
+
+// ReplaceSavedFilter creates the filter with the ID chosen by the user in
+// the context, or replaces the name, the visibility and the search of
+// the filter the user owns; repeating the call leaves the filter as is.
+func (i *inventory) ReplaceSavedFilter(
+	ctx context.Context,
+	filter model.SavedFilter,
+) (*model.SavedFilter, error) {
+	userID, err := filterOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	current, err := i.db.GetSavedFilter(ctx, filter.ID)
+	switch err {
+	case nil:
+		if current.OwnerID != userID {
+			return nil, ErrSavedFilterNotOwner
+		}
+		filter.CreatedTs = current.CreatedTs
+	case store.ErrSavedFilterNotFound:
+		owned, err := i.db.GetSavedFilters(ctx, store.SavedFiltersQuery{
+			OwnerID: userID,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list saved filters")
+		} else if len(owned) >= model.SavedFiltersPerUserMax {
+			return nil, ErrSavedFiltersLimit
+		}
+		filter.CreatedTs = now
+	default:
+		return nil, errors.Wrap(err, "failed to get saved filter")
+	}
+
+	filter.OwnerID = userID
+	filter.UpdatedTs = now
+	err = i.db.UpsertSavedFilter(ctx, filter)
+	if err == store.ErrWriteConflict {
+		// created by another user in the meantime
+		return nil, ErrSavedFilterNotOwner
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to store saved filter")
+	}
+	return &filter, nil
+}
+
+// DeleteSavedFilter removes the filter owned by the user in the context.
+func (i *inventory) DeleteSavedFilter(ctx context.Context, id string) error {
+	userID, err := filterOwner(ctx)
+	if err != nil {
+		return err
+	}
+	current, err := i.getSavedFilter(ctx, userID, id)
+	if err != nil {
+		return err
+	} else if current.OwnerID != userID {
+		return ErrSavedFilterNotOwner
+	}
+	err = i.db.DeleteSavedFilter(ctx, id)
+	if err != nil && err != store.ErrSavedFilterNotFound {
+		return errors.Wrap(err, "failed to remove saved filter")
+	}
+	return err
+}
+
+// importSavedFilter creates the saved filter of a configuration bundle,
+// owned by the user in the context, or replaces the search of the filter
+// with the same ID, keeping its owner.
+func (i *inventory) importSavedFilter(ctx context.Context, filter model.SavedFilter) error {
+	now := time.Now()
+	current, err := i.db.GetSavedFilter(ctx, filter.ID)
+	switch err {
+	case nil:
+		filter.OwnerID = current.OwnerID
+		filter.CreatedTs = current.CreatedTs
+	case store.ErrSavedFilterNotFound:
+		if filter.OwnerID, err = filterOwner(ctx); err != nil {
+			return err
+		}
+		filter.CreatedTs = now
+	default:
+		return errors.Wrap(err, "failed to get saved filter")
+	}
+	filter.UpdatedTs = now
+	if err := i.db.UpsertSavedFilter(ctx, filter); err != nil {
+		return errors.Wrap(err, "failed to store saved filter")
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+	mstore "github.com/mendersoftware/inventory/store/mocks"
+)
+
+func userContext(userID string) context.Context {
+	return identity.WithContext(context.Background(), &identity.Identity{
+		Tenant:  "tenant",
+		Subject: userID,
+		IsUser:  true,
+	})
+}
+
+func TestInventoryListSavedFilters(t *testing.T) {
+	t.Parallel()
+
+	ctx := userContext("user")
+	filters := []model.SavedFilter{{ID: "1", OwnerID: "user"}}
+	shared := true
+	private := false
+
+	db := &mstore.DataStore{}
+	db.On("GetSavedFilters", ctx, store.SavedFiltersQuery{Shared: &shared}).
+		Return(filters, nil).Once()
+	db.On("GetSavedFilters", ctx, store.SavedFiltersQuery{
+		OwnerID: "user",
+		Shared:  &private,
+	}).Return(nil, errors.New("db error")).Once()
+	i := invForTest(db)
+
+	res, err := i.ListSavedFilters(ctx, true)
+	assert.NoError(t, err)
+	assert.Equal(t, filters, res)
+
+	_, err = i.ListSavedFilters(ctx, false)
+	assert.EqualError(t, err, "failed to list saved filters: db error")
+
+	_, err = i.ListSavedFilters(context.Background(), true)
+	assert.Equal(t, ErrSavedFilterUserRequired, err)
+	db.AssertExpectations(t)
+}
+
+func TestInventoryGetSavedFilter(t *testing.T) {
+	t.Parallel()
+
+	ctx := userContext("user")
+	db := &mstore.DataStore{}
+	db.On("GetSavedFilter", ctx, "own").
+		Return(&model.SavedFilter{ID: "own", OwnerID: "user"}, nil)
+	db.On("GetSavedFilter", ctx, "shared").
+		Return(&model.SavedFilter{ID: "shared", OwnerID: "other", Shared: true}, nil)
+	db.On("GetSavedFilter", ctx, "private").
+		Return(&model.SavedFilter{ID: "private", OwnerID: "other"}, nil)
+	db.On("GetSavedFilter", ctx, "error").
+		Return(nil, errors.New("db error"))
+	i := invForTest(db)
+
+	res, err := i.GetSavedFilter(ctx, "own")
+	assert.NoError(t, err)
+	assert.Equal(t, "own", res.ID)
+	res, err = i.GetSavedFilter(ctx, "shared")
+	assert.NoError(t, err)
+	assert.Equal(t, "shared", res.ID)
+
+	// the private filters of the other users are not revealed
+	_, err = i.GetSavedFilter(ctx, "private")
+	assert.Equal(t, store.ErrSavedFilterNotFound, err)
+
+	_, err = i.GetSavedFilter(ctx, "error")
+	assert.EqualError(t, err, "failed to get saved filter: db error")
+}
+
+func TestInventoryCreateSavedFilter(t *testing.T) {
+	t.Parallel()
+
+	userCtx := userContext("user")
+	filter := model.SavedFilter{Name: "offline", Shared: true}
+	testCases := map[string]struct {
+		ctx      context.Context
+		existing int
+		listErr  error
+		err      error
+
+		outErr string
+	}{
+		"ok": {
+			ctx:      userCtx,
+			existing: 1,
+		},
+		"error, no user": {
+			ctx:    context.Background(),
+			outErr: ErrSavedFilterUserRequired.Error(),
+		},
+		"error, limit": {
+			ctx:      userCtx,
+			existing: model.SavedFiltersPerUserMax,
+			outErr:   ErrSavedFiltersLimit.Error(),
+		},
+		"error, list": {
+			ctx:     userCtx,
+			listErr: errors.New("db error"),
+			outErr:  "failed to list saved filters: db error",
+		},
+		"error, create": {
+			ctx:    userCtx,
+			err:    errors.New("db error"),
+			outErr: "failed to create saved filter: db error",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			db := &mstore.DataStore{}
+			db.On("GetSavedFilters", tc.ctx,
+				store.SavedFiltersQuery{OwnerID: "user"},
+			).Return(make([]model.SavedFilter, tc.existing), tc.listErr)
+			db.On("CreateSavedFilter", tc.ctx,
+				mock.MatchedBy(func(f model.SavedFilter) bool {
+					return f.ID != "" && f.OwnerID == "user" &&
+						f.Shared && !f.CreatedTs.IsZero()
+				}),
+			).Return(tc.err)
+
+			i := invForTest(db)
+			res, err := i.CreateSavedFilter(tc.ctx, filter)
+			if tc.outErr != "" {
+				assert.EqualError(t, err, tc.outErr)
+				assert.Nil(t, res)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, "user", res.OwnerID)
+				assert.NotEmpty(t, res.ID)
+			}
+		})
+	}
+}
+
+func TestInventoryReplaceSavedFilter(t *testing.T) {
+	t.Parallel()
+
+	ctx := userContext("user")
+	createdTs := time.Now().Add(-time.Hour)
+	db := &mstore.DataStore{}
+	db.On("GetSavedFilter", ctx, "own").
+		Return(&model.SavedFilter{ID: "own", OwnerID: "user", CreatedTs: createdTs}, nil)
+	db.On("GetSavedFilter", ctx, "shared").
+		Return(&model.SavedFilter{ID: "shared", OwnerID: "other", Shared: true}, nil)
+	db.On("GetSavedFilter", ctx, "new").
+		Return(nil, store.ErrSavedFilterNotFound)
+	db.On("GetSavedFilters", ctx, store.SavedFiltersQuery{OwnerID: "user"}).
+		Return([]model.SavedFilter{{ID: "own"}}, nil)
+	db.On("UpsertSavedFilter", ctx,
+		mock.MatchedBy(func(f model.SavedFilter) bool {
+			return f.ID == "own" && f.OwnerID == "user" &&
+				f.Name == "renamed" && f.CreatedTs.Equal(createdTs) &&
+				!f.UpdatedTs.IsZero()
+		}),
+	).Return(nil)
+	db.On("UpsertSavedFilter", ctx,
+		mock.MatchedBy(func(f model.SavedFilter) bool {
+			return f.ID == "new" && f.OwnerID == "user" && !f.CreatedTs.IsZero()
+		}),
+	).Return(nil)
+	i := invForTest(db)
+
+	// the owner cannot be changed
+	res, err := i.ReplaceSavedFilter(ctx, model.SavedFilter{
+		ID:      "own",
+		Name:    "renamed",
+		OwnerID: "other",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "user", res.OwnerID)
+
+	// the filter is created with the given ID
+	res, err = i.ReplaceSavedFilter(ctx, model.SavedFilter{ID: "new", Name: "new"})
+	assert.NoError(t, err)
+	assert.Equal(t, "new", res.ID)
+
+	_, err = i.ReplaceSavedFilter(ctx, model.SavedFilter{ID: "shared"})
+	assert.Equal(t, ErrSavedFilterNotOwner, err)
+	db.AssertExpectations(t)
+
+	// the ID was taken by another user in the meantime
+	db = &mstore.DataStore{}
+	db.On("GetSavedFilter", ctx, "new").
+		Return(nil, store.ErrSavedFilterNotFound)
+	db.On("GetSavedFilters", ctx, store.SavedFiltersQuery{OwnerID: "user"}).
+		Return([]model.SavedFilter{}, nil)
+	db.On("UpsertSavedFilter", ctx, mock.AnythingOfType("model.SavedFilter")).
+		Return(store.ErrWriteConflict)
+	_, err = invForTest(db).ReplaceSavedFilter(ctx, model.SavedFilter{ID: "new"})
+	assert.Equal(t, ErrSavedFilterNotOwner, err)
+}
+
+func TestInventoryDeleteSavedFilter(t *testing.T) {
+	t.Parallel()
+
+	ctx := userContext("user")
+	db := &mstore.DataStore{}
+	db.On("GetSavedFilter", ctx, "own").
+		Return(&model.SavedFilter{ID: "own", OwnerID: "user"}, nil)
+	db.On("GetSavedFilter", ctx, "shared").
+		Return(&model.SavedFilter{ID: "shared", OwnerID: "other", Shared: true}, nil)
+	db.On("GetSavedFilter", ctx, "missing").
+		Return(nil, store.ErrSavedFilterNotFound)
+	db.On("DeleteSavedFilter", ctx, "own").Return(nil)
+	i := invForTest(db)
+
+	assert.NoError(t, i.DeleteSavedFilter(ctx, "own"))
+	assert.Equal(t, ErrSavedFilterNotOwner, i.DeleteSavedFilter(ctx, "shared"))
+	assert.Equal(t, store.ErrSavedFilterNotFound,
+		i.DeleteSavedFilter(ctx, "missing"))
+	assert.Equal(t, ErrSavedFilterUserRequired,
+		i.DeleteSavedFilter(context.Background(), "own"))
+	db.AssertExpectations(t)
+}
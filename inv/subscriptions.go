@@ -0,0 +1,223 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/mendersoftware/go-lib-micro/mongo/oid"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/events"
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+	"github.com/mendersoftware/inventory/utils/reqctx"
+)
+
+var (
+	// ErrSubscriptionUserRequired is returned when subscriptions are
+	// managed with a token other than a user token.
+	ErrSubscriptionUserRequired = errors.New("subscriptions require a user token")
+	// ErrSubscriptionsLimit is returned when the user has too many
+	// subscriptions.
+	ErrSubscriptionsLimit = errors.Errorf(
+		"at most %d subscriptions are allowed per user",
+		model.SubscriptionsPerUserMax,
+	)
+	// ErrSubscriptionIDInUse is returned when a user creates a subscription
+	// with the ID of a subscription of another user.
+	ErrSubscriptionIDInUse = errors.New("the subscription ID is in use")
+)
+
+// WithNotifier sets the notifier delivering the notifications of
+// the subscriptions; without it no notifications are delivered.
+func (i *inventory) WithNotifier(notifier events.Notifier) InventoryApp {
+	i.notifier = notifier
+	return i
+}
+
+// subscriber returns the ID of the user in the context.
+func subscriber(ctx context.Context) (string, error) {
+	info := reqctx.FromContext(ctx)
+	if !info.IsUser || info.Subject == "" {
+		return "", ErrSubscriptionUserRequired
+	}
+	return info.Subject, nil
+}
+
+// ListSubscriptions returns the subscriptions of the user in the context.
+func (i *inventory) ListSubscriptions(ctx context.Context) ([]model.Subscription, error) {
+	userID, err := subscriber(ctx)
+	if err != nil {
+		return nil, err
+	}
+	subs, err := i.db.GetSubscriptions(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list subscriptions")
+	}
+	return subs, nil
+}
+
+// CreateSubscription subscribes the user in the context to the changes of
+// the device or to the devices starting to match the filters.
+func (i *inventory) CreateSubscription(
+	ctx context.Context,
+	sub model.Subscription,
+) (*model.Subscription, error) {
+	userID, err := subscriber(ctx)
+	if err != nil {
+		return nil, err
+	}
+	subs, err := i.db.GetSubscriptions(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list subscriptions")
+	} else if len(subs) >= model.SubscriptionsPerUserMax {
+		return nil, ErrSubscriptionsLimit
+	}
+
+	sub.ID = oid.NewUUIDv4().String()
+	sub.UserID = userID
+	sub.CreatedTs = time.Now()
+	if err := i.db.CreateSubscription(ctx, sub); err != nil {
+		return nil, errors.Wrap(err, "failed to create subscription")
+	}
+	return &sub, nil
+}
+
+// ReplaceSubscription creates the subscription with the ID chosen by the
+// user in the context, or replaces the subscription of the user; repeating
+// the call leaves the subscription as is.
+func (i *inventory) ReplaceSubscription(
+	ctx context.Context,
+	sub model.Subscription,
+) (*model.Subscription, error) {
+	userID, err := subscriber(ctx)
+	if err != nil {
+		return nil, err
+	}
+	subs, err := i.db.GetSubscriptions(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list subscriptions")
+	}
+	sub.CreatedTs = time.Time{}
+	for _, s := range subs {
+		if s.ID == sub.ID {
+			sub.CreatedTs = s.CreatedTs
+			break
+		}
+	}
+	if sub.CreatedTs.IsZero() {
+		if len(subs) >= model.SubscriptionsPerUserMax {
+			return nil, ErrSubscriptionsLimit
+		}
+		sub.CreatedTs = time.Now()
+	}
+
+	sub.UserID = userID
+	err = i.db.UpsertSubscription(ctx, sub)
+	if err == store.ErrWriteConflict {
+		return nil, ErrSubscriptionIDInUse
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to store subscription")
+	}
+	return &sub, nil
+}
+
+// DeleteSubscription removes the subscription of the user in the context.
+func (i *inventory) DeleteSubscription(ctx context.Context, id string) error {
+	userID, err := subscriber(ctx)
+	if err != nil {
+		return err
+	}
+	return i.db.DeleteSubscription(ctx, userID, id)
+}
+
+// WatchSubscriptions evaluates the subscriptions on each change of
+// the devices and delivers the notifications, until the context is canceled
+// or the change stream fails. The watchers of the group counts are signaled
+// about the changes as well.
+func (i *inventory) WatchSubscriptions(ctx context.Context) error {
+	return i.db.WatchDevices(ctx, i.handleDeviceChange)
+}
+
+// notifySubscribers evaluates the subscriptions of the tenant in the context
+// on the change of the device. Failures to evaluate a subscription or to
+// deliver a notification are logged, and do not stop the change stream.
+func (i *inventory) notifySubscribers(ctx context.Context, id model.DeviceID) error {
+	l := log.FromContext(ctx).F(reqctx.FromContext(ctx).LogContext())
+	subs, err := i.db.GetSubscriptions(ctx, "")
+	if err != nil {
+		l.Errorf("failed to get subscriptions: %s", err.Error())
+		return nil
+	}
+
+	var (
+		device  *model.Device
+		fetched bool
+	)
+	for _, sub := range subs {
+		if sub.DeviceID != "" && sub.DeviceID != id {
+			continue
+		}
+		eventType := events.TypeSubscriptionDeviceChanged
+		if !sub.OnChange() {
+			eventType = events.TypeSubscriptionDeviceMatched
+			notify, err := i.evaluateSubscription(ctx, sub, id)
+			if err != nil {
+				l.Errorf("failed to evaluate subscription %s: %s",
+					sub.ID, err.Error())
+				continue
+			} else if !notify {
+				continue
+			}
+		}
+
+		if i.notifier == nil {
+			continue
+		} else if !fetched {
+			if device, err = i.db.GetDevice(ctx, id); err != nil {
+				l.Errorf("failed to get device %s: %s", id, err.Error())
+			}
+			fetched = true
+		}
+		event := events.New(ctx, eventType, model.Notification{
+			SubscriptionID: sub.ID,
+			DeviceID:       id,
+			Device:         device,
+		})
+		if err := i.notifier.Notify(ctx, sub.Channel, event); err != nil {
+			l.Errorf("failed to notify subscription %s: %s",
+				sub.ID, err.Error())
+			i.recordDeadLetter(ctx, err, sub.ID)
+		}
+	}
+	return ctx.Err()
+}
+
+// evaluateSubscription returns true if the device starts matching
+// the filters of the subscription.
+func (i *inventory) evaluateSubscription(
+	ctx context.Context,
+	sub model.Subscription,
+	id model.DeviceID,
+) (bool, error) {
+	matches, err := i.db.DeviceMatches(ctx, id, sub.Filters)
+	if err != nil {
+		return false, err
+	}
+	return i.db.SetSubscriptionMatch(ctx, sub.ID, id, matches)
+}
@@ -0,0 +1,281 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/inventory/events"
+	mevents "github.com/mendersoftware/inventory/events/mocks"
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+	mstore "github.com/mendersoftware/inventory/store/mocks"
+)
+
+func TestInventoryCreateSubscription(t *testing.T) {
+	t.Parallel()
+
+	userCtx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant:  "tenant",
+		Subject: "user",
+		IsUser:  true,
+	})
+	sub := model.Subscription{
+		DeviceID: "1",
+		Channel: model.NotificationChannel{
+			Type: model.NotificationChannelWebhook,
+			URL:  "https://hooks.example.com",
+		},
+	}
+	testCases := map[string]struct {
+		ctx      context.Context
+		existing int
+		listErr  error
+		err      error
+
+		outErr string
+	}{
+		"ok": {
+			ctx:      userCtx,
+			existing: 1,
+		},
+		"error, no user": {
+			ctx: identity.WithContext(context.Background(), &identity.Identity{
+				Subject:  "1",
+				IsDevice: true,
+			}),
+			outErr: ErrSubscriptionUserRequired.Error(),
+		},
+		"error, limit": {
+			ctx:      userCtx,
+			existing: model.SubscriptionsPerUserMax,
+			outErr:   ErrSubscriptionsLimit.Error(),
+		},
+		"error, list": {
+			ctx:     userCtx,
+			listErr: errors.New("db error"),
+			outErr:  "failed to list subscriptions: db error",
+		},
+		"error, create": {
+			ctx:    userCtx,
+			err:    errors.New("db error"),
+			outErr: "failed to create subscription: db error",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			db := &mstore.DataStore{}
+			db.On("GetSubscriptions", tc.ctx, "user").
+				Return(make([]model.Subscription, tc.existing), tc.listErr)
+			db.On("CreateSubscription", tc.ctx,
+				mock.MatchedBy(func(s model.Subscription) bool {
+					return s.ID != "" && s.UserID == "user" &&
+						s.DeviceID == sub.DeviceID && !s.CreatedTs.IsZero()
+				}),
+			).Return(tc.err)
+
+			i := invForTest(db)
+			res, err := i.CreateSubscription(tc.ctx, sub)
+			if tc.outErr != "" {
+				assert.EqualError(t, err, tc.outErr)
+				assert.Nil(t, res)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, "user", res.UserID)
+				assert.NotEmpty(t, res.ID)
+			}
+		})
+	}
+}
+
+func TestInventoryReplaceSubscription(t *testing.T) {
+	t.Parallel()
+
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant:  "tenant",
+		Subject: "user",
+		IsUser:  true,
+	})
+	createdTs := time.Now().Add(-time.Hour)
+	sub := model.Subscription{
+		ID:       "sub",
+		DeviceID: "1",
+		Channel: model.NotificationChannel{
+			Type: model.NotificationChannelWebhook,
+			URL:  "https://hooks.example.com",
+		},
+	}
+	testCases := map[string]struct {
+		existing []model.Subscription
+		err      error
+
+		created bool
+		outErr  string
+	}{
+		"ok, replaced": {
+			existing: []model.Subscription{
+				{ID: "other"},
+				{ID: "sub", CreatedTs: createdTs},
+			},
+		},
+		"ok, created": {
+			existing: []model.Subscription{{ID: "other"}},
+			created:  true,
+		},
+		"error, limit": {
+			existing: make([]model.Subscription, model.SubscriptionsPerUserMax),
+			outErr:   ErrSubscriptionsLimit.Error(),
+		},
+		"error, ID of another user": {
+			err:    store.ErrWriteConflict,
+			outErr: ErrSubscriptionIDInUse.Error(),
+		},
+		"error, store": {
+			err:    errors.New("db error"),
+			outErr: "failed to store subscription: db error",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			db := &mstore.DataStore{}
+			db.On("GetSubscriptions", ctx, "user").
+				Return(tc.existing, nil)
+			db.On("UpsertSubscription", ctx,
+				mock.MatchedBy(func(s model.Subscription) bool {
+					return s.ID == "sub" && s.UserID == "user" &&
+						!s.CreatedTs.IsZero()
+				}),
+			).Return(tc.err)
+
+			res, err := invForTest(db).ReplaceSubscription(ctx, sub)
+			if tc.outErr != "" {
+				assert.EqualError(t, err, tc.outErr)
+				assert.Nil(t, res)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, "sub", res.ID)
+				assert.Equal(t, tc.created, !res.CreatedTs.Equal(createdTs))
+			}
+		})
+	}
+
+	_, err := invForTest(&mstore.DataStore{}).
+		ReplaceSubscription(context.Background(), sub)
+	assert.Equal(t, ErrSubscriptionUserRequired, err)
+}
+
+func TestInventoryDeleteSubscription(t *testing.T) {
+	t.Parallel()
+
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Subject: "user",
+		IsUser:  true,
+	})
+	db := &mstore.DataStore{}
+	db.On("DeleteSubscription", ctx, "user", "sub").
+		Return(store.ErrSubscriptionNotFound)
+	i := invForTest(db)
+
+	err := i.DeleteSubscription(ctx, "sub")
+	assert.Equal(t, store.ErrSubscriptionNotFound, err)
+
+	err = i.DeleteSubscription(context.Background(), "sub")
+	assert.Equal(t, ErrSubscriptionUserRequired, err)
+	db.AssertExpectations(t)
+}
+
+func TestInventoryWatchSubscriptions(t *testing.T) {
+	t.Parallel()
+
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "tenant",
+	})
+	webhook := model.NotificationChannel{
+		Type: model.NotificationChannelWebhook,
+		URL:  "https://hooks.example.com",
+	}
+	filters := []model.FilterPredicate{{
+		Scope:     model.AttrScopeInventory,
+		Attribute: "status",
+		Type:      "$eq",
+		Value:     "online",
+	}}
+	subs := []model.Subscription{
+		{ID: "changed", DeviceID: "1", Channel: webhook},
+		{ID: "other-device", DeviceID: "2", Channel: webhook},
+		{ID: "matched", Filters: filters, Channel: webhook},
+		{ID: "still-matching", DeviceID: "1", Filters: filters, Channel: webhook},
+		{ID: "failing", Filters: filters, Channel: webhook},
+	}
+	device := &model.Device{ID: "1"}
+
+	db := &mstore.DataStore{}
+	db.On("WatchDevices", ctx, mock.AnythingOfType("store.DeviceChangeHandler")).
+		Run(func(args mock.Arguments) {
+			handler := args.Get(1).(store.DeviceChangeHandler)
+			assert.NoError(t, handler(ctx, model.DeviceChange{
+				DeviceID: "1",
+				Type:     model.DeviceChangeUpdated,
+			}))
+			// the subscriptions are not evaluated on the deletions
+			assert.NoError(t, handler(ctx, model.DeviceChange{
+				DeviceID: "2",
+				Type:     model.DeviceChangeDeleted,
+			}))
+		}).
+		Return(nil)
+	db.On("AppendDeviceChange", ctx, mock.AnythingOfType("model.DeviceChange")).
+		Return(nil).Twice()
+	db.On("GetSubscriptions", ctx, "").Return(subs, nil).Once()
+	db.On("DeviceMatches", ctx, model.DeviceID("1"), filters).Return(true, nil)
+	db.On("SetSubscriptionMatch", ctx, "matched", model.DeviceID("1"), true).
+		Return(true, nil)
+	db.On("SetSubscriptionMatch", ctx, "still-matching", model.DeviceID("1"), true).
+		Return(false, nil)
+	db.On("SetSubscriptionMatch", ctx, "failing", model.DeviceID("1"), true).
+		Return(false, errors.New("db error"))
+	db.On("GetDevice", ctx, model.DeviceID("1")).Return(device, nil).Once()
+
+	notifier := &mevents.Notifier{}
+	notifier.On("Notify", ctx, webhook, mock.MatchedBy(func(e events.Event) bool {
+		n := e.Data.(model.Notification)
+		return e.Type == events.TypeSubscriptionDeviceChanged &&
+			n.SubscriptionID == "changed" && n.Device == device
+	})).Return(nil).Once()
+	notifier.On("Notify", ctx, webhook, mock.MatchedBy(func(e events.Event) bool {
+		n := e.Data.(model.Notification)
+		return e.Type == events.TypeSubscriptionDeviceMatched &&
+			n.SubscriptionID == "matched" && n.DeviceID == "1"
+	})).Return(errors.New("webhook down")).Once()
+
+	i := invForTest(db)
+	i.WithNotifier(notifier)
+	assert.NoError(t, i.WatchSubscriptions(ctx))
+	db.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+}
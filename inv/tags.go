@@ -0,0 +1,260 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+// ImportTags sets the tags of the devices identified by the value of
+// the given identity attribute, which must identify a single device.
+// The rows which cannot be applied are reported in the result; the later
+// rows override the earlier ones setting the same tag of a device.
+func (i *inventory) ImportTags(
+	ctx context.Context,
+	identity string,
+	rows []model.TagsImportRow,
+) (*model.TagsImportResult, error) {
+	if err := i.checkScopeWriters(ctx, model.DeviceAttributes{
+		{Scope: model.AttrScopeTags},
+	}); err != nil {
+		return nil, err
+	}
+	res := &model.TagsImportResult{
+		Rows:   len(rows),
+		Errors: []model.TagsImportError{},
+	}
+	reject := func(row model.TagsImportRow, err error) {
+		res.Errors = append(res.Errors, model.TagsImportError{
+			Row:      row.Row,
+			Identity: row.Identity,
+			Error:    err.Error(),
+		})
+	}
+
+	defs, err := i.db.GetAttributeDefinitions(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get attribute definitions")
+	}
+	var identityDef model.AttributeDefinition
+	tagDefs := make(map[string]model.AttributeDefinition)
+	for _, def := range defs {
+		switch {
+		case def.Scope == model.AttrScopeTags:
+			tagDefs[def.Name] = def
+		case def.Scope == model.AttrScopeIdentity && def.Name == identity:
+			identityDef = def
+		}
+	}
+
+	valid := make([]model.TagsImportRow, 0, len(rows))
+	values := make([]string, 0, len(rows))
+	seen := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		if err := row.Validate(); err != nil {
+			reject(row, err)
+			continue
+		}
+		// the identities are looked up as normalized at their ingestion
+		row.Identity = identityDef.Normalize(row.Identity).(string)
+		def := tagDefs[row.Name]
+		row.Value = def.Normalize(row.Value).(string)
+		if def.Type != "" && !def.Monitored() {
+			if err := def.Check(row.Value); err != nil {
+				reject(row, errors.Wrapf(ErrSchemaViolation,
+					"attribute %s/%s %s", def.Scope, def.Name, err.Error()))
+				continue
+			}
+		}
+		valid = append(valid, row)
+		if !seen[row.Identity] {
+			seen[row.Identity] = true
+			values = append(values, row.Identity)
+		}
+	}
+	if len(valid) == 0 {
+		return res, nil
+	}
+
+	found, err := i.db.GetDeviceIDsByAttribute(ctx,
+		model.AttrScopeIdentity, identity, values,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to look up devices")
+	}
+
+	type deviceTag struct {
+		id   model.DeviceID
+		name string
+	}
+	tags := make(map[deviceTag]string)
+	for _, row := range valid {
+		ids := distinctDeviceIDs(found[row.Identity])
+		switch len(ids) {
+		case 0:
+			reject(row, errors.Errorf("no device with %s/%s %q",
+				model.AttrScopeIdentity, identity, row.Identity))
+			continue
+		case 1:
+		default:
+			reject(row, errors.Errorf("%d devices with %s/%s %q",
+				len(ids), model.AttrScopeIdentity, identity, row.Identity))
+			continue
+		}
+		tags[deviceTag{id: ids[0], name: row.Name}] = row.Value
+		res.Tagged++
+	}
+
+	// the devices getting the same tag are updated at once
+	batches := make(map[model.DeviceAttribute][]model.DeviceID)
+	for tag, value := range tags {
+		attr := model.DeviceAttribute{
+			Scope: model.AttrScopeTags,
+			Name:  tag.name,
+			Value: value,
+		}
+		batches[attr] = append(batches[attr], tag.id)
+	}
+	attrs := make(model.DeviceAttributes, 0, len(batches))
+	for attr := range batches {
+		attrs = append(attrs, attr)
+	}
+	sort.Slice(attrs, func(a, b int) bool {
+		if attrs[a].Name != attrs[b].Name {
+			return attrs[a].Name < attrs[b].Name
+		}
+		return attrs[a].Value.(string) < attrs[b].Value.(string)
+	})
+	for _, attr := range attrs {
+		// stop between the batches if the client is gone
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		ids := batches[attr]
+		sort.Slice(ids, func(a, b int) bool { return ids[a] < ids[b] })
+		if _, err := i.db.UpsertDevicesAttributesWithUpdated(
+			ctx, ids, model.DeviceAttributes{attr},
+		); err != nil {
+			return nil, errors.Wrap(err, "failed to update devices")
+		}
+	}
+
+	sort.SliceStable(res.Errors, func(a, b int) bool {
+		return res.Errors[a].Row < res.Errors[b].Row
+	})
+	return res, nil
+}
+
+// AssignTags sets and removes the tags of the selected devices in
+// a single update; the listed devices which were not updated are
+// reported in the result.
+func (i *inventory) AssignTags(
+	ctx context.Context,
+	assignment model.TagsAssignment,
+) (*model.TagsAssignmentResult, error) {
+	set, unset := assignment.Attributes()
+	if err := i.checkScopeWriters(ctx, model.DeviceAttributes{
+		{Scope: model.AttrScopeTags},
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := i.checkBulkAttributeSchema(ctx, set); err != nil {
+		return nil, err
+	}
+
+	params := assignment.SearchParams()
+	result, matched, err := i.db.UpdateDevicesTags(ctx, params, set, unset)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to update devices")
+	}
+	res := &model.TagsAssignmentResult{
+		Tagged: int(result.MatchedCount),
+		Errors: []model.TagsAssignmentError{},
+	}
+	if len(assignment.DeviceIDs) == 0 {
+		return res, nil
+	}
+	reason := "device not found"
+	if len(assignment.Filters) > 0 {
+		reason = "device not found or not matching the filters"
+	}
+	found := make(map[model.DeviceID]bool, len(matched))
+	for _, id := range matched {
+		found[id] = true
+	}
+	for _, id := range distinctDeviceIDs(assignment.DeviceIDs) {
+		if !found[id] {
+			res.Errors = append(res.Errors, model.TagsAssignmentError{
+				DeviceID: id,
+				Error:    reason,
+			})
+		}
+	}
+	return res, nil
+}
+
+func distinctDeviceIDs(ids []model.DeviceID) []model.DeviceID {
+	if len(ids) < 2 {
+		return ids
+	}
+	seen := make(map[model.DeviceID]bool, len(ids))
+	distinct := make([]model.DeviceID, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			distinct = append(distinct, id)
+		}
+	}
+	return distinct
+}
+
+// checkBulkAttributeSchema normalizes the values of the attributes written
+// to many devices at once in place and verifies they conform to their
+// definitions in the schema; the definitions in the monitor mode are not
+// enforced.
+func (i *inventory) checkBulkAttributeSchema(
+	ctx context.Context,
+	attrs model.DeviceAttributes,
+) error {
+	defs, err := i.db.GetAttributeDefinitions(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get attribute definitions")
+	}
+	defined := make(map[model.SelectAttribute]model.AttributeDefinition, len(defs))
+	for _, def := range defs {
+		defined[model.SelectAttribute{Scope: def.Scope, Attribute: def.Name}] = def
+	}
+	for n, attr := range attrs {
+		def, ok := defined[model.SelectAttribute{Scope: attr.Scope, Attribute: attr.Name}]
+		if !ok {
+			continue
+		}
+		attrs[n].Value = def.Normalize(attr.Value)
+		if def.Monitored() {
+			continue
+		}
+		if err := def.Check(attrs[n].Value); err != nil {
+			return errors.Wrapf(ErrSchemaViolation,
+				"attribute %s/%s %s", def.Scope, def.Name, err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,393 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/inventory/model"
+	mstore "github.com/mendersoftware/inventory/store/mocks"
+)
+
+func TestInventoryImportTags(t *testing.T) {
+	t.Parallel()
+
+	userCtx := identity.WithContext(context.Background(),
+		&identity.Identity{Subject: "user", IsUser: true})
+	deviceCtx := identity.WithContext(context.Background(),
+		&identity.Identity{Subject: "1", IsDevice: true})
+	canceledCtx, cancel := context.WithCancel(userCtx)
+	cancel()
+	defs := []model.AttributeDefinition{{
+		Scope: model.AttrScopeTags,
+		Name:  "floor",
+		Type:  model.AttributeTypeNumber,
+	}}
+	rows := []model.TagsImportRow{
+		{Row: 1, Identity: "00:01", Name: "site", Value: "berlin"},
+		{Row: 2, Identity: "00:02", Name: "site", Value: "berlin"},
+		{Row: 3, Identity: "00:03", Name: "site", Value: "oslo"},
+		{Row: 4, Identity: "00:04", Name: "site", Value: "oslo"},
+		{Row: 5, Identity: "00:05", Name: "site", Value: "oslo"},
+		{Row: 6, Identity: "00:01", Name: "floor", Value: "two"},
+		{Row: 7, Identity: "", Name: "site", Value: "oslo"},
+		{Row: 8, Identity: "00:02", Name: "site", Value: "oslo"},
+	}
+	found := map[string][]model.DeviceID{
+		"00:01": {"1"},
+		"00:02": {"2", "2"},
+		"00:03": {"3"},
+		"00:04": {"4", "5"},
+	}
+	testCases := map[string]struct {
+		ctx  context.Context
+		rows []model.TagsImportRow
+		db   func() *mstore.DataStore
+
+		res *model.TagsImportResult
+		err string
+	}{
+		"ok": {
+			ctx:  userCtx,
+			rows: rows,
+			db: func() *mstore.DataStore {
+				db := &mstore.DataStore{}
+				db.On("GetAttributeDefinitions", userCtx).Return(defs, nil)
+				db.On("GetDeviceIDsByAttribute", userCtx,
+					model.AttrScopeIdentity, "mac",
+					[]string{"00:01", "00:02", "00:03", "00:04", "00:05"},
+				).Return(found, nil)
+				db.On("UpsertDevicesAttributesWithUpdated", userCtx,
+					[]model.DeviceID{"1"},
+					model.DeviceAttributes{{Scope: "tags", Name: "site", Value: "berlin"}},
+				).Return(&model.UpdateResult{MatchedCount: 1}, nil)
+				// the later row overrides the tag of device 2
+				db.On("UpsertDevicesAttributesWithUpdated", userCtx,
+					[]model.DeviceID{"2", "3"},
+					model.DeviceAttributes{{Scope: "tags", Name: "site", Value: "oslo"}},
+				).Return(&model.UpdateResult{MatchedCount: 2}, nil)
+				return db
+			},
+			res: &model.TagsImportResult{
+				Rows:   8,
+				Tagged: 4,
+				Errors: []model.TagsImportError{
+					{Row: 4, Identity: "00:04", Error: `2 devices with identity/mac "00:04"`},
+					{Row: 5, Identity: "00:05", Error: `no device with identity/mac "00:05"`},
+					{Row: 6, Identity: "00:01", Error: "attribute tags/floor must be of type number: " +
+						"attribute does not conform to the schema"},
+					{Row: 7, Error: "Identity: cannot be blank."},
+				},
+			},
+		},
+		"ok, normalized": {
+			ctx: userCtx,
+			rows: []model.TagsImportRow{
+				{Row: 1, Identity: "00-0A-00-00-00-01", Name: "site", Value: " Berlin"},
+			},
+			db: func() *mstore.DataStore {
+				db := &mstore.DataStore{}
+				db.On("GetAttributeDefinitions", userCtx).Return([]model.AttributeDefinition{{
+					Scope:       model.AttrScopeIdentity,
+					Name:        "mac",
+					Normalizers: []string{model.NormalizerMAC},
+				}, {
+					Scope:       model.AttrScopeTags,
+					Name:        "site",
+					Normalizers: []string{model.NormalizerTrim, model.NormalizerLowercase},
+				}}, nil)
+				db.On("GetDeviceIDsByAttribute", userCtx,
+					model.AttrScopeIdentity, "mac",
+					[]string{"00:0a:00:00:00:01"},
+				).Return(map[string][]model.DeviceID{
+					"00:0a:00:00:00:01": {"1"},
+				}, nil)
+				db.On("UpsertDevicesAttributesWithUpdated", userCtx,
+					[]model.DeviceID{"1"},
+					model.DeviceAttributes{{Scope: "tags", Name: "site", Value: "berlin"}},
+				).Return(&model.UpdateResult{MatchedCount: 1}, nil)
+				return db
+			},
+			res: &model.TagsImportResult{
+				Rows:   1,
+				Tagged: 1,
+				Errors: []model.TagsImportError{},
+			},
+		},
+		"ok, no valid rows": {
+			ctx:  userCtx,
+			rows: rows[6:7],
+			db: func() *mstore.DataStore {
+				db := &mstore.DataStore{}
+				db.On("GetAttributeDefinitions", userCtx).Return(nil, nil)
+				return db
+			},
+			res: &model.TagsImportResult{
+				Rows: 1,
+				Errors: []model.TagsImportError{
+					{Row: 7, Error: "Identity: cannot be blank."},
+				},
+			},
+		},
+		"error, device token": {
+			ctx:  deviceCtx,
+			rows: rows,
+			db: func() *mstore.DataStore {
+				return &mstore.DataStore{}
+			},
+			err: "scope tags: writing attributes of the scope is forbidden",
+		},
+		"error, db lookup": {
+			ctx:  userCtx,
+			rows: rows[:1],
+			db: func() *mstore.DataStore {
+				db := &mstore.DataStore{}
+				db.On("GetAttributeDefinitions", userCtx).Return(nil, nil)
+				db.On("GetDeviceIDsByAttribute", userCtx,
+					model.AttrScopeIdentity, "mac", mock.Anything,
+				).Return(nil, errors.New("db error"))
+				return db
+			},
+			err: "failed to look up devices: db error",
+		},
+		"error, db update": {
+			ctx:  userCtx,
+			rows: rows[:1],
+			db: func() *mstore.DataStore {
+				db := &mstore.DataStore{}
+				db.On("GetAttributeDefinitions", userCtx).Return(nil, nil)
+				db.On("GetDeviceIDsByAttribute", userCtx,
+					model.AttrScopeIdentity, "mac", mock.Anything,
+				).Return(found, nil)
+				db.On("UpsertDevicesAttributesWithUpdated", userCtx,
+					mock.Anything, mock.Anything,
+				).Return(nil, errors.New("db error"))
+				return db
+			},
+			err: "failed to update devices: db error",
+		},
+		"error, canceled": {
+			ctx:  canceledCtx,
+			rows: rows[:1],
+			db: func() *mstore.DataStore {
+				db := &mstore.DataStore{}
+				db.On("GetAttributeDefinitions", canceledCtx).Return(nil, nil)
+				db.On("GetDeviceIDsByAttribute", canceledCtx,
+					model.AttrScopeIdentity, "mac", mock.Anything,
+				).Return(found, nil)
+				return db
+			},
+			err: "context canceled",
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			db := tc.db()
+			defer db.AssertExpectations(t)
+
+			i := invForTest(db)
+			res, err := i.ImportTags(tc.ctx, "mac", tc.rows)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.res, res)
+			}
+		})
+	}
+}
+
+func TestInventoryAssignTags(t *testing.T) {
+	t.Parallel()
+
+	userCtx := identity.WithContext(context.Background(),
+		&identity.Identity{Subject: "user", IsUser: true})
+	deviceCtx := identity.WithContext(context.Background(),
+		&identity.Identity{Subject: "1", IsDevice: true})
+	filters := []model.FilterPredicate{{
+		Scope:     model.AttrScopeInventory,
+		Attribute: "device_type",
+		Type:      "$eq",
+		Value:     "raspberrypi4",
+	}}
+	defs := []model.AttributeDefinition{{
+		Scope:       model.AttrScopeTags,
+		Name:        "site",
+		Normalizers: []string{model.NormalizerTrim, model.NormalizerLowercase},
+	}, {
+		Scope: model.AttrScopeTags,
+		Name:  "floor",
+		Type:  model.AttributeTypeNumber,
+	}}
+	site := model.DeviceAttributes{{Scope: "tags", Name: "site", Value: "oslo"}}
+	owner := model.DeviceAttributes{{Scope: "tags", Name: "owner"}}
+	testCases := map[string]struct {
+		ctx        context.Context
+		assignment model.TagsAssignment
+		db         func() *mstore.DataStore
+
+		res *model.TagsAssignmentResult
+		err string
+	}{
+		"ok, filters": {
+			ctx: userCtx,
+			assignment: model.TagsAssignment{
+				Filters: filters,
+				Set:     map[string]string{"site": " Oslo"},
+				Unset:   []string{"owner"},
+			},
+			db: func() *mstore.DataStore {
+				db := &mstore.DataStore{}
+				db.On("GetAttributeDefinitions", userCtx).Return(defs, nil)
+				db.On("UpdateDevicesTags", userCtx,
+					model.SearchParams{Filters: filters}, site, owner,
+				).Return(&model.UpdateResult{MatchedCount: 10}, nil, nil)
+				return db
+			},
+			res: &model.TagsAssignmentResult{
+				Tagged: 10,
+				Errors: []model.TagsAssignmentError{},
+			},
+		},
+		"ok, device IDs": {
+			ctx: userCtx,
+			assignment: model.TagsAssignment{
+				DeviceIDs: []model.DeviceID{"1", "2", "2", "3"},
+				Unset:     []string{"owner"},
+			},
+			db: func() *mstore.DataStore {
+				db := &mstore.DataStore{}
+				db.On("GetAttributeDefinitions", userCtx).Return(defs, nil)
+				db.On("UpdateDevicesTags", userCtx,
+					model.SearchParams{DeviceIDs: []string{"1", "2", "2", "3"}},
+					model.DeviceAttributes(nil), owner,
+				).Return(
+					&model.UpdateResult{MatchedCount: 1},
+					[]model.DeviceID{"2"},
+					nil,
+				)
+				return db
+			},
+			res: &model.TagsAssignmentResult{
+				Tagged: 1,
+				Errors: []model.TagsAssignmentError{
+					{DeviceID: "1", Error: "device not found"},
+					{DeviceID: "3", Error: "device not found"},
+				},
+			},
+		},
+		"ok, device IDs and filters": {
+			ctx: userCtx,
+			assignment: model.TagsAssignment{
+				DeviceIDs: []model.DeviceID{"1", "2"},
+				Filters:   filters,
+				Set:       map[string]string{"site": "oslo"},
+			},
+			db: func() *mstore.DataStore {
+				db := &mstore.DataStore{}
+				db.On("GetAttributeDefinitions", userCtx).Return(nil, nil)
+				db.On("UpdateDevicesTags", userCtx,
+					model.SearchParams{
+						DeviceIDs: []string{"1", "2"},
+						Filters:   filters,
+					},
+					site, model.DeviceAttributes(nil),
+				).Return(
+					&model.UpdateResult{MatchedCount: 1},
+					[]model.DeviceID{"1"},
+					nil,
+				)
+				return db
+			},
+			res: &model.TagsAssignmentResult{
+				Tagged: 1,
+				Errors: []model.TagsAssignmentError{{
+					DeviceID: "2",
+					Error:    "device not found or not matching the filters",
+				}},
+			},
+		},
+		"error, schema violation": {
+			ctx: userCtx,
+			assignment: model.TagsAssignment{
+				Filters: filters,
+				Set:     map[string]string{"floor": "two"},
+			},
+			db: func() *mstore.DataStore {
+				db := &mstore.DataStore{}
+				db.On("GetAttributeDefinitions", userCtx).Return(defs, nil)
+				return db
+			},
+			err: "attribute tags/floor must be of type number: " +
+				"attribute does not conform to the schema",
+		},
+		"error, device token": {
+			ctx: deviceCtx,
+			assignment: model.TagsAssignment{
+				Filters: filters,
+				Unset:   []string{"owner"},
+			},
+			db: func() *mstore.DataStore {
+				return &mstore.DataStore{}
+			},
+			err: "scope tags: writing attributes of the scope is forbidden",
+		},
+		"error, db update": {
+			ctx: userCtx,
+			assignment: model.TagsAssignment{
+				Filters: filters,
+				Unset:   []string{"owner"},
+			},
+			db: func() *mstore.DataStore {
+				db := &mstore.DataStore{}
+				db.On("GetAttributeDefinitions", userCtx).Return(nil, nil)
+				db.On("UpdateDevicesTags", userCtx,
+					mock.Anything, mock.Anything, mock.Anything,
+				).Return(nil, nil, errors.New("db error"))
+				return db
+			},
+			err: "failed to update devices: db error",
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			db := tc.db()
+			defer db.AssertExpectations(t)
+
+			i := invForTest(db)
+			res, err := i.AssignTags(tc.ctx, tc.assignment)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.res, res)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/metrics"
+	"github.com/mendersoftware/inventory/model"
+)
+
+// ErrTimelineBusy is returned when the maximum number of timeline
+// batches is already being ingested; the caller should retry later.
+var ErrTimelineBusy = errors.New("too many timeline batches in progress")
+
+var timelineEventsIngested = metrics.NewCounterVec(
+	"inventory_timeline_events_total",
+	"Number of device timeline events received, by outcome.",
+	"outcome",
+)
+
+// WithTimelineConcurrency limits the number of timeline batches ingested
+// at the same time; the batches over the limit are rejected with
+// ErrTimelineBusy instead of queuing up. Zero means no limit.
+func (i *inventory) WithTimelineConcurrency(n int) InventoryApp {
+	if n > 0 {
+		i.timelineSlots = make(chan struct{}, n)
+	} else {
+		i.timelineSlots = nil
+	}
+	return i
+}
+
+// IngestTimelineEvents adds the events reported by the other services to
+// the device timeline, skipping the duplicates.
+func (i *inventory) IngestTimelineEvents(
+	ctx context.Context,
+	events []model.TimelineEvent,
+) (*model.TimelineIngestResult, error) {
+	if i.timelineSlots != nil {
+		select {
+		case i.timelineSlots <- struct{}{}:
+			defer func() { <-i.timelineSlots }()
+		default:
+			timelineEventsIngested.Add(float64(len(events)), "rejected")
+			return nil, ErrTimelineBusy
+		}
+	}
+
+	n, err := i.db.InsertTimelineEvents(ctx, events)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to ingest timeline events")
+	}
+	res := &model.TimelineIngestResult{
+		Accepted:   n,
+		Duplicates: len(events) - n,
+	}
+	timelineEventsIngested.Add(float64(res.Accepted), "accepted")
+	timelineEventsIngested.Add(float64(res.Duplicates), "duplicate")
+	return res, nil
+}
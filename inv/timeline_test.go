@@ -0,0 +1,62 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/inventory/model"
+	mstore "github.com/mendersoftware/inventory/store/mocks"
+)
+
+func TestInventoryIngestTimelineEvents(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	events := []model.TimelineEvent{
+		{DedupKey: "1", DeviceID: "1", Type: model.TimelineDeploymentStarted},
+		{DedupKey: "2", DeviceID: "1", Type: model.TimelineDeploymentFinished},
+		{DedupKey: "3", DeviceID: "2", Type: model.TimelineAuthStatusChanged},
+	}
+
+	db := &mstore.DataStore{}
+	db.On("InsertTimelineEvents", ctx, events).Return(2, nil).Once()
+	db.On("InsertTimelineEvents", ctx, events).
+		Return(0, errors.New("db error")).Once()
+	i := &inventory{db: db}
+	i.WithTimelineConcurrency(1)
+
+	res, err := i.IngestTimelineEvents(ctx, events)
+	assert.NoError(t, err)
+	assert.Equal(t, &model.TimelineIngestResult{
+		Accepted:   2,
+		Duplicates: 1,
+	}, res)
+
+	_, err = i.IngestTimelineEvents(ctx, events)
+	assert.EqualError(t, err, "failed to ingest timeline events: db error")
+
+	// the batches over the limit are rejected without reaching the store
+	i.timelineSlots <- struct{}{}
+	_, err = i.IngestTimelineEvents(ctx, events)
+	assert.Equal(t, ErrTimelineBusy, err)
+	<-i.timelineSlots
+
+	db.AssertExpectations(t)
+}
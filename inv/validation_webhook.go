@@ -0,0 +1,102 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/validator"
+)
+
+var (
+	// ErrAttributesRejected is returned when the validation webhook of
+	// the tenant rejects the attributes.
+	ErrAttributesRejected = errors.New("attributes rejected by the validation webhook")
+	// ErrValidationUnavailable is returned when the validation webhook
+	// failing closed cannot validate the attributes.
+	ErrValidationUnavailable = errors.New("validation webhook unavailable")
+)
+
+// WithAttributesValidator sets the validator consulting the validation
+// webhooks of the tenants; without it the webhooks are not called.
+func (i *inventory) WithAttributesValidator(v validator.Validator) InventoryApp {
+	i.validator = v
+	return i
+}
+
+// checkValidationWebhook submits the attributes written by a user to
+// the validation webhook of the tenant, if any. When the webhook fails,
+// the write is accepted or rejected according to its failure policy.
+func (i *inventory) checkValidationWebhook(
+	ctx context.Context,
+	id model.DeviceID,
+	attrs model.DeviceAttributes,
+) error {
+	source := model.NewAttributeSource(ctx, time.Time{})
+	if i.validator == nil || source.Type != model.SourceTypeUser {
+		return nil
+	}
+	hook, err := i.db.GetValidationWebhook(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get the validation webhook")
+	} else if hook == nil {
+		return nil
+	}
+	res, err := i.validator.Validate(ctx, *hook, model.AttributesValidationRequest{
+		DeviceID:   id,
+		UserID:     source.ID,
+		Attributes: attrs,
+	})
+	if err != nil {
+		if hook.FailurePolicy == model.ValidationFailOpen {
+			log.FromContext(ctx).Warnf(
+				"accepting attributes of device %s without validation: %v",
+				id, err,
+			)
+			return nil
+		}
+		return errors.Wrap(ErrValidationUnavailable, err.Error())
+	} else if !res.Allowed {
+		return errors.Wrap(ErrAttributesRejected, res.Reason)
+	}
+	return nil
+}
+
+func (i *inventory) GetValidationWebhook(ctx context.Context) (*model.ValidationWebhook, error) {
+	hook, err := i.db.GetValidationWebhook(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get the validation webhook")
+	}
+	return hook, nil
+}
+
+func (i *inventory) SetValidationWebhook(ctx context.Context, hook model.ValidationWebhook) error {
+	if err := i.db.SetValidationWebhook(ctx, hook); err != nil {
+		return errors.Wrap(err, "failed to set the validation webhook")
+	}
+	return nil
+}
+
+func (i *inventory) DeleteValidationWebhook(ctx context.Context) error {
+	if err := i.db.DeleteValidationWebhook(ctx); err != nil {
+		return errors.Wrap(err, "failed to delete the validation webhook")
+	}
+	return nil
+}
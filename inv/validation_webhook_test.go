@@ -0,0 +1,126 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inv
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/inventory/model"
+	mstore "github.com/mendersoftware/inventory/store/mocks"
+	mvalidator "github.com/mendersoftware/inventory/validator/mocks"
+)
+
+func TestInventoryCheckValidationWebhook(t *testing.T) {
+	t.Parallel()
+
+	userCtx := identity.WithContext(context.Background(),
+		&identity.Identity{Subject: "user", IsUser: true})
+	attrs := model.DeviceAttributes{
+		{Scope: model.AttrScopeInventory, Name: "location", Value: "lab"},
+	}
+	req := model.AttributesValidationRequest{
+		DeviceID:   "1",
+		UserID:     "user",
+		Attributes: attrs,
+	}
+
+	testCases := map[string]struct {
+		ctx     context.Context
+		hook    *model.ValidationWebhook
+		hookErr error
+
+		res         *model.AttributesValidationResponse
+		validateErr error
+
+		err string
+	}{
+		"ok, not a user": {
+			ctx: context.Background(),
+		},
+		"ok, no webhook": {
+			ctx: userCtx,
+		},
+		"ok, allowed": {
+			ctx: userCtx,
+			hook: &model.ValidationWebhook{
+				URL:           "https://hooks.example.com",
+				FailurePolicy: model.ValidationFailClosed,
+			},
+			res: &model.AttributesValidationResponse{Allowed: true},
+		},
+		"ok, fail open": {
+			ctx: userCtx,
+			hook: &model.ValidationWebhook{
+				URL:           "https://hooks.example.com",
+				FailurePolicy: model.ValidationFailOpen,
+			},
+			validateErr: errors.New("connection refused"),
+		},
+		"error, rejected": {
+			ctx: userCtx,
+			hook: &model.ValidationWebhook{
+				URL:           "https://hooks.example.com",
+				FailurePolicy: model.ValidationFailOpen,
+			},
+			res: &model.AttributesValidationResponse{
+				Reason: "unknown location",
+			},
+			err: "unknown location: " +
+				"attributes rejected by the validation webhook",
+		},
+		"error, fail closed": {
+			ctx: userCtx,
+			hook: &model.ValidationWebhook{
+				URL:           "https://hooks.example.com",
+				FailurePolicy: model.ValidationFailClosed,
+			},
+			validateErr: errors.New("connection refused"),
+			err:         "connection refused: validation webhook unavailable",
+		},
+		"error, db": {
+			ctx:     userCtx,
+			hookErr: errors.New("db error"),
+			err:     "failed to get the validation webhook: db error",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			db := &mstore.DataStore{}
+			db.On("GetValidationWebhook", tc.ctx).Return(tc.hook, tc.hookErr)
+			v := &mvalidator.Validator{}
+			if tc.hook != nil {
+				v.On("Validate", tc.ctx, *tc.hook, req).
+					Return(tc.res, tc.validateErr)
+			}
+
+			i := invForTest(db).WithAttributesValidator(v)
+			err := i.(*inventory).checkValidationWebhook(tc.ctx, "1", attrs)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+			v.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	api_http "github.com/mendersoftware/inventory/api/http"
+	"github.com/mendersoftware/inventory/config"
+	"github.com/mendersoftware/inventory/verifier"
+)
+
+// listenSettings are the settings of the addresses of the listeners of
+// the route groups.
+var listenSettings = map[api_http.RouteGroup]string{
+	api_http.RouteGroupDevices:    SettingListenDevices,
+	api_http.RouteGroupManagement: SettingListenManagement,
+	api_http.RouteGroupInternal:   SettingListenInternal,
+}
+
+// listener serves a set of route groups on an address.
+type listener struct {
+	Addr        string
+	TLSCert     string
+	TLSKey      string
+	TLSClientCA string
+	Middleware  string
+	Verifier    string
+	JWKSURL     string
+	OIDCIssuer  string
+	Groups      []api_http.RouteGroup
+}
+
+func (ln listener) sameSettings(other listener) bool {
+	return ln.TLSCert == other.TLSCert &&
+		ln.TLSKey == other.TLSKey &&
+		ln.TLSClientCA == other.TLSClientCA &&
+		ln.Middleware == other.Middleware &&
+		ln.Verifier == other.Verifier &&
+		ln.JWKSURL == other.JWKSURL &&
+		ln.OIDCIssuer == other.OIDCIssuer
+}
+
+// makeListeners returns the listeners of the route groups from
+// the configuration. The groups without an address of their own are
+// served on the default listener; groups sharing an address are served
+// by the same listener and must share its settings.
+func makeListeners(c config.Reader) ([]listener, error) {
+	var (
+		listeners []listener
+		shared    []api_http.RouteGroup
+	)
+	add := func(ln listener) error {
+		for n := range listeners {
+			if listeners[n].Addr != ln.Addr {
+				continue
+			}
+			if !listeners[n].sameSettings(ln) {
+				return errors.Errorf(
+					"listener %s: the route groups %v and %v "+
+						"have different settings",
+					ln.Addr, listeners[n].Groups, ln.Groups)
+			}
+			listeners[n].Groups = append(listeners[n].Groups, ln.Groups...)
+			return nil
+		}
+		listeners = append(listeners, ln)
+		return nil
+	}
+
+	for _, group := range api_http.RouteGroups {
+		key := listenSettings[group]
+		if c.GetString(key) == "" {
+			shared = append(shared, group)
+			continue
+		}
+		ln, err := listenerOf(c, key, []api_http.RouteGroup{group})
+		if err != nil {
+			return nil, err
+		}
+		if err := add(ln); err != nil {
+			return nil, err
+		}
+	}
+	if len(shared) > 0 {
+		ln, err := listenerOf(c, SettingListen, shared)
+		if err != nil {
+			return nil, err
+		}
+		if err := add(ln); err != nil {
+			return nil, err
+		}
+	}
+	return listeners, nil
+}
+
+// listenerOf returns the listener of the route groups from the address
+// setting and the settings named after it.
+func listenerOf(
+	c config.Reader,
+	key string,
+	groups []api_http.RouteGroup,
+) (listener, error) {
+	ln := listener{
+		Addr:        c.GetString(key),
+		TLSCert:     c.GetString(key + SettingListenTLSCertSuffix),
+		TLSKey:      c.GetString(key + SettingListenTLSKeySuffix),
+		TLSClientCA: c.GetString(key + SettingListenTLSClientCASuffix),
+		Middleware:  c.GetString(key + SettingListenMiddlewareSuffix),
+		Verifier:    c.GetString(key + SettingListenVerifierSuffix),
+		JWKSURL:     c.GetString(key + SettingListenJWKSURLSuffix),
+		OIDCIssuer:  c.GetString(key + SettingListenOIDCIssuerSuffix),
+		Groups:      groups,
+	}
+	if ln.Middleware == "" {
+		ln.Middleware = c.GetString(SettingMiddleware)
+	}
+	if (ln.TLSCert == "") != (ln.TLSKey == "") {
+		return ln, errors.Errorf(
+			"listener of the %s API: both the TLS certificate "+
+				"and key are required", apiNames(groups))
+	} else if ln.TLSClientCA != "" && ln.TLSCert == "" {
+		return ln, errors.Errorf(
+			"listener of the %s API: client certificates "+
+				"require TLS", apiNames(groups))
+	}
+	switch ln.Verifier {
+	case "", verifier.KindPassthrough:
+	case verifier.KindJWKS:
+		if ln.JWKSURL == "" {
+			return ln, errors.Errorf(
+				"listener of the %s API: the jwks verifier "+
+					"requires a key set URL", apiNames(groups))
+		}
+	case verifier.KindOIDC:
+		if ln.OIDCIssuer == "" {
+			return ln, errors.Errorf(
+				"listener of the %s API: the oidc verifier "+
+					"requires an issuer", apiNames(groups))
+		}
+	case verifier.KindMTLS:
+		if ln.TLSClientCA == "" {
+			return ln, errors.Errorf(
+				"listener of the %s API: the mtls verifier "+
+					"requires client certificates", apiNames(groups))
+		}
+	default:
+		return ln, errors.Errorf(
+			"listener of the %s API: unknown verifier %q",
+			apiNames(groups), ln.Verifier)
+	}
+	return ln, nil
+}
+
+// verifier returns the verifier authenticating the requests of
+// the listener; the passthrough verifier leaves the verification of
+// the tokens to the API gateway.
+func (ln listener) verifier() verifier.Verifier {
+	switch ln.Verifier {
+	case verifier.KindJWKS:
+		return verifier.NewJWKS(ln.JWKSURL, "")
+	case verifier.KindOIDC:
+		return verifier.NewOIDC(ln.OIDCIssuer)
+	case verifier.KindMTLS:
+		return verifier.ClientCert{}
+	default:
+		return verifier.Passthrough{}
+	}
+}
+
+func apiNames(groups []api_http.RouteGroup) string {
+	names := make([]string, len(groups))
+	for n, group := range groups {
+		names[n] = string(group)
+	}
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	last := len(names) - 1
+	return strings.Join(names[:last], ", ") + " and " + names[last]
+}
+
+// server returns the HTTP server of the listener. The TLS certificate is
+// reloaded on SIGHUP and when its files change until the context is
+// canceled.
+func (ln listener) server(
+	ctx context.Context,
+	handler http.Handler,
+) (*http.Server, error) {
+	srv := &http.Server{
+		Addr:    ln.Addr,
+		Handler: handler,
+	}
+	if ln.TLSCert == "" {
+		return srv, nil
+	}
+	certs, err := newCertReloader(ln.TLSCert, ln.TLSKey)
+	if err != nil {
+		return nil, err
+	}
+	srv.TLSConfig = &tls.Config{
+		GetCertificate: certs.GetCertificate,
+	}
+	if ln.TLSClientCA != "" {
+		pem, err := ioutil.ReadFile(ln.TLSClientCA)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read the client CA")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf(
+				"no certificates found in %s", ln.TLSClientCA)
+		}
+		srv.TLSConfig.ClientCAs = pool
+		srv.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	go func() {
+		if err := certs.watch(ctx); err != nil {
+			log.FromContext(ctx).Errorf(
+				"listener %s: the TLS certificate will not be "+
+					"reloaded: %s", ln.Addr, err.Error())
+		}
+	}()
+	return srv, nil
+}
+
+// serve accepts the connections of the listener until it fails.
+func (ln listener) serve(srv *http.Server) error {
+	if srv.TLSConfig != nil {
+		// the certificate is served by TLSConfig.GetCertificate
+		return srv.ListenAndServeTLS("", "")
+	}
+	return srv.ListenAndServe()
+}
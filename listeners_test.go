@@ -0,0 +1,193 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+
+	api_http "github.com/mendersoftware/inventory/api/http"
+	"github.com/mendersoftware/inventory/verifier"
+)
+
+func TestMakeListeners(t *testing.T) {
+	c := viper.New()
+	c.Set(SettingListen, ":8080")
+	c.Set(SettingMiddleware, EnvProd)
+	listeners, err := makeListeners(c)
+	assert.NoError(t, err)
+	assert.Equal(t, []listener{{
+		Addr:       ":8080",
+		Middleware: EnvProd,
+		Groups:     api_http.RouteGroups,
+	}}, listeners)
+
+	c.Set(SettingListenInternal, "127.0.0.1:8083")
+	c.Set(SettingListenInternal+SettingListenTLSCertSuffix, "internal.crt")
+	c.Set(SettingListenInternal+SettingListenTLSKeySuffix, "internal.key")
+	c.Set(SettingListenInternal+SettingListenMiddlewareSuffix, EnvDev)
+	listeners, err = makeListeners(c)
+	assert.NoError(t, err)
+	assert.Equal(t, []listener{{
+		Addr:       "127.0.0.1:8083",
+		TLSCert:    "internal.crt",
+		TLSKey:     "internal.key",
+		Middleware: EnvDev,
+		Groups:     []api_http.RouteGroup{api_http.RouteGroupInternal},
+	}, {
+		Addr:       ":8080",
+		Middleware: EnvProd,
+		Groups: []api_http.RouteGroup{
+			api_http.RouteGroupDevices,
+			api_http.RouteGroupManagement,
+		},
+	}}, listeners)
+
+	// the devices and management APIs share a listener
+	c.Set(SettingListenDevices, ":8081")
+	c.Set(SettingListenManagement, ":8081")
+	listeners, err = makeListeners(c)
+	assert.NoError(t, err)
+	assert.Equal(t, []listener{{
+		Addr:       ":8081",
+		Middleware: EnvProd,
+		Groups: []api_http.RouteGroup{
+			api_http.RouteGroupDevices,
+			api_http.RouteGroupManagement,
+		},
+	}, {
+		Addr:       "127.0.0.1:8083",
+		TLSCert:    "internal.crt",
+		TLSKey:     "internal.key",
+		Middleware: EnvDev,
+		Groups:     []api_http.RouteGroup{api_http.RouteGroupInternal},
+	}}, listeners)
+
+	c.Set(SettingListenManagement+SettingListenMiddlewareSuffix, EnvDev)
+	_, err = makeListeners(c)
+	assert.EqualError(t, err, "listener :8081: the route groups [devices] "+
+		"and [management] have different settings")
+
+	// the default listener takes the TLS settings named after it
+	c = viper.New()
+	c.Set(SettingListen, ":8443")
+	c.Set(SettingListen+SettingListenTLSCertSuffix, "tls.crt")
+	c.Set(SettingListen+SettingListenTLSKeySuffix, "tls.key")
+	listeners, err = makeListeners(c)
+	assert.NoError(t, err)
+	assert.Equal(t, []listener{{
+		Addr:    ":8443",
+		TLSCert: "tls.crt",
+		TLSKey:  "tls.key",
+		Groups:  api_http.RouteGroups,
+	}}, listeners)
+
+	c.Set(SettingListen+SettingListenTLSKeySuffix, "")
+	_, err = makeListeners(c)
+	assert.EqualError(t, err, "listener of the devices, management "+
+		"and internal API: both the TLS certificate and key are required")
+
+	c = viper.New()
+	c.Set(SettingListenInternal, ":8083")
+	c.Set(SettingListenInternal+SettingListenTLSCertSuffix, "internal.crt")
+	_, err = makeListeners(c)
+	assert.EqualError(t, err, "listener of the internal API: "+
+		"both the TLS certificate and key are required")
+
+	c = viper.New()
+	c.Set(SettingListenInternal, ":8083")
+	c.Set(SettingListenInternal+SettingListenTLSClientCASuffix, "ca.crt")
+	_, err = makeListeners(c)
+	assert.EqualError(t, err, "listener of the internal API: "+
+		"client certificates require TLS")
+}
+
+func TestMakeListenersVerifiers(t *testing.T) {
+	c := viper.New()
+	c.Set(SettingListen, ":8080")
+	c.Set(SettingListen+SettingListenVerifierSuffix, verifier.KindOIDC)
+	c.Set(SettingListen+SettingListenOIDCIssuerSuffix, "https://idp.example.com")
+	c.Set(SettingListenInternal, ":8083")
+	c.Set(SettingListenInternal+SettingListenTLSCertSuffix, "internal.crt")
+	c.Set(SettingListenInternal+SettingListenTLSKeySuffix, "internal.key")
+	c.Set(SettingListenInternal+SettingListenTLSClientCASuffix, "ca.crt")
+	c.Set(SettingListenInternal+SettingListenVerifierSuffix, verifier.KindMTLS)
+	listeners, err := makeListeners(c)
+	assert.NoError(t, err)
+	assert.Equal(t, []listener{{
+		Addr:        ":8083",
+		TLSCert:     "internal.crt",
+		TLSKey:      "internal.key",
+		TLSClientCA: "ca.crt",
+		Verifier:    verifier.KindMTLS,
+		Groups:      []api_http.RouteGroup{api_http.RouteGroupInternal},
+	}, {
+		Addr:       ":8080",
+		Verifier:   verifier.KindOIDC,
+		OIDCIssuer: "https://idp.example.com",
+		Groups: []api_http.RouteGroup{
+			api_http.RouteGroupDevices,
+			api_http.RouteGroupManagement,
+		},
+	}}, listeners)
+	assert.IsType(t, verifier.ClientCert{}, listeners[0].verifier())
+	assert.IsType(t, &verifier.OIDC{}, listeners[1].verifier())
+	assert.IsType(t, verifier.Passthrough{}, listener{}.verifier())
+
+	// the devices and management APIs share a listener
+	c.Set(SettingListenDevices, ":8081")
+	c.Set(SettingListenDevices+SettingListenVerifierSuffix, verifier.KindJWKS)
+	c.Set(SettingListenDevices+SettingListenJWKSURLSuffix, "https://example.com/jwks.json")
+	c.Set(SettingListenManagement, ":8081")
+	_, err = makeListeners(c)
+	assert.EqualError(t, err, "listener :8081: the route groups [devices] "+
+		"and [management] have different settings")
+
+	testCases := map[string]struct {
+		verifier string
+		err      string
+	}{
+		"jwks": {
+			verifier: verifier.KindJWKS,
+			err: "listener of the management API: the jwks verifier " +
+				"requires a key set URL",
+		},
+		"oidc": {
+			verifier: verifier.KindOIDC,
+			err: "listener of the management API: the oidc verifier " +
+				"requires an issuer",
+		},
+		"mtls": {
+			verifier: verifier.KindMTLS,
+			err: "listener of the management API: the mtls verifier " +
+				"requires client certificates",
+		},
+		"unknown": {
+			verifier: "useradm",
+			err: "listener of the management API: " +
+				"unknown verifier \"useradm\"",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			c := viper.New()
+			c.Set(SettingListenManagement, ":8082")
+			c.Set(SettingListenManagement+SettingListenVerifierSuffix, tc.verifier)
+			_, err := makeListeners(c)
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
@@ -16,12 +16,18 @@ package main
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"time"
 
+	"github.com/mendersoftware/go-lib-micro/identity"
 	"github.com/mendersoftware/go-lib-micro/log"
 	"github.com/urfave/cli"
+	"gopkg.in/yaml.v2"
 
 	"github.com/mendersoftware/inventory/config"
+	inventory "github.com/mendersoftware/inventory/inv"
+	"github.com/mendersoftware/inventory/model"
 	"github.com/mendersoftware/inventory/store/mongo"
 )
 
@@ -44,6 +50,9 @@ func doMain(args []string) {
 	app := cli.NewApp()
 	app.Usage = "Device Authentication Service"
 	app.Version = CreateVersionString()
+	if fipsMode {
+		app.Version += " (FIPS)"
+	}
 
 	app.Flags = []cli.Flag{
 		cli.StringFlag{
@@ -83,6 +92,13 @@ func doMain(args []string) {
 					Name:  "tenant",
 					Usage: "Takes ID of specific tenant to migrate.",
 				},
+				cli.BoolFlag{
+					Name: "report",
+					Usage: "Print the estimated number of documents " +
+						"and duration of the migrations of each tenant, " +
+						"in the order they are applied, without " +
+						"migrating.",
+				},
 			},
 
 			Action: cmdMigrate,
@@ -107,6 +123,111 @@ func doMain(args []string) {
 
 			Action: cmdMaintenence,
 		},
+		{
+			Name: "export-bundle",
+			Usage: "Export groups, dynamic groups, groups metadata and " +
+				"shared saved filters as a YAML configuration bundle",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "tenant",
+					Usage: "Takes ID of specific tenant to export.",
+				},
+				cli.StringFlag{
+					Name:  "file, f",
+					Usage: "Output `FILE`, defaults to standard output.",
+				},
+			},
+
+			Action: cmdExportBundle,
+		},
+		{
+			Name:  "import-bundle",
+			Usage: "Import a YAML configuration bundle",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "tenant",
+					Usage: "Takes ID of specific tenant to import into.",
+				},
+				cli.StringFlag{
+					Name: "owner",
+					Usage: "ID of the user owning the saved filters created " +
+						"by the import; required if the bundle has new saved filters.",
+				},
+				cli.StringFlag{
+					Name:  "file, f",
+					Usage: "Input `FILE`, defaults to standard input.",
+				},
+			},
+
+			Action: cmdImportBundle,
+		},
+		{
+			Name: "list-tenants",
+			Usage: "List the tenant databases with their number of " +
+				"devices, schema version and storage size",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "json",
+					Usage: "Write the list as JSON.",
+				},
+			},
+
+			Action: cmdListTenants,
+		},
+		{
+			Name: "cutover-groups",
+			Usage: "Backfill the groups array of all the tenants and " +
+				"switch reading the group membership to it",
+			Action: cmdCutoverGroups,
+		},
+		{
+			Name: "anonymize",
+			Usage: "Export the devices as newline-delimited JSON with " +
+				"the device IDs and the identifying attribute values, " +
+				"e.g. MACs, serials and hostnames, pseudonymized",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "tenant",
+					Usage: "Takes ID of specific tenant to export.",
+				},
+				cli.StringFlag{
+					Name:  "out, o",
+					Usage: "Output `FILE`, defaults to standard output.",
+				},
+				cli.StringFlag{
+					Name: "key",
+					Usage: "Pseudonymization `KEY`: the exports with the " +
+						"same key share the pseudonyms. Defaults to " +
+						"a random key.",
+				},
+				cli.StringSliceFlag{
+					Name: "attribute, a",
+					Usage: "Pseudonymize the `SCOPE/NAME` attribute, or " +
+						"the whole SCOPE, too. Flag can be provided " +
+						"multiple times.",
+				},
+			},
+
+			Action: cmdAnonymize,
+		},
+		{
+			Name: "doctor",
+			Usage: "Check the connection to the database, the schema " +
+				"version, the indexes and the documents, and report " +
+				"the problems found with the hints to remediate them",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "json",
+					Usage: "Write the report as JSON.",
+				},
+				cli.BoolFlag{
+					Name:  "no-color",
+					Usage: "Do not color the report.",
+				},
+			},
+
+			Action: cmdDoctor,
+		},
 	}
 
 	app.Action = cmdServer
@@ -124,6 +245,9 @@ func doMain(args []string) {
 		config.Config.SetEnvPrefix("INVENTORY")
 		config.Config.AutomaticEnv()
 
+		if err := checkFIPS(config.Config); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
 		return nil
 	}
 
@@ -139,6 +263,26 @@ func makeDataStoreConfig() mongo.DataStoreMongoConfig {
 
 		Username: config.Config.GetString(SettingDbUsername),
 		Password: config.Config.GetString(SettingDbPassword),
+
+		UnavailableThreshold: time.Duration(
+			config.Config.GetInt(SettingDbUnavailableThreshold),
+		) * time.Second,
+
+		DbNames: mongo.DbNames{
+			Database:     config.Config.GetString(SettingDbName),
+			TenantPrefix: config.Config.GetString(SettingDbTenantPrefix),
+			Devices:      config.Config.GetString(SettingDbDevicesCollection),
+		},
+
+		GroupsDualWrite: config.Config.GetBool(SettingSchemaRolloutGroups),
+
+		SearchExplainSampleRate: config.Config.GetInt(SettingDbSearchExplainSampleRate),
+
+		AttributeCompressionThreshold: config.Config.GetInt(
+			SettingDbAttributeCompressionThreshold,
+		),
+
+		DeviceTelemetry: config.Config.GetBool(SettingDeviceTelemetry),
 	}
 
 }
@@ -191,10 +335,14 @@ func cmdMigrate(args *cli.Context) error {
 	l.Printf("Inventory Service, version %s starting up",
 		CreateVersionString())
 
+	action := "migrating"
+	if args.Bool("report") {
+		action = "estimating the migrations of"
+	}
 	if tenantId != "" {
-		l.Printf("migrating tenant %v", tenantId)
+		l.Printf("%s tenant %v", action, tenantId)
 	} else {
-		l.Printf("migrating all the tenants")
+		l.Printf("%s all the tenants", action)
 	}
 
 	db, err := mongo.NewDataStoreMongo(makeDataStoreConfig())
@@ -205,11 +353,30 @@ func cmdMigrate(args *cli.Context) error {
 			3)
 	}
 
+	ctx := context.Background()
+
+	if args.Bool("report") {
+		var tenantIDs []string
+		if tenantId != "" {
+			tenantIDs = append(tenantIDs, tenantId)
+		}
+		report, err := db.MigrationReport(ctx, mongo.DbVersion, tenantIDs...)
+		if err != nil {
+			return cli.NewExitError(
+				fmt.Sprintf("failed to estimate migrations: %v", err),
+				3)
+		}
+		if err := writeMigrationReport(os.Stdout, report); err != nil {
+			return cli.NewExitError(
+				fmt.Sprintf("failed to write the report: %v", err),
+				3)
+		}
+		return nil
+	}
+
 	// we want to apply migrations
 	db = db.WithAutomigrate()
 
-	ctx := context.Background()
-
 	if tenantId != "" {
 		err = db.MigrateTenant(ctx, mongo.DbVersion, tenantId)
 	} else {
@@ -257,3 +424,121 @@ func cmdMaintenence(args *cli.Context) error {
 
 	return nil
 }
+
+func tenantContext(tenantID string) context.Context {
+	ctx := context.Background()
+	if tenantID != "" {
+		ctx = identity.WithContext(ctx, &identity.Identity{
+			Tenant: tenantID,
+		})
+	}
+	return ctx
+}
+
+func cmdExportBundle(args *cli.Context) error {
+	db, err := mongo.NewDataStoreMongo(makeDataStoreConfig())
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("failed to connect to db: %v", err),
+			3)
+	}
+
+	inv := inventory.NewInventory(db)
+	bundle, err := inv.ExportConfigBundle(tenantContext(args.String("tenant")))
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("failed to export bundle: %v", err),
+			5)
+	}
+	data, err := yaml.Marshal(bundle)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 5)
+	}
+
+	if path := args.String("file"); path != "" {
+		err = ioutil.WriteFile(path, data, 0644)
+	} else {
+		_, err = os.Stdout.Write(data)
+	}
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("failed to write bundle: %v", err),
+			5)
+	}
+	return nil
+}
+
+func cmdImportBundle(args *cli.Context) error {
+	l := log.New(log.Ctx{})
+
+	var (
+		data []byte
+		err  error
+	)
+	if path := args.String("file"); path != "" {
+		data, err = ioutil.ReadFile(path)
+	} else {
+		data, err = ioutil.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("failed to read bundle: %v", err),
+			5)
+	}
+	var bundle model.ConfigBundle
+	if err = yaml.UnmarshalStrict(data, &bundle); err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("failed to parse bundle: %v", err),
+			5)
+	}
+
+	db, err := mongo.NewDataStoreMongo(makeDataStoreConfig())
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("failed to connect to db: %v", err),
+			3)
+	}
+
+	ctx := tenantContext(args.String("tenant"))
+	if owner := args.String("owner"); owner != "" {
+		ctx = identity.WithContext(ctx, &identity.Identity{
+			Tenant:  args.String("tenant"),
+			Subject: owner,
+			IsUser:  true,
+		})
+	}
+	inv := inventory.NewInventory(db)
+	result, err := inv.ImportConfigBundle(ctx, bundle)
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("failed to import bundle: %v", err),
+			5)
+	}
+	l.Infof("imported %d groups: %d devices matched, %d devices updated; "+
+		"%d dynamic groups, %d groups metadata and %d saved filters",
+		len(bundle.Groups), result.MatchedCount, result.UpdatedCount,
+		len(bundle.DynamicGroups), len(bundle.GroupsMetadata),
+		len(bundle.SavedFilters))
+	return nil
+}
+
+func cmdCutoverGroups(args *cli.Context) error {
+	l := log.New(log.Ctx{})
+
+	db, err := mongo.NewDataStoreMongo(makeDataStoreConfig())
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("failed to connect to db: %v", err),
+			3)
+	}
+
+	inv := inventory.NewInventory(db)
+	ctx := log.WithContext(context.Background(), l)
+	if err = inv.CutoverGroups(ctx); err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("failed to cut over groups: %v", err),
+			6)
+	}
+	l.Infof("reading the group membership from the groups array")
+	return nil
+}
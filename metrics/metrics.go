@@ -0,0 +1,203 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+// Package metrics implements a minimal registry of counters and gauges
+// exposed in the Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	typeCounter = "counter"
+	typeGauge   = "gauge"
+
+	// ContentType is the content type of the exposition format.
+	ContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]*metricVec{}
+)
+
+type metricVec struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]*sample
+}
+
+type sample struct {
+	labelValues []string
+	value       float64
+}
+
+func newMetricVec(name, help, typ string, labels []string) *metricVec {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if m, ok := registry[name]; ok {
+		if m.typ != typ {
+			panic(fmt.Sprintf("metrics: %s registered with type %s", name, m.typ))
+		}
+		return m
+	}
+	m := &metricVec{
+		name:   name,
+		help:   help,
+		typ:    typ,
+		labels: labels,
+		values: map[string]*sample{},
+	}
+	registry[name] = m
+	return m
+}
+
+func (m *metricVec) sample(labelValues []string) *sample {
+	if len(labelValues) != len(m.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d",
+			m.name, len(m.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := m.values[key]
+	if !ok {
+		s = &sample{labelValues: append([]string{}, labelValues...)}
+		m.values[key] = s
+	}
+	return s
+}
+
+// CounterVec is a monotonically increasing value partitioned by labels.
+type CounterVec struct {
+	m *metricVec
+}
+
+// NewCounterVec registers a counter with the given name and label names;
+// registering the same name twice returns the existing counter.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{m: newMetricVec(name, help, typeCounter, labels)}
+}
+
+// Add increases the counter by a non-negative value.
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	c.m.mu.Lock()
+	defer c.m.mu.Unlock()
+	c.m.sample(labelValues).value += v
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// GaugeVec is a value which can go up and down, partitioned by labels.
+type GaugeVec struct {
+	m *metricVec
+}
+
+// NewGaugeVec registers a gauge with the given name and label names;
+// registering the same name twice returns the existing gauge.
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{m: newMetricVec(name, help, typeGauge, labels)}
+}
+
+func (g *GaugeVec) Set(v float64, labelValues ...string) {
+	g.m.mu.Lock()
+	defer g.m.mu.Unlock()
+	g.m.sample(labelValues).value = v
+}
+
+func (g *GaugeVec) Add(v float64, labelValues ...string) {
+	g.m.mu.Lock()
+	defer g.m.mu.Unlock()
+	g.m.sample(labelValues).value += v
+}
+
+// Value returns the current value of the metric with the given name and
+// label values; mainly useful in tests.
+func Value(name string, labelValues ...string) float64 {
+	registryMu.Lock()
+	m, ok := registry[name]
+	registryMu.Unlock()
+	if !ok {
+		return 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.values[strings.Join(labelValues, "\xff")]; ok {
+		return s.value
+	}
+	return 0
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// WriteText writes all the registered metrics in the text exposition format.
+func WriteText(w io.Writer) error {
+	registryMu.Lock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	registryMu.Unlock()
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		registryMu.Lock()
+		m := registry[name]
+		registryMu.Unlock()
+
+		fmt.Fprintf(&b, "# HELP %s %s\n", m.name, m.help)
+		fmt.Fprintf(&b, "# TYPE %s %s\n", m.name, m.typ)
+		m.mu.Lock()
+		keys := make([]string, 0, len(m.values))
+		for key := range m.values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			s := m.values[key]
+			b.WriteString(m.name)
+			if len(m.labels) > 0 {
+				b.WriteByte('{')
+				for i, label := range m.labels {
+					if i > 0 {
+						b.WriteByte(',')
+					}
+					fmt.Fprintf(&b, `%s="%s"`, label,
+						labelValueEscaper.Replace(s.labelValues[i]))
+				}
+				b.WriteByte('}')
+			}
+			b.WriteByte(' ')
+			b.WriteString(strconv.FormatFloat(s.value, 'g', -1, 64))
+			b.WriteByte('\n')
+		}
+		m.mu.Unlock()
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
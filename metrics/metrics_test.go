@@ -0,0 +1,56 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	counter := NewCounterVec("test_removed_total", "Removed things.", "scope", "name")
+	counter.Add(2, "inventory", "mac")
+	counter.Inc("inventory", "mac")
+	counter.Inc("telemetry", `gps "fix"`)
+	counter.Add(-1, "telemetry", `gps "fix"`)
+	assert.Equal(t, float64(3), Value("test_removed_total", "inventory", "mac"))
+
+	// registering the same name returns the same metric
+	assert.Equal(t, counter.m, NewCounterVec("test_removed_total", "").m)
+	assert.Panics(t, func() {
+		NewGaugeVec("test_removed_total", "")
+	})
+
+	gauge := NewGaugeVec("test_up", "Whether things are up.")
+	gauge.Set(1)
+	gauge.Add(-1)
+	assert.Panics(t, func() {
+		gauge.Set(1, "unexpected")
+	})
+
+	var b bytes.Buffer
+	assert.NoError(t, WriteText(&b))
+	assert.Contains(t, b.String(),
+		"# HELP test_removed_total Removed things.\n"+
+			"# TYPE test_removed_total counter\n"+
+			`test_removed_total{scope="inventory",name="mac"} 3`+"\n"+
+			`test_removed_total{scope="telemetry",name="gps \"fix\""} 1`+"\n")
+	assert.Contains(t, b.String(),
+		"# HELP test_up Whether things are up.\n"+
+			"# TYPE test_up gauge\n"+
+			"test_up 0\n")
+}
@@ -15,6 +15,7 @@ package main
 
 import (
 	"fmt"
+	"mime"
 	"net/http"
 
 	"github.com/ant0ine/go-json-rest/rest"
@@ -24,6 +25,8 @@ import (
 	log "github.com/mendersoftware/go-lib-micro/log"
 	"github.com/mendersoftware/go-lib-micro/requestid"
 	"github.com/mendersoftware/go-lib-micro/requestlog"
+
+	"github.com/mendersoftware/inventory/utils/reqctx"
 )
 
 const (
@@ -104,12 +107,13 @@ var (
 
 		// verifies the request Content-Type header
 		// The expected Content-Type is 'application/json'
-		// if the content is non-null
-		&rest.ContentTypeCheckerMiddleware{},
+		// if the content is non-null, 'text/csv' or YAML for uploads
+		&contentTypeCheckerMiddleware{},
 		&requestid.RequestIdMiddleware{},
 		&identity.IdentityMiddleware{
 			UpdateLogger: true,
 		},
+		&reqctx.Middleware{},
 	}
 
 	middlewareMap = map[string][]rest.Middleware{
@@ -118,6 +122,26 @@ var (
 	}
 )
 
+// contentTypeCheckerMiddleware lets the CSV and YAML uploads through the
+// check of the JSON content type; the handlers accepting them verify the
+// type.
+type contentTypeCheckerMiddleware struct {
+	rest.ContentTypeCheckerMiddleware
+}
+
+func (mw *contentTypeCheckerMiddleware) MiddlewareFunc(h rest.HandlerFunc) rest.HandlerFunc {
+	checked := mw.ContentTypeCheckerMiddleware.MiddlewareFunc(h)
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		mediatype, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediatype {
+		case "text/csv", "application/yaml", "application/x-yaml":
+			h(w, r)
+			return
+		}
+		checked(w, r)
+	}
+}
+
 func SetupMiddleware(api *rest.Api, mwtype string) error {
 
 	l := log.New(log.Ctx{})
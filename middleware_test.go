@@ -14,9 +14,12 @@
 package main
 
 import (
+	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
 )
 
 func TestSetupMiddleware(t *testing.T) {
@@ -41,3 +44,28 @@ func TestSetupMiddleware(t *testing.T) {
 		}
 	}
 }
+
+func TestContentTypeCheckerMiddleware(t *testing.T) {
+	testCases := map[string]int{
+		"application/json":                http.StatusOK,
+		"application/json; charset=UTF-8": http.StatusOK,
+		"text/csv":                        http.StatusOK,
+		"text/csv; charset=utf-8":         http.StatusOK,
+		"application/yaml":                http.StatusOK,
+		"application/x-yaml":              http.StatusOK,
+		"text/plain":                      http.StatusUnsupportedMediaType,
+	}
+	for contentType, code := range testCases {
+		api := rest.NewApi()
+		api.Use(&contentTypeCheckerMiddleware{})
+		api.SetApp(rest.AppSimple(func(w rest.ResponseWriter, r *rest.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		req, _ := http.NewRequest(http.MethodPost, "http://localhost/",
+			strings.NewReader("a,b,c"))
+		req.Header.Set("Content-Type", contentType)
+		recorded := test.RunRequest(t, api.MakeHandler(), req)
+		recorded.CodeIs(code)
+	}
+}
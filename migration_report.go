@@ -0,0 +1,75 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+// estimate rounds the estimated duration to the precision worth
+// reading.
+func estimate(d time.Duration) time.Duration {
+	if d < time.Second {
+		return d.Round(time.Millisecond)
+	}
+	return d.Round(time.Second)
+}
+
+// writeMigrationReport writes the plan of the migrations for a human,
+// the databases in the order they are migrated.
+func writeMigrationReport(w io.Writer, report *model.MigrationReport) error {
+	if _, err := fmt.Fprintf(w,
+		"Migration of %d databases to version %s, estimated duration %s\n",
+		len(report.Tenants), report.Version, estimate(report.Duration),
+	); err != nil {
+		return err
+	}
+	for n, plan := range report.Tenants {
+		name := plan.Database
+		if plan.TenantID != "" {
+			name += " (tenant " + plan.TenantID + ")"
+		}
+		if _, err := fmt.Fprintf(w, "\n%d. %s at version %s, %d devices\n",
+			n+1, name, plan.Version, plan.Devices); err != nil {
+			return err
+		}
+		if len(plan.Steps) == 0 {
+			if _, err := fmt.Fprintln(w, "   up to date"); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "   starts after %s, lasts %s\n",
+			estimate(plan.Start), estimate(plan.Duration)); err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, step := range plan.Steps {
+			if _, err := fmt.Fprintf(tw, "   %s\t%s\t%d documents\t%s\n",
+				step.Version, step.Collection, step.Documents,
+				estimate(step.Duration)); err != nil {
+				return err
+			}
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
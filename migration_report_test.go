@@ -0,0 +1,63 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+func TestWriteMigrationReport(t *testing.T) {
+	report := &model.MigrationReport{Version: "1.0.9"}
+	report.Add(model.TenantMigrationPlan{
+		TenantID: "foo",
+		Database: "inventory-foo",
+		Version:  "1.0.7",
+		Devices:  120000,
+		Steps: []model.MigrationStep{{
+			Version:    "1.0.8",
+			Collection: "devices",
+			Documents:  120000,
+			Duration:   1200 * time.Millisecond,
+		}, {
+			Version:    "1.0.9",
+			Collection: "devices",
+			Documents:  90000,
+			Duration:   9 * time.Second,
+		}},
+	})
+	report.Add(model.TenantMigrationPlan{
+		TenantID: "bar",
+		Database: "inventory-bar",
+		Version:  "1.0.9",
+		Devices:  10,
+	})
+
+	var buf bytes.Buffer
+	assert.NoError(t, writeMigrationReport(&buf, report))
+	assert.Equal(t,
+		"Migration of 2 databases to version 1.0.9, estimated duration 10s\n"+
+			"\n1. inventory-foo (tenant foo) at version 1.0.7, 120000 devices\n"+
+			"   starts after 0s, lasts 10s\n"+
+			"   1.0.8  devices  120000 documents  1s\n"+
+			"   1.0.9  devices  90000 documents   9s\n"+
+			"\n2. inventory-bar (tenant bar) at version 1.0.9, 10 devices\n"+
+			"   up to date\n",
+		buf.String())
+}
@@ -0,0 +1,121 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// The agents report the attributes of a device besides its client, e.g.
+// a companion agent or the gateway the device is connected through. Each
+// agent writes into its own sub-scope of the reported scope, e.g.
+// inventory:gateway, with its own source and timestamps, so the agents
+// do not overwrite each other's attributes.
+
+const (
+	// AgentScopeSeparator separates the scope from the agent in the names
+	// of the sub-scopes.
+	AgentScopeSeparator = ":"
+	// AgentClient is the client of the device, writing into the scope
+	// itself.
+	AgentClient = "client"
+)
+
+var validAgentNameRegex = regexp.MustCompile("^[a-z0-9_]{1,32}$")
+
+// ValidateAgentName verifies the name of an agent: up to 32 lower case
+// letters, digits and underscores.
+func ValidateAgentName(agent string) error {
+	if !validAgentNameRegex.MatchString(agent) {
+		return errors.Errorf("invalid agent name %q: must be up to 32 "+
+			"lower case letters, digits and underscores", agent)
+	}
+	return nil
+}
+
+// AgentScope returns the sub-scope of the scope written by the agent.
+func AgentScope(scope, agent string) string {
+	if agent == "" || agent == AgentClient {
+		return scope
+	}
+	return scope + AgentScopeSeparator + agent
+}
+
+// SplitAgentScope returns the scope and the agent writing the sub-scope;
+// the scopes themselves are written by the client.
+func SplitAgentScope(scope string) (base, agent string) {
+	if idx := strings.Index(scope, AgentScopeSeparator); idx >= 0 {
+		return scope[:idx], scope[idx+len(AgentScopeSeparator):]
+	}
+	return scope, AgentClient
+}
+
+// MergeAgentScopes folds the sub-scopes of the agents into their scopes,
+// recording the agent in the attributes of the scopes reported by agents. Of the values of an attribute
+// reported by several agents, the value of the agent listed first in
+// the precedence wins; the agents left out of the precedence follow,
+// the last one to write the sub-scope first.
+func MergeAgentScopes(dev *Device, precedence []string) {
+	if dev == nil {
+		return
+	}
+	rank := func(attr DeviceAttribute) (int, int64, string) {
+		_, agent := SplitAgentScope(attr.Scope)
+		for n, name := range precedence {
+			if name == agent {
+				return n, 0, agent
+			}
+		}
+		return len(precedence), -dev.Sources[attr.Scope].Timestamp.UnixNano(), agent
+	}
+	precedes := func(a, b DeviceAttribute) bool {
+		rankA, tsA, agentA := rank(a)
+		rankB, tsB, agentB := rank(b)
+		if rankA != rankB {
+			return rankA < rankB
+		} else if tsA != tsB {
+			return tsA < tsB
+		}
+		return agentA < agentB
+	}
+
+	merged := make(DeviceAttributes, 0, len(dev.Attributes))
+	index := make(map[[2]string]int, len(dev.Attributes))
+	reported := map[string]bool{}
+	for _, attr := range dev.Attributes {
+		base, agent := SplitAgentScope(attr.Scope)
+		if agent != AgentClient {
+			reported[base] = true
+		}
+		key := [2]string{base, attr.Name}
+		if n, ok := index[key]; !ok {
+			index[key] = len(merged)
+			merged = append(merged, attr)
+		} else if precedes(attr, merged[n]) {
+			merged[n] = attr
+		}
+	}
+	for n := range merged {
+		// the agents are only recorded in the scopes they report
+		base, agent := SplitAgentScope(merged[n].Scope)
+		merged[n].Scope = base
+		if reported[base] {
+			merged[n].Agent = agent
+		}
+	}
+	dev.Attributes = merged
+}
@@ -0,0 +1,97 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAgentScope(t *testing.T) {
+	assert.Equal(t, "inventory", AgentScope("inventory", ""))
+	assert.Equal(t, "inventory", AgentScope("inventory", AgentClient))
+	assert.Equal(t, "inventory:gateway", AgentScope("inventory", "gateway"))
+
+	base, agent := SplitAgentScope("inventory:gateway")
+	assert.Equal(t, "inventory", base)
+	assert.Equal(t, "gateway", agent)
+	base, agent = SplitAgentScope("inventory")
+	assert.Equal(t, "inventory", base)
+	assert.Equal(t, AgentClient, agent)
+
+	assert.NoError(t, ValidateAgentName("companion_2"))
+	for _, name := range []string{"", "Gateway", "a:b", "a-b",
+		"abcdefghijklmnopqrstuvwxyz0123456"} {
+		assert.Error(t, ValidateAgentName(name), name)
+	}
+}
+
+func TestMergeAgentScopes(t *testing.T) {
+	ts := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+	device := func() *Device {
+		return &Device{
+			ID: "1",
+			Attributes: DeviceAttributes{
+				{Scope: "identity", Name: "mac", Value: "00:01"},
+				{Scope: "inventory", Name: "ip", Value: "10.0.0.1"},
+				{Scope: "inventory:gateway", Name: "ip", Value: "10.0.0.2"},
+				{Scope: "inventory:companion", Name: "ip", Value: "10.0.0.3"},
+				{Scope: "inventory:companion", Name: "cpu", Value: 40.0},
+			},
+			Sources: map[string]AttributeSource{
+				"inventory":           {Timestamp: ts},
+				"inventory:gateway":   {Timestamp: ts.Add(2 * time.Minute)},
+				"inventory:companion": {Timestamp: ts.Add(time.Minute)},
+			},
+		}
+	}
+	testCases := map[string]struct {
+		precedence []string
+		attributes DeviceAttributes
+	}{
+		"latest agent first": {
+			attributes: DeviceAttributes{
+				{Scope: "identity", Name: "mac", Value: "00:01"},
+				{Scope: "inventory", Name: "ip", Value: "10.0.0.2", Agent: "gateway"},
+				{Scope: "inventory", Name: "cpu", Value: 40.0, Agent: "companion"},
+			},
+		},
+		"precedence": {
+			precedence: []string{"companion", AgentClient},
+			attributes: DeviceAttributes{
+				{Scope: "identity", Name: "mac", Value: "00:01"},
+				{Scope: "inventory", Name: "ip", Value: "10.0.0.3", Agent: "companion"},
+				{Scope: "inventory", Name: "cpu", Value: 40.0, Agent: "companion"},
+			},
+		},
+		"partial precedence": {
+			precedence: []string{"unknown", AgentClient},
+			attributes: DeviceAttributes{
+				{Scope: "identity", Name: "mac", Value: "00:01"},
+				{Scope: "inventory", Name: "ip", Value: "10.0.0.1", Agent: AgentClient},
+				{Scope: "inventory", Name: "cpu", Value: 40.0, Agent: "companion"},
+			},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			dev := device()
+			MergeAgentScopes(dev, tc.precedence)
+			assert.Equal(t, tc.attributes, dev.Attributes)
+			assert.Equal(t, device().Sources, dev.Sources)
+		})
+	}
+}
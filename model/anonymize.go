@@ -0,0 +1,27 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+// AnonymizeOptions selects the pseudonymization of an anonymized export
+// of the devices.
+type AnonymizeOptions struct {
+	// Key of the pseudonyms: a value gets the same pseudonym everywhere
+	// in the exports with the same key.
+	Key []byte
+	// Attributes are pseudonymized in addition to the identity ones
+	// and the ones with identifying names; an empty attribute name
+	// selects the whole scope.
+	Attributes []SelectAttribute
+}
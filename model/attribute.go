@@ -17,4 +17,26 @@ type FilterAttribute struct {
 	Name  string `json:"name" bson:"name"`
 	Scope string `json:"scope" bson:"scope"`
 	Count int32  `json:"count" bson:"count"`
+	// Description of the attribute from the catalog of the tenant
+	Description string `json:"description,omitempty" bson:"-"`
+}
+
+// AttributeValueCount is the number of devices sharing an attribute value.
+type AttributeValueCount struct {
+	Value interface{} `json:"value" bson:"_id"`
+	Count int         `json:"count" bson:"count"`
+}
+
+// AttributeStatistics is the distribution of the values of an attribute
+// aggregated across tenants, with tenant identifiers stripped. The number
+// of devices is exact; the values are the most common ones, and their
+// counts are lower bounds if Approximate is set, when the less common
+// values of some tenants were not collected.
+type AttributeStatistics struct {
+	Name        string                `json:"name"`
+	Scope       string                `json:"scope"`
+	Tenants     int                   `json:"tenants"`
+	Devices     int                   `json:"devices"`
+	Approximate bool                  `json:"approximate"`
+	Values      []AttributeValueCount `json:"values"`
 }
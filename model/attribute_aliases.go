@@ -0,0 +1,153 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pkg/errors"
+)
+
+const (
+	// AttributeAliasesMax is the maximum number of attributes a tenant
+	// can give aliases to.
+	AttributeAliasesMax = 100
+	// AttributeAliasNamesMax is the maximum number of aliases of an
+	// attribute.
+	AttributeAliasNamesMax = 10
+)
+
+// AttributeAlias lists the other names the devices report an attribute
+// under, e.g. the names used by older clients; the filters, the sorts and
+// the attribute catalog treat them as the same attribute.
+type AttributeAlias struct {
+	Scope   string   `json:"scope" bson:"scope"`
+	Name    string   `json:"name" bson:"name"`
+	Aliases []string `json:"aliases" bson:"aliases"`
+}
+
+func (a AttributeAlias) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.Scope, validation.Required,
+			validation.NotIn(AttrScopeIdentity, AttrScopeSystem, AttrScopeTelemetry)),
+		validation.Field(&a.Name, validation.Required, validation.Length(1, 1024)),
+		validation.Field(&a.Aliases, validation.Required,
+			validation.Length(1, AttributeAliasNamesMax),
+			validation.Each(validation.Required, validation.Length(1, 1024))),
+	)
+}
+
+// AttributeAliases are the aliases of the attributes of a tenant.
+type AttributeAliases struct {
+	Attributes []AttributeAlias `json:"attributes" bson:"attributes"`
+}
+
+// Validate checks the aliases and that each name of a scope belongs to
+// a single attribute, so that the aliases do not chain.
+func (a AttributeAliases) Validate() error {
+	if len(a.Attributes) > AttributeAliasesMax {
+		return errors.Errorf("too many attributes: the maximum is %d",
+			AttributeAliasesMax)
+	}
+	seen := make(map[SelectAttribute]bool)
+	for _, attr := range a.Attributes {
+		if err := attr.Validate(); err != nil {
+			return errors.Wrapf(err, "%s/%s", attr.Scope, attr.Name)
+		}
+		for _, name := range append([]string{attr.Name}, attr.Aliases...) {
+			key := SelectAttribute{Scope: attr.Scope, Attribute: name}
+			if seen[key] {
+				return errors.Errorf("%s/%s: the name %s is used more than once",
+					attr.Scope, attr.Name, name)
+			}
+			seen[key] = true
+		}
+	}
+	return nil
+}
+
+// resolve returns the attribute the name of the scope stands for, and
+// false if the name has no aliases.
+func (a AttributeAliases) resolve(scope, name string) (AttributeAlias, bool) {
+	for _, attr := range a.Attributes {
+		if attr.Scope != scope {
+			continue
+		}
+		if attr.Name == name {
+			return attr, true
+		}
+		for _, alias := range attr.Aliases {
+			if alias == name {
+				return attr, true
+			}
+		}
+	}
+	return AttributeAlias{}, false
+}
+
+// Apply resolves the aliases in the filters and the sorts of the search:
+// the filters and the sorts by an attribute, or by any of its aliases,
+// apply to the attribute under all its names. The sorts of the keyset
+// pagination are left as they are, as its cursor holds the values of
+// the sort attributes under their names.
+func (a AttributeAliases) Apply(params SearchParams) SearchParams {
+	if len(a.Attributes) == 0 {
+		return params
+	}
+	if len(params.Filters) > 0 {
+		filters := make([]FilterPredicate, len(params.Filters))
+		for n, filter := range params.Filters {
+			if attr, ok := a.resolve(filter.Scope, filter.Attribute); ok {
+				filter.Attribute = attr.Name
+				filter.Aliases = attr.Aliases
+			}
+			filters[n] = filter
+		}
+		params.Filters = filters
+	}
+	if len(params.Sort) > 0 && params.Cursor == nil {
+		sort := make([]SortCriteria, len(params.Sort))
+		for n, s := range params.Sort {
+			if attr, ok := a.resolve(s.Scope, s.Attribute); ok {
+				s.Attribute = attr.Name
+				s.Aliases = attr.Aliases
+			}
+			sort[n] = s
+		}
+		params.Sort = sort
+	}
+	return params
+}
+
+// MergeFilterAttributes merges the aliases of the attributes into
+// the attributes, adding up their counts.
+func (a AttributeAliases) MergeFilterAttributes(attrs []FilterAttribute) []FilterAttribute {
+	if len(a.Attributes) == 0 {
+		return attrs
+	}
+	merged := make([]FilterAttribute, 0, len(attrs))
+	index := make(map[SelectAttribute]int, len(attrs))
+	for _, attr := range attrs {
+		if alias, ok := a.resolve(attr.Scope, attr.Name); ok {
+			attr.Name = alias.Name
+		}
+		key := SelectAttribute{Scope: attr.Scope, Attribute: attr.Name}
+		if n, ok := index[key]; ok {
+			merged[n].Count += attr.Count
+			continue
+		}
+		index[key] = len(merged)
+		merged = append(merged, attr)
+	}
+	return merged
+}
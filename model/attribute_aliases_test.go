@@ -0,0 +1,93 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttributeAliasesValidate(t *testing.T) {
+	assert.NoError(t, AttributeAliases{}.Validate())
+	assert.NoError(t, AttributeAliases{Attributes: []AttributeAlias{
+		{Scope: AttrScopeInventory, Name: "ip_address", Aliases: []string{"ipv4"}},
+		{Scope: AttrScopeTags, Name: "ipv4", Aliases: []string{"ip"}},
+	}}.Validate())
+	assert.EqualError(t, AttributeAliases{Attributes: []AttributeAlias{
+		{Scope: AttrScopeInventory, Name: "ip_address"},
+	}}.Validate(), "inventory/ip_address: aliases: cannot be blank.")
+	assert.EqualError(t, AttributeAliases{Attributes: []AttributeAlias{
+		{Scope: AttrScopeIdentity, Name: "mac", Aliases: []string{"mac_address"}},
+	}}.Validate(), "identity/mac: scope: must not be in list.")
+	assert.EqualError(t, AttributeAliases{Attributes: []AttributeAlias{
+		{Scope: AttrScopeInventory, Name: "ip_address", Aliases: []string{"ipv4"}},
+		{Scope: AttrScopeInventory, Name: "ipv4", Aliases: []string{"ip"}},
+	}}.Validate(), "inventory/ipv4: the name ipv4 is used more than once")
+}
+
+func TestAttributeAliasesApply(t *testing.T) {
+	aliases := AttributeAliases{Attributes: []AttributeAlias{{
+		Scope:   AttrScopeInventory,
+		Name:    "ip_address",
+		Aliases: []string{"ipv4", "ip"},
+	}}}
+	filters := []FilterPredicate{
+		{Scope: AttrScopeInventory, Attribute: "ip", Type: "$eq", Value: "10.0.0.1"},
+		{Scope: AttrScopeTags, Attribute: "ip", Type: "$eq", Value: "10.0.0.1"},
+	}
+	sort := []SortCriteria{
+		{Scope: AttrScopeInventory, Attribute: "ip_address", Order: "asc"},
+	}
+	params := aliases.Apply(SearchParams{Filters: filters, Sort: sort})
+	assert.Equal(t, SearchParams{
+		Filters: []FilterPredicate{{
+			Scope:     AttrScopeInventory,
+			Attribute: "ip_address",
+			Aliases:   []string{"ipv4", "ip"},
+			Type:      "$eq",
+			Value:     "10.0.0.1",
+		}, filters[1]},
+		Sort: []SortCriteria{{
+			Scope:     AttrScopeInventory,
+			Attribute: "ip_address",
+			Aliases:   []string{"ipv4", "ip"},
+			Order:     "asc",
+		}},
+	}, params)
+	// the search given is left as it is
+	assert.Equal(t, "ip", filters[0].Attribute)
+
+	// the sorts of the keyset pagination keep their names
+	cursor := &DeviceCursor{}
+	params = aliases.Apply(SearchParams{Sort: sort, Cursor: cursor})
+	assert.Equal(t, SearchParams{Sort: sort, Cursor: cursor}, params)
+}
+
+func TestAttributeAliasesMergeFilterAttributes(t *testing.T) {
+	attrs := []FilterAttribute{
+		{Scope: AttrScopeInventory, Name: "ipv4", Count: 10},
+		{Scope: AttrScopeInventory, Name: "os", Count: 20},
+		{Scope: AttrScopeInventory, Name: "ip_address", Count: 5},
+	}
+	assert.Equal(t, attrs, AttributeAliases{}.MergeFilterAttributes(attrs))
+	assert.Equal(t, []FilterAttribute{
+		{Scope: AttrScopeInventory, Name: "ip_address", Count: 15},
+		{Scope: AttrScopeInventory, Name: "os", Count: 20},
+	}, AttributeAliases{Attributes: []AttributeAlias{{
+		Scope:   AttrScopeInventory,
+		Name:    "ip_address",
+		Aliases: []string{"ipv4"},
+	}}}.MergeFilterAttributes(attrs))
+}
@@ -0,0 +1,72 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AttrValueEncodingGzip is the BSON binary subtype, from the user defined
+// range, marking the attribute values stored as gzip-compressed strings.
+// The marker is part of the value, so writing a new value replaces it.
+const AttrValueEncodingGzip byte = 0x80
+
+// CompressAttrValue returns the value to store for an attribute: strings
+// longer than the threshold, in bytes, are compressed unless it does not
+// make them shorter; the other values are returned unchanged. A zero
+// threshold disables the compression.
+func CompressAttrValue(value interface{}, threshold int) (interface{}, error) {
+	s, ok := value.(string)
+	if !ok || threshold <= 0 || len(s) <= threshold {
+		return value, nil
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(s)); err != nil {
+		return nil, errors.Wrap(err, "failed to compress the attribute value")
+	}
+	if err := w.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to compress the attribute value")
+	}
+	if buf.Len() >= len(s) {
+		return value, nil
+	}
+	return primitive.Binary{
+		Subtype: AttrValueEncodingGzip,
+		Data:    buf.Bytes(),
+	}, nil
+}
+
+// DecompressAttrValue returns the string of a compressed attribute value;
+// the other values are returned unchanged.
+func DecompressAttrValue(value interface{}) (interface{}, error) {
+	bin, ok := value.(primitive.Binary)
+	if !ok || bin.Subtype != AttrValueEncodingGzip {
+		return value, nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(bin.Data))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decompress the attribute value")
+	}
+	s, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decompress the attribute value")
+	}
+	return string(s), nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCompressAttrValue(t *testing.T) {
+	log := strings.Repeat("kernel: eth0: link up\n", 100)
+
+	value, err := CompressAttrValue(log, 1024)
+	require.NoError(t, err)
+	bin, ok := value.(primitive.Binary)
+	require.True(t, ok)
+	assert.Equal(t, AttrValueEncodingGzip, bin.Subtype)
+	assert.Less(t, len(bin.Data), len(log))
+
+	value, err = DecompressAttrValue(value)
+	assert.NoError(t, err)
+	assert.Equal(t, log, value)
+
+	// short values, other types and disabled compression
+	for _, tc := range []struct {
+		value     interface{}
+		threshold int
+	}{
+		{"short", 1024},
+		{log, 0},
+		{float64(42), 1},
+		{[]interface{}{log}, 1},
+	} {
+		value, err := CompressAttrValue(tc.value, tc.threshold)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.value, value)
+	}
+
+	// the values which do not shrink are stored as reported
+	noise := make([]byte, 1100)
+	_, err = rand.Read(noise)
+	require.NoError(t, err)
+	value, err = CompressAttrValue(string(noise), 1024)
+	assert.NoError(t, err)
+	assert.IsType(t, "", value)
+
+	_, err = DecompressAttrValue(primitive.Binary{
+		Subtype: AttrValueEncodingGzip,
+		Data:    []byte("garbage"),
+	})
+	assert.Error(t, err)
+}
+
+func TestUnmarshalCompressedAttributes(t *testing.T) {
+	log := strings.Repeat("kernel: eth0: link up\n", 100)
+	compressed, err := CompressAttrValue(log, 1024)
+	require.NoError(t, err)
+
+	b, err := bson.Marshal(bson.M{
+		"_id": "1",
+		"attributes": bson.M{
+			"inventory-dmesg": bson.M{
+				"scope": AttrScopeInventory,
+				"name":  "dmesg",
+				"value": compressed,
+			},
+		},
+	})
+	require.NoError(t, err)
+	var dev Device
+	require.NoError(t, bson.Unmarshal(b, &dev))
+	assert.Equal(t, DeviceAttributes{{
+		Scope: AttrScopeInventory,
+		Name:  "dmesg",
+		Value: log,
+	}}, dev.Attributes)
+}
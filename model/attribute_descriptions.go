@@ -0,0 +1,128 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"sort"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pkg/errors"
+)
+
+const (
+	// AttributeDescriptionsMax is the maximum number of attributes
+	// a tenant can describe.
+	AttributeDescriptionsMax = 1000
+	// AttributeDescriptionMaxLength bounds the description of an
+	// attribute.
+	AttributeDescriptionMaxLength = 1024
+)
+
+// AttributeDescription is the human-readable description of an attribute,
+// maintained for the whole tenant rather than reported with the values.
+type AttributeDescription struct {
+	Scope       string `json:"scope" bson:"scope"`
+	Name        string `json:"name" bson:"name"`
+	Description string `json:"description" bson:"description"`
+}
+
+func (d AttributeDescription) Validate() error {
+	return validation.ValidateStruct(&d,
+		validation.Field(&d.Scope, validation.Required),
+		validation.Field(&d.Name, validation.Required),
+		validation.Field(&d.Description,
+			validation.Length(0, AttributeDescriptionMaxLength)),
+	)
+}
+
+// AttributeDescriptions is the catalog of the descriptions of the
+// attributes of a tenant.
+type AttributeDescriptions []AttributeDescription
+
+// Validate checks the descriptions and that each attribute is described
+// once; the empty descriptions are only valid in the updates of the
+// catalog, where they remove the description.
+func (d AttributeDescriptions) Validate() error {
+	if len(d) > AttributeDescriptionsMax {
+		return errors.Errorf("too many descriptions: the maximum is %d",
+			AttributeDescriptionsMax)
+	}
+	seen := make(map[SelectAttribute]bool, len(d))
+	for _, desc := range d {
+		if err := desc.Validate(); err != nil {
+			return errors.Wrapf(err, "%s/%s", desc.Scope, desc.Name)
+		}
+		key := SelectAttribute{Scope: desc.Scope, Attribute: desc.Name}
+		if seen[key] {
+			return errors.Errorf("%s/%s: described more than once",
+				desc.Scope, desc.Name)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// Sort sorts the descriptions by scope and name.
+func (d AttributeDescriptions) Sort() {
+	sort.Slice(d, func(i, j int) bool {
+		if d[i].Scope != d[j].Scope {
+			return d[i].Scope < d[j].Scope
+		}
+		return d[i].Name < d[j].Name
+	})
+}
+
+func (d AttributeDescriptions) byAttribute() map[SelectAttribute]string {
+	descs := make(map[SelectAttribute]string, len(d))
+	for _, desc := range d {
+		descs[SelectAttribute{Scope: desc.Scope, Attribute: desc.Name}] =
+			desc.Description
+	}
+	return descs
+}
+
+// Describe sets the descriptions of the catalog on the attributes of
+// the devices, in place of the descriptions reported with the values.
+func (d AttributeDescriptions) Describe(devs []Device) {
+	if len(d) == 0 {
+		return
+	}
+	descs := d.byAttribute()
+	describe := func(attrs DeviceAttributes) {
+		for i := range attrs {
+			key := SelectAttribute{Scope: attrs[i].Scope, Attribute: attrs[i].Name}
+			if desc, ok := descs[key]; ok {
+				desc := desc
+				attrs[i].Description = &desc
+			}
+		}
+	}
+	for i := range devs {
+		describe(devs[i].Attributes)
+		describe(devs[i].Highlights)
+	}
+}
+
+// DescribeFilterAttributes sets the descriptions of the catalog on
+// the filter attributes.
+func (d AttributeDescriptions) DescribeFilterAttributes(attrs []FilterAttribute) {
+	if len(d) == 0 {
+		return
+	}
+	descs := d.byAttribute()
+	for i := range attrs {
+		key := SelectAttribute{Scope: attrs[i].Scope, Attribute: attrs[i].Name}
+		attrs[i].Description = descs[key]
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttributeDescriptionsValidate(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		descs AttributeDescriptions
+		err   string
+	}{
+		"ok": {
+			descs: AttributeDescriptions{
+				{Scope: "inventory", Name: "os", Description: "Operating system"},
+				{Scope: "identity", Name: "os", Description: "Factory image"},
+				{Scope: "inventory", Name: "kernel"},
+			},
+		},
+		"ok, empty": {},
+		"error, scope": {
+			descs: AttributeDescriptions{{Name: "os"}},
+			err:   "/os: scope: cannot be blank.",
+		},
+		"error, description": {
+			descs: AttributeDescriptions{{
+				Scope:       "inventory",
+				Name:        "os",
+				Description: strings.Repeat("a", AttributeDescriptionMaxLength+1),
+			}},
+			err: "inventory/os: description: the length must be no more than 1024.",
+		},
+		"error, duplicate": {
+			descs: AttributeDescriptions{
+				{Scope: "inventory", Name: "os", Description: "Operating system"},
+				{Scope: "inventory", Name: "os", Description: "OS"},
+			},
+			err: "inventory/os: described more than once",
+		},
+		"error, too many": {
+			descs: make(AttributeDescriptions, AttributeDescriptionsMax+1),
+			err:   "too many descriptions: the maximum is 1000",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.descs.Validate()
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestAttributeDescriptionsDescribe(t *testing.T) {
+	t.Parallel()
+
+	reported := "reported by the device"
+	descs := AttributeDescriptions{
+		{Scope: "inventory", Name: "os", Description: "Operating system"},
+		{Scope: "inventory", Name: "kernel", Description: "Kernel version"},
+	}
+	devs := []Device{{
+		ID: "1",
+		Attributes: DeviceAttributes{
+			{Scope: "inventory", Name: "os", Value: "linux", Description: &reported},
+			{Scope: "inventory", Name: "mac", Value: "00:11", Description: &reported},
+			{Scope: "identity", Name: "os", Value: "linux"},
+		},
+		Highlights: DeviceAttributes{
+			{Scope: "inventory", Name: "kernel", Value: "5.10"},
+		},
+	}}
+	descs.Describe(devs)
+
+	described := func(attr DeviceAttribute) string {
+		if attr.Description == nil {
+			return ""
+		}
+		return *attr.Description
+	}
+	assert.Equal(t, "Operating system", described(devs[0].Attributes[0]))
+	assert.Equal(t, reported, described(devs[0].Attributes[1]))
+	assert.Equal(t, "", described(devs[0].Attributes[2]))
+	assert.Equal(t, "Kernel version", described(devs[0].Highlights[0]))
+
+	attrs := []FilterAttribute{
+		{Scope: "inventory", Name: "os", Count: 2},
+		{Scope: "inventory", Name: "mac", Count: 1},
+	}
+	descs.DescribeFilterAttributes(attrs)
+	assert.Equal(t, []FilterAttribute{
+		{Scope: "inventory", Name: "os", Count: 2, Description: "Operating system"},
+		{Scope: "inventory", Name: "mac", Count: 1},
+	}, attrs)
+}
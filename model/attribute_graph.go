@@ -0,0 +1,57 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+const (
+	// AttributeClustersLimitDefault and AttributeClustersLimitMax bound
+	// the number of clusters of the attribute graph.
+	AttributeClustersLimitDefault = 20
+	AttributeClustersLimitMax     = 100
+)
+
+// AttributeValueSet is the number of devices sharing the same values of
+// an attribute, with a sample of their IDs.
+type AttributeValueSet struct {
+	Values  []interface{} `bson:"_id"`
+	Count   int           `bson:"count"`
+	Devices []DeviceID    `bson:"devices"`
+}
+
+// AttributeCluster is a connected cluster of devices: two devices are
+// connected when they share a value of the attribute, directly or
+// through other devices of the cluster.
+type AttributeCluster struct {
+	// Values samples the values shared by the cluster; ValueCount is
+	// the number of all of them
+	Values     []interface{} `json:"values"`
+	ValueCount int           `json:"value_count"`
+	// Count is the number of devices of the cluster, and Devices
+	// a sample of them
+	Count   int        `json:"count"`
+	Devices []DeviceID `json:"devices"`
+}
+
+// AttributeGraph groups the devices having an attribute into the clusters
+// connected by its shared values, the largest first.
+type AttributeGraph struct {
+	Name          string             `json:"name"`
+	Scope         string             `json:"scope"`
+	Devices       int                `json:"devices"`
+	TotalClusters int                `json:"total_clusters"`
+	Clusters      []AttributeCluster `json:"clusters"`
+	// Partial is set when the devices have too many distinct values to
+	// be related completely; only the most common ones are clustered
+	Partial bool `json:"partial,omitempty"`
+}
@@ -0,0 +1,52 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+const (
+	// AttributePivotLimitDefault and AttributePivotLimitMax bound the
+	// number of values of each attribute of the pivot.
+	AttributePivotLimitDefault = 20
+	AttributePivotLimitMax     = 100
+)
+
+// AttributePairCount is the number of devices sharing the values of
+// the two attributes of a pivot; the missing attributes count as null.
+type AttributePairCount struct {
+	Row    interface{} `bson:"row"`
+	Column interface{} `bson:"column"`
+	Count  int         `bson:"count"`
+}
+
+// PivotAxis is an attribute of the pivot, with its values in the order
+// of the matrix: the most common first.
+type PivotAxis struct {
+	Scope  string        `json:"scope"`
+	Name   string        `json:"name"`
+	Values []interface{} `json:"values"`
+}
+
+// AttributePivot counts the devices by the values of two attributes, e.g.
+// the device type and the artifact name: Counts[r][c] is the number of
+// devices with the r-th value of the rows and the c-th value of
+// the columns.
+type AttributePivot struct {
+	Rows    PivotAxis `json:"rows"`
+	Columns PivotAxis `json:"columns"`
+	Counts  [][]int   `json:"counts"`
+	Devices int       `json:"devices"`
+	// Partial is set when the values do not all fit the limits; only
+	// the most common ones are counted
+	Partial bool `json:"partial,omitempty"`
+}
@@ -0,0 +1,150 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pkg/errors"
+)
+
+// ConfigBundleVersion is the version of the configuration bundle format;
+// the bundles of the earlier versions hold a subset of its sections.
+const ConfigBundleVersion = 2
+
+// ConfigBundle holds the inventory configuration of a tenant in a form
+// which can be exported and imported into another tenant or environment.
+// The static groups list their members by device ID, which only carry
+// over between environments sharing the devices; the other sections do
+// not refer to the devices.
+type ConfigBundle struct {
+	Version        int                   `json:"version" yaml:"version"`
+	Groups         []GroupDefinition     `json:"groups,omitempty" yaml:"groups,omitempty"`
+	GroupsMetadata []BundleGroupMetadata `json:"groups_metadata,omitempty" yaml:"groups_metadata,omitempty"`
+	DynamicGroups  []BundleDynamicGroup  `json:"dynamic_groups,omitempty" yaml:"dynamic_groups,omitempty"`
+	SavedFilters   []BundleSavedFilter   `json:"saved_filters,omitempty" yaml:"saved_filters,omitempty"`
+}
+
+// GroupDefinition describes a static group by its members; an empty
+// list of devices describes an empty group.
+type GroupDefinition struct {
+	Name    GroupName  `json:"name" yaml:"name"`
+	Devices []DeviceID `json:"devices" yaml:"devices"`
+}
+
+func (g GroupDefinition) Validate() error {
+	return validation.ValidateStruct(&g,
+		validation.Field(&g.Name),
+		validation.Field(&g.Devices, validation.NotNil),
+	)
+}
+
+// BundleGroupMetadata is the description and the parent of a group; the
+// type of the group, its creator and timestamps are set on import.
+type BundleGroupMetadata struct {
+	Name        GroupName `json:"name" yaml:"name"`
+	Description string    `json:"description,omitempty" yaml:"description,omitempty"`
+	Parent      GroupName `json:"parent,omitempty" yaml:"parent,omitempty"`
+}
+
+func (g BundleGroupMetadata) Validate() error {
+	return GroupMetadata{
+		Name:        g.Name,
+		Description: g.Description,
+		Parent:      g.Parent,
+	}.Validate()
+}
+
+// BundleDynamicGroup is the definition of a dynamic group.
+type BundleDynamicGroup struct {
+	Name       GroupName `json:"name" yaml:"name"`
+	Expression string    `json:"expression" yaml:"expression"`
+}
+
+func (g BundleDynamicGroup) Validate() error {
+	return DynamicGroup{Name: g.Name, Expression: g.Expression}.Validate()
+}
+
+// BundleSavedFilter is a shared saved filter; the filter keeps its ID
+// across the imports, and is owned by the importing user when created.
+type BundleSavedFilter struct {
+	ID      string            `json:"id" yaml:"id"`
+	Name    string            `json:"name" yaml:"name"`
+	Filters []FilterPredicate `json:"filters" yaml:"filters"`
+	Sort    []SortCriteria    `json:"sort,omitempty" yaml:"sort,omitempty"`
+}
+
+func (f BundleSavedFilter) Validate() error {
+	if err := ValidateClientID(f.ID); err != nil {
+		return errors.Wrap(err, "id")
+	}
+	return f.SavedFilter().Validate()
+}
+
+// SavedFilter returns the shared saved filter described by the bundle.
+func (f BundleSavedFilter) SavedFilter() SavedFilter {
+	return SavedFilter{
+		ID:      f.ID,
+		Name:    f.Name,
+		Shared:  true,
+		Filters: f.Filters,
+		Sort:    f.Sort,
+	}
+}
+
+func (b ConfigBundle) Validate() error {
+	if b.Version < 1 || b.Version > ConfigBundleVersion {
+		return errors.Errorf("unsupported bundle version: %d", b.Version)
+	}
+	names := make(map[GroupName]struct{}, len(b.Groups))
+	for _, g := range b.Groups {
+		if err := g.Validate(); err != nil {
+			return errors.Wrapf(err, "group %s", g.Name)
+		}
+		if _, ok := names[g.Name]; ok {
+			return errors.Errorf("duplicate group: %s", g.Name)
+		}
+		names[g.Name] = struct{}{}
+	}
+	metadata := make(map[GroupName]struct{}, len(b.GroupsMetadata))
+	for _, g := range b.GroupsMetadata {
+		if err := g.Validate(); err != nil {
+			return errors.Wrapf(err, "group metadata %s", g.Name)
+		}
+		if _, ok := metadata[g.Name]; ok {
+			return errors.Errorf("duplicate group metadata: %s", g.Name)
+		}
+		metadata[g.Name] = struct{}{}
+	}
+	for _, g := range b.DynamicGroups {
+		if err := g.Validate(); err != nil {
+			return errors.Wrapf(err, "dynamic group %s", g.Name)
+		}
+		if _, ok := names[g.Name]; ok {
+			return errors.Errorf("duplicate group: %s", g.Name)
+		}
+		names[g.Name] = struct{}{}
+	}
+	filters := make(map[string]struct{}, len(b.SavedFilters))
+	for _, f := range b.SavedFilters {
+		if err := f.Validate(); err != nil {
+			return errors.Wrapf(err, "saved filter %s", f.ID)
+		}
+		if _, ok := filters[f.ID]; ok {
+			return errors.Errorf("duplicate saved filter: %s", f.ID)
+		}
+		filters[f.ID] = struct{}{}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigBundleValidate(t *testing.T) {
+	testCases := map[string]struct {
+		bundle ConfigBundle
+		err    string
+	}{
+		"ok": {
+			bundle: ConfigBundle{
+				Version: ConfigBundleVersion,
+				Groups: []GroupDefinition{
+					{Name: "foo", Devices: []DeviceID{"1", "2"}},
+					{Name: "bar", Devices: []DeviceID{"3"}},
+				},
+			},
+		},
+		"ok, empty": {
+			bundle: ConfigBundle{Version: ConfigBundleVersion},
+		},
+		"error, version": {
+			bundle: ConfigBundle{Version: 3},
+			err:    "unsupported bundle version: 3",
+		},
+		"ok, version 1": {
+			bundle: ConfigBundle{
+				Version: 1,
+				Groups:  []GroupDefinition{{Name: "foo", Devices: []DeviceID{"1"}}},
+			},
+		},
+		"ok, all sections": {
+			bundle: ConfigBundle{
+				Version: ConfigBundleVersion,
+				Groups:  []GroupDefinition{{Name: "foo", Devices: []DeviceID{"1"}}},
+				GroupsMetadata: []BundleGroupMetadata{
+					{Name: "foo", Description: "Foo", Parent: "bar"},
+					{Name: "bar"},
+				},
+				DynamicGroups: []BundleDynamicGroup{
+					{Name: "bar", Expression: `inventory/os == "linux"`},
+				},
+				SavedFilters: []BundleSavedFilter{{
+					ID:   "1",
+					Name: "linux",
+					Filters: []FilterPredicate{{
+						Scope:     "inventory",
+						Attribute: "os",
+						Type:      "$eq",
+						Value:     "linux",
+					}},
+				}},
+			},
+		},
+		"error, duplicate group metadata": {
+			bundle: ConfigBundle{
+				Version: ConfigBundleVersion,
+				GroupsMetadata: []BundleGroupMetadata{
+					{Name: "foo"},
+					{Name: "foo", Description: "Foo"},
+				},
+			},
+			err: "duplicate group metadata: foo",
+		},
+		"error, dynamic group name of a static group": {
+			bundle: ConfigBundle{
+				Version: ConfigBundleVersion,
+				Groups:  []GroupDefinition{{Name: "foo", Devices: []DeviceID{"1"}}},
+				DynamicGroups: []BundleDynamicGroup{
+					{Name: "foo", Expression: `inventory/os == "linux"`},
+				},
+			},
+			err: "duplicate group: foo",
+		},
+		"error, saved filter id": {
+			bundle: ConfigBundle{
+				Version: ConfigBundleVersion,
+				SavedFilters: []BundleSavedFilter{{
+					Name: "linux",
+					Filters: []FilterPredicate{{
+						Scope:     "inventory",
+						Attribute: "os",
+						Type:      "$eq",
+						Value:     "linux",
+					}},
+				}},
+			},
+			err: "saved filter : id: cannot be blank",
+		},
+		"error, duplicate saved filter": {
+			bundle: ConfigBundle{
+				Version: ConfigBundleVersion,
+				SavedFilters: []BundleSavedFilter{{
+					ID:   "1",
+					Name: "linux",
+					Filters: []FilterPredicate{{
+						Scope:     "inventory",
+						Attribute: "os",
+						Type:      "$eq",
+						Value:     "linux",
+					}},
+				}, {
+					ID:   "1",
+					Name: "debian",
+					Filters: []FilterPredicate{{
+						Scope:     "inventory",
+						Attribute: "os",
+						Type:      "$eq",
+						Value:     "debian",
+					}},
+				}},
+			},
+			err: "duplicate saved filter: 1",
+		},
+		"error, group name": {
+			bundle: ConfigBundle{
+				Version: ConfigBundleVersion,
+				Groups: []GroupDefinition{
+					{Name: "foo bar", Devices: []DeviceID{"1"}},
+				},
+			},
+			err: "group foo bar: name: Group name can only contain: " +
+				"upper/lowercase alphanum, -(dash), _(underscore).",
+		},
+		"error, no devices": {
+			bundle: ConfigBundle{
+				Version: ConfigBundleVersion,
+				Groups:  []GroupDefinition{{Name: "foo"}},
+			},
+			err: "group foo: devices: is required.",
+		},
+		"ok, empty group": {
+			bundle: ConfigBundle{
+				Version: ConfigBundleVersion,
+				Groups:  []GroupDefinition{{Name: "foo", Devices: []DeviceID{}}},
+			},
+		},
+		"error, duplicate group": {
+			bundle: ConfigBundle{
+				Version: ConfigBundleVersion,
+				Groups: []GroupDefinition{
+					{Name: "foo", Devices: []DeviceID{"1"}},
+					{Name: "foo", Devices: []DeviceID{"2"}},
+				},
+			},
+			err: "duplicate group: foo",
+		},
+		"ok, device in two groups": {
+			bundle: ConfigBundle{
+				Version: ConfigBundleVersion,
+				Groups: []GroupDefinition{
+					{Name: "foo", Devices: []DeviceID{"1"}},
+					{Name: "bar", Devices: []DeviceID{"1"}},
+				},
+			},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.bundle.Validate()
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
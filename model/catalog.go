@@ -0,0 +1,41 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import "time"
+
+// GroupCount is the number of devices in a group.
+type GroupCount struct {
+	Group GroupName `json:"group" bson:"_id"`
+	Count int       `json:"count" bson:"count"`
+}
+
+// GroupsListing lists the groups together with the number of devices
+// without group, and on request the number of devices of each group.
+type GroupsListing struct {
+	Groups         []GroupName  `json:"groups"`
+	Counts         []GroupCount `json:"counts,omitempty"`
+	UngroupedCount int          `json:"ungrouped_count"`
+}
+
+// Catalog is the precomputed attribute catalog and statistics of
+// the tenant's inventory.
+type Catalog struct {
+	Attributes []FilterAttribute `json:"attributes" bson:"attributes"`
+	Groups     []GroupCount      `json:"groups" bson:"groups"`
+	Devices    int               `json:"devices" bson:"devices"`
+
+	ComputedTs time.Time `json:"computed_ts" bson:"computed_ts"`
+}
@@ -0,0 +1,146 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pkg/errors"
+)
+
+// CompletenessAlertHoursMax is the longest grace period of the
+// completeness alert: 30 days.
+const CompletenessAlertHoursMax = 30 * 24
+
+// CompletenessRange selects the devices by their inventory completeness:
+// the fraction of the attributes required by the schema which the device
+// has reported. Both bounds are inclusive.
+type CompletenessRange struct {
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+}
+
+func (r CompletenessRange) Validate() error {
+	err := validation.ValidateStruct(&r,
+		validation.Field(&r.Min, validation.Min(0.0), validation.Max(1.0)),
+		validation.Field(&r.Max, validation.Min(0.0), validation.Max(1.0)),
+	)
+	if err != nil {
+		return err
+	} else if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+		return errors.New("completeness: min must not be greater than max")
+	}
+	return nil
+}
+
+// RequiredAttributes returns the attributes the schema definitions
+// require the devices to report.
+func RequiredAttributes(defs []AttributeDefinition) []SelectAttribute {
+	var required []SelectAttribute
+	for _, def := range defs {
+		if def.Required {
+			required = append(required, SelectAttribute{
+				Scope:     def.Scope,
+				Attribute: def.Name,
+			})
+		}
+	}
+	return required
+}
+
+// DeviceCompleteness is the inventory completeness of a device.
+type DeviceCompleteness struct {
+	DeviceID DeviceID `json:"device_id"`
+	// Completeness is the fraction of the required attributes the
+	// device has reported; a device is complete if no attributes are
+	// required.
+	Completeness float64 `json:"completeness"`
+	// Missing are the required attributes the device has not reported.
+	Missing []SelectAttribute `json:"missing"`
+}
+
+// Completeness computes the inventory completeness of the device.
+func Completeness(dev *Device, required []SelectAttribute) DeviceCompleteness {
+	res := DeviceCompleteness{
+		DeviceID:     dev.ID,
+		Completeness: 1,
+		Missing:      []SelectAttribute{},
+	}
+	if len(required) == 0 {
+		return res
+	}
+	reported := make(map[SelectAttribute]bool, len(dev.Attributes))
+	for _, attr := range dev.Attributes {
+		reported[SelectAttribute{Scope: attr.Scope, Attribute: attr.Name}] = true
+	}
+	for _, attr := range required {
+		if !reported[attr] {
+			res.Missing = append(res.Missing, attr)
+		}
+	}
+	res.Completeness = float64(len(required)-len(res.Missing)) /
+		float64(len(required))
+	return res
+}
+
+// GroupCompleteness aggregates the inventory completeness of the devices
+// of a group.
+type GroupCompleteness struct {
+	// Group is the name of the group, empty for the devices not
+	// belonging to any group.
+	Group GroupName `json:"group" bson:"_id"`
+	// Devices is the number of devices in the group.
+	Devices int `json:"devices" bson:"devices"`
+	// Complete is the number of devices which reported all the
+	// required attributes.
+	Complete int `json:"complete" bson:"complete"`
+	// Completeness is the average completeness of the devices.
+	Completeness float64 `json:"completeness" bson:"completeness"`
+}
+
+// CompletenessAlert notifies the tenant about the devices which are still
+// missing required attributes when the grace period since they were first
+// seen is over.
+type CompletenessAlert struct {
+	Channel NotificationChannel `json:"channel" bson:"channel"`
+	// AfterHours is the grace period, in hours, the devices have to
+	// report the required attributes.
+	AfterHours int `json:"after_hours" bson:"after_hours"`
+	// CheckedTs is the time of the last check; the devices whose grace
+	// period ended since are reported by the next one.
+	CheckedTs *time.Time `json:"checked_ts,omitempty" bson:"checked_ts,omitempty"`
+}
+
+func (a CompletenessAlert) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.Channel, validation.Required),
+		validation.Field(&a.AfterHours,
+			validation.Required,
+			validation.Min(1), validation.Max(CompletenessAlertHoursMax)),
+	)
+}
+
+// GracePeriod returns the time the devices have to report the required
+// attributes.
+func (a CompletenessAlert) GracePeriod() time.Duration {
+	return time.Duration(a.AfterHours) * time.Hour
+}
+
+// IncompleteDevices is the payload of the completeness alerts.
+type IncompleteDevices struct {
+	AfterHours int                  `json:"after_hours"`
+	Devices    []DeviceCompleteness `json:"devices"`
+}
@@ -0,0 +1,79 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompletenessRangeValidate(t *testing.T) {
+	low, high, invalid := 0.5, 1.0, 1.5
+	assert.NoError(t, CompletenessRange{}.Validate())
+	assert.NoError(t, CompletenessRange{Min: &low, Max: &high}.Validate())
+	assert.EqualError(t, CompletenessRange{Max: &invalid}.Validate(),
+		"max: must be no greater than 1.")
+	assert.EqualError(t, CompletenessRange{Min: &high, Max: &low}.Validate(),
+		"completeness: min must not be greater than max")
+}
+
+func TestCompleteness(t *testing.T) {
+	os := SelectAttribute{Scope: AttrScopeInventory, Attribute: "os"}
+	mac := SelectAttribute{Scope: AttrScopeIdentity, Attribute: "mac"}
+	required := RequiredAttributes([]AttributeDefinition{
+		{Scope: AttrScopeInventory, Name: "os", Required: true},
+		{Scope: AttrScopeInventory, Name: "cpu"},
+		{Scope: AttrScopeIdentity, Name: "mac", Required: true},
+	})
+	assert.Equal(t, []SelectAttribute{os, mac}, required)
+
+	dev := &Device{ID: "1", Attributes: DeviceAttributes{
+		{Scope: AttrScopeInventory, Name: "os", Value: "linux"},
+		{Scope: AttrScopeInventory, Name: "cpu", Value: "arm"},
+	}}
+	assert.Equal(t, DeviceCompleteness{
+		DeviceID:     "1",
+		Completeness: 0.5,
+		Missing:      []SelectAttribute{mac},
+	}, Completeness(dev, required))
+
+	// devices are complete if no attributes are required
+	assert.Equal(t, DeviceCompleteness{
+		DeviceID:     "1",
+		Completeness: 1,
+		Missing:      []SelectAttribute{},
+	}, Completeness(dev, nil))
+}
+
+func TestCompletenessAlertValidate(t *testing.T) {
+	channel := NotificationChannel{
+		Type:  NotificationChannelEmail,
+		Email: "ops@example.com",
+	}
+	alert := CompletenessAlert{Channel: channel, AfterHours: 24}
+	assert.NoError(t, alert.Validate())
+	assert.Equal(t, 24*time.Hour, alert.GracePeriod())
+
+	assert.EqualError(t, CompletenessAlert{Channel: channel}.Validate(),
+		"after_hours: cannot be blank.")
+	assert.EqualError(t, CompletenessAlert{AfterHours: 24}.Validate(),
+		"channel: (type: cannot be blank.).")
+	assert.EqualError(t, CompletenessAlert{
+		Channel:    NotificationChannel{Type: NotificationChannelEmail},
+		AfterHours: 24,
+	}.Validate(), "channel: (email: cannot be blank.).")
+}
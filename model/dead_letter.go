@@ -0,0 +1,44 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// DeadLetter is a webhook delivery which failed, kept for inspection and
+// to be replayed once the receiver is fixed.
+type DeadLetter struct {
+	ID  string `json:"id" bson:"_id"`
+	URL string `json:"url" bson:"url"`
+	// SubscriptionID is the subscription notified by the delivery; empty
+	// for the events webhook.
+	SubscriptionID string `json:"subscription_id,omitempty" bson:"subscription_id,omitempty"`
+	// Payload is the body of the delivery, as posted to the webhook.
+	Payload json.RawMessage `json:"payload" bson:"payload"`
+	// Reason is why the last delivery attempt failed.
+	Reason   string `json:"reason" bson:"reason"`
+	Attempts int    `json:"attempts" bson:"attempts"`
+
+	CreatedTs     time.Time `json:"created_ts" bson:"created_ts"`
+	LastAttemptTs time.Time `json:"last_attempt_ts" bson:"last_attempt_ts"`
+}
+
+// DeadLettersReplay is the result of replaying the dead letters.
+type DeadLettersReplay struct {
+	Replayed int `json:"replayed"`
+	Failed   int `json:"failed"`
+}
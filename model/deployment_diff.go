@@ -0,0 +1,102 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"reflect"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// DeploymentFinishedMaxDevices is the maximum number of devices of
+// a single deployment completion notice.
+const DeploymentFinishedMaxDevices = 1000
+
+// DeploymentFinished notifies about the devices of a finished deployment.
+type DeploymentFinished struct {
+	Devices []DeviceID `json:"devices"`
+}
+
+func (d DeploymentFinished) Validate() error {
+	return validation.ValidateStruct(&d,
+		validation.Field(&d.Devices,
+			validation.Required,
+			validation.Length(1, DeploymentFinishedMaxDevices),
+			validation.Each(validation.Required),
+		),
+	)
+}
+
+// AttributeSnapshot holds the key attributes of a device as of the last
+// finished deployment.
+type AttributeSnapshot struct {
+	DeviceID     DeviceID         `bson:"_id"`
+	DeploymentID string           `bson:"deployment_id"`
+	Attributes   DeviceAttributes `bson:"attributes"`
+	Ts           time.Time        `bson:"ts"`
+}
+
+// AttributeChange is the value of an attribute before and after
+// a deployment; nil values stand for missing attributes.
+type AttributeChange struct {
+	Scope  string      `json:"scope"`
+	Name   string      `json:"name"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// DeploymentDiff is the change of the key attributes of a device
+// brought by a deployment.
+type DeploymentDiff struct {
+	DeploymentID string   `json:"deployment_id"`
+	DeviceID     DeviceID `json:"device_id"`
+	// PreviousDeploymentID is the deployment the values before were
+	// recorded at; empty if there was none.
+	PreviousDeploymentID string            `json:"previous_deployment_id,omitempty"`
+	Changes              []AttributeChange `json:"changes"`
+}
+
+// DiffAttributes returns the changes of the given attributes between
+// before and after.
+func DiffAttributes(
+	keys []SelectAttribute,
+	before, after DeviceAttributes,
+) []AttributeChange {
+	values := func(attrs DeviceAttributes) map[[2]string]interface{} {
+		m := make(map[[2]string]interface{}, len(attrs))
+		for _, attr := range attrs {
+			m[[2]string{attr.Scope, attr.Name}] = attr.Value
+		}
+		return m
+	}
+	beforeValues, afterValues := values(before), values(after)
+
+	changes := []AttributeChange{}
+	for _, key := range keys {
+		k := [2]string{key.Scope, key.Attribute}
+		b, a := beforeValues[k], afterValues[k]
+		if reflect.DeepEqual(b, a) {
+			continue
+		}
+		changes = append(changes, AttributeChange{
+			Scope:  key.Scope,
+			Name:   key.Attribute,
+			Before: b,
+			After:  a,
+		})
+	}
+	return changes
+}
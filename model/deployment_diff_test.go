@@ -0,0 +1,81 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeploymentFinishedValidate(t *testing.T) {
+	assert.NoError(t, DeploymentFinished{
+		Devices: []DeviceID{"1", "2"},
+	}.Validate())
+	assert.EqualError(t, DeploymentFinished{}.Validate(),
+		"devices: cannot be blank.")
+	assert.EqualError(t, DeploymentFinished{
+		Devices: []DeviceID{"1", ""},
+	}.Validate(), "devices: (1: cannot be blank.).")
+	assert.EqualError(t, DeploymentFinished{
+		Devices: make([]DeviceID, DeploymentFinishedMaxDevices+1),
+	}.Validate(), "devices: the length must be between 1 and 1000.")
+}
+
+func TestDiffAttributes(t *testing.T) {
+	keys := []SelectAttribute{
+		{Scope: AttrScopeInventory, Attribute: "artifact_name"},
+		{Scope: AttrScopeInventory, Attribute: "device_type"},
+		{Scope: AttrScopeInventory, Attribute: "kernel"},
+		{Scope: AttrScopeInventory, Attribute: "missing"},
+	}
+	before := DeviceAttributes{
+		{Scope: AttrScopeInventory, Name: "artifact_name", Value: "release-1"},
+		{Scope: AttrScopeInventory, Name: "device_type", Value: "rpi4"},
+		{Scope: AttrScopeInventory, Name: "kernel", Value: "5.4"},
+	}
+	after := DeviceAttributes{
+		{Scope: AttrScopeInventory, Name: "artifact_name", Value: "release-2"},
+		{Scope: AttrScopeInventory, Name: "device_type", Value: "rpi4"},
+		{Scope: AttrScopeInventory, Name: "mac", Value: "00:01"},
+	}
+	assert.Equal(t, []AttributeChange{
+		{
+			Scope:  AttrScopeInventory,
+			Name:   "artifact_name",
+			Before: "release-1",
+			After:  "release-2",
+		},
+		{
+			Scope:  AttrScopeInventory,
+			Name:   "kernel",
+			Before: "5.4",
+		},
+	}, DiffAttributes(keys, before, after))
+
+	assert.Equal(t, []AttributeChange{
+		{
+			Scope: AttrScopeInventory,
+			Name:  "artifact_name",
+			After: "release-2",
+		},
+		{
+			Scope: AttrScopeInventory,
+			Name:  "device_type",
+			After: "rpi4",
+		},
+	}, DiffAttributes(keys, nil, after))
+	assert.Equal(t, []AttributeChange{}, DiffAttributes(keys, after, after))
+}
@@ -15,6 +15,7 @@
 package model
 
 import (
+	"context"
 	"encoding/json"
 	"reflect"
 	"regexp"
@@ -26,17 +27,29 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/bsontype"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mendersoftware/inventory/utils/reqctx"
 )
 
 const (
 	AttrScopeInventory = "inventory"
 	AttrScopeIdentity  = "identity"
 	AttrScopeSystem    = "system"
+	AttrScopeTags      = "tags"
 
 	AttrNameID      = "id"
 	AttrNameGroup   = "group"
 	AttrNameUpdated = "updated_ts"
 	AttrNameCreated = "created_ts"
+	// AttrNameParentDevice is the system attribute holding the ID of
+	// the gateway the device is connected through.
+	AttrNameParentDevice = "parent_device"
+)
+
+const (
+	SourceTypeDevice   = "device"
+	SourceTypeUser     = "user"
+	SourceTypeInternal = "internal"
 )
 
 const (
@@ -61,6 +74,9 @@ type DeviceAttribute struct {
 	Description *string     `json:"description,omitempty" bson:",omitempty"`
 	Value       interface{} `json:"value" bson:",omitempty"`
 	Scope       string      `json:"scope" bson:",omitempty"`
+	// Agent which reported the attribute, set in the merged views of
+	// the sub-scopes of the agents
+	Agent string `json:"agent,omitempty" bson:"-"`
 }
 
 func (da DeviceAttribute) Validate() error {
@@ -135,15 +151,62 @@ type Device struct {
 	//a map of attributes names and their values.
 	Attributes DeviceAttributes `json:"attributes,omitempty" bson:"attributes,omitempty"`
 
-	//device's group name
+	//the attributes pinned by the tenant, returned by the device searches
+	Highlights DeviceAttributes `json:"highlights,omitempty" bson:"-"`
+
+	//device's group name; the first of the groups if the device is
+	//a member of several
 	Group GroupName `json:"-" bson:"group,omitempty"`
 
+	//names of all the groups of the device, as stored in the groups array
+	//once it is rolled out
+	Groups []GroupName `json:"-" bson:"groups,omitempty"`
+
 	CreatedTs time.Time `json:"-" bson:"created_ts,omitempty"`
 	//Timestamp of the last attribute update.
 	UpdatedTs time.Time `json:"updated_ts" bson:"updated_ts,omitempty"`
 
 	//device object revision
 	Revision uint `json:"-" bson:"revision,omitempty"`
+
+	//principals which last wrote the attributes, by scope
+	Sources map[string]AttributeSource `json:"sources,omitempty" bson:"sources,omitempty"`
+
+	//write frequency of the device, only returned with the single device
+	Telemetry *DeviceTelemetry `json:"-" bson:"telemetry,omitempty"`
+}
+
+// AttributeSource identifies the principal which wrote the attributes
+// of a scope.
+type AttributeSource struct {
+	// Type is one of: device, user or internal
+	Type string `json:"type" bson:"type"`
+	// ID of the device or user, empty for internal services
+	ID string `json:"id,omitempty" bson:"id,omitempty"`
+	// Timestamp of the write
+	Timestamp time.Time `json:"timestamp" bson:"ts"`
+}
+
+// NewAttributeSource returns the source of a write performed at the given
+// time by the principal authenticated in the context. Requests which do not
+// carry a subject (internal API calls) are attributed to internal services.
+func NewAttributeSource(ctx context.Context, ts time.Time) AttributeSource {
+	source := AttributeSource{
+		Type:      SourceTypeInternal,
+		Timestamp: ts,
+	}
+	info := reqctx.FromContext(ctx)
+	if info.Subject == "" {
+		return source
+	}
+	if info.IsDevice {
+		source.Type = SourceTypeDevice
+		source.ID = info.Subject
+	} else if info.IsUser {
+		source.Type = SourceTypeUser
+		source.ID = info.Subject
+	}
+	return source
 }
 
 // internalDevice is only used internally to avoid recursive type-loops for
@@ -176,6 +239,9 @@ func (d Device) MarshalBSON() ([]byte, error) {
 	if err := d.Validate(); err != nil {
 		return nil, err
 	}
+	if d.Group == "" && len(d.Groups) > 0 {
+		d.Group = d.Groups[0]
+	}
 	if d.Group != "" {
 		d.Attributes = append(d.Attributes, DeviceAttribute{
 			Scope: AttrScopeSystem,
@@ -219,6 +285,11 @@ func (gn GroupName) Validate() error {
 	return nil
 }
 
+// DevicesCount is the number of devices matching a listing query.
+type DevicesCount struct {
+	Count int `json:"count"`
+}
+
 // wrapper for device attributes names and values
 type DeviceAttributes []DeviceAttribute
 
@@ -259,7 +330,7 @@ func GetDeviceAttributeNameReplacer() *strings.Replacer {
 }
 
 // UnmarshalBSONValue correctly unmarshals DeviceAttributes from Device
-// documents stored in the DB.
+// documents stored in the DB, decompressing the compressed values.
 func (d *DeviceAttributes) UnmarshalBSONValue(t bsontype.Type, b []byte) error {
 	raw := bson.Raw(b)
 	elems, err := raw.Elements()
@@ -272,6 +343,10 @@ func (d *DeviceAttributes) UnmarshalBSONValue(t bsontype.Type, b []byte) error {
 		if err != nil {
 			return err
 		}
+		(*d)[i].Value, err = DecompressAttrValue((*d)[i].Value)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
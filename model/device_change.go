@@ -0,0 +1,50 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"time"
+)
+
+// Types of the changes of the devices in the change feed.
+const (
+	DeviceChangeUpdated = "updated"
+	DeviceChangeDeleted = "deleted"
+)
+
+const (
+	// DeviceChangesLimitDefault is the number of changes returned in
+	// a page of the change feed when the caller does not choose one.
+	DeviceChangesLimitDefault = 100
+	// DeviceChangesLimitMax is the maximum number of changes returned in
+	// a page of the change feed.
+	DeviceChangesLimitMax = 1000
+)
+
+// DeviceChange is a change of a device recorded in the change feed of
+// the tenant; the changes are ordered by their sequence number.
+type DeviceChange struct {
+	Seq       int64     `json:"-" bson:"_id"`
+	DeviceID  DeviceID  `json:"device_id" bson:"device_id"`
+	Type      string    `json:"type" bson:"type"`
+	Timestamp time.Time `json:"timestamp" bson:"timestamp"`
+}
+
+// DeviceChangesPage is a batch of the change feed; NextToken resumes
+// the feed after the last change of the batch.
+type DeviceChangesPage struct {
+	Changes   []DeviceChange `json:"changes"`
+	NextToken string         `json:"next_token"`
+}
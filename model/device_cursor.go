@@ -0,0 +1,89 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"encoding/base64"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ErrInvalidDeviceCursor is returned for a cursor which was not issued for
+// the same sorting of the devices.
+var ErrInvalidDeviceCursor = errors.New("invalid cursor")
+
+// DeviceCursor resumes the devices listed with keyset pagination after
+// the last device of the previous page, by the values of its sort
+// attributes and its ID; it is exchanged with the clients as an opaque
+// token. A cursor without an ID starts from the first device.
+type DeviceCursor struct {
+	Sort []SortCriteria `bson:"s"`
+	// Values are the values of the sort attributes of the device, nil
+	// for the attributes the device does not have.
+	Values []interface{} `bson:"v"`
+	ID     DeviceID      `bson:"id"`
+}
+
+// NewDeviceCursor returns the cursor resuming after the device, in
+// the order of the sort criteria.
+func NewDeviceCursor(sort []SortCriteria, last Device) *DeviceCursor {
+	values := make([]interface{}, len(sort))
+	for n, s := range sort {
+		for _, attr := range last.Attributes {
+			if attr.Scope == s.Scope && attr.Name == s.Attribute {
+				values[n] = attr.Value
+				break
+			}
+		}
+	}
+	return &DeviceCursor{
+		Sort:   sort,
+		Values: values,
+		ID:     last.ID,
+	}
+}
+
+// Encode returns the opaque token of the cursor.
+func (c DeviceCursor) Encode() (string, error) {
+	b, err := bson.Marshal(c)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode cursor")
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// ParseDeviceCursor decodes the token of a cursor issued for the devices
+// sorted by the sort criteria.
+func ParseDeviceCursor(token string, sort []SortCriteria) (*DeviceCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidDeviceCursor
+	}
+	var c DeviceCursor
+	if err := bson.Unmarshal(b, &c); err != nil {
+		return nil, ErrInvalidDeviceCursor
+	}
+	if c.ID == "" || len(c.Sort) != len(sort) || len(c.Values) != len(sort) {
+		return nil, ErrInvalidDeviceCursor
+	}
+	for n, s := range sort {
+		if c.Sort[n].Scope != s.Scope || c.Sort[n].Attribute != s.Attribute ||
+			c.Sort[n].Order != s.Order || c.Sort[n].Nulls != s.Nulls {
+			return nil, ErrInvalidDeviceCursor
+		}
+	}
+	return &c, nil
+}
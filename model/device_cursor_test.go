@@ -0,0 +1,67 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceCursor(t *testing.T) {
+	sort := []SortCriteria{{
+		Scope:     AttrScopeSystem,
+		Attribute: AttrNameGroup,
+		Order:     "asc",
+	}, {
+		Scope:     AttrScopeInventory,
+		Attribute: "mem_total_kB",
+		Order:     "desc",
+	}}
+	device := Device{
+		ID: "1",
+		Attributes: DeviceAttributes{
+			{Scope: AttrScopeInventory, Name: "mem_total_kB", Value: 1024.0},
+			{Scope: AttrScopeInventory, Name: "device_type", Value: "rpi4"},
+		},
+	}
+
+	cursor := NewDeviceCursor(sort, device)
+	// the device is not in a group
+	assert.Equal(t, &DeviceCursor{
+		Sort:   sort,
+		Values: []interface{}{nil, 1024.0},
+		ID:     "1",
+	}, cursor)
+
+	token, err := cursor.Encode()
+	assert.NoError(t, err)
+	parsed, err := ParseDeviceCursor(token, sort)
+	assert.NoError(t, err)
+	assert.Equal(t, cursor, parsed)
+
+	_, err = ParseDeviceCursor(token, sort[:1])
+	assert.Equal(t, ErrInvalidDeviceCursor, err)
+	_, err = ParseDeviceCursor(token, []SortCriteria{sort[0], {
+		Scope:     AttrScopeInventory,
+		Attribute: "mem_total_kB",
+		Order:     "asc",
+	}})
+	assert.Equal(t, ErrInvalidDeviceCursor, err)
+	_, err = ParseDeviceCursor("not a cursor", sort)
+	assert.Equal(t, ErrInvalidDeviceCursor, err)
+	_, err = ParseDeviceCursor("e30", sort)
+	assert.Equal(t, ErrInvalidDeviceCursor, err)
+}
@@ -0,0 +1,150 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"sort"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pkg/errors"
+)
+
+// DeviceTemplateMaxAttributes is the maximum number of attributes
+// selected for a device template.
+const DeviceTemplateMaxAttributes = 100
+
+// DeviceTemplateRequest extracts the attributes of a device as a template
+// and, if targets are given, applies them to the devices listed by ID or
+// to the devices of a group.
+type DeviceTemplateRequest struct {
+	// Attributes selects the attributes of the template; all the tags
+	// of the device by default.
+	Attributes []SelectAttribute `json:"attributes"`
+	DeviceIDs  []DeviceID        `json:"device_ids"`
+	Group      GroupName         `json:"group"`
+}
+
+func (t DeviceTemplateRequest) Validate() error {
+	if len(t.Attributes) > DeviceTemplateMaxAttributes {
+		return errors.Errorf(
+			"too many attributes: at most %d attributes can be selected",
+			DeviceTemplateMaxAttributes)
+	}
+	for _, attr := range t.Attributes {
+		if err := attr.Validate(); err != nil {
+			return errors.Wrap(err, "attributes")
+		}
+	}
+	if len(t.DeviceIDs) > 0 && t.Group != "" {
+		return errors.New("either device IDs or a group can be provided, not both")
+	}
+	for _, id := range t.DeviceIDs {
+		if err := validation.Validate(string(id),
+			validation.Required, validation.Length(1, 1024),
+		); err != nil {
+			return errors.Wrap(err, "device_ids")
+		}
+	}
+	if t.Group != "" {
+		return t.Group.Validate()
+	}
+	return nil
+}
+
+// Apply returns true if the template is applied to target devices.
+func (t DeviceTemplateRequest) Apply() bool {
+	return len(t.DeviceIDs) > 0 || t.Group != ""
+}
+
+// SearchParams returns the search matching the target devices.
+func (t DeviceTemplateRequest) SearchParams() SearchParams {
+	var params SearchParams
+	for _, id := range t.DeviceIDs {
+		params.DeviceIDs = append(params.DeviceIDs, string(id))
+	}
+	if t.Group != "" {
+		params.Filters = []FilterPredicate{{
+			Scope:     AttrScopeSystem,
+			Attribute: AttrNameGroup,
+			Type:      "$eq",
+			Value:     string(t.Group),
+		}}
+	}
+	return params
+}
+
+// Extract returns the selected attributes of the device, ordered by scope
+// and name, and the selected attributes the device does not have.
+func (t DeviceTemplateRequest) Extract(dev *Device) (DeviceAttributes, []SelectAttribute) {
+	selected := make(map[SelectAttribute]bool, len(t.Attributes))
+	for _, attr := range t.Attributes {
+		selected[attr] = true
+	}
+	found := make(map[SelectAttribute]bool, len(t.Attributes))
+	attrs := DeviceAttributes{}
+	for _, attr := range dev.Attributes {
+		key := SelectAttribute{Scope: attr.Scope, Attribute: attr.Name}
+		if len(selected) > 0 {
+			if !selected[key] {
+				continue
+			}
+		} else if attr.Scope != AttrScopeTags {
+			continue
+		}
+		attrs = append(attrs, DeviceAttribute{
+			Scope: attr.Scope,
+			Name:  attr.Name,
+			Value: attr.Value,
+		})
+		found[key] = true
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		if attrs[i].Scope != attrs[j].Scope {
+			return attrs[i].Scope < attrs[j].Scope
+		}
+		return attrs[i].Name < attrs[j].Name
+	})
+	var missing []SelectAttribute
+	for _, attr := range t.Attributes {
+		if !found[attr] {
+			missing = append(missing, attr)
+			// the attributes may be repeated
+			found[attr] = true
+		}
+	}
+	return attrs, missing
+}
+
+// DeviceTemplateError reports a target device the template was not
+// applied to.
+type DeviceTemplateError struct {
+	DeviceID DeviceID `json:"device_id"`
+	Error    string   `json:"error"`
+}
+
+// DeviceTemplateResult reports the application of a template.
+type DeviceTemplateResult struct {
+	// Updated is the number of target devices updated.
+	Updated int `json:"updated"`
+	// Errors reports the listed devices which were not updated.
+	Errors []DeviceTemplateError `json:"errors"`
+}
+
+// DeviceTemplate is the attributes extracted from a device.
+type DeviceTemplate struct {
+	DeviceID   DeviceID         `json:"device_id"`
+	Attributes DeviceAttributes `json:"attributes"`
+	// Applied reports the application to the target devices, if any.
+	Applied *DeviceTemplateResult `json:"applied,omitempty"`
+}
@@ -0,0 +1,110 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceTemplateRequestValidate(t *testing.T) {
+	site := SelectAttribute{Scope: AttrScopeTags, Attribute: "site"}
+	testCases := map[string]struct {
+		req DeviceTemplateRequest
+		err string
+	}{
+		"ok, extract": {},
+		"ok, device IDs": {
+			req: DeviceTemplateRequest{
+				Attributes: []SelectAttribute{site},
+				DeviceIDs:  []DeviceID{"1", "2"},
+			},
+		},
+		"ok, group": {
+			req: DeviceTemplateRequest{Group: "lab"},
+		},
+		"error, attribute": {
+			req: DeviceTemplateRequest{
+				Attributes: []SelectAttribute{{Scope: AttrScopeTags}},
+			},
+			err: "attributes: attribute: cannot be blank.",
+		},
+		"error, too many attributes": {
+			req: DeviceTemplateRequest{
+				Attributes: make([]SelectAttribute, DeviceTemplateMaxAttributes+1),
+			},
+			err: "too many attributes: at most 100 attributes can be selected",
+		},
+		"error, device IDs and group": {
+			req: DeviceTemplateRequest{
+				DeviceIDs: []DeviceID{"1"},
+				Group:     "lab",
+			},
+			err: "either device IDs or a group can be provided, not both",
+		},
+		"error, device ID": {
+			req: DeviceTemplateRequest{DeviceIDs: []DeviceID{""}},
+			err: "device_ids: cannot be blank",
+		},
+		"error, group": {
+			req: DeviceTemplateRequest{Group: "lab/1"},
+			err: "Group name can only contain: upper/lowercase " +
+				"alphanum, -(dash), _(underscore)",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.req.Validate()
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDeviceTemplateRequestExtract(t *testing.T) {
+	dev := &Device{
+		ID: "1",
+		Attributes: DeviceAttributes{
+			{Scope: AttrScopeInventory, Name: "device_type", Value: "raspberrypi4"},
+			{Scope: AttrScopeTags, Name: "site", Value: "oslo"},
+			{Scope: AttrScopeTags, Name: "floor", Value: "2"},
+		},
+	}
+
+	// all the tags by default
+	attrs, missing := DeviceTemplateRequest{}.Extract(dev)
+	assert.Equal(t, DeviceAttributes{
+		{Scope: AttrScopeTags, Name: "floor", Value: "2"},
+		{Scope: AttrScopeTags, Name: "site", Value: "oslo"},
+	}, attrs)
+	assert.Empty(t, missing)
+
+	attrs, missing = DeviceTemplateRequest{Attributes: []SelectAttribute{
+		{Scope: AttrScopeTags, Attribute: "site"},
+		{Scope: AttrScopeInventory, Attribute: "device_type"},
+		{Scope: AttrScopeTags, Attribute: "owner"},
+		{Scope: AttrScopeTags, Attribute: "owner"},
+	}}.Extract(dev)
+	assert.Equal(t, DeviceAttributes{
+		{Scope: AttrScopeInventory, Name: "device_type", Value: "raspberrypi4"},
+		{Scope: AttrScopeTags, Name: "site", Value: "oslo"},
+	}, attrs)
+	assert.Equal(t, []SelectAttribute{
+		{Scope: AttrScopeTags, Attribute: "owner"},
+	}, missing)
+}
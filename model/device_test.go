@@ -14,9 +14,12 @@
 package model
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
+	"time"
 
+	"github.com/mendersoftware/go-lib-micro/identity"
 	"github.com/stretchr/testify/assert"
 	"go.mongodb.org/mongo-driver/bson"
 )
@@ -133,6 +136,20 @@ func TestMarshalUnmarshalBSON(t *testing.T) {
 		assert.NoError(t, err)
 		assert.EqualValues(t, dev, tmp)
 	}
+
+	// member of several groups: the group attribute holds the first one
+	dev.Attributes = dev.Attributes[:2]
+	dev.Group = ""
+	dev.Groups = []GroupName{"bar", "baz"}
+	b, err = bson.Marshal(dev)
+	if assert.NoError(t, err) {
+		var tmp Device
+		err := bson.Unmarshal(b, &tmp)
+		assert.NoError(t, err)
+		assert.Equal(t, GroupName("bar"), tmp.Group)
+		assert.Contains(t, tmp.Attributes, groupAttr)
+		assert.Equal(t, dev.Groups, tmp.Groups)
+	}
 }
 
 func TestValidateDeviceAttributes(t *testing.T) {
@@ -278,3 +295,59 @@ func TestValidateGroupName(t *testing.T) {
 	group4 := GroupName("test")
 	assert.NoError(t, group4.Validate())
 }
+
+func TestNewAttributeSource(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	testCases := map[string]struct {
+		identity *identity.Identity
+		source   AttributeSource
+	}{
+		"internal, no identity": {
+			source: AttributeSource{
+				Type:      SourceTypeInternal,
+				Timestamp: now,
+			},
+		},
+		"internal, tenant only": {
+			identity: &identity.Identity{Tenant: "tenant"},
+			source: AttributeSource{
+				Type:      SourceTypeInternal,
+				Timestamp: now,
+			},
+		},
+		"device": {
+			identity: &identity.Identity{
+				Subject:  "device",
+				IsDevice: true,
+			},
+			source: AttributeSource{
+				Type:      SourceTypeDevice,
+				ID:        "device",
+				Timestamp: now,
+			},
+		},
+		"user": {
+			identity: &identity.Identity{
+				Subject: "user",
+				IsUser:  true,
+			},
+			source: AttributeSource{
+				Type:      SourceTypeUser,
+				ID:        "user",
+				Timestamp: now,
+			},
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.identity != nil {
+				ctx = identity.WithContext(ctx, tc.identity)
+			}
+			assert.Equal(t, tc.source, NewAttributeSource(ctx, now))
+		})
+	}
+}
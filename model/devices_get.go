@@ -0,0 +1,40 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// DevicesGetMaxIDs is the maximum number of devices fetched at once.
+const DevicesGetMaxIDs = 500
+
+// DevicesGetRequest selects the devices to fetch by their IDs, and
+// optionally the attributes to return.
+type DevicesGetRequest struct {
+	IDs        []DeviceID        `json:"ids"`
+	Attributes []SelectAttribute `json:"attributes,omitempty"`
+}
+
+func (r DevicesGetRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.IDs,
+			validation.Required,
+			validation.Length(1, DevicesGetMaxIDs),
+			validation.Each(validation.Required),
+		),
+		validation.Field(&r.Attributes),
+	)
+}
@@ -0,0 +1,42 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDevicesGetRequestValidate(t *testing.T) {
+	assert.NoError(t, DevicesGetRequest{
+		IDs: []DeviceID{"1", "2"},
+		Attributes: []SelectAttribute{
+			{Scope: AttrScopeInventory, Attribute: "mac"},
+		},
+	}.Validate())
+	assert.EqualError(t, DevicesGetRequest{}.Validate(),
+		"ids: cannot be blank.")
+	assert.EqualError(t, DevicesGetRequest{
+		IDs: []DeviceID{"1", ""},
+	}.Validate(), "ids: (1: cannot be blank.).")
+	assert.EqualError(t, DevicesGetRequest{
+		IDs: make([]DeviceID, DevicesGetMaxIDs+1),
+	}.Validate(), "ids: the length must be between 1 and 500.")
+	assert.EqualError(t, DevicesGetRequest{
+		IDs:        []DeviceID{"1"},
+		Attributes: []SelectAttribute{{Scope: AttrScopeInventory}},
+	}.Validate(), "attributes: (0: (attribute: cannot be blank.).).")
+}
@@ -0,0 +1,98 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// DigestHoursMax is the longest period of the digests and the longest
+// time after which the devices are considered silent: 30 days.
+const DigestHoursMax = 30 * 24
+
+// DigestSettings configures the periodic summary of the inventory of
+// the tenant, delivered to the notification channel.
+type DigestSettings struct {
+	Channel NotificationChannel `json:"channel" bson:"channel"`
+	// PeriodHours is the time, in hours, covered by each digest.
+	PeriodHours int `json:"period_hours" bson:"period_hours"`
+	// SilentHours is the time, in hours, after which the devices which
+	// did not report to the inventory are considered silent.
+	SilentHours int `json:"silent_hours" bson:"silent_hours"`
+	// SentTs is the end of the period covered by the last digest; the
+	// next one starts there.
+	SentTs *time.Time `json:"sent_ts,omitempty" bson:"sent_ts,omitempty"`
+}
+
+func (s DigestSettings) Validate() error {
+	return validation.ValidateStruct(&s,
+		validation.Field(&s.Channel, validation.Required),
+		validation.Field(&s.PeriodHours,
+			validation.Required, validation.Min(1), validation.Max(DigestHoursMax)),
+		validation.Field(&s.SilentHours,
+			validation.Required, validation.Min(1), validation.Max(DigestHoursMax)),
+	)
+}
+
+// Period returns the time covered by each digest.
+func (s DigestSettings) Period() time.Duration {
+	return time.Duration(s.PeriodHours) * time.Hour
+}
+
+// SilentAfter returns the time after which the devices which did not
+// report are considered silent.
+func (s DigestSettings) SilentAfter() time.Duration {
+	return time.Duration(s.SilentHours) * time.Hour
+}
+
+// Window returns the period covered by the next digest: from the end
+// of the last one, or a whole period for the first one, until now.
+func (s DigestSettings) Window(now time.Time) (from, to time.Time) {
+	if s.SentTs != nil {
+		return *s.SentTs, now
+	}
+	return now.Add(-s.Period()), now
+}
+
+// Due returns true if the period of the next digest is over.
+func (s DigestSettings) Due(now time.Time) bool {
+	return s.SentTs == nil || !now.Before(s.SentTs.Add(s.Period()))
+}
+
+// FleetDigest summarizes the changes of the inventory over a period.
+type FleetDigest struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Devices is the number of devices in the inventory.
+	Devices int `json:"devices"`
+	// NewDevices is the number of devices first seen during the period.
+	NewDevices int `json:"new_devices"`
+	// SilentDevices is the number of devices which stopped reporting
+	// during the period: their last report is older than the silence
+	// threshold since the period.
+	SilentDevices int `json:"silent_devices"`
+	// GroupChanges counts the devices which joined or left each group
+	// during the period.
+	GroupChanges []GroupChanges `json:"group_changes"`
+}
+
+// GroupChanges counts the devices which joined or left a group; only
+// the last transition of each device is accounted for.
+type GroupChanges struct {
+	Group  GroupName `json:"group"`
+	Joined int       `json:"joined"`
+	Left   int       `json:"left"`
+}
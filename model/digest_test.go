@@ -0,0 +1,64 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDigestSettingsValidate(t *testing.T) {
+	channel := NotificationChannel{
+		Type: NotificationChannelWebhook,
+		URL:  "https://example.com/digest",
+	}
+	settings := DigestSettings{Channel: channel, PeriodHours: 24, SilentHours: 48}
+	assert.NoError(t, settings.Validate())
+	assert.Equal(t, 24*time.Hour, settings.Period())
+	assert.Equal(t, 48*time.Hour, settings.SilentAfter())
+
+	assert.EqualError(t, DigestSettings{
+		Channel:     channel,
+		SilentHours: 48,
+	}.Validate(), "period_hours: cannot be blank.")
+	assert.EqualError(t, DigestSettings{
+		Channel:     channel,
+		PeriodHours: 24,
+		SilentHours: DigestHoursMax + 1,
+	}.Validate(), "silent_hours: must be no greater than 720.")
+	assert.EqualError(t, DigestSettings{
+		PeriodHours: 24,
+		SilentHours: 48,
+	}.Validate(), "channel: (type: cannot be blank.).")
+}
+
+func TestDigestSettingsWindow(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	settings := DigestSettings{PeriodHours: 24}
+
+	from, to := settings.Window(now)
+	assert.Equal(t, now.Add(-24*time.Hour), from)
+	assert.Equal(t, now, to)
+	assert.True(t, settings.Due(now))
+
+	sent := now.Add(-time.Hour)
+	settings.SentTs = &sent
+	from, to = settings.Window(now)
+	assert.Equal(t, sent, from)
+	assert.Equal(t, now, to)
+	assert.False(t, settings.Due(now))
+	assert.True(t, settings.Due(now.Add(23*time.Hour)))
+}
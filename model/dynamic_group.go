@@ -0,0 +1,61 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DynamicGroupsMax is the maximum number of dynamic groups of a tenant.
+const DynamicGroupsMax = 100
+
+// DynamicGroup is a group defined by a filter expression rather than by
+// the group attribute of its members: the devices of the group are the
+// ones matching the expression at the time of the query.
+type DynamicGroup struct {
+	Name       GroupName `json:"name" bson:"_id"`
+	Expression string    `json:"expression" bson:"expression"`
+
+	CreatedTs time.Time `json:"created_ts" bson:"created_ts"`
+	UpdatedTs time.Time `json:"updated_ts" bson:"updated_ts"`
+}
+
+func (g DynamicGroup) Validate() error {
+	if err := g.Name.Validate(); err != nil {
+		return err
+	}
+	_, err := g.Filters()
+	return err
+}
+
+// Filters returns the filter predicates of the expression of the group.
+func (g DynamicGroup) Filters() ([]FilterPredicate, error) {
+	return parseGroupExpression(g.Expression)
+}
+
+func parseGroupExpression(expr string) ([]FilterPredicate, error) {
+	parsed, err := ParseFilterExpression(expr)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid expression")
+	}
+	for _, pred := range parsed.Predicates {
+		if err := pred.Validate(); err != nil {
+			return nil, errors.Wrap(err, "invalid expression")
+		}
+	}
+	return parsed.Predicates, nil
+}
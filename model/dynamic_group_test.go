@@ -0,0 +1,81 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDynamicGroupValidate(t *testing.T) {
+	testCases := map[string]struct {
+		group DynamicGroup
+		err   string
+	}{
+		"ok": {
+			group: DynamicGroup{
+				Name: "rpi4",
+				Expression: `inventory/device_type == "raspberrypi4" ` +
+					`and identity/status == "accepted"`,
+			},
+		},
+		"error, name": {
+			group: DynamicGroup{
+				Name:       "rpi 4",
+				Expression: `inventory/device_type == "raspberrypi4"`,
+			},
+			err: "Group name can only contain: upper/lowercase " +
+				"alphanum, -(dash), _(underscore)",
+		},
+		"error, no expression": {
+			group: DynamicGroup{Name: "rpi4"},
+			err:   "invalid expression: position 0: empty expression",
+		},
+		"error, expression": {
+			group: DynamicGroup{
+				Name:       "rpi4",
+				Expression: `inventory/device_type matches "(a*)*"`,
+			},
+			err: "invalid expression: value: " +
+				ErrRegexNestedRepetition.Error() + ".",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.group.Validate()
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDynamicGroupFilters(t *testing.T) {
+	group := DynamicGroup{
+		Name:       "rpi4",
+		Expression: `inventory/device_type == "raspberrypi4"`,
+	}
+	filters, err := group.Filters()
+	assert.NoError(t, err)
+	assert.Equal(t, []FilterPredicate{{
+		Scope:     AttrScopeInventory,
+		Attribute: "device_type",
+		Type:      "$eq",
+		Value:     "raspberrypi4",
+	}}, filters)
+}
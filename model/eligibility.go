@@ -0,0 +1,235 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// EligibilityMaxConditions is the maximum number of conditions of
+// an eligibility check.
+const EligibilityMaxConditions = 100
+
+const (
+	EligibilityEq     = "$eq"
+	EligibilityNe     = "$ne"
+	EligibilityIn     = "$in"
+	EligibilityNin    = "$nin"
+	EligibilityGt     = "$gt"
+	EligibilityGte    = "$gte"
+	EligibilityLt     = "$lt"
+	EligibilityLte    = "$lte"
+	EligibilityExists = "$exists"
+)
+
+var validEligibilityTypes = []interface{}{
+	EligibilityEq, EligibilityNe,
+	EligibilityIn, EligibilityNin,
+	EligibilityGt, EligibilityGte,
+	EligibilityLt, EligibilityLte,
+	EligibilityExists,
+}
+
+// EligibilityCondition is a condition the attributes of a device must
+// satisfy, e.g. a minimum free disk space or a compatible device type.
+// Conditions on attributes holding arrays are satisfied by any of the
+// elements, except for $ne and $nin which must hold for all of them.
+type EligibilityCondition struct {
+	Scope     string      `json:"scope"`
+	Attribute string      `json:"attribute"`
+	Type      string      `json:"type"`
+	Value     interface{} `json:"value"`
+}
+
+func (c EligibilityCondition) Validate() error {
+	err := validation.ValidateStruct(&c,
+		validation.Field(&c.Scope, validation.Required),
+		validation.Field(&c.Attribute, validation.Required),
+		validation.Field(&c.Type,
+			validation.Required, validation.In(validEligibilityTypes...)),
+		validation.Field(&c.Value, validation.NotNil),
+	)
+	if err != nil {
+		return err
+	}
+	switch c.Type {
+	case EligibilityIn, EligibilityNin:
+		if _, ok := c.Value.([]interface{}); !ok {
+			return errors.Errorf("value: %s requires an array", c.Type)
+		}
+	case EligibilityExists:
+		if _, ok := c.Value.(bool); !ok {
+			return errors.Errorf("value: %s requires a boolean", c.Type)
+		}
+	case EligibilityGt, EligibilityGte, EligibilityLt, EligibilityLte:
+		switch c.Value.(type) {
+		case float64, string:
+		default:
+			return errors.Errorf(
+				"value: %s requires a number or a string", c.Type)
+		}
+	}
+	return nil
+}
+
+// EligibilityRequest lists the conditions a device must satisfy to be
+// eligible, e.g. for a deployment.
+type EligibilityRequest struct {
+	Conditions []EligibilityCondition `json:"conditions"`
+}
+
+func (r EligibilityRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Conditions,
+			validation.Required,
+			validation.Length(1, EligibilityMaxConditions)),
+	)
+}
+
+// EligibilityFailure is a condition the device does not satisfy, along
+// with the actual value of the attribute; nil if it is missing.
+type EligibilityFailure struct {
+	Condition EligibilityCondition `json:"condition"`
+	Actual    interface{}          `json:"actual"`
+}
+
+// DeviceEligibility is the outcome of an eligibility check.
+type DeviceEligibility struct {
+	DeviceID DeviceID             `json:"device_id"`
+	Eligible bool                 `json:"eligible"`
+	Failed   []EligibilityFailure `json:"failed"`
+}
+
+// CheckEligibility evaluates the conditions against the attributes of
+// the device.
+func CheckEligibility(dev *Device, conditions []EligibilityCondition) DeviceEligibility {
+	values := make(map[[2]string]interface{}, len(dev.Attributes))
+	for _, attr := range dev.Attributes {
+		values[[2]string{attr.Scope, attr.Name}] = attr.Value
+	}
+	res := DeviceEligibility{
+		DeviceID: dev.ID,
+		Failed:   []EligibilityFailure{},
+	}
+	for _, cond := range conditions {
+		actual, found := values[[2]string{cond.Scope, cond.Attribute}]
+		if !cond.satisfiedBy(actual, found) {
+			res.Failed = append(res.Failed, EligibilityFailure{
+				Condition: cond,
+				Actual:    actual,
+			})
+		}
+	}
+	res.Eligible = len(res.Failed) == 0
+	return res
+}
+
+func (c EligibilityCondition) satisfiedBy(actual interface{}, found bool) bool {
+	if c.Type == EligibilityExists {
+		exists, _ := c.Value.(bool)
+		return found == exists
+	} else if !found {
+		// a missing attribute only satisfies the negative conditions
+		return c.Type == EligibilityNe || c.Type == EligibilityNin
+	}
+	elems := []interface{}{actual}
+	switch arr := actual.(type) {
+	case []interface{}:
+		elems = arr
+	case primitive.A:
+		elems = arr
+	}
+	switch c.Type {
+	case EligibilityNe:
+		return !anyElem(elems, func(v interface{}) bool {
+			return eligibilityEqual(v, c.Value)
+		})
+	case EligibilityNin:
+		return !anyElem(elems, c.inValues)
+	case EligibilityIn:
+		return anyElem(elems, c.inValues)
+	case EligibilityEq:
+		return anyElem(elems, func(v interface{}) bool {
+			return eligibilityEqual(v, c.Value)
+		})
+	}
+	return anyElem(elems, func(v interface{}) bool {
+		cmp, ok := eligibilityCompare(v, c.Value)
+		if !ok {
+			return false
+		}
+		switch c.Type {
+		case EligibilityGt:
+			return cmp > 0
+		case EligibilityGte:
+			return cmp >= 0
+		case EligibilityLt:
+			return cmp < 0
+		default:
+			return cmp <= 0
+		}
+	})
+}
+
+func (c EligibilityCondition) inValues(v interface{}) bool {
+	values, _ := c.Value.([]interface{})
+	return anyElem(values, func(value interface{}) bool {
+		return eligibilityEqual(v, value)
+	})
+}
+
+func anyElem(elems []interface{}, pred func(interface{}) bool) bool {
+	for _, elem := range elems {
+		if pred(elem) {
+			return true
+		}
+	}
+	return false
+}
+
+func eligibilityEqual(a, b interface{}) bool {
+	cmp, ok := eligibilityCompare(a, b)
+	return ok && cmp == 0
+}
+
+// eligibilityCompare compares two numbers or two strings; values of
+// different types are not comparable.
+func eligibilityCompare(a, b interface{}) (int, bool) {
+	switch av := a.(type) {
+	case float64:
+		bv, ok := b.(float64)
+		if !ok {
+			return 0, false
+		} else if av < bv {
+			return -1, true
+		} else if av > bv {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		bv, ok := b.(string)
+		if !ok {
+			return 0, false
+		} else if av < bv {
+			return -1, true
+		} else if av > bv {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,125 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestEligibilityRequestValidate(t *testing.T) {
+	assert.NoError(t, EligibilityRequest{
+		Conditions: []EligibilityCondition{{
+			Scope:     AttrScopeInventory,
+			Attribute: "rootfs_free_kb",
+			Type:      EligibilityGte,
+			Value:     float64(1024),
+		}, {
+			Scope:     AttrScopeInventory,
+			Attribute: "device_type",
+			Type:      EligibilityIn,
+			Value:     []interface{}{"rpi3", "rpi4"},
+		}},
+	}.Validate())
+	assert.EqualError(t, EligibilityRequest{}.Validate(),
+		"conditions: cannot be blank.")
+	assert.EqualError(t, EligibilityRequest{
+		Conditions: []EligibilityCondition{{
+			Scope:     AttrScopeInventory,
+			Attribute: "device_type",
+			Type:      "$regex",
+			Value:     "rpi",
+		}},
+	}.Validate(), "conditions: (0: (type: must be a valid value.).).")
+	assert.EqualError(t, EligibilityRequest{
+		Conditions: []EligibilityCondition{{
+			Scope:     AttrScopeInventory,
+			Attribute: "device_type",
+			Type:      EligibilityIn,
+			Value:     "rpi4",
+		}},
+	}.Validate(), "conditions: (0: value: $in requires an array.).")
+	assert.EqualError(t, EligibilityCondition{
+		Scope:     AttrScopeInventory,
+		Attribute: "device_type",
+		Type:      EligibilityExists,
+		Value:     "yes",
+	}.Validate(), "value: $exists requires a boolean")
+	assert.EqualError(t, EligibilityCondition{
+		Scope:     AttrScopeInventory,
+		Attribute: "rootfs_free_kb",
+		Type:      EligibilityLt,
+		Value:     []interface{}{float64(1)},
+	}.Validate(), "value: $lt requires a number or a string")
+}
+
+func TestCheckEligibility(t *testing.T) {
+	dev := &Device{
+		ID: "1",
+		Attributes: DeviceAttributes{
+			{Scope: AttrScopeInventory, Name: "device_type", Value: "rpi4"},
+			{Scope: AttrScopeInventory, Name: "rootfs_free_kb", Value: float64(2048)},
+			{Scope: AttrScopeInventory, Name: "mac", Value: primitive.A{"00:01", "00:02"}},
+		},
+	}
+	cond := func(name, typ string, value interface{}) EligibilityCondition {
+		return EligibilityCondition{
+			Scope:     AttrScopeInventory,
+			Attribute: name,
+			Type:      typ,
+			Value:     value,
+		}
+	}
+
+	satisfied := []EligibilityCondition{
+		cond("device_type", EligibilityEq, "rpi4"),
+		cond("device_type", EligibilityIn, []interface{}{"rpi3", "rpi4"}),
+		cond("device_type", EligibilityNin, []interface{}{"bbb"}),
+		cond("rootfs_free_kb", EligibilityGte, float64(2048)),
+		cond("rootfs_free_kb", EligibilityLt, float64(4096)),
+		cond("mac", EligibilityEq, "00:02"),
+		cond("mac", EligibilityNe, "00:03"),
+		cond("kernel", EligibilityExists, false),
+		cond("kernel", EligibilityNe, "5.4"),
+	}
+	assert.Equal(t, DeviceEligibility{
+		DeviceID: "1",
+		Eligible: true,
+		Failed:   []EligibilityFailure{},
+	}, CheckEligibility(dev, satisfied))
+
+	failed := []EligibilityCondition{
+		cond("device_type", EligibilityIn, []interface{}{"bbb"}),
+		cond("rootfs_free_kb", EligibilityGt, float64(4096)),
+		cond("rootfs_free_kb", EligibilityGt, "1024"),
+		cond("mac", EligibilityNe, "00:01"),
+		cond("kernel", EligibilityExists, true),
+		cond("kernel", EligibilityEq, "5.4"),
+	}
+	assert.Equal(t, DeviceEligibility{
+		DeviceID: "1",
+		Eligible: false,
+		Failed: []EligibilityFailure{
+			{Condition: failed[0], Actual: "rpi4"},
+			{Condition: failed[1], Actual: float64(2048)},
+			{Condition: failed[2], Actual: float64(2048)},
+			{Condition: failed[3], Actual: primitive.A{"00:01", "00:02"}},
+			{Condition: failed[4]},
+			{Condition: failed[5]},
+		},
+	}, CheckEligibility(dev, failed))
+}
@@ -0,0 +1,65 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+const (
+	// ExportKindInventory is a snapshot of the current inventory.
+	ExportKindInventory = "inventory"
+
+	ExportFormatParquet = "parquet"
+)
+
+const (
+	ExportStatusPending = "pending"
+	ExportStatusRunning = "running"
+	ExportStatusDone    = "done"
+	ExportStatusFailed  = "failed"
+)
+
+// ExportRequest starts an export job.
+type ExportRequest struct {
+	Kind   string `json:"kind"`
+	Format string `json:"format"`
+}
+
+func (r ExportRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Kind,
+			validation.Required, validation.In(ExportKindInventory)),
+		validation.Field(&r.Format, validation.In(ExportFormatParquet)),
+	)
+}
+
+// ExportJob tracks an export of the inventory to the blob store.
+type ExportJob struct {
+	ID     string `json:"id" bson:"_id"`
+	Kind   string `json:"kind" bson:"kind"`
+	Format string `json:"format" bson:"format"`
+	Status string `json:"status" bson:"status"`
+	// Object is the key of the exported file in the blob store, once
+	// the export is done.
+	Object  string `json:"object,omitempty" bson:"object,omitempty"`
+	Devices int    `json:"devices" bson:"devices"`
+	Error   string `json:"error,omitempty" bson:"error,omitempty"`
+
+	CreatedTs  time.Time  `json:"created_ts" bson:"created_ts"`
+	FinishedTs *time.Time `json:"finished_ts,omitempty" bson:"finished_ts,omitempty"`
+}
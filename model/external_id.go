@@ -0,0 +1,92 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"regexp"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pkg/errors"
+)
+
+// ExternalIDPrefix prefixes the references to devices by their external ID
+// in place of the device ID: external:<system>:<id>.
+const ExternalIDPrefix = "external:"
+
+// ExternalIDsBatchMax is the maximum number of external IDs mapped at once.
+const ExternalIDsBatchMax = 1000
+
+var validExternalSystemRegex = regexp.MustCompile("^[A-Za-z0-9_.-]+$")
+
+// ExternalIDRef references a device by its ID in an external system.
+type ExternalIDRef struct {
+	System string `json:"system" bson:"system"`
+	ID     string `json:"id" bson:"id"`
+}
+
+func (r ExternalIDRef) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.System,
+			validation.Required,
+			validation.Length(1, 64),
+			validation.Match(validExternalSystemRegex),
+		),
+		validation.Field(&r.ID,
+			validation.Required,
+			validation.Length(1, 1024),
+		),
+	)
+}
+
+func (r ExternalIDRef) String() string {
+	return ExternalIDPrefix + r.System + ":" + r.ID
+}
+
+// ParseExternalIDRef parses the reference to a device by its external ID;
+// it returns nil if the reference is a plain device ID.
+func ParseExternalIDRef(ref string) (*ExternalIDRef, error) {
+	if !strings.HasPrefix(ref, ExternalIDPrefix) {
+		return nil, nil
+	}
+	parts := strings.SplitN(strings.TrimPrefix(ref, ExternalIDPrefix), ":", 2)
+	if len(parts) != 2 {
+		return nil, errors.Errorf(
+			"invalid external device reference: %s, expected %s<system>:<id>",
+			ref, ExternalIDPrefix,
+		)
+	}
+	r := &ExternalIDRef{System: parts[0], ID: parts[1]}
+	if err := r.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid external device reference")
+	}
+	return r, nil
+}
+
+// ExternalID maps the ID of a device in an external system to its ID
+// in the inventory.
+type ExternalID struct {
+	ExternalIDRef `bson:",inline"`
+	DeviceID      DeviceID `json:"device_id" bson:"device_id"`
+}
+
+func (e ExternalID) Validate() error {
+	if err := e.ExternalIDRef.Validate(); err != nil {
+		return err
+	}
+	return validation.ValidateStruct(&e,
+		validation.Field(&e.DeviceID, validation.Required),
+	)
+}
@@ -0,0 +1,85 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseExternalIDRef(t *testing.T) {
+	testCases := map[string]struct {
+		ref string
+
+		out *ExternalIDRef
+		err string
+	}{
+		"ok, device ID": {
+			ref: "5c8a4e4d6f4b0f0001a3c1e2",
+		},
+		"ok, external ID": {
+			ref: "external:erp:SN-1234:56",
+			out: &ExternalIDRef{System: "erp", ID: "SN-1234:56"},
+		},
+		"error, no ID": {
+			ref: "external:erp",
+			err: "invalid external device reference: external:erp, " +
+				"expected external:<system>:<id>",
+		},
+		"error, invalid system": {
+			ref: "external:e/rp:1",
+			err: "invalid external device reference: " +
+				"system: must be in a valid format.",
+		},
+		"error, empty ID": {
+			ref: "external:erp:",
+			err: "invalid external device reference: id: cannot be blank.",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ref, err := ParseExternalIDRef(tc.ref)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.out, ref)
+			}
+		})
+	}
+	ref := ExternalIDRef{System: "erp", ID: "1"}
+	assert.Equal(t, "external:erp:1", ref.String())
+}
+
+func TestExternalIDValidate(t *testing.T) {
+	var extID ExternalID
+	err := json.Unmarshal(
+		[]byte(`{"system": "erp", "id": "1", "device_id": "dev"}`),
+		&extID,
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, ExternalID{
+		ExternalIDRef: ExternalIDRef{System: "erp", ID: "1"},
+		DeviceID:      "dev",
+	}, extID)
+	assert.NoError(t, extID.Validate())
+
+	extID.DeviceID = ""
+	assert.EqualError(t, extID.Validate(), "device_id: cannot be blank.")
+	extID.System = ""
+	assert.EqualError(t, extID.Validate(), "system: cannot be blank.")
+}
@@ -0,0 +1,102 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"github.com/pkg/errors"
+)
+
+// FeatureFlag names a feature which can be enabled per tenant.
+type FeatureFlag string
+
+const (
+	// FeatureDynamicGroups enables the groups defined by filters.
+	FeatureDynamicGroups FeatureFlag = "dynamic_groups"
+	// FeatureWebhooks enables emitting the inventory events to the webhook.
+	FeatureWebhooks FeatureFlag = "webhooks"
+	// FeatureAPIv2 enables the v2 management API.
+	FeatureAPIv2 FeatureFlag = "api_v2"
+)
+
+// FeatureFlags are all the known feature flags.
+var FeatureFlags = []FeatureFlag{
+	FeatureDynamicGroups,
+	FeatureWebhooks,
+	FeatureAPIv2,
+}
+
+func (f FeatureFlag) Validate() error {
+	for _, flag := range FeatureFlags {
+		if f == flag {
+			return nil
+		}
+	}
+	return errors.Errorf("unknown feature flag: %s", f)
+}
+
+// FeatureFlagSet maps the feature flags to their state.
+type FeatureFlagSet map[FeatureFlag]bool
+
+func (s FeatureFlagSet) Validate() error {
+	for flag := range s {
+		if err := flag.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Enabled returns true if the feature is enabled in the set.
+func (s FeatureFlagSet) Enabled(flag FeatureFlag) bool {
+	return s[flag]
+}
+
+// Merge returns a copy of the set with the flags overridden by the given
+// overrides.
+func (s FeatureFlagSet) Merge(overrides FeatureFlagSet) FeatureFlagSet {
+	merged := make(FeatureFlagSet, len(s)+len(overrides))
+	for flag, enabled := range s {
+		merged[flag] = enabled
+	}
+	for flag, enabled := range overrides {
+		merged[flag] = enabled
+	}
+	return merged
+}
+
+// DefaultFeatureFlags enables all the known features.
+func DefaultFeatureFlags() FeatureFlagSet {
+	flags := make(FeatureFlagSet, len(FeatureFlags))
+	for _, flag := range FeatureFlags {
+		flags[flag] = true
+	}
+	return flags
+}
+
+// FeatureFlagsUpdate changes the per-tenant overrides of the feature flags;
+// a nil state removes the override, reverting the flag to its default.
+type FeatureFlagsUpdate map[FeatureFlag]*bool
+
+func (u FeatureFlagsUpdate) Validate() error {
+	if len(u) == 0 {
+		return errors.New("no feature flags to update")
+	}
+	for flag := range u {
+		if err := flag.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeatureFlagSet(t *testing.T) {
+	defaults := DefaultFeatureFlags()
+	assert.NoError(t, defaults.Validate())
+	for _, flag := range FeatureFlags {
+		assert.True(t, defaults.Enabled(flag))
+	}
+
+	merged := defaults.Merge(FeatureFlagSet{FeatureWebhooks: false})
+	assert.False(t, merged.Enabled(FeatureWebhooks))
+	assert.True(t, merged.Enabled(FeatureAPIv2))
+	assert.True(t, defaults.Enabled(FeatureWebhooks))
+
+	assert.EqualError(t, FeatureFlagSet{"teleport": true}.Validate(),
+		"unknown feature flag: teleport")
+}
+
+func TestFeatureFlagsUpdateValidate(t *testing.T) {
+	enabled := true
+	assert.NoError(t, FeatureFlagsUpdate{
+		FeatureWebhooks: &enabled,
+		FeatureAPIv2:    nil,
+	}.Validate())
+	assert.EqualError(t, FeatureFlagsUpdate{}.Validate(),
+		"no feature flags to update")
+	assert.EqualError(t, FeatureFlagsUpdate{"teleport": nil}.Validate(),
+		"unknown feature flag: teleport")
+}
@@ -0,0 +1,494 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// Filter expressions are the textual form of the filter predicates, e.g.:
+//
+//   inventory/device_type == "raspberrypi4" and identity/mac not in ["a", "b"]
+//
+// Each predicate compares the attribute, given as scope/name, with a value:
+// a double-quoted string, a number, true or false, or a list of those.
+// Attribute names with spaces or special characters are double-quoted.
+// The string values are also matched with a regular expression or
+// a substring, e.g. inventory/hostname icontains "kitchen".
+
+const (
+	exprKeywordAnd = "and"
+	exprKeywordNot = "not"
+	exprKeywordIn  = "in"
+	exprOpEq       = "=="
+)
+
+// exprPatternKeywords are the keywords of the operators of the pattern
+// predicates, by their selectors.
+var exprPatternKeywords = map[string]string{
+	SelectorRegex:     "matches",
+	SelectorIRegex:    "imatches",
+	SelectorContains:  "contains",
+	SelectorIContains: "icontains",
+}
+
+var exprKeywords = []string{
+	exprKeywordAnd, exprKeywordNot, exprKeywordIn, "true", "false",
+	"matches", "imatches", "contains", "icontains",
+}
+
+// FilterExpressionError is a syntax or type error in a filter definition.
+type FilterExpressionError struct {
+	// Position is the zero-based offset, in characters, of the error
+	// in the expression.
+	Position int `json:"position"`
+	// Term is the index of the offending predicate of the filter terms.
+	Term    *int   `json:"term,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *FilterExpressionError) Error() string {
+	if e.Term != nil {
+		return fmt.Sprintf("term %d: %s", *e.Term, e.Message)
+	}
+	return fmt.Sprintf("position %d: %s", e.Position, e.Message)
+}
+
+// FilterValidationRequest holds a filter either as an expression or as
+// the list of predicates.
+type FilterValidationRequest struct {
+	Expression string            `json:"expression,omitempty"`
+	Filters    []FilterPredicate `json:"filters,omitempty"`
+}
+
+func (r FilterValidationRequest) Validate() error {
+	if (r.Expression == "") == (len(r.Filters) == 0) {
+		return errors.New("exactly one of expression and filters is required")
+	}
+	return nil
+}
+
+// FilterValidation is the result of the validation of a filter; a valid
+// filter is returned in the canonical form of the expression and as
+// the list of predicates.
+type FilterValidation struct {
+	Valid     bool                    `json:"valid"`
+	Canonical string                  `json:"canonical,omitempty"`
+	Filters   []FilterPredicate       `json:"filters,omitempty"`
+	Errors    []FilterExpressionError `json:"errors,omitempty"`
+}
+
+// ParsedFilterExpression is a filter expression parsed into predicates.
+type ParsedFilterExpression struct {
+	Predicates []FilterPredicate
+	// Positions are the offsets of the predicates in the expression.
+	Positions []int
+}
+
+type exprToken struct {
+	kind  exprTokenKind
+	text  string
+	pos   int
+	value interface{}
+}
+
+type exprTokenKind int
+
+const (
+	exprTokenEOF exprTokenKind = iota
+	exprTokenWord
+	exprTokenString
+	exprTokenSymbol
+)
+
+func (t exprToken) String() string {
+	switch t.kind {
+	case exprTokenEOF:
+		return "end of expression"
+	case exprTokenString:
+		return "string " + t.text
+	default:
+		return strconv.Quote(t.text)
+	}
+}
+
+func exprSyntaxError(pos int, format string, args ...interface{}) error {
+	return &FilterExpressionError{
+		Position: pos,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+func isExprSymbol(r rune) bool {
+	return strings.ContainsRune("/=![],", r)
+}
+
+func isExprWordRune(r rune) bool {
+	return !unicode.IsSpace(r) && !isExprSymbol(r) && r != '"'
+}
+
+// tokenizeFilterExpression splits the expression into words, strings and
+// symbols; the positions of the tokens are counted in characters.
+func tokenizeFilterExpression(expr string) ([]exprToken, error) {
+	runes := []rune(expr)
+	tokens := []exprToken{}
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"':
+			j := i + 1
+			for ; j < len(runes) && runes[j] != '"'; j++ {
+				if runes[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(runes) {
+				return nil, exprSyntaxError(i, "unterminated string")
+			}
+			text := string(runes[i : j+1])
+			var value string
+			if err := json.Unmarshal([]byte(text), &value); err != nil {
+				return nil, exprSyntaxError(i, "invalid string %s", text)
+			}
+			tokens = append(tokens, exprToken{
+				kind: exprTokenString, text: text, pos: i, value: value,
+			})
+			i = j + 1
+		case r == '=' || r == '!':
+			if i+1 < len(runes) && runes[i+1] == '=' {
+				tokens = append(tokens, exprToken{
+					kind: exprTokenSymbol, text: string(runes[i : i+2]), pos: i,
+				})
+				i += 2
+			} else if r == '=' {
+				tokens = append(tokens, exprToken{
+					kind: exprTokenSymbol, text: exprOpEq, pos: i,
+				})
+				i++
+			} else {
+				return nil, exprSyntaxError(i, "unexpected character %q", r)
+			}
+		case isExprSymbol(r):
+			tokens = append(tokens, exprToken{
+				kind: exprTokenSymbol, text: string(r), pos: i,
+			})
+			i++
+		default:
+			j := i
+			for ; j < len(runes) && isExprWordRune(runes[j]); j++ {
+			}
+			tokens = append(tokens, exprToken{
+				kind: exprTokenWord, text: string(runes[i:j]), pos: i,
+			})
+			i = j
+		}
+	}
+	return append(tokens, exprToken{kind: exprTokenEOF, pos: len(runes)}), nil
+}
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() exprToken {
+	t := p.tokens[p.pos]
+	if t.kind != exprTokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *exprParser) isKeyword(t exprToken, keyword string) bool {
+	return t.kind == exprTokenWord && strings.EqualFold(t.text, keyword)
+}
+
+func (p *exprParser) expectSymbol(symbol string) error {
+	t := p.next()
+	if t.kind != exprTokenSymbol || t.text != symbol {
+		return exprSyntaxError(t.pos, "expected %q, found %s", symbol, t)
+	}
+	return nil
+}
+
+// parseName parses a scope or an attribute name, bare or double-quoted.
+func (p *exprParser) parseName(what string) (string, error) {
+	t := p.next()
+	switch t.kind {
+	case exprTokenString:
+		if name := t.value.(string); name != "" {
+			return name, nil
+		}
+	case exprTokenWord:
+		return t.text, nil
+	}
+	return "", exprSyntaxError(t.pos, "expected %s, found %s", what, t)
+}
+
+// parseScalar parses a string, a number or a boolean value.
+func (p *exprParser) parseScalar() (interface{}, error) {
+	t := p.next()
+	switch t.kind {
+	case exprTokenString:
+		return t.value, nil
+	case exprTokenWord:
+		if p.isKeyword(t, "true") {
+			return true, nil
+		} else if p.isKeyword(t, "false") {
+			return false, nil
+		}
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err == nil && !math.IsInf(n, 0) && !math.IsNaN(n) {
+			return n, nil
+		}
+		return nil, exprSyntaxError(t.pos,
+			"expected a value, found %s; strings must be double-quoted", t)
+	}
+	return nil, exprSyntaxError(t.pos, "expected a value, found %s", t)
+}
+
+// parseList parses a non-empty list of scalar values.
+func (p *exprParser) parseList() ([]interface{}, error) {
+	if err := p.expectSymbol("["); err != nil {
+		return nil, err
+	}
+	values := []interface{}{}
+	for {
+		value, err := p.parseScalar()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		t := p.next()
+		if t.kind == exprTokenSymbol && t.text == "]" {
+			return values, nil
+		} else if t.kind != exprTokenSymbol || t.text != "," {
+			return nil, exprSyntaxError(t.pos,
+				"expected \",\" or \"]\", found %s", t)
+		}
+	}
+}
+
+func (p *exprParser) parsePredicate() (FilterPredicate, error) {
+	var (
+		pred FilterPredicate
+		err  error
+	)
+	if pred.Scope, err = p.parseName("attribute scope"); err != nil {
+		return pred, err
+	}
+	if err = p.expectSymbol("/"); err != nil {
+		return pred, err
+	}
+	if pred.Attribute, err = p.parseName("attribute name"); err != nil {
+		return pred, err
+	}
+
+	t := p.next()
+	switch {
+	case t.kind == exprTokenSymbol && t.text == exprOpEq:
+		pred.Type = "$eq"
+		pred.Value, err = p.parseScalar()
+	case p.isKeyword(t, exprKeywordNot):
+		if t = p.next(); !p.isKeyword(t, exprKeywordIn) {
+			return pred, exprSyntaxError(t.pos,
+				"expected %q, found %s", exprKeywordIn, t)
+		}
+		pred.Type = "$nin"
+		pred.Value, err = p.parseList()
+	default:
+		for selector, keyword := range exprPatternKeywords {
+			if p.isKeyword(t, keyword) {
+				pred.Type = selector
+				pred.Value, err = p.parsePattern()
+				return pred, err
+			}
+		}
+		err = exprSyntaxError(t.pos,
+			"expected an operator (%q, \"not in\", \"matches\" or "+
+				"\"contains\"), found %s", exprOpEq, t)
+	}
+	return pred, err
+}
+
+// parsePattern parses the string of a regular expression or a substring.
+func (p *exprParser) parsePattern() (interface{}, error) {
+	t := p.next()
+	if t.kind != exprTokenString {
+		return nil, exprSyntaxError(t.pos, "expected a string, found %s", t)
+	}
+	return t.value, nil
+}
+
+// ParseFilterExpression parses the filter expression; a syntax error is
+// returned as a *FilterExpressionError.
+func ParseFilterExpression(expr string) (*ParsedFilterExpression, error) {
+	tokens, err := tokenizeFilterExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	parsed := &ParsedFilterExpression{}
+	if p.peek().kind == exprTokenEOF {
+		return nil, exprSyntaxError(0, "empty expression")
+	}
+	for {
+		pos := p.peek().pos
+		pred, err := p.parsePredicate()
+		if err != nil {
+			return nil, err
+		}
+		parsed.Predicates = append(parsed.Predicates, pred)
+		parsed.Positions = append(parsed.Positions, pos)
+
+		t := p.next()
+		if t.kind == exprTokenEOF {
+			return parsed, nil
+		} else if !p.isKeyword(t, exprKeywordAnd) {
+			return nil, exprSyntaxError(t.pos,
+				"expected %q or end of expression, found %s", exprKeywordAnd, t)
+		}
+	}
+}
+
+// formatExprName returns the name bare if it parses back as a word,
+// or double-quoted otherwise.
+func formatExprName(name string) string {
+	bare := name != ""
+	for _, r := range name {
+		if !isExprWordRune(r) {
+			bare = false
+			break
+		}
+	}
+	for _, keyword := range exprKeywords {
+		if strings.EqualFold(name, keyword) {
+			bare = false
+		}
+	}
+	if bare {
+		return name
+	}
+	return formatExprValue(name)
+}
+
+func formatExprValue(value interface{}) string {
+	switch v := value.(type) {
+	case []interface{}:
+		values := make([]string, len(v))
+		for i := range v {
+			values[i] = formatExprValue(v[i])
+		}
+		return "[" + strings.Join(values, ", ") + "]"
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	b, _ := json.Marshal(value)
+	return string(b)
+}
+
+// FormatFilterExpression returns the canonical expression of the filter
+// predicates: keywords in lower case, single spaces between the tokens,
+// double-quoted strings and names quoted only where required.
+func FormatFilterExpression(predicates []FilterPredicate) string {
+	terms := make([]string, len(predicates))
+	for i, pred := range predicates {
+		op := exprOpEq
+		if pred.Type == "$nin" {
+			op = exprKeywordNot + " " + exprKeywordIn
+		} else if keyword, ok := exprPatternKeywords[pred.Type]; ok {
+			op = keyword
+		}
+		terms[i] = fmt.Sprintf("%s/%s %s %s",
+			formatExprName(pred.Scope),
+			formatExprName(pred.Attribute),
+			op,
+			formatExprValue(pred.Value),
+		)
+	}
+	return strings.Join(terms, " "+exprKeywordAnd+" ")
+}
+
+// CheckPredicate verifies the value of the predicate suits its operator
+// and conforms to the type of the attribute given its definition.
+func (d AttributeDefinition) CheckPredicate(pred FilterPredicate) error {
+	if pred.IsPattern() {
+		if _, ok := pred.Value.(string); !ok {
+			return errors.Errorf("the value of %q must be a string",
+				exprPatternKeywords[pred.Type])
+		}
+		if d.Type == AttributeTypeNumber {
+			return errors.Errorf("attribute %s/%s: %q matches strings only",
+				d.Scope, d.Name, exprPatternKeywords[pred.Type])
+		}
+		return nil
+	}
+	if pred.Type == "$nin" {
+		values, ok := pred.Value.([]interface{})
+		if !ok {
+			return errors.New("the value of \"not in\" must be a list")
+		}
+		for _, v := range values {
+			if err := d.checkFilterValue(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if _, isList := pred.Value.([]interface{}); isList {
+		return errors.New("the value of \"==\" must not be a list")
+	}
+	return d.checkFilterValue(pred.Value)
+}
+
+func (d AttributeDefinition) checkFilterValue(value interface{}) error {
+	if d.Type == AttributeTypeArray {
+		// a scalar matches any element of an array
+		return nil
+	}
+	if err := d.Check(value); err != nil {
+		return errors.Wrapf(err,
+			"attribute %s/%s", d.Scope, d.Name)
+	}
+	return nil
+}
+
+// TermErrors validates the filter terms given as predicates.
+func TermErrors(predicates []FilterPredicate) []FilterExpressionError {
+	var errs []FilterExpressionError
+	for i, pred := range predicates {
+		if err := pred.Validate(); err != nil {
+			term := i
+			errs = append(errs, FilterExpressionError{
+				Term:    &term,
+				Message: err.Error(),
+			})
+		}
+	}
+	return errs
+}
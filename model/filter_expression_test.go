@@ -0,0 +1,189 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFilterExpression(t *testing.T) {
+	testCases := map[string]struct {
+		expr string
+
+		predicates []FilterPredicate
+		positions  []int
+		canonical  string
+		err        string
+	}{
+		"ok": {
+			expr: `inventory/device_type == "rpi4"`,
+			predicates: []FilterPredicate{{
+				Scope: "inventory", Attribute: "device_type",
+				Type: "$eq", Value: "rpi4",
+			}},
+			positions: []int{0},
+			canonical: `inventory/device_type == "rpi4"`,
+		},
+		"ok, normalized": {
+			expr: `  inventory / cpu_count=4 AND identity/"mac address" NOT IN ["a",true ,1.5]`,
+			predicates: []FilterPredicate{{
+				Scope: "inventory", Attribute: "cpu_count",
+				Type: "$eq", Value: float64(4),
+			}, {
+				Scope: "identity", Attribute: "mac address",
+				Type: "$nin", Value: []interface{}{"a", true, 1.5},
+			}},
+			positions: []int{2, 30},
+			canonical: `inventory/cpu_count == 4 and ` +
+				`identity/"mac address" not in ["a", true, 1.5]`,
+		},
+		"ok, keyword as a name": {
+			expr: `inventory/"and" == "\"x\""`,
+			predicates: []FilterPredicate{{
+				Scope: "inventory", Attribute: "and",
+				Type: "$eq", Value: `"x"`,
+			}},
+			positions: []int{0},
+			canonical: `inventory/"and" == "\"x\""`,
+		},
+		"error, empty": {
+			expr: "  ",
+			err:  "position 0: empty expression",
+		},
+		"error, unterminated string": {
+			expr: `inventory/device_type == "rpi4`,
+			err:  "position 25: unterminated string",
+		},
+		"error, unquoted string": {
+			expr: `inventory/device_type == rpi4`,
+			err: `position 25: expected a value, found "rpi4"; ` +
+				`strings must be double-quoted`,
+		},
+		"error, unknown operator": {
+			expr: `inventory/device_type != "rpi4"`,
+			err: `position 22: expected an operator ("==", "not in", ` +
+				`"matches" or "contains"), found "!="`,
+		},
+		"ok, patterns": {
+			expr: `inventory/hostname ICONTAINS "kitchen" and ` +
+				`inventory/device_type matches "^rpi[34]$"`,
+			predicates: []FilterPredicate{{
+				Scope: "inventory", Attribute: "hostname",
+				Type: SelectorIContains, Value: "kitchen",
+			}, {
+				Scope: "inventory", Attribute: "device_type",
+				Type: SelectorRegex, Value: "^rpi[34]$",
+			}},
+			positions: []int{0, 43},
+			canonical: `inventory/hostname icontains "kitchen" and ` +
+				`inventory/device_type matches "^rpi[34]$"`,
+		},
+		"error, pattern not a string": {
+			expr: `inventory/cpu_count contains 4`,
+			err:  `position 29: expected a string, found "4"`,
+		},
+		"error, missing scope": {
+			expr: `device_type == "rpi4"`,
+			err:  `position 12: expected "/", found "=="`,
+		},
+		"error, unterminated list": {
+			expr: `inventory/device_type not in ["a" "b"]`,
+			err:  `position 34: expected "," or "]", found string "b"`,
+		},
+		"error, missing conjunction": {
+			expr: `inventory/a == 1 inventory/b == 2`,
+			err: `position 17: expected "and" or end of expression, ` +
+				`found "inventory"`,
+		},
+		"error, trailing conjunction": {
+			expr: `inventory/a == 1 and`,
+			err:  "position 20: expected attribute scope, found end of expression",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			parsed, err := ParseFilterExpression(tc.expr)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				assert.IsType(t, &FilterExpressionError{}, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.predicates, parsed.Predicates)
+			assert.Equal(t, tc.positions, parsed.Positions)
+
+			canonical := FormatFilterExpression(parsed.Predicates)
+			assert.Equal(t, tc.canonical, canonical)
+			reparsed, err := ParseFilterExpression(canonical)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.predicates, reparsed.Predicates)
+		})
+	}
+}
+
+func TestAttributeDefinitionCheckPredicate(t *testing.T) {
+	def := AttributeDefinition{
+		Scope: "inventory", Name: "cpu_count", Type: AttributeTypeNumber,
+	}
+	assert.NoError(t, def.CheckPredicate(FilterPredicate{
+		Type: "$eq", Value: float64(4),
+	}))
+	assert.NoError(t, def.CheckPredicate(FilterPredicate{
+		Type: "$nin", Value: []interface{}{float64(1), float64(2)},
+	}))
+	assert.EqualError(t, def.CheckPredicate(FilterPredicate{
+		Type: "$eq", Value: "4",
+	}), "attribute inventory/cpu_count: must be of type number")
+	assert.EqualError(t, def.CheckPredicate(FilterPredicate{
+		Type: "$nin", Value: float64(4),
+	}), `the value of "not in" must be a list`)
+	assert.EqualError(t, def.CheckPredicate(FilterPredicate{
+		Type: "$eq", Value: []interface{}{float64(4)},
+	}), `the value of "==" must not be a list`)
+	assert.EqualError(t, def.CheckPredicate(FilterPredicate{
+		Type: SelectorContains, Value: "4",
+	}), `attribute inventory/cpu_count: "contains" matches strings only`)
+	assert.EqualError(t, def.CheckPredicate(FilterPredicate{
+		Type: SelectorRegex, Value: float64(4),
+	}), `the value of "matches" must be a string`)
+
+	def.Type = AttributeTypeArray
+	assert.NoError(t, def.CheckPredicate(FilterPredicate{
+		Type: "$eq", Value: "wifi",
+	}))
+	assert.NoError(t, def.CheckPredicate(FilterPredicate{
+		Type: SelectorIRegex, Value: "^wi",
+	}))
+}
+
+func TestFilterValidationRequestValidate(t *testing.T) {
+	assert.NoError(t, FilterValidationRequest{Expression: "x"}.Validate())
+	assert.NoError(t, FilterValidationRequest{
+		Filters: []FilterPredicate{{}},
+	}.Validate())
+	assert.EqualError(t, FilterValidationRequest{}.Validate(),
+		"exactly one of expression and filters is required")
+
+	errs := TermErrors([]FilterPredicate{
+		{Scope: "inventory", Attribute: "a", Type: "$eq", Value: "b"},
+		{Scope: "inventory", Attribute: "a", Type: "$gt", Value: "b"},
+	})
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, 1, *errs[0].Term)
+		assert.EqualError(t, &errs[0], "term 1: type: must be a valid value.")
+	}
+}
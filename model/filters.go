@@ -14,17 +14,46 @@
 package model
 
 import (
+	"regexp"
+
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/pkg/errors"
 )
 
+// The selectors of the filter predicates matching the string values
+// with a regular expression or a substring, with the "i" variants
+// ignoring the case.
+const (
+	SelectorRegex     = "$regex"
+	SelectorIRegex    = "$iregex"
+	SelectorContains  = "$contains"
+	SelectorIContains = "$icontains"
+)
+
 var validSelectors = []interface{}{
 	"$eq",
 	"$nin",
+	SelectorRegex,
+	SelectorIRegex,
+	SelectorContains,
+	SelectorIContains,
 }
 
 var validSortOrders = []interface{}{"asc", "desc"}
 
+// The placements of the devices without the sort attribute; by default
+// they sort as the lowest value, first in the ascending order and last
+// in the descending one.
+const (
+	SortNullsFirst = "first"
+	SortNullsLast  = "last"
+)
+
+var validSortNulls = []interface{}{SortNullsFirst, SortNullsLast}
+
+// SearchMaxTimeMS is the upper bound of the time limit of a search.
+const SearchMaxTimeMS = 60000
+
 type SearchParams struct {
 	Page       int               `json:"page"`
 	PerPage    int               `json:"per_page"`
@@ -32,6 +61,19 @@ type SearchParams struct {
 	Sort       []SortCriteria    `json:"sort"`
 	Attributes []SelectAttribute `json:"attributes"`
 	DeviceIDs  []string          `json:"device_ids"`
+	// MaxTimeMS limits the time of the search; when exceeded, the devices
+	// found so far are returned as partial results. Zero is no limit.
+	MaxTimeMS int `json:"max_time_ms,omitempty"`
+	// Completeness limits the search to the devices whose inventory
+	// completeness is in the range.
+	Completeness *CompletenessRange `json:"completeness,omitempty"`
+	// RequiredAttributes are the attributes the completeness is computed
+	// from; they are resolved from the schema, not given by the client.
+	RequiredAttributes []SelectAttribute `json:"-"`
+	// Cursor selects the keyset pagination, resuming the search after
+	// the device of the cursor in place of the Page; the cursor is given
+	// with the query parameters, not in the body.
+	Cursor *DeviceCursor `json:"-"`
 }
 
 type Filter struct {
@@ -41,16 +83,25 @@ type Filter struct {
 }
 
 type FilterPredicate struct {
-	Scope     string      `json:"scope" bson:"scope"`
-	Attribute string      `json:"attribute" bson:"attribute"`
-	Type      string      `json:"type" bson:"type"`
-	Value     interface{} `json:"value" bson:"value"`
+	Scope     string      `json:"scope" bson:"scope" yaml:"scope"`
+	Attribute string      `json:"attribute" bson:"attribute" yaml:"attribute"`
+	Type      string      `json:"type" bson:"type" yaml:"type"`
+	Value     interface{} `json:"value" bson:"value" yaml:"value"`
+	// Aliases are the other names of the attribute, resolved from
+	// the aliases of the tenant, not given by the client.
+	Aliases []string `json:"-" bson:"-" yaml:"-"`
 }
 
 type SortCriteria struct {
-	Scope     string `json:"scope"`
-	Attribute string `json:"attribute"`
-	Order     string `json:"order"`
+	Scope     string `json:"scope" yaml:"scope"`
+	Attribute string `json:"attribute" yaml:"attribute"`
+	Order     string `json:"order" yaml:"order"`
+	// Nulls places the devices without the attribute first or last,
+	// regardless of the order.
+	Nulls string `json:"nulls,omitempty" bson:"nulls,omitempty" yaml:"nulls,omitempty"`
+	// Aliases are the other names of the attribute, resolved from
+	// the aliases of the tenant, not given by the client.
+	Aliases []string `json:"-" bson:"-" yaml:"-"`
 }
 
 type SelectAttribute struct {
@@ -59,6 +110,15 @@ type SelectAttribute struct {
 }
 
 func (sp SearchParams) Validate() error {
+	err := validation.ValidateStruct(&sp,
+		validation.Field(&sp.MaxTimeMS,
+			validation.Min(0), validation.Max(SearchMaxTimeMS)),
+		validation.Field(&sp.Completeness),
+	)
+	if err != nil {
+		return err
+	}
+
 	for _, f := range sp.Filters {
 		err := f.Validate()
 		if err != nil {
@@ -67,26 +127,33 @@ func (sp SearchParams) Validate() error {
 	}
 
 	for _, s := range sp.Sort {
-		err := validation.ValidateStruct(&s,
-			validation.Field(&s.Scope, validation.Required),
-			validation.Field(&s.Attribute, validation.Required),
-			validation.Field(&s.Order, validation.Required, validation.In(validSortOrders...)))
-		if err != nil {
+		if err := s.Validate(); err != nil {
 			return err
 		}
 	}
 
 	for _, s := range sp.Attributes {
-		err := validation.ValidateStruct(&s,
-			validation.Field(&s.Scope, validation.Required),
-			validation.Field(&s.Attribute, validation.Required))
-		if err != nil {
+		if err := s.Validate(); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+func (s SortCriteria) Validate() error {
+	return validation.ValidateStruct(&s,
+		validation.Field(&s.Scope, validation.Required),
+		validation.Field(&s.Attribute, validation.Required),
+		validation.Field(&s.Order, validation.Required, validation.In(validSortOrders...)),
+		validation.Field(&s.Nulls, validation.In(validSortNulls...)))
+}
+
+func (s SelectAttribute) Validate() error {
+	return validation.ValidateStruct(&s,
+		validation.Field(&s.Scope, validation.Required),
+		validation.Field(&s.Attribute, validation.Required))
+}
+
 func (f Filter) Validate() error {
 	err := validation.ValidateStruct(&f,
 		validation.Field(&f.Name, validation.Required))
@@ -111,7 +178,52 @@ func (f Filter) Validate() error {
 func (f FilterPredicate) Validate() error {
 	return validation.ValidateStruct(&f,
 		validation.Field(&f.Scope, validation.Required),
-		validation.Field(&f.Attribute, validation.Required),
-		validation.Field(&f.Type, validation.Required, validation.In(validSelectors...)),
-		validation.Field(&f.Value, validation.NotNil))
+		validation.Field(&f.Attribute, validation.Required,
+			validation.When(f.Scope == AttrScopeTelemetry,
+				validation.In(validTelemetryAttributes...))),
+		validation.Field(&f.Type, validation.Required,
+			validation.When(f.Scope != AttrScopeTelemetry,
+				validation.In(validSelectors...)).
+				Else(validation.In(validTelemetrySelectors...))),
+		validation.Field(&f.Value, validation.NotNil,
+			validation.When(f.IsPattern(), validation.By(f.validatePattern))))
+}
+
+// IsPattern tells whether the predicate matches the values with
+// a regular expression or a substring.
+func (f FilterPredicate) IsPattern() bool {
+	switch f.Type {
+	case SelectorRegex, SelectorIRegex, SelectorContains, SelectorIContains:
+		return true
+	}
+	return false
+}
+
+func (f FilterPredicate) validatePattern(value interface{}) error {
+	pattern, ok := value.(string)
+	if !ok {
+		return errors.New("must be a string")
+	}
+	if f.Type == SelectorContains || f.Type == SelectorIContains {
+		if len(pattern) > RegexMaxLength {
+			return ErrRegexTooLong
+		}
+		return nil
+	}
+	return ValidateRegex(pattern)
+}
+
+// Regex returns the regular expression of a pattern predicate, with
+// the substrings escaped, and whether it ignores the case.
+func (f FilterPredicate) Regex() (pattern string, ignoreCase bool) {
+	pattern, _ = f.Value.(string)
+	switch f.Type {
+	case SelectorContains:
+		pattern = regexp.QuoteMeta(pattern)
+	case SelectorIContains:
+		pattern, ignoreCase = regexp.QuoteMeta(pattern), true
+	case SelectorIRegex:
+		ignoreCase = true
+	}
+	return pattern, ignoreCase
 }
@@ -52,6 +52,27 @@ func TestSearchParams(t *testing.T) {
 			},
 			err: errors.New("attribute: cannot be blank."),
 		},
+		"ok, telemetry filters": {
+			params: &SearchParams{
+				Filters: []FilterPredicate{{
+					Scope:     AttrScopeTelemetry,
+					Attribute: AttrNameUpdatesPerDay,
+					Type:      "$gt",
+					Value:     float64(100),
+				}},
+			},
+		},
+		"ko, telemetry filters": {
+			params: &SearchParams{
+				Filters: []FilterPredicate{{
+					Scope:     AttrScopeTelemetry,
+					Attribute: "intervals",
+					Type:      "$gt",
+					Value:     float64(100),
+				}},
+			},
+			err: errors.New("attribute: must be a valid value."),
+		},
 		"ok, sort": {
 			params: &SearchParams{
 				Sort: []SortCriteria{
@@ -74,6 +95,27 @@ func TestSearchParams(t *testing.T) {
 			},
 			err: errors.New("attribute: cannot be blank."),
 		},
+		"ok, sort nulls": {
+			params: &SearchParams{
+				Sort: []SortCriteria{{
+					Scope:     "scope",
+					Attribute: "attribute",
+					Order:     "desc",
+					Nulls:     SortNullsFirst,
+				}},
+			},
+		},
+		"ko, sort nulls": {
+			params: &SearchParams{
+				Sort: []SortCriteria{{
+					Scope:     "scope",
+					Attribute: "attribute",
+					Order:     "desc",
+					Nulls:     "middle",
+				}},
+			},
+			err: errors.New("nulls: must be a valid value."),
+		},
 		"ok, attributes": {
 			params: &SearchParams{
 				Attributes: []SelectAttribute{
@@ -94,6 +136,55 @@ func TestSearchParams(t *testing.T) {
 			},
 			err: errors.New("attribute: cannot be blank."),
 		},
+		"ok, max time": {
+			params: &SearchParams{
+				MaxTimeMS: 500,
+			},
+		},
+		"ko, max time": {
+			params: &SearchParams{
+				MaxTimeMS: SearchMaxTimeMS + 1,
+			},
+			err: errors.New("max_time_ms: must be no greater than 60000."),
+		},
+		"ok, pattern filters": {
+			params: &SearchParams{
+				Filters: []FilterPredicate{{
+					Scope:     "inventory",
+					Attribute: "hostname",
+					Type:      SelectorIContains,
+					Value:     "(a+)+",
+				}, {
+					Scope:     "inventory",
+					Attribute: "device_type",
+					Type:      SelectorRegex,
+					Value:     "^rpi[0-9]+$",
+				}},
+			},
+		},
+		"ko, regex not a string": {
+			params: &SearchParams{
+				Filters: []FilterPredicate{{
+					Scope:     "inventory",
+					Attribute: "hostname",
+					Type:      SelectorRegex,
+					Value:     42.0,
+				}},
+			},
+			err: errors.New("value: must be a string."),
+		},
+		"ko, catastrophic regex": {
+			params: &SearchParams{
+				Filters: []FilterPredicate{{
+					Scope:     "inventory",
+					Attribute: "hostname",
+					Type:      SelectorIRegex,
+					Value:     "^(a+)+$",
+				}},
+			},
+			err: errors.New("value: nested repetitions " +
+				"(e.g. (a+)+) are not allowed in the pattern."),
+		},
 	}
 
 	for name, tc := range testCases {
@@ -0,0 +1,54 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"github.com/pkg/errors"
+)
+
+// GroupAssignment selects the devices to add to a group, given as a list
+// of device IDs, as filter predicates or both; with both, the devices must
+// match the filters too.
+type GroupAssignment struct {
+	DeviceIDs []DeviceID        `json:"device_ids"`
+	Filters   []FilterPredicate `json:"filters"`
+}
+
+func (a GroupAssignment) Validate() error {
+	if len(a.DeviceIDs) == 0 && len(a.Filters) == 0 {
+		return errors.New("either device IDs or filters must be provided")
+	}
+	return SearchParams{Filters: a.Filters}.Validate()
+}
+
+// SearchParams returns the search matching the selected devices.
+func (a GroupAssignment) SearchParams() SearchParams {
+	params := SearchParams{Filters: a.Filters}
+	for _, id := range a.DeviceIDs {
+		params.DeviceIDs = append(params.DeviceIDs, string(id))
+	}
+	return params
+}
+
+// GroupAssignmentPreview is the outcome of adding the selected devices to
+// a group, computed without modifying them.
+type GroupAssignmentPreview struct {
+	// Matched is the number of devices selected.
+	Matched int `json:"matched"`
+	// NotFound is the number of device IDs unknown to the inventory.
+	NotFound int `json:"not_found"`
+	// AlreadyInGroup is the number of selected devices which are
+	// already members of the group.
+	AlreadyInGroup int `json:"already_in_group"`
+}
@@ -0,0 +1,86 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupAssignmentValidate(t *testing.T) {
+	filters := []FilterPredicate{{
+		Scope:     AttrScopeInventory,
+		Attribute: "device_type",
+		Type:      "$eq",
+		Value:     "raspberrypi4",
+	}}
+	testCases := map[string]struct {
+		assignment GroupAssignment
+		err        string
+	}{
+		"ok, device IDs": {
+			assignment: GroupAssignment{DeviceIDs: []DeviceID{"1", "2"}},
+		},
+		"ok, filters": {
+			assignment: GroupAssignment{Filters: filters},
+		},
+		"ok, both": {
+			assignment: GroupAssignment{
+				DeviceIDs: []DeviceID{"1"},
+				Filters:   filters,
+			},
+		},
+		"error, empty": {
+			err: "either device IDs or filters must be provided",
+		},
+		"error, filter": {
+			assignment: GroupAssignment{Filters: []FilterPredicate{{
+				Scope:     AttrScopeInventory,
+				Attribute: "device_type",
+				Type:      "$gt",
+				Value:     "raspberry",
+			}}},
+			err: "type: must be a valid value.",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.assignment.Validate()
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGroupAssignmentSearchParams(t *testing.T) {
+	filters := []FilterPredicate{{
+		Scope:     AttrScopeInventory,
+		Attribute: "device_type",
+		Type:      "$eq",
+		Value:     "raspberrypi4",
+	}}
+	assert.Equal(t, SearchParams{
+		Filters:   filters,
+		DeviceIDs: []string{"1", "2"},
+	}, GroupAssignment{
+		DeviceIDs: []DeviceID{"1", "2"},
+		Filters:   filters,
+	}.SearchParams())
+	assert.Equal(t, SearchParams{Filters: filters},
+		GroupAssignment{Filters: filters}.SearchParams())
+}
@@ -0,0 +1,61 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+const (
+	// GroupTypeStatic is the type of the groups the devices are
+	// assigned to, GroupTypeDynamic of the groups defined by filter
+	// expressions.
+	GroupTypeStatic  = "static"
+	GroupTypeDynamic = "dynamic"
+
+	// GroupDescriptionMaxLength bounds the description of a group.
+	GroupDescriptionMaxLength = 1024
+)
+
+// GroupMetadata describes a group; the groups themselves remain the values
+// of the group attribute of their devices, or the dynamic groups.
+type GroupMetadata struct {
+	Name        GroupName `json:"name" bson:"_id"`
+	Type        string    `json:"type" bson:"type"`
+	Description string    `json:"description" bson:"description"`
+	// Parent is the group this group is nested in, if any
+	Parent GroupName `json:"parent,omitempty" bson:"parent,omitempty"`
+	// CreatedBy is the user who created the metadata, empty when it was
+	// created along with the group or backfilled
+	CreatedBy string `json:"created_by,omitempty" bson:"created_by,omitempty"`
+
+	CreatedTs time.Time `json:"created_ts" bson:"created_ts"`
+	UpdatedTs time.Time `json:"updated_ts" bson:"updated_ts"`
+}
+
+func (g GroupMetadata) Validate() error {
+	if err := g.Name.Validate(); err != nil {
+		return err
+	}
+	return validation.ValidateStruct(&g,
+		validation.Field(&g.Description,
+			validation.Length(0, GroupDescriptionMaxLength)),
+		validation.Field(&g.Parent,
+			validation.Skip.When(g.Parent == ""),
+			validation.NotIn(g.Name).Error("a group cannot be its own parent")),
+	)
+}
@@ -0,0 +1,66 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupMetadataValidate(t *testing.T) {
+	testCases := map[string]struct {
+		group GroupMetadata
+		err   string
+	}{
+		"ok": {
+			group: GroupMetadata{Name: "rpi4", Description: "Raspberry Pi 4"},
+		},
+		"ok, parent": {
+			group: GroupMetadata{Name: "rpi4", Parent: "rpi"},
+		},
+		"error, name": {
+			group: GroupMetadata{Name: "rpi 4"},
+			err: "Group name can only contain: upper/lowercase " +
+				"alphanum, -(dash), _(underscore)",
+		},
+		"error, description": {
+			group: GroupMetadata{
+				Name:        "rpi4",
+				Description: strings.Repeat("x", GroupDescriptionMaxLength+1),
+			},
+			err: "description: the length must be no more than 1024.",
+		},
+		"error, parent name": {
+			group: GroupMetadata{Name: "rpi4", Parent: "rpi 4"},
+			err: "parent: Group name can only contain: upper/lowercase " +
+				"alphanum, -(dash), _(underscore).",
+		},
+		"error, own parent": {
+			group: GroupMetadata{Name: "rpi4", Parent: "rpi4"},
+			err:   "parent: a group cannot be its own parent.",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.group.Validate()
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pkg/errors"
+)
+
+const (
+	// GroupPreviewSampleDefault is the default number of devices returned
+	// in the sample of the group preview.
+	GroupPreviewSampleDefault = 10
+	// GroupPreviewSampleMax is the maximum number of devices returned in
+	// the sample of the group preview.
+	GroupPreviewSampleMax = 50
+)
+
+// GroupPreview is a candidate definition of a dynamic group, given either
+// as the list of filter predicates or as a filter expression.
+type GroupPreview struct {
+	Filters    []FilterPredicate `json:"filters"`
+	Expression string            `json:"expression,omitempty"`
+	// Attributes are the attributes of the devices included in the sample;
+	// defaults to the group the devices belong to.
+	Attributes []SelectAttribute `json:"attributes"`
+	// Limit is the number of devices included in the sample.
+	Limit int `json:"limit"`
+}
+
+func (p GroupPreview) Validate() error {
+	filters := p.Filters
+	if p.Expression != "" {
+		if len(filters) > 0 {
+			return errors.New("filters and expression are mutually exclusive")
+		}
+		var err error
+		if filters, err = parseGroupExpression(p.Expression); err != nil {
+			return err
+		}
+	} else if len(filters) == 0 {
+		return errors.New("at least one filter term must be provided")
+	}
+	err := validation.ValidateStruct(&p,
+		validation.Field(&p.Limit,
+			validation.Min(0),
+			validation.Max(GroupPreviewSampleMax),
+		),
+	)
+	if err != nil {
+		return err
+	}
+	return SearchParams{
+		Filters:    filters,
+		Attributes: p.Attributes,
+	}.Validate()
+}
+
+// SearchParams returns the search for the first devices matching the
+// candidate definition; the expression, if any, must have been checked
+// by Validate.
+func (p GroupPreview) SearchParams() SearchParams {
+	if p.Expression != "" {
+		p.Filters, _ = parseGroupExpression(p.Expression)
+	}
+	params := SearchParams{
+		Page:       1,
+		PerPage:    p.Limit,
+		Filters:    p.Filters,
+		Attributes: p.Attributes,
+	}
+	if params.PerPage == 0 {
+		params.PerPage = GroupPreviewSampleDefault
+	}
+	if len(params.Attributes) == 0 {
+		params.Attributes = []SelectAttribute{{
+			Scope:     AttrScopeSystem,
+			Attribute: AttrNameGroup,
+		}}
+	}
+	return params
+}
+
+// GroupPreviewResult is the number of devices matching the candidate
+// definition of a dynamic group together with a sample of them.
+type GroupPreviewResult struct {
+	Count   int      `json:"count"`
+	Devices []Device `json:"devices"`
+}
@@ -0,0 +1,125 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupPreviewValidate(t *testing.T) {
+	filters := []FilterPredicate{{
+		Scope:     AttrScopeInventory,
+		Attribute: "device_type",
+		Type:      "$eq",
+		Value:     "raspberrypi4",
+	}}
+	testCases := map[string]struct {
+		preview GroupPreview
+		err     string
+	}{
+		"ok": {
+			preview: GroupPreview{Filters: filters, Limit: 5},
+		},
+		"ok, expression": {
+			preview: GroupPreview{
+				Expression: `inventory/device_type == "raspberrypi4"`,
+			},
+		},
+		"error, no filters": {
+			preview: GroupPreview{},
+			err:     "at least one filter term must be provided",
+		},
+		"error, filters and expression": {
+			preview: GroupPreview{
+				Filters:    filters,
+				Expression: `inventory/device_type == "raspberrypi4"`,
+			},
+			err: "filters and expression are mutually exclusive",
+		},
+		"error, expression": {
+			preview: GroupPreview{Expression: `inventory/device_type ==`},
+			err: "invalid expression: position 24: " +
+				"expected a value, found end of expression",
+		},
+		"error, limit": {
+			preview: GroupPreview{Filters: filters, Limit: 51},
+			err:     "limit: must be no greater than 50.",
+		},
+		"error, filter": {
+			preview: GroupPreview{Filters: []FilterPredicate{{
+				Scope:     AttrScopeInventory,
+				Attribute: "device_type",
+				Type:      "$gt",
+				Value:     "raspberry",
+			}}},
+			err: "type: must be a valid value.",
+		},
+		"error, attribute": {
+			preview: GroupPreview{
+				Filters:    filters,
+				Attributes: []SelectAttribute{{Attribute: "mac"}},
+			},
+			err: "scope: cannot be blank.",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.preview.Validate()
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGroupPreviewSearchParams(t *testing.T) {
+	filters := []FilterPredicate{{
+		Scope:     AttrScopeInventory,
+		Attribute: "device_type",
+		Type:      "$eq",
+		Value:     "raspberrypi4",
+	}}
+	assert.Equal(t, SearchParams{
+		Page:    1,
+		PerPage: GroupPreviewSampleDefault,
+		Filters: filters,
+		Attributes: []SelectAttribute{
+			{Scope: AttrScopeSystem, Attribute: AttrNameGroup},
+		},
+	}, GroupPreview{Filters: filters}.SearchParams())
+
+	attrs := []SelectAttribute{{Scope: AttrScopeInventory, Attribute: "mac"}}
+	assert.Equal(t, SearchParams{
+		Page:       1,
+		PerPage:    5,
+		Filters:    filters,
+		Attributes: attrs,
+	}, GroupPreview{Filters: filters, Attributes: attrs, Limit: 5}.SearchParams())
+
+	assert.Equal(t, SearchParams{
+		Page:    1,
+		PerPage: GroupPreviewSampleDefault,
+		Filters: filters,
+		Attributes: []SelectAttribute{
+			{Scope: AttrScopeSystem, Attribute: AttrNameGroup},
+		},
+	}, GroupPreview{
+		Expression: `inventory/device_type == "raspberrypi4"`,
+	}.SearchParams())
+}
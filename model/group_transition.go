@@ -0,0 +1,95 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	GroupTransitionJoined = "joined"
+	GroupTransitionLeft   = "left"
+)
+
+const (
+	GroupChangeUser     = SourceTypeUser
+	GroupChangeInternal = SourceTypeInternal
+	GroupChangeImport   = "import"
+	GroupChangeRule     = "rule"
+)
+
+const (
+	AttrNameGroupTransition       = "group_transition"
+	AttrNameGroupTransitionReason = "group_transition_reason"
+	AttrNameGroupTransitionTs     = "group_transition_ts"
+)
+
+// GroupChangeReason describes what caused a device to join or leave a group.
+type GroupChangeReason struct {
+	// Type is one of: user, internal, import or rule
+	Type string `json:"type"`
+	// ID of the user or of the rule which changed the group
+	ID string `json:"id,omitempty"`
+}
+
+// NewGroupChangeReason returns the reason of a group change requested by
+// the principal authenticated in the context.
+func NewGroupChangeReason(ctx context.Context) GroupChangeReason {
+	source := NewAttributeSource(ctx, time.Time{})
+	return GroupChangeReason{
+		Type: source.Type,
+		ID:   source.ID,
+	}
+}
+
+func (r GroupChangeReason) String() string {
+	if r.ID == "" {
+		return r.Type
+	}
+	return r.Type + ":" + r.ID
+}
+
+// GroupTransition is a device joining or leaving a group.
+type GroupTransition struct {
+	DeviceID DeviceID `json:"device_id"`
+	// Action is one of: joined or left
+	Action    string            `json:"action"`
+	Group     GroupName         `json:"group"`
+	Reason    GroupChangeReason `json:"reason"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// Attributes returns the system attributes recording the transition as
+// the last one of the device.
+func (t GroupTransition) Attributes() DeviceAttributes {
+	return DeviceAttributes{
+		{
+			Scope: AttrScopeSystem,
+			Name:  AttrNameGroupTransition,
+			Value: t.Action + ":" + string(t.Group),
+		},
+		{
+			Scope: AttrScopeSystem,
+			Name:  AttrNameGroupTransitionReason,
+			Value: t.Reason.String(),
+		},
+		{
+			Scope: AttrScopeSystem,
+			Name:  AttrNameGroupTransitionTs,
+			Value: t.Timestamp,
+		},
+	}
+}
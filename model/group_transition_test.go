@@ -0,0 +1,54 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewGroupChangeReason(t *testing.T) {
+	reason := NewGroupChangeReason(context.Background())
+	assert.Equal(t, GroupChangeReason{Type: GroupChangeInternal}, reason)
+	assert.Equal(t, "internal", reason.String())
+
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Subject: "alice",
+		IsUser:  true,
+	})
+	reason = NewGroupChangeReason(ctx)
+	assert.Equal(t, GroupChangeReason{Type: GroupChangeUser, ID: "alice"}, reason)
+	assert.Equal(t, "user:alice", reason.String())
+}
+
+func TestGroupTransitionAttributes(t *testing.T) {
+	now := time.Now()
+	transition := GroupTransition{
+		DeviceID:  "1",
+		Action:    GroupTransitionJoined,
+		Group:     "foo",
+		Reason:    GroupChangeReason{Type: GroupChangeRule, ID: "rule1"},
+		Timestamp: now,
+	}
+	assert.Equal(t, DeviceAttributes{
+		{Scope: "system", Name: "group_transition", Value: "joined:foo"},
+		{Scope: "system", Name: "group_transition_reason", Value: "rule:rule1"},
+		{Scope: "system", Name: "group_transition_ts", Value: now},
+	}, transition.Attributes())
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"fmt"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+const (
+	LimitPerPage       = "per_page"
+	LimitFilters       = "filters"
+	LimitExportDevices = "export_devices"
+)
+
+// Limits bound the size of the requests: the page size, the number of
+// filters and the number of exported devices. Zero leaves the size
+// unbounded. The same structure describes the size of a request.
+type Limits struct {
+	PerPage       int `json:"per_page,omitempty" bson:"per_page,omitempty"`
+	Filters       int `json:"filters,omitempty" bson:"filters,omitempty"`
+	ExportDevices int `json:"export_devices,omitempty" bson:"export_devices,omitempty"`
+}
+
+func (l Limits) Validate() error {
+	return validation.ValidateStruct(&l,
+		validation.Field(&l.PerPage, validation.Min(0)),
+		validation.Field(&l.Filters, validation.Min(0)),
+		validation.Field(&l.ExportDevices, validation.Min(0)),
+	)
+}
+
+// LimitViolation is a limit exceeded by a request.
+type LimitViolation struct {
+	Limit string
+	Value int
+	Max   int
+}
+
+func (v LimitViolation) String() string {
+	return fmt.Sprintf("%s %d exceeds the limit of %d", v.Limit, v.Value, v.Max)
+}
+
+// Exceeded returns the limits exceeded by a request of the given size.
+func (l Limits) Exceeded(size Limits) []LimitViolation {
+	var violations []LimitViolation
+	for _, c := range []struct {
+		limit      string
+		value, max int
+	}{
+		{LimitPerPage, size.PerPage, l.PerPage},
+		{LimitFilters, size.Filters, l.Filters},
+		{LimitExportDevices, size.ExportDevices, l.ExportDevices},
+	} {
+		if c.max > 0 && c.value > c.max {
+			violations = append(violations, LimitViolation{
+				Limit: c.limit,
+				Value: c.value,
+				Max:   c.max,
+			})
+		}
+	}
+	return violations
+}
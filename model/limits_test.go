@@ -0,0 +1,44 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitsValidate(t *testing.T) {
+	assert.NoError(t, Limits{}.Validate())
+	assert.NoError(t, Limits{PerPage: 100, ExportDevices: 1000}.Validate())
+	assert.EqualError(t, Limits{Filters: -1}.Validate(),
+		"filters: must be no less than 0.")
+}
+
+func TestLimitsExceeded(t *testing.T) {
+	limits := Limits{PerPage: 100, Filters: 5}
+
+	assert.Empty(t, limits.Exceeded(Limits{PerPage: 100, Filters: 5}))
+	// the export size is unbounded
+	assert.Empty(t, limits.Exceeded(Limits{ExportDevices: 1000000}))
+
+	violations := limits.Exceeded(Limits{PerPage: 200, Filters: 6})
+	assert.Equal(t, []LimitViolation{
+		{Limit: LimitPerPage, Value: 200, Max: 100},
+		{Limit: LimitFilters, Value: 6, Max: 5},
+	}, violations)
+	assert.Equal(t, "per_page 200 exceeds the limit of 100",
+		violations[0].String())
+}
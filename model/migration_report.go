@@ -0,0 +1,62 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"time"
+)
+
+// MigrationStep is the estimated impact of a migration on a database.
+type MigrationStep struct {
+	Version    string `json:"version"`
+	Collection string `json:"collection"`
+	// Documents is the estimated number of documents the migration
+	// updates, or scans if it only builds indexes.
+	Documents int64         `json:"documents"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// TenantMigrationPlan is the estimated impact of migrating the database
+// of a tenant.
+type TenantMigrationPlan struct {
+	TenantID string `json:"tenant_id,omitempty"`
+	Database string `json:"database"`
+	// Version is the current version of the database.
+	Version string          `json:"version"`
+	Devices int64           `json:"devices"`
+	Steps   []MigrationStep `json:"steps"`
+	// Start is the estimated time from the start of the migrations to
+	// the start of the migration of the database.
+	Start    time.Duration `json:"start"`
+	Duration time.Duration `json:"duration"`
+}
+
+// MigrationReport is the plan of the migrations of the databases to
+// the target version, in the order the databases are migrated.
+type MigrationReport struct {
+	Version  string                `json:"version"`
+	Tenants  []TenantMigrationPlan `json:"tenants"`
+	Duration time.Duration         `json:"duration"`
+}
+
+// Add schedules the migration of a database after the previous ones.
+func (r *MigrationReport) Add(plan TenantMigrationPlan) {
+	plan.Start = r.Duration
+	plan.Duration = 0
+	for _, step := range plan.Steps {
+		plan.Duration += step.Duration
+	}
+	r.Tenants = append(r.Tenants, plan)
+	r.Duration += plan.Duration
+}
@@ -0,0 +1,51 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrationReportAdd(t *testing.T) {
+	report := &MigrationReport{Version: "1.0.9"}
+	report.Add(TenantMigrationPlan{
+		Database: "inventory-foo",
+		Steps: []MigrationStep{
+			{Version: "1.0.8", Duration: time.Second},
+			{Version: "1.0.9", Duration: 2 * time.Second},
+		},
+	})
+	report.Add(TenantMigrationPlan{Database: "inventory-bar"})
+	report.Add(TenantMigrationPlan{
+		Database: "inventory-baz",
+		Steps:    []MigrationStep{{Version: "1.0.9", Duration: time.Minute}},
+	})
+
+	assert.Equal(t, time.Minute+3*time.Second, report.Duration)
+	assert.Len(t, report.Tenants, 3)
+	for i, tc := range []struct {
+		start    time.Duration
+		duration time.Duration
+	}{
+		{0, 3 * time.Second},
+		{3 * time.Second, 0},
+		{3 * time.Second, time.Minute},
+	} {
+		assert.Equal(t, tc.start, report.Tenants[i].Start)
+		assert.Equal(t, tc.duration, report.Tenants[i].Duration)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// PinnedAttributesMax is the maximum number of attributes a tenant can
+// pin.
+const PinnedAttributesMax = 20
+
+// PinnedAttributes are the attributes the device searches of the tenant
+// always return in the highlights of the devices, even when the search
+// selects other attributes.
+type PinnedAttributes struct {
+	Attributes []SelectAttribute `json:"attributes" bson:"attributes"`
+}
+
+func (p PinnedAttributes) Validate() error {
+	for _, attr := range p.Attributes {
+		if err := attr.Validate(); err != nil {
+			return err
+		}
+	}
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Attributes,
+			validation.Length(0, PinnedAttributesMax)),
+	)
+}
+
+// Highlight copies the pinned attributes of the devices to their
+// highlights. The devices are expected to hold the selected attributes
+// and the pinned ones; the pinned attributes which were not selected are
+// removed from the attributes. No attributes are removed if none are
+// selected, as then all of them are returned.
+func (p PinnedAttributes) Highlight(devs []Device, selected []SelectAttribute) {
+	if len(p.Attributes) == 0 {
+		return
+	}
+	pinned := make(map[SelectAttribute]bool, len(p.Attributes))
+	for _, attr := range p.Attributes {
+		pinned[attr] = true
+	}
+	keep := make(map[SelectAttribute]bool, len(selected))
+	for _, attr := range selected {
+		keep[attr] = true
+	}
+	for i := range devs {
+		dev := &devs[i]
+		attrs := dev.Attributes[:0]
+		for _, attr := range dev.Attributes {
+			key := SelectAttribute{Scope: attr.Scope, Attribute: attr.Name}
+			if pinned[key] {
+				dev.Highlights = append(dev.Highlights, attr)
+			}
+			if len(selected) == 0 || keep[key] || !pinned[key] {
+				attrs = append(attrs, attr)
+			}
+		}
+		dev.Attributes = attrs
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPinnedAttributesValidate(t *testing.T) {
+	assert.NoError(t, PinnedAttributes{}.Validate())
+	assert.NoError(t, PinnedAttributes{Attributes: []SelectAttribute{
+		{Scope: AttrScopeInventory, Attribute: "os"},
+	}}.Validate())
+	assert.EqualError(t, PinnedAttributes{Attributes: []SelectAttribute{
+		{Scope: AttrScopeInventory},
+	}}.Validate(), "attribute: cannot be blank.")
+
+	tooMany := PinnedAttributes{}
+	for i := 0; i <= PinnedAttributesMax; i++ {
+		tooMany.Attributes = append(tooMany.Attributes, SelectAttribute{
+			Scope:     AttrScopeInventory,
+			Attribute: fmt.Sprintf("attr%d", i),
+		})
+	}
+	assert.EqualError(t, tooMany.Validate(),
+		"attributes: the length must be no more than 20.")
+}
+
+func TestPinnedAttributesHighlight(t *testing.T) {
+	os := DeviceAttribute{Scope: AttrScopeInventory, Name: "os", Value: "linux"}
+	mac := DeviceAttribute{Scope: AttrScopeIdentity, Name: "mac", Value: "00:00"}
+	group := DeviceAttribute{Scope: AttrScopeSystem, Name: "group", Value: "dev"}
+	pinned := PinnedAttributes{Attributes: []SelectAttribute{
+		{Scope: AttrScopeInventory, Attribute: "os"},
+		{Scope: AttrScopeSystem, Attribute: "group"},
+	}}
+
+	// all the attributes are kept when none are selected
+	devs := []Device{
+		{ID: "1", Attributes: DeviceAttributes{os, mac, group}},
+		{ID: "2", Attributes: DeviceAttributes{mac}},
+	}
+	pinned.Highlight(devs, nil)
+	assert.Equal(t, []Device{
+		{
+			ID:         "1",
+			Attributes: DeviceAttributes{os, mac, group},
+			Highlights: DeviceAttributes{os, group},
+		},
+		{ID: "2", Attributes: DeviceAttributes{mac}},
+	}, devs)
+
+	// the pinned attributes not selected are removed
+	devs = []Device{
+		{ID: "1", Attributes: DeviceAttributes{os, mac, group}},
+	}
+	pinned.Highlight(devs, []SelectAttribute{
+		{Scope: AttrScopeIdentity, Attribute: "mac"},
+		{Scope: AttrScopeSystem, Attribute: "group"},
+	})
+	assert.Equal(t, []Device{{
+		ID:         "1",
+		Attributes: DeviceAttributes{mac, group},
+		Highlights: DeviceAttributes{os, group},
+	}}, devs)
+}
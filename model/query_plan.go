@@ -0,0 +1,47 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+// QueryPlan summarizes the plan the database chose to run a device query,
+// together with the execution statistics.
+type QueryPlan struct {
+	// Stage is the top stage of the winning plan.
+	Stage string `json:"stage"`
+	// Indexes lists the indexes scanned by the winning plan.
+	Indexes []string `json:"indexes"`
+	// CollectionScan is set if the plan scans the whole collection.
+	CollectionScan bool `json:"collection_scan"`
+
+	Returned        int64 `json:"returned"`
+	KeysExamined    int64 `json:"keys_examined"`
+	DocsExamined    int64 `json:"docs_examined"`
+	ExecutionTimeMS int64 `json:"execution_time_ms"`
+}
+
+// FilterTermCount is the number of devices matching a term of the filters
+// of a search, which explains the searches without results: the first
+// term matching no devices, alone or with the previous terms, is the one
+// eliminating all of them.
+type FilterTermCount struct {
+	Filter FilterPredicate `json:"filter"`
+	// Devices is the number of devices matching the term alone.
+	Devices int `json:"devices"`
+	// Cumulative is the number of devices matching the term and all
+	// the previous ones.
+	Cumulative int `json:"cumulative"`
+	// Reported is the number of devices reporting the attribute of the
+	// term; it is only counted for the terms matching no devices.
+	Reported *int `json:"reported,omitempty"`
+}
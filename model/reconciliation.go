@@ -0,0 +1,93 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"sort"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// Reconciliation is the set of devices known to the other services,
+// as listed by their internal APIs, to compare with the inventory.
+type Reconciliation struct {
+	DeviceAuth  []DeviceID `json:"deviceauth"`
+	Deployments []DeviceID `json:"deployments"`
+	// CreateMissing creates the inventory records of the devices known
+	// to deviceauth but missing from the inventory.
+	CreateMissing bool `json:"create_missing"`
+}
+
+func (r Reconciliation) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.DeviceAuth, validation.NotNil),
+		validation.Field(&r.Deployments, validation.NotNil),
+	)
+}
+
+// ReconciliationReport lists the devices missing from each of the services
+// while known to at least one of the others.
+type ReconciliationReport struct {
+	MissingFromInventory   []DeviceID `json:"missing_from_inventory"`
+	MissingFromDeviceAuth  []DeviceID `json:"missing_from_deviceauth"`
+	MissingFromDeployments []DeviceID `json:"missing_from_deployments"`
+	// Created lists the devices added to the inventory.
+	Created []DeviceID `json:"created"`
+}
+
+// Report compares the devices known to the other services with
+// the devices in the inventory.
+func (r Reconciliation) Report(inventory []DeviceID) *ReconciliationReport {
+	inInventory := makeDeviceSet(inventory)
+	inDeviceAuth := makeDeviceSet(r.DeviceAuth)
+	inDeployments := makeDeviceSet(r.Deployments)
+
+	all := make(map[DeviceID]struct{}, len(inInventory))
+	for _, set := range []map[DeviceID]struct{}{
+		inInventory, inDeviceAuth, inDeployments,
+	} {
+		for id := range set {
+			all[id] = struct{}{}
+		}
+	}
+	return &ReconciliationReport{
+		MissingFromInventory:   missingFrom(inInventory, all),
+		MissingFromDeviceAuth:  missingFrom(inDeviceAuth, all),
+		MissingFromDeployments: missingFrom(inDeployments, all),
+		Created:                []DeviceID{},
+	}
+}
+
+func makeDeviceSet(ids []DeviceID) map[DeviceID]struct{} {
+	set := make(map[DeviceID]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+	return set
+}
+
+// missingFrom returns the sorted IDs of the devices missing from the set.
+func missingFrom(set, all map[DeviceID]struct{}) []DeviceID {
+	missing := []DeviceID{}
+	for id := range all {
+		if _, ok := set[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		return missing[i] < missing[j]
+	})
+	return missing
+}
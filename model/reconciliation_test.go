@@ -0,0 +1,56 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconciliationValidate(t *testing.T) {
+	assert.NoError(t, Reconciliation{
+		DeviceAuth:  []DeviceID{},
+		Deployments: []DeviceID{"1"},
+	}.Validate())
+	assert.EqualError(t, Reconciliation{
+		Deployments: []DeviceID{"1"},
+	}.Validate(), "deviceauth: is required.")
+}
+
+func TestReconciliationReport(t *testing.T) {
+	r := Reconciliation{
+		DeviceAuth:  []DeviceID{"1", "2", "3", "4"},
+		Deployments: []DeviceID{"1", "3", "5"},
+	}
+	report := r.Report([]DeviceID{"2", "1", "6"})
+	assert.Equal(t, &ReconciliationReport{
+		MissingFromInventory:   []DeviceID{"3", "4", "5"},
+		MissingFromDeviceAuth:  []DeviceID{"5", "6"},
+		MissingFromDeployments: []DeviceID{"2", "4", "6"},
+		Created:                []DeviceID{},
+	}, report)
+
+	r = Reconciliation{
+		DeviceAuth:  []DeviceID{"1"},
+		Deployments: []DeviceID{"1"},
+	}
+	assert.Equal(t, &ReconciliationReport{
+		MissingFromInventory:   []DeviceID{},
+		MissingFromDeviceAuth:  []DeviceID{},
+		MissingFromDeployments: []DeviceID{},
+		Created:                []DeviceID{},
+	}, r.Report([]DeviceID{"1"}))
+}
@@ -0,0 +1,75 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"regexp/syntax"
+
+	"github.com/pkg/errors"
+)
+
+// RegexMaxLength is the maximum length of the regular expressions and
+// substrings of the filters.
+const RegexMaxLength = 256
+
+var (
+	ErrRegexTooLong = errors.Errorf(
+		"the pattern must be at most %d characters long", RegexMaxLength)
+	ErrRegexNestedRepetition = errors.New(
+		"nested repetitions (e.g. (a+)+) are not allowed in the pattern")
+)
+
+// ValidateRegex verifies the regular expression of a filter is safe to
+// evaluate by the database: the RE2 syntax rules out the back-references
+// and the lookarounds, and the repetitions of unbounded repetitions,
+// which backtrack catastrophically, are rejected.
+func ValidateRegex(pattern string) error {
+	if len(pattern) > RegexMaxLength {
+		return ErrRegexTooLong
+	}
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return errors.Wrap(err, "invalid regular expression")
+	}
+	if hasNestedRepetition(re, false) {
+		return ErrRegexNestedRepetition
+	}
+	return nil
+}
+
+// isRepetition tells whether the expression repeats its subexpression
+// more than once; unbounded tells whether the number of the repetitions
+// is unbounded.
+func isRepetition(re *syntax.Regexp) (repeats, unbounded bool) {
+	switch re.Op {
+	case syntax.OpStar, syntax.OpPlus:
+		return true, true
+	case syntax.OpRepeat:
+		return re.Max == -1 || re.Max > 1, re.Max == -1
+	}
+	return false, false
+}
+
+func hasNestedRepetition(re *syntax.Regexp, repeated bool) bool {
+	repeats, unbounded := isRepetition(re)
+	if unbounded && repeated {
+		return true
+	}
+	for _, sub := range re.Sub {
+		if hasNestedRepetition(sub, repeated || repeats) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRegex(t *testing.T) {
+	testCases := map[string]struct {
+		pattern string
+		err     string
+	}{
+		"ok":                 {pattern: "^rpi[34]-.*$"},
+		"ok, bounded nested": {pattern: "^(ab?)+(x*y){1}$"},
+		"ok, sequence":       {pattern: "a+b+c*"},
+		"error, too long": {
+			pattern: strings.Repeat("a", RegexMaxLength+1),
+			err:     ErrRegexTooLong.Error(),
+		},
+		"error, syntax": {
+			pattern: "rpi[34",
+			err: "invalid regular expression: error parsing regexp: " +
+				"missing closing ]: `[34`",
+		},
+		"error, lookahead": {
+			pattern: "rpi(?=4)",
+			err: "invalid regular expression: error parsing regexp: " +
+				"invalid or unsupported Perl syntax: `(?=`",
+		},
+		"error, nested plus": {
+			pattern: "^(a+)+$",
+			err:     ErrRegexNestedRepetition.Error(),
+		},
+		"error, nested star": {
+			pattern: "(?:x*)*",
+			err:     ErrRegexNestedRepetition.Error(),
+		},
+		"error, counted repetition": {
+			pattern: "(.*a){20}",
+			err:     ErrRegexNestedRepetition.Error(),
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateRegex(tc.pattern)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestFilterPredicateRegex(t *testing.T) {
+	testCases := map[string]struct {
+		pred FilterPredicate
+
+		pattern    string
+		ignoreCase bool
+	}{
+		"regex": {
+			pred:    FilterPredicate{Type: SelectorRegex, Value: "^a.b"},
+			pattern: "^a.b",
+		},
+		"regex, ignore case": {
+			pred:       FilterPredicate{Type: SelectorIRegex, Value: "^a.b"},
+			pattern:    "^a.b",
+			ignoreCase: true,
+		},
+		"contains": {
+			pred:    FilterPredicate{Type: SelectorContains, Value: "a.b"},
+			pattern: `a\.b`,
+		},
+		"contains, ignore case": {
+			pred:       FilterPredicate{Type: SelectorIContains, Value: "(x)"},
+			pattern:    `\(x\)`,
+			ignoreCase: true,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.True(t, tc.pred.IsPattern())
+			pattern, ignoreCase := tc.pred.Regex()
+			assert.Equal(t, tc.pattern, pattern)
+			assert.Equal(t, tc.ignoreCase, ignoreCase)
+		})
+	}
+}
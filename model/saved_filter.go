@@ -0,0 +1,80 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"regexp"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pkg/errors"
+)
+
+// SavedFiltersPerUserMax is the maximum number of saved filters owned by
+// a user.
+const SavedFiltersPerUserMax = 100
+
+// validClientIDRegex matches the IDs the clients can choose for the saved
+// filters and the subscriptions they create with a PUT request; the IDs
+// are part of the resource URLs.
+var validClientIDRegex = regexp.MustCompile("^[A-Za-z0-9_.~-]{1,64}$")
+
+// ValidateClientID checks an ID chosen by a client.
+func ValidateClientID(id string) error {
+	return validation.Validate(id,
+		validation.Required,
+		validation.Match(validClientIDRegex).Error(
+			"must be at most 64 letters, digits or _.~- characters"),
+	)
+}
+
+// SavedFilter is a device search saved by a user. A private filter is
+// only visible to its owner; a shared one to all the users of the tenant.
+// Either way, only the owner can modify or remove it.
+type SavedFilter struct {
+	ID      string            `json:"id" bson:"_id"`
+	Name    string            `json:"name" bson:"name"`
+	OwnerID string            `json:"owner_id" bson:"owner_id"`
+	Shared  bool              `json:"shared" bson:"shared"`
+	Filters []FilterPredicate `json:"filters" bson:"filters"`
+	Sort    []SortCriteria    `json:"sort,omitempty" bson:"sort,omitempty"`
+
+	CreatedTs time.Time `json:"created_ts" bson:"created_ts"`
+	UpdatedTs time.Time `json:"updated_ts" bson:"updated_ts"`
+}
+
+func (f SavedFilter) Validate() error {
+	if len(f.Filters) == 0 {
+		return errors.New("at least one filter predicate is required")
+	}
+	for _, p := range f.Filters {
+		if err := p.Validate(); err != nil {
+			return errors.Wrap(err, "invalid filter")
+		}
+	}
+	for _, s := range f.Sort {
+		if err := s.Validate(); err != nil {
+			return errors.Wrap(err, "invalid sort")
+		}
+	}
+	return validation.ValidateStruct(&f,
+		validation.Field(&f.Name, validation.Required, validation.Length(1, 256)),
+	)
+}
+
+// VisibleTo returns true if the user can see the filter.
+func (f SavedFilter) VisibleTo(userID string) bool {
+	return f.Shared || f.OwnerID == userID
+}
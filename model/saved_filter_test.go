@@ -0,0 +1,66 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSavedFilterValidate(t *testing.T) {
+	filter := SavedFilter{
+		Name: "offline",
+		Filters: []FilterPredicate{{
+			Scope:     AttrScopeInventory,
+			Attribute: "status",
+			Type:      "$eq",
+			Value:     "offline",
+		}},
+		Sort: []SortCriteria{{
+			Scope:     AttrScopeInventory,
+			Attribute: "name",
+			Order:     "asc",
+		}},
+	}
+	assert.NoError(t, filter.Validate())
+
+	invalid := filter
+	invalid.Name = ""
+	assert.EqualError(t, invalid.Validate(), "name: cannot be blank.")
+
+	invalid = filter
+	invalid.Filters = nil
+	assert.EqualError(t, invalid.Validate(),
+		"at least one filter predicate is required")
+
+	invalid = filter
+	invalid.Sort = []SortCriteria{{
+		Scope:     AttrScopeInventory,
+		Attribute: "name",
+		Order:     "up",
+	}}
+	assert.EqualError(t, invalid.Validate(),
+		"invalid sort: order: must be a valid value.")
+}
+
+func TestSavedFilterVisibleTo(t *testing.T) {
+	filter := SavedFilter{OwnerID: "user"}
+	assert.True(t, filter.VisibleTo("user"))
+	assert.False(t, filter.VisibleTo("other"))
+
+	filter.Shared = true
+	assert.True(t, filter.VisibleTo("other"))
+}
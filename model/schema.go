@@ -0,0 +1,218 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pkg/errors"
+)
+
+const (
+	AttributeTypeString = "string"
+	AttributeTypeNumber = "number"
+	AttributeTypeArray  = "array"
+)
+
+const (
+	// SchemaModeEnforce rejects the writes of nonconforming values.
+	SchemaModeEnforce = "enforce"
+	// SchemaModeMonitor accepts the writes of nonconforming values, but
+	// records the violations in the validation report.
+	SchemaModeMonitor = "monitor"
+)
+
+// The normalizers of the string values of the attributes, applied in turn
+// at the ingestion, so that the values reported in different formats by
+// different clients match the same filters.
+const (
+	// NormalizerTrim removes the leading and trailing whitespace.
+	NormalizerTrim = "trim"
+	// NormalizerLowercase converts the letters to lower case.
+	NormalizerLowercase = "lowercase"
+	// NormalizerMAC formats the MAC addresses as lowercase hexadecimal
+	// digits separated by colons, e.g. 00-1A-2B-3C-4D-5E as
+	// 00:1a:2b:3c:4d:5e.
+	NormalizerMAC = "mac"
+	// NormalizerIP formats the IPv4 and IPv6 addresses, with or without
+	// prefix length, canonically, e.g. 2001:DB8:0:0::1/64 as 2001:db8::1/64.
+	NormalizerIP = "ip"
+)
+
+var normalizers = map[string]func(string) string{
+	NormalizerTrim:      strings.TrimSpace,
+	NormalizerLowercase: strings.ToLower,
+	NormalizerMAC:       normalizeMAC,
+	NormalizerIP:        normalizeIP,
+}
+
+// normalizeMAC formats the MAC address canonically; other values are
+// left unchanged.
+func normalizeMAC(value string) string {
+	mac, err := net.ParseMAC(value)
+	if err != nil {
+		return value
+	}
+	return mac.String()
+}
+
+// normalizeIP formats the IP address, or the address with prefix length,
+// canonically; other values are left unchanged.
+func normalizeIP(value string) string {
+	if addr, prefix := splitPrefix(value); prefix != "" {
+		if ip := net.ParseIP(addr); ip != nil {
+			return ip.String() + "/" + prefix
+		}
+	} else if ip := net.ParseIP(value); ip != nil {
+		return ip.String()
+	}
+	return value
+}
+
+func splitPrefix(value string) (string, string) {
+	if n := strings.LastIndexByte(value, '/'); n >= 0 {
+		return value[:n], value[n+1:]
+	}
+	return value, ""
+}
+
+// AttributeDefinition is the schema entry of a device attribute.
+type AttributeDefinition struct {
+	Scope string `json:"scope" bson:"scope"`
+	Name  string `json:"name" bson:"name"`
+	// TTLDays is the number of days after which the attribute is removed
+	// from the devices which did not update it; zero keeps it forever.
+	TTLDays int `json:"ttl_days,omitempty" bson:"ttl_days,omitempty"`
+	// Type is the type the values of the attribute must conform to;
+	// any value is accepted if empty.
+	Type string `json:"type,omitempty" bson:"type,omitempty"`
+	// Mode defines how nonconforming values are handled; defaults to
+	// SchemaModeEnforce.
+	Mode string `json:"mode,omitempty" bson:"mode,omitempty"`
+	// Required marks the attribute as expected to be reported by every
+	// device; it counts towards the inventory completeness.
+	Required bool `json:"required,omitempty" bson:"required,omitempty"`
+	// Normalizers are applied in turn to the string values of
+	// the attribute written after they are defined.
+	Normalizers []string `json:"normalizers,omitempty" bson:"normalizers,omitempty"`
+
+	UpdatedTs *time.Time `json:"updated_ts,omitempty" bson:"updated_ts,omitempty"`
+}
+
+func (d AttributeDefinition) Validate() error {
+	return validation.ValidateStruct(&d,
+		validation.Field(&d.Scope,
+			validation.Required,
+			validation.Length(1, 1024),
+			validation.NotIn(AttrScopeSystem).
+				Error("system attributes cannot be defined"),
+		),
+		validation.Field(&d.Name, validation.Required, validation.Length(1, 1024)),
+		validation.Field(&d.TTLDays, validation.Min(0)),
+		validation.Field(&d.Type, validation.In(
+			AttributeTypeString, AttributeTypeNumber, AttributeTypeArray,
+		)),
+		validation.Field(&d.Mode, validation.In(
+			SchemaModeEnforce, SchemaModeMonitor,
+		)),
+		validation.Field(&d.Normalizers, validation.Each(validation.In(
+			NormalizerTrim, NormalizerLowercase, NormalizerMAC, NormalizerIP,
+		))),
+	)
+}
+
+// Monitored returns true if the writes of nonconforming values are
+// accepted and only recorded.
+func (d AttributeDefinition) Monitored() bool {
+	return d.Mode == SchemaModeMonitor
+}
+
+// Normalize applies the normalizers to the value, or to the elements of
+// the array value; the other values are returned unchanged.
+func (d AttributeDefinition) Normalize(value interface{}) interface{} {
+	if len(d.Normalizers) == 0 {
+		return value
+	}
+	switch v := value.(type) {
+	case string:
+		return d.normalizeString(v)
+	case []string:
+		res := make([]string, len(v))
+		for n, elem := range v {
+			res[n] = d.normalizeString(elem)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for n, elem := range v {
+			if str, ok := elem.(string); ok {
+				res[n] = d.normalizeString(str)
+			} else {
+				res[n] = elem
+			}
+		}
+		return res
+	}
+	return value
+}
+
+func (d AttributeDefinition) normalizeString(value string) string {
+	for _, name := range d.Normalizers {
+		if normalize, ok := normalizers[name]; ok {
+			value = normalize(value)
+		}
+	}
+	return value
+}
+
+// Check verifies the value conforms to the type of the attribute.
+func (d AttributeDefinition) Check(value interface{}) error {
+	if d.Type == "" || value == nil {
+		return nil
+	}
+	var ok bool
+	switch value.(type) {
+	case string:
+		ok = d.Type == AttributeTypeString
+	case float64:
+		ok = d.Type == AttributeTypeNumber
+	default:
+		ok = d.Type == AttributeTypeArray &&
+			reflect.TypeOf(value).Kind() == reflect.Slice
+	}
+	if !ok {
+		return errors.Errorf("must be of type %s", d.Type)
+	}
+	return nil
+}
+
+// SchemaViolation is an entry of the validation report, recording the
+// latest nonconforming value of a device attribute.
+type SchemaViolation struct {
+	DeviceID DeviceID    `json:"device_id" bson:"device_id"`
+	Scope    string      `json:"scope" bson:"scope"`
+	Name     string      `json:"name" bson:"name"`
+	Value    interface{} `json:"value" bson:"value"`
+	Error    string      `json:"error" bson:"error"`
+	// Count is the number of nonconforming writes of the attribute.
+	Count int `json:"count" bson:"count"`
+
+	FirstTs time.Time `json:"first_ts" bson:"first_ts"`
+	LastTs  time.Time `json:"last_ts" bson:"last_ts"`
+}
@@ -0,0 +1,206 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttributeDefinitionValidate(t *testing.T) {
+	testCases := map[string]struct {
+		def AttributeDefinition
+		err string
+	}{
+		"ok": {
+			def: AttributeDefinition{
+				Scope:   AttrScopeInventory,
+				Name:    "last_gps_fix",
+				TTLDays: 30,
+			},
+		},
+		"ok, monitored type": {
+			def: AttributeDefinition{
+				Scope: AttrScopeInventory,
+				Name:  "cpu_count",
+				Type:  AttributeTypeNumber,
+				Mode:  SchemaModeMonitor,
+			},
+		},
+		"ok, no ttl": {
+			def: AttributeDefinition{Scope: "telemetry", Name: "rssi"},
+		},
+		"error, system scope": {
+			def: AttributeDefinition{Scope: AttrScopeSystem, Name: "group"},
+			err: "scope: system attributes cannot be defined.",
+		},
+		"error, no name": {
+			def: AttributeDefinition{Scope: AttrScopeInventory},
+			err: "name: cannot be blank.",
+		},
+		"error, ttl": {
+			def: AttributeDefinition{
+				Scope:   AttrScopeInventory,
+				Name:    "last_gps_fix",
+				TTLDays: -1,
+			},
+			err: "ttl_days: must be no less than 0.",
+		},
+		"ok, normalizers": {
+			def: AttributeDefinition{
+				Scope:       AttrScopeInventory,
+				Name:        "mac",
+				Normalizers: []string{NormalizerTrim, NormalizerMAC},
+			},
+		},
+		"error, normalizer": {
+			def: AttributeDefinition{
+				Scope:       AttrScopeInventory,
+				Name:        "mac",
+				Normalizers: []string{NormalizerTrim, "uppercase"},
+			},
+			err: "normalizers: (1: must be a valid value.).",
+		},
+		"error, type and mode": {
+			def: AttributeDefinition{
+				Scope: AttrScopeInventory,
+				Name:  "cpu_count",
+				Type:  "integer",
+				Mode:  "warn",
+			},
+			err: "mode: must be a valid value; type: must be a valid value.",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.def.Validate()
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestAttributeDefinitionCheck(t *testing.T) {
+	testCases := map[string]struct {
+		typ   string
+		value interface{}
+		err   string
+	}{
+		"ok, untyped":      {value: 1.0},
+		"ok, no value":     {typ: AttributeTypeNumber},
+		"ok, string":       {typ: AttributeTypeString, value: "foo"},
+		"ok, number":       {typ: AttributeTypeNumber, value: 4.0},
+		"ok, array":        {typ: AttributeTypeArray, value: []interface{}{"foo"}},
+		"ok, string array": {typ: AttributeTypeArray, value: []string{"foo"}},
+		"error, string": {
+			typ:   AttributeTypeNumber,
+			value: "4",
+			err:   "must be of type number",
+		},
+		"error, number": {
+			typ:   AttributeTypeArray,
+			value: 4.0,
+			err:   "must be of type array",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := AttributeDefinition{Type: tc.typ}.Check(tc.value)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestAttributeDefinitionNormalize(t *testing.T) {
+	testCases := map[string]struct {
+		normalizers []string
+		value       interface{}
+		res         interface{}
+	}{
+		"no normalizers": {
+			value: " Foo ",
+			res:   " Foo ",
+		},
+		"trim and lowercase": {
+			normalizers: []string{NormalizerTrim, NormalizerLowercase},
+			value:       " Foo\t",
+			res:         "foo",
+		},
+		"mac": {
+			normalizers: []string{NormalizerMAC},
+			value:       "00-1A-2B-3C-4D-5E",
+			res:         "00:1a:2b:3c:4d:5e",
+		},
+		"mac, dotted": {
+			normalizers: []string{NormalizerMAC},
+			value:       "001A.2B3C.4D5E",
+			res:         "00:1a:2b:3c:4d:5e",
+		},
+		"mac, invalid": {
+			normalizers: []string{NormalizerMAC},
+			value:       "not a mac",
+			res:         "not a mac",
+		},
+		"ip": {
+			normalizers: []string{NormalizerIP},
+			value:       "2001:DB8:0:0:0:0:0:1",
+			res:         "2001:db8::1",
+		},
+		"ip, prefix length": {
+			normalizers: []string{NormalizerIP},
+			value:       "2001:DB8::0001/64",
+			res:         "2001:db8::1/64",
+		},
+		"ip, v4": {
+			normalizers: []string{NormalizerIP},
+			value:       "192.168.1.10/24",
+			res:         "192.168.1.10/24",
+		},
+		"ip, invalid": {
+			normalizers: []string{NormalizerIP},
+			value:       "fe80::zz/64",
+			res:         "fe80::zz/64",
+		},
+		"array": {
+			normalizers: []string{NormalizerLowercase},
+			value:       []interface{}{"A", 1.0},
+			res:         []interface{}{"a", 1.0},
+		},
+		"string array": {
+			normalizers: []string{NormalizerLowercase},
+			value:       []string{"A", "B"},
+			res:         []string{"a", "b"},
+		},
+		"number": {
+			normalizers: []string{NormalizerLowercase},
+			value:       4.0,
+			res:         4.0,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			def := AttributeDefinition{Normalizers: tc.normalizers}
+			assert.Equal(t, tc.res, def.Normalize(tc.value))
+		})
+	}
+}
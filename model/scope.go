@@ -0,0 +1,99 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"regexp"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+var validScopeNameRegex = regexp.MustCompile("^[a-z0-9_]+$")
+
+var scopeWriters = []interface{}{
+	SourceTypeDevice,
+	SourceTypeUser,
+	SourceTypeInternal,
+}
+
+// BuiltinScopes are the attribute scopes managed by the service itself;
+// they cannot be registered, replaced or removed by tenants. The telemetry
+// scope holds the attributes computed from the writes of the devices.
+var BuiltinScopes = []Scope{
+	{Name: AttrScopeInventory, Writer: SourceTypeDevice, Builtin: true},
+	{Name: AttrScopeIdentity, Writer: SourceTypeInternal, Builtin: true},
+	{Name: AttrScopeSystem, Writer: SourceTypeInternal, Builtin: true},
+	{Name: AttrScopeTags, Writer: SourceTypeUser, Builtin: true},
+	{Name: AttrScopeTelemetry, Writer: SourceTypeInternal, Builtin: true},
+}
+
+// Scope is an attribute scope together with its write and retention policy.
+type Scope struct {
+	Name string `json:"name" bson:"_id"`
+	// Writer is the only type of principal, besides internal services,
+	// allowed to write the attributes of the scope.
+	Writer string `json:"writer" bson:"writer"`
+	// RetentionDays is the number of days after which attributes of the
+	// scope which were not updated are removed; zero keeps them forever.
+	RetentionDays int `json:"retention_days,omitempty" bson:"retention_days,omitempty"`
+	// Cold keeps the attributes of the scope apart from the devices; they
+	// are not returned unless explicitly requested.
+	Cold bool `json:"cold,omitempty" bson:"cold,omitempty"`
+
+	Builtin bool `json:"builtin,omitempty" bson:"-"`
+
+	UpdatedTs *time.Time `json:"updated_ts,omitempty" bson:"updated_ts,omitempty"`
+}
+
+func (s Scope) Validate() error {
+	return validation.ValidateStruct(&s,
+		validation.Field(&s.Name,
+			validation.Required,
+			validation.Length(1, 64),
+			validation.Match(validScopeNameRegex),
+			validation.By(func(interface{}) error {
+				if _, ok := GetBuiltinScope(s.Name); ok {
+					return validation.NewError(
+						"validation_scope_builtin",
+						"must not be a builtin scope",
+					)
+				}
+				return nil
+			}),
+		),
+		validation.Field(&s.Writer,
+			validation.Required,
+			validation.In(scopeWriters...),
+		),
+		validation.Field(&s.RetentionDays, validation.Min(0)),
+	)
+}
+
+// AllowsWriter returns true if the principal of the given type may write
+// attributes of the scope.
+func (s Scope) AllowsWriter(sourceType string) bool {
+	return sourceType == SourceTypeInternal || sourceType == s.Writer
+}
+
+// GetBuiltinScope returns the builtin scope with the given name.
+func GetBuiltinScope(name string) (Scope, bool) {
+	for _, s := range BuiltinScopes {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Scope{}, false
+}
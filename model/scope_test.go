@@ -0,0 +1,84 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScopeValidate(t *testing.T) {
+	testCases := map[string]struct {
+		scope Scope
+		err   string
+	}{
+		"ok": {
+			scope: Scope{Name: "warranty", Writer: SourceTypeUser},
+		},
+		"ok, retention": {
+			scope: Scope{
+				Name:          "warranty",
+				Writer:        SourceTypeDevice,
+				RetentionDays: 30,
+			},
+		},
+		"error, builtin": {
+			scope: Scope{Name: AttrScopeIdentity, Writer: SourceTypeDevice},
+			err:   "name: must not be a builtin scope.",
+		},
+		"error, telemetry": {
+			scope: Scope{Name: AttrScopeTelemetry, Writer: SourceTypeDevice},
+			err:   "name: must not be a builtin scope.",
+		},
+		"error, name": {
+			scope: Scope{Name: "Warranty-2", Writer: SourceTypeUser},
+			err:   "name: must be in a valid format.",
+		},
+		"error, writer": {
+			scope: Scope{Name: "warranty"},
+			err:   "writer: cannot be blank.",
+		},
+		"error, retention": {
+			scope: Scope{
+				Name:          "warranty",
+				Writer:        SourceTypeUser,
+				RetentionDays: -1,
+			},
+			err: "retention_days: must be no less than 0.",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.scope.Validate()
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestScopeAllowsWriter(t *testing.T) {
+	scope, ok := GetBuiltinScope(AttrScopeInventory)
+	assert.True(t, ok)
+	assert.True(t, scope.AllowsWriter(SourceTypeDevice))
+	assert.True(t, scope.AllowsWriter(SourceTypeInternal))
+	assert.False(t, scope.AllowsWriter(SourceTypeUser))
+
+	_, ok = GetBuiltinScope("warranty")
+	assert.False(t, ok)
+}
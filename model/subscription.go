@@ -0,0 +1,120 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"net/mail"
+	"net/url"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pkg/errors"
+)
+
+const (
+	NotificationChannelWebhook = "webhook"
+	NotificationChannelEmail   = "email"
+)
+
+// SubscriptionsPerUserMax is the maximum number of subscriptions of a user.
+const SubscriptionsPerUserMax = 100
+
+// NotificationChannel is where the notifications of a subscription are
+// delivered: a webhook URL or an email address.
+type NotificationChannel struct {
+	Type  string `json:"type" bson:"type"`
+	URL   string `json:"url,omitempty" bson:"url,omitempty"`
+	Email string `json:"email,omitempty" bson:"email,omitempty"`
+}
+
+func (c NotificationChannel) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.Type,
+			validation.Required,
+			validation.In(NotificationChannelWebhook, NotificationChannelEmail),
+		),
+		validation.Field(&c.URL,
+			validation.When(c.Type == NotificationChannelWebhook,
+				validation.Required, validation.By(validateWebhookURL),
+			).Else(validation.Empty),
+		),
+		validation.Field(&c.Email,
+			validation.When(c.Type == NotificationChannelEmail,
+				validation.Required, validation.By(validateEmail),
+			).Else(validation.Empty),
+		),
+	)
+}
+
+func validateWebhookURL(value interface{}) error {
+	u, err := url.Parse(value.(string))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("must be a valid http(s) URL")
+	}
+	return nil
+}
+
+func validateEmail(value interface{}) error {
+	addr, err := mail.ParseAddress(value.(string))
+	if err != nil || addr.Address != value.(string) {
+		return errors.New("must be a valid email address")
+	}
+	return nil
+}
+
+// Subscription notifies a user about the changes of a device or about
+// the devices starting to match a filter.
+//
+// A subscription to a device without filters notifies about every change
+// of the device; with filters, it notifies when the device, or any device
+// if none is given, starts matching the filters, e.g. when it comes back
+// online.
+type Subscription struct {
+	ID       string            `json:"id" bson:"_id"`
+	UserID   string            `json:"-" bson:"user_id"`
+	DeviceID DeviceID          `json:"device_id,omitempty" bson:"device_id,omitempty"`
+	Filters  []FilterPredicate `json:"filters,omitempty" bson:"filters,omitempty"`
+
+	Channel NotificationChannel `json:"channel" bson:"channel"`
+
+	CreatedTs time.Time `json:"created_ts" bson:"created_ts"`
+}
+
+func (s Subscription) Validate() error {
+	if s.DeviceID == "" && len(s.Filters) == 0 {
+		return errors.New("a device or filters are required")
+	}
+	for _, f := range s.Filters {
+		if err := f.Validate(); err != nil {
+			return errors.Wrap(err, "invalid filter")
+		}
+	}
+	return validation.ValidateStruct(&s,
+		validation.Field(&s.Channel, validation.Required),
+	)
+}
+
+// OnChange returns true if the subscription notifies about every change
+// of the device, rather than about the device starting to match.
+func (s Subscription) OnChange() bool {
+	return len(s.Filters) == 0
+}
+
+// Notification is the payload of the events delivered to subscribers.
+type Notification struct {
+	SubscriptionID string   `json:"subscription_id"`
+	DeviceID       DeviceID `json:"device_id"`
+	Device         *Device  `json:"device,omitempty"`
+}
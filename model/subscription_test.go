@@ -0,0 +1,119 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscriptionValidate(t *testing.T) {
+	webhook := NotificationChannel{
+		Type: NotificationChannelWebhook,
+		URL:  "https://hooks.example.com/inventory",
+	}
+	testCases := map[string]struct {
+		sub Subscription
+		err string
+	}{
+		"ok, device": {
+			sub: Subscription{DeviceID: "1", Channel: webhook},
+		},
+		"ok, filters and email": {
+			sub: Subscription{
+				Filters: []FilterPredicate{{
+					Scope:     AttrScopeInventory,
+					Attribute: "status",
+					Type:      "$eq",
+					Value:     "online",
+				}},
+				Channel: NotificationChannel{
+					Type:  NotificationChannelEmail,
+					Email: "user@example.com",
+				},
+			},
+		},
+		"error, no device nor filters": {
+			sub: Subscription{Channel: webhook},
+			err: "a device or filters are required",
+		},
+		"error, filter": {
+			sub: Subscription{
+				Filters: []FilterPredicate{{
+					Scope:     AttrScopeInventory,
+					Attribute: "status",
+					Type:      "$gt",
+					Value:     "on",
+				}},
+				Channel: webhook,
+			},
+			err: "invalid filter: type: must be a valid value.",
+		},
+		"error, no channel": {
+			sub: Subscription{DeviceID: "1"},
+			err: "channel: (type: cannot be blank.).",
+		},
+		"error, webhook URL": {
+			sub: Subscription{
+				DeviceID: "1",
+				Channel: NotificationChannel{
+					Type: NotificationChannelWebhook,
+					URL:  "ftp://example.com",
+				},
+			},
+			err: "channel: (url: must be a valid http(s) URL.).",
+		},
+		"error, email": {
+			sub: Subscription{
+				DeviceID: "1",
+				Channel: NotificationChannel{
+					Type:  NotificationChannelEmail,
+					Email: "User <user@example.com>",
+				},
+			},
+			err: "channel: (email: must be a valid email address.).",
+		},
+		"error, url with email channel": {
+			sub: Subscription{
+				DeviceID: "1",
+				Channel: NotificationChannel{
+					Type:  NotificationChannelEmail,
+					Email: "user@example.com",
+					URL:   "https://hooks.example.com",
+				},
+			},
+			err: "channel: (url: must be blank.).",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.sub.Validate()
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSubscriptionOnChange(t *testing.T) {
+	assert.True(t, Subscription{DeviceID: "1"}.OnChange())
+	assert.False(t, Subscription{
+		DeviceID: "1",
+		Filters:  []FilterPredicate{{Attribute: "status"}},
+	}.OnChange())
+}
@@ -0,0 +1,154 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"sort"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pkg/errors"
+)
+
+// TagsImportMaxRows is the maximum number of rows of a tags import.
+const TagsImportMaxRows = 10000
+
+// TagsImportRow sets the tag of the device identified by the value of
+// an identity attribute.
+type TagsImportRow struct {
+	// Row is the number of the row in the imported file, from 1
+	Row      int
+	Identity string
+	Name     string
+	Value    string
+}
+
+func (r TagsImportRow) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Identity, validation.Required),
+		validation.Field(&r.Name, validation.Required, validation.Length(1, 1024)),
+		validation.Field(&r.Value, validation.Length(0, 1024)),
+	)
+}
+
+// TagsImportError reports a row of a tags import which was not applied.
+type TagsImportError struct {
+	Row      int    `json:"row"`
+	Identity string `json:"identity,omitempty"`
+	Error    string `json:"error"`
+}
+
+// TagsImportResult is the report of a tags import.
+type TagsImportResult struct {
+	// Rows is the number of rows read
+	Rows int `json:"rows"`
+	// Tagged is the number of rows applied
+	Tagged int `json:"tagged"`
+	// Errors reports the rows which were not applied, by row number
+	Errors []TagsImportError `json:"errors"`
+}
+
+// TagsAssignmentMaxTags is the maximum number of tags set and removed by
+// a bulk tags assignment.
+const TagsAssignmentMaxTags = 100
+
+// TagsAssignment sets and removes tags on the devices given as a list of
+// device IDs, as filter predicates or both; with both, the devices must
+// match the filters too.
+type TagsAssignment struct {
+	DeviceIDs []DeviceID        `json:"device_ids"`
+	Filters   []FilterPredicate `json:"filters"`
+	// Set maps the names of the tags to set to their values.
+	Set map[string]string `json:"set"`
+	// Unset lists the names of the tags to remove.
+	Unset []string `json:"unset"`
+}
+
+func (a TagsAssignment) Validate() error {
+	if len(a.DeviceIDs) == 0 && len(a.Filters) == 0 {
+		return errors.New("either device IDs or filters must be provided")
+	}
+	if len(a.Set) == 0 && len(a.Unset) == 0 {
+		return errors.New("either tags to set or to unset must be provided")
+	}
+	if len(a.Set)+len(a.Unset) > TagsAssignmentMaxTags {
+		return errors.Errorf(
+			"too many tags: at most %d tags can be set or unset at once",
+			TagsAssignmentMaxTags)
+	}
+	for name, value := range a.Set {
+		if err := validation.Validate(name,
+			validation.Required, validation.Length(1, 1024),
+		); err != nil {
+			return errors.Wrap(err, "set: tag name")
+		}
+		if err := validation.Validate(value, validation.Length(0, 1024)); err != nil {
+			return errors.Wrapf(err, "set: tag %s", name)
+		}
+	}
+	for _, name := range a.Unset {
+		if err := validation.Validate(name,
+			validation.Required, validation.Length(1, 1024),
+		); err != nil {
+			return errors.Wrap(err, "unset: tag name")
+		}
+		if _, ok := a.Set[name]; ok {
+			return errors.Errorf("tag %s cannot be both set and unset", name)
+		}
+	}
+	return SearchParams{Filters: a.Filters}.Validate()
+}
+
+// SearchParams returns the search matching the selected devices.
+func (a TagsAssignment) SearchParams() SearchParams {
+	params := SearchParams{Filters: a.Filters}
+	for _, id := range a.DeviceIDs {
+		params.DeviceIDs = append(params.DeviceIDs, string(id))
+	}
+	return params
+}
+
+// Attributes returns the tags to set and to remove, ordered by name.
+func (a TagsAssignment) Attributes() (set, unset DeviceAttributes) {
+	for name, value := range a.Set {
+		set = append(set, DeviceAttribute{
+			Scope: AttrScopeTags,
+			Name:  name,
+			Value: value,
+		})
+	}
+	sort.Slice(set, func(i, j int) bool { return set[i].Name < set[j].Name })
+	for _, name := range a.Unset {
+		unset = append(unset, DeviceAttribute{
+			Scope: AttrScopeTags,
+			Name:  name,
+		})
+	}
+	sort.Slice(unset, func(i, j int) bool { return unset[i].Name < unset[j].Name })
+	return set, unset
+}
+
+// TagsAssignmentError reports a device the tags were not assigned to.
+type TagsAssignmentError struct {
+	DeviceID DeviceID `json:"device_id"`
+	Error    string   `json:"error"`
+}
+
+// TagsAssignmentResult is the report of a bulk tags assignment.
+type TagsAssignmentResult struct {
+	// Tagged is the number of devices updated.
+	Tagged int `json:"tagged"`
+	// Errors reports the listed devices which were not updated.
+	Errors []TagsAssignmentError `json:"errors"`
+}
@@ -0,0 +1,115 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTagsAssignmentValidate(t *testing.T) {
+	filters := []FilterPredicate{{
+		Scope:     AttrScopeInventory,
+		Attribute: "device_type",
+		Type:      "$eq",
+		Value:     "raspberrypi4",
+	}}
+	set := map[string]string{"location": "oslo"}
+	testCases := map[string]struct {
+		assignment TagsAssignment
+		err        string
+	}{
+		"ok, device IDs": {
+			assignment: TagsAssignment{
+				DeviceIDs: []DeviceID{"1", "2"},
+				Set:       set,
+			},
+		},
+		"ok, filters": {
+			assignment: TagsAssignment{
+				Filters: filters,
+				Unset:   []string{"owner"},
+			},
+		},
+		"error, no devices": {
+			assignment: TagsAssignment{Set: set},
+			err:        "either device IDs or filters must be provided",
+		},
+		"error, no tags": {
+			assignment: TagsAssignment{Filters: filters},
+			err:        "either tags to set or to unset must be provided",
+		},
+		"error, tag name": {
+			assignment: TagsAssignment{
+				Filters: filters,
+				Set:     map[string]string{"": "oslo"},
+			},
+			err: "set: tag name: cannot be blank",
+		},
+		"error, tag value": {
+			assignment: TagsAssignment{
+				Filters: filters,
+				Set:     map[string]string{"location": strings.Repeat("a", 1025)},
+			},
+			err: "set: tag location: the length must be no more than 1024",
+		},
+		"error, set and unset": {
+			assignment: TagsAssignment{
+				Filters: filters,
+				Set:     set,
+				Unset:   []string{"location"},
+			},
+			err: "tag location cannot be both set and unset",
+		},
+		"error, filter": {
+			assignment: TagsAssignment{
+				Filters: []FilterPredicate{{
+					Scope:     AttrScopeInventory,
+					Attribute: "device_type",
+					Type:      "$gt",
+					Value:     "raspberry",
+				}},
+				Set: set,
+			},
+			err: "type: must be a valid value.",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.assignment.Validate()
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestTagsAssignmentAttributes(t *testing.T) {
+	set, unset := TagsAssignment{
+		Set:   map[string]string{"room": "101", "location": "oslo"},
+		Unset: []string{"owner", "floor"},
+	}.Attributes()
+	assert.Equal(t, DeviceAttributes{
+		{Scope: AttrScopeTags, Name: "location", Value: "oslo"},
+		{Scope: AttrScopeTags, Name: "room", Value: "101"},
+	}, set)
+	assert.Equal(t, DeviceAttributes{
+		{Scope: AttrScopeTags, Name: "floor"},
+		{Scope: AttrScopeTags, Name: "owner"},
+	}, unset)
+}
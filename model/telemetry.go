@@ -0,0 +1,64 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"time"
+)
+
+const (
+	// AttrScopeTelemetry is the scope of the computed attributes
+	// describing how often the devices write their attributes; they are
+	// only used by the search filters.
+	AttrScopeTelemetry = "telemetry"
+
+	AttrNameUpdates       = "updates"
+	AttrNameUpdatesPerDay = "updates_per_day"
+	AttrNameLastUpdate    = "last_update_ts"
+
+	// DeviceTelemetryIntervals is the number of intervals between the
+	// latest updates kept per device.
+	DeviceTelemetryIntervals = 10
+)
+
+// the computed attributes of the telemetry scope are numbers and times,
+// compared rather than matched
+var validTelemetrySelectors = []interface{}{
+	"$eq",
+	"$gt",
+	"$gte",
+	"$lt",
+	"$lte",
+}
+
+var validTelemetryAttributes = []interface{}{
+	AttrNameUpdates,
+	AttrNameUpdatesPerDay,
+	AttrNameLastUpdate,
+}
+
+// DeviceTelemetry describes the write frequency of a device; it is
+// updated on each write of its attributes.
+type DeviceTelemetry struct {
+	// Updates is the number of writes recorded since the device was
+	// created, or since the telemetry was enabled.
+	Updates int64 `json:"updates" bson:"updates"`
+	// LastUpdateTs is the time of the latest write.
+	LastUpdateTs *time.Time `json:"last_update_ts,omitempty" bson:"last_update_ts,omitempty"`
+	// Intervals are the durations, in seconds, between the latest
+	// writes, oldest first.
+	Intervals []float64 `json:"intervals" bson:"intervals"`
+	// UpdatesPerDay is the rate of the writes over the intervals.
+	UpdatesPerDay float64 `json:"updates_per_day" bson:"updates_per_day"`
+}
@@ -0,0 +1,27 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+// TenantDatabase describes the database of a tenant.
+type TenantDatabase struct {
+	// TenantID is empty for the database of the single-tenant setups.
+	TenantID string `json:"tenant_id"`
+	Database string `json:"database"`
+	Devices  int64  `json:"devices"`
+	// Version is the schema version of the database; empty if it was
+	// never migrated.
+	Version string `json:"version"`
+	// SizeOnDisk is the storage size of the database, in bytes.
+	SizeOnDisk int64 `json:"size_on_disk"`
+}
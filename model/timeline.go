@@ -0,0 +1,75 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// Types of the events of the device timeline.
+const (
+	TimelineDeploymentStarted  = "deployment_started"
+	TimelineDeploymentFinished = "deployment_finished"
+	TimelineAuthStatusChanged  = "auth_status_changed"
+	TimelineTerminalSession    = "terminal_session"
+)
+
+// TimelineEventsBatchMax is the maximum number of timeline events
+// ingested at once.
+const TimelineEventsBatchMax = 1000
+
+var timelineEventTypes = []interface{}{
+	TimelineDeploymentStarted,
+	TimelineDeploymentFinished,
+	TimelineAuthStatusChanged,
+	TimelineTerminalSession,
+}
+
+// TimelineEvent is an event of the device, reported by another service,
+// in the device timeline.
+type TimelineEvent struct {
+	// DedupKey identifies the event; the events with the key of an event
+	// already in the timeline are skipped, so that the batches can be
+	// retried safely.
+	DedupKey string   `json:"dedup_key" bson:"_id"`
+	DeviceID DeviceID `json:"device_id" bson:"device_id"`
+	Type     string   `json:"type" bson:"type"`
+	// Source is the service which reported the event.
+	Source    string                 `json:"source" bson:"source"`
+	Timestamp time.Time              `json:"timestamp" bson:"timestamp"`
+	Data      map[string]interface{} `json:"data,omitempty" bson:"data,omitempty"`
+}
+
+func (e TimelineEvent) Validate() error {
+	return validation.ValidateStruct(&e,
+		validation.Field(&e.DedupKey,
+			validation.Required, validation.Length(1, 1024)),
+		validation.Field(&e.DeviceID, validation.Required),
+		validation.Field(&e.Type,
+			validation.Required, validation.In(timelineEventTypes...)),
+		validation.Field(&e.Source,
+			validation.Required, validation.Length(1, 64)),
+		validation.Field(&e.Timestamp, validation.Required),
+	)
+}
+
+// TimelineIngestResult counts the events added to the timeline and
+// the ones skipped as duplicates.
+type TimelineIngestResult struct {
+	Accepted   int `json:"accepted"`
+	Duplicates int `json:"duplicates"`
+}
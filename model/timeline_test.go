@@ -0,0 +1,45 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimelineEventValidate(t *testing.T) {
+	event := TimelineEvent{
+		DedupKey:  "deployments:1:2",
+		DeviceID:  "1",
+		Type:      TimelineDeploymentStarted,
+		Source:    "deployments",
+		Timestamp: time.Now(),
+	}
+	assert.NoError(t, event.Validate())
+
+	invalid := event
+	invalid.DedupKey = ""
+	assert.EqualError(t, invalid.Validate(), "dedup_key: cannot be blank.")
+
+	invalid = event
+	invalid.Type = "rebooted"
+	assert.EqualError(t, invalid.Validate(), "type: must be a valid value.")
+
+	invalid = event
+	invalid.Timestamp = time.Time{}
+	assert.EqualError(t, invalid.Validate(), "timestamp: cannot be blank.")
+}
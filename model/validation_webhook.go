@@ -0,0 +1,77 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+const (
+	// ValidationFailOpen accepts the writes when the validation webhook
+	// cannot be reached or does not respond in time.
+	ValidationFailOpen = "open"
+	// ValidationFailClosed rejects the writes when the validation webhook
+	// cannot be reached or does not respond in time.
+	ValidationFailClosed = "closed"
+
+	ValidationTimeoutDefault = time.Second
+	ValidationTimeoutMaxMS   = 5000
+)
+
+// ValidationWebhook is the endpoint of a tenant validating the attributes
+// written by the users before they are accepted.
+type ValidationWebhook struct {
+	URL string `json:"url" bson:"url"`
+	// TimeoutMS is how long the webhook has to respond, in milliseconds;
+	// ValidationTimeoutDefault applies if zero.
+	TimeoutMS int `json:"timeout_ms,omitempty" bson:"timeout_ms,omitempty"`
+	// FailurePolicy decides the fate of the writes when the webhook
+	// fails: ValidationFailOpen or ValidationFailClosed.
+	FailurePolicy string `json:"failure_policy" bson:"failure_policy"`
+}
+
+func (w ValidationWebhook) Validate() error {
+	return validation.ValidateStruct(&w,
+		validation.Field(&w.URL,
+			validation.Required, validation.By(validateWebhookURL)),
+		validation.Field(&w.TimeoutMS,
+			validation.Min(0), validation.Max(ValidationTimeoutMaxMS)),
+		validation.Field(&w.FailurePolicy,
+			validation.Required,
+			validation.In(ValidationFailOpen, ValidationFailClosed)),
+	)
+}
+
+func (w ValidationWebhook) Timeout() time.Duration {
+	if w.TimeoutMS <= 0 {
+		return ValidationTimeoutDefault
+	}
+	return time.Duration(w.TimeoutMS) * time.Millisecond
+}
+
+// AttributesValidationRequest is posted to the validation webhook.
+type AttributesValidationRequest struct {
+	DeviceID   DeviceID         `json:"device_id"`
+	UserID     string           `json:"user_id"`
+	Attributes DeviceAttributes `json:"attributes"`
+}
+
+// AttributesValidationResponse is the verdict of the validation webhook.
+type AttributesValidationResponse struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason,omitempty"`
+}
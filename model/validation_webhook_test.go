@@ -0,0 +1,78 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidationWebhookValidate(t *testing.T) {
+	testCases := map[string]struct {
+		hook ValidationWebhook
+		err  string
+	}{
+		"ok": {
+			hook: ValidationWebhook{
+				URL:           "https://hooks.example.com/validate",
+				TimeoutMS:     500,
+				FailurePolicy: ValidationFailClosed,
+			},
+		},
+		"error, no URL": {
+			hook: ValidationWebhook{FailurePolicy: ValidationFailOpen},
+			err:  "url: cannot be blank.",
+		},
+		"error, URL": {
+			hook: ValidationWebhook{
+				URL:           "ftp://example.com",
+				FailurePolicy: ValidationFailOpen,
+			},
+			err: "url: must be a valid http(s) URL.",
+		},
+		"error, timeout": {
+			hook: ValidationWebhook{
+				URL:           "https://hooks.example.com/validate",
+				TimeoutMS:     ValidationTimeoutMaxMS + 1,
+				FailurePolicy: ValidationFailOpen,
+			},
+			err: "timeout_ms: must be no greater than 5000.",
+		},
+		"error, policy": {
+			hook: ValidationWebhook{
+				URL: "https://hooks.example.com/validate",
+			},
+			err: "failure_policy: cannot be blank.",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.hook.Validate()
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidationWebhookTimeout(t *testing.T) {
+	assert.Equal(t, ValidationTimeoutDefault, ValidationWebhook{}.Timeout())
+	assert.Equal(t, 250*time.Millisecond,
+		ValidationWebhook{TimeoutMS: 250}.Timeout())
+}
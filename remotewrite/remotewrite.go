@@ -0,0 +1,174 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+// Package remotewrite pushes samples to an endpoint implementing
+// the Prometheus remote-write protocol.
+//
+// The WriteRequest protobuf message is encoded by hand: the protocol uses
+// a handful of simple messages, which does not justify generated code.
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/pkg/errors"
+)
+
+const (
+	// LabelName is the label holding the name of the metric.
+	LabelName = "__name__"
+
+	protocolVersion = "0.1.0"
+	defaultTimeout  = 10 * time.Second
+)
+
+// Label is a name-value pair identifying a time series.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a value of a time series, identified by its labels, at
+// the given time.
+type Sample struct {
+	Labels    []Label
+	Value     float64
+	Timestamp time.Time
+}
+
+//go:generate ../utils/mockgen.sh
+type Writer interface {
+	Write(ctx context.Context, samples ...Sample) error
+}
+
+// Client delivers the samples to the remote-write endpoint.
+type Client struct {
+	url    string
+	client *http.Client
+}
+
+func NewClient(url string) *Client {
+	return &Client{
+		url:    url,
+		client: &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+func (c *Client) Write(ctx context.Context, samples ...Sample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+	body := snappy.Encode(nil, encodeWriteRequest(samples))
+	req, err := http.NewRequestWithContext(ctx,
+		http.MethodPost, c.url, bytes.NewReader(body),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to prepare the remote-write request")
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", protocolVersion)
+	rsp, err := c.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to write samples")
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode >= 300 {
+		return errors.Errorf(
+			"failed to write samples: remote-write endpoint responded with %s",
+			rsp.Status,
+		)
+	}
+	return nil
+}
+
+// Protobuf wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+type protoBuffer struct {
+	bytes.Buffer
+}
+
+func (b *protoBuffer) tag(field int, wireType int) {
+	b.uvarint(uint64(field<<3 | wireType))
+}
+
+func (b *protoBuffer) uvarint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	b.Write(buf[:binary.PutUvarint(buf[:], v)])
+}
+
+func (b *protoBuffer) bytesField(field int, data []byte) {
+	b.tag(field, wireBytes)
+	b.uvarint(uint64(len(data)))
+	b.Write(data)
+}
+
+func (b *protoBuffer) stringField(field int, s string) {
+	b.tag(field, wireBytes)
+	b.uvarint(uint64(len(s)))
+	b.WriteString(s)
+}
+
+func (b *protoBuffer) doubleField(field int, v float64) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v))
+	b.tag(field, wireFixed64)
+	b.Write(buf[:])
+}
+
+func (b *protoBuffer) int64Field(field int, v int64) {
+	b.tag(field, wireVarint)
+	b.uvarint(uint64(v))
+}
+
+// encodeWriteRequest encodes the samples as a WriteRequest message, with
+// a time series per sample; the labels of a time series are sorted by
+// name, as required by the protocol.
+func encodeWriteRequest(samples []Sample) []byte {
+	var req protoBuffer
+	for _, s := range samples {
+		labels := make([]Label, len(s.Labels))
+		copy(labels, s.Labels)
+		sort.Slice(labels, func(i, j int) bool {
+			return labels[i].Name < labels[j].Name
+		})
+
+		var series protoBuffer
+		for _, l := range labels {
+			var label protoBuffer
+			label.stringField(1, l.Name)
+			label.stringField(2, l.Value)
+			series.bytesField(1, label.Bytes())
+		}
+		var sample protoBuffer
+		sample.doubleField(1, s.Value)
+		sample.int64Field(2, s.Timestamp.UnixNano()/int64(time.Millisecond))
+		series.bytesField(2, sample.Bytes())
+
+		req.bytesField(1, series.Bytes())
+	}
+	return req.Bytes()
+}
@@ -0,0 +1,134 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package remotewrite
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+)
+
+var testSample = Sample{
+	Labels: []Label{
+		{Name: "device_id", Value: "1"},
+		{Name: LabelName, Value: "m"},
+	},
+	Value:     1.5,
+	Timestamp: time.Unix(1, 0),
+}
+
+func concat(parts ...[]byte) []byte {
+	var res []byte
+	for _, p := range parts {
+		res = append(res, p...)
+	}
+	return res
+}
+
+func TestEncodeWriteRequest(t *testing.T) {
+	nameLabel := concat(
+		[]byte{0x0a, 0x08}, []byte("__name__"),
+		[]byte{0x12, 0x01}, []byte("m"),
+	)
+	deviceLabel := concat(
+		[]byte{0x0a, 0x09}, []byte("device_id"),
+		[]byte{0x12, 0x01}, []byte("1"),
+	)
+	sample := []byte{
+		0x09, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf8, 0x3f,
+		0x10, 0xe8, 0x07,
+	}
+	// the labels are sorted by name
+	series := concat(
+		[]byte{0x0a, byte(len(nameLabel))}, nameLabel,
+		[]byte{0x0a, byte(len(deviceLabel))}, deviceLabel,
+		[]byte{0x12, byte(len(sample))}, sample,
+	)
+	expected := concat([]byte{0x0a, byte(len(series))}, series)
+
+	assert.Equal(t, expected, encodeWriteRequest([]Sample{testSample}))
+	assert.Equal(t, concat(expected, expected),
+		encodeWriteRequest([]Sample{testSample, testSample}))
+	assert.Equal(t, "device_id", testSample.Labels[0].Name)
+}
+
+func TestClient(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		samples []Sample
+		status  int
+
+		calls int
+		err   string
+	}{
+		"ok": {
+			samples: []Sample{testSample},
+			status:  http.StatusNoContent,
+			calls:   1,
+		},
+		"ok, no samples": {
+			status: http.StatusInternalServerError,
+		},
+		"error, endpoint failure": {
+			samples: []Sample{testSample},
+			status:  http.StatusBadRequest,
+			calls:   1,
+			err: "failed to write samples: " +
+				"remote-write endpoint responded with 400 Bad Request",
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			calls := 0
+			srv := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					calls++
+					assert.Equal(t, http.MethodPost, r.Method)
+					assert.Equal(t, "application/x-protobuf",
+						r.Header.Get("Content-Type"))
+					assert.Equal(t, "snappy",
+						r.Header.Get("Content-Encoding"))
+					body, err := ioutil.ReadAll(r.Body)
+					assert.NoError(t, err)
+					body, err = snappy.Decode(nil, body)
+					assert.NoError(t, err)
+					assert.Equal(t, encodeWriteRequest(tc.samples), body)
+					w.WriteHeader(tc.status)
+				},
+			))
+			defer srv.Close()
+
+			client := NewClient(srv.URL)
+			err := client.Write(context.Background(), tc.samples...)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.calls, calls)
+		})
+	}
+}
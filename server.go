@@ -14,16 +14,24 @@
 package main
 
 import (
+	"context"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/ant0ine/go-json-rest/rest"
 	"github.com/mendersoftware/go-lib-micro/log"
 	"github.com/pkg/errors"
 
 	api_http "github.com/mendersoftware/inventory/api/http"
+	"github.com/mendersoftware/inventory/blob"
 	"github.com/mendersoftware/inventory/config"
+	"github.com/mendersoftware/inventory/events"
 	inventory "github.com/mendersoftware/inventory/inv"
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/remotewrite"
 	"github.com/mendersoftware/inventory/store/mongo"
+	"github.com/mendersoftware/inventory/validator"
 )
 
 func SetupAPI(stacktype string) (*rest.Api, error) {
@@ -41,6 +49,183 @@ func SetupAPI(stacktype string) (*rest.Api, error) {
 	return api, nil
 }
 
+// makeAuthzPolicy returns the authorization policy from the configuration
+// or nil if no policy is configured.
+func makeAuthzPolicy(c config.Reader) (*api_http.AuthzPolicy, error) {
+	roles := c.GetStringMapStringSlice(SettingAuthzPolicy)
+	hidden := c.GetStringMapStringSlice(SettingAuthzHiddenAttributes)
+	readOnly := c.GetStringMapStringSlice(SettingAuthzReadOnlyAttributes)
+	if len(roles) == 0 && len(hidden) == 0 && len(readOnly) == 0 {
+		return nil, nil
+	}
+	policy := &api_http.AuthzPolicy{
+		Roles:       make(map[string][]api_http.EndpointClass, len(roles)),
+		DefaultRole: c.GetString(SettingAuthzDefaultRole),
+	}
+	if len(hidden) > 0 || len(readOnly) > 0 {
+		policy.Attributes = make(map[string]api_http.AttributeRules)
+	}
+	for role, classes := range roles {
+		for _, class := range classes {
+			policy.Roles[role] = append(policy.Roles[role],
+				api_http.EndpointClass(class))
+		}
+	}
+	for role, attrs := range hidden {
+		rules := policy.Attributes[role]
+		for _, attr := range attrs {
+			ref, err := api_http.ParseAttributeRef(attr)
+			if err != nil {
+				return nil, errors.Wrapf(err, "hidden attributes of role %s", role)
+			}
+			rules.Hidden = append(rules.Hidden, ref)
+		}
+		policy.Attributes[role] = rules
+	}
+	for role, attrs := range readOnly {
+		rules := policy.Attributes[role]
+		for _, attr := range attrs {
+			ref, err := api_http.ParseAttributeRef(attr)
+			if err != nil {
+				return nil, errors.Wrapf(err, "read-only attributes of role %s", role)
+			}
+			rules.ReadOnly = append(rules.ReadOnly, ref)
+		}
+		policy.Attributes[role] = rules
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// makeFeatureFlags returns the default state of the feature flags from
+// the configuration.
+func makeFeatureFlags(c config.Reader) (model.FeatureFlagSet, error) {
+	flags := model.FeatureFlagSet{}
+	for name, value := range c.GetStringMapString(SettingFeatureFlags) {
+		flag := model.FeatureFlag(name)
+		if err := flag.Validate(); err != nil {
+			return nil, err
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "feature flag %s", name)
+		}
+		flags[flag] = enabled
+	}
+	return flags, nil
+}
+
+// runRetentionSweeper periodically removes the expired device attributes
+// until the context is canceled.
+func runRetentionSweeper(
+	ctx context.Context,
+	inv inventory.InventoryApp,
+	interval time.Duration,
+) {
+	l := log.FromContext(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := inv.SweepExpiredAttributes(ctx); err != nil {
+				l.Errorf("retention sweep: %s", err.Error())
+			}
+		}
+	}
+}
+
+// runCompletenessAlerts periodically reports the devices missing required
+// attributes past their grace period until the context is canceled.
+func runCompletenessAlerts(
+	ctx context.Context,
+	inv inventory.InventoryApp,
+	interval time.Duration,
+) {
+	l := log.FromContext(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := inv.CheckCompletenessAlerts(ctx); err != nil {
+				l.Errorf("completeness alerts: %s", err.Error())
+			}
+		}
+	}
+}
+
+// runDigests periodically sends the digests of the tenants whose digest
+// period is over until the context is canceled.
+func runDigests(
+	ctx context.Context,
+	inv inventory.InventoryApp,
+	interval time.Duration,
+) {
+	l := log.FromContext(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := inv.SendDigests(ctx); err != nil {
+				l.Errorf("digests: %s", err.Error())
+			}
+		}
+	}
+}
+
+// subscriptionsWorkerRetry is the delay before restarting the failed
+// change stream of the subscriptions worker.
+const subscriptionsWorkerRetry = 10 * time.Second
+
+// runSubscriptionsWorker notifies the subscribers of the changes of
+// the devices, restarting the change stream on failures, until the context
+// is canceled.
+func runSubscriptionsWorker(
+	ctx context.Context,
+	inv inventory.InventoryApp,
+	retry time.Duration,
+) {
+	l := log.FromContext(ctx)
+	for {
+		err := inv.WatchSubscriptions(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+		if err != nil {
+			l.Errorf("subscriptions worker: %s", err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+	}
+}
+
+// runGroupsBackfill converts the group membership of the devices written
+// before the dual-write was enabled to the groups array.
+func runGroupsBackfill(ctx context.Context, inv inventory.InventoryApp) {
+	l := log.FromContext(ctx)
+	res, err := inv.BackfillGroups(ctx)
+	if err != nil {
+		l.Errorf("groups backfill: %s", err.Error())
+		return
+	}
+	l.Infof("groups backfill: %d devices updated", res.UpdatedCount)
+}
+
 func RunServer(c config.Reader) error {
 
 	l := log.New(log.Ctx{})
@@ -50,23 +235,154 @@ func RunServer(c config.Reader) error {
 		return errors.Wrap(err, "database connection failed")
 	}
 
-	inv := inventory.NewInventory(db)
+	features, err := makeFeatureFlags(c)
+	if err != nil {
+		return errors.Wrap(err, "invalid feature flags")
+	}
+	inv := inventory.NewInventory(db).WithFeatureFlags(features)
+	if url := c.GetString(SettingEventsWebhookURL); url != "" {
+		inv = inv.WithEventEmitter(events.NewWebhookEmitter(url))
+	}
+	email, err := events.NewEmailConnector(
+		c.GetString(SettingNotificationsEmailConnectorURL),
+	)
+	if err != nil {
+		return errors.Wrap(err, "invalid email connector")
+	}
+	inv = inv.WithNotifier(events.NewChannelNotifier(email))
+	if dir := c.GetString(SettingExportsDir); dir != "" {
+		inv = inv.WithBlobStore(blob.NewFileStore(dir))
+	}
+	if url := c.GetString(SettingRemoteWriteURL); url != "" {
+		inv = inv.WithRemoteWrite(remotewrite.NewClient(url),
+			c.GetStringMapStringSlice(SettingRemoteWriteAttributes))
+	}
+	if attrs := c.GetStringMapStringSlice(
+		SettingDeploymentDiffAttributes,
+	); len(attrs) > 0 {
+		inv = inv.WithDiffAttributes(attrs)
+	}
+	inv = inv.WithAttributesValidator(validator.NewWebhookValidator())
+	inv = inv.WithSoftLimits(model.Limits{
+		PerPage:       c.GetInt(SettingSoftLimitPerPage),
+		Filters:       c.GetInt(SettingSoftLimitFilters),
+		ExportDevices: c.GetInt(SettingSoftLimitExportDevices),
+	})
+	inv = inv.WithTimelineConcurrency(c.GetInt(SettingTimelineConcurrency))
+
+	if maxAge := c.GetInt(SettingDbCursorMaxAge); maxAge > 0 {
+		ctx := log.WithContext(context.Background(), l)
+		go mongo.RunCursorReaper(ctx, time.Duration(maxAge)*time.Second)
+	}
+	if interval := c.GetInt(SettingRetentionSweepInterval); interval > 0 {
+		ctx := log.WithContext(context.Background(), l)
+		go runRetentionSweeper(ctx, inv, time.Duration(interval)*time.Second)
+	}
+	if interval := c.GetInt(SettingCompletenessAlertsInterval); interval > 0 {
+		ctx := log.WithContext(context.Background(), l)
+		go runCompletenessAlerts(ctx, inv, time.Duration(interval)*time.Second)
+	}
+	if interval := c.GetInt(SettingDigestsInterval); interval > 0 {
+		ctx := log.WithContext(context.Background(), l)
+		go runDigests(ctx, inv, time.Duration(interval)*time.Second)
+	}
+
+	if c.GetBool(SettingSchemaRolloutGroups) {
+		ctx := log.WithContext(context.Background(), l)
+		go runGroupsBackfill(ctx, inv)
+	}
+
+	if c.GetBool(SettingSubscriptionsWorker) {
+		ctx := log.WithContext(context.Background(), l)
+		go runSubscriptionsWorker(ctx, inv, subscriptionsWorkerRetry)
+	}
 
 	invapi := api_http.NewInventoryApiHandlers(inv)
 
-	api, err := SetupAPI(c.GetString(SettingMiddleware))
+	policy, err := makeAuthzPolicy(c)
 	if err != nil {
-		return errors.Wrap(err, "API setup failed")
+		return errors.Wrap(err, "invalid authorization policy")
+	}
+	if c.GetInt(SettingLoadSheddingMaxInFlight) > 0 ||
+		c.GetInt(SettingLoadSheddingMaxLatency) > 0 {
+		l.Infof("shedding the low priority requests on overload")
 	}
+	if policy != nil {
+		l.Infof("enforcing authorization policy")
+	}
+	if c.GetBool(SettingDeviceTokenVerification) {
+		l.Infof("enforcing device token verification on attribute reports")
+	}
+
+	listeners, err := makeListeners(c)
+	if err != nil {
+		return errors.Wrap(err, "invalid listeners")
+	}
+	errs := make(chan error, len(listeners))
+	for _, ln := range listeners {
+		handler, err := makeHandler(c, inv, invapi, policy, ln)
+		if err != nil {
+			return err
+		}
+		ctx := log.WithContext(context.Background(), l)
+		srv, err := ln.server(ctx, handler)
+		if err != nil {
+			return errors.Wrapf(err, "listener %s", ln.Addr)
+		}
+		l.Printf("listening on %s: %v", ln.Addr, ln.Groups)
+		go func(ln listener) {
+			errs <- ln.serve(srv)
+		}(ln)
+	}
+	return <-errs
+}
 
-	apph, err := invapi.GetApp()
+// makeHandler returns the handler of the requests of the route groups
+// served by the listener.
+func makeHandler(
+	c config.Reader,
+	inv inventory.InventoryApp,
+	invapi api_http.ApiHandler,
+	policy *api_http.AuthzPolicy,
+	ln listener,
+) (http.Handler, error) {
+	api, err := SetupAPI(ln.Middleware)
 	if err != nil {
-		return errors.Wrap(err, "inventory API handlers setup failed")
+		return nil, errors.Wrap(err, "API setup failed")
+	}
+
+	// the route middlewares depend on the class of the endpoint, known
+	// once the request is routed
+	var routeMiddlewares []rest.Middleware
+	maxInFlight := c.GetInt(SettingLoadSheddingMaxInFlight)
+	maxLatency := time.Duration(c.GetInt(SettingLoadSheddingMaxLatency)) * time.Millisecond
+	if maxInFlight > 0 || maxLatency > 0 {
+		routeMiddlewares = append(routeMiddlewares,
+			&api_http.LoadSheddingMiddleware{
+				MaxInFlight: maxInFlight,
+				MaxLatency:  maxLatency,
+			})
+	}
+	if policy != nil {
+		routeMiddlewares = append(routeMiddlewares,
+			&api_http.AuthzMiddleware{Policy: *policy})
+	}
+
+	apph, err := invapi.GetGroupsApp(ln.Groups, routeMiddlewares...)
+	if err != nil {
+		return nil, errors.Wrap(err, "inventory API handlers setup failed")
 	}
 	api.SetApp(apph)
 
-	addr := c.GetString(SettingListen)
-	l.Printf("listening on %s", addr)
+	api.Use(&api_http.VerifierMiddleware{Verifier: ln.verifier()})
+
+	api.Use(&api_http.FeatureFlagMiddleware{Inventory: inv})
+	api.Use(&api_http.ConditionalGetMiddleware{
+		MaxAge: time.Duration(c.GetInt(SettingCacheMaxAge)) * time.Second,
+	})
+	if c.GetBool(SettingDeviceTokenVerification) {
+		api.Use(&api_http.DeviceTokenMiddleware{})
+	}
 
-	return http.ListenAndServe(addr, api.MakeHandler())
+	return api.MakeHandler(), nil
 }
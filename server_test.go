@@ -14,9 +14,19 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	api_http "github.com/mendersoftware/inventory/api/http"
+	minventory "github.com/mendersoftware/inventory/inv/mocks"
+	"github.com/mendersoftware/inventory/model"
 )
 
 func TestSetupApi(t *testing.T) {
@@ -29,3 +39,168 @@ func TestSetupApi(t *testing.T) {
 	assert.NotNil(t, api)
 	assert.Nil(t, err)
 }
+
+func TestMakeAuthzPolicy(t *testing.T) {
+	c := viper.New()
+	policy, err := makeAuthzPolicy(c)
+	assert.NoError(t, err)
+	assert.Nil(t, policy)
+
+	c.Set(SettingAuthzPolicy, map[string][]string{
+		"helpdesk": {"read"},
+		"operator": {"read", "groups"},
+	})
+	c.Set(SettingAuthzDefaultRole, "helpdesk")
+	policy, err = makeAuthzPolicy(c)
+	assert.NoError(t, err)
+	assert.Equal(t, &api_http.AuthzPolicy{
+		Roles: map[string][]api_http.EndpointClass{
+			"helpdesk": {api_http.EndpointClassRead},
+			"operator": {
+				api_http.EndpointClassRead,
+				api_http.EndpointClassGroups,
+			},
+		},
+		DefaultRole: "helpdesk",
+	}, policy)
+
+	c.Set(SettingAuthzPolicy, map[string][]string{
+		"helpdesk": {"write"},
+	})
+	_, err = makeAuthzPolicy(c)
+	assert.EqualError(t, err,
+		"role helpdesk: unknown endpoint class: write")
+
+	c = viper.New()
+	c.Set(SettingAuthzHiddenAttributes, map[string][]string{
+		"helpdesk": {"inventory/owner_email", "warranty"},
+	})
+	c.Set(SettingAuthzReadOnlyAttributes, map[string][]string{
+		"operator": {"warranty"},
+	})
+	policy, err = makeAuthzPolicy(c)
+	assert.NoError(t, err)
+	assert.Equal(t, &api_http.AuthzPolicy{
+		Roles: map[string][]api_http.EndpointClass{},
+		Attributes: map[string]api_http.AttributeRules{
+			"helpdesk": {Hidden: []api_http.AttributeRef{
+				{Scope: "inventory", Name: "owner_email"},
+				{Scope: "warranty"},
+			}},
+			"operator": {ReadOnly: []api_http.AttributeRef{
+				{Scope: "warranty"},
+			}},
+		},
+	}, policy)
+
+	c.Set(SettingAuthzReadOnlyAttributes, map[string][]string{
+		"operator": {"warranty/"},
+	})
+	_, err = makeAuthzPolicy(c)
+	assert.EqualError(t, err,
+		`read-only attributes of role operator: invalid attribute: "warranty/"`)
+}
+
+func TestMakeFeatureFlags(t *testing.T) {
+	c := viper.New()
+	flags, err := makeFeatureFlags(c)
+	assert.NoError(t, err)
+	assert.Empty(t, flags)
+
+	c.Set(SettingFeatureFlags, map[string]interface{}{
+		"dynamic_groups": false,
+		"api_v2":         "true",
+	})
+	flags, err = makeFeatureFlags(c)
+	assert.NoError(t, err)
+	assert.Equal(t, model.FeatureFlagSet{
+		model.FeatureDynamicGroups: false,
+		model.FeatureAPIv2:         true,
+	}, flags)
+
+	c.Set(SettingFeatureFlags, map[string]interface{}{"teleport": true})
+	_, err = makeFeatureFlags(c)
+	assert.EqualError(t, err, "unknown feature flag: teleport")
+
+	c.Set(SettingFeatureFlags, map[string]interface{}{"webhooks": "maybe"})
+	_, err = makeFeatureFlags(c)
+	assert.EqualError(t, err, "feature flag webhooks: "+
+		"strconv.ParseBool: parsing \"maybe\": invalid syntax")
+}
+
+func TestRunRetentionSweeper(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	swept := make(chan struct{})
+	var once sync.Once
+
+	inv := &minventory.InventoryApp{}
+	inv.On("SweepExpiredAttributes", ctx).
+		Return(errors.New("db error")).Once()
+	inv.On("SweepExpiredAttributes", ctx).
+		Run(func(mock.Arguments) { once.Do(func() { close(swept) }) }).
+		Return(nil)
+
+	done := make(chan struct{})
+	go func() {
+		runRetentionSweeper(ctx, inv, time.Millisecond)
+		close(done)
+	}()
+	select {
+	case <-swept:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the sweep")
+	}
+	cancel()
+	<-done
+	inv.AssertExpectations(t)
+}
+
+func TestRunCompletenessAlerts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	checked := make(chan struct{})
+	var once sync.Once
+
+	inv := &minventory.InventoryApp{}
+	inv.On("CheckCompletenessAlerts", ctx).
+		Return(errors.New("db error")).Once()
+	inv.On("CheckCompletenessAlerts", ctx).
+		Run(func(mock.Arguments) { once.Do(func() { close(checked) }) }).
+		Return(nil)
+
+	done := make(chan struct{})
+	go func() {
+		runCompletenessAlerts(ctx, inv, time.Millisecond)
+		close(done)
+	}()
+	select {
+	case <-checked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the check")
+	}
+	cancel()
+	<-done
+	inv.AssertExpectations(t)
+}
+
+func TestRunSubscriptionsWorker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	inv := &minventory.InventoryApp{}
+	inv.On("WatchSubscriptions", ctx).
+		Return(errors.New("change stream closed")).Once()
+	inv.On("WatchSubscriptions", ctx).
+		Run(func(mock.Arguments) { cancel() }).
+		Return(context.Canceled)
+
+	done := make(chan struct{})
+	go func() {
+		runSubscriptionsWorker(ctx, inv, time.Millisecond)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the worker to stop")
+	}
+	inv.AssertExpectations(t)
+}
@@ -17,6 +17,7 @@ package store
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/mendersoftware/inventory/model"
 )
@@ -33,18 +34,74 @@ var (
 
 	// ErrWriteConflict represents a write conflict in the storage layer
 	ErrWriteConflict = errors.New("write conflict")
+
+	ErrScopeNotFound = errors.New("scope not found")
+
+	ErrAttributeDefinitionNotFound = errors.New("attribute definition not found")
+
+	ErrExternalIDNotFound = errors.New("external ID not found")
+
+	// ErrRolloutDisabled is returned by the operations of a schema rollout
+	// if the dual-write of the new format is not enabled.
+	ErrRolloutDisabled = errors.New("dual-write of the new format is disabled")
+
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+
+	ErrExportJobNotFound = errors.New("export job not found")
+
+	ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+	ErrSavedFilterNotFound = errors.New("saved filter not found")
+
+	ErrDynamicGroupNotFound = errors.New("dynamic group not found")
+
+	ErrGroupMetadataNotFound = errors.New("group metadata not found")
+
+	// ErrPartialResults is returned by SearchDevices together with
+	// the devices found before the search exceeded its max_time_ms; the
+	// total count is -1 if it could not be computed in time.
+	ErrPartialResults = errors.New("search exceeded its time limit: partial results")
 )
 
+// DeviceChangeHandler is called with the context of the tenant for each
+// change of a device.
+type DeviceChangeHandler func(ctx context.Context, change model.DeviceChange) error
+
 //go:generate ../utils/mockgen.sh
 type DataStore interface {
 	Ping(ctx context.Context) error
 
 	GetDevices(ctx context.Context, q ListQuery) ([]model.Device, int, error)
 
+	// CountDevicesByQuery returns the number of devices matching
+	// the filters of the query, ignoring its pagination and sort.
+	CountDevicesByQuery(ctx context.Context, q ListQuery) (int, error)
+
+	// StreamDevices returns the devices matching the query as a stream
+	// decoded incrementally from the database, together with the total
+	// number of matching devices; the stream must be closed by the caller.
+	StreamDevices(ctx context.Context, q ListQuery) (*DeviceStream, int, error)
+
 	// find a device with given `id`, returns the device or nil,
 	// if device was not found, error and returned device are nil
 	GetDevice(ctx context.Context, id model.DeviceID) (*model.Device, error)
 
+	// GetDevicesByIDs returns the devices with the given IDs, limited to
+	// the selected attributes if any; the missing devices are skipped.
+	GetDevicesByIDs(
+		ctx context.Context,
+		ids []model.DeviceID,
+		attributes []model.SelectAttribute,
+	) ([]model.Device, error)
+
+	// GetDeviceIDsByAttribute returns the IDs of the devices by the value
+	// of the attribute, for the listed values only.
+	GetDeviceIDsByAttribute(
+		ctx context.Context,
+		scope, name string,
+		values []string,
+	) (map[string][]model.DeviceID, error)
+
 	// insert device into data store
 	//
 	// ds.AddDevice(&model.Device{
@@ -77,6 +134,12 @@ type DataStore interface {
 	// are created, existing are overwritten; the device resource is also
 	// created if necessary
 	UpsertRemoveDeviceAttributes(ctx context.Context, id model.DeviceID, updateAttrs model.DeviceAttributes, removeAttrs model.DeviceAttributes) (*model.UpdateResult, error)
+
+	// ReplaceDeviceAttributes replaces all the attributes of a scope of
+	// the device with the given ones, which must be of the scope, in
+	// a single atomic update; the device is created if necessary.
+	ReplaceDeviceAttributes(ctx context.Context, id model.DeviceID, scope string, attrs model.DeviceAttributes) (*model.UpdateResult, error)
+
 	// UpsertDevicesAttributesWithRevision upserts attributes for devices in the same way
 	// UpsertDevicesAttributes does.
 	// The only difference between this method and UpsertDevicesAttributes
@@ -89,35 +152,436 @@ type DataStore interface {
 	// in filters
 	GetFiltersAttributes(ctx context.Context) ([]model.FilterAttribute, error)
 
-	// UnsetDevicesGroup removes a list of deices from their respective
-	// groups returning the number of devices that were modified or an
-	// error if any, respectively.
+	// UnsetDevicesGroup removes a list of deices from the group, keeping
+	// their other groups, returning the number of devices that were
+	// modified or an error if any, respectively.
 	UnsetDevicesGroup(ctx context.Context, deviceIDs []model.DeviceID, group model.GroupName) (*model.UpdateResult, error)
 
-	// UpdateDevicesGroup updates multiple devices' group, returning number
-	// of matching devices, the number devices that changed group and error,
-	// if any.
+	// UpdateDevicesGroup adds multiple devices to the group, keeping their
+	// other groups once the groups array is rolled out and moving them
+	// otherwise, returning number of matching devices, the number devices
+	// that joined the group and error, if any.
 	UpdateDevicesGroup(ctx context.Context, devIDs []model.DeviceID, group model.GroupName) (*model.UpdateResult, error)
 
+	// ReplaceDevicesGroup sets the members of the group to the devices,
+	// adding them like UpdateDevicesGroup and removing the other members
+	// in a single update. Returns the number of matching devices, the
+	// number of devices that joined or left the group and the IDs of
+	// the latter.
+	ReplaceDevicesGroup(ctx context.Context, devIDs []model.DeviceID, group model.GroupName) (*model.UpdateResult, []model.DeviceID, error)
+
+	// UpdateDevicesGroupByFilter adds the devices matching the search to
+	// the group in a single update, like UpdateDevicesGroup; returns
+	// the number of matching devices, the number of devices that joined
+	// the group and their IDs. A search without filters matches none.
+	UpdateDevicesGroupByFilter(ctx context.Context, params model.SearchParams, group model.GroupName) (*model.UpdateResult, []model.DeviceID, error)
+
+	// UpdateDevicesTags sets and removes the tags, or other attributes
+	// written by the users, of the devices matching the search in a single
+	// update; returns the number of matching and
+	// modified devices and, for a search by device IDs, the IDs of the
+	// matching devices. A search without filters matches none.
+	UpdateDevicesTags(ctx context.Context, params model.SearchParams, set, unset model.DeviceAttributes) (*model.UpdateResult, []model.DeviceID, error)
+
+	// PreviewDevicesGroup counts the devices UpdateDevicesGroup would add
+	// to the group if given the selected devices, without modifying them.
+	PreviewDevicesGroup(ctx context.Context, assignment model.GroupAssignment, group model.GroupName) (*model.GroupAssignmentPreview, error)
+
+	// DeleteGroup removes all the devices from the group in a single
+	// update, returning the number of devices that were modified.
+	DeleteGroup(ctx context.Context, group model.GroupName) (*model.UpdateResult, error)
+
 	// ListGroups returns a list of all existing groups. Devices included
 	// in the evaluation can be filtered by the filters argument.
 	ListGroups(ctx context.Context, filters []model.FilterPredicate) ([]model.GroupName, error)
 
-	// Lists devices belonging to a group
-	GetDevicesByGroup(ctx context.Context, group model.GroupName, skip, limit int) ([]model.DeviceID, int, error)
+	// SearchGroups returns a page of the group names matching the query,
+	// sorted by name, and the total number of matching groups.
+	SearchGroups(ctx context.Context, q GroupsQuery) ([]model.GroupName, int, error)
+
+	// GetDevicesByGroup lists the IDs of the devices of the group matching
+	// the filters of the query, sorted by its sort keys; the group fields
+	// of the query are ignored but GroupNames, which lists the devices of
+	// the groups in place of the group. Returns ErrGroupNotFound if
+	// the groups have no devices.
+	GetDevicesByGroup(
+		ctx context.Context,
+		group model.GroupName,
+		q ListQuery,
+	) ([]model.DeviceID, int, error)
+
+	// Get device's groups
+	GetDeviceGroups(ctx context.Context, id model.DeviceID) ([]model.GroupName, error)
+
+	// GetDevicesGroups returns the groups of the devices with the given
+	// IDs; devices without a group map to no groups and devices missing
+	// from the inventory are left out.
+	GetDevicesGroups(ctx context.Context, ids []model.DeviceID) (map[model.DeviceID][]model.GroupName, error)
 
-	// Get device's group
-	GetDeviceGroup(ctx context.Context, id model.DeviceID) (model.GroupName, error)
+	// GetAllDeviceIDs returns the IDs of all the devices in the inventory.
+	GetAllDeviceIDs(ctx context.Context) ([]model.DeviceID, error)
 
 	// Scan all devices in collection, grab all (unique) attribute names
 	GetAllAttributeNames(ctx context.Context) ([]string, error)
 
+	// SearchDevices returns the devices matching the search parameters
+	// and their total count; see ErrPartialResults for the searches with
+	// a time limit.
 	SearchDevices(ctx context.Context, searchParams model.SearchParams) ([]model.Device, int, error)
 
+	// ExplainSearchDevices returns the plan the database chooses to run
+	// the device search, along with its execution statistics.
+	ExplainSearchDevices(ctx context.Context, searchParams model.SearchParams) (*model.QueryPlan, error)
+
+	// CountFilterTerms counts the devices matching each term of the
+	// filters of the search alone and together with the previous terms,
+	// as well as the devices reporting the attributes of the terms
+	// matching none.
+	CountFilterTerms(ctx context.Context, searchParams model.SearchParams) ([]model.FilterTermCount, error)
+
+	// GetAttributeValueCounts returns the most common values of the
+	// attribute, sorted by the number of devices in descending order.
+	GetAttributeValueCounts(ctx context.Context, scope, name string, limit int) ([]model.AttributeValueCount, error)
+
+	// CountAttributeDevices returns the number of devices reporting
+	// the attribute.
+	CountAttributeDevices(ctx context.Context, scope, name string) (int, error)
+
+	// GetAttributeValueSets groups the devices by the set of values of
+	// the attribute they have, with up to samples device IDs per set;
+	// the limit most common sets are returned, the largest first.
+	GetAttributeValueSets(ctx context.Context, scope, name string, samples, limit int) ([]model.AttributeValueSet, error)
+
+	// CountDevicesByAttributePair counts the devices matching the filters
+	// of the query by the pairs of values of the two attributes; the limit
+	// most common pairs are returned, the largest first.
+	CountDevicesByAttributePair(
+		ctx context.Context,
+		q ListQuery,
+		row, column model.SelectAttribute,
+		limit int,
+	) ([]model.AttributePairCount, error)
+
+	// GetScopes returns the custom attribute scopes registered by the tenant.
+	GetScopes(ctx context.Context) ([]model.Scope, error)
+
+	// UpsertScope registers the custom attribute scope, replacing the
+	// existing one with the same name.
+	UpsertScope(ctx context.Context, scope model.Scope) error
+
+	// DeleteScope removes the custom attribute scope; returns
+	// ErrScopeNotFound if the scope is not registered.
+	DeleteScope(ctx context.Context, name string) error
+
+	// GetColdAttributes returns the attributes of the cold scopes of the
+	// device, stored apart from the device document.
+	GetColdAttributes(ctx context.Context, id model.DeviceID) (model.DeviceAttributes, error)
+
+	// UpsertRemoveColdAttributes upserts and removes the attributes of
+	// the cold scopes of the device.
+	UpsertRemoveColdAttributes(
+		ctx context.Context,
+		id model.DeviceID,
+		updateAttrs model.DeviceAttributes,
+		removeAttrs model.DeviceAttributes,
+	) error
+
+	// GetAttributeDefinitions returns the attribute schema of the tenant.
+	GetAttributeDefinitions(ctx context.Context) ([]model.AttributeDefinition, error)
+
+	// UpsertAttributeDefinition stores the definition of the attribute,
+	// replacing the existing one with the same scope and name.
+	UpsertAttributeDefinition(ctx context.Context, def model.AttributeDefinition) error
+
+	// DeleteAttributeDefinition removes the definition of the attribute;
+	// returns ErrAttributeDefinitionNotFound if it is not defined.
+	DeleteAttributeDefinition(ctx context.Context, scope, name string) error
+
+	// UnsetExpiredAttributes removes the attribute from the devices which
+	// did not update it since the given time; returns the number of
+	// devices the attribute was removed from.
+	UnsetExpiredAttributes(ctx context.Context, scope, name string, since time.Time) (int64, error)
+
+	// RecordSchemaViolations adds the violations to the validation report;
+	// repeated violations of the same device attribute are merged.
+	RecordSchemaViolations(ctx context.Context, violations []model.SchemaViolation) error
+
+	// GetSchemaViolations returns a page of the validation report, most
+	// recent violations first, optionally limited to a single device,
+	// together with the total number of entries.
+	GetSchemaViolations(ctx context.Context, id model.DeviceID, skip, limit int) ([]model.SchemaViolation, int, error)
+
+	// GetExternalID returns the mapping of the external ID to the device,
+	// or nil if the external ID is not mapped.
+	GetExternalID(ctx context.Context, ref model.ExternalIDRef) (*model.ExternalID, error)
+
+	// UpsertExternalIDs maps the external IDs to the devices, replacing
+	// the existing mappings of the same external IDs.
+	UpsertExternalIDs(ctx context.Context, ids []model.ExternalID) (*model.UpdateResult, error)
+
+	// DeleteExternalID removes the mapping of the external ID; returns
+	// ErrExternalIDNotFound if it is not mapped.
+	DeleteExternalID(ctx context.Context, ref model.ExternalIDRef) error
+
+	// GetFeatureFlags returns the feature flags overridden for the tenant.
+	GetFeatureFlags(ctx context.Context) (model.FeatureFlagSet, error)
+
+	// UpdateFeatureFlags sets or removes the overrides of the feature
+	// flags for the tenant.
+	UpdateFeatureFlags(ctx context.Context, update model.FeatureFlagsUpdate) error
+
+	// GetLimits returns the hard limits of the requests of the tenant.
+	GetLimits(ctx context.Context) (model.Limits, error)
+
+	// SetLimits replaces the hard limits of the requests of the tenant.
+	SetLimits(ctx context.Context, limits model.Limits) error
+
+	// GetValidationWebhook returns the validation webhook of the tenant,
+	// or nil if none is registered.
+	GetValidationWebhook(ctx context.Context) (*model.ValidationWebhook, error)
+
+	// SetValidationWebhook registers the validation webhook of the
+	// tenant, replacing the existing one.
+	SetValidationWebhook(ctx context.Context, hook model.ValidationWebhook) error
+
+	// DeleteValidationWebhook removes the validation webhook of the
+	// tenant.
+	DeleteValidationWebhook(ctx context.Context) error
+
+	// GetGroupsCompleteness aggregates the inventory completeness of the
+	// devices per group, given the attributes required by the schema.
+	GetGroupsCompleteness(ctx context.Context, required []model.SelectAttribute) ([]model.GroupCompleteness, error)
+
+	// GetIncompleteDevices returns a page of the devices missing any of
+	// the required attributes, limited to the required attributes, and
+	// their total count.
+	GetIncompleteDevices(ctx context.Context, q IncompleteDevicesQuery) ([]model.Device, int, error)
+
+	// GetCompletenessAlert returns the completeness alert of the tenant,
+	// or nil if none is set.
+	GetCompletenessAlert(ctx context.Context) (*model.CompletenessAlert, error)
+
+	// SetCompletenessAlert replaces the completeness alert of the tenant.
+	SetCompletenessAlert(ctx context.Context, alert model.CompletenessAlert) error
+
+	// DeleteCompletenessAlert removes the completeness alert of the tenant.
+	DeleteCompletenessAlert(ctx context.Context) error
+
+	// GetDigestSettings returns the digest settings of the tenant, or nil
+	// if the digest is not set up.
+	GetDigestSettings(ctx context.Context) (*model.DigestSettings, error)
+
+	// SetDigestSettings replaces the digest settings of the tenant.
+	SetDigestSettings(ctx context.Context, settings model.DigestSettings) error
+
+	// DeleteDigestSettings removes the digest settings of the tenant.
+	DeleteDigestSettings(ctx context.Context) error
+
+	// GetFleetDigest summarizes the changes of the devices between the
+	// times; the devices whose last report is older than silentAfter
+	// since the period are counted as silent.
+	GetFleetDigest(ctx context.Context, from, to time.Time, silentAfter time.Duration) (*model.FleetDigest, error)
+
+	// GetPinnedAttributes returns the attributes pinned by the tenant.
+	GetPinnedAttributes(ctx context.Context) (model.PinnedAttributes, error)
+
+	// SetPinnedAttributes replaces the attributes pinned by the tenant.
+	SetPinnedAttributes(ctx context.Context, pinned model.PinnedAttributes) error
+
+	// GetAttributeAliases returns the aliases of the attributes of
+	// the tenant.
+	GetAttributeAliases(ctx context.Context) (model.AttributeAliases, error)
+
+	// SetAttributeAliases replaces the aliases of the attributes.
+	SetAttributeAliases(ctx context.Context, aliases model.AttributeAliases) error
+
+	// GetAttributeDescriptions returns the catalog of the descriptions of
+	// the attributes of the tenant, sorted by scope and name.
+	GetAttributeDescriptions(ctx context.Context) (model.AttributeDescriptions, error)
+
+	// SetAttributeDescriptions replaces the catalog of the descriptions.
+	SetAttributeDescriptions(ctx context.Context, descs model.AttributeDescriptions) error
+
+	// UpdateAttributeDescriptions sets and removes the descriptions of the
+	// attributes in a single update, keeping the others.
+	UpdateAttributeDescriptions(ctx context.Context, set, unset model.AttributeDescriptions) error
+
+	// GetSubscriptions returns the subscriptions of the user, or of all
+	// the users of the tenant if the user ID is empty.
+	GetSubscriptions(ctx context.Context, userID string) ([]model.Subscription, error)
+
+	CreateSubscription(ctx context.Context, sub model.Subscription) error
+
+	// UpsertSubscription creates or replaces the subscription of its user,
+	// forgetting the devices matching its filters if they change; returns
+	// ErrWriteConflict if another user has a subscription with the ID.
+	UpsertSubscription(ctx context.Context, sub model.Subscription) error
+
+	// DeleteSubscription removes the subscription of the user; returns
+	// ErrSubscriptionNotFound if the user has no such subscription.
+	DeleteSubscription(ctx context.Context, userID, id string) error
+
+	// DeviceMatches returns true if the device matches all the filters.
+	DeviceMatches(ctx context.Context, id model.DeviceID, filters []model.FilterPredicate) (bool, error)
+
+	// SetSubscriptionMatch records whether the device matches the filters
+	// of the subscription; returns true if the device starts matching.
+	SetSubscriptionMatch(ctx context.Context, subID string, id model.DeviceID, matches bool) (bool, error)
+
+	// WatchDevices calls the handler for each change of the devices of
+	// all the tenants until the context is canceled or the handler fails.
+	// The stream resumes after the last change handled in a previous call.
+	WatchDevices(ctx context.Context, handler DeviceChangeHandler) error
+
+	// AppendDeviceChange records the change at the end of the change feed
+	// of the tenant, assigning its sequence number.
+	AppendDeviceChange(ctx context.Context, change model.DeviceChange) error
+
+	// GetDeviceChanges returns up to limit changes of the change feed of
+	// the tenant, in order, starting from the given sequence number.
+	GetDeviceChanges(ctx context.Context, fromSeq int64, limit int) ([]model.DeviceChange, error)
+
+	// CreateExportJob stores a new export job.
+	CreateExportJob(ctx context.Context, job model.ExportJob) error
+
+	// GetExportJob returns the export job; returns ErrExportJobNotFound
+	// if there is no such job.
+	GetExportJob(ctx context.Context, id string) (*model.ExportJob, error)
+
+	// UpdateExportJob replaces the stored state of the export job.
+	UpdateExportJob(ctx context.Context, job model.ExportJob) error
+
+	// CreateDeadLetter stores a failed webhook delivery.
+	CreateDeadLetter(ctx context.Context, letter model.DeadLetter) error
+
+	// GetDeadLetters returns a page of the failed webhook deliveries,
+	// oldest first, and their total count.
+	GetDeadLetters(ctx context.Context, skip, limit int) ([]model.DeadLetter, int, error)
+
+	// GetDeadLetter returns the failed webhook delivery; returns
+	// ErrDeadLetterNotFound if there is no such delivery.
+	GetDeadLetter(ctx context.Context, id string) (*model.DeadLetter, error)
+
+	// UpdateDeadLetter replaces the stored state of the failed delivery.
+	UpdateDeadLetter(ctx context.Context, letter model.DeadLetter) error
+
+	// DeleteDeadLetter removes the failed webhook delivery; returns
+	// ErrDeadLetterNotFound if there is no such delivery.
+	DeleteDeadLetter(ctx context.Context, id string) error
+
+	// PurgeDeadLetters removes all the failed webhook deliveries.
+	PurgeDeadLetters(ctx context.Context) (*model.UpdateResult, error)
+
+	// GetSavedFilters returns the saved filters selected by the query.
+	GetSavedFilters(ctx context.Context, q SavedFiltersQuery) ([]model.SavedFilter, error)
+
+	// GetSavedFilter returns the saved filter; returns
+	// ErrSavedFilterNotFound if there is no such filter.
+	GetSavedFilter(ctx context.Context, id string) (*model.SavedFilter, error)
+
+	// CreateSavedFilter stores the new saved filter.
+	CreateSavedFilter(ctx context.Context, filter model.SavedFilter) error
+
+	// UpsertSavedFilter creates or replaces the saved filter of its owner;
+	// returns ErrWriteConflict if another user owns a filter with the ID.
+	UpsertSavedFilter(ctx context.Context, filter model.SavedFilter) error
+
+	// DeleteSavedFilter removes the saved filter; returns
+	// ErrSavedFilterNotFound if there is no such filter.
+	DeleteSavedFilter(ctx context.Context, id string) error
+
+	// GetDynamicGroups returns the dynamic groups, sorted by name.
+	GetDynamicGroups(ctx context.Context) ([]model.DynamicGroup, error)
+
+	// GetDynamicGroup returns the dynamic group; returns
+	// ErrDynamicGroupNotFound if there is no such group.
+	GetDynamicGroup(ctx context.Context, name model.GroupName) (*model.DynamicGroup, error)
+
+	// UpsertDynamicGroup stores the dynamic group, replacing the group
+	// of the same name.
+	UpsertDynamicGroup(ctx context.Context, group model.DynamicGroup) error
+
+	// DeleteDynamicGroup removes the dynamic group; returns
+	// ErrDynamicGroupNotFound if there is no such group.
+	DeleteDynamicGroup(ctx context.Context, name model.GroupName) error
+
+	// GetGroupsMetadata returns the metadata of the groups, sorted by
+	// name.
+	GetGroupsMetadata(ctx context.Context) ([]model.GroupMetadata, error)
+
+	// GetGroupMetadata returns the metadata of the group; returns
+	// ErrGroupMetadataNotFound if there is no such metadata.
+	GetGroupMetadata(ctx context.Context, name model.GroupName) (*model.GroupMetadata, error)
+
+	// UpsertGroupMetadata stores the metadata of the group, replacing
+	// the metadata of the same name.
+	UpsertGroupMetadata(ctx context.Context, group model.GroupMetadata) error
+
+	// InsertGroupsMetadata stores the metadata of the groups without
+	// any, keeping the metadata already stored.
+	InsertGroupsMetadata(ctx context.Context, groups []model.GroupMetadata) error
+
+	// DeleteGroupMetadata removes the metadata of the group; returns
+	// ErrGroupMetadataNotFound if there is no such metadata.
+	DeleteGroupMetadata(ctx context.Context, name model.GroupName) error
+
+	// InsertTimelineEvents adds the events to the device timeline,
+	// skipping the ones with the deduplication key of a stored event;
+	// returns the number of events added.
+	InsertTimelineEvents(ctx context.Context, events []model.TimelineEvent) (int, error)
+
+	// CountDevices returns the number of devices in the inventory.
+	CountDevices(ctx context.Context) (int, error)
+
+	// CountDevicesByGroup returns the number of devices in each group,
+	// sorted by the group name. Devices included in the evaluation can
+	// be filtered by the filters argument.
+	CountDevicesByGroup(ctx context.Context, filters []model.FilterPredicate) ([]model.GroupCount, error)
+
+	// GetCatalog returns the precomputed catalog of the tenant, or nil
+	// if it was never computed.
+	GetCatalog(ctx context.Context) (*model.Catalog, error)
+
+	// SaveCatalog replaces the precomputed catalog of the tenant.
+	SaveCatalog(ctx context.Context, catalog model.Catalog) error
+
+	// GetAttributeSnapshots returns the key attributes of the devices
+	// recorded at their last finished deployment.
+	GetAttributeSnapshots(ctx context.Context, ids []model.DeviceID) ([]model.AttributeSnapshot, error)
+
+	// SaveAttributeSnapshots replaces the recorded key attributes of
+	// the devices.
+	SaveAttributeSnapshots(ctx context.Context, snapshots []model.AttributeSnapshot) error
+
+	// ListTenantIDs returns the IDs of the tenants with a database; in
+	// single-tenant setups the result holds the empty tenant ID only.
+	ListTenantIDs(ctx context.Context) ([]string, error)
+
+	// ListTenantDatabases describes the databases of the tenants, or
+	// the database of the single-tenant setups, sorted by name.
+	ListTenantDatabases(ctx context.Context) ([]model.TenantDatabase, error)
+
+	// BackfillGroups converts the group of the devices written before
+	// the dual-write was enabled to the groups array format.
+	BackfillGroups(ctx context.Context) (*model.UpdateResult, error)
+
+	// CountDivergingGroups returns the number of devices whose groups array
+	// does not match the group attribute.
+	CountDivergingGroups(ctx context.Context) (int64, error)
+
+	// CutoverGroups switches reading the group membership to the groups
+	// array format; all the tenants must be backfilled before.
+	CutoverGroups(ctx context.Context) error
+
 	MigrateTenant(ctx context.Context, version string, tenantId string) error
 
 	Migrate(ctx context.Context, version string) error
 
+	// MigrationReport estimates the impact of migrating the databases of
+	// the tenants, or of all the tenants, to the version, in the order
+	// they are migrated, without modifying them.
+	MigrationReport(ctx context.Context, version string, tenantIDs ...string) (*model.MigrationReport, error)
+
 	WithAutomigrate() DataStore
 
 	Maintenance(ctx context.Context, version string, tenantIDs ...string) error
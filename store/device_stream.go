@@ -0,0 +1,119 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package store
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+// DeviceStreamBuffer is the number of devices decoded ahead of the consumer
+// of a DeviceStream.
+const DeviceStreamBuffer = 32
+
+// DeviceCursor is the source of the documents decoded by a DeviceStream;
+// it is satisfied by the mongo cursor.
+//go:generate ../utils/mockgen.sh
+type DeviceCursor interface {
+	Next(ctx context.Context) bool
+	Decode(val interface{}) error
+	Err() error
+	Close(ctx context.Context) error
+}
+
+// DeviceStream decodes the devices from the cursor in a separate goroutine
+// and delivers them on a channel, so that the devices can be serialized
+// while the following ones are still being decoded, without holding the
+// whole result in memory.
+type DeviceStream struct {
+	devices chan model.Device
+	cancel  context.CancelFunc
+	done    chan struct{}
+	err     error
+}
+
+// NewDeviceStream starts decoding the devices from the cursor; the cursor
+// is closed once it is exhausted or the stream is closed.
+func NewDeviceStream(ctx context.Context, cur DeviceCursor) *DeviceStream {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &DeviceStream{
+		devices: make(chan model.Device, DeviceStreamBuffer),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	go s.run(ctx, cur)
+	return s
+}
+
+func (s *DeviceStream) run(ctx context.Context, cur DeviceCursor) {
+	defer close(s.done)
+	defer close(s.devices)
+	// the stream context may be already canceled at this point
+	defer cur.Close(context.Background())
+	defer func() {
+		// the consumer gets the error instead of the service going down
+		if r := recover(); r != nil {
+			s.err = errors.Errorf("failed to decode device: %v", r)
+		}
+	}()
+
+	for cur.Next(ctx) {
+		var dev model.Device
+		if err := cur.Decode(&dev); err != nil {
+			s.err = errors.Wrap(err, "failed to decode device")
+			return
+		}
+		select {
+		case s.devices <- dev:
+		case <-ctx.Done():
+			s.err = ctx.Err()
+			return
+		}
+	}
+	if err := cur.Err(); err != nil {
+		s.err = errors.Wrap(err, "failed to search devices")
+	}
+}
+
+// Devices returns the channel the devices are delivered on; the channel is
+// closed once the cursor is exhausted, fails or the stream is closed.
+func (s *DeviceStream) Devices() <-chan model.Device {
+	return s.devices
+}
+
+// Err waits for the stream to terminate and returns the error which
+// terminated it, if any.
+func (s *DeviceStream) Err() error {
+	<-s.done
+	return s.err
+}
+
+// Close stops decoding the devices and releases the cursor.
+func (s *DeviceStream) Close() {
+	s.cancel()
+	<-s.done
+}
+
+// All collects the remaining devices of the stream into a slice.
+func (s *DeviceStream) All() ([]model.Device, error) {
+	devices := []model.Device{}
+	for dev := range s.devices {
+		devices = append(devices, dev)
+	}
+	return devices, s.Err()
+}
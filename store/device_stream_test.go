@@ -0,0 +1,142 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+type sliceCursor struct {
+	devices []model.Device
+	decErr  error
+	panics  bool
+	pos     int
+	err     error
+	closed  bool
+}
+
+func (c *sliceCursor) Next(ctx context.Context) bool {
+	if c.err = ctx.Err(); c.err != nil || c.pos >= len(c.devices) {
+		return false
+	}
+	c.pos++
+	return true
+}
+
+func (c *sliceCursor) Decode(val interface{}) error {
+	if c.panics {
+		panic("unexpected document")
+	}
+	if c.decErr != nil {
+		return c.decErr
+	}
+	*val.(*model.Device) = c.devices[c.pos-1]
+	return nil
+}
+
+func (c *sliceCursor) Err() error {
+	return c.err
+}
+
+func (c *sliceCursor) Close(ctx context.Context) error {
+	c.closed = true
+	return nil
+}
+
+func makeDevices(n int) []model.Device {
+	devices := make([]model.Device, n)
+	for i := range devices {
+		devices[i] = model.Device{ID: model.DeviceID(string(rune('a' + i%26)))}
+	}
+	return devices
+}
+
+func TestDeviceStream(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ok", func(t *testing.T) {
+		cur := &sliceCursor{devices: makeDevices(DeviceStreamBuffer * 3)}
+		stream := NewDeviceStream(context.Background(), cur)
+
+		devices, err := stream.All()
+		assert.NoError(t, err)
+		assert.Equal(t, cur.devices, devices)
+		assert.True(t, cur.closed)
+	})
+
+	t.Run("ok, empty", func(t *testing.T) {
+		cur := &sliceCursor{}
+		stream := NewDeviceStream(context.Background(), cur)
+
+		devices, err := stream.All()
+		assert.NoError(t, err)
+		assert.Equal(t, []model.Device{}, devices)
+	})
+
+	t.Run("error, decode", func(t *testing.T) {
+		cur := &sliceCursor{
+			devices: makeDevices(2),
+			decErr:  errors.New("corrupt document"),
+		}
+		stream := NewDeviceStream(context.Background(), cur)
+
+		devices, err := stream.All()
+		assert.EqualError(t, err, "failed to decode device: corrupt document")
+		assert.Empty(t, devices)
+		assert.True(t, cur.closed)
+	})
+
+	t.Run("error, panic", func(t *testing.T) {
+		cur := &sliceCursor{devices: makeDevices(2), panics: true}
+		stream := NewDeviceStream(context.Background(), cur)
+
+		devices, err := stream.All()
+		assert.EqualError(t, err, "failed to decode device: unexpected document")
+		assert.Empty(t, devices)
+		assert.True(t, cur.closed)
+	})
+
+	t.Run("closed early", func(t *testing.T) {
+		cur := &sliceCursor{devices: makeDevices(DeviceStreamBuffer * 3)}
+		stream := NewDeviceStream(context.Background(), cur)
+
+		dev := <-stream.Devices()
+		assert.Equal(t, cur.devices[0], dev)
+		stream.Close()
+		assert.True(t, cur.closed)
+		assert.Less(t, cur.pos, len(cur.devices))
+	})
+
+	t.Run("request canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cur := &sliceCursor{devices: makeDevices(DeviceStreamBuffer * 3)}
+		stream := NewDeviceStream(ctx, cur)
+
+		<-stream.Devices()
+		cancel()
+		// the devices decoded ahead are drained, then the stream stops
+		for range stream.Devices() {
+		}
+		assert.True(t, errors.Is(stream.Err(), context.Canceled))
+		assert.True(t, cur.closed)
+		assert.Less(t, cur.pos, len(cur.devices))
+	})
+}
@@ -19,6 +19,8 @@ package mocks
 import (
 	context "context"
 
+	time "time"
+
 	model "github.com/mendersoftware/inventory/model"
 	mock "github.com/stretchr/testify/mock"
 
@@ -44,39 +46,30 @@ func (_m *DataStore) AddDevice(ctx context.Context, dev *model.Device) error {
 	return r0
 }
 
-// DeleteDevices provides a mock function with given fields: ctx, ids
-func (_m *DataStore) DeleteDevices(ctx context.Context, ids []model.DeviceID) (*model.UpdateResult, error) {
-	ret := _m.Called(ctx, ids)
-
-	var r0 *model.UpdateResult
-	if rf, ok := ret.Get(0).(func(context.Context, []model.DeviceID) *model.UpdateResult); ok {
-		r0 = rf(ctx, ids)
-	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*model.UpdateResult)
-		}
-	}
+// AppendDeviceChange provides a mock function with given fields: ctx, change
+func (_m *DataStore) AppendDeviceChange(ctx context.Context, change model.DeviceChange) error {
+	ret := _m.Called(ctx, change)
 
-	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, []model.DeviceID) error); ok {
-		r1 = rf(ctx, ids)
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceChange) error); ok {
+		r0 = rf(ctx, change)
 	} else {
-		r1 = ret.Error(1)
+		r0 = ret.Error(0)
 	}
 
-	return r0, r1
+	return r0
 }
 
-// GetAllAttributeNames provides a mock function with given fields: ctx
-func (_m *DataStore) GetAllAttributeNames(ctx context.Context) ([]string, error) {
+// BackfillGroups provides a mock function with given fields: ctx
+func (_m *DataStore) BackfillGroups(ctx context.Context) (*model.UpdateResult, error) {
 	ret := _m.Called(ctx)
 
-	var r0 []string
-	if rf, ok := ret.Get(0).(func(context.Context) []string); ok {
+	var r0 *model.UpdateResult
+	if rf, ok := ret.Get(0).(func(context.Context) *model.UpdateResult); ok {
 		r0 = rf(ctx)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]string)
+			r0 = ret.Get(0).(*model.UpdateResult)
 		}
 	}
 
@@ -90,22 +83,20 @@ func (_m *DataStore) GetAllAttributeNames(ctx context.Context) ([]string, error)
 	return r0, r1
 }
 
-// GetDevice provides a mock function with given fields: ctx, id
-func (_m *DataStore) GetDevice(ctx context.Context, id model.DeviceID) (*model.Device, error) {
-	ret := _m.Called(ctx, id)
+// CountAttributeDevices provides a mock function with given fields: ctx, scope, name
+func (_m *DataStore) CountAttributeDevices(ctx context.Context, scope string, name string) (int, error) {
+	ret := _m.Called(ctx, scope, name)
 
-	var r0 *model.Device
-	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceID) *model.Device); ok {
-		r0 = rf(ctx, id)
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) int); ok {
+		r0 = rf(ctx, scope, name)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*model.Device)
-		}
+		r0 = ret.Get(0).(int)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, model.DeviceID) error); ok {
-		r1 = rf(ctx, id)
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, scope, name)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -113,20 +104,20 @@ func (_m *DataStore) GetDevice(ctx context.Context, id model.DeviceID) (*model.D
 	return r0, r1
 }
 
-// GetDeviceGroup provides a mock function with given fields: ctx, id
-func (_m *DataStore) GetDeviceGroup(ctx context.Context, id model.DeviceID) (model.GroupName, error) {
-	ret := _m.Called(ctx, id)
+// CountDevices provides a mock function with given fields: ctx
+func (_m *DataStore) CountDevices(ctx context.Context) (int, error) {
+	ret := _m.Called(ctx)
 
-	var r0 model.GroupName
-	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceID) model.GroupName); ok {
-		r0 = rf(ctx, id)
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context) int); ok {
+		r0 = rf(ctx)
 	} else {
-		r0 = ret.Get(0).(model.GroupName)
+		r0 = ret.Get(0).(int)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, model.DeviceID) error); ok {
-		r1 = rf(ctx, id)
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -134,77 +125,82 @@ func (_m *DataStore) GetDeviceGroup(ctx context.Context, id model.DeviceID) (mod
 	return r0, r1
 }
 
-// GetDevices provides a mock function with given fields: ctx, q
-func (_m *DataStore) GetDevices(ctx context.Context, q store.ListQuery) ([]model.Device, int, error) {
-	ret := _m.Called(ctx, q)
+// CountDevicesByAttributePair provides a mock function with given fields: ctx, q, row, column, limit
+func (_m *DataStore) CountDevicesByAttributePair(ctx context.Context, q store.ListQuery, row model.SelectAttribute, column model.SelectAttribute, limit int) ([]model.AttributePairCount, error) {
+	ret := _m.Called(ctx, q, row, column, limit)
 
-	var r0 []model.Device
-	if rf, ok := ret.Get(0).(func(context.Context, store.ListQuery) []model.Device); ok {
-		r0 = rf(ctx, q)
+	var r0 []model.AttributePairCount
+	if rf, ok := ret.Get(0).(func(context.Context, store.ListQuery, model.SelectAttribute, model.SelectAttribute, int) []model.AttributePairCount); ok {
+		r0 = rf(ctx, q, row, column, limit)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]model.Device)
+			r0 = ret.Get(0).([]model.AttributePairCount)
 		}
 	}
 
-	var r1 int
-	if rf, ok := ret.Get(1).(func(context.Context, store.ListQuery) int); ok {
-		r1 = rf(ctx, q)
-	} else {
-		r1 = ret.Get(1).(int)
-	}
-
-	var r2 error
-	if rf, ok := ret.Get(2).(func(context.Context, store.ListQuery) error); ok {
-		r2 = rf(ctx, q)
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, store.ListQuery, model.SelectAttribute, model.SelectAttribute, int) error); ok {
+		r1 = rf(ctx, q, row, column, limit)
 	} else {
-		r2 = ret.Error(2)
+		r1 = ret.Error(1)
 	}
 
-	return r0, r1, r2
+	return r0, r1
 }
 
-// GetDevicesByGroup provides a mock function with given fields: ctx, group, skip, limit
-func (_m *DataStore) GetDevicesByGroup(ctx context.Context, group model.GroupName, skip int, limit int) ([]model.DeviceID, int, error) {
-	ret := _m.Called(ctx, group, skip, limit)
+// CountDevicesByGroup provides a mock function with given fields: ctx, filters
+func (_m *DataStore) CountDevicesByGroup(ctx context.Context, filters []model.FilterPredicate) ([]model.GroupCount, error) {
+	ret := _m.Called(ctx, filters)
 
-	var r0 []model.DeviceID
-	if rf, ok := ret.Get(0).(func(context.Context, model.GroupName, int, int) []model.DeviceID); ok {
-		r0 = rf(ctx, group, skip, limit)
+	var r0 []model.GroupCount
+	if rf, ok := ret.Get(0).(func(context.Context, []model.FilterPredicate) []model.GroupCount); ok {
+		r0 = rf(ctx, filters)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]model.DeviceID)
+			r0 = ret.Get(0).([]model.GroupCount)
 		}
 	}
 
-	var r1 int
-	if rf, ok := ret.Get(1).(func(context.Context, model.GroupName, int, int) int); ok {
-		r1 = rf(ctx, group, skip, limit)
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []model.FilterPredicate) error); ok {
+		r1 = rf(ctx, filters)
 	} else {
-		r1 = ret.Get(1).(int)
+		r1 = ret.Error(1)
 	}
 
-	var r2 error
-	if rf, ok := ret.Get(2).(func(context.Context, model.GroupName, int, int) error); ok {
-		r2 = rf(ctx, group, skip, limit)
+	return r0, r1
+}
+
+// CountDevicesByQuery provides a mock function with given fields: ctx, q
+func (_m *DataStore) CountDevicesByQuery(ctx context.Context, q store.ListQuery) (int, error) {
+	ret := _m.Called(ctx, q)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, store.ListQuery) int); ok {
+		r0 = rf(ctx, q)
 	} else {
-		r2 = ret.Error(2)
+		r0 = ret.Get(0).(int)
 	}
 
-	return r0, r1, r2
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, store.ListQuery) error); ok {
+		r1 = rf(ctx, q)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
-// GetFiltersAttributes provides a mock function with given fields: ctx
-func (_m *DataStore) GetFiltersAttributes(ctx context.Context) ([]model.FilterAttribute, error) {
+// CountDivergingGroups provides a mock function with given fields: ctx
+func (_m *DataStore) CountDivergingGroups(ctx context.Context) (int64, error) {
 	ret := _m.Called(ctx)
 
-	var r0 []model.FilterAttribute
-	if rf, ok := ret.Get(0).(func(context.Context) []model.FilterAttribute); ok {
+	var r0 int64
+	if rf, ok := ret.Get(0).(func(context.Context) int64); ok {
 		r0 = rf(ctx)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]model.FilterAttribute)
-		}
+		r0 = ret.Get(0).(int64)
 	}
 
 	var r1 error
@@ -217,22 +213,22 @@ func (_m *DataStore) GetFiltersAttributes(ctx context.Context) ([]model.FilterAt
 	return r0, r1
 }
 
-// ListGroups provides a mock function with given fields: ctx, filters
-func (_m *DataStore) ListGroups(ctx context.Context, filters []model.FilterPredicate) ([]model.GroupName, error) {
-	ret := _m.Called(ctx, filters)
+// CountFilterTerms provides a mock function with given fields: ctx, searchParams
+func (_m *DataStore) CountFilterTerms(ctx context.Context, searchParams model.SearchParams) ([]model.FilterTermCount, error) {
+	ret := _m.Called(ctx, searchParams)
 
-	var r0 []model.GroupName
-	if rf, ok := ret.Get(0).(func(context.Context, []model.FilterPredicate) []model.GroupName); ok {
-		r0 = rf(ctx, filters)
+	var r0 []model.FilterTermCount
+	if rf, ok := ret.Get(0).(func(context.Context, model.SearchParams) []model.FilterTermCount); ok {
+		r0 = rf(ctx, searchParams)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]model.GroupName)
+			r0 = ret.Get(0).([]model.FilterTermCount)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, []model.FilterPredicate) error); ok {
-		r1 = rf(ctx, filters)
+	if rf, ok := ret.Get(1).(func(context.Context, model.SearchParams) error); ok {
+		r1 = rf(ctx, searchParams)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -240,20 +236,27 @@ func (_m *DataStore) ListGroups(ctx context.Context, filters []model.FilterPredi
 	return r0, r1
 }
 
-// Maintenance provides a mock function with given fields: ctx, version, tenantIDs
-func (_m *DataStore) Maintenance(ctx context.Context, version string, tenantIDs ...string) error {
-	_va := make([]interface{}, len(tenantIDs))
-	for _i := range tenantIDs {
-		_va[_i] = tenantIDs[_i]
+// CreateDeadLetter provides a mock function with given fields: ctx, letter
+func (_m *DataStore) CreateDeadLetter(ctx context.Context, letter model.DeadLetter) error {
+	ret := _m.Called(ctx, letter)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.DeadLetter) error); ok {
+		r0 = rf(ctx, letter)
+	} else {
+		r0 = ret.Error(0)
 	}
-	var _ca []interface{}
-	_ca = append(_ca, ctx, version)
-	_ca = append(_ca, _va...)
-	ret := _m.Called(_ca...)
+
+	return r0
+}
+
+// CreateExportJob provides a mock function with given fields: ctx, job
+func (_m *DataStore) CreateExportJob(ctx context.Context, job model.ExportJob) error {
+	ret := _m.Called(ctx, job)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, ...string) error); ok {
-		r0 = rf(ctx, version, tenantIDs...)
+	if rf, ok := ret.Get(0).(func(context.Context, model.ExportJob) error); ok {
+		r0 = rf(ctx, job)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -261,13 +264,13 @@ func (_m *DataStore) Maintenance(ctx context.Context, version string, tenantIDs
 	return r0
 }
 
-// Migrate provides a mock function with given fields: ctx, version
-func (_m *DataStore) Migrate(ctx context.Context, version string) error {
-	ret := _m.Called(ctx, version)
+// CreateSavedFilter provides a mock function with given fields: ctx, filter
+func (_m *DataStore) CreateSavedFilter(ctx context.Context, filter model.SavedFilter) error {
+	ret := _m.Called(ctx, filter)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
-		r0 = rf(ctx, version)
+	if rf, ok := ret.Get(0).(func(context.Context, model.SavedFilter) error); ok {
+		r0 = rf(ctx, filter)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -275,13 +278,13 @@ func (_m *DataStore) Migrate(ctx context.Context, version string) error {
 	return r0
 }
 
-// MigrateTenant provides a mock function with given fields: ctx, version, tenantId
-func (_m *DataStore) MigrateTenant(ctx context.Context, version string, tenantId string) error {
-	ret := _m.Called(ctx, version, tenantId)
+// CreateSubscription provides a mock function with given fields: ctx, sub
+func (_m *DataStore) CreateSubscription(ctx context.Context, sub model.Subscription) error {
+	ret := _m.Called(ctx, sub)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
-		r0 = rf(ctx, version, tenantId)
+	if rf, ok := ret.Get(0).(func(context.Context, model.Subscription) error); ok {
+		r0 = rf(ctx, sub)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -289,8 +292,8 @@ func (_m *DataStore) MigrateTenant(ctx context.Context, version string, tenantId
 	return r0
 }
 
-// Ping provides a mock function with given fields: ctx
-func (_m *DataStore) Ping(ctx context.Context) error {
+// CutoverGroups provides a mock function with given fields: ctx
+func (_m *DataStore) CutoverGroups(ctx context.Context) error {
 	ret := _m.Called(ctx)
 
 	var r0 error
@@ -303,43 +306,55 @@ func (_m *DataStore) Ping(ctx context.Context) error {
 	return r0
 }
 
-// SearchDevices provides a mock function with given fields: ctx, searchParams
-func (_m *DataStore) SearchDevices(ctx context.Context, searchParams model.SearchParams) ([]model.Device, int, error) {
-	ret := _m.Called(ctx, searchParams)
+// DeleteAttributeDefinition provides a mock function with given fields: ctx, scope, name
+func (_m *DataStore) DeleteAttributeDefinition(ctx context.Context, scope string, name string) error {
+	ret := _m.Called(ctx, scope, name)
 
-	var r0 []model.Device
-	if rf, ok := ret.Get(0).(func(context.Context, model.SearchParams) []model.Device); ok {
-		r0 = rf(ctx, searchParams)
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, scope, name)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]model.Device)
-		}
+		r0 = ret.Error(0)
 	}
 
-	var r1 int
-	if rf, ok := ret.Get(1).(func(context.Context, model.SearchParams) int); ok {
-		r1 = rf(ctx, searchParams)
+	return r0
+}
+
+// DeleteCompletenessAlert provides a mock function with given fields: ctx
+func (_m *DataStore) DeleteCompletenessAlert(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
 	} else {
-		r1 = ret.Get(1).(int)
+		r0 = ret.Error(0)
 	}
 
-	var r2 error
-	if rf, ok := ret.Get(2).(func(context.Context, model.SearchParams) error); ok {
-		r2 = rf(ctx, searchParams)
+	return r0
+}
+
+// DeleteDeadLetter provides a mock function with given fields: ctx, id
+func (_m *DataStore) DeleteDeadLetter(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
 	} else {
-		r2 = ret.Error(2)
+		r0 = ret.Error(0)
 	}
 
-	return r0, r1, r2
+	return r0
 }
 
-// UnsetDevicesGroup provides a mock function with given fields: ctx, deviceIDs, group
-func (_m *DataStore) UnsetDevicesGroup(ctx context.Context, deviceIDs []model.DeviceID, group model.GroupName) (*model.UpdateResult, error) {
-	ret := _m.Called(ctx, deviceIDs, group)
+// DeleteDevices provides a mock function with given fields: ctx, ids
+func (_m *DataStore) DeleteDevices(ctx context.Context, ids []model.DeviceID) (*model.UpdateResult, error) {
+	ret := _m.Called(ctx, ids)
 
 	var r0 *model.UpdateResult
-	if rf, ok := ret.Get(0).(func(context.Context, []model.DeviceID, model.GroupName) *model.UpdateResult); ok {
-		r0 = rf(ctx, deviceIDs, group)
+	if rf, ok := ret.Get(0).(func(context.Context, []model.DeviceID) *model.UpdateResult); ok {
+		r0 = rf(ctx, ids)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*model.UpdateResult)
@@ -347,8 +362,8 @@ func (_m *DataStore) UnsetDevicesGroup(ctx context.Context, deviceIDs []model.De
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, []model.DeviceID, model.GroupName) error); ok {
-		r1 = rf(ctx, deviceIDs, group)
+	if rf, ok := ret.Get(1).(func(context.Context, []model.DeviceID) error); ok {
+		r1 = rf(ctx, ids)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -356,59 +371,55 @@ func (_m *DataStore) UnsetDevicesGroup(ctx context.Context, deviceIDs []model.De
 	return r0, r1
 }
 
-// UpdateDevicesGroup provides a mock function with given fields: ctx, devIDs, group
-func (_m *DataStore) UpdateDevicesGroup(ctx context.Context, devIDs []model.DeviceID, group model.GroupName) (*model.UpdateResult, error) {
-	ret := _m.Called(ctx, devIDs, group)
-
-	var r0 *model.UpdateResult
-	if rf, ok := ret.Get(0).(func(context.Context, []model.DeviceID, model.GroupName) *model.UpdateResult); ok {
-		r0 = rf(ctx, devIDs, group)
-	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*model.UpdateResult)
-		}
-	}
+// DeleteDigestSettings provides a mock function with given fields: ctx
+func (_m *DataStore) DeleteDigestSettings(ctx context.Context) error {
+	ret := _m.Called(ctx)
 
-	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, []model.DeviceID, model.GroupName) error); ok {
-		r1 = rf(ctx, devIDs, group)
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
 	} else {
-		r1 = ret.Error(1)
+		r0 = ret.Error(0)
 	}
 
-	return r0, r1
+	return r0
 }
 
-// UpsertDevicesAttributes provides a mock function with given fields: ctx, ids, attrs
-func (_m *DataStore) UpsertDevicesAttributes(ctx context.Context, ids []model.DeviceID, attrs model.DeviceAttributes) (*model.UpdateResult, error) {
-	ret := _m.Called(ctx, ids, attrs)
+// DeleteDynamicGroup provides a mock function with given fields: ctx, name
+func (_m *DataStore) DeleteDynamicGroup(ctx context.Context, name model.GroupName) error {
+	ret := _m.Called(ctx, name)
 
-	var r0 *model.UpdateResult
-	if rf, ok := ret.Get(0).(func(context.Context, []model.DeviceID, model.DeviceAttributes) *model.UpdateResult); ok {
-		r0 = rf(ctx, ids, attrs)
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.GroupName) error); ok {
+		r0 = rf(ctx, name)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*model.UpdateResult)
-		}
+		r0 = ret.Error(0)
 	}
 
-	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, []model.DeviceID, model.DeviceAttributes) error); ok {
-		r1 = rf(ctx, ids, attrs)
+	return r0
+}
+
+// DeleteExternalID provides a mock function with given fields: ctx, ref
+func (_m *DataStore) DeleteExternalID(ctx context.Context, ref model.ExternalIDRef) error {
+	ret := _m.Called(ctx, ref)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.ExternalIDRef) error); ok {
+		r0 = rf(ctx, ref)
 	} else {
-		r1 = ret.Error(1)
+		r0 = ret.Error(0)
 	}
 
-	return r0, r1
+	return r0
 }
 
-// UpsertDevicesAttributesWithRevision provides a mock function with given fields: ctx, ids, attrs
-func (_m *DataStore) UpsertDevicesAttributesWithRevision(ctx context.Context, ids []model.DeviceUpdate, attrs model.DeviceAttributes) (*model.UpdateResult, error) {
-	ret := _m.Called(ctx, ids, attrs)
+// DeleteGroup provides a mock function with given fields: ctx, group
+func (_m *DataStore) DeleteGroup(ctx context.Context, group model.GroupName) (*model.UpdateResult, error) {
+	ret := _m.Called(ctx, group)
 
 	var r0 *model.UpdateResult
-	if rf, ok := ret.Get(0).(func(context.Context, []model.DeviceUpdate, model.DeviceAttributes) *model.UpdateResult); ok {
-		r0 = rf(ctx, ids, attrs)
+	if rf, ok := ret.Get(0).(func(context.Context, model.GroupName) *model.UpdateResult); ok {
+		r0 = rf(ctx, group)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*model.UpdateResult)
@@ -416,8 +427,8 @@ func (_m *DataStore) UpsertDevicesAttributesWithRevision(ctx context.Context, id
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, []model.DeviceUpdate, model.DeviceAttributes) error); ok {
-		r1 = rf(ctx, ids, attrs)
+	if rf, ok := ret.Get(1).(func(context.Context, model.GroupName) error); ok {
+		r1 = rf(ctx, group)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -425,11 +436,1889 @@ func (_m *DataStore) UpsertDevicesAttributesWithRevision(ctx context.Context, id
 	return r0, r1
 }
 
-// UpsertDevicesAttributesWithUpdated provides a mock function with given fields: ctx, ids, attrs
-func (_m *DataStore) UpsertDevicesAttributesWithUpdated(ctx context.Context, ids []model.DeviceID, attrs model.DeviceAttributes) (*model.UpdateResult, error) {
-	ret := _m.Called(ctx, ids, attrs)
+// DeleteGroupMetadata provides a mock function with given fields: ctx, name
+func (_m *DataStore) DeleteGroupMetadata(ctx context.Context, name model.GroupName) error {
+	ret := _m.Called(ctx, name)
 
-	var r0 *model.UpdateResult
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.GroupName) error); ok {
+		r0 = rf(ctx, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteSavedFilter provides a mock function with given fields: ctx, id
+func (_m *DataStore) DeleteSavedFilter(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteScope provides a mock function with given fields: ctx, name
+func (_m *DataStore) DeleteScope(ctx context.Context, name string) error {
+	ret := _m.Called(ctx, name)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteSubscription provides a mock function with given fields: ctx, userID, id
+func (_m *DataStore) DeleteSubscription(ctx context.Context, userID string, id string) error {
+	ret := _m.Called(ctx, userID, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, userID, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteValidationWebhook provides a mock function with given fields: ctx
+func (_m *DataStore) DeleteValidationWebhook(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeviceMatches provides a mock function with given fields: ctx, id, filters
+func (_m *DataStore) DeviceMatches(ctx context.Context, id model.DeviceID, filters []model.FilterPredicate) (bool, error) {
+	ret := _m.Called(ctx, id, filters)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceID, []model.FilterPredicate) bool); ok {
+		r0 = rf(ctx, id, filters)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.DeviceID, []model.FilterPredicate) error); ok {
+		r1 = rf(ctx, id, filters)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ExplainSearchDevices provides a mock function with given fields: ctx, searchParams
+func (_m *DataStore) ExplainSearchDevices(ctx context.Context, searchParams model.SearchParams) (*model.QueryPlan, error) {
+	ret := _m.Called(ctx, searchParams)
+
+	var r0 *model.QueryPlan
+	if rf, ok := ret.Get(0).(func(context.Context, model.SearchParams) *model.QueryPlan); ok {
+		r0 = rf(ctx, searchParams)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.QueryPlan)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.SearchParams) error); ok {
+		r1 = rf(ctx, searchParams)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAllAttributeNames provides a mock function with given fields: ctx
+func (_m *DataStore) GetAllAttributeNames(ctx context.Context) ([]string, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(context.Context) []string); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAllDeviceIDs provides a mock function with given fields: ctx
+func (_m *DataStore) GetAllDeviceIDs(ctx context.Context) ([]model.DeviceID, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []model.DeviceID
+	if rf, ok := ret.Get(0).(func(context.Context) []model.DeviceID); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.DeviceID)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAttributeAliases provides a mock function with given fields: ctx
+func (_m *DataStore) GetAttributeAliases(ctx context.Context) (model.AttributeAliases, error) {
+	ret := _m.Called(ctx)
+
+	var r0 model.AttributeAliases
+	if rf, ok := ret.Get(0).(func(context.Context) model.AttributeAliases); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(model.AttributeAliases)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAttributeDefinitions provides a mock function with given fields: ctx
+func (_m *DataStore) GetAttributeDefinitions(ctx context.Context) ([]model.AttributeDefinition, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []model.AttributeDefinition
+	if rf, ok := ret.Get(0).(func(context.Context) []model.AttributeDefinition); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.AttributeDefinition)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAttributeDescriptions provides a mock function with given fields: ctx
+func (_m *DataStore) GetAttributeDescriptions(ctx context.Context) (model.AttributeDescriptions, error) {
+	ret := _m.Called(ctx)
+
+	var r0 model.AttributeDescriptions
+	if rf, ok := ret.Get(0).(func(context.Context) model.AttributeDescriptions); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(model.AttributeDescriptions)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAttributeSnapshots provides a mock function with given fields: ctx, ids
+func (_m *DataStore) GetAttributeSnapshots(ctx context.Context, ids []model.DeviceID) ([]model.AttributeSnapshot, error) {
+	ret := _m.Called(ctx, ids)
+
+	var r0 []model.AttributeSnapshot
+	if rf, ok := ret.Get(0).(func(context.Context, []model.DeviceID) []model.AttributeSnapshot); ok {
+		r0 = rf(ctx, ids)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.AttributeSnapshot)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []model.DeviceID) error); ok {
+		r1 = rf(ctx, ids)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAttributeValueCounts provides a mock function with given fields: ctx, scope, name, limit
+func (_m *DataStore) GetAttributeValueCounts(ctx context.Context, scope string, name string, limit int) ([]model.AttributeValueCount, error) {
+	ret := _m.Called(ctx, scope, name, limit)
+
+	var r0 []model.AttributeValueCount
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, int) []model.AttributeValueCount); ok {
+		r0 = rf(ctx, scope, name, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.AttributeValueCount)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, int) error); ok {
+		r1 = rf(ctx, scope, name, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAttributeValueSets provides a mock function with given fields: ctx, scope, name, samples, limit
+func (_m *DataStore) GetAttributeValueSets(ctx context.Context, scope string, name string, samples int, limit int) ([]model.AttributeValueSet, error) {
+	ret := _m.Called(ctx, scope, name, samples, limit)
+
+	var r0 []model.AttributeValueSet
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, int, int) []model.AttributeValueSet); ok {
+		r0 = rf(ctx, scope, name, samples, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.AttributeValueSet)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, int, int) error); ok {
+		r1 = rf(ctx, scope, name, samples, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetCatalog provides a mock function with given fields: ctx
+func (_m *DataStore) GetCatalog(ctx context.Context) (*model.Catalog, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *model.Catalog
+	if rf, ok := ret.Get(0).(func(context.Context) *model.Catalog); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Catalog)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetColdAttributes provides a mock function with given fields: ctx, id
+func (_m *DataStore) GetColdAttributes(ctx context.Context, id model.DeviceID) (model.DeviceAttributes, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 model.DeviceAttributes
+	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceID) model.DeviceAttributes); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(model.DeviceAttributes)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.DeviceID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetCompletenessAlert provides a mock function with given fields: ctx
+func (_m *DataStore) GetCompletenessAlert(ctx context.Context) (*model.CompletenessAlert, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *model.CompletenessAlert
+	if rf, ok := ret.Get(0).(func(context.Context) *model.CompletenessAlert); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.CompletenessAlert)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeadLetter provides a mock function with given fields: ctx, id
+func (_m *DataStore) GetDeadLetter(ctx context.Context, id string) (*model.DeadLetter, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *model.DeadLetter
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.DeadLetter); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.DeadLetter)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeadLetters provides a mock function with given fields: ctx, skip, limit
+func (_m *DataStore) GetDeadLetters(ctx context.Context, skip int, limit int) ([]model.DeadLetter, int, error) {
+	ret := _m.Called(ctx, skip, limit)
+
+	var r0 []model.DeadLetter
+	if rf, ok := ret.Get(0).(func(context.Context, int, int) []model.DeadLetter); ok {
+		r0 = rf(ctx, skip, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.DeadLetter)
+		}
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, int, int) int); ok {
+		r1 = rf(ctx, skip, limit)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, int, int) error); ok {
+		r2 = rf(ctx, skip, limit)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// GetDevice provides a mock function with given fields: ctx, id
+func (_m *DataStore) GetDevice(ctx context.Context, id model.DeviceID) (*model.Device, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *model.Device
+	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceID) *model.Device); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Device)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.DeviceID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeviceChanges provides a mock function with given fields: ctx, fromSeq, limit
+func (_m *DataStore) GetDeviceChanges(ctx context.Context, fromSeq int64, limit int) ([]model.DeviceChange, error) {
+	ret := _m.Called(ctx, fromSeq, limit)
+
+	var r0 []model.DeviceChange
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int) []model.DeviceChange); ok {
+		r0 = rf(ctx, fromSeq, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.DeviceChange)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int64, int) error); ok {
+		r1 = rf(ctx, fromSeq, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeviceGroups provides a mock function with given fields: ctx, id
+func (_m *DataStore) GetDeviceGroups(ctx context.Context, id model.DeviceID) ([]model.GroupName, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 []model.GroupName
+	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceID) []model.GroupName); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.GroupName)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.DeviceID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeviceIDsByAttribute provides a mock function with given fields: ctx, scope, name, values
+func (_m *DataStore) GetDeviceIDsByAttribute(ctx context.Context, scope string, name string, values []string) (map[string][]model.DeviceID, error) {
+	ret := _m.Called(ctx, scope, name, values)
+
+	var r0 map[string][]model.DeviceID
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, []string) map[string][]model.DeviceID); ok {
+		r0 = rf(ctx, scope, name, values)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string][]model.DeviceID)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, []string) error); ok {
+		r1 = rf(ctx, scope, name, values)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDevices provides a mock function with given fields: ctx, q
+func (_m *DataStore) GetDevices(ctx context.Context, q store.ListQuery) ([]model.Device, int, error) {
+	ret := _m.Called(ctx, q)
+
+	var r0 []model.Device
+	if rf, ok := ret.Get(0).(func(context.Context, store.ListQuery) []model.Device); ok {
+		r0 = rf(ctx, q)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Device)
+		}
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, store.ListQuery) int); ok {
+		r1 = rf(ctx, q)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, store.ListQuery) error); ok {
+		r2 = rf(ctx, q)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// GetDevicesByGroup provides a mock function with given fields: ctx, group, q
+func (_m *DataStore) GetDevicesByGroup(ctx context.Context, group model.GroupName, q store.ListQuery) ([]model.DeviceID, int, error) {
+	ret := _m.Called(ctx, group, q)
+
+	var r0 []model.DeviceID
+	if rf, ok := ret.Get(0).(func(context.Context, model.GroupName, store.ListQuery) []model.DeviceID); ok {
+		r0 = rf(ctx, group, q)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.DeviceID)
+		}
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, model.GroupName, store.ListQuery) int); ok {
+		r1 = rf(ctx, group, q)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, model.GroupName, store.ListQuery) error); ok {
+		r2 = rf(ctx, group, q)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// GetDevicesByIDs provides a mock function with given fields: ctx, ids, attributes
+func (_m *DataStore) GetDevicesByIDs(ctx context.Context, ids []model.DeviceID, attributes []model.SelectAttribute) ([]model.Device, error) {
+	ret := _m.Called(ctx, ids, attributes)
+
+	var r0 []model.Device
+	if rf, ok := ret.Get(0).(func(context.Context, []model.DeviceID, []model.SelectAttribute) []model.Device); ok {
+		r0 = rf(ctx, ids, attributes)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Device)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []model.DeviceID, []model.SelectAttribute) error); ok {
+		r1 = rf(ctx, ids, attributes)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDevicesGroups provides a mock function with given fields: ctx, ids
+func (_m *DataStore) GetDevicesGroups(ctx context.Context, ids []model.DeviceID) (map[model.DeviceID][]model.GroupName, error) {
+	ret := _m.Called(ctx, ids)
+
+	var r0 map[model.DeviceID][]model.GroupName
+	if rf, ok := ret.Get(0).(func(context.Context, []model.DeviceID) map[model.DeviceID][]model.GroupName); ok {
+		r0 = rf(ctx, ids)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[model.DeviceID][]model.GroupName)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []model.DeviceID) error); ok {
+		r1 = rf(ctx, ids)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDigestSettings provides a mock function with given fields: ctx
+func (_m *DataStore) GetDigestSettings(ctx context.Context) (*model.DigestSettings, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *model.DigestSettings
+	if rf, ok := ret.Get(0).(func(context.Context) *model.DigestSettings); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.DigestSettings)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDynamicGroup provides a mock function with given fields: ctx, name
+func (_m *DataStore) GetDynamicGroup(ctx context.Context, name model.GroupName) (*model.DynamicGroup, error) {
+	ret := _m.Called(ctx, name)
+
+	var r0 *model.DynamicGroup
+	if rf, ok := ret.Get(0).(func(context.Context, model.GroupName) *model.DynamicGroup); ok {
+		r0 = rf(ctx, name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.DynamicGroup)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.GroupName) error); ok {
+		r1 = rf(ctx, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDynamicGroups provides a mock function with given fields: ctx
+func (_m *DataStore) GetDynamicGroups(ctx context.Context) ([]model.DynamicGroup, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []model.DynamicGroup
+	if rf, ok := ret.Get(0).(func(context.Context) []model.DynamicGroup); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.DynamicGroup)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetExportJob provides a mock function with given fields: ctx, id
+func (_m *DataStore) GetExportJob(ctx context.Context, id string) (*model.ExportJob, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *model.ExportJob
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.ExportJob); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ExportJob)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetExternalID provides a mock function with given fields: ctx, ref
+func (_m *DataStore) GetExternalID(ctx context.Context, ref model.ExternalIDRef) (*model.ExternalID, error) {
+	ret := _m.Called(ctx, ref)
+
+	var r0 *model.ExternalID
+	if rf, ok := ret.Get(0).(func(context.Context, model.ExternalIDRef) *model.ExternalID); ok {
+		r0 = rf(ctx, ref)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ExternalID)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.ExternalIDRef) error); ok {
+		r1 = rf(ctx, ref)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetFeatureFlags provides a mock function with given fields: ctx
+func (_m *DataStore) GetFeatureFlags(ctx context.Context) (model.FeatureFlagSet, error) {
+	ret := _m.Called(ctx)
+
+	var r0 model.FeatureFlagSet
+	if rf, ok := ret.Get(0).(func(context.Context) model.FeatureFlagSet); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(model.FeatureFlagSet)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetFiltersAttributes provides a mock function with given fields: ctx
+func (_m *DataStore) GetFiltersAttributes(ctx context.Context) ([]model.FilterAttribute, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []model.FilterAttribute
+	if rf, ok := ret.Get(0).(func(context.Context) []model.FilterAttribute); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.FilterAttribute)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetFleetDigest provides a mock function with given fields: ctx, from, to, silentAfter
+func (_m *DataStore) GetFleetDigest(ctx context.Context, from time.Time, to time.Time, silentAfter time.Duration) (*model.FleetDigest, error) {
+	ret := _m.Called(ctx, from, to, silentAfter)
+
+	var r0 *model.FleetDigest
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, time.Time, time.Duration) *model.FleetDigest); ok {
+		r0 = rf(ctx, from, to, silentAfter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.FleetDigest)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, time.Time, time.Duration) error); ok {
+		r1 = rf(ctx, from, to, silentAfter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetGroupMetadata provides a mock function with given fields: ctx, name
+func (_m *DataStore) GetGroupMetadata(ctx context.Context, name model.GroupName) (*model.GroupMetadata, error) {
+	ret := _m.Called(ctx, name)
+
+	var r0 *model.GroupMetadata
+	if rf, ok := ret.Get(0).(func(context.Context, model.GroupName) *model.GroupMetadata); ok {
+		r0 = rf(ctx, name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.GroupMetadata)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.GroupName) error); ok {
+		r1 = rf(ctx, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetGroupsCompleteness provides a mock function with given fields: ctx, required
+func (_m *DataStore) GetGroupsCompleteness(ctx context.Context, required []model.SelectAttribute) ([]model.GroupCompleteness, error) {
+	ret := _m.Called(ctx, required)
+
+	var r0 []model.GroupCompleteness
+	if rf, ok := ret.Get(0).(func(context.Context, []model.SelectAttribute) []model.GroupCompleteness); ok {
+		r0 = rf(ctx, required)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.GroupCompleteness)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []model.SelectAttribute) error); ok {
+		r1 = rf(ctx, required)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetGroupsMetadata provides a mock function with given fields: ctx
+func (_m *DataStore) GetGroupsMetadata(ctx context.Context) ([]model.GroupMetadata, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []model.GroupMetadata
+	if rf, ok := ret.Get(0).(func(context.Context) []model.GroupMetadata); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.GroupMetadata)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetIncompleteDevices provides a mock function with given fields: ctx, q
+func (_m *DataStore) GetIncompleteDevices(ctx context.Context, q store.IncompleteDevicesQuery) ([]model.Device, int, error) {
+	ret := _m.Called(ctx, q)
+
+	var r0 []model.Device
+	if rf, ok := ret.Get(0).(func(context.Context, store.IncompleteDevicesQuery) []model.Device); ok {
+		r0 = rf(ctx, q)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Device)
+		}
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, store.IncompleteDevicesQuery) int); ok {
+		r1 = rf(ctx, q)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, store.IncompleteDevicesQuery) error); ok {
+		r2 = rf(ctx, q)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// GetLimits provides a mock function with given fields: ctx
+func (_m *DataStore) GetLimits(ctx context.Context) (model.Limits, error) {
+	ret := _m.Called(ctx)
+
+	var r0 model.Limits
+	if rf, ok := ret.Get(0).(func(context.Context) model.Limits); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(model.Limits)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetPinnedAttributes provides a mock function with given fields: ctx
+func (_m *DataStore) GetPinnedAttributes(ctx context.Context) (model.PinnedAttributes, error) {
+	ret := _m.Called(ctx)
+
+	var r0 model.PinnedAttributes
+	if rf, ok := ret.Get(0).(func(context.Context) model.PinnedAttributes); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(model.PinnedAttributes)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSavedFilter provides a mock function with given fields: ctx, id
+func (_m *DataStore) GetSavedFilter(ctx context.Context, id string) (*model.SavedFilter, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *model.SavedFilter
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.SavedFilter); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.SavedFilter)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSavedFilters provides a mock function with given fields: ctx, q
+func (_m *DataStore) GetSavedFilters(ctx context.Context, q store.SavedFiltersQuery) ([]model.SavedFilter, error) {
+	ret := _m.Called(ctx, q)
+
+	var r0 []model.SavedFilter
+	if rf, ok := ret.Get(0).(func(context.Context, store.SavedFiltersQuery) []model.SavedFilter); ok {
+		r0 = rf(ctx, q)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.SavedFilter)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, store.SavedFiltersQuery) error); ok {
+		r1 = rf(ctx, q)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSchemaViolations provides a mock function with given fields: ctx, id, skip, limit
+func (_m *DataStore) GetSchemaViolations(ctx context.Context, id model.DeviceID, skip int, limit int) ([]model.SchemaViolation, int, error) {
+	ret := _m.Called(ctx, id, skip, limit)
+
+	var r0 []model.SchemaViolation
+	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceID, int, int) []model.SchemaViolation); ok {
+		r0 = rf(ctx, id, skip, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.SchemaViolation)
+		}
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, model.DeviceID, int, int) int); ok {
+		r1 = rf(ctx, id, skip, limit)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, model.DeviceID, int, int) error); ok {
+		r2 = rf(ctx, id, skip, limit)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// GetScopes provides a mock function with given fields: ctx
+func (_m *DataStore) GetScopes(ctx context.Context) ([]model.Scope, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []model.Scope
+	if rf, ok := ret.Get(0).(func(context.Context) []model.Scope); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Scope)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSubscriptions provides a mock function with given fields: ctx, userID
+func (_m *DataStore) GetSubscriptions(ctx context.Context, userID string) ([]model.Subscription, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 []model.Subscription
+	if rf, ok := ret.Get(0).(func(context.Context, string) []model.Subscription); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Subscription)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetValidationWebhook provides a mock function with given fields: ctx
+func (_m *DataStore) GetValidationWebhook(ctx context.Context) (*model.ValidationWebhook, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *model.ValidationWebhook
+	if rf, ok := ret.Get(0).(func(context.Context) *model.ValidationWebhook); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ValidationWebhook)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// InsertGroupsMetadata provides a mock function with given fields: ctx, groups
+func (_m *DataStore) InsertGroupsMetadata(ctx context.Context, groups []model.GroupMetadata) error {
+	ret := _m.Called(ctx, groups)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []model.GroupMetadata) error); ok {
+		r0 = rf(ctx, groups)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// InsertTimelineEvents provides a mock function with given fields: ctx, events
+func (_m *DataStore) InsertTimelineEvents(ctx context.Context, events []model.TimelineEvent) (int, error) {
+	ret := _m.Called(ctx, events)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, []model.TimelineEvent) int); ok {
+		r0 = rf(ctx, events)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []model.TimelineEvent) error); ok {
+		r1 = rf(ctx, events)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListGroups provides a mock function with given fields: ctx, filters
+func (_m *DataStore) ListGroups(ctx context.Context, filters []model.FilterPredicate) ([]model.GroupName, error) {
+	ret := _m.Called(ctx, filters)
+
+	var r0 []model.GroupName
+	if rf, ok := ret.Get(0).(func(context.Context, []model.FilterPredicate) []model.GroupName); ok {
+		r0 = rf(ctx, filters)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.GroupName)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []model.FilterPredicate) error); ok {
+		r1 = rf(ctx, filters)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListTenantDatabases provides a mock function with given fields: ctx
+func (_m *DataStore) ListTenantDatabases(ctx context.Context) ([]model.TenantDatabase, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []model.TenantDatabase
+	if rf, ok := ret.Get(0).(func(context.Context) []model.TenantDatabase); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.TenantDatabase)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListTenantIDs provides a mock function with given fields: ctx
+func (_m *DataStore) ListTenantIDs(ctx context.Context) ([]string, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(context.Context) []string); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Maintenance provides a mock function with given fields: ctx, version, tenantIDs
+func (_m *DataStore) Maintenance(ctx context.Context, version string, tenantIDs ...string) error {
+	_va := make([]interface{}, len(tenantIDs))
+	for _i := range tenantIDs {
+		_va[_i] = tenantIDs[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, version)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, ...string) error); ok {
+		r0 = rf(ctx, version, tenantIDs...)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MigrationReport provides a mock function with given fields: ctx, version, tenantIDs
+func (_m *DataStore) MigrationReport(ctx context.Context, version string, tenantIDs ...string) (*model.MigrationReport, error) {
+	_va := make([]interface{}, len(tenantIDs))
+	for _i := range tenantIDs {
+		_va[_i] = tenantIDs[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, version)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *model.MigrationReport
+	if rf, ok := ret.Get(0).(func(context.Context, string, ...string) *model.MigrationReport); ok {
+		r0 = rf(ctx, version, tenantIDs...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.MigrationReport)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, ...string) error); ok {
+		r1 = rf(ctx, version, tenantIDs...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Migrate provides a mock function with given fields: ctx, version
+func (_m *DataStore) Migrate(ctx context.Context, version string) error {
+	ret := _m.Called(ctx, version)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, version)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MigrateTenant provides a mock function with given fields: ctx, version, tenantId
+func (_m *DataStore) MigrateTenant(ctx context.Context, version string, tenantId string) error {
+	ret := _m.Called(ctx, version, tenantId)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, version, tenantId)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Ping provides a mock function with given fields: ctx
+func (_m *DataStore) Ping(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PreviewDevicesGroup provides a mock function with given fields: ctx, assignment, group
+func (_m *DataStore) PreviewDevicesGroup(ctx context.Context, assignment model.GroupAssignment, group model.GroupName) (*model.GroupAssignmentPreview, error) {
+	ret := _m.Called(ctx, assignment, group)
+
+	var r0 *model.GroupAssignmentPreview
+	if rf, ok := ret.Get(0).(func(context.Context, model.GroupAssignment, model.GroupName) *model.GroupAssignmentPreview); ok {
+		r0 = rf(ctx, assignment, group)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.GroupAssignmentPreview)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.GroupAssignment, model.GroupName) error); ok {
+		r1 = rf(ctx, assignment, group)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PurgeDeadLetters provides a mock function with given fields: ctx
+func (_m *DataStore) PurgeDeadLetters(ctx context.Context) (*model.UpdateResult, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *model.UpdateResult
+	if rf, ok := ret.Get(0).(func(context.Context) *model.UpdateResult); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UpdateResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RecordSchemaViolations provides a mock function with given fields: ctx, violations
+func (_m *DataStore) RecordSchemaViolations(ctx context.Context, violations []model.SchemaViolation) error {
+	ret := _m.Called(ctx, violations)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []model.SchemaViolation) error); ok {
+		r0 = rf(ctx, violations)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ReplaceDeviceAttributes provides a mock function with given fields: ctx, id, scope, attrs
+func (_m *DataStore) ReplaceDeviceAttributes(ctx context.Context, id model.DeviceID, scope string, attrs model.DeviceAttributes) (*model.UpdateResult, error) {
+	ret := _m.Called(ctx, id, scope, attrs)
+
+	var r0 *model.UpdateResult
+	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceID, string, model.DeviceAttributes) *model.UpdateResult); ok {
+		r0 = rf(ctx, id, scope, attrs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UpdateResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.DeviceID, string, model.DeviceAttributes) error); ok {
+		r1 = rf(ctx, id, scope, attrs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReplaceDevicesGroup provides a mock function with given fields: ctx, devIDs, group
+func (_m *DataStore) ReplaceDevicesGroup(ctx context.Context, devIDs []model.DeviceID, group model.GroupName) (*model.UpdateResult, []model.DeviceID, error) {
+	ret := _m.Called(ctx, devIDs, group)
+
+	var r0 *model.UpdateResult
+	if rf, ok := ret.Get(0).(func(context.Context, []model.DeviceID, model.GroupName) *model.UpdateResult); ok {
+		r0 = rf(ctx, devIDs, group)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UpdateResult)
+		}
+	}
+
+	var r1 []model.DeviceID
+	if rf, ok := ret.Get(1).(func(context.Context, []model.DeviceID, model.GroupName) []model.DeviceID); ok {
+		r1 = rf(ctx, devIDs, group)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([]model.DeviceID)
+		}
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, []model.DeviceID, model.GroupName) error); ok {
+		r2 = rf(ctx, devIDs, group)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// SaveAttributeSnapshots provides a mock function with given fields: ctx, snapshots
+func (_m *DataStore) SaveAttributeSnapshots(ctx context.Context, snapshots []model.AttributeSnapshot) error {
+	ret := _m.Called(ctx, snapshots)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []model.AttributeSnapshot) error); ok {
+		r0 = rf(ctx, snapshots)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveCatalog provides a mock function with given fields: ctx, catalog
+func (_m *DataStore) SaveCatalog(ctx context.Context, catalog model.Catalog) error {
+	ret := _m.Called(ctx, catalog)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.Catalog) error); ok {
+		r0 = rf(ctx, catalog)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SearchDevices provides a mock function with given fields: ctx, searchParams
+func (_m *DataStore) SearchDevices(ctx context.Context, searchParams model.SearchParams) ([]model.Device, int, error) {
+	ret := _m.Called(ctx, searchParams)
+
+	var r0 []model.Device
+	if rf, ok := ret.Get(0).(func(context.Context, model.SearchParams) []model.Device); ok {
+		r0 = rf(ctx, searchParams)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Device)
+		}
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, model.SearchParams) int); ok {
+		r1 = rf(ctx, searchParams)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, model.SearchParams) error); ok {
+		r2 = rf(ctx, searchParams)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// SearchGroups provides a mock function with given fields: ctx, q
+func (_m *DataStore) SearchGroups(ctx context.Context, q store.GroupsQuery) ([]model.GroupName, int, error) {
+	ret := _m.Called(ctx, q)
+
+	var r0 []model.GroupName
+	if rf, ok := ret.Get(0).(func(context.Context, store.GroupsQuery) []model.GroupName); ok {
+		r0 = rf(ctx, q)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.GroupName)
+		}
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, store.GroupsQuery) int); ok {
+		r1 = rf(ctx, q)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, store.GroupsQuery) error); ok {
+		r2 = rf(ctx, q)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// SetAttributeAliases provides a mock function with given fields: ctx, aliases
+func (_m *DataStore) SetAttributeAliases(ctx context.Context, aliases model.AttributeAliases) error {
+	ret := _m.Called(ctx, aliases)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.AttributeAliases) error); ok {
+		r0 = rf(ctx, aliases)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetAttributeDescriptions provides a mock function with given fields: ctx, descs
+func (_m *DataStore) SetAttributeDescriptions(ctx context.Context, descs model.AttributeDescriptions) error {
+	ret := _m.Called(ctx, descs)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.AttributeDescriptions) error); ok {
+		r0 = rf(ctx, descs)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetCompletenessAlert provides a mock function with given fields: ctx, alert
+func (_m *DataStore) SetCompletenessAlert(ctx context.Context, alert model.CompletenessAlert) error {
+	ret := _m.Called(ctx, alert)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.CompletenessAlert) error); ok {
+		r0 = rf(ctx, alert)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetDigestSettings provides a mock function with given fields: ctx, settings
+func (_m *DataStore) SetDigestSettings(ctx context.Context, settings model.DigestSettings) error {
+	ret := _m.Called(ctx, settings)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.DigestSettings) error); ok {
+		r0 = rf(ctx, settings)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetLimits provides a mock function with given fields: ctx, limits
+func (_m *DataStore) SetLimits(ctx context.Context, limits model.Limits) error {
+	ret := _m.Called(ctx, limits)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.Limits) error); ok {
+		r0 = rf(ctx, limits)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetPinnedAttributes provides a mock function with given fields: ctx, pinned
+func (_m *DataStore) SetPinnedAttributes(ctx context.Context, pinned model.PinnedAttributes) error {
+	ret := _m.Called(ctx, pinned)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.PinnedAttributes) error); ok {
+		r0 = rf(ctx, pinned)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetSubscriptionMatch provides a mock function with given fields: ctx, subID, id, matches
+func (_m *DataStore) SetSubscriptionMatch(ctx context.Context, subID string, id model.DeviceID, matches bool) (bool, error) {
+	ret := _m.Called(ctx, subID, id, matches)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string, model.DeviceID, bool) bool); ok {
+		r0 = rf(ctx, subID, id, matches)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, model.DeviceID, bool) error); ok {
+		r1 = rf(ctx, subID, id, matches)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetValidationWebhook provides a mock function with given fields: ctx, hook
+func (_m *DataStore) SetValidationWebhook(ctx context.Context, hook model.ValidationWebhook) error {
+	ret := _m.Called(ctx, hook)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.ValidationWebhook) error); ok {
+		r0 = rf(ctx, hook)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// StreamDevices provides a mock function with given fields: ctx, q
+func (_m *DataStore) StreamDevices(ctx context.Context, q store.ListQuery) (*store.DeviceStream, int, error) {
+	ret := _m.Called(ctx, q)
+
+	var r0 *store.DeviceStream
+	if rf, ok := ret.Get(0).(func(context.Context, store.ListQuery) *store.DeviceStream); ok {
+		r0 = rf(ctx, q)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*store.DeviceStream)
+		}
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, store.ListQuery) int); ok {
+		r1 = rf(ctx, q)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, store.ListQuery) error); ok {
+		r2 = rf(ctx, q)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// UnsetDevicesGroup provides a mock function with given fields: ctx, deviceIDs, group
+func (_m *DataStore) UnsetDevicesGroup(ctx context.Context, deviceIDs []model.DeviceID, group model.GroupName) (*model.UpdateResult, error) {
+	ret := _m.Called(ctx, deviceIDs, group)
+
+	var r0 *model.UpdateResult
+	if rf, ok := ret.Get(0).(func(context.Context, []model.DeviceID, model.GroupName) *model.UpdateResult); ok {
+		r0 = rf(ctx, deviceIDs, group)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UpdateResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []model.DeviceID, model.GroupName) error); ok {
+		r1 = rf(ctx, deviceIDs, group)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UnsetExpiredAttributes provides a mock function with given fields: ctx, scope, name, since
+func (_m *DataStore) UnsetExpiredAttributes(ctx context.Context, scope string, name string, since time.Time) (int64, error) {
+	ret := _m.Called(ctx, scope, name, since)
+
+	var r0 int64
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time) int64); ok {
+		r0 = rf(ctx, scope, name, since)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, time.Time) error); ok {
+		r1 = rf(ctx, scope, name, since)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateAttributeDescriptions provides a mock function with given fields: ctx, set, unset
+func (_m *DataStore) UpdateAttributeDescriptions(ctx context.Context, set model.AttributeDescriptions, unset model.AttributeDescriptions) error {
+	ret := _m.Called(ctx, set, unset)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.AttributeDescriptions, model.AttributeDescriptions) error); ok {
+		r0 = rf(ctx, set, unset)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateDeadLetter provides a mock function with given fields: ctx, letter
+func (_m *DataStore) UpdateDeadLetter(ctx context.Context, letter model.DeadLetter) error {
+	ret := _m.Called(ctx, letter)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.DeadLetter) error); ok {
+		r0 = rf(ctx, letter)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateDevicesGroup provides a mock function with given fields: ctx, devIDs, group
+func (_m *DataStore) UpdateDevicesGroup(ctx context.Context, devIDs []model.DeviceID, group model.GroupName) (*model.UpdateResult, error) {
+	ret := _m.Called(ctx, devIDs, group)
+
+	var r0 *model.UpdateResult
+	if rf, ok := ret.Get(0).(func(context.Context, []model.DeviceID, model.GroupName) *model.UpdateResult); ok {
+		r0 = rf(ctx, devIDs, group)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UpdateResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []model.DeviceID, model.GroupName) error); ok {
+		r1 = rf(ctx, devIDs, group)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateDevicesGroupByFilter provides a mock function with given fields: ctx, params, group
+func (_m *DataStore) UpdateDevicesGroupByFilter(ctx context.Context, params model.SearchParams, group model.GroupName) (*model.UpdateResult, []model.DeviceID, error) {
+	ret := _m.Called(ctx, params, group)
+
+	var r0 *model.UpdateResult
+	if rf, ok := ret.Get(0).(func(context.Context, model.SearchParams, model.GroupName) *model.UpdateResult); ok {
+		r0 = rf(ctx, params, group)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UpdateResult)
+		}
+	}
+
+	var r1 []model.DeviceID
+	if rf, ok := ret.Get(1).(func(context.Context, model.SearchParams, model.GroupName) []model.DeviceID); ok {
+		r1 = rf(ctx, params, group)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([]model.DeviceID)
+		}
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, model.SearchParams, model.GroupName) error); ok {
+		r2 = rf(ctx, params, group)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// UpdateDevicesTags provides a mock function with given fields: ctx, params, set, unset
+func (_m *DataStore) UpdateDevicesTags(ctx context.Context, params model.SearchParams, set model.DeviceAttributes, unset model.DeviceAttributes) (*model.UpdateResult, []model.DeviceID, error) {
+	ret := _m.Called(ctx, params, set, unset)
+
+	var r0 *model.UpdateResult
+	if rf, ok := ret.Get(0).(func(context.Context, model.SearchParams, model.DeviceAttributes, model.DeviceAttributes) *model.UpdateResult); ok {
+		r0 = rf(ctx, params, set, unset)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UpdateResult)
+		}
+	}
+
+	var r1 []model.DeviceID
+	if rf, ok := ret.Get(1).(func(context.Context, model.SearchParams, model.DeviceAttributes, model.DeviceAttributes) []model.DeviceID); ok {
+		r1 = rf(ctx, params, set, unset)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([]model.DeviceID)
+		}
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, model.SearchParams, model.DeviceAttributes, model.DeviceAttributes) error); ok {
+		r2 = rf(ctx, params, set, unset)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// UpdateExportJob provides a mock function with given fields: ctx, job
+func (_m *DataStore) UpdateExportJob(ctx context.Context, job model.ExportJob) error {
+	ret := _m.Called(ctx, job)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.ExportJob) error); ok {
+		r0 = rf(ctx, job)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateFeatureFlags provides a mock function with given fields: ctx, update
+func (_m *DataStore) UpdateFeatureFlags(ctx context.Context, update model.FeatureFlagsUpdate) error {
+	ret := _m.Called(ctx, update)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.FeatureFlagsUpdate) error); ok {
+		r0 = rf(ctx, update)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpsertAttributeDefinition provides a mock function with given fields: ctx, def
+func (_m *DataStore) UpsertAttributeDefinition(ctx context.Context, def model.AttributeDefinition) error {
+	ret := _m.Called(ctx, def)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.AttributeDefinition) error); ok {
+		r0 = rf(ctx, def)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpsertDevicesAttributes provides a mock function with given fields: ctx, ids, attrs
+func (_m *DataStore) UpsertDevicesAttributes(ctx context.Context, ids []model.DeviceID, attrs model.DeviceAttributes) (*model.UpdateResult, error) {
+	ret := _m.Called(ctx, ids, attrs)
+
+	var r0 *model.UpdateResult
+	if rf, ok := ret.Get(0).(func(context.Context, []model.DeviceID, model.DeviceAttributes) *model.UpdateResult); ok {
+		r0 = rf(ctx, ids, attrs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UpdateResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []model.DeviceID, model.DeviceAttributes) error); ok {
+		r1 = rf(ctx, ids, attrs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpsertDevicesAttributesWithRevision provides a mock function with given fields: ctx, ids, attrs
+func (_m *DataStore) UpsertDevicesAttributesWithRevision(ctx context.Context, ids []model.DeviceUpdate, attrs model.DeviceAttributes) (*model.UpdateResult, error) {
+	ret := _m.Called(ctx, ids, attrs)
+
+	var r0 *model.UpdateResult
+	if rf, ok := ret.Get(0).(func(context.Context, []model.DeviceUpdate, model.DeviceAttributes) *model.UpdateResult); ok {
+		r0 = rf(ctx, ids, attrs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UpdateResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []model.DeviceUpdate, model.DeviceAttributes) error); ok {
+		r1 = rf(ctx, ids, attrs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpsertDevicesAttributesWithUpdated provides a mock function with given fields: ctx, ids, attrs
+func (_m *DataStore) UpsertDevicesAttributesWithUpdated(ctx context.Context, ids []model.DeviceID, attrs model.DeviceAttributes) (*model.UpdateResult, error) {
+	ret := _m.Called(ctx, ids, attrs)
+
+	var r0 *model.UpdateResult
 	if rf, ok := ret.Get(0).(func(context.Context, []model.DeviceID, model.DeviceAttributes) *model.UpdateResult); ok {
 		r0 = rf(ctx, ids, attrs)
 	} else {
@@ -448,6 +2337,71 @@ func (_m *DataStore) UpsertDevicesAttributesWithUpdated(ctx context.Context, ids
 	return r0, r1
 }
 
+// UpsertDynamicGroup provides a mock function with given fields: ctx, group
+func (_m *DataStore) UpsertDynamicGroup(ctx context.Context, group model.DynamicGroup) error {
+	ret := _m.Called(ctx, group)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.DynamicGroup) error); ok {
+		r0 = rf(ctx, group)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpsertExternalIDs provides a mock function with given fields: ctx, ids
+func (_m *DataStore) UpsertExternalIDs(ctx context.Context, ids []model.ExternalID) (*model.UpdateResult, error) {
+	ret := _m.Called(ctx, ids)
+
+	var r0 *model.UpdateResult
+	if rf, ok := ret.Get(0).(func(context.Context, []model.ExternalID) *model.UpdateResult); ok {
+		r0 = rf(ctx, ids)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UpdateResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []model.ExternalID) error); ok {
+		r1 = rf(ctx, ids)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpsertGroupMetadata provides a mock function with given fields: ctx, group
+func (_m *DataStore) UpsertGroupMetadata(ctx context.Context, group model.GroupMetadata) error {
+	ret := _m.Called(ctx, group)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.GroupMetadata) error); ok {
+		r0 = rf(ctx, group)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpsertRemoveColdAttributes provides a mock function with given fields: ctx, id, updateAttrs, removeAttrs
+func (_m *DataStore) UpsertRemoveColdAttributes(ctx context.Context, id model.DeviceID, updateAttrs model.DeviceAttributes, removeAttrs model.DeviceAttributes) error {
+	ret := _m.Called(ctx, id, updateAttrs, removeAttrs)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.DeviceID, model.DeviceAttributes, model.DeviceAttributes) error); ok {
+		r0 = rf(ctx, id, updateAttrs, removeAttrs)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UpsertRemoveDeviceAttributes provides a mock function with given fields: ctx, id, updateAttrs, removeAttrs
 func (_m *DataStore) UpsertRemoveDeviceAttributes(ctx context.Context, id model.DeviceID, updateAttrs model.DeviceAttributes, removeAttrs model.DeviceAttributes) (*model.UpdateResult, error) {
 	ret := _m.Called(ctx, id, updateAttrs, removeAttrs)
@@ -471,6 +2425,62 @@ func (_m *DataStore) UpsertRemoveDeviceAttributes(ctx context.Context, id model.
 	return r0, r1
 }
 
+// UpsertSavedFilter provides a mock function with given fields: ctx, filter
+func (_m *DataStore) UpsertSavedFilter(ctx context.Context, filter model.SavedFilter) error {
+	ret := _m.Called(ctx, filter)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.SavedFilter) error); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpsertScope provides a mock function with given fields: ctx, scope
+func (_m *DataStore) UpsertScope(ctx context.Context, scope model.Scope) error {
+	ret := _m.Called(ctx, scope)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.Scope) error); ok {
+		r0 = rf(ctx, scope)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpsertSubscription provides a mock function with given fields: ctx, sub
+func (_m *DataStore) UpsertSubscription(ctx context.Context, sub model.Subscription) error {
+	ret := _m.Called(ctx, sub)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.Subscription) error); ok {
+		r0 = rf(ctx, sub)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// WatchDevices provides a mock function with given fields: ctx, handler
+func (_m *DataStore) WatchDevices(ctx context.Context, handler store.DeviceChangeHandler) error {
+	ret := _m.Called(ctx, handler)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, store.DeviceChangeHandler) error); ok {
+		r0 = rf(ctx, handler)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // WithAutomigrate provides a mock function with given fields:
 func (_m *DataStore) WithAutomigrate() store.DataStore {
 	ret := _m.Called()
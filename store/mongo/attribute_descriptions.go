@@ -0,0 +1,120 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	mopts "go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+// attributeDescriptionsDoc is the settings document of the catalog of the
+// descriptions, keyed like the attributes of the devices so that each
+// description is updated in place.
+type attributeDescriptionsDoc struct {
+	ID           string                                `bson:"_id"`
+	Descriptions map[string]model.AttributeDescription `bson:"descriptions"`
+}
+
+func attributeDescriptionKey(desc model.AttributeDescription) string {
+	return fmt.Sprintf("%s-%s", desc.Scope,
+		model.GetDeviceAttributeNameReplacer().Replace(desc.Name))
+}
+
+func (db *DataStoreMongo) GetAttributeDescriptions(
+	ctx context.Context,
+) (model.AttributeDescriptions, error) {
+	c := db.database(ctx).
+		Collection(DbSettingsColl)
+
+	var doc attributeDescriptionsDoc
+	err := c.FindOne(ctx, bson.M{DbDevId: DbSettingsAttributeDescriptions}).
+		Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return model.AttributeDescriptions{}, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to get attribute descriptions")
+	}
+	descs := make(model.AttributeDescriptions, 0, len(doc.Descriptions))
+	for _, desc := range doc.Descriptions {
+		descs = append(descs, desc)
+	}
+	descs.Sort()
+	return descs, nil
+}
+
+func (db *DataStoreMongo) SetAttributeDescriptions(
+	ctx context.Context,
+	descs model.AttributeDescriptions,
+) error {
+	c := db.database(ctx).
+		Collection(DbSettingsColl)
+
+	doc := attributeDescriptionsDoc{
+		ID:           DbSettingsAttributeDescriptions,
+		Descriptions: make(map[string]model.AttributeDescription, len(descs)),
+	}
+	for _, desc := range descs {
+		doc.Descriptions[attributeDescriptionKey(desc)] = desc
+	}
+	_, err := c.ReplaceOne(ctx,
+		bson.M{DbDevId: DbSettingsAttributeDescriptions}, doc,
+		mopts.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to set attribute descriptions")
+	}
+	return nil
+}
+
+func (db *DataStoreMongo) UpdateAttributeDescriptions(
+	ctx context.Context,
+	set, unset model.AttributeDescriptions,
+) error {
+	if len(set) == 0 && len(unset) == 0 {
+		return nil
+	}
+	c := db.database(ctx).
+		Collection(DbSettingsColl)
+
+	update := bson.M{}
+	if len(set) > 0 {
+		fields := make(bson.M, len(set))
+		for _, desc := range set {
+			fields[DbSettingsDescriptions+"."+attributeDescriptionKey(desc)] = desc
+		}
+		update["$set"] = fields
+	}
+	if len(unset) > 0 {
+		fields := make(bson.M, len(unset))
+		for _, desc := range unset {
+			fields[DbSettingsDescriptions+"."+attributeDescriptionKey(desc)] = ""
+		}
+		update["$unset"] = fields
+	}
+	_, err := c.UpdateOne(ctx,
+		bson.M{DbDevId: DbSettingsAttributeDescriptions}, update,
+		mopts.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to update attribute descriptions")
+	}
+	return nil
+}
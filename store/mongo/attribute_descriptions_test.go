@@ -0,0 +1,71 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package mongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+func TestAttributeDescriptions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestAttributeDescriptions in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+
+	descs, err := ds.GetAttributeDescriptions(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, descs)
+
+	assert.NoError(t, ds.SetAttributeDescriptions(ctx, model.AttributeDescriptions{
+		{Scope: "inventory", Name: "os", Description: "Operating system"},
+		{Scope: "inventory", Name: "cpu.model", Description: "CPU model"},
+		{Scope: "identity", Name: "mac", Description: "MAC address"},
+	}))
+	descs, err = ds.GetAttributeDescriptions(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, model.AttributeDescriptions{
+		{Scope: "identity", Name: "mac", Description: "MAC address"},
+		{Scope: "inventory", Name: "cpu.model", Description: "CPU model"},
+		{Scope: "inventory", Name: "os", Description: "Operating system"},
+	}, descs)
+
+	assert.NoError(t, ds.UpdateAttributeDescriptions(ctx,
+		model.AttributeDescriptions{
+			{Scope: "inventory", Name: "os", Description: "OS of the device"},
+			{Scope: "inventory", Name: "kernel", Description: "Kernel version"},
+		},
+		model.AttributeDescriptions{
+			{Scope: "inventory", Name: "cpu.model"},
+		},
+	))
+	descs, err = ds.GetAttributeDescriptions(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, model.AttributeDescriptions{
+		{Scope: "identity", Name: "mac", Description: "MAC address"},
+		{Scope: "inventory", Name: "kernel", Description: "Kernel version"},
+		{Scope: "inventory", Name: "os", Description: "OS of the device"},
+	}, descs)
+
+	assert.NoError(t, ds.SetAttributeDescriptions(ctx, nil))
+	descs, err = ds.GetAttributeDescriptions(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, descs)
+}
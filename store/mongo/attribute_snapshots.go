@@ -0,0 +1,72 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	mopts "go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+// DbAttributeSnapshotsColl holds the key attributes of the devices as of
+// their last finished deployment.
+const DbAttributeSnapshotsColl = "attribute_snapshots"
+
+func (db *DataStoreMongo) GetAttributeSnapshots(
+	ctx context.Context,
+	ids []model.DeviceID,
+) ([]model.AttributeSnapshot, error) {
+	c := db.database(ctx).
+		Collection(DbAttributeSnapshotsColl)
+
+	cur, err := db.find(ctx, c, bson.M{DbDevId: bson.M{"$in": ids}})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get attribute snapshots")
+	}
+	snapshots := []model.AttributeSnapshot{}
+	if err = decodeAll(ctx, cur, &snapshots); err != nil {
+		return nil, errors.Wrap(err, "failed to get attribute snapshots")
+	}
+	return snapshots, nil
+}
+
+func (db *DataStoreMongo) SaveAttributeSnapshots(
+	ctx context.Context,
+	snapshots []model.AttributeSnapshot,
+) error {
+	if len(snapshots) == 0 {
+		return nil
+	}
+	c := db.database(ctx).
+		Collection(DbAttributeSnapshotsColl)
+
+	models := make([]mongo.WriteModel, len(snapshots))
+	for n, snapshot := range snapshots {
+		models[n] = mongo.NewReplaceOneModel().
+			SetFilter(bson.M{DbDevId: snapshot.DeviceID}).
+			SetReplacement(snapshot).
+			SetUpsert(true)
+	}
+	_, err := c.BulkWrite(ctx, models, mopts.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return errors.Wrap(err, "failed to save attribute snapshots")
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	mopts "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// cleanupTimeout bounds the commands releasing the server resources of
+// the operations whose context is canceled.
+const cleanupTimeout = 5 * time.Second
+
+// maxTime returns the time left until the deadline of the context, to be
+// set as the maxTimeMS of the operations, so that the server gives up on
+// them as well; zero if the context has no deadline.
+func maxTime(ctx context.Context) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0
+	}
+	if left := time.Until(deadline); left > time.Millisecond {
+		return left
+	}
+	// zero would disable the limit
+	return time.Millisecond
+}
+
+// closeCursor closes the cursor with a context of its own: closing it
+// with the canceled context of the request would skip the killCursors
+// command and leave the cursor open on the server until it times out.
+func closeCursor(cur *mongo.Cursor) {
+	cursors.untrack(cur)
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+	_ = cur.Close(ctx)
+}
+
+// decodeAll decodes the documents of the cursor into results, a pointer
+// to a slice, and closes the cursor; it replaces Cursor.All, which closes
+// the cursor with the context it is given.
+func decodeAll(ctx context.Context, cur *mongo.Cursor, results interface{}) error {
+	resultsVal := reflect.ValueOf(results)
+	if resultsVal.Kind() != reflect.Ptr || resultsVal.Elem().Kind() != reflect.Slice {
+		closeCursor(cur)
+		return errors.Errorf("results must be a pointer to a slice, got %T", results)
+	}
+	sliceVal := resultsVal.Elem().Slice(0, 0)
+	elemType := sliceVal.Type().Elem()
+	err := iterateCursor(ctx, cur, func(cur *mongo.Cursor) error {
+		elem := reflect.New(elemType)
+		if err := cur.Decode(elem.Interface()); err != nil {
+			return err
+		}
+		sliceVal = reflect.Append(sliceVal, elem.Elem())
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	resultsVal.Elem().Set(sliceVal)
+	return nil
+}
+
+// watchCancel calls onCancel, in the background, if the context is done
+// before stop is called; stop waits for onCancel to return, so that no
+// goroutine outlives the operation.
+func watchCancel(ctx context.Context, onCancel func()) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			onCancel()
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+		<-exited
+	}
+}
+
+// aggregate runs the aggregation, limited to the deadline of the context,
+// and tracks the cursor under the name of the caller.
+// The driver only stops waiting for the reply when the context is
+// canceled, e.g. by a client disconnecting, so the aggregation is tagged
+// with a unique comment and killed on the server.
+func (db *DataStoreMongo) aggregate(
+	ctx context.Context,
+	c *mongo.Collection,
+	pipeline interface{},
+	opts ...*mopts.AggregateOptions,
+) (*mongo.Cursor, error) {
+	comment := primitive.NewObjectID().Hex()
+	aggOpts := mopts.Aggregate().SetComment(comment)
+	if d := maxTime(ctx); d > 0 {
+		aggOpts.SetMaxTime(d)
+	}
+	stop := watchCancel(ctx, func() {
+		db.killOps(log.FromContext(ctx), comment)
+	})
+	defer stop()
+	cur, err := c.Aggregate(ctx, pipeline, append(opts, aggOpts)...)
+	if err != nil {
+		return nil, err
+	}
+	cursors.track(ctx, cur, callerName(1))
+	return cur, nil
+}
+
+// killOps kills the operations in progress tagged with the comment.
+func (db *DataStoreMongo) killOps(l *log.Logger, comment string) {
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+
+	admin := db.client.Database("admin")
+	var res struct {
+		InProg []struct {
+			// int on the replica sets, "<shard>:<opid>" on mongos
+			OpID interface{} `bson:"opid"`
+		} `bson:"inprog"`
+	}
+	err := admin.RunCommand(ctx, bson.D{
+		{Key: "currentOp", Value: 1},
+		{Key: "command.comment", Value: comment},
+	}).Decode(&res)
+	if err != nil {
+		l.Warnf("failed to look up the canceled operation %s: %v", comment, err)
+		return
+	}
+	for _, op := range res.InProg {
+		err := admin.RunCommand(ctx, bson.D{
+			{Key: "killOp", Value: 1},
+			{Key: "op", Value: op.OpID},
+		}).Err()
+		if err != nil {
+			l.Warnf("failed to kill the canceled operation %s: %v", comment, err)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+)
+
+func TestMaxTime(t *testing.T) {
+	t.Parallel()
+
+	assert.Zero(t, maxTime(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	d := maxTime(ctx)
+	assert.True(t, d > 59*time.Second && d <= time.Minute, d)
+
+	ctx, cancel = context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	assert.Equal(t, time.Millisecond, maxTime(ctx))
+}
+
+func TestWatchCancel(t *testing.T) {
+	t.Parallel()
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		called := make(chan struct{})
+		stop := watchCancel(ctx, func() { close(called) })
+		cancel()
+		select {
+		case <-called:
+		case <-time.After(5 * time.Second):
+			t.Fatal("onCancel not called")
+		}
+		stop()
+	})
+
+	t.Run("stopped", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		calls := 0
+		stop := watchCancel(ctx, func() { calls++ })
+		// stop waits for the goroutine to exit
+		stop()
+		cancel()
+		assert.Zero(t, calls)
+	})
+
+	t.Run("not cancelable", func(t *testing.T) {
+		stop := watchCancel(context.Background(), func() {
+			t.Error("onCancel called")
+		})
+		stop()
+	})
+}
+
+// openCursors returns the number of cursors open on the server.
+func openCursors(t *testing.T) int64 {
+	var status struct {
+		Metrics struct {
+			Cursor struct {
+				Open struct {
+					Total int64 `bson:"total"`
+				} `bson:"open"`
+			} `bson:"cursor"`
+		} `bson:"metrics"`
+	}
+	err := db.Client().Database("admin").
+		RunCommand(db.CTX(), bson.M{"serverStatus": 1}).
+		Decode(&status)
+	require.NoError(t, err)
+	return status.Metrics.Cursor.Open.Total
+}
+
+func TestMongoCanceledOperations(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoCanceledOperations in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client()).(*DataStoreMongo)
+	ctx := db.CTX()
+
+	// more than the first batch of a cursor
+	for i := 0; i < 300; i++ {
+		err := ds.AddDevice(ctx, &model.Device{
+			ID:    model.DeviceID(fmt.Sprintf("%03d", i)),
+			Group: model.GroupName(fmt.Sprintf("group%d", i%3)),
+		})
+		require.NoError(t, err, "failed to setup input data")
+	}
+	before := openCursors(t)
+
+	t.Run("stream", func(t *testing.T) {
+		reqCtx, cancel := context.WithCancel(ctx)
+		stream, _, err := ds.StreamDevices(reqCtx, store.ListQuery{})
+		require.NoError(t, err)
+		<-stream.Devices()
+		cancel()
+		stream.Close()
+		assert.Equal(t, before, openCursors(t))
+	})
+
+	t.Run("aggregation", func(t *testing.T) {
+		reqCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err := ds.GetAllAttributeNames(reqCtx)
+		assert.Error(t, err)
+		assert.Equal(t, before, openCursors(t))
+	})
+
+	t.Run("decode all", func(t *testing.T) {
+		reqCtx, cancel := context.WithCancel(ctx)
+		cur, err := ds.database(ctx).
+			Collection(ds.names.Devices).Find(reqCtx, bson.M{})
+		require.NoError(t, err)
+		cancel()
+		var devices []model.Device
+		err = decodeAll(reqCtx, cur, &devices)
+		assert.Error(t, err)
+		assert.Equal(t, before, openCursors(t))
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		reqCtx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+		cur, err := ds.aggregate(reqCtx,
+			ds.database(ctx).Collection(ds.names.Devices),
+			[]bson.M{{"$count": "count"}},
+		)
+		require.NoError(t, err)
+		var res []struct {
+			Count int `bson:"count"`
+		}
+		assert.NoError(t, decodeAll(reqCtx, cur, &res))
+		assert.Equal(t, 300, res[0].Count)
+	})
+}
@@ -0,0 +1,111 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	mopts "go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+const (
+	// DbCatalogColl holds the precomputed catalog of the tenant, as
+	// a single document.
+	DbCatalogColl = "catalog"
+	dbCatalogID   = "catalog"
+)
+
+func (db *DataStoreMongo) CountDevicesByGroup(
+	ctx context.Context,
+	filters []model.FilterPredicate,
+) ([]model.GroupCount, error) {
+	const DbCount = "count"
+	c := db.database(ctx).
+		Collection(db.names.Devices)
+
+	groupsField := db.groupsField(ctx)
+	match, err := groupsQuery(groupsField, filters)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := db.aggregate(ctx, c, []bson.M{
+		{
+			"$match": match,
+		},
+		{
+			// a no-op for the single group format
+			"$unwind": "$" + groupsField,
+		},
+		{
+			"$group": bson.M{
+				DbDevId: "$" + groupsField,
+				DbCount: bson.M{"$sum": 1},
+			},
+		},
+		{
+			"$sort": bson.M{DbDevId: 1},
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to count devices by group")
+	}
+	counts := []model.GroupCount{}
+	if err = decodeAll(ctx, cur, &counts); err != nil {
+		return nil, errors.Wrap(err, "failed to count devices by group")
+	}
+	return counts, nil
+}
+
+func (db *DataStoreMongo) CountDevices(ctx context.Context) (int, error) {
+	c := db.database(ctx).
+		Collection(db.names.Devices)
+
+	count, err := c.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return -1, errors.Wrap(err, "failed to count devices")
+	}
+	return int(count), nil
+}
+
+func (db *DataStoreMongo) GetCatalog(ctx context.Context) (*model.Catalog, error) {
+	c := db.database(ctx).
+		Collection(DbCatalogColl)
+
+	var catalog model.Catalog
+	err := c.FindOne(ctx, bson.M{DbDevId: dbCatalogID}).Decode(&catalog)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to get catalog")
+	}
+	return &catalog, nil
+}
+
+func (db *DataStoreMongo) SaveCatalog(ctx context.Context, catalog model.Catalog) error {
+	c := db.database(ctx).
+		Collection(DbCatalogColl)
+
+	_, err := c.ReplaceOne(ctx, bson.M{DbDevId: dbCatalogID}, catalog,
+		mopts.Replace().SetUpsert(true))
+	if err != nil {
+		return errors.Wrap(err, "failed to save catalog")
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	mopts "go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+// DbColdAttributesColl holds the attributes of the cold scopes, one
+// document per device keyed by the device ID, laid out as the attributes
+// of the device documents.
+const DbColdAttributesColl = "cold_attributes"
+
+func (db *DataStoreMongo) GetColdAttributes(
+	ctx context.Context,
+	id model.DeviceID,
+) (model.DeviceAttributes, error) {
+	var doc struct {
+		Attributes model.DeviceAttributes `bson:"attributes"`
+	}
+	err := db.database(ctx).
+		Collection(DbColdAttributesColl).
+		FindOne(ctx, bson.M{DbDevId: id}).
+		Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return model.DeviceAttributes{}, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to get cold attributes")
+	}
+	return doc.Attributes, nil
+}
+
+func (db *DataStoreMongo) UpsertRemoveColdAttributes(
+	ctx context.Context,
+	id model.DeviceID,
+	updateAttrs model.DeviceAttributes,
+	removeAttrs model.DeviceAttributes,
+) error {
+	if len(updateAttrs) == 0 && len(removeAttrs) == 0 {
+		return nil
+	}
+	compressed, err := compressAttributes(updateAttrs, db.compressThreshold)
+	if err != nil {
+		return err
+	}
+	set, err := makeAttrUpsert(compressed)
+	if err != nil {
+		return err
+	}
+	remove, err := makeAttrRemove(removeAttrs)
+	if err != nil {
+		return err
+	}
+	setAttrTimestamps(set, time.Now(), updateAttrs)
+
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(remove) > 0 {
+		update["$unset"] = remove
+	}
+	_, err = db.database(ctx).
+		Collection(DbColdAttributesColl).
+		UpdateOne(ctx, bson.M{DbDevId: id}, update,
+			mopts.Update().SetUpsert(true))
+	if err != nil {
+		return errors.Wrap(err, "failed to update cold attributes")
+	}
+	return nil
+}
+
+// compressAttributes returns the copies of the attributes with the string
+// values longer than the threshold compressed. Only the attributes of the
+// cold scopes are compressed: they are neither filtered nor aggregated,
+// which cannot see through the compressed values.
+func compressAttributes(
+	attrs model.DeviceAttributes,
+	threshold int,
+) (model.DeviceAttributes, error) {
+	compressed := make(model.DeviceAttributes, len(attrs))
+	for i, attr := range attrs {
+		value, err := model.CompressAttrValue(attr.Value, threshold)
+		if err != nil {
+			return nil, err
+		}
+		attr.Value = value
+		compressed[i] = attr
+	}
+	return compressed, nil
+}
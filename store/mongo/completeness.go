@@ -0,0 +1,219 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	mopts "go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+)
+
+// completenessExpr returns the aggregation expression computing the
+// fraction of the required attributes reported by the device.
+func completenessExpr(required []model.SelectAttribute) interface{} {
+	if len(required) == 0 {
+		return 1.0
+	}
+	reported := make(bson.A, len(required))
+	for i, attr := range required {
+		reported[i] = bson.M{"$cond": bson.A{
+			bson.M{"$gt": bson.A{
+				"$" + makeAttrField(attr.Attribute, attr.Scope), nil,
+			}}, 1, 0,
+		}}
+	}
+	return bson.M{"$divide": bson.A{
+		bson.M{"$add": reported}, float64(len(required)),
+	}}
+}
+
+// completenessQuery returns the query predicate selecting the devices
+// whose completeness is in the range.
+func completenessQuery(
+	r model.CompletenessRange,
+	required []model.SelectAttribute,
+) bson.M {
+	expr := completenessExpr(required)
+	bounds := bson.A{}
+	if r.Min != nil {
+		bounds = append(bounds, bson.M{"$gte": bson.A{expr, *r.Min}})
+	}
+	if r.Max != nil {
+		bounds = append(bounds, bson.M{"$lte": bson.A{expr, *r.Max}})
+	}
+	return bson.M{"$expr": bson.M{"$and": bounds}}
+}
+
+func (db *DataStoreMongo) GetGroupsCompleteness(
+	ctx context.Context,
+	required []model.SelectAttribute,
+) ([]model.GroupCompleteness, error) {
+	const (
+		dbGroup        = "group"
+		dbScore        = "score"
+		dbDevices      = "devices"
+		dbComplete     = "complete"
+		dbCompleteness = "completeness"
+	)
+	c := db.database(ctx).
+		Collection(db.names.Devices)
+
+	cur, err := db.aggregate(ctx, c, []bson.M{
+		{
+			"$project": bson.M{
+				dbGroup: "$" + db.groupsField(ctx),
+				dbScore: completenessExpr(required),
+			},
+		},
+		{
+			// the devices count in each of their groups
+			"$unwind": bson.M{
+				"path":                       "$" + dbGroup,
+				"preserveNullAndEmptyArrays": true,
+			},
+		},
+		{
+			"$group": bson.M{
+				DbDevId:   "$" + dbGroup,
+				dbDevices: bson.M{"$sum": 1},
+				dbComplete: bson.M{"$sum": bson.M{"$cond": bson.A{
+					bson.M{"$gte": bson.A{"$" + dbScore, 1}}, 1, 0,
+				}}},
+				dbCompleteness: bson.M{"$avg": "$" + dbScore},
+			},
+		},
+		{
+			"$sort": bson.M{DbDevId: 1},
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to aggregate completeness")
+	}
+	groups := []model.GroupCompleteness{}
+	if err = decodeAll(ctx, cur, &groups); err != nil {
+		return nil, errors.Wrap(err, "failed to aggregate completeness")
+	}
+	return groups, nil
+}
+
+func (db *DataStoreMongo) GetIncompleteDevices(
+	ctx context.Context,
+	q store.IncompleteDevicesQuery,
+) ([]model.Device, int, error) {
+	const createdField = DbDevAttributes + "." + model.AttrScopeSystem +
+		"-" + model.AttrNameCreated + "." + DbDevAttributesValue
+	if len(q.Required) == 0 {
+		return []model.Device{}, 0, nil
+	}
+	c := db.database(ctx).
+		Collection(db.names.Devices)
+
+	missing := make(bson.A, len(q.Required))
+	for i, attr := range q.Required {
+		missing[i] = bson.M{
+			makeAttrField(attr.Attribute, attr.Scope): bson.M{"$exists": false},
+		}
+	}
+	filter := bson.M{"$or": missing}
+	created := bson.M{}
+	if q.CreatedFrom != nil {
+		created["$gte"] = *q.CreatedFrom
+	}
+	if q.CreatedUntil != nil {
+		created["$lt"] = *q.CreatedUntil
+	}
+	if len(created) > 0 {
+		filter[createdField] = created
+	}
+
+	findOptions := mopts.Find().
+		SetProjection(devicesProjection(false, q.Required)).
+		SetSort(bson.M{DbDevId: 1}).
+		SetSkip(int64(q.Skip))
+	if q.Limit > 0 {
+		findOptions.SetLimit(int64(q.Limit))
+	}
+	cur, err := db.find(ctx, c, filter, findOptions)
+	if err != nil {
+		return nil, -1, errors.Wrap(err, "failed to get incomplete devices")
+	}
+	devices := []model.Device{}
+	if err = decodeAll(ctx, cur, &devices); err != nil {
+		return nil, -1, errors.Wrap(err, "failed to get incomplete devices")
+	}
+	count, err := c.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, -1, errors.Wrap(err, "failed to count incomplete devices")
+	}
+	return devices, int(count), nil
+}
+
+func (db *DataStoreMongo) GetCompletenessAlert(
+	ctx context.Context,
+) (*model.CompletenessAlert, error) {
+	c := db.database(ctx).
+		Collection(DbSettingsColl)
+
+	alert := &model.CompletenessAlert{}
+	err := c.FindOne(ctx, bson.M{DbDevId: DbSettingsCompletenessAlert}).
+		Decode(alert)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to get completeness alert")
+	}
+	return alert, nil
+}
+
+func (db *DataStoreMongo) SetCompletenessAlert(
+	ctx context.Context,
+	alert model.CompletenessAlert,
+) error {
+	c := db.database(ctx).
+		Collection(DbSettingsColl)
+
+	doc := struct {
+		ID                      string `bson:"_id"`
+		model.CompletenessAlert `bson:",inline"`
+	}{
+		ID:                DbSettingsCompletenessAlert,
+		CompletenessAlert: alert,
+	}
+	_, err := c.ReplaceOne(ctx,
+		bson.M{DbDevId: DbSettingsCompletenessAlert}, doc,
+		mopts.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to set completeness alert")
+	}
+	return nil
+}
+
+func (db *DataStoreMongo) DeleteCompletenessAlert(ctx context.Context) error {
+	c := db.database(ctx).
+		Collection(DbSettingsColl)
+
+	_, err := c.DeleteOne(ctx, bson.M{DbDevId: DbSettingsCompletenessAlert})
+	if err != nil {
+		return errors.Wrap(err, "failed to delete completeness alert")
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/mendersoftware/go-lib-micro/requestid"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/mongo"
+	mopts "go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mendersoftware/inventory/metrics"
+)
+
+// cursorReapInterval is the maximum interval between the checks of the
+// cursor reaper.
+const cursorReapInterval = time.Minute
+
+var (
+	cursorsOpen = metrics.NewGaugeVec(
+		"inventory_mongo_cursors_open",
+		"Number of mongo cursors open, by the store operation which opened them.",
+		"operation",
+	)
+	cursorsLeaked = metrics.NewCounterVec(
+		"inventory_mongo_cursors_leaked_total",
+		"Number of mongo cursors left open past the maximum age and closed by the reaper.",
+		"operation",
+	)
+	cursorPanics = metrics.NewCounterVec(
+		"inventory_mongo_cursor_panics_total",
+		"Number of panics recovered while iterating the mongo cursors.",
+		"operation",
+	)
+)
+
+// cursorInfo describes who opened a cursor and when.
+type cursorInfo struct {
+	operation string
+	requestID string
+	opened    time.Time
+}
+
+// cursorTracker keeps track of the cursors open by the store, so that the
+// cursors which are never closed are reported and released.
+type cursorTracker struct {
+	mu   sync.Mutex
+	open map[*mongo.Cursor]cursorInfo
+}
+
+func newCursorTracker() *cursorTracker {
+	return &cursorTracker{open: make(map[*mongo.Cursor]cursorInfo)}
+}
+
+// cursors tracks the cursors of all the data stores, which share
+// the mongo client.
+var cursors = newCursorTracker()
+
+func (t *cursorTracker) track(ctx context.Context, cur *mongo.Cursor, operation string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.open[cur] = cursorInfo{
+		operation: operation,
+		requestID: requestid.FromContext(ctx),
+		opened:    time.Now(),
+	}
+	cursorsOpen.Add(1, operation)
+}
+
+// untrack forgets the cursor; returns false if it was not tracked.
+func (t *cursorTracker) untrack(cur *mongo.Cursor) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	info, ok := t.open[cur]
+	if !ok {
+		return false
+	}
+	delete(t.open, cur)
+	cursorsOpen.Add(-1, info.operation)
+	return true
+}
+
+// operation returns the store operation which opened the cursor.
+func (t *cursorTracker) operation(cur *mongo.Cursor) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.open[cur].operation
+}
+
+// reap forgets and returns the cursors opened before the given time.
+func (t *cursorTracker) reap(before time.Time) map[*mongo.Cursor]cursorInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	reaped := make(map[*mongo.Cursor]cursorInfo)
+	for cur, info := range t.open {
+		if info.opened.Before(before) {
+			reaped[cur] = info
+			delete(t.open, cur)
+			cursorsOpen.Add(-1, info.operation)
+		}
+	}
+	return reaped
+}
+
+// callerName returns the name of the function skip frames above
+// the caller of callerName, without the package and the receiver.
+func callerName(skip int) string {
+	pc, _, _, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return "unknown"
+	}
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return "unknown"
+	}
+	name := fn.Name()
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// find runs the query and tracks the cursor under the name of the caller.
+func (db *DataStoreMongo) find(
+	ctx context.Context,
+	c *mongo.Collection,
+	filter interface{},
+	opts ...*mopts.FindOptions,
+) (*mongo.Cursor, error) {
+	cur, err := c.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	cursors.track(ctx, cur, callerName(1))
+	return cur, nil
+}
+
+// iterateCursor calls f on each document of the cursor and closes it;
+// a panic of f, e.g. on an unexpected document, is returned as an error
+// instead of taking the service down with the cursor left open.
+func iterateCursor(
+	ctx context.Context,
+	cur *mongo.Cursor,
+	f func(cur *mongo.Cursor) error,
+) (err error) {
+	defer closeCursor(cur)
+	defer func() {
+		if r := recover(); r != nil {
+			operation := cursors.operation(cur)
+			cursorPanics.Inc(operation)
+			log.FromContext(ctx).Errorf("panic iterating the cursor of %s: %v",
+				operation, r)
+			err = errors.Errorf("failed to iterate the cursor: %v", r)
+		}
+	}()
+	for cur.Next(ctx) {
+		if err := f(cur); err != nil {
+			return err
+		}
+	}
+	return cur.Err()
+}
+
+// streamCursor stops tracking the cursor once the device stream
+// closes it.
+type streamCursor struct {
+	*mongo.Cursor
+}
+
+func (c streamCursor) Close(ctx context.Context) error {
+	cursors.untrack(c.Cursor)
+	return c.Cursor.Close(ctx)
+}
+
+// reapCursors closes the cursors left open for longer than maxAge and
+// reports them as leaked.
+func reapCursors(ctx context.Context, maxAge time.Duration) int {
+	l := log.FromContext(ctx)
+	reaped := cursors.reap(time.Now().Add(-maxAge))
+	for cur, info := range reaped {
+		cursorsLeaked.Inc(info.operation)
+		l.Warnf("closing the cursor opened by %s %s ago, request %q: "+
+			"the cursor was not closed", info.operation,
+			time.Since(info.opened).Round(time.Second), info.requestID)
+		closeCursor(cur)
+	}
+	return len(reaped)
+}
+
+// RunCursorReaper periodically closes the cursors left open for longer
+// than maxAge until the context is canceled. The maximum age should be
+// longer than any legitimate use of a cursor, such as a large export.
+func RunCursorReaper(ctx context.Context, maxAge time.Duration) {
+	interval := cursorReapInterval
+	if maxAge < interval {
+		interval = maxAge
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reapCursors(ctx, maxAge)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/requestid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/mendersoftware/inventory/metrics"
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+)
+
+func TestCursorTracker(t *testing.T) {
+	t.Parallel()
+
+	const operation = "TestCursorTracker"
+	tracker := newCursorTracker()
+	ctx := requestid.WithContext(context.Background(), "req-1")
+
+	stale, fresh := &mongo.Cursor{}, &mongo.Cursor{}
+	tracker.track(ctx, stale, operation)
+	tracker.open[stale] = cursorInfo{
+		operation: operation,
+		requestID: "req-1",
+		opened:    time.Now().Add(-time.Hour),
+	}
+	tracker.track(ctx, fresh, operation)
+	assert.Equal(t, 2.0, metrics.Value("inventory_mongo_cursors_open", operation))
+	assert.Equal(t, operation, tracker.operation(fresh))
+
+	reaped := tracker.reap(time.Now().Add(-time.Minute))
+	assert.Len(t, reaped, 1)
+	assert.Equal(t, "req-1", reaped[stale].requestID)
+	assert.Equal(t, 1.0, metrics.Value("inventory_mongo_cursors_open", operation))
+
+	assert.True(t, tracker.untrack(fresh))
+	assert.False(t, tracker.untrack(fresh))
+	assert.False(t, tracker.untrack(stale))
+	assert.Equal(t, 0.0, metrics.Value("inventory_mongo_cursors_open", operation))
+}
+
+func TestCallerName(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "TestCallerName", callerName(0))
+	assert.Equal(t, "tRunner", callerName(1))
+}
+
+func TestMongoCursorTracking(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoCursorTracking in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client()).(*DataStoreMongo)
+	ctx := db.CTX()
+
+	for i := 0; i < 300; i++ {
+		err := ds.AddDevice(ctx, &model.Device{
+			ID: model.DeviceID(fmt.Sprintf("%03d", i)),
+		})
+		require.NoError(t, err, "failed to setup input data")
+	}
+	before := openCursors(t)
+	tracked := func() int {
+		cursors.mu.Lock()
+		defer cursors.mu.Unlock()
+		return len(cursors.open)
+	}
+
+	t.Run("released", func(t *testing.T) {
+		_, err := ds.GetDevicesByIDs(ctx, []model.DeviceID{"001", "002"}, nil)
+		assert.NoError(t, err)
+		_, _, err = ds.GetDevices(ctx, store.ListQuery{Limit: 10})
+		assert.NoError(t, err)
+		_, err = ds.GetAllDeviceIDs(ctx)
+		assert.NoError(t, err)
+		assert.Zero(t, tracked())
+		assert.Equal(t, before, openCursors(t))
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		cur, err := ds.find(ctx, ds.database(ctx).Collection(ds.names.Devices), bson.M{})
+		require.NoError(t, err)
+		err = iterateCursor(ctx, cur, func(cur *mongo.Cursor) error {
+			panic("unexpected document")
+		})
+		assert.EqualError(t, err, "failed to iterate the cursor: unexpected document")
+		assert.Zero(t, tracked())
+		assert.Equal(t, before, openCursors(t))
+	})
+
+	t.Run("leaked", func(t *testing.T) {
+		cur, err := ds.find(ctx, ds.database(ctx).Collection(ds.names.Devices), bson.M{})
+		require.NoError(t, err)
+		cur.Next(ctx)
+		assert.Equal(t, 1, tracked())
+		assert.Equal(t, before+1, openCursors(t))
+
+		assert.Zero(t, reapCursors(ctx, time.Hour))
+		assert.Equal(t, 1, reapCursors(ctx, 0))
+		assert.Zero(t, tracked())
+		assert.Equal(t, before, openCursors(t))
+	})
+}
@@ -19,6 +19,8 @@ import (
 	"crypto/tls"
 	"fmt"
 	"io"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -29,7 +31,7 @@ import (
 	mopts "go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/mendersoftware/go-lib-micro/log"
-	mstore "github.com/mendersoftware/go-lib-micro/store"
+	"github.com/mendersoftware/go-lib-micro/mongo/migrate"
 	"github.com/pkg/errors"
 
 	"github.com/mendersoftware/inventory/model"
@@ -37,24 +39,71 @@ import (
 )
 
 const (
-	DbVersion = "1.0.2"
+	DbVersion = "1.0.8"
 
 	DbName        = "inventory"
 	DbDevicesColl = "devices"
+	DbScopesColl  = "scopes"
+	DbSchemaColl  = "schema"
+
+	DbSchemaViolationsColl = "schema_violations"
+	DbExternalIDsColl      = "external_ids"
+	DbSettingsColl         = "settings"
 
 	DbDevId              = "_id"
 	DbDevAttributes      = "attributes"
 	DbDevGroup           = "group"
 	DbDevRevision        = "revision"
 	DbDevUpdatedTs       = "updated_ts"
+	DbDevSources         = "sources"
 	DbDevAttributesDesc  = "description"
 	DbDevAttributesValue = "value"
 	DbDevAttributesScope = "scope"
 	DbDevAttributesName  = "name"
+	DbDevAttributesTs    = "updated_ts"
 	DbDevAttributesGroup = DbDevAttributes + "." +
 		model.AttrScopeSystem + "-" + model.AttrNameGroup
 	DbDevAttributesGroupValue = DbDevAttributesGroup + "." +
 		DbDevAttributesValue
+	DbDevAttributesUpdatedValue = DbDevAttributes + "." +
+		model.AttrScopeSystem + "-" + model.AttrNameUpdated + "." +
+		DbDevAttributesValue
+	DbDevAttributesParentValue = DbDevAttributes + "." +
+		model.AttrScopeSystem + "-" + model.AttrNameParentDevice + "." +
+		DbDevAttributesValue
+
+	DbExternalIDSystem = "system"
+	DbExternalID       = "id"
+
+	// DbSettingsFeatureFlags is the ID of the settings document holding
+	// the feature flags overridden for the tenant.
+	DbSettingsFeatureFlags = "feature_flags"
+	DbSettingsFlags        = "flags"
+	// DbSettingsLimits is the ID of the settings document holding the
+	// hard limits of the requests of the tenant.
+	DbSettingsLimits = "limits"
+	// DbSettingsValidationWebhook is the ID of the settings document
+	// holding the validation webhook of the tenant.
+	DbSettingsValidationWebhook = "validation_webhook"
+	// DbSettingsPinnedAttributes is the ID of the settings document
+	// holding the attributes pinned by the tenant.
+	DbSettingsPinnedAttributes = "pinned_attributes"
+	// DbSettingsCompletenessAlert is the ID of the settings document
+	// holding the completeness alert of the tenant.
+	DbSettingsCompletenessAlert = "completeness_alert"
+	// DbSettingsDeviceChanges is the ID of the settings document holding
+	// the last sequence number of the change feed of the tenant.
+	DbSettingsDeviceChanges = "device_changes"
+	// DbSettingsDigest is the ID of the settings document holding the
+	// digest settings of the tenant.
+	DbSettingsDigest = "digest"
+	// DbSettingsAttributeDescriptions is the ID of the settings document
+	// holding the catalog of the descriptions of the attributes.
+	DbSettingsAttributeDescriptions = "attribute_descriptions"
+	DbSettingsDescriptions          = "descriptions"
+	// DbSettingsAttributeAliases is the ID of the settings document
+	// holding the aliases of the attributes of the tenant.
+	DbSettingsAttributeAliases = "attribute_aliases"
 
 	DbScopeInventory = "inventory"
 
@@ -66,6 +115,8 @@ const (
 var (
 	//with offcial mongodb supported driver we keep client
 	clientGlobal *mongo.Client
+	// topologyGlobal follows the topology events of the global client
+	topologyGlobal *TopologyMonitor
 
 	// once ensures client is created only once
 	once sync.Once
@@ -84,39 +135,94 @@ type DataStoreMongoConfig struct {
 	// Overwrites credentials provided in connection string if provided
 	Username string
 	Password string
+
+	// UnavailableThreshold is the time without a writable server after
+	// which the health check fails without pinging the database; zero
+	// disables the check.
+	UnavailableThreshold time.Duration
+
+	// DbNames overrides the names of the databases and the collections;
+	// the names left empty take the default values.
+	DbNames DbNames
+
+	// GroupsDualWrite enables writing the group membership in the groups
+	// array format alongside the group attribute.
+	GroupsDualWrite bool
+
+	// SearchExplainSampleRate is the number of device searches out of
+	// which one is explained to record the scan metrics; zero disables
+	// the sampling.
+	SearchExplainSampleRate int
+
+	// AttributeCompressionThreshold is the length, in bytes, above which
+	// the string values of the attributes of the cold scopes are stored
+	// compressed; zero disables the compression.
+	AttributeCompressionThreshold int
+
+	// DeviceTelemetry enables recording the writes of the attributes of
+	// each device, with the same update as the attributes.
+	DeviceTelemetry bool
 }
 
 type DataStoreMongo struct {
 	client      *mongo.Client
+	names       DbNames
+	topology    *TopologyMonitor
 	automigrate bool
+
+	groupsRollout *rollout
+	searchSampler *searchSampler
+
+	compressThreshold int
+	telemetry         bool
 }
 
 func NewDataStoreMongoWithSession(client *mongo.Client) store.DataStore {
-	return &DataStoreMongo{client: client}
+	return &DataStoreMongo{
+		client: client,
+		names:  DefaultDbNames(),
+	}
+}
+
+// clientOptions returns the options of the mongo client connecting with
+// the configuration.
+func (config DataStoreMongoConfig) clientOptions() *mopts.ClientOptions {
+	connectionString := config.ConnectionString
+	if !strings.Contains(connectionString, "://") {
+		connectionString = "mongodb://" + connectionString
+	}
+	clientOptions := mopts.Client().ApplyURI(connectionString)
+
+	if config.Username != "" {
+		clientOptions.SetAuth(mopts.Credential{
+			Username: config.Username,
+			Password: config.Password,
+		})
+	}
+
+	if config.SSL {
+		tlsConfig := &tls.Config{}
+		tlsConfig.InsecureSkipVerify = config.SSLSkipVerify
+		clientOptions.SetTLSConfig(tlsConfig)
+	}
+	return clientOptions
 }
 
 //config.ConnectionString must contain a valid
 func NewDataStoreMongo(config DataStoreMongoConfig) (store.DataStore, error) {
+	names := config.DbNames.WithDefaults()
+	if err := names.Validate(); err != nil {
+		return nil, err
+	}
+
 	//init master session
 	var err error
 	once.Do(func() {
-		if !strings.Contains(config.ConnectionString, "://") {
-			config.ConnectionString = "mongodb://" + config.ConnectionString
-		}
-		clientOptions := mopts.Client().ApplyURI(config.ConnectionString)
-
-		if config.Username != "" {
-			clientOptions.SetAuth(mopts.Credential{
-				Username: config.Username,
-				Password: config.Password,
-			})
-		}
+		clientOptions := config.clientOptions()
 
-		if config.SSL {
-			tlsConfig := &tls.Config{}
-			tlsConfig.InsecureSkipVerify = config.SSLSkipVerify
-			clientOptions.SetTLSConfig(tlsConfig)
-		}
+		topologyGlobal = NewTopologyMonitor(config.UnavailableThreshold)
+		clientOptions.SetServerMonitor(topologyGlobal.ServerMonitor())
+		clientOptions.SetPoolMonitor(topologyGlobal.PoolMonitor())
 
 		ctx := context.Background()
 		l := log.FromContext(ctx)
@@ -148,92 +254,285 @@ func NewDataStoreMongo(config DataStoreMongoConfig) (store.DataStore, error) {
 	if clientGlobal == nil {
 		return nil, errors.New("failed to open mongo-driver session")
 	}
-	db := &DataStoreMongo{client: clientGlobal}
+	db := &DataStoreMongo{
+		client:   clientGlobal,
+		names:    names,
+		topology: topologyGlobal,
+
+		groupsRollout: &rollout{enabled: config.GroupsDualWrite},
+		searchSampler: newSearchSampler(config.SearchExplainSampleRate),
+
+		compressThreshold: config.AttributeCompressionThreshold,
+		telemetry:         config.DeviceTelemetry,
+	}
 
 	return db, nil
 }
 
 func (db *DataStoreMongo) Ping(ctx context.Context) error {
-	res := db.client.Database(DbName).RunCommand(ctx, bson.M{"ping": 1})
+	if err := db.topology.Err(); err != nil {
+		return err
+	}
+	res := db.client.Database(db.names.Database).RunCommand(ctx, bson.M{"ping": 1})
 	return res.Err()
 }
 
 func (db *DataStoreMongo) GetDevices(ctx context.Context, q store.ListQuery) ([]model.Device, int, error) {
-	c := db.client.Database(mstore.DbFromContext(ctx, DbName)).Collection(DbDevicesColl)
+	stream, count, err := db.StreamDevices(ctx, q)
+	if err != nil {
+		return nil, -1, err
+	}
+	defer stream.Close()
+
+	devices, err := stream.All()
+	if err != nil {
+		return nil, -1, err
+	}
+	return devices, count, nil
+}
+
+func (db *DataStoreMongo) StreamDevices(
+	ctx context.Context,
+	q store.ListQuery,
+) (*store.DeviceStream, int, error) {
+	cursor, count, err := db.findDevices(ctx, q)
+	if err != nil {
+		return nil, -1, err
+	}
+	return store.NewDeviceStream(ctx, streamCursor{cursor}), count, nil
+}
+
+// filterQuery returns the query of the devices matching the filter.
+func filterQuery(filter store.Filter) bson.M {
+	op := mongoOperator(filter.Operator)
+	name := fmt.Sprintf("%s-%s", filter.AttrScope, model.GetDeviceAttributeNameReplacer().Replace(filter.AttrName))
+	field := fmt.Sprintf("%s.%s.%s", DbDevAttributes, name, DbDevAttributesValue)
+	switch filter.Operator {
+	case store.Ne:
+		// neither the string nor the number
+		values := []interface{}{filter.Value}
+		if filter.ValueFloat != nil {
+			values = append(values, *filter.ValueFloat)
+		}
+		return bson.M{field: bson.M{"$nin": values}}
+	case store.Gt, store.Gte, store.Lt, store.Lte:
+		// the numbers compare by value, other values as strings
+		var value interface{} = filter.Value
+		if filter.ValueFloat != nil {
+			value = *filter.ValueFloat
+		}
+		return bson.M{field: bson.M{op: value}}
+	case store.In, store.Nin:
+		values := make([]interface{}, 0, len(filter.Values))
+		for _, v := range filter.Values {
+			values = append(values, v)
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				values = append(values, f)
+			}
+		}
+		return bson.M{field: bson.M{op: values}}
+	case store.Exists:
+		exists, _ := strconv.ParseBool(filter.Value)
+		return bson.M{field: bson.M{op: exists}}
+	case store.Regex, store.IRegex:
+		return bson.M{field: regexQuery(filter.Value, filter.Operator == store.IRegex)}
+	default:
+		if filter.ValueFloat != nil {
+			return bson.M{"$or": []bson.M{
+				{field: bson.M{op: filter.Value}},
+				{field: bson.M{op: filter.ValueFloat}},
+			}}
+		}
+		return bson.M{field: bson.M{op: filter.Value}}
+	}
+}
+
+// regexQuery returns the query operator matching the string values with
+// the regular expression.
+//
+// Only the case-sensitive expressions anchored at the start, e.g. ^rpi,
+// are evaluated as bounded scans of the indexes of the attributes; any
+// other expression scans all the keys of the index, or all the devices
+// when the attribute is not indexed. The clients searching the devices
+// by substrings of an attribute should thus index it and narrow the
+// search with the other filters, and the patterns are validated with
+// model.ValidateRegex to exclude the catastrophic backtracking.
+func regexQuery(pattern string, ignoreCase bool) bson.M {
+	query := bson.M{"$regex": pattern}
+	if ignoreCase {
+		query["$options"] = "i"
+	}
+	return query
+}
+
+// updatedSinceQuery returns the query of the devices updated at or after
+// the time; with the ID of the last device returned, the devices updated
+// at exactly that time are resumed after it, in the order of the IDs.
+func updatedSinceQuery(since time.Time, afterID model.DeviceID) bson.M {
+	if afterID == "" {
+		return bson.M{DbDevAttributesUpdatedValue: bson.M{"$gte": since}}
+	}
+	return bson.M{"$or": []bson.M{
+		{DbDevAttributesUpdatedValue: bson.M{"$gt": since}},
+		{
+			DbDevAttributesUpdatedValue: since,
+			DbDevId:                     bson.M{"$gt": afterID},
+		},
+	}}
+}
+
+// filterGroupQuery returns the query of the devices matching the group,
+// combining the queries of its filters and nested groups with $and or $or;
+// the query of an empty group is empty.
+func filterGroupQuery(group store.FilterGroup) bson.M {
+	queries := make([]bson.M, 0, len(group.Filters)+len(group.Groups))
+	for _, filter := range group.Filters {
+		queries = append(queries, filterQuery(filter))
+	}
+	for _, nested := range group.Groups {
+		if query := filterGroupQuery(nested); len(query) > 0 {
+			queries = append(queries, query)
+		}
+	}
+	switch {
+	case len(queries) == 0:
+		return bson.M{}
+	case len(queries) == 1:
+		return queries[0]
+	case group.Operator == store.Or:
+		return bson.M{"$or": queries}
+	default:
+		return bson.M{"$and": queries}
+	}
+}
 
+// devicesQuery returns the query of the devices selected by the filters
+// of the list query, regardless of its pagination and sort.
+func (db *DataStoreMongo) devicesQuery(ctx context.Context, q store.ListQuery) bson.M {
 	queryFilters := make([]bson.M, 0)
 	for _, filter := range q.Filters {
-		op := mongoOperator(filter.Operator)
-		name := fmt.Sprintf("%s-%s", filter.AttrScope, model.GetDeviceAttributeNameReplacer().Replace(filter.AttrName))
-		field := fmt.Sprintf("%s.%s.%s", DbDevAttributes, name, DbDevAttributesValue)
-		switch filter.Operator {
-		default:
-			if filter.ValueFloat != nil {
-				queryFilters = append(queryFilters, bson.M{"$or": []bson.M{
-					{field: bson.M{op: filter.Value}},
-					{field: bson.M{op: filter.ValueFloat}},
-				}})
-			} else {
-				queryFilters = append(queryFilters, bson.M{field: bson.M{op: filter.Value}})
-			}
+		queryFilters = append(queryFilters, filterQuery(filter))
+	}
+	if q.FilterGroup != nil {
+		if query := filterGroupQuery(*q.FilterGroup); len(query) > 0 {
+			queryFilters = append(queryFilters, query)
 		}
 	}
-	if q.GroupName != "" {
-		groupFilter := bson.M{DbDevAttributesGroupValue: q.GroupName}
-		queryFilters = append(queryFilters, groupFilter)
+	groupsField, groupsExistsField := DbDevAttributesGroupValue, DbDevAttributesGroup
+	if db.GroupsRolloutPhase(ctx) == RolloutDualRead {
+		groupsField, groupsExistsField = DbDevGroups, DbDevGroups
 	}
-	if q.GroupName != "" {
-		groupFilter := bson.M{DbDevAttributesGroupValue: q.GroupName}
+	if len(q.GroupNames) > 0 {
+		groupFilter := bson.M{groupsField: bson.M{"$in": q.GroupNames}}
+		queryFilters = append(queryFilters, groupFilter)
+	} else if q.GroupName != "" {
+		groupFilter := bson.M{groupsField: q.GroupName}
 		queryFilters = append(queryFilters, groupFilter)
 	}
 	if q.HasGroup != nil {
 		groupExistenceFilter := bson.M{
-			DbDevAttributesGroup: bson.M{
+			groupsExistsField: bson.M{
 				"$exists": *q.HasGroup,
 			},
 		}
 		queryFilters = append(queryFilters, groupExistenceFilter)
 	}
+	if q.Search != "" {
+		queryFilters = append(queryFilters, textSearchQuery(q.Search))
+	}
+	if q.UpdatedSince != nil {
+		queryFilters = append(queryFilters,
+			updatedSinceQuery(*q.UpdatedSince, q.UpdatedAfterID))
+	}
 
 	findQuery := bson.M{}
 	if len(queryFilters) > 0 {
 		findQuery["$and"] = queryFilters
 	}
+	return findQuery
+}
+
+// CountDevicesByQuery counts the devices matching the filters of the list
+// query, without fetching them.
+func (db *DataStoreMongo) CountDevicesByQuery(ctx context.Context, q store.ListQuery) (int, error) {
+	c := db.database(ctx).Collection(db.names.Devices)
+
+	countOptions := mopts.Count()
+	if d := maxTime(ctx); d > 0 {
+		countOptions.SetMaxTime(d)
+	}
+	count, err := c.CountDocuments(ctx, db.devicesQuery(ctx, q), countOptions)
+	if err != nil {
+		return -1, errors.Wrap(err, "failed to count devices")
+	}
+	return int(count), nil
+}
 
+// findDevices counts the devices matching the query and opens the cursor
+// returning them.
+func (db *DataStoreMongo) findDevices(
+	ctx context.Context,
+	q store.ListQuery,
+) (*mongo.Cursor, int, error) {
+	c := db.database(ctx).Collection(db.names.Devices)
+
+	findQuery := db.devicesQuery(ctx, q)
 	findOptions := mopts.Find()
-	if q.Skip > 0 {
+	if q.Skip > 0 && q.Cursor == nil {
 		findOptions.SetSkip(int64(q.Skip))
 	}
 	if q.Limit > 0 {
 		findOptions.SetLimit(int64(q.Limit))
 	}
-	if q.Sort != nil {
-		name := fmt.Sprintf("%s-%s", q.Sort.AttrScope, model.GetDeviceAttributeNameReplacer().Replace(q.Sort.AttrName))
-		sortField := fmt.Sprintf("%s.%s.%s", DbDevAttributes, name, DbDevAttributesValue)
-		sortFieldQuery := bson.D{{Key: sortField, Value: 1}}
-		if !q.Sort.Ascending {
-			sortFieldQuery[0].Value = -1
+	var sortFields bson.M
+	if len(q.Sort) > 0 {
+		criteria := make([]model.SortCriteria, len(q.Sort))
+		for i, sortQ := range q.Sort {
+			criteria[i] = model.SortCriteria{
+				Scope:     sortQ.AttrScope,
+				Attribute: sortQ.AttrName,
+				Order:     "desc",
+				Nulls:     sortQ.Nulls,
+			}
+			if sortQ.Ascending {
+				criteria[i].Order = "asc"
+			}
 		}
-		findOptions.SetSort(sortFieldQuery)
+		var sort bson.D
+		sort, sortFields = attributesSort(criteria)
+		findOptions.SetSort(sort)
+	} else if q.UpdatedSince != nil {
+		// served by the index of the update time
+		findOptions.SetSort(withIDTieBreaker(bson.D{
+			{Key: DbDevAttributesUpdatedValue, Value: 1},
+		}))
+	} else if q.Cursor != nil {
+		// the keyset pagination needs a total order
+		findOptions.SetSort(withIDTieBreaker(bson.D{}))
+	}
+	if projection := devicesProjection(q.IDsOnly, q.Attributes); projection != nil {
+		findOptions.SetProjection(projection)
 	}
 
-	cursor, err := c.Find(ctx, findQuery, findOptions)
-	if err != nil {
-		return nil, -1, errors.Wrap(err, "failed to search devices")
+	countOptions := mopts.Count()
+	if d := maxTime(ctx); d > 0 {
+		findOptions.SetMaxTime(d)
+		countOptions.SetMaxTime(d)
 	}
-	defer cursor.Close(ctx)
 
-	devices := []model.Device{}
-	if err = cursor.All(ctx, &devices); err != nil {
-		return nil, -1, errors.Wrap(err, "failed to search devices")
+	count, err := c.CountDocuments(ctx, findQuery, countOptions)
+	if err != nil {
+		return nil, -1, errors.Wrap(err, "failed to count devices")
 	}
 
-	count, err := c.CountDocuments(ctx, findQuery)
+	// the devices are counted regardless of the cursor
+	cursor, err := db.findSorted(ctx, c, withKeyset(findQuery, q.Cursor),
+		findOptions, sortFields)
 	if err != nil {
-		return nil, -1, errors.Wrap(err, "failed to count devices")
+		return nil, -1, errors.Wrap(err, "failed to search devices")
 	}
 
-	return devices, int(count), nil
+	return cursor, int(count), nil
 }
 
 func (db *DataStoreMongo) GetDevice(
@@ -241,9 +540,8 @@ func (db *DataStoreMongo) GetDevice(
 	id model.DeviceID,
 ) (*model.Device, error) {
 	var res model.Device
-	c := db.client.
-		Database(mstore.DbFromContext(ctx, DbName)).
-		Collection(DbDevicesColl)
+	c := db.database(ctx).
+		Collection(db.names.Devices)
 	l := log.FromContext(ctx)
 
 	if id == model.NilDeviceID {
@@ -261,6 +559,78 @@ func (db *DataStoreMongo) GetDevice(
 	return &res, nil
 }
 
+func (db *DataStoreMongo) GetDevicesByIDs(
+	ctx context.Context,
+	ids []model.DeviceID,
+	attributes []model.SelectAttribute,
+) ([]model.Device, error) {
+	c := db.database(ctx).Collection(db.names.Devices)
+
+	findOptions := mopts.Find()
+	if projection := devicesProjection(false, attributes); projection != nil {
+		findOptions.SetProjection(projection)
+	}
+	cursor, err := db.find(ctx, c, bson.M{DbDevId: bson.M{"$in": ids}}, findOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch devices")
+	}
+	devices := []model.Device{}
+	if err := decodeAll(ctx, cursor, &devices); err != nil {
+		return nil, errors.Wrap(err, "failed to fetch devices")
+	}
+	return devices, nil
+}
+
+func (db *DataStoreMongo) GetDeviceIDsByAttribute(
+	ctx context.Context,
+	scope, name string,
+	values []string,
+) (map[string][]model.DeviceID, error) {
+	c := db.database(ctx).Collection(db.names.Devices)
+
+	field := makeAttrField(name, scope, DbDevAttributesValue)
+	findOptions := mopts.Find().
+		SetProjection(bson.M{DbDevId: 1, field: 1})
+	cursor, err := db.find(ctx, c, bson.M{field: bson.M{"$in": values}}, findOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch devices")
+	}
+
+	wanted := make(map[string]bool, len(values))
+	for _, v := range values {
+		wanted[v] = true
+	}
+	ids := make(map[string][]model.DeviceID)
+	err = iterateCursor(ctx, cursor, func(cursor *mongo.Cursor) error {
+		var dev model.Device
+		if err := cursor.Decode(&dev); err != nil {
+			return errors.Wrap(err, "failed to decode device")
+		}
+		for _, attr := range dev.Attributes {
+			// array attributes match any of their elements
+			var matched []interface{}
+			switch v := attr.Value.(type) {
+			case primitive.A:
+				matched = v
+			case []interface{}:
+				matched = v
+			default:
+				matched = []interface{}{v}
+			}
+			for _, v := range matched {
+				if s, ok := v.(string); ok && wanted[s] {
+					ids[s] = append(ids[s], dev.ID)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch devices")
+	}
+	return ids, nil
+}
+
 // AddDevice inserts a new device, initializing the inventory data.
 func (db *DataStoreMongo) AddDevice(ctx context.Context, dev *model.Device) error {
 	if dev.Group != "" {
@@ -326,16 +696,18 @@ func (db *DataStoreMongo) upsertAttributes(
 		err    error
 	)
 
-	c := db.client.
-		Database(mstore.DbFromContext(ctx, DbName)).
-		Collection(DbDevicesColl)
+	c := db.database(ctx).
+		Collection(db.names.Devices)
 
 	update, err := makeAttrUpsert(attrs)
 	if err != nil {
 		return nil, err
 	}
+	db.dualWriteGroups(ctx, update, nil)
 
 	now := time.Now()
+	setAttrSources(ctx, update, now, attrs)
+	setAttrTimestamps(update, now, attrs)
 	oninsert := bson.M{
 		createdField: model.DeviceAttribute{
 			Scope: model.AttrScopeSystem,
@@ -384,7 +756,8 @@ func (db *DataStoreMongo) upsertAttributes(
 				"$setOnInsert": oninsert,
 			}
 		}
-		res, err = c.UpdateOne(ctx, filter, update, mopts.Update().SetUpsert(true))
+		res, err = c.UpdateOne(ctx, filter, db.withTelemetry(ctx, update, now),
+			mopts.Update().SetUpsert(true))
 		if err != nil {
 			if strings.Contains(err.Error(), "duplicate key error") {
 				return nil, store.ErrWriteConflict
@@ -508,6 +881,31 @@ func makeAttrUpsert(attrs model.DeviceAttributes) (bson.M, error) {
 	return upsert, nil
 }
 
+// setAttrSources records the principal authenticated in the context as
+// the source of the scopes of the given attributes in the update document.
+func setAttrSources(
+	ctx context.Context,
+	update bson.M,
+	ts time.Time,
+	attrs ...model.DeviceAttributes,
+) {
+	source := model.NewAttributeSource(ctx, ts)
+	replacer := model.GetDeviceAttributeNameReplacer()
+	for _, a := range attrs {
+		for i := range a {
+			update[DbDevSources+"."+replacer.Replace(a[i].Scope)] = source
+		}
+	}
+}
+
+// setAttrTimestamps records the time of the update of the given attributes
+// in the update document.
+func setAttrTimestamps(update bson.M, ts time.Time, attrs model.DeviceAttributes) {
+	for i := range attrs {
+		update[makeAttrField(attrs[i].Name, attrs[i].Scope, DbDevAttributesTs)] = ts
+	}
+}
+
 // makeAttrUpsert creates a new upsert document for the given attributes.
 func makeAttrRemove(attrs model.DeviceAttributes) (bson.M, error) {
 	var fieldName string
@@ -536,6 +934,24 @@ func mongoOperator(co store.ComparisonOperator) string {
 	switch co {
 	case store.Eq:
 		return "$eq"
+	case store.Ne:
+		return "$ne"
+	case store.Gt:
+		return "$gt"
+	case store.Gte:
+		return "$gte"
+	case store.Lt:
+		return "$lt"
+	case store.Lte:
+		return "$lte"
+	case store.In:
+		return "$in"
+	case store.Nin:
+		return "$nin"
+	case store.Exists:
+		return "$exists"
+	case store.Regex, store.IRegex:
+		return "$regex"
 	}
 	return ""
 }
@@ -554,9 +970,8 @@ func (db *DataStoreMongo) UpsertRemoveDeviceAttributes(
 		err    error
 	)
 
-	c := db.client.
-		Database(mstore.DbFromContext(ctx, DbName)).
-		Collection(DbDevicesColl)
+	c := db.database(ctx).
+		Collection(db.names.Devices)
 
 	update, err := makeAttrUpsert(updateAttrs)
 	if err != nil {
@@ -566,8 +981,11 @@ func (db *DataStoreMongo) UpsertRemoveDeviceAttributes(
 	if err != nil {
 		return nil, err
 	}
+	db.dualWriteGroups(ctx, update, remove)
 
 	now := time.Now()
+	setAttrSources(ctx, update, now, updateAttrs, removeAttrs)
+	setAttrTimestamps(update, now, updateAttrs)
 	update[updatedField] = model.DeviceAttribute{
 		Scope: model.AttrScopeSystem,
 		Name:  model.AttrNameUpdated,
@@ -589,7 +1007,8 @@ func (db *DataStoreMongo) UpsertRemoveDeviceAttributes(
 
 	var res *mongo.UpdateResult
 	filter := map[string]interface{}{"_id": id}
-	res, err = c.UpdateOne(ctx, filter, update, mopts.Update().SetUpsert(true))
+	res, err = c.UpdateOne(ctx, filter, db.withTelemetry(ctx, update, now),
+		mopts.Update().SetUpsert(true))
 	if err == nil {
 		result = &model.UpdateResult{
 			MatchedCount: res.MatchedCount,
@@ -599,139 +1018,97 @@ func (db *DataStoreMongo) UpsertRemoveDeviceAttributes(
 	return result, err
 }
 
-func (db *DataStoreMongo) UpdateDevicesGroup(
+// ReplaceDeviceAttributes replaces the attributes of the scope of the
+// device with the given ones in a single pipeline update: the attributes
+// of the scope are removed from the stored device rather than from a copy
+// read beforehand, so that the concurrent writes are not resurrected.
+func (db *DataStoreMongo) ReplaceDeviceAttributes(
 	ctx context.Context,
-	devIDs []model.DeviceID,
-	group model.GroupName,
+	id model.DeviceID,
+	scope string,
+	attrs model.DeviceAttributes,
 ) (*model.UpdateResult, error) {
-	database := db.client.Database(mstore.DbFromContext(ctx, DbName))
-	collDevs := database.Collection(DbDevicesColl)
+	const systemScope = DbDevAttributes + "." + model.AttrScopeSystem
+	const updatedField = systemScope + "-" + model.AttrNameUpdated
+	const createdField = systemScope + "-" + model.AttrNameCreated
 
-	var filter = bson.M{}
-	switch len(devIDs) {
-	case 0:
-		return &model.UpdateResult{}, nil
-	case 1:
-		filter[DbDevId] = devIDs[0]
-	default:
-		filter[DbDevId] = bson.M{"$in": devIDs}
-	}
-	update := bson.M{
-		"$set": bson.M{
-			DbDevAttributesGroup: model.DeviceAttribute{
-				Scope: model.AttrScopeSystem,
-				Name:  DbDevGroup,
-				Value: group,
-			},
-		},
-	}
-	res, err := collDevs.UpdateMany(ctx, filter, update)
+	c := db.database(ctx).
+		Collection(db.names.Devices)
+
+	update, err := makeAttrUpsert(attrs)
 	if err != nil {
 		return nil, err
 	}
-	return &model.UpdateResult{
-		MatchedCount: res.MatchedCount,
-		UpdatedCount: res.ModifiedCount,
-	}, nil
-}
-
-func (db *DataStoreMongo) GetFiltersAttributes(ctx context.Context) ([]model.FilterAttribute, error) {
-	database := db.client.Database(mstore.DbFromContext(ctx, DbName))
-	collDevs := database.Collection(DbDevicesColl)
-
-	const DbCount = "count"
+	for i := range attrs {
+		if attrs[i].Scope != scope {
+			return nil, errors.Errorf(
+				"attribute %s is not of the scope %s", attrs[i].Name, scope)
+		}
+	}
+	db.dualWriteGroups(ctx, update, nil)
 
-	cur, err := collDevs.Aggregate(ctx, []bson.M{
-		{
-			"$project": bson.M{
-				"attributes": bson.M{
-					"$objectToArray": "$" + DbDevAttributes,
-				},
-			},
-		},
-		{
-			"$unwind": "$" + DbDevAttributes,
-		},
-		{
-			"$project": bson.M{
-				DbDevAttributesName:  "$" + DbDevAttributes + ".v." + DbDevAttributesName,
-				DbDevAttributesScope: "$" + DbDevAttributes + ".v." + DbDevAttributesScope,
-			},
-		},
-		{
-			"$group": bson.M{
-				DbDevId: bson.M{
-					DbDevAttributesName:  "$" + DbDevAttributesName,
-					DbDevAttributesScope: "$" + DbDevAttributesScope,
-				},
-				DbCount: bson.M{
-					"$sum": 1,
-				},
-			},
-		},
-		{
-			"$project": bson.M{
-				DbDevId:              0,
-				DbDevAttributesName:  "$" + DbDevId + "." + DbDevAttributesName,
-				DbDevAttributesScope: "$" + DbDevId + "." + DbDevAttributesScope,
-				DbCount:              "$" + DbCount,
-			},
-		},
-		{
-			"$sort": bson.D{
-				{Key: DbCount, Value: -1},
-				{Key: DbDevAttributesScope, Value: 1},
-				{Key: DbDevAttributesName, Value: 1},
-			},
-		},
-		{
-			"$limit": FiltersAttributesLimit,
-		},
-	})
-	if err != nil {
-		return nil, err
+	now := time.Now()
+	setAttrSources(ctx, update, now, model.DeviceAttributes{{Scope: scope}})
+	setAttrTimestamps(update, now, attrs)
+	update[updatedField] = model.DeviceAttribute{
+		Scope: model.AttrScopeSystem,
+		Name:  model.AttrNameUpdated,
+		Value: now,
 	}
-	defer cur.Close(ctx)
+	// the values of a pipeline update are expressions: the strings
+	// starting with $ would be read as field paths
+	for field, value := range update {
+		update[field] = bson.M{"$literal": value}
+	}
+	update[createdField] = bson.M{"$ifNull": bson.A{
+		"$" + createdField,
+		bson.M{"$literal": model.DeviceAttribute{
+			Scope: model.AttrScopeSystem,
+			Name:  model.AttrNameCreated,
+			Value: now,
+		}},
+	}}
 
-	var attributes []model.FilterAttribute
-	err = cur.All(ctx, &attributes)
+	pipeline := mongo.Pipeline{{
+		{Key: "$set", Value: bson.M{
+			DbDevAttributes: bson.M{"$arrayToObject": bson.M{
+				"$filter": bson.M{
+					"input": bson.M{"$objectToArray": bson.M{
+						"$ifNull": bson.A{"$" + DbDevAttributes, bson.M{}},
+					}},
+					"cond": bson.M{"$ne": bson.A{
+						"$$this.v." + DbDevAttributesScope, scope,
+					}},
+				},
+			}},
+		}},
+	}, {
+		{Key: "$set", Value: update},
+	}}
+	res, err := c.UpdateOne(ctx, bson.M{DbDevId: id},
+		db.withTelemetry(ctx, pipeline, now), mopts.Update().SetUpsert(true))
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrap(err, "failed to replace the attributes")
 	}
-
-	return attributes, nil
+	return &model.UpdateResult{
+		MatchedCount: res.MatchedCount,
+		CreatedCount: res.UpsertedCount,
+	}, nil
 }
 
-func (db *DataStoreMongo) UnsetDevicesGroup(
+func (db *DataStoreMongo) UpdateDevicesGroup(
 	ctx context.Context,
-	deviceIDs []model.DeviceID,
+	devIDs []model.DeviceID,
 	group model.GroupName,
 ) (*model.UpdateResult, error) {
-	database := db.client.Database(mstore.DbFromContext(ctx, DbName))
-	collDevs := database.Collection(DbDevicesColl)
+	database := db.database(ctx)
+	collDevs := database.Collection(db.names.Devices)
 
-	var filter bson.D
-	// Add filter on device id (either $in or direct indexing)
-	switch len(deviceIDs) {
-	case 0:
+	if len(devIDs) == 0 {
 		return &model.UpdateResult{}, nil
-	case 1:
-		filter = bson.D{{Key: DbDevId, Value: deviceIDs[0]}}
-	default:
-		filter = bson.D{{Key: DbDevId, Value: bson.M{"$in": deviceIDs}}}
 	}
-	// Append filter on group
-	filter = append(
-		filter,
-		bson.E{Key: DbDevAttributesGroupValue, Value: group},
-	)
-	// Create unset operation on group attribute
-	update := bson.M{
-		"$unset": bson.M{
-			DbDevAttributesGroup: "",
-		},
-	}
-	res, err := collDevs.UpdateMany(ctx, filter, update)
+	filter := devicesGroupQuery(devIDs, nil)
+	res, err := collDevs.UpdateMany(ctx, filter, db.addGroupUpdate(ctx, group))
 	if err != nil {
 		return nil, err
 	}
@@ -741,257 +1118,1766 @@ func (db *DataStoreMongo) UnsetDevicesGroup(
 	}, nil
 }
 
-func predicateToQuery(pred model.FilterPredicate) (bson.D, error) {
-	if err := pred.Validate(); err != nil {
-		return nil, err
-	}
+// addGroupUpdate returns the update adding the devices to the group.
+func (db *DataStoreMongo) addGroupUpdate(
+	ctx context.Context,
+	group model.GroupName,
+) mongo.Pipeline {
+	return db.groupsUpdate(ctx, db.joinGroupExpr(ctx, group))
+}
+
+// joinGroupExpr evaluates to the groups of a device added to the group.
+// With the groups array, the devices keep their other groups; otherwise
+// they are moved to the group, the only one the group attribute holds.
+func (db *DataStoreMongo) joinGroupExpr(
+	ctx context.Context,
+	group model.GroupName,
+) interface{} {
+	if db.GroupsRolloutPhase(ctx) == RolloutOff {
+		return bson.A{group}
+	}
+	return addGroupExpr(group)
+}
+
+func (db *DataStoreMongo) UpdateDevicesGroupByFilter(
+	ctx context.Context,
+	params model.SearchParams,
+	group model.GroupName,
+) (*model.UpdateResult, []model.DeviceID, error) {
+	collDevs := db.database(ctx).Collection(db.names.Devices)
+
+	filter := searchDevicesFilter(params)
+	if len(filter) == 0 {
+		// never add all the devices for a search without filters
+		return &model.UpdateResult{}, nil, nil
+	}
+	// the devices joining the group, whose transitions are recorded
+	cur, err := db.find(ctx, collDevs,
+		bson.M{"$and": []bson.M{
+			filter,
+			{db.groupsField(ctx): bson.M{"$ne": group}},
+		}},
+		mopts.Find().SetProjection(bson.M{DbDevId: 1}),
+	)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to fetch device IDs")
+	}
+	joined, err := decodeDeviceIDs(ctx, cur)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res, err := collDevs.UpdateMany(ctx, filter, db.addGroupUpdate(ctx, group))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to update devices")
+	}
+	return &model.UpdateResult{
+		MatchedCount: res.MatchedCount,
+		UpdatedCount: res.ModifiedCount,
+	}, joined, nil
+}
+
+func (db *DataStoreMongo) UpdateDevicesTags(
+	ctx context.Context,
+	params model.SearchParams,
+	set, unset model.DeviceAttributes,
+) (*model.UpdateResult, []model.DeviceID, error) {
+	const updatedField = DbDevAttributes + "." + model.AttrScopeSystem +
+		"-" + model.AttrNameUpdated
+	collDevs := db.database(ctx).Collection(db.names.Devices)
+
+	filter := searchDevicesFilter(params)
+	if len(filter) == 0 {
+		// never tag all the devices for a search without filters
+		return &model.UpdateResult{}, nil, nil
+	}
+	var matched []model.DeviceID
+	if len(params.DeviceIDs) > 0 {
+		cur, err := db.find(ctx, collDevs, filter,
+			mopts.Find().SetProjection(bson.M{DbDevId: 1}),
+		)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to fetch device IDs")
+		}
+		if matched, err = decodeDeviceIDs(ctx, cur); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	upsert, err := makeAttrUpsert(set)
+	if err != nil {
+		return nil, nil, err
+	}
+	remove, err := makeAttrRemove(unset)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	setAttrSources(ctx, upsert, now, set, unset)
+	setAttrTimestamps(upsert, now, set)
+	upsert[updatedField] = model.DeviceAttribute{
+		Scope: model.AttrScopeSystem,
+		Name:  model.AttrNameUpdated,
+		Value: now,
+	}
+	update := bson.M{"$set": upsert}
+	if len(remove) > 0 {
+		update["$unset"] = remove
+	}
+
+	res, err := collDevs.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to update devices")
+	}
+	return &model.UpdateResult{
+		MatchedCount: res.MatchedCount,
+		UpdatedCount: res.ModifiedCount,
+	}, matched, nil
+}
+
+// devicesGroupQuery returns the query selecting the devices to add to
+// a group: the devices with the IDs, if any, matching the filters, if any.
+func devicesGroupQuery(
+	devIDs []model.DeviceID,
+	filters []model.FilterPredicate,
+) bson.M {
+	queryFilters := filterPredicatesQuery(filters)
+	switch len(devIDs) {
+	case 0:
+	case 1:
+		queryFilters = append(queryFilters, bson.M{DbDevId: devIDs[0]})
+	default:
+		queryFilters = append(queryFilters,
+			bson.M{DbDevId: bson.M{"$in": devIDs}})
+	}
+	switch len(queryFilters) {
+	case 0:
+		return bson.M{}
+	case 1:
+		return queryFilters[0]
+	default:
+		return bson.M{"$and": queryFilters}
+	}
+}
+
+func (db *DataStoreMongo) PreviewDevicesGroup(
+	ctx context.Context,
+	assignment model.GroupAssignment,
+	group model.GroupName,
+) (*model.GroupAssignmentPreview, error) {
+	collDevs := db.database(ctx).Collection(db.names.Devices)
+
+	preview := &model.GroupAssignmentPreview{}
+	if len(assignment.DeviceIDs) == 0 && len(assignment.Filters) == 0 {
+		return preview, nil
+	}
+	filter := devicesGroupQuery(assignment.DeviceIDs, assignment.Filters)
+	matched, err := collDevs.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to count devices")
+	}
+	preview.Matched = int(matched)
+	if matched > 0 {
+		already, err := collDevs.CountDocuments(ctx, bson.M{"$and": []bson.M{
+			filter, {db.groupsField(ctx): group},
+		}})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to count devices")
+		}
+		preview.AlreadyInGroup = int(already)
+	}
+
+	if len(assignment.DeviceIDs) > 0 {
+		ids := make(map[model.DeviceID]struct{}, len(assignment.DeviceIDs))
+		for _, id := range assignment.DeviceIDs {
+			ids[id] = struct{}{}
+		}
+		found, err := collDevs.CountDocuments(ctx,
+			devicesGroupQuery(assignment.DeviceIDs, nil))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to count devices")
+		}
+		preview.NotFound = len(ids) - int(found)
+	}
+	return preview, nil
+}
+
+func (db *DataStoreMongo) GetFiltersAttributes(ctx context.Context) ([]model.FilterAttribute, error) {
+	database := db.database(ctx)
+	collDevs := database.Collection(db.names.Devices)
+
+	const DbCount = "count"
+
+	cur, err := db.aggregate(ctx, collDevs, []bson.M{
+		{
+			"$project": bson.M{
+				"attributes": bson.M{
+					"$objectToArray": "$" + DbDevAttributes,
+				},
+			},
+		},
+		{
+			"$unwind": "$" + DbDevAttributes,
+		},
+		{
+			"$project": bson.M{
+				DbDevAttributesName:  "$" + DbDevAttributes + ".v." + DbDevAttributesName,
+				DbDevAttributesScope: "$" + DbDevAttributes + ".v." + DbDevAttributesScope,
+			},
+		},
+		{
+			"$group": bson.M{
+				DbDevId: bson.M{
+					DbDevAttributesName:  "$" + DbDevAttributesName,
+					DbDevAttributesScope: "$" + DbDevAttributesScope,
+				},
+				DbCount: bson.M{
+					"$sum": 1,
+				},
+			},
+		},
+		{
+			"$project": bson.M{
+				DbDevId:              0,
+				DbDevAttributesName:  "$" + DbDevId + "." + DbDevAttributesName,
+				DbDevAttributesScope: "$" + DbDevId + "." + DbDevAttributesScope,
+				DbCount:              "$" + DbCount,
+			},
+		},
+		{
+			"$sort": bson.D{
+				{Key: DbCount, Value: -1},
+				{Key: DbDevAttributesScope, Value: 1},
+				{Key: DbDevAttributesName, Value: 1},
+			},
+		},
+		{
+			"$limit": FiltersAttributesLimit,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var attributes []model.FilterAttribute
+	err = decodeAll(ctx, cur, &attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	return attributes, nil
+}
+
+func (db *DataStoreMongo) UnsetDevicesGroup(
+	ctx context.Context,
+	deviceIDs []model.DeviceID,
+	group model.GroupName,
+) (*model.UpdateResult, error) {
+	var filter bson.D
+	// Add filter on device id (either $in or direct indexing)
+	switch len(deviceIDs) {
+	case 0:
+		return &model.UpdateResult{}, nil
+	case 1:
+		filter = bson.D{{Key: DbDevId, Value: deviceIDs[0]}}
+	default:
+		filter = bson.D{{Key: DbDevId, Value: bson.M{"$in": deviceIDs}}}
+	}
+	return db.pullGroup(ctx, filter, group)
+}
+
+// ReplaceDevicesGroup adds the devices to the group and removes the other
+// members from it in a single update, writing each device once and
+// atomically: a concurrent replace can no longer remove the devices
+// another one added in between its steps. The members leaving the group
+// are looked up before, only to record their transitions.
+func (db *DataStoreMongo) ReplaceDevicesGroup(
+	ctx context.Context,
+	devIDs []model.DeviceID,
+	group model.GroupName,
+) (*model.UpdateResult, []model.DeviceID, error) {
+	collDevs := db.database(ctx).Collection(db.names.Devices)
+	if devIDs == nil {
+		// $nin needs an array; nil encodes as null
+		devIDs = []model.DeviceID{}
+	}
+
+	// the devices leaving the group, whose transitions are recorded
+	cur, err := db.find(ctx, collDevs,
+		bson.D{
+			{Key: DbDevId, Value: bson.M{"$nin": devIDs}},
+			{Key: db.groupsField(ctx), Value: group},
+		},
+		mopts.Find().SetProjection(bson.M{DbDevId: 1}),
+	)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to fetch device IDs")
+	}
+	left, err := decodeDeviceIDs(ctx, cur)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res, err := collDevs.UpdateMany(ctx,
+		bson.M{"$or": bson.A{
+			bson.D{db.groupMemberFilter(ctx, group)},
+			bson.M{DbDevId: bson.M{"$in": devIDs}},
+		}},
+		db.groupsUpdate(ctx, bson.M{"$cond": bson.A{
+			bson.M{"$in": bson.A{"$" + DbDevId, devIDs}},
+			db.joinGroupExpr(ctx, group),
+			removeGroupExpr(group),
+		}}),
+	)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to replace the devices of group")
+	}
+	// the members leaving the group are not counted as matching
+	matched := res.MatchedCount - int64(len(left))
+	if matched < 0 {
+		matched = 0
+	}
+	return &model.UpdateResult{
+		MatchedCount: matched,
+		UpdatedCount: res.ModifiedCount,
+	}, left, nil
+}
+
+func (db *DataStoreMongo) DeleteGroup(
+	ctx context.Context,
+	group model.GroupName,
+) (*model.UpdateResult, error) {
+	return db.pullGroup(ctx, nil, group)
+}
+
+// pullGroup removes the devices matching the filter from the group,
+// keeping their other groups.
+func (db *DataStoreMongo) pullGroup(
+	ctx context.Context,
+	filter bson.D,
+	group model.GroupName,
+) (*model.UpdateResult, error) {
+	collDevs := db.database(ctx).Collection(db.names.Devices)
+
+	res, err := collDevs.UpdateMany(ctx,
+		append(filter, db.groupMemberFilter(ctx, group)),
+		db.groupsUpdate(ctx, removeGroupExpr(group)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &model.UpdateResult{
+		MatchedCount: res.MatchedCount,
+		UpdatedCount: res.ModifiedCount,
+	}, nil
+}
+
+// groupMemberFilter matches the members of the group, in the group
+// attribute or in the groups array, whichever is written.
+func (db *DataStoreMongo) groupMemberFilter(
+	ctx context.Context,
+	group model.GroupName,
+) bson.E {
+	if db.GroupsRolloutPhase(ctx) == RolloutOff {
+		return bson.E{Key: DbDevAttributesGroupValue, Value: group}
+	}
+	return bson.E{Key: "$or", Value: bson.A{
+		bson.M{DbDevAttributesGroupValue: group},
+		bson.M{DbDevGroups: group},
+	}}
+}
+
+func predicateToQuery(pred model.FilterPredicate) (bson.D, error) {
+	if err := pred.Validate(); err != nil {
+		return nil, err
+	}
 	name := fmt.Sprintf(
 		"%s.%s-%s.value", DbDevAttributes, pred.Scope, model.GetDeviceAttributeNameReplacer().Replace(pred.Attribute),
 	)
+	if pred.IsPattern() {
+		pattern, ignoreCase := pred.Regex()
+		return bson.D{{Key: name, Value: regexQuery(pattern, ignoreCase)}}, nil
+	}
 	return bson.D{{
 		Key: name, Value: bson.D{{Key: pred.Type, Value: pred.Value}},
 	}}, nil
 }
 
-func (db *DataStoreMongo) ListGroups(
+func (db *DataStoreMongo) ListGroups(
+	ctx context.Context,
+	filters []model.FilterPredicate,
+) ([]model.GroupName, error) {
+	c := db.database(ctx).
+		Collection(db.names.Devices)
+
+	groupsField := db.groupsField(ctx)
+	fltr, err := groupsQuery(groupsField, filters)
+	if err != nil {
+		return nil, err
+	}
+	results, err := c.Distinct(
+		ctx, groupsField, fltr,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]model.GroupName, len(results))
+	for i, d := range results {
+		groups[i] = model.GroupName(d.(string))
+	}
+	return groups, nil
+}
+
+// groupsQuery matches the devices with a group, filtered by the
+// predicates.
+func groupsQuery(
+	groupsField string,
+	filters []model.FilterPredicate,
+) (bson.D, error) {
+	fltr := bson.D{{
+		Key: groupsField, Value: bson.M{"$exists": true},
+	}}
+	for _, p := range filters {
+		q, err := predicateToQuery(p)
+		if err != nil {
+			return nil, errors.Wrap(
+				err, "store: bad filter predicate",
+			)
+		}
+		fltr = append(fltr, q...)
+	}
+	return fltr, nil
+}
+
+func (db *DataStoreMongo) SearchGroups(
+	ctx context.Context,
+	q store.GroupsQuery,
+) ([]model.GroupName, int, error) {
+	const DbCount = "count"
+	c := db.database(ctx).
+		Collection(db.names.Devices)
+
+	groupsField := db.groupsField(ctx)
+	match := bson.D{{
+		Key: groupsField, Value: bson.M{"$exists": true},
+	}}
+	if q.Prefix != "" {
+		// anchored case-sensitive regular expressions are served by
+		// the index of the group
+		match = bson.D{{
+			Key: groupsField, Value: primitive.Regex{
+				Pattern: "^" + regexp.QuoteMeta(q.Prefix),
+			},
+		}}
+	}
+	for _, p := range q.Filters {
+		pred, err := predicateToQuery(p)
+		if err != nil {
+			return nil, -1, errors.Wrap(err, "store: bad filter predicate")
+		}
+		match = append(match, pred...)
+	}
+	page := bson.A{}
+	if q.Skip > 0 {
+		page = append(page, bson.M{"$skip": q.Skip})
+	}
+	if q.Limit > 0 {
+		page = append(page, bson.M{"$limit": q.Limit})
+	}
+	cur, err := db.aggregate(ctx, c, []bson.M{
+		{
+			"$match": match,
+		},
+		{
+			// a no-op for the single group format
+			"$unwind": "$" + groupsField,
+		},
+		{
+			"$group": bson.M{DbDevId: "$" + groupsField},
+		},
+		{
+			"$sort": bson.M{DbDevId: 1},
+		},
+		{
+			"$facet": bson.M{
+				"total":  bson.A{bson.M{"$count": DbCount}},
+				"groups": page,
+			},
+		},
+	})
+	if err != nil {
+		return nil, -1, errors.Wrap(err, "failed to search groups")
+	}
+	var res []struct {
+		Total []struct {
+			Count int `bson:"count"`
+		} `bson:"total"`
+		Groups []struct {
+			Name model.GroupName `bson:"_id"`
+		} `bson:"groups"`
+	}
+	if err = decodeAll(ctx, cur, &res); err != nil {
+		return nil, -1, errors.Wrap(err, "failed to search groups")
+	}
+	groups := []model.GroupName{}
+	if len(res) == 0 || len(res[0].Total) == 0 {
+		return groups, 0, nil
+	}
+	for _, g := range res[0].Groups {
+		groups = append(groups, g.Name)
+	}
+	return groups, res[0].Total[0].Count, nil
+}
+
+func (db *DataStoreMongo) GetDevicesByGroup(
+	ctx context.Context,
+	group model.GroupName,
+	q store.ListQuery,
+) ([]model.DeviceID, int, error) {
+	c := db.database(ctx).
+		Collection(db.names.Devices)
+
+	filter := bson.M{db.groupsField(ctx): group}
+	if len(q.GroupNames) > 0 {
+		filter = bson.M{db.groupsField(ctx): bson.M{"$in": q.GroupNames}}
+	}
+	result := c.FindOne(ctx, filter,
+		mopts.FindOne().SetProjection(bson.M{DbDevId: 1}))
+	if result == nil {
+		return nil, -1, store.ErrGroupNotFound
+	}
+
+	var dev model.Device
+	err := result.Decode(&dev)
+	if err != nil {
+		return nil, -1, store.ErrGroupNotFound
+	}
+
+	hasGroup := group != ""
+	q.HasGroup = &hasGroup
+	q.GroupName = string(group)
+	q.IDsOnly = true
+	cursor, totalDevices, e := db.findDevices(ctx, q)
+	if e != nil {
+		return nil, -1, errors.Wrap(e, "failed to get device list for group")
+	}
+
+	resIds, e := decodeDeviceIDs(ctx, cursor)
+	if e != nil {
+		return nil, -1, errors.Wrap(e, "failed to get device list for group")
+	}
+	return resIds, totalDevices, nil
+}
+
+func (db *DataStoreMongo) GetDeviceGroups(
+	ctx context.Context,
+	id model.DeviceID,
+) ([]model.GroupName, error) {
+	c := db.database(ctx).
+		Collection(db.names.Devices)
+
+	var dev model.Device
+	findOpts := mopts.FindOne().
+		SetProjection(db.groupsProjection(ctx))
+	err := c.FindOne(ctx, bson.M{DbDevId: id}, findOpts).Decode(&dev)
+	if err != nil {
+		return nil, store.ErrDevNotFound
+	}
+
+	return deviceGroups(dev), nil
+}
+
+func (db *DataStoreMongo) GetDevicesGroups(
+	ctx context.Context,
+	ids []model.DeviceID,
+) (map[model.DeviceID][]model.GroupName, error) {
+	groups := make(map[model.DeviceID][]model.GroupName, len(ids))
+	if len(ids) == 0 {
+		return groups, nil
+	}
+	c := db.database(ctx).
+		Collection(db.names.Devices)
+
+	findOpts := mopts.Find().
+		SetProjection(db.groupsProjection(ctx))
+	cur, err := db.find(ctx, c, bson.M{DbDevId: bson.M{"$in": ids}}, findOpts)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch device groups")
+	}
+
+	err = iterateCursor(ctx, cur, func(cur *mongo.Cursor) error {
+		var dev model.Device
+		if err := cur.Decode(&dev); err != nil {
+			return errors.Wrap(err, "failed to decode device")
+		}
+		groups[dev.ID] = deviceGroups(dev)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch device groups")
+	}
+	return groups, nil
+}
+
+// groupsProjection returns the projection of the fields the groups of
+// the devices are read from.
+func (db *DataStoreMongo) groupsProjection(ctx context.Context) bson.M {
+	if db.GroupsRolloutPhase(ctx) == RolloutDualRead {
+		return bson.M{DbDevAttributesGroup: 1, DbDevGroups: 1}
+	}
+	return bson.M{DbDevAttributesGroup: 1}
+}
+
+// deviceGroups returns the groups of the device decoded with
+// the groupsProjection.
+func deviceGroups(dev model.Device) []model.GroupName {
+	if len(dev.Groups) > 0 {
+		return dev.Groups
+	} else if dev.Group != "" {
+		return []model.GroupName{dev.Group}
+	}
+	return nil
+}
+
+func (db *DataStoreMongo) DeleteDevices(
+	ctx context.Context, ids []model.DeviceID,
+) (*model.UpdateResult, error) {
+	var filter = bson.M{}
+	database := db.database(ctx)
+	collDevs := database.Collection(db.names.Devices)
+
+	switch len(ids) {
+	case 0:
+		// This is a no-op, don't bother requesting mongo.
+		return &model.UpdateResult{DeletedCount: 0}, nil
+	case 1:
+		filter[DbDevId] = ids[0]
+	default:
+		filter[DbDevId] = bson.M{"$in": ids}
+	}
+	res, err := collDevs.DeleteMany(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	_, err = database.Collection(DbColdAttributesColl).DeleteMany(ctx, filter)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to delete cold attributes")
+	}
+	return &model.UpdateResult{
+		DeletedCount: res.DeletedCount,
+	}, nil
+}
+
+func (db *DataStoreMongo) GetAllDeviceIDs(
+	ctx context.Context,
+) ([]model.DeviceID, error) {
+	c := db.database(ctx).
+		Collection(db.names.Devices)
+
+	findOpts := mopts.Find().
+		SetProjection(bson.M{DbDevId: 1})
+	cur, err := db.find(ctx, c, bson.M{}, findOpts)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch device IDs")
+	}
+	return decodeDeviceIDs(ctx, cur)
+}
+
+// decodeDeviceIDs collects the device IDs from the cursor and closes it;
+// the IDs are read from the raw documents, skipping the unmarshalling of
+// whole devices.
+func decodeDeviceIDs(ctx context.Context, cur *mongo.Cursor) ([]model.DeviceID, error) {
+	ids := []model.DeviceID{}
+	err := iterateCursor(ctx, cur, func(cur *mongo.Cursor) error {
+		id, err := deviceIDFromRaw(cur.Current)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch device IDs")
+	}
+	return ids, nil
+}
+
+// deviceIDFromRaw reads the device ID of the raw device document.
+func deviceIDFromRaw(doc bson.Raw) (model.DeviceID, error) {
+	value, err := doc.LookupErr(DbDevId)
+	if err != nil {
+		return model.NilDeviceID, errors.Wrap(err, "failed to decode device")
+	}
+	id, ok := value.StringValueOK()
+	if !ok {
+		return model.NilDeviceID, errors.Errorf(
+			"failed to decode device: unexpected ID type %s", value.Type)
+	}
+	return model.DeviceID(id), nil
+}
+
+func (db *DataStoreMongo) GetAllAttributeNames(ctx context.Context) ([]string, error) {
+	c := db.database(ctx).Collection(db.names.Devices)
+
+	project := bson.M{
+		"$project": bson.M{
+			"arrayofkeyvalue": bson.M{
+				"$objectToArray": "$$ROOT.attributes",
+			},
+		},
+	}
+
+	unwind := bson.M{
+		"$unwind": "$arrayofkeyvalue",
+	}
+
+	group := bson.M{
+		"$group": bson.M{
+			"_id": nil,
+			"allkeys": bson.M{
+				"$addToSet": "$arrayofkeyvalue.v.name",
+			},
+		},
+	}
+
+	l := log.FromContext(ctx)
+	cursor, err := db.aggregate(ctx, c, []bson.M{
+		project,
+		unwind,
+		group,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer closeCursor(cursor)
+
+	cursor.Next(ctx)
+	elem := &bson.D{}
+	err = cursor.Decode(elem)
+	if err != nil {
+		if err != io.EOF {
+			return nil, errors.Wrap(err, "failed to get attributes")
+		} else {
+			return make([]string, 0), nil
+		}
+	}
+	m := elem.Map()
+	results := m["allkeys"].(primitive.A)
+	attributeNames := make([]string, len(results))
+	for i, d := range results {
+		attributeNames[i] = d.(string)
+		l.Debugf("GetAllAttributeNames got: '%v'", d)
+	}
+
+	return attributeNames, nil
+}
+
+// filterPredicatesQuery returns the query filters matching the devices
+// to the filter predicates.
+func filterPredicatesQuery(filters []model.FilterPredicate) []bson.M {
+	queryFilters := make([]bson.M, 0, len(filters))
+	for _, filter := range filters {
+		op := filter.Type
+		value := filter.Value
+		var field string
+		if filter.Scope == model.AttrScopeIdentity && filter.Attribute == model.AttrNameID {
+			field = DbDevId
+		} else if filter.Scope == model.AttrScopeTelemetry {
+			field = DbDevTelemetry + "." + filter.Attribute
+			value = telemetryFilterValue(filter)
+		} else {
+			name := fmt.Sprintf("%s-%s", filter.Scope, model.GetDeviceAttributeNameReplacer().Replace(filter.Attribute))
+			field = fmt.Sprintf("%s.%s.%s", DbDevAttributes, name, DbDevAttributesValue)
+		}
+		var cond interface{} = bson.M{op: value}
+		if filter.IsPattern() {
+			pattern, ignoreCase := filter.Regex()
+			cond = regexQuery(pattern, ignoreCase)
+		}
+		if len(filter.Aliases) == 0 {
+			queryFilters = append(queryFilters, bson.M{field: cond})
+			continue
+		}
+		names := make([]bson.M, 0, len(filter.Aliases)+1)
+		names = append(names, bson.M{field: cond})
+		for _, alias := range filter.Aliases {
+			names = append(names, bson.M{
+				attrValueField(filter.Scope, alias): cond,
+			})
+		}
+		// the devices report the attribute under one of its names: the
+		// filters matching the devices without the attribute must hold
+		// under all of them
+		if isNegativeSelector(op, value) {
+			queryFilters = append(queryFilters, bson.M{"$and": names})
+		} else {
+			queryFilters = append(queryFilters, bson.M{"$or": names})
+		}
+	}
+	return queryFilters
+}
+
+// attrValueField returns the field of the value of the attribute.
+func attrValueField(scope, name string) string {
+	return fmt.Sprintf("%s.%s-%s.%s", DbDevAttributes, scope,
+		model.GetDeviceAttributeNameReplacer().Replace(name),
+		DbDevAttributesValue)
+}
+
+// isNegativeSelector tells whether the selector matches the devices
+// without the attribute.
+func isNegativeSelector(op string, value interface{}) bool {
+	switch op {
+	case "$ne", "$nin":
+		return true
+	case "$exists":
+		exists, _ := value.(bool)
+		return !exists
+	}
+	return false
+}
+
+func (db *DataStoreMongo) SearchDevices(ctx context.Context, searchParams model.SearchParams) ([]model.Device, int, error) {
+	c := db.database(ctx).Collection(db.names.Devices)
+
+	findQuery, findOptions, sortFields := searchDevicesQuery(searchParams)
+
+	var deadline time.Time
+	if searchParams.MaxTimeMS > 0 {
+		maxTime := time.Duration(searchParams.MaxTimeMS) * time.Millisecond
+		deadline = time.Now().Add(maxTime)
+		findOptions.SetMaxTime(maxTime)
+	}
+
+	devices := []model.Device{}
+	// the devices are counted regardless of the cursor
+	cursor, err := db.findSorted(ctx, c,
+		withKeyset(findQuery, searchParams.Cursor), findOptions, sortFields)
+	if isMaxTimeExpired(err) {
+		return devices, -1, store.ErrPartialResults
+	} else if err != nil {
+		return nil, -1, errors.Wrap(err, "failed to search devices")
+	}
+
+	err = iterateCursor(ctx, cursor, func(cursor *mongo.Cursor) error {
+		var dev model.Device
+		if err := cursor.Decode(&dev); err != nil {
+			return err
+		}
+		devices = append(devices, dev)
+		return nil
+	})
+	if isMaxTimeExpired(err) {
+		return devices, -1, store.ErrPartialResults
+	} else if err != nil {
+		return nil, -1, errors.Wrap(err, "failed to search devices")
+	}
+
+	countOptions := mopts.Count()
+	if !deadline.IsZero() {
+		// the count gets what is left of the time limit
+		maxTime := time.Until(deadline)
+		if maxTime < time.Millisecond {
+			return devices, -1, store.ErrPartialResults
+		}
+		countOptions.SetMaxTime(maxTime)
+	}
+	count, err := c.CountDocuments(ctx, findQuery, countOptions)
+	if isMaxTimeExpired(err) {
+		return devices, -1, store.ErrPartialResults
+	} else if err != nil {
+		return nil, -1, errors.Wrap(err, "failed to search devices")
+	}
+
+	db.sampleSearch(ctx, searchParams)
+
+	return devices, int(count), nil
+}
+
+// searchDevicesQuery returns the query and the options finding the page
+// of devices selected by the search parameters, along with the fields
+// computed for the sort (see findSorted); the query leaves out the cursor
+// of the keyset pagination, so that it counts all the devices.
+func searchDevicesQuery(searchParams model.SearchParams) (bson.M, *mopts.FindOptions, bson.M) {
+	findQuery := searchDevicesFilter(searchParams)
+
+	findOptions := mopts.Find()
+	if searchParams.Cursor == nil {
+		findOptions.SetSkip(int64((searchParams.Page - 1) * searchParams.PerPage))
+	}
+	findOptions.SetLimit(int64(searchParams.PerPage))
+
+	if projection := devicesProjection(false, searchParams.Attributes); projection != nil {
+		findOptions.SetProjection(projection)
+	}
+
+	var sortFields bson.M
+	if len(searchParams.Sort) > 0 {
+		var sort bson.D
+		sort, sortFields = attributesSort(searchParams.Sort)
+		findOptions.SetSort(sort)
+	} else if searchParams.Cursor != nil {
+		// the keyset pagination needs a total order
+		findOptions.SetSort(withIDTieBreaker(bson.D{}))
+	}
+
+	return findQuery, findOptions, sortFields
+}
+
+// searchDevicesFilter returns the match expression of the devices
+// selected by the filters, the device IDs and the completeness range of
+// the search parameters.
+func searchDevicesFilter(searchParams model.SearchParams) bson.M {
+	queryFilters := filterPredicatesQuery(searchParams.Filters)
+
+	// FIXME: remove after migrating ids to attributes
+	if len(searchParams.DeviceIDs) > 0 {
+		queryFilters = append(queryFilters, bson.M{"_id": bson.M{"$in": searchParams.DeviceIDs}})
+	}
+	if searchParams.Completeness != nil {
+		queryFilters = append(queryFilters, completenessQuery(
+			*searchParams.Completeness, searchParams.RequiredAttributes,
+		))
+	}
+
+	findQuery := bson.M{}
+	if len(queryFilters) > 0 {
+		findQuery["$and"] = queryFilters
+	}
+	return findQuery
+}
+
+// isMaxTimeExpired tells whether the operation failed for exceeding
+// its maxTimeMS.
+func isMaxTimeExpired(err error) bool {
+	const codeMaxTimeMSExpired = 50
+	var serr mongo.ServerError
+	return errors.As(err, &serr) && serr.HasErrorCode(codeMaxTimeMSExpired)
+}
+
+// devicesProjection returns the projection limiting the device documents
+// to the requested fields, so that the attributes thrown away by the caller
+// are neither transferred nor decoded; returns nil if the whole documents
+// are requested.
+func devicesProjection(idsOnly bool, attributes []model.SelectAttribute) bson.M {
+	if idsOnly {
+		return bson.M{DbDevId: 1}
+	} else if len(attributes) == 0 {
+		return nil
+	}
+	projection := bson.M{DbDevUpdatedTs: 1}
+	for _, attribute := range attributes {
+		if attribute.Scope == model.AttrScopeIdentity &&
+			attribute.Attribute == model.AttrNameID {
+			// the device ID is always returned
+			continue
+		}
+		name := fmt.Sprintf("%s-%s", attribute.Scope, model.GetDeviceAttributeNameReplacer().Replace(attribute.Attribute))
+		field := fmt.Sprintf("%s.%s", DbDevAttributes, name)
+		projection[field] = 1
+	}
+	return projection
+}
+
+// withIDTieBreaker appends the device ID to the sort, so that the devices
+// sharing the same sort values are always returned in the same order and
+// consecutive pages neither overlap nor skip devices.
+func withIDTieBreaker(sort bson.D) bson.D {
+	return append(sort, bson.E{Key: DbDevId, Value: 1})
+}
+
+// sortNullsField prefixes the fields added to the devices to sort the ones
+// without a sort attribute apart from the values of the others.
+const sortNullsField = "_sort_nulls_"
+
+// sortAliasField prefixes the fields added to the devices to sort them by
+// the value of an attribute under any of its names.
+const sortAliasField = "_sort_alias_"
+
+// attributesSort returns the sort of the devices by the attributes in turn
+// and by the ID. The attributes placing the devices without them
+// explicitly are preceded by a key telling whether the device has
+// the attribute, and the attributes with aliases sort by their value
+// under any of their names; the returned fields compute these keys, nil
+// if there are none.
+func attributesSort(criteria []model.SortCriteria) (bson.D, bson.M) {
+	var nullsFields bson.M
+	sort := make(bson.D, 0, len(criteria))
+	for n, s := range criteria {
+		name := fmt.Sprintf("%s-%s", s.Scope, model.GetDeviceAttributeNameReplacer().Replace(s.Attribute))
+		field := fmt.Sprintf("%s.%s.%s", DbDevAttributes, name, DbDevAttributesValue)
+		var value interface{} = "$" + field
+		if len(s.Aliases) > 0 {
+			// the value under the first of the names the device has
+			var alias interface{}
+			for k := len(s.Aliases) - 1; k >= 0; k-- {
+				alias = bson.M{"$ifNull": bson.A{
+					"$" + attrValueField(s.Scope, s.Aliases[k]), alias,
+				}}
+			}
+			value = bson.M{"$ifNull": bson.A{value, alias}}
+			if nullsFields == nil {
+				nullsFields = bson.M{}
+			}
+			field = fmt.Sprintf("%s%d", sortAliasField, n)
+			nullsFields[field] = value
+		}
+		if s.Nulls != "" {
+			if nullsFields == nil {
+				nullsFields = bson.M{}
+			}
+			key := fmt.Sprintf("%s%d", sortNullsField, n)
+			// any value, but null, is greater than null
+			nullsFields[key] = bson.M{"$gt": bson.A{value, nil}}
+			nullsOrder := -1
+			if s.Nulls == model.SortNullsFirst {
+				nullsOrder = 1
+			}
+			sort = append(sort, bson.E{Key: key, Value: nullsOrder})
+		}
+		order := 1
+		if s.Order == "desc" {
+			order = -1
+		}
+		sort = append(sort, bson.E{Key: field, Value: order})
+	}
+	return withIDTieBreaker(sort), nullsFields
+}
+
+// findSorted runs the query as find, or as an aggregation computing
+// the fields of the sort first if there are any. The sort by the computed
+// fields cannot use the indexes, and so requires an in-memory sort of all
+// the matching devices.
+func (db *DataStoreMongo) findSorted(
+	ctx context.Context,
+	c *mongo.Collection,
+	query bson.M,
+	opts *mopts.FindOptions,
+	sortFields bson.M,
+) (*mongo.Cursor, error) {
+	if len(sortFields) == 0 {
+		return db.find(ctx, c, query, opts)
+	}
+	pipeline := []bson.M{
+		{"$match": query},
+		{"$addFields": sortFields},
+		{"$sort": opts.Sort},
+	}
+	if opts.Skip != nil && *opts.Skip > 0 {
+		pipeline = append(pipeline, bson.M{"$skip": *opts.Skip})
+	}
+	if opts.Limit != nil && *opts.Limit > 0 {
+		pipeline = append(pipeline, bson.M{"$limit": *opts.Limit})
+	}
+	if opts.Projection != nil {
+		// the projections include the selected fields only
+		pipeline = append(pipeline, bson.M{"$project": opts.Projection})
+	} else {
+		exclude := make(bson.M, len(sortFields))
+		for key := range sortFields {
+			exclude[key] = 0
+		}
+		pipeline = append(pipeline, bson.M{"$project": exclude})
+	}
+	aggOpts := mopts.Aggregate()
+	if opts.MaxTime != nil {
+		aggOpts.SetMaxTime(*opts.MaxTime)
+	}
+	return db.aggregate(ctx, c, pipeline, aggOpts)
+}
+
+func (db *DataStoreMongo) GetAttributeValueCounts(
+	ctx context.Context,
+	scope, name string,
+	limit int,
+) ([]model.AttributeValueCount, error) {
+	const DbCount = "count"
+	c := db.database(ctx).
+		Collection(db.names.Devices)
+
+	field := fmt.Sprintf("%s.%s-%s.%s", DbDevAttributes, scope,
+		model.GetDeviceAttributeNameReplacer().Replace(name),
+		DbDevAttributesValue)
+	cur, err := db.aggregate(ctx, c, []bson.M{
+		{
+			"$match": bson.M{field: bson.M{"$exists": true}},
+		},
+		{
+			"$group": bson.M{
+				DbDevId: "$" + field,
+				DbCount: bson.M{"$sum": 1},
+			},
+		},
+		{
+			"$sort": bson.D{
+				{Key: DbCount, Value: -1},
+				{Key: DbDevId, Value: 1},
+			},
+		},
+		{
+			"$limit": limit,
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to count attribute values")
+	}
+	var counts []model.AttributeValueCount
+	if err = decodeAll(ctx, cur, &counts); err != nil {
+		return nil, errors.Wrap(err, "failed to count attribute values")
+	}
+	return counts, nil
+}
+
+func (db *DataStoreMongo) CountAttributeDevices(
+	ctx context.Context,
+	scope, name string,
+) (int, error) {
+	c := db.database(ctx).
+		Collection(db.names.Devices)
+
+	field := fmt.Sprintf("%s.%s-%s.%s", DbDevAttributes, scope,
+		model.GetDeviceAttributeNameReplacer().Replace(name),
+		DbDevAttributesValue)
+	n, err := c.CountDocuments(ctx, bson.M{field: bson.M{"$exists": true}})
+	if err != nil {
+		return -1, errors.Wrap(err, "failed to count devices")
+	}
+	return int(n), nil
+}
+
+func (db *DataStoreMongo) GetAttributeValueSets(
+	ctx context.Context,
+	scope, name string,
+	samples, limit int,
+) ([]model.AttributeValueSet, error) {
+	const (
+		DbCount   = "count"
+		DbDevices = "devices"
+	)
+	c := db.database(ctx).
+		Collection(db.names.Devices)
+
+	field := fmt.Sprintf("%s.%s-%s.%s", DbDevAttributes, scope,
+		model.GetDeviceAttributeNameReplacer().Replace(name),
+		DbDevAttributesValue)
+	cur, err := db.aggregate(ctx, c, []bson.M{
+		{
+			"$match": bson.M{field: bson.M{"$exists": true}},
+		},
+		{
+			// the scalar values are sets of a single value
+			"$group": bson.M{
+				DbDevId: bson.M{"$cond": bson.A{
+					bson.M{"$isArray": "$" + field},
+					"$" + field,
+					bson.A{"$" + field},
+				}},
+				DbCount:   bson.M{"$sum": 1},
+				DbDevices: bson.M{"$push": "$" + DbDevId},
+			},
+		},
+		{
+			"$sort": bson.D{
+				{Key: DbCount, Value: -1},
+				{Key: DbDevId, Value: 1},
+			},
+		},
+		{
+			"$limit": limit,
+		},
+		{
+			"$project": bson.M{
+				DbCount:   1,
+				DbDevices: bson.M{"$slice": bson.A{"$" + DbDevices, samples}},
+			},
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to group attribute values")
+	}
+	var sets []model.AttributeValueSet
+	if err = decodeAll(ctx, cur, &sets); err != nil {
+		return nil, errors.Wrap(err, "failed to group attribute values")
+	}
+	return sets, nil
+}
+
+func (db *DataStoreMongo) CountDevicesByAttributePair(
 	ctx context.Context,
-	filters []model.FilterPredicate,
-) ([]model.GroupName, error) {
-	c := db.client.
-		Database(mstore.DbFromContext(ctx, DbName)).
-		Collection(DbDevicesColl)
+	q store.ListQuery,
+	row, column model.SelectAttribute,
+	limit int,
+) ([]model.AttributePairCount, error) {
+	const (
+		DbCount  = "count"
+		DbRow    = "row"
+		DbColumn = "column"
+	)
+	c := db.database(ctx).
+		Collection(db.names.Devices)
 
-	fltr := bson.D{{
-		Key: DbDevAttributesGroupValue, Value: bson.M{"$exists": true},
-	}}
-	if len(fltr) > 0 {
-		for _, p := range filters {
-			q, err := predicateToQuery(p)
-			if err != nil {
-				return nil, errors.Wrap(
-					err, "store: bad filter predicate",
-				)
-			}
-			fltr = append(fltr, q...)
-		}
+	value := func(attr model.SelectAttribute) bson.M {
+		field := fmt.Sprintf("%s.%s-%s.%s", DbDevAttributes, attr.Scope,
+			model.GetDeviceAttributeNameReplacer().Replace(attr.Attribute),
+			DbDevAttributesValue)
+		// the missing attributes are grouped with the null values
+		return bson.M{"$ifNull": bson.A{"$" + field, nil}}
 	}
-	results, err := c.Distinct(
-		ctx, DbDevAttributesGroupValue, fltr,
-	)
+	cur, err := db.aggregate(ctx, c, []bson.M{
+		{
+			"$match": db.devicesQuery(ctx, q),
+		},
+		{
+			"$group": bson.M{
+				DbDevId: bson.M{
+					DbRow:    value(row),
+					DbColumn: value(column),
+				},
+				DbCount: bson.M{"$sum": 1},
+			},
+		},
+		{
+			"$sort": bson.D{
+				{Key: DbCount, Value: -1},
+				{Key: DbDevId, Value: 1},
+			},
+		},
+		{
+			"$limit": limit,
+		},
+		{
+			"$project": bson.M{
+				DbDevId:  0,
+				DbRow:    "$" + DbDevId + "." + DbRow,
+				DbColumn: "$" + DbDevId + "." + DbColumn,
+				DbCount:  1,
+			},
+		},
+	})
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrap(err, "failed to count devices by attribute values")
 	}
-
-	groups := make([]model.GroupName, len(results))
-	for i, d := range results {
-		groups[i] = model.GroupName(d.(string))
+	var counts []model.AttributePairCount
+	if err = decodeAll(ctx, cur, &counts); err != nil {
+		return nil, errors.Wrap(err, "failed to count devices by attribute values")
 	}
-	return groups, nil
+	return counts, nil
 }
 
-func (db *DataStoreMongo) GetDevicesByGroup(ctx context.Context, group model.GroupName, skip, limit int) ([]model.DeviceID, int, error) {
-	c := db.client.
-		Database(mstore.DbFromContext(ctx, DbName)).
-		Collection(DbDevicesColl)
+func (db *DataStoreMongo) GetScopes(ctx context.Context) ([]model.Scope, error) {
+	c := db.database(ctx).
+		Collection(DbScopesColl)
 
-	filter := bson.M{DbDevAttributesGroupValue: group}
-	result := c.FindOne(ctx, filter)
-	if result == nil {
-		return nil, -1, store.ErrGroupNotFound
+	cur, err := db.find(ctx, c, bson.M{},
+		mopts.Find().SetSort(bson.D{{Key: DbDevId, Value: 1}}),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get scopes")
 	}
+	scopes := []model.Scope{}
+	if err = decodeAll(ctx, cur, &scopes); err != nil {
+		return nil, errors.Wrap(err, "failed to get scopes")
+	}
+	return scopes, nil
+}
 
-	var dev model.Device
-	err := result.Decode(&dev)
+func (db *DataStoreMongo) UpsertScope(ctx context.Context, scope model.Scope) error {
+	c := db.database(ctx).
+		Collection(DbScopesColl)
+
+	_, err := c.ReplaceOne(ctx,
+		bson.M{DbDevId: scope.Name}, scope,
+		mopts.Replace().SetUpsert(true),
+	)
 	if err != nil {
-		return nil, -1, store.ErrGroupNotFound
+		return errors.Wrap(err, "failed to store scope")
 	}
+	return nil
+}
 
-	hasGroup := group != ""
-	devices, totalDevices, e := db.GetDevices(ctx,
-		store.ListQuery{
-			Skip:      skip,
-			Limit:     limit,
-			Filters:   nil,
-			Sort:      nil,
-			HasGroup:  &hasGroup,
-			GroupName: string(group)})
-	if e != nil {
-		return nil, -1, errors.Wrap(e, "failed to get device list for group")
+func (db *DataStoreMongo) DeleteScope(ctx context.Context, name string) error {
+	c := db.database(ctx).
+		Collection(DbScopesColl)
+
+	res, err := c.DeleteOne(ctx, bson.M{DbDevId: name})
+	if err != nil {
+		return errors.Wrap(err, "failed to delete scope")
+	} else if res.DeletedCount == 0 {
+		return store.ErrScopeNotFound
 	}
+	return nil
+}
 
-	resIds := make([]model.DeviceID, len(devices))
-	for i, d := range devices {
-		resIds[i] = d.ID
+func (db *DataStoreMongo) GetAttributeDefinitions(
+	ctx context.Context,
+) ([]model.AttributeDefinition, error) {
+	c := db.database(ctx).
+		Collection(DbSchemaColl)
+
+	cur, err := db.find(ctx, c, bson.M{},
+		mopts.Find().SetSort(bson.D{
+			{Key: DbDevAttributesScope, Value: 1},
+			{Key: DbDevAttributesName, Value: 1},
+		}),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get attribute definitions")
 	}
-	return resIds, totalDevices, nil
+	defs := []model.AttributeDefinition{}
+	if err = decodeAll(ctx, cur, &defs); err != nil {
+		return nil, errors.Wrap(err, "failed to get attribute definitions")
+	}
+	return defs, nil
 }
 
-func (db *DataStoreMongo) GetDeviceGroup(ctx context.Context, id model.DeviceID) (model.GroupName, error) {
-	dev, err := db.GetDevice(ctx, id)
-	if err != nil || dev == nil {
-		return "", store.ErrDevNotFound
+func (db *DataStoreMongo) UpsertAttributeDefinition(
+	ctx context.Context,
+	def model.AttributeDefinition,
+) error {
+	c := db.database(ctx).
+		Collection(DbSchemaColl)
+
+	_, err := c.ReplaceOne(ctx,
+		bson.M{
+			DbDevAttributesScope: def.Scope,
+			DbDevAttributesName:  def.Name,
+		}, def,
+		mopts.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to store attribute definition")
 	}
+	return nil
+}
 
-	return dev.Group, nil
+func (db *DataStoreMongo) DeleteAttributeDefinition(
+	ctx context.Context,
+	scope, name string,
+) error {
+	c := db.database(ctx).
+		Collection(DbSchemaColl)
+
+	res, err := c.DeleteOne(ctx, bson.M{
+		DbDevAttributesScope: scope,
+		DbDevAttributesName:  name,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to delete attribute definition")
+	} else if res.DeletedCount == 0 {
+		return store.ErrAttributeDefinitionNotFound
+	}
+	return nil
 }
 
-func (db *DataStoreMongo) DeleteDevices(
-	ctx context.Context, ids []model.DeviceID,
-) (*model.UpdateResult, error) {
-	var filter = bson.M{}
-	database := db.client.Database(mstore.DbFromContext(ctx, DbName))
-	collDevs := database.Collection(DbDevicesColl)
+func (db *DataStoreMongo) UnsetExpiredAttributes(
+	ctx context.Context,
+	scope, name string,
+	since time.Time,
+) (int64, error) {
+	const updatedField = DbDevAttributes + "." + model.AttrScopeSystem +
+		"-" + model.AttrNameUpdated + "." + DbDevAttributesValue
+	c := db.database(ctx).
+		Collection(db.names.Devices)
+
+	field := makeAttrField(name, scope)
+	tsField := makeAttrField(name, scope, DbDevAttributesTs)
+	// attributes written before the update time was tracked per attribute
+	// expire with the last update of the device
+	filter := bson.M{
+		field: bson.M{"$exists": true},
+		"$or": bson.A{
+			bson.M{tsField: bson.M{"$lt": since}},
+			bson.M{
+				tsField:      bson.M{"$exists": false},
+				updatedField: bson.M{"$lt": since},
+			},
+		},
+	}
+	res, err := c.UpdateMany(ctx, filter, bson.M{
+		"$unset": bson.M{field: true},
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to remove expired attributes")
+	}
+	return res.ModifiedCount, nil
+}
 
-	switch len(ids) {
-	case 0:
-		// This is a no-op, don't bother requesting mongo.
-		return &model.UpdateResult{DeletedCount: 0}, nil
-	case 1:
-		filter[DbDevId] = ids[0]
-	default:
-		filter[DbDevId] = bson.M{"$in": ids}
+func (db *DataStoreMongo) RecordSchemaViolations(
+	ctx context.Context,
+	violations []model.SchemaViolation,
+) error {
+	c := db.database(ctx).
+		Collection(DbSchemaViolationsColl)
+
+	models := make([]mongo.WriteModel, len(violations))
+	for i, v := range violations {
+		models[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{
+				"device_id":          v.DeviceID,
+				DbDevAttributesScope: v.Scope,
+				DbDevAttributesName:  v.Name,
+			}).
+			SetUpdate(bson.M{
+				"$set": bson.M{
+					DbDevAttributesValue: v.Value,
+					"error":              v.Error,
+					"last_ts":            v.LastTs,
+				},
+				"$setOnInsert": bson.M{"first_ts": v.FirstTs},
+				"$inc":         bson.M{"count": 1},
+			}).
+			SetUpsert(true)
 	}
-	res, err := collDevs.DeleteMany(ctx, filter)
+	_, err := c.BulkWrite(ctx, models, mopts.BulkWrite().SetOrdered(false))
 	if err != nil {
-		return nil, err
+		return errors.Wrap(err, "failed to record schema violations")
 	}
-	return &model.UpdateResult{
-		DeletedCount: res.DeletedCount,
-	}, nil
+	return nil
 }
 
-func (db *DataStoreMongo) GetAllAttributeNames(ctx context.Context) ([]string, error) {
-	c := db.client.Database(mstore.DbFromContext(ctx, DbName)).Collection(DbDevicesColl)
+func (db *DataStoreMongo) GetSchemaViolations(
+	ctx context.Context,
+	id model.DeviceID,
+	skip, limit int,
+) ([]model.SchemaViolation, int, error) {
+	c := db.database(ctx).
+		Collection(DbSchemaViolationsColl)
+
+	filter := bson.M{}
+	if id != "" {
+		filter["device_id"] = id
+	}
+	findOptions := mopts.Find().SetSort(bson.D{
+		{Key: "last_ts", Value: -1},
+		{Key: DbDevId, Value: 1},
+	})
+	if skip > 0 {
+		findOptions.SetSkip(int64(skip))
+	}
+	if limit > 0 {
+		findOptions.SetLimit(int64(limit))
+	}
+	cur, err := db.find(ctx, c, filter, findOptions)
+	if err != nil {
+		return nil, -1, errors.Wrap(err, "failed to get schema violations")
+	}
+	violations := []model.SchemaViolation{}
+	if err = decodeAll(ctx, cur, &violations); err != nil {
+		return nil, -1, errors.Wrap(err, "failed to get schema violations")
+	}
 
-	project := bson.M{
-		"$project": bson.M{
-			"arrayofkeyvalue": bson.M{
-				"$objectToArray": "$$ROOT.attributes",
-			},
-		},
+	count, err := c.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, -1, errors.Wrap(err, "failed to count schema violations")
 	}
+	return violations, int(count), nil
+}
 
-	unwind := bson.M{
-		"$unwind": "$arrayofkeyvalue",
+func (db *DataStoreMongo) GetExternalID(
+	ctx context.Context,
+	ref model.ExternalIDRef,
+) (*model.ExternalID, error) {
+	c := db.database(ctx).
+		Collection(DbExternalIDsColl)
+
+	var extID model.ExternalID
+	err := c.FindOne(ctx, bson.M{
+		DbExternalIDSystem: ref.System,
+		DbExternalID:       ref.ID,
+	}).Decode(&extID)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch external ID")
 	}
+	return &extID, nil
+}
 
-	group := bson.M{
-		"$group": bson.M{
-			"_id": nil,
-			"allkeys": bson.M{
-				"$addToSet": "$arrayofkeyvalue.v.name",
-			},
-		},
+func (db *DataStoreMongo) UpsertExternalIDs(
+	ctx context.Context,
+	ids []model.ExternalID,
+) (*model.UpdateResult, error) {
+	if len(ids) == 0 {
+		return &model.UpdateResult{}, nil
 	}
+	c := db.database(ctx).
+		Collection(DbExternalIDsColl)
 
-	l := log.FromContext(ctx)
-	cursor, err := c.Aggregate(ctx, []bson.M{
-		project,
-		unwind,
-		group,
-	})
+	models := make([]mongo.WriteModel, len(ids))
+	for i, extID := range ids {
+		models[i] = mongo.NewReplaceOneModel().
+			SetFilter(bson.M{
+				DbExternalIDSystem: extID.System,
+				DbExternalID:       extID.ID,
+			}).
+			SetReplacement(extID).
+			SetUpsert(true)
+	}
+	res, err := c.BulkWrite(ctx, models, mopts.BulkWrite().SetOrdered(false))
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrap(err, "failed to store external IDs")
 	}
-	defer cursor.Close(ctx)
+	return &model.UpdateResult{
+		MatchedCount: res.MatchedCount,
+		UpdatedCount: res.ModifiedCount,
+		CreatedCount: res.UpsertedCount,
+	}, nil
+}
 
-	cursor.Next(ctx)
-	elem := &bson.D{}
-	err = cursor.Decode(elem)
+func (db *DataStoreMongo) DeleteExternalID(
+	ctx context.Context,
+	ref model.ExternalIDRef,
+) error {
+	c := db.database(ctx).
+		Collection(DbExternalIDsColl)
+
+	res, err := c.DeleteOne(ctx, bson.M{
+		DbExternalIDSystem: ref.System,
+		DbExternalID:       ref.ID,
+	})
 	if err != nil {
-		if err != io.EOF {
-			return nil, errors.Wrap(err, "failed to get attributes")
+		return errors.Wrap(err, "failed to remove external ID")
+	} else if res.DeletedCount == 0 {
+		return store.ErrExternalIDNotFound
+	}
+	return nil
+}
+
+func (db *DataStoreMongo) GetFeatureFlags(ctx context.Context) (model.FeatureFlagSet, error) {
+	c := db.database(ctx).
+		Collection(DbSettingsColl)
+
+	var settings struct {
+		Flags model.FeatureFlagSet `bson:"flags"`
+	}
+	err := c.FindOne(ctx, bson.M{DbDevId: DbSettingsFeatureFlags}).
+		Decode(&settings)
+	if err == mongo.ErrNoDocuments {
+		return model.FeatureFlagSet{}, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to get feature flags")
+	}
+	if settings.Flags == nil {
+		settings.Flags = model.FeatureFlagSet{}
+	}
+	return settings.Flags, nil
+}
+
+func (db *DataStoreMongo) UpdateFeatureFlags(
+	ctx context.Context,
+	update model.FeatureFlagsUpdate,
+) error {
+	c := db.database(ctx).
+		Collection(DbSettingsColl)
+
+	set, unset := bson.M{}, bson.M{}
+	for flag, enabled := range update {
+		field := DbSettingsFlags + "." + string(flag)
+		if enabled != nil {
+			set[field] = *enabled
 		} else {
-			return make([]string, 0), nil
+			unset[field] = ""
 		}
 	}
-	m := elem.Map()
-	results := m["allkeys"].(primitive.A)
-	attributeNames := make([]string, len(results))
-	for i, d := range results {
-		attributeNames[i] = d.(string)
-		l.Debugf("GetAllAttributeNames got: '%v'", d)
+	doc := bson.M{}
+	if len(set) > 0 {
+		doc["$set"] = set
+	}
+	if len(unset) > 0 {
+		doc["$unset"] = unset
+	}
+	_, err := c.UpdateOne(ctx,
+		bson.M{DbDevId: DbSettingsFeatureFlags}, doc,
+		mopts.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to update feature flags")
 	}
+	return nil
+}
 
-	return attributeNames, nil
+func (db *DataStoreMongo) GetLimits(ctx context.Context) (model.Limits, error) {
+	c := db.database(ctx).
+		Collection(DbSettingsColl)
+
+	var limits model.Limits
+	err := c.FindOne(ctx, bson.M{DbDevId: DbSettingsLimits}).
+		Decode(&limits)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return limits, errors.Wrap(err, "failed to get limits")
+	}
+	return limits, nil
 }
 
-func (db *DataStoreMongo) SearchDevices(ctx context.Context, searchParams model.SearchParams) ([]model.Device, int, error) {
-	c := db.client.Database(mstore.DbFromContext(ctx, DbName)).Collection(DbDevicesColl)
+func (db *DataStoreMongo) SetLimits(ctx context.Context, limits model.Limits) error {
+	c := db.database(ctx).
+		Collection(DbSettingsColl)
 
-	queryFilters := make([]bson.M, 0)
-	for _, filter := range searchParams.Filters {
-		op := filter.Type
-		var field string
-		if filter.Scope == model.AttrScopeIdentity && filter.Attribute == model.AttrNameID {
-			field = DbDevId
-		} else {
-			name := fmt.Sprintf("%s-%s", filter.Scope, model.GetDeviceAttributeNameReplacer().Replace(filter.Attribute))
-			field = fmt.Sprintf("%s.%s.%s", DbDevAttributes, name, DbDevAttributesValue)
-		}
-		queryFilters = append(queryFilters, bson.M{field: bson.M{op: filter.Value}})
+	doc := struct {
+		ID           string `bson:"_id"`
+		model.Limits `bson:",inline"`
+	}{
+		ID:     DbSettingsLimits,
+		Limits: limits,
+	}
+	_, err := c.ReplaceOne(ctx,
+		bson.M{DbDevId: DbSettingsLimits}, doc,
+		mopts.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to set limits")
 	}
+	return nil
+}
 
-	// FIXME: remove after migrating ids to attributes
-	if len(searchParams.DeviceIDs) > 0 {
-		queryFilters = append(queryFilters, bson.M{"_id": bson.M{"$in": searchParams.DeviceIDs}})
+func (db *DataStoreMongo) GetValidationWebhook(
+	ctx context.Context,
+) (*model.ValidationWebhook, error) {
+	c := db.database(ctx).
+		Collection(DbSettingsColl)
+
+	hook := &model.ValidationWebhook{}
+	err := c.FindOne(ctx, bson.M{DbDevId: DbSettingsValidationWebhook}).
+		Decode(hook)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to get validation webhook")
 	}
+	return hook, nil
+}
 
-	findQuery := bson.M{}
-	if len(queryFilters) > 0 {
-		findQuery["$and"] = queryFilters
+func (db *DataStoreMongo) SetValidationWebhook(
+	ctx context.Context,
+	hook model.ValidationWebhook,
+) error {
+	c := db.database(ctx).
+		Collection(DbSettingsColl)
+
+	doc := struct {
+		ID                      string `bson:"_id"`
+		model.ValidationWebhook `bson:",inline"`
+	}{
+		ID:                DbSettingsValidationWebhook,
+		ValidationWebhook: hook,
+	}
+	_, err := c.ReplaceOne(ctx,
+		bson.M{DbDevId: DbSettingsValidationWebhook}, doc,
+		mopts.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to set validation webhook")
 	}
+	return nil
+}
 
-	findOptions := mopts.Find()
-	findOptions.SetSkip(int64((searchParams.Page - 1) * searchParams.PerPage))
-	findOptions.SetLimit(int64(searchParams.PerPage))
+func (db *DataStoreMongo) DeleteValidationWebhook(ctx context.Context) error {
+	c := db.database(ctx).
+		Collection(DbSettingsColl)
 
-	if len(searchParams.Attributes) > 0 {
-		projection := bson.M{DbDevUpdatedTs: 1}
-		for _, attribute := range searchParams.Attributes {
-			name := fmt.Sprintf("%s-%s", attribute.Scope, model.GetDeviceAttributeNameReplacer().Replace(attribute.Attribute))
-			field := fmt.Sprintf("%s.%s", DbDevAttributes, name)
-			projection[field] = 1
-		}
-		findOptions.SetProjection(projection)
+	_, err := c.DeleteOne(ctx, bson.M{DbDevId: DbSettingsValidationWebhook})
+	if err != nil {
+		return errors.Wrap(err, "failed to delete validation webhook")
 	}
+	return nil
+}
 
-	if len(searchParams.Sort) > 0 {
-		sortField := make(bson.D, len(searchParams.Sort))
-		for i, sortQ := range searchParams.Sort {
-			name := fmt.Sprintf("%s-%s", sortQ.Scope, model.GetDeviceAttributeNameReplacer().Replace(sortQ.Attribute))
-			field := fmt.Sprintf("%s.%s.%s", DbDevAttributes, name, DbDevAttributesValue)
-			sortField[i] = bson.E{Key: field, Value: 1}
-			if sortQ.Order == "desc" {
-				sortField[i].Value = -1
-			}
-		}
-		findOptions.SetSort(sortField)
+func (db *DataStoreMongo) GetPinnedAttributes(
+	ctx context.Context,
+) (model.PinnedAttributes, error) {
+	c := db.database(ctx).
+		Collection(DbSettingsColl)
+
+	var pinned model.PinnedAttributes
+	err := c.FindOne(ctx, bson.M{DbDevId: DbSettingsPinnedAttributes}).
+		Decode(&pinned)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return pinned, errors.Wrap(err, "failed to get pinned attributes")
 	}
+	return pinned, nil
+}
 
-	cursor, err := c.Find(ctx, findQuery, findOptions)
+func (db *DataStoreMongo) SetPinnedAttributes(
+	ctx context.Context,
+	pinned model.PinnedAttributes,
+) error {
+	c := db.database(ctx).
+		Collection(DbSettingsColl)
+
+	doc := struct {
+		ID                     string `bson:"_id"`
+		model.PinnedAttributes `bson:",inline"`
+	}{
+		ID:               DbSettingsPinnedAttributes,
+		PinnedAttributes: pinned,
+	}
+	_, err := c.ReplaceOne(ctx,
+		bson.M{DbDevId: DbSettingsPinnedAttributes}, doc,
+		mopts.Replace().SetUpsert(true),
+	)
 	if err != nil {
-		return nil, -1, errors.Wrap(err, "failed to search devices")
+		return errors.Wrap(err, "failed to set pinned attributes")
 	}
-	defer cursor.Close(ctx)
+	return nil
+}
 
-	devices := []model.Device{}
+func (db *DataStoreMongo) GetAttributeAliases(
+	ctx context.Context,
+) (model.AttributeAliases, error) {
+	c := db.database(ctx).
+		Collection(DbSettingsColl)
 
-	if err = cursor.All(ctx, &devices); err != nil {
-		return nil, -1, errors.Wrap(err, "failed to search devices")
+	var aliases model.AttributeAliases
+	err := c.FindOne(ctx, bson.M{DbDevId: DbSettingsAttributeAliases}).
+		Decode(&aliases)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return aliases, errors.Wrap(err, "failed to get attribute aliases")
 	}
+	return aliases, nil
+}
 
-	count, err := c.CountDocuments(ctx, findQuery)
+func (db *DataStoreMongo) SetAttributeAliases(
+	ctx context.Context,
+	aliases model.AttributeAliases,
+) error {
+	c := db.database(ctx).
+		Collection(DbSettingsColl)
+
+	doc := struct {
+		ID                     string `bson:"_id"`
+		model.AttributeAliases `bson:",inline"`
+	}{
+		ID:               DbSettingsAttributeAliases,
+		AttributeAliases: aliases,
+	}
+	_, err := c.ReplaceOne(ctx,
+		bson.M{DbDevId: DbSettingsAttributeAliases}, doc,
+		mopts.Replace().SetUpsert(true),
+	)
 	if err != nil {
-		return nil, -1, errors.Wrap(err, "failed to search devices")
+		return errors.Wrap(err, "failed to set attribute aliases")
 	}
+	return nil
+}
 
-	return devices, int(count), nil
+func (db *DataStoreMongo) ListTenantIDs(ctx context.Context) ([]string, error) {
+	dbs, err := migrate.GetTenantDbs(ctx, db.client, db.names.IsTenantDb)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed go retrieve tenant DBs")
+	}
+	if len(dbs) == 0 {
+		return []string{""}, nil
+	}
+	tenants := make([]string, len(dbs))
+	for i, d := range dbs {
+		tenants[i] = db.names.TenantFromDb(d)
+	}
+	return tenants, nil
 }
 
-func indexAttr(s *mongo.Client, ctx context.Context, attr string) error {
+func (db *DataStoreMongo) indexAttr(ctx context.Context, attr string) error {
 	l := log.FromContext(ctx)
-	c := s.Database(mstore.DbFromContext(ctx, DbName)).Collection(DbDevicesColl)
+	c := db.database(ctx).Collection(db.names.Devices)
 
 	indexView := c.Indexes()
 	keys := bson.D{
@@ -1004,9 +2890,9 @@ func indexAttr(s *mongo.Client, ctx context.Context, attr string) error {
 
 	if err != nil {
 		if isTooManyIndexes(err) {
-			l.Warnf("failed to index attr %s in db %s: too many indexes", attr, mstore.DbFromContext(ctx, DbName))
+			l.Warnf("failed to index attr %s in db %s: too many indexes", attr, db.dbName(ctx))
 		} else {
-			return errors.Wrapf(err, "failed to index attr %s in db %s", attr, mstore.DbFromContext(ctx, DbName))
+			return errors.Wrapf(err, "failed to index attr %s in db %s", attr, db.dbName(ctx))
 		}
 	}
 
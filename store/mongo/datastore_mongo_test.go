@@ -16,14 +16,18 @@ package mongo
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	mopts "go.mongodb.org/mongo-driver/mongo/options"
 
@@ -50,6 +54,301 @@ func TestPing(t *testing.T) {
 }
 
 // test funcs
+func TestFilterGroupQuery(t *testing.T) {
+	t.Parallel()
+
+	eq := func(name, value string) store.Filter {
+		return store.Filter{
+			AttrName:  name,
+			AttrScope: model.AttrScopeInventory,
+			Value:     value,
+			Operator:  store.Eq,
+		}
+	}
+	assert.Equal(t, bson.M{}, filterGroupQuery(store.FilterGroup{
+		Operator: store.Or,
+		Groups:   []store.FilterGroup{{}},
+	}))
+	assert.Equal(t,
+		bson.M{"attributes.inventory-a.value": bson.M{"$eq": "1"}},
+		filterGroupQuery(store.FilterGroup{
+			Operator: store.Or,
+			Filters:  []store.Filter{eq("a", "1")},
+		}))
+	assert.Equal(t, bson.M{"$and": []bson.M{
+		{"attributes.inventory-c.value": bson.M{"$eq": "3"}},
+		{"$or": []bson.M{
+			{"attributes.inventory-a.value": bson.M{"$eq": "1"}},
+			{"attributes.inventory-b.value": bson.M{"$eq": "2"}},
+		}},
+	}}, filterGroupQuery(store.FilterGroup{
+		Operator: store.And,
+		Filters:  []store.Filter{eq("c", "3")},
+		Groups: []store.FilterGroup{{
+			Operator: store.Or,
+			Filters:  []store.Filter{eq("a", "1"), eq("b", "2")},
+		}},
+	}))
+}
+
+func TestRegexQueries(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t,
+		bson.M{"attributes.inventory-name.value": bson.M{"$regex": "^rpi"}},
+		filterQuery(store.Filter{
+			AttrName:  "name",
+			AttrScope: model.AttrScopeInventory,
+			Value:     "^rpi",
+			Operator:  store.Regex,
+		}))
+	assert.Equal(t,
+		bson.M{"attributes.inventory-name.value": bson.M{
+			"$regex": "rpi", "$options": "i",
+		}},
+		filterQuery(store.Filter{
+			AttrName:  "name",
+			AttrScope: model.AttrScopeInventory,
+			Value:     "rpi",
+			Operator:  store.IRegex,
+		}))
+	assert.Equal(t, []bson.M{
+		{"attributes.inventory-name.value": bson.M{
+			"$regex": `a\.b`, "$options": "i",
+		}},
+		{"attributes.inventory-type.value": bson.M{"$regex": "^x"}},
+	}, filterPredicatesQuery([]model.FilterPredicate{{
+		Scope:     model.AttrScopeInventory,
+		Attribute: "name",
+		Type:      model.SelectorIContains,
+		Value:     "a.b",
+	}, {
+		Scope:     model.AttrScopeInventory,
+		Attribute: "type",
+		Type:      model.SelectorRegex,
+		Value:     "^x",
+	}}))
+	query, err := predicateToQuery(model.FilterPredicate{
+		Scope:     model.AttrScopeInventory,
+		Attribute: "name",
+		Type:      model.SelectorContains,
+		Value:     "a+",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, bson.D{{
+		Key:   "attributes.inventory-name.value",
+		Value: bson.M{"$regex": `a\+`},
+	}}, query)
+}
+
+func TestAliasesQuery(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []bson.M{
+		{"$or": []bson.M{
+			{"attributes.inventory-ip_address.value": bson.M{"$regex": "^10\\."}},
+			{"attributes.inventory-ipv4.value": bson.M{"$regex": "^10\\."}},
+		}},
+		{"$and": []bson.M{
+			{"attributes.inventory-os.value": bson.M{"$ne": "debian"}},
+			{"attributes.inventory-os_name.value": bson.M{"$ne": "debian"}},
+		}},
+		{"$and": []bson.M{
+			{"attributes.inventory-kernel.value": bson.M{"$exists": false}},
+			{"attributes.inventory-kernel_version.value": bson.M{"$exists": false}},
+		}},
+	}, filterPredicatesQuery([]model.FilterPredicate{{
+		Scope:     model.AttrScopeInventory,
+		Attribute: "ip_address",
+		Aliases:   []string{"ipv4"},
+		Type:      model.SelectorRegex,
+		Value:     "^10\\.",
+	}, {
+		Scope:     model.AttrScopeInventory,
+		Attribute: "os",
+		Aliases:   []string{"os_name"},
+		Type:      "$ne",
+		Value:     "debian",
+	}, {
+		Scope:     model.AttrScopeInventory,
+		Attribute: "kernel",
+		Aliases:   []string{"kernel_version"},
+		Type:      "$exists",
+		Value:     false,
+	}}))
+
+	sort, fields := attributesSort([]model.SortCriteria{{
+		Scope:     model.AttrScopeInventory,
+		Attribute: "ip_address",
+		Aliases:   []string{"ipv4", "ip"},
+		Order:     "asc",
+		Nulls:     model.SortNullsLast,
+	}})
+	value := bson.M{"$ifNull": bson.A{
+		"$attributes.inventory-ip_address.value",
+		bson.M{"$ifNull": bson.A{
+			"$attributes.inventory-ipv4.value",
+			bson.M{"$ifNull": bson.A{"$attributes.inventory-ip.value", nil}},
+		}},
+	}}
+	assert.Equal(t, bson.D{
+		{Key: "_sort_nulls_0", Value: -1},
+		{Key: "_sort_alias_0", Value: 1},
+		{Key: "_id", Value: 1},
+	}, sort)
+	assert.Equal(t, bson.M{
+		"_sort_alias_0": value,
+		"_sort_nulls_0": bson.M{"$gt": bson.A{value, nil}},
+	}, fields)
+}
+
+func TestUpdatedSinceQuery(t *testing.T) {
+	t.Parallel()
+
+	since := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t,
+		bson.M{"attributes.system-updated_ts.value": bson.M{"$gte": since}},
+		updatedSinceQuery(since, ""))
+	assert.Equal(t,
+		bson.M{"$or": []bson.M{
+			{"attributes.system-updated_ts.value": bson.M{"$gt": since}},
+			{
+				"attributes.system-updated_ts.value": since,
+				"_id":                                bson.M{"$gt": model.DeviceID("2")},
+			},
+		}},
+		updatedSinceQuery(since, "2"))
+}
+
+func TestKeysetQuery(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t,
+		bson.M{"_id": bson.M{"$gt": model.DeviceID("2")}},
+		keysetQuery(model.DeviceCursor{ID: "2"}))
+	assert.Equal(t,
+		bson.M{"$or": []bson.M{
+			{"attributes.system-group.value": bson.M{"$gt": "prod"}},
+			{"$and": []bson.M{
+				{"attributes.system-group.value": "prod"},
+				{"$or": []bson.M{
+					{"attributes.inventory-mem.value": bson.M{"$lt": 512.0}},
+					{"attributes.inventory-mem.value": nil},
+				}},
+			}},
+			{
+				"attributes.system-group.value":  "prod",
+				"attributes.inventory-mem.value": 512.0,
+				"_id":                            bson.M{"$gt": model.DeviceID("2")},
+			},
+		}},
+		keysetQuery(model.DeviceCursor{
+			Sort: []model.SortCriteria{
+				{Scope: model.AttrScopeSystem, Attribute: "group", Order: "asc"},
+				{Scope: model.AttrScopeInventory, Attribute: "mem", Order: "desc"},
+			},
+			Values: []interface{}{"prod", 512.0},
+			ID:     "2",
+		}))
+	// the devices without the attribute sort first in the ascending
+	// order, and last in the descending one
+	assert.Equal(t,
+		bson.M{"$or": []bson.M{
+			{"attributes.system-group.value": bson.M{"$ne": nil}},
+			{
+				"attributes.system-group.value": nil,
+				"_id":                           bson.M{"$gt": model.DeviceID("2")},
+			},
+		}},
+		keysetQuery(model.DeviceCursor{
+			Sort: []model.SortCriteria{
+				{Scope: model.AttrScopeSystem, Attribute: "group", Order: "asc"},
+			},
+			Values: []interface{}{nil},
+			ID:     "2",
+		}))
+	assert.Equal(t,
+		bson.M{
+			"attributes.system-group.value": nil,
+			"_id":                           bson.M{"$gt": model.DeviceID("2")},
+		},
+		keysetQuery(model.DeviceCursor{
+			Sort: []model.SortCriteria{
+				{Scope: model.AttrScopeSystem, Attribute: "group", Order: "desc"},
+			},
+			Values: []interface{}{nil},
+			ID:     "2",
+		}))
+	// the devices without the attribute placed explicitly
+	assert.Equal(t,
+		bson.M{"$or": []bson.M{
+			{"$or": []bson.M{
+				{"attributes.system-group.value": bson.M{"$gt": "prod"}},
+				{"attributes.system-group.value": nil},
+			}},
+			{
+				"attributes.system-group.value": "prod",
+				"_id":                           bson.M{"$gt": model.DeviceID("2")},
+			},
+		}},
+		keysetQuery(model.DeviceCursor{
+			Sort: []model.SortCriteria{{
+				Scope: model.AttrScopeSystem, Attribute: "group",
+				Order: "asc", Nulls: model.SortNullsLast,
+			}},
+			Values: []interface{}{"prod"},
+			ID:     "2",
+		}))
+	assert.Equal(t,
+		bson.M{"$or": []bson.M{
+			{"attributes.system-group.value": bson.M{"$ne": nil}},
+			{
+				"attributes.system-group.value": nil,
+				"_id":                           bson.M{"$gt": model.DeviceID("2")},
+			},
+		}},
+		keysetQuery(model.DeviceCursor{
+			Sort: []model.SortCriteria{{
+				Scope: model.AttrScopeSystem, Attribute: "group",
+				Order: "desc", Nulls: model.SortNullsFirst,
+			}},
+			Values: []interface{}{nil},
+			ID:     "2",
+		}))
+}
+
+func TestAttributesSort(t *testing.T) {
+	t.Parallel()
+
+	sort, fields := attributesSort([]model.SortCriteria{
+		{Scope: model.AttrScopeInventory, Attribute: "mem", Order: "desc"},
+	})
+	assert.Equal(t, bson.D{
+		{Key: "attributes.inventory-mem.value", Value: -1},
+		{Key: "_id", Value: 1},
+	}, sort)
+	assert.Nil(t, fields)
+
+	sort, fields = attributesSort([]model.SortCriteria{
+		{Scope: model.AttrScopeSystem, Attribute: "group", Order: "asc"},
+		{
+			Scope: model.AttrScopeInventory, Attribute: "mem",
+			Order: "asc", Nulls: model.SortNullsLast,
+		},
+	})
+	assert.Equal(t, bson.D{
+		{Key: "attributes.system-group.value", Value: 1},
+		{Key: "_sort_nulls_1", Value: -1},
+		{Key: "attributes.inventory-mem.value", Value: 1},
+		{Key: "_id", Value: 1},
+	}, sort)
+	assert.Equal(t, bson.M{
+		"_sort_nulls_1": bson.M{"$gt": bson.A{
+			"$attributes.inventory-mem.value", nil,
+		}},
+	}, fields)
+}
+
 func TestMongoGetDevices(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping TestMongoGetDevices in short mode.")
@@ -98,17 +397,21 @@ func TestMongoGetDevices(t *testing.T) {
 	}
 	floatVal4 := 4.0
 	floatVal5 := 5.0
+	floatVal6 := 6.0
 
 	testCases := map[string]struct {
-		expected  []model.Device
-		devTotal  int
-		skip      int
-		limit     int
-		filters   []store.Filter
-		sort      *store.Sort
-		hasGroup  *bool
-		groupName string
-		tenant    string
+		expected    []model.Device
+		devTotal    int
+		skip        int
+		limit       int
+		filters     []store.Filter
+		filterGroup *store.FilterGroup
+		sort        []store.Sort
+		hasGroup    *bool
+		groupName   string
+		search      string
+		idsOnly     bool
+		tenant      string
 	}{
 		"get device from group 1": {
 			expected:  []model.Device{inputDevs[1]},
@@ -211,17 +514,169 @@ func TestMongoGetDevices(t *testing.T) {
 			},
 			sort: nil,
 		},
+		"filter on attribute (range)": {
+			expected: []model.Device{inputDevs[4], inputDevs[5], inputDevs[6]},
+			devTotal: 3,
+			limit:    20,
+			filters: []store.Filter{
+				{
+					AttrName:   "attrFloat",
+					AttrScope:  model.AttrScopeInventory,
+					Value:      "4",
+					ValueFloat: &floatVal4,
+					Operator:   store.Gte,
+				},
+				{
+					AttrName:   "attrFloat",
+					AttrScope:  model.AttrScopeInventory,
+					Value:      "6",
+					ValueFloat: &floatVal6,
+					Operator:   store.Lt,
+				},
+			},
+		},
+		"filter on attribute (not equal)": {
+			expected: []model.Device{inputDevs[0], inputDevs[1], inputDevs[2],
+				inputDevs[3], inputDevs[5], inputDevs[6]},
+			devTotal: 6,
+			limit:    20,
+			filters: []store.Filter{
+				{
+					AttrName:  "attrString",
+					AttrScope: model.AttrScopeInventory,
+					Value:     "val4",
+					Operator:  store.Ne,
+				},
+			},
+		},
+		"filter on attribute (in)": {
+			expected: []model.Device{inputDevs[3], inputDevs[6]},
+			devTotal: 2,
+			limit:    20,
+			filters: []store.Filter{
+				{
+					AttrName:  "attrString",
+					AttrScope: model.AttrScopeInventory,
+					Values:    []string{"val3", "val6"},
+					Operator:  store.In,
+				},
+			},
+		},
+		"filter on attribute (not in, float)": {
+			expected: []model.Device{inputDevs[0], inputDevs[1], inputDevs[2],
+				inputDevs[3], inputDevs[7]},
+			devTotal: 5,
+			limit:    20,
+			filters: []store.Filter{
+				{
+					AttrName:  "attrFloat",
+					AttrScope: model.AttrScopeInventory,
+					Values:    []string{"4", "5"},
+					Operator:  store.Nin,
+				},
+			},
+		},
+		"filter on attribute (exists)": {
+			expected: []model.Device{inputDevs[0], inputDevs[1], inputDevs[2]},
+			devTotal: 3,
+			limit:    20,
+			filters: []store.Filter{
+				{
+					AttrName:  "attrFloat",
+					AttrScope: model.AttrScopeInventory,
+					Value:     "false",
+					Operator:  store.Exists,
+				},
+			},
+		},
+		"filter on attribute (regex)": {
+			expected: []model.Device{inputDevs[3], inputDevs[4], inputDevs[7]},
+			devTotal: 3,
+			limit:    20,
+			filters: []store.Filter{
+				{
+					AttrName:  "attrString",
+					AttrScope: model.AttrScopeInventory,
+					Value:     "^val[34]$",
+					Operator:  store.Regex,
+				},
+			},
+		},
+		"filter on attribute (regex, ignore case)": {
+			expected: []model.Device{inputDevs[6]},
+			devTotal: 1,
+			limit:    20,
+			filters: []store.Filter{
+				{
+					AttrName:  "attrString",
+					AttrScope: model.AttrScopeInventory,
+					Value:     "L6",
+					Operator:  store.IRegex,
+				},
+			},
+		},
+		"filter group (or)": {
+			expected: []model.Device{inputDevs[3], inputDevs[7]},
+			devTotal: 2,
+			limit:    20,
+			filterGroup: &store.FilterGroup{
+				Operator: store.Or,
+				Filters: []store.Filter{
+					{
+						AttrName:  "attrString",
+						AttrScope: model.AttrScopeInventory,
+						Value:     "val3",
+						Operator:  store.Eq,
+					},
+					{
+						AttrName:   "attrFloat",
+						AttrScope:  model.AttrScopeInventory,
+						Value:      "6",
+						ValueFloat: &floatVal6,
+						Operator:   store.Gte,
+					},
+				},
+			},
+		},
+		"search (attribute value)": {
+			expected: []model.Device{inputDevs[5]},
+			devTotal: 1,
+			limit:    20,
+			search:   "AL5",
+		},
+		"search (id, number)": {
+			expected: []model.Device{inputDevs[6], inputDevs[7]},
+			devTotal: 2,
+			limit:    20,
+			search:   "6",
+		},
 		"sort, limit": {
 			expected: []model.Device{inputDevs[5], inputDevs[4], inputDevs[3]},
 			devTotal: len(inputDevs),
 			skip:     0,
 			limit:    3,
 			filters:  nil,
-			sort: &store.Sort{
+			sort: []store.Sort{{
 				AttrName:  "attrFloat",
 				AttrScope: model.AttrScopeInventory,
 				Ascending: false,
-			},
+			}},
+		},
+		"sort by two attributes": {
+			// the devices without the attributes are sorted first
+			expected: []model.Device{inputDevs[3], inputDevs[7], inputDevs[4], inputDevs[5]},
+			devTotal: len(inputDevs),
+			skip:     3,
+			limit:    4,
+			sort: []store.Sort{{
+				AttrName:  "attrString",
+				AttrScope: model.AttrScopeInventory,
+				Ascending: true,
+			}, {
+				AttrName:  "attrFloat",
+				AttrScope: model.AttrScopeInventory,
+				Ascending: false,
+			}},
 		},
 		"hasGroup = true": {
 			expected: []model.Device{inputDevs[1], inputDevs[2], inputDevs[5]},
@@ -241,6 +696,12 @@ func TestMongoGetDevices(t *testing.T) {
 			sort:     nil,
 			hasGroup: boolPtr(false),
 		},
+		"all devs, IDs only": {
+			expected: inputDevs,
+			devTotal: len(inputDevs),
+			limit:    20,
+			idsOnly:  true,
+		},
 	}
 
 	for name, tc := range testCases {
@@ -270,20 +731,46 @@ func TestMongoGetDevices(t *testing.T) {
 			//test
 			devs, totalCount, err := mongoStore.GetDevices(ctx,
 				store.ListQuery{
-					Skip:      tc.skip,
-					Limit:     tc.limit,
-					Filters:   tc.filters,
-					Sort:      tc.sort,
-					HasGroup:  tc.hasGroup,
-					GroupName: tc.groupName})
+					Skip:        tc.skip,
+					Limit:       tc.limit,
+					Filters:     tc.filters,
+					FilterGroup: tc.filterGroup,
+					Sort:        tc.sort,
+					HasGroup:    tc.hasGroup,
+					GroupName:   tc.groupName,
+					Search:      tc.search,
+					IDsOnly:     tc.idsOnly})
 			assert.NoError(t, err, "failed to get devices")
 
 			assert.Equal(t, tc.devTotal, totalCount)
 			assert.Equal(t, len(tc.expected), len(devs))
+			if tc.idsOnly {
+				for _, dev := range devs {
+					assert.NotEmpty(t, dev.ID)
+					assert.Empty(t, dev.Attributes)
+				}
+			}
 		})
 	}
 }
 
+func TestDevicesProjection(t *testing.T) {
+	assert.Nil(t, devicesProjection(false, nil))
+	assert.Equal(t, bson.M{DbDevId: 1}, devicesProjection(true, []model.SelectAttribute{{
+		Scope:     model.AttrScopeInventory,
+		Attribute: "mac",
+	}}))
+	assert.Equal(t, bson.M{
+		DbDevUpdatedTs:                     1,
+		DbDevAttributes + ".inventory-mac": 1,
+		DbDevAttributes + ".system-group":  1,
+	}, devicesProjection(false, []model.SelectAttribute{
+		{Scope: model.AttrScopeIdentity, Attribute: model.AttrNameID},
+		{Scope: model.AttrScopeInventory, Attribute: "mac"},
+		{Scope: model.AttrScopeSystem, Attribute: model.AttrNameGroup},
+	}))
+}
+
 func TestMongoGetAllAttributeNames(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping TestMongoGetAllAttributeNames in short mode.")
@@ -397,6 +884,83 @@ func TestMongoGetAllAttributeNames(t *testing.T) {
 	}
 }
 
+func TestMongoGetDevicesByIDs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoGetDevicesByIDs in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+
+	for _, dev := range []model.Device{
+		{ID: "1", Attributes: model.DeviceAttributes{
+			{Name: "mac", Value: "01", Scope: model.AttrScopeInventory},
+			{Name: "kernel", Value: "5.4", Scope: model.AttrScopeInventory},
+		}},
+		{ID: "2"},
+		{ID: "3"},
+	} {
+		dev := dev
+		err := ds.AddDevice(ctx, &dev)
+		assert.NoError(t, err, "failed to setup input data")
+	}
+
+	devs, err := ds.GetDevicesByIDs(ctx, []model.DeviceID{"1", "3", "4"}, nil)
+	assert.NoError(t, err)
+	ids := []model.DeviceID{}
+	for _, dev := range devs {
+		ids = append(ids, dev.ID)
+	}
+	assert.ElementsMatch(t, []model.DeviceID{"1", "3"}, ids)
+
+	devs, err = ds.GetDevicesByIDs(ctx, []model.DeviceID{"1"},
+		[]model.SelectAttribute{{
+			Scope:     model.AttrScopeInventory,
+			Attribute: "mac",
+		}})
+	assert.NoError(t, err)
+	if assert.Len(t, devs, 1) && assert.Len(t, devs[0].Attributes, 1) {
+		assert.Equal(t, "mac", devs[0].Attributes[0].Name)
+	}
+}
+
+func TestMongoGetDeviceIDsByAttribute(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoGetDeviceIDsByAttribute in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+
+	for _, dev := range []model.Device{
+		{ID: "1", Attributes: model.DeviceAttributes{
+			{Name: "mac", Value: "00:01", Scope: model.AttrScopeIdentity},
+		}},
+		{ID: "2", Attributes: model.DeviceAttributes{
+			{Name: "mac", Value: []interface{}{"00:02", "00:03"}, Scope: model.AttrScopeIdentity},
+		}},
+		{ID: "3", Attributes: model.DeviceAttributes{
+			{Name: "mac", Value: "00:03", Scope: model.AttrScopeIdentity},
+		}},
+		{ID: "4", Attributes: model.DeviceAttributes{
+			{Name: "mac", Value: "00:04", Scope: model.AttrScopeInventory},
+		}},
+	} {
+		dev := dev
+		err := ds.AddDevice(ctx, &dev)
+		assert.NoError(t, err, "failed to setup input data")
+	}
+
+	ids, err := ds.GetDeviceIDsByAttribute(ctx, model.AttrScopeIdentity, "mac",
+		[]string{"00:01", "00:03", "00:04"})
+	assert.NoError(t, err)
+	assert.Len(t, ids, 2)
+	assert.Equal(t, []model.DeviceID{"1"}, ids["00:01"])
+	assert.ElementsMatch(t, []model.DeviceID{"2", "3"}, ids["00:03"])
+}
+
 func TestMongoGetDevice(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping TestMongoGetDevice in short mode.")
@@ -2315,6 +2879,72 @@ func TestMongoUpsertRemoveDeviceAttributes(t *testing.T) {
 	}
 }
 
+func TestMongoReplaceDeviceAttributes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoReplaceDeviceAttributes in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+
+	attr := func(scope, name string, value interface{}) model.DeviceAttribute {
+		return model.DeviceAttribute{Scope: scope, Name: name, Value: value}
+	}
+	agentScope := model.AgentScope(model.AttrScopeInventory, "gateway")
+	err := ds.AddDevice(ctx, &model.Device{
+		ID: "1",
+		Attributes: model.DeviceAttributes{
+			attr(model.AttrScopeInventory, "os", "linux"),
+			attr(model.AttrScopeInventory, "stale", "value"),
+			attr(agentScope, "signal", float64(-70)),
+			attr(model.AttrScopeIdentity, "mac", "00:11:22:33:44:55"),
+		},
+	})
+	require.NoError(t, err)
+	dev, err := ds.GetDevice(ctx, "1")
+	require.NoError(t, err)
+	created := dev.CreatedTs
+
+	res, err := ds.ReplaceDeviceAttributes(ctx, "1", model.AttrScopeInventory,
+		model.DeviceAttributes{
+			attr(model.AttrScopeInventory, "os", "zephyr"),
+			// the values are stored as reported, not as expressions
+			attr(model.AttrScopeInventory, "motd", "$HOME"),
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, &model.UpdateResult{MatchedCount: 1}, res)
+
+	dev, err = ds.GetDevice(ctx, "1")
+	require.NoError(t, err)
+	values := map[string]interface{}{}
+	for _, a := range dev.Attributes {
+		values[a.Scope+"/"+a.Name] = a.Value
+	}
+	// the attributes of the agents and of the other scopes are kept
+	assert.Equal(t, "zephyr", values["inventory/os"])
+	assert.Equal(t, "$HOME", values["inventory/motd"])
+	assert.NotContains(t, values, "inventory/stale")
+	assert.Equal(t, float64(-70), values[agentScope+"/signal"])
+	assert.Equal(t, "00:11:22:33:44:55", values["identity/mac"])
+	assert.Equal(t, created, dev.CreatedTs)
+	assert.Equal(t, model.SourceTypeInternal,
+		dev.Sources[model.AttrScopeInventory].Type)
+
+	// a new device is created
+	res, err = ds.ReplaceDeviceAttributes(ctx, "2", model.AttrScopeInventory,
+		model.DeviceAttributes{attr("", "os", "linux")})
+	assert.NoError(t, err)
+	assert.Equal(t, &model.UpdateResult{CreatedCount: 1}, res)
+	dev, err = ds.GetDevice(ctx, "2")
+	require.NoError(t, err)
+	assert.False(t, dev.CreatedTs.IsZero())
+
+	_, err = ds.ReplaceDeviceAttributes(ctx, "1", model.AttrScopeInventory,
+		model.DeviceAttributes{attr(model.AttrScopeIdentity, "mac", "")})
+	assert.EqualError(t, err, "attribute mac is not of the scope inventory")
+}
+
 func TestGetFiltersAttributes(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping TestGetFiltersAttributes in short mode.")
@@ -2479,6 +3109,7 @@ func TestMongoUpdateDeviceGroup(t *testing.T) {
 		tenant         string
 		OutputError    error
 		Result         model.UpdateResult
+		OutputGroups   map[model.DeviceID][]model.GroupName
 	}{
 		"update group for device with empty device id": {
 			InputDeviceIDs: nil,
@@ -2555,6 +3186,12 @@ func TestMongoUpdateDeviceGroup(t *testing.T) {
 				MatchedCount: 4,
 				UpdatedCount: 3,
 			},
+			// without the groups array the devices are moved
+			OutputGroups: map[model.DeviceID][]model.GroupName{
+				"1": {"grp2"},
+				"4": {"grp2"},
+				"6": {"grp3"},
+			},
 		},
 	}
 
@@ -2603,6 +3240,11 @@ func TestMongoUpdateDeviceGroup(t *testing.T) {
 				assert.Equal(t, testCase.Result, *result)
 			}
 		}
+		for id, groups := range testCase.OutputGroups {
+			devGroups, err := store.GetDeviceGroups(ctx, id)
+			assert.NoError(t, err)
+			assert.Equal(t, groups, devGroups)
+		}
 	}
 }
 
@@ -2765,6 +3407,92 @@ func TestMongoUnsetDevicesGroupWithGroupName(t *testing.T) {
 	}
 }
 
+func TestMongoDeleteGroup(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoDeleteGroup in short mode.")
+	}
+
+	db.Wipe()
+	client := db.Client()
+	store := NewDataStoreMongoWithSession(client)
+	ctx := identity.WithContext(db.CTX(), &identity.Identity{
+		Tenant: "foo",
+	})
+
+	devices := bson.A{
+		&model.Device{ID: "1", Group: "foo"},
+		&model.Device{ID: "2", Group: "foo"},
+		&model.Device{ID: "3", Group: "bar"},
+		&model.Device{ID: "4"},
+	}
+	_, err := client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbDevicesColl).
+		InsertMany(ctx, devices)
+	assert.NoError(t, err)
+
+	res, err := store.DeleteGroup(ctx, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, &model.UpdateResult{MatchedCount: 2, UpdatedCount: 2}, res)
+
+	groups, err := store.ListGroups(ctx, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.GroupName{"bar"}, groups)
+
+	res, err = store.DeleteGroup(ctx, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, &model.UpdateResult{}, res)
+}
+
+func TestMongoReplaceDevicesGroup(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoReplaceDevicesGroup in short mode.")
+	}
+
+	db.Wipe()
+	client := db.Client()
+	ds := NewDataStoreMongoWithSession(client)
+	ctx := identity.WithContext(db.CTX(), &identity.Identity{
+		Tenant: "foo",
+	})
+
+	devices := bson.A{
+		&model.Device{ID: "1", Group: "foo"},
+		&model.Device{ID: "2", Group: "foo"},
+		&model.Device{ID: "3", Group: "bar"},
+		&model.Device{ID: "4"},
+	}
+	_, err := client.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbDevicesColl).
+		InsertMany(ctx, devices)
+	assert.NoError(t, err)
+
+	// 1 leaves, 2 stays, 3 and 4 join; 5 does not exist
+	ids := []model.DeviceID{"2", "3", "4", "5"}
+	res, left, err := ds.ReplaceDevicesGroup(ctx, ids, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, &model.UpdateResult{MatchedCount: 3, UpdatedCount: 3}, res)
+	assert.Equal(t, []model.DeviceID{"1"}, left)
+
+	members, _, err := ds.GetDevicesByGroup(ctx, "foo", store.ListQuery{Limit: 10})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []model.DeviceID{"2", "3", "4"}, members)
+	groups, err := ds.GetDevicesGroups(ctx, []model.DeviceID{"3"})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.GroupName{"foo"}, groups["3"])
+
+	// repeating the replace changes nothing
+	res, left, err = ds.ReplaceDevicesGroup(ctx, ids, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, &model.UpdateResult{MatchedCount: 3}, res)
+	assert.Empty(t, left)
+
+	// an empty list empties the group
+	res, left, err = ds.ReplaceDevicesGroup(ctx, nil, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, &model.UpdateResult{UpdatedCount: 3}, res)
+	assert.ElementsMatch(t, []model.DeviceID{"2", "3", "4"}, left)
+}
+
 func TestMongoListGroups(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping TestMongoListGroups in short mode.")
@@ -2997,6 +3725,78 @@ func TestMongoListGroups(t *testing.T) {
 	}
 }
 
+func TestMongoSearchGroups(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoSearchGroups in short mode.")
+	}
+
+	devices := []model.Device{
+		{ID: "1", Group: "site-oslo"},
+		{ID: "2", Group: "site-oslo"},
+		{ID: "3", Group: "site-bergen"},
+		{ID: "4", Group: "site.x"},
+		{ID: "5", Group: "lab"},
+		{ID: "6"},
+	}
+	testCases := map[string]struct {
+		query store.GroupsQuery
+
+		groups []model.GroupName
+		total  int
+	}{
+		"all": {
+			groups: []model.GroupName{
+				"lab", "site-bergen", "site-oslo", "site.x",
+			},
+			total: 4,
+		},
+		"prefix": {
+			query:  store.GroupsQuery{Prefix: "site-"},
+			groups: []model.GroupName{"site-bergen", "site-oslo"},
+			total:  2,
+		},
+		"prefix, quoted": {
+			query:  store.GroupsQuery{Prefix: "site."},
+			groups: []model.GroupName{"site.x"},
+			total:  1,
+		},
+		"prefix, page": {
+			query:  store.GroupsQuery{Prefix: "site", Skip: 1, Limit: 1},
+			groups: []model.GroupName{"site-oslo"},
+			total:  3,
+		},
+		"page past the end": {
+			query:  store.GroupsQuery{Skip: 10, Limit: 10},
+			groups: []model.GroupName{},
+			total:  4,
+		},
+		"no match": {
+			query:  store.GroupsQuery{Prefix: "nope"},
+			groups: []model.GroupName{},
+		},
+	}
+
+	db.Wipe()
+	client := db.Client()
+	ds := NewDataStoreMongoWithSession(client)
+	ctx := db.CTX()
+	for _, d := range devices {
+		_, err := client.Database(DbName).
+			Collection(DbDevicesColl).
+			InsertOne(ctx, d)
+		assert.NoError(t, err)
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			groups, total, err := ds.SearchGroups(ctx, tc.query)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.groups, groups)
+			assert.Equal(t, tc.total, total)
+		})
+	}
+}
+
 func TestGetDevicesByGroup(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping TestGetDevicesByGroup in short mode.")
@@ -3161,9 +3961,10 @@ func TestGetDevicesByGroup(t *testing.T) {
 	for name, tc := range testCases {
 		t.Logf("test case: %s", name)
 
-		store := NewDataStoreMongoWithSession(client)
+		ds := NewDataStoreMongoWithSession(client)
 
-		devs, totalCount, err := store.GetDevicesByGroup(db.CTX(), tc.InputGroupName, tc.InputSkip, tc.InputLimit)
+		devs, totalCount, err := ds.GetDevicesByGroup(db.CTX(), tc.InputGroupName,
+			store.ListQuery{Skip: tc.InputSkip, Limit: tc.InputLimit})
 
 		if tc.OutputError != nil {
 			assert.EqualError(t, err, tc.OutputError.Error())
@@ -3299,12 +4100,13 @@ func TestGetDevicesByGroupWithTenant(t *testing.T) {
 	for name, tc := range testCases {
 		t.Logf("test case: %s", name)
 
-		store := NewDataStoreMongoWithSession(client)
+		ds := NewDataStoreMongoWithSession(client)
 
 		ctx := identity.WithContext(db.CTX(), &identity.Identity{
 			Tenant: "foo",
 		})
-		devs, totalCount, err := store.GetDevicesByGroup(ctx, tc.InputGroupName, tc.InputSkip, tc.InputLimit)
+		devs, totalCount, err := ds.GetDevicesByGroup(ctx, tc.InputGroupName,
+			store.ListQuery{Skip: tc.InputSkip, Limit: tc.InputLimit})
 
 		if tc.OutputError != nil {
 			assert.EqualError(t, err, tc.OutputError.Error())
@@ -3320,39 +4122,188 @@ func TestGetDevicesByGroupWithTenant(t *testing.T) {
 	}
 }
 
-func TestGetDeviceGroup(t *testing.T) {
+func TestGetDevicesByGroupFilterSort(t *testing.T) {
 	if testing.Short() {
-		t.Skip("skipping TestGetDeviceGroup in short mode.")
+		t.Skip("skipping TestGetDevicesByGroupFilterSort in short mode.")
 	}
 
+	attrs := func(artifact string, rank float64) model.DeviceAttributes {
+		return model.DeviceAttributes{
+			{Name: "artifact_name", Value: artifact, Scope: model.AttrScopeInventory},
+			{Name: "rank", Value: rank, Scope: model.AttrScopeInventory},
+		}
+	}
 	inputDevices := []model.Device{
-		{
-			ID:    model.DeviceID("1"),
-			Group: model.GroupName("dev"),
-		},
-		{
-			ID: model.DeviceID("2"),
-		},
+		{ID: "1", Group: "prod", Attributes: attrs("release-2", 3)},
+		{ID: "2", Group: "prod", Attributes: attrs("release-1", 2)},
+		{ID: "3", Group: "prod", Attributes: attrs("release-2", 1)},
+		{ID: "4", Group: "dev", Attributes: attrs("release-2", 4)},
+	}
+	release2 := store.Filter{
+		AttrName:  "artifact_name",
+		AttrScope: model.AttrScopeInventory,
+		Value:     "release-2",
+		Operator:  store.Eq,
+	}
+	byRank := store.Sort{
+		AttrName:  "rank",
+		AttrScope: model.AttrScopeInventory,
+		Ascending: true,
 	}
 
 	testCases := map[string]struct {
-		InputDeviceID model.DeviceID
-		OutputGroup   model.GroupName
-		OutputError   error
+		group model.GroupName
+		q     store.ListQuery
+
+		devices []model.DeviceID
+		total   int
+		err     error
 	}{
-		"dev has group": {
-			InputDeviceID: model.DeviceID("1"),
-			OutputGroup:   model.GroupName("dev"),
-			OutputError:   nil,
-		},
-		"dev has no group": {
-			InputDeviceID: model.DeviceID("2"),
-			OutputGroup:   "",
+		"filter and sort": {
+			group: "prod",
+			q: store.ListQuery{
+				Limit:   10,
+				Filters: []store.Filter{release2},
+				Sort:    []store.Sort{byRank},
+			},
+			devices: []model.DeviceID{"3", "1"},
+			total:   2,
+		},
+		"sort, no filter": {
+			group: "prod",
+			q: store.ListQuery{
+				Limit: 10,
+				Sort:  []store.Sort{byRank},
+			},
+			devices: []model.DeviceID{"3", "2", "1"},
+			total:   3,
+		},
+		"group fields of the query are ignored": {
+			group: "dev",
+			q: store.ListQuery{
+				Limit:     10,
+				Filters:   []store.Filter{release2},
+				GroupName: "prod",
+			},
+			devices: []model.DeviceID{"4"},
+			total:   1,
+		},
+		"no match": {
+			group: "dev",
+			q: store.ListQuery{
+				Limit: 10,
+				Filters: []store.Filter{{
+					AttrName:  "artifact_name",
+					AttrScope: model.AttrScopeInventory,
+					Value:     "release-1",
+					Operator:  store.Eq,
+				}},
+			},
+			devices: []model.DeviceID{},
+			total:   0,
+		},
+		"group doesn't exist": {
+			group: "unknown",
+			q: store.ListQuery{
+				Limit:   10,
+				Filters: []store.Filter{release2},
+			},
+			err: store.ErrGroupNotFound,
+		},
+	}
+
+	db.Wipe()
+	client := db.Client()
+	ds := NewDataStoreMongoWithSession(client)
+	for _, d := range inputDevices {
+		err := ds.AddDevice(db.CTX(), &d)
+		assert.NoError(t, err, "failed to setup input data")
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			devs, totalCount, err := ds.GetDevicesByGroup(db.CTX(), tc.group, tc.q)
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.devices, devs)
+			assert.Equal(t, tc.total, totalCount)
+		})
+	}
+}
+
+func TestGetDevicesByGroupNames(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestGetDevicesByGroupNames in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+	for _, dev := range []model.Device{
+		{ID: "1", Group: "de"},
+		{ID: "2", Group: "fr"},
+		{ID: "3", Group: "us"},
+		{ID: "4"},
+	} {
+		dev := dev
+		err := ds.AddDevice(ctx, &dev)
+		assert.NoError(t, err, "failed to setup input data")
+	}
+
+	// the parent group has no devices of its own
+	ids, total, err := ds.GetDevicesByGroup(ctx, "eu", store.ListQuery{
+		Limit:      10,
+		GroupNames: []model.GroupName{"eu", "de", "fr"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.ElementsMatch(t, []model.DeviceID{"1", "2"}, ids)
+
+	_, _, err = ds.GetDevicesByGroup(ctx, "eu", store.ListQuery{Limit: 10})
+	assert.Equal(t, store.ErrGroupNotFound, err)
+
+	_, _, err = ds.GetDevicesByGroup(ctx, "asia", store.ListQuery{
+		Limit:      10,
+		GroupNames: []model.GroupName{"asia", "jp"},
+	})
+	assert.Equal(t, store.ErrGroupNotFound, err)
+}
+
+func TestGetDeviceGroups(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestGetDeviceGroups in short mode.")
+	}
+
+	inputDevices := []model.Device{
+		{
+			ID:    model.DeviceID("1"),
+			Group: model.GroupName("dev"),
+		},
+		{
+			ID: model.DeviceID("2"),
+		},
+	}
+
+	testCases := map[string]struct {
+		InputDeviceID model.DeviceID
+		OutputGroups  []model.GroupName
+		OutputError   error
+	}{
+		"dev has group": {
+			InputDeviceID: model.DeviceID("1"),
+			OutputGroups:  []model.GroupName{"dev"},
+			OutputError:   nil,
+		},
+		"dev has no group": {
+			InputDeviceID: model.DeviceID("2"),
 			OutputError:   nil,
 		},
 		"dev doesn't exist": {
 			InputDeviceID: model.DeviceID("3"),
-			OutputGroup:   "",
 			OutputError:   store.ErrDevNotFound,
 		},
 	}
@@ -3367,14 +4318,14 @@ func TestGetDeviceGroup(t *testing.T) {
 
 	for name, tc := range testCases {
 		t.Run(name, func(t *testing.T) {
-			group, err := store.GetDeviceGroup(
+			groups, err := store.GetDeviceGroups(
 				db.CTX(), tc.InputDeviceID,
 			)
 			if tc.OutputError != nil {
 				assert.EqualError(t, err, tc.OutputError.Error())
 			} else {
 				assert.NoError(t, err, "expected no error")
-				if !assert.Equal(t, tc.OutputGroup, group) {
+				if !assert.Equal(t, tc.OutputGroups, groups) {
 					time.Sleep(time.Minute * 5)
 				}
 			}
@@ -3382,9 +4333,411 @@ func TestGetDeviceGroup(t *testing.T) {
 	}
 }
 
-func TestGetDeviceGroupWithTenant(t *testing.T) {
+func TestGetDevicesGroups(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestGetDevicesGroups in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	for _, dev := range []model.Device{
+		{ID: "1", Group: "dev"},
+		{ID: "2"},
+		{ID: "3", Group: "prod"},
+	} {
+		dev := dev
+		err := ds.AddDevice(db.CTX(), &dev)
+		assert.NoError(t, err, "failed to setup input data")
+	}
+
+	groups, err := ds.GetDevicesGroups(db.CTX(), []model.DeviceID{"1", "2", "4"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[model.DeviceID][]model.GroupName{
+		"1": {"dev"},
+		"2": nil,
+	}, groups)
+
+	groups, err = ds.GetDevicesGroups(db.CTX(), nil)
+	assert.NoError(t, err)
+	assert.Empty(t, groups)
+}
+
+func TestUpdateDevicesGroupByFilter(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestUpdateDevicesGroupByFilter in short mode.")
+	}
+
+	deviceType := func(value string) model.DeviceAttributes {
+		return model.DeviceAttributes{{
+			Scope: model.AttrScopeInventory,
+			Name:  "device_type",
+			Value: value,
+		}}
+	}
+	rpi4 := []model.FilterPredicate{{
+		Scope:     model.AttrScopeInventory,
+		Attribute: "device_type",
+		Type:      "$eq",
+		Value:     "raspberrypi4",
+	}}
+	testCases := map[string]struct {
+		params model.SearchParams
+
+		result *model.UpdateResult
+		joined []model.DeviceID
+		groups map[model.DeviceID][]model.GroupName
+	}{
+		"filters": {
+			params: model.SearchParams{Filters: rpi4},
+			result: &model.UpdateResult{MatchedCount: 3, UpdatedCount: 2},
+			joined: []model.DeviceID{"2", "3"},
+			groups: map[model.DeviceID][]model.GroupName{
+				"1": {"prod"},
+				"2": {"prod"},
+				"3": {"prod"},
+				"4": nil,
+			},
+		},
+		"filters and device IDs": {
+			params: model.SearchParams{
+				Filters:   rpi4,
+				DeviceIDs: []string{"3", "4"},
+			},
+			result: &model.UpdateResult{MatchedCount: 1, UpdatedCount: 1},
+			joined: []model.DeviceID{"3"},
+			groups: map[model.DeviceID][]model.GroupName{
+				"1": {"prod"},
+				"2": nil,
+				"3": {"prod"},
+				"4": nil,
+			},
+		},
+		"no filters": {
+			result: &model.UpdateResult{},
+			groups: map[model.DeviceID][]model.GroupName{
+				"1": {"prod"},
+				"2": nil,
+				"3": {"dev"},
+				"4": nil,
+			},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			db.Wipe()
+			ds := NewDataStoreMongoWithSession(db.Client())
+			for _, dev := range []model.Device{
+				{ID: "1", Group: "prod", Attributes: deviceType("raspberrypi4")},
+				{ID: "2", Attributes: deviceType("raspberrypi4")},
+				{ID: "3", Group: "dev", Attributes: deviceType("raspberrypi4")},
+				{ID: "4", Attributes: deviceType("x86")},
+			} {
+				dev := dev
+				err := ds.AddDevice(db.CTX(), &dev)
+				assert.NoError(t, err, "failed to setup input data")
+			}
+
+			result, joined, err := ds.UpdateDevicesGroupByFilter(
+				db.CTX(), tc.params, "prod",
+			)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.result, result)
+			assert.ElementsMatch(t, tc.joined, joined)
+
+			groups, err := ds.GetDevicesGroups(db.CTX(),
+				[]model.DeviceID{"1", "2", "3", "4"})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.groups, groups)
+		})
+	}
+}
+
+func TestUpdateDevicesTags(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestUpdateDevicesTags in short mode.")
+	}
+
+	attrs := func(deviceType, owner string) model.DeviceAttributes {
+		return model.DeviceAttributes{{
+			Scope: model.AttrScopeInventory,
+			Name:  "device_type",
+			Value: deviceType,
+		}, {
+			Scope: model.AttrScopeTags,
+			Name:  "owner",
+			Value: owner,
+		}}
+	}
+	rpi4 := []model.FilterPredicate{{
+		Scope:     model.AttrScopeInventory,
+		Attribute: "device_type",
+		Type:      "$eq",
+		Value:     "raspberrypi4",
+	}}
+	set := model.DeviceAttributes{{
+		Scope: model.AttrScopeTags,
+		Name:  "location",
+		Value: "oslo",
+	}}
+	unset := model.DeviceAttributes{{
+		Scope: model.AttrScopeTags,
+		Name:  "owner",
+	}}
+	testCases := map[string]struct {
+		params model.SearchParams
+
+		result  *model.UpdateResult
+		matched []model.DeviceID
+		tagged  []model.DeviceID
+	}{
+		"filters": {
+			params: model.SearchParams{Filters: rpi4},
+			result: &model.UpdateResult{MatchedCount: 2, UpdatedCount: 2},
+			tagged: []model.DeviceID{"1", "2"},
+		},
+		"filters and device IDs": {
+			params: model.SearchParams{
+				Filters:   rpi4,
+				DeviceIDs: []string{"2", "3", "4"},
+			},
+			result:  &model.UpdateResult{MatchedCount: 1, UpdatedCount: 1},
+			matched: []model.DeviceID{"2"},
+			tagged:  []model.DeviceID{"2"},
+		},
+		"no filters": {
+			result: &model.UpdateResult{},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			db.Wipe()
+			ds := NewDataStoreMongoWithSession(db.Client())
+			for _, dev := range []model.Device{
+				{ID: "1", Attributes: attrs("raspberrypi4", "alice")},
+				{ID: "2", Attributes: attrs("raspberrypi4", "bob")},
+				{ID: "3", Attributes: attrs("x86", "carol")},
+			} {
+				dev := dev
+				err := ds.AddDevice(db.CTX(), &dev)
+				assert.NoError(t, err, "failed to setup input data")
+			}
+
+			result, matched, err := ds.UpdateDevicesTags(
+				db.CTX(), tc.params, set, unset,
+			)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.result, result)
+			assert.ElementsMatch(t, tc.matched, matched)
+
+			tagged := map[model.DeviceID]bool{}
+			for _, id := range tc.tagged {
+				tagged[id] = true
+			}
+			for _, id := range []model.DeviceID{"1", "2", "3"} {
+				dev, err := ds.GetDevice(db.CTX(), id)
+				assert.NoError(t, err)
+				tags := map[string]interface{}{}
+				for _, attr := range dev.Attributes {
+					if attr.Scope == model.AttrScopeTags {
+						tags[attr.Name] = attr.Value
+					}
+				}
+				if tagged[id] {
+					assert.Equal(t, map[string]interface{}{
+						"location": "oslo",
+					}, tags)
+				} else {
+					assert.NotContains(t, tags, "location")
+					assert.Contains(t, tags, "owner")
+				}
+			}
+		})
+	}
+}
+
+func TestPreviewDevicesGroup(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestPreviewDevicesGroup in short mode.")
+	}
+
+	deviceType := func(value string) model.DeviceAttributes {
+		return model.DeviceAttributes{{
+			Scope: model.AttrScopeInventory,
+			Name:  "device_type",
+			Value: value,
+		}}
+	}
+	rpi4 := []model.FilterPredicate{{
+		Scope:     model.AttrScopeInventory,
+		Attribute: "device_type",
+		Type:      "$eq",
+		Value:     "raspberrypi4",
+	}}
+	testCases := map[string]struct {
+		assignment model.GroupAssignment
+
+		preview *model.GroupAssignmentPreview
+	}{
+		"device IDs": {
+			assignment: model.GroupAssignment{
+				DeviceIDs: []model.DeviceID{"1", "2", "4", "4"},
+			},
+			preview: &model.GroupAssignmentPreview{
+				Matched:        2,
+				NotFound:       1,
+				AlreadyInGroup: 1,
+			},
+		},
+		"filters": {
+			assignment: model.GroupAssignment{Filters: rpi4},
+			preview: &model.GroupAssignmentPreview{
+				Matched:        2,
+				AlreadyInGroup: 1,
+			},
+		},
+		"device IDs and filters": {
+			assignment: model.GroupAssignment{
+				DeviceIDs: []model.DeviceID{"2", "3"},
+				Filters:   rpi4,
+			},
+			preview: &model.GroupAssignmentPreview{
+				Matched: 1,
+			},
+		},
+		"no devices": {
+			preview: &model.GroupAssignmentPreview{},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			db.Wipe()
+			ds := NewDataStoreMongoWithSession(db.Client())
+			for _, dev := range []model.Device{
+				{ID: "1", Group: "dev", Attributes: deviceType("raspberrypi4")},
+				{ID: "2", Attributes: deviceType("raspberrypi4")},
+				{ID: "3", Group: "dev", Attributes: deviceType("x86")},
+			} {
+				dev := dev
+				err := ds.AddDevice(db.CTX(), &dev)
+				assert.NoError(t, err, "failed to setup input data")
+			}
+
+			preview, err := ds.PreviewDevicesGroup(db.CTX(), tc.assignment, "dev")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.preview, preview)
+
+			// nothing is modified
+			groups, err := ds.GetDevicesGroups(db.CTX(),
+				[]model.DeviceID{"1", "2", "3"})
+			assert.NoError(t, err)
+			assert.Equal(t, map[model.DeviceID][]model.GroupName{
+				"1": {"dev"},
+				"2": nil,
+				"3": {"dev"},
+			}, groups)
+		})
+	}
+}
+
+func TestGetAllDeviceIDs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestGetAllDeviceIDs in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+
+	ids, err := ds.GetAllDeviceIDs(db.CTX())
+	assert.NoError(t, err)
+	assert.Empty(t, ids)
+
+	for _, dev := range []model.Device{{ID: "1"}, {ID: "2", Group: "dev"}} {
+		dev := dev
+		err := ds.AddDevice(db.CTX(), &dev)
+		assert.NoError(t, err, "failed to setup input data")
+	}
+	ids, err = ds.GetAllDeviceIDs(db.CTX())
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []model.DeviceID{"1", "2"}, ids)
+}
+
+func TestDeviceIDFromRaw(t *testing.T) {
+	testCases := map[string]struct {
+		doc interface{}
+
+		id  model.DeviceID
+		err string
+	}{
+		"ok": {
+			doc: bson.M{DbDevId: "1", DbDevUpdatedTs: time.Now()},
+			id:  "1",
+		},
+		"error, no ID": {
+			doc: bson.M{DbDevUpdatedTs: time.Now()},
+			err: "failed to decode device: element not found",
+		},
+		"error, ID type": {
+			doc: bson.M{DbDevId: 1},
+			err: "failed to decode device: unexpected ID type 32-bit integer",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			doc, err := bson.Marshal(tc.doc)
+			assert.NoError(t, err)
+
+			id, err := deviceIDFromRaw(doc)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.id, id)
+			}
+		})
+	}
+}
+
+func TestMongoExternalIDs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoExternalIDs in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+	ref := model.ExternalIDRef{System: "erp", ID: "SN-1"}
+
+	extID, err := ds.GetExternalID(ctx, ref)
+	assert.NoError(t, err)
+	assert.Nil(t, extID)
+
+	res, err := ds.UpsertExternalIDs(ctx, []model.ExternalID{
+		{ExternalIDRef: ref, DeviceID: "1"},
+		{ExternalIDRef: model.ExternalIDRef{System: "crm", ID: "SN-1"}, DeviceID: "2"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, &model.UpdateResult{CreatedCount: 2}, res)
+
+	// remap the external ID to another device
+	res, err = ds.UpsertExternalIDs(ctx, []model.ExternalID{
+		{ExternalIDRef: ref, DeviceID: "3"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, &model.UpdateResult{MatchedCount: 1, UpdatedCount: 1}, res)
+
+	extID, err = ds.GetExternalID(ctx, ref)
+	assert.NoError(t, err)
+	assert.Equal(t, &model.ExternalID{ExternalIDRef: ref, DeviceID: "3"}, extID)
+
+	err = ds.DeleteExternalID(ctx, ref)
+	assert.NoError(t, err)
+	err = ds.DeleteExternalID(ctx, ref)
+	assert.Equal(t, store.ErrExternalIDNotFound, err)
+}
+
+func TestGetDeviceGroupsWithTenant(t *testing.T) {
 	if testing.Short() {
-		t.Skip("skipping TestGetDeviceGroupWithTenant in short mode.")
+		t.Skip("skipping TestGetDeviceGroupsWithTenant in short mode.")
 	}
 
 	inputDevices := []model.Device{
@@ -3399,22 +4752,20 @@ func TestGetDeviceGroupWithTenant(t *testing.T) {
 
 	testCases := map[string]struct {
 		InputDeviceID model.DeviceID
-		OutputGroup   model.GroupName
+		OutputGroups  []model.GroupName
 		OutputError   error
 	}{
 		"dev has group": {
 			InputDeviceID: model.DeviceID("1"),
-			OutputGroup:   model.GroupName("dev"),
+			OutputGroups:  []model.GroupName{"dev"},
 			OutputError:   nil,
 		},
 		"dev has no group": {
 			InputDeviceID: model.DeviceID("2"),
-			OutputGroup:   "",
 			OutputError:   nil,
 		},
 		"dev doesn't exist": {
 			InputDeviceID: model.DeviceID("3"),
-			OutputGroup:   "",
 			OutputError:   store.ErrDevNotFound,
 		},
 	}
@@ -3435,13 +4786,13 @@ func TestGetDeviceGroupWithTenant(t *testing.T) {
 		ctx := identity.WithContext(db.CTX(), &identity.Identity{
 			Tenant: "foo",
 		})
-		group, err := store.GetDeviceGroup(ctx, tc.InputDeviceID)
+		groups, err := store.GetDeviceGroups(ctx, tc.InputDeviceID)
 
 		if tc.OutputError != nil {
 			assert.EqualError(t, err, tc.OutputError.Error())
 		} else {
 			assert.NoError(t, err, "expected no error")
-			assert.Equal(t, tc.OutputGroup, group)
+			assert.Equal(t, tc.OutputGroups, groups)
 		}
 	}
 }
@@ -3717,6 +5068,68 @@ func TestMongoDeleteDevices(t *testing.T) {
 	}
 }
 
+func TestMongoSortTieBreaker(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoSortTieBreaker in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+
+	// all the devices share the same value of the sort attribute
+	const numDevices = 20
+	for i := numDevices; i > 0; i-- {
+		dev := model.Device{
+			ID: model.DeviceID(fmt.Sprintf("%02d", i)),
+			Attributes: model.DeviceAttributes{{
+				Scope: model.AttrScopeInventory,
+				Name:  "device_type",
+				Value: "raspberrypi4",
+			}},
+		}
+		err := ds.AddDevice(ctx, &dev)
+		assert.NoError(t, err, "failed to setup input data")
+	}
+
+	var listed, searched []model.DeviceID
+	for page := 1; page <= numDevices/3+1; page++ {
+		devs, _, err := ds.GetDevices(ctx, store.ListQuery{
+			Skip:  (page - 1) * 3,
+			Limit: 3,
+			Sort: []store.Sort{{
+				AttrName:  "device_type",
+				AttrScope: model.AttrScopeInventory,
+			}},
+		})
+		assert.NoError(t, err)
+		for _, dev := range devs {
+			listed = append(listed, dev.ID)
+		}
+
+		devs, _, err = ds.SearchDevices(ctx, model.SearchParams{
+			Page:    page,
+			PerPage: 3,
+			Sort: []model.SortCriteria{{
+				Scope:     model.AttrScopeInventory,
+				Attribute: "device_type",
+				Order:     "desc",
+			}},
+		})
+		assert.NoError(t, err)
+		for _, dev := range devs {
+			searched = append(searched, dev.ID)
+		}
+	}
+
+	expected := make([]model.DeviceID, numDevices)
+	for i := range expected {
+		expected[i] = model.DeviceID(fmt.Sprintf("%02d", i+1))
+	}
+	assert.Equal(t, expected, listed)
+	assert.Equal(t, expected, searched)
+}
+
 func TestMongoSearchDevices(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping TestMongoSearchDevices in short mode.")
@@ -4346,6 +5759,20 @@ func TestWithAutomigrate(t *testing.T) {
 	newStore := store.WithAutomigrate()
 
 	assert.NotEqual(t, store, newStore)
+
+	// all the other settings are kept
+	orig := &DataStoreMongo{
+		client:            client,
+		names:             DefaultDbNames(),
+		groupsRollout:     &rollout{},
+		searchSampler:     &searchSampler{},
+		compressThreshold: 1024,
+		telemetry:         true,
+	}
+	expected := *orig
+	expected.automigrate = true
+	assert.Equal(t, &expected, orig.WithAutomigrate())
+	assert.False(t, orig.automigrate)
 }
 
 func TestMongoUpsertDevicesAttributesWithRevision(t *testing.T) {
@@ -4595,3 +6022,1766 @@ func TestMongoUpsertDevicesAttributesWithRevision(t *testing.T) {
 		})
 	}
 }
+
+func TestMongoUpsertDevicesAttributesSources(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoUpsertDevicesAttributesSources in short mode.")
+	}
+
+	db.Wipe()
+	client := db.Client()
+	store := NewDataStoreMongoWithSession(client)
+
+	devCtx := identity.WithContext(db.CTX(), &identity.Identity{
+		Subject:  "0001",
+		IsDevice: true,
+	})
+	_, err := store.UpsertDevicesAttributes(devCtx,
+		[]model.DeviceID{"0001"},
+		model.DeviceAttributes{
+			{Name: "mac", Value: "0001-mac", Scope: model.AttrScopeInventory},
+		},
+	)
+	assert.NoError(t, err)
+
+	userCtx := identity.WithContext(db.CTX(), &identity.Identity{
+		Subject: "user",
+		IsUser:  true,
+	})
+	_, err = store.UpsertDevicesAttributes(userCtx,
+		[]model.DeviceID{"0001"},
+		model.DeviceAttributes{
+			{Name: "mac", Value: "0001-mac", Scope: model.AttrScopeIdentity},
+		},
+	)
+	assert.NoError(t, err)
+
+	dev, err := store.GetDevice(db.CTX(), "0001")
+	assert.NoError(t, err)
+	if assert.NotNil(t, dev) {
+		assert.Len(t, dev.Sources, 2)
+		assert.Equal(t, model.SourceTypeDevice,
+			dev.Sources[model.AttrScopeInventory].Type)
+		assert.Equal(t, "0001", dev.Sources[model.AttrScopeInventory].ID)
+		assert.Equal(t, model.SourceTypeUser,
+			dev.Sources[model.AttrScopeIdentity].Type)
+		assert.Equal(t, "user", dev.Sources[model.AttrScopeIdentity].ID)
+	}
+}
+
+func TestMongoUpsertDevicesAttributesCompressed(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoUpsertDevicesAttributesCompressed in short mode.")
+	}
+
+	db.Wipe()
+	client := db.Client()
+	ds := &DataStoreMongo{
+		client:            client,
+		names:             DefaultDbNames(),
+		compressThreshold: 1024,
+	}
+	ctx := db.CTX()
+
+	dmesg := strings.Repeat("kernel: eth0: link up\n", 100)
+	_, err := ds.UpsertDevicesAttributes(ctx,
+		[]model.DeviceID{"0001"},
+		model.DeviceAttributes{
+			{Name: "dmesg", Value: dmesg, Scope: model.AttrScopeInventory},
+		},
+	)
+	assert.NoError(t, err)
+	err = ds.UpsertRemoveColdAttributes(ctx, "0001",
+		model.DeviceAttributes{
+			{Name: "dmesg", Value: dmesg, Scope: "logs"},
+			{Name: "boot", Value: "ok", Scope: "logs"},
+		}, nil,
+	)
+	assert.NoError(t, err)
+
+	// only the long values of the cold attributes are stored compressed
+	var doc struct {
+		Attributes map[string]struct {
+			Value bson.RawValue `bson:"value"`
+		} `bson:"attributes"`
+	}
+	err = client.Database(DbName).Collection(DbDevicesColl).
+		FindOne(ctx, bson.M{DbDevId: "0001"}).Decode(&doc)
+	assert.NoError(t, err)
+	assert.Equal(t, bsontype.String, doc.Attributes["inventory-dmesg"].Value.Type)
+	err = client.Database(DbName).Collection(DbColdAttributesColl).
+		FindOne(ctx, bson.M{DbDevId: "0001"}).Decode(&doc)
+	assert.NoError(t, err)
+	subtype, _ := doc.Attributes["logs-dmesg"].Value.Binary()
+	assert.Equal(t, model.AttrValueEncodingGzip, subtype)
+	assert.Equal(t, bsontype.String, doc.Attributes["logs-boot"].Value.Type)
+
+	// the long values of the devices are filtered and exported as is
+	devs, _, err := ds.GetDevices(ctx, store.ListQuery{
+		Filters: []store.Filter{{
+			AttrName:  "dmesg",
+			AttrScope: model.AttrScopeInventory,
+			Value:     "eth0: link up",
+			Operator:  store.Regex,
+		}},
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, devs, 1) {
+		assert.Equal(t, model.DeviceID("0001"), devs[0].ID)
+	}
+	stream, _, err := ds.StreamDevices(ctx, store.ListQuery{})
+	if assert.NoError(t, err) {
+		devs, err = stream.All()
+		stream.Close()
+		assert.NoError(t, err)
+		if assert.Len(t, devs, 1) {
+			for _, attr := range devs[0].Attributes {
+				if attr.Name == "dmesg" {
+					assert.Equal(t, dmesg, attr.Value)
+				}
+			}
+		}
+	}
+
+	attrs, err := ds.GetColdAttributes(ctx, "0001")
+	assert.NoError(t, err)
+	for _, attr := range attrs {
+		switch attr.Name {
+		case "dmesg":
+			assert.Equal(t, dmesg, attr.Value)
+		case "boot":
+			assert.Equal(t, "ok", attr.Value)
+		}
+	}
+
+	// a short value replaces the compressed one
+	err = ds.UpsertRemoveColdAttributes(ctx, "0001",
+		model.DeviceAttributes{
+			{Name: "dmesg", Value: "empty", Scope: "logs"},
+		}, nil,
+	)
+	assert.NoError(t, err)
+	attrs, err = ds.GetColdAttributes(ctx, "0001")
+	assert.NoError(t, err)
+	for _, attr := range attrs {
+		if attr.Name == "dmesg" {
+			assert.Equal(t, "empty", attr.Value)
+		}
+	}
+}
+
+func TestMongoGetAttributeValueCounts(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoGetAttributeValueCounts in short mode.")
+	}
+
+	db.Wipe()
+	store := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+
+	versions := map[model.DeviceID]string{
+		"1": "2.5.0", "2": "2.5.0", "3": "2.4.0", "4": "",
+	}
+	for id, version := range versions {
+		attrs := model.DeviceAttributes{
+			{Name: "mac", Value: string(id), Scope: model.AttrScopeInventory},
+		}
+		if version != "" {
+			attrs = append(attrs, model.DeviceAttribute{
+				Name:  "mender_client_version",
+				Value: version,
+				Scope: model.AttrScopeInventory,
+			})
+		}
+		_, err := store.UpsertDevicesAttributes(ctx, []model.DeviceID{id}, attrs)
+		assert.NoError(t, err)
+	}
+
+	counts, err := store.GetAttributeValueCounts(ctx,
+		model.AttrScopeInventory, "mender_client_version", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.AttributeValueCount{
+		{Value: "2.5.0", Count: 2},
+		{Value: "2.4.0", Count: 1},
+	}, counts)
+
+	counts, err = store.GetAttributeValueCounts(ctx,
+		model.AttrScopeInventory, "mender_client_version", 1)
+	assert.NoError(t, err)
+	assert.Len(t, counts, 1)
+
+	n, err := store.CountAttributeDevices(ctx,
+		model.AttrScopeInventory, "mender_client_version")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	tenants, err := store.ListTenantIDs(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{""}, tenants)
+}
+
+func TestMongoGetAttributeValueSets(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoGetAttributeValueSets in short mode.")
+	}
+
+	db.Wipe()
+	store := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+
+	gateways := map[model.DeviceID]interface{}{
+		"1": "gw-1",
+		"2": "gw-1",
+		"3": []interface{}{"gw-1", "gw-2"},
+	}
+	for id, gateway := range gateways {
+		_, err := store.UpsertDevicesAttributes(ctx, []model.DeviceID{id},
+			model.DeviceAttributes{{
+				Name:  "gateway_id",
+				Value: gateway,
+				Scope: model.AttrScopeInventory,
+			}})
+		assert.NoError(t, err)
+	}
+
+	sets, err := store.GetAttributeValueSets(ctx,
+		model.AttrScopeInventory, "gateway_id", 1, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, sets, 2) {
+		assert.Equal(t, []interface{}{"gw-1"}, sets[0].Values)
+		assert.Equal(t, 2, sets[0].Count)
+		assert.Len(t, sets[0].Devices, 1)
+		assert.Equal(t, []interface{}{"gw-1", "gw-2"}, sets[1].Values)
+		assert.Equal(t, 1, sets[1].Count)
+		assert.Equal(t, []model.DeviceID{"3"}, sets[1].Devices)
+	}
+
+	sets, err = store.GetAttributeValueSets(ctx,
+		model.AttrScopeInventory, "gateway_id", 1, 1)
+	assert.NoError(t, err)
+	assert.Len(t, sets, 1)
+}
+
+func TestMongoCountDevicesByQuery(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoCountDevicesByQuery in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+
+	groups := map[model.DeviceID]model.GroupName{
+		"1": "prod",
+		"2": "prod",
+		"3": "staging",
+		"4": "",
+	}
+	for id, group := range groups {
+		err := ds.AddDevice(ctx, &model.Device{ID: id, Group: group})
+		assert.NoError(t, err)
+	}
+
+	count, err := ds.CountDevicesByQuery(ctx, store.ListQuery{Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, count)
+
+	count, err = ds.CountDevicesByQuery(ctx, store.ListQuery{GroupName: "prod"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	hasGroup := false
+	count, err = ds.CountDevicesByQuery(ctx, store.ListQuery{HasGroup: &hasGroup})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestMongoCountDevicesByAttributePair(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoCountDevicesByAttributePair in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+
+	devices := map[model.DeviceID]model.DeviceAttributes{
+		"1": {
+			{Name: "device_type", Value: "rpi4", Scope: model.AttrScopeInventory},
+			{Name: "artifact_name", Value: "release-2", Scope: model.AttrScopeInventory},
+		},
+		"2": {
+			{Name: "device_type", Value: "rpi4", Scope: model.AttrScopeInventory},
+			{Name: "artifact_name", Value: "release-2", Scope: model.AttrScopeInventory},
+		},
+		"3": {
+			{Name: "device_type", Value: "rpi3", Scope: model.AttrScopeInventory},
+		},
+	}
+	for id, attrs := range devices {
+		_, err := ds.UpsertDevicesAttributes(ctx, []model.DeviceID{id}, attrs)
+		assert.NoError(t, err)
+	}
+
+	row := model.SelectAttribute{Scope: model.AttrScopeInventory, Attribute: "device_type"}
+	column := model.SelectAttribute{Scope: model.AttrScopeInventory, Attribute: "artifact_name"}
+	counts, err := ds.CountDevicesByAttributePair(ctx, store.ListQuery{},
+		row, column, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.AttributePairCount{
+		{Row: "rpi4", Column: "release-2", Count: 2},
+		{Row: "rpi3", Column: nil, Count: 1},
+	}, counts)
+
+	counts, err = ds.CountDevicesByAttributePair(ctx, store.ListQuery{
+		Filters: []store.Filter{{
+			AttrName:  "device_type",
+			AttrScope: model.AttrScopeInventory,
+			Value:     "rpi3",
+			Operator:  store.Eq,
+		}},
+	}, row, column, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.AttributePairCount{
+		{Row: "rpi3", Column: nil, Count: 1},
+	}, counts)
+}
+
+func TestMongoScopes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoScopes in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+
+	scopes, err := ds.GetScopes(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, scopes)
+
+	telemetry := model.Scope{Name: "telemetry", Writer: model.SourceTypeDevice}
+	warranty := model.Scope{Name: "warranty", Writer: model.SourceTypeUser}
+	assert.NoError(t, ds.UpsertScope(ctx, warranty))
+	assert.NoError(t, ds.UpsertScope(ctx, telemetry))
+
+	warranty.RetentionDays = 30
+	assert.NoError(t, ds.UpsertScope(ctx, warranty))
+
+	scopes, err = ds.GetScopes(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.Scope{telemetry, warranty}, scopes)
+
+	assert.NoError(t, ds.DeleteScope(ctx, "telemetry"))
+	assert.Equal(t, store.ErrScopeNotFound, ds.DeleteScope(ctx, "telemetry"))
+}
+
+func TestMongoAttributeDefinitions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoAttributeDefinitions in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+
+	defs, err := ds.GetAttributeDefinitions(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, defs)
+
+	gps := model.AttributeDefinition{Scope: "inventory", Name: "last_gps_fix"}
+	rssi := model.AttributeDefinition{Scope: "telemetry", Name: "rssi", TTLDays: 1}
+	assert.NoError(t, ds.UpsertAttributeDefinition(ctx, rssi))
+	assert.NoError(t, ds.UpsertAttributeDefinition(ctx, gps))
+
+	gps.TTLDays = 30
+	assert.NoError(t, ds.UpsertAttributeDefinition(ctx, gps))
+
+	defs, err = ds.GetAttributeDefinitions(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.AttributeDefinition{gps, rssi}, defs)
+
+	assert.NoError(t, ds.DeleteAttributeDefinition(ctx, "telemetry", "rssi"))
+	assert.Equal(t, store.ErrAttributeDefinitionNotFound,
+		ds.DeleteAttributeDefinition(ctx, "telemetry", "rssi"))
+}
+
+func TestMongoGetDevicesUpdatedSince(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoGetDevicesUpdatedSince in short mode.")
+	}
+
+	db.Wipe()
+	client := db.Client()
+	ds := NewDataStoreMongoWithSession(client)
+	ctx := db.CTX()
+
+	for _, id := range []model.DeviceID{"1", "2", "3", "4"} {
+		_, err := ds.UpsertDevicesAttributesWithUpdated(ctx,
+			[]model.DeviceID{id},
+			model.DeviceAttributes{
+				{Name: "mac", Value: string(id), Scope: model.AttrScopeInventory},
+			},
+		)
+		assert.NoError(t, err)
+	}
+	// devices 3 and 2 were updated at the same time, after device 4;
+	// device 1 was updated before
+	since := time.Now().Add(-time.Hour).UTC().Truncate(time.Millisecond)
+	c := client.Database(DbName).Collection(DbDevicesColl)
+	for id, ts := range map[model.DeviceID]time.Time{
+		"1": since.Add(-time.Minute),
+		"2": since.Add(time.Minute),
+		"3": since.Add(time.Minute),
+		"4": since,
+	} {
+		_, err := c.UpdateOne(ctx, bson.M{DbDevId: id}, bson.M{"$set": bson.M{
+			DbDevAttributesUpdatedValue: ts,
+		}})
+		assert.NoError(t, err)
+	}
+
+	ids := func(devs []model.Device) []model.DeviceID {
+		res := []model.DeviceID{}
+		for _, dev := range devs {
+			res = append(res, dev.ID)
+		}
+		return res
+	}
+
+	devs, total, err := ds.GetDevices(ctx, store.ListQuery{
+		Limit:        2,
+		UpdatedSince: &since,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, []model.DeviceID{"4", "2"}, ids(devs))
+
+	// resume after the last device of the page
+	last := devs[len(devs)-1]
+	devs, total, err = ds.GetDevices(ctx, store.ListQuery{
+		Limit:          2,
+		UpdatedSince:   &last.UpdatedTs,
+		UpdatedAfterID: last.ID,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, []model.DeviceID{"3"}, ids(devs))
+}
+
+func TestMongoUnsetExpiredAttributes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoUnsetExpiredAttributes in short mode.")
+	}
+
+	db.Wipe()
+	client := db.Client()
+	ds := NewDataStoreMongoWithSession(client)
+	ctx := db.CTX()
+
+	for _, id := range []model.DeviceID{"1", "2", "3"} {
+		_, err := ds.UpsertDevicesAttributesWithUpdated(ctx,
+			[]model.DeviceID{id},
+			model.DeviceAttributes{
+				{Name: "mac", Value: string(id), Scope: model.AttrScopeInventory},
+				{Name: "last_gps_fix", Value: "52.2,21.0", Scope: model.AttrScopeInventory},
+			},
+		)
+		assert.NoError(t, err)
+	}
+
+	old := time.Now().Add(-40 * 24 * time.Hour)
+	c := client.Database(DbName).Collection(DbDevicesColl)
+	// device 1 did not report its position for 40 days
+	_, err := c.UpdateOne(ctx, bson.M{DbDevId: "1"}, bson.M{"$set": bson.M{
+		makeAttrField("last_gps_fix", model.AttrScopeInventory, DbDevAttributesTs): old,
+	}})
+	assert.NoError(t, err)
+	// device 2 was last updated 40 days ago, before the time of the
+	// update was tracked per attribute
+	_, err = c.UpdateOne(ctx, bson.M{DbDevId: "2"}, bson.M{
+		"$unset": bson.M{
+			makeAttrField("last_gps_fix", model.AttrScopeInventory, DbDevAttributesTs): true,
+		},
+		"$set": bson.M{
+			makeAttrField(model.AttrNameUpdated, model.AttrScopeSystem, DbDevAttributesValue): old,
+		},
+	})
+	assert.NoError(t, err)
+
+	n, err := ds.UnsetExpiredAttributes(ctx,
+		model.AttrScopeInventory, "last_gps_fix",
+		time.Now().Add(-30*24*time.Hour),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	for id, expired := range map[model.DeviceID]bool{"1": true, "2": true, "3": false} {
+		dev, err := ds.GetDevice(ctx, id)
+		assert.NoError(t, err)
+		if assert.NotNil(t, dev) {
+			names := []string{}
+			for _, attr := range dev.Attributes {
+				names = append(names, attr.Name)
+			}
+			assert.Contains(t, names, "mac")
+			if expired {
+				assert.NotContains(t, names, "last_gps_fix")
+			} else {
+				assert.Contains(t, names, "last_gps_fix")
+			}
+		}
+	}
+}
+
+func TestMongoSchemaViolations(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoSchemaViolations in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+
+	first := time.Now().Add(-time.Hour).UTC().Truncate(time.Millisecond)
+	last := first.Add(time.Minute)
+	assert.NoError(t, ds.RecordSchemaViolations(ctx, []model.SchemaViolation{
+		{DeviceID: "1", Scope: "inventory", Name: "kernel", Value: 5.0,
+			Error: "must be of type string", FirstTs: first, LastTs: first},
+		{DeviceID: "2", Scope: "inventory", Name: "kernel", Value: 4.0,
+			Error: "must be of type string", FirstTs: first, LastTs: first},
+	}))
+	assert.NoError(t, ds.RecordSchemaViolations(ctx, []model.SchemaViolation{
+		{DeviceID: "1", Scope: "inventory", Name: "kernel", Value: 5.1,
+			Error: "must be of type string", FirstTs: last, LastTs: last},
+	}))
+
+	violations, total, err := ds.GetSchemaViolations(ctx, "", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, []model.SchemaViolation{
+		{DeviceID: "1", Scope: "inventory", Name: "kernel", Value: 5.1,
+			Error: "must be of type string", Count: 2,
+			FirstTs: first, LastTs: last},
+		{DeviceID: "2", Scope: "inventory", Name: "kernel", Value: 4.0,
+			Error: "must be of type string", Count: 1,
+			FirstTs: first, LastTs: first},
+	}, violations)
+
+	violations, total, err = ds.GetSchemaViolations(ctx, "2", 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Len(t, violations, 1)
+}
+
+func TestMongoFeatureFlags(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoFeatureFlags in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+	tenantCtx := identity.WithContext(ctx, &identity.Identity{
+		Tenant: "tenant",
+	})
+	enabled, disabled := true, false
+
+	flags, err := ds.GetFeatureFlags(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, model.FeatureFlagSet{}, flags)
+
+	err = ds.UpdateFeatureFlags(ctx, model.FeatureFlagsUpdate{
+		model.FeatureWebhooks: &disabled,
+		model.FeatureAPIv2:    &enabled,
+	})
+	assert.NoError(t, err)
+	err = ds.UpdateFeatureFlags(ctx, model.FeatureFlagsUpdate{
+		model.FeatureAPIv2:         nil,
+		model.FeatureDynamicGroups: &enabled,
+	})
+	assert.NoError(t, err)
+
+	flags, err = ds.GetFeatureFlags(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, model.FeatureFlagSet{
+		model.FeatureWebhooks:      false,
+		model.FeatureDynamicGroups: true,
+	}, flags)
+
+	// the flags are kept per tenant
+	flags, err = ds.GetFeatureFlags(tenantCtx)
+	assert.NoError(t, err)
+	assert.Equal(t, model.FeatureFlagSet{}, flags)
+}
+
+func TestMongoLimits(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoLimits in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+	tenantCtx := identity.WithContext(ctx, &identity.Identity{
+		Tenant: "tenant",
+	})
+
+	limits, err := ds.GetLimits(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, model.Limits{}, limits)
+
+	err = ds.SetLimits(ctx, model.Limits{PerPage: 100, Filters: 5})
+	assert.NoError(t, err)
+	err = ds.SetLimits(ctx, model.Limits{ExportDevices: 1000})
+	assert.NoError(t, err)
+
+	limits, err = ds.GetLimits(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, model.Limits{ExportDevices: 1000}, limits)
+
+	// the limits are kept per tenant
+	limits, err = ds.GetLimits(tenantCtx)
+	assert.NoError(t, err)
+	assert.Equal(t, model.Limits{}, limits)
+}
+
+func TestMongoValidationWebhook(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoValidationWebhook in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+	tenantCtx := identity.WithContext(ctx, &identity.Identity{
+		Tenant: "tenant",
+	})
+
+	hook, err := ds.GetValidationWebhook(ctx)
+	assert.NoError(t, err)
+	assert.Nil(t, hook)
+
+	err = ds.SetValidationWebhook(ctx, model.ValidationWebhook{
+		URL:           "https://a.example.com",
+		FailurePolicy: model.ValidationFailOpen,
+	})
+	assert.NoError(t, err)
+	expected := model.ValidationWebhook{
+		URL:           "https://b.example.com",
+		TimeoutMS:     500,
+		FailurePolicy: model.ValidationFailClosed,
+	}
+	err = ds.SetValidationWebhook(ctx, expected)
+	assert.NoError(t, err)
+
+	hook, err = ds.GetValidationWebhook(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, &expected, hook)
+
+	// the webhook is kept per tenant
+	hook, err = ds.GetValidationWebhook(tenantCtx)
+	assert.NoError(t, err)
+	assert.Nil(t, hook)
+
+	err = ds.DeleteValidationWebhook(ctx)
+	assert.NoError(t, err)
+	hook, err = ds.GetValidationWebhook(ctx)
+	assert.NoError(t, err)
+	assert.Nil(t, hook)
+}
+
+func TestMongoPinnedAttributes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoPinnedAttributes in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+	tenantCtx := identity.WithContext(ctx, &identity.Identity{
+		Tenant: "tenant",
+	})
+
+	pinned, err := ds.GetPinnedAttributes(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, model.PinnedAttributes{}, pinned)
+
+	expected := model.PinnedAttributes{Attributes: []model.SelectAttribute{
+		{Scope: model.AttrScopeInventory, Attribute: "os"},
+		{Scope: model.AttrScopeIdentity, Attribute: "mac"},
+	}}
+	err = ds.SetPinnedAttributes(ctx, model.PinnedAttributes{
+		Attributes: []model.SelectAttribute{
+			{Scope: model.AttrScopeInventory, Attribute: "kernel"},
+		},
+	})
+	assert.NoError(t, err)
+	err = ds.SetPinnedAttributes(ctx, expected)
+	assert.NoError(t, err)
+
+	pinned, err = ds.GetPinnedAttributes(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, pinned)
+
+	// the pinned attributes are kept per tenant
+	pinned, err = ds.GetPinnedAttributes(tenantCtx)
+	assert.NoError(t, err)
+	assert.Equal(t, model.PinnedAttributes{}, pinned)
+}
+
+func TestMongoAttributeAliases(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoAttributeAliases in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+
+	aliases, err := ds.GetAttributeAliases(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, model.AttributeAliases{}, aliases)
+
+	expected := model.AttributeAliases{Attributes: []model.AttributeAlias{{
+		Scope:   model.AttrScopeInventory,
+		Name:    "ip_address",
+		Aliases: []string{"ipv4"},
+	}}}
+	assert.NoError(t, ds.SetAttributeAliases(ctx, expected))
+	aliases, err = ds.GetAttributeAliases(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, aliases)
+
+	// the devices match and sort under any of the names
+	for id, attr := range map[string]string{
+		"1": "ip_address", "2": "ipv4", "3": "mac",
+	} {
+		value := "10.0.0." + id
+		if attr == "mac" {
+			value = "00:11"
+		}
+		err := ds.AddDevice(ctx, &model.Device{
+			ID: model.DeviceID(id),
+			Attributes: model.DeviceAttributes{{
+				Scope: model.AttrScopeInventory,
+				Name:  attr,
+				Value: value,
+			}},
+		})
+		assert.NoError(t, err)
+	}
+	params := aliases.Apply(model.SearchParams{
+		Page:    1,
+		PerPage: 10,
+		Filters: []model.FilterPredicate{{
+			Scope:     model.AttrScopeInventory,
+			Attribute: "ipv4",
+			Type:      "$exists",
+			Value:     true,
+		}},
+		Sort: []model.SortCriteria{{
+			Scope:     model.AttrScopeInventory,
+			Attribute: "ip_address",
+			Order:     "desc",
+		}},
+	})
+	devs, total, err := ds.SearchDevices(ctx, params)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, total)
+	if assert.Len(t, devs, 2) {
+		assert.Equal(t, model.DeviceID("2"), devs[0].ID)
+		assert.Equal(t, model.DeviceID("1"), devs[1].ID)
+	}
+}
+
+func TestMongoCompleteness(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoCompleteness in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+
+	os := model.DeviceAttribute{
+		Scope: model.AttrScopeInventory, Name: "os", Value: "linux",
+	}
+	mac := model.DeviceAttribute{
+		Scope: model.AttrScopeIdentity, Name: "mac", Value: "00:00",
+	}
+	group := model.DeviceAttribute{
+		Scope: model.AttrScopeSystem, Name: model.AttrNameGroup, Value: "dev",
+	}
+	devices := map[model.DeviceID]model.DeviceAttributes{
+		"1": {os, mac, group},
+		"2": {group},
+		"3": {os},
+	}
+	for id, attrs := range devices {
+		_, err := ds.UpsertDevicesAttributes(ctx, []model.DeviceID{id}, attrs)
+		assert.NoError(t, err)
+	}
+	required := []model.SelectAttribute{
+		{Scope: model.AttrScopeInventory, Attribute: "os"},
+		{Scope: model.AttrScopeIdentity, Attribute: "mac"},
+	}
+
+	groups, err := ds.GetGroupsCompleteness(ctx, required)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.GroupCompleteness{
+		{Devices: 1, Complete: 0, Completeness: 0.5},
+		{Group: "dev", Devices: 2, Complete: 1, Completeness: 0.5},
+	}, groups)
+
+	// all the devices are complete if no attributes are required
+	groups, err = ds.GetGroupsCompleteness(ctx, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.GroupCompleteness{
+		{Devices: 1, Complete: 1, Completeness: 1},
+		{Group: "dev", Devices: 2, Complete: 2, Completeness: 1},
+	}, groups)
+
+	min, max := 0.5, 0.5
+	devs, totalCount, err := ds.SearchDevices(ctx, model.SearchParams{
+		Page:               1,
+		PerPage:            20,
+		Completeness:       &model.CompletenessRange{Min: &min, Max: &max},
+		RequiredAttributes: required,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, totalCount)
+	if assert.Len(t, devs, 1) {
+		assert.Equal(t, model.DeviceID("3"), devs[0].ID)
+	}
+}
+
+func TestMongoIncompleteDevices(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoIncompleteDevices in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+
+	os := model.DeviceAttribute{
+		Scope: model.AttrScopeInventory, Name: "os", Value: "linux",
+	}
+	cpu := model.DeviceAttribute{
+		Scope: model.AttrScopeInventory, Name: "cpu", Value: "arm",
+	}
+	devices := map[model.DeviceID]model.DeviceAttributes{
+		"1": {os, cpu},
+		"2": {cpu},
+		"3": {os},
+	}
+	for id, attrs := range devices {
+		_, err := ds.UpsertDevicesAttributes(ctx, []model.DeviceID{id}, attrs)
+		assert.NoError(t, err)
+	}
+	required := []model.SelectAttribute{
+		{Scope: model.AttrScopeInventory, Attribute: "os"},
+		{Scope: model.AttrScopeInventory, Attribute: "cpu"},
+	}
+
+	devs, total, err := ds.GetIncompleteDevices(ctx,
+		store.IncompleteDevicesQuery{Required: required, Limit: 1},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, total)
+	if assert.Len(t, devs, 1) {
+		assert.Equal(t, model.DeviceID("2"), devs[0].ID)
+		assert.Len(t, devs[0].Attributes, 1)
+	}
+
+	until := time.Now().Add(time.Hour)
+	devs, total, err = ds.GetIncompleteDevices(ctx,
+		store.IncompleteDevicesQuery{
+			Required:     required,
+			CreatedUntil: &until,
+			Skip:         1,
+		},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, total)
+	if assert.Len(t, devs, 1) {
+		assert.Equal(t, model.DeviceID("3"), devs[0].ID)
+	}
+
+	// none were first seen after the time
+	devs, total, err = ds.GetIncompleteDevices(ctx,
+		store.IncompleteDevicesQuery{Required: required, CreatedFrom: &until},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, total)
+	assert.Empty(t, devs)
+
+	// no device is incomplete if no attributes are required
+	devs, total, err = ds.GetIncompleteDevices(ctx,
+		store.IncompleteDevicesQuery{},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, total)
+	assert.Empty(t, devs)
+}
+
+func TestMongoCompletenessAlert(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoCompletenessAlert in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+
+	alert, err := ds.GetCompletenessAlert(ctx)
+	assert.NoError(t, err)
+	assert.Nil(t, alert)
+
+	checkedTs := time.Now().UTC().Truncate(time.Millisecond)
+	expected := model.CompletenessAlert{
+		Channel: model.NotificationChannel{
+			Type:  model.NotificationChannelEmail,
+			Email: "ops@example.com",
+		},
+		AfterHours: 24,
+		CheckedTs:  &checkedTs,
+	}
+	assert.NoError(t, ds.SetCompletenessAlert(ctx, expected))
+
+	alert, err = ds.GetCompletenessAlert(ctx)
+	assert.NoError(t, err)
+	if assert.NotNil(t, alert) {
+		assert.Equal(t, expected.Channel, alert.Channel)
+		assert.Equal(t, expected.AfterHours, alert.AfterHours)
+		assert.True(t, checkedTs.Equal(*alert.CheckedTs))
+	}
+
+	assert.NoError(t, ds.DeleteCompletenessAlert(ctx))
+	alert, err = ds.GetCompletenessAlert(ctx)
+	assert.NoError(t, err)
+	assert.Nil(t, alert)
+}
+
+func TestMongoSubscriptions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoSubscriptions in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+
+	err := ds.AddDevice(ctx, &model.Device{
+		ID: "1",
+		Attributes: model.DeviceAttributes{{
+			Scope: model.AttrScopeInventory,
+			Name:  "status",
+			Value: "online",
+		}},
+	})
+	assert.NoError(t, err)
+
+	filters := []model.FilterPredicate{{
+		Scope:     model.AttrScopeInventory,
+		Attribute: "status",
+		Type:      "$eq",
+		Value:     "online",
+	}}
+	sub := model.Subscription{
+		ID:      "sub",
+		UserID:  "user",
+		Filters: filters,
+		Channel: model.NotificationChannel{
+			Type:  model.NotificationChannelEmail,
+			Email: "user@example.com",
+		},
+		CreatedTs: time.Now().UTC().Truncate(time.Millisecond),
+	}
+	assert.NoError(t, ds.CreateSubscription(ctx, sub))
+
+	subs, err := ds.GetSubscriptions(ctx, "user")
+	assert.NoError(t, err)
+	assert.Equal(t, []model.Subscription{sub}, subs)
+	subs, err = ds.GetSubscriptions(ctx, "other-user")
+	assert.NoError(t, err)
+	assert.Empty(t, subs)
+
+	matches, err := ds.DeviceMatches(ctx, "1", filters)
+	assert.NoError(t, err)
+	assert.True(t, matches)
+	matches, err = ds.DeviceMatches(ctx, "2", filters)
+	assert.NoError(t, err)
+	assert.False(t, matches)
+
+	// only the transition to matching is reported
+	started, err := ds.SetSubscriptionMatch(ctx, "sub", "1", true)
+	assert.NoError(t, err)
+	assert.True(t, started)
+	started, err = ds.SetSubscriptionMatch(ctx, "sub", "1", true)
+	assert.NoError(t, err)
+	assert.False(t, started)
+	started, err = ds.SetSubscriptionMatch(ctx, "sub", "1", false)
+	assert.NoError(t, err)
+	assert.False(t, started)
+	started, err = ds.SetSubscriptionMatch(ctx, "sub", "1", true)
+	assert.NoError(t, err)
+	assert.True(t, started)
+
+	// replacing the subscription with the same filters keeps the matches
+	sub.Channel.Email = "admin@example.com"
+	assert.NoError(t, ds.UpsertSubscription(ctx, sub))
+	started, err = ds.SetSubscriptionMatch(ctx, "sub", "1", true)
+	assert.NoError(t, err)
+	assert.False(t, started)
+	// new filters forget them
+	sub.Filters = append(sub.Filters, model.FilterPredicate{
+		Scope:     model.AttrScopeInventory,
+		Attribute: "name",
+		Type:      "$exists",
+		Value:     true,
+	})
+	assert.NoError(t, ds.UpsertSubscription(ctx, sub))
+	started, err = ds.SetSubscriptionMatch(ctx, "sub", "1", true)
+	assert.NoError(t, err)
+	assert.True(t, started)
+	subs, err = ds.GetSubscriptions(ctx, "user")
+	assert.NoError(t, err)
+	assert.Equal(t, []model.Subscription{sub}, subs)
+
+	// the IDs are unique across the users
+	other := sub
+	other.UserID = "other-user"
+	assert.Equal(t, store.ErrWriteConflict, ds.UpsertSubscription(ctx, other))
+	other.ID = "other-sub"
+	assert.NoError(t, ds.UpsertSubscription(ctx, other))
+
+	err = ds.DeleteSubscription(ctx, "other-user", "sub")
+	assert.Equal(t, store.ErrSubscriptionNotFound, err)
+	assert.NoError(t, ds.DeleteSubscription(ctx, "user", "sub"))
+	n, err := db.Client().Database(DbName).
+		Collection(DbSubscriptionMatchesColl).
+		CountDocuments(ctx, bson.M{})
+	assert.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestMongoExportJobs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoExportJobs in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+
+	job := model.ExportJob{
+		ID:        "job",
+		Kind:      model.ExportKindInventory,
+		Format:    model.ExportFormatParquet,
+		Status:    model.ExportStatusPending,
+		CreatedTs: time.Now().UTC().Truncate(time.Millisecond),
+	}
+	assert.NoError(t, ds.CreateExportJob(ctx, job))
+
+	res, err := ds.GetExportJob(ctx, "job")
+	assert.NoError(t, err)
+	assert.Equal(t, &job, res)
+
+	finished := time.Now().UTC().Truncate(time.Millisecond)
+	job.Status = model.ExportStatusDone
+	job.Object = "exports/default/job.parquet"
+	job.Devices = 3
+	job.FinishedTs = &finished
+	assert.NoError(t, ds.UpdateExportJob(ctx, job))
+
+	res, err = ds.GetExportJob(ctx, "job")
+	assert.NoError(t, err)
+	assert.Equal(t, &job, res)
+
+	_, err = ds.GetExportJob(ctx, "other")
+	assert.Equal(t, store.ErrExportJobNotFound, err)
+	job.ID = "other"
+	assert.Equal(t, store.ErrExportJobNotFound, ds.UpdateExportJob(ctx, job))
+}
+
+func TestMongoDeadLetters(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoDeadLetters in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	letters := []model.DeadLetter{{
+		ID:            "2",
+		URL:           "https://hooks.example.com",
+		Payload:       json.RawMessage(`[{"id":"2"}]`),
+		Reason:        "connection refused",
+		Attempts:      1,
+		CreatedTs:     now.Add(-time.Minute),
+		LastAttemptTs: now.Add(-time.Minute),
+	}, {
+		ID:             "1",
+		URL:            "https://hooks.example.com",
+		SubscriptionID: "sub",
+		Payload:        json.RawMessage(`[{"id":"1"}]`),
+		Reason:         "connection refused",
+		Attempts:       1,
+		CreatedTs:      now,
+		LastAttemptTs:  now,
+	}}
+	for _, letter := range letters {
+		assert.NoError(t, ds.CreateDeadLetter(ctx, letter))
+	}
+
+	res, total, err := ds.GetDeadLetters(ctx, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, letters, res)
+	res, total, err = ds.GetDeadLetters(ctx, 1, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, letters[1:], res)
+
+	letter := letters[0]
+	letter.Attempts = 2
+	assert.NoError(t, ds.UpdateDeadLetter(ctx, letter))
+	got, err := ds.GetDeadLetter(ctx, "2")
+	assert.NoError(t, err)
+	assert.Equal(t, &letter, got)
+
+	assert.NoError(t, ds.DeleteDeadLetter(ctx, "2"))
+	assert.Equal(t, store.ErrDeadLetterNotFound, ds.DeleteDeadLetter(ctx, "2"))
+	_, err = ds.GetDeadLetter(ctx, "2")
+	assert.Equal(t, store.ErrDeadLetterNotFound, err)
+	assert.Equal(t, store.ErrDeadLetterNotFound, ds.UpdateDeadLetter(ctx, letter))
+
+	result, err := ds.PurgeDeadLetters(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, &model.UpdateResult{DeletedCount: 1}, result)
+}
+
+func TestMongoSavedFilters(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoSavedFilters in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	filters := []model.SavedFilter{{
+		ID:      "1",
+		Name:    "offline",
+		OwnerID: "user",
+		Filters: []model.FilterPredicate{{
+			Scope:     model.AttrScopeInventory,
+			Attribute: "status",
+			Type:      "$eq",
+			Value:     "offline",
+		}},
+		CreatedTs: now,
+		UpdatedTs: now,
+	}, {
+		ID:        "2",
+		Name:      "arm",
+		OwnerID:   "user",
+		Shared:    true,
+		Filters:   []model.FilterPredicate{},
+		CreatedTs: now,
+		UpdatedTs: now,
+	}, {
+		ID:        "3",
+		Name:      "x86",
+		OwnerID:   "other",
+		Shared:    true,
+		Filters:   []model.FilterPredicate{},
+		CreatedTs: now,
+		UpdatedTs: now,
+	}}
+	for _, f := range filters {
+		assert.NoError(t, ds.CreateSavedFilter(ctx, f))
+	}
+
+	shared := true
+	private := false
+	res, err := ds.GetSavedFilters(ctx, store.SavedFiltersQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.SavedFilter{filters[1], filters[0], filters[2]}, res)
+	res, err = ds.GetSavedFilters(ctx, store.SavedFiltersQuery{
+		OwnerID: "user",
+		Shared:  &private,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.SavedFilter{filters[0]}, res)
+	res, err = ds.GetSavedFilters(ctx, store.SavedFiltersQuery{
+		Shared: &shared,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.SavedFilter{filters[1], filters[2]}, res)
+
+	updated := filters[0]
+	updated.Shared = true
+	assert.NoError(t, ds.UpsertSavedFilter(ctx, updated))
+	filter, err := ds.GetSavedFilter(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, &updated, filter)
+
+	// the IDs are unique across the owners
+	taken := updated
+	taken.OwnerID = "other-user"
+	assert.Equal(t, store.ErrWriteConflict, ds.UpsertSavedFilter(ctx, taken))
+
+	assert.NoError(t, ds.DeleteSavedFilter(ctx, "1"))
+	_, err = ds.GetSavedFilter(ctx, "1")
+	assert.Equal(t, store.ErrSavedFilterNotFound, err)
+	assert.Equal(t, store.ErrSavedFilterNotFound,
+		ds.DeleteSavedFilter(ctx, "1"))
+
+	// the upsert creates the filter
+	assert.NoError(t, ds.UpsertSavedFilter(ctx, updated))
+	filter, err = ds.GetSavedFilter(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, &updated, filter)
+}
+
+func TestMongoDynamicGroups(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoDynamicGroups in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	groups := []model.DynamicGroup{{
+		Name:       "rpi4",
+		Expression: `inventory/device_type == "raspberrypi4"`,
+		CreatedTs:  now,
+		UpdatedTs:  now,
+	}, {
+		Name:       "arm",
+		Expression: `inventory/arch in ["arm", "arm64"]`,
+		CreatedTs:  now,
+		UpdatedTs:  now,
+	}}
+	for _, g := range groups {
+		assert.NoError(t, ds.UpsertDynamicGroup(ctx, g))
+	}
+
+	res, err := ds.GetDynamicGroups(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.DynamicGroup{groups[1], groups[0]}, res)
+
+	updated := groups[0]
+	updated.Expression = `inventory/device_type matches "^raspberrypi"`
+	assert.NoError(t, ds.UpsertDynamicGroup(ctx, updated))
+	group, err := ds.GetDynamicGroup(ctx, "rpi4")
+	assert.NoError(t, err)
+	assert.Equal(t, &updated, group)
+
+	assert.NoError(t, ds.DeleteDynamicGroup(ctx, "rpi4"))
+	_, err = ds.GetDynamicGroup(ctx, "rpi4")
+	assert.Equal(t, store.ErrDynamicGroupNotFound, err)
+	assert.Equal(t, store.ErrDynamicGroupNotFound,
+		ds.DeleteDynamicGroup(ctx, "rpi4"))
+}
+
+func TestMongoGroupsMetadata(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoGroupsMetadata in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	groups := []model.GroupMetadata{{
+		Name:        "prod",
+		Type:        model.GroupTypeStatic,
+		Description: "production devices",
+		CreatedBy:   "user-1",
+		CreatedTs:   now,
+		UpdatedTs:   now,
+	}, {
+		Name:      "arm",
+		Type:      model.GroupTypeDynamic,
+		CreatedTs: now,
+		UpdatedTs: now,
+	}}
+	for _, g := range groups {
+		assert.NoError(t, ds.UpsertGroupMetadata(ctx, g))
+	}
+
+	res, err := ds.GetGroupsMetadata(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.GroupMetadata{groups[1], groups[0]}, res)
+
+	// the inserts keep the metadata already stored
+	assert.NoError(t, ds.InsertGroupsMetadata(ctx, []model.GroupMetadata{{
+		Name:      "prod",
+		Type:      model.GroupTypeStatic,
+		CreatedTs: now,
+		UpdatedTs: now,
+	}, {
+		Name:      "staging",
+		Type:      model.GroupTypeStatic,
+		CreatedTs: now,
+		UpdatedTs: now,
+	}}))
+	group, err := ds.GetGroupMetadata(ctx, "prod")
+	assert.NoError(t, err)
+	assert.Equal(t, &groups[0], group)
+	group, err = ds.GetGroupMetadata(ctx, "staging")
+	assert.NoError(t, err)
+	assert.Equal(t, model.GroupTypeStatic, group.Type)
+
+	assert.NoError(t, ds.DeleteGroupMetadata(ctx, "prod"))
+	_, err = ds.GetGroupMetadata(ctx, "prod")
+	assert.Equal(t, store.ErrGroupMetadataNotFound, err)
+	assert.Equal(t, store.ErrGroupMetadataNotFound,
+		ds.DeleteGroupMetadata(ctx, "prod"))
+}
+
+func TestMongoGetDevicesCursor(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoGetDevicesCursor in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+
+	// the devices in the order of the group, ascending, then of
+	// the memory, descending
+	expected := []model.DeviceID{}
+	for _, group := range []string{"", "prod", "staging"} {
+		for _, mem := range []interface{}{1024.0, 512.0, nil} {
+			for n := 0; n < 2; n++ {
+				dev := model.Device{
+					ID:    model.DeviceID(fmt.Sprintf("%s-%v-%d", group, mem, n)),
+					Group: model.GroupName(group),
+				}
+				if mem != nil {
+					dev.Attributes = model.DeviceAttributes{{
+						Scope: model.AttrScopeInventory,
+						Name:  "mem",
+						Value: mem,
+					}}
+				}
+				assert.NoError(t, ds.AddDevice(ctx, &dev))
+				expected = append(expected, dev.ID)
+			}
+		}
+	}
+
+	sort := []store.Sort{{
+		AttrName:  model.AttrNameGroup,
+		AttrScope: model.AttrScopeSystem,
+		Ascending: true,
+	}, {
+		AttrName:  "mem",
+		AttrScope: model.AttrScopeInventory,
+	}}
+	criteria := []model.SortCriteria{
+		{Scope: model.AttrScopeSystem, Attribute: model.AttrNameGroup, Order: "asc"},
+		{Scope: model.AttrScopeInventory, Attribute: "mem", Order: "desc"},
+	}
+	for _, perPage := range []int{1, 4, len(expected)} {
+		listed := []model.DeviceID{}
+		cursor := &model.DeviceCursor{Sort: criteria}
+		for page := 0; page <= len(expected); page++ {
+			devs, total, err := ds.GetDevices(ctx, store.ListQuery{
+				Limit:  perPage,
+				Sort:   sort,
+				Cursor: cursor,
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, len(expected), total)
+			if len(devs) == 0 {
+				break
+			}
+			for _, dev := range devs {
+				listed = append(listed, dev.ID)
+			}
+			cursor = model.NewDeviceCursor(criteria, devs[len(devs)-1])
+		}
+		assert.Equal(t, expected, listed, "per page: %d", perPage)
+	}
+}
+
+func TestMongoDeviceChanges(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoDeviceChanges in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	changes := []model.DeviceChange{
+		{DeviceID: "1", Type: model.DeviceChangeUpdated, Timestamp: now},
+		{DeviceID: "2", Type: model.DeviceChangeUpdated, Timestamp: now},
+		{DeviceID: "1", Type: model.DeviceChangeDeleted, Timestamp: now},
+	}
+	for n := range changes {
+		assert.NoError(t, ds.AppendDeviceChange(ctx, changes[n]))
+		changes[n].Seq = int64(n + 1)
+	}
+
+	res, err := ds.GetDeviceChanges(ctx, 0, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, changes[:2], res)
+
+	res, err = ds.GetDeviceChanges(ctx, 2, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, changes[1:], res)
+
+	res, err = ds.GetDeviceChanges(ctx, 4, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, res)
+}
+
+func TestMongoColdAttributes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoColdAttributes in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+
+	attrs, err := ds.GetColdAttributes(ctx, "1")
+	assert.NoError(t, err)
+	assert.Empty(t, attrs)
+
+	installed := model.DeviceAttribute{
+		Scope: "packages", Name: "installed", Value: "a,b",
+	}
+	removed := model.DeviceAttribute{
+		Scope: "packages", Name: "removed", Value: "c",
+	}
+	err = ds.UpsertRemoveColdAttributes(ctx, "1",
+		model.DeviceAttributes{installed, removed}, nil)
+	assert.NoError(t, err)
+	_, err = ds.UpsertDevicesAttributes(ctx, []model.DeviceID{"1"},
+		model.DeviceAttributes{{Scope: "inventory", Name: "os", Value: "linux"}})
+	assert.NoError(t, err)
+
+	attrs, err = ds.GetColdAttributes(ctx, "1")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, model.DeviceAttributes{installed, removed}, attrs)
+
+	// the cold attributes are not part of the device
+	dev, err := ds.GetDevice(ctx, "1")
+	assert.NoError(t, err)
+	for _, attr := range dev.Attributes {
+		assert.NotEqual(t, "packages", attr.Scope)
+	}
+
+	installed.Value = "a,b,d"
+	err = ds.UpsertRemoveColdAttributes(ctx, "1",
+		model.DeviceAttributes{installed}, model.DeviceAttributes{removed})
+	assert.NoError(t, err)
+	attrs, err = ds.GetColdAttributes(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, model.DeviceAttributes{installed}, attrs)
+
+	_, err = ds.DeleteDevices(ctx, []model.DeviceID{"1"})
+	assert.NoError(t, err)
+	attrs, err = ds.GetColdAttributes(ctx, "1")
+	assert.NoError(t, err)
+	assert.Empty(t, attrs)
+}
+
+func TestMongoInsertTimelineEvents(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoInsertTimelineEvents in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	event := func(key string) model.TimelineEvent {
+		return model.TimelineEvent{
+			DedupKey:  key,
+			DeviceID:  "1",
+			Type:      model.TimelineDeploymentStarted,
+			Source:    "deployments",
+			Timestamp: now,
+		}
+	}
+
+	n, err := ds.InsertTimelineEvents(ctx, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	n, err = ds.InsertTimelineEvents(ctx, []model.TimelineEvent{
+		event("1"), event("2"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	// the duplicates, stored or within the batch, are skipped
+	n, err = ds.InsertTimelineEvents(ctx, []model.TimelineEvent{
+		event("2"), event("3"), event("4"), event("3"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	count, err := db.Client().Database(DbName).
+		Collection(DbTimelineColl).
+		CountDocuments(ctx, bson.M{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), count)
+}
+
+func TestMongoCatalog(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoCatalog in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+
+	catalog, err := ds.GetCatalog(ctx)
+	assert.NoError(t, err)
+	assert.Nil(t, catalog)
+
+	for _, dev := range []model.Device{
+		{ID: "1", Group: "dev"},
+		{ID: "2"},
+		{ID: "3", Group: "prod"},
+		{ID: "4", Group: "dev"},
+	} {
+		dev := dev
+		err := ds.AddDevice(ctx, &dev)
+		assert.NoError(t, err, "failed to setup input data")
+	}
+
+	groups, err := ds.CountDevicesByGroup(ctx, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.GroupCount{
+		{Group: "dev", Count: 2},
+		{Group: "prod", Count: 1},
+	}, groups)
+
+	filtered, err := ds.CountDevicesByGroup(ctx, []model.FilterPredicate{{
+		Scope:     model.AttrScopeSystem,
+		Attribute: model.AttrNameGroup,
+		Type:      "$eq",
+		Value:     "prod",
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.GroupCount{{Group: "prod", Count: 1}}, filtered)
+
+	count, err := ds.CountDevices(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, count)
+
+	expected := model.Catalog{
+		Attributes: []model.FilterAttribute{
+			{Name: "mac", Scope: "inventory", Count: 2},
+		},
+		Groups:     groups,
+		Devices:    count,
+		ComputedTs: time.Now().UTC().Truncate(time.Millisecond),
+	}
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, ds.SaveCatalog(ctx, expected))
+		expected.Devices++
+	}
+	expected.Devices--
+	catalog, err = ds.GetCatalog(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, &expected, catalog)
+}
+
+func TestMongoAttributeSnapshots(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoAttributeSnapshots in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+
+	snapshots, err := ds.GetAttributeSnapshots(ctx, []model.DeviceID{"1"})
+	assert.NoError(t, err)
+	assert.Empty(t, snapshots)
+	assert.NoError(t, ds.SaveAttributeSnapshots(ctx, nil))
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	snapshot := func(id model.DeviceID, deployment, artifact string) model.AttributeSnapshot {
+		return model.AttributeSnapshot{
+			DeviceID:     id,
+			DeploymentID: deployment,
+			Attributes: model.DeviceAttributes{{
+				Scope: model.AttrScopeInventory,
+				Name:  "artifact_name",
+				Value: artifact,
+			}},
+			Ts: now,
+		}
+	}
+	err = ds.SaveAttributeSnapshots(ctx, []model.AttributeSnapshot{
+		snapshot("1", "d0", "release-1"),
+		snapshot("2", "d0", "release-1"),
+	})
+	assert.NoError(t, err)
+	err = ds.SaveAttributeSnapshots(ctx, []model.AttributeSnapshot{
+		snapshot("1", "d1", "release-2"),
+	})
+	assert.NoError(t, err)
+
+	snapshots, err = ds.GetAttributeSnapshots(ctx,
+		[]model.DeviceID{"1", "2", "3"})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []model.AttributeSnapshot{
+		snapshot("1", "d1", "release-2"),
+		snapshot("2", "d0", "release-1"),
+	}, snapshots)
+}
+
+func TestMongoExplainSearchDevices(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoExplainSearchDevices in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+
+	for _, dev := range []model.Device{{ID: "1"}, {ID: "2", Group: "dev"}} {
+		dev := dev
+		err := ds.AddDevice(ctx, &dev)
+		assert.NoError(t, err, "failed to setup input data")
+	}
+
+	plan, err := ds.ExplainSearchDevices(ctx, model.SearchParams{
+		Page:    1,
+		PerPage: 10,
+		Filters: []model.FilterPredicate{{
+			Scope:     model.AttrScopeSystem,
+			Attribute: model.AttrNameGroup,
+			Type:      "$eq",
+			Value:     "dev",
+		}},
+	})
+	assert.NoError(t, err)
+	assert.True(t, plan.CollectionScan)
+	assert.Empty(t, plan.Indexes)
+	assert.Equal(t, int64(1), plan.Returned)
+	assert.Equal(t, int64(2), plan.DocsExamined)
+}
+
+func TestMongoCountFilterTerms(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoCountFilterTerms in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+
+	for id, deviceType := range map[model.DeviceID]string{
+		"1": "raspberrypi4",
+		"2": "raspberrypi4",
+		"3": "beaglebone",
+	} {
+		err := ds.AddDevice(ctx, &model.Device{
+			ID: id,
+			Attributes: model.DeviceAttributes{{
+				Scope: model.AttrScopeInventory,
+				Name:  "device_type",
+				Value: deviceType,
+			}},
+		})
+		assert.NoError(t, err, "failed to setup input data")
+	}
+	predicate := func(name, value string) model.FilterPredicate {
+		return model.FilterPredicate{
+			Scope:     model.AttrScopeInventory,
+			Attribute: name,
+			Type:      "$eq",
+			Value:     value,
+		}
+	}
+	zero, three := 0, 3
+
+	terms, err := ds.CountFilterTerms(ctx, model.SearchParams{
+		Filters: []model.FilterPredicate{
+			predicate("device_type", "raspberrypi4"),
+			predicate("device_type", "beaglebone"),
+			predicate("devce_type", "raspberrypi4"),
+			predicate("device_type", "qemux86-64"),
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.FilterTermCount{{
+		Filter:     predicate("device_type", "raspberrypi4"),
+		Devices:    2,
+		Cumulative: 2,
+	}, {
+		Filter:  predicate("device_type", "beaglebone"),
+		Devices: 1,
+	}, {
+		Filter:   predicate("devce_type", "raspberrypi4"),
+		Reported: &zero,
+	}, {
+		Filter:   predicate("device_type", "qemux86-64"),
+		Reported: &three,
+	}}, terms)
+
+	// the other criteria apply to the counts
+	terms, err = ds.CountFilterTerms(ctx, model.SearchParams{
+		Filters:   []model.FilterPredicate{predicate("device_type", "raspberrypi4")},
+		DeviceIDs: []string{"3"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.FilterTermCount{{
+		Filter:   predicate("device_type", "raspberrypi4"),
+		Reported: &three,
+	}}, terms)
+}
+
+func TestQueryPlanFromExplain(t *testing.T) {
+	var res explainResult
+	res.QueryPlanner.WinningPlan = explainStage{
+		Stage: "LIMIT",
+		InputStage: &explainStage{
+			Stage: "FETCH",
+			InputStage: &explainStage{
+				Stage: "OR",
+				InputStages: []explainStage{
+					{Stage: "IXSCAN", IndexName: IndexNameGroup},
+					{Stage: "IXSCAN", IndexName: IndexNameUpdatedTs},
+				},
+			},
+		},
+	}
+	res.ExecutionStats.NReturned = 3
+	res.ExecutionStats.TotalKeysExamined = 4
+	res.ExecutionStats.TotalDocsExamined = 3
+	res.ExecutionStats.ExecutionTimeMillis = 1
+
+	assert.Equal(t, &model.QueryPlan{
+		Stage:           "LIMIT",
+		Indexes:         []string{IndexNameGroup, IndexNameUpdatedTs},
+		Returned:        3,
+		KeysExamined:    4,
+		DocsExamined:    3,
+		ExecutionTimeMS: 1,
+	}, queryPlanFromExplain(res))
+}
@@ -0,0 +1,126 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	mopts "go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+)
+
+const (
+	DbDeadLettersColl     = "dead_letters"
+	DbDeadLetterCreatedTs = "created_ts"
+)
+
+func (db *DataStoreMongo) CreateDeadLetter(ctx context.Context, letter model.DeadLetter) error {
+	c := db.database(ctx).
+		Collection(DbDeadLettersColl)
+
+	if _, err := c.InsertOne(ctx, letter); err != nil {
+		return errors.Wrap(err, "failed to store dead letter")
+	}
+	return nil
+}
+
+func (db *DataStoreMongo) GetDeadLetters(
+	ctx context.Context,
+	skip, limit int,
+) ([]model.DeadLetter, int, error) {
+	c := db.database(ctx).
+		Collection(DbDeadLettersColl)
+
+	findOptions := mopts.Find().SetSort(bson.D{
+		{Key: DbDeadLetterCreatedTs, Value: 1},
+		{Key: DbDevId, Value: 1},
+	})
+	if skip > 0 {
+		findOptions.SetSkip(int64(skip))
+	}
+	if limit > 0 {
+		findOptions.SetLimit(int64(limit))
+	}
+	cur, err := db.find(ctx, c, bson.M{}, findOptions)
+	if err != nil {
+		return nil, -1, errors.Wrap(err, "failed to get dead letters")
+	}
+	letters := []model.DeadLetter{}
+	if err = decodeAll(ctx, cur, &letters); err != nil {
+		return nil, -1, errors.Wrap(err, "failed to get dead letters")
+	}
+
+	count, err := c.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return nil, -1, errors.Wrap(err, "failed to count dead letters")
+	}
+	return letters, int(count), nil
+}
+
+func (db *DataStoreMongo) GetDeadLetter(ctx context.Context, id string) (*model.DeadLetter, error) {
+	c := db.database(ctx).
+		Collection(DbDeadLettersColl)
+
+	var letter model.DeadLetter
+	err := c.FindOne(ctx, bson.M{DbDevId: id}).Decode(&letter)
+	if err == mongo.ErrNoDocuments {
+		return nil, store.ErrDeadLetterNotFound
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to get dead letter")
+	}
+	return &letter, nil
+}
+
+func (db *DataStoreMongo) UpdateDeadLetter(ctx context.Context, letter model.DeadLetter) error {
+	c := db.database(ctx).
+		Collection(DbDeadLettersColl)
+
+	res, err := c.ReplaceOne(ctx, bson.M{DbDevId: letter.ID}, letter)
+	if err != nil {
+		return errors.Wrap(err, "failed to update dead letter")
+	} else if res.MatchedCount == 0 {
+		return store.ErrDeadLetterNotFound
+	}
+	return nil
+}
+
+func (db *DataStoreMongo) DeleteDeadLetter(ctx context.Context, id string) error {
+	c := db.database(ctx).
+		Collection(DbDeadLettersColl)
+
+	res, err := c.DeleteOne(ctx, bson.M{DbDevId: id})
+	if err != nil {
+		return errors.Wrap(err, "failed to remove dead letter")
+	} else if res.DeletedCount == 0 {
+		return store.ErrDeadLetterNotFound
+	}
+	return nil
+}
+
+func (db *DataStoreMongo) PurgeDeadLetters(ctx context.Context) (*model.UpdateResult, error) {
+	c := db.database(ctx).
+		Collection(DbDeadLettersColl)
+
+	res, err := c.DeleteMany(ctx, bson.M{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to remove dead letters")
+	}
+	return &model.UpdateResult{DeletedCount: res.DeletedCount}, nil
+}
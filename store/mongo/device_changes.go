@@ -0,0 +1,92 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	mopts "go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+const (
+	// DbDeviceChangesColl holds the change feed of the devices, keyed by
+	// the sequence numbers of the changes.
+	DbDeviceChangesColl = "device_changes"
+	DbDeviceChangeTs    = "timestamp"
+	DbDeviceChangesSeq  = "seq"
+
+	// DeviceChangesRetention is how long the changes are kept in the
+	// change feed before expiring.
+	DeviceChangesRetention = 7 * 24 * time.Hour
+)
+
+func (db *DataStoreMongo) AppendDeviceChange(
+	ctx context.Context,
+	change model.DeviceChange,
+) error {
+	database := db.database(ctx)
+
+	// the changes are appended by the single change stream worker, so
+	// the sequence numbers follow the order of the changes
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	err := database.Collection(DbSettingsColl).FindOneAndUpdate(ctx,
+		bson.M{DbDevId: DbSettingsDeviceChanges},
+		bson.M{"$inc": bson.M{DbDeviceChangesSeq: int64(1)}},
+		mopts.FindOneAndUpdate().
+			SetUpsert(true).
+			SetReturnDocument(mopts.After),
+	).Decode(&counter)
+	if err != nil {
+		return errors.Wrap(err, "failed to assign the device change sequence number")
+	}
+
+	change.Seq = counter.Seq
+	_, err = database.Collection(DbDeviceChangesColl).InsertOne(ctx, change)
+	if err != nil {
+		return errors.Wrap(err, "failed to record device change")
+	}
+	return nil
+}
+
+func (db *DataStoreMongo) GetDeviceChanges(
+	ctx context.Context,
+	fromSeq int64,
+	limit int,
+) ([]model.DeviceChange, error) {
+	c := db.database(ctx).
+		Collection(DbDeviceChangesColl)
+
+	cur, err := db.find(ctx, c,
+		bson.M{DbDevId: bson.M{"$gte": fromSeq}},
+		mopts.Find().
+			SetSort(bson.D{{Key: DbDevId, Value: 1}}).
+			SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get device changes")
+	}
+	changes := []model.DeviceChange{}
+	if err = decodeAll(ctx, cur, &changes); err != nil {
+		return nil, errors.Wrap(err, "failed to get device changes")
+	}
+	return changes, nil
+}
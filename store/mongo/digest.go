@@ -0,0 +1,169 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package mongo
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	mopts "go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+func (db *DataStoreMongo) GetDigestSettings(
+	ctx context.Context,
+) (*model.DigestSettings, error) {
+	c := db.database(ctx).
+		Collection(DbSettingsColl)
+
+	settings := &model.DigestSettings{}
+	err := c.FindOne(ctx, bson.M{DbDevId: DbSettingsDigest}).
+		Decode(settings)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to get digest settings")
+	}
+	return settings, nil
+}
+
+func (db *DataStoreMongo) SetDigestSettings(
+	ctx context.Context,
+	settings model.DigestSettings,
+) error {
+	c := db.database(ctx).
+		Collection(DbSettingsColl)
+
+	doc := struct {
+		ID                   string `bson:"_id"`
+		model.DigestSettings `bson:",inline"`
+	}{
+		ID:             DbSettingsDigest,
+		DigestSettings: settings,
+	}
+	_, err := c.ReplaceOne(ctx,
+		bson.M{DbDevId: DbSettingsDigest}, doc,
+		mopts.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to set digest settings")
+	}
+	return nil
+}
+
+func (db *DataStoreMongo) DeleteDigestSettings(ctx context.Context) error {
+	c := db.database(ctx).
+		Collection(DbSettingsColl)
+
+	_, err := c.DeleteOne(ctx, bson.M{DbDevId: DbSettingsDigest})
+	if err != nil {
+		return errors.Wrap(err, "failed to delete digest settings")
+	}
+	return nil
+}
+
+func (db *DataStoreMongo) GetFleetDigest(
+	ctx context.Context,
+	from, to time.Time,
+	silentAfter time.Duration,
+) (*model.FleetDigest, error) {
+	createdField := makeAttrField(model.AttrNameCreated, model.AttrScopeSystem,
+		DbDevAttributesValue)
+	transitionField := makeAttrField(model.AttrNameGroupTransition,
+		model.AttrScopeSystem, DbDevAttributesValue)
+	transitionTsField := makeAttrField(model.AttrNameGroupTransitionTs,
+		model.AttrScopeSystem, DbDevAttributesValue)
+	c := db.database(ctx).
+		Collection(db.names.Devices)
+
+	digest := &model.FleetDigest{
+		From:         from,
+		To:           to,
+		GroupChanges: []model.GroupChanges{},
+	}
+	for _, count := range []struct {
+		filter bson.M
+		res    *int
+	}{
+		{bson.M{}, &digest.Devices},
+		{
+			bson.M{createdField: bson.M{"$gte": from, "$lt": to}},
+			&digest.NewDevices,
+		},
+		{
+			// the devices whose silence threshold was crossed during
+			// the period
+			bson.M{DbDevAttributesUpdatedValue: bson.M{
+				"$gte": from.Add(-silentAfter),
+				"$lt":  to.Add(-silentAfter),
+			}},
+			&digest.SilentDevices,
+		},
+	} {
+		n, err := c.CountDocuments(ctx, count.filter)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to count devices")
+		}
+		*count.res = int(n)
+	}
+
+	// the transitions are recorded as "<action>:<group>"
+	cur, err := db.aggregate(ctx, c, []bson.M{
+		{"$match": bson.M{transitionTsField: bson.M{"$gte": from, "$lt": to}}},
+		{"$group": bson.M{
+			DbDevId: "$" + transitionField,
+			"count": bson.M{"$sum": 1},
+		}},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to aggregate group changes")
+	}
+	var transitions []struct {
+		Transition string `bson:"_id"`
+		Count      int    `bson:"count"`
+	}
+	if err = decodeAll(ctx, cur, &transitions); err != nil {
+		return nil, errors.Wrap(err, "failed to aggregate group changes")
+	}
+	changes := map[model.GroupName]*model.GroupChanges{}
+	for _, t := range transitions {
+		parts := strings.SplitN(t.Transition, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		group := model.GroupName(parts[1])
+		if changes[group] == nil {
+			changes[group] = &model.GroupChanges{Group: group}
+		}
+		switch parts[0] {
+		case model.GroupTransitionJoined:
+			changes[group].Joined += t.Count
+		case model.GroupTransitionLeft:
+			changes[group].Left += t.Count
+		}
+	}
+	for _, change := range changes {
+		digest.GroupChanges = append(digest.GroupChanges, *change)
+	}
+	sort.Slice(digest.GroupChanges, func(i, j int) bool {
+		return digest.GroupChanges[i].Group < digest.GroupChanges[j].Group
+	})
+	return digest, nil
+}
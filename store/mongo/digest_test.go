@@ -0,0 +1,129 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package mongo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+func TestDigestSettings(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestDigestSettings in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client())
+	ctx := db.CTX()
+
+	settings, err := ds.GetDigestSettings(ctx)
+	assert.NoError(t, err)
+	assert.Nil(t, settings)
+
+	sentTs := time.Now().UTC().Truncate(time.Millisecond)
+	expected := model.DigestSettings{
+		Channel: model.NotificationChannel{
+			Type:  model.NotificationChannelEmail,
+			Email: "ops@example.com",
+		},
+		PeriodHours: 24,
+		SilentHours: 48,
+		SentTs:      &sentTs,
+	}
+	assert.NoError(t, ds.SetDigestSettings(ctx, expected))
+
+	settings, err = ds.GetDigestSettings(ctx)
+	assert.NoError(t, err)
+	if assert.NotNil(t, settings) {
+		assert.Equal(t, expected.Channel, settings.Channel)
+		assert.Equal(t, expected.PeriodHours, settings.PeriodHours)
+		assert.Equal(t, expected.SilentHours, settings.SilentHours)
+		assert.True(t, sentTs.Equal(*settings.SentTs))
+	}
+
+	assert.NoError(t, ds.DeleteDigestSettings(ctx))
+	settings, err = ds.GetDigestSettings(ctx)
+	assert.NoError(t, err)
+	assert.Nil(t, settings)
+}
+
+func TestGetFleetDigest(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestGetFleetDigest in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client()).(*DataStoreMongo)
+	ctx := db.CTX()
+
+	to := time.Now().UTC().Truncate(time.Millisecond)
+	from := to.Add(-24 * time.Hour)
+	attr := func(name string, value interface{}) bson.M {
+		return bson.M{
+			"scope": model.AttrScopeSystem,
+			"name":  name,
+			"value": value,
+		}
+	}
+	device := func(
+		id string,
+		created, updated time.Time,
+		transition string,
+	) bson.M {
+		attrs := bson.M{
+			"system-" + model.AttrNameCreated: attr(model.AttrNameCreated, created),
+			"system-" + model.AttrNameUpdated: attr(model.AttrNameUpdated, updated),
+		}
+		if transition != "" {
+			attrs["system-"+model.AttrNameGroupTransition] = attr(
+				model.AttrNameGroupTransition, transition)
+			attrs["system-"+model.AttrNameGroupTransitionTs] = attr(
+				model.AttrNameGroupTransitionTs, to.Add(-time.Hour))
+		}
+		return bson.M{DbDevId: id, DbDevAttributes: attrs}
+	}
+	_, err := ds.database(ctx).Collection(ds.names.Devices).InsertMany(ctx,
+		[]interface{}{
+			// new, reporting
+			device("1", to.Add(-time.Hour), to, "joined:prod"),
+			device("2", to.Add(-2*time.Hour), to, "joined:prod"),
+			// gone silent during the period
+			device("3", from.Add(-72*time.Hour), from.Add(-60*time.Hour), "left:prod"),
+			// silent before the period
+			device("4", from.Add(-96*time.Hour), from.Add(-96*time.Hour), ""),
+		},
+	)
+	require.NoError(t, err)
+
+	digest, err := ds.GetFleetDigest(ctx, from, to, 48*time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, &model.FleetDigest{
+		From:          from,
+		To:            to,
+		Devices:       4,
+		NewDevices:    2,
+		SilentDevices: 1,
+		GroupChanges: []model.GroupChanges{{
+			Group:  "prod",
+			Joined: 2,
+			Left:   1,
+		}},
+	}, digest)
+}
@@ -0,0 +1,567 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/mongo/migrate"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/auth"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+const (
+	// doctorTimeout bounds the attempts to reach the database.
+	doctorTimeout = 10 * time.Second
+	// doctorSampleSize is the number of devices per database whose
+	// documents are checked.
+	doctorSampleSize = 100
+	// doctorListLimit is the number of problems listed by a check.
+	doctorListLimit = 5
+	// maxIndexes is the limit of mongo on the indexes of a collection.
+	maxIndexes = 64
+)
+
+// CheckStatus is the outcome of a diagnostics check.
+type CheckStatus string
+
+const (
+	CheckOK      CheckStatus = "ok"
+	CheckWarning CheckStatus = "warning"
+	CheckFailed  CheckStatus = "failed"
+	CheckSkipped CheckStatus = "skipped"
+)
+
+// Check is the result of a diagnostics check.
+type Check struct {
+	Name    string      `json:"name"`
+	Status  CheckStatus `json:"status"`
+	Message string      `json:"message"`
+	// Hint tells how to remediate the problem found by the check.
+	Hint string `json:"hint,omitempty"`
+}
+
+// DiagnosticsReport is the result of the diagnostics of the database.
+type DiagnosticsReport struct {
+	Checks []Check `json:"checks"`
+}
+
+// Healthy returns false if any of the checks failed.
+func (r *DiagnosticsReport) Healthy() bool {
+	for _, c := range r.Checks {
+		if c.Status == CheckFailed {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *DiagnosticsReport) add(check Check) {
+	r.Checks = append(r.Checks, check)
+}
+
+// skip records the checks which cannot run because of a failed one.
+func (r *DiagnosticsReport) skip(reason string, names ...string) {
+	for _, name := range names {
+		r.add(Check{Name: name, Status: CheckSkipped, Message: reason})
+	}
+}
+
+const (
+	checkConnectivity   = "connectivity"
+	checkAuthentication = "authentication"
+	checkDatabases      = "databases"
+	checkSchemaVersion  = "schema version"
+	checkIndexes        = "indexes"
+	checkSizes          = "collection sizes"
+	checkDocuments      = "documents"
+	checkScopes         = "scopes"
+)
+
+// Diagnose checks the database the service is configured with and
+// reports the problems found with the hints to remediate them.
+func Diagnose(ctx context.Context, config DataStoreMongoConfig) *DiagnosticsReport {
+	r := &DiagnosticsReport{}
+	names := config.DbNames.WithDefaults()
+	if err := names.Validate(); err != nil {
+		r.add(Check{
+			Name:    checkConnectivity,
+			Status:  CheckFailed,
+			Message: err.Error(),
+			Hint:    "fix the mongo_db_name and mongo_tenant_db_prefix settings",
+		})
+		return r
+	}
+
+	clientOptions := config.clientOptions().
+		SetConnectTimeout(doctorTimeout).
+		SetServerSelectionTimeout(doctorTimeout)
+	client, err := mongo.Connect(ctx, clientOptions)
+	if err == nil {
+		defer client.Disconnect(ctx)
+		err = client.Ping(ctx, nil)
+	}
+	switch {
+	case err == nil:
+		r.add(Check{
+			Name:    checkConnectivity,
+			Status:  CheckOK,
+			Message: "connected to " + strings.Join(clientOptions.Hosts, ","),
+		})
+		r.add(Check{
+			Name:    checkAuthentication,
+			Status:  CheckOK,
+			Message: "authenticated",
+		})
+	case isAuthError(err):
+		r.add(Check{
+			Name:    checkConnectivity,
+			Status:  CheckOK,
+			Message: "reached " + strings.Join(clientOptions.Hosts, ","),
+		})
+		r.add(Check{
+			Name:    checkAuthentication,
+			Status:  CheckFailed,
+			Message: err.Error(),
+			Hint: "check the mongo_username and mongo_password settings, " +
+				"or the credentials of the mongo connection string",
+		})
+		r.skip("not authenticated", checkDatabases, checkSchemaVersion,
+			checkIndexes, checkSizes, checkDocuments, checkScopes)
+		return r
+	default:
+		r.add(Check{
+			Name:    checkConnectivity,
+			Status:  CheckFailed,
+			Message: err.Error(),
+			Hint: "check the mongo setting, that the server is running and " +
+				"reachable from the service, and the mongo_ssl settings",
+		})
+		r.skip("not connected", checkAuthentication, checkDatabases,
+			checkSchemaVersion, checkIndexes, checkSizes, checkDocuments, checkScopes)
+		return r
+	}
+
+	db := &DataStoreMongo{client: client, names: names}
+	db.diagnose(ctx, r)
+	return r
+}
+
+// isAuthError returns true if the server rejected the credentials or
+// the permissions of the user.
+func isAuthError(err error) bool {
+	const (
+		codeUnauthorized         = 13
+		codeAuthenticationFailed = 18
+	)
+	var aerr *auth.Error
+	var serr mongo.ServerError
+	return errors.As(err, &aerr) ||
+		(errors.As(err, &serr) &&
+			(serr.HasErrorCode(codeUnauthorized) ||
+				serr.HasErrorCode(codeAuthenticationFailed)))
+}
+
+// diagnose runs the checks of the databases once connected.
+func (db *DataStoreMongo) diagnose(ctx context.Context, r *DiagnosticsReport) {
+	all, err := db.client.ListDatabaseNames(ctx, bson.M{})
+	if err != nil {
+		check := Check{
+			Name:    checkDatabases,
+			Status:  CheckFailed,
+			Message: err.Error(),
+		}
+		if isAuthError(err) {
+			check.Hint = "grant the mongo user the listDatabases action, " +
+				"e.g. with the readWriteAnyDatabase role"
+		}
+		r.add(check)
+		r.skip("databases not listed", checkSchemaVersion,
+			checkIndexes, checkSizes, checkDocuments, checkScopes)
+		return
+	}
+	var dbs []string
+	for _, name := range all {
+		if name == db.names.Database || db.names.IsTenantDb(name) {
+			dbs = append(dbs, name)
+		}
+	}
+	if len(dbs) == 0 {
+		r.add(Check{
+			Name:    checkDatabases,
+			Status:  CheckFailed,
+			Message: "no inventory database found",
+			Hint: "run `inventory migrate` to create the database, " +
+				"and check the mongo_db_name and mongo_tenant_db_prefix settings",
+		})
+		r.skip("no database", checkSchemaVersion,
+			checkIndexes, checkSizes, checkDocuments, checkScopes)
+		return
+	}
+	sort.Strings(dbs)
+	r.add(Check{
+		Name:    checkDatabases,
+		Status:  CheckOK,
+		Message: fmt.Sprintf("%d inventory databases", len(dbs)),
+	})
+
+	r.add(db.checkSchemaVersion(ctx, dbs))
+	r.add(db.checkIndexes(ctx, dbs))
+	r.add(db.checkSizes(ctx, dbs))
+	r.add(db.checkDocuments(ctx, dbs))
+	r.add(db.checkScopes(ctx, dbs))
+}
+
+// listed joins the first problems of the list, noting how many are left.
+func listed(problems []string) string {
+	if len(problems) <= doctorListLimit {
+		return strings.Join(problems, "; ")
+	}
+	return fmt.Sprintf("%s; and %d more",
+		strings.Join(problems[:doctorListLimit], "; "),
+		len(problems)-doctorListLimit)
+}
+
+func (db *DataStoreMongo) checkSchemaVersion(ctx context.Context, dbs []string) Check {
+	target, err := migrate.NewVersion(DbVersion)
+	if err != nil {
+		return Check{Name: checkSchemaVersion, Status: CheckFailed, Message: err.Error()}
+	}
+	var outdated, newer, failed []string
+	for _, name := range dbs {
+		info, err := migrate.GetMigrationInfo(ctx, db.client, name)
+		switch {
+		case err != nil:
+			failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+		case len(info) == 0:
+			outdated = append(outdated, name+": not migrated")
+		case migrate.VersionIsLess(info[0].Version, *target):
+			outdated = append(outdated, name+": "+info[0].Version.String())
+		case migrate.VersionIsLess(*target, info[0].Version):
+			newer = append(newer, name+": "+info[0].Version.String())
+		}
+	}
+	switch {
+	case len(failed) > 0:
+		return Check{
+			Name:    checkSchemaVersion,
+			Status:  CheckFailed,
+			Message: "failed to read the schema version: " + listed(failed),
+		}
+	case len(outdated) > 0:
+		return Check{
+			Name:   checkSchemaVersion,
+			Status: CheckFailed,
+			Message: fmt.Sprintf("%d databases older than %s: %s",
+				len(outdated), DbVersion, listed(outdated)),
+			Hint: "run `inventory migrate`, or start the server with --automigrate",
+		}
+	case len(newer) > 0:
+		return Check{
+			Name:   checkSchemaVersion,
+			Status: CheckWarning,
+			Message: fmt.Sprintf("%d databases newer than %s: %s",
+				len(newer), DbVersion, listed(newer)),
+			Hint: "upgrade the service to the version which migrated the databases",
+		}
+	}
+	return Check{
+		Name:    checkSchemaVersion,
+		Status:  CheckOK,
+		Message: "all the databases at " + DbVersion,
+	}
+}
+
+// requiredIndexes returns the names of the indexes created by the
+// migrations, by collection.
+func (db *DataStoreMongo) requiredIndexes() map[string][]string {
+	devices := []string{IndexNameParentDevice}
+	for name := range coreIndexes {
+		devices = append(devices, name)
+	}
+	for name := range identityIndexes {
+		devices = append(devices, name)
+	}
+	sort.Strings(devices)
+	return map[string][]string{
+		db.names.Devices:    devices,
+		DbExternalIDsColl:   {IndexNameExternalID},
+		DbDeviceChangesColl: {IndexNameDeviceChangesTTL},
+	}
+}
+
+func (db *DataStoreMongo) checkIndexes(ctx context.Context, dbs []string) Check {
+	var missing, failed []string
+	for _, name := range dbs {
+		for coll, indexes := range db.requiredIndexes() {
+			cur, err := db.client.Database(name).Collection(coll).
+				Indexes().List(ctx)
+			if err != nil {
+				failed = append(failed, fmt.Sprintf("%s.%s: %v", name, coll, err))
+				continue
+			}
+			var existing []struct {
+				Name string `bson:"name"`
+			}
+			if err := decodeAll(ctx, cur, &existing); err != nil {
+				failed = append(failed, fmt.Sprintf("%s.%s: %v", name, coll, err))
+				continue
+			}
+			found := make(map[string]bool, len(existing))
+			for _, index := range existing {
+				found[index.Name] = true
+			}
+			for _, index := range indexes {
+				if !found[index] {
+					missing = append(missing,
+						fmt.Sprintf("%s.%s: %s", name, coll, index))
+				}
+			}
+		}
+	}
+	sort.Strings(missing)
+	switch {
+	case len(failed) > 0:
+		return Check{
+			Name:    checkIndexes,
+			Status:  CheckFailed,
+			Message: "failed to list the indexes: " + listed(failed),
+		}
+	case len(missing) > 0:
+		return Check{
+			Name:    checkIndexes,
+			Status:  CheckWarning,
+			Message: fmt.Sprintf("%d indexes missing: %s", len(missing), listed(missing)),
+			Hint: "the migrations skip the indexes once a collection reaches " +
+				"the limit of indexes; drop the unused indexes and create " +
+				"the missing ones, or the device lookups scan the collection",
+		}
+	}
+	return Check{
+		Name:    checkIndexes,
+		Status:  CheckOK,
+		Message: "all the indexes present",
+	}
+}
+
+type collStats struct {
+	Count          int64 `bson:"count"`
+	Size           int64 `bson:"size"`
+	TotalIndexSize int64 `bson:"totalIndexSize"`
+	NIndexes       int   `bson:"nindexes"`
+}
+
+// megabytes formats the size in bytes.
+func megabytes(size int64) string {
+	return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+}
+
+func (db *DataStoreMongo) checkSizes(ctx context.Context, dbs []string) Check {
+	var total collStats
+	var largest string
+	var largestCount int64
+	var crowded []string
+	for _, name := range dbs {
+		var stats collStats
+		err := db.client.Database(name).RunCommand(ctx, bson.D{
+			{Key: "collStats", Value: db.names.Devices},
+		}).Decode(&stats)
+		if err != nil {
+			// the collection is only created with the first device
+			continue
+		}
+		total.Count += stats.Count
+		total.Size += stats.Size
+		total.TotalIndexSize += stats.TotalIndexSize
+		if stats.Count > largestCount {
+			largest, largestCount = name, stats.Count
+		}
+		if stats.NIndexes >= maxIndexes-4 {
+			crowded = append(crowded,
+				fmt.Sprintf("%s: %d indexes", name, stats.NIndexes))
+		}
+	}
+	msg := fmt.Sprintf("%d devices, %s of documents, %s of indexes",
+		total.Count, megabytes(total.Size), megabytes(total.TotalIndexSize))
+	if largest != "" && len(dbs) > 1 {
+		msg += fmt.Sprintf("; largest database %s with %d devices",
+			largest, largestCount)
+	}
+	if len(crowded) > 0 {
+		return Check{
+			Name:    checkSizes,
+			Status:  CheckWarning,
+			Message: msg + "; close to the limit of indexes: " + listed(crowded),
+			Hint: "drop the attribute indexes which are no longer used, " +
+				"new indexes cannot be created past the limit",
+		}
+	}
+	return Check{Name: checkSizes, Status: CheckOK, Message: msg}
+}
+
+func (db *DataStoreMongo) checkDocuments(ctx context.Context, dbs []string) Check {
+	var sampled int
+	var malformed, failed []string
+	for _, name := range dbs {
+		cur, err := db.aggregate(ctx,
+			db.client.Database(name).Collection(db.names.Devices),
+			[]bson.M{{"$sample": bson.M{"size": doctorSampleSize}}},
+		)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		err = iterateCursor(ctx, cur, func(cur *mongo.Cursor) error {
+			sampled++
+			if err := checkDeviceShape(cur.Current); err != nil {
+				id, _ := cur.Current.Lookup(DbDevId).StringValueOK()
+				malformed = append(malformed,
+					fmt.Sprintf("%s/%s: %v", name, id, err))
+			}
+			return nil
+		})
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	switch {
+	case len(failed) > 0:
+		return Check{
+			Name:    checkDocuments,
+			Status:  CheckFailed,
+			Message: "failed to sample the devices: " + listed(failed),
+		}
+	case len(malformed) > 0:
+		return Check{
+			Name:   checkDocuments,
+			Status: CheckWarning,
+			Message: fmt.Sprintf("%d of %d sampled devices malformed: %s",
+				len(malformed), sampled, listed(malformed)),
+			Hint: "the malformed devices are misread by the searches; " +
+				"check for writes bypassing the service, then delete " +
+				"the devices or have them report their attributes again",
+		}
+	}
+	return Check{
+		Name:    checkDocuments,
+		Status:  CheckOK,
+		Message: fmt.Sprintf("%d sampled devices well-formed", sampled),
+	}
+}
+
+// checkScopes looks for the custom scopes registered before their name
+// was taken by a builtin scope: their attributes are only writable by
+// the internal services, and are filtered as the builtin scope.
+func (db *DataStoreMongo) checkScopes(ctx context.Context, dbs []string) Check {
+	names := make(bson.A, len(model.BuiltinScopes))
+	for n, scope := range model.BuiltinScopes {
+		names[n] = scope.Name
+	}
+	var shadowed, failed []string
+	for _, name := range dbs {
+		cur, err := db.find(ctx,
+			db.client.Database(name).Collection(DbScopesColl),
+			bson.M{DbDevId: bson.M{"$in": names}},
+		)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		var scopes []model.Scope
+		if err := decodeAll(ctx, cur, &scopes); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		for _, scope := range scopes {
+			shadowed = append(shadowed, name+": "+scope.Name)
+		}
+	}
+	switch {
+	case len(failed) > 0:
+		return Check{
+			Name:    checkScopes,
+			Status:  CheckFailed,
+			Message: "failed to list the scopes: " + listed(failed),
+		}
+	case len(shadowed) > 0:
+		return Check{
+			Name:   checkScopes,
+			Status: CheckWarning,
+			Message: fmt.Sprintf("%d custom scopes named as a builtin scope: %s",
+				len(shadowed), listed(shadowed)),
+			Hint: "the devices and the users can no longer write the attributes " +
+				"of these scopes; have the tenants register a scope of another " +
+				"name for their attributes and delete the old scope",
+		}
+	}
+	return Check{
+		Name:    checkScopes,
+		Status:  CheckOK,
+		Message: "no custom scope named as a builtin scope",
+	}
+}
+
+// checkDeviceShape checks the raw device document against the layout
+// written by the service.
+func checkDeviceShape(doc bson.Raw) error {
+	id, err := doc.LookupErr(DbDevId)
+	if err != nil {
+		return errors.New("no ID")
+	}
+	if _, ok := id.StringValueOK(); !ok {
+		return errors.Errorf("ID of type %s instead of string", id.Type)
+	}
+	attrs, err := doc.LookupErr(DbDevAttributes)
+	if err != nil {
+		return nil
+	}
+	attrsDoc, ok := attrs.DocumentOK()
+	if !ok {
+		return errors.Errorf("attributes of type %s instead of document", attrs.Type)
+	}
+	elems, err := attrsDoc.Elements()
+	if err != nil {
+		return errors.Wrap(err, "malformed attributes")
+	}
+	for _, elem := range elems {
+		attr, ok := elem.Value().DocumentOK()
+		if !ok {
+			return errors.Errorf("attribute %s of type %s instead of document",
+				elem.Key(), elem.Value().Type)
+		}
+		scope, okScope := attr.Lookup(DbDevAttributesScope).StringValueOK()
+		name, okName := attr.Lookup(DbDevAttributesName).StringValueOK()
+		if !okScope || !okName {
+			return errors.Errorf("attribute %s without scope or name", elem.Key())
+		}
+		if _, err := attr.LookupErr(DbDevAttributesValue); err != nil {
+			return errors.Errorf("attribute %s without value", elem.Key())
+		}
+		key := scope + "-" + model.GetDeviceAttributeNameReplacer().Replace(name)
+		if elem.Key() != key {
+			return errors.Errorf("attribute %s/%s stored as %s",
+				scope, name, elem.Key())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+func TestCheckDeviceShape(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		doc interface{}
+		err string
+	}{
+		"ok": {
+			doc: bson.M{
+				"_id": "1",
+				"attributes": bson.M{
+					"identity-mac":       bson.M{"scope": "identity", "name": "mac", "value": "00:01"},
+					"inventory-a\uFF0Eb": bson.M{"scope": "inventory", "name": "a.b", "value": 1},
+				},
+			},
+		},
+		"ok, no attributes": {
+			doc: bson.M{"_id": "1"},
+		},
+		"error, no ID": {
+			doc: bson.M{"attributes": bson.M{}},
+			err: "no ID",
+		},
+		"error, ID type": {
+			doc: bson.M{"_id": 1},
+			err: "ID of type 32-bit integer instead of string",
+		},
+		"error, attributes type": {
+			doc: bson.M{"_id": "1", "attributes": bson.A{}},
+			err: "attributes of type array instead of document",
+		},
+		"error, attribute type": {
+			doc: bson.M{"_id": "1", "attributes": bson.M{"identity-mac": "00:01"}},
+			err: "attribute identity-mac of type string instead of document",
+		},
+		"error, no scope": {
+			doc: bson.M{"_id": "1", "attributes": bson.M{
+				"identity-mac": bson.M{"name": "mac", "value": "00:01"},
+			}},
+			err: "attribute identity-mac without scope or name",
+		},
+		"error, no value": {
+			doc: bson.M{"_id": "1", "attributes": bson.M{
+				"identity-mac": bson.M{"scope": "identity", "name": "mac"},
+			}},
+			err: "attribute identity-mac without value",
+		},
+		"error, key": {
+			doc: bson.M{"_id": "1", "attributes": bson.M{
+				"mac": bson.M{"scope": "identity", "name": "mac", "value": "00:01"},
+			}},
+			err: "attribute identity/mac stored as mac",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			doc, err := bson.Marshal(tc.doc)
+			require.NoError(t, err)
+			err = checkDeviceShape(doc)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDiagnosticsReportHealthy(t *testing.T) {
+	t.Parallel()
+
+	r := &DiagnosticsReport{}
+	r.add(Check{Name: checkConnectivity, Status: CheckOK})
+	r.add(Check{Name: checkIndexes, Status: CheckWarning})
+	r.skip("not migrated", checkDocuments)
+	assert.True(t, r.Healthy())
+	assert.Equal(t, CheckSkipped, r.Checks[2].Status)
+
+	r.add(Check{Name: checkSchemaVersion, Status: CheckFailed})
+	assert.False(t, r.Healthy())
+}
+
+func TestListed(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "a; b", listed([]string{"a", "b"}))
+	assert.Equal(t, "a; b; c; d; e; and 2 more",
+		listed([]string{"a", "b", "c", "d", "e", "f", "g"}))
+}
+
+func TestMongoDiagnose(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoDiagnose in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client()).(*DataStoreMongo)
+	ctx := db.CTX()
+
+	err := ds.WithAutomigrate().Migrate(ctx, DbVersion)
+	require.NoError(t, err)
+	err = ds.AddDevice(ctx, &model.Device{ID: "1"})
+	require.NoError(t, err)
+	// written bypassing the service
+	_, err = ds.database(ctx).Collection(ds.names.Devices).
+		InsertOne(ctx, bson.M{"_id": 2})
+	require.NoError(t, err)
+
+	// registered before the name was taken by the builtin scope
+	_, err = ds.database(ctx).Collection(DbScopesColl).InsertOne(ctx,
+		model.Scope{Name: model.AttrScopeTelemetry, Writer: model.SourceTypeDevice})
+	require.NoError(t, err)
+
+	r := &DiagnosticsReport{}
+	ds.diagnose(context.Background(), r)
+	statuses := map[string]CheckStatus{}
+	for _, check := range r.Checks {
+		statuses[check.Name] = check.Status
+	}
+	assert.Equal(t, map[string]CheckStatus{
+		checkDatabases:     CheckOK,
+		checkSchemaVersion: CheckOK,
+		checkIndexes:       CheckOK,
+		checkSizes:         CheckOK,
+		checkDocuments:     CheckWarning,
+		checkScopes:        CheckWarning,
+	}, statuses)
+	assert.True(t, r.Healthy())
+}
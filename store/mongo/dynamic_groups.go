@@ -0,0 +1,95 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	mopts "go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+)
+
+// DbDynamicGroupsColl holds the dynamic groups, identified by their names.
+const DbDynamicGroupsColl = "dynamic_groups"
+
+func (db *DataStoreMongo) GetDynamicGroups(ctx context.Context) ([]model.DynamicGroup, error) {
+	c := db.database(ctx).
+		Collection(DbDynamicGroupsColl)
+
+	cur, err := db.find(ctx, c, bson.M{},
+		mopts.Find().SetSort(bson.D{{Key: DbDevId, Value: 1}}),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get dynamic groups")
+	}
+	groups := []model.DynamicGroup{}
+	if err = decodeAll(ctx, cur, &groups); err != nil {
+		return nil, errors.Wrap(err, "failed to get dynamic groups")
+	}
+	return groups, nil
+}
+
+func (db *DataStoreMongo) GetDynamicGroup(
+	ctx context.Context,
+	name model.GroupName,
+) (*model.DynamicGroup, error) {
+	c := db.database(ctx).
+		Collection(DbDynamicGroupsColl)
+
+	var group model.DynamicGroup
+	err := c.FindOne(ctx, bson.M{DbDevId: name}).Decode(&group)
+	if err == mongo.ErrNoDocuments {
+		return nil, store.ErrDynamicGroupNotFound
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to get dynamic group")
+	}
+	return &group, nil
+}
+
+func (db *DataStoreMongo) UpsertDynamicGroup(
+	ctx context.Context,
+	group model.DynamicGroup,
+) error {
+	c := db.database(ctx).
+		Collection(DbDynamicGroupsColl)
+
+	_, err := c.ReplaceOne(ctx,
+		bson.M{DbDevId: group.Name},
+		group,
+		mopts.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to store dynamic group")
+	}
+	return nil
+}
+
+func (db *DataStoreMongo) DeleteDynamicGroup(ctx context.Context, name model.GroupName) error {
+	c := db.database(ctx).
+		Collection(DbDynamicGroupsColl)
+
+	res, err := c.DeleteOne(ctx, bson.M{DbDevId: name})
+	if err != nil {
+		return errors.Wrap(err, "failed to remove dynamic group")
+	} else if res.DeletedCount == 0 {
+		return store.ErrDynamicGroupNotFound
+	}
+	return nil
+}
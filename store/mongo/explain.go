@@ -0,0 +1,172 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+const (
+	explainStageCollScan = "COLLSCAN"
+	explainStageIxScan   = "IXSCAN"
+)
+
+type explainStage struct {
+	Stage       string         `bson:"stage"`
+	IndexName   string         `bson:"indexName"`
+	InputStage  *explainStage  `bson:"inputStage"`
+	InputStages []explainStage `bson:"inputStages"`
+}
+
+type explainResult struct {
+	QueryPlanner struct {
+		WinningPlan explainStage `bson:"winningPlan"`
+	} `bson:"queryPlanner"`
+	ExecutionStats struct {
+		NReturned           int64 `bson:"nReturned"`
+		ExecutionTimeMillis int64 `bson:"executionTimeMillis"`
+		TotalKeysExamined   int64 `bson:"totalKeysExamined"`
+		TotalDocsExamined   int64 `bson:"totalDocsExamined"`
+	} `bson:"executionStats"`
+}
+
+// ExplainSearchDevices runs the device search with explain and returns
+// the summary of the winning plan.
+func (db *DataStoreMongo) ExplainSearchDevices(
+	ctx context.Context,
+	searchParams model.SearchParams,
+) (*model.QueryPlan, error) {
+	// the fields computed for placing the devices without the sort
+	// attributes are missing from the find, which sorts them in memory
+	// all the same
+	findQuery, findOptions, _ := searchDevicesQuery(searchParams)
+	find := bson.D{
+		{Key: "find", Value: db.names.Devices},
+		{Key: "filter", Value: findQuery},
+	}
+	if findOptions.Sort != nil {
+		find = append(find, bson.E{Key: "sort", Value: findOptions.Sort})
+	}
+	if findOptions.Projection != nil {
+		find = append(find, bson.E{Key: "projection", Value: findOptions.Projection})
+	}
+	if findOptions.Skip != nil && *findOptions.Skip > 0 {
+		find = append(find, bson.E{Key: "skip", Value: *findOptions.Skip})
+	}
+	if findOptions.Limit != nil && *findOptions.Limit > 0 {
+		find = append(find, bson.E{Key: "limit", Value: *findOptions.Limit})
+	}
+
+	var res explainResult
+	err := db.database(ctx).RunCommand(ctx, bson.D{
+		{Key: "explain", Value: find},
+		{Key: "verbosity", Value: "executionStats"},
+	}).Decode(&res)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to run explain")
+	}
+	return queryPlanFromExplain(res), nil
+}
+
+func queryPlanFromExplain(res explainResult) *model.QueryPlan {
+	plan := &model.QueryPlan{
+		Stage:           res.QueryPlanner.WinningPlan.Stage,
+		Indexes:         []string{},
+		Returned:        res.ExecutionStats.NReturned,
+		KeysExamined:    res.ExecutionStats.TotalKeysExamined,
+		DocsExamined:    res.ExecutionStats.TotalDocsExamined,
+		ExecutionTimeMS: res.ExecutionStats.ExecutionTimeMillis,
+	}
+	stages := []explainStage{res.QueryPlanner.WinningPlan}
+	for len(stages) > 0 {
+		stage := stages[0]
+		stages = stages[1:]
+		switch stage.Stage {
+		case explainStageCollScan:
+			plan.CollectionScan = true
+		case explainStageIxScan:
+			plan.Indexes = append(plan.Indexes, stage.IndexName)
+		}
+		if stage.InputStage != nil {
+			stages = append(stages, *stage.InputStage)
+		}
+		stages = append(stages, stage.InputStages...)
+	}
+	return plan
+}
+
+// CountFilterTerms counts the devices matching each term of the filters
+// of the search alone and together with the previous terms; the other
+// criteria of the search apply to all the counts.
+func (db *DataStoreMongo) CountFilterTerms(
+	ctx context.Context,
+	searchParams model.SearchParams,
+) ([]model.FilterTermCount, error) {
+	c := db.database(ctx).Collection(db.names.Devices)
+
+	count := func(params model.SearchParams) (int, error) {
+		n, err := c.CountDocuments(ctx, searchDevicesFilter(params))
+		if err != nil {
+			return -1, errors.Wrap(err, "failed to count devices")
+		}
+		return int(n), nil
+	}
+	terms := make([]model.FilterTermCount, len(searchParams.Filters))
+	for k, filter := range searchParams.Filters {
+		params := searchParams
+		terms[k].Filter = filter
+		params.Filters = searchParams.Filters[k : k+1]
+		n, err := count(params)
+		if err != nil {
+			return nil, err
+		}
+		terms[k].Devices = n
+		if k == 0 {
+			terms[k].Cumulative = n
+		} else if terms[k-1].Cumulative == 0 {
+			// no device matches the previous terms either
+			terms[k].Cumulative = 0
+		} else {
+			params.Filters = searchParams.Filters[:k+1]
+			if terms[k].Cumulative, err = count(params); err != nil {
+				return nil, err
+			}
+		}
+		if n > 0 {
+			continue
+		}
+		// the devices of the tenant reporting the attribute tell the
+		// typos in the attribute names from the missing values
+		reported, err := count(model.SearchParams{
+			Filters: []model.FilterPredicate{{
+				Scope:     filter.Scope,
+				Attribute: filter.Attribute,
+				Type:      "$exists",
+				Value:     true,
+				Aliases:   filter.Aliases,
+			}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		terms[k].Reported = &reported
+	}
+	return terms, nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+)
+
+const DbExportJobsColl = "export_jobs"
+
+func (db *DataStoreMongo) CreateExportJob(ctx context.Context, job model.ExportJob) error {
+	c := db.database(ctx).
+		Collection(DbExportJobsColl)
+
+	if _, err := c.InsertOne(ctx, job); err != nil {
+		return errors.Wrap(err, "failed to store export job")
+	}
+	return nil
+}
+
+func (db *DataStoreMongo) GetExportJob(ctx context.Context, id string) (*model.ExportJob, error) {
+	c := db.database(ctx).
+		Collection(DbExportJobsColl)
+
+	var job model.ExportJob
+	err := c.FindOne(ctx, bson.M{DbDevId: id}).Decode(&job)
+	if err == mongo.ErrNoDocuments {
+		return nil, store.ErrExportJobNotFound
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to get export job")
+	}
+	return &job, nil
+}
+
+func (db *DataStoreMongo) UpdateExportJob(ctx context.Context, job model.ExportJob) error {
+	c := db.database(ctx).
+		Collection(DbExportJobsColl)
+
+	res, err := c.ReplaceOne(ctx, bson.M{DbDevId: job.ID}, job)
+	if err != nil {
+		return errors.Wrap(err, "failed to update export job")
+	} else if res.MatchedCount == 0 {
+		return store.ErrExportJobNotFound
+	}
+	return nil
+}
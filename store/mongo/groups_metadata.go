@@ -0,0 +1,122 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	mopts "go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+)
+
+// DbGroupsMetadataColl holds the metadata of the groups, identified by
+// their names.
+const DbGroupsMetadataColl = "groups_metadata"
+
+func (db *DataStoreMongo) GetGroupsMetadata(ctx context.Context) ([]model.GroupMetadata, error) {
+	c := db.database(ctx).
+		Collection(DbGroupsMetadataColl)
+
+	cur, err := db.find(ctx, c, bson.M{},
+		mopts.Find().SetSort(bson.D{{Key: DbDevId, Value: 1}}),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get groups metadata")
+	}
+	groups := []model.GroupMetadata{}
+	if err = decodeAll(ctx, cur, &groups); err != nil {
+		return nil, errors.Wrap(err, "failed to get groups metadata")
+	}
+	return groups, nil
+}
+
+func (db *DataStoreMongo) GetGroupMetadata(
+	ctx context.Context,
+	name model.GroupName,
+) (*model.GroupMetadata, error) {
+	c := db.database(ctx).
+		Collection(DbGroupsMetadataColl)
+
+	var group model.GroupMetadata
+	err := c.FindOne(ctx, bson.M{DbDevId: name}).Decode(&group)
+	if err == mongo.ErrNoDocuments {
+		return nil, store.ErrGroupMetadataNotFound
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to get group metadata")
+	}
+	return &group, nil
+}
+
+func (db *DataStoreMongo) UpsertGroupMetadata(
+	ctx context.Context,
+	group model.GroupMetadata,
+) error {
+	c := db.database(ctx).
+		Collection(DbGroupsMetadataColl)
+
+	_, err := c.ReplaceOne(ctx,
+		bson.M{DbDevId: group.Name},
+		group,
+		mopts.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to store group metadata")
+	}
+	return nil
+}
+
+func (db *DataStoreMongo) InsertGroupsMetadata(
+	ctx context.Context,
+	groups []model.GroupMetadata,
+) error {
+	if len(groups) == 0 {
+		return nil
+	}
+	c := db.database(ctx).
+		Collection(DbGroupsMetadataColl)
+
+	// the upserts only set the metadata of the groups without any, so
+	// that the concurrent inserts do not fail on the duplicates
+	models := make([]mongo.WriteModel, len(groups))
+	for n, group := range groups {
+		models[n] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{DbDevId: group.Name}).
+			SetUpdate(bson.M{"$setOnInsert": group}).
+			SetUpsert(true)
+	}
+	_, err := c.BulkWrite(ctx, models, mopts.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return errors.Wrap(err, "failed to store groups metadata")
+	}
+	return nil
+}
+
+func (db *DataStoreMongo) DeleteGroupMetadata(ctx context.Context, name model.GroupName) error {
+	c := db.database(ctx).
+		Collection(DbGroupsMetadataColl)
+
+	res, err := c.DeleteOne(ctx, bson.M{DbDevId: name})
+	if err != nil {
+		return errors.Wrap(err, "failed to remove group metadata")
+	} else if res.DeletedCount == 0 {
+		return store.ErrGroupMetadataNotFound
+	}
+	return nil
+}
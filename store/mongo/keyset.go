@@ -0,0 +1,104 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+// keysetQuery returns the query of the devices following the cursor in
+// the order of its sort attributes and of the IDs: the devices with
+// the same values of the first attributes as the cursor, and following it
+// on the next attribute or, with all the values the same, on the ID.
+// The devices without an attribute sort as its null value, first in
+// the ascending order unless placed explicitly; the values compare within
+// their type, as the attributes hold values of a single type across
+// the devices.
+func keysetQuery(cursor model.DeviceCursor) bson.M {
+	alternatives := make([]bson.M, 0, len(cursor.Sort)+1)
+	prefix := bson.M{}
+	for n, s := range cursor.Sort {
+		name := fmt.Sprintf("%s-%s", s.Scope, model.GetDeviceAttributeNameReplacer().Replace(s.Attribute))
+		field := fmt.Sprintf("%s.%s.%s", DbDevAttributes, name, DbDevAttributesValue)
+		value := cursor.Values[n]
+		if following := followingValuesQuery(field, value, s.Order == "desc", nullsLast(s)); following != nil {
+			alternative := bson.M{"$and": []bson.M{prefix, following}}
+			if len(prefix) == 0 {
+				alternative = following
+			}
+			alternatives = append(alternatives, alternative)
+		}
+		equal := make(bson.M, len(prefix)+1)
+		for k, v := range prefix {
+			equal[k] = v
+		}
+		// a null value also matches the devices without the attribute
+		equal[field] = value
+		prefix = equal
+	}
+	prefix[DbDevId] = bson.M{"$gt": cursor.ID}
+	alternatives = append(alternatives, prefix)
+	if len(alternatives) == 1 {
+		return alternatives[0]
+	}
+	return bson.M{"$or": alternatives}
+}
+
+// nullsLast tells whether the devices without the attribute sort after
+// the others.
+func nullsLast(s model.SortCriteria) bool {
+	if s.Nulls != "" {
+		return s.Nulls == model.SortNullsLast
+	}
+	return s.Order == "desc"
+}
+
+// followingValuesQuery returns the query of the values of the field
+// following the value in the order of the sort, or nil if none does.
+func followingValuesQuery(field string, value interface{}, desc, nullsLast bool) bson.M {
+	var following bson.M
+	switch {
+	case value == nil && nullsLast:
+		return nil
+	case value == nil:
+		return bson.M{field: bson.M{"$ne": nil}}
+	case desc:
+		following = bson.M{field: bson.M{"$lt": value}}
+	default:
+		following = bson.M{field: bson.M{"$gt": value}}
+	}
+	if nullsLast {
+		return bson.M{"$or": []bson.M{following, {field: nil}}}
+	}
+	return following
+}
+
+// withKeyset returns the query limited to the devices following
+// the cursor; the query is unchanged by a cursor starting from the first
+// device.
+func withKeyset(query bson.M, cursor *model.DeviceCursor) bson.M {
+	if cursor == nil || cursor.ID == "" {
+		return query
+	}
+	keyset := keysetQuery(*cursor)
+	if len(query) == 0 {
+		return keyset
+	}
+	return bson.M{"$and": []bson.M{query, keyset}}
+}
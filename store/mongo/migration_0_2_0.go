@@ -19,7 +19,6 @@ import (
 	"fmt"
 
 	"github.com/mendersoftware/go-lib-micro/mongo/migrate"
-	mstore "github.com/mendersoftware/go-lib-micro/store"
 	"github.com/pkg/errors"
 	"go.mongodb.org/mongo-driver/bson"
 )
@@ -38,8 +37,8 @@ func (m *migration_0_2_0) Up(from migrate.Version) error {
 
 	// rename every attribute occurrence to scoped version, add scope field
 	// hacky - we're doing it in two runs, but dead simple
-	databaseName := mstore.DbFromContext(m.ctx, DbName)
-	coll := m.ms.client.Database(databaseName).Collection(DbDevicesColl)
+	databaseName := m.ms.dbName(m.ctx)
+	coll := m.ms.client.Database(databaseName).Collection(m.ms.names.Devices)
 	for _, n := range names {
 		nold := fmt.Sprintf("%s.%s", DbDevAttributes, n)
 		nnew := fmt.Sprintf("%s.%s-%s", DbDevAttributes, DbScopeInventory, n)
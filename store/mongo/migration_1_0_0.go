@@ -24,7 +24,6 @@ import (
 
 	"github.com/mendersoftware/go-lib-micro/log"
 	"github.com/mendersoftware/go-lib-micro/mongo/migrate"
-	mstore "github.com/mendersoftware/go-lib-micro/store"
 	"github.com/mendersoftware/inventory/model"
 )
 
@@ -71,8 +70,8 @@ const (
 // the new fields are upserted.
 func (m *migration_1_0_0) doMaintenance(from migrate.Version) error {
 	var N int
-	database := m.ms.client.Database(mstore.DbFromContext(m.ctx, DbName))
-	collDevs := database.Collection(DbDevicesColl)
+	database := m.ms.database(m.ctx)
+	collDevs := database.Collection(m.ms.names.Devices)
 	collMgrInfo := database.Collection("migration_info")
 	l := log.FromContext(m.ctx)
 
@@ -143,8 +142,8 @@ func (m *migration_1_0_0) doMaintenance(from migrate.Version) error {
 }
 
 func (m *migration_1_0_0) doMigrate(from migrate.Version) error {
-	databaseName := mstore.DbFromContext(m.ctx, DbName)
-	collDevs := m.ms.client.Database(databaseName).Collection(DbDevicesColl)
+	databaseName := m.ms.dbName(m.ctx)
+	collDevs := m.ms.client.Database(databaseName).Collection(m.ms.names.Devices)
 
 	// Move timestamps to identity scope.
 	_, err := collDevs.UpdateMany(m.ctx, bson.M{}, bson.M{
@@ -180,8 +179,8 @@ func (m *migration_1_0_0) doMigrate(from migrate.Version) error {
 }
 
 func (m *migration_1_0_0) doCleanup() error {
-	databaseName := mstore.DbFromContext(m.ctx, DbName)
-	collDevs := m.ms.client.Database(databaseName).Collection(DbDevicesColl)
+	databaseName := m.ms.dbName(m.ctx)
+	collDevs := m.ms.client.Database(databaseName).Collection(m.ms.names.Devices)
 
 	update := bson.M{"$unset": bson.M{
 		"updated_ts": "",
@@ -196,7 +195,7 @@ func (m *migration_1_0_0) doCleanup() error {
 // The values reflect the values previously held in the root of the document.
 func (m *migration_1_0_0) Up(from migrate.Version) error {
 	l := log.FromContext(m.ctx)
-	tenantDB := mstore.DbFromContext(m.ctx, DbName)
+	tenantDB := m.ms.dbName(m.ctx)
 	if !migrate.VersionIsLess(from, m.Version()) {
 		l.Infof("db '%s' already migrated", tenantDB)
 		return nil
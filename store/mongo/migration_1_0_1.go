@@ -34,7 +34,7 @@ var attributesToIndex = []string{
 
 func (m *migration_1_0_1) Up(from migrate.Version) error {
 	for _, key := range attributesToIndex {
-		_ = indexAttr(m.ms.client, m.ctx, key)
+		_ = m.ms.indexAttr(m.ctx, key)
 	}
 	return nil
 }
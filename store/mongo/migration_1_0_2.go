@@ -20,10 +20,11 @@ import (
 	"github.com/mendersoftware/go-lib-micro/log"
 	"github.com/mendersoftware/go-lib-micro/mongo/migrate"
 	"go.mongodb.org/mongo-driver/bson"
-
-	mstore "github.com/mendersoftware/go-lib-micro/store"
 )
 
+// noRevisionFilter selects the devices written before the revisions.
+var noRevisionFilter = bson.M{DbDevRevision: bson.M{"$exists": false}}
+
 type migration_1_0_2 struct {
 	ms  *DataStoreMongo
 	ctx context.Context
@@ -32,11 +33,10 @@ type migration_1_0_2 struct {
 func (m *migration_1_0_2) Up(from migrate.Version) error {
 	l := log.FromContext(m.ctx)
 
-	databaseName := mstore.DbFromContext(m.ctx, DbName)
-	coll := m.ms.client.Database(databaseName).Collection(DbDevicesColl)
-	filter := bson.M{DbDevRevision: bson.M{"$exists": false}}
+	databaseName := m.ms.dbName(m.ctx)
+	coll := m.ms.client.Database(databaseName).Collection(m.ms.names.Devices)
 	update := bson.M{"$set": bson.M{DbDevRevision: 0}}
-	resp, err := coll.UpdateMany(m.ctx, filter, update)
+	resp, err := coll.UpdateMany(m.ctx, noRevisionFilter, update)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,51 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+
+	"github.com/mendersoftware/go-lib-micro/mongo/migrate"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	mopts "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const IndexNameExternalID = "system_id"
+
+// migration_1_0_3 indexes the lookup of the devices by their external IDs.
+type migration_1_0_3 struct {
+	ms  *DataStoreMongo
+	ctx context.Context
+}
+
+func (m *migration_1_0_3) Up(from migrate.Version) error {
+	databaseName := m.ms.dbName(m.ctx)
+	coll := m.ms.client.Database(databaseName).Collection(DbExternalIDsColl)
+	_, err := coll.Indexes().CreateOne(m.ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: DbExternalIDSystem, Value: 1},
+			{Key: DbExternalID, Value: 1},
+		},
+		Options: mopts.Index().
+			SetName(IndexNameExternalID).
+			SetUnique(true),
+	})
+	return err
+}
+
+func (m *migration_1_0_3) Version() migrate.Version {
+	return migrate.MakeVersion(1, 0, 3)
+}
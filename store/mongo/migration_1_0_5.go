@@ -0,0 +1,72 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/mendersoftware/go-lib-micro/mongo/migrate"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	mopts "go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+const (
+	IndexNameGroup     = "system_group"
+	IndexNameUpdatedTs = "system_updated_ts"
+	IndexNameCreatedTs = "system_created_ts"
+)
+
+// coreIndexes are the single-attribute indexes of the most common
+// filters: the indexes of migration 1.0.1 are prefixed with the identity
+// status and do not serve the queries not filtering by status.
+var coreIndexes = map[string]string{
+	IndexNameGroup:     DbDevAttributesGroupValue,
+	IndexNameUpdatedTs: indexAttrName(model.AttrScopeSystem + "-" + model.AttrNameUpdated),
+	IndexNameCreatedTs: indexAttrName(model.AttrScopeSystem + "-" + model.AttrNameCreated),
+}
+
+// migration_1_0_5 indexes the group and the timestamps of the devices.
+type migration_1_0_5 struct {
+	ms  *DataStoreMongo
+	ctx context.Context
+}
+
+func (m *migration_1_0_5) Up(from migrate.Version) error {
+	l := log.FromContext(m.ctx)
+	databaseName := m.ms.dbName(m.ctx)
+	coll := m.ms.client.Database(databaseName).Collection(m.ms.names.Devices)
+	for name, key := range coreIndexes {
+		_, err := coll.Indexes().CreateOne(m.ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: key, Value: 1}},
+			Options: mopts.Index().SetName(name),
+		})
+		if err != nil && isTooManyIndexes(err) {
+			l.Warnf("failed to create index %s in db %s: too many indexes",
+				name, databaseName)
+		} else if err != nil {
+			return errors.Wrapf(err, "failed to create index %s", name)
+		}
+	}
+	return nil
+}
+
+func (m *migration_1_0_5) Version() migrate.Version {
+	return migrate.MakeVersion(1, 0, 5)
+}
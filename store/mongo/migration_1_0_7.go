@@ -0,0 +1,54 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+
+	"github.com/mendersoftware/go-lib-micro/mongo/migrate"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	mopts "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const IndexNameDeviceChangesTTL = "device_changes_ttl"
+
+// migration_1_0_7 expires the changes of the change feed after
+// the retention period.
+type migration_1_0_7 struct {
+	ms  *DataStoreMongo
+	ctx context.Context
+}
+
+func (m *migration_1_0_7) Up(from migrate.Version) error {
+	databaseName := m.ms.dbName(m.ctx)
+	coll := m.ms.client.Database(databaseName).Collection(DbDeviceChangesColl)
+	_, err := coll.Indexes().CreateOne(m.ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: DbDeviceChangeTs, Value: 1}},
+		Options: mopts.Index().
+			SetName(IndexNameDeviceChangesTTL).
+			SetExpireAfterSeconds(int32(DeviceChangesRetention.Seconds())),
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to create index %s",
+			IndexNameDeviceChangesTTL)
+	}
+	return nil
+}
+
+func (m *migration_1_0_7) Version() migrate.Version {
+	return migrate.MakeVersion(1, 0, 7)
+}
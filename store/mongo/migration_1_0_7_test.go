@@ -0,0 +1,65 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/mongo/migrate"
+	mstore "github.com/mendersoftware/go-lib-micro/store"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMigration_1_0_7(t *testing.T) {
+	ctx := context.Background()
+
+	db.Wipe()
+	s := db.Client()
+	ds := NewDataStoreMongoWithSession(s).(*DataStoreMongo)
+
+	migrator := &migrate.SimpleMigrator{
+		Client:      s,
+		Db:          mstore.DbFromContext(ctx, DbName),
+		Automigrate: true,
+	}
+	err := migrator.Apply(ctx, migrate.MakeVersion(1, 0, 7),
+		[]migrate.Migration{
+			&migration_1_0_7{
+				ms:  ds,
+				ctx: ctx,
+			},
+		},
+	)
+	assert.NoError(t, err)
+
+	cur, err := s.Database(mstore.DbFromContext(ctx, DbName)).
+		Collection(DbDeviceChangesColl).
+		Indexes().List(ctx)
+	assert.NoError(t, err)
+	var indexes []bson.M
+	assert.NoError(t, cur.All(ctx, &indexes))
+	found := false
+	for _, index := range indexes {
+		if index["name"] == IndexNameDeviceChangesTTL {
+			found = true
+			assert.Equal(t, bson.M{DbDeviceChangeTs: int32(1)}, index["key"])
+			assert.EqualValues(t, DeviceChangesRetention.Seconds(),
+				index["expireAfterSeconds"])
+		}
+	}
+	assert.True(t, found, "index not created: %s", IndexNameDeviceChangesTTL)
+}
@@ -0,0 +1,68 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/mongo/migrate"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+// migration_1_0_8 backfills the metadata of the groups the devices are
+// assigned to and of the dynamic groups; the groups are taken as created
+// at the time of the migration.
+type migration_1_0_8 struct {
+	ms  *DataStoreMongo
+	ctx context.Context
+}
+
+func (m *migration_1_0_8) Up(from migrate.Version) error {
+	names, err := m.ms.ListGroups(m.ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to list groups")
+	}
+	dynamic, err := m.ms.GetDynamicGroups(m.ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to list dynamic groups")
+	}
+
+	now := time.Now()
+	groups := make([]model.GroupMetadata, 0, len(names)+len(dynamic))
+	for _, name := range names {
+		groups = append(groups, model.GroupMetadata{
+			Name:      name,
+			Type:      model.GroupTypeStatic,
+			CreatedTs: now,
+			UpdatedTs: now,
+		})
+	}
+	for _, group := range dynamic {
+		groups = append(groups, model.GroupMetadata{
+			Name:      group.Name,
+			Type:      model.GroupTypeDynamic,
+			CreatedTs: group.CreatedTs,
+			UpdatedTs: group.UpdatedTs,
+		})
+	}
+	return m.ms.InsertGroupsMetadata(m.ctx, groups)
+}
+
+func (m *migration_1_0_8) Version() migrate.Version {
+	return migrate.MakeVersion(1, 0, 8)
+}
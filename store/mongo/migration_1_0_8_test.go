@@ -0,0 +1,87 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/mongo/migrate"
+	mstore "github.com/mendersoftware/go-lib-micro/store"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+func TestMigration_1_0_8(t *testing.T) {
+	ctx := context.Background()
+
+	db.Wipe()
+	s := db.Client()
+	ds := NewDataStoreMongoWithSession(s).(*DataStoreMongo)
+
+	for _, dev := range []model.Device{
+		{ID: "1", Group: "dev"},
+		{ID: "2"},
+		{ID: "3", Group: "prod"},
+	} {
+		dev := dev
+		assert.NoError(t, ds.AddDevice(ctx, &dev))
+	}
+	created := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, ds.UpsertDynamicGroup(ctx, model.DynamicGroup{
+		Name:       "arm",
+		Expression: `inventory/arch == "arm"`,
+		CreatedTs:  created,
+		UpdatedTs:  created,
+	}))
+	// the metadata already stored is kept
+	assert.NoError(t, ds.UpsertGroupMetadata(ctx, model.GroupMetadata{
+		Name:        "prod",
+		Type:        model.GroupTypeStatic,
+		Description: "production devices",
+		CreatedTs:   created,
+		UpdatedTs:   created,
+	}))
+
+	migrator := &migrate.SimpleMigrator{
+		Client:      s,
+		Db:          mstore.DbFromContext(ctx, DbName),
+		Automigrate: true,
+	}
+	err := migrator.Apply(ctx, migrate.MakeVersion(1, 0, 8),
+		[]migrate.Migration{
+			&migration_1_0_8{
+				ms:  ds,
+				ctx: ctx,
+			},
+		},
+	)
+	assert.NoError(t, err)
+
+	groups, err := ds.GetGroupsMetadata(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, groups, 3) {
+		assert.Equal(t, model.GroupName("arm"), groups[0].Name)
+		assert.Equal(t, model.GroupTypeDynamic, groups[0].Type)
+		assert.True(t, created.Equal(groups[0].CreatedTs))
+		assert.Equal(t, model.GroupName("dev"), groups[1].Name)
+		assert.Equal(t, model.GroupTypeStatic, groups[1].Type)
+		assert.False(t, groups[1].CreatedTs.IsZero())
+		assert.Equal(t, model.GroupName("prod"), groups[2].Name)
+		assert.Equal(t, "production devices", groups[2].Description)
+	}
+}
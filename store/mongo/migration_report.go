@@ -0,0 +1,216 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/mongo/migrate"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+const (
+	// migrationSampleSize is the number of documents per collection
+	// sampled to estimate the number of documents a migration updates.
+	migrationSampleSize = 1000
+	// migrationUpdateCost is the estimated time a migration spends
+	// updating a document.
+	migrationUpdateCost = 100 * time.Microsecond
+	// migrationScanCost is the estimated time a migration spends
+	// scanning a document, e.g. to build an index.
+	migrationScanCost = 10 * time.Microsecond
+)
+
+// migrationImpact describes the documents a migration works on, to
+// estimate its duration.
+type migrationImpact struct {
+	// collection is the collection the migration works on; the devices
+	// if empty.
+	collection string
+	// filter selects the documents the migration updates; nil if it
+	// updates none.
+	filter bson.M
+	// scans is the number of scans of the whole collection, e.g. one
+	// per index built.
+	scans int
+}
+
+// migrationImpacts are the impacts of the migrations, by version.
+var migrationImpacts = map[string]migrationImpact{
+	"0.2.0": {filter: bson.M{}},
+	"1.0.0": {filter: bson.M{}},
+	"1.0.1": {scans: len(attributesToIndex)},
+	"1.0.2": {filter: noRevisionFilter},
+	"1.0.3": {collection: DbExternalIDsColl, scans: 1},
+	"1.0.4": {scans: 1},
+	"1.0.5": {scans: len(coreIndexes)},
+	"1.0.6": {scans: len(identityIndexes)},
+	"1.0.7": {collection: DbDeviceChangesColl, scans: 1},
+	"1.0.8": {scans: 1},
+}
+
+// MigrationReport estimates the impact of migrating the databases of
+// the tenants, or of all the tenants, to the version without modifying
+// them; the number of documents updated is estimated from a sample of
+// the documents of the large collections.
+func (db *DataStoreMongo) MigrationReport(
+	ctx context.Context,
+	version string,
+	tenantIDs ...string,
+) (*model.MigrationReport, error) {
+	target, err := migrate.NewVersion(version)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse service version")
+	}
+
+	var dbs []string
+	if len(tenantIDs) > 0 {
+		for _, tenantID := range tenantIDs {
+			dbs = append(dbs, db.names.ForTenant(tenantID))
+		}
+	} else {
+		dbs, err = migrate.GetTenantDbs(ctx, db.client, db.names.IsTenantDb)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed go retrieve tenant DBs")
+		}
+		if len(dbs) == 0 {
+			dbs = []string{db.names.Database}
+		}
+	}
+
+	report := &model.MigrationReport{Version: target.String()}
+	for _, name := range dbs {
+		plan, err := db.planMigrations(ctx, name, *target)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to estimate the migration of %s", name)
+		}
+		report.Add(*plan)
+	}
+	return report, nil
+}
+
+// planMigrations estimates the impact of the migrations of the database
+// up to the target version.
+func (db *DataStoreMongo) planMigrations(
+	ctx context.Context,
+	database string,
+	target migrate.Version,
+) (*model.TenantMigrationPlan, error) {
+	current, _, err := db.schemaVersion(ctx, database)
+	if err != nil {
+		return nil, err
+	}
+
+	devices, err := db.client.Database(database).
+		Collection(db.names.Devices).
+		EstimatedDocumentCount(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to count devices")
+	}
+	plan := &model.TenantMigrationPlan{
+		TenantID: db.names.TenantFromDb(database),
+		Database: database,
+		Version:  current.String(),
+		Devices:  devices,
+		Steps:    []model.MigrationStep{},
+	}
+	for _, m := range db.migrations(ctx) {
+		v := m.Version()
+		if !migrate.VersionIsLess(current, v) || migrate.VersionIsLess(target, v) {
+			continue
+		}
+		impact, ok := migrationImpacts[v.String()]
+		if !ok {
+			return nil, errors.Errorf("no estimate of the migration %s", v)
+		}
+		step, err := db.estimateMigration(ctx, database, impact)
+		if err != nil {
+			return nil, errors.Wrapf(err, "migration %s", v)
+		}
+		step.Version = v.String()
+		plan.Steps = append(plan.Steps, *step)
+	}
+	return plan, nil
+}
+
+// estimateMigration estimates the number of documents and the duration
+// of the migration of the database.
+func (db *DataStoreMongo) estimateMigration(
+	ctx context.Context,
+	database string,
+	impact migrationImpact,
+) (*model.MigrationStep, error) {
+	name := impact.collection
+	if name == "" {
+		name = db.names.Devices
+	}
+	coll := db.client.Database(database).Collection(name)
+	total, err := coll.EstimatedDocumentCount(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to count the documents of %s", name)
+	}
+	step := &model.MigrationStep{Collection: name}
+	if impact.filter != nil {
+		updated, err := db.estimateMatching(ctx, coll, total, impact.filter)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to sample the documents of %s", name)
+		}
+		step.Documents = updated
+		step.Duration = time.Duration(updated) * migrationUpdateCost
+	} else {
+		step.Documents = total
+	}
+	step.Duration += time.Duration(total*int64(impact.scans)) * migrationScanCost
+	return step, nil
+}
+
+// estimateMatching estimates the number of documents of the collection
+// matching the filter: the documents of the small collections are
+// counted, the large ones are sampled.
+func (db *DataStoreMongo) estimateMatching(
+	ctx context.Context,
+	coll *mongo.Collection,
+	total int64,
+	filter bson.M,
+) (int64, error) {
+	switch {
+	case total == 0 || len(filter) == 0:
+		return total, nil
+	case total <= migrationSampleSize:
+		return coll.CountDocuments(ctx, filter)
+	}
+	cur, err := db.aggregate(ctx, coll, []bson.M{
+		{"$sample": bson.M{"size": migrationSampleSize}},
+		{"$match": filter},
+		{"$count": "count"},
+	})
+	if err != nil {
+		return 0, err
+	}
+	var counts []struct {
+		Count int64 `bson:"count"`
+	}
+	if err := decodeAll(ctx, cur, &counts); err != nil {
+		return 0, err
+	}
+	if len(counts) == 0 {
+		return 0, nil
+	}
+	return total * counts[0].Count / migrationSampleSize, nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package mongo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/mongo/migrate"
+	mstore "github.com/mendersoftware/go-lib-micro/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+func TestMigrationImpacts(t *testing.T) {
+	ds := &DataStoreMongo{}
+	for _, m := range ds.migrations(context.Background()) {
+		assert.Contains(t, migrationImpacts, m.Version().String())
+	}
+}
+
+func TestMigrationReport(t *testing.T) {
+	ctx := context.Background()
+
+	db.Wipe()
+	s := db.Client()
+	ds := NewDataStoreMongoWithSession(s).(*DataStoreMongo)
+
+	database := mstore.DbFromContext(ctx, DbName)
+	coll := s.Database(database).Collection(DbDevicesColl)
+	_, err := coll.InsertMany(ctx, []interface{}{
+		bson.M{DbDevId: "1", DbDevRevision: 1},
+		bson.M{DbDevId: "2"},
+	})
+	require.NoError(t, err)
+	err = migrate.UpdateMigrationInfo(ctx, migrate.MakeVersion(1, 0, 0),
+		s, database)
+	require.NoError(t, err)
+
+	report, err := ds.MigrationReport(ctx, "1.0.2")
+	assert.NoError(t, err)
+	if assert.Len(t, report.Tenants, 1) {
+		plan := report.Tenants[0]
+		assert.Equal(t, database, plan.Database)
+		assert.Equal(t, "1.0.0", plan.Version)
+		assert.Equal(t, int64(2), plan.Devices)
+		scans := time.Duration(2*len(attributesToIndex)) * migrationScanCost
+		assert.Equal(t, []model.MigrationStep{{
+			Version:    "1.0.1",
+			Collection: DbDevicesColl,
+			Documents:  2,
+			Duration:   scans,
+		}, {
+			Version:    "1.0.2",
+			Collection: DbDevicesColl,
+			Documents:  1,
+			Duration:   migrationUpdateCost,
+		}}, plan.Steps)
+		assert.Equal(t, scans+migrationUpdateCost, report.Duration)
+	}
+
+	// nothing is migrated
+	n, err := coll.CountDocuments(ctx, noRevisionFilter)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	_, err = ds.MigrationReport(ctx, "1.0")
+	assert.Error(t, err)
+}
@@ -22,23 +22,21 @@ import (
 	"github.com/mendersoftware/go-lib-micro/identity"
 	"github.com/mendersoftware/go-lib-micro/log"
 	"github.com/mendersoftware/go-lib-micro/mongo/migrate"
-	mstore "github.com/mendersoftware/go-lib-micro/store"
 	"github.com/mendersoftware/inventory/store"
 )
 
 // WithAutomigrate enables automatic migration and returns a new datastore based
 // on current one
 func (db *DataStoreMongo) WithAutomigrate() store.DataStore {
-	return &DataStoreMongo{
-		client:      db.client,
-		automigrate: true,
-	}
+	cp := *db
+	cp.automigrate = true
+	return &cp
 }
 
 func (db *DataStoreMongo) MigrateTenant(ctx context.Context, version string, tenantId string) error {
 	l := log.FromContext(ctx)
 
-	database := mstore.DbNameForTenant(tenantId, DbName)
+	database := db.names.ForTenant(tenantId)
 
 	l.Infof("migrating %s", database)
 
@@ -57,7 +55,18 @@ func (db *DataStoreMongo) MigrateTenant(ctx context.Context, version string, ten
 		Tenant: tenantId,
 	})
 
-	migrations := []migrate.Migration{
+	err = m.Apply(ctx, *ver, db.migrations(ctx))
+	if err != nil {
+		return errors.Wrap(err, "failed to apply migrations")
+	}
+
+	return nil
+}
+
+// migrations returns the migrations of the database of the tenant in
+// the context, in the order of their versions.
+func (db *DataStoreMongo) migrations(ctx context.Context) []migrate.Migration {
+	return []migrate.Migration{
 		&migration_0_2_0{
 			ms:  db,
 			ctx: ctx,
@@ -74,26 +83,43 @@ func (db *DataStoreMongo) MigrateTenant(ctx context.Context, version string, ten
 			ms:  db,
 			ctx: ctx,
 		},
+		&migration_1_0_3{
+			ms:  db,
+			ctx: ctx,
+		},
+		&migration_1_0_4{
+			ms:  db,
+			ctx: ctx,
+		},
+		&migration_1_0_5{
+			ms:  db,
+			ctx: ctx,
+		},
+		&migration_1_0_6{
+			ms:  db,
+			ctx: ctx,
+		},
+		&migration_1_0_7{
+			ms:  db,
+			ctx: ctx,
+		},
+		&migration_1_0_8{
+			ms:  db,
+			ctx: ctx,
+		},
 	}
-
-	err = m.Apply(ctx, *ver, migrations)
-	if err != nil {
-		return errors.Wrap(err, "failed to apply migrations")
-	}
-
-	return nil
 }
 
 func (db *DataStoreMongo) Migrate(ctx context.Context, version string) error {
 	l := log.FromContext(ctx)
 
-	dbs, err := migrate.GetTenantDbs(ctx, db.client, mstore.IsTenantDb(DbName))
+	dbs, err := migrate.GetTenantDbs(ctx, db.client, db.names.IsTenantDb)
 	if err != nil {
 		return errors.Wrap(err, "failed go retrieve tenant DBs")
 	}
 
 	if len(dbs) == 0 {
-		dbs = []string{DbName}
+		dbs = []string{db.names.Database}
 	}
 
 	if db.automigrate {
@@ -105,7 +131,7 @@ func (db *DataStoreMongo) Migrate(ctx context.Context, version string) error {
 	for _, d := range dbs {
 		l.Infof("migrating %s", d)
 
-		tenantId := mstore.TenantFromDbName(d, DbName)
+		tenantId := db.names.TenantFromDb(d)
 
 		if err := db.MigrateTenant(ctx, version, tenantId); err != nil {
 			return err
@@ -138,7 +164,7 @@ func (db *DataStoreMongo) upgradeTenant(
 			version,
 		)
 	}
-	tenantDB := mstore.DbFromContext(ctx, DbName)
+	tenantDB := db.dbName(ctx)
 
 	migrationInfo, err := migrate.GetMigrationInfo(
 		ctx, db.client, tenantDB,
@@ -179,16 +205,16 @@ func (db *DataStoreMongo) Maintenance(
 		}
 	} else {
 		dbs, err := migrate.GetTenantDbs(
-			ctx, db.client, mstore.IsTenantDb(DbName),
+			ctx, db.client, db.names.IsTenantDb,
 		)
 		if err != nil {
 			return errors.Wrap(err, "failed to retrieve tenant DBs")
 		}
 		if len(dbs) == 0 {
-			dbs = []string{DbName}
+			dbs = []string{db.names.Database}
 		}
 		for _, d := range dbs {
-			tenantID := mstore.TenantFromDbName(d, DbName)
+			tenantID := db.names.TenantFromDb(d)
 			l.Infof("Updating DB: %s", d)
 			tenantCTX := identity.WithContext(
 				ctx,
@@ -0,0 +1,102 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/mendersoftware/inventory/utils/reqctx"
+)
+
+// DbNames are the names of the databases and the collections used by
+// the store; distinct names allow multiple inventory instances to share
+// the same mongo cluster.
+type DbNames struct {
+	// Database is the name of the database used without a tenant.
+	Database string
+	// TenantPrefix is the prefix of the tenant databases, followed by
+	// the tenant ID.
+	TenantPrefix string
+	// Devices is the name of the devices collection.
+	Devices string
+}
+
+// DefaultDbNames returns the names used unless configured otherwise.
+func DefaultDbNames() DbNames {
+	return DbNames{
+		Database:     DbName,
+		TenantPrefix: DbName + "-",
+		Devices:      DbDevicesColl,
+	}
+}
+
+// WithDefaults fills the names left empty with the defaults; the tenant
+// prefix defaults to the database name followed by a dash.
+func (n DbNames) WithDefaults() DbNames {
+	if n.Database == "" {
+		n.Database = DbName
+	}
+	if n.TenantPrefix == "" {
+		n.TenantPrefix = n.Database + "-"
+	}
+	if n.Devices == "" {
+		n.Devices = DbDevicesColl
+	}
+	return n
+}
+
+func (n DbNames) Validate() error {
+	if n.TenantPrefix == n.Database {
+		return errors.New("tenant database prefix must differ " +
+			"from the database name")
+	}
+	return nil
+}
+
+// ForTenant returns the name of the database of the tenant.
+func (n DbNames) ForTenant(tenantID string) string {
+	if tenantID == "" {
+		return n.Database
+	}
+	return n.TenantPrefix + tenantID
+}
+
+// IsTenantDb returns true if the database belongs to a tenant.
+func (n DbNames) IsTenantDb(name string) bool {
+	return strings.HasPrefix(name, n.TenantPrefix)
+}
+
+// TenantFromDb returns the ID of the tenant the database belongs to, or
+// an empty ID for the database used without a tenant.
+func (n DbNames) TenantFromDb(name string) string {
+	if !n.IsTenantDb(name) {
+		return ""
+	}
+	return strings.TrimPrefix(name, n.TenantPrefix)
+}
+
+// dbName returns the name of the database of the tenant in the context.
+func (db *DataStoreMongo) dbName(ctx context.Context) string {
+	return db.names.ForTenant(reqctx.FromContext(ctx).TenantID)
+}
+
+// database returns the database of the tenant in the context.
+func (db *DataStoreMongo) database(ctx context.Context) *mongo.Database {
+	return db.client.Database(db.dbName(ctx))
+}
@@ -0,0 +1,64 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDbNames(t *testing.T) {
+	names := DbNames{}.WithDefaults()
+	assert.Equal(t, DefaultDbNames(), names)
+	assert.NoError(t, names.Validate())
+	assert.Equal(t, DbName, names.ForTenant(""))
+	assert.Equal(t, DbName+"-tenant", names.ForTenant("tenant"))
+	assert.True(t, names.IsTenantDb(DbName+"-tenant"))
+	assert.False(t, names.IsTenantDb(DbName))
+	assert.Equal(t, "tenant", names.TenantFromDb(DbName+"-tenant"))
+	assert.Equal(t, "", names.TenantFromDb(DbName))
+
+	names = DbNames{Database: "staging-inventory"}.WithDefaults()
+	assert.Equal(t, DbNames{
+		Database:     "staging-inventory",
+		TenantPrefix: "staging-inventory-",
+		Devices:      DbDevicesColl,
+	}, names)
+	assert.Equal(t, "staging-inventory-tenant", names.ForTenant("tenant"))
+	assert.False(t, names.IsTenantDb(DbName+"-tenant"))
+	assert.Equal(t, "", names.TenantFromDb(DbName+"-tenant"))
+
+	names = DbNames{
+		Database:     "inventory",
+		TenantPrefix: "tenant_",
+		Devices:      "staging_devices",
+	}.WithDefaults()
+	assert.Equal(t, "tenant_foo", names.ForTenant("foo"))
+	assert.Equal(t, "foo", names.TenantFromDb("tenant_foo"))
+	assert.Equal(t, "staging_devices", names.Devices)
+
+	names.TenantPrefix = names.Database
+	assert.EqualError(t, names.Validate(),
+		"tenant database prefix must differ from the database name")
+}
+
+func TestNewDataStoreMongoDbNames(t *testing.T) {
+	_, err := NewDataStoreMongo(DataStoreMongoConfig{
+		DbNames: DbNames{Database: "inventory", TenantPrefix: "inventory"},
+	})
+	assert.EqualError(t, err,
+		"tenant database prefix must differ from the database name")
+}
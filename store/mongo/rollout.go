@@ -0,0 +1,356 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	mopts "go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+)
+
+const (
+	// DbDevGroups is the new format of the group membership: an array of
+	// group names, in place of the scalar group attribute.
+	DbDevGroups = "groups"
+
+	// DbRolloutsColl records the state of the rollouts of the new document
+	// formats; it lives in the database used without a tenant.
+	DbRolloutsColl = "schema_rollouts"
+	DbRolloutPhase = "phase"
+	DbRolloutTs    = "ts"
+
+	// RolloutGroups identifies the rollout of the groups array.
+	RolloutGroups = "groups"
+
+	// rolloutRefreshInterval is how long the phase of a rollout is cached.
+	rolloutRefreshInterval = 30 * time.Second
+	// rolloutFetchTimeout is the timeout of the fetch of the phase.
+	rolloutFetchTimeout = 10 * time.Second
+)
+
+// RolloutPhase is the phase of the rollout of a new document format, during
+// which the old and the new formats coexist.
+type RolloutPhase string
+
+const (
+	// RolloutOff writes and reads the old format only.
+	RolloutOff RolloutPhase = "off"
+	// RolloutDualWrite writes both formats and reads the old one; the
+	// documents written before are converted by the backfill.
+	RolloutDualWrite RolloutPhase = "dual_write"
+	// RolloutDualRead writes both formats and reads the new one; it is
+	// entered with the cutover, once the backfill is complete.
+	RolloutDualRead RolloutPhase = "dual_read"
+)
+
+// rollout caches the phase of a rollout recorded in the database. A stale
+// phase is refreshed in the background, so that the requests never wait
+// for the database; only the first fetch is done in the foreground. A failed
+// fetch is retried after the refresh interval.
+type rollout struct {
+	// enabled is the feature flag enabling the dual-write
+	enabled bool
+
+	mu         sync.Mutex
+	cutover    bool
+	fetchedAt  time.Time
+	refreshing bool
+}
+
+// rolloutFetcher reads the phase of a rollout from the database.
+type rolloutFetcher func(ctx context.Context) (RolloutPhase, error)
+
+// isCutover returns true if the cutover of the rollout is recorded,
+// refreshing the cached phase if it is stale.
+func (r *rollout) isCutover(fetch rolloutFetcher) bool {
+	r.mu.Lock()
+	stale := !r.refreshing && time.Since(r.fetchedAt) > rolloutRefreshInterval
+	initial := r.fetchedAt.IsZero()
+	if stale {
+		r.refreshing = true
+	}
+	r.mu.Unlock()
+
+	if stale && initial {
+		r.refresh(fetch)
+	} else if stale {
+		go r.refresh(fetch)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cutover
+}
+
+// refresh fetches the phase of the rollout; the cached phase is kept if
+// the fetch fails.
+func (r *rollout) refresh(fetch rolloutFetcher) {
+	ctx, cancel := context.WithTimeout(context.Background(), rolloutFetchTimeout)
+	defer cancel()
+	phase, err := fetch(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.refreshing = false
+	r.fetchedAt = time.Now()
+	if err != nil {
+		log.NewEmpty().Errorf("failed to refresh the rollout phase: %s",
+			err.Error())
+		return
+	}
+	r.cutover = phase == RolloutDualRead
+}
+
+// GroupsRolloutPhase returns the current phase of the rollout of the groups
+// array.
+func (db *DataStoreMongo) GroupsRolloutPhase(ctx context.Context) RolloutPhase {
+	r := db.groupsRollout
+	if r == nil || !r.enabled {
+		return RolloutOff
+	}
+	if r.isCutover(db.fetchGroupsRolloutPhase) {
+		return RolloutDualRead
+	}
+	return RolloutDualWrite
+}
+
+// fetchGroupsRolloutPhase reads the phase of the rollout of the groups
+// array recorded in the database; the cutover is the only phase recorded.
+func (db *DataStoreMongo) fetchGroupsRolloutPhase(
+	ctx context.Context,
+) (RolloutPhase, error) {
+	var doc struct {
+		Phase RolloutPhase `bson:"phase"`
+	}
+	err := db.client.Database(db.names.Database).
+		Collection(DbRolloutsColl).
+		FindOne(ctx, bson.M{DbDevId: RolloutGroups}).
+		Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return RolloutDualWrite, nil
+	} else if err != nil {
+		return "", err
+	}
+	return doc.Phase, nil
+}
+
+// CutoverGroups records the cutover to reading the groups array; it is
+// picked up by all the instances within the refresh interval.
+func (db *DataStoreMongo) CutoverGroups(ctx context.Context) error {
+	if db.GroupsRolloutPhase(ctx) == RolloutOff {
+		return store.ErrRolloutDisabled
+	}
+	_, err := db.client.Database(db.names.Database).
+		Collection(DbRolloutsColl).
+		ReplaceOne(ctx,
+			bson.M{DbDevId: RolloutGroups},
+			bson.M{
+				DbRolloutPhase: RolloutDualRead,
+				DbRolloutTs:    time.Now(),
+			},
+			mopts.Replace().SetUpsert(true),
+		)
+	if err != nil {
+		return errors.Wrap(err, "failed to record the cutover")
+	}
+	r := db.groupsRollout
+	r.mu.Lock()
+	r.cutover = true
+	r.fetchedAt = time.Now()
+	r.mu.Unlock()
+	return nil
+}
+
+// groupsField returns the field the group membership is read from.
+func (db *DataStoreMongo) groupsField(ctx context.Context) string {
+	if db.GroupsRolloutPhase(ctx) == RolloutDualRead {
+		return DbDevGroups
+	}
+	return DbDevAttributesGroupValue
+}
+
+// dualWriteGroups mirrors the changes of the group attribute in the set
+// and the unset documents of an update to the groups array.
+func (db *DataStoreMongo) dualWriteGroups(ctx context.Context, set, unset bson.M) {
+	if db.GroupsRolloutPhase(ctx) == RolloutOff {
+		return
+	}
+	if group, ok := set[DbDevAttributesGroupValue]; ok {
+		set[DbDevGroups] = bson.A{group}
+	} else if group, ok := set[DbDevAttributesGroup].(model.DeviceAttribute); ok {
+		set[DbDevGroups] = bson.A{group.Value}
+	}
+	if _, ok := unset[DbDevAttributesGroup]; ok {
+		unset[DbDevGroups] = ""
+	}
+}
+
+// groupAttrArrayExpr evaluates to the group attribute as an array holding
+// at most the one group.
+var groupAttrArrayExpr = bson.M{"$cond": bson.A{
+	bson.M{"$eq": bson.A{
+		bson.M{"$type": "$" + DbDevAttributesGroupValue}, "string",
+	}},
+	bson.A{"$" + DbDevAttributesGroupValue},
+	bson.A{},
+}}
+
+// groupsArrayExpr evaluates to the groups of the device: the groups array,
+// unless the group attribute was changed without it, e.g. by an instance
+// with the rollout disabled, in which case the attribute prevails.
+var groupsArrayExpr = bson.M{"$cond": bson.A{
+	bson.M{"$and": bson.A{
+		bson.M{"$isArray": "$" + DbDevGroups},
+		bson.M{"$eq": bson.A{
+			bson.M{"$arrayElemAt": bson.A{"$" + DbDevGroups, 0}},
+			"$" + DbDevAttributesGroupValue,
+		}},
+	}},
+	"$" + DbDevGroups,
+	groupAttrArrayExpr,
+}}
+
+// groupsUpdate returns the update setting the groups of the devices to
+// the value of the expression, in which $$groups are the current groups of
+// the device. The group attribute, read by the older instances and before
+// the cutover, holds the first of the groups; the groups array, written
+// unless the rollout is disabled, holds all of them. Both are removed from
+// the devices left without groups.
+func (db *DataStoreMongo) groupsUpdate(
+	ctx context.Context,
+	expr interface{},
+) mongo.Pipeline {
+	dualWrite := db.GroupsRolloutPhase(ctx) != RolloutOff
+	current := interface{}(groupAttrArrayExpr)
+	if dualWrite {
+		current = groupsArrayExpr
+	}
+	groups := bson.M{"$let": bson.M{
+		"vars": bson.M{"groups": current},
+		"in":   expr,
+	}}
+	first := bson.M{"$arrayElemAt": bson.A{groups, 0}}
+	none := bson.M{"$eq": bson.A{bson.M{"$size": groups}, 0}}
+
+	set := bson.M{
+		// the attribute is kept as is if its group does not change
+		DbDevAttributesGroup: bson.M{"$switch": bson.M{
+			"branches": bson.A{
+				bson.M{"case": none, "then": "$$REMOVE"},
+				bson.M{
+					"case": bson.M{"$eq": bson.A{
+						first, "$" + DbDevAttributesGroupValue,
+					}},
+					"then": "$" + DbDevAttributesGroup,
+				},
+			},
+			"default": bson.D{
+				{Key: DbDevAttributesName, Value: model.AttrNameGroup},
+				{Key: DbDevAttributesValue, Value: first},
+				{Key: DbDevAttributesScope, Value: model.AttrScopeSystem},
+			},
+		}},
+	}
+	if dualWrite {
+		set[DbDevGroups] = bson.M{"$cond": bson.A{none, "$$REMOVE", groups}}
+	}
+	return mongo.Pipeline{{{Key: "$set", Value: set}}}
+}
+
+// addGroupExpr evaluates to the groups of the device, $$groups, with
+// the group appended unless the device is already a member.
+func addGroupExpr(group model.GroupName) bson.M {
+	return bson.M{"$concatArrays": bson.A{
+		"$$groups",
+		bson.M{"$cond": bson.A{
+			bson.M{"$in": bson.A{group, "$$groups"}},
+			bson.A{},
+			bson.A{group},
+		}},
+	}}
+}
+
+// removeGroupExpr evaluates to the groups of the device, $$groups,
+// without the group.
+func removeGroupExpr(group model.GroupName) bson.M {
+	return bson.M{"$filter": bson.M{
+		"input": "$$groups",
+		"cond":  bson.M{"$ne": bson.A{"$$this", group}},
+	}}
+}
+
+// divergingGroupsFilters match the devices whose groups array does not
+// reflect the group attribute, the first of their groups.
+var divergingGroupsFilters = []bson.M{
+	{
+		DbDevAttributesGroupValue: bson.M{"$exists": true},
+		"$expr": bson.M{"$ne": bson.A{
+			bson.M{"$arrayElemAt": bson.A{"$" + DbDevGroups, 0}},
+			"$" + DbDevAttributesGroupValue,
+		}},
+	},
+	{
+		DbDevAttributesGroupValue: bson.M{"$exists": false},
+		DbDevGroups:               bson.M{"$exists": true},
+	},
+}
+
+// BackfillGroups converts the group attribute of the devices of the tenant
+// written before the dual-write was enabled to the groups array.
+func (db *DataStoreMongo) BackfillGroups(ctx context.Context) (*model.UpdateResult, error) {
+	if db.GroupsRolloutPhase(ctx) == RolloutOff {
+		return nil, store.ErrRolloutDisabled
+	}
+	c := db.database(ctx).Collection(db.names.Devices)
+
+	set, err := c.UpdateMany(ctx, divergingGroupsFilters[0], mongo.Pipeline{{
+		{Key: "$set", Value: bson.M{
+			DbDevGroups: bson.A{"$" + DbDevAttributesGroupValue},
+		}},
+	}})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to backfill groups")
+	}
+	unset, err := c.UpdateMany(ctx, divergingGroupsFilters[1], bson.M{
+		"$unset": bson.M{DbDevGroups: ""},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to backfill groups")
+	}
+	return &model.UpdateResult{
+		MatchedCount: set.MatchedCount + unset.MatchedCount,
+		UpdatedCount: set.ModifiedCount + unset.ModifiedCount,
+	}, nil
+}
+
+// CountDivergingGroups returns the number of devices of the tenant whose
+// groups array does not reflect the group attribute.
+func (db *DataStoreMongo) CountDivergingGroups(ctx context.Context) (int64, error) {
+	c := db.database(ctx).Collection(db.names.Devices)
+	n, err := c.CountDocuments(ctx, bson.M{"$or": divergingGroupsFilters})
+	if err != nil {
+		return -1, errors.Wrap(err, "failed to count devices")
+	}
+	return n, nil
+}
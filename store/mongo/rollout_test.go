@@ -0,0 +1,243 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+)
+
+func TestDualWriteGroups(t *testing.T) {
+	ctx := context.Background()
+	group := model.DeviceAttribute{
+		Scope: model.AttrScopeSystem,
+		Name:  DbDevGroup,
+		Value: model.GroupName("foo"),
+	}
+
+	db := &DataStoreMongo{}
+	assert.Equal(t, RolloutOff, db.GroupsRolloutPhase(ctx))
+	assert.Equal(t, DbDevAttributesGroupValue, db.groupsField(ctx))
+	set := bson.M{DbDevAttributesGroupValue: "foo"}
+	db.dualWriteGroups(ctx, set, nil)
+	assert.Equal(t, bson.M{DbDevAttributesGroupValue: "foo"}, set)
+
+	// the devices are moved to the group, the only one of the attribute
+	update := db.addGroupUpdate(ctx, "foo")
+	if assert.Len(t, update, 1) {
+		set := update[0][0].Value.(bson.M)
+		assert.Contains(t, set, DbDevAttributesGroup)
+		assert.NotContains(t, set, DbDevGroups)
+	}
+
+	// the phase is cached, the database is not queried
+	db.groupsRollout = &rollout{enabled: true, fetchedAt: time.Now()}
+	assert.Equal(t, RolloutDualWrite, db.GroupsRolloutPhase(ctx))
+	assert.Equal(t, DbDevAttributesGroupValue, db.groupsField(ctx))
+
+	set = bson.M{DbDevAttributesGroupValue: "foo"}
+	db.dualWriteGroups(ctx, set, nil)
+	assert.Equal(t, bson.M{
+		DbDevAttributesGroupValue: "foo",
+		DbDevGroups:               bson.A{"foo"},
+	}, set)
+
+	set = bson.M{DbDevAttributesGroup: group}
+	db.dualWriteGroups(ctx, set, nil)
+	assert.Equal(t, bson.A{model.GroupName("foo")}, set[DbDevGroups])
+
+	update = db.addGroupUpdate(ctx, "foo")
+	if assert.Len(t, update, 1) {
+		set := update[0][0].Value.(bson.M)
+		assert.Contains(t, set, DbDevAttributesGroup)
+		assert.Contains(t, set, DbDevGroups)
+	}
+
+	unset := bson.M{DbDevAttributesGroup: ""}
+	db.dualWriteGroups(ctx, nil, unset)
+	assert.Equal(t, bson.M{
+		DbDevAttributesGroup: "",
+		DbDevGroups:          "",
+	}, unset)
+
+	set, unset = bson.M{"attributes.inventory-foo.value": "bar"}, bson.M{}
+	db.dualWriteGroups(ctx, set, unset)
+	assert.NotContains(t, set, DbDevGroups)
+	assert.Empty(t, unset)
+
+	db.groupsRollout.cutover = true
+	assert.Equal(t, RolloutDualRead, db.GroupsRolloutPhase(ctx))
+	assert.Equal(t, DbDevGroups, db.groupsField(ctx))
+}
+
+func TestRolloutRefresh(t *testing.T) {
+	var (
+		fetches int32
+		phase   atomic.Value
+		release = make(chan struct{})
+	)
+	phase.Store(RolloutDualWrite)
+	fetch := func(ctx context.Context) (RolloutPhase, error) {
+		n := atomic.AddInt32(&fetches, 1)
+		switch n {
+		case 2:
+			return "", errors.New("primary unavailable")
+		case 3:
+			<-release
+		}
+		return phase.Load().(RolloutPhase), nil
+	}
+	r := &rollout{enabled: true}
+
+	// the first fetch is done in the foreground
+	assert.False(t, r.isCutover(fetch))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+	assert.False(t, r.isCutover(fetch))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+
+	// a failed refresh keeps the cached phase and backs off
+	r.mu.Lock()
+	r.fetchedAt = r.fetchedAt.Add(-2 * rolloutRefreshInterval)
+	r.mu.Unlock()
+	phase.Store(RolloutDualRead)
+	assert.False(t, r.isCutover(fetch))
+	assert.Eventually(t, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return !r.refreshing
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetches))
+	assert.False(t, r.isCutover(fetch))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetches))
+
+	// the callers do not wait for a slow refresh, which runs once
+	r.mu.Lock()
+	r.fetchedAt = r.fetchedAt.Add(-2 * rolloutRefreshInterval)
+	r.mu.Unlock()
+	assert.False(t, r.isCutover(fetch))
+	assert.False(t, r.isCutover(fetch))
+	close(release)
+	assert.Eventually(t, func() bool {
+		return r.isCutover(fetch)
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&fetches))
+}
+
+func TestMongoGroupsRollout(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoGroupsRollout in short mode.")
+	}
+
+	db.Wipe()
+	client := db.Client()
+	ctx := db.CTX()
+
+	legacy := NewDataStoreMongoWithSession(client)
+	_, err := legacy.UpdateDevicesGroup(ctx,
+		[]model.DeviceID{"1", "2"}, model.GroupName("foo"))
+	assert.NoError(t, err)
+
+	ds := &DataStoreMongo{
+		client:        client,
+		names:         DefaultDbNames(),
+		groupsRollout: &rollout{enabled: true},
+	}
+	_, err = ds.UpdateDevicesGroup(ctx,
+		[]model.DeviceID{"3"}, model.GroupName("bar"))
+	assert.NoError(t, err)
+
+	n, err := ds.CountDivergingGroups(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	res, err := ds.BackfillGroups(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), res.UpdatedCount)
+
+	n, err = ds.CountDivergingGroups(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+
+	_, err = ds.UnsetDevicesGroup(ctx,
+		[]model.DeviceID{"1"}, model.GroupName("foo"))
+	assert.NoError(t, err)
+	n, err = ds.CountDivergingGroups(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+
+	// the group attribute keeps the first group, read until the cutover
+	_, err = ds.UpdateDevicesGroup(ctx,
+		[]model.DeviceID{"2"}, model.GroupName("bar"))
+	assert.NoError(t, err)
+	groups, err := ds.GetDeviceGroups(ctx, "2")
+	assert.NoError(t, err)
+	assert.Equal(t, []model.GroupName{"foo"}, groups)
+	n, err = ds.CountDivergingGroups(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+
+	// an instance with the rollout disabled moves the devices
+	_, err = legacy.UpdateDevicesGroup(ctx,
+		[]model.DeviceID{"3"}, model.GroupName("baz"))
+	assert.NoError(t, err)
+	n, err = ds.CountDivergingGroups(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	_, err = ds.BackfillGroups(ctx)
+	assert.NoError(t, err)
+
+	assert.NoError(t, ds.CutoverGroups(ctx))
+	assert.Equal(t, RolloutDualRead, ds.GroupsRolloutPhase(ctx))
+
+	// another instance picks up the cutover from the database
+	other := &DataStoreMongo{
+		client:        client,
+		names:         DefaultDbNames(),
+		groupsRollout: &rollout{enabled: true},
+	}
+	assert.Equal(t, RolloutDualRead, other.GroupsRolloutPhase(ctx))
+
+	groups, err = other.ListGroups(ctx, nil)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t,
+		[]model.GroupName{"foo", "bar", "baz"}, groups)
+	ids, total, err := other.GetDevicesByGroup(ctx, "foo", store.ListQuery{Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, []model.DeviceID{"2"}, ids)
+	groups, err = other.GetDeviceGroups(ctx, "2")
+	assert.NoError(t, err)
+	assert.Equal(t, []model.GroupName{"foo", "bar"}, groups)
+
+	// leaving the first group moves the next one to the group attribute
+	_, err = other.UnsetDevicesGroup(ctx,
+		[]model.DeviceID{"2"}, model.GroupName("foo"))
+	assert.NoError(t, err)
+	groups, err = legacy.GetDeviceGroups(ctx, "2")
+	assert.NoError(t, err)
+	assert.Equal(t, []model.GroupName{"bar"}, groups)
+
+	_, err = legacy.BackfillGroups(ctx)
+	assert.Equal(t, store.ErrRolloutDisabled, err)
+}
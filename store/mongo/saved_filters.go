@@ -0,0 +1,127 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	mopts "go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+)
+
+const (
+	DbSavedFiltersColl   = "saved_filters"
+	DbSavedFilterName    = "name"
+	DbSavedFilterOwnerID = "owner_id"
+	DbSavedFilterShared  = "shared"
+)
+
+func (db *DataStoreMongo) GetSavedFilters(
+	ctx context.Context,
+	q store.SavedFiltersQuery,
+) ([]model.SavedFilter, error) {
+	c := db.database(ctx).
+		Collection(DbSavedFiltersColl)
+
+	filter := bson.M{}
+	if q.OwnerID != "" {
+		filter[DbSavedFilterOwnerID] = q.OwnerID
+	}
+	if q.Shared != nil {
+		filter[DbSavedFilterShared] = *q.Shared
+	}
+	cur, err := db.find(ctx, c, filter,
+		mopts.Find().SetSort(bson.D{
+			{Key: DbSavedFilterName, Value: 1},
+			{Key: DbDevId, Value: 1},
+		}),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get saved filters")
+	}
+	filters := []model.SavedFilter{}
+	if err = decodeAll(ctx, cur, &filters); err != nil {
+		return nil, errors.Wrap(err, "failed to get saved filters")
+	}
+	return filters, nil
+}
+
+func (db *DataStoreMongo) GetSavedFilter(
+	ctx context.Context,
+	id string,
+) (*model.SavedFilter, error) {
+	c := db.database(ctx).
+		Collection(DbSavedFiltersColl)
+
+	var filter model.SavedFilter
+	err := c.FindOne(ctx, bson.M{DbDevId: id}).Decode(&filter)
+	if err == mongo.ErrNoDocuments {
+		return nil, store.ErrSavedFilterNotFound
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to get saved filter")
+	}
+	return &filter, nil
+}
+
+func (db *DataStoreMongo) CreateSavedFilter(
+	ctx context.Context,
+	filter model.SavedFilter,
+) error {
+	c := db.database(ctx).
+		Collection(DbSavedFiltersColl)
+
+	if _, err := c.InsertOne(ctx, filter); err != nil {
+		return errors.Wrap(err, "failed to store saved filter")
+	}
+	return nil
+}
+
+func (db *DataStoreMongo) UpsertSavedFilter(
+	ctx context.Context,
+	filter model.SavedFilter,
+) error {
+	c := db.database(ctx).
+		Collection(DbSavedFiltersColl)
+
+	_, err := c.ReplaceOne(ctx,
+		bson.M{DbDevId: filter.ID, DbSavedFilterOwnerID: filter.OwnerID},
+		filter,
+		mopts.Replace().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return store.ErrWriteConflict
+	} else if err != nil {
+		return errors.Wrap(err, "failed to store saved filter")
+	}
+	return nil
+}
+
+func (db *DataStoreMongo) DeleteSavedFilter(ctx context.Context, id string) error {
+	c := db.database(ctx).
+		Collection(DbSavedFiltersColl)
+
+	res, err := c.DeleteOne(ctx, bson.M{DbDevId: id})
+	if err != nil {
+		return errors.Wrap(err, "failed to remove saved filter")
+	} else if res.DeletedCount == 0 {
+		return store.ErrSavedFilterNotFound
+	}
+	return nil
+}
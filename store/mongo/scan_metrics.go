@@ -0,0 +1,161 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+
+	"github.com/mendersoftware/inventory/metrics"
+	"github.com/mendersoftware/inventory/model"
+)
+
+const (
+	// searchExplainTimeout bounds the explain of a sampled search.
+	searchExplainTimeout = 30 * time.Second
+	// collScanWarnDocs is the number of documents examined by a sampled
+	// search scanning the whole collection above which a warning is logged.
+	collScanWarnDocs = 10000
+)
+
+var (
+	searchSamples = metrics.NewCounterVec(
+		"inventory_mongo_search_samples_total",
+		"Number of device searches sampled with explain.",
+		"shape",
+	)
+	searchDocsExamined = metrics.NewCounterVec(
+		"inventory_mongo_search_docs_examined_total",
+		"Number of documents examined by the sampled device searches.",
+		"shape",
+	)
+	searchDocsReturned = metrics.NewCounterVec(
+		"inventory_mongo_search_docs_returned_total",
+		"Number of documents returned by the sampled device searches.",
+		"shape",
+	)
+	searchCollectionScans = metrics.NewCounterVec(
+		"inventory_mongo_search_collection_scans_total",
+		"Number of sampled device searches scanning the whole collection.",
+		"shape",
+	)
+	searchScanRatio = metrics.NewGaugeVec(
+		"inventory_mongo_search_scan_ratio",
+		"Documents examined per document returned by the last sampled device search.",
+		"shape",
+	)
+)
+
+// searchSampler selects one in every given number of device searches to
+// be explained.
+type searchSampler struct {
+	every uint64
+	count uint64
+}
+
+func newSearchSampler(every int) *searchSampler {
+	if every <= 0 {
+		return nil
+	}
+	return &searchSampler{every: uint64(every)}
+}
+
+func (s *searchSampler) sample() bool {
+	if s == nil {
+		return false
+	}
+	return atomic.AddUint64(&s.count, 1)%s.every == 0
+}
+
+// searchShape identifies the device searches which differ by the values
+// they filter on only, for these share the same query plan.
+func searchShape(searchParams model.SearchParams) string {
+	filters := make([]string, 0, len(searchParams.Filters))
+	for _, f := range searchParams.Filters {
+		filters = append(filters,
+			fmt.Sprintf("%s/%s:%s", f.Scope, f.Attribute, f.Type))
+	}
+	sort.Strings(filters)
+	if len(searchParams.DeviceIDs) > 0 {
+		filters = append([]string{"ids"}, filters...)
+	}
+	shape := strings.Join(filters, ",")
+	if shape == "" {
+		shape = "all"
+	}
+	if len(searchParams.Sort) > 0 {
+		sorts := make([]string, len(searchParams.Sort))
+		for i, s := range searchParams.Sort {
+			sorts[i] = fmt.Sprintf("%s/%s:%s", s.Scope, s.Attribute, s.Order)
+		}
+		shape += " sort " + strings.Join(sorts, ",")
+	}
+	return shape
+}
+
+// sampleSearch explains the device search in the background and records
+// the scan metrics, if the search is sampled.
+func (db *DataStoreMongo) sampleSearch(
+	ctx context.Context,
+	searchParams model.SearchParams,
+) {
+	if !db.searchSampler.sample() {
+		return
+	}
+	// the search context ends with the request
+	ctx = identity.WithContext(context.Background(), identity.FromContext(ctx))
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, searchExplainTimeout)
+		defer cancel()
+		db.recordSearchPlan(ctx, searchParams)
+	}()
+}
+
+func (db *DataStoreMongo) recordSearchPlan(
+	ctx context.Context,
+	searchParams model.SearchParams,
+) {
+	l := log.FromContext(ctx)
+	plan, err := db.ExplainSearchDevices(ctx, searchParams)
+	if err != nil {
+		l.Warnf("failed to sample the device search: %v", err)
+		return
+	}
+
+	shape := searchShape(searchParams)
+	searchSamples.Inc(shape)
+	searchDocsExamined.Add(float64(plan.DocsExamined), shape)
+	searchDocsReturned.Add(float64(plan.Returned), shape)
+	ratio := float64(plan.DocsExamined)
+	if plan.Returned > 0 {
+		ratio /= float64(plan.Returned)
+	}
+	searchScanRatio.Set(ratio, shape)
+	if plan.CollectionScan {
+		searchCollectionScans.Inc(shape)
+		if plan.DocsExamined >= collScanWarnDocs {
+			l.Warnf("device search %q in db %s scans the whole collection: "+
+				"%d documents examined, %d returned",
+				shape, db.dbName(ctx), plan.DocsExamined, plan.Returned)
+		}
+	}
+}
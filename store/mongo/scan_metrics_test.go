@@ -0,0 +1,113 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/inventory/metrics"
+	"github.com/mendersoftware/inventory/model"
+)
+
+func TestSearchShape(t *testing.T) {
+	testCases := map[string]struct {
+		params model.SearchParams
+		shape  string
+	}{
+		"all": {
+			shape: "all",
+		},
+		"filters and sort": {
+			params: model.SearchParams{
+				Filters: []model.FilterPredicate{
+					{Scope: "system", Attribute: "group", Type: "$eq", Value: "dev"},
+					{Scope: "inventory", Attribute: "mac", Type: "$in", Value: []string{"a"}},
+				},
+				Sort: []model.SortCriteria{
+					{Scope: "system", Attribute: "updated_ts", Order: "desc"},
+				},
+			},
+			shape: "inventory/mac:$in,system/group:$eq sort system/updated_ts:desc",
+		},
+		"device IDs": {
+			params: model.SearchParams{
+				DeviceIDs: []string{"1", "2"},
+				Filters: []model.FilterPredicate{
+					{Scope: "system", Attribute: "group", Type: "$eq", Value: "prod"},
+				},
+			},
+			shape: "ids,system/group:$eq",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.shape, searchShape(tc.params))
+		})
+	}
+}
+
+func TestSearchSampler(t *testing.T) {
+	assert.Nil(t, newSearchSampler(0))
+	assert.False(t, newSearchSampler(0).sample())
+
+	s := newSearchSampler(3)
+	sampled := 0
+	for i := 0; i < 9; i++ {
+		if s.sample() {
+			sampled++
+		}
+	}
+	assert.Equal(t, 3, sampled)
+}
+
+func TestMongoRecordSearchPlan(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoRecordSearchPlan in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client()).(*DataStoreMongo)
+	ctx := db.CTX()
+
+	for _, dev := range []model.Device{{ID: "1"}, {ID: "2", Group: "dev"}} {
+		dev := dev
+		err := ds.AddDevice(ctx, &dev)
+		assert.NoError(t, err, "failed to setup input data")
+	}
+
+	params := model.SearchParams{
+		Page:    1,
+		PerPage: 10,
+		Filters: []model.FilterPredicate{{
+			Scope:     model.AttrScopeSystem,
+			Attribute: model.AttrNameGroup,
+			Type:      "$eq",
+			Value:     "dev",
+		}},
+	}
+	shape := searchShape(params)
+	samples := metrics.Value("inventory_mongo_search_samples_total", shape)
+	scans := metrics.Value("inventory_mongo_search_collection_scans_total", shape)
+
+	ds.recordSearchPlan(ctx, params)
+	assert.Equal(t, samples+1,
+		metrics.Value("inventory_mongo_search_samples_total", shape))
+	assert.Equal(t, scans+1,
+		metrics.Value("inventory_mongo_search_collection_scans_total", shape))
+	assert.Equal(t, float64(2),
+		metrics.Value("inventory_mongo_search_scan_ratio", shape))
+}
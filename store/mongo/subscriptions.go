@@ -0,0 +1,273 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	mopts "go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store"
+)
+
+const (
+	DbSubscriptionsColl       = "subscriptions"
+	DbSubscriptionMatchesColl = "subscription_matches"
+	DbSubscriptionUserID      = "user_id"
+	DbSubscriptionCreatedTs   = "created_ts"
+	DbSubscriptionMatchSub    = DbDevId + ".subscription"
+
+	// DbChangeStreamsColl holds the resume tokens of the change streams;
+	// it lives in the database used without a tenant.
+	DbChangeStreamsColl = "change_streams"
+	DbChangeStreamToken = "token"
+	changeStreamDevices = "devices"
+)
+
+type subscriptionMatchID struct {
+	Subscription string         `bson:"subscription"`
+	Device       model.DeviceID `bson:"device"`
+}
+
+func (db *DataStoreMongo) GetSubscriptions(
+	ctx context.Context,
+	userID string,
+) ([]model.Subscription, error) {
+	c := db.database(ctx).
+		Collection(DbSubscriptionsColl)
+
+	filter := bson.M{}
+	if userID != "" {
+		filter[DbSubscriptionUserID] = userID
+	}
+	cur, err := db.find(ctx, c, filter,
+		mopts.Find().SetSort(bson.D{{Key: DbSubscriptionCreatedTs, Value: 1}}),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get subscriptions")
+	}
+	subs := []model.Subscription{}
+	if err = decodeAll(ctx, cur, &subs); err != nil {
+		return nil, errors.Wrap(err, "failed to get subscriptions")
+	}
+	return subs, nil
+}
+
+func (db *DataStoreMongo) CreateSubscription(
+	ctx context.Context,
+	sub model.Subscription,
+) error {
+	c := db.database(ctx).
+		Collection(DbSubscriptionsColl)
+
+	if _, err := c.InsertOne(ctx, sub); err != nil {
+		return errors.Wrap(err, "failed to store subscription")
+	}
+	return nil
+}
+
+func (db *DataStoreMongo) UpsertSubscription(
+	ctx context.Context,
+	sub model.Subscription,
+) error {
+	database := db.database(ctx)
+
+	previous, err := database.Collection(DbSubscriptionsColl).
+		FindOneAndReplace(ctx,
+			bson.M{DbDevId: sub.ID, DbSubscriptionUserID: sub.UserID},
+			sub,
+			mopts.FindOneAndReplace().
+				SetUpsert(true).
+				SetReturnDocument(mopts.Before),
+		).DecodeBytes()
+	if err == mongo.ErrNoDocuments {
+		return nil
+	} else if mongo.IsDuplicateKeyError(err) {
+		return store.ErrWriteConflict
+	} else if err != nil {
+		return errors.Wrap(err, "failed to store subscription")
+	}
+
+	// the matches of the previous filters would hold back the
+	// notifications of the devices matching the new ones
+	current, err := bson.Marshal(sub)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode subscription")
+	}
+	if bytes.Equal(previous.Lookup("filters").Value,
+		bson.Raw(current).Lookup("filters").Value) {
+		return nil
+	}
+	_, err = database.Collection(DbSubscriptionMatchesColl).
+		DeleteMany(ctx, bson.M{DbSubscriptionMatchSub: sub.ID})
+	if err != nil {
+		return errors.Wrap(err, "failed to remove subscription matches")
+	}
+	return nil
+}
+
+func (db *DataStoreMongo) DeleteSubscription(
+	ctx context.Context,
+	userID, id string,
+) error {
+	database := db.database(ctx)
+
+	res, err := database.Collection(DbSubscriptionsColl).
+		DeleteOne(ctx, bson.M{DbDevId: id, DbSubscriptionUserID: userID})
+	if err != nil {
+		return errors.Wrap(err, "failed to remove subscription")
+	} else if res.DeletedCount == 0 {
+		return store.ErrSubscriptionNotFound
+	}
+	_, err = database.Collection(DbSubscriptionMatchesColl).
+		DeleteMany(ctx, bson.M{DbSubscriptionMatchSub: id})
+	if err != nil {
+		return errors.Wrap(err, "failed to remove subscription matches")
+	}
+	return nil
+}
+
+func (db *DataStoreMongo) DeviceMatches(
+	ctx context.Context,
+	id model.DeviceID,
+	filters []model.FilterPredicate,
+) (bool, error) {
+	c := db.database(ctx).Collection(db.names.Devices)
+
+	query := append(filterPredicatesQuery(filters), bson.M{DbDevId: id})
+	n, err := c.CountDocuments(ctx, bson.M{"$and": query})
+	if err != nil {
+		return false, errors.Wrap(err, "failed to match device")
+	}
+	return n > 0, nil
+}
+
+func (db *DataStoreMongo) SetSubscriptionMatch(
+	ctx context.Context,
+	subID string,
+	id model.DeviceID,
+	matches bool,
+) (bool, error) {
+	c := db.database(ctx).
+		Collection(DbSubscriptionMatchesColl)
+
+	filter := bson.M{DbDevId: subscriptionMatchID{
+		Subscription: subID,
+		Device:       id,
+	}}
+	if !matches {
+		_, err := c.DeleteOne(ctx, filter)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to record subscription match")
+		}
+		return false, nil
+	}
+	res, err := c.UpdateOne(ctx, filter,
+		bson.M{"$setOnInsert": filter},
+		mopts.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to record subscription match")
+	}
+	return res.UpsertedCount > 0, nil
+}
+
+type deviceChangeEvent struct {
+	ID            bson.Raw `bson:"_id"`
+	OperationType string   `bson:"operationType"`
+	NS            struct {
+		DB string `bson:"db"`
+	} `bson:"ns"`
+	DocumentKey struct {
+		ID model.DeviceID `bson:"_id"`
+	} `bson:"documentKey"`
+}
+
+func (db *DataStoreMongo) WatchDevices(
+	ctx context.Context,
+	handler store.DeviceChangeHandler,
+) error {
+	tokens := db.client.Database(db.names.Database).
+		Collection(DbChangeStreamsColl)
+
+	opts := mopts.ChangeStream()
+	var resume struct {
+		Token bson.Raw `bson:"token"`
+	}
+	err := tokens.FindOne(ctx, bson.M{DbDevId: changeStreamDevices}).
+		Decode(&resume)
+	if err == nil {
+		opts.SetResumeAfter(resume.Token)
+	} else if err != mongo.ErrNoDocuments {
+		return errors.Wrap(err, "failed to get the resume token")
+	}
+
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{
+		"ns.coll": db.names.Devices,
+		"operationType": bson.M{
+			"$in": bson.A{"insert", "update", "replace", "delete"},
+		},
+	}}}}
+	stream, err := db.client.Watch(ctx, pipeline, opts)
+	if err != nil {
+		return errors.Wrap(err, "failed to watch devices")
+	}
+	defer stream.Close(context.Background())
+
+	for stream.Next(ctx) {
+		var event deviceChangeEvent
+		if err := stream.Decode(&event); err != nil {
+			return errors.Wrap(err, "failed to decode device change")
+		}
+		tctx := ctx
+		if db.names.IsTenantDb(event.NS.DB) {
+			tctx = identity.WithContext(ctx, &identity.Identity{
+				Tenant: db.names.TenantFromDb(event.NS.DB),
+			})
+		} else if event.NS.DB != db.names.Database {
+			continue
+		}
+		change := model.DeviceChange{
+			DeviceID:  event.DocumentKey.ID,
+			Type:      model.DeviceChangeUpdated,
+			Timestamp: time.Now().UTC(),
+		}
+		if event.OperationType == "delete" {
+			change.Type = model.DeviceChangeDeleted
+		}
+		if err := handler(tctx, change); err != nil {
+			return err
+		}
+		_, err := tokens.ReplaceOne(ctx,
+			bson.M{DbDevId: changeStreamDevices},
+			bson.M{DbChangeStreamToken: event.ID},
+			mopts.Replace().SetUpsert(true),
+		)
+		if err != nil {
+			return errors.Wrap(err, "failed to store the resume token")
+		}
+	}
+	if err := stream.Err(); err != nil && ctx.Err() == nil {
+		return errors.Wrap(err, "failed to watch devices")
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,149 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+const (
+	DbDevTelemetry              = "telemetry"
+	DbDevTelemetryUpdates       = DbDevTelemetry + "." + model.AttrNameUpdates
+	DbDevTelemetryUpdatesPerDay = DbDevTelemetry + "." + model.AttrNameUpdatesPerDay
+	DbDevTelemetryLastUpdate    = DbDevTelemetry + "." + model.AttrNameLastUpdate
+	DbDevTelemetryIntervals     = DbDevTelemetry + ".intervals"
+)
+
+// withTelemetry returns the update of a single device recording the write
+// in the telemetry of the device as well, if enabled. The update is turned
+// into a pipeline update, so that the interval since the previous write is
+// computed in the same round-trip as the write of the attributes. The
+// writes of the management users, e.g. tags, are not recorded.
+func (db *DataStoreMongo) withTelemetry(
+	ctx context.Context,
+	update interface{},
+	ts time.Time,
+) interface{} {
+	if !db.telemetry ||
+		model.NewAttributeSource(ctx, ts).Type == model.SourceTypeUser {
+		return update
+	}
+	var pipeline mongo.Pipeline
+	switch u := update.(type) {
+	case mongo.Pipeline:
+		pipeline = u
+	case bson.M:
+		pipeline = pipelineUpdate(u)
+	default:
+		return update
+	}
+	return append(pipeline, telemetryStages(ts)...)
+}
+
+// pipelineUpdate converts an update made of $set, $setOnInsert and $unset
+// to the equivalent pipeline update: the fields set on insert are only
+// set if missing.
+func pipelineUpdate(update bson.M) mongo.Pipeline {
+	set := bson.M{}
+	// the values of a pipeline update are expressions: the strings
+	// starting with $ would be read as field paths
+	if fields, ok := update["$set"].(bson.M); ok {
+		for field, value := range fields {
+			set[field] = bson.M{"$literal": value}
+		}
+	}
+	if fields, ok := update["$setOnInsert"].(bson.M); ok {
+		for field, value := range fields {
+			set[field] = bson.M{"$ifNull": bson.A{
+				"$" + field, bson.M{"$literal": value},
+			}}
+		}
+	}
+	pipeline := mongo.Pipeline{{{Key: "$set", Value: set}}}
+	if fields, ok := update["$unset"].(bson.M); ok && len(fields) > 0 {
+		unset := make(bson.A, 0, len(fields))
+		for field := range fields {
+			unset = append(unset, field)
+		}
+		pipeline = append(pipeline, bson.D{{Key: "$unset", Value: unset}})
+	}
+	return pipeline
+}
+
+// telemetryStages returns the stages of a pipeline update counting a
+// write of the attributes of the device at the given time and appending
+// the interval since the previous one to the latest intervals.
+func telemetryStages(ts time.Time) mongo.Pipeline {
+	const (
+		updates   = "$" + DbDevTelemetryUpdates
+		last      = "$" + DbDevTelemetryLastUpdate
+		intervals = "$" + DbDevTelemetryIntervals
+	)
+	previous := bson.M{"$ifNull": bson.A{intervals, bson.A{}}}
+	// the clock of the replicas may go back a little: the intervals
+	// are never negative
+	interval := bson.M{"$max": bson.A{0, bson.M{
+		"$divide": bson.A{bson.M{"$subtract": bson.A{ts, last}}, 1000},
+	}}}
+	return mongo.Pipeline{{
+		{Key: "$set", Value: bson.M{
+			DbDevTelemetryUpdates: bson.M{
+				"$add": bson.A{bson.M{"$ifNull": bson.A{updates, 0}}, 1},
+			},
+			DbDevTelemetryLastUpdate: ts,
+			DbDevTelemetryIntervals: bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{bson.M{"$type": last}, "date"}},
+				bson.M{"$slice": bson.A{
+					bson.M{"$concatArrays": bson.A{previous, bson.A{interval}}},
+					-model.DeviceTelemetryIntervals,
+				}},
+				previous,
+			}},
+		}},
+	}, {
+		{Key: "$set", Value: bson.M{
+			DbDevTelemetryUpdatesPerDay: bson.M{"$cond": bson.A{
+				bson.M{"$gt": bson.A{bson.M{"$sum": intervals}, 0}},
+				bson.M{"$divide": bson.A{
+					bson.M{"$multiply": bson.A{
+						bson.M{"$size": intervals}, 24 * 60 * 60,
+					}},
+					bson.M{"$sum": intervals},
+				}},
+				0,
+			}},
+		}},
+	}}
+}
+
+// telemetryFilterValue returns the value of a predicate on a computed
+// attribute of the telemetry scope: the times are given as RFC3339
+// strings.
+func telemetryFilterValue(filter model.FilterPredicate) interface{} {
+	if filter.Attribute != model.AttrNameLastUpdate {
+		return filter.Value
+	}
+	if s, ok := filter.Value.(string); ok {
+		if ts, err := time.Parse(time.RFC3339, s); err == nil {
+			return ts
+		}
+	}
+	return filter.Value
+}
@@ -0,0 +1,188 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package mongo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+func TestTelemetryStages(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestTelemetryStages in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client()).(*DataStoreMongo)
+	ctx := db.CTX()
+
+	c := ds.database(ctx).Collection(ds.names.Devices)
+	_, err := c.InsertMany(ctx,
+		[]interface{}{bson.M{DbDevId: "1"}, bson.M{DbDevId: "2"}},
+	)
+	require.NoError(t, err)
+	record := func(id model.DeviceID, ts time.Time) {
+		_, err := c.UpdateOne(ctx, bson.M{DbDevId: id}, telemetryStages(ts))
+		require.NoError(t, err)
+	}
+
+	// device 1 writes every minute, more than the intervals kept
+	start := time.Now().UTC().Truncate(time.Millisecond).Add(-time.Hour)
+	n := model.DeviceTelemetryIntervals + 5
+	for k := 0; k <= n; k++ {
+		record("1", start.Add(time.Duration(k)*time.Minute))
+	}
+	// device 2 writes once
+	record("2", start)
+
+	dev, err := ds.GetDevice(ctx, "1")
+	require.NoError(t, err)
+	require.NotNil(t, dev.Telemetry)
+	assert.Equal(t, int64(n+1), dev.Telemetry.Updates)
+	assert.True(t, start.Add(time.Duration(n)*time.Minute).
+		Equal(*dev.Telemetry.LastUpdateTs))
+	assert.Len(t, dev.Telemetry.Intervals, model.DeviceTelemetryIntervals)
+	for _, interval := range dev.Telemetry.Intervals {
+		assert.Equal(t, float64(60), interval)
+	}
+	assert.Equal(t, float64(24*60), dev.Telemetry.UpdatesPerDay)
+
+	dev, err = ds.GetDevice(ctx, "2")
+	require.NoError(t, err)
+	require.NotNil(t, dev.Telemetry)
+	assert.Equal(t, int64(1), dev.Telemetry.Updates)
+	assert.Empty(t, dev.Telemetry.Intervals)
+	assert.Equal(t, float64(0), dev.Telemetry.UpdatesPerDay)
+
+	// the computed attributes filter the devices
+	for _, tc := range []struct {
+		filter   model.FilterPredicate
+		expected []model.DeviceID
+	}{{
+		filter: model.FilterPredicate{
+			Scope:     model.AttrScopeTelemetry,
+			Attribute: model.AttrNameUpdatesPerDay,
+			Type:      "$gt",
+			Value:     float64(100),
+		},
+		expected: []model.DeviceID{"1"},
+	}, {
+		filter: model.FilterPredicate{
+			Scope:     model.AttrScopeTelemetry,
+			Attribute: model.AttrNameUpdates,
+			Type:      "$lte",
+			Value:     float64(1),
+		},
+		expected: []model.DeviceID{"2"},
+	}, {
+		filter: model.FilterPredicate{
+			Scope:     model.AttrScopeTelemetry,
+			Attribute: model.AttrNameLastUpdate,
+			Type:      "$gt",
+			Value:     start.Format(time.RFC3339),
+		},
+		expected: []model.DeviceID{"1"},
+	}} {
+		devs, _, err := ds.SearchDevices(ctx, model.SearchParams{
+			Page:    1,
+			PerPage: 10,
+			Filters: []model.FilterPredicate{tc.filter},
+		})
+		assert.NoError(t, err)
+		ids := []model.DeviceID{}
+		for _, dev := range devs {
+			ids = append(ids, dev.ID)
+		}
+		assert.Equal(t, tc.expected, ids)
+	}
+}
+
+func TestMongoDeviceTelemetry(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMongoDeviceTelemetry in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithSession(db.Client()).(*DataStoreMongo)
+	ds.telemetry = true
+	devCtx := identity.WithContext(db.CTX(), &identity.Identity{
+		Subject:  "1",
+		IsDevice: true,
+	})
+	userCtx := identity.WithContext(db.CTX(), &identity.Identity{
+		Subject: "user",
+		IsUser:  true,
+	})
+	attrs := model.DeviceAttributes{
+		{Name: "mac", Value: "$00:01", Scope: model.AttrScopeInventory},
+	}
+
+	// the writes of the device are recorded with the attributes, the
+	// writes of the users are not
+	_, err := ds.UpsertDevicesAttributesWithUpdated(devCtx,
+		[]model.DeviceID{"1"}, attrs)
+	require.NoError(t, err)
+	_, err = ds.UpsertDevicesAttributes(devCtx, []model.DeviceID{"1"}, attrs)
+	require.NoError(t, err)
+	_, err = ds.ReplaceDeviceAttributes(devCtx, "1",
+		model.AttrScopeInventory, attrs)
+	require.NoError(t, err)
+	_, err = ds.UpsertRemoveDeviceAttributes(devCtx, "1", nil, attrs)
+	require.NoError(t, err)
+	_, err = ds.UpsertDevicesAttributes(userCtx, []model.DeviceID{"1"},
+		model.DeviceAttributes{
+			{Name: "owner", Value: "me", Scope: model.AttrScopeTags},
+		})
+	require.NoError(t, err)
+
+	dev, err := ds.GetDevice(devCtx, "1")
+	require.NoError(t, err)
+	require.NotNil(t, dev.Telemetry)
+	assert.Equal(t, int64(4), dev.Telemetry.Updates)
+	assert.Len(t, dev.Telemetry.Intervals, 3)
+	// the values are written as such, not read as expressions
+	var created, mac bool
+	for _, attr := range dev.Attributes {
+		switch {
+		case attr.Scope == model.AttrScopeSystem &&
+			attr.Name == model.AttrNameCreated:
+			created = true
+		case attr.Name == "mac":
+			mac = true
+		}
+	}
+	assert.True(t, created)
+	assert.False(t, mac)
+
+	// disabled, the writes are not recorded
+	ds.telemetry = false
+	_, err = ds.UpsertDevicesAttributes(devCtx, []model.DeviceID{"1"}, attrs)
+	require.NoError(t, err)
+	dev, err = ds.GetDevice(devCtx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), dev.Telemetry.Updates)
+	for _, attr := range dev.Attributes {
+		if attr.Name == "mac" {
+			assert.Equal(t, "$00:01", attr.Value)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package mongo
+
+import (
+	"context"
+	"sort"
+
+	"github.com/mendersoftware/go-lib-micro/mongo/migrate"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+// schemaVersion returns the version of the last migration applied to
+// the database, or false if it was never migrated.
+func (db *DataStoreMongo) schemaVersion(
+	ctx context.Context,
+	database string,
+) (migrate.Version, bool, error) {
+	applied, err := migrate.GetMigrationInfo(ctx, db.client, database)
+	if err != nil {
+		return migrate.Version{}, false, errors.Wrap(err, "failed to list applied migrations")
+	}
+	var version migrate.Version
+	for _, info := range applied {
+		if migrate.VersionIsLess(version, info.Version) {
+			version = info.Version
+		}
+	}
+	return version, len(applied) > 0, nil
+}
+
+func (db *DataStoreMongo) ListTenantDatabases(ctx context.Context) ([]model.TenantDatabase, error) {
+	res, err := db.client.ListDatabases(ctx, bson.M{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list databases")
+	}
+	dbs := []model.TenantDatabase{}
+	for _, spec := range res.Databases {
+		if spec.Name != db.names.Database && !db.names.IsTenantDb(spec.Name) {
+			continue
+		}
+		devices, err := db.client.Database(spec.Name).
+			Collection(db.names.Devices).
+			EstimatedDocumentCount(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to count the devices of %s", spec.Name)
+		}
+		tenantDB := model.TenantDatabase{
+			TenantID:   db.names.TenantFromDb(spec.Name),
+			Database:   spec.Name,
+			Devices:    devices,
+			SizeOnDisk: spec.SizeOnDisk,
+		}
+		version, ok, err := db.schemaVersion(ctx, spec.Name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read the version of %s", spec.Name)
+		} else if ok {
+			tenantDB.Version = version.String()
+		}
+		dbs = append(dbs, tenantDB)
+	}
+	sort.Slice(dbs, func(i, j int) bool {
+		return dbs[i].Database < dbs[j].Database
+	})
+	return dbs, nil
+}
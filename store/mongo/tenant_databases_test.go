@@ -0,0 +1,68 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package mongo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/mongo/migrate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+func TestListTenantDatabases(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestListTenantDatabases in short mode.")
+	}
+
+	db.Wipe()
+	s := db.Client()
+	ds := NewDataStoreMongoWithSession(s).(*DataStoreMongo)
+
+	for tenantID, ids := range map[string][]model.DeviceID{
+		"foo": {"1", "2"},
+		"bar": {"3"},
+	} {
+		ctx := identity.WithContext(context.Background(), &identity.Identity{
+			Tenant: tenantID,
+		})
+		for _, id := range ids {
+			require.NoError(t, ds.AddDevice(ctx, &model.Device{ID: id}))
+		}
+	}
+	err := migrate.UpdateMigrationInfo(context.Background(),
+		migrate.MakeVersion(1, 0, 9), s, ds.names.ForTenant("foo"))
+	require.NoError(t, err)
+
+	dbs, err := ds.ListTenantDatabases(context.Background())
+	assert.NoError(t, err)
+	for n := range dbs {
+		assert.GreaterOrEqual(t, dbs[n].SizeOnDisk, int64(0))
+		dbs[n].SizeOnDisk = 0
+	}
+	assert.Equal(t, []model.TenantDatabase{{
+		TenantID: "bar",
+		Database: ds.names.ForTenant("bar"),
+		Devices:  1,
+	}, {
+		TenantID: "foo",
+		Database: ds.names.ForTenant("foo"),
+		Devices:  2,
+		Version:  "1.0.9",
+	}}, dbs)
+}
@@ -0,0 +1,69 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package mongo
+
+import (
+	"regexp"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// searchableTypes are the BSON types of the values matched by the text
+// searches; the timestamps, in particular, are left out.
+var searchableTypes = bson.A{"string", "double", "int", "long", "decimal"}
+
+// textSearchQuery returns the query predicate selecting the devices with
+// the ID or any attribute value containing the text, ignoring the case;
+// the numbers are matched by their decimal representation and the arrays
+// by any of their elements.
+//
+// The attribute names are not known in advance, so the predicate is
+// an aggregation expression evaluated on each of the devices selected by
+// the other filters of the query: it cannot use the indexes, and the
+// searches should be combined with the indexed filters, e.g. the group,
+// on the large collections.
+func textSearchQuery(text string) bson.M {
+	pattern := regexp.QuoteMeta(text)
+	contains := func(input interface{}) bson.M {
+		return bson.M{"$regexMatch": bson.M{
+			"input": bson.M{"$cond": bson.A{
+				bson.M{"$in": bson.A{bson.M{"$type": input}, searchableTypes}},
+				bson.M{"$toString": input},
+				"",
+			}},
+			"regex":   pattern,
+			"options": "i",
+		}}
+	}
+	const value = "$$attr.v." + DbDevAttributesValue
+	attrContains := bson.M{"$cond": bson.A{
+		bson.M{"$isArray": value},
+		bson.M{"$anyElementTrue": bson.A{bson.M{"$map": bson.M{
+			"input": value,
+			"as":    "elem",
+			"in":    contains("$$elem"),
+		}}}},
+		contains(value),
+	}}
+	return bson.M{"$expr": bson.M{"$or": bson.A{
+		contains("$" + DbDevId),
+		bson.M{"$anyElementTrue": bson.A{bson.M{"$map": bson.M{
+			"input": bson.M{"$objectToArray": bson.M{
+				"$ifNull": bson.A{"$" + DbDevAttributes, bson.M{}},
+			}},
+			"as": "attr",
+			"in": attrContains,
+		}}}},
+	}}}
+}
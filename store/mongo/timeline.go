@@ -0,0 +1,64 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/mongo"
+	mopts "go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+const (
+	// DbTimelineColl holds the events of the device timeline, keyed by
+	// their deduplication keys.
+	DbTimelineColl = "timeline"
+
+	errCodeDuplicateKey = 11000
+)
+
+func (db *DataStoreMongo) InsertTimelineEvents(
+	ctx context.Context,
+	events []model.TimelineEvent,
+) (int, error) {
+	if len(events) == 0 {
+		return 0, nil
+	}
+	c := db.database(ctx).
+		Collection(DbTimelineColl)
+
+	docs := make([]interface{}, len(events))
+	for n, event := range events {
+		docs[n] = event
+	}
+	// the events are inserted unordered, so that the duplicates do not
+	// stop the insertion of the rest of the batch
+	res, err := c.InsertMany(ctx, docs, mopts.InsertMany().SetOrdered(false))
+	if bwe, ok := err.(mongo.BulkWriteException); ok &&
+		bwe.WriteConcernError == nil {
+		for _, werr := range bwe.WriteErrors {
+			if werr.Code != errCodeDuplicateKey {
+				return 0, errors.Wrap(err, "failed to insert timeline events")
+			}
+		}
+		return len(events) - len(bwe.WriteErrors), nil
+	} else if err != nil {
+		return 0, errors.Wrap(err, "failed to insert timeline events")
+	}
+	return len(res.InsertedIDs), nil
+}
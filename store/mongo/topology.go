@@ -0,0 +1,153 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/description"
+
+	"github.com/mendersoftware/inventory/metrics"
+)
+
+var (
+	ErrTopologyUnavailable = errors.New("no writable mongo server available")
+
+	mongoAvailable = metrics.NewGaugeVec(
+		"inventory_mongo_available",
+		"Whether a writable mongo server is available (1) or not (0).",
+	)
+	mongoPrimaryChanges = metrics.NewCounterVec(
+		"inventory_mongo_primary_changes_total",
+		"Number of changes of the mongo primary server.",
+	)
+	mongoPoolCleared = metrics.NewCounterVec(
+		"inventory_mongo_pool_cleared_total",
+		"Number of times the connection pool of a mongo server was cleared.",
+		"address",
+	)
+	mongoHeartbeatFailures = metrics.NewCounterVec(
+		"inventory_mongo_heartbeat_failures_total",
+		"Number of failed heartbeats of the mongo servers.",
+	)
+)
+
+// TopologyMonitor follows the topology and connection pool events of
+// the mongo driver, logging and counting the primary changes and cleared
+// connection pools. It also keeps track of the time since there is no
+// writable server, so that the readiness check can fail right away during
+// a prolonged unavailability instead of timing out.
+type TopologyMonitor struct {
+	// Threshold is the time without a writable server after which the
+	// topology is reported as unavailable; zero disables the reporting.
+	Threshold time.Duration
+
+	mu               sync.Mutex
+	primary          string
+	unavailableSince time.Time
+	now              func() time.Time
+}
+
+func NewTopologyMonitor(threshold time.Duration) *TopologyMonitor {
+	return &TopologyMonitor{
+		Threshold: threshold,
+		now:       time.Now,
+	}
+}
+
+// ServerMonitor returns the monitor to register with the client options.
+func (m *TopologyMonitor) ServerMonitor() *event.ServerMonitor {
+	return &event.ServerMonitor{
+		TopologyDescriptionChanged: m.topologyChanged,
+		ServerHeartbeatFailed: func(*event.ServerHeartbeatFailedEvent) {
+			mongoHeartbeatFailures.Inc()
+		},
+	}
+}
+
+// PoolMonitor returns the monitor to register with the client options.
+func (m *TopologyMonitor) PoolMonitor() *event.PoolMonitor {
+	return &event.PoolMonitor{
+		Event: func(evt *event.PoolEvent) {
+			if evt.Type != event.PoolCleared {
+				return
+			}
+			mongoPoolCleared.Inc(evt.Address)
+			log.NewEmpty().Warnf("mongo: connection pool of %s cleared",
+				evt.Address)
+		},
+	}
+}
+
+func (m *TopologyMonitor) topologyChanged(evt *event.TopologyDescriptionChangedEvent) {
+	l := log.NewEmpty()
+	primary, writable := writableServer(evt.NewDescription)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if writable {
+		if !m.unavailableSince.IsZero() {
+			l.Infof("mongo: writable server %s available again after %s",
+				primary, m.now().Sub(m.unavailableSince))
+		}
+		m.unavailableSince = time.Time{}
+		mongoAvailable.Set(1)
+	} else if m.unavailableSince.IsZero() {
+		l.Warnf("mongo: no writable server available")
+		m.unavailableSince = m.now()
+		mongoAvailable.Set(0)
+	}
+	if writable && primary != m.primary {
+		if m.primary != "" {
+			l.Warnf("mongo: primary changed from %s to %s", m.primary, primary)
+			mongoPrimaryChanges.Inc()
+		}
+		m.primary = primary
+	}
+}
+
+// writableServer returns the address of the server accepting writes,
+// if any.
+func writableServer(topology description.Topology) (string, bool) {
+	for _, server := range topology.Servers {
+		switch server.Kind {
+		case description.RSPrimary, description.Standalone, description.Mongos:
+			return server.Addr.String(), true
+		}
+	}
+	return "", false
+}
+
+// Err returns ErrTopologyUnavailable if there was no writable server for
+// longer than the threshold.
+func (m *TopologyMonitor) Err() error {
+	if m == nil || m.Threshold <= 0 {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.unavailableSince.IsZero() {
+		return nil
+	}
+	if d := m.now().Sub(m.unavailableSince); d > m.Threshold {
+		return errors.Wrapf(ErrTopologyUnavailable,
+			"mongo unavailable for %s", d.Round(time.Second))
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/address"
+	"go.mongodb.org/mongo-driver/mongo/description"
+
+	"github.com/mendersoftware/inventory/metrics"
+)
+
+func makeTopologyEvent(kinds ...description.ServerKind) *event.TopologyDescriptionChangedEvent {
+	topology := description.Topology{}
+	for i, kind := range kinds {
+		topology.Servers = append(topology.Servers, description.Server{
+			Addr: address.Address(string(rune('a'+i)) + ":27017"),
+			Kind: kind,
+		})
+	}
+	return &event.TopologyDescriptionChangedEvent{NewDescription: topology}
+}
+
+func TestTopologyMonitor(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	m := NewTopologyMonitor(30 * time.Second)
+	m.now = func() time.Time { return now }
+	changed := m.ServerMonitor().TopologyDescriptionChanged
+	primaryChanges := metrics.Value("inventory_mongo_primary_changes_total")
+
+	changed(makeTopologyEvent(description.RSPrimary, description.RSSecondary))
+	assert.NoError(t, m.Err())
+	assert.Equal(t, float64(1), metrics.Value("inventory_mongo_available"))
+
+	// election in progress
+	changed(makeTopologyEvent(description.RSSecondary, description.RSSecondary))
+	assert.NoError(t, m.Err())
+	assert.Equal(t, float64(0), metrics.Value("inventory_mongo_available"))
+
+	now = now.Add(20 * time.Second)
+	changed(makeTopologyEvent(description.Unknown, description.RSSecondary))
+	assert.NoError(t, m.Err())
+
+	now = now.Add(20 * time.Second)
+	err := m.Err()
+	assert.EqualError(t, err,
+		"mongo unavailable for 40s: no writable mongo server available")
+
+	// new primary elected
+	changed(makeTopologyEvent(description.RSSecondary, description.RSPrimary))
+	assert.NoError(t, m.Err())
+	assert.Equal(t, float64(1), metrics.Value("inventory_mongo_available"))
+	assert.Equal(t, primaryChanges+1,
+		metrics.Value("inventory_mongo_primary_changes_total"))
+
+	m.Threshold = 0
+	changed(makeTopologyEvent())
+	now = now.Add(time.Hour)
+	assert.NoError(t, m.Err())
+}
+
+func TestTopologyMonitorPoolCleared(t *testing.T) {
+	m := NewTopologyMonitor(0)
+	cleared := metrics.Value("inventory_mongo_pool_cleared_total", "a:27017")
+
+	m.PoolMonitor().Event(&event.PoolEvent{
+		Type:    event.ConnectionCreated,
+		Address: "a:27017",
+	})
+	m.PoolMonitor().Event(&event.PoolEvent{
+		Type:    event.PoolCleared,
+		Address: "a:27017",
+	})
+	assert.Equal(t, cleared+1,
+		metrics.Value("inventory_mongo_pool_cleared_total", "a:27017"))
+}
@@ -13,10 +13,28 @@
 //    limitations under the License.
 package store
 
+import (
+	"time"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
 type ComparisonOperator int
 
 const (
 	Eq ComparisonOperator = 1 << iota
+	Ne
+	Gt
+	Gte
+	Lt
+	Lte
+	In
+	Nin
+	Exists
+	// Regex and IRegex match the string values with the regular
+	// expression of the Value, IRegex ignoring the case.
+	Regex
+	IRegex
 )
 
 type Filter struct {
@@ -24,20 +42,110 @@ type Filter struct {
 	AttrScope  string
 	Value      string
 	ValueFloat *float64
-	Operator   ComparisonOperator
+	// Values are the values of the In and Nin operators.
+	Values   []string
+	Operator ComparisonOperator
+}
+
+// LogicalOperator combines the filters of a FilterGroup.
+type LogicalOperator int
+
+const (
+	And LogicalOperator = iota
+	Or
+)
+
+// FilterGroup is a node of a tree of filters: its filters and nested
+// groups are combined with its operator. An empty group matches all
+// the devices.
+type FilterGroup struct {
+	Operator LogicalOperator
+	Filters  []Filter
+	Groups   []FilterGroup
+}
+
+// AllFilters returns the filters of the group and of its nested groups.
+func (g *FilterGroup) AllFilters() []Filter {
+	if g == nil {
+		return nil
+	}
+	filters := append([]Filter(nil), g.Filters...)
+	for n := range g.Groups {
+		filters = append(filters, g.Groups[n].AllFilters()...)
+	}
+	return filters
 }
 
 type Sort struct {
 	AttrName  string
 	AttrScope string
 	Ascending bool
+	// Nulls places the devices without the attribute first or last
+	// (model.SortNullsFirst or model.SortNullsLast); by default they
+	// sort as the lowest value.
+	Nulls string
 }
 
 type ListQuery struct {
-	Skip      int
-	Limit     int
-	Filters   []Filter
-	Sort      *Sort
+	Skip    int
+	Limit   int
+	Filters []Filter
+	// FilterGroup is ANDed with the Filters.
+	FilterGroup *FilterGroup
+	// Sort orders the devices by the attributes in turn, each one
+	// breaking the ties of the previous ones.
+	Sort      []Sort
 	HasGroup  *bool
 	GroupName string
+	// GroupNames limits the devices to the members of any of the groups,
+	// e.g. a group and its descendants, in place of GroupName.
+	GroupNames []model.GroupName
+	// Search limits the devices to the ones with the ID or any attribute
+	// value containing the text, ignoring the case.
+	Search string
+	// UpdatedSince limits the devices to the ones updated at or after
+	// the time; unless sorted otherwise, the devices are then returned
+	// by the update time and ID.
+	UpdatedSince *time.Time
+	// UpdatedAfterID resumes the devices updated since UpdatedSince after
+	// the device with the ID, skipping the devices updated at exactly
+	// UpdatedSince with the IDs up to it.
+	UpdatedAfterID model.DeviceID
+	// Cursor selects the keyset pagination, resuming the listing after
+	// the device of the cursor in place of Skip.
+	Cursor *model.DeviceCursor
+	// IDsOnly limits the returned devices to their IDs.
+	IDsOnly bool
+	// Attributes limits the returned device attributes to the selected
+	// ones; all the attributes are returned if empty.
+	Attributes []model.SelectAttribute
+}
+
+// GroupsQuery selects a page of the group names, sorted by name.
+type GroupsQuery struct {
+	// Prefix limits the groups to the names starting with it.
+	Prefix  string
+	Filters []model.FilterPredicate
+	Skip    int
+	Limit   int
+}
+
+// SavedFiltersQuery selects the saved filters, sorted by name.
+type SavedFiltersQuery struct {
+	// OwnerID limits the filters to the ones of the user.
+	OwnerID string
+	// Shared limits the filters to the shared or to the private ones.
+	Shared *bool
+}
+
+// IncompleteDevicesQuery selects a page of the devices missing any of
+// the required attributes, sorted by ID.
+type IncompleteDevicesQuery struct {
+	Required []model.SelectAttribute
+	// CreatedFrom and CreatedUntil limit the devices to the ones first
+	// seen within [CreatedFrom, CreatedUntil).
+	CreatedFrom  *time.Time
+	CreatedUntil *time.Time
+	Skip         int
+	Limit        int
 }
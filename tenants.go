@@ -0,0 +1,89 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/urfave/cli"
+
+	"github.com/mendersoftware/inventory/model"
+	"github.com/mendersoftware/inventory/store/mongo"
+)
+
+// writeTenantDatabases writes the databases of the tenants as a table for
+// a human.
+func writeTenantDatabases(w io.Writer, dbs []model.TenantDatabase) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, "TENANT\tDATABASE\tDEVICES\tVERSION\tSIZE"); err != nil {
+		return err
+	}
+	var devices, size int64
+	for _, db := range dbs {
+		tenantID, version := db.TenantID, db.Version
+		if tenantID == "" {
+			tenantID = "-"
+		}
+		if version == "" {
+			version = "-"
+		}
+		if _, err := fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%.1f MB\n",
+			tenantID, db.Database, db.Devices, version,
+			float64(db.SizeOnDisk)/(1<<20)); err != nil {
+			return err
+		}
+		devices += db.Devices
+		size += db.SizeOnDisk
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d databases, %d devices, %.1f MB\n",
+		len(dbs), devices, float64(size)/(1<<20))
+	return err
+}
+
+func cmdListTenants(args *cli.Context) error {
+	db, err := mongo.NewDataStoreMongo(makeDataStoreConfig())
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("failed to connect to db: %v", err),
+			3)
+	}
+
+	dbs, err := db.ListTenantDatabases(context.Background())
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("failed to list tenant databases: %v", err),
+			3)
+	}
+	if args.Bool("json") {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(dbs)
+	} else {
+		err = writeTenantDatabases(os.Stdout, dbs)
+	}
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("failed to write the list: %v", err),
+			3)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+func TestWriteTenantDatabases(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, writeTenantDatabases(&buf, []model.TenantDatabase{{
+		TenantID:   "foo",
+		Database:   "inventory-foo",
+		Devices:    1200,
+		Version:    "1.0.9",
+		SizeOnDisk: 3 << 20,
+	}, {
+		Database:   "inventory",
+		SizeOnDisk: 1 << 19,
+	}}))
+	assert.Equal(t,
+		"TENANT  DATABASE       DEVICES  VERSION  SIZE\n"+
+			"foo     inventory-foo  1200     1.0.9    3.0 MB\n"+
+			"-       inventory      0        -        0.5 MB\n"+
+			"\n2 databases, 1200 devices, 3.5 MB\n",
+		buf.String())
+}
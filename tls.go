@@ -0,0 +1,136 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+)
+
+// certReloadDelay is the quiet period after a change of the certificate
+// files before reloading them, so that the certificate and the key are
+// both written.
+const certReloadDelay = time.Second
+
+// certReloader serves the TLS certificate of a listener and reloads it
+// from its files, so that renewed certificates are used without
+// a restart.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reload loads the certificate from the files; the previous certificate
+// is kept if they are invalid.
+func (r *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return errors.Wrap(err, "failed to load the TLS certificate")
+	}
+	r.mu.Lock()
+	r.cert = &cert
+	r.mu.Unlock()
+	return nil
+}
+
+// GetCertificate returns the current certificate, see
+// tls.Config.GetCertificate.
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// watch reloads the certificate on SIGHUP and when its files change until
+// the context is canceled.
+func (r *certReloader) watch(ctx context.Context) error {
+	l := log.FromContext(ctx)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "failed to watch the TLS certificate")
+	}
+	defer watcher.Close()
+	// the directories are watched: the files are often replaced rather
+	// than written, e.g. the mounted Kubernetes secrets
+	for _, dir := range []string{
+		filepath.Dir(r.certFile),
+		filepath.Dir(r.keyFile),
+	} {
+		if err := watcher.Add(dir); err != nil {
+			return errors.Wrapf(err, "failed to watch %s", dir)
+		}
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	delay := time.NewTimer(certReloadDelay)
+	delay.Stop()
+	defer delay.Stop()
+	reload := func() {
+		if err := r.reload(); err != nil {
+			l.Errorf("keeping the previous TLS certificate: %s", err.Error())
+		} else {
+			l.Infof("reloaded the TLS certificate %s", r.certFile)
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hup:
+			reload()
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op != fsnotify.Chmod {
+				delay.Reset(certReloadDelay)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			l.Errorf("watching the TLS certificate: %s", err.Error())
+		case <-delay.C:
+			reload()
+		}
+	}
+}
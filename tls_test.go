@@ -0,0 +1,122 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCert writes a self-signed certificate of the host name and its key
+// to the files.
+func writeCert(t *testing.T, certFile, keyFile, name string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	// the files are replaced, like the updated Kubernetes secrets
+	for file, block := range map[string]*pem.Block{
+		certFile: {Type: "CERTIFICATE", Bytes: der},
+		keyFile:  {Type: "EC PRIVATE KEY", Bytes: keyDER},
+	} {
+		tmp := file + ".tmp"
+		require.NoError(t, ioutil.WriteFile(tmp, pem.EncodeToMemory(block), 0600))
+		require.NoError(t, os.Rename(tmp, file))
+	}
+}
+
+func certName(t *testing.T, r *certReloader) string {
+	cert, err := r.GetCertificate(nil)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inventory-tls")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+
+	_, err = newCertReloader(certFile, keyFile)
+	assert.Error(t, err)
+
+	writeCert(t, certFile, keyFile, "one")
+	r, err := newCertReloader(certFile, keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, "one", certName(t, r))
+
+	// an invalid certificate does not replace the current one
+	require.NoError(t, ioutil.WriteFile(certFile, []byte("garbage"), 0600))
+	assert.Error(t, r.reload())
+	assert.Equal(t, "one", certName(t, r))
+
+	writeCert(t, certFile, keyFile, "two")
+	assert.NoError(t, r.reload())
+	assert.Equal(t, "two", certName(t, r))
+}
+
+func TestCertReloaderWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inventory-tls")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+
+	writeCert(t, certFile, keyFile, "one")
+	r, err := newCertReloader(certFile, keyFile)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- r.watch(ctx)
+	}()
+	// give the watcher the time to start
+	time.Sleep(100 * time.Millisecond)
+
+	writeCert(t, certFile, keyFile, "two")
+	assert.Eventually(t, func() bool {
+		return certName(t, r) == "two"
+	}, 5*certReloadDelay, 50*time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
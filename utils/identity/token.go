@@ -25,10 +25,18 @@ import (
 // Token field names
 const (
 	subjectClaim = "sub"
+	userClaim    = "mender.user"
+	deviceClaim  = "mender.device"
+	rolesClaim   = "mender.roles"
+	scopeClaim   = "scope"
 )
 
 type Identity struct {
-	Subject string
+	Subject  string
+	IsUser   bool
+	IsDevice bool
+	Roles    []string
+	Scopes   []string
 }
 
 type rawClaims map[string]interface{}
@@ -60,7 +68,8 @@ func decodeClaims(token string) (rawClaims, error) {
 	return claims, nil
 }
 
-// Generate identity information from given JWT by extracting subject claim.
+// Generate identity information from given JWT by extracting subject claim,
+// the user/device flags and the roles and scopes granted to the token, if any.
 // Note that this function does not perform any form of token signature
 // verification.
 func ExtractIdentity(token string) (Identity, error) {
@@ -79,7 +88,29 @@ func ExtractIdentity(token string) (Identity, error) {
 		return Identity{}, errors.Errorf("invalid subject format")
 	}
 
-	return Identity{Subject: sub}, nil
+	id := Identity{Subject: sub}
+	id.IsUser, _ = claims[userClaim].(bool)
+	id.IsDevice, _ = claims[deviceClaim].(bool)
+	if rawroles, ok := claims[rolesClaim]; ok {
+		roles, ok := rawroles.([]interface{})
+		if !ok {
+			return Identity{}, errors.Errorf("invalid roles format")
+		}
+		for _, role := range roles {
+			if r, ok := role.(string); ok {
+				id.Roles = append(id.Roles, r)
+			}
+		}
+	}
+	if rawscope, ok := claims[scopeClaim]; ok {
+		scope, ok := rawscope.(string)
+		if !ok {
+			return Identity{}, errors.Errorf("invalid scope format")
+		}
+		id.Scopes = strings.Fields(scope)
+	}
+
+	return id, nil
 }
 
 // Extract identity information from HTTP Authorization header. The header is
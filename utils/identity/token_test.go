@@ -64,6 +64,52 @@ func TestExtractIdentity(t *testing.T) {
 	enc = base64.StdEncoding.EncodeToString([]byte(`{"sub": 1}`))
 	_, err = ExtractIdentity("foo." + enc + ".bar")
 	assert.Error(t, err)
+
+	// user token with roles
+	enc = base64.StdEncoding.EncodeToString([]byte(
+		`{"sub": "foobar", "mender.user": true, "mender.roles": ["helpdesk"]}`,
+	))
+	idata, err = ExtractIdentity("foo." + enc + ".bar")
+	assert.NoError(t, err)
+	assert.Equal(t, Identity{
+		Subject: "foobar",
+		IsUser:  true,
+		Roles:   []string{"helpdesk"},
+	}, idata)
+
+	// device token
+	enc = base64.StdEncoding.EncodeToString([]byte(
+		`{"sub": "foobar", "mender.device": true}`,
+	))
+	idata, err = ExtractIdentity("foo." + enc + ".bar")
+	assert.NoError(t, err)
+	assert.Equal(t, Identity{Subject: "foobar", IsDevice: true}, idata)
+
+	// device token with scopes
+	enc = base64.StdEncoding.EncodeToString([]byte(
+		`{"sub": "foobar", "mender.device": true, "scope": "inventory-report other"}`,
+	))
+	idata, err = ExtractIdentity("foo." + enc + ".bar")
+	assert.NoError(t, err)
+	assert.Equal(t, Identity{
+		Subject:  "foobar",
+		IsDevice: true,
+		Scopes:   []string{"inventory-report", "other"},
+	}, idata)
+
+	// bad scope
+	enc = base64.StdEncoding.EncodeToString([]byte(
+		`{"sub": "foobar", "scope": ["inventory-report"]}`,
+	))
+	_, err = ExtractIdentity("foo." + enc + ".bar")
+	assert.Error(t, err)
+
+	// bad roles
+	enc = base64.StdEncoding.EncodeToString([]byte(
+		`{"sub": "foobar", "mender.roles": "helpdesk"}`,
+	))
+	_, err = ExtractIdentity("foo." + enc + ".bar")
+	assert.Error(t, err)
 }
 
 func TestExtractIdentityFromHeaders(t *testing.T) {
@@ -0,0 +1,454 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+// Package parquet writes tabular data as Apache Parquet files, so that
+// the inventory exports can be loaded directly into data warehouses.
+//
+// The writer covers what the exports need: a flat schema of required or
+// optional columns, PLAIN encoded and uncompressed, with the rows buffered
+// and written out in row groups.
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Type is the type of the values of a column.
+type Type int
+
+const (
+	// Boolean columns hold bool values.
+	Boolean Type = iota
+	// Int64 columns hold int64 values.
+	Int64
+	// Double columns hold float64 values.
+	Double
+	// String columns hold string values.
+	String
+	// JSON columns hold any value, serialized as a JSON document.
+	JSON
+	// Timestamp columns hold time.Time values, stored with millisecond
+	// precision.
+	Timestamp
+)
+
+// DefaultRowGroupSize is the default number of rows buffered before they
+// are written out as a row group.
+const DefaultRowGroupSize = 10000
+
+const (
+	magic     = "PAR1"
+	createdBy = "mender inventory"
+)
+
+// Values of the enums of the Parquet format.
+const (
+	physicalBoolean   = 0
+	physicalInt64     = 2
+	physicalDouble    = 5
+	physicalByteArray = 6
+
+	convertedUTF8            = 0
+	convertedTimestampMillis = 9
+	convertedJSON            = 19
+
+	repetitionRequired = 0
+	repetitionOptional = 1
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecUncompressed = 0
+	pageTypeData      = 0
+)
+
+// Column describes a column of the file.
+type Column struct {
+	Name string
+	Type Type
+	// Optional columns accept nil values.
+	Optional bool
+}
+
+func (c Column) physicalType() int32 {
+	switch c.Type {
+	case Boolean:
+		return physicalBoolean
+	case Int64, Timestamp:
+		return physicalInt64
+	case Double:
+		return physicalDouble
+	default:
+		return physicalByteArray
+	}
+}
+
+func (c Column) convertedType() (int32, bool) {
+	switch c.Type {
+	case String:
+		return convertedUTF8, true
+	case JSON:
+		return convertedJSON, true
+	case Timestamp:
+		return convertedTimestampMillis, true
+	}
+	return 0, false
+}
+
+type columnBuffer struct {
+	present []bool
+	values  bytes.Buffer
+	bools   []bool
+}
+
+type columnChunk struct {
+	offset int64
+	size   int64
+}
+
+type rowGroup struct {
+	chunks  []columnChunk
+	numRows int64
+}
+
+// Writer writes the rows to a Parquet file.
+type Writer struct {
+	w            io.Writer
+	columns      []Column
+	rowGroupSize int
+
+	buffers   []columnBuffer
+	rows      int
+	offset    int64
+	rowGroups []rowGroup
+	numRows   int64
+	err       error
+}
+
+// NewWriter returns a writer of a file with the given columns.
+func NewWriter(w io.Writer, columns []Column) *Writer {
+	return &Writer{
+		w:            w,
+		columns:      columns,
+		rowGroupSize: DefaultRowGroupSize,
+		buffers:      make([]columnBuffer, len(columns)),
+	}
+}
+
+// WithRowGroupSize sets the number of rows written in a row group.
+func (w *Writer) WithRowGroupSize(size int) *Writer {
+	w.rowGroupSize = size
+	return w
+}
+
+// Write appends a row to the file; the values are given in the order of
+// the columns.
+func (w *Writer) Write(row ...interface{}) error {
+	if w.err != nil {
+		return w.err
+	} else if len(row) != len(w.columns) {
+		return errors.Errorf("expected %d values, got %d",
+			len(w.columns), len(row))
+	}
+	// validate the whole row before buffering it
+	for i, value := range row {
+		if err := checkValue(w.columns[i], value); err != nil {
+			return err
+		}
+	}
+	for i, value := range row {
+		w.buffers[i].append(w.columns[i], value)
+	}
+	w.rows++
+	if w.rows >= w.rowGroupSize {
+		w.err = w.flush()
+	}
+	return w.err
+}
+
+// Close writes out the buffered rows and the file footer; it does not
+// close the underlying writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.flush(); err != nil {
+		w.err = err
+		return err
+	}
+	if err := w.start(); err != nil {
+		return err
+	}
+	footer := w.footer()
+	if err := w.write(footer); err != nil {
+		return err
+	}
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(footer)))
+	if err := w.write(length[:]); err != nil {
+		return err
+	}
+	if err := w.write([]byte(magic)); err != nil {
+		return err
+	}
+	w.err = errors.New("parquet: writer closed")
+	return nil
+}
+
+func (w *Writer) write(b []byte) error {
+	n, err := w.w.Write(b)
+	w.offset += int64(n)
+	if err != nil {
+		w.err = errors.Wrap(err, "parquet: failed to write")
+	}
+	return w.err
+}
+
+// start writes the magic bytes opening the file.
+func (w *Writer) start() error {
+	if w.offset > 0 {
+		return nil
+	}
+	return w.write([]byte(magic))
+}
+
+func checkValue(column Column, value interface{}) error {
+	if value == nil {
+		if !column.Optional {
+			return errors.Errorf("column %s: missing value", column.Name)
+		}
+		return nil
+	}
+	var ok bool
+	switch column.Type {
+	case Boolean:
+		_, ok = value.(bool)
+	case Int64:
+		_, ok = value.(int64)
+	case Double:
+		_, ok = value.(float64)
+	case String:
+		_, ok = value.(string)
+	case Timestamp:
+		_, ok = value.(time.Time)
+	case JSON:
+		if _, err := json.Marshal(value); err != nil {
+			return errors.Wrapf(err, "column %s", column.Name)
+		}
+		ok = true
+	}
+	if !ok {
+		return errors.Errorf("column %s: unexpected value of type %T",
+			column.Name, value)
+	}
+	return nil
+}
+
+func (b *columnBuffer) append(column Column, value interface{}) {
+	b.present = append(b.present, value != nil)
+	if value == nil {
+		return
+	}
+	var scratch [8]byte
+	switch column.Type {
+	case Boolean:
+		b.bools = append(b.bools, value.(bool))
+	case Int64:
+		binary.LittleEndian.PutUint64(scratch[:], uint64(value.(int64)))
+		b.values.Write(scratch[:])
+	case Timestamp:
+		ms := value.(time.Time).UnixNano() / int64(time.Millisecond)
+		binary.LittleEndian.PutUint64(scratch[:], uint64(ms))
+		b.values.Write(scratch[:])
+	case Double:
+		binary.LittleEndian.PutUint64(scratch[:],
+			math.Float64bits(value.(float64)))
+		b.values.Write(scratch[:])
+	case String:
+		b.appendByteArray([]byte(value.(string)))
+	case JSON:
+		// checked by checkValue
+		data, _ := json.Marshal(value)
+		b.appendByteArray(data)
+	}
+}
+
+func (b *columnBuffer) appendByteArray(data []byte) {
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(data)))
+	b.values.Write(length[:])
+	b.values.Write(data)
+}
+
+// page returns the body of the data page holding the buffered values.
+func (b *columnBuffer) page(column Column) []byte {
+	var page bytes.Buffer
+	if column.Optional {
+		levels := encodeDefinitionLevels(b.present)
+		var length [4]byte
+		binary.LittleEndian.PutUint32(length[:], uint32(len(levels)))
+		page.Write(length[:])
+		page.Write(levels)
+	}
+	if column.Type == Boolean {
+		// booleans are bit-packed, least significant bit first
+		packed := make([]byte, (len(b.bools)+7)/8)
+		for i, v := range b.bools {
+			if v {
+				packed[i/8] |= 1 << (i % 8)
+			}
+		}
+		page.Write(packed)
+	} else {
+		page.Write(b.values.Bytes())
+	}
+	return page.Bytes()
+}
+
+// encodeDefinitionLevels encodes the definition levels of an optional
+// column, 1 for present values and 0 for nulls, as RLE runs of
+// the RLE/bit-packing hybrid encoding with a bit width of 1.
+func encodeDefinitionLevels(present []bool) []byte {
+	var buf bytes.Buffer
+	var header [binary.MaxVarintLen64]byte
+	for start := 0; start < len(present); {
+		end := start + 1
+		for end < len(present) && present[end] == present[start] {
+			end++
+		}
+		n := binary.PutUvarint(header[:], uint64(end-start)<<1)
+		buf.Write(header[:n])
+		if present[start] {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+		start = end
+	}
+	return buf.Bytes()
+}
+
+// flush writes out the buffered rows as a row group.
+func (w *Writer) flush() error {
+	if w.rows == 0 {
+		return nil
+	}
+	if err := w.start(); err != nil {
+		return err
+	}
+	group := rowGroup{
+		chunks:  make([]columnChunk, len(w.columns)),
+		numRows: int64(w.rows),
+	}
+	for i, column := range w.columns {
+		page := w.buffers[i].page(column)
+
+		header := &thriftWriter{}
+		header.beginStruct()
+		header.i32Field(1, pageTypeData)
+		header.i32Field(2, int32(len(page)))
+		header.i32Field(3, int32(len(page)))
+		header.structField(5)
+		header.i32Field(1, int32(w.rows))
+		header.i32Field(2, encodingPlain)
+		header.i32Field(3, encodingRLE)
+		header.i32Field(4, encodingRLE)
+		header.endStruct()
+		header.endStruct()
+
+		offset := w.offset
+		if err := w.write(header.buf.Bytes()); err != nil {
+			return err
+		}
+		if err := w.write(page); err != nil {
+			return err
+		}
+		group.chunks[i] = columnChunk{offset: offset, size: w.offset - offset}
+		w.buffers[i] = columnBuffer{}
+	}
+	w.rowGroups = append(w.rowGroups, group)
+	w.numRows += int64(w.rows)
+	w.rows = 0
+	return nil
+}
+
+// footer returns the file metadata.
+func (w *Writer) footer() []byte {
+	t := &thriftWriter{}
+	t.beginStruct()
+	t.i32Field(1, 1)
+
+	t.listField(2, thriftStruct, len(w.columns)+1)
+	t.beginStruct()
+	t.stringField(4, "schema")
+	t.i32Field(5, int32(len(w.columns)))
+	t.endStruct()
+	for _, column := range w.columns {
+		t.beginStruct()
+		t.i32Field(1, column.physicalType())
+		if column.Optional {
+			t.i32Field(3, repetitionOptional)
+		} else {
+			t.i32Field(3, repetitionRequired)
+		}
+		t.stringField(4, column.Name)
+		if converted, ok := column.convertedType(); ok {
+			t.i32Field(6, converted)
+		}
+		t.endStruct()
+	}
+
+	t.i64Field(3, w.numRows)
+
+	t.listField(4, thriftStruct, len(w.rowGroups))
+	for _, group := range w.rowGroups {
+		t.beginStruct()
+		t.listField(1, thriftStruct, len(group.chunks))
+		var total int64
+		for i, chunk := range group.chunks {
+			column := w.columns[i]
+			t.beginStruct()
+			t.i64Field(2, chunk.offset)
+			t.structField(3)
+			t.i32Field(1, column.physicalType())
+			t.listField(2, thriftI32, 2)
+			t.varint(encodingPlain)
+			t.varint(encodingRLE)
+			t.listField(3, thriftBinary, 1)
+			t.binary(column.Name)
+			t.i32Field(4, codecUncompressed)
+			t.i64Field(5, group.numRows)
+			t.i64Field(6, chunk.size)
+			t.i64Field(7, chunk.size)
+			t.i64Field(9, chunk.offset)
+			t.endStruct()
+			t.endStruct()
+			total += chunk.size
+		}
+		t.i64Field(2, total)
+		t.i64Field(3, group.numRows)
+		t.endStruct()
+	}
+
+	t.stringField(6, createdBy)
+	t.endStruct()
+	return t.buf.Bytes()
+}
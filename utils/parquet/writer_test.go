@@ -0,0 +1,225 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// thriftReader decodes the Thrift compact protocol into maps keyed by
+// the field IDs, to verify the metadata written by the writer.
+type thriftReader struct {
+	r *bytes.Reader
+}
+
+func (t *thriftReader) varint() int64 {
+	v, err := binary.ReadUvarint(t.r)
+	if err != nil {
+		panic(err)
+	}
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (t *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case 1:
+		return true
+	case 2:
+		return false
+	case thriftI32, thriftI64:
+		return t.varint()
+	case thriftBinary:
+		n, _ := binary.ReadUvarint(t.r)
+		b := make([]byte, n)
+		_, _ = t.r.Read(b)
+		return string(b)
+	case thriftList:
+		h, _ := t.r.ReadByte()
+		size := int(h >> 4)
+		if size == 15 {
+			n, _ := binary.ReadUvarint(t.r)
+			size = int(n)
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = t.value(h & 0x0f)
+		}
+		return list
+	case thriftStruct:
+		return t.readStruct()
+	}
+	panic(errors.New("unsupported type"))
+}
+
+func (t *thriftReader) readStruct() map[int16]interface{} {
+	fields := map[int16]interface{}{}
+	var id int16
+	for {
+		h, _ := t.r.ReadByte()
+		if h == 0 {
+			return fields
+		}
+		if delta := int16(h >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(t.varint())
+		}
+		fields[id] = t.value(h & 0x0f)
+	}
+}
+
+func readFooter(t *testing.T, data []byte) map[int16]interface{} {
+	if !assert.True(t, len(data) > 12) {
+		t.FailNow()
+	}
+	assert.Equal(t, magic, string(data[:4]))
+	assert.Equal(t, magic, string(data[len(data)-4:]))
+	length := binary.LittleEndian.Uint32(data[len(data)-8:])
+	footer := data[len(data)-8-int(length) : len(data)-8]
+	r := &thriftReader{r: bytes.NewReader(footer)}
+	meta := r.readStruct()
+	assert.Zero(t, r.r.Len())
+	return meta
+}
+
+// readPage returns the body of the data page of the column chunk.
+func readPage(data []byte, chunk map[int16]interface{}) []byte {
+	meta := chunk[3].(map[int16]interface{})
+	offset := meta[9].(int64)
+	r := &thriftReader{r: bytes.NewReader(data[offset:])}
+	header := r.readStruct()
+	start := int(offset) + int(r.r.Size()) - r.r.Len()
+	return data[start : start+int(header[3].(int64))]
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, []Column{
+		{Name: "id", Type: String},
+		{Name: "count", Type: Double, Optional: true},
+		{Name: "online", Type: Boolean, Optional: true},
+		{Name: "tags", Type: JSON, Optional: true},
+		{Name: "updated_ts", Type: Timestamp},
+		{Name: "revision", Type: Int64},
+	}).WithRowGroupSize(2)
+
+	ts := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, w.Write("1", 4.0, true, []string{"a"}, ts, int64(1)))
+	assert.NoError(t, w.Write("2", nil, nil, nil, ts, int64(2)))
+	assert.NoError(t, w.Write("3", 1.5, false, nil, ts, int64(3)))
+
+	err := w.Write("4", "four", nil, nil, ts, int64(4))
+	assert.EqualError(t, err, "column count: unexpected value of type string")
+	err = w.Write(nil, nil, nil, nil, ts, int64(4))
+	assert.EqualError(t, err, "column id: missing value")
+	err = w.Write("4")
+	assert.EqualError(t, err, "expected 6 values, got 1")
+
+	assert.NoError(t, w.Close())
+	assert.Error(t, w.Write("4", nil, nil, nil, ts, int64(4)))
+
+	data := buf.Bytes()
+	meta := readFooter(t, data)
+	assert.Equal(t, int64(1), meta[1])
+	assert.Equal(t, int64(3), meta[3])
+	assert.Equal(t, createdBy, meta[6])
+
+	schema := meta[2].([]interface{})
+	if !assert.Len(t, schema, 7) {
+		t.FailNow()
+	}
+	assert.Equal(t, map[int16]interface{}{4: "schema", 5: int64(6)}, schema[0])
+	assert.Equal(t, map[int16]interface{}{
+		1: int64(physicalByteArray),
+		3: int64(repetitionRequired),
+		4: "id",
+		6: int64(convertedUTF8),
+	}, schema[1])
+	assert.Equal(t, map[int16]interface{}{
+		1: int64(physicalDouble),
+		3: int64(repetitionOptional),
+		4: "count",
+	}, schema[2])
+	assert.Equal(t, int64(convertedTimestampMillis),
+		schema[5].(map[int16]interface{})[6])
+
+	groups := meta[4].([]interface{})
+	if !assert.Len(t, groups, 2) {
+		t.FailNow()
+	}
+	first := groups[0].(map[int16]interface{})
+	assert.Equal(t, int64(2), first[3])
+	second := groups[1].(map[int16]interface{})
+	assert.Equal(t, int64(1), second[3])
+
+	chunks := first[1].([]interface{})
+	if !assert.Len(t, chunks, 6) {
+		t.FailNow()
+	}
+
+	page := readPage(data, chunks[0].(map[int16]interface{}))
+	assert.Equal(t, []byte{1, 0, 0, 0, '1', 1, 0, 0, 0, '2'}, page)
+
+	// definition levels: a run of one present value, a run of one null
+	page = readPage(data, chunks[1].(map[int16]interface{}))
+	if !assert.Len(t, page, 4+4+8) {
+		t.FailNow()
+	}
+	assert.Equal(t, []byte{4, 0, 0, 0, 2, 1, 2, 0}, page[:8])
+	assert.Equal(t, 4.0,
+		math.Float64frombits(binary.LittleEndian.Uint64(page[8:])))
+
+	page = readPage(data, chunks[3].(map[int16]interface{}))
+	assert.Equal(t, `["a"]`, string(page[8+4:]))
+
+	page = readPage(data, chunks[4].(map[int16]interface{}))
+	assert.Equal(t, ts.UnixNano()/int64(time.Millisecond),
+		int64(binary.LittleEndian.Uint64(page[:8])))
+
+	chunks = second[1].([]interface{})
+	page = readPage(data, chunks[2].(map[int16]interface{}))
+	assert.Equal(t, []byte{2, 0, 0, 0, 2, 1, 0}, page)
+}
+
+func TestWriterEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, []Column{{Name: "id", Type: String}})
+	assert.NoError(t, w.Close())
+
+	meta := readFooter(t, buf.Bytes())
+	assert.Equal(t, int64(0), meta[3])
+	assert.Empty(t, meta[4])
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestWriterError(t *testing.T) {
+	w := NewWriter(failingWriter{}, []Column{{Name: "id", Type: String}}).
+		WithRowGroupSize(1)
+	err := w.Write("1")
+	assert.EqualError(t, err, "parquet: failed to write: disk full")
+	assert.EqualError(t, w.Close(), "parquet: failed to write: disk full")
+}
@@ -0,0 +1,111 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+// Package reqctx gathers the tenant, the request ID and the caller of
+// a request in a single structure, extracted from the request context, so
+// that logging, metrics, the database selection and the audit records all
+// read them the same way.
+package reqctx
+
+import (
+	"context"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/mendersoftware/go-lib-micro/requestid"
+	mstore "github.com/mendersoftware/go-lib-micro/store"
+)
+
+// Info describes the request a context belongs to.
+type Info struct {
+	TenantID  string
+	RequestID string
+	// Subject is the ID of the user or the device which issued the
+	// request; it is empty for internal service calls.
+	Subject  string
+	IsUser   bool
+	IsDevice bool
+
+	// identity is the identity the info was extracted from; used to detect
+	// contexts derived with a different identity, e.g. of another tenant.
+	identity *identity.Identity
+}
+
+type infoContextKey struct{}
+
+func extract(ctx context.Context) Info {
+	info := Info{
+		RequestID: requestid.FromContext(ctx),
+		identity:  identity.FromContext(ctx),
+	}
+	if id := info.identity; id != nil {
+		info.TenantID = id.Tenant
+		info.Subject = id.Subject
+		info.IsUser = id.IsUser
+		info.IsDevice = id.IsDevice
+	}
+	return info
+}
+
+// FromContext returns the info about the request the context belongs to;
+// the info stored in the context is reused unless the identity or
+// the request ID of the context changed since it was stored.
+func FromContext(ctx context.Context) Info {
+	info, ok := ctx.Value(infoContextKey{}).(Info)
+	if ok &&
+		info.identity == identity.FromContext(ctx) &&
+		info.RequestID == requestid.FromContext(ctx) {
+		return info
+	}
+	return extract(ctx)
+}
+
+// WithContext extracts the info about the request from the context and
+// stores it in the returned context.
+func WithContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, infoContextKey{}, extract(ctx))
+}
+
+// DbName returns the name of the database of the tenant.
+func (info Info) DbName(baseDb string) string {
+	return mstore.DbNameForTenant(info.TenantID, baseDb)
+}
+
+// LogContext returns the non-empty fields of the info to be added to
+// the log entries.
+func (info Info) LogContext() log.Ctx {
+	ctx := log.Ctx{}
+	if info.TenantID != "" {
+		ctx["tenant_id"] = info.TenantID
+	}
+	if info.RequestID != "" {
+		ctx["request_id"] = info.RequestID
+	}
+	if info.Subject != "" {
+		ctx["subject"] = info.Subject
+	}
+	return ctx
+}
+
+// Middleware stores the info about the request in the request context; it
+// must follow the request ID and the identity middlewares.
+type Middleware struct{}
+
+func (mw *Middleware) MiddlewareFunc(h rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		r.Request = r.Request.WithContext(WithContext(r.Context()))
+		h(w, r)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reqctx
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/mendersoftware/go-lib-micro/requestid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromContext(t *testing.T) {
+	ctx := requestid.WithContext(context.Background(), "req")
+	ctx = identity.WithContext(ctx, &identity.Identity{
+		Subject: "user",
+		Tenant:  "tenant",
+		IsUser:  true,
+	})
+
+	info := FromContext(ctx)
+	assert.Equal(t, "tenant", info.TenantID)
+	assert.Equal(t, "req", info.RequestID)
+	assert.Equal(t, "user", info.Subject)
+	assert.True(t, info.IsUser)
+	assert.False(t, info.IsDevice)
+	assert.Equal(t, "inventory-tenant", info.DbName("inventory"))
+	assert.Equal(t, log.Ctx{
+		"tenant_id":  "tenant",
+		"request_id": "req",
+		"subject":    "user",
+	}, info.LogContext())
+
+	// stored info is reused
+	ctx = WithContext(ctx)
+	assert.Equal(t, info, FromContext(ctx))
+
+	// unless the identity changed
+	tctx := identity.WithContext(ctx, &identity.Identity{Tenant: "other"})
+	info = FromContext(tctx)
+	assert.Equal(t, "other", info.TenantID)
+	assert.Equal(t, "req", info.RequestID)
+	assert.Empty(t, info.Subject)
+	assert.Equal(t, "inventory-other", info.DbName("inventory"))
+
+	info = FromContext(context.Background())
+	assert.Empty(t, info.TenantID)
+	assert.Equal(t, "inventory", info.DbName("inventory"))
+	assert.Equal(t, log.Ctx{}, info.LogContext())
+}
+
+func TestMiddleware(t *testing.T) {
+	var info Info
+	api := rest.NewApi()
+	api.Use(
+		&requestid.RequestIdMiddleware{},
+		&identity.IdentityMiddleware{},
+		&Middleware{},
+	)
+	api.SetApp(rest.AppSimple(func(w rest.ResponseWriter, r *rest.Request) {
+		var ok bool
+		info, ok = r.Context().Value(infoContextKey{}).(Info)
+		assert.True(t, ok)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+	req.Header.Set(requestid.RequestIdHeader, "req")
+	req.Header.Set("Authorization", "Bearer foo."+
+		"eyJzdWIiOiJkZXYiLCJtZW5kZXIudGVuYW50IjoidGVuYW50IiwibWVuZGVyLmRldmljZSI6dHJ1ZX0"+
+		".bar")
+	test.RunRequest(t, api.MakeHandler(), req).CodeIs(http.StatusNoContent)
+
+	assert.Equal(t, "tenant", info.TenantID)
+	assert.Equal(t, "req", info.RequestID)
+	assert.Equal(t, "dev", info.Subject)
+	assert.True(t, info.IsDevice)
+}
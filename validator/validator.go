@@ -0,0 +1,84 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+// Package validator consults the validation webhooks of the tenants
+// before the attributes written by the users are accepted.
+package validator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+//go:generate ../utils/mockgen.sh
+type Validator interface {
+	Validate(
+		ctx context.Context,
+		hook model.ValidationWebhook,
+		req model.AttributesValidationRequest,
+	) (*model.AttributesValidationResponse, error)
+}
+
+// WebhookValidator posts the attributes to the validation webhook and
+// returns its verdict.
+type WebhookValidator struct {
+	client *http.Client
+}
+
+func NewWebhookValidator() *WebhookValidator {
+	return &WebhookValidator{
+		client: &http.Client{},
+	}
+}
+
+func (v *WebhookValidator) Validate(
+	ctx context.Context,
+	hook model.ValidationWebhook,
+	req model.AttributesValidationRequest,
+) (*model.AttributesValidationResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode the validation request")
+	}
+	ctx, cancel := context.WithTimeout(ctx, hook.Timeout())
+	defer cancel()
+	hreq, err := http.NewRequestWithContext(ctx,
+		http.MethodPost, hook.URL, bytes.NewReader(body),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to prepare the validation request")
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+	rsp, err := v.client.Do(hreq)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to call the validation webhook")
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode >= 300 {
+		return nil, errors.Errorf(
+			"validation webhook responded with %s", rsp.Status,
+		)
+	}
+	res := &model.AttributesValidationResponse{}
+	if err := json.NewDecoder(rsp.Body).Decode(res); err != nil {
+		return nil, errors.Wrap(err, "failed to decode the validation response")
+	}
+	return res, nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package validator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/inventory/model"
+)
+
+func TestWebhookValidator(t *testing.T) {
+	t.Parallel()
+
+	req := model.AttributesValidationRequest{
+		DeviceID: "1",
+		UserID:   "user",
+		Attributes: model.DeviceAttributes{
+			{Name: "location", Value: "lab", Scope: model.AttrScopeInventory},
+		},
+	}
+	testCases := map[string]struct {
+		status int
+		body   string
+		delay  time.Duration
+
+		res *model.AttributesValidationResponse
+		err string
+	}{
+		"ok, allowed": {
+			status: http.StatusOK,
+			body:   `{"allowed":true}`,
+			res:    &model.AttributesValidationResponse{Allowed: true},
+		},
+		"ok, rejected": {
+			status: http.StatusOK,
+			body:   `{"allowed":false,"reason":"unknown location"}`,
+			res: &model.AttributesValidationResponse{
+				Reason: "unknown location",
+			},
+		},
+		"error, webhook failure": {
+			status: http.StatusBadGateway,
+			err:    "validation webhook responded with 502 Bad Gateway",
+		},
+		"error, malformed response": {
+			status: http.StatusOK,
+			body:   `allowed`,
+			err:    "failed to decode the validation response",
+		},
+		"error, timeout": {
+			status: http.StatusOK,
+			body:   `{"allowed":true}`,
+			delay:  time.Second,
+			err:    "failed to call the validation webhook",
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					assert.Equal(t, http.MethodPost, r.Method)
+					assert.Equal(t, "application/json",
+						r.Header.Get("Content-Type"))
+					var body model.AttributesValidationRequest
+					err := json.NewDecoder(r.Body).Decode(&body)
+					assert.NoError(t, err)
+					assert.Equal(t, req, body)
+					time.Sleep(tc.delay)
+					w.WriteHeader(tc.status)
+					w.Write([]byte(tc.body))
+				},
+			))
+			defer srv.Close()
+
+			hook := model.ValidationWebhook{
+				URL:           srv.URL,
+				TimeoutMS:     100,
+				FailurePolicy: model.ValidationFailClosed,
+			}
+			res, err := NewWebhookValidator().
+				Validate(context.Background(), hook, req)
+			if tc.err != "" {
+				assert.Error(t, err)
+				assert.True(t, strings.HasPrefix(err.Error(), tc.err),
+					"unexpected error: %v", err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.res, res)
+			}
+		})
+	}
+}
@@ -0,0 +1,338 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package verifier
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/pkg/errors"
+)
+
+const (
+	// keysRefreshInterval is the minimum delay between two fetches of
+	// the key set looking up a key it does not know
+	keysRefreshInterval = time.Minute
+	// fetchTimeout is the timeout of the requests fetching the key set
+	// and the OIDC discovery document
+	fetchTimeout = 10 * time.Second
+)
+
+var (
+	ErrTokenMalformed   = errors.New("malformed token")
+	ErrTokenAlgorithm   = errors.New("unsupported token signature algorithm")
+	ErrTokenKeyUnknown  = errors.New("token signed with an unknown key")
+	ErrTokenSignature   = errors.New("invalid token signature")
+	ErrTokenExpired     = errors.New("token expired")
+	ErrTokenNotYetValid = errors.New("token not valid yet")
+	ErrTokenIssuer      = errors.New("token issued by another issuer")
+)
+
+// JWKS verifies the signatures of the tokens with the keys of the JSON Web
+// Key Set served on a URL. The set is fetched again when a token is
+// signed with a key it does not know, at most once per minute, so that
+// the rotated keys are picked up.
+type JWKS struct {
+	url    string
+	issuer string
+	client *http.Client
+	now    func() time.Time
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+// NewJWKS returns the verifier of the tokens signed with the keys served
+// on the URL. If the issuer is not empty, the tokens must carry it in
+// their iss claim.
+func NewJWKS(url, issuer string) *JWKS {
+	return &JWKS{
+		url:    url,
+		issuer: issuer,
+		client: &http.Client{},
+		now:    time.Now,
+	}
+}
+
+func (v *JWKS) Verify(r *http.Request) error {
+	token, err := identity.ExtractJWTFromHeader(r)
+	if err != nil {
+		return err
+	}
+	return v.verifyToken(r.Context(), token)
+}
+
+type tokenHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+type tokenClaims struct {
+	Issuer    string `json:"iss"`
+	ExpiresAt *int64 `json:"exp"`
+	NotBefore *int64 `json:"nbf"`
+}
+
+func (v *JWKS) verifyToken(ctx context.Context, token string) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ErrTokenMalformed
+	}
+	var header tokenHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return ErrTokenMalformed
+	}
+	key, err := v.key(ctx, header.KeyID)
+	if err != nil {
+		return err
+	}
+	err = verifySignature(header.Algorithm, key, parts[0]+"."+parts[1], sig)
+	if err != nil {
+		return err
+	}
+
+	var claims tokenClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return err
+	}
+	now := v.now().Unix()
+	if claims.ExpiresAt != nil && now >= *claims.ExpiresAt {
+		return ErrTokenExpired
+	} else if claims.NotBefore != nil && now < *claims.NotBefore {
+		return ErrTokenNotYetValid
+	} else if v.issuer != "" && claims.Issuer != v.issuer {
+		return ErrTokenIssuer
+	}
+	return nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return ErrTokenMalformed
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return ErrTokenMalformed
+	}
+	return nil
+}
+
+// key returns the key of the ID, fetching the key set if it is not
+// known. Tokens without a key ID are verified with the only key of
+// the set.
+func (v *JWKS) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	lookup := func() (crypto.PublicKey, bool) {
+		if kid == "" && len(v.keys) == 1 {
+			for _, key := range v.keys {
+				return key, true
+			}
+		}
+		key, ok := v.keys[kid]
+		return key, ok
+	}
+	if key, ok := lookup(); ok {
+		return key, nil
+	}
+	if !v.fetched.IsZero() && v.now().Sub(v.fetched) < keysRefreshInterval {
+		return nil, ErrTokenKeyUnknown
+	}
+	keys, err := v.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v.keys = keys
+	v.fetched = v.now()
+	if key, ok := lookup(); ok {
+		return key, nil
+	}
+	return nil, ErrTokenKeyUnknown
+}
+
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+}
+
+// fetch returns the signature keys of the key set by their IDs; the keys
+// of other types and uses are skipped.
+func (v *JWKS) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := getJSON(ctx, v.client, v.url, &set); err != nil {
+		return nil, errors.Wrap(err, "failed to fetch the key set")
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return nil, errors.Wrapf(err, "key %q", jwk.KeyID)
+		} else if key != nil {
+			keys[jwk.KeyID] = key
+		}
+	}
+	return keys, nil
+}
+
+// publicKey returns the RSA or EC public key, or nil for the other key
+// types.
+func (jwk jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch jwk.KeyType {
+	case "RSA":
+		n, err := decodeInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(jwk.E)
+		if err != nil {
+			return nil, err
+		} else if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errors.Errorf("unsupported curve %q", jwk.Curve)
+		}
+		x, err := decodeInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		} else if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("invalid EC point")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, nil
+	}
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(data) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// verifySignature verifies the signature of the signed part of the token
+// with the key; the algorithm must match the type of the key, and
+// the unsigned tokens are rejected.
+func verifySignature(alg string, key crypto.PublicKey, signed string, sig []byte) error {
+	if len(alg) != 5 {
+		return ErrTokenAlgorithm
+	}
+	var hash crypto.Hash
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return ErrTokenAlgorithm
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		var err error
+		switch alg[:2] {
+		case "RS":
+			err = rsa.VerifyPKCS1v15(key, hash, digest, sig)
+		case "PS":
+			err = rsa.VerifyPSS(key, hash, digest, sig, nil)
+		default:
+			return ErrTokenAlgorithm
+		}
+		if err != nil {
+			return ErrTokenSignature
+		}
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if alg[:2] != "ES" {
+			return ErrTokenAlgorithm
+		} else if len(sig) != 2*size {
+			return ErrTokenSignature
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return ErrTokenSignature
+		}
+	default:
+		return ErrTokenAlgorithm
+	}
+	return nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	rsp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode >= 300 {
+		return errors.Errorf("%s responded with %s", url, rsp.Status)
+	}
+	return json.NewDecoder(rsp.Body).Decode(v)
+}
@@ -0,0 +1,98 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package verifier
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const oidcDiscoveryPath = "/.well-known/openid-configuration"
+
+// OIDC verifies the tokens issued by an OpenID Connect provider with
+// the keys of the key set found in its discovery document. The tokens must
+// carry the issuer in their iss claim. The discovery is retried at most
+// once per minute until it succeeds.
+type OIDC struct {
+	issuer string
+	client *http.Client
+	now    func() time.Time
+
+	mu         sync.Mutex
+	keys       *JWKS
+	discovered time.Time
+	err        error
+}
+
+// NewOIDC returns the verifier of the tokens issued by the provider at
+// the issuer URL.
+func NewOIDC(issuer string) *OIDC {
+	return &OIDC{
+		issuer: issuer,
+		client: &http.Client{},
+		now:    time.Now,
+	}
+}
+
+func (v *OIDC) Verify(r *http.Request) error {
+	keys, err := v.keySet(r.Context())
+	if err != nil {
+		return err
+	}
+	return keys.Verify(r)
+}
+
+// keySet returns the verifier of the key set of the provider, discovering
+// it on the first call.
+func (v *OIDC) keySet(ctx context.Context) (*JWKS, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.keys != nil {
+		return v.keys, nil
+	} else if !v.discovered.IsZero() &&
+		v.now().Sub(v.discovered) < keysRefreshInterval {
+		return nil, v.err
+	}
+
+	var doc struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	url := strings.TrimSuffix(v.issuer, "/") + oidcDiscoveryPath
+	err := getJSON(ctx, v.client, url, &doc)
+	if err != nil {
+		err = errors.Wrap(err, "OIDC discovery failed")
+	} else if doc.Issuer != v.issuer {
+		err = errors.Errorf(
+			"OIDC discovery failed: the provider issuer %q "+
+				"does not match %q", doc.Issuer, v.issuer)
+	} else if doc.JWKSURI == "" {
+		err = errors.New("OIDC discovery failed: no jwks_uri")
+	}
+	v.discovered = v.now()
+	v.err = err
+	if err != nil {
+		return nil, err
+	}
+	v.keys = NewJWKS(doc.JWKSURI, doc.Issuer)
+	v.keys.client = v.client
+	v.keys.now = v.now
+	return v.keys, nil
+}
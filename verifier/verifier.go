@@ -0,0 +1,64 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+// Package verifier authenticates the requests before the identity of
+// the caller is taken from their token. The default passthrough verifier
+// trusts the API gateway to have verified the tokens issued by useradm and
+// deviceauth; the other verifiers let deployments without them check the
+// tokens against the keys of their identity provider or require client
+// certificates.
+package verifier
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// kinds of the verifiers, as configured for the listeners
+const (
+	KindPassthrough = "passthrough"
+	KindJWKS        = "jwks"
+	KindOIDC        = "oidc"
+	KindMTLS        = "mtls"
+)
+
+var (
+	ErrClientCertMissing = errors.New("verified client certificate required")
+)
+
+//go:generate ../utils/mockgen.sh
+type Verifier interface {
+	// Verify returns an error if the request is not authenticated.
+	Verify(r *http.Request) error
+}
+
+// Passthrough accepts all the requests.
+type Passthrough struct{}
+
+func (Passthrough) Verify(r *http.Request) error {
+	return nil
+}
+
+// ClientCert accepts the requests made over TLS connections with a client
+// certificate verified by the server, e.g. the internal calls of
+// the other services.
+type ClientCert struct{}
+
+func (ClientCert) Verify(r *http.Request) error {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return ErrClientCertMissing
+	}
+	return nil
+}
@@ -0,0 +1,298 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package verifier
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testNow = time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"n":   b64(key.N.Bytes()),
+		"e":   b64(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+// padded returns the big-endian bytes of the integer left-padded to
+// the size.
+func padded(n *big.Int, size int) []byte {
+	data := n.Bytes()
+	return append(make([]byte, size-len(data)), data...)
+}
+
+func ecJWK(kid string, key *ecdsa.PublicKey) map[string]string {
+	size := (key.Curve.Params().BitSize + 7) / 8
+	x, y := padded(key.X, size), padded(key.Y, size)
+	return map[string]string{
+		"kty": "EC",
+		"kid": kid,
+		"crv": key.Curve.Params().Name,
+		"x":   b64(x),
+		"y":   b64(y),
+	}
+}
+
+// signToken returns the token of the claims signed with the key.
+func signToken(t *testing.T, alg, kid string, key crypto.Signer, claims interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := b64(header) + "." + b64(payload)
+	digest := crypto.SHA256.New()
+	digest.Write([]byte(signed))
+	var (
+		sig []byte
+		err error
+	)
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest.Sum(nil))
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, key, digest.Sum(nil))
+		sig = append(padded(r, 32), padded(s, 32)...)
+	}
+	require.NoError(t, err)
+	return signed + "." + b64(sig)
+}
+
+func bearer(token string) *http.Request {
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestJWKS(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	keys := []map[string]string{
+		rsaJWK("rsa", &rsaKey.PublicKey),
+		ecJWK("ec", &ecKey.PublicKey),
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQ", "e": "AQAB"},
+		{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"},
+	}
+	valid := map[string]interface{}{
+		"sub": "user", "iss": "https://idp", "exp": testNow.Add(time.Hour).Unix(),
+	}
+	testCases := map[string]struct {
+		token  string
+		issuer string
+
+		err error
+	}{
+		"ok, RS256": {
+			token: signToken(t, "RS256", "rsa", rsaKey, valid),
+		},
+		"ok, ES256": {
+			token:  signToken(t, "ES256", "ec", ecKey, valid),
+			issuer: "https://idp",
+		},
+		"error, expired": {
+			token: signToken(t, "RS256", "rsa", rsaKey, map[string]interface{}{
+				"sub": "user", "exp": testNow.Add(-time.Minute).Unix(),
+			}),
+			err: ErrTokenExpired,
+		},
+		"error, not valid yet": {
+			token: signToken(t, "RS256", "rsa", rsaKey, map[string]interface{}{
+				"sub": "user", "nbf": testNow.Add(time.Minute).Unix(),
+			}),
+			err: ErrTokenNotYetValid,
+		},
+		"error, issuer": {
+			token:  signToken(t, "RS256", "rsa", rsaKey, valid),
+			issuer: "https://other",
+			err:    ErrTokenIssuer,
+		},
+		"error, signed with another key": {
+			token: signToken(t, "RS256", "rsa", otherKey, valid),
+			err:   ErrTokenSignature,
+		},
+		"error, unknown key": {
+			token: signToken(t, "RS256", "other", otherKey, valid),
+			err:   ErrTokenKeyUnknown,
+		},
+		"error, algorithm of another key type": {
+			token: signToken(t, "ES256", "rsa", rsaKey, valid),
+			err:   ErrTokenAlgorithm,
+		},
+		"error, unsigned": {
+			token: b64([]byte(`{"alg":"none","kid":"rsa"}`)) + "." +
+				b64([]byte(`{"sub":"user"}`)) + ".",
+			err: ErrTokenAlgorithm,
+		},
+		"error, encryption key": {
+			token: signToken(t, "RS256", "enc", rsaKey, valid),
+			err:   ErrTokenKeyUnknown,
+		},
+		"error, malformed": {
+			token: "not-a-token",
+			err:   ErrTokenMalformed,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+				}))
+			defer srv.Close()
+
+			v := NewJWKS(srv.URL, tc.issuer)
+			v.now = func() time.Time { return testNow }
+			err := v.Verify(bearer(tc.token))
+			if tc.err != nil {
+				assert.Equal(t, tc.err, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestJWKSRotation(t *testing.T) {
+	t.Parallel()
+
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var (
+		fetches int32
+		keys    atomic.Value
+	)
+	keys.Store([]map[string]string{rsaJWK("old", &oldKey.PublicKey)})
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&fetches, 1)
+			json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys.Load()})
+		}))
+	defer srv.Close()
+
+	now := testNow
+	v := NewJWKS(srv.URL, "")
+	v.now = func() time.Time { return now }
+	claims := map[string]interface{}{"sub": "user"}
+
+	assert.NoError(t, v.Verify(bearer(signToken(t, "RS256", "old", oldKey, claims))))
+	assert.NoError(t, v.Verify(bearer(signToken(t, "RS256", "old", oldKey, claims))))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+
+	// the rotated key is picked up once the refresh interval is over
+	keys.Store([]map[string]string{rsaJWK("new", &newKey.PublicKey)})
+	newToken := signToken(t, "RS256", "new", newKey, claims)
+	assert.Equal(t, ErrTokenKeyUnknown, v.Verify(bearer(newToken)))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+
+	now = now.Add(keysRefreshInterval)
+	assert.NoError(t, v.Verify(bearer(newToken)))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetches))
+}
+
+func TestOIDC(t *testing.T) {
+	t.Parallel()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var issuer string
+	mux := http.NewServeMux()
+	mux.HandleFunc(oidcDiscoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":   issuer,
+			"jwks_uri": issuer + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{rsaJWK("rsa", &key.PublicKey)},
+		})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	issuer = srv.URL
+
+	v := NewOIDC(issuer)
+	v.now = func() time.Time { return testNow }
+	err = v.Verify(bearer(signToken(t, "RS256", "rsa", key, map[string]interface{}{
+		"sub": "user", "iss": issuer,
+	})))
+	assert.NoError(t, err)
+
+	err = v.Verify(bearer(signToken(t, "RS256", "rsa", key, map[string]interface{}{
+		"sub": "user", "iss": "https://other",
+	})))
+	assert.Equal(t, ErrTokenIssuer, err)
+
+	v = NewOIDC(issuer + "/realm")
+	v.now = func() time.Time { return testNow }
+	err = v.Verify(bearer(signToken(t, "RS256", "rsa", key, map[string]interface{}{
+		"sub": "user", "iss": issuer + "/realm",
+	})))
+	assert.EqualError(t, err, "OIDC discovery failed: "+srv.URL+"/realm"+
+		oidcDiscoveryPath+" responded with 404 Not Found")
+}
+
+func TestClientCert(t *testing.T) {
+	t.Parallel()
+
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+	assert.Equal(t, ErrClientCertMissing, ClientCert{}.Verify(req))
+
+	req.TLS = &tls.ConnectionState{}
+	assert.Equal(t, ErrClientCertMissing, ClientCert{}.Verify(req))
+
+	req.TLS.VerifiedChains = [][]*x509.Certificate{{{}}}
+	assert.NoError(t, ClientCert{}.Verify(req))
+
+	assert.NoError(t, Passthrough{}.Verify(req))
+}